@@ -0,0 +1,113 @@
+package alerting
+
+import (
+	"RoyDental/config"
+	"RoyDental/metrics"
+	"RoyDental/notifications"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Monitor periodically compares counters with their previous values and notifies when one grows
+// faster than its rule allows. Counters are per process, so every replica alerts on its own share.
+type Monitor struct {
+	config    config.AlertingConfig
+	notifier  notifications.Notifier
+	last      map[string]float64
+	lastAlert map[string]time.Time
+}
+
+func NewMonitor(cfg config.AlertingConfig, notifier notifications.Notifier) *Monitor {
+	return &Monitor{
+		config:    cfg,
+		notifier:  notifier,
+		last:      map[string]float64{},
+		lastAlert: map[string]time.Time{},
+	}
+}
+
+// NewNotifier sends alerts to the configured email recipients and the operational_alert chat
+// channel, or only logs them when neither is configured.
+func NewNotifier(cfg config.AlertingConfig) notifications.Notifier {
+	var notifiers notifications.MultiNotifier
+	if len(cfg.Recipients) > 0 {
+		if email, err := notifications.NewEmailNotifierFromEnv(); err != nil {
+			log.Printf("Operational alerts will not be emailed: %v", err)
+		} else {
+			notifiers = append(notifiers, email)
+		}
+	}
+	if chat := notifications.ForEvent(notifications.EventOperationalAlert); chat != nil {
+		notifiers = append(notifiers, chat)
+	}
+	if len(notifiers) == 0 {
+		return notifications.LogNotifier{Printf: log.Printf}
+	}
+	return notifiers
+}
+
+// Run checks the rules every interval until the context is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	// The first check only records a baseline
+	m.check(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.check(ctx, now)
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context, now time.Time) {
+	for _, rule := range m.config.Rules {
+		counter := metrics.LookupCounter(rule.Metric)
+		if rule.Threshold <= 0 || counter == nil {
+			continue
+		}
+
+		value := counter.Total()
+		if len(rule.Labels) > 0 {
+			value = counter.Value(rule.Labels...)
+		}
+		previous, seen := m.last[rule.Name]
+		m.last[rule.Name] = value
+		if !seen {
+			continue
+		}
+
+		increase := value - previous
+		if increase < rule.Threshold || now.Sub(m.lastAlert[rule.Name]) < m.config.Cooldown {
+			continue
+		}
+		m.lastAlert[rule.Name] = now
+		m.alert(ctx, rule, increase)
+	}
+}
+
+func (m *Monitor) alert(ctx context.Context, rule config.AlertRule, increase float64) {
+	host, _ := os.Hostname()
+	series := rule.Metric
+	if len(rule.Labels) > 0 {
+		series += "{" + strings.Join(rule.Labels, ",") + "}"
+	}
+
+	alert := notifications.Notification{
+		Recipients: m.config.Recipients,
+		Subject:    fmt.Sprintf("Operational alert: %s on %s", rule.Name, host),
+		Body: fmt.Sprintf("%s increased by %g within %s on %s (threshold %g).\n\nFurther %s alerts are suppressed for %s.",
+			series, increase, m.config.Interval, host, rule.Threshold, rule.Name, m.config.Cooldown),
+	}
+	log.Printf("Operational alert: %s increased by %g", series, increase)
+	if err := m.notifier.Send(ctx, alert); err != nil {
+		log.Printf("Failed to send operational alert: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+// Package anonymize turns a copy of the clinic's database into a staging dataset without real
+// patient data. Every value is replaced by one derived from it with a secret key, so the same name,
+// phone number or identifier gets the same replacement wherever it appears and records still match
+// up, while the volumes and shape of the data stay as they were.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// dateOfBirthLayouts are the formats dates of birth are found in, including HL7's
+var dateOfBirthLayouts = []string{"2006-01-02", "02/01/2006", "2006/01/02", "02-01-2006", "20060102"}
+
+// maxBirthShift bounds how far a date of birth moves, so age bands stay roughly the same
+const maxBirthShift = 182
+
+// maxCoordinateShift bounds how far coordinates move, about two kilometres
+const maxCoordinateShift = 0.02
+
+var (
+	firstNames = []string{
+		"Amani", "Baraka", "Wanjiru", "Otieno", "Akinyi", "Kipchoge", "Njeri", "Mwangi", "Achieng", "Kamau",
+		"Zawadi", "Juma", "Halima", "Mutua", "Nafula", "Omondi", "Wairimu", "Kibet", "Atieno", "Nyambura",
+		"Chege", "Adhiambo", "Kiplagat", "Wangari", "Odera", "Jeptoo", "Makena", "Ochieng", "Nekesa", "Rotich",
+		"Imani", "Kioko", "Auma", "Githinji", "Chepkoech", "Mumbi", "Wafula", "Awino", "Kimani", "Shiku",
+	}
+	lastNames = []string{
+		"Odhiambo", "Kariuki", "Wambui", "Kiprono", "Chebet", "Ndungu", "Onyango", "Wekesa", "Muthoni", "Barasa",
+		"Kosgei", "Njoroge", "Owino", "Kirui", "Mugo", "Simiyu", "Gathoni", "Okoth", "Langat", "Macharia",
+		"Nyaga", "Oduor", "Koech", "Wanyama", "Gitau", "Achola", "Mbugua", "Ruto", "Kamande", "Omollo",
+	}
+)
+
+// Anonymizer derives replacement values with a secret key. Runs with the same key replace values
+// the same way.
+type Anonymizer struct {
+	key []byte
+}
+
+func New(key []byte) *Anonymizer {
+	return &Anonymizer{key: key}
+}
+
+// sum returns the keyed hash of a value of some kind, so equal values of different kinds differ
+func (a *Anonymizer) sum(kind, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func (a *Anonymizer) number(kind, value string) uint64 {
+	return binary.BigEndian.Uint64(a.sum(kind, value))
+}
+
+func (a *Anonymizer) pick(list []string, kind, value string) string {
+	return list[a.number(kind, value)%uint64(len(list))]
+}
+
+// FirstName replaces a given or middle name
+func (a *Anonymizer) FirstName(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+	return a.pick(firstNames, "first_name", strings.ToLower(strings.TrimSpace(value)))
+}
+
+// LastName replaces a family name
+func (a *Anonymizer) LastName(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+	return a.pick(lastNames, "last_name", strings.ToLower(strings.TrimSpace(value)))
+}
+
+// FullName replaces a name written as "First Middle Last", or as DICOM's "Last^First^Middle", name
+// by name, so it matches the names replaced on the patient's record.
+func (a *Anonymizer) FullName(value string) string {
+	if strings.Contains(value, "^") {
+		parts := strings.Split(value, "^")
+		parts[0] = a.LastName(parts[0])
+		for i := 1; i < len(parts); i++ {
+			parts[i] = a.FirstName(parts[i])
+		}
+		return strings.Join(parts, "^")
+	}
+	parts := strings.Fields(value)
+	for i := range parts {
+		if i == len(parts)-1 && i > 0 {
+			parts[i] = a.LastName(parts[i])
+		} else {
+			parts[i] = a.FirstName(parts[i])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Phone replaces the digits of a phone number after its first three, keeping its format and prefix
+func (a *Anonymizer) Phone(value string) string {
+	digits := a.sum("phone", digitsOf(value))
+	var b strings.Builder
+	seen := 0
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			b.WriteRune(r)
+			continue
+		}
+		if seen < 3 {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('0' + digits[seen%len(digits)]%10)
+		}
+		seen++
+	}
+	return b.String()
+}
+
+// Email replaces an email address with one at a reserved domain that can never be delivered to
+func (a *Anonymizer) Email(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+	return fmt.Sprintf("patient.%x@example.com", a.sum("email", strings.ToLower(strings.TrimSpace(value)))[:5])
+}
+
+// Street replaces the street part of an address. Cities and counties are kept, as they are shared
+// by many patients and make up the catchment reports.
+func (a *Anonymizer) Street(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+	return fmt.Sprintf("P.O. Box %d", 100+a.number("street", value)%9900)
+}
+
+// Coordinate moves a latitude or longitude by up to maxCoordinateShift degrees, so distances from
+// the clinic stay roughly the same but nobody's home can be found. Values that are not numbers
+// are returned unchanged.
+func (a *Anonymizer) Coordinate(value string) string {
+	degrees, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return value
+	}
+	shift := float64(a.number("coordinate", value)%20001)/10000 - 1
+	return strconv.FormatFloat(degrees+shift*maxCoordinateShift, 'f', 6, 64)
+}
+
+// DateOfBirth moves a date of birth by up to half a year, keeping its format. Dates that cannot be
+// read are scrambled.
+func (a *Anonymizer) DateOfBirth(value string) string {
+	trimmed := strings.TrimSpace(value)
+	for _, layout := range dateOfBirthLayouts {
+		born, err := time.Parse(layout, trimmed)
+		if err != nil {
+			continue
+		}
+		shift := int(a.number("date_of_birth", born.Format("2006-01-02"))%(2*maxBirthShift+1)) - maxBirthShift
+		return born.AddDate(0, 0, shift).Format(layout)
+	}
+	return a.Identifier(value)
+}
+
+// IP replaces an IP address with one from the range reserved for documentation
+func (a *Anonymizer) IP(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return value
+	}
+	return fmt.Sprintf("192.0.2.%d", 1+a.number("ip", value)%254)
+}
+
+// Identifier replaces every letter and digit of an identifier such as a national ID or insurance
+// member number, keeping its length and punctuation
+func (a *Anonymizer) Identifier(value string) string {
+	return a.scramble("identifier", value)
+}
+
+// Text scrambles free text word by word. Each word becomes a made-up word of the same length and
+// case, the same one every time, so texts keep their length and repetition but say nothing.
+func (a *Anonymizer) Text(value string) string {
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		if len(word) > 0 {
+			b.WriteString(a.scramble("text", string(word)))
+			word = word[:0]
+		}
+	}
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// JSONText scrambles the text values of a JSON object or array, such as the custom fields of a
+// patient or the recipients of a message, as Text does, leaving keys, numbers and booleans alone
+func (a *Anonymizer) JSONText(value string) string {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return value
+	}
+	switch decoded := decoded.(type) {
+	case map[string]interface{}:
+		for key, v := range decoded {
+			if text, ok := v.(string); ok {
+				decoded[key] = a.Text(text)
+			}
+		}
+	case []interface{}:
+		for i, v := range decoded {
+			if text, ok := v.(string); ok {
+				decoded[i] = a.Text(text)
+			}
+		}
+	default:
+		return value
+	}
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+// scramble replaces letters by letters of the same case and digits by digits, derived from the
+// value regardless of its case
+func (a *Anonymizer) scramble(kind, value string) string {
+	sum := a.sum(kind, strings.ToLower(value))
+	var b strings.Builder
+	i := 0
+	for _, r := range value {
+		n := sum[i%len(sum)] ^ byte(i/len(sum))
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteByte('0' + n%10)
+		case unicode.IsUpper(r):
+			b.WriteByte('A' + n%26)
+		case unicode.IsLetter(r):
+			b.WriteByte('a' + n%26)
+		default:
+			b.WriteRune(r)
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
+
+func digitsOf(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,310 @@
+package anonymize
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// batchSize is the number of rows read at a time
+const batchSize = 500
+
+// column is a column holding personal data and how its values are replaced
+type column struct {
+	Name    string
+	Replace func(a *Anonymizer, value string) string
+}
+
+// table lists the personal columns of a table keyed by id, or by the columns of Key. AppendOnly names
+// the trigger that keeps the table append-only, which is switched off while the table is rewritten.
+type table struct {
+	Name       string
+	Key        []string
+	Columns    []column
+	AppendOnly string
+}
+
+// tables lists every column holding patient data. Names, contact details and identifiers are
+// replaced the same way wherever they appear; clinical and other free text is scrambled.
+var tables = []table{
+	{Name: "patient", Columns: []column{
+		{"first_name", (*Anonymizer).FirstName},
+		{"middle_name", (*Anonymizer).FirstName},
+		{"last_name", (*Anonymizer).LastName},
+		{"date_of_birth", (*Anonymizer).DateOfBirth},
+		{"phone", (*Anonymizer).Phone},
+		{"email", (*Anonymizer).Email},
+		{"address_street", (*Anonymizer).Street},
+		{"address_latitude", (*Anonymizer).Coordinate},
+		{"address_longitude", (*Anonymizer).Coordinate},
+		{"guarantor_name", (*Anonymizer).FullName},
+		{"guarantor_phone", (*Anonymizer).Phone},
+		{"guarantor_email", (*Anonymizer).Email},
+		{"national_id", (*Anonymizer).Identifier},
+		{"member_number", (*Anonymizer).Identifier},
+		{"place_of_work", (*Anonymizer).Text},
+		{"custom_fields", (*Anonymizer).JSONText},
+	}},
+	{Name: "emergency_contact", Columns: []column{
+		{"name", (*Anonymizer).FullName},
+		{"phone", (*Anonymizer).Phone},
+	}},
+	{Name: "pending_registration", Columns: []column{
+		{"first_name", (*Anonymizer).FirstName},
+		{"middle_name", (*Anonymizer).FirstName},
+		{"last_name", (*Anonymizer).LastName},
+		{"date_of_birth", (*Anonymizer).DateOfBirth},
+		{"phone", (*Anonymizer).Phone},
+		{"email", (*Anonymizer).Email},
+		{"address_street", (*Anonymizer).Street},
+		{"guarantor_name", (*Anonymizer).FullName},
+		{"guarantor_phone", (*Anonymizer).Phone},
+		{"guarantor_email", (*Anonymizer).Email},
+		{"medical_history", (*Anonymizer).Text},
+		{"allergies", (*Anonymizer).Text},
+		{"medications", (*Anonymizer).Text},
+		{"review_note", (*Anonymizer).Text},
+		{"ip", (*Anonymizer).IP},
+	}},
+	{Name: "appointment_request", Columns: []column{
+		{"first_name", (*Anonymizer).FirstName},
+		{"last_name", (*Anonymizer).LastName},
+		{"phone", (*Anonymizer).Phone},
+		{"email", (*Anonymizer).Email},
+		{"message", (*Anonymizer).Text},
+		{"review_note", (*Anonymizer).Text},
+		{"ip", (*Anonymizer).IP},
+	}},
+	{Name: "household", Columns: []column{
+		{"name", (*Anonymizer).LastName},
+		{"phone", (*Anonymizer).Phone},
+		{"address_street", (*Anonymizer).Street},
+	}},
+	{Name: "patient_contact_update", Columns: []column{
+		{"phone", (*Anonymizer).Phone},
+		{"email", (*Anonymizer).Email},
+		{"address_street", (*Anonymizer).Street},
+	}},
+	{Name: "patient_verification", Columns: []column{
+		{"reference", (*Anonymizer).Identifier},
+		{"detail", (*Anonymizer).Text},
+		{"resolution_note", (*Anonymizer).Text},
+	}},
+	{Name: "hl7_message", Columns: []column{
+		{"pid_first_name", (*Anonymizer).FirstName},
+		{"pid_middle_name", (*Anonymizer).FirstName},
+		{"pid_last_name", (*Anonymizer).LastName},
+		{"pid_date_of_birth", (*Anonymizer).DateOfBirth},
+		{"pid_phone", (*Anonymizer).Phone},
+		{"pid_address_street", (*Anonymizer).Street},
+		{"reason", (*Anonymizer).Text},
+		{"raw", (*Anonymizer).Text},
+	}},
+	{Name: "imaging_study", Columns: []column{
+		{"source_patient_name", (*Anonymizer).FullName},
+	}},
+	{Name: "controlled_register", AppendOnly: "controlled_register_append_only", Columns: []column{
+		{"patient_name", (*Anonymizer).FullName},
+	}},
+	{Name: "examination", Columns: []column{{"report", (*Anonymizer).Text}}},
+	{Name: "examination_audio_note", Columns: []column{{"title", (*Anonymizer).Text}, {"transcript", (*Anonymizer).Text}}},
+	{Name: "treatment_plan", Columns: []column{{"plan", (*Anonymizer).Text}}},
+	{Name: "material_usage", Columns: []column{{"notes", (*Anonymizer).Text}}},
+	{Name: "case_image_pair", Columns: []column{{"notes", (*Anonymizer).Text}}},
+	{Name: "eligibility_check", Columns: []column{
+		{"member_number", (*Anonymizer).Identifier},
+		{"detail", (*Anonymizer).Text},
+	}},
+	{Name: "treatment_plan_version", Columns: []column{
+		{"plan", (*Anonymizer).Text},
+		{"reason", (*Anonymizer).Text},
+	}},
+	{Name: "appointment", Columns: []column{
+		{"emergency_reason", (*Anonymizer).Text},
+		{"custom_fields", (*Anonymizer).JSONText},
+	}},
+	{Name: "handover_note", Columns: []column{{"body", (*Anonymizer).Text}}},
+	{Name: "patient_alert", Columns: []column{{"note", (*Anonymizer).Text}}},
+	{Name: "patient_note", Columns: []column{{"body", (*Anonymizer).Text}}},
+	{Name: "referral", Columns: []column{
+		{"reason", (*Anonymizer).Text},
+		{"letter", (*Anonymizer).Text},
+		{"status_note", (*Anonymizer).Text},
+	}},
+	{Name: "referral_examination", Columns: []column{{"excerpt", (*Anonymizer).Text}}},
+	{Name: "prescription", Columns: []column{{"instructions", (*Anonymizer).Text}}},
+	{Name: "vitals", Columns: []column{{"notes", (*Anonymizer).Text}}},
+	{Name: "survey", Columns: []column{{"comment", (*Anonymizer).Text}}},
+	{Name: "payment_plan", Columns: []column{{"description", (*Anonymizer).Text}}},
+	{Name: "billing_dispute", Columns: []column{
+		{"reason", (*Anonymizer).Text},
+		{"resolution_note", (*Anonymizer).Text},
+	}},
+	{Name: "consent_record", Columns: []column{{"given_by", (*Anonymizer).FullName}}},
+	// Texts to emergency contacts are logged with the patient's other messages
+	{Name: "communication_log", Columns: []column{
+		{"recipient", (*Anonymizer).Text},
+		{"subject", (*Anonymizer).Text},
+		{"body", (*Anonymizer).Text},
+	}},
+	{Name: "campaign_recipient", Key: []string{"campaign_id", "patient_id"}, Columns: []column{
+		{"recipient", (*Anonymizer).Text},
+	}},
+	{Name: "sms_reply", Columns: []column{
+		{"from_number", (*Anonymizer).Phone},
+		{"body", (*Anonymizer).Text},
+		{"note", (*Anonymizer).Text},
+	}},
+	{Name: "deferred_message", Columns: []column{
+		{"recipients", (*Anonymizer).JSONText},
+		{"subject", (*Anonymizer).Text},
+		{"body", (*Anonymizer).Text},
+	}},
+	{Name: "failed_delivery", Columns: []column{
+		{"recipients", (*Anonymizer).JSONText},
+		{"subject", (*Anonymizer).Text},
+		{"body", (*Anonymizer).Text},
+	}},
+	{Name: "email_suppression", Columns: []column{
+		{"address", (*Anonymizer).Email},
+		{"diagnostic", (*Anonymizer).Text},
+	}},
+	{Name: "document_share", Columns: []column{
+		{"recipient", (*Anonymizer).Text},
+		{"message", (*Anonymizer).Text},
+	}},
+	{Name: "document_share_access", Columns: []column{{"ip", (*Anonymizer).IP}}},
+	{Name: "recall", Columns: []column{{"reason", (*Anonymizer).Text}}},
+	{Name: "task", Columns: []column{
+		{"title", (*Anonymizer).Text},
+		{"description", (*Anonymizer).Text},
+	}},
+	{Name: "break_glass_access", Columns: []column{
+		{"reason", (*Anonymizer).Text},
+		{"review_note", (*Anonymizer).Text},
+		{"ip", (*Anonymizer).IP},
+	}},
+	{Name: "clinical_audit_item", Columns: []column{{"comments", (*Anonymizer).Text}}},
+	{Name: "audit_logs", Columns: []column{
+		{"ip", (*Anonymizer).IP},
+		{"detail", (*Anonymizer).Text},
+	}},
+}
+
+// Result counts the rows rewritten per table
+type Result struct {
+	Rows map[string]int
+}
+
+// Run rewrites the patient data in db in a single transaction, so a failed run leaves nothing half
+// anonymized. It does not touch stored files such as attachments and images, nor the cache.
+func Run(ctx context.Context, db *gorm.DB, a *Anonymizer) (*Result, error) {
+	result := &Result{Rows: map[string]int{}}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			rows, err := rewrite(tx, a, t)
+			if err != nil {
+				return err
+			}
+			result.Rows[t.Name] = rows
+			log.Printf("Anonymized %d %s rows", rows, t.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func rewrite(tx *gorm.DB, a *Anonymizer, t table) (int, error) {
+	if t.AppendOnly != "" {
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q DISABLE TRIGGER %q`, t.Name, t.AppendOnly)).Error; err != nil {
+			return 0, fmt.Errorf("failed to disable trigger %s: %w", t.AppendOnly, err)
+		}
+	}
+
+	key := t.Key
+	if len(key) == 0 {
+		key = []string{"id"}
+	}
+	names := append([]string(nil), key...)
+	for _, c := range t.Columns {
+		names = append(names, c.Name)
+	}
+	keyList := strings.Join(key, ", ")
+	after := fmt.Sprintf("(%s) > (%s)", keyList, strings.TrimSuffix(strings.Repeat("?, ", len(key)), ", "))
+
+	count := 0
+	var last []any
+	for {
+		query := tx.Table(t.Name).Select(names).Order(keyList).Limit(batchSize)
+		if last != nil {
+			query = query.Where(after, last...)
+		}
+		rows, err := query.Rows()
+		if err != nil {
+			return count, fmt.Errorf("failed to read %s: %w", t.Name, err)
+		}
+
+		var ids [][]any
+		var values [][]sql.NullString
+		for rows.Next() {
+			id := make([]any, len(key))
+			row := make([]sql.NullString, len(t.Columns))
+			dest := make([]any, 0, len(names))
+			for i := range id {
+				dest = append(dest, &id[i])
+			}
+			for i := range row {
+				dest = append(dest, &row[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return count, fmt.Errorf("failed to read %s: %w", t.Name, err)
+			}
+			ids = append(ids, id)
+			values = append(values, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, fmt.Errorf("failed to read %s: %w", t.Name, err)
+		}
+
+		for i, id := range ids {
+			updates := map[string]any{}
+			for j, c := range t.Columns {
+				if values[i][j].Valid && values[i][j].String != "" {
+					updates[c.Name] = c.Replace(a, values[i][j].String)
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			where := tx.Table(t.Name)
+			for j, column := range key {
+				where = where.Where(column+" = ?", id[j])
+			}
+			if err := where.UpdateColumns(updates).Error; err != nil {
+				return count, fmt.Errorf("failed to anonymize %s %v: %w", t.Name, id, err)
+			}
+			count++
+		}
+
+		if len(ids) < batchSize {
+			break
+		}
+		last = ids[len(ids)-1]
+	}
+
+	if t.AppendOnly != "" {
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q ENABLE TRIGGER %q`, t.Name, t.AppendOnly)).Error; err != nil {
+			return count, fmt.Errorf("failed to enable trigger %s: %w", t.AppendOnly, err)
+		}
+	}
+	return count, nil
+}
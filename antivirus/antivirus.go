@@ -0,0 +1,40 @@
+// Package antivirus checks uploaded files for malware through a pluggable scanner: a ClamAV daemon,
+// or a webhook in front of a cloud scanning API.
+package antivirus
+
+import (
+	"RoyDental/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Scanner checks files for malware
+type Scanner interface {
+	// Scan returns the name of the threat found in content, or "" when it is clean
+	Scan(ctx context.Context, name string, content []byte) (string, error)
+}
+
+// New returns the configured scanner, or nil when uploads are not scanned
+func New(cfg config.VirusScanConfig) (Scanner, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "clamav":
+		if cfg.Address == "" {
+			return nil, errors.New("VIRUS_SCAN_ADDRESS is required for the clamav provider")
+		}
+		network, address, err := clamdAddress(cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		return &ClamAV{Network: network, Address: address, Timeout: cfg.Timeout}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("VIRUS_SCAN_URL is required for the webhook provider")
+		}
+		return &Webhook{URL: cfg.URL, Token: cfg.Token, Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown virus scan provider %q", cfg.Provider)
+}
@@ -0,0 +1,89 @@
+package antivirus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks a file is streamed to clamd in
+const clamdChunkSize = 64 << 10
+
+// ClamAV streams files to a clamd daemon with its INSTREAM command. Files larger than clamd's
+// StreamMaxLength are refused by the daemon and reported as errors.
+type ClamAV struct {
+	Network string // "tcp" or "unix"
+	Address string
+	Timeout time.Duration
+}
+
+func (s *ClamAV) Scan(ctx context.Context, name string, content []byte) (string, error) {
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach clamd: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(s.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("failed to send %s to clamd: %w", name, err)
+	}
+	size := make([]byte, 4)
+	for start := 0; start < len(content); start += clamdChunkSize {
+		chunk := content[start:min(start+clamdChunkSize, len(content))]
+		binary.BigEndian.PutUint32(size, uint32(len(chunk)))
+		if _, err := conn.Write(append(size, chunk...)); err != nil {
+			return "", fmt.Errorf("failed to send %s to clamd: %w", name, err)
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return "", fmt.Errorf("failed to send %s to clamd: %w", name, err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read clamd's verdict on %s: %w", name, err)
+	}
+	return clamdVerdict(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// clamdVerdict reads clamd's reply to INSTREAM: "stream: OK", "stream: <threat> FOUND" or
+// "<reason> ERROR"
+func clamdVerdict(reply string) (string, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd could not scan the file: %s", reply)
+}
+
+// clamdAddress splits a tcp://host:port or unix:///path address into a network and an address
+func clamdAddress(value string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(value, "unix://"):
+		return "unix", strings.TrimPrefix(value, "unix://"), nil
+	case strings.HasPrefix(value, "tcp://"):
+		return "tcp", strings.TrimPrefix(value, "tcp://"), nil
+	case strings.HasPrefix(value, "/"):
+		return "unix", value, nil
+	case strings.Contains(value, "://"):
+		return "", "", fmt.Errorf("invalid clamd address %q: use tcp://host:port or unix:///path", value)
+	}
+	return "tcp", value, nil
+}
@@ -0,0 +1,53 @@
+package antivirus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Webhook posts the file's content, named URL-encoded by the X-File-Name header, and expects
+// {"infected": true, "threat": "..."} or {"infected": false}
+type Webhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (w *Webhook) Scan(ctx context.Context, name string, content []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", url.PathEscape(name))
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("virus scan webhook returned %s", resp.Status)
+	}
+
+	var result struct {
+		Infected bool   `json:"infected"`
+		Threat   string `json:"threat"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode virus scan webhook response: %w", err)
+	}
+	if !result.Infected {
+		return "", nil
+	}
+	if result.Threat == "" {
+		result.Threat = "unnamed threat"
+	}
+	return result.Threat, nil
+}
@@ -0,0 +1,153 @@
+package breaker
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// State is the position of a circuit breaker.
+type State int
+
+const (
+	Closed   State = iota // Calls flow normally
+	HalfOpen              // A single probe call is allowed to test recovery
+	Open                  // Calls are rejected until the cooldown elapses
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Breaker stops calling a failing dependency after consecutive failures and probes it again after a cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	listeners []func(from, to State)
+}
+
+// New creates a closed breaker that opens after threshold consecutive failures.
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// Configure changes the failure threshold and cooldown of the breaker.
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if threshold < 1 {
+		threshold = 1
+	}
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// OnStateChange registers a listener invoked after every state transition.
+func (b *Breaker) OnStateChange(fn func(from, to State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Allow reports whether a call may proceed. Callers that get true must report Success, Failure or
+// Cancel.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	switch b.state {
+	case Closed:
+		b.mu.Unlock()
+		return true
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return false
+		}
+		b.probing = true
+		b.transition(HalfOpen)
+		return true
+	default: // HalfOpen
+		if b.probing {
+			b.mu.Unlock()
+			return false
+		}
+		b.probing = true
+		b.mu.Unlock()
+		return true
+	}
+}
+
+// Success records a successful call and closes a half-open breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	if b.state != Closed {
+		b.transition(Closed)
+		return
+	}
+	b.mu.Unlock()
+}
+
+// Failure records a failed call and opens the breaker once the threshold is reached.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.transition(Open)
+		return
+	}
+	b.mu.Unlock()
+}
+
+// Cancel releases a call that gave up before its outcome said anything about the dependency, such
+// as one whose context was cancelled, so a half-open breaker lets another call probe it.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Name returns the dependency name the breaker protects.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// transition switches state and notifies listeners. It must be called with the mutex held and releases it.
+func (b *Breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	listeners := append([]func(from, to State){}, b.listeners...)
+	b.mu.Unlock()
+
+	log.Printf("Circuit breaker %s: %s -> %s", b.name, from, to)
+	for _, listener := range listeners {
+		listener(from, to)
+	}
+}
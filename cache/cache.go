@@ -1,23 +1,53 @@
 package cache
 
 import (
+	"RoyDental/breaker"
 	"RoyDental/database"
+	"RoyDental/metrics"
 	"context"
 	"errors"
+	"log"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// maxPendingInvalidations bounds the invalidations remembered while Redis is unavailable.
+const maxPendingInvalidations = 10000
+
+var cacheErrors = metrics.NewCounterVec("cache_errors_total", "Redis cache operations that failed.", "operation")
+
+var cacheBypassed = metrics.NewCounterVec("cache_bypassed_total", "Cache operations skipped because the Redis circuit breaker was open.", "operation")
+
 type Cache struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
+// pendingInvalidations holds keys and patterns that could not be deleted while Redis was down.
+// They are replayed once the breaker closes so no stale entry outlives the outage.
+var pendingInvalidations = struct {
+	sync.Mutex
+	keys     map[string]struct{}
+	patterns map[string]struct{}
+	overflow bool
+}{keys: map[string]struct{}{}, patterns: map[string]struct{}{}}
+
+var replayOnce sync.Once
+
 // NewCache creates a new Cache instance, ensuring that RedisClient is not nil.
 func NewCache() (*Cache, error) {
 	if database.RedisClient == nil {
 		return nil, errors.New("Redis client is not initialized")
 	}
+	replayOnce.Do(func() {
+		database.RedisBreaker.OnStateChange(func(from, to breaker.State) {
+			if to == breaker.Closed {
+				go replayInvalidations(database.RedisClient)
+			}
+		})
+	})
 	return &Cache{client: database.RedisClient}, nil
 }
 
@@ -25,47 +55,218 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	if c.client == nil {
 		return errors.New("Redis client is not initialized")
 	}
-	return c.client.Del(ctx, key).Err()
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("delete")
+		deferInvalidation(key, false)
+		return nil
+	}
+	err := c.client.Del(ctx, key).Err()
+	if c.recordResult("delete", err) {
+		deferInvalidation(key, false)
+		return nil
+	}
+	return err
 }
 
 func (c *Cache) DeleteAll(ctx context.Context, pattern string) error {
 	if c.client == nil {
 		return errors.New("Redis client is not initialized")
 	}
-	// Use SCAN for better efficiency on large datasets
-	iter := c.client.Scan(ctx, 0, pattern, 0).Iterator()
-	for iter.Next(ctx) {
-		if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
-			return err
-		}
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("delete_all")
+		deferInvalidation(pattern, true)
+		return nil
 	}
-	if err := iter.Err(); err != nil {
-		return err
+	err := deletePattern(ctx, c.client, pattern)
+	if c.recordResult("delete_all", err) {
+		deferInvalidation(pattern, true)
+		return nil
 	}
-	return nil
+	return err
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	if c.client == nil {
 		return errors.New("Redis client is not initialized")
 	}
-	return c.client.Set(ctx, key, value, expiration).Err()
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("set")
+		return nil
+	}
+	err := c.client.Set(ctx, key, value, expiration).Err()
+	if c.recordResult("set", err) {
+		return nil
+	}
+	return err
 }
 
 func (c *Cache) Get(ctx context.Context, key string) (string, error) {
 	if c.client == nil {
 		return "", errors.New("Redis client is not initialized")
 	}
+	if !database.RedisBreaker.Allow() {
+		// Behave as a miss so callers read straight from the database
+		cacheBypassed.Inc("get")
+		return "", nil
+	}
 	val, err := c.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		database.RedisBreaker.Success()
 		return "", nil // key does not exist
 	}
+	if c.recordResult("get", err) {
+		return "", nil
+	}
 	return val, err
 }
 
+// SetNX sets key only when it does not exist yet and reports whether it did. While Redis is
+// unavailable every key is reported as set, so callers carry on as if they were first.
+func (c *Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if c.client == nil {
+		return false, errors.New("Redis client is not initialized")
+	}
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("setnx")
+		return true, nil
+	}
+	set, err := c.client.SetNX(ctx, key, value, expiration).Result()
+	if c.recordResult("setnx", err) {
+		return true, nil
+	}
+	return set, err
+}
+
+// SetObject encodes value with the configured codec and caches it.
+func (c *Cache) SetObject(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := Encode(value)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, data, expiration)
+}
+
+// GetObject decodes the cached value into dest and reports whether it was found.
+// An entry that cannot be decoded is reported as an error and treated as a miss.
+func (c *Cache) GetObject(ctx context.Context, key string, dest interface{}) (bool, error) {
+	val, err := c.Get(ctx, key)
+	if err != nil || val == "" {
+		return false, err
+	}
+	if err := Decode([]byte(val), dest); err != nil {
+		// Do not leave a partially decoded value behind for the caller to fill from the database
+		if rv := reflect.ValueOf(dest); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func (c *Cache) DeleteBatch(ctx context.Context, keys ...string) error {
 	if c.client == nil {
 		return errors.New("Redis client is not initialized")
 	}
-	return c.client.Del(ctx, keys...).Err()
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("delete_batch")
+		for _, key := range keys {
+			deferInvalidation(key, false)
+		}
+		return nil
+	}
+	// One DEL per key: a multi-key DEL fails with CROSSSLOT in cluster mode
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if c.recordResult("delete_batch", err) {
+		for _, key := range keys {
+			deferInvalidation(key, false)
+		}
+		return nil
+	}
+	return err
+}
+
+// recordResult reports the outcome to the breaker and returns true when the error is a Redis
+// failure the caller should absorb rather than propagate.
+func (c *Cache) recordResult(operation string, err error) bool {
+	if err == nil {
+		database.RedisBreaker.Success()
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		database.RedisBreaker.Cancel()
+		return false
+	}
+	cacheErrors.Inc(operation)
+	database.RedisBreaker.Failure()
+	log.Printf("Redis %s failed, degrading to database: %v", operation, err)
+	return true
+}
+
+func deletePattern(ctx context.Context, client redis.UniversalClient, pattern string) error {
+	// SCAN only walks the node it is sent to, so a cluster is scanned master by master
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return deletePattern(ctx, master, pattern)
+		})
+	}
+
+	// Use SCAN for better efficiency on large datasets
+	iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func deferInvalidation(keyOrPattern string, isPattern bool) {
+	pendingInvalidations.Lock()
+	defer pendingInvalidations.Unlock()
+	if len(pendingInvalidations.keys)+len(pendingInvalidations.patterns) >= maxPendingInvalidations {
+		pendingInvalidations.overflow = true
+		return
+	}
+	if isPattern {
+		pendingInvalidations.patterns[keyOrPattern] = struct{}{}
+	} else {
+		pendingInvalidations.keys[keyOrPattern] = struct{}{}
+	}
+}
+
+// replayInvalidations deletes everything that changed while Redis was unavailable.
+func replayInvalidations(client redis.UniversalClient) {
+	pendingInvalidations.Lock()
+	keys, patterns, overflow := pendingInvalidations.keys, pendingInvalidations.patterns, pendingInvalidations.overflow
+	pendingInvalidations.keys = map[string]struct{}{}
+	pendingInvalidations.patterns = map[string]struct{}{}
+	pendingInvalidations.overflow = false
+	pendingInvalidations.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if overflow {
+		// Too much changed to track individually; drop every cached entity of this environment
+		patterns = map[string]struct{}{keyConfig.Environment + ":*:g*": {}}
+		keys = map[string]struct{}{}
+	}
+	for key := range keys {
+		if err := client.Del(ctx, key).Err(); err != nil {
+			log.Printf("Failed to replay cache invalidation for %s: %v", key, err)
+		}
+	}
+	for pattern := range patterns {
+		if err := deletePattern(ctx, client, pattern); err != nil {
+			log.Printf("Failed to replay cache invalidation for %s: %v", pattern, err)
+		}
+	}
+	if len(keys)+len(patterns) > 0 {
+		log.Printf("Replayed %d cache invalidations after Redis recovered", len(keys)+len(patterns))
+	}
 }
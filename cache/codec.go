@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"RoyDental/config"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Every encoded value starts with a header byte naming its codec, with headerGzip set when the
+// payload is compressed. Values without a known header are treated as plain JSON, which is how
+// entries written before codecs existed are still read during a rollout.
+const (
+	headerJSON    byte = 0x01
+	headerMsgpack byte = 0x02
+	headerGzip    byte = 0x80
+)
+
+// Codec serialises values stored in the cache.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	header() byte
+}
+
+// JSONCodec stores values as JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (JSONCodec) header() byte                               { return headerJSON }
+
+// MsgpackCodec stores values as MessagePack. Field names and omissions follow the json tags so
+// cached models carry exactly what the API would return.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (MsgpackCodec) header() byte { return headerMsgpack }
+
+var (
+	codec             Codec = MsgpackCodec{}
+	compressThreshold       = config.DefaultCacheCodecConfig().CompressThreshold
+)
+
+// SetCodec selects the codec and compression threshold, typically once at startup.
+func SetCodec(cfg config.CacheCodecConfig) error {
+	switch strings.ToLower(cfg.Codec) {
+	case "", "msgpack":
+		codec = MsgpackCodec{}
+	case "json":
+		codec = JSONCodec{}
+	default:
+		return fmt.Errorf("unknown cache codec %q", cfg.Codec)
+	}
+	compressThreshold = cfg.CompressThreshold
+	return nil
+}
+
+// Encode serialises v with the configured codec, compressing it when it exceeds the threshold.
+func Encode(v interface{}) ([]byte, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := codec.header()
+	if compressThreshold > 0 && len(data) > compressThreshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data, header = buf.Bytes(), header|headerGzip
+	}
+	return append([]byte{header}, data...), nil
+}
+
+// Decode reverses Encode. It reads values written by any codec, not just the configured one.
+func Decode(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("empty cache value")
+	}
+	header, payload := data[0], data[1:]
+	if header&headerGzip != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		if payload, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+		header &^= headerGzip
+	}
+	switch header {
+	case headerMsgpack:
+		return MsgpackCodec{}.Unmarshal(payload, v)
+	case headerJSON:
+		return JSONCodec{}.Unmarshal(payload, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// generationRefresh bounds how long a replica keeps using a namespace generation after another
+// replica invalidated the namespace.
+const generationRefresh = 5 * time.Second
+
+// keyConfig holds the environment, default branch and version every cache key is namespaced with.
+var keyConfig = config.DefaultCacheKeyConfig()
+
+// SetKeyConfig replaces the cache key namespace, typically once at startup.
+func SetKeyConfig(cfg config.CacheKeyConfig) {
+	if cfg.Environment == "" {
+		cfg.Environment = config.DefaultCacheKeyConfig().Environment
+	}
+	if cfg.Branch == "" {
+		cfg.Branch = config.DefaultCacheKeyConfig().Branch
+	}
+	keyConfig = cfg
+}
+
+type branchContextKey struct{}
+
+// WithBranch scopes the cache keys built from ctx to branch instead of the configured default.
+func WithBranch(ctx context.Context, branch string) context.Context {
+	return context.WithValue(ctx, branchContextKey{}, branch)
+}
+
+func branchFrom(ctx context.Context) string {
+	if branch, ok := ctx.Value(branchContextKey{}).(string); ok && branch != "" {
+		return branch
+	}
+	return keyConfig.Branch
+}
+
+// namespace returns the key prefix of branch, e.g. "production:main:v1".
+func namespace(branch string) string {
+	return fmt.Sprintf("%s:%s:v%d", keyConfig.Environment, branch, keyConfig.Version)
+}
+
+// generations caches each namespace's generation so building a key rarely costs a round trip.
+var generations = struct {
+	sync.Mutex
+	entries map[string]generation
+}{entries: map[string]generation{}}
+
+type generation struct {
+	value   int64
+	fetched time.Time
+}
+
+// Key builds the key of an entity in the namespace of ctx, e.g. Key(ctx, "patient", id) gives
+// "production:main:v1:g0:patient:PAT-000001". Without parts it names a cached list, e.g. Key(ctx, "patients").
+func (c *Cache) Key(ctx context.Context, entity string, parts ...interface{}) string {
+	ns := namespace(branchFrom(ctx))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:g%d:%s", ns, c.generation(ctx, ns), entity)
+	for _, part := range parts {
+		fmt.Fprintf(&b, ":%v", part)
+	}
+	return b.String()
+}
+
+// InvalidateNamespace drops every entry cached for the branch of ctx at once. The namespace moves
+// to a new generation, so old entries are never read again and simply expire with their TTL.
+func (c *Cache) InvalidateNamespace(ctx context.Context) error {
+	ns := namespace(branchFrom(ctx))
+	if c.client == nil {
+		return errors.New("Redis client is not initialized")
+	}
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("invalidate_namespace")
+		deferInvalidation(ns+":g*", true)
+		return nil
+	}
+	value, err := c.client.Incr(ctx, generationKey(ns)).Result()
+	if c.recordResult("invalidate_namespace", err) {
+		deferInvalidation(ns+":g*", true)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	generations.Lock()
+	generations.entries[ns] = generation{value: value, fetched: time.Now()}
+	generations.Unlock()
+	return nil
+}
+
+// generation returns the current generation of ns, falling back to the last known one while
+// Redis is unavailable.
+func (c *Cache) generation(ctx context.Context, ns string) int64 {
+	generations.Lock()
+	current, ok := generations.entries[ns]
+	generations.Unlock()
+	if ok && time.Since(current.fetched) < generationRefresh {
+		return current.value
+	}
+	if c.client == nil || !database.RedisBreaker.Allow() {
+		return current.value
+	}
+
+	value, err := c.client.Get(ctx, generationKey(ns)).Int64()
+	if err == redis.Nil {
+		value, err = 0, nil
+	}
+	if c.recordResult("generation", err) || err != nil {
+		return current.value
+	}
+
+	generations.Lock()
+	generations.entries[ns] = generation{value: value, fetched: time.Now()}
+	generations.Unlock()
+	return value
+}
+
+func generationKey(ns string) string {
+	return ns + ":generation"
+}
+
+// listCaches names the entities whose entries are lists, which grow with the rows they list
+var listCaches = map[string]bool{
+	"appointments": true, "billings": true, "doctors": true, "emergency_contacts": true, "examinations": true,
+	"insurance_companies": true, "patients": true, "treatment_plans": true,
+	"doctor_appointments": true, "doctor_billings": true, "doctor_patients": true,
+}
+
+// EnvironmentPattern matches the keys cached in the environment, of every branch and generation.
+func EnvironmentPattern() string {
+	return keyConfig.Environment + ":*:g*"
+}
+
+// IsListKey reports whether key holds a cached list, such as "production:main:v1:g0:patients" or
+// a doctor's page of patients.
+func IsListKey(key string) bool {
+	parts := strings.SplitN(key, ":", 6)
+	if len(parts) < 5 || !strings.HasPrefix(parts[3], "g") || strings.HasSuffix(key, ":refresh") {
+		return false
+	}
+	if _, err := strconv.ParseInt(parts[3][1:], 10, 64); err != nil {
+		return false
+	}
+	return listCaches[parts[4]]
+}
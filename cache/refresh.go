@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"RoyDental/database"
+	"RoyDental/metrics"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// listRefreshLock is how long a replica holds the right to refresh a list
+const listRefreshLock = 30 * time.Second
+
+var listRefreshes = metrics.NewCounterVec("cache_list_refreshes_total", "Cached lists refreshed in the background before they expired.", "result")
+
+// GetObjectTTL is GetObject that also returns how long the entry has left before it expires,
+// 0 for a miss or an entry without an expiry.
+func (c *Cache) GetObjectTTL(ctx context.Context, key string, dest interface{}) (bool, time.Duration, error) {
+	if c.client == nil {
+		return false, 0, errors.New("Redis client is not initialized")
+	}
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("get")
+		return false, 0, nil
+	}
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		database.RedisBreaker.Success()
+		return false, 0, nil
+	}
+	if c.recordResult("get", err) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if err := Decode([]byte(get.Val()), dest); err != nil {
+		return false, 0, err
+	}
+	return true, max(ttl.Val(), 0), nil
+}
+
+// Replace encodes value and caches it only when key is still cached, so a background refresh never
+// brings back an entry that was invalidated while it ran.
+func (c *Cache) Replace(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if c.client == nil {
+		return errors.New("Redis client is not initialized")
+	}
+	data, err := Encode(value)
+	if err != nil {
+		return err
+	}
+	if !database.RedisBreaker.Allow() {
+		cacheBypassed.Inc("replace")
+		return nil
+	}
+	err = c.client.SetXX(ctx, key, data, expiration).Err()
+	if c.recordResult("replace", err) {
+		return nil
+	}
+	return err
+}
+
+// ReadList returns the list of entity cached under key, reading and caching it on a miss. A list
+// about to expire, within the entity's refresh window, is still returned at once while one replica
+// reads it again in the background, so the list is rarely missed and read by every request at once.
+func ReadList[T any](ctx context.Context, c *Cache, key, entity string, read func(ctx context.Context) ([]T, error)) ([]T, error) {
+	var rows []T
+	found, remaining, err := c.GetObjectTTL(ctx, key, &rows)
+	if err != nil {
+		// Do not leave a partially decoded list behind
+		rows = nil
+		log.Printf("Failed to get %s from cache: %v", key, err)
+	} else if found {
+		if window := TTLs.For(entity).Refresh; window > 0 && remaining > 0 && remaining <= window {
+			refreshList(ctx, c, key, entity, read)
+		}
+		return rows, nil
+	}
+
+	rows, err = read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetObject(ctx, key, rows, ListTTL(entity)); err != nil {
+		log.Printf("Failed to set %s in cache: %v", key, err)
+	}
+	return rows, nil
+}
+
+// refreshList reads the list cached under key again in the background, unless another request is
+// already doing so
+func refreshList[T any](ctx context.Context, c *Cache, key, entity string, read func(ctx context.Context) ([]T, error)) {
+	claimed, err := c.SetNX(ctx, key+":refresh", 1, listRefreshLock)
+	if err != nil || !claimed {
+		return
+	}
+	// The refresh outlives the request that noticed the list was about to expire
+	ctx, cancel := database.WithReadTimeout(context.WithoutCancel(ctx))
+	go func() {
+		defer cancel()
+		rows, err := read(ctx)
+		if err == nil {
+			err = c.Replace(ctx, key, rows, ListTTL(entity))
+		}
+		if err != nil {
+			listRefreshes.Inc("failed")
+			log.Printf("Failed to refresh %s in cache: %v", key, err)
+			return
+		}
+		listRefreshes.Inc("refreshed")
+	}()
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"RoyDental/database"
+	"context"
+)
+
+// Status is the state of the cache an admin checks before flushing it: whether Redis is in use,
+// the namespace and generation keys are built in, and the invalidations waiting for Redis to
+// come back
+type Status struct {
+	Breaker              string `json:"breaker"`
+	Namespace            string `json:"namespace"`
+	Generation           int64  `json:"generation"`
+	PendingInvalidations int    `json:"pending_invalidations"`
+	Overflowed           bool   `json:"overflowed"` // Too many to track, the whole environment is dropped once Redis is back
+}
+
+// Status returns the state of the cache for the branch of ctx
+func (c *Cache) Status(ctx context.Context) Status {
+	ns := namespace(branchFrom(ctx))
+	status := Status{
+		Breaker:    database.RedisBreaker.State().String(),
+		Namespace:  ns,
+		Generation: c.generation(ctx, ns),
+	}
+	pendingInvalidations.Lock()
+	status.PendingInvalidations = len(pendingInvalidations.keys) + len(pendingInvalidations.patterns)
+	status.Overflowed = pendingInvalidations.overflow
+	pendingInvalidations.Unlock()
+	return status
+}
+
+// InvalidateEntity drops every entry cached under entity in the branch of ctx: "patient" drops the
+// cached patients and "patients" the cached list of them
+func (c *Cache) InvalidateEntity(ctx context.Context, entity string) error {
+	key := c.Key(ctx, entity)
+	if err := c.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.DeleteAll(ctx, key+":*")
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"RoyDental/config"
+	"math/rand"
+	"time"
+)
+
+// TTLs holds the cache expiries used by repositories and services.
+var TTLs = config.DefaultCacheTTLConfig()
+
+// SetTTLs replaces the cache expiries, typically once at startup.
+func SetTTLs(cfg config.CacheTTLConfig) {
+	if cfg.Overrides == nil {
+		cfg.Overrides = map[string]config.EntityTTL{}
+	}
+	if cfg.Jitter < 0 || cfg.Jitter >= 1 {
+		cfg.Jitter = 0
+	}
+	TTLs = cfg
+}
+
+// ItemTTL returns the jittered expiry for a single cached record of entity.
+func ItemTTL(entity string) time.Duration {
+	return withJitter(TTLs.For(entity).Item)
+}
+
+// ListTTL returns the jittered expiry for a cached list of entity.
+func ListTTL(entity string) time.Duration {
+	return withJitter(TTLs.For(entity).List)
+}
+
+// withJitter spreads expiries by ±TTLs.Jitter so entries cached together do not all expire together.
+func withJitter(ttl time.Duration) time.Duration {
+	if TTLs.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * TTLs.Jitter
+	return ttl + time.Duration((rand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,201 @@
+// Package client is the Go client of the RoyDental API, for the internal tools calling the server.
+// Its operations are generated from the API definition, api/openapi.json, as is the TypeScript
+// client in client/typescript. It sends and decodes the server's own models, so its requests and
+// responses cannot drift from what the handlers bind and return.
+package client
+
+//go:generate go run ../cmd/apigen -spec ../api/openapi.json -go generated.go -ts typescript
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadOnlyCode is the code of the errors returned while the server refuses writes
+const ReadOnlyCode = "read_only"
+
+// defaultTimeout bounds a request when no HTTP client is given
+const defaultTimeout = 30 * time.Second
+
+// Client calls the RoyDental API with the API bearer token and, once signed in, the user's access
+// token. It is safe for concurrent use.
+type Client struct {
+	baseURL     string
+	bearerToken string
+	httpClient  *http.Client
+
+	mu          sync.RWMutex
+	accessToken string
+}
+
+// New returns a client of the server at baseURL. A nil httpClient uses one with a 30 second timeout.
+func New(baseURL, bearerToken string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), bearerToken: bearerToken, httpClient: httpClient}
+}
+
+// SetAccessToken sets the access token sent with every request, such as one kept from an earlier
+// sign-in
+func (c *Client) SetAccessToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = token
+}
+
+// AccessToken returns the access token sent with every request
+func (c *Client) AccessToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.accessToken
+}
+
+// Error is a response the server refused or failed, with its status and message
+type Error struct {
+	Status  int
+	Message string `json:"error"`
+	Code    string `json:"code"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("roydental: %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("roydental: %d %s", e.Status, e.Message)
+}
+
+// IsReadOnly tells whether err is a write the server refused because it is read-only
+func IsReadOnly(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.Code == ReadOnlyCode
+}
+
+// IsNotFound tells whether err is a 404 from the server
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.Status == http.StatusNotFound
+}
+
+// mergePatch is a JSON merge patch, sent with its own content type
+type mergePatch json.RawMessage
+
+// do sends body as JSON to path with query and decodes a successful response into out when it is
+// not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// stream sends a GET to a list route asking for NDJSON and calls fn with each item as it arrives
+func stream[T any](ctx context.Context, c *Client, path string, query url.Values, fn func(T) error) error {
+	resp, err := c.send(ctx, http.MethodGet, path, query, nil, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		// The server ends a list it failed to read to the end with an error line
+		var failure struct {
+			Error *string `json:"error"`
+		}
+		if json.Unmarshal(line, &failure) == nil && failure.Error != nil {
+			return &Error{Status: resp.StatusCode, Message: *failure.Error}
+		}
+		var item T
+		if err := json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("failed to decode item of %s: %w", path, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// send sends the request and returns the response of a successful one, whose body the caller closes
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}, accept string) (*http.Response, error) {
+	var payload io.Reader
+	contentType := "application/json"
+	if body != nil {
+		if patch, ok := body.(mergePatch); ok {
+			payload, contentType = bytes.NewReader(patch), "application/merge-patch+json"
+		} else if raw, ok := body.(json.RawMessage); ok {
+			payload = bytes.NewReader(raw)
+		} else {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode request body: %w", err)
+			}
+			payload = bytes.NewReader(data)
+		}
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if token := c.AccessToken(); token != "" && query.Get("accessToken") == "" {
+		query.Set("accessToken", token)
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "roydental-go-client/"+Version)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	apiErr := &Error{Status: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return nil, apiErr
+}
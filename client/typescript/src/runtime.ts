@@ -0,0 +1,145 @@
+// Transport of the generated client: the API bearer token, the user's access token, JSON requests,
+// NDJSON streams and the errors the server answers with.
+
+/** Code of the errors returned while the server refuses writes */
+export const READ_ONLY_CODE = "read_only";
+
+/** A response the server refused or failed, with its status and message */
+export class ApiError extends Error {
+  constructor(
+    readonly status: number,
+    message: string,
+    readonly code?: string,
+  ) {
+    super(`roydental: ${status} ${message}`);
+    this.name = "ApiError";
+  }
+}
+
+/** Tells whether err is a write the server refused because it is read-only */
+export function isReadOnly(err: unknown): boolean {
+  return err instanceof ApiError && err.code === READ_ONLY_CODE;
+}
+
+/** Tells whether err is a 404 from the server */
+export function isNotFound(err: unknown): boolean {
+  return err instanceof ApiError && err.status === 404;
+}
+
+export type Query = Record<string, string | number | boolean | undefined>;
+
+export interface RequestOptions {
+  query?: Query;
+  body?: unknown;
+  contentType?: string;
+}
+
+export interface ClientOptions {
+  /** The fetch to send requests with, the global one by default */
+  fetch?: typeof fetch;
+  /** An access token kept from an earlier sign-in */
+  accessToken?: string;
+}
+
+/**
+ * Calls the API with the API bearer token and, once signed in, the user's access token. The
+ * operations are generated from the API definition in RoyDentalClient.
+ */
+export class BaseClient {
+  /** The access token sent with every request */
+  accessToken?: string;
+
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+
+  constructor(
+    baseUrl: string,
+    private readonly bearerToken: string,
+    options: ClientOptions = {},
+  ) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+    this.fetchImpl = options.fetch ?? fetch.bind(globalThis);
+    this.accessToken = options.accessToken;
+  }
+
+  /** Sends options.body as JSON and decodes the JSON response, undefined when it has none */
+  protected async request<T>(method: string, path: string, options: RequestOptions = {}): Promise<T> {
+    const response = await this.send(method, path, "application/json", options);
+    if (response.status === 204) {
+      return undefined as T;
+    }
+    const text = await response.text();
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+
+  /** Asks a list route for NDJSON and yields each item as it arrives */
+  protected async *stream<T>(path: string, query?: Query): AsyncGenerator<T> {
+    const response = await this.send("GET", path, "application/x-ndjson", { query });
+    if (!response.body) {
+      return;
+    }
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffered = "";
+    for (;;) {
+      const { done, value } = await reader.read();
+      buffered += done ? decoder.decode() : decoder.decode(value, { stream: true });
+      const lines = buffered.split("\n");
+      buffered = done ? "" : (lines.pop() ?? "");
+      for (const line of lines) {
+        if (line.trim() === "") {
+          continue;
+        }
+        const item = JSON.parse(line);
+        // The server ends a list it failed to read to the end with an error line
+        if (item && typeof item === "object" && typeof item.error === "string" && Object.keys(item).length === 1) {
+          throw new ApiError(response.status, item.error);
+        }
+        yield item as T;
+      }
+      if (done) {
+        return;
+      }
+    }
+  }
+
+  private async send(method: string, path: string, accept: string, options: RequestOptions): Promise<Response> {
+    const url = new URL(this.baseUrl + path);
+    for (const [name, value] of Object.entries(options.query ?? {})) {
+      if (value !== undefined && value !== "") {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    if (this.accessToken && !url.searchParams.has("accessToken")) {
+      url.searchParams.set("accessToken", this.accessToken);
+    }
+
+    const headers: Record<string, string> = { Accept: accept };
+    if (this.bearerToken) {
+      headers.Authorization = `Bearer ${this.bearerToken}`;
+    }
+    let body: string | undefined;
+    if (options.body !== undefined) {
+      headers["Content-Type"] = options.contentType ?? "application/json";
+      body = JSON.stringify(options.body);
+    }
+
+    const response = await this.fetchImpl(url.toString(), { method, headers, body });
+    if (response.ok) {
+      return response;
+    }
+    const text = await response.text();
+    let message = text.trim() || response.statusText;
+    let code: string | undefined;
+    try {
+      const failure = JSON.parse(text);
+      if (failure && typeof failure.error === "string" && failure.error !== "") {
+        message = failure.error;
+        code = failure.code;
+      }
+    } catch {
+      // Not JSON; the body is the message
+    }
+    throw new ApiError(response.status, message, code);
+  }
+}
@@ -0,0 +1,99 @@
+// Package clock tells the time to the code that acts on it, so tests can hold time still and move it
+// on at will, and staging can run ahead of the wall clock to see what reminders, recalls, dunning and
+// period closing will do.
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System is the wall clock
+var System Clock = systemClock{}
+
+type offsetClock struct {
+	base   Clock
+	offset time.Duration
+}
+
+func (c offsetClock) Now() time.Time {
+	return c.base.Now().Add(c.offset)
+}
+
+// Offset returns a clock reading offset ahead of base, or behind it when offset is negative
+func Offset(base Clock, offset time.Duration) Clock {
+	if offset == 0 {
+		return base
+	}
+	return offsetClock{base: base, offset: offset}
+}
+
+// Manual is a clock that only moves when it is set or advanced. It is safe for concurrent use.
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManual returns a clock stopped at now
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Set moves the clock to now
+func (m *Manual) Set(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// Advance moves the clock on by d
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+// holder lets an interface be stored in an atomic.Pointer
+type holder struct {
+	clock Clock
+}
+
+// current is the process clock. Until it is set, the wall clock is used.
+var current atomic.Pointer[holder]
+
+// SetDefault sets the process clock, which Default hands to services and Now reads. Services keep
+// the clock they were built with, so it is set before they are.
+func SetDefault(c Clock) {
+	current.Store(&holder{clock: c})
+}
+
+// Default returns the process clock
+func Default() Clock {
+	if h := current.Load(); h != nil {
+		return h.clock
+	}
+	return System
+}
+
+// Now returns the current time on the process clock
+func Now() time.Time {
+	return Default().Now()
+}
@@ -0,0 +1,86 @@
+// Command anonymize rewrites the patient data of a copy of the clinic's database, such as a restored
+// backup, so developers can debug against realistic volumes on staging without real patient data.
+// It refuses to run while ENV is production and only rewrites the database named by -confirm. When
+// REDIS_URL is set the cache is invalidated afterwards, so the API stops serving the original records.
+//
+//	ENV=staging DB_URL=... go run ./cmd/anonymize -confirm roydental_staging
+//
+// Replacements are derived from -key, random unless given, so rerunning with the same key on a
+// fresh copy gives the same staging dataset. Stored files such as attachments are left untouched.
+package main
+
+import (
+	"RoyDental/anonymize"
+	"RoyDental/cache"
+	"RoyDental/config"
+	"RoyDental/database"
+	"context"
+	"crypto/rand"
+	"flag"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	confirm := flag.String("confirm", "", "name of the database to anonymize, as a safeguard against pointing at the wrong one")
+	key := flag.String("key", "", "secret the replacement values are derived from; random when empty")
+	flag.Parse()
+
+	keys := config.LoadCacheKeyConfig()
+	if keys.Environment == "production" {
+		log.Fatal("refusing to anonymize while ENV is production")
+	}
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		log.Fatal("missing DB_URL environment variable")
+	}
+
+	ctx := context.Background()
+	db, err := database.InitDB(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+	var name string
+	if err := db.Raw("SELECT current_database()").Scan(&name).Error; err != nil {
+		log.Fatalf("failed to read database name: %v", err)
+	}
+	if *confirm != name {
+		log.Fatalf("refusing to anonymize database %q: pass -confirm %s to go ahead", name, name)
+	}
+
+	secret := []byte(*key)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate key: %v", err)
+		}
+	}
+
+	started := time.Now()
+	result, err := anonymize.Run(ctx, db, anonymize.New(secret))
+	if err != nil {
+		log.Fatalf("failed to anonymize: %v", err)
+	}
+	total := 0
+	for _, rows := range result.Rows {
+		total += rows
+	}
+	log.Printf("Anonymized %d rows of database %s in %s", total, name, time.Since(started).Round(time.Millisecond))
+
+	if os.Getenv("REDIS_URL") == "" {
+		return
+	}
+	if err := database.InitializeRedis(); err != nil {
+		log.Fatalf("failed to initialize Redis: %v", err)
+	}
+	cache.SetKeyConfig(keys)
+	c, err := cache.NewCache()
+	if err != nil {
+		log.Fatalf("failed to initialize cache: %v", err)
+	}
+	if err := c.InvalidateNamespace(ctx); err != nil {
+		log.Fatalf("failed to invalidate cache: %v", err)
+	}
+	log.Println("Cache invalidated")
+}
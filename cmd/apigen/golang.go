@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// goImports are the packages generated Go code may use, by the prefix of their identifiers
+var goImports = []struct{ prefix, path string }{
+	{"models.", "RoyDental/models"},
+	{"context.", "context"},
+	{"json.", "encoding/json"},
+	{"http.", "net/http"},
+	{"url.", "net/url"},
+	{"strconv.", "strconv"},
+	{"time.", "time"},
+}
+
+// generateGo returns the Go client's version, the types of the schemas without a Go type and a
+// method per operation
+func generateGo(api *spec, operations []*operation) ([]byte, error) {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// Version is the version of this client, sent in the User-Agent of its requests. It is the\n")
+	fmt.Fprintf(&body, "// version of the API definition, which changes with every change to the routes or models it covers.\n")
+	fmt.Fprintf(&body, "const Version = %q\n", api.Info.Version)
+
+	for _, name := range sortedSchemas(api) {
+		s := api.Components.Schemas[name]
+		if s.GoType != "" {
+			continue
+		}
+		if s.Type != "object" {
+			return nil, fmt.Errorf("schema %s written by hand must be an object", name)
+		}
+		body.WriteString("\n")
+		writeComment(&body, "", s.Description)
+		fmt.Fprintf(&body, "type %s struct {\n", name)
+		for _, property := range orderedProperties(s) {
+			tag := property
+			if !slices.Contains(s.Required, property) {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&body, "\t%s %s `json:%q`\n", goIdent(property, true), goType(api, s.Properties[property]), tag)
+		}
+		body.WriteString("}\n")
+	}
+
+	for _, op := range operations {
+		body.WriteString("\n")
+		if err := writeGoOperation(&body, api, op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+		}
+	}
+
+	var source bytes.Buffer
+	source.WriteString("// Code generated by apigen from api/openapi.json. DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	var code strings.Builder
+	for _, line := range strings.Split(body.String(), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			code.WriteString(line + "\n")
+		}
+	}
+	for _, imp := range goImports {
+		if strings.Contains(code.String(), imp.prefix) {
+			fmt.Fprintf(&source, "\t%q\n", imp.path)
+		}
+	}
+	source.WriteString(")\n\n")
+	source.Write(body.Bytes())
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid Go: %w", err)
+	}
+	return formatted, nil
+}
+
+func writeGoOperation(w *bytes.Buffer, api *spec, op *operation) error {
+	args := []string{"ctx context.Context"}
+	for _, param := range op.PathParams {
+		args = append(args, goIdent(param.Name, false)+" "+goParamType(param))
+	}
+
+	// The request body, as an argument or built from the arguments of its properties
+	bodyExpr := "nil"
+	switch {
+	case op.Body == nil:
+	case op.MergePatch:
+		args = append(args, "patch json.RawMessage")
+		bodyExpr = "mergePatch(patch)"
+	case op.Body.Ref != "":
+		name := goIdent(op.Body.refName(), false)
+		args = append(args, name+" "+goType(api, op.Body))
+		bodyExpr = name
+	case op.Body.Type == "object" && len(op.Body.Properties) > 0:
+		var fields []string
+		for _, property := range orderedProperties(op.Body) {
+			name := goIdent(property, false)
+			args = append(args, name+" "+goType(api, op.Body.Properties[property]))
+			fields = append(fields, fmt.Sprintf("%q: %s", property, name))
+		}
+		bodyExpr = "map[string]interface{}{" + strings.Join(fields, ", ") + "}"
+	default:
+		return fmt.Errorf("request body must refer to a schema or list its properties")
+	}
+
+	for _, param := range op.Params {
+		args = append(args, goIdent(param.Name, false)+" "+goParamType(param))
+	}
+
+	var results string
+	switch {
+	case op.Stream:
+		if op.Result == nil || op.Result.Ref == "" {
+			return fmt.Errorf("a streamed list must refer to the schema of its items")
+		}
+		args = append(args, "fn func("+goType(api, op.Result)+") error")
+		results = "error"
+	case op.Result == nil:
+		results = "error"
+	case op.Result.Type == "array":
+		results = "(" + goType(api, op.Result) + ", error)"
+	default:
+		results = "(*" + goType(api, op.Result) + ", error)"
+	}
+
+	writeComment(w, "", op.OperationID+" "+lowerFirst(op.Summary))
+	fmt.Fprintf(w, "func (c *Client) %s(%s) %s {\n", op.OperationID, strings.Join(args, ", "), results)
+
+	query := "nil"
+	if len(op.Params) > 0 {
+		query = "query"
+		w.WriteString("\tquery := url.Values{}\n")
+		for _, param := range op.Params {
+			writeGoQueryParam(w, param)
+		}
+	}
+	path := goPath(op)
+
+	switch {
+	case op.Stream:
+		fmt.Fprintf(w, "\treturn stream(ctx, c, %s, %s, fn)\n", path, query)
+	case op.Result == nil:
+		call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s, nil)", titleCase(op.Method), path, query, bodyExpr)
+		if !op.ClearsAccessToken {
+			fmt.Fprintf(w, "\treturn %s\n", call)
+			break
+		}
+		fmt.Fprintf(w, "\tif err := %s; err != nil {\n\t\treturn err\n\t}\n", call)
+		w.WriteString("\tc.SetAccessToken(\"\")\n\treturn nil\n")
+	default:
+		result := "&out"
+		if op.Result.Type == "array" {
+			result = "out"
+		}
+		fmt.Fprintf(w, "\tvar out %s\n", goType(api, op.Result))
+		fmt.Fprintf(w, "\tif err := c.do(ctx, http.Method%s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n",
+			titleCase(op.Method), path, query, bodyExpr)
+		if op.SetsAccessToken != "" {
+			fmt.Fprintf(w, "\tc.SetAccessToken(out.%s)\n", goIdent(op.SetsAccessToken, true))
+		}
+		fmt.Fprintf(w, "\treturn %s, nil\n", result)
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+// writeGoQueryParam adds a query parameter, leaving out optional ones left at their zero value
+func writeGoQueryParam(w *bytes.Buffer, param parameter) {
+	name := goIdent(param.Name, false)
+	var value, zeroCheck string
+	switch goParamType(param) {
+	case "time.Time":
+		value, zeroCheck = name+`.Format("2006-01-02")`, "!"+name+".IsZero()"
+	case "int":
+		value, zeroCheck = "strconv.Itoa("+name+")", name+" != 0"
+	case "bool":
+		value, zeroCheck = "strconv.FormatBool("+name+")", name
+	default:
+		value, zeroCheck = name, name+` != ""`
+	}
+	if param.Required {
+		fmt.Fprintf(w, "\tquery.Set(%q, %s)\n", param.Name, value)
+		return
+	}
+	fmt.Fprintf(w, "\tif %s {\n\t\tquery.Set(%q, %s)\n\t}\n", zeroCheck, param.Name, value)
+}
+
+// goPath returns the expression of the operation's path with its parameters filled in
+func goPath(op *operation) string {
+	var parts []string
+	rest := op.Path
+	for _, param := range op.PathParams {
+		before, after, _ := strings.Cut(rest, "{"+param.Name+"}")
+		parts = append(parts, fmt.Sprintf("%q", before))
+		name := goIdent(param.Name, false)
+		if goParamType(param) == "uint" {
+			parts = append(parts, "strconv.FormatUint(uint64("+name+"), 10)")
+		} else {
+			parts = append(parts, "url.PathEscape("+name+")")
+		}
+		rest = after
+	}
+	if rest != "" {
+		parts = append(parts, fmt.Sprintf("%q", rest))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// goParamType returns the Go type of a parameter: IDs in paths are unsigned, dates are days
+func goParamType(param parameter) string {
+	switch param.Schema.Type {
+	case "integer":
+		if param.In == "path" {
+			return "uint"
+		}
+		return "int"
+	case "boolean":
+		return "bool"
+	case "string":
+		if param.Schema.Format == "date" {
+			return "time.Time"
+		}
+	}
+	return "string"
+}
+
+// goType returns the Go type of values of a schema, the model of a schema of one
+func goType(api *spec, s *schema) string {
+	switch {
+	case s.Ref != "":
+		if ref := api.Components.Schemas[s.refName()]; ref != nil && ref.GoType != "" {
+			return ref.GoType
+		}
+		return s.refName()
+	case len(s.AllOf) == 1:
+		return "*" + goType(api, s.AllOf[0])
+	}
+	switch s.Type {
+	case "array":
+		return "[]" + goType(api, s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + goType(api, s.AdditionalProperties)
+		}
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	}
+	return "interface{}"
+}
+
+// goIdent returns the Go name of a JSON or parameter name, such as patientID for patient_id
+func goIdent(name string, exported bool) string {
+	var b strings.Builder
+	for i, word := range words(name) {
+		switch {
+		case i == 0 && !exported:
+			b.WriteString(strings.ToLower(word))
+		case goInitialisms[strings.ToLower(word)]:
+			b.WriteString(strings.ToUpper(word))
+		default:
+			b.WriteString(titleCase(word))
+		}
+	}
+	return b.String()
+}
+
+// goInitialisms are the words Go names spell in capitals
+var goInitialisms = map[string]bool{"id": true, "csrf": true, "url": true, "api": true, "sms": true}
+
+// words splits a snake_case or camelCase name into its words
+func words(name string) []string {
+	var result []string
+	for _, part := range strings.Split(name, "_") {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				result = append(result, part[start:i])
+				start = i
+			}
+		}
+		if start < len(part) {
+			result = append(result, part[start:])
+		}
+	}
+	return result
+}
+
+func titleCase(word string) string {
+	if word == "" {
+		return word
+	}
+	return strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+}
+
+func lowerFirst(text string) string {
+	if text == "" {
+		return text
+	}
+	return strings.ToLower(text[:1]) + text[1:]
+}
+
+// writeComment writes text as a comment wrapped at 100 columns, each line starting with prefix
+func writeComment(w *bytes.Buffer, prefix, text string) {
+	if text == "" {
+		return
+	}
+	line := prefix + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 100 && line != prefix+"//" {
+			w.WriteString(line + "\n")
+			line = prefix + "//"
+		}
+		line += " " + word
+	}
+	w.WriteString(line + "\n")
+}
+
+// orderedProperties returns the properties of a schema, its required ones first in their order
+func orderedProperties(s *schema) []string {
+	names := append([]string(nil), s.Required...)
+	var optional []string
+	for name := range s.Properties {
+		if !slices.Contains(s.Required, name) {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	return append(names, optional...)
+}
+
+func sortedSchemas(api *spec) []string {
+	names := make([]string, 0, len(api.Components.Schemas))
+	for name := range api.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,263 @@
+// Command apigen generates the API clients from the OpenAPI definition of the routes they cover,
+// api/openapi.json. It first writes the schemas of the models the definition names with x-go-type
+// into its components, from the models themselves, so the definition cannot drift from what the
+// handlers bind and return. It then generates from the definition the Go client's operations and
+// the TypeScript package, both versioned with the definition's info.version.
+//
+//	go generate ./client/
+//
+// Operations follow the definition with a few extensions: x-sets-access-token names the property
+// of the response holding an access token the client sends from then on, x-clears-access-token
+// stops sending it, and x-page-operation adds an operation reading one page of a list whose
+// NDJSON stream the operation reads. A streamed list takes no query parameters; those of the
+// operation are its page's.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Media types of the definition's requests and responses
+const (
+	jsonContentType       = "application/json"
+	ndjsonContentType     = "application/x-ndjson"
+	mergePatchContentType = "application/merge-patch+json"
+)
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "OpenAPI definition of the API")
+	goOut := flag.String("go", "client/generated.go", "Go file of the generated operations")
+	tsOut := flag.String("ts", "client/typescript", "directory of the TypeScript package")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("failed to read the API definition: %v", err)
+	}
+	data, err = refreshSchemas(data)
+	if err != nil {
+		log.Fatalf("failed to write the model schemas: %v", err)
+	}
+	if err := os.WriteFile(*specPath, data, 0o644); err != nil {
+		log.Fatalf("failed to write the API definition: %v", err)
+	}
+
+	var api spec
+	if err := json.Unmarshal(data, &api); err != nil {
+		log.Fatalf("invalid API definition: %v", err)
+	}
+	operations, err := api.operations()
+	if err != nil {
+		log.Fatalf("invalid API definition: %v", err)
+	}
+
+	goSource, err := generateGo(&api, operations)
+	if err != nil {
+		log.Fatalf("failed to generate the Go client: %v", err)
+	}
+	if err := os.WriteFile(*goOut, goSource, 0o644); err != nil {
+		log.Fatalf("failed to write the Go client: %v", err)
+	}
+
+	manifest, err := packageJSON(&api)
+	if err != nil {
+		log.Fatalf("failed to generate the TypeScript package: %v", err)
+	}
+	files := map[string][]byte{
+		filepath.Join("src", "generated.ts"): generateTypeScript(&api, operations),
+		"package.json":                       manifest,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(*tsOut, name), content, 0o644); err != nil {
+			log.Fatalf("failed to write the TypeScript client: %v", err)
+		}
+	}
+}
+
+// spec is the part of an OpenAPI 3.0 definition the clients are generated from
+type spec struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID        string               `json:"operationId"`
+	Summary            string               `json:"summary"`
+	Parameters         []parameter          `json:"parameters"`
+	RequestBody        *requestBody         `json:"requestBody"`
+	Responses          map[string]*response `json:"responses"`
+	SetsAccessToken    string               `json:"x-sets-access-token"`
+	ClearsAccessToken  bool                 `json:"x-clears-access-token"`
+	PageOperation      *pageOperation       `json:"x-page-operation"`
+	Method, Path       string               `json:"-"`
+	Stream, Page       bool                 `json:"-"`
+	Body, Result       *schema              `json:"-"`
+	MergePatch         bool                 `json:"-"`
+	PathParams, Params []parameter          `json:"-"`
+}
+
+type pageOperation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Content map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Content map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	GoType               string             `json:"x-go-type,omitempty"`
+}
+
+// refName returns the name of the component a schema refers to
+func (s *schema) refName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// operations returns the operations of the definition in the order of their paths and methods,
+// with a page operation after the list it reads a page of
+func (api *spec) operations() ([]*operation, error) {
+	paths := make([]string, 0, len(api.Paths))
+	for path := range api.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var operations []*operation
+	seen := map[string]bool{}
+	for _, path := range paths {
+		for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+			op := api.Paths[path][method]
+			if op == nil {
+				continue
+			}
+			op.Method, op.Path = strings.ToUpper(method), path
+			if err := op.resolve(); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Method, path, err)
+			}
+			variants := []*operation{op}
+			if op.PageOperation != nil {
+				if !op.Stream {
+					return nil, fmt.Errorf("%s %s: x-page-operation needs an NDJSON response", op.Method, path)
+				}
+				page := *op
+				page.OperationID, page.Summary = op.PageOperation.OperationID, op.PageOperation.Summary
+				page.Stream, page.Page = false, true
+				page.Result = op.Responses["200"].Content[jsonContentType].Schema
+				if page.Result == nil {
+					return nil, fmt.Errorf("%s %s: x-page-operation needs a JSON response", op.Method, path)
+				}
+				op.Params = nil
+				variants = append(variants, &page)
+			} else if op.Stream && len(op.Params) > 0 {
+				return nil, fmt.Errorf("%s %s: a streamed list takes no query parameters", op.Method, path)
+			}
+			for _, variant := range variants {
+				if variant.OperationID == "" || seen[variant.OperationID] {
+					return nil, fmt.Errorf("%s %s: missing or repeated operationId %q", op.Method, path, variant.OperationID)
+				}
+				seen[variant.OperationID] = true
+				operations = append(operations, variant)
+			}
+		}
+	}
+	return operations, nil
+}
+
+// resolve sorts the operation's parameters and finds its body and result
+func (op *operation) resolve() error {
+	for _, param := range op.Parameters {
+		if param.Schema == nil {
+			return fmt.Errorf("parameter %s has no schema", param.Name)
+		}
+		switch param.In {
+		case "path":
+			op.PathParams = append(op.PathParams, param)
+		case "query":
+			op.Params = append(op.Params, param)
+		default:
+			return fmt.Errorf("parameter %s is in %s, which clients do not send", param.Name, param.In)
+		}
+	}
+	// Path parameters are passed in the order the path names them
+	sort.SliceStable(op.PathParams, func(i, j int) bool {
+		return strings.Index(op.Path, "{"+op.PathParams[i].Name+"}") < strings.Index(op.Path, "{"+op.PathParams[j].Name+"}")
+	})
+
+	if op.RequestBody != nil {
+		if content, ok := op.RequestBody.Content[mergePatchContentType]; ok {
+			op.Body, op.MergePatch = content.Schema, true
+		} else if content, ok := op.RequestBody.Content[jsonContentType]; ok {
+			op.Body = content.Schema
+		} else {
+			return fmt.Errorf("request body is neither JSON nor a JSON merge patch")
+		}
+	}
+
+	for _, status := range []string{"200", "201", "204"} {
+		resp := op.Responses[status]
+		if resp == nil {
+			continue
+		}
+		if content, ok := resp.Content[ndjsonContentType]; ok {
+			op.Stream, op.Result = true, content.Schema
+		} else if content, ok := resp.Content[jsonContentType]; ok {
+			op.Result = content.Schema
+		}
+		return nil
+	}
+	return fmt.Errorf("no 200, 201 or 204 response")
+}
+
+// writeJSON encodes v as indented JSON ending with a newline
+func writeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"RoyDental/models"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// goTypes are the models the definition may name with x-go-type in the schemas its operations
+// refer to. The models they hold are named after their Go type.
+var goTypes = map[string]reflect.Type{
+	"models.Patient":                      reflect.TypeOf(models.Patient{}),
+	"models.Appointment":                  reflect.TypeOf(models.Appointment{}),
+	"models.Billing":                      reflect.TypeOf(models.Billing{}),
+	"models.AvailableSlot":                reflect.TypeOf(models.AvailableSlot{}),
+	"models.ListPage[models.Appointment]": reflect.TypeOf(models.ListPage[models.Appointment]{}),
+	"models.ListPage[models.Billing]":     reflect.TypeOf(models.ListPage[models.Billing]{}),
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// refreshSchemas rewrites the schemas of models in the definition from the models, keeping the
+// schemas written by hand: those without x-go-type, or naming a type of the client package itself
+func refreshSchemas(data []byte) ([]byte, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid API definition: %w", err)
+	}
+	var api spec
+	if err := json.Unmarshal(data, &api); err != nil {
+		return nil, fmt.Errorf("invalid API definition: %w", err)
+	}
+
+	// Schemas of models the operations do not refer to are those of the types models hold, which
+	// are written again with the models
+	roots := map[string]bool{}
+	for _, methods := range api.Paths {
+		for _, op := range methods {
+			for _, param := range op.Parameters {
+				param.Schema.refs(roots)
+			}
+			if op.RequestBody != nil {
+				for _, content := range op.RequestBody.Content {
+					content.Schema.refs(roots)
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, content := range resp.Content {
+					content.Schema.refs(roots)
+				}
+			}
+		}
+	}
+
+	generator := &schemaGenerator{schemas: map[string]*schema{}}
+	names := make([]string, 0, len(api.Components.Schemas))
+	for name := range api.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		existing := api.Components.Schemas[name]
+		if !strings.Contains(existing.GoType, ".") {
+			generator.schemas[name] = existing
+			continue
+		}
+		if !roots[name] {
+			continue
+		}
+		t, ok := goTypes[existing.GoType]
+		if !ok {
+			return nil, fmt.Errorf("schema %s names %s, which apigen does not know", name, existing.GoType)
+		}
+		if err := generator.component(name, existing.GoType, t); err != nil {
+			return nil, err
+		}
+	}
+
+	// Round-trip the schemas so the definition keeps one form whether read or written
+	encoded, err := json.Marshal(generator.schemas)
+	if err != nil {
+		return nil, err
+	}
+	var schemas map[string]interface{}
+	if err := json.Unmarshal(encoded, &schemas); err != nil {
+		return nil, err
+	}
+	components, _ := document["components"].(map[string]interface{})
+	if components == nil {
+		components = map[string]interface{}{}
+		document["components"] = components
+	}
+	components["schemas"] = schemas
+	return writeJSON(document)
+}
+
+// refs adds the names of the components s refers to
+func (s *schema) refs(names map[string]bool) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		names[s.refName()] = true
+	}
+	for _, sub := range s.AllOf {
+		sub.refs(names)
+	}
+	s.Items.refs(names)
+	s.AdditionalProperties.refs(names)
+	for _, property := range s.Properties {
+		property.refs(names)
+	}
+}
+
+// schemaGenerator writes the schemas of models and of the types they hold
+type schemaGenerator struct {
+	schemas map[string]*schema
+	names   map[reflect.Type]string
+}
+
+// component adds the schema of t under name
+func (g *schemaGenerator) component(name, goType string, t reflect.Type) error {
+	if g.names == nil {
+		g.names = map[reflect.Type]string{}
+	}
+	if existing, ok := g.names[t]; ok && existing != name {
+		return fmt.Errorf("%s is both schema %s and %s", goType, existing, name)
+	}
+	if _, ok := g.schemas[name]; ok {
+		if g.names[t] == name {
+			return nil
+		}
+		return fmt.Errorf("schema %s is given for more than one type", name)
+	}
+	g.names[t] = name
+	object := &schema{Type: "object", GoType: goType, Properties: map[string]*schema{}}
+	g.schemas[name] = object
+	return g.fields(object, t)
+}
+
+// fields adds the JSON fields of struct t to object, those of embedded structs included
+func (g *schemaGenerator) fields(object *schema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				if err := g.fields(object, fieldType); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := g.schemaOf(fieldType)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		if strings.Contains(options, "string") {
+			property = &schema{Type: "string"}
+		}
+		object.Properties[name] = property
+		if !strings.Contains(options, "omitempty") {
+			object.Required = append(object.Required, name)
+		}
+	}
+	return nil
+}
+
+// schemaOf returns the schema of values of t as encoding/json writes them
+func (g *schemaGenerator) schemaOf(t reflect.Type) (*schema, error) {
+	switch {
+	case t == timeType:
+		return &schema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if elem.Ref != "" {
+			return &schema{AllOf: []*schema{elem}, Nullable: true}, nil
+		}
+		elem.Nullable = true
+		return elem, nil
+	case reflect.Struct:
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return nil, fmt.Errorf("%s needs a schema of its own in the definition", t)
+		}
+		name := t.Name()
+		if err := g.component(name, goTypeName(t), t); err != nil {
+			return nil, err
+		}
+		return &schema{Ref: "#/components/schemas/" + name}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Interface:
+		return &schema{}, nil
+	case reflect.String:
+		return &schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &schema{Type: "boolean"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int, reflect.Int64:
+		return &schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &schema{Type: "integer", Format: "int64", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number", Format: "double"}, nil
+	}
+	return nil, fmt.Errorf("%s has no JSON schema", t)
+}
+
+// goTypeName returns how the Go client names t
+func goTypeName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// generateTypeScript returns the TypeScript client's version, an interface per schema and a method
+// per operation
+func generateTypeScript(api *spec, operations []*operation) []byte {
+	var w bytes.Buffer
+	w.WriteString("// Code generated by apigen from api/openapi.json. DO NOT EDIT.\n\n")
+	w.WriteString("import { BaseClient } from \"./runtime\";\n\n")
+	w.WriteString("/** Version of the API definition this client was generated from */\n")
+	fmt.Fprintf(&w, "export const VERSION = %q;\n", api.Info.Version)
+
+	for _, name := range sortedSchemas(api) {
+		s := api.Components.Schemas[name]
+		w.WriteString("\n")
+		writeDoc(&w, "", s.Description)
+		fmt.Fprintf(&w, "export interface %s {\n", name)
+		for _, property := range orderedProperties(s) {
+			optional := "?"
+			if slices.Contains(s.Required, property) {
+				optional = ""
+			}
+			fmt.Fprintf(&w, "  %s%s: %s;\n", property, optional, tsType(s.Properties[property]))
+		}
+		w.WriteString("}\n")
+	}
+
+	w.WriteString("\n/** Client of the RoyDental API */\n")
+	w.WriteString("export class RoyDentalClient extends BaseClient {\n")
+	for i, op := range operations {
+		if i > 0 {
+			w.WriteString("\n")
+		}
+		writeTypeScriptOperation(&w, op)
+	}
+	w.WriteString("}\n")
+	return w.Bytes()
+}
+
+func writeTypeScriptOperation(w *bytes.Buffer, op *operation) {
+	var args []string
+	for _, param := range op.PathParams {
+		args = append(args, tsIdent(param.Name)+": "+tsParamType(param))
+	}
+
+	body := ""
+	switch {
+	case op.Body == nil:
+	case op.MergePatch:
+		args = append(args, "patch: Record<string, unknown>")
+		body = "patch"
+	case op.Body.Ref != "":
+		name := tsIdent(op.Body.refName())
+		args = append(args, name+": "+op.Body.refName())
+		body = name
+	default:
+		var fields []string
+		for _, property := range orderedProperties(op.Body) {
+			name := tsIdent(property)
+			args = append(args, name+": "+tsType(op.Body.Properties[property]))
+			if name == property {
+				fields = append(fields, name)
+			} else {
+				fields = append(fields, property+": "+name)
+			}
+		}
+		body = "{ " + strings.Join(fields, ", ") + " }"
+	}
+
+	// Required query parameters are arguments, optional ones options after them
+	var query, options []string
+	for _, param := range op.Params {
+		name := tsIdent(param.Name)
+		if param.Required {
+			args = append(args, name+": "+tsParamType(param))
+			query = append(query, fmt.Sprintf("%s: %s", param.Name, name))
+		} else {
+			options = append(options, name+"?: "+tsParamType(param))
+			query = append(query, fmt.Sprintf("%s: options.%s", param.Name, name))
+		}
+	}
+	if len(options) > 0 {
+		args = append(args, "options: { "+strings.Join(options, "; ")+" } = {}")
+	}
+
+	request := []string{}
+	if len(query) > 0 {
+		request = append(request, "query: { "+strings.Join(query, ", ")+" }")
+	}
+	if body != "" {
+		request = append(request, "body: "+body)
+	}
+	if op.MergePatch {
+		request = append(request, fmt.Sprintf("contentType: %q", mergePatchContentType))
+	}
+	requestArgs := ""
+	if len(request) > 0 {
+		requestArgs = ", { " + strings.Join(request, ", ") + " }"
+	}
+
+	name := lowerFirst(op.OperationID)
+	path := tsPath(op)
+	writeDoc(w, "  ", op.Summary)
+	switch {
+	case op.Stream:
+		item := op.Result.refName()
+		fmt.Fprintf(w, "  async *%s(%s): AsyncGenerator<%s> {\n", name, strings.Join(args, ", "), item)
+		fmt.Fprintf(w, "    yield* this.stream<%s>(%s);\n", item, path)
+	case op.Result == nil:
+		fmt.Fprintf(w, "  async %s(%s): Promise<void> {\n", name, strings.Join(args, ", "))
+		fmt.Fprintf(w, "    await this.request<void>(%q, %s%s);\n", op.Method, path, requestArgs)
+		if op.ClearsAccessToken {
+			w.WriteString("    this.accessToken = undefined;\n")
+		}
+	default:
+		result := tsType(op.Result)
+		fmt.Fprintf(w, "  async %s(%s): Promise<%s> {\n", name, strings.Join(args, ", "), result)
+		if op.SetsAccessToken == "" {
+			fmt.Fprintf(w, "    return this.request<%s>(%q, %s%s);\n", result, op.Method, path, requestArgs)
+			break
+		}
+		fmt.Fprintf(w, "    const result = await this.request<%s>(%q, %s%s);\n", result, op.Method, path, requestArgs)
+		fmt.Fprintf(w, "    this.accessToken = result.%s;\n", op.SetsAccessToken)
+		w.WriteString("    return result;\n")
+	}
+	w.WriteString("  }\n")
+}
+
+// tsPath returns the template literal of the operation's path with its parameters filled in
+func tsPath(op *operation) string {
+	path := op.Path
+	for _, param := range op.PathParams {
+		value := "encodeURIComponent(" + tsIdent(param.Name) + ")"
+		if param.Schema.Type == "integer" {
+			value = tsIdent(param.Name)
+		}
+		path = strings.Replace(path, "{"+param.Name+"}", "${"+value+"}", 1)
+	}
+	return "`" + path + "`"
+}
+
+func tsParamType(param parameter) string {
+	switch param.Schema.Type {
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	}
+	return "string"
+}
+
+// tsType returns the TypeScript type of values of a schema
+func tsType(s *schema) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.refName()
+	case len(s.AllOf) == 1:
+		t = tsType(s.AllOf[0])
+	case s.Type == "array":
+		t = tsType(s.Items)
+		if strings.Contains(t, " ") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Record<string, " + tsType(s.AdditionalProperties) + ">"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer", s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	default:
+		t = "unknown"
+	}
+	if s.Nullable && t != "unknown" {
+		t += " | null"
+	}
+	return t
+}
+
+// tsIdent returns the TypeScript name of a JSON or parameter name, such as patientId for patient_id
+func tsIdent(name string) string {
+	var b strings.Builder
+	for i, word := range words(name) {
+		if i == 0 {
+			b.WriteString(strings.ToLower(word))
+		} else {
+			b.WriteString(titleCase(word))
+		}
+	}
+	return b.String()
+}
+
+// writeDoc writes text as a JSDoc comment wrapped at 100 columns, each line starting with indent
+func writeDoc(w *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(indent)+3+len(line)+1+len(word) > 100 {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	lines = append(lines, line)
+	if len(lines) == 1 && len(indent)+7+len(lines[0]) <= 100 {
+		fmt.Fprintf(w, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(w, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(w, "%s */\n", indent)
+}
+
+// packageJSON returns the manifest of the TypeScript package, versioned with the API definition
+func packageJSON(api *spec) ([]byte, error) {
+	manifest := struct {
+		Name            string            `json:"name"`
+		Version         string            `json:"version"`
+		Description     string            `json:"description"`
+		License         string            `json:"license"`
+		Main            string            `json:"main"`
+		Types           string            `json:"types"`
+		Files           []string          `json:"files"`
+		Scripts         map[string]string `json:"scripts"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}{
+		Name:            "@roydental/api-client",
+		Version:         api.Info.Version,
+		Description:     "TypeScript client of the RoyDental API, generated from api/openapi.json",
+		License:         "UNLICENSED",
+		Main:            "dist/index.js",
+		Types:           "dist/index.d.ts",
+		Files:           []string{"dist"},
+		Scripts:         map[string]string{"build": "tsc -p .", "prepublishOnly": "npm run build"},
+		DevDependencies: map[string]string{"typescript": "^5.4.0"},
+	}
+	return writeJSON(manifest)
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"RoyDental/alerting"
 	"RoyDental/cache"
+	"RoyDental/clock"
 	"RoyDental/config"
 	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
 	"RoyDental/routes"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -14,34 +21,75 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // The clinic time zone must load even where the host has no zone database
+
+	"gorm.io/gorm"
 )
 
 func main() {
 	// Load configuration from config package
-	config, err := loadConfig()
+	config, err := config.LoadAppConfig()
 	if err != nil {
 		log.Fatalf("failed to load configuration: %v", err)
 	}
 
-	// Initialize the database
-	db, err := database.InitDB(context.Background(), config.DBURL)
-	if err != nil {
-		log.Fatalf("failed to initialize database: %v", err)
+	// Apply per-operation timeouts to repositories and locks
+	database.SetTimeouts(config.Timeouts)
+
+	// Write the IDs of patients, doctors, bills and insurers in their configured formats
+	repositories.SetIDFormats(config.IDFormats)
+
+	// Select how concurrent writes are serialised (Redis or Postgres advisory locks)
+	if err := database.SetLockProvider(config.LockProvider); err != nil {
+		log.Fatalf("failed to configure lock provider: %v", err)
 	}
 
-	// Initialize Redis
-	if err := database.InitializeRedis(); err != nil {
-		log.Fatalf("failed to initialize Redis client: %v", err)
+	// Read and show appointment times in the clinic's time zone, whatever the server runs in
+	clinicLocation, err := config.Scheduling.Location()
+	if err != nil {
+		log.Fatalf("failed to configure clinic time zone: %v", err)
 	}
+	models.SetClinicLocation(clinicLocation)
 
-	// Initialize the cache utility
-	cache, err := cache.NewCache()
+	// Run ahead of the wall clock when staging simulates the days to come
+	offset, err := config.Clock.OffsetFrom(time.Now())
 	if err != nil {
-		log.Fatalf("failed to initialize cache: %v", err)
+		log.Fatalf("failed to configure clock: %v", err)
+	}
+	if offset != 0 {
+		log.Printf("Warning: the server's clock runs %s from the wall clock", offset)
+		clock.SetDefault(clock.Offset(clock.System, offset))
 	}
 
-	// Pass the config to SetupRoutes
-	handler := routes.SetupRoutes(cache, config, db)
+	// Route operational alerts and business events to their chat webhooks
+	notifications.SetEventChannels(config.ChatWebhooks)
+
+	// Background work (startup retries, partition maintenance) stops when the server exits
+	appCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Watch error counters from the start, so failing dependencies are reported too
+	go alerting.NewMonitor(config.Alerting, alerting.NewNotifier(config.Alerting)).Run(appCtx)
+
+	// Connect to Postgres and Redis, either before serving or in the background in lazy mode
+	var handler http.Handler
+	if config.Startup.Lazy {
+		lazy := routes.NewLazyHandler()
+		handler = lazy
+		go func() {
+			h, err := initDependencies(appCtx, config)
+			if err != nil {
+				log.Fatalf("failed to initialize dependencies: %v", err)
+			}
+			lazy.Ready(h)
+			log.Println("Dependencies ready, serving all routes")
+		}()
+	} else {
+		handler, err = initDependencies(appCtx, config)
+		if err != nil {
+			log.Fatalf("failed to initialize dependencies: %v", err)
+		}
+	}
 
 	// Configure and start the server
 	srv := &http.Server{
@@ -78,34 +126,73 @@ func main() {
 		log.Fatalf("server shutdown failed: %+v", err)
 	}
 
+	// Hand the scheduled background jobs to a standby replica straight away
+	database.ResignLeadership(shutdownCtx)
+
 	wg.Wait() // Wait for all goroutines to finish before exiting
 	log.Println("Server exited gracefully")
 }
 
-// loadConfig loads configuration from environment variables.
-func loadConfig() (*config.AppConfig, error) {
-	// Get the database URL
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		return nil, errors.New("missing DB_URL environment variable")
+// initDependencies connects to Postgres and Redis with retries and builds the application handler.
+func initDependencies(ctx context.Context, config *config.AppConfig) (http.Handler, error) {
+	startup := config.Startup
+	if startup.Lazy {
+		// The server is already up, so keep trying for as long as it takes
+		startup.RetryAttempts = 0
+	}
+
+	// Initialize the database
+	var db *gorm.DB
+	err := database.RetryWithBackoff(ctx, "database", startup, func(ctx context.Context) error {
+		var err error
+		if db, err = database.InitDB(ctx, config.DBURL); err != nil {
+			database.CloseDB()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go database.MaintainPartitions(ctx, 24*time.Hour)
+
+	// Reads fail over to the replica while the primary is unreachable
+	if config.ReadOnly.ReplicaURL != "" {
+		if err := database.InitReplica(ctx, config.ReadOnly.ReplicaURL); err != nil {
+			log.Printf("Read replica unavailable, reads will not fail over: %v", err)
+		}
+	}
+
+	// Initialize Redis
+	if err := database.RetryWithBackoff(ctx, "Redis", startup, func(context.Context) error {
+		return database.InitializeRedis()
+	}); err != nil {
+		return nil, err
 	}
 
-	// Get the Redis URL
-	redisAddress := os.Getenv("REDIS_URL")
-	if redisAddress == "" {
-		return nil, errors.New("missing REDIS_URL environment variable")
+	// Elect the replica that runs the scheduled background jobs before the services start them
+	if config.LeaderElection.Enabled {
+		database.StartLeaderElection(ctx, config.LeaderElection)
 	}
 
-	// Get the Bearer Token
-	bearerToken := os.Getenv("BEARER_TOKEN")
-	if bearerToken == "" {
-		return nil, errors.New("missing BEARER_TOKEN environment variable")
+	// Share domain events with the other instances
+	if config.EventBus.RedisBridge {
+		events.StartRedisBridge(ctx, config.EventBus.Channel)
 	}
 
-	// Returning the AppConfig with dynamic database name and other values
-	return &config.AppConfig{
-		DBURL:        dbURL,
-		RedisAddress: redisAddress,
-		BearerToken:  bearerToken,
-	}, nil
+	// Apply cache expiries, serialisation and key namespace before anything is cached
+	cache.SetTTLs(config.CacheTTLs)
+	cache.SetKeyConfig(config.CacheKeys)
+	if err := cache.SetCodec(config.CacheCodec); err != nil {
+		return nil, fmt.Errorf("failed to configure cache codec: %w", err)
+	}
+
+	// Initialize the cache utility
+	cache, err := cache.NewCache()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
+	// Pass the config to SetupRoutes
+	return routes.SetupRoutes(cache, config, db)
 }
@@ -0,0 +1,63 @@
+// Command seed fills the database at DB_URL with generated patients, appointments and bills for load
+// tests. When REDIS_URL is set the cache is invalidated afterwards, so the API serves the new records.
+//
+//	DB_URL=... go run ./cmd/seed -patients 20000 -appointments 5 -billings 3
+package main
+
+import (
+	"RoyDental/cache"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/testutil"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	cfg := testutil.DefaultSeedConfig()
+	flag.IntVar(&cfg.Doctors, "doctors", cfg.Doctors, "number of doctors")
+	flag.IntVar(&cfg.Patients, "patients", cfg.Patients, "number of patients")
+	flag.IntVar(&cfg.AppointmentsPerPatient, "appointments", cfg.AppointmentsPerPatient, "appointments per patient")
+	flag.IntVar(&cfg.BillingsPerPatient, "billings", cfg.BillingsPerPatient, "bills per patient")
+	flag.IntVar(&cfg.Months, "months", cfg.Months, "months of history the records are spread over")
+	flag.Int64Var(&cfg.RandomSeed, "seed", cfg.RandomSeed, "random seed, so a run can be repeated")
+	flag.Parse()
+
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		log.Fatal("missing DB_URL environment variable")
+	}
+
+	ctx := context.Background()
+	db, err := database.InitDB(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+
+	started := time.Now()
+	result, err := testutil.Seed(ctx, db, cfg)
+	if err != nil {
+		log.Fatalf("failed to seed: %v", err)
+	}
+	log.Printf("Seeded run %s in %s: %d doctors, %d patients, %d appointments, %d bills",
+		result.Run, time.Since(started).Round(time.Millisecond), result.Doctors, result.Patients, result.Appointments, result.Billings)
+
+	if os.Getenv("REDIS_URL") == "" {
+		return
+	}
+	if err := database.InitializeRedis(); err != nil {
+		log.Fatalf("failed to initialize Redis: %v", err)
+	}
+	cache.SetKeyConfig(config.LoadCacheKeyConfig())
+	c, err := cache.NewCache()
+	if err != nil {
+		log.Fatalf("failed to initialize cache: %v", err)
+	}
+	if err := c.InvalidateNamespace(ctx); err != nil {
+		log.Fatalf("failed to invalidate cache: %v", err)
+	}
+	log.Println("Cache invalidated")
+}
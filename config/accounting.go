@@ -0,0 +1,62 @@
+package config
+
+// AccountCodes are the ledger accounts billing is posted to in the accounting package.
+type AccountCodes struct {
+	Receivable string // Patient receivables, debited by invoices and credited by payments
+	Income     string // Fee income, credited by invoices
+	Cash       string // Where cash payments are deposited
+	Insurance  string // Where insurer payments are deposited
+	Refunds    string // Debited when payments are refunded
+}
+
+// AccountingConfig maps billing to the accounts of each supported accounting package.
+type AccountingConfig struct {
+	QuickBooks     AccountCodes // Account names as they appear in the QuickBooks chart of accounts
+	Xero           AccountCodes // Account codes from the Xero chart of accounts
+	XeroTaxRate    string       // Tax rate name applied to every Xero journal line
+	XeroDateFormat string       // Go layout of journal dates, matching the Xero organisation's region
+}
+
+// DefaultAccountingConfig returns the accounts of the stock QuickBooks and Xero charts.
+func DefaultAccountingConfig() AccountingConfig {
+	return AccountingConfig{
+		QuickBooks: AccountCodes{
+			Receivable: "Accounts Receivable",
+			Income:     "Dental Fees",
+			Cash:       "Undeposited Funds",
+			Insurance:  "Undeposited Funds",
+			Refunds:    "Patient Refunds",
+		},
+		Xero: AccountCodes{
+			Receivable: "610",
+			Income:     "200",
+			Cash:       "090",
+			Insurance:  "090",
+			Refunds:    "260",
+		},
+		XeroTaxRate:    "Tax Exempt",
+		XeroDateFormat: "02/01/2006",
+	}
+}
+
+// LoadAccountingConfig loads accounting export settings from environment variables with default fallbacks.
+func LoadAccountingConfig() AccountingConfig {
+	defaults := DefaultAccountingConfig()
+	return AccountingConfig{
+		QuickBooks:     loadAccountCodes("ACCOUNTING_QUICKBOOKS_", defaults.QuickBooks),
+		Xero:           loadAccountCodes("ACCOUNTING_XERO_", defaults.Xero),
+		XeroTaxRate:    GetEnv("ACCOUNTING_XERO_TAX_RATE", defaults.XeroTaxRate),
+		XeroDateFormat: GetEnv("ACCOUNTING_XERO_DATE_FORMAT", defaults.XeroDateFormat),
+	}
+}
+
+// loadAccountCodes reads <prefix>RECEIVABLE_ACCOUNT, <prefix>INCOME_ACCOUNT and so on.
+func loadAccountCodes(prefix string, defaults AccountCodes) AccountCodes {
+	return AccountCodes{
+		Receivable: GetEnv(prefix+"RECEIVABLE_ACCOUNT", defaults.Receivable),
+		Income:     GetEnv(prefix+"INCOME_ACCOUNT", defaults.Income),
+		Cash:       GetEnv(prefix+"CASH_ACCOUNT", defaults.Cash),
+		Insurance:  GetEnv(prefix+"INSURANCE_ACCOUNT", defaults.Insurance),
+		Refunds:    GetEnv(prefix+"REFUNDS_ACCOUNT", defaults.Refunds),
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// AlertRule raises an alert when a counter grows by at least Threshold within one check interval.
+type AlertRule struct {
+	Name      string
+	Metric    string   // Name of the counter in /metrics
+	Labels    []string // Label values selecting one series; empty sums all series
+	Threshold float64  // Increase per interval that raises the alert; 0 disables the rule
+}
+
+// AlertingConfig controls the operational alerts raised from the application's metrics.
+type AlertingConfig struct {
+	Interval   time.Duration // How often counters are compared with the previous check
+	Cooldown   time.Duration // Minimum time between two alerts of the same rule
+	Recipients []string      // Email addresses that receive operational alerts
+	Rules      []AlertRule
+}
+
+// DefaultAlertingConfig returns the alert thresholds used when nothing is configured.
+func DefaultAlertingConfig() AlertingConfig {
+	return AlertingConfig{
+		Interval: time.Minute,
+		Cooldown: 30 * time.Minute,
+		Rules: []AlertRule{
+			{Name: "http_5xx", Metric: "http_responses_total", Labels: []string{"5xx"}, Threshold: 20},
+			{Name: "lock_failures", Metric: "lock_acquisition_failures_total", Threshold: 5},
+			{Name: "cache_errors", Metric: "cache_errors_total", Threshold: 50},
+			{Name: "mail_failures", Metric: "mail_delivery_failures_total", Threshold: 3},
+		},
+	}
+}
+
+// LoadAlertingConfig loads alert settings from environment variables with default fallbacks.
+// Rule thresholds are read from ALERT_THRESHOLD_<RULE>, e.g. ALERT_THRESHOLD_HTTP_5XX.
+func LoadAlertingConfig() AlertingConfig {
+	cfg := DefaultAlertingConfig()
+	cfg.Interval = GetEnvAsDuration("ALERT_CHECK_INTERVAL", cfg.Interval)
+	cfg.Cooldown = GetEnvAsDuration("ALERT_COOLDOWN", cfg.Cooldown)
+	cfg.Recipients = GetEnvAsList("ALERT_EMAILS", nil)
+	for i, rule := range cfg.Rules {
+		cfg.Rules[i].Threshold = GetEnvAsFloat("ALERT_THRESHOLD_"+strings.ToUpper(rule.Name), rule.Threshold)
+	}
+	return cfg
+}
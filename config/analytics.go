@@ -0,0 +1,30 @@
+package config
+
+// AnalyticsConfig controls the nightly job building anonymized reporting aggregates.
+type AnalyticsConfig struct {
+	Enabled       bool // Whether this replica runs the aggregation job
+	RunHour       int  // Local hour of the day at which the job runs
+	RecomputeDays int  // Past days rebuilt on every run, so late edits are picked up
+	MinCellSize   int  // Buckets with fewer visits are merged into "Other" so no one can be singled out
+}
+
+// DefaultAnalyticsConfig returns the analytics settings used when nothing is configured.
+func DefaultAnalyticsConfig() AnalyticsConfig {
+	return AnalyticsConfig{
+		Enabled:       true,
+		RunHour:       2,
+		RecomputeDays: 3,
+		MinCellSize:   5,
+	}
+}
+
+// LoadAnalyticsConfig loads analytics settings from environment variables with default fallbacks.
+func LoadAnalyticsConfig() AnalyticsConfig {
+	defaults := DefaultAnalyticsConfig()
+	return AnalyticsConfig{
+		Enabled:       GetEnvAsBool("ANALYTICS_ENABLED", defaults.Enabled),
+		RunHour:       GetEnvAsInt("ANALYTICS_RUN_HOUR", defaults.RunHour),
+		RecomputeDays: GetEnvAsInt("ANALYTICS_RECOMPUTE_DAYS", defaults.RecomputeDays),
+		MinCellSize:   GetEnvAsInt("ANALYTICS_MIN_CELL_SIZE", defaults.MinCellSize),
+	}
+}
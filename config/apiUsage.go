@@ -0,0 +1,65 @@
+package config
+
+import (
+	"RoyDental/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIUsageConfig controls the counting of API requests by user and integration, for access reviews
+// and the usage analytics, and the soft hourly quotas warned about.
+type APIUsageConfig struct {
+	FlushInterval  time.Duration    // How often the counts kept in memory are added to the database; 0 stops counting
+	HourlyQuota    int64            // Requests a consumer may make in an hour before admins are warned; 0 for none
+	KindQuotas     map[string]int64 // Hourly quota of each kind of consumer, over HourlyQuota
+	ConsumerQuotas map[string]int64 // Hourly quota of single consumers, such as kiosk:3f2a9c1e, over their kind's
+}
+
+// DefaultAPIUsageConfig returns the API usage settings used when nothing is configured.
+func DefaultAPIUsageConfig() APIUsageConfig {
+	return APIUsageConfig{
+		FlushInterval:  time.Minute,
+		KindQuotas:     map[string]int64{},
+		ConsumerQuotas: map[string]int64{},
+	}
+}
+
+// LoadAPIUsageConfig loads API usage settings from environment variables with default fallbacks.
+// Each kind of consumer reads its quota from API_USAGE_HOURLY_QUOTA_<KIND>, and API_USAGE_QUOTAS
+// lists single consumers' quotas, e.g. API_USAGE_QUOTAS=kiosk:3f2a9c1e=2000,user:12=500.
+func LoadAPIUsageConfig() APIUsageConfig {
+	cfg := DefaultAPIUsageConfig()
+	cfg.FlushInterval = GetEnvAsDuration("API_USAGE_FLUSH_INTERVAL", cfg.FlushInterval)
+	cfg.HourlyQuota = int64(GetEnvAsInt("API_USAGE_HOURLY_QUOTA", 0))
+	for _, kind := range models.APIConsumerKinds {
+		if quota := GetEnvAsInt("API_USAGE_HOURLY_QUOTA_"+strings.ToUpper(kind), 0); quota > 0 {
+			cfg.KindQuotas[kind] = int64(quota)
+		}
+	}
+	for _, item := range GetEnvAsList("API_USAGE_QUOTAS", nil) {
+		consumer, value, ok := strings.Cut(item, "=")
+		quota, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if ok && err == nil && quota > 0 {
+			cfg.ConsumerQuotas[strings.TrimSpace(consumer)] = quota
+		}
+	}
+	return cfg
+}
+
+// QuotaFor returns the hourly quota of consumer, 0 when it has none
+func (c APIUsageConfig) QuotaFor(consumer string) int64 {
+	if quota, ok := c.ConsumerQuotas[consumer]; ok {
+		return quota
+	}
+	kind, _, _ := strings.Cut(consumer, ":")
+	if quota, ok := c.KindQuotas[kind]; ok {
+		return quota
+	}
+	return c.HourlyQuota
+}
+
+// HasQuotas reports whether any consumer has an hourly quota
+func (c APIUsageConfig) HasQuotas() bool {
+	return c.HourlyQuota > 0 || len(c.KindQuotas) > 0 || len(c.ConsumerQuotas) > 0
+}
@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// AppointmentReminderConfig controls the reminders patients, or the guardians of patients who are
+// minors, are sent ahead of their appointments.
+type AppointmentReminderConfig struct {
+	DispatchInterval time.Duration // How often upcoming appointments are checked for reminders to send; 0 disables reminders
+	Lead             time.Duration // How long before an appointment its reminder is sent, until the cadences of appointment types are set
+	BatchSize        int           // Appointments reminded of per dispatch run
+}
+
+// DefaultAppointmentReminderConfig returns the appointment reminder settings used when nothing is configured.
+func DefaultAppointmentReminderConfig() AppointmentReminderConfig {
+	return AppointmentReminderConfig{
+		DispatchInterval: 15 * time.Minute,
+		Lead:             24 * time.Hour,
+		BatchSize:        200,
+	}
+}
+
+// LoadAppointmentReminderConfig loads appointment reminder settings from environment variables with default fallbacks.
+func LoadAppointmentReminderConfig() AppointmentReminderConfig {
+	defaults := DefaultAppointmentReminderConfig()
+	return AppointmentReminderConfig{
+		DispatchInterval: GetEnvAsDuration("APPOINTMENT_REMINDER_DISPATCH_INTERVAL", defaults.DispatchInterval),
+		Lead:             GetEnvAsDuration("APPOINTMENT_REMINDER_LEAD", defaults.Lead),
+		BatchSize:        GetEnvAsInt("APPOINTMENT_REMINDER_BATCH_SIZE", defaults.BatchSize),
+	}
+}
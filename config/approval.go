@@ -0,0 +1,19 @@
+package config
+
+// ApprovalConfig controls which actions wait for a second user's approval.
+type ApprovalConfig struct {
+	DiscountThreshold float64 // Percentage below its catalog or contract price from which a bill needs approval; 0 turns the check off
+}
+
+// DefaultApprovalConfig returns the approval settings used when nothing is configured.
+func DefaultApprovalConfig() ApprovalConfig {
+	return ApprovalConfig{DiscountThreshold: 20}
+}
+
+// LoadApprovalConfig loads approval settings from environment variables with default fallbacks.
+func LoadApprovalConfig() ApprovalConfig {
+	defaults := DefaultApprovalConfig()
+	return ApprovalConfig{
+		DiscountThreshold: GetEnvAsFloat("APPROVAL_DISCOUNT_THRESHOLD", defaults.DiscountThreshold),
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// AuditConfig controls the security audit trail and its alerts.
+type AuditConfig struct {
+	AuthFailureAlertThreshold int           // Failures from one IP or user within the window that raise an alert; 0 disables alerts
+	AuthFailureAlertWindow    time.Duration // Window over which authorization failures are counted
+	SecurityAlertRecipients   []string      // Email addresses that receive security alerts
+}
+
+// DefaultAuditConfig returns the audit settings used when nothing is configured.
+func DefaultAuditConfig() AuditConfig {
+	return AuditConfig{
+		AuthFailureAlertThreshold: 10,
+		AuthFailureAlertWindow:    5 * time.Minute,
+	}
+}
+
+// LoadAuditConfig loads audit settings from environment variables with default fallbacks.
+func LoadAuditConfig() AuditConfig {
+	defaults := DefaultAuditConfig()
+	return AuditConfig{
+		AuthFailureAlertThreshold: GetEnvAsInt("AUTH_FAILURE_ALERT_THRESHOLD", defaults.AuthFailureAlertThreshold),
+		AuthFailureAlertWindow:    GetEnvAsDuration("AUTH_FAILURE_ALERT_WINDOW", defaults.AuthFailureAlertWindow),
+		SecurityAlertRecipients:   GetEnvAsList("SECURITY_ALERT_EMAILS", nil),
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// AuditArchiveConfig controls the shipping of the audit trail to an append-only, hash-chained file
+// outside the database.
+type AuditArchiveConfig struct {
+	Path      string        // File the audit trail is appended to, on storage replicas share; empty disables shipping
+	Interval  time.Duration // How often new audit entries are shipped
+	BatchSize int           // Entries shipped per batch
+	Lag       time.Duration // Age an entry must reach before it is shipped, so entries still being written are not skipped
+}
+
+// DefaultAuditArchiveConfig returns the audit archive settings used when nothing is configured.
+func DefaultAuditArchiveConfig() AuditArchiveConfig {
+	return AuditArchiveConfig{
+		Interval:  15 * time.Minute,
+		BatchSize: 1000,
+		Lag:       time.Minute,
+	}
+}
+
+// LoadAuditArchiveConfig loads audit archive settings from environment variables with default fallbacks.
+func LoadAuditArchiveConfig() AuditArchiveConfig {
+	defaults := DefaultAuditArchiveConfig()
+	return AuditArchiveConfig{
+		Path:      GetEnv("AUDIT_ARCHIVE_PATH", defaults.Path),
+		Interval:  GetEnvAsDuration("AUDIT_ARCHIVE_INTERVAL", defaults.Interval),
+		BatchSize: GetEnvAsInt("AUDIT_ARCHIVE_BATCH_SIZE", defaults.BatchSize),
+		Lag:       GetEnvAsDuration("AUDIT_ARCHIVE_LAG", defaults.Lag),
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// AuthRateLimitConfig controls the limits on the login and password reset endpoints, counted in Redis
+// so every replica shares them and kept apart from the general API rate limit. Each endpoint allows
+// PerEmail attempts for one email address and PerIP from one client address within Window.
+type AuthRateLimitConfig struct {
+	Window   time.Duration // Window the attempts are counted over; 0 turns the limits off
+	PerEmail int           // Attempts allowed per email address and endpoint; 0 leaves emails unlimited
+	PerIP    int           // Attempts allowed per client address and endpoint; 0 leaves addresses unlimited
+}
+
+// DefaultAuthRateLimitConfig returns the auth rate limits used when nothing is configured.
+func DefaultAuthRateLimitConfig() AuthRateLimitConfig {
+	return AuthRateLimitConfig{
+		Window:   15 * time.Minute,
+		PerEmail: 5,
+		PerIP:    20,
+	}
+}
+
+// LoadAuthRateLimitConfig loads auth rate limits from environment variables with default fallbacks.
+func LoadAuthRateLimitConfig() AuthRateLimitConfig {
+	defaults := DefaultAuthRateLimitConfig()
+	return AuthRateLimitConfig{
+		Window:   GetEnvAsDuration("AUTH_RATE_LIMIT_WINDOW", defaults.Window),
+		PerEmail: GetEnvAsInt("AUTH_RATE_LIMIT_PER_EMAIL", defaults.PerEmail),
+		PerIP:    GetEnvAsInt("AUTH_RATE_LIMIT_PER_IP", defaults.PerIP),
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"RoyDental/models"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// defaultPolicies is the route authorization policy table the API is built with
+//
+//go:embed policies.json
+var defaultPolicies []byte
+
+// AuthorizationConfig selects the route authorization policy table: which routes need an access
+// token, for which clients and roles, and which permissions those roles must be granted.
+type AuthorizationConfig struct {
+	// PolicyFile replaces the built-in policy table with a JSON file of the same form, e.g. to
+	// narrow routes to fewer roles in a deployment; it must still cover every route
+	PolicyFile string
+}
+
+// DefaultAuthorizationConfig returns the authorization settings used when nothing is configured.
+func DefaultAuthorizationConfig() AuthorizationConfig {
+	return AuthorizationConfig{}
+}
+
+// LoadAuthorizationConfig loads authorization settings from environment variables with default fallbacks.
+func LoadAuthorizationConfig() AuthorizationConfig {
+	defaults := DefaultAuthorizationConfig()
+	return AuthorizationConfig{
+		PolicyFile: GetEnv("AUTHORIZATION_POLICY_FILE", defaults.PolicyFile),
+	}
+}
+
+// Policies reads the policy table, the built-in one unless a policy file is configured
+func (c AuthorizationConfig) Policies() ([]models.AuthPolicy, error) {
+	data := defaultPolicies
+	if c.PolicyFile != "" {
+		var err error
+		if data, err = os.ReadFile(c.PolicyFile); err != nil {
+			return nil, fmt.Errorf("failed to read authorization policies: %w", err)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var policies []models.AuthPolicy
+	if err := decoder.Decode(&policies); err != nil {
+		return nil, fmt.Errorf("invalid authorization policies: %w", err)
+	}
+	return policies, nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+// BreakGlassConfig controls emergency access to records clinicians are otherwise kept out of.
+type BreakGlassConfig struct {
+	Duration        time.Duration // How long an emergency access lasts
+	MinReasonLength int           // Characters the reason given for it must have at least
+}
+
+// DefaultBreakGlassConfig returns the emergency access settings used when nothing is configured.
+func DefaultBreakGlassConfig() BreakGlassConfig {
+	return BreakGlassConfig{
+		Duration:        time.Hour,
+		MinReasonLength: 20,
+	}
+}
+
+// LoadBreakGlassConfig loads emergency access settings from environment variables with default fallbacks.
+func LoadBreakGlassConfig() BreakGlassConfig {
+	defaults := DefaultBreakGlassConfig()
+	return BreakGlassConfig{
+		Duration:        GetEnvAsDuration("BREAK_GLASS_DURATION", defaults.Duration),
+		MinReasonLength: GetEnvAsInt("BREAK_GLASS_MIN_REASON_LENGTH", defaults.MinReasonLength),
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+// CacheCodecConfig controls how cached values are serialised in Redis.
+type CacheCodecConfig struct {
+	Codec             string // "msgpack" or "json"
+	CompressThreshold int    // Values larger than this many bytes are gzipped; 0 disables compression
+}
+
+// DefaultCacheCodecConfig returns the serialisation used when nothing is configured.
+func DefaultCacheCodecConfig() CacheCodecConfig {
+	return CacheCodecConfig{
+		Codec:             "msgpack",
+		CompressThreshold: 1024,
+	}
+}
+
+// LoadCacheCodecConfig loads cache serialisation settings from environment variables.
+func LoadCacheCodecConfig() CacheCodecConfig {
+	defaults := DefaultCacheCodecConfig()
+	return CacheCodecConfig{
+		Codec:             GetEnv("CACHE_CODEC", defaults.Codec),
+		CompressThreshold: GetEnvAsInt("CACHE_COMPRESS_THRESHOLD", defaults.CompressThreshold),
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+// CacheKeyConfig namespaces cache keys so deployments and branches sharing a Redis never read each other's entries.
+type CacheKeyConfig struct {
+	Environment string // Deployment the keys belong to, e.g. production or staging
+	Branch      string // Branch used when a request does not name one
+	Version     int    // Bumped when the shape of cached values changes, so old entries are never decoded
+}
+
+// DefaultCacheKeyConfig returns the key namespace used when nothing is configured.
+func DefaultCacheKeyConfig() CacheKeyConfig {
+	return CacheKeyConfig{
+		Environment: "production",
+		Branch:      "main",
+		Version:     1,
+	}
+}
+
+// LoadCacheKeyConfig loads the cache key namespace from environment variables with default fallbacks.
+func LoadCacheKeyConfig() CacheKeyConfig {
+	defaults := DefaultCacheKeyConfig()
+	return CacheKeyConfig{
+		Environment: GetEnv("ENV", defaults.Environment),
+		Branch:      GetEnv("CACHE_BRANCH", defaults.Branch),
+		Version:     GetEnvAsInt("CACHE_KEY_VERSION", defaults.Version),
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import "time"
+
+// CacheMaintenanceConfig controls the job that clears out the Redis keys nothing removes by itself:
+// reset codes and sessions left without an expiry, locks of processes that crashed while holding
+// them and cached lists grown too big to be worth keeping.
+type CacheMaintenanceConfig struct {
+	Enabled      bool
+	Interval     time.Duration // How often Redis is cleaned
+	ScanCount    int           // Keys looked at per SCAN call
+	MaxListBytes int64         // Size above which a cached list is dropped, to be read again from the database
+	LockMaxAge   time.Duration // How long a lock without an expiry is held before it is taken as orphaned
+}
+
+// DefaultCacheMaintenanceConfig returns the cache maintenance used when nothing is configured.
+func DefaultCacheMaintenanceConfig() CacheMaintenanceConfig {
+	return CacheMaintenanceConfig{
+		Enabled:      true,
+		Interval:     time.Hour,
+		ScanCount:    500,
+		MaxListBytes: 4 << 20,
+		LockMaxAge:   10 * time.Minute,
+	}
+}
+
+// LoadCacheMaintenanceConfig loads cache maintenance from environment variables with default fallbacks.
+func LoadCacheMaintenanceConfig() CacheMaintenanceConfig {
+	defaults := DefaultCacheMaintenanceConfig()
+	cfg := CacheMaintenanceConfig{
+		Enabled:      GetEnvAsBool("CACHE_MAINTENANCE_ENABLED", defaults.Enabled),
+		Interval:     GetEnvAsDuration("CACHE_MAINTENANCE_INTERVAL", defaults.Interval),
+		ScanCount:    GetEnvAsInt("CACHE_MAINTENANCE_SCAN_COUNT", defaults.ScanCount),
+		MaxListBytes: int64(GetEnvAsInt("CACHE_MAX_LIST_BYTES", int(defaults.MaxListBytes))),
+		LockMaxAge:   GetEnvAsDuration("CACHE_MAINTENANCE_LOCK_MAX_AGE", defaults.LockMaxAge),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.ScanCount <= 0 {
+		cfg.ScanCount = defaults.ScanCount
+	}
+	if cfg.MaxListBytes <= 0 {
+		cfg.MaxListBytes = defaults.MaxListBytes
+	}
+	if cfg.LockMaxAge <= 0 {
+		cfg.LockMaxAge = defaults.LockMaxAge
+	}
+	return cfg
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// CacheEntities lists the cached entities that accept per-entity TTL overrides.
+var CacheEntities = []string{
+	"appointment", "billing", "doctor", "emergency_contact", "examination",
+	"insurance_company", "patient", "patient_visits", "treatment_plan", "user",
+}
+
+// EntityTTL holds the expiry of a single entity's item and list caches.
+type EntityTTL struct {
+	Item time.Duration // Expiry of a single cached record
+	List time.Duration // Expiry of a cached list, which goes stale on any write
+	// Refresh is how long before a cached list expires it is refreshed in the background on a read,
+	// while the cached list is still served; 0 lets lists expire and be read again on the next miss
+	Refresh time.Duration
+}
+
+// CacheTTLConfig holds cache expiries with per-entity overrides.
+type CacheTTLConfig struct {
+	Default   EntityTTL
+	Overrides map[string]EntityTTL
+	Jitter    float64 // Fraction of the TTL randomly added or removed, e.g. 0.1 for ±10%
+}
+
+// DefaultCacheTTLConfig returns the cache expiries used when nothing is configured.
+func DefaultCacheTTLConfig() CacheTTLConfig {
+	return CacheTTLConfig{
+		Default: EntityTTL{
+			Item:    7 * 24 * time.Hour,
+			List:    time.Hour,
+			Refresh: 5 * time.Minute,
+		},
+		Overrides: map[string]EntityTTL{
+			// Patients' own visits are cached briefly instead of being dropped on every appointment write
+			"patient_visits": {Item: time.Minute, List: time.Minute},
+		},
+		Jitter: 0.1,
+	}
+}
+
+// LoadCacheTTLConfig loads cache expiries from environment variables with default fallbacks.
+// Per-entity overrides are read from CACHE_TTL_<ENTITY>_ITEM, CACHE_TTL_<ENTITY>_LIST and
+// CACHE_TTL_<ENTITY>_REFRESH.
+func LoadCacheTTLConfig() CacheTTLConfig {
+	cfg := DefaultCacheTTLConfig()
+	cfg.Default.Item = GetEnvAsDuration("CACHE_ITEM_TTL", cfg.Default.Item)
+	cfg.Default.List = GetEnvAsDuration("CACHE_LIST_TTL", cfg.Default.List)
+	cfg.Default.Refresh = GetEnvAsDuration("CACHE_LIST_REFRESH", cfg.Default.Refresh)
+	cfg.Jitter = GetEnvAsFloat("CACHE_TTL_JITTER", cfg.Jitter)
+
+	for _, entity := range CacheEntities {
+		prefix := "CACHE_TTL_" + strings.ToUpper(entity)
+		base := cfg.For(entity)
+		ttl := EntityTTL{
+			Item:    GetEnvAsDuration(prefix+"_ITEM", base.Item),
+			List:    GetEnvAsDuration(prefix+"_LIST", base.List),
+			Refresh: GetEnvAsDuration(prefix+"_REFRESH", base.Refresh),
+		}
+		if ttl != cfg.Default {
+			cfg.Overrides[entity] = ttl
+		}
+	}
+	return cfg
+}
+
+// For returns the expiries for an entity, falling back to the defaults.
+func (c CacheTTLConfig) For(entity string) EntityTTL {
+	if ttl, ok := c.Overrides[entity]; ok {
+		return ttl
+	}
+	return c.Default
+}
@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// CalendarConfig controls the doctors' iCalendar subscription feeds.
+type CalendarConfig struct {
+	SigningKey    string        // Secret signing feed tokens; feeds are disabled when empty
+	BaseURL       string        // Public address of the API used in subscription links, e.g. https://api.example.com
+	EventDuration time.Duration // Length in the feed of an appointment without an end time
+	PastDays      int           // Days of past appointments included in the feed
+	FutureDays    int           // Days of upcoming appointments included in the feed
+}
+
+// DefaultCalendarConfig returns the calendar settings used when nothing is configured.
+func DefaultCalendarConfig() CalendarConfig {
+	return CalendarConfig{
+		EventDuration: 30 * time.Minute,
+		PastDays:      30,
+		FutureDays:    180,
+	}
+}
+
+// LoadCalendarConfig loads calendar settings from environment variables with default fallbacks.
+func LoadCalendarConfig() CalendarConfig {
+	defaults := DefaultCalendarConfig()
+	return CalendarConfig{
+		SigningKey:    GetEnv("CALENDAR_SIGNING_KEY", ""),
+		BaseURL:       GetEnv("CALENDAR_BASE_URL", ""),
+		EventDuration: GetEnvAsDuration("CALENDAR_EVENT_DURATION", defaults.EventDuration),
+		PastDays:      GetEnvAsInt("CALENDAR_PAST_DAYS", defaults.PastDays),
+		FutureDays:    GetEnvAsInt("CALENDAR_FUTURE_DAYS", defaults.FutureDays),
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// CampaignConfig controls how bulk communication campaigns are sent.
+type CampaignConfig struct {
+	SendInterval  time.Duration // How often scheduled campaigns are started and their next recipients sent to; 0 disables sending
+	BatchSize     int           // Messages sent per interval across all campaigns, which throttles the send
+	PreviewSample int           // Messages rendered when previewing a campaign's audience
+}
+
+// DefaultCampaignConfig returns the campaign settings used when nothing is configured.
+func DefaultCampaignConfig() CampaignConfig {
+	return CampaignConfig{
+		SendInterval:  time.Minute,
+		BatchSize:     100,
+		PreviewSample: 5,
+	}
+}
+
+// LoadCampaignConfig loads campaign settings from environment variables with default fallbacks.
+func LoadCampaignConfig() CampaignConfig {
+	defaults := DefaultCampaignConfig()
+	return CampaignConfig{
+		SendInterval:  GetEnvAsDuration("CAMPAIGN_SEND_INTERVAL", defaults.SendInterval),
+		BatchSize:     GetEnvAsInt("CAMPAIGN_BATCH_SIZE", defaults.BatchSize),
+		PreviewSample: GetEnvAsInt("CAMPAIGN_PREVIEW_SAMPLE", defaults.PreviewSample),
+	}
+}
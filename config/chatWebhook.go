@@ -0,0 +1,47 @@
+package config
+
+import "strings"
+
+// ChatEvents lists the event types that can be posted to a chat webhook.
+var ChatEvents = []string{"operational_alert", "new_online_booking", "large_balance", "verification_failed", "appointment_cancelled", "approval_requested", "approval_decided", "eligibility_failed", "credential_expiring", "sms_reply_received"}
+
+// ChatWebhookConfig routes operational alerts and business events to Slack or Teams webhooks.
+type ChatWebhookConfig struct {
+	Format                string            // Payload format of the webhooks: slack or teams
+	URLs                  map[string]string // Webhook per event type; events without one are not posted
+	LargeBalanceThreshold float64           // Billing balance from which a large_balance event is posted
+}
+
+// DefaultChatWebhookConfig returns the chat settings used when nothing is configured.
+func DefaultChatWebhookConfig() ChatWebhookConfig {
+	return ChatWebhookConfig{
+		Format:                "slack",
+		URLs:                  map[string]string{},
+		LargeBalanceThreshold: 50000,
+	}
+}
+
+// LoadChatWebhookConfig loads chat settings from environment variables with default fallbacks.
+// CHAT_WEBHOOK_URL receives the events listed in CHAT_WEBHOOK_EVENTS, and CHAT_WEBHOOK_URL_<EVENT>
+// sends a single event type elsewhere.
+func LoadChatWebhookConfig() ChatWebhookConfig {
+	cfg := DefaultChatWebhookConfig()
+	cfg.Format = GetEnv("CHAT_WEBHOOK_FORMAT", cfg.Format)
+	cfg.LargeBalanceThreshold = GetEnvAsFloat("LARGE_BALANCE_THRESHOLD", cfg.LargeBalanceThreshold)
+
+	if url := GetEnv("CHAT_WEBHOOK_URL", ""); url != "" {
+		for _, event := range GetEnvAsList("CHAT_WEBHOOK_EVENTS", []string{"operational_alert"}) {
+			cfg.URLs[event] = url
+		}
+	}
+	// ALERT_WEBHOOK_URL predates the per-event settings
+	if url := GetEnv("ALERT_WEBHOOK_URL", ""); url != "" {
+		cfg.URLs["operational_alert"] = url
+	}
+	for _, event := range ChatEvents {
+		if url := GetEnv("CHAT_WEBHOOK_URL_"+strings.ToUpper(event), ""); url != "" {
+			cfg.URLs[event] = url
+		}
+	}
+	return cfg
+}
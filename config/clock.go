@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ClockConfig moves the server's clock away from the wall clock, for staging runs that simulate the
+// days ahead. Production refuses both.
+type ClockConfig struct {
+	Environment string        // Deployment the server runs in; only non-production ones may move the clock
+	Offset      time.Duration // How far ahead of the wall clock the server runs; negative runs it behind
+	StartAt     time.Time     // The time the server's clock reads when it starts, running on from there; overrides Offset
+}
+
+// LoadClockConfig loads the clock settings from CLOCK_OFFSET (a duration) and CLOCK_START (RFC 3339).
+func LoadClockConfig() ClockConfig {
+	cfg := ClockConfig{
+		Environment: GetEnv("ENV", "production"),
+		Offset:      GetEnvAsDuration("CLOCK_OFFSET", 0),
+	}
+	if start := GetEnv("CLOCK_START", ""); start != "" {
+		startAt, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			log.Printf("Warning: Invalid CLOCK_START %q, expected RFC 3339; keeping the offset clock", start)
+		} else {
+			cfg.StartAt = startAt
+		}
+	}
+	return cfg
+}
+
+// OffsetFrom returns how far the server's clock runs from the wall clock, which reads now. Moving
+// the clock is refused in production, where it would misdate every record and reminder.
+func (c ClockConfig) OffsetFrom(now time.Time) (time.Duration, error) {
+	offset := c.Offset
+	if !c.StartAt.IsZero() {
+		offset = c.StartAt.Sub(now)
+	}
+	if offset != 0 && c.Environment == "production" {
+		return 0, fmt.Errorf("CLOCK_OFFSET and CLOCK_START are only honoured outside production, and ENV is %q", c.Environment)
+	}
+	return offset, nil
+}
@@ -1,13 +1,198 @@
 package config
 
+import (
+	"errors"
+	"os"
+)
+
 // AppConfig holds the application configuration
 type AppConfig struct {
-	DBURL        string
-	RedisAddress string
-	BearerToken  string
+	DBURL                string
+	RedisAddress         string
+	BearerToken          string
+	Timeouts             TimeoutConfig
+	RequestTimeouts      RequestTimeoutConfig
+	LatencyBudgets       LatencyBudgetConfig
+	LockProvider         string
+	CacheTTLs            CacheTTLConfig
+	CacheCodec           CacheCodecConfig
+	CacheKeys            CacheKeyConfig
+	Startup              StartupConfig
+	Audit                AuditConfig
+	IPFilter             IPFilterConfig
+	SecurityHeaders      SecurityHeadersConfig
+	KioskAPIKeys         []string
+	Survey               SurveyConfig
+	Analytics            AnalyticsConfig
+	Alerting             AlertingConfig
+	ChatWebhooks         ChatWebhookConfig
+	Calendar             CalendarConfig
+	Accounting           AccountingConfig
+	EditLocks            EditLockConfig
+	Imaging              ImagingConfig
+	HL7                  HL7Config
+	ControlledSubstances ControlledSubstanceConfig
+	PaymentPlans         PaymentPlanConfig
+	Scheduling           SchedulingConfig
+	Registration         RegistrationConfig
+	Verification         VerificationConfig
+	EventBus             EventBusConfig
+	DebugLog             DebugLogConfig
+	Portal               PortalConfig
+	Geocoding            GeocodingConfig
+	Greeting             GreetingConfig
+	NoShow               NoShowConfig
+	PaymentGateway       PaymentGatewayConfig
+	PostOp               PostOpConfig
+	Dunning              DunningConfig
+	Campaigns            CampaignConfig
+	BreakGlass           BreakGlassConfig
+	Storage              StorageConfig
+	VirusScan            VirusScanConfig
+	VisitSummary         VisitSummaryConfig
+	Referral             ReferralConfig
+	Payroll              PayrollConfig
+	DocumentShare        DocumentShareConfig
+	Approval             ApprovalConfig
+	IDFormats            IDFormatConfig
+	Integrity            IntegrityConfig
+	ReportTokens         ReportTokenConfig
+	AuditArchive         AuditArchiveConfig
+	AppointmentReminder  AppointmentReminderConfig
+	Guarantors           GuarantorConfig
+	Profiling            ProfilingConfig
+	DuplicateSubmissions DuplicateSubmissionConfig
+	AuthRateLimits       AuthRateLimitConfig
+	EmailDelivery        EmailDeliveryConfig
+	Printing             PrintingConfig
+	PatientQR            PatientQRConfig
+	Eligibility          EligibilityConfig
+	Retention            RetentionConfig
+	APIUsage             APIUsageConfig
+	StaffDirectory       StaffDirectoryConfig
+	Credentials          CredentialConfig
+	Transcription        TranscriptionConfig
+	Website              WebsiteConfig
+	ResponseCache        ResponseCacheConfig
+	Clock                ClockConfig
+	SchemaCheck          SchemaCheckConfig
+	ReadOnly             ReadOnlyConfig
+	SMSReplies           SMSReplyConfig
+	DailySummary         DailySummaryConfig
+	QuietHours           QuietHoursConfig
+	Console              ConsoleConfig
+	DeliveryRetry        DeliveryRetryConfig
+	CacheMaintenance     CacheMaintenanceConfig
+	SIEM                 SIEMConfig
+	Authorization        AuthorizationConfig
+	LeaderElection       LeaderElectionConfig
 }
 
 // GetBearerToken returns the BearerToken from the config
 func (c *AppConfig) GetBearerToken() string {
 	return c.BearerToken
 }
+
+// LoadAppConfig loads configuration from environment variables.
+func LoadAppConfig() (*AppConfig, error) {
+	// Get the database URL
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		return nil, errors.New("missing DB_URL environment variable")
+	}
+
+	// Get the Redis URL (Sentinel and Cluster modes use REDIS_ADDRS instead)
+	redisAddress := os.Getenv("REDIS_URL")
+	if redisAddress == "" && GetEnv("REDIS_MODE", "single") == "single" {
+		return nil, errors.New("missing REDIS_URL environment variable")
+	}
+
+	// Get the Bearer Token
+	bearerToken := os.Getenv("BEARER_TOKEN")
+	if bearerToken == "" {
+		return nil, errors.New("missing BEARER_TOKEN environment variable")
+	}
+
+	// Returning the AppConfig with dynamic database name and other values
+	return &AppConfig{
+		DBURL:                dbURL,
+		RedisAddress:         redisAddress,
+		BearerToken:          bearerToken,
+		Timeouts:             LoadTimeoutConfig(),
+		RequestTimeouts:      LoadRequestTimeoutConfig(),
+		LatencyBudgets:       LoadLatencyBudgetConfig(),
+		LockProvider:         GetEnv("LOCK_PROVIDER", "redis"),
+		CacheTTLs:            LoadCacheTTLConfig(),
+		CacheCodec:           LoadCacheCodecConfig(),
+		CacheKeys:            LoadCacheKeyConfig(),
+		Startup:              LoadStartupConfig(),
+		Audit:                LoadAuditConfig(),
+		IPFilter:             LoadIPFilterConfig(),
+		SecurityHeaders:      LoadSecurityHeadersConfig(),
+		KioskAPIKeys:         GetEnvAsList("KIOSK_API_KEYS", nil),
+		Survey:               LoadSurveyConfig(),
+		Analytics:            LoadAnalyticsConfig(),
+		Alerting:             LoadAlertingConfig(),
+		ChatWebhooks:         LoadChatWebhookConfig(),
+		Calendar:             LoadCalendarConfig(),
+		Accounting:           LoadAccountingConfig(),
+		EditLocks:            LoadEditLockConfig(),
+		Imaging:              LoadImagingConfig(),
+		HL7:                  LoadHL7Config(),
+		ControlledSubstances: LoadControlledSubstanceConfig(),
+		PaymentPlans:         LoadPaymentPlanConfig(),
+		Scheduling:           LoadSchedulingConfig(),
+		Registration:         LoadRegistrationConfig(),
+		Verification:         LoadVerificationConfig(),
+		EventBus:             LoadEventBusConfig(),
+		DebugLog:             LoadDebugLogConfig(),
+		Portal:               LoadPortalConfig(),
+		Geocoding:            LoadGeocodingConfig(),
+		Greeting:             LoadGreetingConfig(),
+		NoShow:               LoadNoShowConfig(),
+		PaymentGateway:       LoadPaymentGatewayConfig(),
+		PostOp:               LoadPostOpConfig(),
+		Dunning:              LoadDunningConfig(),
+		Campaigns:            LoadCampaignConfig(),
+		BreakGlass:           LoadBreakGlassConfig(),
+		Storage:              LoadStorageConfig(),
+		VirusScan:            LoadVirusScanConfig(),
+		VisitSummary:         LoadVisitSummaryConfig(),
+		Referral:             LoadReferralConfig(),
+		Payroll:              LoadPayrollConfig(),
+		DocumentShare:        LoadDocumentShareConfig(),
+		Approval:             LoadApprovalConfig(),
+		IDFormats:            LoadIDFormatConfig(),
+		Integrity:            LoadIntegrityConfig(),
+		ReportTokens:         LoadReportTokenConfig(),
+		AuditArchive:         LoadAuditArchiveConfig(),
+		AppointmentReminder:  LoadAppointmentReminderConfig(),
+		Guarantors:           LoadGuarantorConfig(),
+		Profiling:            LoadProfilingConfig(),
+		DuplicateSubmissions: LoadDuplicateSubmissionConfig(),
+		AuthRateLimits:       LoadAuthRateLimitConfig(),
+		EmailDelivery:        LoadEmailDeliveryConfig(),
+		Printing:             LoadPrintingConfig(),
+		PatientQR:            LoadPatientQRConfig(),
+		Eligibility:          LoadEligibilityConfig(),
+		Retention:            LoadRetentionConfig(),
+		APIUsage:             LoadAPIUsageConfig(),
+		StaffDirectory:       LoadStaffDirectoryConfig(),
+		Credentials:          LoadCredentialConfig(),
+		Transcription:        LoadTranscriptionConfig(),
+		Website:              LoadWebsiteConfig(),
+		ResponseCache:        LoadResponseCacheConfig(),
+		Clock:                LoadClockConfig(),
+		SchemaCheck:          LoadSchemaCheckConfig(),
+		ReadOnly:             LoadReadOnlyConfig(),
+		SMSReplies:           LoadSMSReplyConfig(),
+		DailySummary:         LoadDailySummaryConfig(),
+		QuietHours:           LoadQuietHoursConfig(),
+		Console:              LoadConsoleConfig(),
+		DeliveryRetry:        LoadDeliveryRetryConfig(),
+		CacheMaintenance:     LoadCacheMaintenanceConfig(),
+		SIEM:                 LoadSIEMConfig(),
+		Authorization:        LoadAuthorizationConfig(),
+		LeaderElection:       LoadLeaderElectionConfig(),
+	}, nil
+}
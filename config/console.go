@@ -0,0 +1,25 @@
+package config
+
+// ConsoleConfig controls the admin console the API serves at /console, a web page admins manage the
+// settings, cache, background jobs and audit trail from without a separate operations front-end.
+type ConsoleConfig struct {
+	Enabled               bool
+	ContentSecurityPolicy string // Applied to the console's pages, which load their own scripts and styles
+}
+
+// DefaultConsoleConfig returns the console settings used when nothing is configured.
+func DefaultConsoleConfig() ConsoleConfig {
+	return ConsoleConfig{
+		Enabled:               true,
+		ContentSecurityPolicy: "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:; form-action 'none'; base-uri 'none'; frame-ancestors 'none'",
+	}
+}
+
+// LoadConsoleConfig loads console settings from environment variables with default fallbacks.
+func LoadConsoleConfig() ConsoleConfig {
+	defaults := DefaultConsoleConfig()
+	return ConsoleConfig{
+		Enabled:               GetEnvAsBool("CONSOLE_ENABLED", defaults.Enabled),
+		ContentSecurityPolicy: GetEnv("CONSOLE_CONTENT_SECURITY_POLICY", defaults.ContentSecurityPolicy),
+	}
+}
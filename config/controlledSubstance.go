@@ -0,0 +1,27 @@
+package config
+
+// ControlledSubstanceConfig controls who may prescribe controlled substances and how the register
+// is reported to the regulator.
+type ControlledSubstanceConfig struct {
+	PrescriberRoles      []string // Roles allowed to prescribe controlled substances; the user must also be linked to a doctor
+	PracticeRegistration string   // The practice's registration number, printed on the regulator export
+	MaxDurationDays      int      // Longest course of a controlled substance that may be prescribed
+}
+
+// DefaultControlledSubstanceConfig returns the controlled substance settings used when nothing is configured.
+func DefaultControlledSubstanceConfig() ControlledSubstanceConfig {
+	return ControlledSubstanceConfig{
+		PrescriberRoles: []string{"Doctor"},
+		MaxDurationDays: 30,
+	}
+}
+
+// LoadControlledSubstanceConfig loads controlled substance settings from environment variables with default fallbacks.
+func LoadControlledSubstanceConfig() ControlledSubstanceConfig {
+	defaults := DefaultControlledSubstanceConfig()
+	return ControlledSubstanceConfig{
+		PrescriberRoles:      GetEnvAsList("CONTROLLED_PRESCRIBER_ROLES", defaults.PrescriberRoles),
+		PracticeRegistration: GetEnv("CONTROLLED_PRACTICE_REGISTRATION", defaults.PracticeRegistration),
+		MaxDurationDays:      GetEnvAsInt("CONTROLLED_MAX_DURATION_DAYS", defaults.MaxDurationDays),
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// CredentialConfig controls the reminders about doctors' licences, indemnity insurance and CPD
+// compliance running out.
+type CredentialConfig struct {
+	ReminderInterval time.Duration // How often credentials are checked for reminders to send; 0 disables reminders
+	WarningDays      int           // How many days before a credential expires it is reported as expiring and reminded of
+}
+
+// DefaultCredentialConfig returns the credential reminder settings used when nothing is configured.
+func DefaultCredentialConfig() CredentialConfig {
+	return CredentialConfig{
+		ReminderInterval: 24 * time.Hour,
+		WarningDays:      30,
+	}
+}
+
+// LoadCredentialConfig loads credential reminder settings from environment variables with default fallbacks.
+func LoadCredentialConfig() CredentialConfig {
+	defaults := DefaultCredentialConfig()
+	return CredentialConfig{
+		ReminderInterval: GetEnvAsDuration("CREDENTIAL_REMINDER_INTERVAL", defaults.ReminderInterval),
+		WarningDays:      GetEnvAsInt("CREDENTIAL_WARNING_DAYS", defaults.WarningDays),
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+// DailySummaryConfig controls the end-of-day summary emailed to management.
+type DailySummaryConfig struct {
+	Recipients []string // Email addresses the summary is sent to; none disables the email
+	SendHour   int      // Local hour of the day at which the day's summary is sent
+}
+
+// DefaultDailySummaryConfig returns the daily summary settings used when nothing is configured.
+func DefaultDailySummaryConfig() DailySummaryConfig {
+	return DailySummaryConfig{
+		SendHour: 19,
+	}
+}
+
+// LoadDailySummaryConfig loads daily summary settings from environment variables with default fallbacks.
+func LoadDailySummaryConfig() DailySummaryConfig {
+	defaults := DefaultDailySummaryConfig()
+	return DailySummaryConfig{
+		Recipients: GetEnvAsList("DAILY_SUMMARY_EMAILS", defaults.Recipients),
+		SendHour:   GetEnvAsInt("DAILY_SUMMARY_SEND_HOUR", defaults.SendHour),
+	}
+}
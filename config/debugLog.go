@@ -0,0 +1,39 @@
+package config
+
+import "time"
+
+// DebugLogConfig controls the request and response body log used to reproduce client-reported
+// issues. Admins switch it on and off at runtime through the settings API.
+type DebugLogConfig struct {
+	Enabled         bool          // Whether the log is on until an Admin changes the setting
+	Dir             string        // Directory of the log files
+	MaxFileSize     int64         // Size in bytes at which the current file is rotated
+	MaxFiles        int           // Rotated files kept next to the current one
+	MaxBodySize     int           // Bytes of each request and response body that are logged
+	RefreshInterval time.Duration // How often the setting is reloaded, so every instance follows a change
+}
+
+// DefaultDebugLogConfig returns the debug log settings used when nothing is configured.
+func DefaultDebugLogConfig() DebugLogConfig {
+	return DebugLogConfig{
+		Enabled:         false,
+		Dir:             "logs",
+		MaxFileSize:     10 << 20,
+		MaxFiles:        5,
+		MaxBodySize:     16 << 10,
+		RefreshInterval: 30 * time.Second,
+	}
+}
+
+// LoadDebugLogConfig loads debug log settings from environment variables with default fallbacks.
+func LoadDebugLogConfig() DebugLogConfig {
+	defaults := DefaultDebugLogConfig()
+	return DebugLogConfig{
+		Enabled:         GetEnvAsBool("DEBUG_LOG_ENABLED", defaults.Enabled),
+		Dir:             GetEnv("DEBUG_LOG_DIR", defaults.Dir),
+		MaxFileSize:     int64(GetEnvAsInt("DEBUG_LOG_MAX_FILE_SIZE", int(defaults.MaxFileSize))),
+		MaxFiles:        GetEnvAsInt("DEBUG_LOG_MAX_FILES", defaults.MaxFiles),
+		MaxBodySize:     GetEnvAsInt("DEBUG_LOG_MAX_BODY_SIZE", defaults.MaxBodySize),
+		RefreshInterval: GetEnvAsDuration("DEBUG_LOG_REFRESH_INTERVAL", defaults.RefreshInterval),
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import "time"
+
+// DeliveryRetryConfig controls how emails, text messages and chat webhook posts that failed are
+// tried again: after BaseDelay, then twice as long after each failure up to MaxDelay, until
+// MaxAttempts were made and the delivery is kept in the dead-letter queue.
+type DeliveryRetryConfig struct {
+	Enabled     bool
+	Interval    time.Duration // How often deliveries that are due are tried again
+	BatchSize   int           // Deliveries tried per run
+	BaseDelay   time.Duration // How long a delivery waits after its first failure
+	MaxDelay    time.Duration // Longest wait between two attempts
+	MaxAttempts int           // Attempts, the first one included, before a delivery is given up
+	ClaimLease  time.Duration // How long a replica has to try the deliveries it claimed before others may
+}
+
+// DefaultDeliveryRetryConfig returns the delivery retries used when nothing is configured.
+func DefaultDeliveryRetryConfig() DeliveryRetryConfig {
+	return DeliveryRetryConfig{
+		Enabled:     true,
+		Interval:    30 * time.Second,
+		BatchSize:   50,
+		BaseDelay:   time.Minute,
+		MaxDelay:    2 * time.Hour,
+		MaxAttempts: 8,
+		ClaimLease:  5 * time.Minute,
+	}
+}
+
+// LoadDeliveryRetryConfig loads delivery retries from environment variables with default fallbacks.
+func LoadDeliveryRetryConfig() DeliveryRetryConfig {
+	defaults := DefaultDeliveryRetryConfig()
+	cfg := DeliveryRetryConfig{
+		Enabled:     GetEnvAsBool("DELIVERY_RETRY_ENABLED", defaults.Enabled),
+		Interval:    GetEnvAsDuration("DELIVERY_RETRY_INTERVAL", defaults.Interval),
+		BatchSize:   GetEnvAsInt("DELIVERY_RETRY_BATCH_SIZE", defaults.BatchSize),
+		BaseDelay:   GetEnvAsDuration("DELIVERY_RETRY_BASE_DELAY", defaults.BaseDelay),
+		MaxDelay:    GetEnvAsDuration("DELIVERY_RETRY_MAX_DELAY", defaults.MaxDelay),
+		MaxAttempts: GetEnvAsInt("DELIVERY_RETRY_MAX_ATTEMPTS", defaults.MaxAttempts),
+		ClaimLease:  GetEnvAsDuration("DELIVERY_RETRY_CLAIM_LEASE", defaults.ClaimLease),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		cfg.MaxDelay = cfg.BaseDelay
+	}
+	return cfg
+}
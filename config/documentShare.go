@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// DocumentShareConfig controls sharing patients' documents with them through signed links.
+type DocumentShareConfig struct {
+	ClinicName string        // Name messages and invoices are signed with
+	SigningKey string        // Secret signing the share links; sharing is disabled without it or BaseURL
+	BaseURL    string        // Public page the signed link points to, e.g. https://example.com/documents
+	LinkTTL    time.Duration // How long a share link stays valid unless staff choose otherwise
+	MaxLinkTTL time.Duration // The longest a share link may stay valid
+}
+
+// DefaultDocumentShareConfig returns the document sharing settings used when nothing is configured.
+func DefaultDocumentShareConfig() DocumentShareConfig {
+	return DocumentShareConfig{
+		ClinicName: "RoyDental",
+		LinkTTL:    72 * time.Hour,
+		MaxLinkTTL: 30 * 24 * time.Hour,
+	}
+}
+
+// LoadDocumentShareConfig loads document sharing settings from environment variables with default fallbacks.
+func LoadDocumentShareConfig() DocumentShareConfig {
+	defaults := DefaultDocumentShareConfig()
+	return DocumentShareConfig{
+		ClinicName: GetEnv("DOCUMENT_SHARE_CLINIC_NAME", defaults.ClinicName),
+		SigningKey: GetEnv("DOCUMENT_SHARE_SIGNING_KEY", ""),
+		BaseURL:    GetEnv("DOCUMENT_SHARE_BASE_URL", ""),
+		LinkTTL:    GetEnvAsDuration("DOCUMENT_SHARE_LINK_TTL", defaults.LinkTTL),
+		MaxLinkTTL: GetEnvAsDuration("DOCUMENT_SHARE_MAX_LINK_TTL", defaults.MaxLinkTTL),
+	}
+}
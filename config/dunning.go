@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// DunningConfig controls the statements sent to patients about overdue bills.
+type DunningConfig struct {
+	DispatchInterval time.Duration // How often overdue bills are checked for statements to send; 0 disables dunning
+	PaymentTermsDays int           // Days after a bill is raised before it counts as overdue
+	BatchSize        int           // Bills considered per dispatch run
+}
+
+// DefaultDunningConfig returns the dunning settings used when nothing is configured.
+func DefaultDunningConfig() DunningConfig {
+	return DunningConfig{
+		DispatchInterval: time.Hour,
+		PaymentTermsDays: 0,
+		BatchSize:        200,
+	}
+}
+
+// LoadDunningConfig loads dunning settings from environment variables with default fallbacks.
+func LoadDunningConfig() DunningConfig {
+	defaults := DefaultDunningConfig()
+	return DunningConfig{
+		DispatchInterval: GetEnvAsDuration("DUNNING_DISPATCH_INTERVAL", defaults.DispatchInterval),
+		PaymentTermsDays: GetEnvAsInt("DUNNING_PAYMENT_TERMS_DAYS", defaults.PaymentTermsDays),
+		BatchSize:        GetEnvAsInt("DUNNING_BATCH_SIZE", defaults.BatchSize),
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// DuplicateSubmissionConfig controls how identical create requests from the same user are caught,
+// such as a form saved twice by a double click. The first is handled and the others within Window
+// get its response instead of creating a twin.
+type DuplicateSubmissionConfig struct {
+	Window time.Duration // How long a submission is remembered; 0 turns detection off
+	Wait   time.Duration // How long a duplicate waits for the first submission's response
+}
+
+// DefaultDuplicateSubmissionConfig returns the duplicate submission settings used when nothing is configured.
+func DefaultDuplicateSubmissionConfig() DuplicateSubmissionConfig {
+	return DuplicateSubmissionConfig{
+		Window: 10 * time.Second,
+		Wait:   5 * time.Second,
+	}
+}
+
+// LoadDuplicateSubmissionConfig loads duplicate submission settings from environment variables with default fallbacks.
+func LoadDuplicateSubmissionConfig() DuplicateSubmissionConfig {
+	defaults := DefaultDuplicateSubmissionConfig()
+	return DuplicateSubmissionConfig{
+		Window: GetEnvAsDuration("DUPLICATE_SUBMISSION_WINDOW", defaults.Window),
+		Wait:   GetEnvAsDuration("DUPLICATE_SUBMISSION_WAIT", defaults.Wait),
+	}
+}
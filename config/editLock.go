@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// EditLockConfig controls the soft locks staff take on a record while editing it.
+type EditLockConfig struct {
+	TTL time.Duration // How long a lock lasts without a heartbeat, e.g. after the browser tab is closed
+}
+
+// DefaultEditLockConfig returns the edit lock settings used when nothing is configured.
+func DefaultEditLockConfig() EditLockConfig {
+	return EditLockConfig{
+		TTL: 2 * time.Minute,
+	}
+}
+
+// LoadEditLockConfig loads edit lock settings from environment variables with default fallbacks.
+func LoadEditLockConfig() EditLockConfig {
+	defaults := DefaultEditLockConfig()
+	return EditLockConfig{
+		TTL: GetEnvAsDuration("EDIT_LOCK_TTL", defaults.TTL),
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// EligibilityConfig points insurance eligibility checks at the insurer integration.
+type EligibilityConfig struct {
+	URL     string        // Webhook asking the insurer whether a patient is covered; staff check by hand without it
+	Token   string        // Bearer token sent to the webhook
+	Timeout time.Duration // How long a single check may take
+}
+
+// DefaultEligibilityConfig returns the eligibility check settings used when nothing is configured.
+func DefaultEligibilityConfig() EligibilityConfig {
+	return EligibilityConfig{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// LoadEligibilityConfig loads eligibility check settings from environment variables with default fallbacks.
+func LoadEligibilityConfig() EligibilityConfig {
+	defaults := DefaultEligibilityConfig()
+	return EligibilityConfig{
+		URL:     GetEnv("ELIGIBILITY_URL", ""),
+		Token:   GetEnv("ELIGIBILITY_TOKEN", ""),
+		Timeout: GetEnvAsDuration("ELIGIBILITY_TIMEOUT", defaults.Timeout),
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+// EmailDeliveryConfig controls the endpoint the mail provider reports bounces and complaints to.
+type EmailDeliveryConfig struct {
+	WebhookSecret string // Secret the provider signs its notifications with; they are refused while empty
+}
+
+// DefaultEmailDeliveryConfig returns the email delivery settings used when nothing is configured.
+func DefaultEmailDeliveryConfig() EmailDeliveryConfig {
+	return EmailDeliveryConfig{}
+}
+
+// LoadEmailDeliveryConfig loads email delivery settings from environment variables with default fallbacks.
+func LoadEmailDeliveryConfig() EmailDeliveryConfig {
+	defaults := DefaultEmailDeliveryConfig()
+	return EmailDeliveryConfig{
+		WebhookSecret: GetEnv("EMAIL_WEBHOOK_SECRET", defaults.WebhookSecret),
+	}
+}
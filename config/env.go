@@ -0,0 +1,76 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns the value of an environment variable or the default when it is unset.
+func GetEnv(name, defaultValue string) string {
+	if value, exists := os.LookupEnv(name); exists && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvAsInt returns an environment variable parsed as an int, or the default.
+func GetEnvAsInt(name string, defaultValue int) int {
+	if value, exists := os.LookupEnv(name); exists {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+		log.Printf("Warning: Invalid integer value for %s, using default: %d", name, defaultValue)
+	}
+	return defaultValue
+}
+
+// GetEnvAsFloat returns an environment variable parsed as a float64, or the default.
+func GetEnvAsFloat(name string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(name); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		log.Printf("Warning: Invalid float value for %s, using default: %g", name, defaultValue)
+	}
+	return defaultValue
+}
+
+// GetEnvAsBool returns an environment variable parsed as a bool, or the default.
+func GetEnvAsBool(name string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(name); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+		log.Printf("Warning: Invalid boolean value for %s, using default: %t", name, defaultValue)
+	}
+	return defaultValue
+}
+
+// GetEnvAsDuration returns an environment variable parsed as a time.Duration, or the default.
+func GetEnvAsDuration(name string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(name); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+		log.Printf("Warning: Invalid duration value for %s, using default: %s", name, defaultValue.String())
+	}
+	return defaultValue
+}
+
+// GetEnvAsList returns a comma-separated environment variable as a trimmed slice, or the default.
+func GetEnvAsList(name string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(name)
+	if !exists || strings.TrimSpace(value) == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
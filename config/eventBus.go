@@ -0,0 +1,23 @@
+package config
+
+// EventBusConfig controls how domain events travel between instances.
+type EventBusConfig struct {
+	RedisBridge bool   // Forward events over Redis pub/sub so handlers on other instances see them
+	Channel     string // Redis channel the instances share
+}
+
+// DefaultEventBusConfig returns the event bus settings used when nothing is configured.
+func DefaultEventBusConfig() EventBusConfig {
+	return EventBusConfig{
+		Channel: "roydental:events",
+	}
+}
+
+// LoadEventBusConfig loads event bus settings from environment variables with default fallbacks.
+func LoadEventBusConfig() EventBusConfig {
+	defaults := DefaultEventBusConfig()
+	return EventBusConfig{
+		RedisBridge: GetEnvAsBool("EVENT_BUS_REDIS_BRIDGE", defaults.RedisBridge),
+		Channel:     GetEnv("EVENT_BUS_CHANNEL", defaults.Channel),
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import "time"
+
+// GeocodingConfig selects the provider placing patient addresses on the map, and where the clinic
+// is, for reports on how far patients travel.
+type GeocodingConfig struct {
+	Provider        string        // "nominatim" or "webhook"; addresses are not geocoded when empty
+	URL             string        // Endpoint of the provider; Nominatim defaults to the public OpenStreetMap server
+	Token           string        // Bearer token sent to a webhook provider
+	CountryCodes    string        // Countries Nominatim searches in, e.g. "ke"
+	UserAgent       string        // Identifies the clinic to Nominatim, as its usage policy requires
+	Timeout         time.Duration // How long a single lookup may take
+	RequestInterval time.Duration // Pause between lookups, so the provider's rate limit is respected
+	PollInterval    time.Duration // How often new and changed addresses are looked up
+	BatchSize       int           // Addresses looked up per poll
+	ClinicLatitude  float64       // Where the clinic is; distances are not reported while both are 0
+	ClinicLongitude float64
+}
+
+// DefaultGeocodingConfig returns the geocoding settings used when nothing is configured.
+func DefaultGeocodingConfig() GeocodingConfig {
+	return GeocodingConfig{
+		UserAgent:       "RoyDental",
+		Timeout:         10 * time.Second,
+		RequestInterval: time.Second,
+		PollInterval:    5 * time.Minute,
+		BatchSize:       100,
+	}
+}
+
+// LoadGeocodingConfig loads geocoding settings from environment variables with default fallbacks.
+func LoadGeocodingConfig() GeocodingConfig {
+	defaults := DefaultGeocodingConfig()
+	return GeocodingConfig{
+		Provider:        GetEnv("GEOCODING_PROVIDER", ""),
+		URL:             GetEnv("GEOCODING_URL", ""),
+		Token:           GetEnv("GEOCODING_TOKEN", ""),
+		CountryCodes:    GetEnv("GEOCODING_COUNTRY_CODES", ""),
+		UserAgent:       GetEnv("GEOCODING_USER_AGENT", defaults.UserAgent),
+		Timeout:         GetEnvAsDuration("GEOCODING_TIMEOUT", defaults.Timeout),
+		RequestInterval: GetEnvAsDuration("GEOCODING_REQUEST_INTERVAL", defaults.RequestInterval),
+		PollInterval:    GetEnvAsDuration("GEOCODING_POLL_INTERVAL", defaults.PollInterval),
+		BatchSize:       GetEnvAsInt("GEOCODING_BATCH_SIZE", defaults.BatchSize),
+		ClinicLatitude:  GetEnvAsFloat("CLINIC_LATITUDE", 0),
+		ClinicLongitude: GetEnvAsFloat("CLINIC_LONGITUDE", 0),
+	}
+}
+
+// ClinicLocated reports whether the clinic's coordinates are configured
+func (c GeocodingConfig) ClinicLocated() bool {
+	return c.ClinicLatitude != 0 || c.ClinicLongitude != 0
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// GreetingConfig controls the birthday greetings sent while the birthday_greetings setting is on.
+// The templates are Go text templates given the patient's .FirstName, .LastName and .Age.
+type GreetingConfig struct {
+	DispatchInterval time.Duration // How often today's birthdays are checked for greetings to send
+	SendHour         int           // Clinic hour of the day from which greetings are sent
+	Subject          string        // Subject of a birthday greeting
+	Body             string        // Body of a birthday greeting
+	MilestoneSubject string        // Subject of the greeting on a milestone birthday
+	MilestoneBody    string        // Body of the greeting on a milestone birthday
+	MilestoneAges    []int         // Ages that are milestone birthdays
+}
+
+// DefaultGreetingConfig returns the greeting settings used when nothing is configured.
+func DefaultGreetingConfig() GreetingConfig {
+	return GreetingConfig{
+		DispatchInterval: time.Hour,
+		SendHour:         9,
+		Subject:          "Happy birthday, {{.FirstName}}!",
+		Body:             "Dear {{.FirstName}},\n\nEveryone at the clinic wishes you a very happy birthday!\n",
+		MilestoneSubject: "Happy {{.Age}}th birthday, {{.FirstName}}!",
+		MilestoneBody:    "Dear {{.FirstName}},\n\nCongratulations on turning {{.Age}}! Everyone at the clinic wishes you a wonderful birthday.\n",
+		MilestoneAges:    []int{18, 30, 40, 50, 60, 70, 80, 90, 100},
+	}
+}
+
+// LoadGreetingConfig loads greeting settings from environment variables with default fallbacks.
+func LoadGreetingConfig() GreetingConfig {
+	defaults := DefaultGreetingConfig()
+	ages := defaults.MilestoneAges
+	if values := GetEnvAsList("GREETING_MILESTONE_AGES", nil); values != nil {
+		ages = nil
+		for _, value := range values {
+			if age, err := strconv.Atoi(value); err == nil {
+				ages = append(ages, age)
+			}
+		}
+	}
+	return GreetingConfig{
+		DispatchInterval: GetEnvAsDuration("GREETING_DISPATCH_INTERVAL", defaults.DispatchInterval),
+		SendHour:         GetEnvAsInt("GREETING_SEND_HOUR", defaults.SendHour),
+		Subject:          GetEnv("GREETING_SUBJECT", defaults.Subject),
+		Body:             GetEnv("GREETING_BODY", defaults.Body),
+		MilestoneSubject: GetEnv("GREETING_MILESTONE_SUBJECT", defaults.MilestoneSubject),
+		MilestoneBody:    GetEnv("GREETING_MILESTONE_BODY", defaults.MilestoneBody),
+		MilestoneAges:    ages,
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+// GuarantorConfig controls which patients must have a guarantor, the responsible adult who is sent
+// their bills and reminders and gives consent on their behalf.
+type GuarantorConfig struct {
+	AdultAge int // Age from which patients answer for themselves; younger patients must have a guarantor
+}
+
+// DefaultGuarantorConfig returns the guarantor settings used when nothing is configured.
+func DefaultGuarantorConfig() GuarantorConfig {
+	return GuarantorConfig{
+		AdultAge: 18,
+	}
+}
+
+// LoadGuarantorConfig loads guarantor settings from environment variables with default fallbacks.
+func LoadGuarantorConfig() GuarantorConfig {
+	defaults := DefaultGuarantorConfig()
+	return GuarantorConfig{
+		AdultAge: GetEnvAsInt("GUARANTOR_ADULT_AGE", defaults.AdultAge),
+	}
+}
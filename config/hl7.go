@@ -0,0 +1,30 @@
+package config
+
+// HL7Config controls the listener for the partner hospital's HL7v2 ADT messages.
+type HL7Config struct {
+	MLLPAddress       string   // Address the MLLP listener binds, e.g. ":2575"; MLLP is disabled when empty
+	APIKeys           []string // Keys for posting messages over HTTP; the HTTP listener is disabled when empty
+	SendingFacilities []string // Facilities (MSH-4) messages are accepted from; any when empty
+	Application       string   // Our application name in acknowledgements (MSH-3)
+	Facility          string   // Our facility name in acknowledgements (MSH-4)
+}
+
+// DefaultHL7Config returns the HL7 settings used when nothing is configured.
+func DefaultHL7Config() HL7Config {
+	return HL7Config{
+		Application: "ROYDENTAL",
+		Facility:    "ROYDENTAL",
+	}
+}
+
+// LoadHL7Config loads HL7 settings from environment variables with default fallbacks.
+func LoadHL7Config() HL7Config {
+	defaults := DefaultHL7Config()
+	return HL7Config{
+		MLLPAddress:       GetEnv("HL7_MLLP_ADDRESS", ""),
+		APIKeys:           GetEnvAsList("HL7_API_KEYS", nil),
+		SendingFacilities: GetEnvAsList("HL7_SENDING_FACILITIES", nil),
+		Application:       GetEnv("HL7_APPLICATION", defaults.Application),
+		Facility:          GetEnv("HL7_FACILITY", defaults.Facility),
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import "strings"
+
+// IDEntities lists the records whose IDs are written in a configurable format.
+var IDEntities = []string{"patient", "doctor", "billing", "insurance_company"}
+
+// IDFormat is how the IDs of an entity are written: DP-000123, or INV-2025-000123 when the
+// numbering starts over each year.
+type IDFormat struct {
+	Prefix      string
+	Padding     int  // Digits the number is zero-padded to
+	YearlyReset bool // Whether the year follows the prefix and the numbering starts over each year
+}
+
+// IDFormatConfig holds the ID format of each entity in IDEntities.
+type IDFormatConfig map[string]IDFormat
+
+// DefaultIDFormatConfig returns the ID formats used when nothing is configured.
+func DefaultIDFormatConfig() IDFormatConfig {
+	return IDFormatConfig{
+		"patient":           {Prefix: "DP-", Padding: 6},
+		"doctor":            {Prefix: "DR-", Padding: 6},
+		"billing":           {Prefix: "PB-", Padding: 6},
+		"insurance_company": {Prefix: "IC-", Padding: 6},
+	}
+}
+
+// LoadIDFormatConfig loads ID formats from environment variables with default fallbacks. Each
+// entity reads ID_FORMAT_<ENTITY>_PREFIX, ID_FORMAT_<ENTITY>_PADDING and ID_FORMAT_<ENTITY>_YEARLY_RESET.
+func LoadIDFormatConfig() IDFormatConfig {
+	cfg := DefaultIDFormatConfig()
+	for _, entity := range IDEntities {
+		prefix := "ID_FORMAT_" + strings.ToUpper(entity)
+		format := IDFormat{
+			Prefix:      GetEnv(prefix+"_PREFIX", cfg[entity].Prefix),
+			Padding:     GetEnvAsInt(prefix+"_PADDING", cfg[entity].Padding),
+			YearlyReset: GetEnvAsBool(prefix+"_YEARLY_RESET", cfg[entity].YearlyReset),
+		}
+		if format.Padding < 0 || format.Padding > 18 {
+			format.Padding = cfg[entity].Padding
+		}
+		cfg[entity] = format
+	}
+	return cfg
+}
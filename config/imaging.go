@@ -0,0 +1,26 @@
+package config
+
+// ImagingConfig controls how DICOM files from the clinic's imaging unit are received.
+type ImagingConfig struct {
+	APIKeys      []string // Keys the imaging unit authenticates with; ingestion is disabled when empty
+	PatientIDTag string   // DICOM tag carrying our patient ID, e.g. "0010,0020" or a private tag
+	MaxFileSize  int64    // Largest DICOM file accepted, in bytes
+}
+
+// DefaultImagingConfig returns the imaging settings used when nothing is configured.
+func DefaultImagingConfig() ImagingConfig {
+	return ImagingConfig{
+		PatientIDTag: "0010,0020",
+		MaxFileSize:  100 << 20,
+	}
+}
+
+// LoadImagingConfig loads imaging settings from environment variables with default fallbacks.
+func LoadImagingConfig() ImagingConfig {
+	defaults := DefaultImagingConfig()
+	return ImagingConfig{
+		APIKeys:      GetEnvAsList("IMAGING_API_KEYS", nil),
+		PatientIDTag: GetEnv("IMAGING_PATIENT_ID_TAG", defaults.PatientIDTag),
+		MaxFileSize:  int64(GetEnvAsInt("IMAGING_MAX_FILE_MB", int(defaults.MaxFileSize>>20))) << 20,
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// IntegrityConfig controls the checks for orphaned records, stale cache entries and bad balances.
+type IntegrityConfig struct {
+	CheckInterval  time.Duration // How often the checks run on their own; 0 leaves them to admins
+	AutoRepair     bool          // Whether the scheduled checks also repair what they find
+	RepairBalances bool          // Whether the scheduled checks recompute drifted balances even without AutoRepair, which is always safe
+	CacheSample    int           // Most recently updated patients, doctors and bills compared with their cached copies
+	IssueLimit     int           // Issues reported per check in a single run
+}
+
+// DefaultIntegrityConfig returns the integrity check settings used when nothing is configured.
+func DefaultIntegrityConfig() IntegrityConfig {
+	return IntegrityConfig{
+		CheckInterval:  24 * time.Hour,
+		AutoRepair:     false,
+		RepairBalances: true,
+		CacheSample:    200,
+		IssueLimit:     500,
+	}
+}
+
+// LoadIntegrityConfig loads integrity check settings from environment variables with default fallbacks.
+func LoadIntegrityConfig() IntegrityConfig {
+	defaults := DefaultIntegrityConfig()
+	return IntegrityConfig{
+		CheckInterval:  GetEnvAsDuration("INTEGRITY_CHECK_INTERVAL", defaults.CheckInterval),
+		AutoRepair:     GetEnvAsBool("INTEGRITY_AUTO_REPAIR", defaults.AutoRepair),
+		RepairBalances: GetEnvAsBool("INTEGRITY_REPAIR_BALANCES", defaults.RepairBalances),
+		CacheSample:    GetEnvAsInt("INTEGRITY_CACHE_SAMPLE", defaults.CacheSample),
+		IssueLimit:     GetEnvAsInt("INTEGRITY_ISSUE_LIMIT", defaults.IssueLimit),
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+// IPRule restricts the clients that may reach routes under a path prefix.
+type IPRule struct {
+	PathPrefix string
+	Allow      []string // CIDRs or addresses; when set, only these clients are admitted
+	Deny       []string // CIDRs or addresses that are always rejected
+}
+
+// IPFilterConfig holds network access rules applied before authentication.
+type IPFilterConfig struct {
+	Rules            []IPRule
+	BlockedCountries []string // ISO 3166-1 alpha-2 codes rejected on every route
+	CountryHeader    string   // Header set by the edge proxy/CDN carrying the client's country
+	TrustedProxies   []string // Proxies whose X-Forwarded-For is believed when resolving client IPs
+}
+
+// LoadIPFilterConfig loads network access rules from environment variables.
+func LoadIPFilterConfig() IPFilterConfig {
+	cfg := IPFilterConfig{
+		BlockedCountries: GetEnvAsList("BLOCKED_COUNTRIES", nil),
+		CountryHeader:    GetEnv("GEO_COUNTRY_HEADER", "CF-IPCountry"),
+		TrustedProxies:   GetEnvAsList("TRUSTED_PROXIES", nil),
+	}
+	if deny := GetEnvAsList("IP_DENYLIST", nil); len(deny) > 0 {
+		cfg.Rules = append(cfg.Rules, IPRule{PathPrefix: "/", Deny: deny})
+	}
+	if allow := GetEnvAsList("ADMIN_IP_ALLOWLIST", nil); len(allow) > 0 {
+		cfg.Rules = append(cfg.Rules, IPRule{PathPrefix: "/auth/admin", Allow: allow})
+	}
+	return cfg
+}
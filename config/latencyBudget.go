@@ -0,0 +1,64 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// LatencyBudgetConfig holds how long requests of each route group, named by their first path
+// segment as for request deadlines, are expected to take, and when heavy groups are turned away so
+// they do not starve interactive traffic such as booking of database connections.
+type LatencyBudgetConfig struct {
+	Default    time.Duration
+	Overrides  map[string]time.Duration
+	Shed       bool          // Whether requests of ShedGroups are answered 503 while the pool is saturated
+	ShedGroups []string      // Heavy route groups, such as reports and exports
+	ShedRatio  float64       // Share of the open connection limit in use at which the pool counts as saturated
+	RetryAfter time.Duration // When clients turned away are told to try again
+}
+
+// DefaultLatencyBudgetConfig returns the latency budgets used when nothing is configured.
+func DefaultLatencyBudgetConfig() LatencyBudgetConfig {
+	return LatencyBudgetConfig{
+		Default: 2 * time.Second,
+		Overrides: map[string]time.Duration{
+			"exports": 15 * time.Second,
+			"reports": 10 * time.Second,
+		},
+		Shed:       false,
+		ShedGroups: []string{"exports", "reports"},
+		ShedRatio:  0.9,
+		RetryAfter: 30 * time.Second,
+	}
+}
+
+// LoadLatencyBudgetConfig loads latency budgets from environment variables with default fallbacks.
+// Per-group budgets are read from LATENCY_BUDGET_<GROUP>.
+func LoadLatencyBudgetConfig() LatencyBudgetConfig {
+	cfg := DefaultLatencyBudgetConfig()
+	cfg.Default = GetEnvAsDuration("LATENCY_BUDGET", cfg.Default)
+	for _, group := range RequestTimeoutGroups {
+		if budget := GetEnvAsDuration("LATENCY_BUDGET_"+strings.ToUpper(group), cfg.For(group)); budget != cfg.For(group) {
+			cfg.Overrides[group] = budget
+		}
+	}
+	cfg.Shed = GetEnvAsBool("SHED_HEAVY_REQUESTS", cfg.Shed)
+	cfg.ShedGroups = GetEnvAsList("SHED_GROUPS", cfg.ShedGroups)
+	cfg.ShedRatio = GetEnvAsFloat("SHED_POOL_RATIO", cfg.ShedRatio)
+	cfg.RetryAfter = GetEnvAsDuration("SHED_RETRY_AFTER", cfg.RetryAfter)
+	return cfg
+}
+
+// For returns the latency budget of a route group, falling back to the default.
+func (c LatencyBudgetConfig) For(group string) time.Duration {
+	if budget, ok := c.Overrides[group]; ok {
+		return budget
+	}
+	return c.Default
+}
+
+// Sheddable reports whether requests of a route group are turned away while the pool is saturated.
+func (c LatencyBudgetConfig) Sheddable(group string) bool {
+	return c.Shed && slices.Contains(c.ShedGroups, group)
+}
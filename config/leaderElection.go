@@ -0,0 +1,39 @@
+package config
+
+import "time"
+
+// LeaderElectionConfig controls how replicas elect the one running the scheduled background jobs,
+// such as reminders, dunning and archiving, so each runs once however many replicas serve the API.
+// The others stand by and take over when the leader stops renewing its lease.
+type LeaderElectionConfig struct {
+	Enabled       bool          // Every replica runs the scheduled jobs when disabled, as a single instance does
+	Lease         time.Duration // How long the leader leads without renewing; a standby takes over at most this long after it stops
+	RenewInterval time.Duration // How often the leader renews its lease and standbys try to take it
+}
+
+// DefaultLeaderElectionConfig returns the leader election used when nothing is configured.
+func DefaultLeaderElectionConfig() LeaderElectionConfig {
+	return LeaderElectionConfig{
+		Enabled:       true,
+		Lease:         15 * time.Second,
+		RenewInterval: 5 * time.Second,
+	}
+}
+
+// LoadLeaderElectionConfig loads leader election from environment variables with default fallbacks.
+func LoadLeaderElectionConfig() LeaderElectionConfig {
+	defaults := DefaultLeaderElectionConfig()
+	cfg := LeaderElectionConfig{
+		Enabled:       GetEnvAsBool("LEADER_ELECTION_ENABLED", defaults.Enabled),
+		Lease:         GetEnvAsDuration("LEADER_ELECTION_LEASE", defaults.Lease),
+		RenewInterval: GetEnvAsDuration("LEADER_ELECTION_RENEW_INTERVAL", defaults.RenewInterval),
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaults.Lease
+	}
+	// The lease must outlast a few renewals, or a leader slowed for a moment loses it
+	if cfg.RenewInterval <= 0 || cfg.RenewInterval > cfg.Lease/2 {
+		cfg.RenewInterval = cfg.Lease / 3
+	}
+	return cfg
+}
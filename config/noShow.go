@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+// NoShowConfig selects the scorer rating how likely upcoming appointments are to be missed, so the
+// desk can double-confirm or overbook risky slots.
+type NoShowConfig struct {
+	Scorer        string        // "heuristic" or "webhook"; appointments are not scored when empty
+	URL           string        // Endpoint of a webhook scorer
+	Token         string        // Bearer token sent to a webhook scorer
+	Timeout       time.Duration // How long scoring a single appointment may take
+	PollInterval  time.Duration // How often upcoming appointments are scored again
+	HorizonDays   int           // Days ahead whose appointments are scored
+	HighRisk      float64       // Score from which an appointment counts as high risk
+	HistoryMonths int           // Months of a patient's past appointments the heuristic looks at
+}
+
+// DefaultNoShowConfig returns the no-show scoring settings used when nothing is configured.
+func DefaultNoShowConfig() NoShowConfig {
+	return NoShowConfig{
+		Scorer:        "heuristic",
+		Timeout:       10 * time.Second,
+		PollInterval:  time.Hour,
+		HorizonDays:   14,
+		HighRisk:      0.4,
+		HistoryMonths: 24,
+	}
+}
+
+// LoadNoShowConfig loads no-show scoring settings from environment variables with default fallbacks.
+func LoadNoShowConfig() NoShowConfig {
+	defaults := DefaultNoShowConfig()
+	return NoShowConfig{
+		Scorer:        GetEnv("NO_SHOW_SCORER", defaults.Scorer),
+		URL:           GetEnv("NO_SHOW_SCORER_URL", ""),
+		Token:         GetEnv("NO_SHOW_SCORER_TOKEN", ""),
+		Timeout:       GetEnvAsDuration("NO_SHOW_SCORER_TIMEOUT", defaults.Timeout),
+		PollInterval:  GetEnvAsDuration("NO_SHOW_POLL_INTERVAL", defaults.PollInterval),
+		HorizonDays:   GetEnvAsInt("NO_SHOW_HORIZON_DAYS", defaults.HorizonDays),
+		HighRisk:      GetEnvAsFloat("NO_SHOW_HIGH_RISK", defaults.HighRisk),
+		HistoryMonths: GetEnvAsInt("NO_SHOW_HISTORY_MONTHS", defaults.HistoryMonths),
+	}
+}
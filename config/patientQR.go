@@ -0,0 +1,19 @@
+package config
+
+// PatientQRConfig controls the signed QR codes identifying patients on printed documents.
+type PatientQRConfig struct {
+	SigningKey string // Secret signing the codes; documents are printed without them and lookups are refused while empty
+}
+
+// DefaultPatientQRConfig returns the patient QR code settings used when nothing is configured.
+func DefaultPatientQRConfig() PatientQRConfig {
+	return PatientQRConfig{}
+}
+
+// LoadPatientQRConfig loads patient QR code settings from environment variables with default fallbacks.
+func LoadPatientQRConfig() PatientQRConfig {
+	defaults := DefaultPatientQRConfig()
+	return PatientQRConfig{
+		SigningKey: GetEnv("PATIENT_QR_SIGNING_KEY", defaults.SigningKey),
+	}
+}
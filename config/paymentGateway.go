@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+// PaymentGatewayConfig selects the gateway patients pay their bills through from the portal.
+type PaymentGatewayConfig struct {
+	Provider       string        // "webhook"; online payments are disabled when empty
+	URL            string        // Endpoint starting a checkout at the gateway
+	Token          string        // Bearer token sent to the gateway
+	CallbackSecret string        // Secret the gateway signs its payment callbacks with
+	Currency       string        // ISO 4217 code payments are taken in
+	ReturnURL      string        // Page the gateway sends patients back to after paying
+	Timeout        time.Duration // How long starting a checkout may take
+}
+
+// DefaultPaymentGatewayConfig returns the payment gateway settings used when nothing is configured.
+func DefaultPaymentGatewayConfig() PaymentGatewayConfig {
+	return PaymentGatewayConfig{
+		Currency: "KES",
+		Timeout:  15 * time.Second,
+	}
+}
+
+// LoadPaymentGatewayConfig loads payment gateway settings from environment variables with default fallbacks.
+func LoadPaymentGatewayConfig() PaymentGatewayConfig {
+	defaults := DefaultPaymentGatewayConfig()
+	return PaymentGatewayConfig{
+		Provider:       GetEnv("PAYMENT_GATEWAY_PROVIDER", ""),
+		URL:            GetEnv("PAYMENT_GATEWAY_URL", ""),
+		Token:          GetEnv("PAYMENT_GATEWAY_TOKEN", ""),
+		CallbackSecret: GetEnv("PAYMENT_GATEWAY_CALLBACK_SECRET", ""),
+		Currency:       GetEnv("PAYMENT_GATEWAY_CURRENCY", defaults.Currency),
+		ReturnURL:      GetEnv("PAYMENT_GATEWAY_RETURN_URL", ""),
+		Timeout:        GetEnvAsDuration("PAYMENT_GATEWAY_TIMEOUT", defaults.Timeout),
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+// PaymentPlanConfig controls overdue detection and reminders for installment payment plans.
+type PaymentPlanConfig struct {
+	DispatchInterval time.Duration // How often installments are checked for reminders and overdue notices
+	ReminderLeadDays int           // Days before an installment is due that the patient is reminded; 0 disables reminders
+}
+
+// DefaultPaymentPlanConfig returns the payment plan settings used when nothing is configured.
+func DefaultPaymentPlanConfig() PaymentPlanConfig {
+	return PaymentPlanConfig{
+		DispatchInterval: time.Hour,
+		ReminderLeadDays: 3,
+	}
+}
+
+// LoadPaymentPlanConfig loads payment plan settings from environment variables with default fallbacks.
+func LoadPaymentPlanConfig() PaymentPlanConfig {
+	defaults := DefaultPaymentPlanConfig()
+	return PaymentPlanConfig{
+		DispatchInterval: GetEnvAsDuration("PAYMENT_PLAN_DISPATCH_INTERVAL", defaults.DispatchInterval),
+		ReminderLeadDays: GetEnvAsInt("PAYMENT_PLAN_REMINDER_LEAD_DAYS", defaults.ReminderLeadDays),
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+// PayrollConfig controls the monthly payroll export.
+type PayrollConfig struct {
+	CommissionRate float64 // Percentage of the money collected on their bills paid to doctors without a rate of their own
+}
+
+// DefaultPayrollConfig returns the payroll settings used when nothing is configured.
+func DefaultPayrollConfig() PayrollConfig {
+	return PayrollConfig{CommissionRate: 0}
+}
+
+// LoadPayrollConfig loads payroll settings from environment variables with default fallbacks.
+func LoadPayrollConfig() PayrollConfig {
+	defaults := DefaultPayrollConfig()
+	return PayrollConfig{
+		CommissionRate: GetEnvAsFloat("PAYROLL_COMMISSION_RATE", defaults.CommissionRate),
+	}
+}
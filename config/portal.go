@@ -0,0 +1,16 @@
+package config
+
+// PortalConfig controls the patient portal pages patients reach through signed links in the
+// messages sent to them.
+type PortalConfig struct {
+	SigningKey string // Secret signing portal links; the portal is disabled without it or BaseURL
+	BaseURL    string // Public page managing communication preferences, e.g. https://example.com/preferences
+}
+
+// LoadPortalConfig loads patient portal settings from environment variables.
+func LoadPortalConfig() PortalConfig {
+	return PortalConfig{
+		SigningKey: GetEnv("PORTAL_SIGNING_KEY", ""),
+		BaseURL:    GetEnv("PORTAL_BASE_URL", ""),
+	}
+}
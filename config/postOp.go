@@ -0,0 +1,20 @@
+package config
+
+// PostOpConfig controls the post-operative instructions sent after fulfilled appointments.
+type PostOpConfig struct {
+	DefaultLanguage string // Language of the instructions sent to patients with none in their own
+}
+
+// DefaultPostOpConfig returns the post-operative instruction settings used when nothing is configured.
+func DefaultPostOpConfig() PostOpConfig {
+	return PostOpConfig{DefaultLanguage: "en"}
+}
+
+// LoadPostOpConfig loads post-operative instruction settings from environment variables with
+// default fallbacks.
+func LoadPostOpConfig() PostOpConfig {
+	defaults := DefaultPostOpConfig()
+	return PostOpConfig{
+		DefaultLanguage: GetEnv("POST_OP_DEFAULT_LANGUAGE", defaults.DefaultLanguage),
+	}
+}
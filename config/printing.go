@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// PrintingConfig controls the print queue the front desk's printers are fed from by print agents
+// running next to them.
+type PrintingConfig struct {
+	AgentKeys    []string      // Keys print agents authenticate with; agents are refused when empty
+	ClinicName   string        // Name printed documents are headed with
+	ClaimTimeout time.Duration // How long an agent has to report on a job before it is queued again
+}
+
+// DefaultPrintingConfig returns the printing settings used when nothing is configured.
+func DefaultPrintingConfig() PrintingConfig {
+	return PrintingConfig{
+		ClinicName:   "RoyDental",
+		ClaimTimeout: 2 * time.Minute,
+	}
+}
+
+// LoadPrintingConfig loads printing settings from environment variables with default fallbacks.
+func LoadPrintingConfig() PrintingConfig {
+	defaults := DefaultPrintingConfig()
+	return PrintingConfig{
+		AgentKeys:    GetEnvAsList("PRINT_AGENT_KEYS", nil),
+		ClinicName:   GetEnv("PRINT_CLINIC_NAME", defaults.ClinicName),
+		ClaimTimeout: GetEnvAsDuration("PRINT_CLAIM_TIMEOUT", defaults.ClaimTimeout),
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+// ProfilingConfig controls Gin's debug mode and the net/http/pprof endpoints used to capture CPU and
+// heap profiles. In development both are on and the profiles need no login; elsewhere the profiles
+// are only served to Admins, and only when enabled.
+type ProfilingConfig struct {
+	Environment string // Deployment the server runs in, e.g. development, staging or production
+	Enabled     bool   // Whether Admins can capture profiles outside development
+}
+
+// DefaultProfilingConfig returns the profiling settings used when nothing is configured.
+func DefaultProfilingConfig() ProfilingConfig {
+	return ProfilingConfig{
+		Environment: "production",
+		Enabled:     false,
+	}
+}
+
+// LoadProfilingConfig loads profiling settings from environment variables with default fallbacks.
+func LoadProfilingConfig() ProfilingConfig {
+	defaults := DefaultProfilingConfig()
+	return ProfilingConfig{
+		Environment: GetEnv("ENV", defaults.Environment),
+		Enabled:     GetEnvAsBool("PPROF_ENABLED", defaults.Enabled),
+	}
+}
+
+// Development reports whether the server runs in development, where Gin logs in debug mode and the
+// profiles are open to anyone who can reach the server.
+func (c ProfilingConfig) Development() bool {
+	return c.Environment == "development"
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+// QuietHoursConfig controls when patients are not contacted. Messages sent to them on the quiet
+// channels during quiet hours, or on a public holiday of the closure calendar, are held and
+// delivered once the quiet period ends.
+type QuietHoursConfig struct {
+	Start            string        // Clinic time of day, as HH:MM, quiet hours start; they are off when it equals End
+	End              string        // Clinic time of day, as HH:MM, quiet hours end, the next day when before Start
+	Channels         []string      // Channels held back during quiet hours, email and sms
+	PublicHolidays   bool          // Whether public holidays are quiet all day
+	DeliveryInterval time.Duration // How often held messages that are due are delivered
+	BatchSize        int           // Held messages delivered per run
+	MaxAttempts      int           // Deliveries tried before a held message is given up
+	RetryDelay       time.Duration // How long a held message that failed waits before it is tried again
+}
+
+// DefaultQuietHoursConfig returns the quiet hours used when nothing is configured.
+func DefaultQuietHoursConfig() QuietHoursConfig {
+	return QuietHoursConfig{
+		Start:            "20:00",
+		End:              "08:00",
+		Channels:         []string{"sms"},
+		PublicHolidays:   true,
+		DeliveryInterval: time.Minute,
+		BatchSize:        100,
+		MaxAttempts:      3,
+		RetryDelay:       15 * time.Minute,
+	}
+}
+
+// LoadQuietHoursConfig loads quiet hours from environment variables with default fallbacks.
+func LoadQuietHoursConfig() QuietHoursConfig {
+	defaults := DefaultQuietHoursConfig()
+	return QuietHoursConfig{
+		Start:            GetEnv("QUIET_HOURS_START", defaults.Start),
+		End:              GetEnv("QUIET_HOURS_END", defaults.End),
+		Channels:         GetEnvAsList("QUIET_HOURS_CHANNELS", defaults.Channels),
+		PublicHolidays:   GetEnvAsBool("QUIET_HOURS_PUBLIC_HOLIDAYS", defaults.PublicHolidays),
+		DeliveryInterval: GetEnvAsDuration("QUIET_HOURS_DELIVERY_INTERVAL", defaults.DeliveryInterval),
+		BatchSize:        GetEnvAsInt("QUIET_HOURS_BATCH_SIZE", defaults.BatchSize),
+		MaxAttempts:      GetEnvAsInt("QUIET_HOURS_MAX_ATTEMPTS", defaults.MaxAttempts),
+		RetryDelay:       GetEnvAsDuration("QUIET_HOURS_RETRY_DELAY", defaults.RetryDelay),
+	}
+}
+
+// Applies reports whether messages on channel are held back during quiet periods
+func (c QuietHoursConfig) Applies(channel string) bool {
+	return slices.Contains(c.Channels, channel)
+}
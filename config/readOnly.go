@@ -0,0 +1,39 @@
+package config
+
+import "time"
+
+// ReadOnlyConfig controls read-only mode, in which writes are refused while reads go on, and the read
+// replica reads fail over to while the primary database is unreachable.
+type ReadOnlyConfig struct {
+	ReplicaURL       string        // Connection string of a read replica of the primary; empty configures none
+	Automatic        bool          // Whether the server turns read-only on its own while the primary is down and the replica is up
+	ProbeInterval    time.Duration // How often the databases are probed and the admins' toggle read; 0 leaves the toggle to this server
+	FailureThreshold int           // Failed probes of the primary in a row before reads fail over to the replica
+	RetryAfter       time.Duration // Retry-After sent with the writes refused
+}
+
+// DefaultReadOnlyConfig returns the read-only mode settings used when nothing is configured.
+func DefaultReadOnlyConfig() ReadOnlyConfig {
+	return ReadOnlyConfig{
+		Automatic:        true,
+		ProbeInterval:    5 * time.Second,
+		FailureThreshold: 3,
+		RetryAfter:       30 * time.Second,
+	}
+}
+
+// LoadReadOnlyConfig loads read-only mode settings from environment variables with default fallbacks.
+func LoadReadOnlyConfig() ReadOnlyConfig {
+	defaults := DefaultReadOnlyConfig()
+	cfg := ReadOnlyConfig{
+		ReplicaURL:       GetEnv("DB_REPLICA_URL", ""),
+		Automatic:        GetEnvAsBool("READ_ONLY_AUTOMATIC", defaults.Automatic),
+		ProbeInterval:    GetEnvAsDuration("READ_ONLY_PROBE_INTERVAL", defaults.ProbeInterval),
+		FailureThreshold: GetEnvAsInt("READ_ONLY_FAILURE_THRESHOLD", defaults.FailureThreshold),
+		RetryAfter:       GetEnvAsDuration("READ_ONLY_RETRY_AFTER", defaults.RetryAfter),
+	}
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	return cfg
+}
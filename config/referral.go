@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// ReferralConfig controls the referral letters sent to specialists.
+type ReferralConfig struct {
+	ClinicName    string        // Name the letters are headed with
+	SigningKey    string        // Secret signing the imaging links in letters; letters list images without links when empty
+	BaseURL       string        // Public address of the API used in imaging links, e.g. https://api.example.com
+	LinkTTL       time.Duration // How long an imaging link in a letter stays valid
+	ExcerptLength int           // Characters of each examination report quoted in a letter
+}
+
+// DefaultReferralConfig returns the referral settings used when nothing is configured.
+func DefaultReferralConfig() ReferralConfig {
+	return ReferralConfig{
+		ClinicName:    "RoyDental",
+		LinkTTL:       30 * 24 * time.Hour,
+		ExcerptLength: 600,
+	}
+}
+
+// LoadReferralConfig loads referral settings from environment variables with default fallbacks.
+func LoadReferralConfig() ReferralConfig {
+	defaults := DefaultReferralConfig()
+	return ReferralConfig{
+		ClinicName:    GetEnv("REFERRAL_CLINIC_NAME", defaults.ClinicName),
+		SigningKey:    GetEnv("REFERRAL_SIGNING_KEY", ""),
+		BaseURL:       GetEnv("REFERRAL_BASE_URL", ""),
+		LinkTTL:       GetEnvAsDuration("REFERRAL_LINK_TTL", defaults.LinkTTL),
+		ExcerptLength: GetEnvAsInt("REFERRAL_EXCERPT_LENGTH", defaults.ExcerptLength),
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+// RegistrationConfig controls the public pre-registration form new patients fill in before their first visit.
+type RegistrationConfig struct {
+	CaptchaSecret    string // Server-side captcha secret; the public form is disabled without it
+	CaptchaVerifyURL string // Verification endpoint; reCAPTCHA and hCaptcha share the same protocol
+}
+
+// DefaultRegistrationConfig returns the pre-registration settings used when nothing is configured.
+func DefaultRegistrationConfig() RegistrationConfig {
+	return RegistrationConfig{
+		CaptchaVerifyURL: "https://www.google.com/recaptcha/api/siteverify",
+	}
+}
+
+// LoadRegistrationConfig loads pre-registration settings from environment variables with default fallbacks.
+func LoadRegistrationConfig() RegistrationConfig {
+	defaults := DefaultRegistrationConfig()
+	return RegistrationConfig{
+		CaptchaSecret:    GetEnv("REGISTRATION_CAPTCHA_SECRET", ""),
+		CaptchaVerifyURL: GetEnv("REGISTRATION_CAPTCHA_VERIFY_URL", defaults.CaptchaVerifyURL),
+	}
+}
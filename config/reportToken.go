@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// ReportTokenConfig controls the read-only tokens minted for reporting tools.
+type ReportTokenConfig struct {
+	Lifetime time.Duration // How long a token works when minted without an expiry; 0 keeps it until revoked
+}
+
+// DefaultReportTokenConfig returns the report token settings used when nothing is configured.
+func DefaultReportTokenConfig() ReportTokenConfig {
+	return ReportTokenConfig{Lifetime: 90 * 24 * time.Hour}
+}
+
+// LoadReportTokenConfig loads report token settings from environment variables with default fallbacks.
+func LoadReportTokenConfig() ReportTokenConfig {
+	defaults := DefaultReportTokenConfig()
+	return ReportTokenConfig{
+		Lifetime: GetEnvAsDuration("REPORT_TOKEN_LIFETIME", defaults.Lifetime),
+	}
+}
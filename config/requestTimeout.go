@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// RequestTimeoutGroups lists the route groups, named by their first path segment, that accept their own deadline.
+var RequestTimeoutGroups = []string{
+	"appointments", "billings", "debug", "doctors", "exports", "insurance_companies", "me",
+	"patients", "queue", "reports", "staff", "surveys", "visits",
+}
+
+// RequestTimeoutConfig holds the deadline of each request with per-group overrides.
+// Deadlines above the server's 30s write timeout have no effect.
+type RequestTimeoutConfig struct {
+	Default   time.Duration
+	Overrides map[string]time.Duration
+}
+
+// DefaultRequestTimeoutConfig returns the request deadlines used when nothing is configured.
+func DefaultRequestTimeoutConfig() RequestTimeoutConfig {
+	return RequestTimeoutConfig{
+		Default:   20 * time.Second,
+		Overrides: map[string]time.Duration{},
+	}
+}
+
+// LoadRequestTimeoutConfig loads request deadlines from environment variables with default fallbacks.
+// Per-group overrides are read from REQUEST_TIMEOUT_<GROUP>.
+func LoadRequestTimeoutConfig() RequestTimeoutConfig {
+	cfg := DefaultRequestTimeoutConfig()
+	cfg.Default = GetEnvAsDuration("REQUEST_TIMEOUT", cfg.Default)
+
+	for _, group := range RequestTimeoutGroups {
+		if timeout := GetEnvAsDuration("REQUEST_TIMEOUT_"+strings.ToUpper(group), cfg.Default); timeout != cfg.Default {
+			cfg.Overrides[group] = timeout
+		}
+	}
+	return cfg
+}
+
+// For returns the deadline of a route group, falling back to the default.
+func (c RequestTimeoutConfig) For(group string) time.Duration {
+	if timeout, ok := c.Overrides[group]; ok {
+		return timeout
+	}
+	return c.Default
+}
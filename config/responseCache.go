@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// ResponseCacheConfig controls the HTTP cache of expensive GETs that answer every user alike, such
+// as the doctors list and the procedure catalog. Entries are dropped as soon as the records behind
+// them change, so TTL only bounds how long an entry missed by an invalidation can live.
+type ResponseCacheConfig struct {
+	TTL     time.Duration // How long a response is kept; 0 turns the cache off
+	MaxSize int           // Largest response body kept, in bytes
+}
+
+// DefaultResponseCacheConfig returns the response cache settings used when nothing is configured.
+func DefaultResponseCacheConfig() ResponseCacheConfig {
+	return ResponseCacheConfig{
+		TTL:     10 * time.Minute,
+		MaxSize: 1 << 20,
+	}
+}
+
+// LoadResponseCacheConfig loads response cache settings from environment variables with default fallbacks.
+func LoadResponseCacheConfig() ResponseCacheConfig {
+	defaults := DefaultResponseCacheConfig()
+	return ResponseCacheConfig{
+		TTL:     GetEnvAsDuration("RESPONSE_CACHE_TTL", defaults.TTL),
+		MaxSize: GetEnvAsInt("RESPONSE_CACHE_MAX_KB", defaults.MaxSize>>10) << 10,
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// RetentionEntities lists the records retention rules can be set for.
+var RetentionEntities = []string{"communication_log", "document_share_access", "examination", "hl7_message", "print_job", "record_access"}
+
+// What a retention rule does with records older than its period
+const (
+	RetentionPurge   = "purge"   // Delete them
+	RetentionArchive = "archive" // Write them to the archive directory, then delete them
+)
+
+// RetentionRule is how long an entity's records are kept and what happens to them afterwards.
+type RetentionRule struct {
+	Action string
+	Days   int
+}
+
+// RetentionConfig holds the data retention rules of the data-protection policy and the job enforcing them.
+type RetentionConfig struct {
+	Rules      map[string]RetentionRule // Rule of each entity in RetentionEntities; entities without one are kept
+	Interval   time.Duration            // How often the rules are enforced; 0 leaves them to be run by hand
+	BatchSize  int                      // Records removed per transaction
+	ArchiveDir string                   // Directory archived records are written to as JSON lines
+}
+
+// DefaultRetentionConfig returns the retention settings used when nothing is configured: every record is kept.
+func DefaultRetentionConfig() RetentionConfig {
+	return RetentionConfig{
+		Rules:     map[string]RetentionRule{},
+		Interval:  24 * time.Hour,
+		BatchSize: 1000,
+	}
+}
+
+// LoadRetentionConfig loads retention settings from environment variables with default fallbacks. Each
+// entity reads RETENTION_<ENTITY>_ACTION, purge or archive, and RETENTION_<ENTITY>_DAYS, e.g.
+// RETENTION_COMMUNICATION_LOG_ACTION=purge with RETENTION_COMMUNICATION_LOG_DAYS=730.
+func LoadRetentionConfig() RetentionConfig {
+	cfg := DefaultRetentionConfig()
+	cfg.Interval = GetEnvAsDuration("RETENTION_INTERVAL", cfg.Interval)
+	cfg.BatchSize = GetEnvAsInt("RETENTION_BATCH_SIZE", cfg.BatchSize)
+	cfg.ArchiveDir = GetEnv("RETENTION_ARCHIVE_DIR", cfg.ArchiveDir)
+	for _, entity := range RetentionEntities {
+		prefix := "RETENTION_" + strings.ToUpper(entity)
+		rule := RetentionRule{
+			Action: strings.ToLower(GetEnv(prefix+"_ACTION", "")),
+			Days:   GetEnvAsInt(prefix+"_DAYS", 0),
+		}
+		if (rule.Action == RetentionPurge || rule.Action == RetentionArchive) && rule.Days > 0 {
+			cfg.Rules[entity] = rule
+		}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRetentionConfig().BatchSize
+	}
+	return cfg
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchedulingConfig controls appointment slots and the opening hours availability is offered in.
+type SchedulingConfig struct {
+	SlotDuration        time.Duration // How long an appointment takes up its doctor and chair
+	DayStart            string        // Time of day, as HH:MM, the first slot starts
+	DayEnd              string        // Time of day, as HH:MM, the last slot must end by
+	TimeZone            string        // IANA time zone of the clinic, e.g. Africa/Nairobi; "Local" uses the server's
+	OverbookPerHour     int           // Short appointments a doctor may be booked over another per hour; 0 allows none
+	OverbookMaxDuration time.Duration // Longest appointment that may be booked over another
+	RequireRoster       bool          // Whether doctors with a staff account are only offered slots within their rostered shifts
+}
+
+// DefaultSchedulingConfig returns the scheduling settings used when nothing is configured.
+func DefaultSchedulingConfig() SchedulingConfig {
+	return SchedulingConfig{
+		SlotDuration:        30 * time.Minute,
+		DayStart:            "08:00",
+		DayEnd:              "17:00",
+		TimeZone:            "Local",
+		OverbookMaxDuration: 15 * time.Minute,
+	}
+}
+
+// LoadSchedulingConfig loads scheduling settings from environment variables with default fallbacks.
+func LoadSchedulingConfig() SchedulingConfig {
+	defaults := DefaultSchedulingConfig()
+	return SchedulingConfig{
+		SlotDuration:        GetEnvAsDuration("SCHEDULING_SLOT_DURATION", defaults.SlotDuration),
+		DayStart:            GetEnv("SCHEDULING_DAY_START", defaults.DayStart),
+		DayEnd:              GetEnv("SCHEDULING_DAY_END", defaults.DayEnd),
+		TimeZone:            GetEnv("CLINIC_TIME_ZONE", defaults.TimeZone),
+		OverbookPerHour:     GetEnvAsInt("SCHEDULING_OVERBOOK_PER_HOUR", defaults.OverbookPerHour),
+		OverbookMaxDuration: GetEnvAsDuration("SCHEDULING_OVERBOOK_MAX_DURATION", defaults.OverbookMaxDuration),
+		RequireRoster:       GetEnvAsBool("SCHEDULING_REQUIRE_ROSTER", defaults.RequireRoster),
+	}
+}
+
+// Location returns the clinic's time zone, which days, opening hours and appointment times without
+// an offset are read in.
+func (c SchedulingConfig) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown clinic time zone %q: %w", c.TimeZone, err)
+	}
+	return loc, nil
+}
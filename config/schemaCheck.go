@@ -0,0 +1,24 @@
+package config
+
+// SchemaCheckConfig controls the comparison of the live database schema with the one the build expects.
+type SchemaCheckConfig struct {
+	OnStartup   bool // Whether the schema is checked once the database is migrated on startup
+	FailOnDrift bool // Whether the server refuses to start when the startup check finds drift
+}
+
+// DefaultSchemaCheckConfig returns the schema check settings used when nothing is configured.
+func DefaultSchemaCheckConfig() SchemaCheckConfig {
+	return SchemaCheckConfig{
+		OnStartup:   true,
+		FailOnDrift: false,
+	}
+}
+
+// LoadSchemaCheckConfig loads schema check settings from environment variables with default fallbacks.
+func LoadSchemaCheckConfig() SchemaCheckConfig {
+	defaults := DefaultSchemaCheckConfig()
+	return SchemaCheckConfig{
+		OnStartup:   GetEnvAsBool("SCHEMA_CHECK_ON_STARTUP", defaults.OnStartup),
+		FailOnDrift: GetEnvAsBool("SCHEMA_DRIFT_FATAL", defaults.FailOnDrift),
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+// SecurityHeadersConfig holds the security headers added to every response.
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            time.Duration // 0 disables Strict-Transport-Security
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	ContentSecurityPolicy string // Applied to API responses
+	SwaggerPathPrefix     string // Routes serving the Swagger UI
+	SwaggerCSP            string // Looser policy allowing the Swagger UI's scripts and styles
+}
+
+// DefaultSecurityHeadersConfig returns the headers used when nothing is configured.
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		HSTSMaxAge:            180 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=()",
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		SwaggerPathPrefix:     "/swagger",
+		SwaggerCSP:            "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'",
+	}
+}
+
+// LoadSecurityHeadersConfig loads security headers from environment variables with default fallbacks.
+func LoadSecurityHeadersConfig() SecurityHeadersConfig {
+	defaults := DefaultSecurityHeadersConfig()
+	return SecurityHeadersConfig{
+		HSTSMaxAge:            GetEnvAsDuration("HSTS_MAX_AGE", defaults.HSTSMaxAge),
+		HSTSIncludeSubdomains: GetEnvAsBool("HSTS_INCLUDE_SUBDOMAINS", defaults.HSTSIncludeSubdomains),
+		HSTSPreload:           GetEnvAsBool("HSTS_PRELOAD", defaults.HSTSPreload),
+		ReferrerPolicy:        GetEnv("REFERRER_POLICY", defaults.ReferrerPolicy),
+		PermissionsPolicy:     GetEnv("PERMISSIONS_POLICY", defaults.PermissionsPolicy),
+		ContentSecurityPolicy: GetEnv("CONTENT_SECURITY_POLICY", defaults.ContentSecurityPolicy),
+		SwaggerPathPrefix:     GetEnv("SWAGGER_PATH_PREFIX", defaults.SwaggerPathPrefix),
+		SwaggerCSP:            GetEnv("SWAGGER_CONTENT_SECURITY_POLICY", defaults.SwaggerCSP),
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Formats audit entries are forwarded to a SIEM in
+const (
+	SIEMFormatCEF  = "cef"  // ArcSight Common Event Format
+	SIEMFormatJSON = "json" // The audit entry as a JSON object
+)
+
+// SIEMConfig selects the SIEM or syslog collector the audit trail, security events included, is
+// forwarded to as it is written, besides being kept in the database.
+type SIEMConfig struct {
+	// URL of the collector: tcp://host:port or tls://host:port for syslog over TCP, or an http(s)
+	// URL entries are posted to; nothing is forwarded when empty
+	URL       string
+	Format    string        // "cef" or "json"
+	Token     string        // Bearer token sent to HTTP collectors
+	Interval  time.Duration // How often new audit entries are forwarded
+	BatchSize int           // Entries forwarded at once
+	Lag       time.Duration // Age an entry must reach before it is forwarded, so entries still being written are not skipped
+	Timeout   time.Duration // How long sending a batch may take
+	// Backfill forwards the audit trail written before the collector was configured too, instead
+	// of starting from the entries written since
+	Backfill bool
+}
+
+// DefaultSIEMConfig returns the SIEM forwarding used when nothing is configured.
+func DefaultSIEMConfig() SIEMConfig {
+	return SIEMConfig{
+		Format:    SIEMFormatCEF,
+		Interval:  5 * time.Second,
+		BatchSize: 500,
+		Lag:       5 * time.Second,
+		Timeout:   10 * time.Second,
+	}
+}
+
+// LoadSIEMConfig loads SIEM forwarding from environment variables with default fallbacks.
+func LoadSIEMConfig() SIEMConfig {
+	defaults := DefaultSIEMConfig()
+	cfg := SIEMConfig{
+		URL:       GetEnv("SIEM_URL", defaults.URL),
+		Format:    strings.ToLower(GetEnv("SIEM_FORMAT", defaults.Format)),
+		Token:     GetEnv("SIEM_TOKEN", defaults.Token),
+		Interval:  GetEnvAsDuration("SIEM_INTERVAL", defaults.Interval),
+		BatchSize: GetEnvAsInt("SIEM_BATCH_SIZE", defaults.BatchSize),
+		Lag:       GetEnvAsDuration("SIEM_LAG", defaults.Lag),
+		Timeout:   GetEnvAsDuration("SIEM_TIMEOUT", defaults.Timeout),
+		Backfill:  GetEnvAsBool("SIEM_BACKFILL", defaults.Backfill),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	return cfg
+}
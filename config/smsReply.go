@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// SMSReplyConfig controls the endpoint the SMS gateway forwards patients' replies to, and how
+// replies to appointment reminders are understood.
+type SMSReplyConfig struct {
+	WebhookSecret string        // Secret the gateway signs forwarded replies with; they are refused while empty
+	ConfirmWords  []string      // Replies confirming the appointment reminded of, compared without case
+	CancelWords   []string      // Replies cancelling the appointment reminded of, compared without case
+	Window        time.Duration // How long after a reminder a reply is taken to answer it
+	MatchDigits   int           // Trailing digits of phone numbers compared, so local and international forms match
+}
+
+// DefaultSMSReplyConfig returns the SMS reply settings used when nothing is configured.
+func DefaultSMSReplyConfig() SMSReplyConfig {
+	return SMSReplyConfig{
+		ConfirmWords: []string{"YES", "Y", "CONFIRM"},
+		CancelWords:  []string{"NO", "N", "C", "CANCEL"},
+		Window:       72 * time.Hour,
+		MatchDigits:  9,
+	}
+}
+
+// LoadSMSReplyConfig loads SMS reply settings from environment variables with default fallbacks.
+func LoadSMSReplyConfig() SMSReplyConfig {
+	defaults := DefaultSMSReplyConfig()
+	return SMSReplyConfig{
+		WebhookSecret: GetEnv("SMS_REPLY_WEBHOOK_SECRET", defaults.WebhookSecret),
+		ConfirmWords:  GetEnvAsList("SMS_REPLY_CONFIRM_WORDS", defaults.ConfirmWords),
+		CancelWords:   GetEnvAsList("SMS_REPLY_CANCEL_WORDS", defaults.CancelWords),
+		Window:        GetEnvAsDuration("SMS_REPLY_WINDOW", defaults.Window),
+		MatchDigits:   GetEnvAsInt("SMS_REPLY_MATCH_DIGITS", defaults.MatchDigits),
+	}
+}
+
+// Enabled reports whether replies are accepted
+func (c SMSReplyConfig) Enabled() bool {
+	return c.WebhookSecret != ""
+}
+
+// Invitation is the line texted reminders end with, telling patients how to confirm or cancel by
+// reply, or nothing while replies are not accepted
+func (c SMSReplyConfig) Invitation() string {
+	if !c.Enabled() || len(c.ConfirmWords) == 0 || len(c.CancelWords) == 0 {
+		return ""
+	}
+	return "Reply " + c.ConfirmWords[0] + " to confirm or " + c.CancelWords[0] + " to cancel.\n"
+}
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// StaffDirectoryConfig points doctor syncs at the external staff directory, such as the HR system.
+type StaffDirectoryConfig struct {
+	URL      string        // Endpoint listing the clinic's doctors as JSON; doctors are imported from CSV files without it
+	Token    string        // Bearer token sent to the directory
+	Timeout  time.Duration // How long fetching the directory may take
+	Interval time.Duration // How often doctors are synced from the directory; 0 syncs only on request
+}
+
+// DefaultStaffDirectoryConfig returns the staff directory settings used when nothing is configured.
+func DefaultStaffDirectoryConfig() StaffDirectoryConfig {
+	return StaffDirectoryConfig{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// LoadStaffDirectoryConfig loads staff directory settings from environment variables with default fallbacks.
+func LoadStaffDirectoryConfig() StaffDirectoryConfig {
+	defaults := DefaultStaffDirectoryConfig()
+	return StaffDirectoryConfig{
+		URL:      GetEnv("STAFF_DIRECTORY_URL", ""),
+		Token:    GetEnv("STAFF_DIRECTORY_TOKEN", ""),
+		Timeout:  GetEnvAsDuration("STAFF_DIRECTORY_TIMEOUT", defaults.Timeout),
+		Interval: GetEnvAsDuration("STAFF_DIRECTORY_SYNC_INTERVAL", defaults.Interval),
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// StartupConfig controls how the server waits for Postgres and Redis at boot.
+type StartupConfig struct {
+	RetryAttempts   int           // Connection attempts per dependency before giving up; 0 retries forever
+	RetryBackoff    time.Duration // Delay before the first retry, doubled after each failure
+	RetryMaxBackoff time.Duration // Upper bound for the delay between retries
+	Lazy            bool          // Start serving immediately and connect in the background
+}
+
+// DefaultStartupConfig returns the startup behaviour used when nothing is configured.
+func DefaultStartupConfig() StartupConfig {
+	return StartupConfig{
+		RetryAttempts:   10,
+		RetryBackoff:    time.Second,
+		RetryMaxBackoff: 30 * time.Second,
+		Lazy:            false,
+	}
+}
+
+// LoadStartupConfig loads startup behaviour from environment variables with default fallbacks.
+func LoadStartupConfig() StartupConfig {
+	defaults := DefaultStartupConfig()
+	return StartupConfig{
+		RetryAttempts:   GetEnvAsInt("STARTUP_RETRY_ATTEMPTS", defaults.RetryAttempts),
+		RetryBackoff:    GetEnvAsDuration("STARTUP_RETRY_BACKOFF", defaults.RetryBackoff),
+		RetryMaxBackoff: GetEnvAsDuration("STARTUP_RETRY_MAX_BACKOFF", defaults.RetryMaxBackoff),
+		Lazy:            GetEnvAsBool("STARTUP_LAZY", defaults.Lazy),
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// StorageBuckets lists the kinds of files kept in object storage, each in a bucket of its own.
+var StorageBuckets = []string{"documents", "photos", "exports", "backups"}
+
+// Storage backends
+const (
+	StorageDatabase = "database" // Files stay in the database, as they always did
+	StorageLocal    = "local"    // Files are written under a directory on disk
+	StorageS3       = "s3"       // Files go to S3 or an S3-compatible store such as MinIO
+	StorageGCS      = "gcs"      // Files go to Google Cloud Storage through its S3-compatible API, with HMAC keys
+)
+
+// StorageBucket is where one kind of file is stored. Region is the region the bucket lives in,
+// which decides the country the files are kept in.
+type StorageBucket struct {
+	Name   string
+	Region string
+}
+
+// StorageConfig controls where files such as attachments, exports and archives are kept, so a
+// deployment can keep them in the region its data-residency rules require.
+type StorageConfig struct {
+	Backend    string                   // StorageDatabase, StorageLocal, StorageS3 or StorageGCS
+	LocalDir   string                   // Directory files are written under by the local backend, a directory per bucket
+	Endpoint   string                   // Endpoint of an S3-compatible store; empty uses AWS's, or Google's for gcs
+	Region     string                   // Region of buckets that do not set their own
+	AccessKey  string                   // Access key, or HMAC key for gcs
+	SecretKey  string                   // Secret of the access key
+	Encryption string                   // Server-side encryption: empty for the store's default, AES256, or aws:kms with KMSKey
+	KMSKey     string                   // KMS key files are encrypted with; a Cloud KMS key name for gcs
+	Buckets    map[string]StorageBucket // Bucket of each kind in StorageBuckets
+	Timeout    time.Duration            // Bounds each request to the store
+}
+
+// DefaultStorageConfig returns the storage settings used when nothing is configured: files stay in
+// the database.
+func DefaultStorageConfig() StorageConfig {
+	return StorageConfig{
+		Backend:  StorageDatabase,
+		LocalDir: "storage",
+		Region:   "us-east-1",
+		Buckets:  map[string]StorageBucket{},
+		Timeout:  30 * time.Second,
+	}
+}
+
+// LoadStorageConfig loads storage settings from environment variables with default fallbacks. Each
+// kind of file reads STORAGE_<KIND>_BUCKET and STORAGE_<KIND>_REGION, e.g.
+// STORAGE_PHOTOS_BUCKET=clinic-photos with STORAGE_PHOTOS_REGION=af-south-1; a bucket without a name
+// is named after its kind.
+func LoadStorageConfig() StorageConfig {
+	cfg := DefaultStorageConfig()
+	cfg.Backend = strings.ToLower(GetEnv("STORAGE_BACKEND", cfg.Backend))
+	cfg.LocalDir = GetEnv("STORAGE_LOCAL_DIR", cfg.LocalDir)
+	cfg.Endpoint = GetEnv("STORAGE_ENDPOINT", cfg.Endpoint)
+	cfg.Region = GetEnv("STORAGE_REGION", cfg.Region)
+	cfg.AccessKey = GetEnv("STORAGE_ACCESS_KEY", cfg.AccessKey)
+	cfg.SecretKey = GetEnv("STORAGE_SECRET_KEY", cfg.SecretKey)
+	cfg.Encryption = GetEnv("STORAGE_ENCRYPTION", cfg.Encryption)
+	cfg.KMSKey = GetEnv("STORAGE_KMS_KEY", cfg.KMSKey)
+	cfg.Timeout = GetEnvAsDuration("STORAGE_TIMEOUT", cfg.Timeout)
+	for _, kind := range StorageBuckets {
+		prefix := "STORAGE_" + strings.ToUpper(kind)
+		cfg.Buckets[kind] = StorageBucket{
+			Name:   GetEnv(prefix+"_BUCKET", kind),
+			Region: GetEnv(prefix+"_REGION", cfg.Region),
+		}
+	}
+	return cfg
+}
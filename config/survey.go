@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// SurveyConfig controls the satisfaction surveys sent after fulfilled appointments.
+type SurveyConfig struct {
+	SigningKey       string        // Secret signing survey links; surveys are disabled without it or BaseURL
+	BaseURL          string        // Public page the signed link points to, e.g. https://example.com/survey
+	DispatchInterval time.Duration // How often fulfilled appointments are checked for surveys to send
+	Lookback         time.Duration // How far back fulfilled appointments still get a survey
+}
+
+// DefaultSurveyConfig returns the survey settings used when nothing is configured.
+func DefaultSurveyConfig() SurveyConfig {
+	return SurveyConfig{
+		DispatchInterval: 15 * time.Minute,
+		Lookback:         72 * time.Hour,
+	}
+}
+
+// LoadSurveyConfig loads survey settings from environment variables with default fallbacks.
+func LoadSurveyConfig() SurveyConfig {
+	defaults := DefaultSurveyConfig()
+	return SurveyConfig{
+		SigningKey:       GetEnv("SURVEY_SIGNING_KEY", ""),
+		BaseURL:          GetEnv("SURVEY_BASE_URL", ""),
+		DispatchInterval: GetEnvAsDuration("SURVEY_DISPATCH_INTERVAL", defaults.DispatchInterval),
+		Lookback:         GetEnvAsDuration("SURVEY_LOOKBACK", defaults.Lookback),
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// TimeoutConfig holds the per-operation deadlines applied by the data layer.
+type TimeoutConfig struct {
+	DBRead         time.Duration // Deadline for read queries, including cache lookups
+	DBWrite        time.Duration // Deadline for writes and transactions, including lock waits
+	LockWait       time.Duration // Maximum time spent retrying a busy lock
+	LockRetryDelay time.Duration // Pause between lock acquisition attempts
+	LockTTL        time.Duration // Expiry of an acquired lock, in case the holder crashes
+}
+
+// DefaultTimeoutConfig returns the timeouts used when nothing is configured.
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		DBRead:         5 * time.Second,
+		DBWrite:        15 * time.Second,
+		LockWait:       6 * time.Second,
+		LockRetryDelay: 2 * time.Second,
+		LockTTL:        10 * time.Second,
+	}
+}
+
+// LoadTimeoutConfig loads timeouts from environment variables with default fallbacks.
+func LoadTimeoutConfig() TimeoutConfig {
+	defaults := DefaultTimeoutConfig()
+	return TimeoutConfig{
+		DBRead:         GetEnvAsDuration("DB_READ_TIMEOUT", defaults.DBRead),
+		DBWrite:        GetEnvAsDuration("DB_WRITE_TIMEOUT", defaults.DBWrite),
+		LockWait:       GetEnvAsDuration("LOCK_WAIT_TIMEOUT", defaults.LockWait),
+		LockRetryDelay: GetEnvAsDuration("LOCK_RETRY_DELAY", defaults.LockRetryDelay),
+		LockTTL:        GetEnvAsDuration("LOCK_TTL", defaults.LockTTL),
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "time"
+
+// TranscriptionConfig selects the provider transcribing the audio notes dictated on examinations.
+type TranscriptionConfig struct {
+	Provider     string        // "webhook"; notes wait for staff to transcribe them when empty
+	URL          string        // Endpoint of the provider
+	Token        string        // Bearer token sent to a webhook provider
+	Language     string        // Language the notes are dictated in
+	Timeout      time.Duration // How long transcribing a single note may take
+	PollInterval time.Duration // How often notes waiting for the provider are picked up
+	MaxAttempts  int           // Tries before a note is left to be transcribed by hand
+}
+
+// DefaultTranscriptionConfig returns the transcription settings used when nothing is configured.
+func DefaultTranscriptionConfig() TranscriptionConfig {
+	return TranscriptionConfig{
+		Language:     "en",
+		Timeout:      2 * time.Minute,
+		PollInterval: time.Minute,
+		MaxAttempts:  3,
+	}
+}
+
+// LoadTranscriptionConfig loads transcription settings from environment variables with default fallbacks.
+func LoadTranscriptionConfig() TranscriptionConfig {
+	defaults := DefaultTranscriptionConfig()
+	return TranscriptionConfig{
+		Provider:     GetEnv("TRANSCRIPTION_PROVIDER", ""),
+		URL:          GetEnv("TRANSCRIPTION_URL", ""),
+		Token:        GetEnv("TRANSCRIPTION_TOKEN", ""),
+		Language:     GetEnv("TRANSCRIPTION_LANGUAGE", defaults.Language),
+		Timeout:      GetEnvAsDuration("TRANSCRIPTION_TIMEOUT", defaults.Timeout),
+		PollInterval: GetEnvAsDuration("TRANSCRIPTION_POLL_INTERVAL", defaults.PollInterval),
+		MaxAttempts:  GetEnvAsInt("TRANSCRIPTION_MAX_ATTEMPTS", defaults.MaxAttempts),
+	}
+}
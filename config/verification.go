@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// VerificationConfig points patient records at the external services that confirm their identity
+// and insurance cover.
+type VerificationConfig struct {
+	NationalIDURL    string        // Webhook validating national ID numbers; the check is skipped without it
+	InsurerMemberURL string        // Webhook looking up insurer membership; the check is skipped without it
+	Token            string        // Bearer token sent to both webhooks
+	Timeout          time.Duration // How long a single check may take
+}
+
+// DefaultVerificationConfig returns the verification settings used when nothing is configured.
+func DefaultVerificationConfig() VerificationConfig {
+	return VerificationConfig{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// LoadVerificationConfig loads verification settings from environment variables with default fallbacks.
+func LoadVerificationConfig() VerificationConfig {
+	defaults := DefaultVerificationConfig()
+	return VerificationConfig{
+		NationalIDURL:    GetEnv("VERIFICATION_NATIONAL_ID_URL", ""),
+		InsurerMemberURL: GetEnv("VERIFICATION_INSURER_MEMBER_URL", ""),
+		Token:            GetEnv("VERIFICATION_TOKEN", ""),
+		Timeout:          GetEnvAsDuration("VERIFICATION_TIMEOUT", defaults.Timeout),
+	}
+}
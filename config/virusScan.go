@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// VirusScanConfig selects the scanner uploaded files are checked with before they are kept.
+type VirusScanConfig struct {
+	Provider string        // "clamav" or "webhook"; uploads are not scanned when empty
+	Address  string        // clamd's socket, as tcp://host:3310 or unix:///path/to/clamd.sock
+	URL      string        // Endpoint of a webhook scanner, such as a cloud scanning API
+	Token    string        // Bearer token sent to a webhook scanner
+	Timeout  time.Duration // How long scanning a single file may take
+	// FailOpen keeps uploads the scanner could not check instead of refusing them
+	FailOpen bool
+}
+
+// DefaultVirusScanConfig returns the virus scanning settings used when nothing is configured.
+func DefaultVirusScanConfig() VirusScanConfig {
+	return VirusScanConfig{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// LoadVirusScanConfig loads virus scanning settings from environment variables with default fallbacks.
+func LoadVirusScanConfig() VirusScanConfig {
+	defaults := DefaultVirusScanConfig()
+	return VirusScanConfig{
+		Provider: GetEnv("VIRUS_SCAN_PROVIDER", ""),
+		Address:  GetEnv("VIRUS_SCAN_ADDRESS", ""),
+		URL:      GetEnv("VIRUS_SCAN_URL", ""),
+		Token:    GetEnv("VIRUS_SCAN_TOKEN", ""),
+		Timeout:  GetEnvAsDuration("VIRUS_SCAN_TIMEOUT", defaults.Timeout),
+		FailOpen: GetEnvAsBool("VIRUS_SCAN_FAIL_OPEN", defaults.FailOpen),
+	}
+}
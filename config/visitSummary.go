@@ -0,0 +1,21 @@
+package config
+
+// VisitSummaryConfig controls the visit summary letters given to patients.
+type VisitSummaryConfig struct {
+	ClinicName      string // Name the letters are headed with
+	EmailOnCheckout bool   // Whether patients are emailed the summary once their appointment is fulfilled
+}
+
+// DefaultVisitSummaryConfig returns the visit summary settings used when nothing is configured.
+func DefaultVisitSummaryConfig() VisitSummaryConfig {
+	return VisitSummaryConfig{ClinicName: "RoyDental", EmailOnCheckout: false}
+}
+
+// LoadVisitSummaryConfig loads visit summary settings from environment variables with default fallbacks.
+func LoadVisitSummaryConfig() VisitSummaryConfig {
+	defaults := DefaultVisitSummaryConfig()
+	return VisitSummaryConfig{
+		ClinicName:      GetEnv("VISIT_SUMMARY_CLINIC_NAME", defaults.ClinicName),
+		EmailOnCheckout: GetEnvAsBool("VISIT_SUMMARY_EMAIL_ON_CHECKOUT", defaults.EmailOnCheckout),
+	}
+}
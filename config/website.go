@@ -0,0 +1,26 @@
+package config
+
+// WebsiteConfig controls the public API the clinic website reads doctors and services from and sends
+// appointment requests to.
+type WebsiteConfig struct {
+	APIKeys      []string // Keys the website authenticates with; the website API is disabled when empty
+	ShowPrices   bool     // Whether services are listed with what cash patients are charged
+	BookingAhead int      // How many days ahead a preferred appointment date may be requested
+}
+
+// DefaultWebsiteConfig returns the website settings used when nothing is configured.
+func DefaultWebsiteConfig() WebsiteConfig {
+	return WebsiteConfig{
+		BookingAhead: 180,
+	}
+}
+
+// LoadWebsiteConfig loads website settings from environment variables with default fallbacks.
+func LoadWebsiteConfig() WebsiteConfig {
+	defaults := DefaultWebsiteConfig()
+	return WebsiteConfig{
+		APIKeys:      GetEnvAsList("WEBSITE_API_KEYS", nil),
+		ShowPrices:   GetEnvAsBool("WEBSITE_SHOW_PRICES", defaults.ShowPrices),
+		BookingAhead: GetEnvAsInt("WEBSITE_BOOKING_AHEAD_DAYS", defaults.BookingAhead),
+	}
+}
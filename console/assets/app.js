@@ -0,0 +1,305 @@
+"use strict";
+
+// The admin console calls the API's admin endpoints with the bearer token and the access token of
+// the Admin who signed in. Both are kept in memory only, so closing or reloading the tab signs out.
+(function () {
+  const session = { bearer: "", accessToken: "", csrfToken: "" };
+  const auditPageSize = 100;
+  let auditQuery = null;
+  let auditOffset = 0;
+
+  const $ = (id) => document.getElementById(id);
+
+  function showMessage(text, isError) {
+    const message = $("message");
+    message.textContent = text;
+    message.className = isError ? "error" : "";
+    message.hidden = !text;
+  }
+
+  async function api(method, path, body) {
+    const url = new URL(path, window.location.origin);
+    if (session.accessToken) {
+      url.searchParams.set("accessToken", session.accessToken);
+    }
+    const headers = { Authorization: "Bearer " + session.bearer };
+    if (session.csrfToken) {
+      headers["X-CSRF-Token"] = session.csrfToken;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    const response = await fetch(url, {
+      method: method,
+      headers: headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+      credentials: "same-origin",
+    });
+    if (response.status === 401 && session.accessToken) {
+      signOut();
+      throw new Error("Your session expired, sign in again");
+    }
+    const text = await response.text();
+    const data = text ? JSON.parse(text) : null;
+    if (!response.ok) {
+      throw new Error((data && data.error) || response.status + " " + response.statusText);
+    }
+    return data;
+  }
+
+  // run reports the outcome of an action in the message bar; done may be a function writing the
+  // message from what the action found
+  async function run(action, done) {
+    try {
+      await action();
+      showMessage((typeof done === "function" ? done() : done) || "", false);
+    } catch (err) {
+      showMessage(err.message, true);
+    }
+  }
+
+  function cell(row, text) {
+    const td = document.createElement("td");
+    td.textContent = text === undefined || text === null ? "" : String(text);
+    row.appendChild(td);
+    return td;
+  }
+
+  function formatTime(value) {
+    return value ? new Date(value).toLocaleString() : "";
+  }
+
+  // Sign in
+
+  async function signIn(event) {
+    event.preventDefault();
+    const form = event.target;
+    session.bearer = form.bearer.value;
+    await run(async () => {
+      const tokens = await api("POST", "/auth/login", { email: form.email.value, password: form.password.value });
+      session.accessToken = tokens.accessToken;
+      session.csrfToken = tokens.csrfToken;
+      try {
+        await loadSettings();
+      } catch (err) {
+        signOut();
+        throw err;
+      }
+      form.reset();
+      $("sign-in").hidden = true;
+      $("console").hidden = false;
+      $("sign-out").hidden = false;
+    });
+  }
+
+  function signOut() {
+    session.bearer = session.accessToken = session.csrfToken = "";
+    $("console").hidden = true;
+    $("sign-out").hidden = true;
+    $("sign-in").hidden = false;
+  }
+
+  // Settings and switches
+
+  async function loadSettings() {
+    const settings = await api("GET", "/auth/admin/settings");
+    const switches = $("switches-form");
+    switches.debug_logging.checked = settings.debug_logging;
+    switches.birthday_greetings.checked = settings.birthday_greetings;
+
+    const format = $("document-format-form");
+    for (const name of ["currency", "thousands_separator", "decimal_separator", "date_style"]) {
+      format[name].value = settings.document_format[name];
+    }
+    $("settings-json").textContent = JSON.stringify(settings, null, 2);
+
+    const readOnly = await api("GET", "/read_only");
+    const toggle = $("read-only-form");
+    toggle.enabled.checked = readOnly.enabled;
+    toggle.message.value = readOnly.message || "";
+    $("read-only-status").textContent = readOnly.enabled
+      ? "Changes are refused" + (readOnly.reason ? " (" + readOnly.reason + ")" : "") + (readOnly.since ? " since " + formatTime(readOnly.since) : "")
+      : "Changes are accepted";
+  }
+
+  function saveSwitches(event) {
+    event.preventDefault();
+    const form = event.target;
+    run(async () => {
+      await api("PUT", "/auth/admin/settings", {
+        debug_logging: form.debug_logging.checked,
+        birthday_greetings: form.birthday_greetings.checked,
+      });
+      await loadSettings();
+    }, "Switches saved");
+  }
+
+  function saveReadOnly(event) {
+    event.preventDefault();
+    const form = event.target;
+    run(async () => {
+      await api("PUT", "/read_only", { enabled: form.enabled.checked, message: form.message.value });
+      await loadSettings();
+    }, "Read-only mode saved");
+  }
+
+  function saveDocumentFormat(event) {
+    event.preventDefault();
+    const form = event.target;
+    run(async () => {
+      await api("PUT", "/auth/admin/settings", {
+        document_format: {
+          currency: form.currency.value,
+          thousands_separator: form.thousands_separator.value,
+          decimal_separator: form.decimal_separator.value,
+          date_style: form.date_style.value,
+        },
+      });
+      await loadSettings();
+    }, "Document format saved");
+  }
+
+  // Cache
+
+  async function loadCache() {
+    const status = await api("GET", "/auth/admin/cache");
+    const list = $("cache-status");
+    list.replaceChildren();
+    const rows = [
+      ["Redis circuit breaker", status.breaker],
+      ["Namespace", status.namespace],
+      ["Generation", status.generation],
+      ["Invalidations waiting for Redis", status.pending_invalidations + (status.overflowed ? ", too many to track" : "")],
+    ];
+    for (const [term, value] of rows) {
+      const dt = document.createElement("dt");
+      dt.textContent = term;
+      const dd = document.createElement("dd");
+      dd.textContent = String(value);
+      list.append(dt, dd);
+    }
+  }
+
+  function flushCache() {
+    if (!window.confirm("Drop every cached entry? The API reads from the database until the cache fills again.")) {
+      return;
+    }
+    run(async () => {
+      await api("POST", "/auth/admin/cache/flush");
+      await loadCache();
+    }, "Cache flushed");
+  }
+
+  function pruneCache() {
+    let deleted = "";
+    run(async () => {
+      const result = await api("POST", "/auth/admin/cache/prune");
+      deleted = Object.entries(result.pruned)
+        .map(([kind, keys]) => keys + " " + kind.replace(/_/g, " "))
+        .join(", ");
+      if (result.error) {
+        throw new Error("Cleaning stopped early after deleting " + deleted + ": " + result.error);
+      }
+    }, () => "Deleted " + deleted);
+  }
+
+  function invalidateEntity(event) {
+    event.preventDefault();
+    const entity = event.target.entity.value;
+    run(async () => {
+      await api("DELETE", "/auth/admin/cache/" + encodeURIComponent(entity));
+      await loadCache();
+    }, "Cached " + entity + " entries dropped");
+  }
+
+  // Jobs
+
+  async function loadJobs() {
+    const queues = await api("GET", "/auth/admin/jobs");
+    const body = $("jobs");
+    body.replaceChildren();
+    for (const queue of queues) {
+      const row = document.createElement("tr");
+      cell(row, queue.name.replace(/_/g, " "));
+      const statuses = Object.entries(queue.statuses).map(([status, jobs]) => status + ": " + jobs);
+      cell(row, statuses.length ? statuses.join(", ") : "empty");
+      cell(row, formatTime(queue.oldest_waiting));
+      body.appendChild(row);
+    }
+  }
+
+  // Audit trail
+
+  function searchAudit(event) {
+    event.preventDefault();
+    const form = event.target;
+    const params = new URLSearchParams({ limit: String(auditPageSize) });
+    for (const name of ["event", "user_id", "ip"]) {
+      if (form[name].value) {
+        params.set(name, form[name].value);
+      }
+    }
+    for (const name of ["from", "to"]) {
+      if (form[name].value) {
+        params.set(name, new Date(form[name].value).toISOString());
+      }
+    }
+    auditQuery = "/auth/admin/audit/" + form.trail.value + "?" + params.toString();
+    auditOffset = 0;
+    $("audit-entries").replaceChildren();
+    run(loadAuditPage);
+  }
+
+  async function loadAuditPage() {
+    const page = await api("GET", auditQuery + "&offset=" + auditOffset);
+    const body = $("audit-entries");
+    for (const entry of page.entries) {
+      const row = document.createElement("tr");
+      cell(row, formatTime(entry.created_at));
+      cell(row, entry.event);
+      cell(row, entry.user_id ? entry.user_id + (entry.role ? " (" + entry.role + ")" : "") : "");
+      cell(row, entry.ip);
+      cell(row, entry.method + " " + entry.route);
+      cell(row, entry.status);
+      cell(row, entry.detail);
+      body.appendChild(row);
+    }
+    auditOffset += page.entries.length;
+    $("audit-total").textContent = auditOffset + " of " + page.total + " entries";
+    $("audit-more").hidden = auditOffset >= page.total;
+  }
+
+  // Tabs
+
+  const tabLoaders = { settings: loadSettings, cache: loadCache, jobs: loadJobs };
+
+  function showTab(name) {
+    for (const button of document.querySelectorAll("nav button")) {
+      button.classList.toggle("active", button.dataset.tab === name);
+    }
+    for (const tab of document.querySelectorAll(".tab")) {
+      tab.hidden = tab.id !== "tab-" + name;
+    }
+    if (tabLoaders[name]) {
+      run(tabLoaders[name]);
+    }
+  }
+
+  document.addEventListener("DOMContentLoaded", () => {
+    $("sign-in-form").addEventListener("submit", signIn);
+    $("sign-out").addEventListener("click", signOut);
+    $("switches-form").addEventListener("submit", saveSwitches);
+    $("read-only-form").addEventListener("submit", saveReadOnly);
+    $("document-format-form").addEventListener("submit", saveDocumentFormat);
+    $("cache-refresh").addEventListener("click", () => run(loadCache));
+    $("cache-flush").addEventListener("click", flushCache);
+    $("cache-prune").addEventListener("click", pruneCache);
+    $("cache-entity-form").addEventListener("submit", invalidateEntity);
+    $("jobs-refresh").addEventListener("click", () => run(loadJobs));
+    $("audit-form").addEventListener("submit", searchAudit);
+    $("audit-more").addEventListener("click", () => run(loadAuditPage));
+    for (const button of document.querySelectorAll("nav button")) {
+      button.addEventListener("click", () => showTab(button.dataset.tab));
+    }
+  });
+})();
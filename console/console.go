@@ -0,0 +1,32 @@
+// Package console holds the admin console, a web page served by the API that admins of small
+// deployments manage the server from: runtime settings and switches, the cache, background jobs
+// and the audit trail. The page only calls the API's admin endpoints, with the bearer token and an
+// Admin's sign-in entered in it, so serving it exposes no data.
+package console
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Assets returns the console's page, scripts and styles. They are embedded at build time, so
+// failing to read them is a programming error.
+func Assets() fs.FS {
+	files, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// Page returns the console's HTML page
+func Page() []byte {
+	page, err := fs.ReadFile(assets, "assets/index.html")
+	if err != nil {
+		panic(err)
+	}
+	return page
+}
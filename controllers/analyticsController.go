@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAnalyticsRoutes registers the anonymized analytics reports
+func SetupAnalyticsRoutes(router *gin.Engine, analyticsHandler *handlers.AnalyticsHandler) {
+	router.GET("/reports/analytics", analyticsHandler.GetAnalytics)
+	router.POST("/reports/analytics/rebuild",
+		analyticsHandler.RebuildAnalytics,
+	)
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAPIUsageRoutes registers the Admin-only API usage analytics by user and integration
+func SetupAPIUsageRoutes(router *gin.Engine, apiUsageHandler *handlers.APIUsageHandler) {
+	adminGroup := router.Group("/admin")
+	{
+		adminGroup.GET("/usage", apiUsageHandler.GetAPIUsage)
+	}
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAppointmentReminderRoutes registers the preview of an appointment's reminders and the front
+// desk's override of their cadence
+func SetupAppointmentReminderRoutes(router *gin.Engine, appointmentReminderHandler *handlers.AppointmentReminderHandler) {
+	staffGroup := router.Group("/patients/:patient_id/appointments/:appointment_id/reminders")
+	{
+		staffGroup.GET("", appointmentReminderHandler.GetAppointmentReminders)
+	}
+
+	deskGroup := router.Group("/patients/:patient_id/appointments/:appointment_id/reminders")
+	{
+		deskGroup.PUT("", appointmentReminderHandler.SetAppointmentReminders)
+	}
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupApprovalRoutes registers the actions waiting for approval: staff follow and withdraw the
+// ones they asked for, and admins decide them
+func SetupApprovalRoutes(router *gin.Engine, approvalHandler *handlers.ApprovalHandler) {
+	router.GET("/me/approvals", approvalHandler.GetMyApprovals)
+	router.POST("/me/approvals/:id/cancel", approvalHandler.CancelApproval)
+
+	router.GET("/approvals", approvalHandler.GetApprovals)
+	router.GET("/approvals/:id", approvalHandler.GetApproval)
+	router.POST("/approvals/:id/approve", approvalHandler.ApproveApproval)
+	router.POST("/approvals/:id/reject", approvalHandler.RejectApproval)
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAttachmentRoutes registers the documents and x-rays filed with examinations
+func SetupAttachmentRoutes(router *gin.Engine, attachmentHandler *handlers.AttachmentHandler) {
+	router.POST("/patients/:patient_id/examinations/:examination_id/attachments", attachmentHandler.UploadAttachment)
+	router.GET("/patients/:patient_id/examinations/:examination_id/attachments/:id", attachmentHandler.DownloadAttachment)
+	router.PUT("/patients/:patient_id/examinations/:examination_id/attachments/:id/annotations", attachmentHandler.UpdateAnnotations)
+	router.DELETE("/patients/:patient_id/examinations/:examination_id/attachments/:id", attachmentHandler.DeleteAttachment)
+}
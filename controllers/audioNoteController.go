@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAudioNoteRoutes registers the audio notes dictated on examinations and the staff's transcription queue
+func SetupAudioNoteRoutes(router *gin.Engine, audioNoteHandler *handlers.AudioNoteHandler) {
+	router.POST("/patients/:patient_id/examinations/:examination_id/audio_notes", audioNoteHandler.UploadAudioNote)
+	router.GET("/patients/:patient_id/examinations/:examination_id/audio_notes", audioNoteHandler.GetAudioNotes)
+	router.GET("/patients/:patient_id/examinations/:examination_id/audio_notes/:id", audioNoteHandler.DownloadAudioNote)
+	router.PUT("/patients/:patient_id/examinations/:examination_id/audio_notes/:id/transcript", audioNoteHandler.SetTranscript)
+	router.POST("/patients/:patient_id/examinations/:examination_id/audio_notes/:id/transcribe", audioNoteHandler.Retranscribe)
+	router.DELETE("/patients/:patient_id/examinations/:examination_id/audio_notes/:id", audioNoteHandler.DeleteAudioNote)
+
+	queueGroup := router.Group("/audio_notes")
+	{
+		queueGroup.GET("", audioNoteHandler.GetTranscriptionQueue)
+	}
+}
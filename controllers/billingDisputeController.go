@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBillingDisputeRoutes registers disputes about bills, which the front desk records and works
+// through with the patient or insurer
+func SetupBillingDisputeRoutes(router *gin.Engine, billingDisputeHandler *handlers.BillingDisputeHandler) {
+	router.POST("/billings/:id/disputes", billingDisputeHandler.CreateBillingDispute)
+	router.GET("/billings/:id/disputes", billingDisputeHandler.GetBillingDisputes)
+	router.GET("/billing_disputes/:id", billingDisputeHandler.GetBillingDispute)
+	router.PUT("/billing_disputes/:id", billingDisputeHandler.UpdateBillingDispute)
+	router.GET("/reports/disputes", billingDisputeHandler.GetOpenDisputeReport)
+}
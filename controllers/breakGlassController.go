@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBreakGlassRoutes registers emergency access: doctors open it to a patient's record they are
+// otherwise kept out of, and admins review it
+func SetupBreakGlassRoutes(router *gin.Engine, breakGlassHandler *handlers.BreakGlassHandler) {
+	doctorGroup := router.Group("/me/doctor")
+	{
+		doctorGroup.POST("/patients/:id/break_glass", breakGlassHandler.OpenBreakGlass)
+	}
+
+	router.GET("/break_glass_accesses", breakGlassHandler.GetBreakGlassAccesses)
+	router.POST("/break_glass_accesses/:id/review", breakGlassHandler.ReviewBreakGlassAccess)
+}
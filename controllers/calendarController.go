@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCalendarFeedRoutes registers the doctors' calendar feeds, authenticated by the signed token only
+// because calendar apps cannot send API credentials
+func SetupCalendarFeedRoutes(router *gin.Engine, calendarHandler *handlers.CalendarHandler) {
+	router.GET("/doctors/:id/calendar.ics",
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+		calendarHandler.GetCalendarFeed,
+	)
+}
+
+// SetupCalendarLinkRoutes registers the staff endpoint issuing calendar subscription links
+func SetupCalendarLinkRoutes(router *gin.Engine, calendarHandler *handlers.CalendarHandler) {
+	router.GET("/doctors/:id/calendar-link", calendarHandler.GetCalendarLink)
+}
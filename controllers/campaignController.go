@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCampaignRoutes registers bulk communication campaigns: their segments and messages, the
+// preview of their audience, scheduling and cancelling them, and their delivery reports
+func SetupCampaignRoutes(router *gin.Engine, campaignHandler *handlers.CampaignHandler) {
+	router.POST("/campaigns", campaignHandler.CreateCampaign)
+	router.GET("/campaigns", campaignHandler.GetCampaigns)
+	router.GET("/campaigns/:id", campaignHandler.GetCampaign)
+	router.PUT("/campaigns/:id", campaignHandler.UpdateCampaign)
+	router.DELETE("/campaigns/:id", campaignHandler.DeleteCampaign)
+	router.GET("/campaigns/:id/preview", campaignHandler.PreviewCampaign)
+	router.POST("/campaigns/:id/schedule", campaignHandler.ScheduleCampaign)
+	router.POST("/campaigns/:id/cancel", campaignHandler.CancelCampaign)
+	router.GET("/campaigns/:id/recipients", campaignHandler.GetCampaignRecipients)
+	router.GET("/campaigns/:id/report", campaignHandler.GetCampaignReport)
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCareRuleRoutes registers the care pathway rules, which only admins set up, and the recall
+// list the rules fill, which the desk works through
+func SetupCareRuleRoutes(router *gin.Engine, careRuleHandler *handlers.CareRuleHandler) {
+	router.GET("/recalls", careRuleHandler.GetRecalls)
+	router.PUT("/recalls/:id", careRuleHandler.UpdateRecall)
+
+	router.POST("/care_rules", careRuleHandler.CreateCareRule)
+	router.GET("/care_rules", careRuleHandler.GetCareRules)
+	router.GET("/care_rules/:id", careRuleHandler.GetCareRule)
+	router.PUT("/care_rules/:id", careRuleHandler.UpdateCareRule)
+	router.DELETE("/care_rules/:id", careRuleHandler.DeleteCareRule)
+}
@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCaseExportRoutes registers the anonymized treatment cases exported for teaching. Exports
+// wait for an admin's approval through the approval routes.
+func SetupCaseExportRoutes(router *gin.Engine, caseExportHandler *handlers.CaseExportHandler) {
+	router.POST("/patients/:patient_id/treatment_plans/:treatment_plan_id/case_export", caseExportHandler.RequestCaseExport)
+	router.GET("/case_exports/:id", caseExportHandler.DownloadCaseExport)
+}
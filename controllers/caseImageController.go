@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCaseImageRoutes registers the before and after image pairs of treatment plans and the
+// gallery of the pairs patients consented to
+func SetupCaseImageRoutes(router *gin.Engine, caseImageHandler *handlers.CaseImageHandler) {
+	router.POST("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs", caseImageHandler.CreateImagePair)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs", caseImageHandler.GetImagePairs)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs/:id", caseImageHandler.GetImagePair)
+	router.PUT("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs/:id", caseImageHandler.UpdateImagePair)
+	router.PUT("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs/:id/consent", caseImageHandler.UpdateImagePairConsent)
+	router.DELETE("/patients/:patient_id/treatment_plans/:treatment_plan_id/image_pairs/:id", caseImageHandler.DeleteImagePair)
+	router.GET("/gallery", caseImageHandler.GetGallery)
+	router.GET("/gallery/:id/:side", caseImageHandler.GetGalleryImage)
+}
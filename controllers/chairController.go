@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupChairRoutes registers treatment rooms, their chairs and the downtime of chairs and equipment,
+// which staff look up when booking and only admins set up
+func SetupChairRoutes(router *gin.Engine, chairHandler *handlers.ChairHandler) {
+	router.GET("/rooms", chairHandler.GetRooms)
+	router.GET("/rooms/:id", chairHandler.GetRoom)
+	router.GET("/chairs", chairHandler.GetChairs)
+	router.GET("/downtime", chairHandler.GetDowntimes)
+	router.GET("/downtime/:id", chairHandler.GetDowntime)
+
+	router.POST("/rooms", chairHandler.CreateRoom)
+	router.PUT("/rooms/:id", chairHandler.UpdateRoom)
+	router.DELETE("/rooms/:id", chairHandler.DeleteRoom)
+	router.POST("/chairs", chairHandler.CreateChair)
+	router.PUT("/chairs/:id", chairHandler.UpdateChair)
+	router.DELETE("/chairs/:id", chairHandler.DeleteChair)
+	router.POST("/downtime", chairHandler.CreateDowntime)
+	router.PUT("/downtime/:id", chairHandler.UpdateDowntime)
+	router.DELETE("/downtime/:id", chairHandler.DeleteDowntime)
+	router.GET("/reports/downtime", chairHandler.GetDowntimeReport)
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/database"
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupChangeRoutes registers GET /<entity>/changes for every entity sync clients can mirror
+func SetupChangeRoutes(router *gin.Engine, changeHandler *handlers.ChangeHandler) {
+	for entity := range database.ChangeSources {
+		router.GET("/"+entity+"/changes", changeHandler.GetChanges(entity))
+	}
+}
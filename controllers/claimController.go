@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupClaimRoutes registers insurance claims and the batches they are submitted to insurers in.
+// Staff open claims for bills; only admins approve them, submit batches and record insurers' responses.
+func SetupClaimRoutes(router *gin.Engine, claimHandler *handlers.ClaimHandler) {
+	router.POST("/billings/:id/claim", claimHandler.CreateClaim)
+	router.GET("/claims", claimHandler.GetClaims)
+	router.GET("/claims/:id", claimHandler.GetClaim)
+	router.GET("/insurance_companies/:id/claims/batches", claimHandler.GetBatches)
+	router.GET("/claim_batches/:id", claimHandler.GetBatch)
+	router.GET("/claim_batches/:id/download", claimHandler.DownloadBatch)
+
+	router.POST("/claims/:id/approve", claimHandler.ApproveClaim)
+	router.POST("/insurance_companies/:id/claims/batch", claimHandler.CreateBatch)
+	router.PUT("/claim_batches/:id/status", claimHandler.UpdateBatchStatus)
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupClinicalAuditRoutes registers the clinical audits of the practice's quality program: admins
+// draw samples of visits, and admins and doctors review and score them
+func SetupClinicalAuditRoutes(router *gin.Engine, clinicalAuditHandler *handlers.ClinicalAuditHandler) {
+	router.POST("/clinical_audits", clinicalAuditHandler.CreateClinicalAudit)
+	router.DELETE("/clinical_audits/:id", clinicalAuditHandler.DeleteClinicalAudit)
+
+	router.GET("/clinical_audits", clinicalAuditHandler.GetClinicalAudits)
+	router.GET("/clinical_audits/trends", clinicalAuditHandler.GetClinicalAuditTrends)
+	router.GET("/clinical_audits/:id", clinicalAuditHandler.GetClinicalAudit)
+	router.GET("/clinical_audits/:id/worksheet", clinicalAuditHandler.GetClinicalAuditWorksheet)
+	router.PUT("/clinical_audits/:id/items/:item_id/score", clinicalAuditHandler.ScoreClinicalAuditItem)
+}
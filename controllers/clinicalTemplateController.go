@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupClinicalTemplateRoutes registers doctors' examination and treatment plan templates. Doctors
+// keep their own and ask for them to be shared; only admins approve sharing with the clinic.
+func SetupClinicalTemplateRoutes(router *gin.Engine, templateHandler *handlers.ClinicalTemplateHandler) {
+	router.POST("/templates", templateHandler.CreateTemplate)
+	router.GET("/templates", templateHandler.GetTemplates)
+	router.GET("/templates/:id", templateHandler.GetTemplate)
+	router.PUT("/templates/:id", templateHandler.UpdateTemplate)
+	router.DELETE("/templates/:id", templateHandler.DeleteTemplate)
+	router.POST("/templates/:id/share", templateHandler.ShareTemplate)
+
+	router.GET("/templates/pending", templateHandler.GetPendingTemplates)
+	router.POST("/templates/:id/review", templateHandler.ReviewTemplate)
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupClosureRoutes registers the closure calendar of public holidays and other days the clinic is
+// closed, which staff look up when booking and only admins maintain
+func SetupClosureRoutes(router *gin.Engine, closureHandler *handlers.ClosureHandler) {
+	router.GET("/closures", closureHandler.GetClosures)
+	router.GET("/closures/:id", closureHandler.GetClosure)
+
+	// Receptionists rebook the appointments left on a closure
+	router.GET("/closures/:id/appointments", closureHandler.GetClosureAppointments)
+
+	router.POST("/closures", closureHandler.CreateClosure)
+	router.PUT("/closures/:id", closureHandler.UpdateClosure)
+	router.DELETE("/closures/:id", closureHandler.DeleteClosure)
+}
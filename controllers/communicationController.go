@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPortalRoutes registers the patient portal, where patients choose which messages they receive.
+// It is authenticated by the signed link in those messages only.
+func SetupPortalRoutes(router *gin.Engine, communicationHandler *handlers.CommunicationHandler) {
+	portalGroup := router.Group("/portal").Use(
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+	)
+	{
+		portalGroup.GET("/preferences", communicationHandler.GetPortalPreferences)
+		portalGroup.PUT("/preferences", communicationHandler.UpdatePortalPreferences)
+	}
+}
+
+// SetupCommunicationRoutes registers the desk endpoints recording patients' communication
+// preferences and consents, and the messages sent to them
+func SetupCommunicationRoutes(router *gin.Engine, communicationHandler *handlers.CommunicationHandler) {
+	router.GET("/patients/:patient_id/communication_preferences", communicationHandler.GetCommunicationPreferences)
+	router.PUT("/patients/:patient_id/communication_preferences", communicationHandler.UpdateCommunicationPreferences)
+	router.GET("/patients/:patient_id/consents", communicationHandler.GetConsents)
+	router.GET("/patients/:patient_id/communications", communicationHandler.GetCommunicationLog)
+}
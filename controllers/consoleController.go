@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"RoyDental/console"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConsolePath is where the admin console is served
+const ConsolePath = "/console"
+
+// SetupConsoleRoutes serves the admin console's page and assets under the console's own content
+// security policy, which lets the page load its scripts and styles and call the API. The page
+// holds no data, so it is served without the bearer token; the admin endpoints it calls require
+// it as usual.
+func SetupConsoleRoutes(router *gin.Engine, contentSecurityPolicy string) {
+	page := console.Page()
+	consoleGroup := router.Group(ConsolePath, func(c *gin.Context) {
+		c.Header("Content-Security-Policy", contentSecurityPolicy)
+		c.Header("Cache-Control", "no-cache")
+		c.Next()
+	})
+	{
+		consoleGroup.GET("", func(c *gin.Context) {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		})
+		consoleGroup.StaticFS("/static", http.FS(console.Assets()))
+	}
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupContractRateRoutes registers insurers' contract price lists, which only admins change, and the
+// contract variance report
+func SetupContractRateRoutes(router *gin.Engine, contractRateHandler *handlers.ContractRateHandler) {
+	router.GET("/insurance_companies/:id/contract_rates", contractRateHandler.GetContractRates)
+
+	router.POST("/insurance_companies/:id/contract_rates", contractRateHandler.CreateContractRate)
+	router.PUT("/insurance_companies/:id/contract_rates/:rate_id", contractRateHandler.UpdateContractRate)
+	router.DELETE("/insurance_companies/:id/contract_rates/:rate_id", contractRateHandler.DeleteContractRate)
+	router.GET("/reports/contract-variance", contractRateHandler.GetVarianceReport)
+}
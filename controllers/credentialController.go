@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCredentialRoutes registers the Admin-only compliance report of the doctors' credentials
+func SetupCredentialRoutes(router *gin.Engine, credentialHandler *handlers.CredentialHandler) {
+	credentialGroup := router.Group("/auth/admin/doctors")
+	{
+		credentialGroup.GET("/compliance", credentialHandler.GetComplianceReport)
+	}
+}
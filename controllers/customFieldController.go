@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCustomFieldRoutes registers the custom field definitions. Staff read them to build their
+// forms; only admins change them.
+func SetupCustomFieldRoutes(router *gin.Engine, customFieldHandler *handlers.CustomFieldHandler) {
+	router.GET("/custom_fields", customFieldHandler.GetCustomFields)
+	router.GET("/custom_fields/:id", customFieldHandler.GetCustomField)
+
+	router.POST("/custom_fields", customFieldHandler.CreateCustomField)
+	router.PUT("/custom_fields/:id", customFieldHandler.UpdateCustomField)
+	router.DELETE("/custom_fields/:id", customFieldHandler.DeleteCustomField)
+}
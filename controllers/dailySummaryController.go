@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDailySummaryRoutes registers the report of a day's key figures emailed to management
+func SetupDailySummaryRoutes(router *gin.Engine, dailySummaryHandler *handlers.DailySummaryHandler) {
+	router.GET("/reports/daily-summary",
+		dailySummaryHandler.GetDailySummary,
+	)
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDiagnosticsRoutes registers the runbook diagnostics: slow queries, lock waits, Redis
+// latency, the server's runtime statistics and schema drift
+func SetupDiagnosticsRoutes(router *gin.Engine, diagnosticsHandler *handlers.DiagnosticsHandler) {
+	diagnosticsGroup := router.Group("/diagnostics")
+	{
+		diagnosticsGroup.GET("", diagnosticsHandler.GetDiagnostics)
+		diagnosticsGroup.GET("/slow_queries", diagnosticsHandler.GetSlowQueries)
+		diagnosticsGroup.GET("/locks", diagnosticsHandler.GetLockWaits)
+		diagnosticsGroup.GET("/redis", diagnosticsHandler.GetRedisLatency)
+		diagnosticsGroup.GET("/runtime", diagnosticsHandler.GetRuntimeStats)
+		diagnosticsGroup.GET("/schema", diagnosticsHandler.GetSchemaDrift)
+	}
+}
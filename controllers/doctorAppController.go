@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDoctorAppRoutes registers the doctors' mobile app endpoints, scoped to the signed-in doctor
+func SetupDoctorAppRoutes(router *gin.Engine, doctorAppHandler *handlers.DoctorAppHandler) {
+	doctorGroup := router.Group("/me/doctor")
+	{
+		doctorGroup.GET("/appointments", doctorAppHandler.GetMyAppointments)
+		doctorGroup.GET("/patients/:id/summary", doctorAppHandler.GetMyPatientSummary)
+	}
+
+	// The doctor's own records, paginated, so the app never downloads the whole clinic's. Their
+	// appointments are at /me/appointments, which patients sign in to as well.
+	meGroup := router.Group("/me")
+	{
+		meGroup.GET("/patients", doctorAppHandler.GetMyPatients)
+		meGroup.GET("/billings", doctorAppHandler.GetMyBillings)
+	}
+}
+
+// SetupMyAppointmentRoutes registers /me/appointments for both roles that have appointments of
+// their own: doctors page through the appointments they see, and patients get their upcoming and
+// past visits
+func SetupMyAppointmentRoutes(router *gin.Engine, doctorAppHandler *handlers.DoctorAppHandler, patientPortalHandler *handlers.PatientPortalHandler) {
+	router.GET("/me/appointments",
+		func(c *gin.Context) {
+			if role, _ := middlewares.ExtractUserRoleFromContext(c.Request.Context()); role == "Patient" {
+				patientPortalHandler.GetMyAppointments(c)
+				return
+			}
+			doctorAppHandler.GetMyAppointmentsPage(c)
+		},
+	)
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDoctorImportRoutes registers the Admin-only endpoints importing doctors from a CSV file and
+// syncing them from the staff directory
+func SetupDoctorImportRoutes(router *gin.Engine, doctorImportHandler *handlers.DoctorImportHandler) {
+	importGroup := router.Group("/auth/admin/doctors")
+	{
+		importGroup.POST("/import", doctorImportHandler.ImportDoctors)
+		importGroup.POST("/sync", doctorImportHandler.SyncDoctors)
+	}
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSharedDocumentRoutes registers the links patients open the documents shared with them
+// through, authenticated by the signed link only because patients have no API credentials
+func SetupSharedDocumentRoutes(router *gin.Engine, documentShareHandler *handlers.DocumentShareHandler) {
+	rateLimiter := middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+		RequestsPerSecond: 2,
+		Burst:             10,
+	})
+	router.GET("/shared_documents/:id", rateLimiter, documentShareHandler.GetSharedDocuments)
+	router.GET("/shared_documents/:id/documents/:document_id", rateLimiter, documentShareHandler.GetSharedDocument)
+}
+
+// SetupDocumentShareRoutes registers the documents staff share with patients and the log of their use
+func SetupDocumentShareRoutes(router *gin.Engine, documentShareHandler *handlers.DocumentShareHandler) {
+	router.POST("/patients/:patient_id/document_shares", documentShareHandler.CreateDocumentShare)
+	router.GET("/patients/:patient_id/document_shares", documentShareHandler.GetDocumentShares)
+	router.GET("/patients/:patient_id/document_shares/:id", documentShareHandler.GetDocumentShare)
+	router.POST("/patients/:patient_id/document_shares/:id/revoke", documentShareHandler.RevokeDocumentShare)
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDunningRoutes registers the dunning schedule, which the front desk reads and only admins
+// change, and the statements sent about each bill
+func SetupDunningRoutes(router *gin.Engine, dunningHandler *handlers.DunningHandler) {
+	router.GET("/dunning_stages", dunningHandler.GetDunningStages)
+	router.GET("/dunning_stages/:id", dunningHandler.GetDunningStage)
+	router.GET("/billings/:id/dunning_notices", dunningHandler.GetBillingDunningNotices)
+
+	router.POST("/dunning_stages", dunningHandler.CreateDunningStage)
+	router.PUT("/dunning_stages/:id", dunningHandler.UpdateDunningStage)
+	router.DELETE("/dunning_stages/:id", dunningHandler.DeleteDunningStage)
+}
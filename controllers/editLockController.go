@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEditLockRoutes registers the soft locks warning staff that someone else is editing a patient
+func SetupEditLockRoutes(router *gin.Engine, editLockHandler *handlers.EditLockHandler) {
+	lockGroup := router.Group("/patients/:patient_id/lock")
+	{
+		lockGroup.POST("", editLockHandler.LockPatient)
+		lockGroup.POST("/heartbeat", editLockHandler.HeartbeatPatientLock)
+		lockGroup.DELETE("", editLockHandler.UnlockPatient)
+		lockGroup.GET("", editLockHandler.GetPatientLock)
+	}
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEligibilityRoutes registers the front desk endpoints following up insurance eligibility checks
+func SetupEligibilityRoutes(router *gin.Engine, eligibilityHandler *handlers.EligibilityHandler) {
+	router.GET("/eligibility_checks", eligibilityHandler.GetEligibilityChecks)
+	router.GET("/eligibility_checks/:id", eligibilityHandler.GetEligibilityCheck)
+	router.PUT("/eligibility_checks/:id", eligibilityHandler.RecordEligibility)
+	router.POST("/eligibility_checks/:id/run", eligibilityHandler.RunEligibilityCheck)
+	router.GET("/patients/:patient_id/appointments/:appointment_id/eligibility", eligibilityHandler.GetAppointmentEligibility)
+}
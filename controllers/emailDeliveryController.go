@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEmailDeliveryRoutes registers the mail provider's bounce and complaint notifications, which
+// are authenticated by the provider's signature of the body only, and the suppression list Admins
+// manage
+func SetupEmailDeliveryRoutes(router *gin.Engine, emailDeliveryHandler *handlers.EmailDeliveryHandler) {
+	router.POST("/email/events", emailDeliveryHandler.ReceiveEmailEvents)
+
+	adminGroup := router.Group("/email_suppressions")
+	{
+		adminGroup.GET("", emailDeliveryHandler.GetEmailSuppressions)
+		adminGroup.DELETE("/:address", emailDeliveryHandler.DeleteEmailSuppression)
+	}
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEmergencySlotRoutes registers the slots held back for same-day emergencies, which only admins
+// set up, and the booking of emergencies into them
+func SetupEmergencySlotRoutes(router *gin.Engine, emergencySlotHandler *handlers.EmergencySlotHandler, appointmentHandler *handlers.AppointmentHandler) {
+	router.GET("/emergency_slots", emergencySlotHandler.GetEmergencySlots)
+	router.GET("/emergency_slots/:id", emergencySlotHandler.GetEmergencySlot)
+	router.POST("/appointments/emergency", appointmentHandler.CreateEmergencyAppointment)
+
+	router.POST("/emergency_slots", emergencySlotHandler.CreateEmergencySlot)
+	router.PUT("/emergency_slots/:id", emergencySlotHandler.UpdateEmergencySlot)
+	router.DELETE("/emergency_slots/:id", emergencySlotHandler.DeleteEmergencySlot)
+}
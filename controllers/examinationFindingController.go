@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupExaminationFindingRoutes registers the schema structured examination findings follow and the
+// clinical audit report searching them
+func SetupExaminationFindingRoutes(router *gin.Engine, examinationHandler *handlers.ExaminationHandler) {
+	router.GET("/examination_findings/schema", examinationHandler.GetFindingSchemas)
+
+	clinicalGroup := router.Group("/reports/findings")
+	{
+		clinicalGroup.GET("", examinationHandler.GetFindingReport)
+		clinicalGroup.GET("/templates", examinationHandler.GetFindingTemplates)
+	}
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupExportRoutes registers the background exports, such as the accounting export of billing
+func SetupExportRoutes(router *gin.Engine, exportHandler *handlers.ExportHandler) {
+	exportGroup := router.Group("/exports")
+	{
+		exportGroup.POST("", exportHandler.RequestExport)
+		exportGroup.GET("", exportHandler.GetExports)
+		exportGroup.GET("/:id", exportHandler.GetExport)
+		exportGroup.GET("/:id/download", exportHandler.DownloadExport)
+	}
+}
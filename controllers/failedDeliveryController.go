@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupFailedDeliveryRoutes registers the Admin-only dead-letter queue of the emails, text messages
+// and chat webhook posts that could not be delivered, and their replay
+func SetupFailedDeliveryRoutes(router *gin.Engine, failedDeliveryHandler *handlers.FailedDeliveryHandler) {
+	deliveryGroup := router.Group("/admin/deliveries")
+	{
+		deliveryGroup.GET("/failed", failedDeliveryHandler.GetFailedDeliveries)
+		deliveryGroup.POST("/failed/replay", failedDeliveryHandler.ReplayFailedDeliveries)
+		deliveryGroup.POST("/:id/replay", failedDeliveryHandler.ReplayFailedDelivery)
+	}
+}
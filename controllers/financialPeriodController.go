@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupFinancialPeriodRoutes registers closing the books of a month and the adjustments made
+// afterwards. Closing a month needs the close_period permission, which admins can grant to the
+// front desk.
+func SetupFinancialPeriodRoutes(router *gin.Engine, financialPeriodHandler *handlers.FinancialPeriodHandler) {
+	router.GET("/financial_periods", financialPeriodHandler.GetPeriods)
+	router.POST("/financial_periods", financialPeriodHandler.ClosePeriod)
+	router.GET("/billings/:id/adjustments", financialPeriodHandler.GetBillingAdjustments)
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupGreetingRoutes registers the send log of birthday greetings, which only admins review.
+// The job itself is switched on and off through the birthday_greetings setting.
+func SetupGreetingRoutes(router *gin.Engine, greetingHandler *handlers.GreetingHandler) {
+	router.GET("/greetings", greetingHandler.GetGreetings)
+}
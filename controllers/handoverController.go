@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHandoverRoutes registers the notes clinicians hand over between shifts, the doctors' read
+// receipts of them and the morning huddle of a branch
+func SetupHandoverRoutes(router *gin.Engine, handoverHandler *handlers.HandoverHandler) {
+	router.POST("/handovers", handoverHandler.CreateHandover)
+	router.GET("/handovers", handoverHandler.GetHandovers)
+	router.GET("/handovers/:id", handoverHandler.GetHandover)
+	router.PUT("/handovers/:id", handoverHandler.UpdateHandover)
+	router.DELETE("/handovers/:id", handoverHandler.DeleteHandover)
+	router.POST("/handovers/:id/read", handoverHandler.MarkHandoverRead)
+
+	router.GET("/huddle", handoverHandler.GetMorningHuddle)
+}
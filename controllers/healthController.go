@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"RoyDental/breaker"
+	"RoyDental/database"
+	"RoyDental/metrics"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// livenessHandler reports that the process is up.
+func livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readinessHandler reports whether the server can serve traffic. Postgres is required unless reads
+// have failed over to the replica; an unavailable Redis only degrades the service because reads fall
+// back to the database.
+func readinessHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := gin.H{}
+	status := http.StatusOK
+
+	if err := pingDatabase(ctx); err == nil {
+		checks["database"] = "ok"
+	} else if database.ReadOnlyStatus().ReadsFromReplica {
+		// Reads go on from the replica, so the server keeps taking traffic in read-only mode
+		checks["database"] = "read-only (reading from replica): " + err.Error()
+	} else {
+		checks["database"] = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	switch state := database.RedisStatus(); state {
+	case breaker.Closed:
+		checks["redis"] = "ok"
+	default:
+		checks["redis"] = "degraded (circuit " + state.String() + ")"
+	}
+
+	overall := "ready"
+	if status != http.StatusOK {
+		overall = "not ready"
+	}
+	c.JSON(status, gin.H{"status": overall, "checks": checks})
+}
+
+func pingDatabase(ctx context.Context) error {
+	if database.DB == nil {
+		return errors.New("database is not initialized")
+	}
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// startingHandler reports that dependencies are still being connected.
+func startingHandler(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "error": "service is starting"})
+}
+
+// metricsHandler exposes metrics in the Prometheus text format.
+func metricsHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	metrics.WriteText(c.Writer)
+}
+
+// SetupHealthRoutes registers the liveness, readiness and metrics endpoints
+func SetupHealthRoutes(router *gin.Engine) {
+	router.GET("/healthz", livenessHandler)
+	router.GET("/readyz", readinessHandler)
+	router.GET("/metrics", metricsHandler)
+}
+
+// SetupStartupRoutes registers the probes served while the server is still connecting to its
+// dependencies; every other route answers 503 until startup completes.
+func SetupStartupRoutes(router *gin.Engine) {
+	router.GET("/healthz", livenessHandler)
+	router.GET("/readyz", startingHandler)
+	router.GET("/metrics", metricsHandler)
+	router.NoRoute(startingHandler)
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHL7ListenerRoutes registers the HTTP listener for the hospital's ADT messages, authenticated by HL7 keys only
+func SetupHL7ListenerRoutes(router *gin.Engine, hl7Handler *handlers.HL7Handler, hl7Keys []string) {
+	router.POST("/hl7/adt", middlewares.HL7AuthMiddleware(hl7Keys), hl7Handler.ReceiveMessage)
+}
+
+// SetupHL7ReviewRoutes registers the queue where staff resolve messages that could not be matched safely
+func SetupHL7ReviewRoutes(router *gin.Engine, hl7Handler *handlers.HL7Handler) {
+	reviewGroup := router.Group("/hl7/messages")
+	{
+		reviewGroup.GET("", hl7Handler.GetMessages)
+		reviewGroup.POST("/:id/apply", hl7Handler.ApplyMessage)
+		reviewGroup.POST("/:id/reject", hl7Handler.RejectMessage)
+	}
+}
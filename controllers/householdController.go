@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHouseholdRoutes registers the households linking the patients of a family, which every
+// staff member sees and the front desk manages along with the family's consolidated statement
+func SetupHouseholdRoutes(router *gin.Engine, householdHandler *handlers.HouseholdHandler) {
+	router.GET("/households", householdHandler.GetHouseholds)
+	router.GET("/households/:id", householdHandler.GetHousehold)
+	router.GET("/patients/:patient_id/household", householdHandler.GetPatientHousehold)
+
+	deskGroup := router.Group("/households")
+	{
+		deskGroup.POST("", householdHandler.CreateHousehold)
+		deskGroup.GET("/suggestions", householdHandler.GetHouseholdSuggestions)
+		deskGroup.PUT("/:id", householdHandler.UpdateHousehold)
+		deskGroup.DELETE("/:id", householdHandler.DeleteHousehold)
+		deskGroup.POST("/:id/members", householdHandler.AddHouseholdMember)
+		deskGroup.PUT("/:id/members/:patient_id", householdHandler.UpdateHouseholdMember)
+		deskGroup.DELETE("/:id/members/:patient_id", householdHandler.RemoveHouseholdMember)
+		deskGroup.GET("/:id/statement", householdHandler.GetHouseholdStatement)
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupImagingIngestRoutes registers DICOM ingestion for the imaging unit, authenticated by imaging keys only
+func SetupImagingIngestRoutes(router *gin.Engine, imagingHandler *handlers.ImagingHandler, imagingKeys []string) {
+	router.POST("/imaging/dicom", middlewares.ImagingAuthMiddleware(imagingKeys), imagingHandler.IngestDICOM)
+}
+
+// SetupImagingRoutes registers the staff endpoints viewing received images and matching them to patients
+func SetupImagingRoutes(router *gin.Engine, imagingHandler *handlers.ImagingHandler) {
+	router.GET("/imaging/studies", imagingHandler.GetStudies)
+	router.GET("/imaging/studies/:id", imagingHandler.GetStudy)
+	router.GET("/imaging/studies/:id/preview", imagingHandler.GetStudyPreview)
+	router.GET("/imaging/studies/:id/download", imagingHandler.DownloadStudy)
+	router.POST("/imaging/studies/:id/match", imagingHandler.MatchStudy)
+	router.GET("/patients/:patient_id/imaging", imagingHandler.GetPatientStudies)
+}
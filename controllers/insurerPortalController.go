@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupInsurerPortalRoutes registers what an insurer's claims officer sees of their company's
+// members, which is their claims and bills only, and the admins' linking of officers to their
+// companies and log of the officers' look-ups
+func SetupInsurerPortalRoutes(router *gin.Engine, insurerPortalHandler *handlers.InsurerPortalHandler) {
+	insurerGroup := router.Group("/me/insurer")
+	{
+		insurerGroup.GET("/claims", insurerPortalHandler.GetMyClaims)
+		insurerGroup.GET("/claims/:id", insurerPortalHandler.GetMyClaim)
+		insurerGroup.GET("/billings", insurerPortalHandler.GetMyBillings)
+		insurerGroup.GET("/billings/:id", insurerPortalHandler.GetMyBilling)
+	}
+
+	router.PUT("/users/:id/insurer", insurerPortalHandler.LinkInsurerAccount)
+	router.DELETE("/users/:id/insurer", insurerPortalHandler.UnlinkInsurerAccount)
+	router.GET("/insurer_accounts", insurerPortalHandler.GetInsurerAccounts)
+	router.GET("/insurer_accesses", insurerPortalHandler.GetInsurerAccesses)
+}
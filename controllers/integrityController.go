@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupIntegrityRoutes registers the integrity checks for orphaned records, stale cache entries and
+// bad balances
+func SetupIntegrityRoutes(router *gin.Engine, integrityHandler *handlers.IntegrityHandler) {
+	integrityGroup := router.Group("/integrity_checks")
+	{
+		integrityGroup.POST("", integrityHandler.StartIntegrityCheck)
+		integrityGroup.GET("", integrityHandler.GetIntegrityChecks)
+		integrityGroup.GET("/:id", integrityHandler.GetIntegrityCheck)
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupKioskRoutes registers the waiting-room kiosk API, authenticated by kiosk keys only
+func SetupKioskRoutes(router *gin.Engine, kioskHandler *handlers.KioskHandler, kioskKeys []string) {
+	kioskGroup := router.Group("/kiosk").Use(
+		middlewares.KioskAuthMiddleware(kioskKeys),
+		// Patients identify themselves by phone and date of birth, so guessing must stay slow
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+	)
+	{
+		kioskGroup.POST("/appointments/lookup", kioskHandler.LookupAppointments)
+		kioskGroup.POST("/appointments/:appointment_id/check-in", kioskHandler.CheckIn)
+		kioskGroup.POST("/contact-updates", kioskHandler.SubmitContactUpdate)
+	}
+}
+
+// SetupContactUpdateRoutes registers the staff endpoints reviewing kiosk contact updates
+func SetupContactUpdateRoutes(router *gin.Engine, kioskHandler *handlers.KioskHandler) {
+	router.GET("/contact_updates", kioskHandler.GetContactUpdates)
+	router.POST("/contact_updates/:id/review", kioskHandler.ReviewContactUpdate)
+}
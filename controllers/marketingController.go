@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMarketingRoutes registers the report of what each source and campaign brings in
+func SetupMarketingRoutes(router *gin.Engine, marketingHandler *handlers.MarketingHandler) {
+	router.GET("/reports/marketing",
+		marketingHandler.GetMarketingReport,
+	)
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMaterialRoutes registers the material lots used for each billed procedure and the search
+// for patients treated with a recalled lot
+func SetupMaterialRoutes(router *gin.Engine, materialHandler *handlers.MaterialHandler) {
+	router.GET("/billings/:id/materials", materialHandler.GetBillingMaterials)
+	router.POST("/billings/:id/materials", materialHandler.RecordMaterial)
+	router.DELETE("/billings/:id/materials/:material_id", materialHandler.DeleteMaterial)
+	router.GET("/patients/:patient_id/materials", materialHandler.GetPatientMaterials)
+
+	router.GET("/materials/recall", materialHandler.RecallMaterial)
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupNoShowRoutes registers the list of appointments likely to be missed, which the desk works
+// through to double-confirm or overbook
+func SetupNoShowRoutes(router *gin.Engine, noShowHandler *handlers.NoShowHandler) {
+	router.GET("/queue/no_show_risks", noShowHandler.GetNoShowRisks)
+}
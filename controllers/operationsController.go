@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupOperationsRoutes registers the Admin-only cache tools and background job queue status the
+// admin console works with
+func SetupOperationsRoutes(router *gin.Engine, operationsHandler *handlers.OperationsHandler) {
+	adminGroup := router.Group("/auth/admin")
+	{
+		adminGroup.GET("/cache", operationsHandler.GetCacheStatus)
+		adminGroup.POST("/cache/flush", operationsHandler.FlushCache)
+		adminGroup.POST("/cache/prune", operationsHandler.PruneCache)
+		adminGroup.DELETE("/cache/:entity", operationsHandler.InvalidateCacheEntity)
+		adminGroup.GET("/jobs", operationsHandler.GetJobQueues)
+	}
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPatientAlertRoutes registers patients' risk alerts, which every staff member sees and
+// clinicians raise and acknowledge before treatment
+func SetupPatientAlertRoutes(router *gin.Engine, patientAlertHandler *handlers.PatientAlertHandler) {
+	router.GET("/patients/:patient_id/alerts", patientAlertHandler.GetPatientAlerts)
+
+	router.POST("/patients/:patient_id/alerts", patientAlertHandler.CreatePatientAlert)
+	router.PUT("/patients/:patient_id/alerts/:id", patientAlertHandler.UpdatePatientAlert)
+	router.POST("/patients/:patient_id/appointments/:appointment_id/alerts/acknowledge", patientAlertHandler.AcknowledgeAlerts)
+}
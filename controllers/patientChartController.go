@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPatientChartRoutes registers the export of patients' full clinical charts, for doctors and
+// admins whose role grants the export
+func SetupPatientChartRoutes(router *gin.Engine, patientChartHandler *handlers.PatientChartHandler) {
+	router.GET("/patients/:patient_id/chart.pdf", patientChartHandler.GetPatientChartPDF)
+}
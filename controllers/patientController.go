@@ -2,36 +2,42 @@ package controllers
 
 import (
 	"RoyDental/handlers"
+	"RoyDental/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupPatientRoutes(router *gin.Engine, patientHandler *handlers.PatientHandler, doctorHandler *handlers.DoctorHandler, insuranceCompanyHandler *handlers.InsuranceCompanyHandler, emergencyContactHandler *handlers.EmergencyContactHandler, examinationHandler *handlers.ExaminationHandler, billingHandler *handlers.BillingHandler, treatmentPlanHandler *handlers.TreatmentPlanHandler, appointmentHandler *handlers.AppointmentHandler) {
+func SetupPatientRoutes(router *gin.Engine, patientHandler *handlers.PatientHandler, doctorHandler *handlers.DoctorHandler, insuranceCompanyHandler *handlers.InsuranceCompanyHandler, emergencyContactHandler *handlers.EmergencyContactHandler, examinationHandler *handlers.ExaminationHandler, billingHandler *handlers.BillingHandler, treatmentPlanHandler *handlers.TreatmentPlanHandler, appointmentHandler *handlers.AppointmentHandler, duplicateSubmissions gin.HandlerFunc, responseCache *middlewares.ResponseCache) {
 	// Define the routes directly on the router
 	router.POST("/doctors", doctorHandler.CreateDoctor)
 	router.GET("/doctors/:id", doctorHandler.GetDoctorByID)
 	router.PUT("/doctors/:id", doctorHandler.UpdateDoctor)
+	router.PATCH("/doctors/:id", doctorHandler.PatchDoctor)
 	router.DELETE("/doctors/:id", doctorHandler.DeleteDoctor)
-	router.GET("/doctors", doctorHandler.GetAllDoctors)
+	router.GET("/doctors", responseCache.For("doctors"), doctorHandler.GetAllDoctors)
 
 	router.POST("/patients", patientHandler.CreatePatient)
 	router.GET("/patients/:patient_id", patientHandler.GetPatientByID)
 	router.PUT("/patients/:patient_id", patientHandler.UpdatePatient)
+	router.PATCH("/patients/:patient_id", patientHandler.PatchPatient)
 	router.DELETE("/patients/:patient_id", patientHandler.DeletePatient)
 	router.DELETE("/patients/:patient_id/related", patientHandler.DeletePatientAndRelated)
 	router.GET("/patients", patientHandler.GetAllPatients)
 
 	router.POST("/insurance_companies", insuranceCompanyHandler.CreateInsuranceCompany)
-	router.GET("/insurance_companies/:id", insuranceCompanyHandler.GetInsuranceCompanyByID)
+	router.GET("/insurance_companies/:id", responseCache.For("insurance_companies"), insuranceCompanyHandler.GetInsuranceCompanyByID)
 	router.PUT("/insurance_companies/:id", insuranceCompanyHandler.UpdateInsuranceCompany)
 	router.DELETE("/insurance_companies/:id", insuranceCompanyHandler.DeleteInsuranceCompany)
-	router.GET("/insurance_companies", insuranceCompanyHandler.GetAllInsuranceCompanies)
+	router.GET("/insurance_companies", responseCache.For("insurance_companies"), insuranceCompanyHandler.GetAllInsuranceCompanies)
 
 	router.POST("/patients/:patient_id/emergency_contacts", emergencyContactHandler.CreateEmergencyContact)
 	router.GET("/patients/:patient_id/emergency_contacts", emergencyContactHandler.GetAllEmergencyContacts)
 	router.GET("/patients/:patient_id/emergency_contacts/:emergency_contact_id", emergencyContactHandler.GetEmergencyContactByID)
 	router.PUT("/patients/:patient_id/emergency_contacts/:emergency_contact_id", emergencyContactHandler.UpdateEmergencyContact)
 	router.DELETE("/patients/:patient_id/emergency_contacts/:emergency_contact_id", emergencyContactHandler.DeleteEmergencyContact)
+	router.PUT("/patients/:patient_id/emergency_contacts/:emergency_contact_id/sms_consent", emergencyContactHandler.UpdateEmergencyContactConsent)
+	router.POST("/patients/:patient_id/emergency_contacts/notify", emergencyContactHandler.NotifyEmergencyContact)
+	router.GET("/emergency_contact_templates", emergencyContactHandler.GetEmergencyContactTemplates)
 
 	router.POST("/patients/:patient_id/examinations", examinationHandler.CreateExamination)
 	router.GET("/patients/:patient_id/examinations", examinationHandler.GetAllExaminations)
@@ -44,16 +50,23 @@ func SetupPatientRoutes(router *gin.Engine, patientHandler *handlers.PatientHand
 	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id", treatmentPlanHandler.GetTreatmentPlanByID)
 	router.PUT("/patients/:patient_id/treatment_plans/:treatment_plan_id", treatmentPlanHandler.UpdateTreatmentPlan)
 	router.DELETE("/patients/:patient_id/treatment_plans/:treatment_plan_id", treatmentPlanHandler.DeleteTreatmentPlan)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/versions", treatmentPlanHandler.GetTreatmentPlanVersions)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/versions/diff", treatmentPlanHandler.GetTreatmentPlanDiff)
 
-	router.POST("/billings", billingHandler.CreateBilling)
+	// A bill or appointment saved twice in quick succession is only created once
+	router.POST("/billings", duplicateSubmissions, billingHandler.CreateBilling)
 	router.GET("/billings/:id", billingHandler.GetBillingByID)
 	router.PUT("/billings/:id", billingHandler.UpdateBilling)
+	router.PATCH("/billings/:id", billingHandler.PatchBilling)
 	router.DELETE("/billings/:id", billingHandler.DeleteBilling)
 	router.GET("/billings", billingHandler.GetAllBillings)
 
-	router.POST("/patients/:patient_id/appointments", appointmentHandler.CreateAppointment)
+	router.POST("/patients/:patient_id/appointments", duplicateSubmissions, appointmentHandler.CreateAppointment)
 	router.GET("/patients/:patient_id/appointments", appointmentHandler.GetAllAppointments)
 	router.GET("/patients/:patient_id/appointments/:appointment_id", appointmentHandler.GetAppointmentByID)
 	router.PUT("/patients/:patient_id/appointments/:appointment_id", appointmentHandler.UpdateAppointment)
+	router.PATCH("/patients/:patient_id/appointments/:appointment_id", appointmentHandler.PatchAppointment)
 	router.DELETE("/patients/:patient_id/appointments/:appointment_id", appointmentHandler.DeleteAppointment)
+	router.GET("/availability", appointmentHandler.GetAvailability)
+	router.GET("/reports/overbooking", appointmentHandler.GetOverbookingReport)
 }
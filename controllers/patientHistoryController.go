@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPatientHistoryRoutes registers the pages of a patient's full history, which the patient
+// itself only summarises
+func SetupPatientHistoryRoutes(router *gin.Engine, patientHistoryHandler *handlers.PatientHistoryHandler) {
+	staffGroup := router.Group("/patients/:patient_id/history")
+	{
+		staffGroup.GET("/appointments", patientHistoryHandler.GetAppointmentHistory)
+		staffGroup.GET("/billings", patientHistoryHandler.GetBillingHistory)
+		staffGroup.GET("/examinations", patientHistoryHandler.GetExaminationHistory)
+		staffGroup.GET("/treatment_plans", patientHistoryHandler.GetTreatmentPlanHistory)
+	}
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPatientPortalRoutes registers the signed-in patient's own bills, balance and statement, and
+// paying them online
+func SetupPatientPortalRoutes(router *gin.Engine, patientPortalHandler *handlers.PatientPortalHandler) {
+	patientGroup := router.Group("/me/patient")
+	{
+		patientGroup.GET("/billings", patientPortalHandler.GetMyBillings)
+		patientGroup.GET("/balance", patientPortalHandler.GetMyBalance)
+		patientGroup.GET("/statement", patientPortalHandler.GetMyStatement)
+		patientGroup.GET("/payments", patientPortalHandler.GetMyPayments)
+		patientGroup.POST("/billings/:id/payments", patientPortalHandler.StartPayment)
+	}
+}
+
+// SetupPaymentCallbackRoutes registers the payment gateway's callback. It is authenticated by the
+// gateway's signature of the body only.
+func SetupPaymentCallbackRoutes(router *gin.Engine, patientPortalHandler *handlers.PatientPortalHandler) {
+	router.POST("/payments/callback", patientPortalHandler.ReceivePaymentCallback)
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPatientQRRoutes registers the patient QR code images and the lookup reception scans them with
+func SetupPatientQRRoutes(router *gin.Engine, patientQRHandler *handlers.PatientQRHandler) {
+	router.GET("/patients/lookup", patientQRHandler.LookupPatient)
+	router.GET("/patients/:patient_id/qr", patientQRHandler.GetPatientQR)
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPaymentPlanRoutes registers installment payment plans, which the front desk sets up and
+// takes payments against. Taking a payment needs the capture_payment permission.
+func SetupPaymentPlanRoutes(router *gin.Engine, paymentPlanHandler *handlers.PaymentPlanHandler) {
+	router.POST("/patients/:patient_id/payment_plans", paymentPlanHandler.CreatePaymentPlan)
+	router.GET("/patients/:patient_id/payment_plans", paymentPlanHandler.GetPatientPaymentPlans)
+	router.GET("/payment_plans", paymentPlanHandler.GetPaymentPlans)
+	router.GET("/payment_plans/:id", paymentPlanHandler.GetPaymentPlan)
+	router.POST("/payment_plans/:id/installments/:number/payments", paymentPlanHandler.RecordPayment)
+	router.POST("/payment_plans/:id/cancel", paymentPlanHandler.CancelPaymentPlan)
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPayrollRoutes registers the monthly payroll export, its sign-off and the doctors' commission rates
+func SetupPayrollRoutes(router *gin.Engine, payrollHandler *handlers.PayrollHandler) {
+	router.GET("/payroll/:period", payrollHandler.GetPayroll)
+	router.GET("/payroll/:period/export.csv", payrollHandler.ExportPayroll)
+	router.POST("/payroll/:period/sign_off", payrollHandler.SignOffPayroll)
+	router.DELETE("/payroll/:period/sign_off", payrollHandler.ReopenPayroll)
+	router.GET("/commission_rates", payrollHandler.GetCommissionRates)
+	router.PUT("/doctors/:id/commission_rate", payrollHandler.SetCommissionRate)
+	router.DELETE("/doctors/:id/commission_rate", payrollHandler.DeleteCommissionRate)
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPolicyRoutes registers the Admin-only review of the authorization policy enforced on each route
+func SetupPolicyRoutes(router *gin.Engine, policyHandler *handlers.PolicyHandler) {
+	router.GET("/admin/policies", policyHandler.GetPolicies)
+}
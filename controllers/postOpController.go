@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPostOpRoutes registers the post-operative instruction library, which staff read and only
+// admins change
+func SetupPostOpRoutes(router *gin.Engine, postOpHandler *handlers.PostOpHandler) {
+	router.GET("/post_op_instructions", postOpHandler.GetPostOpInstructions)
+	router.GET("/post_op_instructions/:id", postOpHandler.GetPostOpInstruction)
+
+	router.POST("/post_op_instructions", postOpHandler.CreatePostOpInstruction)
+	router.PUT("/post_op_instructions/:id", postOpHandler.UpdatePostOpInstruction)
+	router.DELETE("/post_op_instructions/:id", postOpHandler.DeletePostOpInstruction)
+}
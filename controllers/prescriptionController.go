@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPrescriptionRoutes registers patients' allergies, prescribing with allergy and interaction
+// checks, and the drug interaction table, controlled substance list and register, which only admins
+// change or read in full
+func SetupPrescriptionRoutes(router *gin.Engine, prescriptionHandler *handlers.PrescriptionHandler) {
+	router.GET("/patients/:patient_id/allergies", prescriptionHandler.GetAllergies)
+	router.POST("/patients/:patient_id/allergies", prescriptionHandler.CreateAllergy)
+	router.DELETE("/patients/:patient_id/allergies/:id", prescriptionHandler.DeleteAllergy)
+
+	router.GET("/patients/:patient_id/prescriptions", prescriptionHandler.GetPrescriptions)
+	router.POST("/patients/:patient_id/prescriptions", prescriptionHandler.CreatePrescription)
+	router.POST("/patients/:patient_id/prescriptions/check", prescriptionHandler.CheckPrescription)
+	router.GET("/drug-interactions", prescriptionHandler.GetDrugInteractions)
+	router.GET("/controlled-substances", prescriptionHandler.GetControlledSubstances)
+
+	adminGroup := router.Group("/drug-interactions")
+	{
+		adminGroup.POST("", prescriptionHandler.CreateDrugInteraction)
+		adminGroup.DELETE("/:id", prescriptionHandler.DeleteDrugInteraction)
+	}
+
+	// The register is append-only: entries are written with controlled prescriptions and never edited
+	router.POST("/controlled-substances", prescriptionHandler.CreateControlledSubstance)
+	router.DELETE("/controlled-substances/:id", prescriptionHandler.DeleteControlledSubstance)
+	router.GET("/controlled-register", prescriptionHandler.GetControlledRegister)
+}
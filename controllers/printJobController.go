@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPrintJobRoutes registers the front desk endpoints queueing documents for the desk printers
+func SetupPrintJobRoutes(router *gin.Engine, printJobHandler *handlers.PrintJobHandler) {
+	router.POST("/print_jobs", printJobHandler.CreatePrintJob)
+	router.GET("/print_jobs", printJobHandler.GetPrintJobs)
+	router.GET("/print_jobs/:id", printJobHandler.GetPrintJob)
+	router.POST("/print_jobs/:id/cancel", printJobHandler.CancelPrintJob)
+}
+
+// SetupPrintAgentRoutes registers the endpoints the print agents poll, authenticated by print agent keys only
+func SetupPrintAgentRoutes(router *gin.Engine, printJobHandler *handlers.PrintJobHandler, agentKeys []string) {
+	agentGroup := router.Group("/print_agent").Use(middlewares.PrintAgentAuthMiddleware(agentKeys))
+	{
+		agentGroup.POST("/jobs/next", printJobHandler.ClaimPrintJob)
+		agentGroup.POST("/jobs/:id/result", printJobHandler.CompletePrintJob)
+	}
+}
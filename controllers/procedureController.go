@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupProcedureRoutes registers the procedure catalog, which only admins change, and the
+// procedures each doctor performs
+func SetupProcedureRoutes(router *gin.Engine, procedureHandler *handlers.ProcedureHandler, doctorHandler *handlers.DoctorHandler, responseCache *middlewares.ResponseCache) {
+	router.GET("/procedures", responseCache.For("procedures"), procedureHandler.GetProcedures)
+	router.GET("/procedures/:id", responseCache.For("procedures"), procedureHandler.GetProcedure)
+	router.GET("/doctors/:id/procedures", responseCache.For("doctor_procedures"), doctorHandler.GetDoctorProcedures)
+
+	router.POST("/procedures", procedureHandler.CreateProcedure)
+	router.PUT("/procedures/:id", procedureHandler.UpdateProcedure)
+	router.DELETE("/procedures/:id", procedureHandler.DeleteProcedure)
+	router.PUT("/doctors/:id/procedures", doctorHandler.SetDoctorProcedures)
+	router.GET("/reports/revenue-by-specialty", doctorHandler.GetRevenueBySpecialty)
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// profileHandler serves the net/http/pprof endpoint named by the path. The index also serves the
+// named profiles such as heap, goroutine and block.
+func profileHandler(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// ProfilingPolicy is the policy table route of the pprof endpoints, which are opened in development
+const ProfilingPolicy = "/debug/pprof/*"
+
+// SetupProfilingRoutes registers the pprof endpoints under /debug/pprof, for Admins only unless
+// opened. CPU profiles and traces must be asked for with ?seconds= below the request timeout of the
+// debug group and the server's write timeout.
+func SetupProfilingRoutes(router *gin.Engine) {
+	profilingGroup := router.Group("/debug/pprof")
+	profilingGroup.GET("/*name", profileHandler)
+	profilingGroup.POST("/*name", profileHandler)
+}
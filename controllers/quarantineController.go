@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupQuarantineRoutes registers the uploads the virus scanner flagged, for admins to review and
+// discard
+func SetupQuarantineRoutes(router *gin.Engine, quarantineHandler *handlers.QuarantineHandler) {
+	router.GET("/quarantined_files", quarantineHandler.GetQuarantinedFiles)
+	router.GET("/quarantined_files/:id", quarantineHandler.GetQuarantinedFile)
+	router.DELETE("/quarantined_files/:id", quarantineHandler.DeleteQuarantinedFile)
+}
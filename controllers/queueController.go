@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupQueueRoutes registers the waiting-queue, walk-in, visit preparation and front-desk dashboard
+// routes; only admins configure the pre-procedure checklists
+func SetupQueueRoutes(router *gin.Engine, queueHandler *handlers.QueueHandler, visitHandler *handlers.VisitHandler) {
+	router.GET("/queue/today", queueHandler.GetTodayQueue)
+	router.POST("/patients/:patient_id/appointments/:appointment_id/check-in", queueHandler.CheckIn)
+	router.POST("/patients/:patient_id/appointments/:appointment_id/seen", queueHandler.MarkSeen)
+	router.POST("/patients/:patient_id/appointments/:appointment_id/start", queueHandler.StartTreatment)
+	router.POST("/visits/walk-in", visitHandler.CreateWalkIn)
+
+	router.GET("/patients/:patient_id/appointments/:appointment_id/visit", visitHandler.GetVisit)
+	router.PUT("/patients/:patient_id/appointments/:appointment_id/vitals", visitHandler.SaveVitals)
+	router.POST("/patients/:patient_id/appointments/:appointment_id/checklists/:checklist_id", visitHandler.CompleteChecklist)
+	router.GET("/checklists", visitHandler.GetChecklists)
+
+	checklistGroup := router.Group("/checklists")
+	{
+		checklistGroup.POST("", visitHandler.CreateChecklist)
+		checklistGroup.PUT("/:id", visitHandler.UpdateChecklist)
+		checklistGroup.DELETE("/:id", visitHandler.DeleteChecklist)
+	}
+
+	router.GET("/dashboard/summary", queueHandler.GetDashboardSummary)
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadOnlyTogglePath is the route admins turn read-only mode on and off at, which read-only mode
+// itself lets through
+const ReadOnlyTogglePath = "/read_only"
+
+// SetupReadOnlyRoutes registers the read-only mode status, which every staff member may check, and
+// its toggle for admins
+func SetupReadOnlyRoutes(router *gin.Engine, readOnlyHandler *handlers.ReadOnlyHandler) {
+	staffGroup := router.Group(ReadOnlyTogglePath)
+	{
+		staffGroup.GET("", readOnlyHandler.GetReadOnlyStatus)
+	}
+
+	adminGroup := router.Group(ReadOnlyTogglePath)
+	{
+		adminGroup.PUT("", readOnlyHandler.SetReadOnly)
+	}
+}
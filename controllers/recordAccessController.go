@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRecordAccessRoutes registers who read patients' records: the signed-in patient's own record
+// in the portal, and every patient's for admins
+func SetupRecordAccessRoutes(router *gin.Engine, recordAccessHandler *handlers.RecordAccessHandler) {
+	patientGroup := router.Group("/me/patient")
+	{
+		patientGroup.GET("/record_accesses", recordAccessHandler.GetMyRecordAccesses)
+	}
+
+	router.GET("/record_accesses", recordAccessHandler.GetRecordAccesses)
+	router.GET("/patients/:patient_id/record_accesses", recordAccessHandler.GetPatientRecordAccesses)
+}
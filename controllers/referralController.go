@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupReferralImagingRoutes registers the imaging links of referral letters, authenticated by the
+// signed link only because specialists have no API credentials
+func SetupReferralImagingRoutes(router *gin.Engine, referralHandler *handlers.ReferralHandler) {
+	router.GET("/referrals/:id/imaging/:study_id",
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+		referralHandler.GetReferralImaging,
+	)
+}
+
+// SetupReferralRoutes registers patients' referrals to specialists, which clinicians write and
+// the front desk prints and follows up, and the letter templates only admins change
+func SetupReferralRoutes(router *gin.Engine, referralHandler *handlers.ReferralHandler) {
+	router.GET("/referral_templates", referralHandler.GetReferralTemplates)
+	router.GET("/referral_templates/:id", referralHandler.GetReferralTemplate)
+	router.GET("/patients/:patient_id/referrals", referralHandler.GetReferrals)
+	router.GET("/patients/:patient_id/referrals/:id", referralHandler.GetReferral)
+	router.GET("/patients/:patient_id/referrals/:id/letter.pdf", referralHandler.GetReferralLetterPDF)
+	router.PUT("/patients/:patient_id/referrals/:id/status", referralHandler.UpdateReferralStatus)
+
+	router.POST("/patients/:patient_id/referrals", referralHandler.CreateReferral)
+
+	router.POST("/referral_templates", referralHandler.CreateReferralTemplate)
+	router.PUT("/referral_templates/:id", referralHandler.UpdateReferralTemplate)
+	router.DELETE("/referral_templates/:id", referralHandler.DeleteReferralTemplate)
+}
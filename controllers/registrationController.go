@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRegistrationFormRoutes registers the public pre-registration form, protected by a captcha
+// and a tight rate limit instead of an API token
+func SetupRegistrationFormRoutes(router *gin.Engine, registrationHandler *handlers.RegistrationHandler) {
+	formGroup := router.Group("/registrations").Use(
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 1,
+			Burst:             5,
+		}),
+	)
+	{
+		formGroup.POST("", registrationHandler.SubmitRegistration)
+	}
+}
+
+// SetupRegistrationReviewRoutes registers the queue receptionists work pre-registrations from
+func SetupRegistrationReviewRoutes(router *gin.Engine, registrationHandler *handlers.RegistrationHandler) {
+	router.GET("/pending_registrations", registrationHandler.GetRegistrations)
+	router.GET("/pending_registrations/:id", registrationHandler.GetRegistration)
+	router.POST("/pending_registrations/:id/convert", registrationHandler.ConvertRegistration)
+	router.POST("/pending_registrations/:id/reject", registrationHandler.RejectRegistration)
+}
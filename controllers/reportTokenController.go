@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewReportingGroup creates the group of reports read with report tokens instead of the bearer
+// token. It must be created before the bearer token is required; the reports are registered on it
+// with SetupReportingRoutes once their handlers exist.
+func NewReportingGroup(router *gin.Engine, tokens middlewares.ReportTokenAuthenticator, branch string) *gin.RouterGroup {
+	return router.Group("/reporting",
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+		middlewares.ReportTokenMiddleware(tokens, branch),
+	)
+}
+
+// SetupReportingRoutes registers the reports reporting tools read with their tokens. Each route is
+// named after the report a token is scoped to.
+func SetupReportingRoutes(
+	reportingGroup *gin.RouterGroup,
+	analyticsHandler *handlers.AnalyticsHandler,
+	contractRateHandler *handlers.ContractRateHandler,
+	billingDisputeHandler *handlers.BillingDisputeHandler,
+	chairHandler *handlers.ChairHandler,
+	appointmentHandler *handlers.AppointmentHandler,
+	doctorHandler *handlers.DoctorHandler,
+	staffActivityHandler *handlers.StaffActivityHandler,
+	surveyHandler *handlers.SurveyHandler,
+	marketingHandler *handlers.MarketingHandler,
+	dailySummaryHandler *handlers.DailySummaryHandler,
+) {
+	reportingGroup.GET("/analytics", analyticsHandler.GetAnalytics)
+	reportingGroup.GET("/contract-variance", contractRateHandler.GetVarianceReport)
+	reportingGroup.GET("/daily-summary", dailySummaryHandler.GetDailySummary)
+	reportingGroup.GET("/disputes", billingDisputeHandler.GetOpenDisputeReport)
+	reportingGroup.GET("/downtime", chairHandler.GetDowntimeReport)
+	reportingGroup.GET("/marketing", marketingHandler.GetMarketingReport)
+	reportingGroup.GET("/overbooking", appointmentHandler.GetOverbookingReport)
+	reportingGroup.GET("/revenue-by-specialty", doctorHandler.GetRevenueBySpecialty)
+	reportingGroup.GET("/staff-activity", staffActivityHandler.GetStaffActivity)
+	reportingGroup.GET("/surveys", surveyHandler.GetDoctorReports)
+}
+
+// SetupReportTokenRoutes registers the admin routes minting and revoking report tokens
+func SetupReportTokenRoutes(router *gin.Engine, reportTokenHandler *handlers.ReportTokenHandler) {
+	adminGroup := router.Group("/report_tokens")
+	{
+		adminGroup.POST("", reportTokenHandler.MintReportToken)
+		adminGroup.GET("", reportTokenHandler.GetReportTokens)
+		adminGroup.GET("/:id", reportTokenHandler.GetReportToken)
+		adminGroup.POST("/:id/revoke", reportTokenHandler.RevokeReportToken)
+	}
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRetentionRoutes registers the Admin-only endpoints reporting on and running the data retention rules
+func SetupRetentionRoutes(router *gin.Engine, retentionHandler *handlers.RetentionHandler) {
+	retentionGroup := router.Group("/auth/admin/retention")
+	{
+		retentionGroup.GET("", retentionHandler.GetRetentionPolicies)
+		retentionGroup.GET("/runs", retentionHandler.GetRetentionRuns)
+		retentionGroup.POST("/run", retentionHandler.RunRetention)
+	}
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRoleRoutes registers the roles and the permissions granted to them, which only admins manage
+func SetupRoleRoutes(router *gin.Engine, roleHandler *handlers.RoleHandler) {
+	router.GET("/roles", roleHandler.GetRoles)
+	router.POST("/roles", roleHandler.CreateRole)
+	router.GET("/roles/:id", roleHandler.GetRole)
+	router.PUT("/roles/:id", roleHandler.UpdateRole)
+	router.DELETE("/roles/:id", roleHandler.DeleteRole)
+	router.PUT("/roles/:id/permissions/:permission_id", roleHandler.GrantPermission)
+	router.DELETE("/roles/:id/permissions/:permission_id", roleHandler.RevokePermission)
+	router.GET("/permissions", roleHandler.GetPermissions)
+	router.GET("/users/:id/permissions", roleHandler.GetUserPermissions)
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRosterRoutes registers the staff roster. Every staff member sees the roster and asks for
+// their own swaps and leave; admins plan the shifts and decide the requests.
+func SetupRosterRoutes(router *gin.Engine, rosterHandler *handlers.RosterHandler) {
+	router.GET("/roster", rosterHandler.GetRoster)
+	router.GET("/roster.ics", rosterHandler.GetRosterCalendar)
+	router.GET("/shifts/:id", rosterHandler.GetShift)
+
+	router.GET("/me/roster", rosterHandler.GetMyRoster)
+	router.GET("/me/roster.ics", rosterHandler.GetMyRosterCalendar)
+	router.POST("/me/shift_swaps", rosterHandler.RequestShiftSwap)
+	router.GET("/me/shift_swaps", rosterHandler.GetMyShiftSwaps)
+	router.POST("/me/shift_swaps/:id/cancel", rosterHandler.CancelShiftSwap)
+	router.POST("/me/leave_requests", rosterHandler.RequestLeave)
+	router.GET("/me/leave_requests", rosterHandler.GetMyLeaveRequests)
+	router.POST("/me/leave_requests/:id/cancel", rosterHandler.CancelLeaveRequest)
+
+	router.POST("/shifts", rosterHandler.CreateShift)
+	router.PUT("/shifts/:id", rosterHandler.UpdateShift)
+	router.DELETE("/shifts/:id", rosterHandler.DeleteShift)
+	router.GET("/shift_swaps", rosterHandler.GetShiftSwaps)
+	router.PUT("/shift_swaps/:id/review", rosterHandler.ReviewShiftSwap)
+	router.GET("/leave_requests", rosterHandler.GetLeaveRequests)
+	router.PUT("/leave_requests/:id/review", rosterHandler.ReviewLeaveRequest)
+}
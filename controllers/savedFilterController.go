@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSavedFilterRoutes registers the list views each member of staff saves for themselves
+func SetupSavedFilterRoutes(router *gin.Engine, savedFilterHandler *handlers.SavedFilterHandler) {
+	router.POST("/saved_filters", savedFilterHandler.CreateSavedFilter)
+	router.GET("/saved_filters", savedFilterHandler.GetSavedFilters)
+	router.GET("/saved_filters/:id", savedFilterHandler.GetSavedFilter)
+	router.PUT("/saved_filters/:id", savedFilterHandler.UpdateSavedFilter)
+	router.DELETE("/saved_filters/:id", savedFilterHandler.DeleteSavedFilter)
+}
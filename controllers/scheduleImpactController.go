@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupScheduleImpactRoutes registers the endpoints the front desk uses when a doctor calls in sick
+func SetupScheduleImpactRoutes(router *gin.Engine, scheduleImpactHandler *handlers.ScheduleImpactHandler) {
+	router.GET("/doctors/:id/schedule/impact", scheduleImpactHandler.GetScheduleImpact)
+	router.POST("/doctors/:id/schedule/notify", scheduleImpactHandler.NotifyAffectedPatients)
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSettingRoutes registers the Admin-only runtime settings, such as the debug request log, and
+// the appointment types every staff member's calendar shows
+func SetupSettingRoutes(router *gin.Engine, settingHandler *handlers.SettingHandler) {
+	router.GET("/appointment_types", settingHandler.GetAppointmentTypes)
+
+	settingGroup := router.Group("/auth/admin/settings")
+	{
+		settingGroup.GET("", settingHandler.GetSettings)
+		settingGroup.PUT("", settingHandler.UpdateSettings)
+	}
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSMSReplyRoutes registers the SMS gateway's forwarded replies, which are authenticated by the
+// gateway's signature of the body only, and the inbox of replies staff deal with
+func SetupSMSReplyRoutes(router *gin.Engine, smsReplyHandler *handlers.SMSReplyHandler) {
+	router.POST("/sms/replies", smsReplyHandler.ReceiveSMSReply)
+
+	staffGroup := router.Group("/sms/inbox")
+	{
+		staffGroup.GET("", smsReplyHandler.GetSMSInbox)
+		staffGroup.PUT("/:id/handled", smsReplyHandler.MarkSMSReplyHandled)
+	}
+}
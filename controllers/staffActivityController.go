@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupStaffActivityRoutes registers the staff activity report used in performance reviews
+func SetupStaffActivityRoutes(router *gin.Engine, staffActivityHandler *handlers.StaffActivityHandler) {
+	router.GET("/reports/staff-activity",
+		staffActivityHandler.GetStaffActivity,
+	)
+}
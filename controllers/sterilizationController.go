@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSterilizationRoutes registers the sterilization log, the batches used for each billed
+// procedure and the batch traceability report
+func SetupSterilizationRoutes(router *gin.Engine, sterilizationHandler *handlers.SterilizationHandler) {
+	router.GET("/sterilization/batches", sterilizationHandler.GetBatches)
+	router.POST("/sterilization/batches", sterilizationHandler.CreateBatch)
+	router.GET("/sterilization/batches/:id", sterilizationHandler.GetBatch)
+	router.PUT("/sterilization/batches/:id/result", sterilizationHandler.RecordResult)
+	router.GET("/sterilization/batches/:id/patients", sterilizationHandler.TraceBatch)
+
+	router.GET("/billings/:id/batches", sterilizationHandler.GetBillingBatches)
+	router.POST("/billings/:id/batches", sterilizationHandler.LinkBatches)
+	router.DELETE("/billings/:id/batches/:batch_id", sterilizationHandler.UnlinkBatch)
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSurveyRoutes registers the public survey endpoints, authenticated by the signed link only
+func SetupSurveyRoutes(router *gin.Engine, surveyHandler *handlers.SurveyHandler) {
+	surveyGroup := router.Group("/surveys").Use(
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 2,
+			Burst:             10,
+		}),
+	)
+	{
+		surveyGroup.GET("/:appointment_id", surveyHandler.GetSurvey)
+		surveyGroup.POST("/:appointment_id", surveyHandler.SubmitSurvey)
+	}
+}
+
+// SetupSurveyReportRoutes registers the staff survey reports
+func SetupSurveyReportRoutes(router *gin.Engine, surveyHandler *handlers.SurveyHandler) {
+	router.GET("/reports/surveys", surveyHandler.GetDoctorReports)
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSyncRoutes registers the sync API of the offline-capable desktop app
+func SetupSyncRoutes(router *gin.Engine, syncHandler *handlers.SyncHandler) {
+	syncGroup := router.Group("/sync")
+	{
+		syncGroup.GET("", syncHandler.GetSync)
+		syncGroup.POST("/push", syncHandler.PushSync)
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTaskRoutes registers staff tasks and internal patient notes, which patients never see
+func SetupTaskRoutes(router *gin.Engine, taskHandler *handlers.TaskHandler) {
+	staffGroup := router.Group("/staff")
+	{
+		staffGroup.POST("/tasks", taskHandler.CreateTask)
+		staffGroup.GET("/tasks", taskHandler.GetTasks)
+		staffGroup.GET("/tasks/:id", taskHandler.GetTask)
+		staffGroup.PUT("/tasks/:id", taskHandler.UpdateTask)
+		staffGroup.DELETE("/tasks/:id", taskHandler.DeleteTask)
+
+		staffGroup.POST("/patients/:patient_id/notes", taskHandler.CreateNote)
+		staffGroup.GET("/patients/:patient_id/notes", taskHandler.GetNotes)
+		staffGroup.DELETE("/patients/:patient_id/notes/:id", taskHandler.DeleteNote)
+	}
+}
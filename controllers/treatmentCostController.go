@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTreatmentCostRoutes registers the cost comparison of a treatment plan by financing option,
+// quoted to patients during consultations
+func SetupTreatmentCostRoutes(router *gin.Engine, treatmentCostHandler *handlers.TreatmentCostHandler) {
+	router.POST("/patients/:patient_id/treatment_plans/:treatment_plan_id/cost_comparison", treatmentCostHandler.CompareTreatmentCosts)
+}
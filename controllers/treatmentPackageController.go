@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTreatmentPackageRoutes registers the fixed-price treatment packages, which only admins
+// change, and applying them to patients at the front desk
+func SetupTreatmentPackageRoutes(router *gin.Engine, treatmentPackageHandler *handlers.TreatmentPackageHandler) {
+	router.GET("/treatment_packages", treatmentPackageHandler.GetTreatmentPackages)
+	router.GET("/treatment_packages/:id", treatmentPackageHandler.GetTreatmentPackage)
+
+	router.POST("/patients/:patient_id/treatment_packages", treatmentPackageHandler.ApplyTreatmentPackage)
+
+	router.POST("/treatment_packages", treatmentPackageHandler.CreateTreatmentPackage)
+	router.PUT("/treatment_packages/:id", treatmentPackageHandler.UpdateTreatmentPackage)
+	router.DELETE("/treatment_packages/:id", treatmentPackageHandler.DeleteTreatmentPackage)
+}
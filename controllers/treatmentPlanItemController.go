@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupTreatmentPlanItemRoutes registers the items of treatment plans, which patients accept or
+// decline, and the report of accepted treatment with no appointment booked that the desk works
+// through by calling the patients
+func SetupTreatmentPlanItemRoutes(router *gin.Engine, treatmentPlanItemHandler *handlers.TreatmentPlanItemHandler) {
+	router.POST("/patients/:patient_id/treatment_plans/:treatment_plan_id/items", treatmentPlanItemHandler.CreateTreatmentPlanItem)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/items", treatmentPlanItemHandler.GetTreatmentPlanItems)
+	router.GET("/patients/:patient_id/treatment_plans/:treatment_plan_id/items/:id", treatmentPlanItemHandler.GetTreatmentPlanItem)
+	router.PUT("/patients/:patient_id/treatment_plans/:treatment_plan_id/items/:id", treatmentPlanItemHandler.UpdateTreatmentPlanItem)
+	router.DELETE("/patients/:patient_id/treatment_plans/:treatment_plan_id/items/:id", treatmentPlanItemHandler.DeleteTreatmentPlanItem)
+
+	router.GET("/reports/unscheduled-treatment", treatmentPlanItemHandler.GetUnscheduledTreatment)
+	router.POST("/reports/unscheduled-treatment/tasks", treatmentPlanItemHandler.CreateUnscheduledTreatmentTasks)
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupUserActivityRoutes registers the Admin-only user activity reports used in access reviews
+func SetupUserActivityRoutes(router *gin.Engine, userActivityHandler *handlers.UserActivityHandler) {
+	adminGroup := router.Group("/auth/admin")
+	{
+		adminGroup.GET("/users/:id/activity", userActivityHandler.GetUserActivity)
+	}
+}
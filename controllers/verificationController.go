@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupVerificationRoutes registers the outcomes of external patient checks and their follow-up
+func SetupVerificationRoutes(router *gin.Engine, verificationHandler *handlers.VerificationHandler) {
+	router.GET("/patients/:patient_id/verifications", verificationHandler.GetPatientVerifications)
+	router.POST("/patients/:patient_id/verifications", verificationHandler.RecheckPatient)
+	router.GET("/patient_verifications", verificationHandler.GetOpenVerifications)
+	router.POST("/patient_verifications/:id/resolve", verificationHandler.ResolveVerification)
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupVisitSummaryRoutes registers the visit summary letters staff print or send to patients
+func SetupVisitSummaryRoutes(router *gin.Engine, visitSummaryHandler *handlers.VisitSummaryHandler) {
+	router.GET("/patients/:patient_id/visits/:appointment_id/summary.pdf", visitSummaryHandler.GetVisitSummaryPDF)
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"RoyDental/handlers"
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupWebsiteRoutes registers the public API of the clinic website, authenticated by website keys only
+func SetupWebsiteRoutes(router *gin.Engine, websiteHandler *handlers.WebsiteHandler, websiteKeys []string) {
+	websiteGroup := router.Group("/website").Use(
+		middlewares.WebsiteAuthMiddleware(websiteKeys),
+		middlewares.NewRateLimiterMiddleware(middlewares.RateLimiterConfig{
+			RequestsPerSecond: 5,
+			Burst:             20,
+		}),
+	)
+	{
+		websiteGroup.GET("/doctors", websiteHandler.GetWebsiteDoctors)
+		websiteGroup.GET("/services", websiteHandler.GetWebsiteServices)
+		websiteGroup.POST("/appointment_requests", websiteHandler.RequestAppointment)
+	}
+}
+
+// SetupAppointmentRequestRoutes registers the queue receptionists book website appointment requests from
+func SetupAppointmentRequestRoutes(router *gin.Engine, websiteHandler *handlers.WebsiteHandler) {
+	router.GET("/appointment_requests", websiteHandler.GetAppointmentRequests)
+	router.GET("/appointment_requests/:id", websiteHandler.GetAppointmentRequest)
+	router.POST("/appointment_requests/:id/book", websiteHandler.BookAppointmentRequest)
+	router.POST("/appointment_requests/:id/decline", websiteHandler.DeclineAppointmentRequest)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ChangeSource describes how to read the changes of an entity exposed to sync clients.
+type ChangeSource struct {
+	Table        string
+	IDColumn     string
+	NumericID    bool // The ID column is a serial number rather than text
+	HasCreatedAt bool
+}
+
+// ChangeSources are the entities whose changes can be polled, keyed by their route name.
+var ChangeSources = map[string]ChangeSource{
+	"doctors":             {Table: "doctor", IDColumn: "id", HasCreatedAt: true},
+	"patients":            {Table: "patient", IDColumn: "id", HasCreatedAt: true},
+	"emergency_contacts":  {Table: "emergency_contact", IDColumn: "id", NumericID: true},
+	"insurance_companies": {Table: "insurance_company", IDColumn: "id"},
+	"examinations":        {Table: "examination", IDColumn: "id", NumericID: true, HasCreatedAt: true},
+	"billings":            {Table: "billing", IDColumn: "billing_id", HasCreatedAt: true},
+	"treatment_plans":     {Table: "treatment_plan", IDColumn: "id", NumericID: true, HasCreatedAt: true},
+	"appointments":        {Table: "appointment", IDColumn: "id", NumericID: true, HasCreatedAt: true},
+}
+
+// trackChanges backfills updated_at on existing rows and installs triggers recording a tombstone in
+// deleted_record for every deleted row. Triggers also catch cascades and manual deletes, which
+// repository code would miss. The entity is passed explicitly because on partitioned tables
+// TG_TABLE_NAME names the partition.
+func trackChanges(tx *gorm.DB) error {
+	err := tx.Exec(`CREATE OR REPLACE FUNCTION record_deletion() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO deleted_record (entity, record_id, deleted_at) VALUES (TG_ARGV[0], to_jsonb(OLD) ->> TG_ARGV[1], now());
+	RETURN OLD;
+END
+$$ LANGUAGE plpgsql`).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to create record_deletion function")
+	}
+
+	for _, source := range ChangeSources {
+		backfill := "now()"
+		if source.HasCreatedAt {
+			backfill = "created_at"
+		}
+		if err := tx.Exec(fmt.Sprintf(`UPDATE %q SET updated_at = %s WHERE updated_at IS NULL`, source.Table, backfill)).Error; err != nil {
+			return errors.Wrapf(err, "failed to backfill %s.updated_at", source.Table)
+		}
+
+		trigger := source.Table + "_record_deletion"
+		if err := tx.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS %q ON %q`, trigger, source.Table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop trigger %s", trigger)
+		}
+		err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER %q AFTER DELETE ON %q FOR EACH ROW EXECUTE FUNCTION record_deletion('%s', '%s')`,
+			trigger, source.Table, source.Table, source.IDColumn)).Error
+		if err != nil {
+			return errors.Wrapf(err, "failed to create trigger %s", trigger)
+		}
+	}
+	return nil
+}
+
+// versionRecords gives the records sync clients mirror a sync_version, counting their updates, so a
+// client pushing a change made offline can tell whether the record changed since it last pulled.
+// A trigger bumps it on every update, whichever code path makes it.
+func versionRecords(tx *gorm.DB) error {
+	err := tx.Exec(`CREATE OR REPLACE FUNCTION bump_sync_version() RETURNS trigger AS $$
+BEGIN
+	NEW.sync_version := OLD.sync_version + 1;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to create bump_sync_version function")
+	}
+
+	for _, source := range ChangeSources {
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q ADD COLUMN IF NOT EXISTS sync_version bigint NOT NULL DEFAULT 1`, source.Table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to add %s.sync_version", source.Table)
+		}
+		trigger := source.Table + "_sync_version"
+		if err := tx.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS %q ON %q`, trigger, source.Table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop trigger %s", trigger)
+		}
+		err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER %q BEFORE UPDATE ON %q FOR EACH ROW EXECUTE FUNCTION bump_sync_version()`, trigger, source.Table)).Error
+		if err != nil {
+			return errors.Wrapf(err, "failed to create trigger %s", trigger)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"RoyDental/config"
+	"RoyDental/metrics"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// leaderKey holds the ID of the replica running the scheduled background jobs
+const leaderKey = "background_jobs_leader"
+
+var leaderChanges = metrics.NewCounterVec("background_jobs_leader_changes_total", "Times this replica became or stopped being the one running the scheduled background jobs.", "change")
+
+// election is this replica's campaign to run the scheduled background jobs
+type election struct {
+	id     string
+	config config.LeaderElectionConfig
+	leader atomic.Bool
+
+	mu        sync.Mutex
+	expiresAt time.Time // When the lease last renewed runs out
+	failing   bool
+}
+
+// currentElection is nil until StartLeaderElection, and every replica leads without one
+var currentElection atomic.Pointer[election]
+
+func init() {
+	metrics.NewGaugeFunc("background_jobs_leader", "Whether this replica runs the scheduled background jobs (1=leader, 0=standby).", func() float64 {
+		if IsLeader() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// StartLeaderElection makes the replicas sharing Redis elect one of them to run the scheduled
+// background jobs, so reminders, dunning, archiving and the like run once however many replicas
+// there are. The leader holds a lease in Redis that it renews every config.RenewInterval; the other
+// replicas stand by and the first to find the lease expired takes over. The first campaign runs
+// before returning, so a replica started alone leads before its jobs first run.
+func StartLeaderElection(ctx context.Context, cfg config.LeaderElectionConfig) {
+	if RedisClient == nil {
+		log.Println("Redis is not initialized, every replica runs the scheduled background jobs")
+		return
+	}
+	host, _ := os.Hostname()
+	e := &election{id: host + ":" + uuid.New().String(), config: cfg}
+	currentElection.Store(e)
+
+	e.campaign(ctx)
+	go e.run(ctx)
+}
+
+// IsLeader reports whether this replica runs the scheduled background jobs: it is the elected
+// leader, or no election is held
+func IsLeader() bool {
+	e := currentElection.Load()
+	return e == nil || e.leader.Load()
+}
+
+// ResignLeadership gives up the lease when the replica shuts down, so a standby takes over on its
+// next campaign rather than once the lease expires
+func ResignLeadership(ctx context.Context) {
+	e := currentElection.Load()
+	if e == nil || !e.leader.Load() {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.setLeader(false)
+	if err := ReleaseLock(ctx, leaderKey, e.id); err != nil && !errors.Is(err, ErrNotLockOwner) {
+		log.Printf("Failed to resign leadership of the background jobs: %v", err)
+	}
+}
+
+func (e *election) run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// campaign renews the lease when this replica leads, or takes it when no replica does
+func (e *election) campaign(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.RenewInterval)
+	defer cancel()
+	held, err := e.acquire(ctx)
+	if err != nil {
+		// While Redis is unreachable no other replica can take the lease either, so the leader keeps
+		// leading until its lease runs out and then stands down rather than risk running jobs twice
+		if !e.failing {
+			log.Printf("Failed to renew leadership of the background jobs: %v", err)
+		}
+		e.failing = true
+		if e.leader.Load() && time.Now().After(e.expiresAt) {
+			e.setLeader(false)
+		}
+		return
+	}
+	if e.failing {
+		log.Println("Leader election for the background jobs resumed")
+	}
+	e.failing = false
+	if held {
+		e.expiresAt = time.Now().Add(e.config.Lease)
+	}
+	e.setLeader(held)
+}
+
+// acquire extends the lease when this replica holds it, or else takes it if it is free
+func (e *election) acquire(ctx context.Context) (bool, error) {
+	if !RedisBreaker.Allow() {
+		return false, errors.New("Redis circuit breaker is open")
+	}
+	held, err := ExtendLock(ctx, leaderKey, e.id, e.config.Lease)
+	if err == nil && !held {
+		held, err = NewLock(ctx, leaderKey, e.id, e.config.Lease)
+	}
+	if err != nil {
+		RedisBreaker.Failure()
+		return false, err
+	}
+	RedisBreaker.Success()
+	return held, nil
+}
+
+func (e *election) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		leaderChanges.Inc("acquired")
+		log.Println("This replica now runs the scheduled background jobs")
+	} else {
+		leaderChanges.Inc("lost")
+		log.Println("This replica stopped running the scheduled background jobs")
+	}
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"RoyDental/breaker"
+	"RoyDental/metrics"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// releaseTimeout bounds lock release, which must run even after the request context is cancelled.
+const releaseTimeout = 2 * time.Second
+
+var lockFallbacks = metrics.NewCounterVec("lock_fallbacks_total", "Locks taken with Postgres advisory locks because Redis was unavailable.")
+
+var lockFailures = metrics.NewCounterVec("lock_acquisition_failures_total", "Locks that could not be acquired before the wait deadline.")
+
+// AcquireLock takes the distributed lock for key, retrying while it is busy until the configured
+// lock wait elapses or ctx is done. The returned function releases the lock.
+//
+// While the Redis circuit breaker is open the lock is taken as a Postgres session advisory lock
+// instead, so writes keep their mutual exclusion during a Redis outage.
+func AcquireLock(ctx context.Context, key string) (func(), error) {
+	value := uuid.New().String() // Generate a unique lock value
+	return retryLock(ctx, func(ctx context.Context) (func(), error) {
+		return tryLock(ctx, key, value)
+	})
+}
+
+// retryLock calls try until it takes the lock, while the lock is busy or failing, until the
+// configured lock wait elapses or ctx is done
+func retryLock(ctx context.Context, try func(ctx context.Context) (func(), error)) (func(), error) {
+	waitCtx, cancel := context.WithTimeout(ctx, Timeouts.LockWait)
+	defer cancel()
+
+	var lastErr error
+	for {
+		release, err := try(waitCtx)
+		if err == nil && release != nil {
+			return release, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+
+		timer := time.NewTimer(Timeouts.LockRetryDelay)
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+			if lastErr == nil {
+				lastErr = waitCtx.Err()
+			}
+			lockFailures.Inc()
+			return nil, fmt.Errorf("failed to acquire lock after retries: %w", lastErr)
+		case <-timer.C:
+		}
+	}
+}
+
+// tryLock makes a single acquisition attempt. It returns a nil release function when the lock is busy.
+func tryLock(ctx context.Context, key, value string) (func(), error) {
+	if RedisClient != nil && RedisBreaker.Allow() {
+		locked, err := NewLock(ctx, key, value, Timeouts.LockTTL)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up; that says nothing about Redis health
+			RedisBreaker.Cancel()
+			return nil, err
+		}
+		if err == nil {
+			RedisBreaker.Success()
+			if !locked {
+				return nil, nil
+			}
+			return func() {
+				releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+				defer cancel()
+				if err := ReleaseLock(releaseCtx, key, value); err != nil {
+					log.Printf("Failed to release lock: %v", err)
+				}
+			}, nil
+		}
+		RedisBreaker.Failure()
+		log.Printf("Redis lock failed, falling back to advisory lock: %v", err)
+	}
+
+	release, err := tryAdvisoryLock(ctx, key)
+	if release != nil {
+		lockFallbacks.Inc()
+	}
+	return release, err
+}
+
+// tryAdvisoryLock takes a Postgres session advisory lock on a dedicated connection.
+func tryAdvisoryLock(ctx context.Context, key string) (func(), error) {
+	if DB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sql.DB from GORM: %w", err)
+	}
+
+	// Session advisory locks belong to a connection, so hold one until release
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+
+	return func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+		defer cancel()
+		releaseAdvisoryLock(releaseCtx, conn, key)
+	}, nil
+}
+
+func releaseAdvisoryLock(ctx context.Context, conn *sql.Conn, key string) {
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", key); err != nil {
+		log.Printf("Failed to release advisory lock: %v", err)
+		// Discard the connection so the session, and the lock it holds, ends with it
+		_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+}
+
+// RedisStatus reports the Redis circuit breaker state for readiness checks.
+func RedisStatus() breaker.State {
+	return RedisBreaker.State()
+}
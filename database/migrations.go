@@ -0,0 +1,266 @@
+package database
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// migrationLockID is the advisory lock key that serialises versioned migrations across replicas.
+const migrationLockID = 724_001
+
+// SchemaMigration records a versioned migration that has been applied.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;column:version"`
+	Name      string    `gorm:"column:name;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;autoCreateTime"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migration is a versioned schema change that AutoMigrate cannot express on its own.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// migrations lists every versioned migration in the order it must be applied.
+// Versions are never reused or reordered once released.
+var migrations = []Migration{
+	{Version: 1, Name: "partition_appointment_by_created_at", Up: partitionByCreatedAt("appointment", "id", &models.Appointment{})},
+	{Version: 2, Name: "partition_billing_by_created_at", Up: partitionByCreatedAt("billing", "billing_id", &models.Billing{})},
+	{Version: 3, Name: "allow_checked_in_appointment_status", Up: replaceCheck("appointment", "chk_appointment_status", "status IN ('scheduled', 'checked_in', 'fulfilled', 'cancelled')")},
+	{Version: 4, Name: "track_updates_and_deletions", Up: trackChanges},
+	{Version: 5, Name: "make_controlled_register_append_only", Up: appendOnly("controlled_register")},
+	{Version: 6, Name: "allow_in_progress_appointment_status", Up: replaceCheck("appointment", "chk_appointment_status", "status IN ('scheduled', 'checked_in', 'in_progress', 'fulfilled', 'cancelled')")},
+	{Version: 7, Name: "backfill_appointment_slots", Up: backfillAppointmentSlots},
+	{Version: 8, Name: "store_appointment_times_in_utc", Up: appointmentTimesToUTC},
+	{Version: 9, Name: "split_addresses", Up: splitAddresses},
+	{Version: 10, Name: "backfill_treatment_plan_versions", Up: backfillTreatmentPlanVersions},
+	{Version: 11, Name: "make_audit_archive_batch_append_only", Up: appendOnly("audit_archive_batch")},
+	{Version: 12, Name: "key_appointment_reminders_by_lead", Up: keyAppointmentRemindersByLead},
+	{Version: 13, Name: "allow_case_export_approvals", Up: replaceCheck("approval", "chk_approval_action", "action IN ('patient_deletion', 'billing_discount', 'payroll_reopen', 'case_export')")},
+	{Version: 14, Name: "allow_deferred_messages", Up: replaceCheck("communication_log", "chk_communication_log_status", "status IN ('sent', 'not_permitted', 'failed', 'deferred')")},
+	{Version: 15, Name: "version_synced_records", Up: versionRecords},
+	{Version: 16, Name: "key_primary_emergency_contacts_by_patient", Up: keyPrimaryEmergencyContactsByPatient},
+	{Version: 17, Name: "open_payment_ledger", Up: openPaymentLedger},
+}
+
+// Migrations returns the versioned migrations this build applies, in order.
+func Migrations() []Migration {
+	return migrations
+}
+
+// backfillTreatmentPlanVersions records the treatment plans written before revisions were kept as
+// their first version. Earlier revisions were overwritten and cannot be recovered.
+func backfillTreatmentPlanVersions(tx *gorm.DB) error {
+	err := tx.Exec(`INSERT INTO treatment_plan_version (treatment_plan_id, version, patient_id, plan, estimated_cost, reason, created_at, created_by)
+SELECT tp.id, tp.version, tp.patient_id, tp.plan, tp.estimated_cost, '', tp.updated_at, COALESCE(tp.updated_by, tp.created_by)
+FROM treatment_plan tp
+WHERE NOT EXISTS (SELECT 1 FROM treatment_plan_version v WHERE v.treatment_plan_id = tp.id)`).Error
+	return errors.Wrap(err, "failed to backfill treatment plan versions")
+}
+
+// backfillAppointmentSlots sets starts_at and ends_at on appointments booked before chairs were
+// tracked, so they count against doctor and chair capacity. Only the date and minute of date_time
+// are read, and every existing appointment is given the default 30 minute slot.
+func backfillAppointmentSlots(tx *gorm.DB) error {
+	err := tx.Exec(`UPDATE appointment
+SET starts_at = to_timestamp(left(replace(date_time, 'T', ' '), 16), 'YYYY-MM-DD HH24:MI'),
+	ends_at = to_timestamp(left(replace(date_time, 'T', ' '), 16), 'YYYY-MM-DD HH24:MI') + interval '30 minutes'
+WHERE starts_at IS NULL AND date_time ~ '^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}'`).Error
+	return errors.Wrap(err, "failed to backfill appointment slots")
+}
+
+// appointmentTimesToUTC rewrites the date_time of existing appointments, entered as clinic time
+// without an offset, in UTC, and recomputes starts_at and ends_at from it. The slots backfilled
+// earlier were read in the database's time zone, which need not be the clinic's. The clinic time
+// zone must be set before migrating.
+func appointmentTimesToUTC(tx *gorm.DB) error {
+	type appointmentTime struct {
+		ID       uint
+		DateTime string
+		StartsAt *time.Time
+		EndsAt   *time.Time
+	}
+
+	var rows []appointmentTime
+	err := tx.Table("appointment").Select("id, date_time, starts_at, ends_at").
+		Where(`date_time ~ '^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}'`).
+		FindInBatches(&rows, 500, func(batch *gorm.DB, _ int) error {
+			for _, row := range rows {
+				start, ok := models.ParseAppointmentTime(row.DateTime)
+				if !ok {
+					continue
+				}
+				// Walk-ins have no booked end, and booked appointments keep the length of their slot
+				start = start.UTC()
+				var end *time.Time
+				if row.StartsAt != nil && row.EndsAt != nil {
+					t := start.Add(row.EndsAt.Sub(*row.StartsAt))
+					end = &t
+				}
+				err := tx.Table("appointment").Where("id = ?", row.ID).
+					Updates(map[string]interface{}{"date_time": models.StoredAppointmentTime(start), "starts_at": start, "ends_at": end}).Error
+				if err != nil {
+					return errors.Wrapf(err, "failed to convert the time of appointment %d", row.ID)
+				}
+			}
+			return nil
+		}).Error
+	return errors.Wrap(err, "failed to store appointment times in UTC")
+}
+
+// splitAddresses moves the free-text addresses into the street, city, county and postal code
+// columns and drops the old ones. A comma-separated address is taken to end with its city, and
+// the county and postal code are left for staff to fill in.
+func splitAddresses(tx *gorm.DB) error {
+	for _, column := range []struct{ table, from, prefix string }{
+		{"patient", "address", "address_"},
+		{"pending_registration", "address", "address_"},
+		{"patient_contact_update", "address", "address_"},
+		{"hl7_message", "pid_address", "pid_address_"},
+	} {
+		if !tx.Migrator().HasColumn(column.table, column.from) {
+			continue
+		}
+		err := tx.Exec(fmt.Sprintf(`UPDATE %[1]q SET
+	%[3]q = CASE WHEN %[2]q LIKE '%%,%%' THEN trim(regexp_replace(%[2]q, ',[^,]*$', '')) ELSE trim(%[2]q) END,
+	%[4]q = CASE WHEN %[2]q LIKE '%%,%%' THEN trim(regexp_replace(%[2]q, '^.*,', '')) ELSE '' END
+WHERE trim(COALESCE(%[2]q, '')) <> ''`, column.table, column.from, column.prefix+"street", column.prefix+"city")).Error
+		if err != nil {
+			return errors.Wrapf(err, "failed to split %s.%s", column.table, column.from)
+		}
+		if err := tx.Migrator().DropColumn(column.table, column.from); err != nil {
+			return errors.Wrapf(err, "failed to drop %s.%s", column.table, column.from)
+		}
+	}
+	return nil
+}
+
+// keyAppointmentRemindersByLead keys the reminders of an appointment by their lead, now that an
+// appointment may have several. Reminders sent while there was only one are taken to have been sent
+// the configured lead ahead, so they are not sent again.
+func keyAppointmentRemindersByLead(tx *gorm.DB) error {
+	lead := int(config.LoadAppointmentReminderConfig().Lead / time.Minute)
+	if err := tx.Exec(`UPDATE appointment_reminder SET lead_minutes = ? WHERE lead_minutes = 0`, lead).Error; err != nil {
+		return errors.Wrap(err, "failed to set the lead of sent reminders")
+	}
+	err := tx.Exec(`ALTER TABLE appointment_reminder DROP CONSTRAINT IF EXISTS appointment_reminder_pkey,
+	ADD PRIMARY KEY (appointment_id, lead_minutes)`).Error
+	return errors.Wrap(err, "failed to key appointment reminders by lead")
+}
+
+// keyPrimaryEmergencyContactsByPatient rebuilds the index allowing one primary emergency contact,
+// which was first made over the whole table instead of per patient. AutoMigrate keeps an index
+// whose name it already finds, so the index is replaced here.
+func keyPrimaryEmergencyContactsByPatient(tx *gorm.DB) error {
+	if err := tx.Exec(`DROP INDEX IF EXISTS idx_emergency_contact_primary`).Error; err != nil {
+		return errors.Wrap(err, "failed to drop the primary emergency contact index")
+	}
+	err := tx.Exec(`CREATE UNIQUE INDEX idx_emergency_contact_primary ON emergency_contact (patient_id) WHERE is_primary`).Error
+	return errors.Wrap(err, "failed to index primary emergency contacts by patient")
+}
+
+// openPaymentLedger records what each bill had received before payments were kept as one payment
+// per method, less the online payments already in their own table, so the ledger starts out
+// agreeing with the bills and the integrity checks catch drift from then on.
+func openPaymentLedger(tx *gorm.DB) error {
+	err := tx.Exec(`INSERT INTO payment (billing_id, method, amount, created_at)
+		SELECT billing_id, method, amount, created_at FROM (
+			SELECT billing_id, 'cash' AS method, created_at, paid_cash_amount - (SELECT COALESCE(SUM(amount), 0) FROM online_payment
+				WHERE online_payment.billing_id = billing.billing_id AND status = 'succeeded') AS amount FROM billing
+			UNION ALL
+			SELECT billing_id, 'insurance', created_at, paid_insurance_amount FROM billing
+		) AS received WHERE ABS(amount) >= 0.005`).Error
+	return errors.Wrap(err, "failed to open the payment ledger")
+}
+
+// appendOnly installs triggers rejecting updates, deletions and truncation of table, so rows can
+// only ever be added, whatever the application or a manual query attempts.
+func appendOnly(table string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		err := tx.Exec(`CREATE OR REPLACE FUNCTION reject_append_only_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END
+$$ LANGUAGE plpgsql`).Error
+		if err != nil {
+			return errors.Wrap(err, "failed to create reject_append_only_change function")
+		}
+
+		rowTrigger := table + "_append_only"
+		if err := tx.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS %q ON %q`, rowTrigger, table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop trigger %s", rowTrigger)
+		}
+		if err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER %q BEFORE UPDATE OR DELETE ON %q FOR EACH ROW EXECUTE FUNCTION reject_append_only_change()`, rowTrigger, table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to create trigger %s", rowTrigger)
+		}
+
+		truncateTrigger := table + "_no_truncate"
+		if err := tx.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS %q ON %q`, truncateTrigger, table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop trigger %s", truncateTrigger)
+		}
+		if err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER %q BEFORE TRUNCATE ON %q FOR EACH STATEMENT EXECUTE FUNCTION reject_append_only_change()`, truncateTrigger, table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to create trigger %s", truncateTrigger)
+		}
+		return nil
+	}
+}
+
+// replaceCheck swaps a check constraint for a new definition. AutoMigrate only creates missing
+// constraints and never alters an existing one.
+func replaceCheck(table, name, expr string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q DROP CONSTRAINT IF EXISTS %q`, table, name)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop constraint %s", name)
+		}
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q ADD CONSTRAINT %q CHECK (%s)`, table, name, expr)).Error; err != nil {
+			return errors.Wrapf(err, "failed to add constraint %s", name)
+		}
+		return nil
+	}
+}
+
+// runVersionedMigrations applies pending migrations after AutoMigrate, each in its own transaction.
+func runVersionedMigrations() error {
+	if err := DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		return errors.Wrap(err, "failed to migrate schema_migrations table")
+	}
+
+	for _, m := range migrations {
+		m := m
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			// Only one replica may apply migrations at a time
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+				return errors.Wrap(err, "failed to acquire migration lock")
+			}
+
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return errors.Wrap(err, "failed to check migration state")
+			}
+			if count > 0 {
+				return nil
+			}
+
+			log.Printf("Applying migration %d: %s", m.Version, m.Name)
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name}).Error
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply migration %d (%s)", m.Version, m.Name)
+		}
+	}
+	return nil
+}
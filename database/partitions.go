@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// PartitionMonthsAhead is how many future monthly partitions are kept ready for billing and appointment.
+const PartitionMonthsAhead = 3
+
+// partitionedTables lists the tables range-partitioned by month on created_at.
+var partitionedTables = []string{"appointment", "billing"}
+
+// partitionByCreatedAt converts an existing table into a table range-partitioned by month on created_at.
+//
+// Data movement plan for existing rows:
+//  1. the live table and its indexes are renamed to *_legacy so names are free for the new table;
+//  2. a partitioned table with the same columns, defaults and checks is created under the old name,
+//     keyed on (key, created_at) because Postgres requires the partition key in the primary key;
+//  3. monthly partitions covering the oldest row up to PartitionMonthsAhead months from now are
+//     created, plus a default partition as a safety net for out-of-range timestamps, whose rows
+//     move to their month's partition once maintenance creates it;
+//  4. rows are copied in a single INSERT ... SELECT inside the migration transaction, so readers
+//     see either the old or the new table and never a half-moved state;
+//  5. sequences owned by the legacy table are re-owned by the new table before the legacy table
+//     is dropped, and AutoMigrate recreates the model's indexes and foreign keys.
+//
+// The copy holds an exclusive lock on the table for its duration; run it in a maintenance window
+// on large installations.
+func partitionByCreatedAt(table, key string, model interface{}) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		var partitioned bool
+		if err := tx.Raw(`SELECT EXISTS (
+			SELECT 1 FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid
+			WHERE c.relname = ? AND c.relnamespace = current_schema()::regnamespace)`, table).Scan(&partitioned).Error; err != nil {
+			return errors.Wrapf(err, "failed to inspect %s", table)
+		}
+		if partitioned {
+			return nil
+		}
+
+		legacy := table + "_legacy"
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, table, legacy)).Error; err != nil {
+			return errors.Wrapf(err, "failed to rename %s", table)
+		}
+
+		// Free index names (including the primary key) for the new table
+		var indexes []string
+		if err := tx.Raw("SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ?", legacy).
+			Scan(&indexes).Error; err != nil {
+			return errors.Wrapf(err, "failed to list indexes of %s", legacy)
+		}
+		for _, index := range indexes {
+			if err := tx.Exec(fmt.Sprintf(`ALTER INDEX %q RENAME TO %q`, index, index+"_legacy")).Error; err != nil {
+				return errors.Wrapf(err, "failed to rename index %s", index)
+			}
+		}
+
+		stmts := []string{
+			fmt.Sprintf(`UPDATE %q SET created_at = now() WHERE created_at IS NULL`, legacy),
+			fmt.Sprintf(`CREATE TABLE %q (LIKE %q INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (created_at)`, table, legacy),
+			fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN created_at SET NOT NULL, ALTER COLUMN created_at SET DEFAULT now()`, table),
+			fmt.Sprintf(`ALTER TABLE %q ADD PRIMARY KEY (%q, created_at)`, table, key),
+		}
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return errors.Wrapf(err, "failed to create partitioned %s", table)
+			}
+		}
+
+		// Cover every month that already holds data
+		var oldest *time.Time
+		if err := tx.Raw(fmt.Sprintf(`SELECT min(created_at) FROM %q`, legacy)).Scan(&oldest).Error; err != nil {
+			return errors.Wrapf(err, "failed to find oldest row of %s", legacy)
+		}
+		from := time.Now().UTC()
+		if oldest != nil && oldest.Before(from) {
+			from = oldest.UTC()
+		}
+		if err := createMonthlyPartitions(tx, table, from, time.Now().UTC().AddDate(0, PartitionMonthsAhead, 0)); err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(`CREATE TABLE %q PARTITION OF %q DEFAULT`, table+"_default", table)).Error; err != nil {
+			return errors.Wrapf(err, "failed to create default partition of %s", table)
+		}
+
+		if err := tx.Exec(fmt.Sprintf(`INSERT INTO %q SELECT * FROM %q`, table, legacy)).Error; err != nil {
+			return errors.Wrapf(err, "failed to move rows into partitioned %s", table)
+		}
+
+		// Keep serial sequences alive when the legacy table is dropped
+		var sequence *string
+		if err := tx.Raw("SELECT pg_get_serial_sequence(?, ?)", legacy, key).Scan(&sequence).Error; err != nil {
+			return errors.Wrapf(err, "failed to inspect sequence of %s", legacy)
+		}
+		if sequence != nil && *sequence != "" {
+			if err := tx.Exec(fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY %q.%q`, *sequence, table, key)).Error; err != nil {
+				return errors.Wrapf(err, "failed to re-own sequence %s", *sequence)
+			}
+		}
+
+		if err := tx.Exec(fmt.Sprintf(`DROP TABLE %q`, legacy)).Error; err != nil {
+			return errors.Wrapf(err, "failed to drop %s", legacy)
+		}
+
+		// Recreate the model's indexes, composite indexes and foreign keys on the partitioned table
+		return tx.AutoMigrate(model)
+	}
+}
+
+// createMonthlyPartitions creates one partition per calendar month between from and to, inclusive.
+func createMonthlyPartitions(tx *gorm.DB, table string, from, to time.Time) error {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		next := month.AddDate(0, 1, 0)
+		if err := tx.Transaction(func(tx *gorm.DB) error {
+			return createMonthlyPartition(tx, table, month, next)
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create %s partition for %s", table, month.Format("2006-01"))
+		}
+		month = next
+	}
+	return nil
+}
+
+// createMonthlyPartition creates the partition of table for [month, next) unless it exists.
+//
+// Postgres refuses to create a partition for a range the default partition already holds rows
+// of, which it does once rows were written for a month before its partition was created: rows
+// dated further ahead than the partitions kept ready, or every row of a month that maintenance
+// missed. The default partition is then detached while those rows move to the new partition and
+// attached again, all in the caller's transaction.
+func createMonthlyPartition(tx *gorm.DB, table string, month, next time.Time) error {
+	partition := fmt.Sprintf("%s_p%s", table, month.Format("2006_01"))
+	defaultPartition := table + "_default"
+	var exists, hasDefault bool
+	if err := tx.Raw("SELECT to_regclass(?) IS NOT NULL, to_regclass(?) IS NOT NULL", quoteIdent(partition), quoteIdent(defaultPartition)).
+		Row().Scan(&exists, &hasDefault); err != nil {
+		return errors.Wrapf(err, "failed to inspect partitions of %s", table)
+	}
+	if exists {
+		return nil
+	}
+
+	var stranded bool
+	if hasDefault {
+		if err := tx.Raw(fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %q WHERE created_at >= ? AND created_at < ?)`, defaultPartition), month, next).
+			Scan(&stranded).Error; err != nil {
+			return errors.Wrapf(err, "failed to inspect %s", defaultPartition)
+		}
+	}
+
+	create := fmt.Sprintf(`CREATE TABLE %q PARTITION OF %q FOR VALUES FROM ('%s') TO ('%s')`,
+		partition, table, month.Format(time.RFC3339), next.Format(time.RFC3339))
+	if !stranded {
+		return tx.Exec(create).Error
+	}
+	stmts := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{sql: fmt.Sprintf(`ALTER TABLE %q DETACH PARTITION %q`, table, defaultPartition)},
+		{sql: create},
+		// With the default partition detached the rows are routed to the new partition
+		{sql: fmt.Sprintf(`INSERT INTO %q SELECT * FROM %q WHERE created_at >= ? AND created_at < ?`, table, defaultPartition), args: []interface{}{month, next}},
+		{sql: fmt.Sprintf(`DELETE FROM %q WHERE created_at >= ? AND created_at < ?`, defaultPartition), args: []interface{}{month, next}},
+		{sql: fmt.Sprintf(`ALTER TABLE %q ATTACH PARTITION %q DEFAULT`, table, defaultPartition)},
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(stmt.sql, stmt.args...).Error; err != nil {
+			return errors.Wrapf(err, "failed to move rows of %s out of %s", partition, defaultPartition)
+		}
+	}
+	log.Printf("Moved the rows of %s out of %s into their new partition", month.Format("2006-01"), defaultPartition)
+	return nil
+}
+
+// quoteIdent quotes a table name for to_regclass
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// EnsurePartitions creates any missing monthly partitions up to PartitionMonthsAhead months from now.
+func EnsurePartitions(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, table := range partitionedTables {
+		if err := createMonthlyPartitions(DB.WithContext(ctx), table, now, now.AddDate(0, PartitionMonthsAhead, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaintainPartitions keeps future partitions in place until the context is cancelled.
+func MaintainPartitions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !IsLeader() {
+				continue
+			}
+			if err := EnsurePartitions(ctx); err != nil {
+				log.Printf("Failed to maintain partitions: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import "RoyDental/metrics"
+
+func init() {
+	metrics.NewGaugeFunc("db_pool_usage", "Share of the database connection limit in use.", PoolUsage)
+}
+
+// PoolUsage returns the share of the open connection limit in use, 0 before the database is
+// connected. A pool near 1 makes new queries wait for a connection.
+func PoolUsage() float64 {
+	if DB == nil {
+		return 0
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return 0
+	}
+	stats := sqlDB.Stats()
+	if stats.MaxOpenConnections <= 0 {
+		return 0
+	}
+	return float64(stats.InUse) / float64(stats.MaxOpenConnections)
+}
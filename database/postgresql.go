@@ -51,6 +51,16 @@ func InitDB(ctx context.Context, dsn string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// Apply versioned migrations that AutoMigrate cannot express
+	if err := runVersionedMigrations(); err != nil {
+		return nil, err
+	}
+
+	// Make sure upcoming monthly partitions exist
+	if err := EnsurePartitions(ctx); err != nil {
+		return nil, err
+	}
+
 	// Seed initial data
 	if err := seedInitialData(); err != nil {
 		return nil, err
@@ -60,6 +70,17 @@ func InitDB(ctx context.Context, dsn string) (*gorm.DB, error) {
 	return DB, nil
 }
 
+// CloseDB closes the database connection pool, e.g. after a failed initialization attempt.
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	DB = nil
+}
+
 // configureConnectionPool sets up the connection pool settings for the database.
 func configureConnectionPool() error {
 	sqlDB, err := DB.DB()
@@ -86,7 +107,12 @@ func testDatabaseConnection(ctx context.Context) error {
 
 // runMigrations performs database schema migrations.
 func runMigrations() error {
-	return DB.AutoMigrate(
+	return DB.AutoMigrate(SchemaModels()...)
+}
+
+// SchemaModels returns the models AutoMigrate keeps the schema of, in the order it migrates them.
+func SchemaModels() []interface{} {
+	return []interface{}{
 		&models.Role{},
 		&models.Permission{},
 		&models.RolePermission{},
@@ -99,7 +125,116 @@ func runMigrations() error {
 		&models.Billing{},
 		&models.TreatmentPlan{},
 		&models.Appointment{},
-	)
+		&models.AuditLog{},
+		&models.PatientContactUpdate{},
+		&models.Task{},
+		&models.PatientNote{},
+		&models.Survey{},
+		&models.AnalyticsAggregate{},
+		&models.DeletedRecord{},
+		&models.ExportJob{},
+		&models.Procedure{},
+		&models.DoctorProcedure{},
+		&models.ExaminationAttachment{},
+		&models.ImagingStudy{},
+		&models.HL7PatientLink{},
+		&models.HL7Message{},
+		&models.PatientAllergy{},
+		&models.DrugInteraction{},
+		&models.Prescription{},
+		&models.ControlledSubstance{},
+		&models.ControlledRegisterEntry{},
+		&models.Vitals{},
+		&models.Checklist{},
+		&models.ChecklistCompletion{},
+		&models.SterilizationBatch{},
+		&models.BatchUsage{},
+		&models.ContractRate{},
+		&models.InsuranceClaim{},
+		&models.ClaimBatch{},
+		&models.PaymentPlan{},
+		&models.PaymentInstallment{},
+		&models.Room{},
+		&models.Chair{},
+		&models.PendingRegistration{},
+		&models.PatientVerification{},
+		&models.FinancialPeriod{},
+		&models.BillingAdjustment{},
+		&models.Setting{},
+		&models.Closure{},
+		&models.CommunicationPreference{},
+		&models.ConsentRecord{},
+		&models.Greeting{},
+		&models.TreatmentPlanVersion{},
+		&models.ClinicalTemplate{},
+		&models.EmergencySlot{},
+		&models.OnlinePayment{},
+		&models.Payment{},
+		&models.CareRule{},
+		&models.Recall{},
+		&models.CommunicationLog{},
+		&models.PostOpInstruction{},
+		&models.CustomField{},
+		&models.SavedFilter{},
+		&models.BillingDispute{},
+		&models.DunningStage{},
+		&models.DunningNotice{},
+		&models.PatientAlert{},
+		&models.PatientAlertAcknowledgement{},
+		&models.ReferralTemplate{},
+		&models.Referral{},
+		&models.ReferralExamination{},
+		&models.ReferralImaging{},
+		&models.Shift{},
+		&models.ShiftSwap{},
+		&models.LeaveRequest{},
+		&models.CommissionRate{},
+		&models.PayrollPeriod{},
+		&models.PayrollLine{},
+		&models.Downtime{},
+		&models.MaterialUsage{},
+		&models.DocumentShare{},
+		&models.SharedDocument{},
+		&models.DocumentShareAccess{},
+		&models.Approval{},
+		&models.IDCounter{},
+		&models.IntegrityRun{},
+		&models.IntegrityIssue{},
+		&models.ReportToken{},
+		&models.AuditArchiveBatch{},
+		&models.Household{},
+		&models.HouseholdMember{},
+		&models.AppointmentReminder{},
+		&models.TreatmentPackage{},
+		&models.TreatmentPackageItem{},
+		&models.EmailSuppression{},
+		&models.PrintJob{},
+		&models.CaseImagePair{},
+		&models.EligibilityCheck{},
+		&models.RetentionRun{},
+		&models.APIUsage{},
+		&models.APIUsageBucket{},
+		&models.SMSReply{},
+		&models.DailySummaryRun{},
+		&models.CredentialReminder{},
+		&models.ExaminationAudioNote{},
+		&models.AppointmentRequest{},
+		&models.InsurerAccount{},
+		&models.InsurerAccess{},
+		&models.DeferredMessage{},
+		&models.TreatmentPlanItem{},
+		&models.HandoverNote{},
+		&models.HandoverRead{},
+		&models.RecordAccess{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+		&models.BreakGlassAccess{},
+		&models.ClinicalAudit{},
+		&models.ClinicalAuditItem{},
+		&models.QuarantinedFile{},
+		&models.FailedDelivery{},
+		&models.SIEMCursor{},
+	}
 }
 
 // seedInitialData populates the database with initial data.
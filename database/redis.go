@@ -1,26 +1,54 @@
 package database
 
 import (
+	"RoyDental/breaker"
+	"RoyDental/metrics"
 	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-var RedisClient *redis.Client
+// RedisClient is a single-node, Sentinel-backed or Cluster client depending on REDIS_MODE.
+var RedisClient redis.UniversalClient
+
+// Redis deployment modes accepted in REDIS_MODE.
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// RedisBreaker trips when Redis keeps failing so callers can fall back to the database.
+var RedisBreaker = breaker.New("redis", 5, 30*time.Second)
+
+func init() {
+	metrics.NewGaugeFunc("redis_circuit_breaker_state", "State of the Redis circuit breaker (0=closed, 1=half-open, 2=open).", func() float64 {
+		return float64(RedisBreaker.State())
+	})
+}
 
 type RedisConfig struct {
-	URL          string
-	PoolSize     int
-	DialTimeout  time.Duration
-	MinIdleConns int
-	ReadTimeout  time.Duration
-	MaxRetries   int
+	Mode             string
+	URL              string   // Single mode only
+	Addrs            []string // Sentinel addresses in sentinel mode, seed nodes in cluster mode
+	MasterName       string   // Sentinel mode only
+	Username         string
+	Password         string
+	SentinelPassword string
+	PoolSize         int
+	DialTimeout      time.Duration
+	MinIdleConns     int
+	ReadTimeout      time.Duration
+	MaxRetries       int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 // InitializeRedis initializes the Redis client lazily
@@ -34,6 +62,7 @@ func InitializeRedis() error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize Redis client: %w", err)
 	}
+	RedisBreaker.Configure(config.BreakerThreshold, config.BreakerCooldown)
 
 	log.Println("Redis connection initialized successfully.")
 	return nil
@@ -41,24 +70,59 @@ func InitializeRedis() error {
 
 // LoadRedisConfig loads configuration from environment variables with default fallbacks
 func LoadRedisConfig() (RedisConfig, error) {
+	mode := strings.ToLower(os.Getenv("REDIS_MODE"))
+	if mode == "" {
+		mode = RedisModeSingle
+	}
 	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		return RedisConfig{}, errors.New("REDIS_URL environment variable is not set")
+	var addrs []string
+	for _, addr := range strings.Split(os.Getenv("REDIS_ADDRS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	masterName := os.Getenv("REDIS_MASTER_NAME")
+
+	switch mode {
+	case RedisModeSingle:
+		if redisURL == "" {
+			return RedisConfig{}, errors.New("REDIS_URL environment variable is not set")
+		}
+	case RedisModeSentinel:
+		if len(addrs) == 0 || masterName == "" {
+			return RedisConfig{}, errors.New("REDIS_ADDRS and REDIS_MASTER_NAME must be set in sentinel mode")
+		}
+	case RedisModeCluster:
+		if len(addrs) == 0 {
+			return RedisConfig{}, errors.New("REDIS_ADDRS must be set in cluster mode")
+		}
+	default:
+		return RedisConfig{}, fmt.Errorf("unknown REDIS_MODE %q", mode)
 	}
 
 	poolSize := getEnvAsInt("REDIS_POOL_SIZE", 10) // Default: 10
 	dialTimeout := getEnvAsDuration("REDIS_DIAL_TIMEOUT", 30*time.Second)
 	minIdleConns := getEnvAsInt("REDIS_MIN_IDLE_CONNS", 5) // Default: 5
 	readTimeout := getEnvAsDuration("REDIS_READ_TIMEOUT", 10*time.Second)
-	maxRetries := getEnvAsInt("REDIS_MAX_RETRIES", 3) // Default: 3
+	maxRetries := getEnvAsInt("REDIS_MAX_RETRIES", 3)             // Default: 3
+	breakerThreshold := getEnvAsInt("REDIS_BREAKER_THRESHOLD", 5) // Default: 5 consecutive failures
+	breakerCooldown := getEnvAsDuration("REDIS_BREAKER_COOLDOWN", 30*time.Second)
 
 	return RedisConfig{
-		URL:          redisURL,
-		PoolSize:     poolSize,
-		DialTimeout:  dialTimeout,
-		MinIdleConns: minIdleConns,
-		ReadTimeout:  readTimeout,
-		MaxRetries:   maxRetries,
+		Mode:             mode,
+		URL:              redisURL,
+		Addrs:            addrs,
+		MasterName:       masterName,
+		Username:         os.Getenv("REDIS_USERNAME"),
+		Password:         os.Getenv("REDIS_PASSWORD"),
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		PoolSize:         poolSize,
+		DialTimeout:      dialTimeout,
+		MinIdleConns:     minIdleConns,
+		ReadTimeout:      readTimeout,
+		MaxRetries:       maxRetries,
+		BreakerThreshold: breakerThreshold,
+		BreakerCooldown:  breakerCooldown,
 	}, nil
 }
 
@@ -82,34 +146,71 @@ func getEnvAsDuration(name string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
-// NewRedisClient creates a Redis client with the provided configuration
-func NewRedisClient(config RedisConfig) (*redis.Client, error) {
-	opt, err := redis.ParseURL(config.URL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
-	}
+// NewRedisClient creates a Redis client for the configured mode
+func NewRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	var client redis.UniversalClient
+	switch config.Mode {
+	case RedisModeSentinel:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.MasterName,
+			SentinelAddrs:    config.Addrs,
+			SentinelPassword: config.SentinelPassword,
+			Username:         config.Username,
+			Password:         config.Password,
+			PoolSize:         config.PoolSize,
+			MinIdleConns:     config.MinIdleConns,
+			DialTimeout:      config.DialTimeout,
+			ReadTimeout:      config.ReadTimeout,
+			MaxRetries:       config.MaxRetries,
+		})
+	case RedisModeCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        config.Addrs,
+			Username:     config.Username,
+			Password:     config.Password,
+			PoolSize:     config.PoolSize,
+			MinIdleConns: config.MinIdleConns,
+			DialTimeout:  config.DialTimeout,
+			ReadTimeout:  config.ReadTimeout,
+			MaxRetries:   config.MaxRetries,
+		})
+	default:
+		opt, err := redis.ParseURL(config.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
 
-	opt.PoolSize = config.PoolSize
-	opt.MinIdleConns = config.MinIdleConns
-	opt.DialTimeout = config.DialTimeout
-	opt.ReadTimeout = config.ReadTimeout
-	opt.MaxRetries = config.MaxRetries
+		opt.PoolSize = config.PoolSize
+		opt.MinIdleConns = config.MinIdleConns
+		opt.DialTimeout = config.DialTimeout
+		opt.ReadTimeout = config.ReadTimeout
+		opt.MaxRetries = config.MaxRetries
+		if config.Username != "" {
+			opt.Username = config.Username
+		}
+		if config.Password != "" {
+			opt.Password = config.Password
+		}
 
-	client := redis.NewClient(opt)
+		client = redis.NewClient(opt)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to ping Redis server: %w", err)
 	}
 
-	log.Printf("Redis client initialized with configuration: PoolSize=%d, MinIdleConns=%d, DialTimeout=%s, ReadTimeout=%s, MaxRetries=%d",
-		config.PoolSize, config.MinIdleConns, config.DialTimeout.String(), config.ReadTimeout.String(), config.MaxRetries)
+	log.Printf("Redis client initialized with configuration: Mode=%s, PoolSize=%d, MinIdleConns=%d, DialTimeout=%s, ReadTimeout=%s, MaxRetries=%d",
+		config.Mode, config.PoolSize, config.MinIdleConns, config.DialTimeout.String(), config.ReadTimeout.String(), config.MaxRetries)
 	return client, nil
 }
 
-// NewLock acquires a distributed lock using Redis
+// NewLock acquires a distributed lock using Redis.
+// Lock commands touch a single key, so they route to one shard in cluster mode and to the
+// current master behind Sentinel.
 func NewLock(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
 	if RedisClient == nil {
 		return false, errors.New("Redis client is not initialized")
@@ -118,6 +219,9 @@ func NewLock(ctx context.Context, key string, value string, ttl time.Duration) (
 	return RedisClient.SetNX(ctx, key, value, ttl).Result()
 }
 
+// ErrNotLockOwner is returned when releasing a lock that expired or is held by someone else
+var ErrNotLockOwner = errors.New("lock release failed: not the lock owner")
+
 // ReleaseLock releases a distributed lock using Redis with Lua scripting
 func ReleaseLock(ctx context.Context, key string, value string) error {
 	if RedisClient == nil {
@@ -138,11 +242,33 @@ func ReleaseLock(ctx context.Context, key string, value string) error {
 		return fmt.Errorf("failed to release lock: %w", err)
 	}
 	if result.(int64) == 0 {
-		return errors.New("lock release failed: not the lock owner")
+		return ErrNotLockOwner
 	}
 	return nil
 }
 
+// ExtendLock resets the expiry of a lock still held with value and reports whether it was
+func ExtendLock(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return false, errors.New("Redis client is not initialized")
+	}
+
+	const extendLockScript = `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	else
+		return 0
+	end
+	`
+
+	script := redis.NewScript(extendLockScript)
+	result, err := script.Run(ctx, RedisClient, []string{key}, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("failed to extend lock: %w", err)
+	}
+	return result == 1, nil
+}
+
 // MonitorRedisPool logs the connection pool statistics for monitoring
 func MonitorRedisPool(ctx context.Context) {
 	stats := RedisClient.PoolStats()
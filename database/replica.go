@@ -0,0 +1,94 @@
+package database
+
+import (
+	"RoyDental/models"
+	"context"
+	"log"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Replica is the read replica reads fail over to while the primary is unreachable, nil when none is
+// configured.
+var Replica *gorm.DB
+
+// readOnly is the server's read-only status, kept up to date by the read-only monitor.
+var readOnly atomic.Pointer[models.ReadOnlyStatus]
+
+// InitReplica connects to the read replica and routes the primary's reads to it while the read-only
+// status says so. A replica that cannot be reached yet is only logged; the monitor keeps probing it.
+func InitReplica(ctx context.Context, dsn string) error {
+	replica, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		PrepareStmt:          true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to open replica connection")
+	}
+	Replica = replica
+	if err := PingReplica(ctx); err != nil {
+		log.Printf("Read replica is not reachable yet: %v", err)
+	}
+
+	if err := DB.Callback().Query().Before("gorm:query").Register("roydental:replica_reads", routeReadsToReplica); err != nil {
+		return errors.Wrap(err, "failed to register replica reads")
+	}
+	if err := DB.Callback().Row().Before("gorm:row").Register("roydental:replica_rows", routeReadsToReplica); err != nil {
+		return errors.Wrap(err, "failed to register replica reads")
+	}
+	return nil
+}
+
+// routeReadsToReplica sends a query outside a transaction to the replica while reads have failed
+// over. Writes and transactions stay on the primary, which refuses them while it is down.
+func routeReadsToReplica(db *gorm.DB) {
+	if Replica == nil || !ReadOnlyStatus().ReadsFromReplica {
+		return
+	}
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+		return
+	}
+	db.Statement.ConnPool = Replica.ConnPool
+}
+
+// PingPrimary checks that the primary database answers.
+func PingPrimary(ctx context.Context) error {
+	return ping(ctx, DB)
+}
+
+// PingReplica checks that the read replica answers.
+func PingReplica(ctx context.Context) error {
+	return ping(ctx, Replica)
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	if db == nil {
+		return errors.New("database is not initialized")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := WithReadTimeout(ctx)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// SetReadOnlyStatus records whether the server refuses writes and reads from the replica.
+func SetReadOnlyStatus(status models.ReadOnlyStatus) {
+	readOnly.Store(&status)
+}
+
+// ReadOnlyStatus returns the server's read-only status. Until the monitor first runs, the server
+// writes to and reads from the primary.
+func ReadOnlyStatus() models.ReadOnlyStatus {
+	if status := readOnly.Load(); status != nil {
+		return *status
+	}
+	return models.ReadOnlyStatus{PrimaryHealthy: true}
+}
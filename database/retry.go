@@ -0,0 +1,37 @@
+package database
+
+import (
+	"RoyDental/config"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RetryWithBackoff calls fn until it succeeds, the configured attempts are used up or ctx is done.
+// The delay between attempts doubles from RetryBackoff up to RetryMaxBackoff.
+func RetryWithBackoff(ctx context.Context, name string, cfg config.StartupConfig, fn func(ctx context.Context) error) error {
+	delay := cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if cfg.RetryAttempts > 0 && attempt >= cfg.RetryAttempts {
+			return fmt.Errorf("%s unavailable after %d attempts: %w", name, attempt, err)
+		}
+		log.Printf("%s unavailable (attempt %d), retrying in %s: %v", name, attempt, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up waiting for %s: %w", name, ctx.Err())
+		case <-timer.C:
+		}
+
+		if delay *= 2; delay > cfg.RetryMaxBackoff {
+			delay = cfg.RetryMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"RoyDental/config"
+	"context"
+)
+
+// Timeouts holds the per-operation deadlines used by repositories and locks.
+var Timeouts = config.DefaultTimeoutConfig()
+
+// SetTimeouts replaces the per-operation deadlines, typically once at startup.
+func SetTimeouts(timeouts config.TimeoutConfig) {
+	Timeouts = timeouts
+}
+
+// WithReadTimeout derives a context bounded by the configured read deadline.
+func WithReadTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, Timeouts.DBRead)
+}
+
+// WithWriteTimeout derives a context bounded by the configured write deadline.
+func WithWriteTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, Timeouts.DBWrite)
+}
@@ -0,0 +1,72 @@
+// Package debuglog records scrubbed request and response bodies while an Admin has debug logging
+// switched on, to reproduce client-reported data issues.
+package debuglog
+
+import (
+	"RoyDental/config"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is one request with its response, as written to the log
+type Entry struct {
+	Time         time.Time `json:"time"`
+	RequestID    string    `json:"request_id,omitempty"`
+	UserID       string    `json:"user_id,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Query        string    `json:"query,omitempty"`
+	Status       int       `json:"status"`
+	DurationMS   int64     `json:"duration_ms"`
+	RequestBody  string    `json:"request_body,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	Truncated    bool      `json:"truncated,omitempty"` // A body was longer than the logged part
+}
+
+// Logger writes entries as JSON lines to a rotating file while it is enabled
+type Logger struct {
+	enabled     atomic.Bool
+	out         *RotatingFile
+	maxBodySize int
+}
+
+// New creates a logger writing to debug.log in the configured directory, enabled as configured.
+func New(cfg config.DebugLogConfig) *Logger {
+	l := &Logger{
+		out:         NewRotatingFile(cfg.Dir, "debug.log", cfg.MaxFileSize, cfg.MaxFiles),
+		maxBodySize: cfg.MaxBodySize,
+	}
+	l.enabled.Store(cfg.Enabled)
+	return l
+}
+
+// Enabled reports whether requests are being logged.
+func (l *Logger) Enabled() bool {
+	return l.enabled.Load()
+}
+
+// SetEnabled switches logging on or off.
+func (l *Logger) SetEnabled(enabled bool) {
+	if l.enabled.Swap(enabled) != enabled {
+		log.Printf("Debug request logging switched %s", map[bool]string{true: "on", false: "off"}[enabled])
+	}
+}
+
+// MaxBodySize is the number of bytes of each body that is logged.
+func (l *Logger) MaxBodySize() int {
+	return l.maxBodySize
+}
+
+// Write appends entry to the log. Bodies and the query must already be scrubbed.
+func (l *Logger) Write(entry Entry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode debug log entry: %v", err)
+		return
+	}
+	if _, err := l.out.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write debug log entry: %v", err)
+	}
+}
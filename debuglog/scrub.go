@@ -0,0 +1,136 @@
+package debuglog
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// redacted replaces secrets, which are never logged in any form
+const redacted = "[REDACTED]"
+
+// secretFields are JSON fields and query parameters whose value is dropped, matched without case
+// and ignoring underscores, so accessToken, access_token and AccessToken are all caught.
+var secretFields = []string{"password", "token", "secret", "authorization", "cookie", "apikey", "captcha"}
+
+// secretNames are dropped only under exactly that name, as they are common inside other names
+var secretNames = []string{"code", "resetcode", "otp", "pin"}
+
+// contactFields are JSON fields and query parameters holding an email address or phone number,
+// which are masked even when they do not look like one.
+var contactFields = []string{"email", "phone"}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// International numbers, and local ones such as 0712 345 678; dates and amounts do not match
+	phonePattern = regexp.MustCompile(`\+\d[\d ]{7,16}\d|\b0\d{2,3} ?\d{3} ?\d{3,4}\b`)
+)
+
+// ScrubBody masks the passwords, tokens, email addresses and phone numbers in a request or
+// response body. JSON is scrubbed field by field; anything else only has its emails and phone
+// numbers masked.
+func ScrubBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return ScrubText(string(body))
+	}
+	scrubbed, err := json.Marshal(scrubValue("", value))
+	if err != nil {
+		return ScrubText(string(body))
+	}
+	return string(scrubbed)
+}
+
+// ScrubQuery masks the secret and contact parameters of a raw query string.
+func ScrubQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return ScrubText(rawQuery)
+	}
+	for name, list := range values {
+		for i, value := range list {
+			list[i] = scrubField(name, value)
+		}
+	}
+	// Logged for people to read, so the masks are left unescaped
+	if decoded, err := url.QueryUnescape(values.Encode()); err == nil {
+		return decoded
+	}
+	return values.Encode()
+}
+
+// ScrubText masks every email address and phone number in free text.
+func ScrubText(text string) string {
+	text = emailPattern.ReplaceAllStringFunc(text, maskEmail)
+	return phonePattern.ReplaceAllStringFunc(text, maskPhone)
+}
+
+func scrubValue(field string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = scrubValue(key, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = scrubValue(field, item)
+		}
+		return v
+	case string:
+		return scrubField(field, v)
+	}
+	return value
+}
+
+func scrubField(field, value string) string {
+	name := strings.ToLower(strings.ReplaceAll(field, "_", ""))
+	for _, secret := range secretFields {
+		if strings.Contains(name, secret) {
+			return redacted
+		}
+	}
+	if slices.Contains(secretNames, name) {
+		return redacted
+	}
+	for _, contact := range contactFields {
+		if strings.Contains(name, contact) {
+			if strings.Contains(value, "@") {
+				return maskEmail(value)
+			}
+			return maskPhone(value)
+		}
+	}
+	return ScrubText(value)
+}
+
+// maskEmail keeps the first letter and the domain, e.g. j***@example.com.
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone keeps the last three digits, e.g. ***123.
+func maskPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) <= 3 {
+		return "***"
+	}
+	return "***" + digits[len(digits)-3:]
+}
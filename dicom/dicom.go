@@ -0,0 +1,335 @@
+// Package dicom reads the little of a DICOM Part 10 file the clinic needs: a few identifying
+// attributes and the pixel data of the first frame for a preview.
+package dicom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transfer syntaxes the dataset can be read in
+const (
+	ImplicitVRLittleEndian = "1.2.840.10008.1.2"
+	ExplicitVRLittleEndian = "1.2.840.10008.1.2.1"
+	ExplicitVRBigEndian    = "1.2.840.10008.1.2.2"
+	DeflatedExplicitVR     = "1.2.840.10008.1.2.1.99"
+	JPEGBaseline           = "1.2.840.10008.1.2.4.50"
+	JPEGExtended           = "1.2.840.10008.1.2.4.51"
+)
+
+// undefinedLength marks sequences, items and encapsulated pixel data delimited by markers
+const undefinedLength = 0xFFFFFFFF
+
+var (
+	ErrNotDICOM            = errors.New("not a DICOM file")
+	ErrUnsupportedSyntax   = errors.New("unsupported transfer syntax")
+	ErrTruncated           = errors.New("truncated DICOM file")
+	ErrInvalidTagReference = errors.New("invalid tag, expected gggg,eeee")
+)
+
+// Tag identifies a data element by group and element number
+type Tag struct {
+	Group   uint16
+	Element uint16
+}
+
+func (t Tag) String() string {
+	return fmt.Sprintf("(%04X,%04X)", t.Group, t.Element)
+}
+
+// ParseTag reads a tag written as "0010,0020", with or without parentheses
+func ParseTag(value string) (Tag, error) {
+	value = strings.Trim(strings.TrimSpace(value), "()")
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return Tag{}, ErrInvalidTagReference
+	}
+	group, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 16, 16)
+	if err != nil {
+		return Tag{}, ErrInvalidTagReference
+	}
+	element, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 16, 16)
+	if err != nil {
+		return Tag{}, ErrInvalidTagReference
+	}
+	return Tag{Group: uint16(group), Element: uint16(element)}, nil
+}
+
+// Attributes read from the dataset
+var (
+	TagTransferSyntaxUID         = Tag{0x0002, 0x0010}
+	TagSOPInstanceUID            = Tag{0x0008, 0x0018}
+	TagStudyDate                 = Tag{0x0008, 0x0020}
+	TagModality                  = Tag{0x0008, 0x0060}
+	TagPatientName               = Tag{0x0010, 0x0010}
+	TagPatientID                 = Tag{0x0010, 0x0020}
+	TagStudyInstanceUID          = Tag{0x0020, 0x000D}
+	TagSamplesPerPixel           = Tag{0x0028, 0x0002}
+	TagPhotometricInterpretation = Tag{0x0028, 0x0004}
+	TagPlanarConfiguration       = Tag{0x0028, 0x0006}
+	TagRows                      = Tag{0x0028, 0x0010}
+	TagColumns                   = Tag{0x0028, 0x0011}
+	TagBitsAllocated             = Tag{0x0028, 0x0100}
+	TagPixelRepresentation       = Tag{0x0028, 0x0103}
+	TagPixelData                 = Tag{0x7FE0, 0x0010}
+
+	tagItem                 = Tag{0xFFFE, 0xE000}
+	tagItemDelimitation     = Tag{0xFFFE, 0xE00D}
+	tagSequenceDelimitation = Tag{0xFFFE, 0xE0DD}
+
+	// longLengthVRs are the explicit VRs with a 32-bit length after two reserved bytes
+	longLengthVRs = map[string]bool{"OB": true, "OD": true, "OF": true, "OL": true, "OV": true, "OW": true, "SQ": true, "SV": true, "UC": true, "UN": true, "UR": true, "UT": true, "UV": true}
+)
+
+// File is a parsed DICOM file. Only top-level elements are kept; sequences are skipped.
+type File struct {
+	TransferSyntax string
+	elements       map[Tag][]byte
+	// fragments hold encapsulated (compressed) pixel data, the basic offset table first
+	fragments [][]byte
+}
+
+// Parse reads a DICOM Part 10 file: the 128-byte preamble, "DICM", the file meta information and the dataset
+func Parse(data []byte) (*File, error) {
+	if len(data) < 132 || string(data[128:132]) != "DICM" {
+		return nil, ErrNotDICOM
+	}
+	file := &File{elements: map[Tag][]byte{}}
+
+	// The file meta information is always explicit VR little endian
+	meta := &reader{data: data, pos: 132, explicit: true}
+	for meta.pos+4 <= len(data) && binary.LittleEndian.Uint16(data[meta.pos:]) == 0x0002 {
+		tag, value, _, err := meta.element()
+		if err != nil {
+			return nil, err
+		}
+		file.elements[tag] = value
+	}
+	file.TransferSyntax = file.String(TagTransferSyntaxUID)
+	if file.TransferSyntax == "" {
+		file.TransferSyntax = ImplicitVRLittleEndian
+	}
+
+	explicit := file.TransferSyntax != ImplicitVRLittleEndian
+	if file.TransferSyntax == ExplicitVRBigEndian || file.TransferSyntax == DeflatedExplicitVR {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedSyntax, file.TransferSyntax)
+	}
+
+	dataset := &reader{data: data, pos: meta.pos, explicit: explicit}
+	for dataset.pos < len(data) {
+		tag, value, fragments, err := dataset.element()
+		if err != nil {
+			return nil, err
+		}
+		if tag == TagPixelData && fragments != nil {
+			file.fragments = fragments
+			continue
+		}
+		file.elements[tag] = value
+	}
+	return file, nil
+}
+
+// String returns the text value of tag without its padding, or "" when absent
+func (f *File) String(tag Tag) string {
+	return strings.TrimRight(strings.TrimSpace(string(f.elements[tag])), "\x00 ")
+}
+
+// Uint returns the unsigned short value of tag, or fallback when absent
+func (f *File) Uint(tag Tag, fallback int) int {
+	value := f.elements[tag]
+	if len(value) < 2 {
+		return fallback
+	}
+	return int(binary.LittleEndian.Uint16(value))
+}
+
+// Date returns the DA value of tag, or nil when absent or malformed
+func (f *File) Date(tag Tag) *time.Time {
+	date, err := time.Parse("20060102", f.String(tag))
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+// PatientName returns the patient's name with the DICOM component separators turned into spaces
+func (f *File) PatientName() string {
+	parts := strings.Split(f.String(TagPatientName), "^")
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, part)
+		}
+	}
+	// Family name comes first in DICOM; put the given names before it
+	if len(names) > 1 {
+		names = append(names[1:], names[0])
+	}
+	return strings.Join(names, " ")
+}
+
+// reader walks data elements in little endian, with explicit or implicit VRs
+type reader struct {
+	data     []byte
+	pos      int
+	explicit bool
+}
+
+func (r *reader) uint16() (uint16, error) {
+	if r.pos+2 > len(r.data) {
+		return 0, ErrTruncated
+	}
+	value := binary.LittleEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return value, nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, ErrTruncated
+	}
+	value := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return value, nil
+}
+
+func (r *reader) tag() (Tag, error) {
+	group, err := r.uint16()
+	if err != nil {
+		return Tag{}, err
+	}
+	element, err := r.uint16()
+	if err != nil {
+		return Tag{}, err
+	}
+	return Tag{Group: group, Element: element}, nil
+}
+
+func (r *reader) bytes(length uint32) ([]byte, error) {
+	if uint64(r.pos)+uint64(length) > uint64(len(r.data)) {
+		return nil, ErrTruncated
+	}
+	value := r.data[r.pos : r.pos+int(length)]
+	r.pos += int(length)
+	return value, nil
+}
+
+// element reads the next data element. Undefined-length sequences are skipped; undefined-length
+// pixel data is returned as its fragments.
+func (r *reader) element() (Tag, []byte, [][]byte, error) {
+	tag, err := r.tag()
+	if err != nil {
+		return Tag{}, nil, nil, err
+	}
+
+	var length uint32
+	if r.explicit && tag.Group != 0xFFFE {
+		vr, err := r.bytes(2)
+		if err != nil {
+			return Tag{}, nil, nil, err
+		}
+		if longLengthVRs[string(vr)] {
+			r.pos += 2 // reserved
+			length, err = r.uint32()
+		} else {
+			var short uint16
+			short, err = r.uint16()
+			length = uint32(short)
+		}
+		if err != nil {
+			return Tag{}, nil, nil, err
+		}
+	} else if length, err = r.uint32(); err != nil {
+		return Tag{}, nil, nil, err
+	}
+
+	if length == undefinedLength {
+		if tag == TagPixelData {
+			fragments, err := r.fragments()
+			return tag, nil, fragments, err
+		}
+		return tag, nil, nil, r.skipSequence()
+	}
+	value, err := r.bytes(length)
+	return tag, value, nil, err
+}
+
+// skipSequence moves past the items of an undefined-length sequence and its delimiter
+func (r *reader) skipSequence() error {
+	for {
+		tag, err := r.tag()
+		if err != nil {
+			return err
+		}
+		length, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case tagSequenceDelimitation:
+			return nil
+		case tagItem:
+			if length != undefinedLength {
+				if _, err := r.bytes(length); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := r.skipItem(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %s in sequence", tag)
+		}
+	}
+}
+
+// skipItem moves past the elements of an undefined-length item and its delimiter
+func (r *reader) skipItem() error {
+	for {
+		if r.pos+4 > len(r.data) {
+			return ErrTruncated
+		}
+		group := binary.LittleEndian.Uint16(r.data[r.pos:])
+		element := binary.LittleEndian.Uint16(r.data[r.pos+2:])
+		if (Tag{group, element}) == tagItemDelimitation {
+			r.pos += 8
+			return nil
+		}
+		if _, _, _, err := r.element(); err != nil {
+			return err
+		}
+	}
+}
+
+// fragments reads the items of encapsulated pixel data up to the sequence delimiter
+func (r *reader) fragments() ([][]byte, error) {
+	var fragments [][]byte
+	for {
+		tag, err := r.tag()
+		if err != nil {
+			return nil, err
+		}
+		length, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tagSequenceDelimitation:
+			return fragments, nil
+		case tagItem:
+			fragment, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			fragments = append(fragments, fragment)
+		default:
+			return nil, fmt.Errorf("unexpected %s in pixel data", tag)
+		}
+	}
+}
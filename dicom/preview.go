@@ -0,0 +1,138 @@
+package dicom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// maxPreviewSize is the longest side of a preview in pixels
+const maxPreviewSize = 1024
+
+// ErrNoPreview is returned for pixel data a preview cannot be made of, such as JPEG 2000
+var ErrNoPreview = errors.New("no preview available for this image")
+
+// Preview renders the first frame as a PNG no larger than maxPreviewSize. Grayscale images are
+// stretched between their darkest and brightest pixels, as no display window is applied.
+func (f *File) Preview() ([]byte, error) {
+	img, err := f.image()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, downscale(img)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *File) image() (image.Image, error) {
+	if f.fragments != nil {
+		if f.TransferSyntax != JPEGBaseline && f.TransferSyntax != JPEGExtended {
+			return nil, ErrNoPreview
+		}
+		// The first item is the offset table; a single frame spans the remaining fragments
+		var frame []byte
+		for _, fragment := range f.fragments[min(1, len(f.fragments)):] {
+			frame = append(frame, fragment...)
+		}
+		return jpeg.Decode(bytes.NewReader(frame))
+	}
+
+	pixels := f.elements[TagPixelData]
+	rows, columns := f.Uint(TagRows, 0), f.Uint(TagColumns, 0)
+	bits := f.Uint(TagBitsAllocated, 0)
+	samples := f.Uint(TagSamplesPerPixel, 1)
+	if len(pixels) == 0 || rows == 0 || columns == 0 {
+		return nil, ErrNoPreview
+	}
+
+	switch {
+	case samples == 1 && (bits == 8 || bits == 16):
+		return f.grayscale(pixels, rows, columns, bits)
+	case samples == 3 && bits == 8 && f.String(TagPhotometricInterpretation) == "RGB":
+		return f.rgb(pixels, rows, columns)
+	}
+	return nil, ErrNoPreview
+}
+
+func (f *File) grayscale(pixels []byte, rows, columns, bits int) (image.Image, error) {
+	count := rows * columns
+	if len(pixels) < count*bits/8 {
+		return nil, ErrTruncated
+	}
+	signed := f.Uint(TagPixelRepresentation, 0) == 1
+	values := make([]int, count)
+	low, high := int(^uint(0)>>1), -int(^uint(0)>>1)-1
+	for i := range values {
+		var value int
+		switch {
+		case bits == 8 && signed:
+			value = int(int8(pixels[i]))
+		case bits == 8:
+			value = int(pixels[i])
+		case signed:
+			value = int(int16(binary.LittleEndian.Uint16(pixels[2*i:])))
+		default:
+			value = int(binary.LittleEndian.Uint16(pixels[2*i:]))
+		}
+		values[i] = value
+		low, high = min(low, value), max(high, value)
+	}
+
+	// MONOCHROME1 shows the lowest values brightest
+	inverted := f.String(TagPhotometricInterpretation) == "MONOCHROME1"
+	spread := max(high-low, 1)
+	img := image.NewGray(image.Rect(0, 0, columns, rows))
+	for i, value := range values {
+		level := uint8((value - low) * 255 / spread)
+		if inverted {
+			level = 255 - level
+		}
+		img.Pix[i] = level
+	}
+	return img, nil
+}
+
+func (f *File) rgb(pixels []byte, rows, columns int) (image.Image, error) {
+	count := rows * columns
+	if len(pixels) < count*3 {
+		return nil, ErrTruncated
+	}
+	// Planar configuration 1 stores all the reds, then the greens, then the blues
+	planar := f.Uint(TagPlanarConfiguration, 0) == 1
+	img := image.NewRGBA(image.Rect(0, 0, columns, rows))
+	for i := 0; i < count; i++ {
+		var r, g, b uint8
+		if planar {
+			r, g, b = pixels[i], pixels[count+i], pixels[2*count+i]
+		} else {
+			r, g, b = pixels[3*i], pixels[3*i+1], pixels[3*i+2]
+		}
+		img.Set(i%columns, i/columns, color.RGBA{R: r, G: g, B: b, A: 255})
+	}
+	return img, nil
+}
+
+// downscale shrinks img by nearest neighbour so its longest side is at most maxPreviewSize
+func downscale(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	longest := max(width, height)
+	if longest <= maxPreviewSize {
+		return img
+	}
+	scaledWidth := max(width*maxPreviewSize/longest, 1)
+	scaledHeight := max(height*maxPreviewSize/longest, 1)
+	scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		for x := 0; x < scaledWidth; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*width/scaledWidth, bounds.Min.Y+y*height/scaledHeight))
+		}
+	}
+	return scaled
+}
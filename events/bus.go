@@ -0,0 +1,150 @@
+package events
+
+import (
+	"RoyDental/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Domain events published by the repositories once a write has committed
+const (
+	PatientCreated       = "patient.created"
+	PatientUpdated       = "patient.updated"
+	PatientDeleted       = "patient.deleted"
+	PaymentRecorded      = "payment.recorded"
+	AppointmentBooked    = "appointment.booked"
+	AppointmentCancelled = "appointment.cancelled"
+	AppointmentFulfilled = "appointment.fulfilled"
+	BillingCreated       = "billing.created"
+
+	// Catalog changes, which carry the changed record's ID
+	DoctorChanged           = "doctor.changed"
+	ProcedureChanged        = "procedure.changed"
+	InsuranceCompanyChanged = "insurance_company.changed"
+)
+
+// Event is something that happened to a record. Events cross the Redis bridge as JSON, so they
+// carry identifiers rather than records.
+type Event struct {
+	Name       string              `json:"name"`
+	PatientID  string              `json:"patient_id,omitempty"`
+	Record     string              `json:"record,omitempty"` // Table of RecordID
+	RecordID   string              `json:"record_id,omitempty"`
+	Amount     float64             `json:"amount,omitempty"`
+	Related    map[string][]string `json:"related,omitempty"` // IDs of the records removed with the subject, by table
+	ActorID    *int64              `json:"actor_id,omitempty"`
+	OccurredAt time.Time           `json:"occurred_at"`
+	Instance   string              `json:"instance"`
+}
+
+// Handler reacts to an event. Its error is logged; the write that raised the event has already committed.
+type Handler func(ctx context.Context, event Event) error
+
+type subscription struct {
+	handler Handler
+	remote  bool
+}
+
+// Bus delivers events to the handlers subscribed to them, in the order they subscribed
+type Bus struct {
+	instance string
+
+	mu            sync.RWMutex
+	subscriptions map[string][]subscription
+	forward       func(Event)
+}
+
+// NewBus creates a bus with a random instance ID, so the Redis bridge can tell its own events apart.
+func NewBus() *Bus {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("failed to generate event bus instance ID: %v", err))
+	}
+	return &Bus{instance: hex.EncodeToString(id), subscriptions: map[string][]subscription{}}
+}
+
+// Default is the bus the repositories publish to
+var Default = NewBus()
+
+// Subscribe calls handler for every event of that name published by this instance. Use it for side
+// effects that must happen once, such as cache invalidation, notifications and audit entries.
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.subscribe(name, subscription{handler: handler})
+}
+
+// SubscribeAll also calls handler for the events other instances forward over the Redis bridge.
+func (b *Bus) SubscribeAll(name string, handler Handler) {
+	b.subscribe(name, subscription{handler: handler, remote: true})
+}
+
+func (b *Bus) subscribe(name string, s subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[name] = append(b.subscriptions[name], s)
+}
+
+// Publish stamps the event and hands it to this instance's handlers before returning, then
+// forwards it to the other instances when the Redis bridge is running
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	event.Instance = b.instance
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.ActorID == nil {
+		if userID, ok := models.ActorFrom(ctx); ok {
+			event.ActorID = &userID
+		}
+	}
+
+	b.dispatch(ctx, event, false)
+
+	b.mu.RLock()
+	forward := b.forward
+	b.mu.RUnlock()
+	if forward != nil {
+		forward(event)
+	}
+}
+
+// dispatch runs the handlers of an event; a failing or panicking handler does not stop the others
+func (b *Bus) dispatch(ctx context.Context, event Event, remote bool) {
+	b.mu.RLock()
+	subscriptions := b.subscriptions[event.Name]
+	b.mu.RUnlock()
+
+	for _, s := range subscriptions {
+		if remote && !s.remote {
+			continue
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Event handler for %s panicked: %v", event.Name, r)
+				}
+			}()
+			if err := s.handler(ctx, event); err != nil {
+				log.Printf("Event handler for %s failed: %v", event.Name, err)
+			}
+		}()
+	}
+}
+
+// Subscribe subscribes handler to the default bus
+func Subscribe(name string, handler Handler) {
+	Default.Subscribe(name, handler)
+}
+
+// SubscribeAll subscribes handler to the default bus, including events from other instances
+func SubscribeAll(name string, handler Handler) {
+	Default.SubscribeAll(name, handler)
+}
+
+// Publish publishes an event on the default bus
+func Publish(ctx context.Context, event Event) {
+	Default.Publish(ctx, event)
+}
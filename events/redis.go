@@ -0,0 +1,75 @@
+package events
+
+import (
+	"RoyDental/database"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// bridgeTimeout bounds forwarding a single event to Redis.
+const bridgeTimeout = 5 * time.Second
+
+// StartRedisBridge forwards the bus's events to a Redis pub/sub channel and delivers the events other
+// instances forward to the handlers subscribed with SubscribeAll, until ctx is done.
+func (b *Bus) StartRedisBridge(ctx context.Context, channel string) {
+	b.mu.Lock()
+	b.forward = func(event Event) {
+		go b.forwardToRedis(channel, event)
+	}
+	b.mu.Unlock()
+
+	go b.receive(ctx, channel)
+}
+
+func (b *Bus) forwardToRedis(channel string, event Event) {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event.Name, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bridgeTimeout)
+	defer cancel()
+	if err := database.RedisClient.Publish(ctx, channel, payload).Err(); err != nil {
+		database.RedisBreaker.Failure()
+		log.Printf("Failed to forward %s event: %v", event.Name, err)
+		return
+	}
+	database.RedisBreaker.Success()
+}
+
+// receive delivers the events of other instances; the subscription reconnects by itself after outages
+func (b *Bus) receive(ctx context.Context, channel string) {
+	pubsub := database.RedisClient.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				log.Printf("Failed to decode forwarded event: %v", err)
+				continue
+			}
+			if event.Instance == b.instance {
+				continue
+			}
+			b.dispatch(ctx, event, true)
+		}
+	}
+}
+
+// StartRedisBridge starts the Redis bridge of the default bus
+func StartRedisBridge(ctx context.Context, channel string) {
+	Default.StartRedisBridge(ctx, channel)
+}
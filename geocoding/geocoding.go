@@ -0,0 +1,61 @@
+// Package geocoding places postal addresses on the map through a pluggable provider: OpenStreetMap's
+// Nominatim, or a webhook in front of any other service.
+package geocoding
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// earthRadiusKM is the mean radius of the Earth
+const earthRadiusKM = 6371.0
+
+// ErrNotFound is returned for addresses the provider cannot place
+var ErrNotFound = errors.New("address not found")
+
+// Coordinates are a latitude and longitude in degrees
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Provider looks up where addresses are
+type Provider interface {
+	// Geocode returns where an address is, or ErrNotFound when it cannot be placed
+	Geocode(ctx context.Context, address models.Address) (Coordinates, error)
+}
+
+// New returns the configured provider, or nil when geocoding is switched off
+func New(cfg config.GeocodingConfig) (Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "nominatim":
+		url := cfg.URL
+		if url == "" {
+			url = defaultNominatimURL
+		}
+		return &Nominatim{URL: url, UserAgent: cfg.UserAgent, CountryCodes: cfg.CountryCodes, Client: client}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("GEOCODING_URL is required for the webhook provider")
+		}
+		return &Webhook{URL: cfg.URL, Token: cfg.Token, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown geocoding provider %q", cfg.Provider)
+}
+
+// DistanceKM returns the great-circle distance between two places in kilometres
+func DistanceKM(a, b Coordinates) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
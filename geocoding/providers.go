@@ -0,0 +1,118 @@
+package geocoding
+
+import (
+	"RoyDental/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// defaultNominatimURL is OpenStreetMap's public search endpoint, limited to one request a second
+const defaultNominatimURL = "https://nominatim.openstreetmap.org/search"
+
+// Nominatim looks addresses up with a Nominatim server
+type Nominatim struct {
+	URL          string
+	UserAgent    string
+	CountryCodes string
+	Client       *http.Client
+}
+
+func (n *Nominatim) Geocode(ctx context.Context, address models.Address) (Coordinates, error) {
+	query := url.Values{"format": {"jsonv2"}, "limit": {"1"}}
+	if address.Street != "" {
+		query.Set("street", address.Street)
+	}
+	if address.City != "" {
+		query.Set("city", address.City)
+	}
+	if address.County != "" {
+		query.Set("county", address.County)
+	}
+	if address.PostalCode != "" {
+		query.Set("postalcode", address.PostalCode)
+	}
+	if n.CountryCodes != "" {
+		query.Set("countrycodes", n.CountryCodes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Coordinates{}, fmt.Errorf("nominatim returned %s", resp.Status)
+	}
+
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return Coordinates{}, fmt.Errorf("failed to decode nominatim response: %w", err)
+	}
+	if len(places) == 0 {
+		return Coordinates{}, ErrNotFound
+	}
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return Coordinates{}, fmt.Errorf("invalid latitude %q from nominatim", places[0].Lat)
+	}
+	lon, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return Coordinates{}, fmt.Errorf("invalid longitude %q from nominatim", places[0].Lon)
+	}
+	return Coordinates{Latitude: lat, Longitude: lon}, nil
+}
+
+// Webhook posts the address as JSON and expects {"found": true, "latitude": ..., "longitude": ...}
+type Webhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (w *Webhook) Geocode(ctx context.Context, address models.Address) (Coordinates, error) {
+	body, err := json.Marshal(address)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return Coordinates{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Coordinates{}, fmt.Errorf("geocoding webhook returned %s", resp.Status)
+	}
+
+	var result struct {
+		Found bool `json:"found"`
+		Coordinates
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Coordinates{}, fmt.Errorf("failed to decode geocoding webhook response: %w", err)
+	}
+	if !result.Found {
+		return Coordinates{}, ErrNotFound
+	}
+	return result.Coordinates, nil
+}
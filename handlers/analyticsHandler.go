@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	service *services.AnalyticsService
+}
+
+func NewAnalyticsHandler(service *services.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service}
+}
+
+// GetAnalytics reports the anonymized aggregates between the from and to dates (YYYY-MM-DD),
+// the last 30 days by default
+func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
+	to := models.ClinicNow().AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, -29)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	report, err := h.service.Report(c, from, to)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
+
+// RebuildAnalytics recomputes the aggregates of ?date= (YYYY-MM-DD), e.g. after correcting old records
+func (h *AnalyticsHandler) RebuildAnalytics(c *gin.Context) {
+	day, err := models.ParseClinicDate(c.Query("date"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if err := h.service.AggregateDay(c, day); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Analytics rebuilt"})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIUsageHandler struct {
+	service *services.APIUsageService
+}
+
+func NewAPIUsageHandler(service *services.APIUsageService) *APIUsageHandler {
+	return &APIUsageHandler{service: service}
+}
+
+// GetAPIUsage reports the requests, errors and bytes of each user and integration from ?from=
+// through ?to= (YYYY-MM-DD), the last 24 hours by default, in ?bucket=hour or day buckets. ?consumer=
+// narrows it to one consumer, such as kiosk:3f2a9c1e, or one kind, such as kiosk; ?route= to one
+// route; and ?by_route=true breaks the buckets down by route.
+func (h *APIUsageHandler) GetAPIUsage(c *gin.Context) {
+	report, err := h.service.Report(c, c.Query("from"), c.Query("to"), c.Query("bucket"), c.Query("consumer"), c.Query("route"), c.Query("by_route") == "true")
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIUsageQuery) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
@@ -1,19 +1,23 @@
 package handlers
 
 import (
+	"RoyDental/middlewares"
 	"RoyDental/models"
+	"RoyDental/repositories"
 	"RoyDental/services"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AppointmentHandler struct {
-	service *services.AppointmentService
+	service      *services.AppointmentService
+	savedFilters *services.SavedFilterService
 }
 
-func NewAppointmentHandler(service *services.AppointmentService) *AppointmentHandler {
-	return &AppointmentHandler{service: service}
+func NewAppointmentHandler(service *services.AppointmentService, savedFilters *services.SavedFilterService) *AppointmentHandler {
+	return &AppointmentHandler{service: service, savedFilters: savedFilters}
 }
 
 func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
@@ -22,8 +26,43 @@ func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.service.Create(c, &appointment); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := h.service.Create(c, &appointment, patientConflictAudit(c)); err != nil {
+		appointmentError(c, err)
+		return
+	}
+	c.JSON(201, appointment)
+}
+
+// CreateEmergencyAppointment books a same-day emergency with its reason, e.g. {"patient_id": "...",
+// "reason": "Swelling and severe pain"}. Without a date_time it takes the first emergency slot still
+// free today, with the doctor_id given or the slot's doctor.
+func (h *AppointmentHandler) CreateEmergencyAppointment(c *gin.Context) {
+	var req struct {
+		PatientID string `json:"patient_id" binding:"required"`
+		DoctorID  string `json:"doctor_id"`
+		DateTime  string `json:"date_time"`
+		ChairID   *uint  `json:"chair_id"`
+		Reason    string `json:"reason" binding:"required"`
+		Override  bool   `json:"override_patient_conflict"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DateTime != "" && req.DoctorID == "" {
+		c.JSON(400, gin.H{"error": "doctor_id is required with a date_time"})
+		return
+	}
+	appointment := models.Appointment{
+		PatientID:               req.PatientID,
+		DoctorID:                req.DoctorID,
+		DateTime:                req.DateTime,
+		ChairID:                 req.ChairID,
+		EmergencyReason:         req.Reason,
+		OverridePatientConflict: req.Override,
+	}
+	if err := h.service.CreateEmergency(c, &appointment, patientConflictAudit(c)); err != nil {
+		appointmentError(c, err)
 		return
 	}
 	c.JSON(201, appointment)
@@ -46,13 +85,28 @@ func (h *AppointmentHandler) GetAppointmentByID(c *gin.Context) {
 	c.JSON(200, appointment)
 }
 
+// GetAllAppointments streams the list, as NDJSON with Accept: application/x-ndjson, or returns
+// one page of it when ?limit= or an ?after= cursor is given. A saved filter (?filter_id=) and
+// ?sort= narrow and order the streamed list.
 func (h *AppointmentHandler) GetAllAppointments(c *gin.Context) {
-	appointments, err := h.service.GetAll(c)
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if c.Query("limit") != "" || c.Query("after") != "" {
+		h.listAppointmentsPage(c)
+		return
+	}
+	query, ok := filteredList(c, h.savedFilters, models.ListAppointments)
+	if !ok {
+		return
+	}
+	stream := newJSONStream(c)
+	if query != nil {
+		stream.Close(h.service.StreamFiltered(c, *query, func(appointment models.Appointment) error {
+			return stream.Write(appointment)
+		}))
 		return
 	}
-	c.JSON(200, appointments)
+	stream.Close(h.service.Stream(c, func(appointment models.Appointment) error {
+		return stream.Write(appointment)
+	}))
 }
 
 func (h *AppointmentHandler) UpdateAppointment(c *gin.Context) {
@@ -72,8 +126,28 @@ func (h *AppointmentHandler) UpdateAppointment(c *gin.Context) {
 	appointment.PatientID = patientID
 	appointment.ID = uint(id)
 
-	if err := h.service.Update(c, &appointment); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := h.service.Update(c, &appointment, patientConflictAudit(c)); err != nil {
+		appointmentError(c, err)
+		return
+	}
+	c.JSON(200, appointment)
+}
+
+// PatchAppointment changes only the fields in the body, a JSON merge patch
+func (h *AppointmentHandler) PatchAppointment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	appointment, err := h.service.Patch(c, c.Param("patient_id"), uint(id), patch, patientConflictAudit(c))
+	if err != nil {
+		appointmentError(c, err)
 		return
 	}
 	c.JSON(200, appointment)
@@ -94,3 +168,97 @@ func (h *AppointmentHandler) DeleteAppointment(c *gin.Context) {
 	}
 	c.JSON(204, gin.H{"message": "Appointment deleted"})
 }
+
+// GetAvailability returns the free slots on ?date= (YYYY-MM-DD, default today) for ?doctor_id=,
+// counting both the doctor's appointments and the chairs left
+func (h *AppointmentHandler) GetAvailability(c *gin.Context) {
+	day := models.ClinicNow()
+	if value := c.Query("date"); value != "" {
+		var err error
+		if day, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	slots, err := h.service.Availability(c, day, c.Query("doctor_id"))
+	if err != nil {
+		appointmentError(c, err)
+		return
+	}
+	c.JSON(200, slots)
+}
+
+// GetOverbookingReport reports how often appointments booked over another collided with it between
+// the from and to dates (YYYY-MM-DD), the last 30 days by default
+func (h *AppointmentHandler) GetOverbookingReport(c *gin.Context) {
+	to := models.ClinicNow()
+	from := to.AddDate(0, 0, -29)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	report, err := h.service.OverbookingReport(c, from, to)
+	if err != nil {
+		appointmentError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func (h *AppointmentHandler) listAppointmentsPage(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	page, err := h.service.ListPage(c, c.Query("after"), limit)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, page)
+}
+
+// patientConflictAudit starts the audit entry written when a booking overrides a patient conflict
+func patientConflictAudit(c *gin.Context) models.AuditLog {
+	audit := middlewares.NewAuditEntry(c, models.AuditCategoryActivity, models.AuditEventPatientConflictOverridden)
+	audit.Status = 200
+	if c.Request.Method == "POST" {
+		audit.Status = 201
+	}
+	return audit
+}
+
+// appointmentError answers a patient conflict with the appointments in the way, which the client
+// may book over by sending the appointment again with override_patient_conflict set
+func appointmentError(c *gin.Context, err error) {
+	var conflict *repositories.PatientDoubleBookedError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(409, gin.H{"error": err.Error(), "warning": "patient_conflict", "conflicts": conflict.Conflicts})
+	case errors.Is(err, repositories.ErrAppointmentNotFound), errors.Is(err, repositories.ErrChairNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrVisitNotReady), errors.Is(err, repositories.ErrChairDoubleBooked), errors.Is(err, repositories.ErrNoChairAvailable),
+		errors.Is(err, repositories.ErrChairDown), errors.Is(err, repositories.ErrDoctorDoubleBooked), errors.Is(err, services.ErrClinicClosed),
+		errors.Is(err, services.ErrEmergencyOnly), errors.Is(err, services.ErrNoEmergencySlot), errors.Is(err, services.ErrCredentialsExpired):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAppointment), errors.Is(err, services.ErrInvalidCustomFieldValues), errors.Is(err, services.ErrInvalidPatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AppointmentReminderHandler struct {
+	service *services.AppointmentReminderService
+}
+
+func NewAppointmentReminderHandler(service *services.AppointmentReminderService) *AppointmentReminderHandler {
+	return &AppointmentReminderHandler{service: service}
+}
+
+// GetAppointmentReminders previews when an appointment's reminders are sent, and which were already
+func (h *AppointmentReminderHandler) GetAppointmentReminders(c *gin.Context) {
+	appointmentID, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	plan, err := h.service.Plan(c, c.Param("patient_id"), uint(appointmentID))
+	if err != nil {
+		appointmentReminderError(c, err)
+		return
+	}
+	c.JSON(200, plan)
+}
+
+// SetAppointmentReminders gives an appointment its own reminder cadence, e.g. {"lead_minutes": [2880,
+// 120]}, or {"lead_minutes": null} to follow the cadence of its type again
+func (h *AppointmentReminderHandler) SetAppointmentReminders(c *gin.Context) {
+	appointmentID, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	var req struct {
+		LeadMinutes models.ReminderLeads `json:"lead_minutes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	plan, err := h.service.SetCadence(c, c.Param("patient_id"), uint(appointmentID), req.LeadMinutes)
+	if err != nil {
+		appointmentReminderError(c, err)
+		return
+	}
+	c.JSON(200, plan)
+}
+
+func appointmentReminderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidReminderCadence):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrAppointmentNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
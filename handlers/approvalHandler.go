@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApprovalHandler struct {
+	service *services.ApprovalService
+}
+
+func NewApprovalHandler(service *services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{service: service}
+}
+
+type approvalDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+// GetApprovals lists the approvals, in ?status= and for ?action= only when given
+func (h *ApprovalHandler) GetApprovals(c *gin.Context) {
+	approvals, err := h.service.List(c, models.ApprovalFilter{Status: c.Query("status"), Action: c.Query("action")})
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+	c.JSON(200, approvals)
+}
+
+func (h *ApprovalHandler) GetApproval(c *gin.Context) {
+	id, ok := approvalParamID(c)
+	if !ok {
+		return
+	}
+	approval, err := h.service.Get(c, id)
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+	c.JSON(200, approval)
+}
+
+// ApproveApproval approves a pending action, which is carried out straight away
+func (h *ApprovalHandler) ApproveApproval(c *gin.Context) {
+	h.decide(c, h.service.Approve)
+}
+
+func (h *ApprovalHandler) RejectApproval(c *gin.Context) {
+	h.decide(c, h.service.Reject)
+}
+
+func (h *ApprovalHandler) decide(c *gin.Context, decide func(context.Context, uint, int64, string) (*models.Approval, error)) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := approvalParamID(c)
+	if !ok {
+		return
+	}
+	// The note is optional when approving, so the body may be left out
+	var request approvalDecisionRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	approval, err := decide(c, id, userID, request.Note)
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+	c.JSON(200, approval)
+}
+
+// GetMyApprovals lists the approvals the signed-in user asked for, in ?status= only when given
+func (h *ApprovalHandler) GetMyApprovals(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	approvals, err := h.service.List(c, models.ApprovalFilter{Status: c.Query("status"), CreatedBy: &userID})
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+	c.JSON(200, approvals)
+}
+
+// CancelApproval withdraws a pending action the signed-in user asked for
+func (h *ApprovalHandler) CancelApproval(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := approvalParamID(c)
+	if !ok {
+		return
+	}
+	approval, err := h.service.Cancel(c, id, userID)
+	if err != nil {
+		approvalError(c, err)
+		return
+	}
+	c.JSON(200, approval)
+}
+
+// approvalPending answers 202 Accepted with the approval an action waits for
+func approvalPending(c *gin.Context, err error) {
+	var required *services.ApprovalRequiredError
+	if !errors.As(err, &required) {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(202, gin.H{"message": "The action is waiting for approval", "approval": required.Approval})
+}
+
+func approvalParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func approvalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrApprovalNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidApproval):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSelfApproval), errors.Is(err, services.ErrApprovalPermission):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalNotPending), errors.Is(err, services.ErrApprovalFailed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
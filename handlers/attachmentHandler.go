@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttachmentHandler struct {
+	service *services.AttachmentService
+}
+
+func NewAttachmentHandler(service *services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{service: service}
+}
+
+// UploadAttachment files the multipart "file" with the examination, with optional "annotations" as a JSON array
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	// Leave room for the multipart framing and the annotations around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAttachmentSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": "The file is too large"})
+			return
+		}
+		c.JSON(400, gin.H{"error": "A file is required"})
+		return
+	}
+	if header.Size > services.MaxAttachmentSize {
+		c.JSON(413, gin.H{"error": "The file is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload := services.AttachmentUpload{
+		FileName:    header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Content:     content,
+	}
+	if upload.ContentType == "" || upload.ContentType == "application/octet-stream" {
+		upload.ContentType = http.DetectContentType(content)
+	}
+	if value := c.PostForm("annotations"); value != "" {
+		if err := json.Unmarshal([]byte(value), &upload.Annotations); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid annotations, expected a JSON array"})
+			return
+		}
+	}
+
+	attachment, err := h.service.Upload(c, c.Param("patient_id"), examinationID, upload)
+	if err != nil {
+		attachmentError(c, err)
+		return
+	}
+	c.JSON(201, attachment)
+}
+
+// DownloadAttachment sends the attached file
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	attachment, err := h.service.Download(c, c.Param("patient_id"), examinationID, id)
+	if err != nil {
+		attachmentError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="`+attachment.FileName+`"`)
+	c.Data(200, attachment.ContentType, attachment.Content)
+}
+
+// UpdateAnnotations replaces the annotations of an attachment with the JSON array in the body
+func (h *AttachmentHandler) UpdateAnnotations(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	var annotations models.Annotations
+	if err := c.ShouldBindJSON(&annotations); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	attachment, err := h.service.UpdateAnnotations(c, c.Param("patient_id"), examinationID, id, annotations)
+	if err != nil {
+		attachmentError(c, err)
+		return
+	}
+	c.JSON(200, attachment)
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, c.Param("patient_id"), examinationID, id); err != nil {
+		attachmentError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Attachment deleted successfully"})
+}
+
+func attachmentParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func attachmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrExaminationNotFound), errors.Is(err, services.ErrAttachmentNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAttachment), errors.Is(err, services.ErrInvalidAnnotation):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInfectedFile):
+		c.JSON(422, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVirusScanUnavailable):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AudioNoteHandler struct {
+	service *services.AudioNoteService
+}
+
+func NewAudioNoteHandler(service *services.AudioNoteService) *AudioNoteHandler {
+	return &AudioNoteHandler{service: service}
+}
+
+// UploadAudioNote files the multipart "file" with the examination, with an optional "title",
+// "duration_seconds" and "recorded_at" (RFC 3339)
+func (h *AudioNoteHandler) UploadAudioNote(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAudioNoteSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": "The file is too large"})
+			return
+		}
+		c.JSON(400, gin.H{"error": "A file is required"})
+		return
+	}
+	if header.Size > services.MaxAudioNoteSize {
+		c.JSON(413, gin.H{"error": "The file is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload := services.AudioNoteUpload{
+		FileName:    header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Content:     content,
+		Title:       c.PostForm("title"),
+	}
+	if upload.ContentType == "" || upload.ContentType == "application/octet-stream" {
+		upload.ContentType = http.DetectContentType(content)
+	}
+	if value := c.PostForm("duration_seconds"); value != "" {
+		duration, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid duration_seconds"})
+			return
+		}
+		upload.DurationSeconds = &duration
+	}
+	if value := c.PostForm("recorded_at"); value != "" {
+		recordedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid recorded_at, expected RFC 3339"})
+			return
+		}
+		upload.RecordedAt = &recordedAt
+	}
+
+	note, err := h.service.Upload(c, c.Param("patient_id"), examinationID, upload)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(201, note)
+}
+
+// GetAudioNotes lists the audio notes of an examination with their transcripts
+func (h *AudioNoteHandler) GetAudioNotes(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	notes, err := h.service.List(c, c.Param("patient_id"), examinationID)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(200, notes)
+}
+
+// DownloadAudioNote sends the recording
+func (h *AudioNoteHandler) DownloadAudioNote(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	note, err := h.service.Download(c, c.Param("patient_id"), examinationID, id)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="`+note.FileName+`"`)
+	c.Data(200, note.ContentType, note.Content)
+}
+
+// SetTranscript records the transcript typed by staff, sent as {"transcript": "..."}
+func (h *AudioNoteHandler) SetTranscript(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	var request struct {
+		Transcript string `json:"transcript" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	note, err := h.service.SetTranscript(c, c.Param("patient_id"), examinationID, id, request.Transcript)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(200, note)
+}
+
+// Retranscribe sends the note back to the transcription provider
+func (h *AudioNoteHandler) Retranscribe(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	note, err := h.service.Retranscribe(c, c.Param("patient_id"), examinationID, id)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(202, note)
+}
+
+func (h *AudioNoteHandler) DeleteAudioNote(c *gin.Context) {
+	examinationID, ok := attachmentParam(c, "examination_id")
+	if !ok {
+		return
+	}
+	id, ok := attachmentParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, c.Param("patient_id"), examinationID, id); err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Audio note deleted successfully"})
+}
+
+// GetTranscriptionQueue lists the notes in the ?status= transcription state (pending by default),
+// at most ?limit= (50 by default)
+func (h *AudioNoteHandler) GetTranscriptionQueue(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+	items, err := h.service.Queue(c, c.Query("status"), limit)
+	if err != nil {
+		audioNoteError(c, err)
+		return
+	}
+	c.JSON(200, items)
+}
+
+func audioNoteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrExaminationNotFound), errors.Is(err, services.ErrAudioNoteNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAudioNote), errors.Is(err, services.ErrInvalidTranscript):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAudioNoteTranscribing):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTranscriptionUnavailable):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditArchiveHandler struct {
+	service *services.AuditArchiveService
+}
+
+func NewAuditArchiveHandler(service *services.AuditArchiveService) *AuditArchiveHandler {
+	return &AuditArchiveHandler{service: service}
+}
+
+// GetAuditArchive reports how far the audit trail has been shipped to the archive
+func (h *AuditArchiveHandler) GetAuditArchive(c *gin.Context) {
+	status, err := h.service.Status(c)
+	if err != nil {
+		auditArchiveError(c, err)
+		return
+	}
+	c.JSON(200, status)
+}
+
+// ShipAuditArchive ships the audit entries written since the last batch without waiting for the next interval
+func (h *AuditArchiveHandler) ShipAuditArchive(c *gin.Context) {
+	shipped, err := h.service.Ship(c)
+	if err != nil {
+		auditArchiveError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"shipped": shipped})
+}
+
+// VerifyAuditArchive checks the archive's hash chain and compares it with the audit trail
+func (h *AuditArchiveHandler) VerifyAuditArchive(c *gin.Context) {
+	verification, err := h.service.Verify(c)
+	if err != nil {
+		auditArchiveError(c, err)
+		return
+	}
+	c.JSON(200, verification)
+}
+
+func auditArchiveError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAuditArchiveDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAuditArchiveMismatch):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
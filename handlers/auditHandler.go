@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	service *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// GetAuthFailures lists authorization failures, filtered by event, ip, user_id and an RFC 3339 from/to range.
+func (h *AuditHandler) GetAuthFailures(c *gin.Context) {
+	h.listEntries(c, h.service.ListAuthFailures)
+}
+
+// GetClinicalEvents lists clinical audit entries, such as acknowledged prescription warnings, with the same filters.
+func (h *AuditHandler) GetClinicalEvents(c *gin.Context) {
+	h.listEntries(c, h.service.ListClinicalEvents)
+}
+
+// GetActivityEvents lists activity audit entries, such as recorded payments and cancelled appointments, with the same filters.
+func (h *AuditHandler) GetActivityEvents(c *gin.Context) {
+	h.listEntries(c, h.service.ListActivityEvents)
+}
+
+func (h *AuditHandler) listEntries(c *gin.Context, list func(context.Context, models.AuditFilter) ([]models.AuditLog, int64, error)) {
+	filter := models.AuditFilter{
+		Event:  c.Query("event"),
+		IP:     c.Query("ip"),
+		UserID: c.Query("user_id"),
+	}
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to: " + err.Error()})
+		return
+	}
+	if filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "100")); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || filter.Offset < 0 {
+		c.JSON(400, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	entries, total, err := list(c, filter)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"total": total, "entries": entries})
+}
+
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"RoyDental/middlewares"
 	"RoyDental/models"
 	"RoyDental/services"
 	"RoyDental/utils"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -63,11 +65,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	c.Status(201)
 }
 
-// Login authenticates the user and returns tokens along with user info
+// Login authenticates the user and returns tokens along with user info. The tokens are issued to
+// the client named by "audience", the default of the user's role when left out; staff sign in a
+// waiting-room kiosk with "audience": "kiosk" so its token only opens the kiosk routes.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var credentials struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Audience string `json:"audience"`
 	}
 
 	if err := c.ShouldBindJSON(&credentials); err != nil {
@@ -78,19 +83,38 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	ctx := c.Request.Context()
 	user, err := h.UserService.AuthenticateUser(ctx, credentials.Email, credentials.Password)
 	if err != nil {
+		// Failed attempts are kept against the account they were made on, when it exists
+		account, _ := h.UserService.GetUserByEmail(ctx, credentials.Email)
+		middlewares.AuditLogin(c, models.AuditEventLoginFailed, 401, account, "")
 		c.JSON(401, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	accessToken, refreshToken, err := utils.GenerateTokens(strconv.FormatInt(user.ID, 10), user.Role.Name)
+	audience, err := utils.TokenAudience(user.Role.TokenRole(), credentials.Audience)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, refreshToken, err := utils.GenerateTokens(strconv.FormatInt(user.ID, 10), user.Role.TokenRole(), audience)
 	if err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate tokens: %v", err)})
 		return
 	}
 
+	// Issue a CSRF token for clients that authenticate with cookies
+	csrfToken, err := utils.GenerateCSRFToken()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate CSRF token: %v", err)})
+		return
+	}
+	utils.SetCSRFCookie(c, csrfToken)
+	middlewares.AuditLogin(c, models.AuditEventLoginSucceeded, 200, user, "")
+
 	c.JSON(200, gin.H{
 		"accessToken":  accessToken,
 		"refreshToken": refreshToken,
+		"csrfToken":    csrfToken,
 	})
 }
 
@@ -103,13 +127,13 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient")
+	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient", "Insurer")
 	if err != nil {
 		c.JSON(401, gin.H{"error": "Invalid access token"})
 		return
 	}
 
-	accessToken, err := utils.GenerateAccessToken(claims.UserID, claims.Role)
+	accessToken, err := utils.GenerateAccessToken(claims.UserID, claims.Role, claims.Scope())
 	if err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to generate access token: %v", err)})
 		return
@@ -123,6 +147,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 // Logoff logs the user out by clearing cookies
 func (h *AuthHandler) Logoff(c *gin.Context) {
 	utils.ClearAuthCookies(c)
+	middlewares.AuditLogin(c, models.AuditEventLogout, 200, nil, "")
 	c.Status(200)
 }
 
@@ -184,7 +209,7 @@ func (h *AuthHandler) ChangeEmail(c *gin.Context) {
 		return
 	}
 
-	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient")
+	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient", "Insurer")
 	if err != nil {
 		c.JSON(401, gin.H{"error": "Invalid access token"})
 		return
@@ -224,7 +249,7 @@ func (h *AuthHandler) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient")
+	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient", "Insurer")
 	if err != nil {
 		c.JSON(401, gin.H{"error": "Invalid access token"})
 		return
@@ -255,7 +280,7 @@ func (h *AuthHandler) UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient")
+	claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient", "Insurer")
 	if err != nil {
 		c.JSON(401, gin.H{"error": "Invalid access token"})
 		return
@@ -338,6 +363,11 @@ func (h *AuthHandler) AdminManageUsers(c *gin.Context) {
 	// Validate the token
 	claims, err := utils.ValidateToken(token, "Admin")
 	if err != nil {
+		event := models.AuditEventInvalidAccessToken
+		if errors.Is(err, utils.ErrInsufficientPermissions) {
+			event = models.AuditEventPermissionDenied
+		}
+		middlewares.AuditAuthFailure(c, event, 401, err.Error())
 		c.JSON(401, gin.H{"error": "Invalid access token"})
 		return
 	}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BillingDisputeHandler struct {
+	service *services.BillingDisputeService
+}
+
+func NewBillingDisputeHandler(service *services.BillingDisputeService) *BillingDisputeHandler {
+	return &BillingDisputeHandler{service: service}
+}
+
+// CreateBillingDispute opens a dispute about the bill, raised by the patient or their insurer
+func (h *BillingDisputeHandler) CreateBillingDispute(c *gin.Context) {
+	var request struct {
+		RaisedBy  string   `json:"raised_by" binding:"required"`
+		Reference string   `json:"reference"`
+		Reason    string   `json:"reason" binding:"required"`
+		Amount    *float64 `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	dispute := &models.BillingDispute{
+		BillingID: c.Param("id"),
+		RaisedBy:  request.RaisedBy,
+		Reference: request.Reference,
+		Reason:    request.Reason,
+		Amount:    request.Amount,
+	}
+	created, err := h.service.Create(c, dispute)
+	if err != nil {
+		billingDisputeError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func (h *BillingDisputeHandler) GetBillingDisputes(c *gin.Context) {
+	disputes, err := h.service.ListByBilling(c, c.Param("id"))
+	if err != nil {
+		billingDisputeError(c, err)
+		return
+	}
+	c.JSON(200, disputes)
+}
+
+// GetBillingDispute returns a dispute with the adjustments made for it
+func (h *BillingDisputeHandler) GetBillingDispute(c *gin.Context) {
+	id, ok := billingDisputeID(c)
+	if !ok {
+		return
+	}
+	dispute, err := h.service.Get(c, id)
+	if err != nil {
+		billingDisputeError(c, err)
+		return
+	}
+	c.JSON(200, dispute)
+}
+
+// UpdateBillingDispute moves a dispute under review, or resolves or rejects it with a note
+func (h *BillingDisputeHandler) UpdateBillingDispute(c *gin.Context) {
+	id, ok := billingDisputeID(c)
+	if !ok {
+		return
+	}
+	var update models.BillingDisputeUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	dispute, err := h.service.Update(c, id, update)
+	if err != nil {
+		billingDisputeError(c, err)
+		return
+	}
+	c.JSON(200, dispute)
+}
+
+// GetOpenDisputeReport lists the disputes still being worked on and the amounts they hold up
+func (h *BillingDisputeHandler) GetOpenDisputeReport(c *gin.Context) {
+	report, err := h.service.OpenDisputeReport(c)
+	if err != nil {
+		billingDisputeError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func billingDisputeID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid billing dispute ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func billingDisputeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBillingDisputeNotFound), errors.Is(err, services.ErrBillingNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrDisputeNotOpen):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBillingDispute):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
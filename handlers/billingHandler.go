@@ -2,17 +2,21 @@ package handlers
 
 import (
 	"RoyDental/models"
+	"RoyDental/repositories"
 	"RoyDental/services"
+	"errors"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type BillingHandler struct {
-	service *services.BillingService
+	service      *services.BillingService
+	savedFilters *services.SavedFilterService
 }
 
-func NewBillingHandler(service *services.BillingService) *BillingHandler {
-	return &BillingHandler{service: service}
+func NewBillingHandler(service *services.BillingService, savedFilters *services.SavedFilterService) *BillingHandler {
+	return &BillingHandler{service: service, savedFilters: savedFilters}
 }
 
 func (h *BillingHandler) CreateBilling(c *gin.Context) {
@@ -22,7 +26,7 @@ func (h *BillingHandler) CreateBilling(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &billing); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		billingError(c, err)
 		return
 	}
 	c.JSON(201, billing)
@@ -38,13 +42,28 @@ func (h *BillingHandler) GetBillingByID(c *gin.Context) {
 	c.JSON(200, billing)
 }
 
+// GetAllBillings streams the list, as NDJSON with Accept: application/x-ndjson, or returns
+// one page of it when ?limit= or an ?after= cursor is given. A saved filter (?filter_id=) and
+// ?sort= narrow and order the streamed list.
 func (h *BillingHandler) GetAllBillings(c *gin.Context) {
-	billings, err := h.service.GetAll(c)
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if c.Query("limit") != "" || c.Query("after") != "" {
+		h.listBillingsPage(c)
+		return
+	}
+	query, ok := filteredList(c, h.savedFilters, models.ListBillings)
+	if !ok {
+		return
+	}
+	stream := newJSONStream(c)
+	if query != nil {
+		stream.Close(h.service.StreamFiltered(c, *query, func(billing models.Billing) error {
+			return stream.Write(billing)
+		}))
 		return
 	}
-	c.JSON(200, billings)
+	stream.Close(h.service.Stream(c, func(billing models.Billing) error {
+		return stream.Write(billing)
+	}))
 }
 
 func (h *BillingHandler) UpdateBilling(c *gin.Context) {
@@ -56,7 +75,22 @@ func (h *BillingHandler) UpdateBilling(c *gin.Context) {
 	}
 	billing.BillingID = id
 	if err := h.service.Update(c, &billing); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		billingError(c, err)
+		return
+	}
+	c.JSON(200, billing)
+}
+
+// PatchBilling changes only the fields in the body, a JSON merge patch
+func (h *BillingHandler) PatchBilling(c *gin.Context) {
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	billing, err := h.service.Patch(c, c.Param("id"), patch)
+	if err != nil {
+		billingError(c, err)
 		return
 	}
 	c.JSON(200, billing)
@@ -65,8 +99,41 @@ func (h *BillingHandler) UpdateBilling(c *gin.Context) {
 func (h *BillingHandler) DeleteBilling(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.Delete(c, id); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		billingError(c, err)
 		return
 	}
 	c.JSON(204, gin.H{"message": "Billing deleted"})
 }
+
+func (h *BillingHandler) listBillingsPage(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	page, err := h.service.ListPage(c, c.Query("after"), limit)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, page)
+}
+
+func billingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrApprovalRequired):
+		approvalPending(c, err)
+	case errors.Is(err, services.ErrBillingNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProcedureNotFound), errors.Is(err, services.ErrInvalidAdjustment), errors.Is(err, services.ErrInvalidPatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrPeriodClosed), errors.Is(err, repositories.ErrDisputeNotOpen):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BreakGlassHandler struct {
+	service *services.BreakGlassService
+}
+
+func NewBreakGlassHandler(service *services.BreakGlassService) *BreakGlassHandler {
+	return &BreakGlassHandler{service: service}
+}
+
+// OpenBreakGlass grants the signed-in clinician emergency access to the patient's record for the
+// reason given; the admins are alerted straight away
+func (h *BreakGlassHandler) OpenBreakGlass(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var request models.BreakGlassRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	access := models.BreakGlassAccess{
+		PatientID: c.Param("id"),
+		UserID:    userID,
+		Reason:    request.Reason,
+		IP:        c.ClientIP(),
+	}
+	access.Role, _ = middlewares.ExtractUserRoleFromContext(c.Request.Context())
+	if err := h.service.Open(c, &access); err != nil {
+		breakGlassError(c, err)
+		return
+	}
+	c.JSON(201, access)
+}
+
+// GetBreakGlassAccesses lists emergency accesses newest first, optionally of a ?patient_id=, by a
+// ?user_id=, only those not reviewed yet with ?unreviewed=true, and up to ?limit=
+func (h *BreakGlassHandler) GetBreakGlassAccesses(c *gin.Context) {
+	filter := models.BreakGlassFilter{PatientID: c.Query("patient_id"), Unreviewed: c.Query("unreviewed") == "true"}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter.UserID = &userID
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	filter.Limit = limit
+	accesses, err := h.service.List(c, filter)
+	if err != nil {
+		breakGlassError(c, err)
+		return
+	}
+	c.JSON(200, accesses)
+}
+
+// ReviewBreakGlassAccess records that the signed-in admin looked into an emergency access
+func (h *BreakGlassHandler) ReviewBreakGlassAccess(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid break-glass access ID"})
+		return
+	}
+	var review models.BreakGlassReview
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&review); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	access, err := h.service.Review(c, uint(id), review)
+	if err != nil {
+		breakGlassError(c, err)
+		return
+	}
+	c.JSON(200, access)
+}
+
+func breakGlassError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBreakGlassNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBreakGlass):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
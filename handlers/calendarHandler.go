@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarHandler struct {
+	service *services.CalendarService
+}
+
+func NewCalendarHandler(service *services.CalendarService) *CalendarHandler {
+	return &CalendarHandler{service: service}
+}
+
+// GetCalendarFeed serves a doctor's appointments as an iCalendar feed for calendar subscriptions
+func (h *CalendarHandler) GetCalendarFeed(c *gin.Context) {
+	feed, err := h.service.Feed(c, c.Param("id"), c.Query("token"))
+	if err != nil {
+		calendarError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(200, "text/calendar; charset=utf-8", feed)
+}
+
+// GetCalendarLink returns the subscription link of a doctor's feed; ?private=true hides patient names
+func (h *CalendarHandler) GetCalendarLink(c *gin.Context) {
+	link, err := h.service.Link(c, c.Param("id"), c.Query("private") == "true")
+	if err != nil {
+		calendarError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"url": link})
+}
+
+func calendarError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCalendarToken):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCalendarDoctorMissing):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CampaignHandler struct {
+	service *services.CampaignService
+}
+
+func NewCampaignHandler(service *services.CampaignService) *CampaignHandler {
+	return &CampaignHandler{service: service}
+}
+
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var campaign models.Campaign
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &campaign); err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(201, campaign)
+}
+
+// GetCampaigns lists the campaigns, those in the ?status= when it is given
+func (h *CampaignHandler) GetCampaigns(c *gin.Context) {
+	campaigns, err := h.service.List(c, c.Query("status"))
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, campaigns)
+}
+
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	campaign, err := h.service.Get(c, id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, campaign)
+}
+
+func (h *CampaignHandler) UpdateCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	var update models.Campaign
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	campaign, err := h.service.Update(c, id, update)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, campaign)
+}
+
+func (h *CampaignHandler) DeleteCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Campaign deleted"})
+}
+
+// PreviewCampaign shows the audience the campaign would reach if sent now and a sample of its messages
+func (h *CampaignHandler) PreviewCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	preview, err := h.service.Preview(c, id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, preview)
+}
+
+// ScheduleCampaign queues the campaign to be sent at the send_at given, straight away without one
+func (h *CampaignHandler) ScheduleCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	var schedule models.CampaignSchedule
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	campaign, err := h.service.Schedule(c, id, schedule.SendAt)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, campaign)
+}
+
+func (h *CampaignHandler) CancelCampaign(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	campaign, err := h.service.Cancel(c, id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, campaign)
+}
+
+// GetCampaignRecipients lists up to ?limit= of the patients the campaign was sent to, those in the
+// ?status= when it is given
+func (h *CampaignHandler) GetCampaignRecipients(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	recipients, err := h.service.Recipients(c, id, c.Query("status"), limit)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, recipients)
+}
+
+// GetCampaignReport reports how the campaign's messages fared and who opted out
+func (h *CampaignHandler) GetCampaignReport(c *gin.Context) {
+	id, ok := campaignID(c)
+	if !ok {
+		return
+	}
+	report, err := h.service.Report(c, id)
+	if err != nil {
+		campaignError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func campaignID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid campaign ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func campaignError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCampaignNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCampaign):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCampaignStatus):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CareRuleHandler struct {
+	service *services.CareRuleService
+}
+
+func NewCareRuleHandler(service *services.CareRuleService) *CareRuleHandler {
+	return &CareRuleHandler{service: service}
+}
+
+// CreateCareRule sets up an action taken when a bill is created or an appointment fulfilled
+func (h *CareRuleHandler) CreateCareRule(c *gin.Context) {
+	var rule models.CareRule
+	rule.Active = true
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &rule); err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(201, rule)
+}
+
+func (h *CareRuleHandler) GetCareRules(c *gin.Context) {
+	rules, err := h.service.List(c)
+	if err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, rules)
+}
+
+func (h *CareRuleHandler) GetCareRule(c *gin.Context) {
+	id, ok := careRuleParamID(c)
+	if !ok {
+		return
+	}
+	rule, err := h.service.Get(c, id)
+	if err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, rule)
+}
+
+func (h *CareRuleHandler) UpdateCareRule(c *gin.Context) {
+	id, ok := careRuleParamID(c)
+	if !ok {
+		return
+	}
+	var rule models.CareRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = id
+	if err := h.service.Update(c, &rule); err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, rule)
+}
+
+func (h *CareRuleHandler) DeleteCareRule(c *gin.Context) {
+	id, ok := careRuleParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Care rule deleted successfully"})
+}
+
+// GetRecalls lists the patients to ask back, in ?status= (due by default) and falling due before
+// ?before= (YYYY-MM-DD, tomorrow by default)
+func (h *CareRuleHandler) GetRecalls(c *gin.Context) {
+	recalls, err := h.service.Recalls(c, c.Query("status"), c.Query("before"))
+	if err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, recalls)
+}
+
+// UpdateRecall records that a recalled patient booked or was dismissed
+func (h *CareRuleHandler) UpdateRecall(c *gin.Context) {
+	id, ok := careRuleParamID(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.SetRecallStatus(c, id, request.Status); err != nil {
+		careRuleError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"id": id, "status": request.Status})
+}
+
+func careRuleParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func careRuleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCareRuleNotFound), errors.Is(err, services.ErrRecallNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCareRule), errors.Is(err, services.ErrInvalidRecall):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CaseExportHandler struct {
+	service *services.CaseExportService
+}
+
+func NewCaseExportHandler(service *services.CaseExportService) *CaseExportHandler {
+	return &CaseExportHandler{service: service}
+}
+
+// RequestCaseExport asks for the treatment plan to be exported as an anonymized teaching case,
+// answering 202 with the approval it waits for
+func (h *CaseExportHandler) RequestCaseExport(c *gin.Context) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return
+	}
+	var request models.CaseExportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	caseExportError(c, h.service.Request(c, c.Param("patient_id"), treatmentPlanID, request))
+}
+
+// DownloadCaseExport sends the package of an approved export, by the ID of its approval
+func (h *CaseExportHandler) DownloadCaseExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid case export ID"})
+		return
+	}
+	file, err := h.service.Download(c, uint(id))
+	if err != nil {
+		caseExportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+file.Name+`"`)
+	c.Data(200, file.ContentType, file.Content)
+}
+
+func caseExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrApprovalRequired):
+		approvalPending(c, err)
+	case errors.Is(err, services.ErrCaseExportNotFound), errors.Is(err, services.ErrTreatmentPlanNotFound),
+		errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCaseExport):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCaseExportNotApproved):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CaseImageHandler struct {
+	service *services.CaseImageService
+}
+
+func NewCaseImageHandler(service *services.CaseImageService) *CaseImageHandler {
+	return &CaseImageHandler{service: service}
+}
+
+func (h *CaseImageHandler) CreateImagePair(c *gin.Context) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return
+	}
+	var pair models.CaseImagePair
+	if err := c.ShouldBindJSON(&pair); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, c.Param("patient_id"), treatmentPlanID, &pair); err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(201, pair)
+}
+
+func (h *CaseImageHandler) GetImagePairs(c *gin.Context) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return
+	}
+	pairs, err := h.service.List(c, c.Param("patient_id"), treatmentPlanID)
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, pairs)
+}
+
+func (h *CaseImageHandler) GetImagePair(c *gin.Context) {
+	treatmentPlanID, id, ok := caseImagePairIDs(c)
+	if !ok {
+		return
+	}
+	pair, err := h.service.Get(c, c.Param("patient_id"), treatmentPlanID, id)
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, pair)
+}
+
+// UpdateImagePair changes the treatment and images of a pair; its consent is left as recorded
+func (h *CaseImageHandler) UpdateImagePair(c *gin.Context) {
+	treatmentPlanID, id, ok := caseImagePairIDs(c)
+	if !ok {
+		return
+	}
+	var update models.CaseImagePair
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	pair, err := h.service.Update(c, c.Param("patient_id"), treatmentPlanID, id, update)
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, pair)
+}
+
+// UpdateImagePairConsent records the uses the patient agrees the pair may be shown for, e.g.
+// {"marketing": false, "presentation": true}
+func (h *CaseImageHandler) UpdateImagePairConsent(c *gin.Context) {
+	treatmentPlanID, id, ok := caseImagePairIDs(c)
+	if !ok {
+		return
+	}
+	var consent models.CaseImageConsent
+	if err := c.ShouldBindJSON(&consent); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	pair, err := h.service.UpdateConsent(c, c.Param("patient_id"), treatmentPlanID, id, consent)
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, pair)
+}
+
+func (h *CaseImageHandler) DeleteImagePair(c *gin.Context) {
+	treatmentPlanID, id, ok := caseImagePairIDs(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, c.Param("patient_id"), treatmentPlanID, id); err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Image pair deleted"})
+}
+
+// GetGallery lists the pairs consented to for ?consent= (marketing, presentation or teaching),
+// optionally those whose treatment contains ?q=
+func (h *CaseImageHandler) GetGallery(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	cases, err := h.service.Gallery(c, c.Query("consent"), c.Query("q"), limit)
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.JSON(200, cases)
+}
+
+// GetGalleryImage sends the before or after image of a gallery case consented to for ?consent=
+func (h *CaseImageHandler) GetGalleryImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid gallery case ID"})
+		return
+	}
+	image, err := h.service.GalleryImage(c, uint(id), c.Query("consent"), c.Param("side"))
+	if err != nil {
+		caseImageError(c, err)
+		return
+	}
+	c.Data(200, image.ContentType, image.Content)
+}
+
+func caseImagePlanID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("treatment_plan_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid treatment plan ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func caseImagePairIDs(c *gin.Context) (uint, uint, bool) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid image pair ID"})
+		return 0, 0, false
+	}
+	return treatmentPlanID, uint(id), true
+}
+
+func caseImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCaseImagePairNotFound), errors.Is(err, services.ErrTreatmentPlanNotFound),
+		errors.Is(err, services.ErrGalleryImageNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCaseImagePair):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChairHandler struct {
+	service *services.ChairService
+}
+
+func NewChairHandler(service *services.ChairService) *ChairHandler {
+	return &ChairHandler{service: service}
+}
+
+func (h *ChairHandler) CreateRoom(c *gin.Context) {
+	var room models.Room
+	if err := c.ShouldBindJSON(&room); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateRoom(c, &room); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(201, room)
+}
+
+// GetRooms lists every room with its chairs
+func (h *ChairHandler) GetRooms(c *gin.Context) {
+	rooms, err := h.service.ListRooms(c)
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, rooms)
+}
+
+func (h *ChairHandler) GetRoom(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid room ID")
+	if !ok {
+		return
+	}
+	room, err := h.service.GetRoom(c, id)
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, room)
+}
+
+func (h *ChairHandler) UpdateRoom(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid room ID")
+	if !ok {
+		return
+	}
+	var room models.Room
+	if err := c.ShouldBindJSON(&room); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	room.ID = id
+	if err := h.service.UpdateRoom(c, &room); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, room)
+}
+
+func (h *ChairHandler) DeleteRoom(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid room ID")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteRoom(c, id); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Room deleted successfully"})
+}
+
+func (h *ChairHandler) CreateChair(c *gin.Context) {
+	chair := models.Chair{Active: true}
+	if err := c.ShouldBindJSON(&chair); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateChair(c, &chair); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(201, chair)
+}
+
+// GetChairs lists the chairs of every room, only the active ones with ?active=true
+func (h *ChairHandler) GetChairs(c *gin.Context) {
+	chairs, err := h.service.ListChairs(c, c.Query("active") == "true")
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, chairs)
+}
+
+func (h *ChairHandler) UpdateChair(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid chair ID")
+	if !ok {
+		return
+	}
+	chair := models.Chair{Active: true}
+	if err := c.ShouldBindJSON(&chair); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	chair.ID = id
+	if err := h.service.UpdateChair(c, &chair); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, chair)
+}
+
+func (h *ChairHandler) DeleteChair(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid chair ID")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteChair(c, id); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Chair deleted successfully"})
+}
+
+func (h *ChairHandler) CreateDowntime(c *gin.Context) {
+	var downtime models.Downtime
+	if err := c.ShouldBindJSON(&downtime); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateDowntime(c, &downtime); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(201, downtime)
+}
+
+func (h *ChairHandler) GetDowntime(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid downtime ID")
+	if !ok {
+		return
+	}
+	downtime, err := h.service.GetDowntime(c, id)
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, downtime)
+}
+
+// GetDowntimes lists downtime, of ?chair_id= or ?equipment= and overlapping the clinic days ?from=
+// through ?to= (YYYY-MM-DD) when given
+func (h *ChairHandler) GetDowntimes(c *gin.Context) {
+	var filter models.DowntimeFilter
+	if value := c.Query("chair_id"); value != "" {
+		chairID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid chair_id"})
+			return
+		}
+		id := uint(chairID)
+		filter.ChairID = &id
+	}
+	filter.Equipment = c.Query("equipment")
+	if value := c.Query("from"); value != "" {
+		from, err := models.ParseClinicDate(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+		start, _ := models.ClinicDay(from)
+		filter.From = &start
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := models.ParseClinicDate(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+		_, end := models.ClinicDay(to)
+		filter.To = &end
+	}
+	downtime, err := h.service.ListDowntime(c, filter)
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, downtime)
+}
+
+func (h *ChairHandler) UpdateDowntime(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid downtime ID")
+	if !ok {
+		return
+	}
+	var downtime models.Downtime
+	if err := c.ShouldBindJSON(&downtime); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	downtime.ID = id
+	if err := h.service.UpdateDowntime(c, &downtime); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, downtime)
+}
+
+func (h *ChairHandler) DeleteDowntime(c *gin.Context) {
+	id, ok := chairParamID(c, "Invalid downtime ID")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteDowntime(c, id); err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Downtime deleted successfully"})
+}
+
+// GetDowntimeReport returns the downtime hours per chair and equipment in the months ?from= through
+// ?to= (YYYY-MM), the last twelve months by default
+func (h *ChairHandler) GetDowntimeReport(c *gin.Context) {
+	now := models.ClinicNow()
+	from := c.DefaultQuery("from", now.AddDate(0, -11, 1-now.Day()).Format(models.FinancialPeriodLayout))
+	to := c.DefaultQuery("to", now.Format(models.FinancialPeriodLayout))
+	report, err := h.service.DowntimeReport(c, from, to)
+	if err != nil {
+		chairError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func chairParamID(c *gin.Context, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": message})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func chairError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound), errors.Is(err, services.ErrChairNotFound), errors.Is(err, services.ErrDowntimeNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidChair), errors.Is(err, services.ErrInvalidDowntime):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
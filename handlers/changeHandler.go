@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangeHandler struct {
+	service *services.ChangeService
+}
+
+func NewChangeHandler(service *services.ChangeService) *ChangeHandler {
+	return &ChangeHandler{service: service}
+}
+
+// GetChanges returns a handler listing the entity's records created, updated or deleted after the
+// RFC 3339 ?since= cursor. Clients poll again with next_since until has_more is false.
+func (h *ChangeHandler) GetChanges(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var since time.Time
+		if value := c.Query("since"); value != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				c.JSON(400, gin.H{"error": "Invalid since, expected an RFC 3339 timestamp"})
+				return
+			}
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 {
+			c.JSON(400, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		page, err := h.service.Changes(c, entity, since, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, page)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClaimHandler struct {
+	service *services.ClaimService
+}
+
+func NewClaimHandler(service *services.ClaimService) *ClaimHandler {
+	return &ClaimHandler{service: service}
+}
+
+// CreateClaim opens an insurance claim for a bill; the amount defaults to the part not paid in cash
+func (h *ClaimHandler) CreateClaim(c *gin.Context) {
+	var request struct {
+		Amount float64 `json:"amount"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	claim, err := h.service.CreateForBilling(c, c.Param("id"), request.Amount)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(201, claim)
+}
+
+// GetClaims lists claims, optionally by ?status= and ?insurance_company_id=
+func (h *ClaimHandler) GetClaims(c *gin.Context) {
+	claims, err := h.service.List(c, models.ClaimFilter{
+		Status:             c.Query("status"),
+		InsuranceCompanyID: c.Query("insurance_company_id"),
+	})
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, claims)
+}
+
+func (h *ClaimHandler) GetClaim(c *gin.Context) {
+	id, ok := claimParamID(c, "id", "Invalid claim ID")
+	if !ok {
+		return
+	}
+	claim, err := h.service.GetByID(c, id)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, claim)
+}
+
+func (h *ClaimHandler) ApproveClaim(c *gin.Context) {
+	id, ok := claimParamID(c, "id", "Invalid claim ID")
+	if !ok {
+		return
+	}
+	claim, err := h.service.Approve(c, id)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, claim)
+}
+
+// CreateBatch submits the insurer's approved claims for services from ?from= to ?to= (YYYY-MM-DD)
+// inclusive, defaulting to the previous month, in the insurer's claim format
+func (h *ClaimHandler) CreateBatch(c *gin.Context) {
+	now := models.ClinicNow()
+	from := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, models.ClinicLocation())
+	to := time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, models.ClinicLocation())
+	var request struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	var err error
+	if request.From != "" {
+		if from, err = models.ParseClinicDate(request.From); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if request.To != "" {
+		if to, err = models.ParseClinicDate(request.To); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	batch, err := h.service.CreateBatch(c, c.Param("id"), from, to)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(201, batch)
+}
+
+// GetBatches lists the insurer's claim batches, newest first
+func (h *ClaimHandler) GetBatches(c *gin.Context) {
+	batches, err := h.service.ListBatches(c, c.Param("id"))
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, batches)
+}
+
+// GetBatch returns a batch with the claims submitted in it
+func (h *ClaimHandler) GetBatch(c *gin.Context) {
+	id, ok := claimParamID(c, "id", "Invalid batch ID")
+	if !ok {
+		return
+	}
+	batch, err := h.service.GetBatch(c, id)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	claims, err := h.service.ListBatchClaims(c, id)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"batch": batch, "claims": claims})
+}
+
+// DownloadBatch returns the submission file sent to the insurer
+func (h *ClaimHandler) DownloadBatch(c *gin.Context) {
+	id, ok := claimParamID(c, "id", "Invalid batch ID")
+	if !ok {
+		return
+	}
+	batch, err := h.service.GetBatch(c, id)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+batch.FileName+`"`)
+	c.Data(200, batch.ContentType, batch.Content)
+}
+
+// UpdateBatchStatus records the insurer's response to a batch
+func (h *ClaimHandler) UpdateBatchStatus(c *gin.Context) {
+	id, ok := claimParamID(c, "id", "Invalid batch ID")
+	if !ok {
+		return
+	}
+	var request struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	batch, err := h.service.UpdateBatchStatus(c, id, request.Status, request.Note)
+	if err != nil {
+		claimError(c, err)
+		return
+	}
+	c.JSON(200, batch)
+}
+
+func claimParamID(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": message})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func claimError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrClaimNotFound), errors.Is(err, services.ErrClaimBatchNotFound),
+		errors.Is(err, services.ErrBillingNotFound), errors.Is(err, services.ErrInsuranceCompanyNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrClaimExists), errors.Is(err, services.ErrClaimNotPending),
+		errors.Is(err, services.ErrClaimBatchConflict), errors.Is(err, services.ErrInvalidBatchStatus):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidClaim), errors.Is(err, services.ErrNoApprovedClaims):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClinicalAuditHandler struct {
+	service *services.ClinicalAuditService
+}
+
+func NewClinicalAuditHandler(service *services.ClinicalAuditService) *ClinicalAuditHandler {
+	return &ClinicalAuditHandler{service: service}
+}
+
+// CreateClinicalAudit samples at random sample_size of the visits fulfilled from from to to
+// (YYYY-MM-DD), of doctor_id's when given
+func (h *ClinicalAuditHandler) CreateClinicalAudit(c *gin.Context) {
+	var audit models.ClinicalAudit
+	if err := c.ShouldBindJSON(&audit); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	created, err := h.service.Create(c, &audit)
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func (h *ClinicalAuditHandler) GetClinicalAudits(c *gin.Context) {
+	audits, err := h.service.List(c)
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, audits)
+}
+
+func (h *ClinicalAuditHandler) GetClinicalAudit(c *gin.Context) {
+	id, ok := clinicalAuditID(c, "id")
+	if !ok {
+		return
+	}
+	audit, err := h.service.Get(c, id)
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, audit)
+}
+
+func (h *ClinicalAuditHandler) DeleteClinicalAudit(c *gin.Context) {
+	id, ok := clinicalAuditID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Clinical audit deleted"})
+}
+
+// GetClinicalAuditWorksheet returns the audit's sampled visits with the examinations, treatment
+// plans and bills to review for each
+func (h *ClinicalAuditHandler) GetClinicalAuditWorksheet(c *gin.Context) {
+	id, ok := clinicalAuditID(c, "id")
+	if !ok {
+		return
+	}
+	worksheet, err := h.service.Worksheet(c, id)
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, worksheet)
+}
+
+// ScoreClinicalAuditItem records the signed-in auditor's score (1 to 5) and comments of a sampled visit
+func (h *ClinicalAuditHandler) ScoreClinicalAuditItem(c *gin.Context) {
+	auditID, ok := clinicalAuditID(c, "id")
+	if !ok {
+		return
+	}
+	itemID, ok := clinicalAuditID(c, "item_id")
+	if !ok {
+		return
+	}
+	var score models.ClinicalAuditScore
+	if err := c.ShouldBindJSON(&score); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	item, err := h.service.Score(c, auditID, itemID, score)
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, item)
+}
+
+// GetClinicalAuditTrends returns how scored visits fared month by month from ?from= to ?to=
+// (YYYY-MM-DD), the last twelve months by default, of ?doctor_id='s visits when given
+func (h *ClinicalAuditHandler) GetClinicalAuditTrends(c *gin.Context) {
+	trends, err := h.service.Trends(c, c.Query("from"), c.Query("to"), c.Query("doctor_id"))
+	if err != nil {
+		clinicalAuditError(c, err)
+		return
+	}
+	c.JSON(200, trends)
+}
+
+func clinicalAuditID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid clinical audit ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func clinicalAuditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrClinicalAuditNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidClinicalAudit):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrClinicalAuditOwnVisit):
+		c.JSON(403, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
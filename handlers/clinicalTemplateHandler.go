@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClinicalTemplateHandler struct {
+	service *services.ClinicalTemplateService
+}
+
+func NewClinicalTemplateHandler(service *services.ClinicalTemplateService) *ClinicalTemplateHandler {
+	return &ClinicalTemplateHandler{service: service}
+}
+
+type templateRequest struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+func (h *ClinicalTemplateHandler) CreateTemplate(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var request templateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	template := models.ClinicalTemplate{OwnerID: userID, Kind: request.Kind, Name: request.Name, Body: request.Body}
+	if err := h.service.Create(c, &template); err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(201, template)
+}
+
+// GetTemplates lists the caller's templates and the shared ones, filtered by kind and by
+// scope=mine (own templates only) or scope=shared (clinic-wide templates only)
+func (h *ClinicalTemplateHandler) GetTemplates(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	filter := models.TemplateFilter{Kind: c.Query("kind")}
+	switch c.Query("scope") {
+	case "":
+	case "mine":
+		filter.OwnerID = userID
+	case "shared":
+		filter.Sharing = models.TemplateShared
+	default:
+		c.JSON(400, gin.H{"error": "Invalid scope"})
+		return
+	}
+	templates, err := h.service.List(c, userID, filter)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, templates)
+}
+
+// GetPendingTemplates lists the templates awaiting approval for clinic-wide use
+func (h *ClinicalTemplateHandler) GetPendingTemplates(c *gin.Context) {
+	templates, err := h.service.Pending(c)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, templates)
+}
+
+func (h *ClinicalTemplateHandler) GetTemplate(c *gin.Context) {
+	id, userID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	template, err := h.service.Get(c, id, userID, isAdmin(c))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+func (h *ClinicalTemplateHandler) UpdateTemplate(c *gin.Context) {
+	id, userID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	var request templateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	template, err := h.service.Update(c, &models.ClinicalTemplate{ID: id, Kind: request.Kind, Name: request.Name, Body: request.Body}, userID)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+func (h *ClinicalTemplateHandler) DeleteTemplate(c *gin.Context) {
+	id, userID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id, userID, isAdmin(c)); err != nil {
+		templateError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+// ShareTemplate asks for the caller's template to be approved for clinic-wide use
+func (h *ClinicalTemplateHandler) ShareTemplate(c *gin.Context) {
+	id, userID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	template, err := h.service.RequestSharing(c, id, userID)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+// ReviewTemplate approves or declines sharing a template, e.g. {"approve": false, "note": "Duplicates the clinic's template"}
+func (h *ClinicalTemplateHandler) ReviewTemplate(c *gin.Context) {
+	id, userID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Approve *bool  `json:"approve" binding:"required"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	template, err := h.service.Review(c, id, userID, *request.Approve, request.Note)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+func templateParams(c *gin.Context) (uint, int64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid template ID"})
+		return 0, 0, false
+	}
+	userID, ok := contextUserID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint(id), userID, true
+}
+
+func isAdmin(c *gin.Context) bool {
+	role, _ := middlewares.ExtractUserRoleFromContext(c.Request.Context())
+	return role == "Admin"
+}
+
+// isTemplateInsertError reports whether a record could not be created from the template it named
+func isTemplateInsertError(err error) bool {
+	return errors.Is(err, services.ErrTemplateNotFound) || errors.Is(err, services.ErrTemplateWrongKind)
+}
+
+func templateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTemplateNotOwned):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTemplateNotInReview), errors.Is(err, services.ErrTemplateAlreadyShared):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTemplate):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClosureHandler struct {
+	service *services.ClosureService
+}
+
+func NewClosureHandler(service *services.ClosureService) *ClosureHandler {
+	return &ClosureHandler{service: service}
+}
+
+// CreateClosure declares a public holiday or closure. The response lists the appointments already
+// booked on it, with a warning when there are any.
+func (h *ClosureHandler) CreateClosure(c *gin.Context) {
+	var closure models.Closure
+	if err := c.ShouldBindJSON(&closure); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := h.service.Create(c, &closure)
+	if err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(201, result)
+}
+
+// GetClosures lists the closures overlapping ?from= through ?to= (YYYY-MM-DD, both optional)
+func (h *ClosureHandler) GetClosures(c *gin.Context) {
+	closures, err := h.service.List(c, c.Query("from"), c.Query("to"))
+	if err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(200, closures)
+}
+
+func (h *ClosureHandler) GetClosure(c *gin.Context) {
+	id, ok := closureParamID(c)
+	if !ok {
+		return
+	}
+	closure, err := h.service.Get(c, id)
+	if err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(200, closure)
+}
+
+// GetClosureAppointments lists the appointments still booked on a closure
+func (h *ClosureHandler) GetClosureAppointments(c *gin.Context) {
+	id, ok := closureParamID(c)
+	if !ok {
+		return
+	}
+	appointments, err := h.service.Appointments(c, id)
+	if err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(200, appointments)
+}
+
+func (h *ClosureHandler) UpdateClosure(c *gin.Context) {
+	id, ok := closureParamID(c)
+	if !ok {
+		return
+	}
+	var closure models.Closure
+	if err := c.ShouldBindJSON(&closure); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	closure.ID = id
+	result, err := h.service.Update(c, &closure)
+	if err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (h *ClosureHandler) DeleteClosure(c *gin.Context) {
+	id, ok := closureParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		closureError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Closure deleted successfully"})
+}
+
+func closureParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid closure ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func closureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrClosureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidClosure):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
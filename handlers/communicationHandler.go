@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommunicationHandler struct {
+	service *services.CommunicationService
+}
+
+func NewCommunicationHandler(service *services.CommunicationService) *CommunicationHandler {
+	return &CommunicationHandler{service: service}
+}
+
+// GetCommunicationPreferences shows how a patient agreed to be contacted
+func (h *CommunicationHandler) GetCommunicationPreferences(c *gin.Context) {
+	preference, err := h.service.GetPreference(c, c.Param("patient_id"))
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, preference)
+}
+
+// UpdateCommunicationPreferences records the consents a patient gives or withdraws at the desk.
+// Fields left out of the body keep their value.
+func (h *CommunicationHandler) UpdateCommunicationPreferences(c *gin.Context) {
+	var update models.CommunicationPreferenceUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	preference, err := h.service.UpdatePreference(c, c.Param("patient_id"), update)
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, preference)
+}
+
+// GetConsents lists the consents a patient gave and withdrew, newest first
+func (h *CommunicationHandler) GetConsents(c *gin.Context) {
+	records, err := h.service.ListConsents(c, c.Param("patient_id"))
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, records)
+}
+
+// GetCommunicationLog lists the messages sent to a patient, newest first
+func (h *CommunicationHandler) GetCommunicationLog(c *gin.Context) {
+	entries, err := h.service.ListLog(c, c.Param("patient_id"))
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, entries)
+}
+
+// GetPortalPreferences shows the preferences of the patient a signed portal link is for
+func (h *CommunicationHandler) GetPortalPreferences(c *gin.Context) {
+	preferences, err := h.service.GetPortalPreferences(c, c.Query("patient"), c.Query("sig"))
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, preferences)
+}
+
+// UpdatePortalPreferences records the choices a patient makes through a signed portal link
+func (h *CommunicationHandler) UpdatePortalPreferences(c *gin.Context) {
+	var update models.CommunicationPreferenceUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	preferences, err := h.service.UpdatePortalPreferences(c, c.Query("patient"), c.Query("sig"), update)
+	if err != nil {
+		communicationError(c, err)
+		return
+	}
+	c.JSON(200, preferences)
+}
+
+func communicationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPortalSignature):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
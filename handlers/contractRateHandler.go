@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContractRateHandler struct {
+	service *services.ContractRateService
+}
+
+func NewContractRateHandler(service *services.ContractRateService) *ContractRateHandler {
+	return &ContractRateHandler{service: service}
+}
+
+// contractRateRequest is a contract rate with its effective date as YYYY-MM-DD
+type contractRateRequest struct {
+	ProcedureID   uint    `json:"procedure_id"`
+	Amount        float64 `json:"amount"`
+	EffectiveFrom string  `json:"effective_from"`
+}
+
+func (h *ContractRateHandler) CreateContractRate(c *gin.Context) {
+	var request contractRateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	rate, ok := request.rate(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Create(c, rate); err != nil {
+		contractRateError(c, err)
+		return
+	}
+	c.JSON(201, rate)
+}
+
+// GetContractRates returns the insurer's price list
+func (h *ContractRateHandler) GetContractRates(c *gin.Context) {
+	rates, err := h.service.List(c, c.Param("id"))
+	if err != nil {
+		contractRateError(c, err)
+		return
+	}
+	c.JSON(200, rates)
+}
+
+func (h *ContractRateHandler) UpdateContractRate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("rate_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid contract rate ID"})
+		return
+	}
+	var request contractRateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	rate, ok := request.rate(c)
+	if !ok {
+		return
+	}
+	rate.ID = uint(id)
+	if err := h.service.Update(c, rate); err != nil {
+		contractRateError(c, err)
+		return
+	}
+	c.JSON(200, rate)
+}
+
+func (h *ContractRateHandler) DeleteContractRate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("rate_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid contract rate ID"})
+		return
+	}
+	if err := h.service.Delete(c, c.Param("id"), uint(id)); err != nil {
+		contractRateError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Contract rate deleted successfully"})
+}
+
+// GetVarianceReport compares contract and billed amounts from ?from= to ?to= (YYYY-MM-DD) inclusive,
+// defaulting to the current month, for one insurer when ?insurance_company_id= is given
+func (h *ContractRateHandler) GetVarianceReport(c *gin.Context) {
+	now := models.ClinicNow()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, models.ClinicLocation())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, models.ClinicLocation())
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	report, err := h.service.VarianceReport(c, from, to, c.Query("insurance_company_id"))
+	if err != nil {
+		contractRateError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func (r contractRateRequest) rate(c *gin.Context) (*models.ContractRate, bool) {
+	rate := &models.ContractRate{
+		InsuranceCompanyID: c.Param("id"),
+		ProcedureID:        r.ProcedureID,
+		Amount:             r.Amount,
+	}
+	if r.EffectiveFrom != "" {
+		effectiveFrom, err := models.ParseClinicDate(r.EffectiveFrom)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid effective_from, expected YYYY-MM-DD"})
+			return nil, false
+		}
+		rate.EffectiveFrom = effectiveFrom
+	}
+	return rate, true
+}
+
+func contractRateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrContractRateNotFound), errors.Is(err, services.ErrInsuranceCompanyNotFound), errors.Is(err, services.ErrProcedureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidContractRate):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
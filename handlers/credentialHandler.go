@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"RoyDental/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CredentialHandler struct {
+	service *services.CredentialService
+}
+
+func NewCredentialHandler(service *services.CredentialService) *CredentialHandler {
+	return &CredentialHandler{service: service}
+}
+
+// GetComplianceReport reports every doctor's licence, indemnity insurance and CPD expiry, and
+// whether the doctor can be scheduled
+func (h *CredentialHandler) GetComplianceReport(c *gin.Context) {
+	report, err := h.service.Report(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
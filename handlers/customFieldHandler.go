@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CustomFieldHandler struct {
+	service *services.CustomFieldService
+}
+
+func NewCustomFieldHandler(service *services.CustomFieldService) *CustomFieldHandler {
+	return &CustomFieldHandler{service: service}
+}
+
+// CreateCustomField adds a field to patients or appointments
+func (h *CustomFieldHandler) CreateCustomField(c *gin.Context) {
+	var field models.CustomField
+	field.Active = true
+	if err := c.ShouldBindJSON(&field); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &field); err != nil {
+		customFieldError(c, err)
+		return
+	}
+	c.JSON(201, field)
+}
+
+// GetCustomFields lists the fields, of the ?entity= (patient or appointment) only when given
+func (h *CustomFieldHandler) GetCustomFields(c *gin.Context) {
+	fields, err := h.service.List(c, c.Query("entity"))
+	if err != nil {
+		customFieldError(c, err)
+		return
+	}
+	c.JSON(200, fields)
+}
+
+func (h *CustomFieldHandler) GetCustomField(c *gin.Context) {
+	id, ok := customFieldParamID(c)
+	if !ok {
+		return
+	}
+	field, err := h.service.Get(c, id)
+	if err != nil {
+		customFieldError(c, err)
+		return
+	}
+	c.JSON(200, field)
+}
+
+func (h *CustomFieldHandler) UpdateCustomField(c *gin.Context) {
+	id, ok := customFieldParamID(c)
+	if !ok {
+		return
+	}
+	var field models.CustomField
+	if err := c.ShouldBindJSON(&field); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	field.ID = id
+	if err := h.service.Update(c, &field); err != nil {
+		customFieldError(c, err)
+		return
+	}
+	c.JSON(200, field)
+}
+
+// DeleteCustomField removes a field and every value recorded for it
+func (h *CustomFieldHandler) DeleteCustomField(c *gin.Context) {
+	id, ok := customFieldParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		customFieldError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Custom field deleted successfully"})
+}
+
+func customFieldParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func customFieldError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCustomFieldNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCustomFieldExists):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCustomField):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
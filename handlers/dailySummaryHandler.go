@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DailySummaryHandler struct {
+	service *services.DailySummaryService
+}
+
+func NewDailySummaryHandler(service *services.DailySummaryService) *DailySummaryHandler {
+	return &DailySummaryHandler{service: service}
+}
+
+// GetDailySummary reports the key figures of ?date= (YYYY-MM-DD), today by default, as emailed to
+// management at the end of the day
+func (h *DailySummaryHandler) GetDailySummary(c *gin.Context) {
+	day := h.service.Today()
+	if value := c.Query("date"); value != "" {
+		var err error
+		if day, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	summary, err := h.service.Summary(c, day)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidReportPeriod) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, summary)
+}
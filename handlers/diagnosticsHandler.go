@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiagnosticsHandler struct {
+	service *services.DiagnosticsService
+}
+
+func NewDiagnosticsHandler(service *services.DiagnosticsService) *DiagnosticsHandler {
+	return &DiagnosticsHandler{service: service}
+}
+
+// GetDiagnostics returns every diagnostics section at once, with the errors of those that failed
+func (h *DiagnosticsHandler) GetDiagnostics(c *gin.Context) {
+	c.JSON(200, h.service.Collect(c))
+}
+
+// GetSlowQueries returns the ?limit= statements slowest on average (20 by default)
+func (h *DiagnosticsHandler) GetSlowQueries(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+	queries, err := h.service.SlowQueries(c, limit)
+	if err != nil {
+		diagnosticsError(c, err)
+		return
+	}
+	c.JSON(200, queries)
+}
+
+func (h *DiagnosticsHandler) GetLockWaits(c *gin.Context) {
+	waits, err := h.service.LockWaits(c)
+	if err != nil {
+		diagnosticsError(c, err)
+		return
+	}
+	c.JSON(200, waits)
+}
+
+func (h *DiagnosticsHandler) GetRedisLatency(c *gin.Context) {
+	latency, err := h.service.Redis(c)
+	if err != nil {
+		diagnosticsError(c, err)
+		return
+	}
+	c.JSON(200, latency)
+}
+
+// GetSchemaDrift lists the tables, columns, indexes, sequences and migrations the database lacks
+func (h *DiagnosticsHandler) GetSchemaDrift(c *gin.Context) {
+	drift, err := h.service.Schema(c)
+	if err != nil {
+		diagnosticsError(c, err)
+		return
+	}
+	c.JSON(200, drift)
+}
+
+func (h *DiagnosticsHandler) GetRuntimeStats(c *gin.Context) {
+	c.JSON(200, h.service.Runtime())
+}
+
+func diagnosticsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDiagnostics):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrSlowQueriesUnavailable), errors.Is(err, repositories.ErrRedisUnavailable):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
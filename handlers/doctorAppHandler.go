@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+	"RoyDental/models"
+	"RoyDental/services"
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DoctorAppHandler struct {
+	service *services.DoctorAppService
+}
+
+func NewDoctorAppHandler(service *services.DoctorAppService) *DoctorAppHandler {
+	return &DoctorAppHandler{service: service}
+}
+
+// GetMyAppointments lists the signed-in doctor's appointments on ?date= (YYYY-MM-DD), today by default
+func (h *DoctorAppHandler) GetMyAppointments(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+
+	day := models.ClinicNow()
+	if date := c.Query("date"); date != "" {
+		parsed, err := models.ParseClinicDate(date)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		day = parsed
+	}
+
+	appointments, err := h.service.Appointments(c, userID, day)
+	if err != nil {
+		doctorAppError(c, err)
+		return
+	}
+	c.JSON(200, appointments)
+}
+
+func (h *DoctorAppHandler) GetMyPatientSummary(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.service.PatientSummary(c, userID, c.Param("id"))
+	if err != nil {
+		doctorAppError(c, err)
+		return
+	}
+	c.JSON(200, summary)
+}
+
+// GetMyPatients lists the signed-in doctor's patients a page at a time, see listDoctorPage
+func (h *DoctorAppHandler) GetMyPatients(c *gin.Context) {
+	listDoctorPage(c, h.service.PatientsPage)
+}
+
+// GetMyAppointmentsPage lists the signed-in doctor's appointments a page at a time, see listDoctorPage
+func (h *DoctorAppHandler) GetMyAppointmentsPage(c *gin.Context) {
+	listDoctorPage(c, h.service.AppointmentsPage)
+}
+
+// GetMyBillings lists the signed-in doctor's billings a page at a time, see listDoctorPage
+func (h *DoctorAppHandler) GetMyBillings(c *gin.Context) {
+	listDoctorPage(c, h.service.BillingsPage)
+}
+
+// listDoctorPage answers with a page of up to ?limit= rows newest first, after the ?after= cursor
+// of the previous page
+func listDoctorPage[T any](c *gin.Context, page func(ctx context.Context, userID int64, after string, limit int) (*models.ListPage[T], error)) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	result, err := page(c, userID, c.Query("after"), limit)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		doctorAppError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// contextUserID reads the user ID put in the request context by the authorization middleware
+func contextUserID(c *gin.Context) (int64, bool) {
+	userIDStr, err := middlewares.ExtractUserIDFromContext(c.Request.Context())
+	if err != nil {
+		c.JSON(401, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func doctorAppError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDoctorNotLinked):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDoctorPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -3,6 +3,9 @@ package handlers
 import (
 	"RoyDental/models"
 	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,7 +25,7 @@ func (h *DoctorHandler) CreateDoctor(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &doctor); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		doctorError(c, err)
 		return
 	}
 	c.JSON(201, doctor)
@@ -38,7 +41,27 @@ func (h *DoctorHandler) GetDoctorByID(c *gin.Context) {
 	c.JSON(200, doctor)
 }
 
+// GetAllDoctors lists the doctors, narrowed to a ?specialty= or to those performing ?procedure_id= when given
 func (h *DoctorHandler) GetAllDoctors(c *gin.Context) {
+	filter := models.DoctorFilter{Specialty: c.Query("specialty")}
+	if value := c.Query("procedure_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid procedure ID"})
+			return
+		}
+		filter.ProcedureID = uint(id)
+	}
+	if filter != (models.DoctorFilter{}) {
+		doctors, err := h.service.Search(c, filter)
+		if err != nil {
+			doctorError(c, err)
+			return
+		}
+		c.JSON(200, doctors)
+		return
+	}
+
 	doctors, err := h.service.GetAll(c)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
@@ -56,7 +79,22 @@ func (h *DoctorHandler) UpdateDoctor(c *gin.Context) {
 	}
 	doctor.ID = id
 	if err := h.service.Update(c, &doctor); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		doctorError(c, err)
+		return
+	}
+	c.JSON(200, doctor)
+}
+
+// PatchDoctor changes only the fields in the body, a JSON merge patch
+func (h *DoctorHandler) PatchDoctor(c *gin.Context) {
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	doctor, err := h.service.Patch(c, c.Param("id"), patch)
+	if err != nil {
+		doctorError(c, err)
 		return
 	}
 	c.JSON(200, doctor)
@@ -70,3 +108,70 @@ func (h *DoctorHandler) DeleteDoctor(c *gin.Context) {
 	}
 	c.JSON(204, gin.H{"message": "Doctor deleted"})
 }
+
+// GetDoctorProcedures lists the procedures the doctor performs
+func (h *DoctorHandler) GetDoctorProcedures(c *gin.Context) {
+	procedures, err := h.service.GetProcedures(c, c.Param("id"))
+	if err != nil {
+		doctorError(c, err)
+		return
+	}
+	c.JSON(200, procedures)
+}
+
+// SetDoctorProcedures replaces the procedures the doctor performs with {"procedure_ids": [...]}
+func (h *DoctorHandler) SetDoctorProcedures(c *gin.Context) {
+	var input struct {
+		ProcedureIDs []uint `json:"procedure_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	procedures, err := h.service.SetProcedures(c, c.Param("id"), input.ProcedureIDs)
+	if err != nil {
+		doctorError(c, err)
+		return
+	}
+	c.JSON(200, procedures)
+}
+
+// GetRevenueBySpecialty reports billing by doctor specialty between the from and to dates (YYYY-MM-DD),
+// the last 30 days by default
+func (h *DoctorHandler) GetRevenueBySpecialty(c *gin.Context) {
+	to := models.ClinicNow()
+	from := to.AddDate(0, 0, -29)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, models.ClinicLocation())
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, models.ClinicLocation())
+
+	revenue, err := h.service.RevenueBySpecialty(c, from, to)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"from": from.Format("2006-01-02"), "to": to.Format("2006-01-02"), "specialties": revenue})
+}
+
+func doctorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDoctorNotFound), errors.Is(err, services.ErrProcedureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSpecialty), errors.Is(err, services.ErrInvalidPatch), errors.Is(err, services.ErrInvalidPhotoURL):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
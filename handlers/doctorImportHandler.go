@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDoctorImportSize is the largest CSV file accepted for a doctor import
+const maxDoctorImportSize = 5 << 20
+
+type DoctorImportHandler struct {
+	service *services.DoctorImportService
+}
+
+func NewDoctorImportHandler(service *services.DoctorImportService) *DoctorImportHandler {
+	return &DoctorImportHandler{service: service}
+}
+
+// ImportDoctors imports the doctors of a CSV file, sent as the multipart "file" or as the body, and
+// with ?dry_run=true only reports what the import would change
+func (h *DoctorImportHandler) ImportDoctors(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDoctorImportSize+1<<20)
+
+	var file io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(413, gin.H{"error": "The file is too large"})
+				return
+			}
+			c.JSON(400, gin.H{"error": "A file is required"})
+			return
+		}
+		opened, err := header.Open()
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		defer opened.Close()
+		file = opened
+	}
+
+	report, err := h.service.ImportCSV(c, io.LimitReader(file, maxDoctorImportSize), dryRun)
+	if err != nil {
+		doctorImportError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+// SyncDoctors syncs the doctors from the staff directory now, or with ?dry_run=true reports what the
+// sync would change
+func (h *DoctorImportHandler) SyncDoctors(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	report, err := h.service.Sync(c, dryRun)
+	if err != nil {
+		doctorImportError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func doctorImportError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(413, gin.H{"error": "The file is too large"})
+	case errors.Is(err, services.ErrInvalidDoctorImport):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStaffDirectoryDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocumentShareHandler struct {
+	service *services.DocumentShareService
+}
+
+func NewDocumentShareHandler(service *services.DocumentShareService) *DocumentShareHandler {
+	return &DocumentShareHandler{service: service}
+}
+
+// CreateDocumentShare sends the patient a link to the documents, by email or SMS
+func (h *DocumentShareHandler) CreateDocumentShare(c *gin.Context) {
+	var request models.DocumentShareRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	share, err := h.service.Share(c, c.Param("patient_id"), request)
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.JSON(201, share)
+}
+
+func (h *DocumentShareHandler) GetDocumentShares(c *gin.Context) {
+	shares, err := h.service.List(c, c.Param("patient_id"))
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.JSON(200, shares)
+}
+
+// GetDocumentShare returns a share with every time its link was opened or a document downloaded
+func (h *DocumentShareHandler) GetDocumentShare(c *gin.Context) {
+	id, ok := documentShareParamID(c, "id")
+	if !ok {
+		return
+	}
+	share, err := h.service.Get(c, c.Param("patient_id"), id)
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.JSON(200, share)
+}
+
+// RevokeDocumentShare stops a share's link from working before it expires
+func (h *DocumentShareHandler) RevokeDocumentShare(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := documentShareParamID(c, "id")
+	if !ok {
+		return
+	}
+	share, err := h.service.Revoke(c, c.Param("patient_id"), id, userID)
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.JSON(200, share)
+}
+
+// GetSharedDocuments lists the documents shared with a patient, authenticated by the link's
+// signature only
+func (h *DocumentShareHandler) GetSharedDocuments(c *gin.Context) {
+	id, ok := documentShareParamID(c, "id")
+	if !ok {
+		return
+	}
+	shared, err := h.service.Linked(c, id, c.Query("expires"), c.Query("signature"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.JSON(200, shared)
+}
+
+// GetSharedDocument lets the patient download a document shared with them, authenticated by the
+// link's signature only
+func (h *DocumentShareHandler) GetSharedDocument(c *gin.Context) {
+	id, ok := documentShareParamID(c, "id")
+	if !ok {
+		return
+	}
+	documentID, ok := documentShareParamID(c, "document_id")
+	if !ok {
+		return
+	}
+	file, err := h.service.LinkedFile(c, id, documentID, c.Query("expires"), c.Query("signature"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		documentShareError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", `attachment; filename="`+file.Name+`"`)
+	c.Data(200, file.ContentType, file.Content)
+}
+
+func documentShareParamID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func documentShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDocumentShareNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDocumentShare):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDocumentShareLink):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentShareNotPermitted):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentShareNotSent):
+		c.JSON(502, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentSharingDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DunningHandler struct {
+	service *services.DunningService
+}
+
+func NewDunningHandler(service *services.DunningService) *DunningHandler {
+	return &DunningHandler{service: service}
+}
+
+// dunningStageRequest is a dunning stage; stages are active unless active is false
+type dunningStageRequest struct {
+	DaysOverdue int    `json:"days_overdue" binding:"required"`
+	Subject     string `json:"subject" binding:"required"`
+	Body        string `json:"body" binding:"required"`
+	SendEmail   bool   `json:"send_email"`
+	SendSMS     bool   `json:"send_sms"`
+	Active      *bool  `json:"active"`
+}
+
+func (r dunningStageRequest) stage(id uint) *models.DunningStage {
+	stage := &models.DunningStage{
+		ID:          id,
+		DaysOverdue: r.DaysOverdue,
+		Subject:     r.Subject,
+		Body:        r.Body,
+		SendEmail:   r.SendEmail,
+		SendSMS:     r.SendSMS,
+		Active:      true,
+	}
+	if r.Active != nil {
+		stage.Active = *r.Active
+	}
+	return stage
+}
+
+// CreateDunningStage adds a stage to the dunning schedule
+func (h *DunningHandler) CreateDunningStage(c *gin.Context) {
+	var request dunningStageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	stage := request.stage(0)
+	if err := h.service.CreateStage(c, stage); err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(201, stage)
+}
+
+// GetDunningStages lists the dunning schedule in the order bills reach its stages
+func (h *DunningHandler) GetDunningStages(c *gin.Context) {
+	stages, err := h.service.ListStages(c)
+	if err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(200, stages)
+}
+
+func (h *DunningHandler) GetDunningStage(c *gin.Context) {
+	id, ok := dunningParamID(c)
+	if !ok {
+		return
+	}
+	stage, err := h.service.GetStage(c, id)
+	if err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(200, stage)
+}
+
+func (h *DunningHandler) UpdateDunningStage(c *gin.Context) {
+	id, ok := dunningParamID(c)
+	if !ok {
+		return
+	}
+	var request dunningStageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	stage := request.stage(id)
+	if err := h.service.UpdateStage(c, stage); err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(200, stage)
+}
+
+func (h *DunningHandler) DeleteDunningStage(c *gin.Context) {
+	id, ok := dunningParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteStage(c, id); err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Dunning stage deleted successfully"})
+}
+
+// GetBillingDunningNotices lists the dunning stages a bill reached and when the patient was told
+func (h *DunningHandler) GetBillingDunningNotices(c *gin.Context) {
+	notices, err := h.service.ListNotices(c, c.Param("id"))
+	if err != nil {
+		dunningError(c, err)
+		return
+	}
+	c.JSON(200, notices)
+}
+
+func dunningParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func dunningError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDunningStageNotFound), errors.Is(err, services.ErrBillingNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDunningStage):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EditLockHandler struct {
+	service *services.EditLockService
+}
+
+func NewEditLockHandler(service *services.EditLockService) *EditLockHandler {
+	return &EditLockHandler{service: service}
+}
+
+// LockPatient takes or renews the caller's edit lock on a patient. A 409 carries the lock of
+// whoever is already editing, for a "record in use by X" warning.
+func (h *EditLockHandler) LockPatient(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	lock, err := h.service.Acquire(c, services.EditLockEntityPatient, c.Param("patient_id"), userID)
+	if err != nil {
+		editLockError(c, err, lock)
+		return
+	}
+	c.JSON(200, lock)
+}
+
+// HeartbeatPatientLock keeps the caller's edit lock on a patient from expiring
+func (h *EditLockHandler) HeartbeatPatientLock(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	lock, err := h.service.Heartbeat(c, services.EditLockEntityPatient, c.Param("patient_id"), userID)
+	if err != nil {
+		editLockError(c, err, lock)
+		return
+	}
+	c.JSON(200, lock)
+}
+
+func (h *EditLockHandler) UnlockPatient(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Release(c, services.EditLockEntityPatient, c.Param("patient_id"), userID); err != nil {
+		editLockError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Edit lock released"})
+}
+
+// GetPatientLock tells who is editing a patient, with a null lock when nobody is
+func (h *EditLockHandler) GetPatientLock(c *gin.Context) {
+	lock, err := h.service.Get(c, services.EditLockEntityPatient, c.Param("patient_id"))
+	if err != nil {
+		editLockError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"lock": lock})
+}
+
+func editLockError(c *gin.Context, err error, lock interface{}) {
+	switch {
+	case errors.Is(err, services.ErrEditLocked):
+		c.JSON(409, gin.H{"error": err.Error(), "lock": lock})
+	case errors.Is(err, services.ErrEditLockNotHeld):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEditLocksUnavailable):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EligibilityHandler struct {
+	service *services.EligibilityService
+}
+
+func NewEligibilityHandler(service *services.EligibilityService) *EligibilityHandler {
+	return &EligibilityHandler{service: service}
+}
+
+// GetEligibilityChecks lists the checks with ?status=, or those not confirmed yet, soonest appointment first
+func (h *EligibilityHandler) GetEligibilityChecks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	checks, err := h.service.List(c, c.Query("status"), limit)
+	if err != nil {
+		eligibilityError(c, err)
+		return
+	}
+	c.JSON(200, checks)
+}
+
+func (h *EligibilityHandler) GetEligibilityCheck(c *gin.Context) {
+	id, ok := eligibilityCheckID(c)
+	if !ok {
+		return
+	}
+	check, err := h.service.Get(c, id)
+	if err != nil {
+		eligibilityError(c, err)
+		return
+	}
+	c.JSON(200, check)
+}
+
+// GetAppointmentEligibility returns the eligibility check of a patient's appointment
+func (h *EligibilityHandler) GetAppointmentEligibility(c *gin.Context) {
+	appointmentID, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	check, err := h.service.GetByAppointment(c, c.Param("patient_id"), uint(appointmentID))
+	if err != nil {
+		eligibilityError(c, err)
+		return
+	}
+	c.JSON(200, check)
+}
+
+// RecordEligibility saves the outcome of a check the front desk made with the insurer by hand
+func (h *EligibilityHandler) RecordEligibility(c *gin.Context) {
+	id, ok := eligibilityCheckID(c)
+	if !ok {
+		return
+	}
+	var outcome models.EligibilityOutcome
+	if err := c.ShouldBindJSON(&outcome); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	check, err := h.service.Record(c, id, outcome)
+	if err != nil {
+		eligibilityError(c, err)
+		return
+	}
+	c.JSON(200, check)
+}
+
+// RunEligibilityCheck asks the insurer integration about a check again
+func (h *EligibilityHandler) RunEligibilityCheck(c *gin.Context) {
+	id, ok := eligibilityCheckID(c)
+	if !ok {
+		return
+	}
+	check, err := h.service.Run(c, id)
+	if err != nil {
+		eligibilityError(c, err)
+		return
+	}
+	c.JSON(200, check)
+}
+
+func eligibilityCheckID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid eligibility check ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func eligibilityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEligibilityCheckNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidEligibility):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEligibilityIntegrationDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
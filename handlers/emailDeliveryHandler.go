@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailSignatureHeader carries the mail provider's signature of a delivery notification
+const emailSignatureHeader = "X-Email-Signature"
+
+type EmailDeliveryHandler struct {
+	service *services.EmailDeliveryService
+}
+
+func NewEmailDeliveryHandler(service *services.EmailDeliveryService) *EmailDeliveryHandler {
+	return &EmailDeliveryHandler{service: service}
+}
+
+// ReceiveEmailEvents takes the mail provider's signed bounces and complaints, e.g. [{"type": "bounce",
+// "message_id": "<...>", "recipient": "jane@example.com", "permanent": true, "diagnostic": "550 5.1.1 user unknown"}]
+func (h *EmailDeliveryHandler) ReceiveEmailEvents(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := h.service.HandleEvents(c, body, c.GetHeader(emailSignatureHeader))
+	if err != nil {
+		emailDeliveryError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// GetEmailSuppressions lists the addresses no longer emailed after bouncing or complaining
+func (h *EmailDeliveryHandler) GetEmailSuppressions(c *gin.Context) {
+	suppressions, err := h.service.ListSuppressions(c)
+	if err != nil {
+		emailDeliveryError(c, err)
+		return
+	}
+	c.JSON(200, suppressions)
+}
+
+// DeleteEmailSuppression emails an address again and clears its flag on patients and users
+func (h *EmailDeliveryHandler) DeleteEmailSuppression(c *gin.Context) {
+	if err := h.service.DeleteSuppression(c, c.Param("address")); err != nil {
+		emailDeliveryError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Email address suppression removed"})
+}
+
+func emailDeliveryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidEmailEvents):
+		c.JSON(401, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailSuppressionNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailWebhookDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"RoyDental/models"
 	"RoyDental/services"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -25,7 +26,7 @@ func (h *EmergencyContactHandler) CreateEmergencyContact(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &contact); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		emergencyContactError(c, err)
 		return
 	}
 	c.JSON(201, contact)
@@ -75,7 +76,7 @@ func (h *EmergencyContactHandler) UpdateEmergencyContact(c *gin.Context) {
 	contact.ID = uint(id)
 	contact.PatientID = patientID
 	if err := h.service.Update(c, &contact); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		emergencyContactError(c, err)
 		return
 	}
 	c.JSON(200, contact)
@@ -97,3 +98,61 @@ func (h *EmergencyContactHandler) DeleteEmergencyContact(c *gin.Context) {
 	}
 	c.JSON(204, gin.H{"message": "Emergency contact deleted"})
 }
+
+// UpdateEmergencyContactConsent records whether the contact agreed to be texted about the patient.
+func (h *EmergencyContactHandler) UpdateEmergencyContactConsent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("emergency_contact_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var request models.EmergencyContactConsent
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	contact, err := h.service.SetSMSConsent(c, c.Param("patient_id"), uint(id), *request.Consent)
+	if err != nil {
+		emergencyContactError(c, err)
+		return
+	}
+	c.JSON(200, contact)
+}
+
+// GetEmergencyContactTemplates lists the texts an emergency contact may be sent.
+func (h *EmergencyContactHandler) GetEmergencyContactTemplates(c *gin.Context) {
+	c.JSON(200, h.service.Templates())
+}
+
+// NotifyEmergencyContact texts a template to the patient's primary emergency contact, or to the
+// contact_id given.
+func (h *EmergencyContactHandler) NotifyEmergencyContact(c *gin.Context) {
+	var notice models.EmergencyContactNotice
+	if err := c.ShouldBindJSON(&notice); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := h.service.Notify(c, c.Param("patient_id"), notice)
+	if err != nil {
+		emergencyContactError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// emergencyContactError answers invalid input with 400, unknown contacts with 404, contacts who
+// may not be texted with 409, texts the gateway refused with 502 and anything else with 500.
+func emergencyContactError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRelationship), errors.Is(err, services.ErrInvalidEmergencyContactNotice):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmergencyContactNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoEmergencyContactConsent):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmergencyContactNotSent):
+		c.JSON(502, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmergencySlotHandler struct {
+	service *services.EmergencySlotService
+}
+
+func NewEmergencySlotHandler(service *services.EmergencySlotService) *EmergencySlotHandler {
+	return &EmergencySlotHandler{service: service}
+}
+
+// CreateEmergencySlot holds part of the schedule back for emergencies, on one date or every week
+func (h *EmergencySlotHandler) CreateEmergencySlot(c *gin.Context) {
+	var slot models.EmergencySlot
+	if err := c.ShouldBindJSON(&slot); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &slot); err != nil {
+		emergencySlotError(c, err)
+		return
+	}
+	c.JSON(201, slot)
+}
+
+func (h *EmergencySlotHandler) GetEmergencySlots(c *gin.Context) {
+	slots, err := h.service.List(c)
+	if err != nil {
+		emergencySlotError(c, err)
+		return
+	}
+	c.JSON(200, slots)
+}
+
+func (h *EmergencySlotHandler) GetEmergencySlot(c *gin.Context) {
+	id, ok := emergencySlotParamID(c)
+	if !ok {
+		return
+	}
+	slot, err := h.service.Get(c, id)
+	if err != nil {
+		emergencySlotError(c, err)
+		return
+	}
+	c.JSON(200, slot)
+}
+
+func (h *EmergencySlotHandler) UpdateEmergencySlot(c *gin.Context) {
+	id, ok := emergencySlotParamID(c)
+	if !ok {
+		return
+	}
+	var slot models.EmergencySlot
+	if err := c.ShouldBindJSON(&slot); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	slot.ID = id
+	if err := h.service.Update(c, &slot); err != nil {
+		emergencySlotError(c, err)
+		return
+	}
+	c.JSON(200, slot)
+}
+
+func (h *EmergencySlotHandler) DeleteEmergencySlot(c *gin.Context) {
+	id, ok := emergencySlotParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		emergencySlotError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Emergency slot deleted successfully"})
+}
+
+func emergencySlotParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid emergency slot ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func emergencySlotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEmergencySlotNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidEmergencySlot):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
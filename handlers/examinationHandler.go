@@ -3,7 +3,10 @@ package handlers
 import (
 	"RoyDental/models"
 	"RoyDental/services"
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +26,10 @@ func (h *ExaminationHandler) CreateExamination(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &examination); err != nil {
+		if isTemplateInsertError(err) || errors.Is(err, services.ErrInvalidFindings) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -70,6 +77,10 @@ func (h *ExaminationHandler) UpdateExamination(c *gin.Context) {
 	examination.ID = uint(id)
 	examination.PatientID = patientID
 	if err := h.service.Update(c, &examination); err != nil {
+		if errors.Is(err, services.ErrInvalidFindings) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -89,3 +100,75 @@ func (h *ExaminationHandler) DeleteExamination(c *gin.Context) {
 	}
 	c.JSON(204, gin.H{"message": "Examination deleted"})
 }
+
+// GetFindingSchemas lists the conditions examination findings are recorded of, with the stages,
+// grades and extents each may be given
+func (h *ExaminationHandler) GetFindingSchemas(c *gin.Context) {
+	c.JSON(200, models.FindingSchemas)
+}
+
+// GetFindingTemplates lists the clinical audit searches run with ?template= on the findings report
+func (h *ExaminationHandler) GetFindingTemplates(c *gin.Context) {
+	c.JSON(200, models.FindingQueryTemplates)
+}
+
+// GetFindingReport searches examinations for a ?condition=, optionally in comma-separated ?stages=
+// and ?grades=, of an ?extent=, on a ?tooth= and recorded on the days ?from= and ?to= (YYYY-MM-DD).
+// A ?template= runs one of the prepared searches instead.
+func (h *ExaminationHandler) GetFindingReport(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if name := c.Query("template"); name != "" {
+		report, err := h.service.RunFindingTemplate(c, name, limit)
+		if err != nil {
+			findingError(c, err)
+			return
+		}
+		c.JSON(200, report)
+		return
+	}
+
+	filter := models.FindingFilter{Condition: c.Query("condition"), Extent: c.Query("extent"), Limit: limit}
+	if value := c.Query("stages"); value != "" {
+		filter.Stages = strings.Split(value, ",")
+	}
+	if value := c.Query("grades"); value != "" {
+		filter.Grades = strings.Split(value, ",")
+	}
+	if value := c.Query("tooth"); value != "" {
+		tooth, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid tooth, expected an FDI tooth number"})
+			return
+		}
+		filter.Tooth = tooth
+	}
+	for param, day := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseClinicDate(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid " + param + " date, expected YYYY-MM-DD"})
+			return
+		}
+		*day = &parsed
+	}
+	report, err := h.service.SearchFindings(c, filter)
+	if err != nil {
+		findingError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func findingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrFindingTemplateNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidFindingFilter):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
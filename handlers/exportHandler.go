@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	service *services.ExportService
+}
+
+func NewExportHandler(service *services.ExportService) *ExportHandler {
+	return &ExportHandler{service: service}
+}
+
+// exportRequest asks for an export of the days from From to To (YYYY-MM-DD) inclusive
+type exportRequest struct {
+	Type   string `json:"type" binding:"required"`
+	Format string `json:"format" binding:"required"`
+	From   string `json:"from" binding:"required"`
+	To     string `json:"to" binding:"required"`
+}
+
+// RequestExport queues an export and returns the job to poll for its status
+func (h *ExportHandler) RequestExport(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var request exportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := models.ParseClinicDate(request.From)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+		return
+	}
+	to, err := models.ParseClinicDate(request.To)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+		return
+	}
+
+	job := models.ExportJob{Type: request.Type, Format: request.Format, From: from, To: to, RequestedBy: userID}
+	if err := h.service.Request(c, &job); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(202, job)
+}
+
+func (h *ExportHandler) GetExports(c *gin.Context) {
+	jobs, err := h.service.ListExports(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, jobs)
+}
+
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid export ID"})
+		return
+	}
+	job, err := h.service.GetExport(c, uint(id))
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.JSON(200, job)
+}
+
+// DownloadExport sends the generated file once the job is done
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid export ID"})
+		return
+	}
+	file, err := h.service.Download(c, uint(id))
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+file.Name+`"`)
+	c.Data(200, file.ContentType, file.Content)
+}
+
+func exportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrExportNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrExportNotReady):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FailedDeliveryHandler struct {
+	service *services.DeliveryRetryService
+}
+
+func NewFailedDeliveryHandler(service *services.DeliveryRetryService) *FailedDeliveryHandler {
+	return &FailedDeliveryHandler{service: service}
+}
+
+// GetFailedDeliveries lists the dead-letter queue, latest failures first, of ?transport= (email, sms
+// or webhook:<event>) when set, paged by ?limit= (100 by default) and ?offset=
+func (h *FailedDeliveryHandler) GetFailedDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid offset"})
+		return
+	}
+	deliveries, total, err := h.service.ListFailed(c, c.Query("transport"), limit, offset)
+	if err != nil {
+		failedDeliveryError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"total": total, "deliveries": deliveries})
+}
+
+// ReplayFailedDelivery queues a delivery to be tried again at once
+func (h *FailedDeliveryHandler) ReplayFailedDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+	delivery, err := h.service.Replay(c, uint(id))
+	if err != nil {
+		failedDeliveryError(c, err)
+		return
+	}
+	c.JSON(202, delivery)
+}
+
+// ReplayFailedDeliveries queues every delivery of the dead-letter queue still of use, of
+// ?transport= when set, to be tried again at once
+func (h *FailedDeliveryHandler) ReplayFailedDeliveries(c *gin.Context) {
+	replayed, err := h.service.ReplayAll(c, c.Query("transport"))
+	if err != nil {
+		failedDeliveryError(c, err)
+		return
+	}
+	c.JSON(202, gin.H{"replayed": replayed})
+}
+
+func failedDeliveryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidFailedDeliveryQuery):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFailedDeliveryNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFailedDeliveryExpired):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FinancialPeriodHandler struct {
+	service *services.FinancialPeriodService
+}
+
+func NewFinancialPeriodHandler(service *services.FinancialPeriodService) *FinancialPeriodHandler {
+	return &FinancialPeriodHandler{service: service}
+}
+
+// ClosePeriod closes the books of the month in the body, e.g. {"period": "2024-05"}
+func (h *FinancialPeriodHandler) ClosePeriod(c *gin.Context) {
+	var request struct {
+		Period string `json:"period" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	period, err := h.service.Close(c, request.Period, userID)
+	if err != nil {
+		financialPeriodError(c, err)
+		return
+	}
+	c.JSON(201, period)
+}
+
+func (h *FinancialPeriodHandler) GetPeriods(c *gin.Context) {
+	periods, err := h.service.List(c)
+	if err != nil {
+		financialPeriodError(c, err)
+		return
+	}
+	c.JSON(200, periods)
+}
+
+// GetBillingAdjustments lists the adjustments made to a bill of a closed period
+func (h *FinancialPeriodHandler) GetBillingAdjustments(c *gin.Context) {
+	adjustments, err := h.service.ListAdjustments(c, c.Param("id"))
+	if err != nil {
+		financialPeriodError(c, err)
+		return
+	}
+	c.JSON(200, adjustments)
+}
+
+func financialPeriodError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPeriod):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPeriodAlreadyClosed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
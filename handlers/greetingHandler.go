@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GreetingHandler struct {
+	service *services.GreetingService
+}
+
+func NewGreetingHandler(service *services.GreetingService) *GreetingHandler {
+	return &GreetingHandler{service: service}
+}
+
+// GetGreetings returns the send log of birthday greetings from ?from= through ?to= (YYYY-MM-DD, both optional)
+func (h *GreetingHandler) GetGreetings(c *gin.Context) {
+	entries, err := h.service.List(c, c.Query("from"), c.Query("to"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGreetingQuery) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, entries)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HandoverHandler struct {
+	service *services.HandoverService
+}
+
+func NewHandoverHandler(service *services.HandoverService) *HandoverHandler {
+	return &HandoverHandler{service: service}
+}
+
+func (h *HandoverHandler) CreateHandover(c *gin.Context) {
+	var note models.HandoverNote
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &note); err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(201, note)
+}
+
+// GetHandovers lists the notes handed over to the ?branch= for the ?date= (YYYY-MM-DD), today by
+// default
+func (h *HandoverHandler) GetHandovers(c *gin.Context) {
+	notes, err := h.service.List(c, c.Query("branch"), c.Query("date"))
+	if err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, notes)
+}
+
+func (h *HandoverHandler) GetHandover(c *gin.Context) {
+	id, ok := handoverID(c)
+	if !ok {
+		return
+	}
+	note, err := h.service.Get(c, id)
+	if err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, note)
+}
+
+func (h *HandoverHandler) UpdateHandover(c *gin.Context) {
+	id, ok := handoverID(c)
+	if !ok {
+		return
+	}
+	var update models.HandoverNote
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	note, err := h.service.Update(c, id, update)
+	if err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, note)
+}
+
+func (h *HandoverHandler) DeleteHandover(c *gin.Context) {
+	id, ok := handoverID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Handover note deleted"})
+}
+
+// MarkHandoverRead records that the signed-in doctor read the note
+func (h *HandoverHandler) MarkHandoverRead(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := handoverID(c)
+	if !ok {
+		return
+	}
+	note, err := h.service.MarkRead(c, userID, id)
+	if err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, note)
+}
+
+// GetMorningHuddle summarizes the ?date= (YYYY-MM-DD), today by default, for the ?branch=: the
+// notes handed over to it, the appointments and the lab work due back
+func (h *HandoverHandler) GetMorningHuddle(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	huddle, err := h.service.Huddle(c, userID, c.Query("branch"), c.Query("date"))
+	if err != nil {
+		handoverError(c, err)
+		return
+	}
+	c.JSON(200, huddle)
+}
+
+func handoverID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid handover note ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func handoverError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrHandoverNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidHandover):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDoctorNotLinked):
+		c.JSON(403, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
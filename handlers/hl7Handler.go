@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHL7MessageSize bounds a message posted over HTTP
+const maxHL7MessageSize = 1 << 20
+
+type HL7Handler struct {
+	service *services.HL7Service
+}
+
+func NewHL7Handler(service *services.HL7Service) *HL7Handler {
+	return &HL7Handler{service: service}
+}
+
+// ReceiveMessage takes a raw ADT message in the body and answers with its HL7 acknowledgement
+func (h *HL7Handler) ReceiveMessage(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxHL7MessageSize))
+	if err != nil {
+		c.JSON(413, gin.H{"error": "The message is too large"})
+		return
+	}
+	ack := h.service.Receive(c, string(body))
+	c.Data(200, "application/hl7-v2; charset=utf-8", []byte(ack))
+}
+
+// GetMessages lists received messages, filtered by ?status= such as pending_review
+func (h *HL7Handler) GetMessages(c *gin.Context) {
+	messages, err := h.service.ListMessages(c, c.Query("status"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, messages)
+}
+
+// ApplyMessage resolves a pending message for {"patient_id": "..."}, or registers a new patient when it is omitted
+func (h *HL7Handler) ApplyMessage(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid HL7 message ID"})
+		return
+	}
+	var input struct {
+		PatientID string `json:"patient_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	message, err := h.service.Apply(c, uint(id), input.PatientID, userID)
+	if err != nil {
+		hl7Error(c, err)
+		return
+	}
+	c.JSON(200, message)
+}
+
+// RejectMessage resolves a pending message without changing any patient
+func (h *HL7Handler) RejectMessage(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid HL7 message ID"})
+		return
+	}
+	message, err := h.service.Reject(c, uint(id), userID)
+	if err != nil {
+		hl7Error(c, err)
+		return
+	}
+	c.JSON(200, message)
+}
+
+func hl7Error(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrHL7MessageNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHL7NotPending):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
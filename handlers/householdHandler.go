@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HouseholdHandler struct {
+	service *services.HouseholdService
+}
+
+func NewHouseholdHandler(service *services.HouseholdService) *HouseholdHandler {
+	return &HouseholdHandler{service: service}
+}
+
+type householdMemberRequest struct {
+	Relationship string  `json:"relationship" binding:"required"`
+	GuardianID   *string `json:"guardian_id"`
+}
+
+// CreateHousehold links guarantor_id and the patients in members into a household
+func (h *HouseholdHandler) CreateHousehold(c *gin.Context) {
+	var household models.Household
+	if err := c.ShouldBindJSON(&household); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &household); err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(201, household)
+}
+
+func (h *HouseholdHandler) GetHousehold(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	household, err := h.service.Get(c, id)
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, household)
+}
+
+func (h *HouseholdHandler) GetHouseholds(c *gin.Context) {
+	households, err := h.service.List(c)
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, households)
+}
+
+// GetPatientHousehold returns the household the patient is in
+func (h *HouseholdHandler) GetPatientHousehold(c *gin.Context) {
+	household, err := h.service.ByPatient(c, c.Param("patient_id"))
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, household)
+}
+
+// UpdateHousehold changes the household's name, phone, address and guarantor
+func (h *HouseholdHandler) UpdateHousehold(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	var household models.Household
+	if err := c.ShouldBindJSON(&household); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	household.ID = id
+	updated, err := h.service.Update(c, &household)
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+func (h *HouseholdHandler) DeleteHousehold(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Household deleted successfully"})
+}
+
+func (h *HouseholdHandler) AddHouseholdMember(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	var member models.HouseholdMember
+	if err := c.ShouldBindJSON(&member); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	household, err := h.service.AddMember(c, id, &member)
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(201, household)
+}
+
+func (h *HouseholdHandler) UpdateHouseholdMember(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	var request householdMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	household, err := h.service.UpdateMember(c, id, c.Param("patient_id"), models.HouseholdMember{Relationship: request.Relationship, GuardianID: request.GuardianID})
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, household)
+}
+
+func (h *HouseholdHandler) RemoveHouseholdMember(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	household, err := h.service.RemoveMember(c, id, c.Param("patient_id"))
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, household)
+}
+
+// GetHouseholdSuggestions lists patients in no household who share a phone number or address
+func (h *HouseholdHandler) GetHouseholdSuggestions(c *gin.Context) {
+	suggestions, err := h.service.Suggestions(c)
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, suggestions)
+}
+
+// GetHouseholdStatement returns the members' bills from ?from= to ?to= (YYYY-MM-DD) as one statement
+func (h *HouseholdHandler) GetHouseholdStatement(c *gin.Context) {
+	id, ok := householdParamID(c)
+	if !ok {
+		return
+	}
+	statement, err := h.service.Statement(c, id, c.Query("from"), c.Query("to"))
+	if err != nil {
+		householdError(c, err)
+		return
+	}
+	c.JSON(200, statement)
+}
+
+func householdParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func householdError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrHouseholdNotFound), errors.Is(err, services.ErrHouseholdMemberNotFound),
+		errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidHousehold), errors.Is(err, services.ErrInvalidStatement):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrPatientInHousehold):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImagingHandler struct {
+	service *services.ImagingService
+}
+
+func NewImagingHandler(service *services.ImagingService) *ImagingHandler {
+	return &ImagingHandler{service: service}
+}
+
+// IngestDICOM receives a DICOM file from the imaging unit, either as the raw body
+// (Content-Type: application/dicom) or as the multipart "file"
+func (h *ImagingHandler) IngestDICOM(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.service.MaxFileSize()+1<<20)
+
+	var fileName string
+	var content []byte
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, formErr := c.FormFile("file")
+		if formErr != nil {
+			err = formErr
+		} else {
+			fileName = header.Filename
+			var file io.ReadCloser
+			if file, err = header.Open(); err == nil {
+				content, err = io.ReadAll(file)
+				file.Close()
+			}
+		}
+	} else {
+		fileName = c.Query("file_name")
+		content, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": "The file is too large"})
+			return
+		}
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if int64(len(content)) > h.service.MaxFileSize() {
+		c.JSON(413, gin.H{"error": "The file is too large"})
+		return
+	}
+
+	study, err := h.service.Ingest(c, fileName, content)
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.JSON(201, study)
+}
+
+// GetStudies lists imaging studies, filtered by ?status= (matched or unmatched) and ?patient_id=
+func (h *ImagingHandler) GetStudies(c *gin.Context) {
+	studies, err := h.service.List(c, c.Query("status"), c.Query("patient_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, studies)
+}
+
+// GetPatientStudies lists the images filed with a patient
+func (h *ImagingHandler) GetPatientStudies(c *gin.Context) {
+	studies, err := h.service.List(c, "", c.Param("patient_id"))
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.JSON(200, studies)
+}
+
+func (h *ImagingHandler) GetStudy(c *gin.Context) {
+	id, ok := imagingStudyID(c)
+	if !ok {
+		return
+	}
+	study, err := h.service.Get(c, id)
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.JSON(200, study)
+}
+
+// DownloadStudy sends the original DICOM file
+func (h *ImagingHandler) DownloadStudy(c *gin.Context) {
+	id, ok := imagingStudyID(c)
+	if !ok {
+		return
+	}
+	study, err := h.service.Download(c, id)
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+study.FileName+`"`)
+	c.Data(200, "application/dicom", study.Content)
+}
+
+// GetStudyPreview sends the PNG preview of the image
+func (h *ImagingHandler) GetStudyPreview(c *gin.Context) {
+	id, ok := imagingStudyID(c)
+	if !ok {
+		return
+	}
+	preview, err := h.service.Preview(c, id)
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.Data(200, "image/png", preview)
+}
+
+// MatchStudy links a study to the patient in {"patient_id": "..."}
+func (h *ImagingHandler) MatchStudy(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := imagingStudyID(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		PatientID string `json:"patient_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	study, err := h.service.Match(c, id, input.PatientID, userID)
+	if err != nil {
+		imagingError(c, err)
+		return
+	}
+	c.JSON(200, study)
+}
+
+func imagingStudyID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid imaging study ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func imagingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrImagingStudyNotFound), errors.Is(err, services.ErrNoImagingPreview):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDICOM), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
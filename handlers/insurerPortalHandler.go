@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InsurerPortalHandler struct {
+	service *services.InsurerPortalService
+}
+
+func NewInsurerPortalHandler(service *services.InsurerPortalService) *InsurerPortalHandler {
+	return &InsurerPortalHandler{service: service}
+}
+
+// GetMyClaims lists the claims made to the signed-in officer's company, optionally by ?status= and
+// service days ?from= and ?to= (YYYY-MM-DD)
+func (h *InsurerPortalHandler) GetMyClaims(c *gin.Context) {
+	access, ok := insurerAccess(c)
+	if !ok {
+		return
+	}
+	filter, ok := insurerFilter(c)
+	if !ok {
+		return
+	}
+	claims, err := h.service.Claims(c, access, filter)
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, claims)
+}
+
+func (h *InsurerPortalHandler) GetMyClaim(c *gin.Context) {
+	access, ok := insurerAccess(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid claim ID"})
+		return
+	}
+	claim, err := h.service.Claim(c, access, uint(id))
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, claim)
+}
+
+// GetMyBillings lists the bills of the signed-in officer's company's members, optionally by the
+// ?status= of their claim and the days ?from= and ?to= they were raised (YYYY-MM-DD)
+func (h *InsurerPortalHandler) GetMyBillings(c *gin.Context) {
+	access, ok := insurerAccess(c)
+	if !ok {
+		return
+	}
+	filter, ok := insurerFilter(c)
+	if !ok {
+		return
+	}
+	billings, err := h.service.Billings(c, access, filter)
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, billings)
+}
+
+func (h *InsurerPortalHandler) GetMyBilling(c *gin.Context) {
+	access, ok := insurerAccess(c)
+	if !ok {
+		return
+	}
+	billing, err := h.service.Billing(c, access, c.Param("id"))
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, billing)
+}
+
+type insurerAccountRequest struct {
+	InsuranceCompanyID string `json:"insurance_company_id" binding:"required"`
+}
+
+// LinkInsurerAccount lets a user with the Insurer role see an insurance company's claims and bills
+func (h *InsurerPortalHandler) LinkInsurerAccount(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var request insurerAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	account, err := h.service.LinkAccount(c, userID, request.InsuranceCompanyID)
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, account)
+}
+
+func (h *InsurerPortalHandler) UnlinkInsurerAccount(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if err := h.service.UnlinkAccount(c, userID); err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Insurer account unlinked"})
+}
+
+// GetInsurerAccounts lists the users linked to insurers, optionally of ?insurance_company_id=
+func (h *InsurerPortalHandler) GetInsurerAccounts(c *gin.Context) {
+	accounts, err := h.service.Accounts(c, c.Query("insurance_company_id"))
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, accounts)
+}
+
+// GetInsurerAccesses lists the officers' look-ups newest first, optionally by ?user_id= and
+// ?insurance_company_id=, or only the denied ones with ?denied=true
+func (h *InsurerPortalHandler) GetInsurerAccesses(c *gin.Context) {
+	filter := models.InsurerAccessFilter{InsuranceCompanyID: c.Query("insurance_company_id"), DeniedOnly: c.Query("denied") == "true"}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter.UserID = &userID
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	accesses, err := h.service.Accesses(c, filter)
+	if err != nil {
+		insurerPortalError(c, err)
+		return
+	}
+	c.JSON(200, accesses)
+}
+
+// insurerAccess starts the access log entry of a look-up with who made it and from where
+func insurerAccess(c *gin.Context) (models.InsurerAccess, bool) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return models.InsurerAccess{}, false
+	}
+	return models.InsurerAccess{UserID: userID, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}, true
+}
+
+func insurerFilter(c *gin.Context) (models.InsurerFilter, bool) {
+	filter := models.InsurerFilter{Status: c.Query("status")}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	for param, day := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseClinicDate(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid " + param + " date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		*day = &parsed
+	}
+	return filter, true
+}
+
+func insurerPortalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInsurerNotLinked):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInsurerRecordNotFound), errors.Is(err, services.ErrInsurerAccountNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidInsurerAccount), errors.Is(err, services.ErrInvalidInsurerFilter):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IntegrityHandler struct {
+	service *services.IntegrityService
+}
+
+func NewIntegrityHandler(service *services.IntegrityService) *IntegrityHandler {
+	return &IntegrityHandler{service: service}
+}
+
+// integrityRequest asks for the issues found to be repaired as well as reported
+type integrityRequest struct {
+	Repair bool `json:"repair"`
+}
+
+// StartIntegrityCheck starts a run of the integrity checks and returns the run to poll for its report
+func (h *IntegrityHandler) StartIntegrityCheck(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	// Without a body the run only reports
+	var request integrityRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	run, err := h.service.Start(c, userID, request.Repair)
+	if err != nil {
+		integrityError(c, err)
+		return
+	}
+	c.JSON(202, run)
+}
+
+func (h *IntegrityHandler) GetIntegrityChecks(c *gin.Context) {
+	runs, err := h.service.List(c)
+	if err != nil {
+		integrityError(c, err)
+		return
+	}
+	c.JSON(200, runs)
+}
+
+// GetIntegrityCheck returns a run with the issues it found and how they were repaired
+func (h *IntegrityHandler) GetIntegrityCheck(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+	run, err := h.service.Get(c, uint(id))
+	if err != nil {
+		integrityError(c, err)
+		return
+	}
+	c.JSON(200, run)
+}
+
+func integrityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrIntegrityRunNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrIntegrityRunning):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KioskHandler struct {
+	service *services.KioskService
+}
+
+func NewKioskHandler(service *services.KioskService) *KioskHandler {
+	return &KioskHandler{service: service}
+}
+
+// kioskIdentity is how a patient identifies themself at the kiosk
+type kioskIdentity struct {
+	Phone       string `json:"phone" binding:"required"`
+	DateOfBirth string `json:"date_of_birth" binding:"required"`
+}
+
+func (h *KioskHandler) LookupAppointments(c *gin.Context) {
+	var identity kioskIdentity
+	if err := c.ShouldBindJSON(&identity); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	appointments, err := h.service.LookupToday(c, identity.Phone, identity.DateOfBirth)
+	if err != nil {
+		kioskError(c, err)
+		return
+	}
+	c.JSON(200, appointments)
+}
+
+func (h *KioskHandler) CheckIn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	var identity kioskIdentity
+	if err := c.ShouldBindJSON(&identity); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	appointment, err := h.service.CheckIn(c, identity.Phone, identity.DateOfBirth, uint(id))
+	if err != nil {
+		kioskError(c, err)
+		return
+	}
+	c.JSON(200, appointment)
+}
+
+func (h *KioskHandler) SubmitContactUpdate(c *gin.Context) {
+	var request struct {
+		kioskIdentity
+		NewPhone string         `json:"new_phone"`
+		Email    string         `json:"email"`
+		Address  models.Address `json:"address"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	update := models.PatientContactUpdate{Phone: request.NewPhone, Email: request.Email, Address: request.Address}
+	if err := h.service.SubmitContactUpdate(c, request.Phone, request.DateOfBirth, &update); err != nil {
+		kioskError(c, err)
+		return
+	}
+	c.JSON(202, gin.H{"message": "Contact details submitted for staff approval", "id": update.ID})
+}
+
+func (h *KioskHandler) GetContactUpdates(c *gin.Context) {
+	updates, err := h.service.GetContactUpdates(c, c.DefaultQuery("status", models.ContactUpdatePending))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, updates)
+}
+
+func (h *KioskHandler) ReviewContactUpdate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid contact update ID"})
+		return
+	}
+	var request struct {
+		Approve    bool   `json:"approve"`
+		ReviewedBy string `json:"reviewed_by" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	update, err := h.service.ReviewContactUpdate(c, uint(id), request.Approve, request.ReviewedBy)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, update)
+}
+
+// kioskError maps kiosk failures to responses without revealing whether a patient exists.
+func kioskError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrKioskPatientNotFound):
+		c.JSON(404, gin.H{"error": "No appointment found for these details"})
+	case errors.Is(err, repositories.ErrAppointmentNotCheckable):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": "Kiosk request failed"})
+	}
+}
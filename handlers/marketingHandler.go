@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MarketingHandler struct {
+	service *services.MarketingService
+}
+
+func NewMarketingHandler(service *services.MarketingService) *MarketingHandler {
+	return &MarketingHandler{service: service}
+}
+
+// GetMarketingReport reports the new patients, bookings and revenue each source and campaign brought
+// in between the from and to dates (YYYY-MM-DD), the last 30 days by default
+func (h *MarketingHandler) GetMarketingReport(c *gin.Context) {
+	to := models.ClinicNow()
+	from := to.AddDate(0, 0, -29)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, models.ClinicLocation())
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, models.ClinicLocation())
+
+	report, err := h.service.Report(c, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidReportPeriod) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
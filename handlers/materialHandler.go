@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MaterialHandler struct {
+	service *services.MaterialService
+}
+
+func NewMaterialHandler(service *services.MaterialService) *MaterialHandler {
+	return &MaterialHandler{service: service}
+}
+
+// RecordMaterial records the lot of a material used for the bill
+func (h *MaterialHandler) RecordMaterial(c *gin.Context) {
+	var usage models.MaterialUsage
+	if err := c.ShouldBindJSON(&usage); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Record(c, c.Param("id"), &usage); err != nil {
+		materialError(c, err)
+		return
+	}
+	c.JSON(201, usage)
+}
+
+func (h *MaterialHandler) GetBillingMaterials(c *gin.Context) {
+	usages, err := h.service.GetBillingMaterials(c, c.Param("id"))
+	if err != nil {
+		materialError(c, err)
+		return
+	}
+	c.JSON(200, usages)
+}
+
+func (h *MaterialHandler) GetPatientMaterials(c *gin.Context) {
+	usages, err := h.service.GetPatientMaterials(c, c.Param("patient_id"))
+	if err != nil {
+		materialError(c, err)
+		return
+	}
+	c.JSON(200, usages)
+}
+
+func (h *MaterialHandler) DeleteMaterial(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("material_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid material ID"})
+		return
+	}
+	if err := h.service.Delete(c, c.Param("id"), uint(id)); err != nil {
+		materialError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Material deleted successfully"})
+}
+
+// RecallMaterial lists the patients treated with material from the lot ?lot_number=, narrowed by
+// ?manufacturer= and ?product= when given
+func (h *MaterialHandler) RecallMaterial(c *gin.Context) {
+	report, err := h.service.Recall(c, models.MaterialRecallFilter{
+		LotNumber:    c.Query("lot_number"),
+		Manufacturer: c.Query("manufacturer"),
+		Product:      c.Query("product"),
+	})
+	if err != nil {
+		materialError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func materialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBillingNotFound), errors.Is(err, services.ErrMaterialUsageNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMaterialUsage):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NoShowHandler struct {
+	service *services.NoShowService
+}
+
+func NewNoShowHandler(service *services.NoShowService) *NoShowHandler {
+	return &NoShowHandler{service: service}
+}
+
+// GetNoShowRisks returns the day's (?date=YYYY-MM-DD, today by default) scheduled appointments with
+// a no-show risk of at least ?min_risk=, riskiest first, so the desk knows whom to call
+func (h *NoShowHandler) GetNoShowRisks(c *gin.Context) {
+	risks, err := h.service.Risks(c, c.Query("date"), c.Query("min_risk"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNoShowQuery) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, risks)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OperationsHandler struct {
+	service *services.OperationsService
+}
+
+func NewOperationsHandler(service *services.OperationsService) *OperationsHandler {
+	return &OperationsHandler{service: service}
+}
+
+// GetCacheStatus returns whether Redis is in use, the namespace and generation of cache keys and the
+// invalidations waiting for Redis to come back
+func (h *OperationsHandler) GetCacheStatus(c *gin.Context) {
+	c.JSON(200, h.service.CacheStatus(c))
+}
+
+// FlushCache drops every cached entry at once
+func (h *OperationsHandler) FlushCache(c *gin.Context) {
+	if err := h.service.FlushCache(c); err != nil {
+		operationsError(c, err)
+		return
+	}
+	c.JSON(200, h.service.CacheStatus(c))
+}
+
+// PruneCache deletes the expired reset codes and sessions, orphaned locks and oversized cached lists
+// now and returns how many of each went
+func (h *OperationsHandler) PruneCache(c *gin.Context) {
+	run, err := h.service.PruneCache(c)
+	if err != nil {
+		operationsError(c, err)
+		return
+	}
+	c.JSON(200, run)
+}
+
+// InvalidateCacheEntity drops the entries cached under :entity, e.g. DELETE /auth/admin/cache/patient
+func (h *OperationsHandler) InvalidateCacheEntity(c *gin.Context) {
+	if err := h.service.InvalidateCacheEntity(c, c.Param("entity")); err != nil {
+		operationsError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+// GetJobQueues returns the background job queues with their jobs counted by status
+func (h *OperationsHandler) GetJobQueues(c *gin.Context) {
+	queues, err := h.service.JobQueues(c)
+	if err != nil {
+		operationsError(c, err)
+		return
+	}
+	c.JSON(200, queues)
+}
+
+func operationsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCacheEntity):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientAlertHandler struct {
+	service *services.PatientAlertService
+}
+
+func NewPatientAlertHandler(service *services.PatientAlertService) *PatientAlertHandler {
+	return &PatientAlertHandler{service: service}
+}
+
+type patientAlertRequest struct {
+	Type     string `json:"type" binding:"required"`
+	Severity string `json:"severity" binding:"required"`
+	Note     string `json:"note"`
+}
+
+// CreatePatientAlert flags a risk on the patient's record
+func (h *PatientAlertHandler) CreatePatientAlert(c *gin.Context) {
+	var request patientAlertRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	alert := models.PatientAlert{PatientID: c.Param("patient_id"), Type: request.Type, Severity: request.Severity, Note: request.Note}
+	if err := h.service.Create(c, &alert); err != nil {
+		patientAlertError(c, err)
+		return
+	}
+	c.JSON(201, alert)
+}
+
+// GetPatientAlerts lists the patient's active alerts, or all of them with ?all=true
+func (h *PatientAlertHandler) GetPatientAlerts(c *gin.Context) {
+	alerts, err := h.service.List(c, c.Param("patient_id"), c.Query("all") == "true")
+	if err != nil {
+		patientAlertError(c, err)
+		return
+	}
+	c.JSON(200, alerts)
+}
+
+// UpdatePatientAlert changes an alert; alerts that no longer apply are sent with active false
+func (h *PatientAlertHandler) UpdatePatientAlert(c *gin.Context) {
+	id, ok := visitParam(c, "id")
+	if !ok {
+		return
+	}
+	var request struct {
+		patientAlertRequest
+		Active *bool `json:"active" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	alert := models.PatientAlert{
+		ID:        id,
+		PatientID: c.Param("patient_id"),
+		Type:      request.Type,
+		Severity:  request.Severity,
+		Note:      request.Note,
+		Active:    *request.Active,
+	}
+	if err := h.service.Update(c, &alert); err != nil {
+		patientAlertError(c, err)
+		return
+	}
+	updated, err := h.service.Get(c, alert.PatientID, id)
+	if err != nil {
+		patientAlertError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+// AcknowledgeAlerts records that the listed alerts were read before treating the patient at the
+// appointment, and returns the patient's alerts for the visit
+func (h *PatientAlertHandler) AcknowledgeAlerts(c *gin.Context) {
+	appointmentID, ok := visitParam(c, "appointment_id")
+	if !ok {
+		return
+	}
+	var request struct {
+		AlertIDs []uint `json:"alert_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	alerts, err := h.service.Acknowledge(c, c.Param("patient_id"), appointmentID, request.AlertIDs)
+	if err != nil {
+		patientAlertError(c, err)
+		return
+	}
+	c.JSON(200, alerts)
+}
+
+func patientAlertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPatientAlertNotFound), errors.Is(err, services.ErrPatientNotFound),
+		errors.Is(err, repositories.ErrAppointmentNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPatientAlert), errors.Is(err, repositories.ErrAlertNotActive):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
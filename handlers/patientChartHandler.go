@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientChartHandler struct {
+	service *services.PatientChartService
+}
+
+func NewPatientChartHandler(service *services.PatientChartService) *PatientChartHandler {
+	return &PatientChartHandler{service: service}
+}
+
+// GetPatientChartPDF returns the patient's complete clinical chart as a PDF to send with a referral out
+func (h *PatientChartHandler) GetPatientChartPDF(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	data, err := h.service.PDF(c, patientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPatientNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="chart-%s.pdf"`, patientID))
+	c.Data(200, "application/pdf", data)
+}
@@ -2,17 +2,20 @@ package handlers
 
 import (
 	"RoyDental/models"
+	"RoyDental/repositories"
 	"RoyDental/services"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PatientHandler struct {
-	service *services.PatientService
+	service      *services.PatientService
+	savedFilters *services.SavedFilterService
 }
 
-func NewPatientHandler(service *services.PatientService) *PatientHandler {
-	return &PatientHandler{service: service}
+func NewPatientHandler(service *services.PatientService, savedFilters *services.SavedFilterService) *PatientHandler {
+	return &PatientHandler{service: service, savedFilters: savedFilters}
 }
 
 func (h *PatientHandler) CreatePatient(c *gin.Context) {
@@ -22,6 +25,10 @@ func (h *PatientHandler) CreatePatient(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &patient); err != nil {
+		if errors.Is(err, services.ErrInvalidCustomFieldValues) || errors.Is(err, services.ErrInvalidGuarantor) || errors.Is(err, services.ErrInvalidAttribution) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -38,13 +45,23 @@ func (h *PatientHandler) GetPatientByID(c *gin.Context) {
 	c.JSON(200, patient)
 }
 
+// GetAllPatients streams the list, as NDJSON with Accept: application/x-ndjson. A saved filter
+// (?filter_id=) and ?sort= narrow and order it.
 func (h *PatientHandler) GetAllPatients(c *gin.Context) {
-	patients, err := h.service.GetAll(c)
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	query, ok := filteredList(c, h.savedFilters, models.ListPatients)
+	if !ok {
+		return
+	}
+	stream := newJSONStream(c)
+	if query != nil {
+		stream.Close(h.service.StreamFiltered(c, *query, func(patient models.Patient) error {
+			return stream.Write(patient)
+		}))
 		return
 	}
-	c.JSON(200, patients)
+	stream.Close(h.service.Stream(c, func(patient models.Patient) error {
+		return stream.Write(patient)
+	}))
 }
 
 func (h *PatientHandler) UpdatePatient(c *gin.Context) {
@@ -56,15 +73,45 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 	}
 	patient.ID = id
 	if err := h.service.Update(c, &patient); err != nil {
+		if errors.Is(err, services.ErrInvalidCustomFieldValues) || errors.Is(err, services.ErrInvalidGuarantor) || errors.Is(err, services.ErrInvalidAttribution) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(200, patient)
 }
 
+// PatchPatient changes only the fields in the body, a JSON merge patch
+func (h *PatientHandler) PatchPatient(c *gin.Context) {
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	patient, err := h.service.Patch(c, c.Param("patient_id"), patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPatientNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidPatch), errors.Is(err, services.ErrInvalidCustomFieldValues), errors.Is(err, services.ErrInvalidGuarantor), errors.Is(err, services.ErrInvalidAttribution):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, patient)
+}
+
 func (h *PatientHandler) DeletePatient(c *gin.Context) {
 	id := c.Param("patient_id")
 	if err := h.service.Delete(c, id); err != nil {
+		if errors.Is(err, services.ErrApprovalRequired) {
+			approvalPending(c, err)
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -74,6 +121,14 @@ func (h *PatientHandler) DeletePatient(c *gin.Context) {
 func (h *PatientHandler) DeletePatientAndRelated(c *gin.Context) {
 	id := c.Param("patient_id")
 	if err := h.service.DeletePatientAndRelated(c, id); err != nil {
+		if errors.Is(err, services.ErrApprovalRequired) {
+			approvalPending(c, err)
+			return
+		}
+		if errors.Is(err, repositories.ErrPeriodClosed) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientHistoryHandler struct {
+	service *services.PatientHistoryService
+}
+
+func NewPatientHistoryHandler(service *services.PatientHistoryService) *PatientHistoryHandler {
+	return &PatientHistoryHandler{service: service}
+}
+
+// GetAppointmentHistory lists the patient's appointments a page at a time, see listPatientPage
+func (h *PatientHistoryHandler) GetAppointmentHistory(c *gin.Context) {
+	listPatientPage(c, h.service.AppointmentsPage)
+}
+
+// GetBillingHistory lists the patient's billings a page at a time, see listPatientPage
+func (h *PatientHistoryHandler) GetBillingHistory(c *gin.Context) {
+	listPatientPage(c, h.service.BillingsPage)
+}
+
+// GetExaminationHistory lists the patient's examinations a page at a time, see listPatientPage
+func (h *PatientHistoryHandler) GetExaminationHistory(c *gin.Context) {
+	listPatientPage(c, h.service.ExaminationsPage)
+}
+
+// GetTreatmentPlanHistory lists the patient's treatment plans a page at a time, see listPatientPage
+func (h *PatientHistoryHandler) GetTreatmentPlanHistory(c *gin.Context) {
+	listPatientPage(c, h.service.TreatmentPlansPage)
+}
+
+// listPatientPage answers with a page of up to ?limit= of the patient's rows newest first, after the
+// ?after= cursor of the previous page
+func listPatientPage[T any](c *gin.Context, page func(ctx context.Context, patientID, after string, limit int) (*models.ListPage[T], error)) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	result, err := page(c, c.Param("patient_id"), c.Query("after"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCursor):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPatientNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, result)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// paymentSignatureHeader carries the gateway's signature of a payment callback
+const paymentSignatureHeader = "X-Payment-Signature"
+
+type PatientPortalHandler struct {
+	service *services.PatientPortalService
+}
+
+func NewPatientPortalHandler(service *services.PatientPortalService) *PatientPortalHandler {
+	return &PatientPortalHandler{service: service}
+}
+
+// GetMyAppointments returns the signed-in patient's upcoming visits and their latest past ones
+func (h *PatientPortalHandler) GetMyAppointments(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	visits, err := h.service.Appointments(c, userID)
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, visits)
+}
+
+// GetMyBillings returns the signed-in patient's bills newest first
+func (h *PatientPortalHandler) GetMyBillings(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	billings, err := h.service.Billings(c, userID)
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, billings)
+}
+
+// GetMyBalance returns what the signed-in patient was billed, has paid and still owes
+func (h *PatientPortalHandler) GetMyBalance(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	balance, err := h.service.Balance(c, userID)
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, balance)
+}
+
+// GetMyStatement returns the signed-in patient's statement from ?from= to ?to= (YYYY-MM-DD)
+func (h *PatientPortalHandler) GetMyStatement(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	statement, err := h.service.Statement(c, userID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, statement)
+}
+
+// GetMyPayments returns the signed-in patient's online payments newest first
+func (h *PatientPortalHandler) GetMyPayments(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	payments, err := h.service.Payments(c, userID)
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, payments)
+}
+
+// StartPayment starts paying one of the signed-in patient's bills online. Without an amount the
+// whole balance is paid. The patient is sent on to the returned payment_url.
+func (h *PatientPortalHandler) StartPayment(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Amount float64 `json:"amount"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	payment, err := h.service.StartPayment(c, userID, c.Param("id"), request.Amount)
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(201, payment)
+}
+
+// ReceivePaymentCallback takes the gateway's signed report of how a payment ended
+func (h *PatientPortalHandler) ReceivePaymentCallback(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	payment, err := h.service.HandleCallback(c, body, c.GetHeader(paymentSignatureHeader))
+	if err != nil {
+		patientPortalError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"reference": payment.Reference, "status": payment.Status})
+}
+
+func patientPortalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPatientNotLinked):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPortalBillingNotFound), errors.Is(err, services.ErrOnlinePaymentNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidStatement), errors.Is(err, services.ErrInvalidOnlinePayment):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPaymentCallback):
+		c.JSON(401, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentGateway):
+		c.JSON(502, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOnlinePaymentsDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
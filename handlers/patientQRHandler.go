@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PatientQRHandler struct {
+	service *services.PatientQRService
+}
+
+func NewPatientQRHandler(service *services.PatientQRService) *PatientQRHandler {
+	return &PatientQRHandler{service: service}
+}
+
+// LookupPatient returns the patient identified by the scanned code in ?qr=
+func (h *PatientQRHandler) LookupPatient(c *gin.Context) {
+	patient, err := h.service.Lookup(c, c.Query("qr"))
+	if err != nil {
+		patientQRError(c, err)
+		return
+	}
+	c.JSON(200, patient)
+}
+
+// GetPatientQR sends the patient's code as a PNG
+func (h *PatientQRHandler) GetPatientQR(c *gin.Context) {
+	image, err := h.service.Image(c, c.Param("patient_id"))
+	if err != nil {
+		patientQRError(c, err)
+		return
+	}
+	c.Data(200, "image/png", image)
+}
+
+func patientQRError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPatientQR):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientQRDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentPlanHandler struct {
+	service *services.PaymentPlanService
+}
+
+func NewPaymentPlanHandler(service *services.PaymentPlanService) *PaymentPlanHandler {
+	return &PaymentPlanHandler{service: service}
+}
+
+// paymentPlanRequest is a payment plan with dates as YYYY-MM-DD. Either installments are listed, or
+// installment_count of them are generated monthly (or every interval_months) from first_due_date.
+type paymentPlanRequest struct {
+	TreatmentPlanID  *uint                `json:"treatment_plan_id"`
+	BillingID        *string              `json:"billing_id"`
+	Description      string               `json:"description"`
+	TotalAmount      float64              `json:"total_amount"`
+	Installments     []installmentRequest `json:"installments"`
+	InstallmentCount int                  `json:"installment_count"`
+	FirstDueDate     string               `json:"first_due_date"`
+	IntervalMonths   int                  `json:"interval_months"`
+}
+
+type installmentRequest struct {
+	DueDate string  `json:"due_date" binding:"required"`
+	Amount  float64 `json:"amount" binding:"required"`
+}
+
+func (h *PaymentPlanHandler) CreatePaymentPlan(c *gin.Context) {
+	var request paymentPlanRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	plan := &models.PaymentPlan{
+		PatientID:       c.Param("patient_id"),
+		TreatmentPlanID: request.TreatmentPlanID,
+		BillingID:       request.BillingID,
+		Description:     request.Description,
+		TotalAmount:     request.TotalAmount,
+	}
+	for _, item := range request.Installments {
+		dueDate, err := models.ParseClinicDate(item.DueDate)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid due_date, expected YYYY-MM-DD"})
+			return
+		}
+		plan.Installments = append(plan.Installments, models.PaymentInstallment{DueDate: dueDate, Amount: item.Amount})
+	}
+	schedule := models.InstallmentSchedule{Count: request.InstallmentCount, IntervalMonths: request.IntervalMonths}
+	if request.FirstDueDate != "" {
+		firstDueDate, err := models.ParseClinicDate(request.FirstDueDate)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid first_due_date, expected YYYY-MM-DD"})
+			return
+		}
+		schedule.FirstDueDate = firstDueDate
+	}
+
+	view, err := h.service.Create(c, plan, schedule)
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(201, view)
+}
+
+// GetPatientPaymentPlans lists a patient's plans with their progress
+func (h *PaymentPlanHandler) GetPatientPaymentPlans(c *gin.Context) {
+	views, err := h.service.List(c, c.Param("patient_id"), c.Query("status"), false)
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(200, views)
+}
+
+// GetPaymentPlans lists every patient's plans, optionally by ?status= and only those with overdue
+// installments when ?overdue=true
+func (h *PaymentPlanHandler) GetPaymentPlans(c *gin.Context) {
+	views, err := h.service.List(c, "", c.Query("status"), c.Query("overdue") == "true")
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(200, views)
+}
+
+func (h *PaymentPlanHandler) GetPaymentPlan(c *gin.Context) {
+	id, ok := paymentPlanID(c)
+	if !ok {
+		return
+	}
+	view, err := h.service.GetByID(c, id)
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(200, view)
+}
+
+// RecordPayment adds a payment towards one installment of the plan
+func (h *PaymentPlanHandler) RecordPayment(c *gin.Context) {
+	id, ok := paymentPlanID(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid installment number"})
+		return
+	}
+	var request struct {
+		Amount float64 `json:"amount" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	view, err := h.service.RecordPayment(c, id, number, request.Amount)
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(200, view)
+}
+
+func (h *PaymentPlanHandler) CancelPaymentPlan(c *gin.Context) {
+	id, ok := paymentPlanID(c)
+	if !ok {
+		return
+	}
+	view, err := h.service.Cancel(c, id)
+	if err != nil {
+		paymentPlanError(c, err)
+		return
+	}
+	c.JSON(200, view)
+}
+
+func paymentPlanID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid payment plan ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func paymentPlanError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentPlanNotFound), errors.Is(err, services.ErrInstallmentNotFound),
+		errors.Is(err, services.ErrPatientNotFound), errors.Is(err, services.ErrTreatmentPlanNotFound),
+		errors.Is(err, services.ErrBillingNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentPlanNotActive):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPaymentPlan), errors.Is(err, services.ErrInvalidPayment):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
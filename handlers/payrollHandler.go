@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayrollHandler struct {
+	service *services.PayrollService
+}
+
+func NewPayrollHandler(service *services.PayrollService) *PayrollHandler {
+	return &PayrollHandler{service: service}
+}
+
+// GetPayroll returns the payroll of the month :period (YYYY-MM), as signed off once it is
+func (h *PayrollHandler) GetPayroll(c *gin.Context) {
+	payroll, err := h.service.Payroll(c, c.Param("period"))
+	if err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(200, payroll)
+}
+
+// ExportPayroll returns the payroll of the month :period as a CSV file for payroll preparation
+func (h *PayrollHandler) ExportPayroll(c *gin.Context) {
+	content, err := h.service.Export(c, c.Param("period"))
+	if err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="payroll-`+c.Param("period")+`.csv"`)
+	c.Data(200, "text/csv; charset=utf-8", content)
+}
+
+func (h *PayrollHandler) SignOffPayroll(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	payroll, err := h.service.SignOff(c, c.Param("period"), userID)
+	if err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(201, payroll)
+}
+
+// ReopenPayroll asks for a signed-off month to be reopened, answering 202 with the approval it
+// waits for
+func (h *PayrollHandler) ReopenPayroll(c *gin.Context) {
+	if err := h.service.Reopen(c, c.Param("period")); err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Payroll reopened"})
+}
+
+func (h *PayrollHandler) GetCommissionRates(c *gin.Context) {
+	rates, err := h.service.CommissionRates(c)
+	if err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(200, rates)
+}
+
+// SetCommissionRate pays the doctor :id the percentage in the body, e.g. {"rate": 30}
+func (h *PayrollHandler) SetCommissionRate(c *gin.Context) {
+	var request struct {
+		Rate *float64 `json:"rate" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	rate, err := h.service.SetCommissionRate(c, c.Param("id"), *request.Rate)
+	if err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(200, rate)
+}
+
+// DeleteCommissionRate takes the doctor :id back to the clinic's default commission rate
+func (h *PayrollHandler) DeleteCommissionRate(c *gin.Context) {
+	if err := h.service.DeleteCommissionRate(c, c.Param("id")); err != nil {
+		payrollError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Commission rate deleted successfully"})
+}
+
+func payrollError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrApprovalRequired):
+		approvalPending(c, err)
+	case errors.Is(err, services.ErrDoctorNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPayroll):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPayrollSignedOff), errors.Is(err, services.ErrPayrollNotSignedOff):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
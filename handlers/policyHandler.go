@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyHandler struct {
+	authorization *middlewares.Authorization
+}
+
+func NewPolicyHandler(authorization *middlewares.Authorization) *PolicyHandler {
+	return &PolicyHandler{authorization: authorization}
+}
+
+// GetPolicies lists every route with the policy enforced on it and the policy table entry it comes from
+func (h *PolicyHandler) GetPolicies(c *gin.Context) {
+	c.JSON(200, h.authorization.Routes())
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PostOpHandler struct {
+	service *services.PostOpService
+}
+
+func NewPostOpHandler(service *services.PostOpService) *PostOpHandler {
+	return &PostOpHandler{service: service}
+}
+
+// CreatePostOpInstruction adds the instructions of a procedure in one language
+func (h *PostOpHandler) CreatePostOpInstruction(c *gin.Context) {
+	var instruction models.PostOpInstruction
+	if err := c.ShouldBindJSON(&instruction); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &instruction); err != nil {
+		postOpError(c, err)
+		return
+	}
+	c.JSON(201, instruction)
+}
+
+// GetPostOpInstructions lists the instructions, of ?procedure_id= only when given
+func (h *PostOpHandler) GetPostOpInstructions(c *gin.Context) {
+	var procedureID *uint
+	if value := c.Query("procedure_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid procedure_id"})
+			return
+		}
+		parsed := uint(id)
+		procedureID = &parsed
+	}
+	instructions, err := h.service.List(c, procedureID)
+	if err != nil {
+		postOpError(c, err)
+		return
+	}
+	c.JSON(200, instructions)
+}
+
+func (h *PostOpHandler) GetPostOpInstruction(c *gin.Context) {
+	id, ok := postOpParamID(c)
+	if !ok {
+		return
+	}
+	instruction, err := h.service.Get(c, id)
+	if err != nil {
+		postOpError(c, err)
+		return
+	}
+	c.JSON(200, instruction)
+}
+
+func (h *PostOpHandler) UpdatePostOpInstruction(c *gin.Context) {
+	id, ok := postOpParamID(c)
+	if !ok {
+		return
+	}
+	var instruction models.PostOpInstruction
+	if err := c.ShouldBindJSON(&instruction); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	instruction.ID = id
+	if err := h.service.Update(c, &instruction); err != nil {
+		postOpError(c, err)
+		return
+	}
+	c.JSON(200, instruction)
+}
+
+func (h *PostOpHandler) DeletePostOpInstruction(c *gin.Context) {
+	id, ok := postOpParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		postOpError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Post-operative instruction deleted successfully"})
+}
+
+func postOpParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func postOpError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPostOpInstructionNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPostOpInstruction):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrescriptionHandler struct {
+	service *services.PrescriptionService
+}
+
+func NewPrescriptionHandler(service *services.PrescriptionService) *PrescriptionHandler {
+	return &PrescriptionHandler{service: service}
+}
+
+// prescriptionRequest is a prescription with the codes of the warnings the prescriber acknowledges
+type prescriptionRequest struct {
+	Drug         string   `json:"drug" binding:"required"`
+	Dose         string   `json:"dose"`
+	Frequency    string   `json:"frequency"`
+	DurationDays int      `json:"duration_days" binding:"required"`
+	Quantity     float64  `json:"quantity"`
+	Instructions string   `json:"instructions"`
+	Acknowledged []string `json:"acknowledged_warnings"`
+}
+
+// CheckPrescription returns the warnings for prescribing the drug in the body, without saving anything
+func (h *PrescriptionHandler) CheckPrescription(c *gin.Context) {
+	var request struct {
+		Drug string `json:"drug" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	warnings, err := h.service.Check(c, c.Param("patient_id"), request.Drug)
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"warnings": warnings})
+}
+
+// CreatePrescription saves the prescription, or answers 409 with the warnings still to be acknowledged
+func (h *PrescriptionHandler) CreatePrescription(c *gin.Context) {
+	var request prescriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	prescription := models.Prescription{
+		PatientID:    c.Param("patient_id"),
+		PrescribedBy: userID,
+		Drug:         request.Drug,
+		Dose:         request.Dose,
+		Frequency:    request.Frequency,
+		DurationDays: request.DurationDays,
+		Quantity:     request.Quantity,
+		Instructions: request.Instructions,
+	}
+	role, _ := middlewares.ExtractUserRoleFromContext(c.Request.Context())
+	audit := middlewares.NewAuditEntry(c, models.AuditCategoryClinical, models.AuditEventPrescriptionWarningsAcknowledged)
+	audit.Status = 201
+	warnings, err := h.service.Create(c, &prescription, role, request.Acknowledged, audit)
+	if err != nil {
+		prescriptionError(c, err, warnings)
+		return
+	}
+	c.JSON(201, gin.H{"prescription": prescription, "acknowledged_warnings": warnings})
+}
+
+func (h *PrescriptionHandler) GetPrescriptions(c *gin.Context) {
+	prescriptions, err := h.service.GetByPatient(c, c.Param("patient_id"))
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, prescriptions)
+}
+
+func (h *PrescriptionHandler) CreateAllergy(c *gin.Context) {
+	var allergy models.PatientAllergy
+	if err := c.ShouldBindJSON(&allergy); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	allergy.PatientID = c.Param("patient_id")
+	if err := h.service.AddAllergy(c, &allergy); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(201, allergy)
+}
+
+func (h *PrescriptionHandler) GetAllergies(c *gin.Context) {
+	allergies, err := h.service.GetAllergies(c, c.Param("patient_id"))
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, allergies)
+}
+
+func (h *PrescriptionHandler) DeleteAllergy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid allergy ID"})
+		return
+	}
+	if err := h.service.DeleteAllergy(c, c.Param("patient_id"), uint(id)); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Allergy deleted successfully"})
+}
+
+func (h *PrescriptionHandler) CreateDrugInteraction(c *gin.Context) {
+	var interaction models.DrugInteraction
+	if err := c.ShouldBindJSON(&interaction); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.AddInteraction(c, &interaction); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(201, interaction)
+}
+
+// GetDrugInteractions lists the interaction table, limited to ?drug= when given
+func (h *PrescriptionHandler) GetDrugInteractions(c *gin.Context) {
+	interactions, err := h.service.GetInteractions(c, c.Query("drug"))
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, interactions)
+}
+
+func (h *PrescriptionHandler) DeleteDrugInteraction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid drug interaction ID"})
+		return
+	}
+	if err := h.service.DeleteInteraction(c, uint(id)); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Drug interaction deleted successfully"})
+}
+
+func (h *PrescriptionHandler) GetControlledSubstances(c *gin.Context) {
+	substances, err := h.service.GetControlledSubstances(c)
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, substances)
+}
+
+func (h *PrescriptionHandler) CreateControlledSubstance(c *gin.Context) {
+	var substance models.ControlledSubstance
+	if err := c.ShouldBindJSON(&substance); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.AddControlledSubstance(c, &substance); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(201, substance)
+}
+
+func (h *PrescriptionHandler) DeleteControlledSubstance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid controlled substance ID"})
+		return
+	}
+	if err := h.service.DeleteControlledSubstance(c, uint(id)); err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Controlled substance deleted successfully"})
+}
+
+// GetControlledRegister lists the register entries for the days from ?from= to ?to= (YYYY-MM-DD)
+// inclusive, defaulting to the current month
+func (h *PrescriptionHandler) GetControlledRegister(c *gin.Context) {
+	now := models.ClinicNow()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, models.ClinicLocation())
+	to := now
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	entries, err := h.service.GetRegister(c, from, to)
+	if err != nil {
+		prescriptionError(c, err, nil)
+		return
+	}
+	c.JSON(200, entries)
+}
+
+func prescriptionError(c *gin.Context, err error, warnings []models.PrescriptionWarning) {
+	switch {
+	case errors.Is(err, services.ErrUnacknowledgedWarnings):
+		c.JSON(409, gin.H{"error": err.Error(), "warnings": warnings})
+	case errors.Is(err, services.ErrControlledPrescriber):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDrugInteractionDuplicate):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPrescription), errors.Is(err, services.ErrInvalidAllergy), errors.Is(err, services.ErrInvalidDrugInteraction),
+		errors.Is(err, services.ErrInvalidControlledDrug):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
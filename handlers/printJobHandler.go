@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrintJobHandler struct {
+	service *services.PrintService
+}
+
+func NewPrintJobHandler(service *services.PrintService) *PrintJobHandler {
+	return &PrintJobHandler{service: service}
+}
+
+// CreatePrintJob renders a document for a patient and queues it for the printer named in the request
+func (h *PrintJobHandler) CreatePrintJob(c *gin.Context) {
+	var request models.PrintJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := h.service.Enqueue(c, request)
+	if err != nil {
+		printJobError(c, err)
+		return
+	}
+	c.JSON(201, job)
+}
+
+// GetPrintJobs lists the latest print jobs, filtered by ?printer= and ?status=
+func (h *PrintJobHandler) GetPrintJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	jobs, err := h.service.List(c, c.Query("printer"), c.Query("status"), limit)
+	if err != nil {
+		printJobError(c, err)
+		return
+	}
+	c.JSON(200, jobs)
+}
+
+func (h *PrintJobHandler) GetPrintJob(c *gin.Context) {
+	id, ok := printJobID(c)
+	if !ok {
+		return
+	}
+	job, err := h.service.Get(c, id)
+	if err != nil {
+		printJobError(c, err)
+		return
+	}
+	c.JSON(200, job)
+}
+
+// CancelPrintJob takes a job that has not been printed yet off the queue
+func (h *PrintJobHandler) CancelPrintJob(c *gin.Context) {
+	id, ok := printJobID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Cancel(c, id); err != nil {
+		printJobError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Print job cancelled"})
+}
+
+// ClaimPrintJob hands the polling agent the next job for ?printer= with its content, base64 encoded,
+// answering 204 when there is nothing to print
+func (h *PrintJobHandler) ClaimPrintJob(c *gin.Context) {
+	job, err := h.service.Claim(c, c.Query("printer"), c.Query("agent"))
+	if err != nil {
+		printJobError(c, err)
+		return
+	}
+	if job == nil {
+		c.Status(204)
+		return
+	}
+	c.JSON(200, gin.H{"job": job, "content": job.Content})
+}
+
+// CompletePrintJob records the agent's report on a job it claimed
+func (h *PrintJobHandler) CompletePrintJob(c *gin.Context) {
+	id, ok := printJobID(c)
+	if !ok {
+		return
+	}
+	var result models.PrintJobResult
+	if err := c.ShouldBindJSON(&result); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := h.service.Complete(c, id, result)
+	if err != nil {
+		printJobError(c, err)
+		return
+	}
+	c.JSON(200, job)
+}
+
+func printJobID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid print job ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func printJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPrintJobNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPrintJob):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPrintJobState):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
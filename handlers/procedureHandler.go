@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProcedureHandler struct {
+	service *services.ProcedureService
+}
+
+func NewProcedureHandler(service *services.ProcedureService) *ProcedureHandler {
+	return &ProcedureHandler{service: service}
+}
+
+func (h *ProcedureHandler) CreateProcedure(c *gin.Context) {
+	var procedure models.Procedure
+	if err := c.ShouldBindJSON(&procedure); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &procedure); err != nil {
+		procedureError(c, err)
+		return
+	}
+	c.JSON(201, procedure)
+}
+
+// GetProcedures lists the catalog, limited to ?specialty= when given
+func (h *ProcedureHandler) GetProcedures(c *gin.Context) {
+	procedures, err := h.service.List(c, c.Query("specialty"))
+	if err != nil {
+		procedureError(c, err)
+		return
+	}
+	c.JSON(200, procedures)
+}
+
+func (h *ProcedureHandler) GetProcedure(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid procedure ID"})
+		return
+	}
+	procedure, err := h.service.GetByID(c, uint(id))
+	if err != nil {
+		procedureError(c, err)
+		return
+	}
+	c.JSON(200, procedure)
+}
+
+func (h *ProcedureHandler) UpdateProcedure(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid procedure ID"})
+		return
+	}
+	var procedure models.Procedure
+	if err := c.ShouldBindJSON(&procedure); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	procedure.ID = uint(id)
+	if err := h.service.Update(c, &procedure); err != nil {
+		procedureError(c, err)
+		return
+	}
+	c.JSON(200, procedure)
+}
+
+func (h *ProcedureHandler) DeleteProcedure(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid procedure ID"})
+		return
+	}
+	if err := h.service.Delete(c, uint(id)); err != nil {
+		procedureError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Procedure deleted successfully"})
+}
+
+func procedureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProcedureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSpecialty), errors.Is(err, services.ErrInvalidProcedurePrice):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuarantineHandler struct {
+	service *services.VirusScanService
+}
+
+func NewQuarantineHandler(service *services.VirusScanService) *QuarantineHandler {
+	return &QuarantineHandler{service: service}
+}
+
+// GetQuarantinedFiles lists the uploads the virus scanner flagged, latest first
+func (h *QuarantineHandler) GetQuarantinedFiles(c *gin.Context) {
+	files, err := h.service.List(c)
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(200, files)
+}
+
+func (h *QuarantineHandler) GetQuarantinedFile(c *gin.Context) {
+	id, ok := quarantinedFileID(c)
+	if !ok {
+		return
+	}
+	file, err := h.service.Get(c, id)
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(200, file)
+}
+
+// DeleteQuarantinedFile discards a flagged upload
+func (h *QuarantineHandler) DeleteQuarantinedFile(c *gin.Context) {
+	id, ok := quarantinedFileID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Quarantined file deleted"})
+}
+
+func quarantinedFileID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid quarantined file ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func quarantineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrQuarantinedFileNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
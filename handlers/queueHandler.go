@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueueHandler struct {
+	service *services.QueueService
+}
+
+func NewQueueHandler(service *services.QueueService) *QueueHandler {
+	return &QueueHandler{service: service}
+}
+
+func (h *QueueHandler) GetTodayQueue(c *gin.Context) {
+	entries, err := h.service.Today(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, entries)
+}
+
+func (h *QueueHandler) CheckIn(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	eligibility, err := h.service.CheckIn(c, patientID, uint(id))
+	if err != nil {
+		queueError(c, err)
+		return
+	}
+	if eligibility != nil {
+		c.JSON(200, gin.H{"message": "Patient checked in", "warning": "Insurance eligibility was not confirmed", "eligibility": eligibility})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Patient checked in"})
+}
+
+func (h *QueueHandler) MarkSeen(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	if err := h.service.MarkSeen(c, patientID, uint(id)); err != nil {
+		queueError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Patient marked as seen"})
+}
+
+// StartTreatment moves a checked-in patient into treatment, or answers 409 with what is outstanding
+func (h *QueueHandler) StartTreatment(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	if err := h.service.Start(c, patientID, uint(id)); err != nil {
+		queueError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Treatment started"})
+}
+
+func (h *QueueHandler) GetDashboardSummary(c *gin.Context) {
+	summary, err := h.service.Summary(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, summary)
+}
+
+func queueError(c *gin.Context, err error) {
+	if errors.Is(err, repositories.ErrAppointmentNotCheckable) || errors.Is(err, repositories.ErrAppointmentNotCheckedIn) || errors.Is(err, repositories.ErrVisitNotReady) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repositories.ErrAppointmentNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
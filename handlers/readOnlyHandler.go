@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReadOnlyHandler struct {
+	service *services.ReadOnlyService
+}
+
+func NewReadOnlyHandler(service *services.ReadOnlyService) *ReadOnlyHandler {
+	return &ReadOnlyHandler{service: service}
+}
+
+// GetReadOnlyStatus tells whether the server refuses writes, why, and whether it reads from the replica
+func (h *ReadOnlyHandler) GetReadOnlyStatus(c *gin.Context) {
+	c.JSON(200, h.service.Status())
+}
+
+// SetReadOnly turns read-only mode on or off for every server
+func (h *ReadOnlyHandler) SetReadOnly(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Enabled *bool  `json:"enabled" binding:"required"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	status, err := h.service.Set(c, *req.Enabled, req.Message, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidReadOnly):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrReadOnlyToggleUnavailable):
+			c.JSON(503, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, status)
+}
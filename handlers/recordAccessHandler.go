@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecordAccessHandler struct {
+	service *services.RecordAccessService
+}
+
+func NewRecordAccessHandler(service *services.RecordAccessService) *RecordAccessHandler {
+	return &RecordAccessHandler{service: service}
+}
+
+// GetMyRecordAccesses lists who read the signed-in patient's record, newest first, optionally by
+// ?section= and the days ?from= and ?to= (YYYY-MM-DD)
+func (h *RecordAccessHandler) GetMyRecordAccesses(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	filter, ok := recordAccessFilter(c)
+	if !ok {
+		return
+	}
+	viewers, err := h.service.MyViewers(c, userID, filter)
+	if err != nil {
+		recordAccessError(c, err)
+		return
+	}
+	c.JSON(200, viewers)
+}
+
+// GetPatientRecordAccesses lists the reads of a patient's record, newest first, with the same
+// filters as GetRecordAccesses
+func (h *RecordAccessHandler) GetPatientRecordAccesses(c *gin.Context) {
+	filter, ok := recordAccessFilter(c)
+	if !ok {
+		return
+	}
+	filter.PatientID = c.Param("patient_id")
+	h.accesses(c, filter)
+}
+
+// GetRecordAccesses lists the reads of patients' records, newest first, optionally of a
+// ?patient_id=, by a ?user_id=, of a ?section= and on the days ?from= and ?to= (YYYY-MM-DD)
+func (h *RecordAccessHandler) GetRecordAccesses(c *gin.Context) {
+	filter, ok := recordAccessFilter(c)
+	if !ok {
+		return
+	}
+	filter.PatientID = c.Query("patient_id")
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter.UserID = &userID
+	}
+	h.accesses(c, filter)
+}
+
+func (h *RecordAccessHandler) accesses(c *gin.Context, filter models.RecordAccessFilter) {
+	accesses, err := h.service.Accesses(c, filter)
+	if err != nil {
+		recordAccessError(c, err)
+		return
+	}
+	c.JSON(200, accesses)
+}
+
+// recordAccessFilter reads the section, days and limit of a record access list. The to day is
+// included.
+func recordAccessFilter(c *gin.Context) (models.RecordAccessFilter, bool) {
+	filter := models.RecordAccessFilter{Section: c.Query("section")}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	for param, day := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseClinicDate(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid " + param + " date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		if param == "to" {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		*day = &parsed
+	}
+	return filter, true
+}
+
+func recordAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPatientNotLinked):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRecordAccessFilter):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
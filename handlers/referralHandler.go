@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReferralHandler struct {
+	service *services.ReferralService
+}
+
+func NewReferralHandler(service *services.ReferralService) *ReferralHandler {
+	return &ReferralHandler{service: service}
+}
+
+type referralStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+func (h *ReferralHandler) CreateReferralTemplate(c *gin.Context) {
+	var template models.ReferralTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateTemplate(c, &template); err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(201, template)
+}
+
+func (h *ReferralHandler) GetReferralTemplates(c *gin.Context) {
+	templates, err := h.service.ListTemplates(c)
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, templates)
+}
+
+func (h *ReferralHandler) GetReferralTemplate(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	template, err := h.service.GetTemplate(c, id)
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+func (h *ReferralHandler) UpdateReferralTemplate(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	var template models.ReferralTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID = id
+	if err := h.service.UpdateTemplate(c, &template); err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, template)
+}
+
+func (h *ReferralHandler) DeleteReferralTemplate(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteTemplate(c, id); err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Referral template deleted successfully"})
+}
+
+// CreateReferral writes a referral letter for the patient, quoting the examinations in
+// examination_ids and linking the imaging in imaging_study_ids
+func (h *ReferralHandler) CreateReferral(c *gin.Context) {
+	var referral models.Referral
+	if err := c.ShouldBindJSON(&referral); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	referral.PatientID = c.Param("patient_id")
+	if err := h.service.Create(c, &referral); err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(201, referral)
+}
+
+func (h *ReferralHandler) GetReferrals(c *gin.Context) {
+	referrals, err := h.service.List(c, c.Param("patient_id"))
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, referrals)
+}
+
+func (h *ReferralHandler) GetReferral(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	referral, err := h.service.Get(c, c.Param("patient_id"), id)
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, referral)
+}
+
+// UpdateReferralStatus records how far the referral has got with the specialist
+func (h *ReferralHandler) UpdateReferralStatus(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	var request referralStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	referral, err := h.service.SetStatus(c, c.Param("patient_id"), id, request.Status, request.Note)
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.JSON(200, referral)
+}
+
+// GetReferralLetterPDF returns the referral letter as a PDF to print or send to the specialist
+func (h *ReferralHandler) GetReferralLetterPDF(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	data, err := h.service.PDF(c, c.Param("patient_id"), id)
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="referral-%d.pdf"`, id))
+	c.Data(200, "application/pdf", data)
+}
+
+// GetReferralImaging lets the specialist download an imaging study linked from their referral
+// letter, authenticated by the link's signature only
+func (h *ReferralHandler) GetReferralImaging(c *gin.Context) {
+	id, ok := referralParamID(c, "id")
+	if !ok {
+		return
+	}
+	studyID, ok := referralParamID(c, "study_id")
+	if !ok {
+		return
+	}
+	study, err := h.service.LinkedStudy(c, id, studyID, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		referralError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", `attachment; filename="`+study.FileName+`"`)
+	c.Data(200, "application/dicom", study.Content)
+}
+
+func referralParamID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func referralError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrReferralNotFound), errors.Is(err, services.ErrReferralTemplateNotFound),
+		errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReferral), errors.Is(err, services.ErrInvalidReferralTemplate):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReferralLink):
+		c.JSON(403, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
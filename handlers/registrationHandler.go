@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegistrationHandler struct {
+	service *services.RegistrationService
+}
+
+func NewRegistrationHandler(service *services.RegistrationService) *RegistrationHandler {
+	return &RegistrationHandler{service: service}
+}
+
+// SubmitRegistration takes the public pre-registration form with its captcha response
+func (h *RegistrationHandler) SubmitRegistration(c *gin.Context) {
+	var request struct {
+		models.PendingRegistration
+		CaptchaToken string `json:"captcha_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	registration := request.PendingRegistration
+	if err := h.service.Submit(c, &registration, request.CaptchaToken, c.ClientIP()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCaptchaFailed), errors.Is(err, services.ErrInvalidRegistration):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			// The public form never sees internal errors
+			log.Printf("Failed to submit registration: %v", err)
+			c.JSON(500, gin.H{"error": "Registration could not be submitted, please try again later"})
+		}
+		return
+	}
+	c.JSON(201, gin.H{"message": "Thank you, we will confirm your registration at your first visit"})
+}
+
+// GetRegistrations lists pre-registrations by ?status=, the pending queue by default
+func (h *RegistrationHandler) GetRegistrations(c *gin.Context) {
+	registrations, err := h.service.List(c, c.Query("status"))
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.JSON(200, registrations)
+}
+
+func (h *RegistrationHandler) GetRegistration(c *gin.Context) {
+	id, ok := registrationID(c)
+	if !ok {
+		return
+	}
+	registration, err := h.service.GetByID(c, id)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.JSON(200, registration)
+}
+
+// ConvertRegistration creates the patient record from a pending registration
+func (h *RegistrationHandler) ConvertRegistration(c *gin.Context) {
+	id, ok := registrationID(c)
+	if !ok {
+		return
+	}
+	reviewer, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	note := reviewNote(c)
+	if note == nil {
+		return
+	}
+	patient, err := h.service.Convert(c, id, reviewer, *note)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.JSON(201, patient)
+}
+
+func (h *RegistrationHandler) RejectRegistration(c *gin.Context) {
+	id, ok := registrationID(c)
+	if !ok {
+		return
+	}
+	reviewer, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	note := reviewNote(c)
+	if note == nil {
+		return
+	}
+	registration, err := h.service.Reject(c, id, reviewer, *note)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.JSON(200, registration)
+}
+
+// reviewNote reads the optional review note from the body, answering 400 and returning nil when it is malformed
+func reviewNote(c *gin.Context) *string {
+	var request struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return nil
+		}
+	}
+	return &request.Note
+}
+
+func registrationID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid registration ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func registrationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRegistrationNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistrationReviewed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
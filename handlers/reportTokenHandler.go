@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportTokenHandler struct {
+	service *services.ReportTokenService
+}
+
+func NewReportTokenHandler(service *services.ReportTokenService) *ReportTokenHandler {
+	return &ReportTokenHandler{service: service}
+}
+
+// reportTokenRequest mints a token for the reports and branches listed, every one of them when
+// left empty, working until ExpiresAt or the configured lifetime
+type reportTokenRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Reports   []string   `json:"reports"`
+	Branches  []string   `json:"branches"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// MintReportToken creates a read-only report token. The token is only in this response.
+func (h *ReportTokenHandler) MintReportToken(c *gin.Context) {
+	var request reportTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	token := models.ReportToken{
+		Name:      request.Name,
+		Reports:   request.Reports,
+		Branches:  request.Branches,
+		ExpiresAt: request.ExpiresAt,
+	}
+	if err := h.service.Mint(c, &token); err != nil {
+		reportTokenError(c, err)
+		return
+	}
+	c.JSON(201, token)
+}
+
+func (h *ReportTokenHandler) GetReportTokens(c *gin.Context) {
+	tokens, err := h.service.List(c)
+	if err != nil {
+		reportTokenError(c, err)
+		return
+	}
+	c.JSON(200, tokens)
+}
+
+func (h *ReportTokenHandler) GetReportToken(c *gin.Context) {
+	id, ok := reportTokenParamID(c)
+	if !ok {
+		return
+	}
+	token, err := h.service.Get(c, id)
+	if err != nil {
+		reportTokenError(c, err)
+		return
+	}
+	c.JSON(200, token)
+}
+
+// RevokeReportToken stops a token from working before it expires
+func (h *ReportTokenHandler) RevokeReportToken(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := reportTokenParamID(c)
+	if !ok {
+		return
+	}
+	token, err := h.service.Revoke(c, id, userID)
+	if err != nil {
+		reportTokenError(c, err)
+		return
+	}
+	c.JSON(200, token)
+}
+
+func reportTokenParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func reportTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrReportTokenNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReportToken):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionHandler struct {
+	service *services.RetentionService
+}
+
+func NewRetentionHandler(service *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{service: service}
+}
+
+// GetRetentionPolicies lists the retention rules in force
+func (h *RetentionHandler) GetRetentionPolicies(c *gin.Context) {
+	c.JSON(200, h.service.Policies())
+}
+
+// RunRetention enforces the retention rules now, or with ?dry_run=true reports what they would remove
+func (h *RetentionHandler) RunRetention(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	runs, err := h.service.Enforce(c, dryRun)
+	if err != nil {
+		retentionError(c, err)
+		return
+	}
+	c.JSON(200, runs)
+}
+
+// GetRetentionRuns lists the latest retention runs, of ?entity= when given
+func (h *RetentionHandler) GetRetentionRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.service.Runs(c, c.Query("entity"), limit)
+	if err != nil {
+		retentionError(c, err)
+		return
+	}
+	c.JSON(200, runs)
+}
+
+func retentionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRetentionEntity):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	service *services.RoleService
+}
+
+func NewRoleHandler(service *services.RoleService) *RoleHandler {
+	return &RoleHandler{service: service}
+}
+
+type roleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	BasedOn     string `json:"based_on"`
+}
+
+type roleUpdateRequest struct {
+	Description string `json:"description"`
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var request roleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	role := models.Role{Name: request.Name, Description: request.Description, BasedOn: request.BasedOn}
+	if err := h.service.CreateRole(c, &role); err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(201, role)
+}
+
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles(c)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, roles)
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	id, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	role, err := h.service.GetRole(c, id)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, role)
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	var request roleUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	role, err := h.service.UpdateRole(c, id, request.Description)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteRole(c, id); err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Role deleted successfully"})
+}
+
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.service.ListPermissions(c)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, permissions)
+}
+
+// GrantPermission gives the role the permission
+func (h *RoleHandler) GrantPermission(c *gin.Context) {
+	roleID, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	permissionID, ok := roleParamID(c, "permission_id")
+	if !ok {
+		return
+	}
+	role, err := h.service.Grant(c, roleID, permissionID)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, role)
+}
+
+// RevokePermission takes the permission from the role
+func (h *RoleHandler) RevokePermission(c *gin.Context) {
+	roleID, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	permissionID, ok := roleParamID(c, "permission_id")
+	if !ok {
+		return
+	}
+	role, err := h.service.Revoke(c, roleID, permissionID)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, role)
+}
+
+// GetUserPermissions returns what the user may do through their role
+func (h *RoleHandler) GetUserPermissions(c *gin.Context) {
+	userID, ok := roleParamID(c, "id")
+	if !ok {
+		return
+	}
+	permissions, err := h.service.EffectivePermissions(c, userID)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+	c.JSON(200, permissions)
+}
+
+func roleParamID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+func roleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrPermissionNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRole):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSeededRole), errors.Is(err, repositories.ErrRoleInUse):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RosterHandler struct {
+	service *services.RosterService
+}
+
+func NewRosterHandler(service *services.RosterService) *RosterHandler {
+	return &RosterHandler{service: service}
+}
+
+type rosterReviewRequest struct {
+	Status     string `json:"status" binding:"required"`
+	ReviewNote string `json:"review_note"`
+}
+
+func (h *RosterHandler) CreateShift(c *gin.Context) {
+	var shift models.Shift
+	if err := c.ShouldBindJSON(&shift); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateShift(c, &shift); err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(201, shift)
+}
+
+func (h *RosterHandler) GetShift(c *gin.Context) {
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	shift, err := h.service.GetShift(c, id)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, shift)
+}
+
+func (h *RosterHandler) UpdateShift(c *gin.Context) {
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	var shift models.Shift
+	if err := c.ShouldBindJSON(&shift); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	shift.ID = id
+	if err := h.service.UpdateShift(c, &shift); err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, shift)
+}
+
+func (h *RosterHandler) DeleteShift(c *gin.Context) {
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteShift(c, id); err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Shift deleted successfully"})
+}
+
+// GetRoster returns the shifts and approved leave on ?from= through ?to= (YYYY-MM-DD, a week from
+// today by default), of ?user_id= and at ?branch= when given
+func (h *RosterHandler) GetRoster(c *gin.Context) {
+	userID, ok := rosterUserFilter(c)
+	if !ok {
+		return
+	}
+	roster, err := h.service.Roster(c, c.Query("from"), c.Query("to"), userID, c.Query("branch"))
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, roster)
+}
+
+// GetRosterCalendar returns the same roster as GetRoster as an iCalendar file
+func (h *RosterHandler) GetRosterCalendar(c *gin.Context) {
+	userID, ok := rosterUserFilter(c)
+	if !ok {
+		return
+	}
+	calendar, err := h.service.Calendar(c, c.Query("from"), c.Query("to"), userID, c.Query("branch"))
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="roster.ics"`)
+	c.Data(200, "text/calendar; charset=utf-8", calendar)
+}
+
+// GetMyRoster returns the signed-in staff member's shifts and approved leave on ?from= through ?to=
+func (h *RosterHandler) GetMyRoster(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	roster, err := h.service.Roster(c, c.Query("from"), c.Query("to"), &userID, "")
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, roster)
+}
+
+// GetMyRosterCalendar returns the signed-in staff member's roster as an iCalendar file
+func (h *RosterHandler) GetMyRosterCalendar(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	calendar, err := h.service.Calendar(c, c.Query("from"), c.Query("to"), &userID, "")
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="my-roster.ics"`)
+	c.Data(200, "text/calendar; charset=utf-8", calendar)
+}
+
+// RequestShiftSwap asks for one of the signed-in staff member's shifts to go to to_user_id
+func (h *RosterHandler) RequestShiftSwap(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var swap models.ShiftSwap
+	if err := c.ShouldBindJSON(&swap); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.RequestSwap(c, userID, &swap); err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(201, swap)
+}
+
+// GetMyShiftSwaps lists the swaps the signed-in staff member asked for or was asked to take
+func (h *RosterHandler) GetMyShiftSwaps(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	swaps, err := h.service.SwapsOf(c, userID)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, swaps)
+}
+
+func (h *RosterHandler) CancelShiftSwap(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	swap, err := h.service.CancelSwap(c, userID, id)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, swap)
+}
+
+// GetShiftSwaps lists the shift swaps, in ?status= only when given
+func (h *RosterHandler) GetShiftSwaps(c *gin.Context) {
+	swaps, err := h.service.Swaps(c, c.Query("status"))
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, swaps)
+}
+
+// ReviewShiftSwap approves or rejects a pending swap; an approved swap hands the shift over
+func (h *RosterHandler) ReviewShiftSwap(c *gin.Context) {
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	var request rosterReviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	swap, err := h.service.ReviewSwap(c, id, request.Status, request.ReviewNote)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, swap)
+}
+
+// RequestLeave asks for the signed-in staff member to be away from start_date through end_date
+func (h *RosterHandler) RequestLeave(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var leave models.LeaveRequest
+	if err := c.ShouldBindJSON(&leave); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.RequestLeave(c, userID, &leave); err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(201, leave)
+}
+
+func (h *RosterHandler) GetMyLeaveRequests(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	leave, err := h.service.LeaveOf(c, userID)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, leave)
+}
+
+func (h *RosterHandler) CancelLeaveRequest(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	leave, err := h.service.CancelLeave(c, userID, id)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, leave)
+}
+
+// GetLeaveRequests lists the leave requests, in ?status= only when given
+func (h *RosterHandler) GetLeaveRequests(c *gin.Context) {
+	leave, err := h.service.Leave(c, c.Query("status"))
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, leave)
+}
+
+func (h *RosterHandler) ReviewLeaveRequest(c *gin.Context) {
+	id, ok := rosterParamID(c)
+	if !ok {
+		return
+	}
+	var request rosterReviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	leave, err := h.service.ReviewLeave(c, id, request.Status, request.ReviewNote)
+	if err != nil {
+		rosterError(c, err)
+		return
+	}
+	c.JSON(200, leave)
+}
+
+func rosterParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func rosterUserFilter(c *gin.Context) (*int64, bool) {
+	value := c.Query("user_id")
+	if value == "" {
+		return nil, true
+	}
+	userID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user_id"})
+		return nil, false
+	}
+	return &userID, true
+}
+
+func rosterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrShiftNotFound), errors.Is(err, services.ErrShiftSwapNotFound),
+		errors.Is(err, services.ErrLeaveRequestNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRoster):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrShiftOverlap), errors.Is(err, repositories.ErrRequestNotPending):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SavedFilterHandler struct {
+	service *services.SavedFilterService
+}
+
+func NewSavedFilterHandler(service *services.SavedFilterService) *SavedFilterHandler {
+	return &SavedFilterHandler{service: service}
+}
+
+// CreateSavedFilter saves a view of a list for the signed-in user
+func (h *SavedFilterHandler) CreateSavedFilter(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var filter models.SavedFilter
+	if err := c.ShouldBindJSON(&filter); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	filter.ID, filter.UserID = 0, userID
+	if err := h.service.Create(c, &filter); err != nil {
+		savedFilterError(c, err)
+		return
+	}
+	c.JSON(201, filter)
+}
+
+// GetSavedFilters lists the signed-in user's saved filters, for the ?list= only when given
+func (h *SavedFilterHandler) GetSavedFilters(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	filters, err := h.service.List(c, userID, c.Query("list"))
+	if err != nil {
+		savedFilterError(c, err)
+		return
+	}
+	c.JSON(200, filters)
+}
+
+func (h *SavedFilterHandler) GetSavedFilter(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := savedFilterParamID(c)
+	if !ok {
+		return
+	}
+	filter, err := h.service.Get(c, userID, id)
+	if err != nil {
+		savedFilterError(c, err)
+		return
+	}
+	c.JSON(200, filter)
+}
+
+func (h *SavedFilterHandler) UpdateSavedFilter(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := savedFilterParamID(c)
+	if !ok {
+		return
+	}
+	var filter models.SavedFilter
+	if err := c.ShouldBindJSON(&filter); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	filter.ID, filter.UserID = id, userID
+	if err := h.service.Update(c, &filter); err != nil {
+		savedFilterError(c, err)
+		return
+	}
+	c.JSON(200, filter)
+}
+
+func (h *SavedFilterHandler) DeleteSavedFilter(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := savedFilterParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, userID, id); err != nil {
+		savedFilterError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Saved filter deleted successfully"})
+}
+
+// filteredList returns how a list is filtered and sorted when the request asks for a saved filter
+// with ?filter_id= or a ?sort=, and nil when it asks for neither. It answers the request itself
+// and returns false when the query is not valid.
+func filteredList(c *gin.Context, savedFilters *services.SavedFilterService, list string) (*models.ListQuery, bool) {
+	filterID, sort := c.Query("filter_id"), c.Query("sort")
+	if filterID == "" && sort == "" {
+		return nil, true
+	}
+	var userID int64
+	if filterID != "" {
+		var ok bool
+		if userID, ok = contextUserID(c); !ok {
+			return nil, false
+		}
+	}
+	query, err := savedFilters.Query(c, userID, list, filterID, sort)
+	if err != nil {
+		savedFilterError(c, err)
+		return nil, false
+	}
+	return &query, true
+}
+
+func savedFilterParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func savedFilterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSavedFilterNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSavedFilterExists):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSavedFilter), errors.Is(err, repositories.ErrInvalidListQuery):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
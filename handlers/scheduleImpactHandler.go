@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleImpactHandler struct {
+	service *services.ScheduleImpactService
+}
+
+func NewScheduleImpactHandler(service *services.ScheduleImpactService) *ScheduleImpactHandler {
+	return &ScheduleImpactHandler{service: service}
+}
+
+// GetScheduleImpact summarises what would need rebooking if the doctor were off on ?date= (YYYY-MM-DD)
+func (h *ScheduleImpactHandler) GetScheduleImpact(c *gin.Context) {
+	impact, err := h.service.Impact(c, c.Param("id"), c.Query("date"))
+	if err != nil {
+		scheduleImpactError(c, err)
+		return
+	}
+	c.JSON(200, impact)
+}
+
+// NotifyAffectedPatients messages every patient booked with the doctor on the notice's date
+func (h *ScheduleImpactHandler) NotifyAffectedPatients(c *gin.Context) {
+	var notice models.ScheduleNotice
+	if err := c.ShouldBindJSON(&notice); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	summary, err := h.service.Notify(c, c.Param("id"), notice)
+	if err != nil {
+		scheduleImpactError(c, err)
+		return
+	}
+	c.JSON(200, summary)
+}
+
+func scheduleImpactError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDoctorNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidScheduleNotice):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SettingHandler struct {
+	service *services.SettingService
+}
+
+func NewSettingHandler(service *services.SettingService) *SettingHandler {
+	return &SettingHandler{service: service}
+}
+
+// GetSettings returns the runtime settings in effect.
+func (h *SettingHandler) GetSettings(c *gin.Context) {
+	settings, err := h.service.Get(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, settings)
+}
+
+// GetAppointmentTypes returns the default duration and calendar colour of each appointment type.
+func (h *SettingHandler) GetAppointmentTypes(c *gin.Context) {
+	c.JSON(200, h.service.AppointmentTypes())
+}
+
+// UpdateSettings changes the runtime settings present in the body, e.g. {"debug_logging": true,
+// "birthday_greetings": false, "appointment_types": {"hygiene": {"duration_minutes": 60, "color": "#50B86C"}},
+// "reminder_cadences": {"surgery": [10080, 1440, 120], "hygiene": [1440]},
+// "document_format": {"currency": "KES", "thousands_separator": ",", "date_style": "dd/mm/yyyy"}},
+// reminders being sent the given minutes before appointments of the type. Fields of the document
+// format left out keep their value.
+func (h *SettingHandler) UpdateSettings(c *gin.Context) {
+	var req struct {
+		DebugLogging      *bool                                    `json:"debug_logging"`
+		BirthdayGreetings *bool                                    `json:"birthday_greetings"`
+		AppointmentTypes  map[string]models.AppointmentTypeSetting `json:"appointment_types"`
+		ReminderCadences  map[string]models.ReminderLeads          `json:"reminder_cadences"`
+		DocumentFormat    *struct {
+			Currency           *string `json:"currency"`
+			ThousandsSeparator *string `json:"thousands_separator"`
+			DecimalSeparator   *string `json:"decimal_separator"`
+			DateStyle          *string `json:"date_style"`
+		} `json:"document_format"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DebugLogging == nil && req.BirthdayGreetings == nil && len(req.AppointmentTypes) == 0 && len(req.ReminderCadences) == 0 && req.DocumentFormat == nil {
+		c.JSON(400, gin.H{"error": "No setting to change"})
+		return
+	}
+
+	var settings *models.AppSettings
+	var err error
+	// The document format, reminder cadences and appointment types are applied first, so an
+	// invalid one leaves the switches unchanged
+	if req.DocumentFormat != nil {
+		format := models.CurrentDocumentFormat()
+		if req.DocumentFormat.Currency != nil {
+			format.Currency = *req.DocumentFormat.Currency
+		}
+		if req.DocumentFormat.ThousandsSeparator != nil {
+			format.ThousandsSeparator = *req.DocumentFormat.ThousandsSeparator
+		}
+		if req.DocumentFormat.DecimalSeparator != nil {
+			format.DecimalSeparator = *req.DocumentFormat.DecimalSeparator
+		}
+		if req.DocumentFormat.DateStyle != nil {
+			format.DateStyle = *req.DocumentFormat.DateStyle
+		}
+		if settings, err = h.service.SetDocumentFormat(c, format); err != nil {
+			if errors.Is(err, services.ErrInvalidSetting) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(req.ReminderCadences) > 0 {
+		if settings, err = h.service.SetReminderCadences(c, req.ReminderCadences); err != nil {
+			if errors.Is(err, services.ErrInvalidSetting) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(req.AppointmentTypes) > 0 {
+		if settings, err = h.service.SetAppointmentTypes(c, req.AppointmentTypes); err != nil {
+			if errors.Is(err, services.ErrInvalidSetting) {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.DebugLogging != nil {
+		if settings, err = h.service.SetDebugLogging(c, *req.DebugLogging); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.BirthdayGreetings != nil {
+		if settings, err = h.service.SetBirthdayGreetings(c, *req.BirthdayGreetings); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(200, settings)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SIEMHandler struct {
+	service *services.SIEMService
+}
+
+func NewSIEMHandler(service *services.SIEMService) *SIEMHandler {
+	return &SIEMHandler{service: service}
+}
+
+// GetSIEM reports where the audit trail is forwarded to and how far it got
+func (h *SIEMHandler) GetSIEM(c *gin.Context) {
+	status, err := h.service.Status(c)
+	if err != nil {
+		siemError(c, err)
+		return
+	}
+	c.JSON(200, status)
+}
+
+// ForwardSIEM forwards the audit entries written since the last forwarded one without waiting for the next interval
+func (h *SIEMHandler) ForwardSIEM(c *gin.Context) {
+	forwarded, err := h.service.Forward(c)
+	if err != nil {
+		siemError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"forwarded": forwarded})
+}
+
+func siemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSIEMDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSIEMUnavailable):
+		c.JSON(502, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// smsSignatureHeader carries the SMS gateway's signature of a forwarded reply
+const smsSignatureHeader = "X-SMS-Signature"
+
+type SMSReplyHandler struct {
+	service *services.SMSReplyService
+}
+
+func NewSMSReplyHandler(service *services.SMSReplyService) *SMSReplyHandler {
+	return &SMSReplyHandler{service: service}
+}
+
+// ReceiveSMSReply takes a patient's text message the gateway forwards, e.g. {"message_id": "SM123",
+// "from": "+254712345678", "body": "YES", "received_at": "2026-10-16T08:30:00Z"}
+func (h *SMSReplyHandler) ReceiveSMSReply(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	reply, err := h.service.Receive(c, body, c.GetHeader(smsSignatureHeader))
+	if err != nil {
+		smsReplyError(c, err)
+		return
+	}
+	c.JSON(200, reply)
+}
+
+// GetSMSInbox lists the replies waiting for staff, or those with ?status= (open, handled,
+// confirmed, cancelled or all)
+func (h *SMSReplyHandler) GetSMSInbox(c *gin.Context) {
+	replies, err := h.service.Inbox(c, c.Query("status"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSMSReply) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		smsReplyError(c, err)
+		return
+	}
+	c.JSON(200, replies)
+}
+
+// MarkSMSReplyHandled takes a reply out of the inbox, with an optional note of what was done
+func (h *SMSReplyHandler) MarkSMSReplyHandled(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid SMS reply ID"})
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	reply, err := h.service.MarkHandled(c, uint(id), userID, req.Note)
+	if err != nil {
+		smsReplyError(c, err)
+		return
+	}
+	c.JSON(200, reply)
+}
+
+func smsReplyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSMSReply):
+		c.JSON(401, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSMSReplyNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSMSReplyNotOpen):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSMSWebhookDisabled):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SterilizationHandler struct {
+	service *services.SterilizationService
+}
+
+func NewSterilizationHandler(service *services.SterilizationService) *SterilizationHandler {
+	return &SterilizationHandler{service: service}
+}
+
+func (h *SterilizationHandler) CreateBatch(c *gin.Context) {
+	var batch models.SterilizationBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateBatch(c, &batch); err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(201, batch)
+}
+
+// GetBatches lists the batches sterilized from ?from= to ?to= (YYYY-MM-DD) inclusive, defaulting to
+// the last 30 days
+func (h *SterilizationHandler) GetBatches(c *gin.Context) {
+	to := models.ClinicNow()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = models.ParseClinicDate(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, models.ClinicLocation())
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, models.ClinicLocation())
+	batches, err := h.service.ListBatches(c, from, to)
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, batches)
+}
+
+func (h *SterilizationHandler) GetBatch(c *gin.Context) {
+	batch, err := h.service.GetBatch(c, c.Param("id"))
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, batch)
+}
+
+// RecordResult sets whether the batch's cycle passed once its indicators have been read
+func (h *SterilizationHandler) RecordResult(c *gin.Context) {
+	var request struct {
+		Result string `json:"result" binding:"required"`
+		Notes  string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	batch, err := h.service.RecordResult(c, c.Param("id"), request.Result, request.Notes)
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, batch)
+}
+
+// TraceBatch reports which patients were treated with instruments from the batch
+func (h *SterilizationHandler) TraceBatch(c *gin.Context) {
+	report, err := h.service.Trace(c, c.Param("id"))
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+// LinkBatches attaches the batch IDs in the body to the bill
+func (h *SterilizationHandler) LinkBatches(c *gin.Context) {
+	var request struct {
+		BatchIDs []string `json:"batch_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	usages, err := h.service.LinkBatches(c, c.Param("id"), request.BatchIDs)
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, usages)
+}
+
+func (h *SterilizationHandler) GetBillingBatches(c *gin.Context) {
+	usages, err := h.service.GetBillingBatches(c, c.Param("id"))
+	if err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, usages)
+}
+
+func (h *SterilizationHandler) UnlinkBatch(c *gin.Context) {
+	if err := h.service.UnlinkBatch(c, c.Param("id"), c.Param("batch_id")); err != nil {
+		sterilizationError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Batch unlinked successfully"})
+}
+
+func sterilizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBatchNotFound), errors.Is(err, services.ErrBillingNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBatchNotPassed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBatch):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"RoyDental/middlewares"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ndjsonContentType asks for a list as one JSON document per line.
+	ndjsonContentType = "application/x-ndjson"
+	// streamFlushEvery is how many items are written between flushes to the client.
+	streamFlushEvery = 100
+)
+
+// jsonStream writes a list to the client item by item, as a JSON array or, when the client accepts
+// it, as NDJSON, so the response starts before the whole list is read and is never held in memory.
+type jsonStream struct {
+	c       *gin.Context
+	ndjson  bool
+	encoder *json.Encoder
+	count   int
+}
+
+func newJSONStream(c *gin.Context) *jsonStream {
+	return &jsonStream{
+		c:       c,
+		ndjson:  strings.Contains(c.GetHeader("Accept"), ndjsonContentType),
+		encoder: json.NewEncoder(c.Writer),
+	}
+}
+
+// Write sends one item of the list
+func (s *jsonStream) Write(item interface{}) error {
+	if s.count == 0 {
+		s.start()
+	} else if !s.ndjson {
+		if _, err := s.c.Writer.WriteString(","); err != nil {
+			return err
+		}
+	}
+	if err := s.encoder.Encode(item); err != nil {
+		return err
+	}
+	s.count++
+	if s.count%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+// Close ends the list. An error before anything was sent gets the usual error response; after that
+// the status is already out, so a JSON array is left unterminated and NDJSON ends with an error line.
+func (s *jsonStream) Close(err error) {
+	if err != nil {
+		if s.count == 0 {
+			s.c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Streaming %s failed after %d items [request_id=%s]: %v", s.c.Request.URL.Path, s.count, middlewares.RequestID(s.c), err)
+		if s.ndjson {
+			s.encoder.Encode(gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if s.count == 0 {
+		s.start()
+	}
+	if !s.ndjson {
+		s.c.Writer.WriteString("]\n")
+	}
+	s.c.Writer.Flush()
+}
+
+func (s *jsonStream) start() {
+	if s.ndjson {
+		s.c.Header("Content-Type", ndjsonContentType)
+	} else {
+		s.c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	s.c.Status(200)
+	if !s.ndjson {
+		s.c.Writer.WriteString("[")
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SurveyHandler struct {
+	service *services.SurveyService
+}
+
+func NewSurveyHandler(service *services.SurveyService) *SurveyHandler {
+	return &SurveyHandler{service: service}
+}
+
+// GetSurvey shows the visit a signed survey link is about
+func (h *SurveyHandler) GetSurvey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	survey, err := h.service.GetSurvey(c, uint(id), c.Query("sig"))
+	if err != nil {
+		surveyError(c, err)
+		return
+	}
+	c.JSON(200, survey)
+}
+
+// SubmitSurvey collects the rating, NPS score and comment of a signed survey link
+func (h *SurveyHandler) SubmitSurvey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("appointment_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+	var answers struct {
+		Rating  int    `json:"rating" binding:"required"`
+		NPS     *int   `json:"nps" binding:"required"`
+		Comment string `json:"comment" binding:"max=2000"`
+	}
+	if err := c.ShouldBindJSON(&answers); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Respond(c, uint(id), c.Query("sig"), answers.Rating, *answers.NPS, answers.Comment); err != nil {
+		surveyError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Thank you for your feedback"})
+}
+
+// GetDoctorReports aggregates ratings and NPS per doctor for surveys sent in an RFC 3339 from/to range
+func (h *SurveyHandler) GetDoctorReports(c *gin.Context) {
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to: " + err.Error()})
+		return
+	}
+	reports, err := h.service.DoctorReports(c, from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, reports)
+}
+
+func surveyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSurveySignature):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrSurveyNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrSurveyAlreadyAnswered):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(400, gin.H{"error": err.Error()})
+	}
+}
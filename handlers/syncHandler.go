@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	service *services.SyncService
+}
+
+func NewSyncHandler(service *services.SyncService) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// GetSync returns the records created, updated or deleted after the RFC 3339 ?since= cursor, of the
+// comma-separated ?entities= or of every entity. Clients pull again with next_since until has_more
+// is false.
+func (h *SyncHandler) GetSync(c *gin.Context) {
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid since, expected an RFC 3339 timestamp"})
+			return
+		}
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+	var entities []string
+	for _, entity := range strings.Split(c.Query("entities"), ",") {
+		if entity = strings.TrimSpace(entity); entity != "" {
+			entities = append(entities, entity)
+		}
+	}
+
+	page, err := h.service.Pull(c, since, entities, limit)
+	if err != nil {
+		syncError(c, err)
+		return
+	}
+	c.JSON(200, page)
+}
+
+// PushSync saves the changes the desktop app made offline, e.g. {"changes": [{"entity": "patients",
+// "action": "updated", "id": "...", "version": 3, "data": {...}}]}, and answers how each went
+func (h *SyncHandler) PushSync(c *gin.Context) {
+	var req struct {
+		Changes []models.SyncPushChange `json:"changes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := h.service.Push(c, req.Changes, patientConflictAudit(c))
+	if err != nil {
+		syncError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"results": results})
+}
+
+func syncError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSyncRequest):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
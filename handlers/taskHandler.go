@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskHandler struct {
+	service *services.TaskService
+}
+
+func NewTaskHandler(service *services.TaskService) *TaskHandler {
+	return &TaskHandler{service: service}
+}
+
+func (h *TaskHandler) CreateTask(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var task models.Task
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	task.ID = 0
+	task.CreatedBy = userID
+	if err := h.service.CreateTask(c, &task); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(201, task)
+}
+
+// GetTasks lists tasks filtered by assignee_id (or mine=true), patient_id and status
+func (h *TaskHandler) GetTasks(c *gin.Context) {
+	filter := models.TaskFilter{
+		PatientID: c.Query("patient_id"),
+		Status:    c.Query("status"),
+	}
+	if c.Query("mine") == "true" {
+		userID, ok := contextUserID(c)
+		if !ok {
+			return
+		}
+		filter.AssigneeID = userID
+	} else if assignee := c.Query("assignee_id"); assignee != "" {
+		id, err := strconv.ParseInt(assignee, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid assignee ID"})
+			return
+		}
+		filter.AssigneeID = id
+	}
+
+	tasks, err := h.service.ListTasks(c, filter)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, tasks)
+}
+
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	task, err := h.service.GetTask(c, uint(id))
+	if err != nil {
+		taskError(c, err)
+		return
+	}
+	c.JSON(200, task)
+}
+
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	var task models.Task
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	task.ID = uint(id)
+	if err := h.service.UpdateTask(c, &task); err != nil {
+		taskError(c, err)
+		return
+	}
+	c.JSON(200, task)
+}
+
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	if err := h.service.DeleteTask(c, uint(id)); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Task deleted successfully"})
+}
+
+func (h *TaskHandler) CreateNote(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var note models.PatientNote
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	note.ID = 0
+	note.PatientID = c.Param("patient_id")
+	note.AuthorID = userID
+	if err := h.service.AddNote(c, &note); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(201, note)
+}
+
+func (h *TaskHandler) GetNotes(c *gin.Context) {
+	notes, err := h.service.GetNotes(c, c.Param("patient_id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, notes)
+}
+
+func (h *TaskHandler) DeleteNote(c *gin.Context) {
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid note ID"})
+		return
+	}
+	if err := h.service.DeleteNote(c, c.Param("patient_id"), uint(id), userID); err != nil {
+		taskError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Note deleted successfully"})
+}
+
+func taskError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrTaskNotFound) || errors.Is(err, services.ErrNoteNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TreatmentCostHandler struct {
+	service *services.TreatmentCostService
+}
+
+func NewTreatmentCostHandler(service *services.TreatmentCostService) *TreatmentCostHandler {
+	return &TreatmentCostHandler{service: service}
+}
+
+// CompareTreatmentCosts prices the procedures of a treatment plan paying cash and under each of the
+// patient's insurance schemes, with what the insurer and the patient would pay
+func (h *TreatmentCostHandler) CompareTreatmentCosts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("treatment_plan_id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var request struct {
+		Procedures []models.TreatmentCostItem `json:"procedures"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	comparison, err := h.service.Compare(c, c.Param("patient_id"), uint(id), request.Procedures)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTreatmentPlanNotFound):
+			c.JSON(404, gin.H{"error": "Treatment Plan not found"})
+		case errors.Is(err, services.ErrInvalidTreatmentCost):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, comparison)
+}
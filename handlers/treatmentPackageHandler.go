@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TreatmentPackageHandler struct {
+	service *services.TreatmentPackageService
+}
+
+func NewTreatmentPackageHandler(service *services.TreatmentPackageService) *TreatmentPackageHandler {
+	return &TreatmentPackageHandler{service: service}
+}
+
+// treatmentPackageApplyRequest picks the package to apply and when its first installment is due
+// (YYYY-MM-DD, today when empty)
+type treatmentPackageApplyRequest struct {
+	PackageID    uint   `json:"package_id" binding:"required"`
+	FirstDueDate string `json:"first_due_date"`
+}
+
+func (h *TreatmentPackageHandler) CreateTreatmentPackage(c *gin.Context) {
+	var pkg models.TreatmentPackage
+	if err := c.ShouldBindJSON(&pkg); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, &pkg); err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(201, pkg)
+}
+
+func (h *TreatmentPackageHandler) GetTreatmentPackage(c *gin.Context) {
+	id, ok := treatmentPackageParamID(c)
+	if !ok {
+		return
+	}
+	pkg, err := h.service.Get(c, id)
+	if err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(200, pkg)
+}
+
+func (h *TreatmentPackageHandler) GetTreatmentPackages(c *gin.Context) {
+	packages, err := h.service.List(c)
+	if err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(200, packages)
+}
+
+// UpdateTreatmentPackage changes a package; the items given replace its current ones
+func (h *TreatmentPackageHandler) UpdateTreatmentPackage(c *gin.Context) {
+	id, ok := treatmentPackageParamID(c)
+	if !ok {
+		return
+	}
+	var pkg models.TreatmentPackage
+	if err := c.ShouldBindJSON(&pkg); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	pkg.ID = id
+	updated, err := h.service.Update(c, &pkg)
+	if err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+func (h *TreatmentPackageHandler) DeleteTreatmentPackage(c *gin.Context) {
+	id, ok := treatmentPackageParamID(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, id); err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Treatment package deleted successfully"})
+}
+
+// ApplyTreatmentPackage expands a package into a treatment plan and payment plan for the patient
+func (h *TreatmentPackageHandler) ApplyTreatmentPackage(c *gin.Context) {
+	var request treatmentPackageApplyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var firstDueDate time.Time
+	if request.FirstDueDate != "" {
+		var err error
+		if firstDueDate, err = models.ParseClinicDate(request.FirstDueDate); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid first_due_date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	application, err := h.service.Apply(c, request.PackageID, c.Param("patient_id"), firstDueDate)
+	if err != nil {
+		treatmentPackageError(c, err)
+		return
+	}
+	c.JSON(201, application)
+}
+
+func treatmentPackageParamID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func treatmentPackageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTreatmentPackageNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTreatmentPackage), errors.Is(err, services.ErrInvalidPaymentPlan):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
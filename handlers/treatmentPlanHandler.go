@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"RoyDental/models"
+	"RoyDental/repositories"
 	"RoyDental/services"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -24,7 +26,7 @@ func (h *TreatmentPlanHandler) CreateTreatmentPlan(c *gin.Context) {
 		return
 	}
 	if err := h.service.Create(c, &plan); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		treatmentPlanError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, plan)
@@ -69,7 +71,7 @@ func (h *TreatmentPlanHandler) UpdateTreatmentPlan(c *gin.Context) {
 	plan.ID = uint(id)
 	plan.PatientID = patientID
 	if err := h.service.Update(c, &plan); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		treatmentPlanError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, plan)
@@ -88,3 +90,60 @@ func (h *TreatmentPlanHandler) DeleteTreatmentPlan(c *gin.Context) {
 	}
 	c.JSON(http.StatusNoContent, gin.H{"message": "Treatment Plan deleted"})
 }
+
+// GetTreatmentPlanVersions lists every version of a treatment plan, oldest first
+func (h *TreatmentPlanHandler) GetTreatmentPlanVersions(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	id, err := strconv.ParseUint(c.Param("treatment_plan_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	versions, err := h.service.GetVersions(c, patientID, uint(id))
+	if err != nil {
+		treatmentPlanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetTreatmentPlanDiff compares two versions of a treatment plan given as ?from= and ?to=, by
+// default the current version with the one before it
+func (h *TreatmentPlanHandler) GetTreatmentPlanDiff(c *gin.Context) {
+	patientID := c.Param("patient_id")
+	id, err := strconv.ParseUint(c.Param("treatment_plan_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var versions [2]int
+	for i, name := range []string{"from", "to"} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		if versions[i], err = strconv.Atoi(value); err != nil || versions[i] < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " version"})
+			return
+		}
+	}
+	diff, err := h.service.Diff(c, patientID, uint(id), versions[0], versions[1])
+	if err != nil {
+		treatmentPlanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func treatmentPlanError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrTreatmentPlanNotFound), errors.Is(err, services.ErrTreatmentPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Treatment Plan not found"})
+	case errors.Is(err, services.ErrTreatmentPlanVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTreatmentPlanDiff), isTemplateInsertError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
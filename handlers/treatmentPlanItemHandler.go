@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TreatmentPlanItemHandler struct {
+	service *services.TreatmentPlanItemService
+}
+
+func NewTreatmentPlanItemHandler(service *services.TreatmentPlanItemService) *TreatmentPlanItemHandler {
+	return &TreatmentPlanItemHandler{service: service}
+}
+
+func (h *TreatmentPlanItemHandler) CreateTreatmentPlanItem(c *gin.Context) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return
+	}
+	var item models.TreatmentPlanItem
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.Create(c, c.Param("patient_id"), treatmentPlanID, &item); err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(201, item)
+}
+
+func (h *TreatmentPlanItemHandler) GetTreatmentPlanItems(c *gin.Context) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return
+	}
+	items, err := h.service.List(c, c.Param("patient_id"), treatmentPlanID)
+	if err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(200, items)
+}
+
+func (h *TreatmentPlanItemHandler) GetTreatmentPlanItem(c *gin.Context) {
+	treatmentPlanID, id, ok := treatmentPlanItemIDs(c)
+	if !ok {
+		return
+	}
+	item, err := h.service.Get(c, c.Param("patient_id"), treatmentPlanID, id)
+	if err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(200, item)
+}
+
+// UpdateTreatmentPlanItem changes an item, and records the patient accepting, declining or
+// completing it with its status
+func (h *TreatmentPlanItemHandler) UpdateTreatmentPlanItem(c *gin.Context) {
+	treatmentPlanID, id, ok := treatmentPlanItemIDs(c)
+	if !ok {
+		return
+	}
+	var update models.TreatmentPlanItem
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	item, err := h.service.Update(c, c.Param("patient_id"), treatmentPlanID, id, update)
+	if err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(200, item)
+}
+
+func (h *TreatmentPlanItemHandler) DeleteTreatmentPlanItem(c *gin.Context) {
+	treatmentPlanID, id, ok := treatmentPlanItemIDs(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c, c.Param("patient_id"), treatmentPlanID, id); err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Treatment plan item deleted"})
+}
+
+// GetUnscheduledTreatment lists the patients with accepted treatment and no future appointment,
+// optionally only treatment accepted at least ?min_days= ago
+func (h *TreatmentPlanItemHandler) GetUnscheduledTreatment(c *gin.Context) {
+	filter := models.UnscheduledTreatmentFilter{}
+	if value := c.Query("min_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid min_days"})
+			return
+		}
+		filter.MinDays = days
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	report, err := h.service.Unscheduled(c, filter)
+	if err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+// CreateUnscheduledTreatmentTasks gives the desk a task to call each patient named to book their
+// accepted treatment
+func (h *TreatmentPlanItemHandler) CreateUnscheduledTreatmentTasks(c *gin.Context) {
+	var request models.UnscheduledTaskRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := h.service.CreateCallTasks(c, request)
+	if err != nil {
+		treatmentPlanItemError(c, err)
+		return
+	}
+	c.JSON(201, result)
+}
+
+func treatmentPlanItemIDs(c *gin.Context) (uint, uint, bool) {
+	treatmentPlanID, ok := caseImagePlanID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid treatment plan item ID"})
+		return 0, 0, false
+	}
+	return treatmentPlanID, uint(id), true
+}
+
+func treatmentPlanItemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTreatmentPlanItemNotFound), errors.Is(err, services.ErrTreatmentPlanNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTreatmentPlanItem):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
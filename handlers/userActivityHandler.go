@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserActivityHandler struct {
+	service *services.UserActivityService
+}
+
+func NewUserActivityHandler(service *services.UserActivityService) *UserActivityHandler {
+	return &UserActivityHandler{service: service}
+}
+
+// GetUserActivity reports a user's sign-ins, audited actions and API usage from ?from= through ?to=
+// (YYYY-MM-DD), the last 90 days by default
+func (h *UserActivityHandler) GetUserActivity(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	report, err := h.service.Report(c, userID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		userActivityError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func userActivityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidActivityPeriod):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
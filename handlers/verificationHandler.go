@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerificationHandler struct {
+	service *services.VerificationService
+}
+
+func NewVerificationHandler(service *services.VerificationService) *VerificationHandler {
+	return &VerificationHandler{service: service}
+}
+
+// GetPatientVerifications returns the latest outcome of each check of a patient
+func (h *VerificationHandler) GetPatientVerifications(c *gin.Context) {
+	verifications, err := h.service.ListByPatient(c, c.Param("patient_id"))
+	if err != nil {
+		verificationError(c, err)
+		return
+	}
+	c.JSON(200, verifications)
+}
+
+// RecheckPatient runs the checks of a patient again, for example once the registry is reachable again
+func (h *VerificationHandler) RecheckPatient(c *gin.Context) {
+	verifications, err := h.service.Recheck(c, c.Param("patient_id"))
+	if err != nil {
+		verificationError(c, err)
+		return
+	}
+	c.JSON(200, verifications)
+}
+
+// GetOpenVerifications lists the failed checks awaiting staff follow-up
+func (h *VerificationHandler) GetOpenVerifications(c *gin.Context) {
+	verifications, err := h.service.ListOpen(c)
+	if err != nil {
+		verificationError(c, err)
+		return
+	}
+	c.JSON(200, verifications)
+}
+
+func (h *VerificationHandler) ResolveVerification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid verification ID"})
+		return
+	}
+	userID, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	note := reviewNote(c)
+	if note == nil {
+		return
+	}
+	verification, err := h.service.Resolve(c, uint(id), userID, *note)
+	if err != nil {
+		verificationError(c, err)
+		return
+	}
+	c.JSON(200, verification)
+}
+
+func verificationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrVerificationNotFound), errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVerificationResolved):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VisitHandler struct {
+	service *services.VisitService
+}
+
+func NewVisitHandler(service *services.VisitService) *VisitHandler {
+	return &VisitHandler{service: service}
+}
+
+func (h *VisitHandler) CreateWalkIn(c *gin.Context) {
+	var request services.WalkInRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	appointment, err := h.service.CreateWalkIn(c, request)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(201, appointment)
+}
+
+// GetVisit returns the appointment with its vitals, completed checklists and what is outstanding
+func (h *VisitHandler) GetVisit(c *gin.Context) {
+	appointmentID, ok := visitParam(c, "appointment_id")
+	if !ok {
+		return
+	}
+	visit, err := h.service.GetVisit(c, c.Param("patient_id"), appointmentID)
+	if err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, visit)
+}
+
+func (h *VisitHandler) SaveVitals(c *gin.Context) {
+	appointmentID, ok := visitParam(c, "appointment_id")
+	if !ok {
+		return
+	}
+	var vitals models.Vitals
+	if err := c.ShouldBindJSON(&vitals); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	vitals.PatientID = c.Param("patient_id")
+	vitals.AppointmentID = appointmentID
+	if err := h.service.SaveVitals(c, &vitals); err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, vitals)
+}
+
+// CompleteChecklist records a checklist as completed with the items ticked in the body
+func (h *VisitHandler) CompleteChecklist(c *gin.Context) {
+	appointmentID, ok := visitParam(c, "appointment_id")
+	if !ok {
+		return
+	}
+	checklistID, ok := visitParam(c, "checklist_id")
+	if !ok {
+		return
+	}
+	var request struct {
+		Checked []string `json:"checked"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	completion, err := h.service.CompleteChecklist(c, c.Param("patient_id"), appointmentID, checklistID, request.Checked)
+	if err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, completion)
+}
+
+func (h *VisitHandler) GetChecklists(c *gin.Context) {
+	checklists, err := h.service.GetChecklists(c)
+	if err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, checklists)
+}
+
+func (h *VisitHandler) CreateChecklist(c *gin.Context) {
+	var checklist models.Checklist
+	if err := c.ShouldBindJSON(&checklist); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.CreateChecklist(c, &checklist); err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(201, checklist)
+}
+
+func (h *VisitHandler) UpdateChecklist(c *gin.Context) {
+	id, ok := visitParam(c, "id")
+	if !ok {
+		return
+	}
+	var checklist models.Checklist
+	if err := c.ShouldBindJSON(&checklist); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	checklist.ID = id
+	if err := h.service.UpdateChecklist(c, &checklist); err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, checklist)
+}
+
+func (h *VisitHandler) DeleteChecklist(c *gin.Context) {
+	id, ok := visitParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteChecklist(c, id); err != nil {
+		visitError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Checklist deleted successfully"})
+}
+
+func visitParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func visitError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrAppointmentNotFound), errors.Is(err, services.ErrChecklistNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidVitals), errors.Is(err, services.ErrInvalidChecklist), errors.Is(err, services.ErrChecklistIncomplete):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VisitSummaryHandler struct {
+	service *services.VisitSummaryService
+}
+
+func NewVisitSummaryHandler(service *services.VisitSummaryService) *VisitSummaryHandler {
+	return &VisitSummaryHandler{service: service}
+}
+
+// GetVisitSummaryPDF returns the summary letter of the patient's visit as a PDF to print or hand over
+func (h *VisitSummaryHandler) GetVisitSummaryPDF(c *gin.Context) {
+	appointmentID, ok := visitParam(c, "appointment_id")
+	if !ok {
+		return
+	}
+	data, err := h.service.PDF(c, c.Param("patient_id"), appointmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrAppointmentNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrVisitNotStarted):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="visit-summary-%d.pdf"`, appointmentID))
+	c.Data(200, "application/pdf", data)
+}
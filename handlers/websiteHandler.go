@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebsiteHandler struct {
+	service *services.WebsiteService
+}
+
+func NewWebsiteHandler(service *services.WebsiteService) *WebsiteHandler {
+	return &WebsiteHandler{service: service}
+}
+
+// GetWebsiteDoctors lists the doctors shown on the clinic website
+func (h *WebsiteHandler) GetWebsiteDoctors(c *gin.Context) {
+	doctors, err := h.service.Doctors(c)
+	if err != nil {
+		log.Printf("Failed to list website doctors: %v", err)
+		c.JSON(500, gin.H{"error": "Doctors could not be listed, please try again later"})
+		return
+	}
+	c.JSON(200, doctors)
+}
+
+// GetWebsiteServices lists the services shown on the clinic website
+func (h *WebsiteHandler) GetWebsiteServices(c *gin.Context) {
+	procedures, err := h.service.Services(c)
+	if err != nil {
+		log.Printf("Failed to list website services: %v", err)
+		c.JSON(500, gin.H{"error": "Services could not be listed, please try again later"})
+		return
+	}
+	c.JSON(200, procedures)
+}
+
+// RequestAppointment takes the appointment request form of the clinic website
+func (h *WebsiteHandler) RequestAppointment(c *gin.Context) {
+	var request models.AppointmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.service.RequestAppointment(c, &request, c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrInvalidAppointmentRequest) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		// The website never sees internal errors
+		log.Printf("Failed to submit appointment request: %v", err)
+		c.JSON(500, gin.H{"error": "Your request could not be sent, please try again later"})
+		return
+	}
+	c.JSON(201, gin.H{"id": request.ID, "message": "Thank you, we will contact you to confirm your appointment"})
+}
+
+// GetAppointmentRequests lists appointment requests by ?status=, the pending queue by default
+func (h *WebsiteHandler) GetAppointmentRequests(c *gin.Context) {
+	requests, err := h.service.ListRequests(c, c.Query("status"))
+	if err != nil {
+		appointmentRequestError(c, err)
+		return
+	}
+	c.JSON(200, requests)
+}
+
+func (h *WebsiteHandler) GetAppointmentRequest(c *gin.Context) {
+	id, ok := appointmentRequestID(c)
+	if !ok {
+		return
+	}
+	request, err := h.service.GetRequest(c, id)
+	if err != nil {
+		appointmentRequestError(c, err)
+		return
+	}
+	c.JSON(200, request)
+}
+
+// BookAppointmentRequest closes a request with the appointment booked for it, sent as
+// {"patient_id": "...", "appointment_id": 1, "note": "..."}
+func (h *WebsiteHandler) BookAppointmentRequest(c *gin.Context) {
+	id, ok := appointmentRequestID(c)
+	if !ok {
+		return
+	}
+	reviewer, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		PatientID     string `json:"patient_id" binding:"required"`
+		AppointmentID uint   `json:"appointment_id" binding:"required"`
+		Note          string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	request, err := h.service.Book(c, id, body.PatientID, body.AppointmentID, reviewer, body.Note)
+	if err != nil {
+		appointmentRequestError(c, err)
+		return
+	}
+	c.JSON(200, request)
+}
+
+func (h *WebsiteHandler) DeclineAppointmentRequest(c *gin.Context) {
+	id, ok := appointmentRequestID(c)
+	if !ok {
+		return
+	}
+	reviewer, ok := contextUserID(c)
+	if !ok {
+		return
+	}
+	note := reviewNote(c)
+	if note == nil {
+		return
+	}
+	request, err := h.service.Decline(c, id, reviewer, *note)
+	if err != nil {
+		appointmentRequestError(c, err)
+		return
+	}
+	c.JSON(200, request)
+}
+
+func appointmentRequestID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid appointment request ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func appointmentRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAppointmentRequestNotFound), errors.Is(err, repositories.ErrAppointmentNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAppointmentRequestReviewed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,165 @@
+// Package hl7 parses HL7 version 2 messages, builds their acknowledgements and frames them for MLLP.
+package hl7
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acknowledgement codes
+const (
+	AckAccept = "AA"
+	AckError  = "AE"
+	AckReject = "AR"
+)
+
+var ErrInvalidMessage = errors.New("invalid HL7 message")
+
+// Message is a parsed HL7v2 message: its segments, each split into fields
+type Message struct {
+	segments     [][]string
+	component    string
+	repetition   string
+	escape       string
+	subcomponent string
+}
+
+// Parse splits a message into segments and fields using the separators declared in MSH
+func Parse(raw string) (*Message, error) {
+	raw = strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(raw, "\r\n", "\r"), "\n", "\r"))
+	if len(raw) < 8 || !strings.HasPrefix(raw, "MSH") {
+		return nil, fmt.Errorf("%w: it must start with an MSH segment", ErrInvalidMessage)
+	}
+	field := string(raw[3])
+	encoding := raw[4:8]
+	msg := &Message{
+		component:    string(encoding[0]),
+		repetition:   string(encoding[1]),
+		escape:       string(encoding[2]),
+		subcomponent: string(encoding[3]),
+	}
+	for _, segment := range strings.Split(raw, "\r") {
+		if segment = strings.TrimSpace(segment); segment == "" {
+			continue
+		}
+		fields := strings.Split(segment, field)
+		if fields[0] == "MSH" {
+			// MSH-1 is the field separator itself, so the fields are shifted by one
+			fields = append([]string{"MSH", field}, fields[1:]...)
+		}
+		msg.segments = append(msg.segments, fields)
+	}
+	return msg, nil
+}
+
+// Get returns the first repetition of a field or component, addressed like "PID-5" or "PID-5.2",
+// in the first segment of that type
+func (m *Message) Get(ref string) string {
+	fieldRef, componentRef, hasComponent := strings.Cut(ref, ".")
+	value, ok := m.field(fieldRef)
+	if !ok {
+		return ""
+	}
+	if fieldRef == "MSH-1" || fieldRef == "MSH-2" {
+		return value
+	}
+	if !hasComponent {
+		return m.unescape(value)
+	}
+	componentIndex, err := strconv.Atoi(componentRef)
+	if err != nil || componentIndex < 1 {
+		return ""
+	}
+	components := m.Components(fieldRef)
+	if componentIndex > len(components) {
+		return ""
+	}
+	return components[componentIndex-1]
+}
+
+// Components returns every component of the first repetition of a field addressed like "PID-11"
+func (m *Message) Components(ref string) []string {
+	value, ok := m.field(ref)
+	if !ok || value == "" {
+		return nil
+	}
+	components := strings.Split(value, m.component)
+	for i, component := range components {
+		components[i] = m.unescape(strings.Split(component, m.subcomponent)[0])
+	}
+	return components
+}
+
+// field returns the raw first repetition of a field addressed like "PID-5"
+func (m *Message) field(ref string) (string, bool) {
+	name, rest, ok := strings.Cut(ref, "-")
+	if !ok {
+		return "", false
+	}
+	fieldIndex, err := strconv.Atoi(rest)
+	if err != nil || fieldIndex < 1 {
+		return "", false
+	}
+	for _, segment := range m.segments {
+		if segment[0] != name {
+			continue
+		}
+		if fieldIndex >= len(segment) {
+			return "", false
+		}
+		// MSH-1 and MSH-2 hold the separators themselves
+		if name == "MSH" && fieldIndex <= 2 {
+			return segment[fieldIndex], true
+		}
+		return strings.Split(segment[fieldIndex], m.repetition)[0], true
+	}
+	return "", false
+}
+
+// ControlID is the message control ID (MSH-10) the acknowledgement refers to
+func (m *Message) ControlID() string {
+	return m.Get("MSH-10")
+}
+
+// Type returns the message type and trigger event, e.g. "ADT" and "A08"
+func (m *Message) Type() (string, string) {
+	return m.Get("MSH-9.1"), m.Get("MSH-9.2")
+}
+
+func (m *Message) unescape(value string) string {
+	if m.escape == "" || !strings.Contains(value, m.escape) {
+		return value
+	}
+	e := m.escape
+	return strings.NewReplacer(
+		e+"F"+e, "|",
+		e+"S"+e, m.component,
+		e+"R"+e, m.repetition,
+		e+"T"+e, m.subcomponent,
+		e+"E"+e, e,
+	).Replace(value)
+}
+
+// Ack builds the acknowledgement of msg from the receiving application and facility. msg may be
+// nil when it could not be parsed.
+func Ack(msg *Message, code, text, application, facility string) string {
+	var sendingApp, sendingFacility, controlID, version, event string
+	if msg != nil {
+		sendingApp, sendingFacility = msg.Get("MSH-3"), msg.Get("MSH-4")
+		controlID, version = msg.ControlID(), msg.Get("MSH-12")
+		_, event = msg.Type()
+	}
+	if version == "" {
+		version = "2.5"
+	}
+	text = strings.NewReplacer("|", " ", "^", " ", "~", " ", "\\", " ", "&", " ", "\r", " ", "\n", " ").Replace(text)
+	now := time.Now()
+	return strings.Join([]string{
+		strings.Join([]string{"MSH", `^~\&`, application, facility, sendingApp, sendingFacility,
+			now.Format("20060102150405"), "", "ACK^" + event, fmt.Sprintf("ACK%d", now.UnixNano()), "P", version}, "|"),
+		strings.Join([]string{"MSA", code, controlID, text}, "|"),
+	}, "\r") + "\r"
+}
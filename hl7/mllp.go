@@ -0,0 +1,62 @@
+package hl7
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// MLLP frames each message between a start block and an end block followed by a carriage return
+const (
+	mllpStart = 0x0B
+	mllpEnd   = 0x1C
+	mllpCR    = 0x0D
+)
+
+// maxMessageSize bounds a framed message so a misbehaving peer cannot exhaust memory
+const maxMessageSize = 1 << 20
+
+var ErrFrame = errors.New("invalid MLLP frame")
+
+// ReadFrame reads the next framed message, skipping anything before its start block
+func ReadFrame(r *bufio.Reader) (string, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == mllpStart {
+			break
+		}
+	}
+	var message []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if b == mllpEnd {
+			if next, err := r.ReadByte(); err == nil && next != mllpCR {
+				_ = r.UnreadByte()
+			}
+			return string(message), nil
+		}
+		if len(message) >= maxMessageSize {
+			return "", ErrFrame
+		}
+		message = append(message, b)
+	}
+}
+
+// WriteFrame writes message framed for MLLP
+func WriteFrame(w io.Writer, message string) error {
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, mllpStart)
+	frame = append(frame, message...)
+	frame = append(frame, mllpEnd, mllpCR)
+	_, err := w.Write(frame)
+	return err
+}
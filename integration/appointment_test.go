@@ -0,0 +1,70 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func createAppointment(t *testing.T, appointment models.Appointment) models.Appointment {
+	t.Helper()
+	path := "/patients/" + appointment.PatientID + "/appointments"
+	if status := env.Request(t, http.MethodPost, path, "", appointment, &appointment); status != http.StatusCreated {
+		t.Fatalf("POST %s: got %d, want 201", path, status)
+	}
+	return appointment
+}
+
+// Where an appointment came from and when it was confirmed are read back both on its own and in the
+// patient's history, which select their columns one by one
+func TestAppointmentAttributionAndConfirmationAreReadBack(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+	appointment := createAppointment(t, models.Appointment{
+		PatientID: patient.ID,
+		DoctorID:  doctor.ID,
+		DateTime:  time.Now().AddDate(0, 0, 7).Format("2006-01-02") + "T10:00",
+		Status:    models.AppointmentStatusScheduled,
+		Attribution: models.Attribution{
+			Source:       models.SourceCampaign,
+			CampaignCode: "SMILE-2026",
+			ReferredBy:   "Grace Njeri",
+		},
+	})
+	want := appointment.Attribution
+	confirmedAt := time.Now().Truncate(time.Second)
+	if confirmed, err := repositories.NewAppointmentRepository(env.Cache).Confirm(context.Background(), patient.ID, appointment.ID, confirmedAt); err != nil || !confirmed {
+		t.Fatalf("Confirm = %v, %v; want the appointment confirmed", confirmed, err)
+	}
+
+	path := fmt.Sprintf("/patients/%s/appointments/%d", patient.ID, appointment.ID)
+	var got models.Appointment
+	if !fetch(t, path, &got) {
+		t.Fatalf("GET %s: appointment not found after creation", path)
+	}
+	if got.Attribution != want {
+		t.Fatalf("GET %s attribution = %+v, want %+v", path, got.Attribution, want)
+	}
+	if got.ConfirmedAt == nil || !got.ConfirmedAt.Equal(confirmedAt) {
+		t.Fatalf("GET %s confirmed_at = %v, want %v", path, got.ConfirmedAt, confirmedAt)
+	}
+
+	historyPath := "/patients/" + patient.ID + "/history/appointments?limit=10"
+	var page models.ListPage[models.Appointment]
+	fetch(t, historyPath, &page)
+	if len(page.Items) != 1 {
+		t.Fatalf("GET %s: got %d appointments, want 1", historyPath, len(page.Items))
+	}
+	if page.Items[0].Attribution != want {
+		t.Fatalf("GET %s attribution = %+v, want %+v", historyPath, page.Items[0].Attribution, want)
+	}
+	if page.Items[0].ConfirmedAt == nil || !page.Items[0].ConfirmedAt.Equal(confirmedAt) {
+		t.Fatalf("GET %s confirmed_at = %v, want %v", historyPath, page.Items[0].ConfirmedAt, confirmedAt)
+	}
+}
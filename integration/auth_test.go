@@ -0,0 +1,61 @@
+//go:build integration
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBearerTokenIsRequired(t *testing.T) {
+	if status := env.RequestWithoutBearer(t, http.MethodGet, "/patients", nil); status != http.StatusUnauthorized {
+		t.Fatalf("GET /patients without a bearer token: got %d, want 401", status)
+	}
+}
+
+func TestSignInAndProfile(t *testing.T) {
+	token := env.SignIn(t, "Receptionist")
+
+	var profile struct {
+		User struct {
+			ID   int64 `json:"id"`
+			Role struct {
+				Name string `json:"name"`
+			} `json:"role"`
+		} `json:"user"`
+	}
+	if status := env.Request(t, http.MethodGet, "/auth/user/profile", token, nil, &profile); status != http.StatusOK {
+		t.Fatalf("GET /auth/user/profile: got %d, want 200", status)
+	}
+	if profile.User.ID == 0 || profile.User.Role.Name != "Receptionist" {
+		t.Fatalf("profile = %+v, want a Receptionist", profile.User)
+	}
+
+	if status := env.Request(t, http.MethodGet, "/auth/user/profile", "not-a-token", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("GET /auth/user/profile with an invalid token: got %d, want 401", status)
+	}
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	env.SignIn(t, "Doctor")
+
+	credentials := map[string]string{"email": "nobody@example.com", "password": "Wr0ngPassword!"}
+	if status := env.Request(t, http.MethodPost, "/auth/login", "", credentials, nil); status != http.StatusUnauthorized {
+		t.Fatalf("POST /auth/login with unknown credentials: got %d, want 401", status)
+	}
+}
+
+func TestRoleRestrictedRoutes(t *testing.T) {
+	receptionist := env.SignIn(t, "Receptionist")
+	admin := env.SignIn(t, "Admin")
+
+	if status := env.Request(t, http.MethodGet, "/auth/admin/audit/auth-failures", "", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("audit trail without an access token: got %d, want 401", status)
+	}
+	if status := env.Request(t, http.MethodGet, "/auth/admin/audit/auth-failures", receptionist, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("audit trail as a Receptionist: got %d, want 403", status)
+	}
+	if status := env.Request(t, http.MethodGet, "/auth/admin/audit/auth-failures", admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("audit trail as an Admin: got %d, want 200", status)
+	}
+}
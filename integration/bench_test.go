@@ -0,0 +1,170 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/database"
+	"RoyDental/repositories"
+	"RoyDental/testutil"
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Benchmarks of the hot repository paths against seeded data. Run them alone, with the volume to
+// measure at:
+//
+//	go test -tags integration -run '^$' -bench . -benchmem ./integration/ -seed.patients 5000
+
+var (
+	seedPatients     = flag.Int("seed.patients", 1000, "patients seeded for the benchmarks")
+	seedAppointments = flag.Int("seed.appointments", 4, "appointments seeded per patient")
+	seedBillings     = flag.Int("seed.billings", 3, "bills seeded per patient")
+)
+
+var (
+	seedOnce   sync.Once
+	seeded     *testutil.SeedResult
+	seedFailed error
+)
+
+// seedData seeds the benchmark data once per run, and drops the cached lists it made stale.
+func seedData(b *testing.B) *testutil.SeedResult {
+	b.Helper()
+	seedOnce.Do(func() {
+		cfg := testutil.DefaultSeedConfig()
+		cfg.Patients = *seedPatients
+		cfg.AppointmentsPerPatient = *seedAppointments
+		cfg.BillingsPerPatient = *seedBillings
+
+		ctx := context.Background()
+		if seeded, seedFailed = testutil.Seed(ctx, env.DB, cfg); seedFailed == nil {
+			seedFailed = env.Cache.InvalidateNamespace(ctx)
+		}
+	})
+	if seedFailed != nil {
+		b.Fatalf("failed to seed: %v", seedFailed)
+	}
+	return seeded
+}
+
+func BenchmarkPatientGetAllUncached(b *testing.B) {
+	seedData(b)
+	repo := repositories.NewPatientRepository(env.Cache)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := env.Cache.Delete(ctx, env.Cache.Key(ctx, "patients")); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if _, err := repo.GetAll(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPatientGetAllCached(b *testing.B) {
+	seedData(b)
+	repo := repositories.NewPatientRepository(env.Cache)
+	ctx := context.Background()
+	if _, err := repo.GetAll(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetAll(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPatientGetByID(b *testing.B) {
+	ids := seedData(b).PatientIDs
+	repo := repositories.NewPatientRepository(env.Cache)
+	ctx := context.Background()
+
+	b.Run("miss", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			id := ids[i%len(ids)]
+			b.StopTimer()
+			if err := env.Cache.Delete(ctx, env.Cache.Key(ctx, "patient", id)); err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			if _, err := repo.GetByID(ctx, id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("hit", func(b *testing.B) {
+		id := ids[0]
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetByID(ctx, id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkAppointmentGetAllUncached(b *testing.B) {
+	seedData(b)
+	repo := repositories.NewAppointmentRepository(env.Cache)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := env.Cache.Delete(ctx, env.Cache.Key(ctx, "appointments")); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if _, err := repo.GetAll(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWithLock measures the lock around writes, with every writer after the same record and
+// with each after a record of its own.
+func BenchmarkWithLock(b *testing.B) {
+	ctx := context.Background()
+	write := func(tx *gorm.DB) error {
+		return tx.Exec("SELECT 1").Error
+	}
+
+	b.Run("contended", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := database.WithLock(ctx, "bench_lock:shared", write); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("uncontended", func(b *testing.B) {
+		var worker atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			key := fmt.Sprintf("bench_lock:%d", worker.Add(1))
+			for pb.Next() {
+				if err := database.WithLock(ctx, key, write); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
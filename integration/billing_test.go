@@ -0,0 +1,147 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBillingMath(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+
+	billing := models.Billing{
+		PatientID:           patient.ID,
+		DoctorID:            doctor.ID,
+		Procedure:           "Root canal",
+		BillingAmount:       12000,
+		PaidCashAmount:      3000,
+		PaidInsuranceAmount: 4500.50,
+	}
+	if status := env.Request(t, http.MethodPost, "/billings", "", billing, &billing); status != http.StatusCreated {
+		t.Fatalf("POST /billings: got %d, want 201", status)
+	}
+	if !strings.HasPrefix(billing.BillingID, "PB-") {
+		t.Fatalf("billing ID = %q, want a PB- sequence number", billing.BillingID)
+	}
+	checkAmounts(t, billing, 4499.50, 7500.50)
+
+	// A payment towards the bill is reflected in the balance, including for a bill that was read
+	// and cached before the payment
+	path := "/billings/" + billing.BillingID
+	var got models.Billing
+	if !fetch(t, path, &got) {
+		t.Fatalf("GET %s: bill not found", path)
+	}
+	checkAmounts(t, got, 4499.50, 7500.50)
+
+	billing.PaidCashAmount = 7499.50
+	if status := env.Request(t, http.MethodPut, path, "", billing, &billing); status != http.StatusOK {
+		t.Fatalf("PUT %s: got %d, want 200", path, status)
+	}
+	checkAmounts(t, billing, 0, 12000)
+	fetch(t, path, &got)
+	checkAmounts(t, got, 0, 12000)
+}
+
+func TestBillingRequiresKnownDoctor(t *testing.T) {
+	patient := createPatient(t)
+	billing := models.Billing{PatientID: patient.ID, DoctorID: "no-such-doctor", Procedure: "Extraction", BillingAmount: 1500}
+	if status := env.Request(t, http.MethodPost, "/billings", "", billing, nil); status < 400 {
+		t.Fatalf("POST /billings for an unknown doctor: got %d, want an error", status)
+	}
+}
+
+func TestPaymentsAreAudited(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+	admin := env.SignIn(t, "Admin")
+
+	billing := models.Billing{PatientID: patient.ID, DoctorID: doctor.ID, Procedure: "Filling", BillingAmount: 4000, PaidCashAmount: 1000}
+	if status := env.Request(t, http.MethodPost, "/billings", admin, billing, &billing); status != http.StatusCreated {
+		t.Fatalf("POST /billings: got %d, want 201", status)
+	}
+
+	// Audit entries are written in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var page struct {
+			Entries []models.AuditLog `json:"entries"`
+		}
+		if status := env.Request(t, http.MethodGet, "/auth/admin/audit/activity?event="+models.AuditEventPaymentRecorded, admin, nil, &page); status != http.StatusOK {
+			t.Fatalf("GET activity audit: got %d, want 200", status)
+		}
+		for _, entry := range page.Entries {
+			if strings.Contains(entry.Detail, billing.BillingID) && strings.Contains(entry.Detail, "amount=1000.00") {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no payment_recorded audit entry for %s among %+v", billing.BillingID, page.Entries)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// A bill whose paid amounts drift from its payments is found even when its balance adds up from
+// them, and recomputing takes the amounts back to what the payments come to
+func TestBalanceMismatchFollowsPayments(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+
+	billing := models.Billing{PatientID: patient.ID, DoctorID: doctor.ID, Procedure: "Crown", BillingAmount: 12000, PaidCashAmount: 3000, PaidInsuranceAmount: 1000}
+	if status := env.Request(t, http.MethodPost, "/billings", "", billing, &billing); status != http.StatusCreated {
+		t.Fatalf("POST /billings: got %d, want 201", status)
+	}
+	path := "/billings/" + billing.BillingID
+	billing.PaidCashAmount = 4000
+	if status := env.Request(t, http.MethodPut, path, "", billing, &billing); status != http.StatusOK {
+		t.Fatalf("PUT %s: got %d, want 200", path, status)
+	}
+
+	err := env.DB.Exec("UPDATE billing SET paid_cash_amount = 5000, total_received = 6000, balance = 6000 WHERE billing_id = ?", billing.BillingID).Error
+	if err != nil {
+		t.Fatalf("failed to change the paid amounts: %v", err)
+	}
+
+	repository := repositories.NewIntegrityRepository(env.Cache)
+	issues, err := repository.BalanceMismatches(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("BalanceMismatches: %v", err)
+	}
+	found := false
+	for _, issue := range issues {
+		found = found || issue.RecordID == billing.BillingID
+	}
+	if !found {
+		t.Fatalf("BalanceMismatches = %+v, want bill %s whose cash received disagrees with its payments", issues, billing.BillingID)
+	}
+
+	if err := repository.RecomputeBalance(context.Background(), billing.BillingID); err != nil {
+		t.Fatalf("RecomputeBalance: %v", err)
+	}
+	var got models.Billing
+	if !fetch(t, path, &got) {
+		t.Fatalf("GET %s: bill not found", path)
+	}
+	if math.Abs(got.PaidCashAmount-4000) > 0.001 || math.Abs(got.PaidInsuranceAmount-1000) > 0.001 {
+		t.Fatalf("bill %s: paid %.2f cash and %.2f insurance after recomputing, want 4000.00 and 1000.00",
+			got.BillingID, got.PaidCashAmount, got.PaidInsuranceAmount)
+	}
+	checkAmounts(t, got, 7000, 5000)
+}
+
+func checkAmounts(t *testing.T, billing models.Billing, balance, received float64) {
+	t.Helper()
+	if math.Abs(billing.Balance-balance) > 0.001 || math.Abs(billing.TotalReceived-received) > 0.001 {
+		t.Fatalf("bill %s: balance %.2f and total received %.2f, want %.2f and %.2f",
+			billing.BillingID, billing.Balance, billing.TotalReceived, balance, received)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/breaker"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/testutil"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// cached reports whether the patient is in Redis.
+func cached(t *testing.T, patientID string) bool {
+	t.Helper()
+	ctx := context.Background()
+	value, err := env.Cache.Get(ctx, env.Cache.Key(ctx, "patient", patientID))
+	if err != nil {
+		t.Fatalf("failed to read the patient cache: %v", err)
+	}
+	return value != ""
+}
+
+func TestPatientCacheInvalidation(t *testing.T) {
+	patient := createPatient(t)
+	path := "/patients/" + patient.ID
+
+	var got models.Patient
+	fetch(t, path, &got)
+	if !cached(t, patient.ID) {
+		t.Fatalf("patient %s not cached after a read", patient.ID)
+	}
+
+	// A new primary contact shows on the patient straight away
+	contact := models.EmergencyContact{PatientID: patient.ID, Name: "Wanjiru Otieno", Phone: testutil.NewID("+2547"), Relationship: "parent", Primary: true}
+	if status := env.Request(t, http.MethodPost, path+"/emergency_contacts", "", contact, &contact); status != http.StatusCreated {
+		t.Fatalf("POST emergency contact: got %d, want 201", status)
+	}
+	if cached(t, patient.ID) {
+		t.Fatalf("patient %s still cached after a contact was added", patient.ID)
+	}
+	fetch(t, path, &got)
+	if got.PrimaryContact == nil || got.PrimaryContact.ID != contact.ID {
+		t.Fatalf("primary contact = %+v, want contact %d", got.PrimaryContact, contact.ID)
+	}
+
+	// So does an update of the patient
+	patient.LastName = "Kamau"
+	if status := env.Request(t, http.MethodPut, path, "", patient, nil); status != http.StatusOK {
+		t.Fatalf("PUT %s: got %d, want 200", path, status)
+	}
+	if cached(t, patient.ID) {
+		t.Fatalf("patient %s still cached after an update", patient.ID)
+	}
+	fetch(t, path, &got)
+	if got.LastName != "Kamau" {
+		t.Fatalf("last name = %q after update, want Kamau", got.LastName)
+	}
+
+	// And the removal of the patient with its records
+	if status := env.Request(t, http.MethodDelete, path+"/related", "", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE related: got %d, want 204", status)
+	}
+	if cached(t, patient.ID) {
+		t.Fatalf("patient %s still cached after deletion", patient.ID)
+	}
+	if fetch(t, path, nil) {
+		t.Fatalf("GET %s: still found after deletion", path)
+	}
+}
+
+// A request cancelled while it was the probe of a half-open breaker must not keep the breaker from
+// probing Redis again, or the cache would stay bypassed until the process restarts
+func TestCancelledProbeReleasesRedisBreaker(t *testing.T) {
+	config, err := database.LoadRedisConfig()
+	if err != nil {
+		t.Fatalf("LoadRedisConfig: %v", err)
+	}
+	t.Cleanup(func() {
+		database.RedisBreaker.Configure(config.BreakerThreshold, config.BreakerCooldown)
+		database.RedisBreaker.Success()
+	})
+	database.RedisBreaker.Configure(1, 10*time.Millisecond)
+	database.RedisBreaker.Failure()
+	if state := database.RedisBreaker.State(); state != breaker.Open {
+		t.Fatalf("breaker is %s after a failure, want open", state)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// The first call after the cooldown is the probe, and its request is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := env.Cache.Get(ctx, env.Cache.Key(ctx, "patient", "cancelled-probe")); err == nil {
+		t.Fatal("Get with a cancelled context succeeded, want the cancellation")
+	}
+	if state := database.RedisBreaker.State(); state != breaker.HalfOpen {
+		t.Fatalf("breaker is %s after the cancelled probe, want half-open", state)
+	}
+
+	ctx = context.Background()
+	if _, err := env.Cache.Get(ctx, env.Cache.Key(ctx, "patient", "next-probe")); err != nil {
+		t.Fatalf("Get after the cancelled probe: %v", err)
+	}
+	if state := database.RedisBreaker.State(); state != breaker.Closed {
+		t.Fatalf("breaker is %s after the next probe, want closed", state)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build integration
+
+// Package integration exercises the HTTP API against Postgres and Redis started in Docker.
+// Run it with: go test -tags integration ./integration/
+package integration
+
+import (
+	"RoyDental/testutil"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+var env *testutil.Env
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	var err error
+	env, err = testutil.Start(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start the integration environment: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	env.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,64 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// A bill written for a month before its partition exists lands in the default partition; creating
+// the month's partition must move it there instead of failing every maintenance run
+func TestPartitionMaintenanceMovesRowsOutOfDefault(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+	billing := models.Billing{PatientID: patient.ID, DoctorID: doctor.ID, Procedure: "Scaling", BillingAmount: 2500}
+	if status := env.Request(t, http.MethodPost, "/billings", "", billing, &billing); status != http.StatusCreated {
+		t.Fatalf("POST /billings: got %d, want 201", status)
+	}
+
+	// Maintenance missed the furthest month kept ready, and a bill was dated in it
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, database.PartitionMonthsAhead, 0)
+	partition := fmt.Sprintf("billing_p%s", month.Format("2006_01"))
+	if err := env.DB.Exec(fmt.Sprintf(`DROP TABLE %q`, partition)).Error; err != nil {
+		t.Fatalf("failed to drop %s: %v", partition, err)
+	}
+	if err := env.DB.Exec("UPDATE billing SET created_at = ? WHERE billing_id = ?", month.AddDate(0, 0, 14), billing.BillingID).Error; err != nil {
+		t.Fatalf("failed to backdate bill: %v", err)
+	}
+	if got := billingPartition(t, billing.BillingID); got != "billing_default" {
+		t.Fatalf("bill is in %s before maintenance, want billing_default", got)
+	}
+
+	if err := database.EnsurePartitions(context.Background()); err != nil {
+		t.Fatalf("EnsurePartitions: %v", err)
+	}
+	if got := billingPartition(t, billing.BillingID); got != partition {
+		t.Fatalf("bill is in %s after maintenance, want %s", got, partition)
+	}
+	// The default partition is attached again and keeps catching rows without a partition
+	var attached bool
+	if err := env.DB.Raw(`SELECT EXISTS (SELECT 1 FROM pg_inherits WHERE inhrelid = 'billing_default'::regclass AND inhparent = 'billing'::regclass)`).
+		Scan(&attached).Error; err != nil {
+		t.Fatalf("failed to inspect billing_default: %v", err)
+	}
+	if !attached {
+		t.Fatal("billing_default is not attached to billing after maintenance")
+	}
+}
+
+// billingPartition returns the partition a bill is stored in
+func billingPartition(t *testing.T, billingID string) string {
+	t.Helper()
+	var partition string
+	if err := env.DB.Raw("SELECT tableoid::regclass::text FROM billing WHERE billing_id = ?", billingID).Scan(&partition).Error; err != nil {
+		t.Fatalf("failed to find bill %s: %v", billingID, err)
+	}
+	return partition
+}
@@ -0,0 +1,195 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/models"
+	"RoyDental/testutil"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func newPatient(id string) models.Patient {
+	return models.Patient{
+		ID:          id,
+		FirstName:   "Amani",
+		LastName:    "Otieno",
+		Sex:         "Female",
+		DateOfBirth: "1990-04-12",
+		Cash:        true,
+		Phone:       "+254700000001",
+	}
+}
+
+func createPatient(t *testing.T) models.Patient {
+	t.Helper()
+	patient := newPatient(testutil.NewID("P"))
+	if status := env.Request(t, http.MethodPost, "/patients", "", patient, &patient); status != http.StatusCreated {
+		t.Fatalf("POST /patients: got %d, want 201", status)
+	}
+	return patient
+}
+
+func createDoctor(t *testing.T) models.Doctor {
+	t.Helper()
+	doctor := models.Doctor{ID: testutil.NewID("D"), FirstName: "Baraka", LastName: "Mwangi"}
+	if status := env.Request(t, http.MethodPost, "/doctors", "", doctor, &doctor); status != http.StatusCreated {
+		t.Fatalf("POST /doctors: got %d, want 201", status)
+	}
+	return doctor
+}
+
+// fetch GETs path into out and reports whether a record came back. Handlers answer a missing record
+// with either 404 or a null body.
+func fetch(t *testing.T, path string, out interface{}) bool {
+	t.Helper()
+	var body json.RawMessage
+	status := env.Request(t, http.MethodGet, path, "", nil, &body)
+	switch {
+	case status == http.StatusNotFound || (status == http.StatusOK && string(body) == "null"):
+		return false
+	case status != http.StatusOK:
+		t.Fatalf("GET %s: got %d, want 200", path, status)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("failed to decode GET %s: %v", path, err)
+		}
+	}
+	return true
+}
+
+func TestPatientLifecycle(t *testing.T) {
+	patient := createPatient(t)
+	path := "/patients/" + patient.ID
+
+	var got models.Patient
+	if !fetch(t, path, &got) {
+		t.Fatalf("GET %s: patient not found after creation", path)
+	}
+	if got.FirstName != patient.FirstName || got.Sex != patient.Sex || got.DateOfBirth != patient.DateOfBirth {
+		t.Fatalf("GET %s = %+v, want %+v", path, got, patient)
+	}
+
+	patient.Occupation = "Teacher"
+	patient.Phone = "+254700000002"
+	if status := env.Request(t, http.MethodPut, path, "", patient, nil); status != http.StatusOK {
+		t.Fatalf("PUT %s: got %d, want 200", path, status)
+	}
+	fetch(t, path, &got)
+	if got.Occupation != "Teacher" || got.Phone != "+254700000002" {
+		t.Fatalf("GET %s after update = %+v, want the new occupation and phone", path, got)
+	}
+
+	if status := env.Request(t, http.MethodDelete, path, "", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE %s: got %d, want 204", path, status)
+	}
+	if fetch(t, path, nil) {
+		t.Fatalf("GET %s: patient still found after deletion", path)
+	}
+}
+
+func TestPatientRejectsInvalidSex(t *testing.T) {
+	patient := newPatient(testutil.NewID("P"))
+	patient.Sex = "Unknown"
+	if status := env.Request(t, http.MethodPost, "/patients", "", patient, nil); status < 400 {
+		t.Fatalf("POST /patients with sex %q: got %d, want an error", patient.Sex, status)
+	}
+	if fetch(t, "/patients/"+patient.ID, nil) {
+		t.Fatalf("patient with an invalid sex was stored")
+	}
+}
+
+// Where a patient came from is read back both on its own and in the patient list, which select their
+// columns one by one
+func TestPatientAttributionIsReadBack(t *testing.T) {
+	patient := newPatient(testutil.NewID("P"))
+	patient.Attribution = models.Attribution{
+		Source:       models.SourceCampaign,
+		CampaignCode: "SMILE-2026",
+		ReferredBy:   "Grace Njeri",
+	}
+	want := patient.Attribution
+	if status := env.Request(t, http.MethodPost, "/patients", "", patient, &patient); status != http.StatusCreated {
+		t.Fatalf("POST /patients: got %d, want 201", status)
+	}
+
+	var got models.Patient
+	if !fetch(t, "/patients/"+patient.ID, &got) {
+		t.Fatalf("GET /patients/%s: patient not found after creation", patient.ID)
+	}
+	if got.Attribution != want {
+		t.Fatalf("GET /patients/%s attribution = %+v, want %+v", patient.ID, got.Attribution, want)
+	}
+
+	var patients []models.Patient
+	fetch(t, "/patients", &patients)
+	for _, listed := range patients {
+		if listed.ID != patient.ID {
+			continue
+		}
+		if listed.Attribution != want {
+			t.Fatalf("GET /patients attribution = %+v, want %+v", listed.Attribution, want)
+		}
+		return
+	}
+	t.Fatalf("GET /patients: patient %s not listed", patient.ID)
+}
+
+// Every patient may have a primary emergency contact of their own
+func TestPrimaryEmergencyContactPerPatient(t *testing.T) {
+	for _, patient := range []models.Patient{createPatient(t), createPatient(t)} {
+		path := "/patients/" + patient.ID + "/emergency_contacts"
+		contact := models.EmergencyContact{PatientID: patient.ID, Name: "Juma Otieno", Phone: testutil.NewID("+2547"), Relationship: "spouse", Primary: true}
+		if status := env.Request(t, http.MethodPost, path, "", contact, &contact); status != http.StatusCreated {
+			t.Fatalf("POST %s with a primary contact: got %d, want 201", path, status)
+		}
+		var got models.EmergencyContact
+		contactPath := fmt.Sprintf("%s/%d", path, contact.ID)
+		if !fetch(t, contactPath, &got) || !got.Primary {
+			t.Fatalf("GET %s = %+v, want the primary contact", contactPath, got)
+		}
+	}
+}
+
+func TestDeletePatientAndRelated(t *testing.T) {
+	patient := createPatient(t)
+	doctor := createDoctor(t)
+
+	contact := models.EmergencyContact{PatientID: patient.ID, Name: "Juma Otieno", Phone: testutil.NewID("+2547"), Relationship: "spouse"}
+	if status := env.Request(t, http.MethodPost, "/patients/"+patient.ID+"/emergency_contacts", "", contact, &contact); status != http.StatusCreated {
+		t.Fatalf("POST emergency contact: got %d, want 201", status)
+	}
+	billing := models.Billing{PatientID: patient.ID, DoctorID: doctor.ID, Procedure: "Scaling", BillingAmount: 2500, PaidCashAmount: 2500}
+	if status := env.Request(t, http.MethodPost, "/billings", "", billing, &billing); status != http.StatusCreated {
+		t.Fatalf("POST /billings: got %d, want 201", status)
+	}
+
+	contactPath := fmt.Sprintf("/patients/%s/emergency_contacts/%d", patient.ID, contact.ID)
+	billingPath := "/billings/" + billing.BillingID
+	if !fetch(t, contactPath, nil) || !fetch(t, billingPath, nil) {
+		t.Fatalf("related records not found before deletion")
+	}
+
+	// A patient with billing history is only deleted once an admin approves it
+	var pending struct {
+		Approval models.Approval `json:"approval"`
+	}
+	if status := env.Request(t, http.MethodDelete, "/patients/"+patient.ID+"/related", "", nil, &pending); status != http.StatusAccepted {
+		t.Fatalf("DELETE related: got %d, want 202", status)
+	}
+	if !fetch(t, "/patients/"+patient.ID, nil) {
+		t.Fatalf("patient deleted before the deletion was approved")
+	}
+	approvePath := fmt.Sprintf("/approvals/%d/approve", pending.Approval.ID)
+	if status := env.Request(t, http.MethodPost, approvePath, env.SignIn(t, "Admin"), nil, nil); status != http.StatusOK {
+		t.Fatalf("POST %s: got %d, want 200", approvePath, status)
+	}
+	for _, path := range []string{"/patients/" + patient.ID, contactPath, billingPath} {
+		if fetch(t, path, nil) {
+			t.Errorf("GET %s: still found after the patient and related records were deleted", path)
+		}
+	}
+}
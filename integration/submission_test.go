@@ -0,0 +1,46 @@
+//go:build integration
+
+package integration
+
+import (
+	"RoyDental/config"
+	"RoyDental/middlewares"
+	"RoyDental/repositories"
+	"RoyDental/services"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A response too large to replay to duplicates must not leave its submission claimed, or retries
+// would be refused until the window ends
+func TestOversizedSubmissionResponseIsNotReplayed(t *testing.T) {
+	tracker := services.NewSubmissionService(repositories.NewSubmissionRepository(env.Cache),
+		config.DuplicateSubmissionConfig{Window: time.Minute, Wait: 200 * time.Millisecond})
+	handled := 0
+	router := gin.New()
+	router.POST("/exports", middlewares.DuplicateSubmissionMiddleware(tracker), func(c *gin.Context) {
+		handled++
+		c.String(http.StatusCreated, strings.Repeat("x", 2<<20))
+	})
+
+	body := []byte(`{"case": "` + time.Now().Format(time.RFC3339Nano) + `"}`)
+	for i := 1; i <= 2; i++ {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/exports", bytes.NewReader(body)))
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("submission %d: got %d, want 201", i, recorder.Code)
+		}
+		if recorder.Header().Get(middlewares.DuplicateSubmissionHeader) != "" {
+			t.Fatalf("submission %d was answered as a duplicate", i)
+		}
+	}
+	if handled != 2 {
+		t.Fatalf("handler ran %d times, want 2", handled)
+	}
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// collector is anything that can write itself in the Prometheus text exposition format.
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]collector{}
+)
+
+func register(name string, c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	registry[name] = c
+}
+
+// WriteText writes every registered metric in the Prometheus text exposition format.
+func WriteText(w io.Writer) {
+	registryMu.RLock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, registry[name])
+	}
+	registryMu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// LookupCounter returns the registered counter with the given name, or nil when there is none.
+func LookupCounter(name string) *CounterVec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, _ := registry[name].(*CounterVec)
+	return c
+}
+
+// CounterVec is a set of monotonically increasing counters partitioned by label values.
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// NewCounterVec registers a counter with the given label names. Pass no labels for a plain counter.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     map[string]float64{},
+		labels:     map[string][]string{},
+	}
+	register(name, c)
+	return c
+}
+
+// Inc adds one to the counter identified by the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter identified by the label values.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.labels[key]; !exists {
+		c.labels[key] = append([]string{}, labelValues...)
+	}
+	c.values[key] += delta
+}
+
+// Value returns the current value of the counter identified by the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+// Total returns the sum of the counter across all label values.
+func (c *CounterVec) Total() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total float64
+	for _, v := range c.values {
+		total += v
+	}
+	return total
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, c.labels[key]), formatValue(c.values[key]))
+	}
+}
+
+// GaugeFunc is a gauge whose value is computed when metrics are scraped.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge backed by fn.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatValue(g.fn()))
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return "0"
+	}
+	return fmt.Sprintf("%g", v)
+}
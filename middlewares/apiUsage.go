@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiConsumerKey holds the integration a request was made by, set by the middleware admitting its key
+const apiConsumerKey = "apiConsumer"
+
+// APIUsageRecorder counts the requests of signed-in users and integrations.
+type APIUsageRecorder interface {
+	Enabled() bool
+	RecordAPIUsage(request models.APIRequest)
+}
+
+// APIUsageMiddleware counts each request made by a signed-in user or an integration with its own
+// key against its route once it has been answered, with the bytes sent each way. Requests that match
+// no route or that no one could be identified for are not counted.
+func APIUsageMiddleware(recorder APIUsageRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !recorder.Enabled() {
+			c.Next()
+			return
+		}
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		request := models.APIRequest{Method: c.Request.Method, Route: route, Status: c.Writer.Status(), At: time.Now()}
+		if userID, ok := models.ActorFrom(c.Request.Context()); ok {
+			request.Consumer = models.APIConsumerUser + ":" + strconv.FormatInt(userID, 10)
+			request.UserID = userID
+		} else if consumer := c.GetString(apiConsumerKey); consumer != "" {
+			request.Consumer = consumer
+		} else {
+			return
+		}
+		if c.Request.ContentLength > 0 {
+			request.RequestBytes = c.Request.ContentLength
+		}
+		if size := c.Writer.Size(); size > 0 {
+			request.ResponseBytes = int64(size)
+		}
+		recorder.RecordAPIUsage(request)
+	}
+}
+
+// identifyAPIKey counts the request against the integration whose key admitted it. The key is
+// named by a fingerprint so it never reaches the database.
+func identifyAPIKey(c *gin.Context, kind, key string) {
+	hash := sha256.Sum256([]byte(key))
+	c.Set(apiConsumerKey, kind+":"+hex.EncodeToString(hash[:4]))
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthFailureAuditor records rejected requests in the security audit trail, and the sign-ins and
+// sign-outs kept there for access reviews.
+type AuthFailureAuditor interface {
+	RecordAuthFailure(ctx context.Context, entry models.AuditLog)
+	RecordSecurityEvent(ctx context.Context, entry models.AuditLog)
+}
+
+var authFailureAuditor AuthFailureAuditor
+
+// SetAuthFailureAuditor installs the auditor notified of every authorization failure.
+func SetAuthFailureAuditor(auditor AuthFailureAuditor) {
+	authFailureAuditor = auditor
+}
+
+// AuditAuthFailure records an authorization failure with the request's IP, route and, when known, user.
+func AuditAuthFailure(c *gin.Context, event string, status int, detail string) {
+	if authFailureAuditor == nil {
+		return
+	}
+	entry := NewAuditEntry(c, models.AuditCategorySecurity, event)
+	entry.Status = status
+	entry.Detail = detail
+	authFailureAuditor.RecordAuthFailure(c.Request.Context(), entry)
+}
+
+// AuditLogin records a sign-in or sign-out of user, when known, in the security audit trail. Failed
+// sign-ins are recorded as authorization failures so they count towards the failure alerts.
+func AuditLogin(c *gin.Context, event string, status int, user *models.User, detail string) {
+	if authFailureAuditor == nil {
+		return
+	}
+	entry := NewAuditEntry(c, models.AuditCategorySecurity, event)
+	entry.Status = status
+	entry.Detail = detail
+	if user != nil {
+		entry.UserID = strconv.FormatInt(user.ID, 10)
+		entry.Role = user.Role.Name
+	}
+	if status >= 400 {
+		authFailureAuditor.RecordAuthFailure(c.Request.Context(), entry)
+		return
+	}
+	authFailureAuditor.RecordSecurityEvent(c.Request.Context(), entry)
+}
+
+// NewAuditEntry starts an audit entry for the request with its IP, route and, when known, user.
+func NewAuditEntry(c *gin.Context, category, event string) models.AuditLog {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	entry := models.AuditLog{
+		Category: category,
+		Event:    event,
+		IP:       c.ClientIP(),
+		Method:   c.Request.Method,
+		Route:    route,
+	}
+	entry.UserID, _ = ExtractUserIDFromContext(c.Request.Context())
+	entry.Role, _ = ExtractUserRoleFromContext(c.Request.Context())
+	return entry
+}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuthBodySize bounds the login and password reset bodies read for their email, which hold
+// only a few short fields
+const maxAuthBodySize = 64 << 10
+
+// AuthRateLimiter counts attempts at the auth endpoints per email address and client address.
+type AuthRateLimiter interface {
+	Enabled() bool
+	CheckAuthAttempt(ctx context.Context, endpoint, email, ip string) (time.Duration, error)
+}
+
+// AuthRateLimitMiddleware refuses attempts at a login or password reset endpoint once the email in
+// the body or the client address has used up its attempts, with a Retry-After of when the next is
+// allowed. It applies on top of the general rate limit, and lets requests through whenever the
+// limiter cannot be reached.
+func AuthRateLimitMiddleware(limiter AuthRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Enabled() {
+			c.Next()
+			return
+		}
+		var email string
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxAuthBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+					return
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			var credentials struct {
+				Email string `json:"email"`
+			}
+			// A body that is not JSON is left to the handler to refuse, and counted by address only
+			_ = json.Unmarshal(body, &credentials)
+			email = credentials.Email
+		}
+
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = c.Request.URL.Path
+		}
+		retryAfter, err := limiter.CheckAuthAttempt(c.Request.Context(), endpoint, email, c.ClientIP())
+		if err != nil {
+			log.Printf("Failed to check auth rate limit: %v", err)
+			c.Next()
+			return
+		}
+		if retryAfter > 0 {
+			AuditAuthFailure(c, models.AuditEventAuthRateLimited, http.StatusTooManyRequests, fmt.Sprintf("retry after %s", retryAfter.Round(time.Second)))
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many attempts, try again later",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,197 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"RoyDental/utils"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// policyMethods are the methods policies may be limited to
+var policyMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// tokenAudiences are the audiences access tokens are issued to
+var tokenAudiences = []string{utils.AudienceStaff, utils.AudiencePatientPortal, utils.AudienceKiosk, utils.AudienceIntegration}
+
+// Authorization enforces the route authorization policy table, one middleware deciding for every
+// route which access token, role and permissions a request needs. A route follows the entry of the
+// table that names it exactly, or else the entry with the longest prefix of it, and of those an
+// entry naming the request's method before one that does not. Routes without a policy are refused.
+type Authorization struct {
+	policies  []models.AuthPolicy
+	effective map[string]*models.AuthPolicy // By method and route, resolved by Check
+	routes    []models.RoutePolicy
+}
+
+// NewAuthorization checks the policy table, refusing entries that name unknown methods, audiences
+// or roles, that make anonymous routes need more than a bearer token, or that cover the same
+// routes and methods as another
+func NewAuthorization(policies []models.AuthPolicy) (*Authorization, error) {
+	seen := map[string]bool{}
+	for _, policy := range policies {
+		if !strings.HasPrefix(policy.Route, "/") {
+			return nil, fmt.Errorf("policy route %q must start with /", policy.Route)
+		}
+		if policy.Anonymous && (len(policy.Audiences) > 0 || len(policy.Roles) > 0 || len(policy.Permissions) > 0) {
+			return nil, fmt.Errorf("policy %s is anonymous but requires audiences, roles or permissions", policy.Route)
+		}
+		for _, audience := range policy.Audiences {
+			if !slices.Contains(tokenAudiences, audience) {
+				return nil, fmt.Errorf("policy %s names unknown audience %q", policy.Route, audience)
+			}
+		}
+		for _, role := range policy.Roles {
+			if !slices.Contains(tokenRoles, role) {
+				return nil, fmt.Errorf("policy %s names unknown role %q", policy.Route, role)
+			}
+		}
+		methods := policy.Methods
+		if len(methods) == 0 {
+			methods = []string{""}
+		}
+		for _, method := range methods {
+			if method != "" && !slices.Contains(policyMethods, method) {
+				return nil, fmt.Errorf("policy %s names unknown method %q", policy.Route, method)
+			}
+			key := strings.TrimSpace(method + " " + policy.Route)
+			if seen[key] {
+				return nil, fmt.Errorf("policy %s is given more than once", key)
+			}
+			seen[key] = true
+		}
+	}
+	return &Authorization{policies: policies, effective: map[string]*models.AuthPolicy{}}, nil
+}
+
+// Install enforces the policies on the routes registered on router from then on. The routes
+// registered before it, those of clients that authenticate with keys of their own, must be anonymous.
+func (a *Authorization) Install(router *gin.Engine) error {
+	for _, route := range router.Routes() {
+		if policy := a.match(route.Method, route.Path); policy == nil || !policy.Anonymous {
+			return fmt.Errorf("route %s %s is registered before authorization is enforced but is not anonymous", route.Method, route.Path)
+		}
+	}
+	router.Use(a.Middleware())
+	return nil
+}
+
+// Check resolves the policy of every route, failing when a route has none so it cannot be served
+// without one
+func (a *Authorization) Check(routes gin.RoutesInfo) error {
+	var missing []string
+	for _, route := range routes {
+		policy := a.match(route.Method, route.Path)
+		if policy == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+			continue
+		}
+		a.effective[route.Method+" "+route.Path] = policy
+		a.routes = append(a.routes, models.RoutePolicy{
+			Method:      route.Method,
+			Route:       route.Path,
+			Policy:      policy.Route,
+			Anonymous:   policy.Anonymous,
+			Audiences:   policyAudiences(policy),
+			Roles:       policy.Roles,
+			Permissions: policy.Permissions,
+		})
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no authorization policy covers %s", strings.Join(missing, ", "))
+	}
+	sort.Slice(a.routes, func(i, j int) bool {
+		if a.routes[i].Route != a.routes[j].Route {
+			return a.routes[i].Route < a.routes[j].Route
+		}
+		return a.routes[i].Method < a.routes[j].Method
+	})
+	return nil
+}
+
+// Routes returns the policy enforced on each route, by route and method
+func (a *Authorization) Routes() []models.RoutePolicy {
+	return a.routes
+}
+
+// Middleware enforces the policy of the request's route
+func (a *Authorization) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			// Not a route; gin answers 404
+			c.Next()
+			return
+		}
+		policy := a.effective[c.Request.Method+" "+route]
+		if policy == nil {
+			AuditAuthFailure(c, models.AuditEventPermissionDenied, http.StatusForbidden, "no authorization policy")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient privileges"})
+			c.Abort()
+			return
+		}
+		if policy.Anonymous {
+			c.Next()
+			return
+		}
+
+		if !authenticate(c, policyAudiences(policy)) {
+			return
+		}
+		if len(policy.Roles) > 0 && !authorizeRole(c, policy.Roles) {
+			return
+		}
+		for _, permission := range policy.Permissions {
+			if !authorizePermission(c, permission) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// match returns the most specific policy covering the route, or nil when none does
+func (a *Authorization) match(method, route string) *models.AuthPolicy {
+	var best *models.AuthPolicy
+	bestRank := -1
+	for i := range a.policies {
+		policy := &a.policies[i]
+		if len(policy.Methods) > 0 && !slices.Contains(policy.Methods, method) {
+			continue
+		}
+		// A policy is as specific as the part of the route it names, and naming the method makes it more so
+		var rank int
+		prefix, isPrefix := strings.CutSuffix(policy.Route, "/*")
+		switch {
+		case policy.Route == route:
+			rank = len(route) + 1
+		case isPrefix && (route == prefix || strings.HasPrefix(route, prefix+"/")):
+			rank = len(prefix)
+		default:
+			continue
+		}
+		rank *= 2
+		if len(policy.Methods) > 0 {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = policy, rank
+		}
+	}
+	return best
+}
+
+// policyAudiences returns the audiences tokens must be issued to for a policy
+func policyAudiences(policy *models.AuthPolicy) []string {
+	if policy.Anonymous {
+		return nil
+	}
+	if len(policy.Audiences) == 0 {
+		return staffAudiences
+	}
+	return policy.Audiences
+}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"RoyDental/models"
 	"log"
 	"net/http"
 	"strings"
@@ -15,6 +16,7 @@ func ValidateBearerToken(expectedBearerToken string) gin.HandlerFunc {
 		// Retrieve the Bearer token from the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			AuditAuthFailure(c, models.AuditEventMissingBearerToken, http.StatusUnauthorized, "Authorization header is missing")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
 			c.Abort()
 			return
@@ -22,6 +24,7 @@ func ValidateBearerToken(expectedBearerToken string) gin.HandlerFunc {
 
 		// Check if the Authorization header has the Bearer scheme
 		if !strings.HasPrefix(authHeader, "Bearer ") {
+			AuditAuthFailure(c, models.AuditEventInvalidBearerToken, http.StatusUnauthorized, "Invalid Authorization header format")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
 			c.Abort()
 			return
@@ -32,6 +35,7 @@ func ValidateBearerToken(expectedBearerToken string) gin.HandlerFunc {
 
 		// Constant-time comparison to mitigate timing attacks
 		if !secureCompare(token, expectedBearerToken) {
+			AuditAuthFailure(c, models.AuditEventInvalidBearerToken, http.StatusUnauthorized, "Bearer token mismatch")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Bearer Token"})
 			c.Abort()
 			return
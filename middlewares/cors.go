@@ -29,10 +29,6 @@ func CorsMiddleware(config *CorsConfig) gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Credentials", "true")
 		}
 
-		c.Header("X-Content-Type-Options", "nosniff")
-		c.Header("X-Frame-Options", "deny")
-		c.Header("X-XSS-Protection", "1; mode=block")
-
 		if c.Request.Method == http.MethodOptions {
 			c.Status(http.StatusOK)
 			c.Abort()
@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"RoyDental/debuglog"
+	"RoyDental/models"
+	"bytes"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyRecorder passes the response through while keeping its first limit bytes for the debug log.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *bodyRecorder) Write(p []byte) (int, error) {
+	w.record(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyRecorder) record(p []byte) {
+	if room := w.limit - w.body.Len(); room < len(p) {
+		w.truncated = true
+		p = p[:max(room, 0)]
+	}
+	w.body.Write(p)
+}
+
+// DebugLogMiddleware writes every request and response, with passwords, tokens, email addresses
+// and phone numbers masked, to the debug log while an Admin has it switched on. Only the first
+// bytes of each body are kept, and binary bodies such as uploads are left out.
+func DebugLogMiddleware(logger *debuglog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !logger.Enabled() {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		limit := logger.MaxBodySize()
+
+		// Read the start of the body and hand the handler all of it, however large it is
+		var requestBody []byte
+		truncated := false
+		if c.Request.Body != nil && loggableBody(c.GetHeader("Content-Type")) {
+			head, _ := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+			if len(head) > limit {
+				head, truncated = head[:limit], true
+			}
+			requestBody = head
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer, limit: limit}
+		c.Writer = recorder
+		c.Next()
+
+		entry := debuglog.Entry{
+			Time:        start,
+			RequestID:   RequestID(c),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Query:       debuglog.ScrubQuery(c.Request.URL.RawQuery),
+			Status:      c.Writer.Status(),
+			DurationMS:  time.Since(start).Milliseconds(),
+			RequestBody: debuglog.ScrubBody(requestBody),
+			Truncated:   truncated || recorder.truncated,
+		}
+		if userID, ok := models.ActorFrom(c.Request.Context()); ok {
+			entry.UserID = strconv.FormatInt(userID, 10)
+		}
+		if loggableBody(c.Writer.Header().Get("Content-Type")) {
+			entry.ResponseBody = debuglog.ScrubBody(recorder.body.Bytes())
+		}
+		logger.Write(entry)
+	}
+}
+
+// loggableBody reports whether a body of contentType is text worth logging.
+func loggableBody(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.Contains(mediaType, "json") ||
+		mediaType == "application/x-www-form-urlencoded" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// readCloser reads the replayed body and closes the original one
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,120 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DuplicateSubmissionHeader marks a response replayed to a duplicate submission.
+const DuplicateSubmissionHeader = "X-Duplicate-Submission"
+
+// maxSubmissionResponse is the largest response kept to answer duplicates with.
+const maxSubmissionResponse = 1 << 20
+
+// SubmissionTracker remembers recent create submissions and the responses to them.
+type SubmissionTracker interface {
+	Enabled() bool
+	ClaimSubmission(ctx context.Context, fingerprint string) (bool, error)
+	AwaitSubmissionResponse(ctx context.Context, fingerprint string) (*models.SubmissionResponse, error)
+	SaveSubmissionResponse(ctx context.Context, fingerprint string, response models.SubmissionResponse) error
+	ReleaseSubmission(ctx context.Context, fingerprint string) error
+}
+
+// DuplicateSubmissionMiddleware answers a create request that repeats one the same user just made,
+// with the same path and body, with the first request's response instead of handling it again.
+// Requests that failed are forgotten so they can be retried, and detection is skipped whenever the
+// tracker cannot be reached.
+func DuplicateSubmissionMiddleware(tracker SubmissionTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracker.Enabled() || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		fingerprint := submissionFingerprint(c, body)
+		first, err := tracker.ClaimSubmission(ctx, fingerprint)
+		if err != nil {
+			log.Printf("Failed to check for a duplicate submission: %v", err)
+			c.Next()
+			return
+		}
+		if !first {
+			replaySubmission(c, tracker, fingerprint)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer, limit: maxSubmissionResponse}
+		c.Writer = recorder
+		c.Next()
+
+		// The request's deadline may have passed while it was handled
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status < 200 || status >= 300 {
+			if err := tracker.ReleaseSubmission(ctx, fingerprint); err != nil {
+				log.Printf("Failed to release submission: %v", err)
+			}
+			return
+		}
+		if recorder.truncated {
+			// Too large to replay, so a retry is handled again rather than refused until the window ends
+			if err := tracker.ReleaseSubmission(ctx, fingerprint); err != nil {
+				log.Printf("Failed to release submission: %v", err)
+			}
+			return
+		}
+		response := models.SubmissionResponse{Status: status, ContentType: recorder.Header().Get("Content-Type"), Body: recorder.body.Bytes()}
+		if err := tracker.SaveSubmissionResponse(ctx, fingerprint, response); err != nil {
+			log.Printf("Failed to save submission response: %v", err)
+		}
+	}
+}
+
+// replaySubmission answers a duplicate with the first submission's response once there is one
+func replaySubmission(c *gin.Context, tracker SubmissionTracker, fingerprint string) {
+	response, err := tracker.AwaitSubmissionResponse(c.Request.Context(), fingerprint)
+	if err != nil {
+		log.Printf("Failed to get the response to a submission: %v", err)
+	}
+	if response == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "An identical request was just submitted and is still being processed"})
+		c.Abort()
+		return
+	}
+	c.Header(DuplicateSubmissionHeader, "true")
+	c.Data(response.Status, response.ContentType, response.Body)
+	c.Abort()
+}
+
+// submissionFingerprint identifies a submission by who made it, its method and path, and its body.
+// Requests without a user are told apart by their client address.
+func submissionFingerprint(c *gin.Context, body []byte) string {
+	submitter := "ip:" + c.ClientIP()
+	if userID, ok := models.ActorFrom(c.Request.Context()); ok {
+		submitter = "user:" + strconv.FormatInt(userID, 10)
+	}
+	hash := sha256.New()
+	for _, part := range []string{submitter, c.Request.Method, c.Request.URL.Path} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
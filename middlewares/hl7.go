@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HL7KeyHeader carries the key the hospital posts HL7 messages with.
+const HL7KeyHeader = "X-HL7-Key"
+
+// HL7AuthMiddleware admits requests carrying one of the configured HL7 API keys. The keys
+// only grant access to the HL7 listener; they are not accepted anywhere else.
+func HL7AuthMiddleware(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HL7KeyHeader)
+		for _, expected := range keys {
+			if key != "" && secureCompare(key, expected) {
+				identifyAPIKey(c, models.APIConsumerHL7, key)
+				c.Next()
+				return
+			}
+		}
+		AuditAuthFailure(c, models.AuditEventInvalidHL7Key, http.StatusUnauthorized, "invalid HL7 key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid HL7 key"})
+		c.Abort()
+	}
+}
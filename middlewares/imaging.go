@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImagingKeyHeader carries the imaging unit's API key.
+const ImagingKeyHeader = "X-Imaging-Key"
+
+// ImagingAuthMiddleware admits requests carrying one of the configured imaging API keys. The keys
+// only grant access to DICOM ingestion; they are not accepted anywhere else.
+func ImagingAuthMiddleware(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(ImagingKeyHeader)
+		for _, expected := range keys {
+			if key != "" && secureCompare(key, expected) {
+				identifyAPIKey(c, models.APIConsumerImaging, key)
+				c.Next()
+				return
+			}
+		}
+		AuditAuthFailure(c, models.AuditEventInvalidImagingKey, http.StatusUnauthorized, "invalid imaging key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid imaging key"})
+		c.Abort()
+	}
+}
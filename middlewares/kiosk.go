@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"RoyDental/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KioskKeyHeader carries the kiosk-scoped API key.
+const KioskKeyHeader = "X-Kiosk-Key"
+
+// KioskAuthMiddleware admits requests carrying one of the configured kiosk API keys, or the access
+// token of a kiosk a staff member signed in. Both only grant access to the kiosk routes; they are
+// not accepted anywhere else.
+func KioskAuthMiddleware(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("accessToken"); token != "" {
+			claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist")
+			if err == nil && claims.Scope() == utils.AudienceKiosk {
+				c.Request = c.Request.WithContext(withActor(c.Request.Context(), claims.UserID))
+				c.Next()
+				return
+			}
+		}
+
+		key := c.GetHeader(KioskKeyHeader)
+		for _, expected := range keys {
+			if key != "" && secureCompare(key, expected) {
+				identifyAPIKey(c, models.APIConsumerKiosk, key)
+				c.Next()
+				return
+			}
+		}
+		AuditAuthFailure(c, models.AuditEventInvalidKioskKey, http.StatusUnauthorized, "invalid kiosk key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid kiosk key"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"RoyDental/config"
+	"RoyDental/metrics"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	latencyBudgetExceeded = metrics.NewCounterVec("http_latency_budget_exceeded_total", "Requests that took longer than their route group's latency budget.", "group")
+	requestsShed          = metrics.NewCounterVec("http_requests_shed_total", "Requests turned away while the database pool was saturated.", "group")
+)
+
+// LatencyBudgetMiddleware logs and counts requests that take longer than the budget of their route
+// group, chosen by the first segment of the route as request deadlines are. When shedding is on,
+// requests of the heavy groups, such as reports and exports, are answered 503 with a Retry-After
+// while poolUsage reports the database pool saturated, so booking and other interactive traffic
+// keeps its connections.
+func LatencyBudgetMiddleware(cfg config.LatencyBudgetConfig, poolUsage func() float64) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds())))
+	return func(c *gin.Context) {
+		group := routeGroup(c.FullPath())
+		if cfg.Sheddable(group) {
+			if usage := poolUsage(); usage >= cfg.ShedRatio {
+				requestsShed.Inc(group)
+				log.Printf("Shed %s %s with the database pool %.0f%% in use [request_id=%s]", c.Request.Method, c.Request.URL.Path, usage*100, RequestID(c))
+				c.Header("Retry-After", retryAfter)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "The server is busy, try again later", "request_id": RequestID(c)})
+				return
+			}
+		}
+
+		start := time.Now()
+		c.Next()
+
+		budget := cfg.For(group)
+		if elapsed := time.Since(start); budget > 0 && elapsed > budget {
+			latencyBudgetExceeded.Inc(group)
+			log.Printf("Request %s %s took %s, over its %s budget [request_id=%s]", c.Request.Method, c.FullPath(), elapsed.Round(time.Millisecond), budget, RequestID(c))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"RoyDental/metrics"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var httpResponses = metrics.NewCounterVec("http_responses_total", "HTTP responses by status class.", "class")
+
+// ResponseMetricsMiddleware counts responses by status class (2xx, 4xx, 5xx, ...).
+func ResponseMetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		httpResponses.Inc(strconv.Itoa(c.Writer.Status()/100) + "xx")
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionChecker reports whether the role of a user grants a permission
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+var permissionChecker PermissionChecker
+
+// SetPermissionChecker installs the checker asked about the permissions route policies require.
+// Until one is installed every request to a route needing a permission is refused.
+func SetPermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// authorizePermission aborts the request unless the role of its user grants the permission. Roles
+// decide which routes a user reaches, permissions what they may do there.
+func authorizePermission(c *gin.Context, permission string) bool {
+	userID, err := ExtractUserIDFromContext(c.Request.Context())
+	if err != nil {
+		AuditAuthFailure(c, models.AuditEventPermissionDenied, http.StatusUnauthorized, "User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		c.Abort()
+		return false
+	}
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		AuditAuthFailure(c, models.AuditEventPermissionDenied, http.StatusUnauthorized, "Invalid user ID")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		c.Abort()
+		return false
+	}
+
+	granted := false
+	if permissionChecker != nil {
+		granted, err = permissionChecker.HasPermission(c.Request.Context(), id, permission)
+		if err != nil {
+			log.Printf("Failed to check permission %s of user %d: %v", permission, id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return false
+		}
+	}
+	if !granted {
+		AuditAuthFailure(c, models.AuditEventPermissionDenied, http.StatusForbidden, "required permission "+permission)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient privileges"})
+		c.Abort()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrintAgentKeyHeader carries the print agent's API key.
+const PrintAgentKeyHeader = "X-Print-Agent-Key"
+
+// PrintAgentAuthMiddleware admits requests carrying one of the configured print agent keys. The keys
+// only grant access to fetching print jobs and reporting on them.
+func PrintAgentAuthMiddleware(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(PrintAgentKeyHeader)
+		for _, expected := range keys {
+			if key != "" && secureCompare(key, expected) {
+				identifyAPIKey(c, models.APIConsumerPrintAgent, key)
+				c.Next()
+				return
+			}
+		}
+		AuditAuthFailure(c, models.AuditEventInvalidPrintAgentKey, http.StatusUnauthorized, "invalid print agent key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid print agent key"})
+		c.Abort()
+	}
+}
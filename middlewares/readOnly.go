@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadOnlyErrorCode tells clients a write was refused because the server is read-only, not because
+// it failed
+const ReadOnlyErrorCode = "read_only"
+
+// defaultReadOnlyMessage is shown when read-only mode was turned on without a message
+const defaultReadOnlyMessage = "The system is read-only for now. Records can be looked up but changes cannot be saved."
+
+// ReadOnlyState tells whether the server refuses writes.
+type ReadOnlyState interface {
+	Status() models.ReadOnlyStatus
+	RetryAfter() time.Duration
+}
+
+// ReadOnlyMiddleware refuses every request but GET, HEAD and OPTIONS with 503 and the read_only code
+// while the server is read-only, so the front desk can go on looking patients up during an incident.
+// The routes in exempt, such as signing in and turning read-only mode off, are always let through.
+func ReadOnlyMiddleware(state ReadOnlyState, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		status := state.Status()
+		if !status.Enabled || slices.Contains(exempt, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		message := status.Message
+		if message == "" {
+			message = defaultReadOnlyMessage
+		}
+		if retryAfter := state.RetryAfter(); retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":  message,
+			"code":   ReadOnlyErrorCode,
+			"reason": status.Reason,
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// patientIDParam is the route parameter most routes of a patient's record name the patient by
+const patientIDParam = ":patient_id"
+
+// otherRecordRoutes are the routes of a patient's record that name the patient by :id, with the
+// section they read
+var otherRecordRoutes = map[string]string{
+	"/me/doctor/patients/:id/summary": "summary",
+}
+
+// RecordAccessRecorder logs the reads of patients' records.
+type RecordAccessRecorder interface {
+	RecordAccess(ctx context.Context, access models.RecordAccess)
+}
+
+// RecordAccessMiddleware logs each successful read of a patient's record once it has been answered:
+// who read it, when, from where and which section, named by the first segment of the route after
+// the patient without its extension, such as chart for chart.pdf, or "record" for the patient's own
+// routes.
+func RecordAccessMiddleware(recorder RecordAccessRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Request.Method != http.MethodGet || c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
+			return
+		}
+		patientID, section, ok := recordSection(c)
+		if !ok || patientID == "" {
+			return
+		}
+		access := models.RecordAccess{
+			PatientID:  patientID,
+			Section:    section,
+			Route:      c.FullPath(),
+			IP:         c.ClientIP(),
+			AccessedAt: time.Now(),
+		}
+		if userID, ok := models.ActorFrom(c.Request.Context()); ok {
+			access.UserID = &userID
+		}
+		access.Role, _ = ExtractUserRoleFromContext(c.Request.Context())
+		recorder.RecordAccess(c.Request.Context(), access)
+	}
+}
+
+// recordSection returns the patient whose record the request read and the section it read, or
+// false when the route is not of a patient's record
+func recordSection(c *gin.Context) (string, string, bool) {
+	route := c.FullPath()
+	if section, ok := otherRecordRoutes[route]; ok {
+		return c.Param("id"), section, true
+	}
+	_, rest, found := strings.Cut(route, "/"+patientIDParam)
+	if !found || (rest != "" && rest[0] != '/') {
+		return "", "", false
+	}
+	section, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+	section = strings.TrimSuffix(section, path.Ext(section))
+	if section == "" {
+		section = models.RecordSectionPatient
+	}
+	return c.Param("patient_id"), section, true
+}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"RoyDental/config"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// requestIDHeader carries the request ID in from proxies and back out to clients.
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is where the request ID is kept in the gin context.
+	requestIDKey = "requestID"
+	// maxRequestIDLength bounds request IDs accepted from upstream.
+	maxRequestIDLength = 128
+)
+
+// RequestIDMiddleware tags each request with the X-Request-ID sent by a proxy, or a new one,
+// and echoes it in the response so errors can be matched with the logs.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID RequestIDMiddleware gave the request.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// RecoveryMiddleware turns a panic into the standard error response and logs its stack with the request ID.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// A client that hung up cannot be answered, and its stack is noise
+			if err, ok := recovered.(error); ok && isBrokenPipe(err) {
+				log.Printf("Request %s %s aborted by client [request_id=%s]: %v", c.Request.Method, c.Request.URL.Path, RequestID(c), err)
+				c.Abort()
+				return
+			}
+			log.Printf("Panic serving %s %s [request_id=%s]: %v\n%s", c.Request.Method, c.Request.URL.Path, RequestID(c), recovered, debug.Stack())
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "request_id": RequestID(c)})
+		}()
+		c.Next()
+	}
+}
+
+// RequestTimeoutMiddleware gives each request a deadline, chosen by the first segment of its route,
+// that database and cache calls made with the request context honour. A handler that runs out of
+// time without answering gets a 504.
+func RequestTimeoutMiddleware(cfg config.RequestTimeoutConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.For(routeGroup(c.FullPath())))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			log.Printf("Request %s %s timed out [request_id=%s]", c.Request.Method, c.Request.URL.Path, RequestID(c))
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out", "request_id": RequestID(c)})
+		}
+	}
+}
+
+// routeGroup returns the first segment of a route, e.g. "patients" for /patients/:patient_id.
+func routeGroup(route string) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+	return group
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func isBrokenPipe(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if !errors.As(opErr.Err, &syscallErr) {
+		return false
+	}
+	msg := strings.ToLower(syscallErr.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"context"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportTokenHeader carries the read-only token reporting tools read reports with.
+const ReportTokenHeader = "X-Report-Token"
+
+// ReportTokenAuthenticator looks up report tokens, returning nil for tokens that are unknown,
+// expired or revoked.
+type ReportTokenAuthenticator interface {
+	AuthenticateReportToken(ctx context.Context, token string) (*models.ReportToken, error)
+}
+
+// ReportTokenMiddleware admits requests carrying an active report token scoped to the report they
+// read, named by the last segment of the route, and to branch, the branch this server serves. The
+// tokens only grant access to the reporting routes; they are not accepted anywhere else.
+func ReportTokenMiddleware(tokens ReportTokenAuthenticator, branch string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(ReportTokenHeader)
+		if key == "" {
+			AuditAuthFailure(c, models.AuditEventInvalidReportToken, http.StatusUnauthorized, "missing report token")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing report token"})
+			c.Abort()
+			return
+		}
+		token, err := tokens.AuthenticateReportToken(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if token == nil {
+			AuditAuthFailure(c, models.AuditEventInvalidReportToken, http.StatusUnauthorized, "invalid report token")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid report token"})
+			c.Abort()
+			return
+		}
+
+		report := path.Base(c.FullPath())
+		if !token.Reports.Allows(report) || !token.Branches.Allows(branch) {
+			AuditAuthFailure(c, models.AuditEventInvalidReportToken, http.StatusForbidden, "report token "+token.Prefix+" is not scoped to "+report+" at "+branch)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: the report token does not cover this report"})
+			c.Abort()
+			return
+		}
+		c.Set(apiConsumerKey, models.APIConsumerReportToken+":"+token.Prefix)
+		c.Next()
+	}
+}
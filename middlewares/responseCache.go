@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCacheHeader tells whether a response came from the response cache: HIT or MISS.
+const ResponseCacheHeader = "X-Cache"
+
+// ResponseStore keeps the responses to cacheable GETs by route.
+type ResponseStore interface {
+	Enabled() bool
+	MaxResponseSize() int
+	CachedResponse(ctx context.Context, route, fingerprint string) (*models.CachedResponse, error)
+	SaveResponse(ctx context.Context, route, fingerprint string, response models.CachedResponse) error
+}
+
+// ResponseCache hands out the caching middleware of each cacheable route. A nil ResponseCache
+// caches nothing.
+type ResponseCache struct {
+	store ResponseStore
+}
+
+func NewResponseCache(store ResponseStore) *ResponseCache {
+	return &ResponseCache{store: store}
+}
+
+// For returns the middleware caching the successful GETs of route. Responses are shared by every
+// user, so it only goes on routes whose response does not depend on who asks; they are kept until
+// the store drops the route's responses. A request with Cache-Control: no-cache skips the cached
+// response and refreshes it, and the cache is skipped whenever the store cannot be reached.
+func (rc *ResponseCache) For(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rc == nil || !rc.store.Enabled() || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		fingerprint := responseFingerprint(c)
+		if !strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+			response, err := rc.store.CachedResponse(ctx, route, fingerprint)
+			if err != nil {
+				log.Printf("Failed to get cached response: %v", err)
+			}
+			if response != nil {
+				c.Header(ResponseCacheHeader, "HIT")
+				c.Data(response.Status, response.ContentType, response.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Header(ResponseCacheHeader, "MISS")
+		recorder := &bodyRecorder{ResponseWriter: c.Writer, limit: rc.store.MaxResponseSize()}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() != http.StatusOK || recorder.truncated {
+			return
+		}
+		response := models.CachedResponse{Status: http.StatusOK, ContentType: recorder.Header().Get("Content-Type"), Body: recorder.body.Bytes()}
+		// The request's deadline may have passed while it was handled
+		if err := rc.store.SaveResponse(context.WithoutCancel(ctx), route, fingerprint, response); err != nil {
+			log.Printf("Failed to cache response: %v", err)
+		}
+	}
+}
+
+// responseFingerprint identifies a request by its path and query, with the query parameters sorted
+// so the same request is recognised whatever their order
+func responseFingerprint(c *gin.Context) string {
+	hash := sha256.Sum256([]byte(c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()))
+	return hex.EncodeToString(hash[:])
+}
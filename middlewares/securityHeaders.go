@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"RoyDental/config"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersMiddleware adds the security headers to every response, whether or not the
+// request came from an allowed CORS origin and whether or not it is later rejected.
+func SecurityHeadersMiddleware(cfg config.SecurityHeadersConfig) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "deny")
+		h.Set("X-XSS-Protection", "1; mode=block")
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.PermissionsPolicy != "" {
+			h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+		}
+
+		csp := cfg.ContentSecurityPolicy
+		if cfg.SwaggerPathPrefix != "" && strings.HasPrefix(c.Request.URL.Path, cfg.SwaggerPathPrefix) {
+			csp = cfg.SwaggerCSP
+		}
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+
+		c.Next()
+	}
+}
@@ -1,10 +1,14 @@
 package middlewares
 
 import (
+	"RoyDental/models"
 	"RoyDental/utils"
 	"context"
 	"errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,56 +22,90 @@ const (
 	userRoleKey contextKey = "userRole"
 )
 
-// TokenAuthMiddleware validates the token and adds user details to the request context.
-func TokenAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Retrieve the accessToken from the URL query parameter.
-		token := c.DefaultQuery("accessToken", "")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
-			c.Abort()
-			return
-		}
+// staffAudiences are the token audiences routes accept when their policy names none: the staff application's
+var staffAudiences = []string{utils.AudienceStaff}
 
-		// Validate the token and extract claims.
-		claims, err := utils.ValidateToken(token, "Admin", "Doctor", "Receptionist", "Patient")
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+// tokenRoles are the roles access tokens are accepted for
+var tokenRoles = []string{"Admin", "Doctor", "Receptionist", "Patient", "Insurer"}
 
-		// Add user details (UserID and Role) to the context for later use in handlers.
-		ctx := context.WithValue(c.Request.Context(), userIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, userRoleKey, claims.Role)
-		c.Request = c.Request.WithContext(ctx)
+// authenticate validates the access token of the request and adds user details to the request
+// context, aborting the request when it has none or it is invalid. The token must have been issued
+// to one of audiences, so a token of a kiosk or the patient portal is refused whatever its role.
+func authenticate(c *gin.Context, audiences []string) bool {
+	// Retrieve the accessToken from the URL query parameter.
+	token := c.DefaultQuery("accessToken", "")
+	if token == "" {
+		AuditAuthFailure(c, models.AuditEventMissingAccessToken, http.StatusUnauthorized, "Missing access token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+		c.Abort()
+		return false
+	}
 
-		// Continue to the next middleware/handler.
-		c.Next()
+	// Validate the token and extract claims.
+	claims, err := utils.ValidateToken(token, tokenRoles...)
+	if err != nil {
+		AuditAuthFailure(c, models.AuditEventInvalidAccessToken, http.StatusUnauthorized, err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
 	}
+
+	// The token must have been issued to a client the route serves.
+	if scope := claims.Scope(); !slices.Contains(audiences, scope) {
+		AuditAuthFailure(c, models.AuditEventAudienceMismatch, http.StatusForbidden, "token audience "+scope+", required "+strings.Join(audiences, " or "))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: token not issued for this client"})
+		c.Abort()
+		return false
+	}
+
+	// Add user details (UserID and Role) to the context for later use in handlers.
+	ctx := context.WithValue(c.Request.Context(), userIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, userRoleKey, claims.Role)
+	c.Request = c.Request.WithContext(withActor(ctx, claims.UserID))
+	return true
 }
 
-// RoleAuthMiddleware restricts access to users with the specified role.
-func RoleAuthMiddleware(requiredRole string) gin.HandlerFunc {
+// IdentifyUserMiddleware attributes the writes of a request to the user of its accessToken, when
+// one is given and valid, without requiring it. Routes that need a user require it in their policy.
+func IdentifyUserMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract user role from context.
-		role, err := ExtractUserRoleFromContext(c.Request.Context())
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context"})
-			c.Abort()
-			return
+		if token := c.Query("accessToken"); token != "" {
+			if claims, err := utils.ValidateToken(token, tokenRoles...); err == nil {
+				c.Request = c.Request.WithContext(withActor(c.Request.Context(), claims.UserID))
+			}
 		}
+		c.Next()
+	}
+}
 
-		// Check if the user's role matches the required role.
-		if role != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient privileges"})
-			c.Abort()
-			return
-		}
+// withActor records the user in ctx so repositories fill created_by and updated_by
+func withActor(ctx context.Context, userID string) context.Context {
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return ctx
+	}
+	return models.WithActor(ctx, id)
+}
 
-		// Role matches, so continue processing the request.
-		c.Next()
+// authorizeRole aborts the request unless its user has one of roles
+func authorizeRole(c *gin.Context, roles []string) bool {
+	// Extract user role from context.
+	role, err := ExtractUserRoleFromContext(c.Request.Context())
+	if err != nil {
+		AuditAuthFailure(c, models.AuditEventRoleMismatch, http.StatusUnauthorized, "User role not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context"})
+		c.Abort()
+		return false
+	}
+
+	// Check if the user's role matches one of the required roles.
+	if !slices.Contains(roles, role) {
+		AuditAuthFailure(c, models.AuditEventRoleMismatch, http.StatusForbidden, "required role "+strings.Join(roles, " or "))
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: insufficient privileges"})
+		c.Abort()
+		return false
 	}
+	return true
 }
 
 // ExtractUserIDFromContext retrieves the userID from the context.
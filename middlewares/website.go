@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"RoyDental/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebsiteKeyHeader carries the clinic website's API key.
+const WebsiteKeyHeader = "X-Website-Key"
+
+// WebsiteAuthMiddleware admits requests carrying one of the configured website API keys. The keys
+// only grant access to the public listings and to requesting appointments.
+func WebsiteAuthMiddleware(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(WebsiteKeyHeader)
+		for _, expected := range keys {
+			if key != "" && secureCompare(key, expected) {
+				identifyAPIKey(c, models.APIConsumerWebsite, key)
+				c.Next()
+				return
+			}
+		}
+		AuditAuthFailure(c, models.AuditEventInvalidWebsiteKey, http.StatusUnauthorized, "invalid website key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid website key"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type actorContextKey struct{}
+
+// WithActor records in ctx the staff member on whose behalf records are written
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, userID)
+}
+
+// ActorFrom returns the staff member recorded by WithActor
+func ActorFrom(ctx context.Context) (int64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	userID, ok := ctx.Value(actorContextKey{}).(int64)
+	return userID, ok
+}
+
+// actorColumn is the acting user as a nullable column value; writes without one, such as
+// background jobs, are recorded as NULL rather than keeping what the client sent
+func actorColumn(tx *gorm.DB) *int64 {
+	if userID, ok := ActorFrom(tx.Statement.Context); ok {
+		return &userID
+	}
+	return nil
+}
+
+// stampCreated fills created_by and updated_by of a new row from the statement's context
+func stampCreated(tx *gorm.DB) {
+	actor := actorColumn(tx)
+	tx.Statement.SetColumn("created_by", actor)
+	tx.Statement.SetColumn("updated_by", actor)
+}
+
+// stampUpdated fills updated_by of a changed row from the statement's context
+func stampUpdated(tx *gorm.DB) {
+	tx.Statement.SetColumn("updated_by", actorColumn(tx))
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Address is a postal address, kept in parts so patients can be counted by area
+type Address struct {
+	Street     string `gorm:"column:street" json:"street"`
+	City       string `gorm:"column:city" json:"city"`
+	County     string `gorm:"column:county" json:"county"`
+	PostalCode string `gorm:"column:postal_code" json:"postal_code"`
+}
+
+// Trimmed returns the address without surrounding spaces in its parts
+func (a Address) Trimmed() Address {
+	return Address{
+		Street:     strings.TrimSpace(a.Street),
+		City:       strings.TrimSpace(a.City),
+		County:     strings.TrimSpace(a.County),
+		PostalCode: strings.TrimSpace(a.PostalCode),
+	}
+}
+
+// IsZero reports whether no part of the address is filled in
+func (a Address) IsZero() bool {
+	return a.Trimmed() == Address{}
+}
+
+// String returns the address on one line, e.g. for a geocoding query or a claim form
+func (a Address) String() string {
+	a = a.Trimmed()
+	var parts []string
+	for _, part := range []string{a.Street, a.City, a.County, a.PostalCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GeoLocation is where the geocoding provider placed an address. GeocodedAt is set once the address
+// was looked up, with no coordinates when the provider could not place it.
+type GeoLocation struct {
+	Latitude   *float64   `gorm:"column:latitude" json:"latitude,omitempty"`
+	Longitude  *float64   `gorm:"column:longitude" json:"longitude,omitempty"`
+	GeocodedAt *time.Time `gorm:"column:geocoded_at" json:"geocoded_at,omitempty"`
+}
+
+// Located reports whether the address was placed on the map
+func (l GeoLocation) Located() bool {
+	return l.Latitude != nil && l.Longitude != nil
+}
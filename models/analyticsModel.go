@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// Analytics dimensions
+const (
+	AnalyticsDimensionProcedure      = "procedure"
+	AnalyticsDimensionAgeBand        = "age_band"
+	AnalyticsDimensionInsurer        = "insurer"
+	AnalyticsDimensionAttendance     = "attendance"
+	AnalyticsDimensionCounty         = "county"           // Visits by the county, or else the city, patients live in
+	AnalyticsDimensionBookedDistance = "booked_distance"  // Booked appointments by how far from the clinic patients live
+	AnalyticsDimensionNoShowDistance = "no_show_distance" // No-shows by how far from the clinic patients live
+)
+
+// AnalyticsUnknownBucket collects patients whose county or distance is not known
+const AnalyticsUnknownBucket = "Unknown"
+
+// DistanceBand is a range of distances from the clinic patients are grouped in
+type DistanceBand struct {
+	Name   string
+	UpToKM float64 // Exclusive upper bound; 0 for the last band
+}
+
+// DistanceBands are the distance bands in order
+var DistanceBands = []DistanceBand{
+	{"0-5 km", 5}, {"5-10 km", 10}, {"10-20 km", 20}, {"20-50 km", 50}, {"50+ km", 0},
+}
+
+// DistanceBandOf returns the name of the band a distance falls in
+func DistanceBandOf(km float64) string {
+	for _, band := range DistanceBands {
+		if band.UpToKM == 0 || km < band.UpToKM {
+			return band.Name
+		}
+	}
+	return AnalyticsUnknownBucket
+}
+
+// Attendance buckets
+const (
+	AttendanceScheduled = "scheduled"
+	AttendanceAttended  = "attended"
+	AttendanceCancelled = "cancelled"
+	AttendanceNoShow    = "no_show"
+)
+
+// AnalyticsOtherBucket collects buckets too small to report on their own
+const AnalyticsOtherBucket = "Other"
+
+// AnalyticsAggregate is one anonymized daily count, e.g. the visits of patients aged 18-34 on a day.
+// It holds no patient identifiers, so reporting tools can query it freely.
+type AnalyticsAggregate struct {
+	Day       time.Time `gorm:"column:day;type:date;primaryKey" json:"day"`
+	Dimension string    `gorm:"column:dimension;size:50;primaryKey" json:"dimension"`
+	Bucket    string    `gorm:"column:bucket;size:255;primaryKey" json:"bucket"`
+	Count     int64     `gorm:"column:count;not null" json:"count"`
+	Amount    float64   `gorm:"column:amount;not null;default:0" json:"amount"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (AnalyticsAggregate) TableName() string {
+	return "analytics_aggregate"
+}
+
+// AnalyticsBucket is a bucket total over a reporting period
+type AnalyticsBucket struct {
+	Bucket string  `json:"bucket"`
+	Count  int64   `json:"count"`
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// AnalyticsReport summarizes the anonymized aggregates over a period
+type AnalyticsReport struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Procedures []AnalyticsBucket `json:"procedures"`
+	AgeBands   []AnalyticsBucket `json:"age_bands"`
+	Insurers   []AnalyticsBucket `json:"insurers"`
+	Attendance []AnalyticsBucket `json:"attendance"`
+	NoShowRate float64           `json:"no_show_rate"`
+	Counties   []AnalyticsBucket `json:"counties"`
+	// NoShowsByDistance is only filled in once the clinic's location is configured
+	NoShowsByDistance []DistanceNoShows `json:"no_shows_by_distance"`
+}
+
+// DistanceNoShows is how often patients living in a distance band missed their appointments
+type DistanceNoShows struct {
+	Band       string  `json:"band"`
+	Booked     int64   `json:"booked"`
+	NoShows    int64   `json:"no_shows"`
+	NoShowRate float64 `json:"no_show_rate"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// Kinds of API consumer, the part of a consumer before the colon
+const (
+	APIConsumerUser        = "user"         // A signed-in user, by user ID
+	APIConsumerKiosk       = "kiosk"        // A waiting-room kiosk, by key fingerprint
+	APIConsumerWebsite     = "website"      // The clinic website, by key fingerprint
+	APIConsumerPrintAgent  = "print_agent"  // A desk printer's agent, by key fingerprint
+	APIConsumerHL7         = "hl7"          // The partner hospital, by key fingerprint
+	APIConsumerImaging     = "imaging"      // The imaging unit, by key fingerprint
+	APIConsumerReportToken = "report_token" // A reporting tool, by token prefix
+)
+
+// APIConsumerKinds lists the kinds of API consumer
+var APIConsumerKinds = []string{
+	APIConsumerUser, APIConsumerKiosk, APIConsumerWebsite, APIConsumerPrintAgent,
+	APIConsumerHL7, APIConsumerImaging, APIConsumerReportToken,
+}
+
+// APIUsage bucket sizes
+const (
+	APIUsageBucketHour = "hour"
+	APIUsageBucketDay  = "day"
+)
+
+// APIRequest is one answered request, as counted for the API usage analytics
+type APIRequest struct {
+	Consumer      string // Kind and ID of who made it, such as user:12 or kiosk:3f2a9c1e
+	UserID        int64  // Signed-in user who made it, 0 for an integration
+	Method        string
+	Route         string
+	Status        int
+	RequestBytes  int64
+	ResponseBytes int64
+	At            time.Time
+}
+
+// APIUsageBucket counts a consumer's requests to a route over an hour
+type APIUsageBucket struct {
+	Consumer      string    `gorm:"column:consumer;size:100;primaryKey" json:"consumer"`
+	Hour          time.Time `gorm:"column:hour;primaryKey;index" json:"hour"`
+	Method        string    `gorm:"column:method;size:10;primaryKey" json:"method"`
+	Route         string    `gorm:"column:route;size:255;primaryKey" json:"route"`
+	Requests      int64     `gorm:"column:requests;not null;default:0" json:"requests"`
+	Errors        int64     `gorm:"column:errors;not null;default:0" json:"errors"` // Requests answered with a 4xx or 5xx status
+	RequestBytes  int64     `gorm:"column:request_bytes;not null;default:0" json:"request_bytes"`
+	ResponseBytes int64     `gorm:"column:response_bytes;not null;default:0" json:"response_bytes"`
+}
+
+func (APIUsageBucket) TableName() string {
+	return "api_usage_buckets"
+}
+
+// APIUsageQuery selects the usage reported at /admin/usage
+type APIUsageQuery struct {
+	From     time.Time
+	To       time.Time // Exclusive
+	Bucket   string    // hour or day, in clinic time
+	Consumer string    // Only this consumer, or every consumer of a kind when given without an ID
+	Route    string    // Only this route
+	ByRoute  bool      // Break each consumer's buckets down by route
+}
+
+// APIUsageBucketCount is a consumer's usage over one time bucket, of one route when broken down by route
+type APIUsageBucketCount struct {
+	Start         time.Time `json:"start"`
+	Consumer      string    `json:"consumer"`
+	Method        string    `json:"method,omitempty"`
+	Route         string    `json:"route,omitempty"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	ErrorRate     float64   `json:"error_rate"`
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+}
+
+// APIConsumerUsage is a consumer's usage over the whole period of a report
+type APIConsumerUsage struct {
+	Consumer         string  `json:"consumer"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	ErrorRate        float64 `json:"error_rate"`
+	RequestBytes     int64   `json:"request_bytes"`
+	ResponseBytes    int64   `json:"response_bytes"`
+	PeakHourRequests int64   `json:"peak_hour_requests"`
+	HourlyQuota      int64   `json:"hourly_quota,omitempty"` // Soft quota warned about, 0 when none
+	OverQuotaHours   int     `json:"over_quota_hours"`       // Hours the consumer went over its quota
+}
+
+// APIUsageReport is the API usage by consumer over a period, in time buckets
+type APIUsageReport struct {
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Bucket    string                `json:"bucket"`
+	Consumers []APIConsumerUsage    `json:"consumers"` // Most requests first
+	Buckets   []APIUsageBucketCount `json:"buckets"`   // Oldest first
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PatientConflict is another appointment of the same patient overlapping the one being booked,
+// possibly with a different doctor
+type PatientConflict struct {
+	AppointmentID uint       `json:"appointment_id"`
+	DoctorID      string     `json:"doctor_id"`
+	DoctorName    string     `json:"doctor_name"`
+	DateTime      string     `json:"date_time"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	Status        string     `json:"status"`
+}
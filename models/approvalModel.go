@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actions that wait for a second user's approval before they are carried out
+const (
+	ApprovalActionPatientDeletion = "patient_deletion" // Deleting a patient with billing history
+	ApprovalActionBillingDiscount = "billing_discount" // Billing well below the catalog or contract price
+	ApprovalActionPayrollReopen   = "payroll_reopen"   // Withdrawing the sign-off of a month's payroll
+	ApprovalActionCaseExport      = "case_export"      // Sharing an anonymized treatment case outside the practice
+)
+
+// IsValidApprovalAction reports whether action is one of the actions waiting for approval
+func IsValidApprovalAction(action string) bool {
+	switch action {
+	case ApprovalActionPatientDeletion, ApprovalActionBillingDiscount, ApprovalActionPayrollReopen, ApprovalActionCaseExport:
+		return true
+	}
+	return false
+}
+
+// ApprovalPermission returns the permission deciding approvals of action takes on top of being an
+// admin, or "" when being one is enough
+func ApprovalPermission(action string) string {
+	if action == ApprovalActionBillingDiscount {
+		return PermissionApproveDiscount
+	}
+	return ""
+}
+
+// Approval statuses. An approved action is carried out straight away; one that could not be ends
+// up failed, with the reason in Error.
+const (
+	ApprovalStatusPending   = "pending"
+	ApprovalStatusApproved  = "approved"
+	ApprovalStatusRejected  = "rejected"
+	ApprovalStatusCancelled = "cancelled"
+	ApprovalStatusFailed    = "failed"
+)
+
+// IsValidApprovalStatus reports whether status is one of the approval statuses
+func IsValidApprovalStatus(status string) bool {
+	switch status {
+	case ApprovalStatusPending, ApprovalStatusApproved, ApprovalStatusRejected, ApprovalStatusCancelled, ApprovalStatusFailed:
+		return true
+	}
+	return false
+}
+
+// Approval is an action a user asked for that waits for an admin other than them to approve it.
+// Payload holds what is needed to carry the action out once approved.
+type Approval struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Action       string     `gorm:"column:action;size:30;not null;check:action IN ('patient_deletion', 'billing_discount', 'payroll_reopen', 'case_export');index:idx_approval_action_record,priority:1" json:"action"`
+	RecordID     string     `gorm:"column:record_id;size:50;index:idx_approval_action_record,priority:2" json:"record_id,omitempty"`
+	Summary      string     `gorm:"column:summary;type:text;not null" json:"summary"`
+	Payload      string     `gorm:"column:payload;type:text" json:"-"`
+	Status       string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'approved', 'rejected', 'cancelled', 'failed');index" json:"status"`
+	DecidedBy    *int64     `gorm:"column:decided_by" json:"decided_by,omitempty"`
+	DecidedAt    *time.Time `gorm:"column:decided_at" json:"decided_at,omitempty"`
+	DecisionNote string     `gorm:"column:decision_note" json:"decision_note,omitempty"`
+	Error        string     `gorm:"column:error" json:"error,omitempty"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	CreatedBy    *int64     `gorm:"column:created_by" json:"created_by"`
+}
+
+func (Approval) TableName() string {
+	return "approval"
+}
+
+func (a *Approval) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// ApprovalFilter narrows down the approvals listed
+type ApprovalFilter struct {
+	Status    string
+	Action    string
+	CreatedBy *int64
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnnotationRegion is a rectangle on an image, in fractions of its width and height so it
+// survives resizing and thumbnails
+type AnnotationRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Annotation marks a region of an attachment, such as a carious lesion on a bitewing
+type Annotation struct {
+	Label  string           `json:"label"`
+	Region AnnotationRegion `json:"region"`
+	Tooth  string           `json:"tooth,omitempty"`
+	Note   string           `json:"note,omitempty"`
+}
+
+// AnnotationLabelRedact labels a region, such as the patient's face or a name on a radiograph, that
+// is blacked out wherever the image is shared outside the practice
+const AnnotationLabelRedact = "redact"
+
+// Annotations are stored as a JSONB array
+type Annotations []Annotation
+
+func (a Annotations) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *Annotations) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*a = Annotations{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported annotations value")
+	}
+	return json.Unmarshal(data, a)
+}
+
+// ExaminationAttachment is a document or image, such as an x-ray, filed with an examination
+type ExaminationAttachment struct {
+	ID            uint        `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ExaminationID uint        `gorm:"column:examination_id;not null;index" json:"examination_id"`
+	FileName      string      `gorm:"column:file_name;size:255;not null" json:"file_name"`
+	ContentType   string      `gorm:"column:content_type;size:100;not null" json:"content_type"`
+	Size          int64       `gorm:"column:size;not null" json:"size"`
+	Content       []byte      `gorm:"column:content;type:bytea;not null" json:"-"`
+	StorageKey    string      `gorm:"column:storage_key" json:"-"` // Key of the file in object storage, in which case Content is empty
+	Annotations   Annotations `gorm:"column:annotations;type:jsonb;not null;default:'[]'" json:"annotations"`
+	CreatedAt     time.Time   `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time   `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy     *int64      `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy     *int64      `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (ExaminationAttachment) TableName() string {
+	return "examination_attachment"
+}
+
+func (a *ExaminationAttachment) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (a *ExaminationAttachment) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import "slices"
+
+// Where patients and appointments come from
+const (
+	SourceWalkIn   = "walk_in"
+	SourcePhone    = "phone"
+	SourceWebsite  = "website"
+	SourceReferral = "referral"
+	SourceCampaign = "campaign" // An advertising or marketing campaign, named by its campaign code
+	SourceOther    = "other"
+)
+
+// SourceUnknown names, in the marketing report, the patients and appointments recorded without a source
+const SourceUnknown = "unknown"
+
+// Sources lists where patients and appointments may come from
+var Sources = []string{SourceWalkIn, SourcePhone, SourceWebsite, SourceReferral, SourceCampaign, SourceOther}
+
+// IsValidSource reports whether source is one of Sources
+func IsValidSource(source string) bool {
+	return slices.Contains(Sources, source)
+}
+
+// Attribution is where a patient or appointment came from, for the marketing report. Its columns are
+// embedded in the patient and appointment tables.
+type Attribution struct {
+	Source       string `gorm:"column:source;size:20;not null;default:'';index" json:"source"`
+	CampaignCode string `gorm:"column:campaign_code;size:50;not null;default:'';index" json:"campaign_code,omitempty"` // Such as the code of a Google Ads campaign
+	ReferredBy   string `gorm:"column:referred_by;size:255;not null;default:''" json:"referred_by,omitempty"`          // Who referred a referral
+}
+
+// MarketingSource is what a source, or a campaign of it, brought in over the period of a marketing
+// report
+type MarketingSource struct {
+	Source       string `json:"source"`
+	CampaignCode string `json:"campaign_code,omitempty"`
+	// NewPatients are the patients registered in the period who came from the source
+	NewPatients int64 `json:"new_patients"`
+	// Bookings are the appointments booked in the period through the source, of which Fulfilled were
+	// seen and Cancelled were called off
+	Bookings  int64 `json:"bookings"`
+	Fulfilled int64 `json:"fulfilled"`
+	Cancelled int64 `json:"cancelled"`
+	// Revenue is what was billed in the period to patients who came from the source, and Collected
+	// what was paid of it
+	Revenue   float64 `json:"revenue"`
+	Collected float64 `json:"collected"`
+	// RevenuePerPatient is Revenue over the patients from the source billed in the period
+	BilledPatients    int64   `json:"billed_patients"`
+	RevenuePerPatient float64 `json:"revenue_per_patient"`
+	// ConversionRate is the share of Bookings that were fulfilled
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// MarketingReport is what each source and campaign brought in over a period, most revenue first
+type MarketingReport struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Sources []MarketingSource `json:"sources"`
+}
+
+// MarketingCount is a count or total of one source and campaign, as read for the marketing report
+type MarketingCount struct {
+	Source       string
+	CampaignCode string
+	Count        int64
+	Fulfilled    int64
+	Cancelled    int64
+	Amount       float64
+	Collected    float64
+}
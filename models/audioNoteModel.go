@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Transcription states of an audio note
+const (
+	TranscriptionPending      = "pending"      // Waiting for the provider, or for staff when there is none
+	TranscriptionTranscribing = "transcribing" // Being transcribed by the provider
+	TranscriptionCompleted    = "completed"
+	TranscriptionFailed       = "failed" // The provider gave up; staff transcribe it by hand
+)
+
+// TranscriptionStatuses lists the transcription states of an audio note
+var TranscriptionStatuses = []string{TranscriptionPending, TranscriptionTranscribing, TranscriptionCompleted, TranscriptionFailed}
+
+// ExaminationAudioNote is a short memo a dentist dictated on an examination, to be transcribed later
+// by the transcription provider or by staff
+type ExaminationAudioNote struct {
+	ID                  uint        `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ExaminationID       uint        `gorm:"column:examination_id;not null;index" json:"examination_id"`
+	Examination         Examination `gorm:"foreignKey:ExaminationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Title               string      `gorm:"column:title;size:255" json:"title,omitempty"`
+	FileName            string      `gorm:"column:file_name;size:255;not null" json:"file_name"`
+	ContentType         string      `gorm:"column:content_type;size:100;not null" json:"content_type"`
+	Size                int64       `gorm:"column:size;not null" json:"size"`
+	DurationSeconds     *int        `gorm:"column:duration_seconds" json:"duration_seconds,omitempty"` // As reported by the recorder
+	Content             []byte      `gorm:"column:content;type:bytea;not null" json:"-"`
+	RecordedAt          time.Time   `gorm:"column:recorded_at;not null" json:"recorded_at"`
+	TranscriptionStatus string      `gorm:"column:transcription_status;size:20;not null;default:pending;check:transcription_status IN ('pending', 'transcribing', 'completed', 'failed');index" json:"transcription_status"`
+	Transcript          string      `gorm:"column:transcript;type:text" json:"transcript,omitempty"`
+	TranscribedBy       string      `gorm:"column:transcribed_by;size:50" json:"transcribed_by,omitempty"` // The provider's name, or "staff"
+	TranscribedAt       *time.Time  `gorm:"column:transcribed_at" json:"transcribed_at,omitempty"`
+	TranscriptionError  string      `gorm:"column:transcription_error;type:text" json:"transcription_error,omitempty"`
+	Attempts            int         `gorm:"column:attempts;not null;default:0" json:"attempts"` // Tries by the provider
+	CreatedAt           time.Time   `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time   `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy           *int64      `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy           *int64      `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (ExaminationAudioNote) TableName() string {
+	return "examination_audio_note"
+}
+
+func (n *ExaminationAudioNote) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (n *ExaminationAudioNote) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// TranscriptionQueueItem is an audio note in the staff's transcription queue
+type TranscriptionQueueItem struct {
+	ID                  uint      `json:"id"`
+	ExaminationID       uint      `json:"examination_id"`
+	PatientID           string    `json:"patient_id"`
+	Title               string    `json:"title,omitempty"`
+	DurationSeconds     *int      `json:"duration_seconds,omitempty"`
+	RecordedAt          time.Time `json:"recorded_at"`
+	TranscriptionStatus string    `json:"transcription_status"`
+	TranscriptionError  string    `json:"transcription_error,omitempty"`
+	Attempts            int       `json:"attempts"`
+	CreatedBy           *int64    `json:"created_by"` // The dentist who dictated it
+}
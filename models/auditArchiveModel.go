@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditArchiveRecord is a line of the audit archive: an audit entry as it was shipped, chained to
+// the line before it. Hash is the SHA-256 of the previous line's hash, the sequence number and the
+// entry, so altering, removing or reordering a line breaks every hash after it.
+type AuditArchiveRecord struct {
+	Seq      int64           `json:"seq"`
+	Entry    json.RawMessage `json:"entry"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+// AuditArchiveBatch is a batch of audit entries appended to the archive, kept in an append-only
+// table. Hash is the archive's last hash once the batch was written, which the archive must
+// still reproduce.
+type AuditArchiveBatch struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	FirstAuditID int64     `gorm:"column:first_audit_id;not null" json:"first_audit_id"`
+	LastAuditID  int64     `gorm:"column:last_audit_id;not null;index" json:"last_audit_id"`
+	FirstSeq     int64     `gorm:"column:first_seq;not null" json:"first_seq"`
+	LastSeq      int64     `gorm:"column:last_seq;not null;uniqueIndex" json:"last_seq"`
+	Hash         string    `gorm:"column:hash;size:64;not null" json:"hash"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (AuditArchiveBatch) TableName() string {
+	return "audit_archive_batch"
+}
+
+// AuditArchiveStatus is how far the audit trail has been shipped to the archive
+type AuditArchiveStatus struct {
+	Enabled       bool       `json:"enabled"`
+	Batches       int64      `json:"batches"`
+	LastSeq       int64      `json:"last_seq"`
+	LastAuditID   int64      `json:"last_audit_id"`
+	Hash          string     `json:"hash,omitempty"`
+	LastShippedAt *time.Time `json:"last_shipped_at,omitempty"`
+	Pending       int64      `json:"pending"` // Audit entries not shipped yet
+}
+
+// AuditArchiveVerification is the result of checking the archive's hash chain against the batches
+// recorded in the database, and the archived entries against the audit trail
+type AuditArchiveVerification struct {
+	Valid          bool      `json:"valid"`
+	Records        int64     `json:"records"`
+	BrokenAtSeq    *int64    `json:"broken_at_seq,omitempty"`   // First line whose hash does not follow from the lines before it
+	MissingBatches []int64   `json:"missing_batches,omitempty"` // Last sequence numbers of recorded batches the archive no longer reproduces
+	AlteredEntries []int64   `json:"altered_entries,omitempty"` // Audit entries changed in the database since they were shipped
+	DeletedEntries []int64   `json:"deleted_entries,omitempty"` // Audit entries removed from the database since they were shipped
+	Truncated      bool      `json:"truncated,omitempty"`       // Whether more problems were found than are listed
+	VerifiedAt     time.Time `json:"verified_at"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// Audit categories
+const (
+	AuditCategorySecurity = "security"
+	AuditCategoryClinical = "clinical"
+	AuditCategoryActivity = "activity"
+)
+
+// Security audit events
+const (
+	AuditEventMissingBearerToken   = "missing_bearer_token"
+	AuditEventInvalidBearerToken   = "invalid_bearer_token"
+	AuditEventMissingAccessToken   = "missing_access_token"
+	AuditEventInvalidAccessToken   = "invalid_access_token"
+	AuditEventRoleMismatch         = "role_mismatch"
+	AuditEventAudienceMismatch     = "audience_mismatch"
+	AuditEventPermissionDenied     = "permission_denied"
+	AuditEventIPDenied             = "ip_denied"
+	AuditEventGeoBlocked           = "geo_blocked"
+	AuditEventCSRFRejected         = "csrf_rejected"
+	AuditEventInvalidKioskKey      = "invalid_kiosk_key"
+	AuditEventInvalidImagingKey    = "invalid_imaging_key"
+	AuditEventInvalidHL7Key        = "invalid_hl7_key"
+	AuditEventInvalidPrintAgentKey = "invalid_print_agent_key"
+	AuditEventInvalidReportToken   = "invalid_report_token"
+	AuditEventInvalidWebsiteKey    = "invalid_website_key"
+	AuditEventAuthRateLimited      = "auth_rate_limited"
+)
+
+// Security audit events of signing in and out, kept for access reviews
+const (
+	AuditEventLoginSucceeded = "login_succeeded"
+	AuditEventLoginFailed    = "login_failed"
+	AuditEventLogout         = "logout"
+)
+
+// Clinical audit events
+const (
+	AuditEventPrescriptionWarningsAcknowledged = "prescription_warnings_acknowledged"
+	AuditEventBreakGlassAccess                 = "break_glass_access"
+)
+
+// Activity audit events, recorded from the domain events on the event bus
+const (
+	AuditEventPatientDeleted       = "patient_deleted"
+	AuditEventPaymentRecorded      = "payment_recorded"
+	AuditEventAppointmentCancelled = "appointment_cancelled"
+)
+
+// Activity audit events of bookings made over a warning
+const (
+	AuditEventPatientConflictOverridden = "patient_conflict_overridden"
+)
+
+// Activity audit events of actions waiting for approval
+const (
+	AuditEventApprovalRequested = "approval_requested"
+	AuditEventApprovalApproved  = "approval_approved"
+	AuditEventApprovalRejected  = "approval_rejected"
+	AuditEventApprovalCancelled = "approval_cancelled"
+	AuditEventApprovalFailed    = "approval_failed"
+)
+
+// AuditLog is an entry in the audit trail
+type AuditLog struct {
+	ID        int64     `gorm:"primaryKey;column:id" json:"id"`
+	Category  string    `gorm:"size:50;not null;column:category;index:idx_audit_category_created,priority:1" json:"category"`
+	Event     string    `gorm:"size:100;not null;index;column:event" json:"event"`
+	UserID    string    `gorm:"size:100;index;column:user_id" json:"user_id,omitempty"`
+	Role      string    `gorm:"size:50;column:role" json:"role,omitempty"`
+	IP        string    `gorm:"size:64;index;column:ip" json:"ip"`
+	Method    string    `gorm:"size:10;column:method" json:"method"`
+	Route     string    `gorm:"size:255;column:route" json:"route"`
+	Status    int       `gorm:"column:status" json:"status"`
+	Detail    string    `gorm:"type:text;column:detail" json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime;column:created_at;index:idx_audit_category_created,priority:2" json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditFilter narrows down audit log queries
+type AuditFilter struct {
+	Category      string
+	Event         string
+	Events        []string // Any of these events
+	ExcludeEvents []string
+	UserID        string
+	IP            string
+	From          *time.Time
+	To            *time.Time
+	Limit         int
+	Offset        int
+}
@@ -0,0 +1,28 @@
+package models
+
+// AuthPolicy is an entry of the route authorization policy table: what a request to the routes it
+// covers must carry. Route is a gin route such as /patients/:patient_id, or a prefix ending in /*
+// covering the route it names and every route under it. Requests need an access token issued to
+// one of Audiences, the staff application when none are named, held by a user of one of Roles, any
+// role when none are named, whose role grants every permission in Permissions.
+type AuthPolicy struct {
+	Route   string   `json:"route"`
+	Methods []string `json:"methods,omitempty"` // Every method when empty
+	// Anonymous routes need no access token: they are reached with the application's bearer token
+	// alone, or authenticate their callers with keys or signed links of their own
+	Anonymous   bool     `json:"anonymous,omitempty"`
+	Audiences   []string `json:"audiences,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// RoutePolicy is the policy enforced on a registered route, and the policy table entry it comes from
+type RoutePolicy struct {
+	Method      string   `json:"method"`
+	Route       string   `json:"route"`
+	Policy      string   `json:"policy"` // Route of the policy table entry matched
+	Anonymous   bool     `json:"anonymous"`
+	Audiences   []string `json:"audiences,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Who raised a billing dispute
+const (
+	DisputeRaisedByPatient = "patient"
+	DisputeRaisedByInsurer = "insurer"
+)
+
+// Billing dispute statuses. Open and under review disputes hold back reminders about the bill.
+const (
+	DisputeStatusOpen        = "open"
+	DisputeStatusUnderReview = "under_review"
+	DisputeStatusResolved    = "resolved"
+	DisputeStatusRejected    = "rejected"
+)
+
+// OpenDisputeStatuses are the statuses of disputes still being worked on
+var OpenDisputeStatuses = []string{DisputeStatusOpen, DisputeStatusUnderReview}
+
+// IsValidDisputeStatus reports whether status is one of the billing dispute statuses
+func IsValidDisputeStatus(status string) bool {
+	switch status {
+	case DisputeStatusOpen, DisputeStatusUnderReview, DisputeStatusResolved, DisputeStatusRejected:
+		return true
+	}
+	return false
+}
+
+// IsOpenDisputeStatus reports whether a dispute in status is still being worked on
+func IsOpenDisputeStatus(status string) bool {
+	return status == DisputeStatusOpen || status == DisputeStatusUnderReview
+}
+
+// BillingDispute is a query about a bill raised by the patient or their insurer. Corrections made
+// to the bill for it are the adjustments linked to it.
+type BillingDispute struct {
+	ID             uint                `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID      string              `gorm:"column:billing_id;not null;index" json:"billing_id"`
+	RaisedBy       string              `gorm:"column:raised_by;size:20;not null;check:raised_by IN ('patient', 'insurer')" json:"raised_by"`
+	Reference      string              `gorm:"column:reference;size:100" json:"reference,omitempty"`
+	Reason         string              `gorm:"column:reason;type:text;not null" json:"reason"`
+	Amount         *float64            `gorm:"column:amount" json:"amount,omitempty"`
+	Status         string              `gorm:"column:status;size:20;not null;default:open;check:status IN ('open', 'under_review', 'resolved', 'rejected');index" json:"status"`
+	ResolutionNote string              `gorm:"column:resolution_note;type:text" json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time          `gorm:"column:resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt      time.Time           `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time           `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy      *int64              `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy      *int64              `gorm:"column:updated_by" json:"updated_by"`
+	Adjustments    []BillingAdjustment `gorm:"-" json:"adjustments"`
+}
+
+func (BillingDispute) TableName() string {
+	return "billing_dispute"
+}
+
+func (d *BillingDispute) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (d *BillingDispute) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// BillingDisputeUpdate moves a dispute along; resolving or rejecting it needs a resolution note
+type BillingDisputeUpdate struct {
+	Status         string `json:"status" binding:"required"`
+	ResolutionNote string `json:"resolution_note"`
+}
+
+// OpenDispute is a dispute still being worked on, with the bill and patient it concerns
+type OpenDispute struct {
+	BillingDispute
+	PatientID   string  `json:"patient_id"`
+	PatientName string  `json:"patient_name"`
+	Balance     float64 `json:"balance"`
+	AgeDays     int     `json:"age_days"`
+}
+
+// OpenDisputeReport counts the disputes still being worked on and the money they hold up
+type OpenDisputeReport struct {
+	Count          int            `json:"count"`
+	ByRaisedBy     map[string]int `json:"by_raised_by"`
+	DisputedAmount float64        `json:"disputed_amount"`
+	Balance        float64        `json:"balance"`
+	Disputes       []OpenDispute  `json:"disputes"`
+}
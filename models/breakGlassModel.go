@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// BreakGlassAccess lets a clinician into the record of a patient they would otherwise be kept
+// out of, such as a colleague's patient in an emergency, until ExpiresAt. The reason is mandatory
+// and every access is audited, sent to the admins and kept for them to review.
+type BreakGlassAccess struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID  string     `gorm:"column:patient_id;not null;index:idx_break_glass_user_patient,priority:2" json:"patient_id"`
+	UserID     int64      `gorm:"column:user_id;not null;index:idx_break_glass_user_patient,priority:1" json:"user_id"`
+	Role       string     `gorm:"column:role;size:50" json:"role,omitempty"`
+	Reason     string     `gorm:"column:reason;type:text;not null" json:"reason"`
+	IP         string     `gorm:"column:ip;size:64" json:"ip"`
+	GrantedAt  time.Time  `gorm:"column:granted_at;not null;index" json:"granted_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at;index" json:"reviewed_at,omitempty"`
+	ReviewedBy *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewNote string     `gorm:"column:review_note;type:text" json:"review_note,omitempty"`
+	Patient    *Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (BreakGlassAccess) TableName() string {
+	return "break_glass_access"
+}
+
+// BreakGlassRequest asks for emergency access to a patient's record
+type BreakGlassRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// BreakGlassReview records that an admin looked into an emergency access
+type BreakGlassReview struct {
+	Note string `json:"note"`
+}
+
+// BreakGlassFilter narrows down the emergency accesses listed
+type BreakGlassFilter struct {
+	PatientID  string
+	UserID     *int64
+	Unreviewed bool
+	Limit      int
+}
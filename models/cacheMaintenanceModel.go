@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// RedisKey is a key kept in Redis as the cache maintenance sees it
+type RedisKey struct {
+	Key string
+	// TTL is how long the key has left, or NoExpiry for a key that never expires
+	TTL time.Duration
+	// Idle is how long since the key was last read or written, 0 when Redis evicts by frequency
+	// and does not keep it
+	Idle  time.Duration
+	Bytes int64 // Memory the key takes up, only looked up when asked for
+}
+
+// NoExpiry is the TTL of Redis keys left without an expiry
+const NoExpiry = time.Duration(-1)
+
+// CacheMaintenanceRun is what a run of the cache maintenance cleaned up: the keys it deleted of
+// each kind, and the first error that stopped a kind from being cleaned
+type CacheMaintenanceRun struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Pruned     map[string]int `json:"pruned"`
+	Error      string         `json:"error,omitempty"`
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Campaign statuses
+const (
+	CampaignDraft     = "draft"
+	CampaignScheduled = "scheduled"
+	CampaignSending   = "sending"
+	CampaignSent      = "sent"
+	CampaignCancelled = "cancelled"
+)
+
+// Campaign is a message sent in bulk to a segment of patients: those the patients list returns for
+// Criteria. A campaign built from a saved filter of the patients list keeps its criteria and notes
+// SavedFilterID, so periods such as "registered in the last 90 days" are still taken from the day
+// it is sent. Subject and Body may name the patient with {first_name} and {last_name} and the
+// clinic with {clinic_name}. Marketing campaigns only reach patients who opted in to marketing.
+type Campaign struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name          string         `gorm:"column:name;size:100;not null" json:"name"`
+	Channel       string         `gorm:"column:channel;size:10;not null;check:channel IN ('email', 'sms')" json:"channel"`
+	Purpose       string         `gorm:"column:purpose;size:20;not null;default:marketing;check:purpose IN ('marketing', 'service')" json:"purpose"`
+	Subject       string         `gorm:"column:subject" json:"subject"`
+	Body          string         `gorm:"column:body;type:text;not null" json:"body"`
+	Criteria      FilterCriteria `gorm:"column:criteria;type:jsonb;not null;default:'[]'" json:"criteria"`
+	SavedFilterID *uint          `gorm:"column:saved_filter_id" json:"saved_filter_id,omitempty"`
+	Status        string         `gorm:"column:status;size:20;not null;default:draft;index;check:status IN ('draft', 'scheduled', 'sending', 'sent', 'cancelled')" json:"status"`
+	ScheduledAt   *time.Time     `gorm:"column:scheduled_at" json:"scheduled_at,omitempty"`
+	StartedAt     *time.Time     `gorm:"column:started_at" json:"started_at,omitempty"`
+	FinishedAt    *time.Time     `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	CreatedAt     time.Time      `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy     *int64         `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy     *int64         `gorm:"column:updated_by" json:"updated_by"`
+
+	Recipients []CampaignRecipient `gorm:"foreignKey:CampaignID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Campaign) TableName() string {
+	return "campaign"
+}
+
+func (c *Campaign) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (c *Campaign) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Fill returns text with the campaign's placeholders replaced for the patient
+func (c Campaign) Fill(text, firstName, lastName, clinicName string) string {
+	return strings.NewReplacer(
+		"{first_name}", firstName,
+		"{last_name}", lastName,
+		"{clinic_name}", clinicName,
+	).Replace(text)
+}
+
+// Campaign recipient statuses. Sending marks recipients a replica claimed, so no patient is sent a
+// campaign twice; the others are the outcome of the message, as in the communication log.
+const (
+	CampaignRecipientPending = "pending"
+	CampaignRecipientSending = "sending"
+)
+
+// CampaignRecipient is a patient a campaign is sent to, taken from its segment when it starts
+// sending. Recipient is the address or number the message goes to.
+type CampaignRecipient struct {
+	CampaignID uint       `gorm:"primaryKey;autoIncrement:false;column:campaign_id" json:"campaign_id"`
+	PatientID  string     `gorm:"primaryKey;column:patient_id" json:"patient_id"`
+	Recipient  string     `gorm:"column:recipient;not null" json:"recipient"`
+	Status     string     `gorm:"column:status;size:20;not null;default:pending;index;check:status IN ('pending', 'sending', 'sent', 'deferred', 'not_permitted', 'failed')" json:"status"`
+	Error      string     `gorm:"column:error" json:"error,omitempty"`
+	MessageID  string     `gorm:"column:message_id" json:"message_id,omitempty"`
+	SentAt     *time.Time `gorm:"column:sent_at" json:"sent_at,omitempty"`
+	Patient    *Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CampaignRecipient) TableName() string {
+	return "campaign_recipient"
+}
+
+// CampaignSchedule asks for a campaign to be sent at SendAt, straight away when it is not set
+type CampaignSchedule struct {
+	SendAt *time.Time `json:"send_at"`
+}
+
+// CampaignAudienceMember is a patient of a campaign's segment
+type CampaignAudienceMember struct {
+	PatientID string `json:"patient_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Recipient string `json:"recipient"`
+}
+
+// CampaignPreviewMessage is the message a patient of the segment would be sent
+type CampaignPreviewMessage struct {
+	PatientID string `json:"patient_id"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject,omitempty"`
+	Body      string `json:"body"`
+}
+
+// CampaignPreview is the audience a campaign would reach if it were sent now: the patients of its
+// segment, those with an address or number on the campaign's channel, and those of them whose
+// preferences allow it. Sample shows the messages the first of them would be sent.
+type CampaignPreview struct {
+	Audience  int64                    `json:"audience"`
+	Reachable int64                    `json:"reachable"`
+	Permitted int64                    `json:"permitted"`
+	Sample    []CampaignPreviewMessage `json:"sample"`
+}
+
+// CampaignReport is how a campaign's messages fared. Bounced and Complained come from the mail
+// provider's reports, and OptedOut counts recipients who withdrew their consent to the campaign's
+// purpose or channel after it started sending.
+type CampaignReport struct {
+	Campaign     Campaign `json:"campaign"`
+	Recipients   int64    `json:"recipients"`
+	Pending      int64    `json:"pending"`
+	Sent         int64    `json:"sent"`
+	Deferred     int64    `json:"deferred"`
+	NotPermitted int64    `json:"not_permitted"`
+	Failed       int64    `json:"failed"`
+	Bounced      int64    `json:"bounced"`
+	Complained   int64    `json:"complained"`
+	OptedOut     int64    `json:"opted_out"`
+}
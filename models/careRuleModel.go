@@ -0,0 +1,136 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Events a care rule can be triggered by, named as on the event bus
+const (
+	CareEventBillingCreated       = "billing.created"
+	CareEventAppointmentFulfilled = "appointment.fulfilled"
+)
+
+// IsValidCareEvent reports whether event is one a care rule can be triggered by
+func IsValidCareEvent(event string) bool {
+	switch event {
+	case CareEventBillingCreated, CareEventAppointmentFulfilled:
+		return true
+	}
+	return false
+}
+
+// Actions a care rule takes
+const (
+	CareActionCreateTask       = "create_task"       // Assign a staff member a task about the patient
+	CareActionSendInstructions = "send_instructions" // Email the patient instructions
+	CareActionCreateRecall     = "create_recall"     // Put the patient on the recall list
+)
+
+// IsValidCareAction reports whether action is one a care rule can take
+func IsValidCareAction(action string) bool {
+	switch action {
+	case CareActionCreateTask, CareActionSendInstructions, CareActionCreateRecall:
+		return true
+	}
+	return false
+}
+
+// CareRule takes an action when an event happens to a patient's bill or appointment, limited to
+// one procedure when ProcedureID is set: e.g. a review task after an extraction is billed, or a
+// six-month recall once a scaling is done. DelayDays after the event the task or recall is due.
+// Title names the task or recall; Subject and Body are the email sent, where {first_name},
+// {last_name} and {procedure} are replaced with the patient's names and the procedure.
+type CareRule struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name        string     `gorm:"column:name;size:255;not null" json:"name"`
+	Event       string     `gorm:"column:event;size:50;not null;index" json:"event"`
+	ProcedureID *uint      `gorm:"column:procedure_id;index" json:"procedure_id,omitempty"`
+	Action      string     `gorm:"column:action;size:30;not null;check:action IN ('create_task', 'send_instructions', 'create_recall')" json:"action"`
+	Active      bool       `gorm:"column:active;not null;default:true" json:"active"`
+	DelayDays   int        `gorm:"column:delay_days;not null;default:0;check:delay_days >= 0" json:"delay_days"`
+	AssigneeID  *int64     `gorm:"column:assignee_id" json:"assignee_id,omitempty"`
+	Title       string     `gorm:"column:title;size:255" json:"title,omitempty"`
+	Subject     string     `gorm:"column:subject;size:255" json:"subject,omitempty"`
+	Body        string     `gorm:"column:body;type:text" json:"body,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy   *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy   *int64     `gorm:"column:updated_by" json:"updated_by"`
+	Procedure   *Procedure `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CareRule) TableName() string {
+	return "care_rule"
+}
+
+func (r *CareRule) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (r *CareRule) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// CareSubject is the patient and procedure of the bill or appointment a care rule is run for
+type CareSubject struct {
+	PatientID        string
+	PatientFirstName string
+	PatientLastName  string
+	PatientEmail     string
+	PatientPhone     string
+	PatientLanguage  string
+	ProcedureID      *uint
+	Procedure        string
+}
+
+// Fill replaces the placeholders of a care rule's text with the subject's names and procedure
+func (s CareSubject) Fill(text string) string {
+	return strings.NewReplacer("{first_name}", s.PatientFirstName, "{last_name}", s.PatientLastName, "{procedure}", s.Procedure).Replace(text)
+}
+
+// Recall statuses
+const (
+	RecallStatusDue       = "due"
+	RecallStatusBooked    = "booked"
+	RecallStatusDismissed = "dismissed"
+)
+
+// IsValidRecallStatus reports whether status is one of the recall statuses
+func IsValidRecallStatus(status string) bool {
+	switch status {
+	case RecallStatusDue, RecallStatusBooked, RecallStatusDismissed:
+		return true
+	}
+	return false
+}
+
+// Recall is a patient the desk should ask back from DueDate, e.g. for a check-up after a scaling
+type Recall struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID   string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	ProcedureID *uint     `gorm:"column:procedure_id" json:"procedure_id,omitempty"`
+	RuleID      *uint     `gorm:"column:rule_id;index" json:"rule_id,omitempty"`
+	DueDate     time.Time `gorm:"column:due_date;type:date;not null;index" json:"due_date"`
+	Reason      string    `gorm:"column:reason;size:255;not null" json:"reason"`
+	Status      string    `gorm:"column:status;size:20;not null;default:due;check:status IN ('due', 'booked', 'dismissed')" json:"status"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	Patient     *Patient  `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Rule        *CareRule `gorm:"foreignKey:RuleID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (Recall) TableName() string {
+	return "recall"
+}
+
+// DueRecall is a recall on the desk's list, with whom to call
+type DueRecall struct {
+	Recall
+	PatientName  string `json:"patient_name"`
+	PatientPhone string `json:"patient_phone"`
+}
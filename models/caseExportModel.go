@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// CaseExportRequest asks for a treatment plan to be exported as an anonymized case for study clubs
+// and teaching
+type CaseExportRequest struct {
+	IncludeCosts bool `json:"include_costs"`
+}
+
+// CasePresentation is the anonymized case put in the package as case.json. Nothing in it names
+// the patient: dates are given in days from the plan and the age as a band.
+type CasePresentation struct {
+	Reference     string         `json:"reference"`
+	Sex           string         `json:"sex,omitempty"`
+	AgeBand       string         `json:"age_band"`
+	Findings      []CaseFinding  `json:"findings"`
+	Plan          string         `json:"plan"`
+	Images        []CaseImageSet `json:"images"`
+	EstimatedCost *float64       `json:"estimated_cost,omitempty"`
+	ExportedAt    time.Time      `json:"exported_at"`
+}
+
+// CaseFinding is the report of an examination an image of the case was filed with
+type CaseFinding struct {
+	Day    int    `json:"day"` // Days from the plan, negative before it
+	Report string `json:"report"`
+}
+
+// CaseImageSet is a before and after pair in the package, by the names of its image files
+type CaseImageSet struct {
+	Item   string `json:"item"`
+	Tooth  string `json:"tooth,omitempty"`
+	Notes  string `json:"notes,omitempty"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
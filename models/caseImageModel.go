@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Uses of case images a patient can consent to
+const (
+	ImageConsentMarketing    = "marketing"    // The practice's website, social media and advertising
+	ImageConsentPresentation = "presentation" // Case presentations to other patients at the practice
+	ImageConsentTeaching     = "teaching"     // Anonymized cases shared with study clubs and for teaching
+)
+
+// IsValidImageConsent reports whether consent is a use case images are filtered by
+func IsValidImageConsent(consent string) bool {
+	return consent == ImageConsentMarketing || consent == ImageConsentPresentation || consent == ImageConsentTeaching
+}
+
+// CaseImagePair pairs an image taken before an item of a treatment plan with one taken after it,
+// both filed with the patient's examinations. The pair is only shown outside the patient's record
+// for the uses the patient consented to.
+type CaseImagePair struct {
+	ID                  uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID           string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	TreatmentPlanID     uint       `gorm:"column:treatment_plan_id;not null;index" json:"treatment_plan_id"`
+	Item                string     `gorm:"column:item;size:255;not null" json:"item"` // The treatment shown, e.g. "Veneers 11-21"
+	Tooth               string     `gorm:"column:tooth;size:20" json:"tooth,omitempty"`
+	BeforeAttachmentID  uint       `gorm:"column:before_attachment_id;not null;index" json:"before_attachment_id"`
+	AfterAttachmentID   uint       `gorm:"column:after_attachment_id;not null;index" json:"after_attachment_id"`
+	Notes               string     `gorm:"column:notes" json:"notes,omitempty"`
+	MarketingConsent    bool       `gorm:"column:marketing_consent;not null;default:false" json:"marketing_consent"`
+	PresentationConsent bool       `gorm:"column:presentation_consent;not null;default:false" json:"presentation_consent"`
+	TeachingConsent     bool       `gorm:"column:teaching_consent;not null;default:false" json:"teaching_consent"`
+	ConsentRecordedAt   *time.Time `gorm:"column:consent_recorded_at" json:"consent_recorded_at,omitempty"`
+	ConsentRecordedBy   *int64     `gorm:"column:consent_recorded_by" json:"consent_recorded_by,omitempty"`
+	CreatedAt           time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy           *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy           *int64     `gorm:"column:updated_by" json:"updated_by"`
+
+	Patient          *Patient               `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	TreatmentPlan    *TreatmentPlan         `gorm:"foreignKey:TreatmentPlanID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	BeforeAttachment *ExaminationAttachment `gorm:"foreignKey:BeforeAttachmentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	AfterAttachment  *ExaminationAttachment `gorm:"foreignKey:AfterAttachmentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CaseImagePair) TableName() string {
+	return "case_image_pair"
+}
+
+func (p *CaseImagePair) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (p *CaseImagePair) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// CaseImageConsent is what a patient agreed their case images may be used for
+type CaseImageConsent struct {
+	Marketing    bool `json:"marketing"`
+	Presentation bool `json:"presentation"`
+	Teaching     bool `json:"teaching"`
+}
+
+// GalleryCase is a consented pair as the gallery shows it: the treatment and the images, without
+// who the patient is
+type GalleryCase struct {
+	ID                  uint      `json:"id"`
+	Item                string    `json:"item"`
+	Tooth               string    `json:"tooth,omitempty"`
+	Notes               string    `json:"notes,omitempty"`
+	MarketingConsent    bool      `json:"marketing_consent"`
+	PresentationConsent bool      `json:"presentation_consent"`
+	TeachingConsent     bool      `json:"teaching_consent"`
+	CreatedAt           time.Time `json:"created_at"`
+}
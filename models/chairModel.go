@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Room is a treatment room holding one or more dental chairs
+type Room struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name      string    `gorm:"column:name;size:100;not null;unique" json:"name"`
+	Notes     string    `gorm:"column:notes;type:text" json:"notes,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Chairs    []Chair   `gorm:"foreignKey:RoomID;references:ID;constraint:OnDelete:CASCADE" json:"chairs,omitempty"`
+}
+
+func (Room) TableName() string {
+	return "room"
+}
+
+func (r *Room) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (r *Room) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Chair is a dental chair appointments are assigned to; only active chairs take bookings
+type Chair struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	RoomID    uint      `gorm:"column:room_id;not null;uniqueIndex:idx_chair_room_name,priority:1" json:"room_id"`
+	Name      string    `gorm:"column:name;size:100;not null;uniqueIndex:idx_chair_room_name,priority:2" json:"name"`
+	Active    bool      `gorm:"column:active;not null;default:true;index" json:"active"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (Chair) TableName() string {
+	return "chair"
+}
+
+func (c *Chair) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (c *Chair) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Booking is the time a non-cancelled appointment takes up a doctor and, once assigned, a chair
+type Booking struct {
+	AppointmentID uint      `json:"appointment_id"`
+	DoctorID      string    `json:"doctor_id"`
+	ChairID       *uint     `json:"chair_id,omitempty"`
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	Overbooked    bool      `json:"overbooked"`
+}
+
+// AvailableSlot is a slot in which the doctor is free and a chair can be had. Overbook marks slots
+// in which the doctor is busy but a short appointment may still be booked over theirs.
+type AvailableSlot struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	FreeChairs int       `json:"free_chairs"`
+	ChairIDs   []uint    `json:"chair_ids"`
+	Overbook   bool      `json:"overbook,omitempty"`
+}
+
+// Downtime is a chair or piece of equipment out of service from StartsAt until EndsAt, or until
+// further notice while EndsAt is not set. A chair takes no bookings while it is down.
+type Downtime struct {
+	ID                   uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ChairID              *uint      `gorm:"column:chair_id;index" json:"chair_id,omitempty"`
+	Equipment            string     `gorm:"column:equipment;size:100;index" json:"equipment,omitempty"` // Equipment that is down other than the chair itself, e.g. "autoclave"
+	Reason               string     `gorm:"column:reason;not null" json:"reason"`
+	StartsAt             time.Time  `gorm:"column:starts_at;not null;index" json:"starts_at"`
+	EndsAt               *time.Time `gorm:"column:ends_at;index" json:"ends_at"`
+	Notes                string     `gorm:"column:notes;type:text" json:"notes,omitempty"`
+	CreatedAt            time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy            *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy            *int64     `gorm:"column:updated_by" json:"updated_by"`
+	AffectedAppointments []uint     `gorm:"-" json:"affected_appointments,omitempty"` // Appointments already booked on the chair while it is down
+	Chair                *Chair     `gorm:"foreignKey:ChairID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Downtime) TableName() string {
+	return "downtime"
+}
+
+func (d *Downtime) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (d *Downtime) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Down reports whether the downtime takes its chair out of service for any of start to end
+func (d Downtime) Down(start, end time.Time) bool {
+	return d.ChairID != nil && d.StartsAt.Before(end) && (d.EndsAt == nil || d.EndsAt.After(start))
+}
+
+// DowntimeFilter narrows a downtime listing to a chair, equipment or the downtime overlapping From to To
+type DowntimeFilter struct {
+	ChairID   *uint
+	Equipment string
+	From      *time.Time
+	To        *time.Time
+}
+
+// DowntimeHours is the time a chair or piece of equipment was out of service in a month
+type DowntimeHours struct {
+	Month     string  `json:"month"`
+	ChairID   *uint   `json:"chair_id,omitempty"`
+	ChairName string  `json:"chair_name,omitempty"`
+	Equipment string  `json:"equipment,omitempty"`
+	Incidents int     `json:"incidents"`
+	Hours     float64 `json:"hours"`
+}
+
+// DowntimeReport is the downtime of each chair and piece of equipment month by month
+type DowntimeReport struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	TotalHours float64         `json:"total_hours"`
+	Months     []DowntimeHours `json:"months"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Change actions
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// DeletedRecord is a tombstone written by a database trigger whenever a tracked record is deleted,
+// so sync clients learn about deletions too
+type DeletedRecord struct {
+	ID        int64     `gorm:"primaryKey;column:id" json:"-"`
+	Entity    string    `gorm:"column:entity;size:50;not null;index:idx_deleted_record_entity_deleted,priority:1" json:"entity"`
+	RecordID  string    `gorm:"column:record_id;size:100;not null" json:"record_id"`
+	DeletedAt time.Time `gorm:"column:deleted_at;not null;default:now();index:idx_deleted_record_entity_deleted,priority:2" json:"deleted_at"`
+}
+
+func (DeletedRecord) TableName() string {
+	return "deleted_record"
+}
+
+// ChangeRecord references a record created, updated or deleted since a sync cursor
+type ChangeRecord struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Insurance claim statuses
+const (
+	ClaimStatusPending   = "pending"
+	ClaimStatusApproved  = "approved"
+	ClaimStatusSubmitted = "submitted"
+	ClaimStatusPaid      = "paid"
+	ClaimStatusRejected  = "rejected"
+)
+
+// Claim batch statuses, in lifecycle order; a batch ends settled or rejected
+const (
+	ClaimBatchStatusSubmitted    = "submitted"
+	ClaimBatchStatusAcknowledged = "acknowledged"
+	ClaimBatchStatusSettled      = "settled"
+	ClaimBatchStatusRejected     = "rejected"
+)
+
+// Claim submission formats
+const (
+	ClaimFormatCSV = "csv"
+	ClaimFormatXML = "xml"
+)
+
+// IsValidClaimFormat reports whether format is one of the claim submission formats
+func IsValidClaimFormat(format string) bool {
+	return format == ClaimFormatCSV || format == ClaimFormatXML
+}
+
+// InsuranceClaim is the part of a bill claimed from the patient's insurer
+type InsuranceClaim struct {
+	ID                 uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID          string     `gorm:"column:billing_id;not null;uniqueIndex" json:"billing_id"`
+	PatientID          string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	InsuranceCompanyID string     `gorm:"column:insurance_company_id;not null;index:idx_claim_insurer_status,priority:1" json:"insurance_company_id"`
+	Amount             float64    `gorm:"column:amount;not null" json:"amount"`
+	Status             string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'approved', 'submitted', 'paid', 'rejected');index:idx_claim_insurer_status,priority:2" json:"status"`
+	BatchID            *uint      `gorm:"column:batch_id;index" json:"batch_id,omitempty"`
+	ServiceDate        time.Time  `gorm:"column:service_date;type:date;not null" json:"service_date"`
+	ApprovedAt         *time.Time `gorm:"column:approved_at" json:"approved_at,omitempty"`
+	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy          *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy          *int64     `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (InsuranceClaim) TableName() string {
+	return "insurance_claim"
+}
+
+func (c *InsuranceClaim) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (c *InsuranceClaim) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ClaimBatch is a submission file bundling an insurer's approved claims for a period
+type ClaimBatch struct {
+	ID                 uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Reference          string     `gorm:"column:reference;size:100;not null;uniqueIndex" json:"reference"`
+	InsuranceCompanyID string     `gorm:"column:insurance_company_id;not null;index" json:"insurance_company_id"`
+	From               time.Time  `gorm:"column:from_date;type:date;not null" json:"from"`
+	To                 time.Time  `gorm:"column:to_date;type:date;not null" json:"to"`
+	Format             string     `gorm:"column:format;size:10;not null" json:"format"`
+	Status             string     `gorm:"column:status;size:20;not null;default:submitted;check:status IN ('submitted', 'acknowledged', 'settled', 'rejected');index" json:"status"`
+	ClaimCount         int        `gorm:"column:claim_count;not null" json:"claim_count"`
+	TotalAmount        float64    `gorm:"column:total_amount;not null" json:"total_amount"`
+	Note               string     `gorm:"column:note;type:text" json:"note,omitempty"`
+	FileName           string     `gorm:"column:file_name;size:255;not null" json:"file_name"`
+	ContentType        string     `gorm:"column:content_type;size:100;not null" json:"-"`
+	Content            []byte     `gorm:"column:content;type:bytea" json:"-"`
+	SubmittedAt        time.Time  `gorm:"column:submitted_at;not null" json:"submitted_at"`
+	AcknowledgedAt     *time.Time `gorm:"column:acknowledged_at" json:"acknowledged_at,omitempty"`
+	ClosedAt           *time.Time `gorm:"column:closed_at" json:"closed_at,omitempty"`
+	CreatedBy          *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy          *int64     `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (ClaimBatch) TableName() string {
+	return "claim_batch"
+}
+
+func (b *ClaimBatch) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (b *ClaimBatch) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ClaimLine is a claim as written to a submission file
+type ClaimLine struct {
+	ClaimID     uint      `json:"claim_id"`
+	BillingID   string    `json:"billing_id"`
+	PatientID   string    `json:"patient_id"`
+	PatientName string    `json:"patient_name"`
+	DateOfBirth string    `json:"date_of_birth"`
+	Scheme      string    `json:"scheme"`
+	DoctorID    string    `json:"doctor_id"`
+	Procedure   string    `json:"procedure"`
+	ServiceDate time.Time `json:"service_date"`
+	Amount      float64   `json:"amount"`
+}
+
+// ClaimFilter narrows down claim lists
+type ClaimFilter struct {
+	Status             string
+	InsuranceCompanyID string
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"RoyDental/clock"
+	"sync/atomic"
+	"time"
+)
+
+// clinicLocation is the time zone the clinic keeps its days and opening hours in. Until it is set,
+// the server's own time zone is used.
+var clinicLocation atomic.Pointer[time.Location]
+
+// appointmentTimeLayouts are the formats appointment date_time values are accepted in. Times without
+// an offset are read as clinic time.
+var appointmentTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// SetClinicLocation sets the clinic's time zone
+func SetClinicLocation(loc *time.Location) {
+	clinicLocation.Store(loc)
+}
+
+// ClinicLocation returns the clinic's time zone
+func ClinicLocation() *time.Location {
+	if loc := clinicLocation.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// ClinicNow returns the current time on the process clock in the clinic's time zone
+func ClinicNow() time.Time {
+	return clock.Now().In(ClinicLocation())
+}
+
+// ClinicDay returns when the clinic day t falls on starts and when the next one starts. Days are not
+// assumed to be 24 hours long, so the bounds stay right across daylight saving changes.
+func ClinicDay(t time.Time) (time.Time, time.Time) {
+	t = t.In(ClinicLocation())
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// ParseClinicDate reads a YYYY-MM-DD date as the start of that day in clinic time
+func ParseClinicDate(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, ClinicLocation())
+}
+
+// ParseAppointmentTime reads an appointment date_time, taking a time without an offset as clinic time
+func ParseAppointmentTime(value string) (time.Time, bool) {
+	for _, layout := range appointmentTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, ClinicLocation()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// StoredAppointmentTime formats t as appointment date_time values are stored, in UTC
+func StoredAppointmentTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ClinicDateTime formats a stored date_time in clinic time with its offset. Values that cannot be
+// read as a time, such as free text entered before times were checked, are returned as they are.
+func ClinicDateTime(value string) string {
+	t, ok := ParseAppointmentTime(value)
+	if !ok {
+		return value
+	}
+	return t.In(ClinicLocation()).Format(time.RFC3339)
+}
+
+// inClinicTime converts t, if set, to clinic time for display
+func inClinicTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(ClinicLocation())
+	return &local
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Clinical audit scores range from 1, care well below the practice's standard, to 5, exemplary
+// care. Scores up to ClinicalAuditBelowStandard count as below standard in the trends.
+const (
+	MinClinicalAuditScore      = 1
+	MaxClinicalAuditScore      = 5
+	ClinicalAuditBelowStandard = 2
+)
+
+// ClinicalAudit is a random sample of the visits fulfilled in a period, drawn for the practice's
+// internal quality program, of one doctor's visits when DoctorID is set. Dates are clinic days as
+// YYYY-MM-DD, both included. Each sampled visit is scored by an auditor.
+type ClinicalAudit struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name       string    `gorm:"column:name;size:100;not null" json:"name"`
+	From       string    `gorm:"column:period_from;size:10;not null" json:"from"`
+	To         string    `gorm:"column:period_to;size:10;not null" json:"to"`
+	DoctorID   *string   `gorm:"column:doctor_id;index" json:"doctor_id,omitempty"`
+	SampleSize int       `gorm:"column:sample_size;not null" json:"sample_size"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy  *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy  *int64    `gorm:"column:updated_by" json:"updated_by"`
+
+	Items []ClinicalAuditItem `gorm:"foreignKey:AuditID;references:ID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+}
+
+func (ClinicalAudit) TableName() string {
+	return "clinical_audit"
+}
+
+func (a *ClinicalAudit) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (a *ClinicalAudit) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ClinicalAuditItem is a visit sampled for an audit and the auditor's score of it. A visit is
+// sampled by one audit at most. AppointmentID is not a foreign key, as appointments are partitioned;
+// the service leaves out visits whose appointment was deleted.
+type ClinicalAuditItem struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AuditID       uint       `gorm:"column:audit_id;not null;index" json:"audit_id"`
+	AppointmentID uint       `gorm:"column:appointment_id;not null;uniqueIndex" json:"appointment_id"`
+	PatientID     string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	DoctorID      string     `gorm:"column:doctor_id;not null;index" json:"doctor_id"`
+	VisitedAt     time.Time  `gorm:"column:visited_at;not null;index" json:"visited_at"`
+	Score         *int       `gorm:"column:score;check:score BETWEEN 1 AND 5" json:"score,omitempty"`
+	Comments      string     `gorm:"column:comments;type:text" json:"comments,omitempty"`
+	ScoredBy      *int64     `gorm:"column:scored_by" json:"scored_by,omitempty"`
+	ScoredAt      *time.Time `gorm:"column:scored_at" json:"scored_at,omitempty"`
+}
+
+func (ClinicalAuditItem) TableName() string {
+	return "clinical_audit_item"
+}
+
+// ClinicalAuditScore is an auditor's score of a sampled visit
+type ClinicalAuditScore struct {
+	Score    int    `json:"score"`
+	Comments string `json:"comments"`
+}
+
+// ClinicalAuditVisit is a sampled visit with the records an auditor reviews: the examinations and
+// bills of the patient on the day of the visit, and the treatment plans the patient had by then
+type ClinicalAuditVisit struct {
+	Item           ClinicalAuditItem `json:"item"`
+	PatientName    string            `json:"patient_name"`
+	DoctorName     string            `json:"doctor_name"`
+	Type           string            `json:"type"`
+	Examinations   []Examination     `json:"examinations"`
+	TreatmentPlans []TreatmentPlan   `json:"treatment_plans"`
+	Billings       []Billing         `json:"billings"`
+}
+
+// ClinicalAuditWorksheet is an audit with its sampled visits to review and how far scoring went
+type ClinicalAuditWorksheet struct {
+	Audit        ClinicalAudit        `json:"audit"`
+	Visits       []ClinicalAuditVisit `json:"visits"`
+	Scored       int                  `json:"scored"`
+	AverageScore *float64             `json:"average_score,omitempty"`
+}
+
+// ClinicalAuditTrend is how the visits of a month of visits scored, across every audit
+type ClinicalAuditTrend struct {
+	Month         string  `json:"month"` // YYYY-MM
+	Scored        int64   `json:"scored"`
+	AverageScore  float64 `json:"average_score"`
+	BelowStandard int64   `json:"below_standard"`
+}
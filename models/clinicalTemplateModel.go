@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Template kinds, by the record they are inserted into
+const (
+	TemplateExamination   = "examination"
+	TemplateTreatmentPlan = "treatment_plan"
+)
+
+// Template sharing states. Doctors keep templates to themselves until an Admin approves sharing
+// them with the whole clinic.
+const (
+	TemplatePrivate = "private"
+	TemplatePending = "pending"
+	TemplateShared  = "shared"
+)
+
+// IsValidTemplateKind reports whether kind is one of the template kinds
+func IsValidTemplateKind(kind string) bool {
+	return kind == TemplateExamination || kind == TemplateTreatmentPlan
+}
+
+// ClinicalTemplate is a reusable block of examination text or treatment plan boilerplate
+type ClinicalTemplate struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	OwnerID    int64      `gorm:"column:owner_id;not null;index" json:"owner_id"`
+	Kind       string     `gorm:"column:kind;size:20;not null;check:kind IN ('examination', 'treatment_plan')" json:"kind"`
+	Name       string     `gorm:"column:name;size:255;not null" json:"name"`
+	Body       string     `gorm:"column:body;type:text;not null" json:"body"`
+	Sharing    string     `gorm:"column:sharing;size:20;not null;default:private;check:sharing IN ('private', 'pending', 'shared');index" json:"sharing"`
+	ReviewedBy *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewNote string     `gorm:"column:review_note;type:text" json:"review_note,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	Owner      User       `gorm:"foreignKey:OwnerID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ClinicalTemplate) TableName() string {
+	return "clinical_template"
+}
+
+// TemplateFilter narrows down template queries
+type TemplateFilter struct {
+	ViewerID int64  // Only templates this user owns or that are shared
+	OwnerID  int64  // Only templates this user owns
+	Kind     string // Only templates of this kind
+	Sharing  string // Only templates in this sharing state
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Closure kinds
+const (
+	ClosureKindPublicHoliday = "public_holiday"
+	ClosureKindClosure       = "closure"
+)
+
+// ClosureDateLayout formats the days of a closure
+const ClosureDateLayout = "2006-01-02"
+
+// IsValidClosureKind reports whether kind is one of the closure kinds
+func IsValidClosureKind(kind string) bool {
+	return kind == ClosureKindPublicHoliday || kind == ClosureKindClosure
+}
+
+// Closure is a day, or run of days, the clinic is closed, such as a public holiday. No slots are
+// offered and no appointments booked on them. Dates are clinic days as YYYY-MM-DD.
+type Closure struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	StartDate string    `gorm:"column:start_date;size:10;not null;index" json:"start_date"`
+	EndDate   string    `gorm:"column:end_date;size:10;not null;index" json:"end_date"`
+	Kind      string    `gorm:"column:kind;size:20;not null;default:closure;check:kind IN ('public_holiday', 'closure')" json:"kind"`
+	Reason    string    `gorm:"column:reason;not null" json:"reason"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (Closure) TableName() string {
+	return "closure"
+}
+
+func (c *Closure) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (c *Closure) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ClosedAppointment is a booked appointment falling on a closure, which has to be moved or cancelled
+type ClosedAppointment struct {
+	AppointmentID uint   `json:"appointment_id"`
+	PatientID     string `json:"patient_id"`
+	PatientName   string `json:"patient_name"`
+	PatientPhone  string `json:"patient_phone"`
+	DoctorID      string `json:"doctor_id"`
+	DoctorName    string `json:"doctor_name"`
+	DateTime      string `json:"date_time"`
+	Status        string `json:"status"`
+}
+
+// ClosureResult is a saved closure with the appointments that fall on it
+type ClosureResult struct {
+	Closure      Closure             `json:"closure"`
+	Appointments []ClosedAppointment `json:"appointments"`
+	Warnings     []string            `json:"warnings,omitempty"`
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Consents a patient gives or withdraws
+const (
+	ConsentEmail     = "email"
+	ConsentSMS       = "sms"
+	ConsentReminders = "reminders"
+	ConsentMarketing = "marketing"
+)
+
+// Where a consent was given or withdrawn
+const (
+	ConsentSourceDesk   = "desk"
+	ConsentSourcePortal = "portal"
+)
+
+// CommunicationPreference is how a patient agrees to be contacted. Patients without one get
+// email and SMS, including reminders, but no marketing until they opt in.
+type CommunicationPreference struct {
+	PatientID          string     `gorm:"primaryKey;column:patient_id" json:"patient_id"`
+	Email              bool       `gorm:"column:email;not null;default:true" json:"email"`
+	SMS                bool       `gorm:"column:sms;not null;default:true" json:"sms"`
+	Reminders          bool       `gorm:"column:reminders;not null;default:true" json:"reminders"`
+	Marketing          bool       `gorm:"column:marketing;not null;default:false" json:"marketing"`
+	MarketingConsentAt *time.Time `gorm:"column:marketing_consent_at" json:"marketing_consent_at,omitempty"`
+	UpdatedAt          time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	UpdatedBy          *int64     `gorm:"column:updated_by" json:"updated_by"`
+	Patient            Patient    `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CommunicationPreference) TableName() string {
+	return "communication_preference"
+}
+
+// BeforeCreate stamps the user, as preferences are only ever written by upsert
+func (p *CommunicationPreference) BeforeCreate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// DefaultCommunicationPreference returns the preferences of a patient who has not stated any
+func DefaultCommunicationPreference(patientID string) CommunicationPreference {
+	return CommunicationPreference{PatientID: patientID, Email: true, SMS: true, Reminders: true}
+}
+
+// CommunicationPreferenceUpdate changes some of a patient's preferences; fields left out keep their value
+type CommunicationPreferenceUpdate struct {
+	Email     *bool `json:"email"`
+	SMS       *bool `json:"sms"`
+	Reminders *bool `json:"reminders"`
+	Marketing *bool `json:"marketing"`
+}
+
+// ConsentRecord is a consent a patient gave or withdrew, kept as evidence of what they agreed to and when.
+// GivenBy names who gave it: the patient, or their guarantor on their behalf.
+type ConsentRecord struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID  string    `gorm:"column:patient_id;not null;index:idx_consent_patient_recorded,priority:1" json:"patient_id"`
+	Consent    string    `gorm:"column:consent;size:20;not null;check:consent IN ('email', 'sms', 'reminders', 'marketing')" json:"consent"`
+	Granted    bool      `gorm:"column:granted;not null" json:"granted"`
+	Source     string    `gorm:"column:source;size:20;not null;check:source IN ('desk', 'portal')" json:"source"`
+	GivenBy    string    `gorm:"column:given_by" json:"given_by,omitempty"`
+	RecordedAt time.Time `gorm:"column:recorded_at;not null;index:idx_consent_patient_recorded,priority:2" json:"recorded_at"`
+	RecordedBy *int64    `gorm:"column:recorded_by" json:"recorded_by"`
+}
+
+func (ConsentRecord) TableName() string {
+	return "consent_record"
+}
+
+func (r *ConsentRecord) BeforeCreate(tx *gorm.DB) error {
+	tx.Statement.SetColumn("recorded_by", actorColumn(tx))
+	return nil
+}
+
+// PortalPreferences is what the patient portal shows: the patient's first name and preferences
+type PortalPreferences struct {
+	FirstName   string                  `json:"first_name"`
+	Preferences CommunicationPreference `json:"preferences"`
+}
+
+// Outcomes of a message sent to a patient
+const (
+	MessageSent         = "sent"
+	MessageNotPermitted = "not_permitted"
+	MessageFailed       = "failed"
+	MessageDeferred     = "deferred" // Held back by quiet hours until they end
+)
+
+// CommunicationLog is a message sent, or held back by the patient's preferences, to a patient,
+// with the record it was about
+type CommunicationLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string    `gorm:"column:patient_id;not null;index:idx_communication_log_patient_sent,priority:1" json:"patient_id"`
+	Channel   string    `gorm:"column:channel;size:10;not null;check:channel IN ('email', 'sms')" json:"channel"`
+	Purpose   string    `gorm:"column:purpose;size:20;not null" json:"purpose"`
+	Recipient string    `gorm:"column:recipient;not null" json:"recipient"`
+	Subject   string    `gorm:"column:subject" json:"subject"`
+	Body      string    `gorm:"column:body;type:text" json:"body"`
+	Status    string    `gorm:"column:status;size:20;not null;check:status IN ('sent', 'not_permitted', 'failed', 'deferred')" json:"status"`
+	Error     string    `gorm:"column:error" json:"error,omitempty"`
+	MessageID string    `gorm:"column:message_id;index" json:"message_id,omitempty"` // Message-ID of an email, which bounces are matched by, or ID of a text
+	Delivery  string    `gorm:"column:delivery;size:20" json:"delivery,omitempty"`   // Bounced or complained, once the mail provider reports it
+	Record    string    `gorm:"column:record;size:50" json:"record,omitempty"`
+	RecordID  string    `gorm:"column:record_id" json:"record_id,omitempty"`
+	SentAt    time.Time `gorm:"column:sent_at;not null;index:idx_communication_log_patient_sent,priority:2" json:"sent_at"`
+	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CommunicationLog) TableName() string {
+	return "communication_log"
+}
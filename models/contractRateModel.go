@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ContractRate is the amount an insurer's contract pays for a procedure from EffectiveFrom until a
+// later rate for the same procedure takes over
+type ContractRate struct {
+	ID                 uint             `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	InsuranceCompanyID string           `gorm:"column:insurance_company_id;not null;uniqueIndex:idx_contract_rate,priority:1" json:"insurance_company_id"`
+	ProcedureID        uint             `gorm:"column:procedure_id;not null;uniqueIndex:idx_contract_rate,priority:2" json:"procedure_id"`
+	EffectiveFrom      time.Time        `gorm:"column:effective_from;type:date;not null;uniqueIndex:idx_contract_rate,priority:3" json:"effective_from"`
+	Amount             float64          `gorm:"column:amount;not null" json:"amount"`
+	CreatedAt          time.Time        `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time        `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	InsuranceCompany   InsuranceCompany `gorm:"foreignKey:InsuranceCompanyID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Procedure          Procedure        `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:CASCADE" json:"procedure,omitempty"`
+}
+
+func (ContractRate) TableName() string {
+	return "contract_rate"
+}
+
+// ContractVariance is a bill whose amount differs from the insurer's contract rate
+type ContractVariance struct {
+	BillingID            string    `json:"billing_id"`
+	PatientID            string    `json:"patient_id"`
+	InsuranceCompanyID   string    `json:"insurance_company_id"`
+	InsuranceCompanyName string    `json:"insurance_company_name"`
+	Procedure            string    `json:"procedure"`
+	ContractAmount       float64   `json:"contract_amount"`
+	BillingAmount        float64   `json:"billing_amount"`
+	Variance             float64   `json:"variance"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// ContractVarianceReport lists the bills of a period that differ from contract rates
+type ContractVarianceReport struct {
+	From          string             `json:"from"`
+	To            string             `json:"to"`
+	Bills         []ContractVariance `json:"bills"`
+	TotalContract float64            `json:"total_contract"`
+	TotalBilled   float64            `json:"total_billed"`
+	TotalVariance float64            `json:"total_variance"`
+}
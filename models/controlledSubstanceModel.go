@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// ControlledSubstance is a drug the regulator schedules; prescribing it is restricted and recorded
+// in the controlled substance register.
+type ControlledSubstance struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Drug      string    `gorm:"column:drug;size:255;not null;uniqueIndex" json:"drug"`
+	Schedule  string    `gorm:"column:schedule;size:20;not null" json:"schedule"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (ControlledSubstance) TableName() string {
+	return "controlled_substance"
+}
+
+// ControlledRegisterEntry is a line of the controlled substance register. Entries are numbered
+// without gaps in the order they are written and are never changed or removed; names are copied in
+// so the register stays complete when patients or doctors are deleted.
+type ControlledRegisterEntry struct {
+	Number         int64     `gorm:"primaryKey;autoIncrement:false;column:number" json:"number"`
+	PrescriptionID uint      `gorm:"column:prescription_id;not null;uniqueIndex" json:"prescription_id"`
+	PrescribedOn   time.Time `gorm:"column:prescribed_on;type:date;not null;index" json:"prescribed_on"`
+	DoctorID       string    `gorm:"column:doctor_id;not null" json:"doctor_id"`
+	DoctorName     string    `gorm:"column:doctor_name;not null" json:"doctor_name"`
+	PatientID      string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	PatientName    string    `gorm:"column:patient_name;not null" json:"patient_name"`
+	Drug           string    `gorm:"column:drug;size:255;not null" json:"drug"`
+	Schedule       string    `gorm:"column:schedule;size:20;not null" json:"schedule"`
+	Dose           string    `gorm:"column:dose;size:100" json:"dose"`
+	Quantity       float64   `gorm:"column:quantity;not null" json:"quantity"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (ControlledRegisterEntry) TableName() string {
+	return "controlled_register"
+}
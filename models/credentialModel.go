@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// Credentials a doctor must hold current to be scheduled
+const (
+	CredentialLicence   = "licence"
+	CredentialIndemnity = "indemnity"
+	CredentialCPD       = "cpd"
+)
+
+// Credentials lists the credentials tracked for every doctor
+var Credentials = []string{CredentialLicence, CredentialIndemnity, CredentialCPD}
+
+// Standing of a doctor's credential in the compliance report
+const (
+	CredentialValid    = "valid"
+	CredentialExpiring = "expiring" // Valid, but expiring within the warning period
+	CredentialExpired  = "expired"
+	CredentialMissing  = "missing" // No expiry on record
+)
+
+// CredentialExpiry returns the last day the doctor's credential is valid, nil when none is on record
+func (d *Doctor) CredentialExpiry(credential string) *time.Time {
+	switch credential {
+	case CredentialLicence:
+		return d.LicenceExpiresOn
+	case CredentialIndemnity:
+		return d.IndemnityExpiresOn
+	case CredentialCPD:
+		return d.CPDExpiresOn
+	}
+	return nil
+}
+
+// ExpiredCredentials lists the doctor's credentials no longer valid on the clinic day of t.
+// Credentials without an expiry on record are not counted as expired.
+func (d *Doctor) ExpiredCredentials(t time.Time) []string {
+	day := t.In(ClinicLocation()).Format(ClosureDateLayout)
+	var expired []string
+	for _, credential := range Credentials {
+		if expiry := d.CredentialExpiry(credential); expiry != nil && CredentialDay(*expiry) < day {
+			expired = append(expired, credential)
+		}
+	}
+	return expired
+}
+
+// CredentialDay formats the expiry day of a credential, which is stored as a date without a time zone
+func CredentialDay(expiry time.Time) string {
+	return expiry.UTC().Format(ClosureDateLayout)
+}
+
+// DoctorCredential is the standing of one of a doctor's credentials
+type DoctorCredential struct {
+	Credential string     `json:"credential"`
+	ExpiresOn  *time.Time `json:"expires_on,omitempty"`
+	Status     string     `json:"status"`
+	DaysLeft   *int       `json:"days_left,omitempty"` // Negative once expired
+}
+
+// DoctorCompliance is a doctor's line in the credential compliance report
+type DoctorCompliance struct {
+	DoctorID      string             `json:"doctor_id"`
+	Name          string             `json:"name"`
+	LicenceNumber *string            `json:"licence_number,omitempty"`
+	Credentials   []DoctorCredential `json:"credentials"`
+	Schedulable   bool               `json:"schedulable"` // False while any credential has expired
+}
+
+// ComplianceReport is the standing of every doctor's credentials on a day
+type ComplianceReport struct {
+	Date        string             `json:"date"`
+	WarningDays int                `json:"warning_days"`
+	Expired     int                `json:"expired"` // Doctors with an expired credential, who cannot be scheduled
+	Expiring    int                `json:"expiring"`
+	Missing     int                `json:"missing"`
+	Doctors     []DoctorCompliance `json:"doctors"`
+}
+
+// CredentialReminder records that a doctor was reminded of a credential's expiry, so each expiry is
+// reminded of once before it and once after
+type CredentialReminder struct {
+	DoctorID   string    `gorm:"primaryKey;column:doctor_id;size:50" json:"doctor_id"`
+	Credential string    `gorm:"primaryKey;column:credential;size:20" json:"credential"`
+	ExpiresOn  time.Time `gorm:"primaryKey;column:expires_on;type:date" json:"expires_on"`
+	Status     string    `gorm:"primaryKey;column:status;size:20" json:"status"` // expiring or expired
+	SentAt     time.Time `gorm:"column:sent_at;not null" json:"sent_at"`
+}
+
+func (CredentialReminder) TableName() string {
+	return "credential_reminder"
+}
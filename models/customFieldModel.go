@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Records custom fields can be defined for
+const (
+	CustomFieldEntityPatient     = "patient"
+	CustomFieldEntityAppointment = "appointment"
+)
+
+// IsValidCustomFieldEntity reports whether custom fields can be defined for entity
+func IsValidCustomFieldEntity(entity string) bool {
+	return entity == CustomFieldEntityPatient || entity == CustomFieldEntityAppointment
+}
+
+// Custom field types
+const (
+	CustomFieldText    = "text"
+	CustomFieldNumber  = "number"
+	CustomFieldBoolean = "boolean"
+	CustomFieldDate    = "date"
+	CustomFieldSelect  = "select"
+)
+
+// IsValidCustomFieldType reports whether fieldType is one of the custom field types
+func IsValidCustomFieldType(fieldType string) bool {
+	switch fieldType {
+	case CustomFieldText, CustomFieldNumber, CustomFieldBoolean, CustomFieldDate, CustomFieldSelect:
+		return true
+	}
+	return false
+}
+
+// CustomFieldOptions are the choices of a select field, stored as a JSONB array
+type CustomFieldOptions []string
+
+func (o CustomFieldOptions) Value() (driver.Value, error) {
+	if o == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (o *CustomFieldOptions) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*o = CustomFieldOptions{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported custom field options value")
+	}
+	return json.Unmarshal(data, o)
+}
+
+// CustomField is a practice-specific field an admin adds to patients or appointments, such as a
+// referral source. Pattern applies to text fields, Min and Max to number fields and Options to
+// select fields. Inactive fields are no longer required, but the values recorded are kept.
+type CustomField struct {
+	ID        uint               `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Entity    string             `gorm:"column:entity;size:20;not null;uniqueIndex:idx_custom_field_entity_key,priority:1;check:entity IN ('patient', 'appointment')" json:"entity"`
+	Key       string             `gorm:"column:key;size:50;not null;uniqueIndex:idx_custom_field_entity_key,priority:2" json:"key"`
+	Label     string             `gorm:"column:label;size:100;not null" json:"label"`
+	Type      string             `gorm:"column:type;size:20;not null;check:type IN ('text', 'number', 'boolean', 'date', 'select')" json:"type"`
+	Required  bool               `gorm:"column:required;not null;default:false" json:"required"`
+	Options   CustomFieldOptions `gorm:"column:options;type:jsonb;not null;default:'[]'" json:"options"`
+	Pattern   string             `gorm:"column:pattern;size:255" json:"pattern,omitempty"`
+	Min       *float64           `gorm:"column:min" json:"min,omitempty"`
+	Max       *float64           `gorm:"column:max" json:"max,omitempty"`
+	Active    bool               `gorm:"column:active;not null;default:true" json:"active"`
+	CreatedAt time.Time          `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64             `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64             `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (CustomField) TableName() string {
+	return "custom_field"
+}
+
+func (f *CustomField) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (f *CustomField) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// CustomFieldValues are the custom field values of a patient or appointment by field key, stored
+// as a JSONB object
+type CustomFieldValues map[string]interface{}
+
+func (v CustomFieldValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (v *CustomFieldValues) Scan(value interface{}) error {
+	var data []byte
+	switch val := value.(type) {
+	case nil:
+		*v = CustomFieldValues{}
+		return nil
+	case []byte:
+		data = val
+	case string:
+		data = []byte(val)
+	default:
+		return errors.New("unsupported custom field values value")
+	}
+	return json.Unmarshal(data, v)
+}
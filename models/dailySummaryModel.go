@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// How money was collected
+const (
+	PaymentMethodCash      = "cash"      // Paid at the desk
+	PaymentMethodOnline    = "online"    // Paid through the portal's payment gateway
+	PaymentMethodInsurance = "insurance" // Paid by the patient's insurer
+)
+
+// PaymentTotal is the money collected by one method
+type PaymentTotal struct {
+	Method string  `json:"method"`
+	Amount float64 `json:"amount"`
+}
+
+// DailySummary is the day's key figures management is emailed at the end of each clinic day
+type DailySummary struct {
+	Date           string         `json:"date"`
+	Appointments   int            `json:"appointments"`  // Appointments the day had, cancelled ones aside
+	PatientsSeen   int            `json:"patients_seen"` // Patients who came in for an appointment
+	NoShows        int            `json:"no_shows"`      // Appointments nobody checked in for
+	Cancelled      int            `json:"cancelled"`
+	NewPatients    int            `json:"new_patients"`
+	Billed         float64        `json:"billed"`
+	Collected      []PaymentTotal `json:"collected"` // By method, cash, online and insurance in that order
+	TotalCollected float64        `json:"total_collected"`
+	OpenTasks      int            `json:"open_tasks"`    // Tasks not done or cancelled when the summary was made
+	OverdueTasks   int            `json:"overdue_tasks"` // Open tasks past their due date
+}
+
+// DailySummaryRun records that the summary of a clinic day was sent, so replicas send it once
+type DailySummaryRun struct {
+	Date   string    `gorm:"primaryKey;column:date;size:10" json:"date"`
+	SentAt time.Time `gorm:"column:sent_at;not null" json:"sent_at"`
+}
+
+func (DailySummaryRun) TableName() string {
+	return "daily_summary_run"
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DeferredRecipients are the addresses or numbers a held message goes to, stored as a JSONB array
+type DeferredRecipients []string
+
+func (r DeferredRecipients) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *DeferredRecipients) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*r = DeferredRecipients{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported deferred recipients value")
+	}
+	return json.Unmarshal(data, r)
+}
+
+// DeferredAttachment is a file emailed along with a held message
+type DeferredAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// DeferredAttachments are stored as a JSONB array
+type DeferredAttachments []DeferredAttachment
+
+func (a DeferredAttachments) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *DeferredAttachments) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*a = DeferredAttachments{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported deferred attachments value")
+	}
+	return json.Unmarshal(data, a)
+}
+
+// DeferredMessage is a message to a patient held back during quiet hours, delivered at SendAt
+type DeferredMessage struct {
+	ID          uint                `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Channel     string              `gorm:"column:channel;size:10;not null" json:"channel"`
+	PatientID   string              `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Purpose     string              `gorm:"column:purpose;size:20;not null" json:"purpose"`
+	Recipients  DeferredRecipients  `gorm:"column:recipients;type:jsonb;not null" json:"recipients"`
+	Subject     string              `gorm:"column:subject" json:"subject"`
+	Body        string              `gorm:"column:body;type:text" json:"body"`
+	Attachments DeferredAttachments `gorm:"column:attachments;type:jsonb;not null;default:'[]'" json:"-"`
+	MessageID   string              `gorm:"column:message_id;index" json:"message_id,omitempty"`
+	Expires     *time.Time          `gorm:"column:expires" json:"expires,omitempty"`
+	SendAt      time.Time           `gorm:"column:send_at;not null;index" json:"send_at"`
+	Attempts    int                 `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError   string              `gorm:"column:last_error" json:"last_error,omitempty"`
+	CreatedAt   time.Time           `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (DeferredMessage) TableName() string {
+	return "deferred_message"
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// SlowQuery is a normalized statement from pg_stat_statements with its execution times in
+// milliseconds
+type SlowQuery struct {
+	Query   string  `json:"query"`
+	Calls   int64   `json:"calls"`
+	Rows    int64   `json:"rows"`
+	TotalMs float64 `json:"total_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// LockWait is a session waiting on a lock held by another session
+type LockWait struct {
+	PID            int     `json:"pid"`
+	Application    string  `json:"application"`
+	WaitEvent      string  `json:"wait_event"`
+	WaitingSeconds float64 `json:"waiting_seconds"`
+	Query          string  `json:"query"`
+	BlockingPID    int     `json:"blocking_pid"`
+	BlockingState  string  `json:"blocking_state"`
+	BlockingQuery  string  `json:"blocking_query"`
+}
+
+// DatabasePoolStats is the state of the server's Postgres connection pool
+type DatabasePoolStats struct {
+	MaxOpen      int     `json:"max_open"`
+	Open         int     `json:"open"`
+	InUse        int     `json:"in_use"`
+	Idle         int     `json:"idle"`
+	WaitCount    int64   `json:"wait_count"`
+	WaitSeconds  float64 `json:"wait_seconds"`
+	MaxIdleClose int64   `json:"max_idle_closed"`
+}
+
+// RedisLatency summarises the round trips of a few PINGs to Redis, in milliseconds
+type RedisLatency struct {
+	Circuit    string    `json:"circuit"`
+	Samples    []float64 `json:"samples_ms"`
+	MinMs      float64   `json:"min_ms"`
+	MeanMs     float64   `json:"mean_ms"`
+	MaxMs      float64   `json:"max_ms"`
+	Errors     int       `json:"errors"`
+	LastError  string    `json:"last_error,omitempty"`
+	TotalConns uint32    `json:"total_conns"`
+	IdleConns  uint32    `json:"idle_conns"`
+	StaleConns uint32    `json:"stale_conns"`
+}
+
+// RuntimeStats is the server process's goroutines, memory and garbage collection
+type RuntimeStats struct {
+	StartedAt      time.Time  `json:"started_at"`
+	UptimeSeconds  float64    `json:"uptime_seconds"`
+	Goroutines     int        `json:"goroutines"`
+	HeapAllocBytes uint64     `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64     `json:"heap_inuse_bytes"`
+	HeapObjects    uint64     `json:"heap_objects"`
+	SysBytes       uint64     `json:"sys_bytes"`
+	NumGC          uint32     `json:"num_gc"`
+	GCPauseTotalMs float64    `json:"gc_pause_total_ms"`
+	LastGCAt       *time.Time `json:"last_gc_at,omitempty"`
+}
+
+// Diagnostics gathers everything the runbook asks for when triaging a production issue. A section
+// that could not be collected is left out and its error reported in Errors.
+type Diagnostics struct {
+	CollectedAt time.Time          `json:"collected_at"`
+	SlowQueries []SlowQuery        `json:"slow_queries,omitempty"`
+	LockWaits   []LockWait         `json:"lock_waits,omitempty"`
+	Pool        *DatabasePoolStats `json:"database_pool,omitempty"`
+	Redis       *RedisLatency      `json:"redis,omitempty"`
+	Schema      *SchemaDrift       `json:"schema,omitempty"`
+	Runtime     RuntimeStats       `json:"runtime"`
+	Errors      map[string]string  `json:"errors,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// DirectoryDoctor is a doctor as listed by the staff directory or a row of an imported CSV file
+type DirectoryDoctor struct {
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	LicenceNumber string `json:"licence_number"`
+	Specialty     string `json:"specialty"`
+	Email         string `json:"email"`    // Address of the doctor's user account, created when the doctor has none
+	Username      string `json:"username"` // Defaults to the part of the email before the @
+}
+
+// What an import did with a directory row
+const (
+	DoctorImportCreated   = "created"
+	DoctorImportUpdated   = "updated"
+	DoctorImportUnchanged = "unchanged"
+	DoctorImportFailed    = "failed"
+)
+
+// What an import did with the user account of a directory row's doctor
+const (
+	DoctorAccountCreated = "created"
+	DoctorAccountLinked  = "linked"
+)
+
+// DoctorImportChange reports what an import did, or with a dry run would do, with one directory row
+type DoctorImportChange struct {
+	Row           int      `json:"row"` // Position in the file or directory listing, from 1
+	Name          string   `json:"name"`
+	LicenceNumber string   `json:"licence_number,omitempty"`
+	DoctorID      string   `json:"doctor_id,omitempty"`
+	Action        string   `json:"action"`
+	Fields        []string `json:"fields,omitempty"`  // Fields an update changed
+	Account       string   `json:"account,omitempty"` // Whether a user account was created or an existing one linked
+	UserID        *int64   `json:"user_id,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// DoctorImportReport is the change report of a doctor import or directory sync
+type DoctorImportReport struct {
+	Source    string               `json:"source"` // csv or directory
+	DryRun    bool                 `json:"dry_run"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Failed    int                  `json:"failed"`
+	Accounts  int                  `json:"accounts"` // User accounts created or linked
+	Changes   []DoctorImportChange `json:"changes"`
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Date styles of generated documents, statements and emails
+const (
+	DateStyleLong         = "long"       // 2 January 2006
+	DateStyleDayMonthYear = "dd/mm/yyyy" // 02/01/2006
+	DateStyleMonthDayYear = "mm/dd/yyyy" // 01/02/2006
+	DateStyleISO          = "yyyy-mm-dd" // 2006-01-02
+)
+
+// dateLayouts are the time layouts of the date styles
+var dateLayouts = map[string]string{
+	DateStyleLong:         "2 January 2006",
+	DateStyleDayMonthYear: "02/01/2006",
+	DateStyleMonthDayYear: "01/02/2006",
+	DateStyleISO:          "2006-01-02",
+}
+
+// IsValidDateStyle reports whether style is one of the date styles
+func IsValidDateStyle(style string) bool {
+	_, ok := dateLayouts[style]
+	return ok
+}
+
+// DocumentFormat is how the PDFs, statements and emails sent to patients write amounts and dates:
+// amounts as KES 1,500.50, the currency first and the digits grouped by three, and dates in one
+// of the date styles
+type DocumentFormat struct {
+	Currency           string `json:"currency"`
+	ThousandsSeparator string `json:"thousands_separator"`
+	DecimalSeparator   string `json:"decimal_separator"`
+	DateStyle          string `json:"date_style"`
+}
+
+// DefaultDocumentFormat returns the format used until an Admin changes it: Kenyan shillings and
+// long dates
+func DefaultDocumentFormat() DocumentFormat {
+	return DocumentFormat{Currency: "KES", ThousandsSeparator: ",", DecimalSeparator: ".", DateStyle: DateStyleLong}
+}
+
+// documentFormat is the format in effect, kept by the settings
+var documentFormat atomic.Pointer[DocumentFormat]
+
+// SetDocumentFormat sets how documents write amounts and dates
+func SetDocumentFormat(format DocumentFormat) {
+	documentFormat.Store(&format)
+}
+
+// CurrentDocumentFormat returns how documents write amounts and dates
+func CurrentDocumentFormat() DocumentFormat {
+	if format := documentFormat.Load(); format != nil {
+		return *format
+	}
+	return DefaultDocumentFormat()
+}
+
+// FormatMoney writes an amount in the clinic's currency, such as KES 1,500.50
+func FormatMoney(amount float64) string {
+	return FormatAmount(CurrentDocumentFormat().Currency, amount)
+}
+
+// FormatAmount writes an amount in currency, rounded to cents, with the separators of the document
+// format
+func FormatAmount(currency string, amount float64) string {
+	format := CurrentDocumentFormat()
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	if currency != "" {
+		b.WriteString(currency + " ")
+	}
+	if amount < 0 && cents > 0 {
+		b.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(format.ThousandsSeparator)
+		}
+		b.WriteRune(digit)
+	}
+	b.WriteString(format.DecimalSeparator)
+	fraction := strconv.FormatInt(cents%100, 10)
+	if len(fraction) < 2 {
+		b.WriteString("0")
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
+
+// FormatDate writes the date of t in the date style of the document format. Times are written as
+// they are, so callers move them to clinic time first.
+func FormatDate(t time.Time) string {
+	layout, ok := dateLayouts[CurrentDocumentFormat().DateStyle]
+	if !ok {
+		layout = dateLayouts[DateStyleLong]
+	}
+	return t.Format(layout)
+}
+
+// FormatDayDate writes the weekday and date of t, such as Monday 2 January 2006
+func FormatDayDate(t time.Time) string {
+	return t.Format("Monday") + " " + FormatDate(t)
+}
+
+// FormatDateTime writes the date and time of day of t, such as 2 January 2006 at 15:04
+func FormatDateTime(t time.Time) string {
+	return FormatDate(t) + " at " + t.Format("15:04")
+}
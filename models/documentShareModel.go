@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of documents shared with patients
+const (
+	SharedDocumentAttachment   = "attachment"    // A file attached to an examination, such as an x-ray photo
+	SharedDocumentImagingStudy = "imaging_study" // A DICOM image from the imaging unit
+	SharedDocumentInvoice      = "invoice"       // A bill, rendered as a PDF invoice
+)
+
+// IsValidSharedDocumentKind reports whether kind is one of the kinds of documents shared with patients
+func IsValidSharedDocumentKind(kind string) bool {
+	switch kind {
+	case SharedDocumentAttachment, SharedDocumentImagingStudy, SharedDocumentInvoice:
+		return true
+	}
+	return false
+}
+
+// DocumentShare is a set of a patient's documents sent to them by email or SMS as a signed link.
+// The link works until ExpiresAt unless staff revoke it, and every use of it is logged.
+type DocumentShare struct {
+	ID        uint                  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string                `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Channel   string                `gorm:"column:channel;size:10;not null;check:channel IN ('email', 'sms')" json:"channel"`
+	Recipient string                `gorm:"column:recipient;not null" json:"recipient"`
+	Message   string                `gorm:"column:message;type:text" json:"message,omitempty"`
+	ExpiresAt time.Time             `gorm:"column:expires_at;not null" json:"expires_at"`
+	RevokedAt *time.Time            `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	RevokedBy *int64                `gorm:"column:revoked_by" json:"revoked_by,omitempty"`
+	CreatedAt time.Time             `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	CreatedBy *int64                `gorm:"column:created_by" json:"created_by"`
+	Documents []SharedDocument      `gorm:"foreignKey:ShareID;references:ID;constraint:OnDelete:CASCADE" json:"documents"`
+	Accesses  []DocumentShareAccess `gorm:"foreignKey:ShareID;references:ID;constraint:OnDelete:CASCADE" json:"accesses,omitempty"`
+	Link      string                `gorm:"-" json:"link,omitempty"` // The signed link, only returned when the share is created
+	Patient   Patient               `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (DocumentShare) TableName() string {
+	return "document_share"
+}
+
+func (s *DocumentShare) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// Active reports whether the share's link still works at now
+func (s DocumentShare) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SharedDocument is a document in a share: an examination attachment, an imaging study or a bill,
+// identified by RecordID. Attachments also name their examination.
+type SharedDocument struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ShareID       uint   `gorm:"column:share_id;not null;index" json:"-"`
+	Kind          string `gorm:"column:kind;size:20;not null;check:kind IN ('attachment', 'imaging_study', 'invoice')" json:"kind"`
+	RecordID      string `gorm:"column:record_id;not null" json:"record_id"`
+	ExaminationID *uint  `gorm:"column:examination_id" json:"examination_id,omitempty"`
+	Name          string `gorm:"column:name;size:255;not null" json:"name"`
+}
+
+func (SharedDocument) TableName() string {
+	return "shared_document"
+}
+
+// DocumentShareAccess is a use of a share link: opening the list of documents, or downloading one
+// of them when DocumentID is set
+type DocumentShareAccess struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ShareID    uint      `gorm:"column:share_id;not null;index" json:"share_id"`
+	DocumentID *uint     `gorm:"column:document_id" json:"document_id,omitempty"`
+	IP         string    `gorm:"column:ip;size:64" json:"ip"`
+	UserAgent  string    `gorm:"column:user_agent" json:"user_agent,omitempty"`
+	AccessedAt time.Time `gorm:"column:accessed_at;not null" json:"accessed_at"`
+}
+
+func (DocumentShareAccess) TableName() string {
+	return "document_share_access"
+}
+
+// DocumentShareRequest is what staff share with a patient
+type DocumentShareRequest struct {
+	Channel    string           `json:"channel" binding:"required"`
+	Documents  []SharedDocument `json:"documents" binding:"required"`
+	Message    string           `json:"message"`
+	ValidHours int              `json:"valid_hours"` // How long the link stays valid; the configured default when 0
+}
+
+// SharedDocumentLink is a document as listed to the patient opening a share link
+type SharedDocumentLink struct {
+	ID   uint   `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	URL  string `json:"url"` // Path of the download, signed like the share link
+}
+
+// SharedDocuments is what a patient sees on opening a share link
+type SharedDocuments struct {
+	Clinic    string               `json:"clinic"`
+	FirstName string               `json:"first_name"`
+	Message   string               `json:"message,omitempty"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	Documents []SharedDocumentLink `json:"documents"`
+}
+
+// SharedFile is a downloaded shared document
+type SharedFile struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
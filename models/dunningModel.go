@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DunningStage is a statement sent to patients once a bill is DaysOverdue days overdue, e.g. at 14,
+// 30 and 60 days. {first_name}, {last_name}, {days_overdue}, {balance} and {statement} in the subject
+// and body are replaced with the patient's names, the stage, the amount owed and the list of bills.
+type DunningStage struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	DaysOverdue int       `gorm:"column:days_overdue;not null;uniqueIndex" json:"days_overdue"`
+	Subject     string    `gorm:"column:subject;size:255;not null" json:"subject"`
+	Body        string    `gorm:"column:body;type:text;not null" json:"body"`
+	SendEmail   bool      `gorm:"column:send_email;not null" json:"send_email"`
+	SendSMS     bool      `gorm:"column:send_sms;not null" json:"send_sms"`
+	Active      bool      `gorm:"column:active;not null" json:"active"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy   *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy   *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (DunningStage) TableName() string {
+	return "dunning_stage"
+}
+
+func (s *DunningStage) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (s *DunningStage) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// DunningNotice records that a bill reached a dunning stage and was included in a statement
+type DunningNotice struct {
+	ID        uint         `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID string       `gorm:"column:billing_id;not null;uniqueIndex:idx_dunning_notice,priority:1" json:"billing_id"`
+	StageID   uint         `gorm:"column:stage_id;not null;uniqueIndex:idx_dunning_notice,priority:2" json:"stage_id"`
+	PatientID string       `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	SentAt    time.Time    `gorm:"column:sent_at;not null" json:"sent_at"`
+	Stage     DunningStage `gorm:"foreignKey:StageID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (DunningNotice) TableName() string {
+	return "dunning_notice"
+}
+
+// DunningCandidate is an overdue bill and the latest dunning stage it reached without a notice. The
+// statement goes to AccountID, the guarantor of the patient's household or the patient, whose name
+// and contact details the Patient fields are; BilledFirstName is the billed patient's own.
+type DunningCandidate struct {
+	BillingID        string
+	PatientID        string
+	BilledFirstName  string
+	AccountID        string
+	Procedure        string
+	CreatedAt        time.Time
+	Balance          float64
+	StageID          uint
+	StageDays        int
+	PatientFirstName string
+	PatientLastName  string
+	PatientEmail     string
+	PatientPhone     string
+}
+
+// DunningStatement is what one account is sent about its bills that reached a dunning stage
+type DunningStatement struct {
+	Stage DunningStage
+	Bills []DunningCandidate
+}
+
+// Balance returns the amount owed on the statement's bills
+func (s DunningStatement) Balance() float64 {
+	var balance float64
+	for _, bill := range s.Bills {
+		balance += bill.Balance
+	}
+	return balance
+}
+
+// Fill replaces the placeholders of a dunning stage's text with the statement's details
+func (s DunningStatement) Fill(text string) string {
+	var lines []string
+	for _, bill := range s.Bills {
+		procedure := bill.Procedure
+		if bill.PatientID != bill.AccountID {
+			procedure += " (" + bill.BilledFirstName + ")"
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s  %s", FormatDate(bill.CreatedAt.In(ClinicLocation())), procedure, FormatMoney(bill.Balance)))
+	}
+	first := s.Bills[0]
+	return strings.NewReplacer(
+		"{first_name}", first.PatientFirstName,
+		"{last_name}", first.PatientLastName,
+		"{days_overdue}", fmt.Sprint(s.Stage.DaysOverdue),
+		"{balance}", FormatMoney(s.Balance()),
+		"{statement}", strings.Join(lines, "\n"),
+	).Replace(text)
+}
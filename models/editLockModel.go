@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// EditLock tells who is editing a record, so others are warned before overwriting their changes
+type EditLock struct {
+	Entity    string    `json:"entity"`
+	RecordID  string    `json:"record_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
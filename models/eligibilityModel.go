@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Eligibility check statuses. Appointments of insured patients carry the status of their check.
+const (
+	EligibilityPending     = "pending"      // Waiting for staff or the insurer integration
+	EligibilityConfirmed   = "confirmed"    // The insurer covers the patient on the appointment's date
+	EligibilityNotEligible = "not_eligible" // The insurer does not cover the patient
+	EligibilityError       = "error"        // The insurer integration could not be reached or gave no usable answer
+)
+
+// How an eligibility check is carried out
+const (
+	EligibilityMethodManual      = "manual"      // Staff call or log in to the insurer and record the outcome
+	EligibilityMethodIntegration = "integration" // The insurer integration is asked
+)
+
+// IsValidEligibilityStatus reports whether status is one of the eligibility check statuses
+func IsValidEligibilityStatus(status string) bool {
+	switch status {
+	case EligibilityPending, EligibilityConfirmed, EligibilityNotEligible, EligibilityError:
+		return true
+	}
+	return false
+}
+
+// EligibilityCheck confirms with the insurer that an insured patient is covered before their
+// appointment, so claims for the visit are not rejected. One is queued for every appointment an
+// insured patient books, with the cover the patient had on file then.
+type EligibilityCheck struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AppointmentID    uint       `gorm:"column:appointment_id;not null;uniqueIndex" json:"appointment_id"`
+	PatientID        string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	InsuranceCompany string     `gorm:"column:insurance_company;not null" json:"insurance_company"`
+	Scheme           string     `gorm:"column:scheme" json:"scheme,omitempty"`
+	MemberNumber     string     `gorm:"column:member_number" json:"member_number,omitempty"`
+	ServiceDate      string     `gorm:"column:service_date;size:10;not null" json:"service_date"` // YYYY-MM-DD of the appointment
+	Method           string     `gorm:"column:method;size:20;not null;check:method IN ('manual', 'integration')" json:"method"`
+	Status           string     `gorm:"column:status;size:20;not null;index;check:status IN ('pending', 'confirmed', 'not_eligible', 'error')" json:"status"`
+	Detail           string     `gorm:"column:detail;type:text" json:"detail,omitempty"`
+	CheckedAt        *time.Time `gorm:"column:checked_at" json:"checked_at,omitempty"`
+	CheckedBy        *int64     `gorm:"column:checked_by" json:"checked_by,omitempty"` // Staff who recorded a manual outcome
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	Patient          Patient    `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (EligibilityCheck) TableName() string {
+	return "eligibility_check"
+}
+
+// Confirmed reports whether the insurer confirmed the patient's cover
+func (c *EligibilityCheck) Confirmed() bool {
+	return c.Status == EligibilityConfirmed
+}
+
+// EligibilityOutcome is what staff found when checking a patient's cover themselves
+type EligibilityOutcome struct {
+	Status string `json:"status" binding:"required"` // confirmed or not_eligible
+	Detail string `json:"detail"`
+}
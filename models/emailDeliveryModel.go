@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Kinds of delivery problem the mail provider reports about an email
+const (
+	EmailEventBounce    = "bounce"
+	EmailEventComplaint = "complaint"
+)
+
+// IsValidEmailEvent reports whether kind is a delivery problem the provider may report
+func IsValidEmailEvent(kind string) bool {
+	return kind == EmailEventBounce || kind == EmailEventComplaint
+}
+
+// Delivery outcomes of an email, recorded on its communication log entry once the provider reports them
+const (
+	DeliveryBounced    = "bounced"
+	DeliveryComplained = "complained"
+)
+
+// EmailEvent is a bounce or complaint the mail provider reports for an email it was handed. Bounces
+// are permanent when the address does not exist or refuses mail for good; other bounces, such as a
+// full mailbox, are transient. Complaints are treated as permanent.
+type EmailEvent struct {
+	Type       string     `json:"type"`
+	MessageID  string     `json:"message_id"`
+	Recipient  string     `json:"recipient"`
+	Permanent  bool       `json:"permanent"`
+	Diagnostic string     `json:"diagnostic"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// EmailSuppression is an address that bounced permanently or complained, which is no longer
+// emailed until an Admin removes it
+type EmailSuppression struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Address    string    `gorm:"column:address;not null;uniqueIndex" json:"address"` // Lower case
+	Reason     string    `gorm:"column:reason;size:20;not null;check:reason IN ('bounce', 'complaint')" json:"reason"`
+	Diagnostic string    `gorm:"column:diagnostic" json:"diagnostic,omitempty"`
+	MessageID  string    `gorm:"column:message_id" json:"message_id,omitempty"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (EmailSuppression) TableName() string {
+	return "email_suppression"
+}
+
+// EmailEventResult sums up what was done with the events of a provider notification
+type EmailEventResult struct {
+	Received   int `json:"received"`
+	Matched    int `json:"matched"`    // Events for an email found in the communication log
+	Suppressed int `json:"suppressed"` // Addresses newly suppressed
+}
@@ -0,0 +1,81 @@
+package models
+
+// Templates of the texts sent to a patient's emergency contact
+const (
+	EmergencyTemplateSedationPickup = "sedation_pickup"
+	EmergencyTemplateReadyForPickup = "ready_for_pickup"
+	EmergencyTemplateDelayed        = "delayed"
+	EmergencyTemplateCustom         = "custom"
+)
+
+// EmergencyContactTemplate is a text sent to a patient's emergency contact. Its body names the
+// contact with {contact_name}, the patient with {patient_name} and the clinic with {clinic_name};
+// templates needing a time or a message of the sender's own use {time} and {message}.
+type EmergencyContactTemplate struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Body         string `json:"body"`
+	NeedsTime    bool   `json:"needs_time"`
+	NeedsMessage bool   `json:"needs_message"`
+}
+
+// EmergencyContactTemplates are the texts an emergency contact may be sent
+var EmergencyContactTemplates = []EmergencyContactTemplate{
+	{
+		Name:        EmergencyTemplateSedationPickup,
+		Description: "Ask the contact to collect the patient after treatment under sedation",
+		Body:        "Hello {contact_name}, {patient_name} is having treatment under sedation at {clinic_name} and cannot travel home alone. Please collect them at {time}.",
+		NeedsTime:   true,
+	},
+	{
+		Name:        EmergencyTemplateReadyForPickup,
+		Description: "Tell the contact the patient is ready to be collected",
+		Body:        "Hello {contact_name}, {patient_name} is ready to be collected from {clinic_name}.",
+	},
+	{
+		Name:        EmergencyTemplateDelayed,
+		Description: "Tell the contact the patient's treatment is running late",
+		Body:        "Hello {contact_name}, {patient_name}'s treatment at {clinic_name} is running late. Please collect them at {time} instead.",
+		NeedsTime:   true,
+	},
+	{
+		Name:         EmergencyTemplateCustom,
+		Description:  "Send the contact a message of your own",
+		Body:         "Hello {contact_name}, this is {clinic_name} about {patient_name}. {message}",
+		NeedsMessage: true,
+	},
+}
+
+// EmergencyContactTemplateNamed returns the template with name
+func EmergencyContactTemplateNamed(name string) (EmergencyContactTemplate, bool) {
+	for _, template := range EmergencyContactTemplates {
+		if template.Name == name {
+			return template, true
+		}
+	}
+	return EmergencyContactTemplate{}, false
+}
+
+// EmergencyContactNotice asks for a template to be texted to one of the patient's emergency
+// contacts, their primary contact when ContactID is not set. Time is a clock time, HH:MM.
+type EmergencyContactNotice struct {
+	Template  string `json:"template" binding:"required"`
+	ContactID *uint  `json:"contact_id"`
+	Time      string `json:"time"`
+	Message   string `json:"message"`
+}
+
+// EmergencyContactNoticeResult is a text sent to an emergency contact, with its status in the
+// patient's communication log
+type EmergencyContactNoticeResult struct {
+	ContactID uint   `json:"contact_id"`
+	Recipient string `json:"recipient"`
+	Body      string `json:"body"`
+	Status    string `json:"status"`
+	MessageID string `json:"message_id"`
+}
+
+// EmergencyContactConsent records whether an emergency contact agreed to be texted
+type EmergencyContactConsent struct {
+	Consent *bool `json:"consent" binding:"required"`
+}
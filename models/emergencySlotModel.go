@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmergencySlotTimeLayout formats the clinic times an emergency slot starts and ends at
+const EmergencySlotTimeLayout = "15:04"
+
+// EmergencySlot is a part of the schedule held back for same-day emergencies. Routine and online
+// bookings cannot take it; only emergency bookings can. A slot is either on one Date (YYYY-MM-DD)
+// or on every Weekday (0 for Sunday), and either for one doctor or, without DoctorID, for all.
+type EmergencySlot struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	DoctorID  *string   `gorm:"column:doctor_id;index" json:"doctor_id,omitempty"`
+	Date      string    `gorm:"column:date;size:10;index" json:"date,omitempty"`
+	Weekday   *int      `gorm:"column:weekday;check:weekday BETWEEN 0 AND 6" json:"weekday,omitempty"`
+	StartTime string    `gorm:"column:start_time;size:5;not null" json:"start_time"`
+	EndTime   string    `gorm:"column:end_time;size:5;not null" json:"end_time"`
+	Note      string    `gorm:"column:note" json:"note"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Doctor    *Doctor   `gorm:"foreignKey:DoctorID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (EmergencySlot) TableName() string {
+	return "emergency_slot"
+}
+
+func (s *EmergencySlot) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (s *EmergencySlot) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// On returns when the slot is held on the clinic day day falls on, if it is held that day
+func (s EmergencySlot) On(day time.Time) (start, end time.Time, ok bool) {
+	day = day.In(ClinicLocation())
+	if s.Date != "" && s.Date != day.Format("2006-01-02") {
+		return time.Time{}, time.Time{}, false
+	}
+	if s.Date == "" && (s.Weekday == nil || *s.Weekday != int(day.Weekday())) {
+		return time.Time{}, time.Time{}, false
+	}
+	startClock, err := time.Parse(EmergencySlotTimeLayout, s.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	endClock, err := time.Parse(EmergencySlotTimeLayout, s.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start = time.Date(day.Year(), day.Month(), day.Day(), startClock.Hour(), startClock.Minute(), 0, 0, day.Location())
+	end = time.Date(day.Year(), day.Month(), day.Day(), endClock.Hour(), endClock.Minute(), 0, 0, day.Location())
+	return start, end, true
+}
+
+// Holds reports whether the slot is held for the doctor from start until end
+func (s EmergencySlot) Holds(doctorID string, start, end time.Time) bool {
+	if s.DoctorID != nil && *s.DoctorID != doctorID {
+		return false
+	}
+	slotStart, slotEnd, ok := s.On(start)
+	return ok && slotStart.Before(end) && slotEnd.After(start)
+}
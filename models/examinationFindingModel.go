@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Conditions recorded as structured examination findings
+const (
+	FindingCaries        = "caries"
+	FindingPeriodontitis = "periodontitis"
+	FindingGingivitis    = "gingivitis"
+	FindingAbscess       = "abscess"
+	FindingFracture      = "fracture"
+	FindingMobility      = "mobility"
+	FindingMissingTooth  = "missing_tooth"
+	FindingMalocclusion  = "malocclusion"
+)
+
+// FindingSchema is what may be recorded of a condition. A finding leaves out the attributes whose
+// values are empty, and must name teeth when TeethRequired is set.
+type FindingSchema struct {
+	Condition     string   `json:"condition"`
+	Stages        []string `json:"stages,omitempty"`
+	Grades        []string `json:"grades,omitempty"`
+	Extents       []string `json:"extents,omitempty"`
+	TeethRequired bool     `json:"teeth_required"`
+}
+
+// FindingSchemas are the conditions findings are recorded of. Periodontitis is staged and graded as
+// in the 2017 AAP/EFP classification.
+var FindingSchemas = []FindingSchema{
+	{Condition: FindingCaries, Stages: []string{"enamel", "dentine", "pulp"}, TeethRequired: true},
+	{Condition: FindingPeriodontitis, Stages: []string{"I", "II", "III", "IV"}, Grades: []string{"A", "B", "C"}, Extents: []string{"localized", "generalized", "molar_incisor"}},
+	{Condition: FindingGingivitis, Extents: []string{"localized", "generalized"}},
+	{Condition: FindingAbscess, Stages: []string{"periapical", "periodontal"}, TeethRequired: true},
+	{Condition: FindingFracture, Stages: []string{"enamel", "crown", "root"}, TeethRequired: true},
+	{Condition: FindingMobility, Grades: []string{"1", "2", "3"}, TeethRequired: true},
+	{Condition: FindingMissingTooth, TeethRequired: true},
+	{Condition: FindingMalocclusion, Stages: []string{"class_I", "class_II", "class_III"}},
+}
+
+// FindingSchemaOf returns the schema of condition, or nil when findings of it are not recorded
+func FindingSchemaOf(condition string) *FindingSchema {
+	for i := range FindingSchemas {
+		if FindingSchemas[i].Condition == condition {
+			return &FindingSchemas[i]
+		}
+	}
+	return nil
+}
+
+// Finding is a condition found at an examination, on the teeth named by FDI number when it is
+// limited to some
+type Finding struct {
+	Condition string `json:"condition"`
+	Stage     string `json:"stage,omitempty"`
+	Grade     string `json:"grade,omitempty"`
+	Extent    string `json:"extent,omitempty"`
+	Teeth     []int  `json:"teeth,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// IsValidFDITooth reports whether tooth is an FDI number, of a permanent or a primary tooth
+func IsValidFDITooth(tooth int) bool {
+	quadrant, position := tooth/10, tooth%10
+	switch {
+	case quadrant >= 1 && quadrant <= 4:
+		return position >= 1 && position <= 8
+	case quadrant >= 5 && quadrant <= 8:
+		return position >= 1 && position <= 5
+	}
+	return false
+}
+
+// ExaminationFindings are the findings of an examination, stored as a JSONB array searched through
+// a GIN index
+type ExaminationFindings []Finding
+
+func (f ExaminationFindings) Value() (driver.Value, error) {
+	if f == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (f *ExaminationFindings) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*f = ExaminationFindings{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported examination findings value")
+	}
+	return json.Unmarshal(data, f)
+}
+
+// FindingFilter narrows down the examinations searched for findings. An examination matches when
+// one of its findings is of Condition, in one of Stages and Grades when they are given and on Tooth
+// when it is set, and it was recorded on the days From through To.
+type FindingFilter struct {
+	Condition string     `json:"condition"`
+	Stages    []string   `json:"stages,omitempty"`
+	Grades    []string   `json:"grades,omitempty"`
+	Extent    string     `json:"extent,omitempty"`
+	Tooth     int        `json:"tooth,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Limit     int        `json:"-"`
+}
+
+// FindingQueryTemplate is a clinical audit search kept ready to run, over the last Days days
+type FindingQueryTemplate struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Filter      FindingFilter `json:"filter"`
+	Days        int           `json:"days"`
+}
+
+// FindingQueryTemplates are the clinical audit searches offered with the findings report
+var FindingQueryTemplates = []FindingQueryTemplate{
+	{Name: "periodontitis-advanced", Description: "Patients with periodontitis stage III or IV recorded in the last year",
+		Filter: FindingFilter{Condition: FindingPeriodontitis, Stages: []string{"III", "IV"}}, Days: 365},
+	{Name: "periodontitis-rapid", Description: "Patients with grade C (rapidly progressing) periodontitis recorded in the last year",
+		Filter: FindingFilter{Condition: FindingPeriodontitis, Grades: []string{"C"}}, Days: 365},
+	{Name: "caries-pulp", Description: "Patients with caries reaching the pulp recorded in the last six months",
+		Filter: FindingFilter{Condition: FindingCaries, Stages: []string{"pulp"}}, Days: 182},
+	{Name: "abscesses", Description: "Patients with an abscess recorded in the last three months",
+		Filter: FindingFilter{Condition: FindingAbscess}, Days: 91},
+	{Name: "mobility-severe", Description: "Patients with grade 3 tooth mobility recorded in the last year",
+		Filter: FindingFilter{Condition: FindingMobility, Grades: []string{"3"}}, Days: 365},
+}
+
+// FindingQueryTemplateNamed returns the template called name, or nil when there is none
+func FindingQueryTemplateNamed(name string) *FindingQueryTemplate {
+	for i := range FindingQueryTemplates {
+		if FindingQueryTemplates[i].Name == name {
+			return &FindingQueryTemplates[i]
+		}
+	}
+	return nil
+}
+
+// FindingMatch is an examination with a finding searched for
+type FindingMatch struct {
+	ExaminationID uint                `json:"examination_id"`
+	PatientID     string              `json:"patient_id"`
+	PatientName   string              `json:"patient_name"`
+	ExaminedAt    time.Time           `json:"examined_at"`
+	Findings      ExaminationFindings `json:"findings"` // The examination's findings of the condition searched for
+}
+
+// FindingReport is the outcome of a findings search: the matching examinations, latest first, and
+// how many patients they are of
+type FindingReport struct {
+	Template  string         `json:"template,omitempty"`
+	Filter    FindingFilter  `json:"filter"`
+	Patients  int            `json:"patients"`
+	Truncated bool           `json:"truncated,omitempty"` // Whether more examinations matched than are listed
+	Matches   []FindingMatch `json:"matches"`
+}
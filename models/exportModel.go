@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Export job statuses
+const (
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+)
+
+// Export types
+const (
+	ExportTypeAccounting           = "accounting"
+	ExportTypeControlledSubstances = "controlled_substances"
+)
+
+// Accounting export formats
+const (
+	ExportFormatQuickBooksIIF = "quickbooks_iif"
+	ExportFormatXeroCSV       = "xero_csv"
+)
+
+// Controlled substance register export formats
+const (
+	ExportFormatRegulatorCSV = "regulator_csv"
+)
+
+// ExportJob is a file generated in the background and kept for download, such as the month's
+// billing for the accountant.
+type ExportJob struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Type        string     `gorm:"column:type;size:50;not null" json:"type"`
+	Format      string     `gorm:"column:format;size:50;not null" json:"format"`
+	From        time.Time  `gorm:"column:from_date;type:date;not null" json:"from"`
+	To          time.Time  `gorm:"column:to_date;type:date;not null" json:"to"`
+	Status      string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'running', 'done', 'failed');index" json:"status"`
+	Error       string     `gorm:"column:error;type:text" json:"error,omitempty"`
+	FileName    string     `gorm:"column:file_name;size:255" json:"file_name,omitempty"`
+	ContentType string     `gorm:"column:content_type;size:100" json:"-"`
+	Content     []byte     `gorm:"column:content;type:bytea" json:"-"`
+	StorageKey  string     `gorm:"column:storage_key" json:"-"` // Key of the file in object storage, in which case Content is empty
+	RequestedBy int64      `gorm:"column:requested_by;not null" json:"requested_by"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completed_at,omitempty"`
+}
+
+func (ExportJob) TableName() string {
+	return "export_job"
+}
+
+// AccountingEntry is one bill with what has been paid against it, as posted to the accounting package,
+// or an adjustment to a bill of a closed period with the differences it made
+type AccountingEntry struct {
+	BillingID           string
+	PatientName         string
+	Procedure           string
+	BillingAmount       float64
+	PaidCashAmount      float64
+	PaidInsuranceAmount float64
+	CreatedAt           time.Time
+	Adjustment          bool
+	AdjustmentReason    string
+}
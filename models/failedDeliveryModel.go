@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Statuses of a failed delivery
+const (
+	DeliveryRetrying = "retrying" // Tried again once its next attempt is due
+	DeliveryDead     = "dead"     // Given up on and kept in the dead-letter queue until an admin replays it
+)
+
+// FailedDelivery is a notification an email, SMS or chat webhook could not take, such as an
+// appointment confirmation sent while the SMTP server was down. It is tried again with growing
+// delays and deleted once delivered; after the last attempt it stays in the dead-letter queue.
+// Transport names what it is sent through: email, sms, or webhook:<event> for chat events.
+type FailedDelivery struct {
+	ID            uint                `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Transport     string              `gorm:"column:transport;size:100;not null" json:"transport"`
+	PatientID     string              `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	Purpose       string              `gorm:"column:purpose;size:20" json:"purpose,omitempty"`
+	Recipients    DeferredRecipients  `gorm:"column:recipients;type:jsonb;not null" json:"recipients"`
+	Subject       string              `gorm:"column:subject" json:"subject"`
+	Body          string              `gorm:"column:body;type:text" json:"body"`
+	Attachments   DeferredAttachments `gorm:"column:attachments;type:jsonb;not null;default:'[]'" json:"-"`
+	MessageID     string              `gorm:"column:message_id;index" json:"message_id,omitempty"`
+	Expires       *time.Time          `gorm:"column:expires" json:"expires,omitempty"`
+	Status        string              `gorm:"column:status;size:20;not null;default:retrying;check:status IN ('retrying', 'dead');index:idx_failed_delivery_due,priority:1" json:"status"`
+	Attempts      int                 `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError     string              `gorm:"column:last_error;type:text" json:"last_error"`
+	NextAttemptAt time.Time           `gorm:"column:next_attempt_at;not null;index:idx_failed_delivery_due,priority:2" json:"next_attempt_at"`
+	CreatedAt     time.Time           `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time           `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (FailedDelivery) TableName() string {
+	return "failed_delivery"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FinancialPeriodLayout formats the month a financial period covers
+const FinancialPeriodLayout = "2006-01"
+
+// FinancialPeriod is a month the books have been closed for. Bills created in a closed month can
+// only be corrected through adjustments posted in the current month.
+type FinancialPeriod struct {
+	Period   string    `gorm:"primaryKey;size:7;column:period" json:"period"`
+	ClosedAt time.Time `gorm:"column:closed_at;not null" json:"closed_at"`
+	ClosedBy *int64    `gorm:"column:closed_by" json:"closed_by"`
+}
+
+func (FinancialPeriod) TableName() string {
+	return "financial_period"
+}
+
+// FinancialPeriodOf returns the financial period t falls in
+func FinancialPeriodOf(t time.Time) string {
+	return t.In(ClinicLocation()).Format(FinancialPeriodLayout)
+}
+
+// BillingAdjustment is a correction to a bill of a closed period. The bill shows the corrected
+// amounts, while reports post the differences in the month the adjustment was made. DisputeID is
+// the billing dispute the correction settles, if any.
+type BillingAdjustment struct {
+	ID                  uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID           string    `gorm:"column:billing_id;not null;index" json:"billing_id"`
+	Period              string    `gorm:"size:7;column:period;not null" json:"period"`
+	BillingAmount       float64   `gorm:"column:billing_amount;not null" json:"billing_amount"`
+	PaidCashAmount      float64   `gorm:"column:paid_cash_amount;not null" json:"paid_cash_amount"`
+	PaidInsuranceAmount float64   `gorm:"column:paid_insurance_amount;not null" json:"paid_insurance_amount"`
+	Reason              string    `gorm:"column:reason;type:text;not null" json:"reason"`
+	DisputeID           *uint     `gorm:"column:dispute_id;index" json:"dispute_id,omitempty"`
+	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	CreatedBy           *int64    `gorm:"column:created_by" json:"created_by"`
+}
+
+func (BillingAdjustment) TableName() string {
+	return "billing_adjustment"
+}
+
+func (a *BillingAdjustment) BeforeCreate(tx *gorm.DB) error {
+	a.CreatedBy = actorColumn(tx)
+	return nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Greeting kinds
+const (
+	GreetingBirthday  = "birthday"
+	GreetingMilestone = "milestone"
+)
+
+// Greeting statuses
+const (
+	GreetingSent    = "sent"
+	GreetingSkipped = "skipped"
+)
+
+// Greeting is an entry in the send log of birthday greetings. A patient is greeted at most once
+// a day, whichever replica gets there first.
+type Greeting struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string    `gorm:"column:patient_id;not null;uniqueIndex:idx_greeting_patient_day,priority:1" json:"patient_id"`
+	Day       string    `gorm:"column:day;size:10;not null;uniqueIndex:idx_greeting_patient_day,priority:2;index" json:"day"`
+	Kind      string    `gorm:"column:kind;size:20;not null;check:kind IN ('birthday', 'milestone')" json:"kind"`
+	Age       int       `gorm:"column:age;not null" json:"age"`
+	Channel   string    `gorm:"column:channel;size:20;not null" json:"channel"`
+	Status    string    `gorm:"column:status;size:20;not null;check:status IN ('sent', 'skipped')" json:"status"`
+	SentAt    time.Time `gorm:"column:sent_at;not null" json:"sent_at"`
+	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Greeting) TableName() string {
+	return "greeting"
+}
+
+// GreetingCandidate is a patient who may have their birthday on a given day
+type GreetingCandidate struct {
+	PatientID   string
+	FirstName   string
+	LastName    string
+	Email       string
+	DateOfBirth string
+}
+
+// GreetingLogEntry is a greeting in the send log, with the name of the patient greeted
+type GreetingLogEntry struct {
+	Greeting
+	PatientName string `json:"patient_name"`
+}
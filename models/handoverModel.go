@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Handover note kinds
+const (
+	HandoverKindConcern = "concern"  // Anything the next shift should know of
+	HandoverKindPatient = "patient"  // A patient to watch
+	HandoverKindLabWork = "lab_work" // Lab work pending, expected back on ExpectedOn
+)
+
+// IsValidHandoverKind reports whether kind is one of the handover note kinds
+func IsValidHandoverKind(kind string) bool {
+	switch kind {
+	case HandoverKindConcern, HandoverKindPatient, HandoverKindLabWork:
+		return true
+	}
+	return false
+}
+
+// HandoverNote is what a clinician hands over to the shift working at a branch on a clinic day.
+// Dates are clinic days as YYYY-MM-DD, as closures are.
+type HandoverNote struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Date       string    `gorm:"column:date;size:10;not null;index:idx_handover_note_branch_date,priority:2" json:"date"`
+	Branch     string    `gorm:"column:branch;size:50;not null;index:idx_handover_note_branch_date,priority:1" json:"branch"`
+	Kind       string    `gorm:"column:kind;size:20;not null;default:concern;check:kind IN ('concern', 'patient', 'lab_work')" json:"kind"`
+	PatientID  *string   `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	Body       string    `gorm:"column:body;type:text;not null" json:"body"`
+	ExpectedOn *string   `gorm:"column:expected_on;size:10;index" json:"expected_on,omitempty"` // The day lab work is due back
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy  *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy  *int64    `gorm:"column:updated_by" json:"updated_by"`
+
+	Reads   []HandoverRead `gorm:"foreignKey:NoteID;references:ID;constraint:OnDelete:CASCADE" json:"reads"`
+	Patient *Patient       `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (HandoverNote) TableName() string {
+	return "handover_note"
+}
+
+func (n *HandoverNote) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (n *HandoverNote) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// HandoverRead records that a doctor read a handover note
+type HandoverRead struct {
+	NoteID   uint      `gorm:"primaryKey;autoIncrement:false;column:note_id" json:"-"`
+	DoctorID string    `gorm:"primaryKey;column:doctor_id" json:"doctor_id"`
+	ReadAt   time.Time `gorm:"column:read_at;not null" json:"read_at"`
+	Doctor   *Doctor   `gorm:"foreignKey:DoctorID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (HandoverRead) TableName() string {
+	return "handover_read"
+}
+
+// MorningHuddle is what the team goes through at the start of a clinic day: the notes handed over
+// to the branch, the day's appointments and the lab work due back
+type MorningHuddle struct {
+	Date         string         `json:"date"`
+	Branch       string         `json:"branch"`
+	Handovers    []HandoverNote `json:"handovers"`
+	Unread       int            `json:"unread"` // Handovers the signed-in doctor has not read yet
+	Appointments []QueueEntry   `json:"appointments"`
+	LabArrivals  []HandoverNote `json:"lab_arrivals"`
+}
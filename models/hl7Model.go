@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// HL7 message statuses
+const (
+	HL7StatusApplied       = "applied"
+	HL7StatusPendingReview = "pending_review"
+	HL7StatusRejected      = "rejected"
+	HL7StatusIgnored       = "ignored"
+)
+
+// HL7Demographics are the patient details read from a PID segment
+type HL7Demographics struct {
+	FirstName   string  `gorm:"column:first_name" json:"first_name"`
+	MiddleName  string  `gorm:"column:middle_name" json:"middle_name,omitempty"`
+	LastName    string  `gorm:"column:last_name" json:"last_name"`
+	Sex         string  `gorm:"column:sex" json:"sex"`
+	DateOfBirth string  `gorm:"column:date_of_birth" json:"date_of_birth"`
+	Phone       string  `gorm:"column:phone" json:"phone,omitempty"`
+	Address     Address `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+}
+
+// HL7PatientLink maps the hospital's identifier for a patient to ours
+type HL7PatientLink struct {
+	Facility   string    `gorm:"column:facility;size:100;primaryKey" json:"facility"`
+	ExternalID string    `gorm:"column:external_id;size:100;primaryKey" json:"external_id"`
+	PatientID  string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (HL7PatientLink) TableName() string {
+	return "hl7_patient_link"
+}
+
+// HL7Message is an ADT message received from the hospital and what became of it. Messages that
+// could not be matched safely to a patient wait for staff review.
+type HL7Message struct {
+	ID           uint            `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Facility     string          `gorm:"column:facility;size:100;not null;uniqueIndex:idx_hl7_message_control,priority:1" json:"facility"`
+	ControlID    string          `gorm:"column:control_id;size:100;not null;uniqueIndex:idx_hl7_message_control,priority:2" json:"control_id"`
+	Event        string          `gorm:"column:event;size:10;not null" json:"event"`
+	ExternalID   string          `gorm:"column:external_id;size:100;index" json:"external_id"`
+	Demographics HL7Demographics `gorm:"embedded;embeddedPrefix:pid_" json:"demographics"`
+	Status       string          `gorm:"column:status;size:20;not null;check:status IN ('applied', 'pending_review', 'rejected', 'ignored');index" json:"status"`
+	Reason       string          `gorm:"column:reason;type:text" json:"reason,omitempty"`
+	PatientID    *string         `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	Ack          string          `gorm:"column:ack;size:2" json:"ack"`
+	Raw          string          `gorm:"column:raw;type:text;not null" json:"raw"`
+	ReviewedBy   *int64          `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time      `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time       `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+func (HL7Message) TableName() string {
+	return "hl7_message"
+}
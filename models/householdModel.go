@@ -0,0 +1,146 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How household members are related to the household's guarantor
+const (
+	HouseholdRelationshipGuarantor = "guarantor"
+	HouseholdRelationshipSpouse    = "spouse"
+	HouseholdRelationshipPartner   = "partner"
+	HouseholdRelationshipChild     = "child"
+	HouseholdRelationshipParent    = "parent"
+	HouseholdRelationshipSibling   = "sibling"
+	HouseholdRelationshipDependent = "dependent"
+	HouseholdRelationshipOther     = "other"
+)
+
+// IsValidHouseholdRelationship reports whether relationship is how a member other than the
+// guarantor can be related to the guarantor
+func IsValidHouseholdRelationship(relationship string) bool {
+	switch relationship {
+	case HouseholdRelationshipSpouse, HouseholdRelationshipPartner, HouseholdRelationshipChild, HouseholdRelationshipParent,
+		HouseholdRelationshipSibling, HouseholdRelationshipDependent, HouseholdRelationshipOther:
+		return true
+	}
+	return false
+}
+
+// Household links the patients of a family sharing a guarantor, address and phone. The guarantor
+// pays the family's bills and is sent its statements, and the reminders of members who are minors go
+// to their guardian, the guarantor unless the member names another.
+type Household struct {
+	ID          uint              `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name        string            `gorm:"column:name;not null" json:"name"`
+	GuarantorID string            `gorm:"column:guarantor_id;not null;index" json:"guarantor_id"`
+	Phone       string            `gorm:"column:phone;index" json:"phone"`
+	Address     Address           `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+	CreatedAt   time.Time         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy   *int64            `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy   *int64            `gorm:"column:updated_by" json:"updated_by"`
+	Members     []HouseholdMember `gorm:"foreignKey:HouseholdID;references:ID;constraint:OnDelete:CASCADE" json:"members"`
+}
+
+func (Household) TableName() string {
+	return "household"
+}
+
+func (h *Household) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (h *Household) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// HouseholdMember is a patient of a household; a patient is in one household at most. GuardianID is
+// the member sent the patient's reminders while they are a minor, the guarantor when empty. The
+// patient's name and date of birth are filled in when the household is read.
+type HouseholdMember struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	HouseholdID  uint      `gorm:"column:household_id;not null;index" json:"household_id"`
+	PatientID    string    `gorm:"column:patient_id;not null;uniqueIndex" json:"patient_id"`
+	Relationship string    `gorm:"column:relationship;size:20;not null;check:relationship IN ('guarantor', 'spouse', 'partner', 'child', 'parent', 'sibling', 'dependent', 'other')" json:"relationship"`
+	GuardianID   *string   `gorm:"column:guardian_id" json:"guardian_id,omitempty"`
+	FirstName    string    `gorm:"-" json:"first_name"`
+	LastName     string    `gorm:"-" json:"last_name"`
+	DateOfBirth  string    `gorm:"-" json:"date_of_birth"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Patient      Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (HouseholdMember) TableName() string {
+	return "household_member"
+}
+
+// Ways patients outside any household are matched as likely to share one
+const (
+	HouseholdMatchPhone   = "phone"
+	HouseholdMatchAddress = "address"
+)
+
+// HouseholdSuggestion is a group of patients in no household yet who share a phone or address, the
+// same family entered more than once in the contact directory
+type HouseholdSuggestion struct {
+	Match      string   `json:"match"`
+	Value      string   `json:"value"`
+	PatientIDs []string `json:"patient_ids"`
+}
+
+// HouseholdStatement consolidates the statements of a household's members between two clinic days,
+// From and To (YYYY-MM-DD), into one for the guarantor
+type HouseholdStatement struct {
+	HouseholdID    uint               `json:"household_id"`
+	GuarantorID    string             `json:"guarantor_id"`
+	From           string             `json:"from"`
+	To             string             `json:"to"`
+	OpeningBalance float64            `json:"opening_balance"`
+	Billed         float64            `json:"billed"`
+	Received       float64            `json:"received"`
+	ClosingBalance float64            `json:"closing_balance"`
+	Members        []PatientStatement `json:"members"`
+}
+
+// AppointmentReminder records that one of the reminders of an appointment, the one lead minutes
+// ahead, was sent, and to which patient: the appointment's own or, for a minor in a household, their
+// guardian. A reminder already overtaken by a later one when it came due is recorded as skipped.
+type AppointmentReminder struct {
+	AppointmentID uint      `gorm:"primaryKey;autoIncrement:false;column:appointment_id" json:"appointment_id"`
+	LeadMinutes   int       `gorm:"primaryKey;autoIncrement:false;column:lead_minutes;not null;default:0" json:"lead_minutes"`
+	RecipientID   string    `gorm:"column:recipient_id;not null" json:"recipient_id"`
+	Skipped       bool      `gorm:"column:skipped;not null;default:false" json:"skipped"`
+	SentAt        time.Time `gorm:"column:sent_at;not null" json:"sent_at"`
+}
+
+func (AppointmentReminder) TableName() string {
+	return "appointment_reminder"
+}
+
+// AppointmentReminderCandidate is an upcoming appointment with a reminder due, with the contact
+// details of its patient, of the patient's guarantor, and of the household guardian who is sent the
+// reminder while the patient is a minor
+type AppointmentReminderCandidate struct {
+	AppointmentID     uint
+	LeadMinutes       int // The reminder due
+	PatientID         string
+	PatientFirstName  string
+	PatientEmail      string
+	PatientPhone      string
+	DateOfBirth       string
+	StartsAt          time.Time
+	DoctorFirstName   string
+	DoctorLastName    string
+	GuarantorName     string
+	GuarantorEmail    string
+	GuarantorPhone    string
+	GuardianID        string
+	GuardianFirstName string
+	GuardianEmail     string
+	GuardianPhone     string
+}
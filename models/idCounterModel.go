@@ -0,0 +1,13 @@
+package models
+
+// IDCounter is the last number given to an entity in a year, for IDs whose numbering starts over
+// each year. Entities numbered without a yearly reset keep drawing from their Postgres sequence.
+type IDCounter struct {
+	Entity string `gorm:"primaryKey;column:entity;size:50" json:"entity"`
+	Year   int    `gorm:"primaryKey;column:year;autoIncrement:false" json:"year"`
+	Value  int64  `gorm:"column:value;not null" json:"value"`
+}
+
+func (IDCounter) TableName() string {
+	return "id_counter"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Imaging study statuses
+const (
+	ImagingStatusMatched   = "matched"
+	ImagingStatusUnmatched = "unmatched"
+)
+
+// ImagingStudy is a DICOM image received from the imaging unit. Images whose patient could not be
+// identified wait, unmatched, for staff to link them.
+type ImagingStudy struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID         *string    `gorm:"column:patient_id;index" json:"patient_id"`
+	Status            string     `gorm:"column:status;size:20;not null;check:status IN ('matched', 'unmatched');index" json:"status"`
+	SOPInstanceUID    string     `gorm:"column:sop_instance_uid;size:128;uniqueIndex:idx_imaging_sop_instance,where:sop_instance_uid <> ''" json:"sop_instance_uid"`
+	StudyInstanceUID  string     `gorm:"column:study_instance_uid;size:128;index" json:"study_instance_uid"`
+	StudyDate         *time.Time `gorm:"column:study_date;type:date" json:"study_date"`
+	Modality          string     `gorm:"column:modality;size:16" json:"modality"`
+	SourcePatientID   string     `gorm:"column:source_patient_id;size:128" json:"source_patient_id"`
+	SourcePatientName string     `gorm:"column:source_patient_name;size:255" json:"source_patient_name"`
+	FileName          string     `gorm:"column:file_name;size:255;not null" json:"file_name"`
+	Size              int64      `gorm:"column:size;not null" json:"size"`
+	Content           []byte     `gorm:"column:content;type:bytea;not null" json:"-"`
+	Preview           []byte     `gorm:"column:preview;type:bytea" json:"-"`
+	HasPreview        bool       `gorm:"column:has_preview;not null;default:false" json:"has_preview"`
+	CreatedAt         time.Time  `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	MatchedAt         *time.Time `gorm:"column:matched_at" json:"matched_at,omitempty"`
+	MatchedBy         *int64     `gorm:"column:matched_by" json:"matched_by,omitempty"`
+}
+
+func (ImagingStudy) TableName() string {
+	return "imaging_study"
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InsurerAccount links a user with the Insurer role to the insurance company whose claims and
+// bills they may see
+type InsurerAccount struct {
+	UserID             int64     `gorm:"primaryKey;autoIncrement:false;column:user_id" json:"user_id"`
+	InsuranceCompanyID string    `gorm:"column:insurance_company_id;not null;index" json:"insurance_company_id"`
+	CreatedAt          time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy          *int64    `gorm:"column:created_by" json:"created_by"`
+
+	User             *User             `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	InsuranceCompany *InsuranceCompany `gorm:"foreignKey:InsuranceCompanyID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (InsurerAccount) TableName() string {
+	return "insurer_account"
+}
+
+func (a *InsurerAccount) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// Resources an insurer's claims officer looks up
+const (
+	InsurerResourceClaims   = "claims"
+	InsurerResourceClaim    = "claim"
+	InsurerResourceBillings = "billings"
+	InsurerResourceBilling  = "billing"
+)
+
+// InsurerAccess is a look-up by an insurer's claims officer. Denied is set when the record asked for
+// is not one of their company's members', so attempts to reach other records stand out.
+type InsurerAccess struct {
+	ID                 uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UserID             int64     `gorm:"column:user_id;not null;index" json:"user_id"`
+	InsuranceCompanyID string    `gorm:"column:insurance_company_id;not null;index" json:"insurance_company_id"`
+	Resource           string    `gorm:"column:resource;size:20;not null" json:"resource"`
+	RecordID           string    `gorm:"column:record_id;size:50" json:"record_id,omitempty"`
+	Query              string    `gorm:"column:query" json:"query,omitempty"`
+	Results            int       `gorm:"column:results;not null;default:0" json:"results"`
+	Denied             bool      `gorm:"column:denied;not null;default:false" json:"denied"`
+	IP                 string    `gorm:"column:ip;size:64" json:"ip"`
+	UserAgent          string    `gorm:"column:user_agent" json:"user_agent,omitempty"`
+	AccessedAt         time.Time `gorm:"column:accessed_at;not null;index" json:"accessed_at"`
+}
+
+func (InsurerAccess) TableName() string {
+	return "insurer_access"
+}
+
+// InsurerAccessFilter narrows down the insurer look-ups listed
+type InsurerAccessFilter struct {
+	UserID             *int64
+	InsuranceCompanyID string
+	DeniedOnly         bool
+	Limit              int
+}
+
+// InsurerFilter narrows down the claims and bills an insurer lists, by claim status and the days
+// from and to inclusive
+type InsurerFilter struct {
+	Status string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+}
+
+// InsurerMember is the insured patient a claim or bill is for, as far as their insurer needs to
+// know them
+type InsurerMember struct {
+	PatientID    string `json:"patient_id"`
+	MemberName   string `json:"member_name"`
+	MemberNumber string `json:"member_number,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	DateOfBirth  string `json:"date_of_birth"`
+}
+
+// InsurerClaim is a claim as the insurer sees it, without clinical records
+type InsurerClaim struct {
+	InsurerMember
+	ID          uint       `json:"id"`
+	BillingID   string     `json:"billing_id"`
+	Procedure   string     `json:"procedure"`
+	Amount      float64    `json:"amount"`
+	Status      string     `json:"status"`
+	BatchID     *uint      `json:"batch_id,omitempty"`
+	ServiceDate time.Time  `json:"service_date"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// InsurerBilling is a bill of one of the insurer's members as the insurer sees it, without clinical
+// records
+type InsurerBilling struct {
+	InsurerMember
+	BillingID           string    `json:"billing_id"`
+	Procedure           string    `json:"procedure"`
+	BillingAmount       float64   `json:"billing_amount"`
+	PaidInsuranceAmount float64   `json:"paid_insurance_amount"`
+	PaidCashAmount      float64   `json:"paid_cash_amount"`
+	Balance             float64   `json:"balance"`
+	ClaimID             *uint     `json:"claim_id,omitempty"`
+	ClaimStatus         string    `json:"claim_status,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Integrity run statuses. Only one run is running at a time across replicas.
+const (
+	IntegrityStatusRunning = "running"
+	IntegrityStatusDone    = "done"
+	IntegrityStatusFailed  = "failed"
+)
+
+// What started an integrity run
+const (
+	IntegrityTriggerManual    = "manual"
+	IntegrityTriggerScheduled = "scheduled"
+)
+
+// Integrity checks, each finding one kind of issue
+const (
+	IntegrityCheckBillingWithoutPatient     = "billing_without_patient"
+	IntegrityCheckBillingWithoutDoctor      = "billing_without_doctor"
+	IntegrityCheckAppointmentWithoutPatient = "appointment_without_patient"
+	IntegrityCheckAppointmentWithoutDoctor  = "appointment_without_doctor"
+	IntegrityCheckNegativeBalance           = "negative_balance"
+	IntegrityCheckBalanceMismatch           = "balance_mismatch"
+	IntegrityCheckPatientCache              = "patient_cache_mismatch"
+	IntegrityCheckDoctorCache               = "doctor_cache_mismatch"
+	IntegrityCheckBillingCache              = "billing_cache_mismatch"
+)
+
+// Repairs of integrity issues. Issues without a repair, such as an overpaid bill, are left to staff.
+const (
+	IntegrityRepairDelete    = "delete"    // The orphaned record is deleted
+	IntegrityRepairCancel    = "cancel"    // The appointment is cancelled
+	IntegrityRepairRecompute = "recompute" // The bill's balance and total received are worked out again from its amounts
+	IntegrityRepairEvict     = "evict"     // The cached copy is dropped so the next read loads it from the database
+)
+
+// IntegrityRun is one pass of the integrity checks, started by an admin or on schedule, with the
+// issues it found. Issues are repaired only when Repair is set.
+type IntegrityRun struct {
+	ID            uint             `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Trigger       string           `gorm:"column:trigger_type;size:20;not null" json:"trigger"`
+	Repair        bool             `gorm:"column:repair;not null;default:false" json:"repair"`
+	Status        string           `gorm:"column:status;size:20;not null;default:running;check:status IN ('running', 'done', 'failed');uniqueIndex:idx_integrity_run_running,where:status = 'running'" json:"status"`
+	IssueCount    int              `gorm:"column:issue_count;not null;default:0" json:"issue_count"`
+	RepairedCount int              `gorm:"column:repaired_count;not null;default:0" json:"repaired_count"`
+	Error         string           `gorm:"column:error;type:text" json:"error,omitempty"`
+	RequestedBy   *int64           `gorm:"column:requested_by" json:"requested_by,omitempty"`
+	StartedAt     time.Time        `gorm:"column:started_at;not null;index" json:"started_at"`
+	CompletedAt   *time.Time       `gorm:"column:completed_at" json:"completed_at,omitempty"`
+	Issues        []IntegrityIssue `gorm:"foreignKey:RunID;references:ID;constraint:OnDelete:CASCADE" json:"issues,omitempty"`
+}
+
+func (IntegrityRun) TableName() string {
+	return "integrity_run"
+}
+
+// IntegrityIssue is a record an integrity check found wrong, with the repair it takes if any
+type IntegrityIssue struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	RunID       uint   `gorm:"column:run_id;not null;index" json:"run_id"`
+	Check       string `gorm:"column:check_name;size:50;not null" json:"check"`
+	RecordID    string `gorm:"column:record_id;size:50;not null" json:"record_id"`
+	Detail      string `gorm:"column:detail;type:text" json:"detail"`
+	Repair      string `gorm:"column:repair;size:20" json:"repair,omitempty"`
+	Repaired    bool   `gorm:"column:repaired;not null;default:false" json:"repaired"`
+	RepairError string `gorm:"column:repair_error;type:text" json:"repair_error,omitempty"`
+}
+
+func (IntegrityIssue) TableName() string {
+	return "integrity_issue"
+}
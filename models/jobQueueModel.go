@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Background job queues the admin console reports on
+const (
+	JobQueueExports          = "exports"
+	JobQueuePrintJobs        = "print_jobs"
+	JobQueueIntegrityChecks  = "integrity_checks"
+	JobQueueDeferredMessages = "deferred_messages"
+	JobQueueCampaignMessages = "campaign_messages"
+)
+
+// Statuses of messages held for quiet hours, which are kept until they are sent
+const (
+	DeferredMessageHeld = "held" // Waiting for the end of quiet hours
+	DeferredMessageDue  = "due"  // Past its send time, waiting for the next delivery run
+)
+
+// JobQueue is a queue of background work with how many of its jobs are in each status, and when
+// the oldest job still waiting or running was queued
+type JobQueue struct {
+	Name          string           `json:"name"`
+	Statuses      map[string]int64 `json:"statuses"`
+	OldestWaiting *time.Time       `json:"oldest_waiting,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Contact update request statuses
+const (
+	ContactUpdatePending  = "pending"
+	ContactUpdateApproved = "approved"
+	ContactUpdateRejected = "rejected"
+)
+
+// PatientContactUpdate is a change to a patient's contact details submitted at the kiosk,
+// applied only once a staff member approves it
+type PatientContactUpdate struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID  string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Phone      string     `gorm:"column:phone" json:"phone,omitempty"`
+	Email      string     `gorm:"column:email" json:"email,omitempty"`
+	Address    Address    `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+	Source     string     `gorm:"size:20;column:source;not null" json:"source"`
+	Status     string     `gorm:"size:20;column:status;not null;index;check:status IN ('pending', 'approved', 'rejected')" json:"status"`
+	ReviewedBy string     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Patient    Patient    `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+}
+
+func (PatientContactUpdate) TableName() string {
+	return "patient_contact_update"
+}
+
+// KioskAppointment is the lean view of an appointment shown on the waiting-room kiosk
+type KioskAppointment struct {
+	ID               uint   `json:"id"`
+	PatientID        string `json:"patient_id"`
+	PatientFirstName string `json:"patient_first_name"`
+	DoctorName       string `json:"doctor_name"`
+	DateTime         string `json:"date_time"`
+	Status           string `json:"status"`
+}
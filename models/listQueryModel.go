@@ -0,0 +1,155 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Lists that can be sorted, filtered and saved as views
+const (
+	ListPatients     = "patients"
+	ListAppointments = "appointments"
+	ListBillings     = "billings"
+)
+
+// IsValidList reports whether list is one of the filterable lists
+func IsValidList(list string) bool {
+	switch list {
+	case ListPatients, ListAppointments, ListBillings:
+		return true
+	}
+	return false
+}
+
+// Filter operators. FilterPeriod matches times in a period relative to now, such as this_month.
+const (
+	FilterEq       = "eq"
+	FilterNe       = "ne"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+	FilterIn       = "in"
+	FilterContains = "contains"
+	FilterPeriod   = "period"
+)
+
+// Periods a FilterPeriod condition can match
+const (
+	PeriodToday     = "today"
+	PeriodThisWeek  = "this_week"
+	PeriodThisMonth = "this_month"
+	PeriodLastMonth = "last_month"
+	PeriodThisYear  = "this_year"
+)
+
+// PeriodBounds returns the start and end in clinic time of a period containing or before now.
+// Weeks start on Monday.
+func PeriodBounds(period string, now time.Time) (time.Time, time.Time, error) {
+	day, _ := ClinicDay(now)
+	switch period {
+	case PeriodToday:
+		return day, day.AddDate(0, 0, 1), nil
+	case PeriodThisWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), nil
+	case PeriodThisMonth:
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(0, 1, 0), nil
+	case PeriodLastMonth:
+		end := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		return end.AddDate(0, -1, 0), end, nil
+	case PeriodThisYear:
+		start := time.Date(day.Year(), 1, 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q", period)
+}
+
+// FilterCondition narrows a list to the rows whose Field compares to Value with Op
+type FilterCondition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// FilterCriteria are conditions a row must all meet, stored as a JSONB array
+type FilterCriteria []FilterCondition
+
+func (c FilterCriteria) Value() (driver.Value, error) {
+	if c == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *FilterCriteria) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = FilterCriteria{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported filter criteria value")
+	}
+	return json.Unmarshal(data, c)
+}
+
+// SortField orders a list by Field, descending when Desc is set
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort reads a ?sort= value such as "-balance,last_name": fields separated by commas, each
+// descending when prefixed with a minus
+func ParseSort(value string) []SortField {
+	var fields []SortField
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: strings.TrimSpace(part[1:]), Desc: true}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// ListQuery is how a list is filtered and sorted
+type ListQuery struct {
+	Criteria FilterCriteria
+	Sort     []SortField
+}
+
+// SavedFilter is a view of a list a user saved, such as unpaid insured patients this month. Sort is
+// used unless the request gives its own.
+type SavedFilter struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UserID    int64          `gorm:"column:user_id;not null;uniqueIndex:idx_saved_filter_user_name,priority:1" json:"user_id"`
+	List      string         `gorm:"column:list;size:20;not null;uniqueIndex:idx_saved_filter_user_name,priority:2;check:list IN ('patients', 'appointments', 'billings')" json:"list"`
+	Name      string         `gorm:"column:name;size:100;not null;uniqueIndex:idx_saved_filter_user_name,priority:3" json:"name"`
+	Criteria  FilterCriteria `gorm:"column:criteria;type:jsonb;not null;default:'[]'" json:"criteria"`
+	Sort      string         `gorm:"column:sort;size:255" json:"sort"`
+	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (SavedFilter) TableName() string {
+	return "saved_filter"
+}
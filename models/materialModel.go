@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of treatment material whose lots are recorded for recalls
+const (
+	MaterialKindImplant   = "implant"
+	MaterialKindAbutment  = "abutment"
+	MaterialKindComposite = "composite"
+	MaterialKindCement    = "cement"
+	MaterialKindBoneGraft = "bone_graft"
+	MaterialKindMembrane  = "membrane"
+	MaterialKindOther     = "other"
+)
+
+// IsValidMaterialKind reports whether kind is one of the treatment material kinds
+func IsValidMaterialKind(kind string) bool {
+	switch kind {
+	case MaterialKindImplant, MaterialKindAbutment, MaterialKindComposite, MaterialKindCement,
+		MaterialKindBoneGraft, MaterialKindMembrane, MaterialKindOther:
+		return true
+	}
+	return false
+}
+
+// MaterialUsage records the supplier lot of a material used for a billed procedure, so the patients
+// can be found when the supplier recalls the lot. The patient, doctor and procedure are copied from
+// the bill so traces survive changes to it.
+type MaterialUsage struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID    string    `gorm:"column:billing_id;not null;index" json:"billing_id"`
+	PatientID    string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	DoctorID     string    `gorm:"column:doctor_id;not null" json:"doctor_id"`
+	Procedure    string    `gorm:"column:procedure;not null" json:"procedure"`
+	TreatedAt    time.Time `gorm:"column:treated_at;not null" json:"treated_at"`
+	Kind         string    `gorm:"column:kind;size:20;not null;check:kind IN ('implant', 'abutment', 'composite', 'cement', 'bone_graft', 'membrane', 'other')" json:"kind"`
+	Manufacturer string    `gorm:"column:manufacturer;size:100;not null" json:"manufacturer"`
+	Product      string    `gorm:"column:product;size:255;not null" json:"product"`
+	LotNumber    string    `gorm:"column:lot_number;size:100;not null;index" json:"lot_number"`
+	ExpiresOn    string    `gorm:"column:expires_on;size:10" json:"expires_on,omitempty"` // YYYY-MM-DD, as printed on the packaging
+	Quantity     int       `gorm:"column:quantity;not null;default:1" json:"quantity"`
+	Notes        string    `gorm:"column:notes;type:text" json:"notes,omitempty"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy    *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy    *int64    `gorm:"column:updated_by" json:"-"`
+}
+
+func (MaterialUsage) TableName() string {
+	return "material_usage"
+}
+
+func (u *MaterialUsage) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// MaterialTrace is a patient treated with material from a recalled lot
+type MaterialTrace struct {
+	BillingID    string    `json:"billing_id"`
+	PatientID    string    `json:"patient_id"`
+	PatientName  string    `json:"patient_name"`
+	Phone        string    `json:"phone"`
+	Email        string    `json:"email"`
+	DoctorID     string    `json:"doctor_id"`
+	Procedure    string    `json:"procedure"`
+	TreatedAt    time.Time `json:"treated_at"`
+	Kind         string    `json:"kind"`
+	Manufacturer string    `json:"manufacturer"`
+	Product      string    `json:"product"`
+	LotNumber    string    `json:"lot_number"`
+	Quantity     int       `json:"quantity"`
+}
+
+// MaterialRecallFilter is the lot a supplier recalls, narrowed to a manufacturer or product when
+// the lot number alone is ambiguous
+type MaterialRecallFilter struct {
+	LotNumber    string `json:"lot_number"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+}
+
+// MaterialRecallReport lists everyone treated with material from a recalled lot
+type MaterialRecallReport struct {
+	MaterialRecallFilter
+	PatientCount int             `json:"patient_count"`
+	Patients     []MaterialTrace `json:"patients"`
+}
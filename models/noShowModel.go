@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// NoShowFeatures is what a no-show scorer knows about an upcoming appointment: when it is, when it
+// was booked and how often the patient missed appointments before.
+type NoShowFeatures struct {
+	AppointmentID    uint      `json:"appointment_id"`
+	PatientID        string    `json:"patient_id"`
+	Type             string    `json:"type"`
+	StartsAt         time.Time `json:"starts_at"`
+	BookedAt         time.Time `json:"booked_at"`
+	PastAppointments int       `json:"past_appointments"`
+	PastNoShows      int       `json:"past_no_shows"`
+}
+
+// LeadTime returns how long ahead of its start the appointment was booked
+func (f NoShowFeatures) LeadTime() time.Duration {
+	if f.StartsAt.Before(f.BookedAt) {
+		return 0
+	}
+	return f.StartsAt.Sub(f.BookedAt)
+}
+
+// NoShowRisk is an upcoming appointment as the desk sees it when deciding whom to call to confirm
+type NoShowRisk struct {
+	AppointmentID uint       `json:"appointment_id"`
+	PatientID     string     `json:"patient_id"`
+	PatientName   string     `json:"patient_name"`
+	PatientPhone  string     `json:"patient_phone"`
+	DoctorID      string     `json:"doctor_id"`
+	DoctorName    string     `json:"doctor_name"`
+	DateTime      string     `json:"date_time"`
+	Type          string     `json:"type"`
+	NoShowRisk    float64    `json:"no_show_risk"`
+	HighRisk      bool       `json:"high_risk"`
+	ScoredAt      *time.Time `json:"no_show_scored_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Statuses of an online payment
+const (
+	OnlinePaymentPending   = "pending"
+	OnlinePaymentSucceeded = "succeeded"
+	OnlinePaymentFailed    = "failed"
+)
+
+// OnlinePayment is a payment a patient started from the portal against one of their bills. It
+// stays pending until the payment gateway reports how it ended; a succeeded payment is added to the
+// bill's cash received and the patient is emailed a receipt.
+type OnlinePayment struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Reference        string     `gorm:"column:reference;not null;uniqueIndex" json:"reference"`
+	PatientID        string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	BillingID        string     `gorm:"column:billing_id;not null;index" json:"billing_id"`
+	Amount           float64    `gorm:"column:amount;not null;check:amount > 0" json:"amount"`
+	Currency         string     `gorm:"column:currency;size:3;not null" json:"currency"`
+	Status           string     `gorm:"column:status;not null;default:pending;check:status IN ('pending', 'succeeded', 'failed')" json:"status"`
+	GatewayReference string     `gorm:"column:gateway_reference;index" json:"gateway_reference,omitempty"`
+	PaymentURL       string     `gorm:"column:payment_url" json:"payment_url,omitempty"`
+	FailureReason    string     `gorm:"column:failure_reason" json:"failure_reason,omitempty"`
+	CompletedAt      *time.Time `gorm:"column:completed_at" json:"completed_at,omitempty"`
+	ReceiptSentAt    *time.Time `gorm:"column:receipt_sent_at" json:"receipt_sent_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	Patient          *Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (OnlinePayment) TableName() string {
+	return "online_payment"
+}
+
+// PatientBalance sums up what a patient was billed and has paid
+type PatientBalance struct {
+	PatientID string  `json:"patient_id"`
+	Billed    float64 `json:"billed"`
+	Received  float64 `json:"received"`
+	Balance   float64 `json:"balance"`
+	OpenBills int     `json:"open_bills"`
+}
+
+// PatientStatement lists a patient's bills between two clinic days, From and To (YYYY-MM-DD),
+// with the balance brought forward from before From.
+type PatientStatement struct {
+	PatientID      string    `json:"patient_id"`
+	From           string    `json:"from"`
+	To             string    `json:"to"`
+	OpeningBalance float64   `json:"opening_balance"`
+	Billed         float64   `json:"billed"`
+	Received       float64   `json:"received"`
+	ClosingBalance float64   `json:"closing_balance"`
+	Billings       []Billing `json:"billings"`
+}
+
+// OnlinePaymentReceipt is what a receipt email is written from
+type OnlinePaymentReceipt struct {
+	Payment          OnlinePayment `gorm:"-"`
+	PatientFirstName string
+	PatientEmail     string
+	Procedure        string
+	Balance          float64
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// OverbookingPolicy is how far a doctor may be booked on top of existing appointments. Up to
+// PerHour short appointments, of at most MaxDuration each, may be booked per clinic hour over
+// a single appointment of the doctor's; none are allowed while PerHour is 0.
+type OverbookingPolicy struct {
+	PerHour     int
+	MaxDuration time.Duration
+}
+
+// Permits reports whether an appointment of the duration may be booked over the doctor's
+// overlapping appointments, given how many overbooked ones already start in the same hour
+func (p OverbookingPolicy) Permits(duration time.Duration, overlapping, overbookedInHour int) bool {
+	return p.PerHour > 0 && duration <= p.MaxDuration && overlapping == 1 && overbookedInHour < p.PerHour
+}
+
+// ClinicHour returns the start of the clinic hour t falls in, which overbooking is counted per
+func ClinicHour(t time.Time) time.Time {
+	t = t.In(ClinicLocation())
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// OverbookingReport shows how often overbooked appointments collided with the appointment they
+// were booked over, i.e. both patients turned up
+type OverbookingReport struct {
+	From          string                    `json:"from"`
+	To            string                    `json:"to"`
+	Overbooked    int64                     `json:"overbooked"`
+	Collided      int64                     `json:"collided"`
+	CollisionRate float64                   `json:"collision_rate"`
+	Doctors       []DoctorOverbookingReport `json:"doctors"`
+}
+
+// DoctorOverbookingReport is one doctor's share of an overbooking report
+type DoctorOverbookingReport struct {
+	DoctorID      string  `json:"doctor_id"`
+	DoctorName    string  `json:"doctor_name"`
+	Overbooked    int64   `json:"overbooked"`
+	Collided      int64   `json:"collided"`
+	CollisionRate float64 `json:"collision_rate"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for an ?after= cursor that was not issued by a list
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListCursor marks the last row of a page of a list ordered newest first; the next page starts after it
+type ListCursor struct {
+	CreatedAt time.Time
+	Key       string
+}
+
+// String encodes the cursor for the next_cursor field and the ?after= parameter
+func (c ListCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.Format(time.RFC3339Nano) + "|" + c.Key))
+}
+
+// ParseListCursor decodes a cursor produced by ListCursor.String
+func ParseListCursor(value string) (*ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, key, ok := strings.Cut(string(raw), "|")
+	if !ok || key == "" {
+		return nil, ErrInvalidCursor
+	}
+	cursor := ListCursor{Key: key}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// ListPage is one page of a keyset-paginated list and the cursor of the next page
+type ListPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
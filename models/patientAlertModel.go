@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Patient alert types
+const (
+	AlertTypeAnticoagulant     = "anticoagulant"
+	AlertTypePenicillinAllergy = "penicillin_allergy"
+	AlertTypePreMedication     = "pre_medication"
+	AlertTypeSafeguarding      = "safeguarding"
+	AlertTypeOther             = "other"
+)
+
+// IsValidAlertType reports whether alertType is one of the patient alert types
+func IsValidAlertType(alertType string) bool {
+	switch alertType {
+	case AlertTypeAnticoagulant, AlertTypePenicillinAllergy, AlertTypePreMedication, AlertTypeSafeguarding, AlertTypeOther:
+		return true
+	}
+	return false
+}
+
+// Patient alert severities. Warning and critical alerts must be acknowledged before treatment starts.
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// alertSeverityRank orders the severities, most severe last
+var alertSeverityRank = map[string]int{AlertSeverityInfo: 1, AlertSeverityWarning: 2, AlertSeverityCritical: 3}
+
+// IsValidAlertSeverity reports whether severity is one of the patient alert severities
+func IsValidAlertSeverity(severity string) bool {
+	return alertSeverityRank[severity] > 0
+}
+
+// AlertSeverityRank returns how severe severity is, higher being more severe
+func AlertSeverityRank(severity string) int {
+	return alertSeverityRank[severity]
+}
+
+// PatientAlert is a clinical or safeguarding risk staff must be aware of when treating the patient.
+// Alerts no longer relevant are deactivated rather than deleted, so the history is kept.
+type PatientAlert struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Type      string    `gorm:"column:type;size:30;not null;check:type IN ('anticoagulant', 'penicillin_allergy', 'pre_medication', 'safeguarding', 'other')" json:"type"`
+	Severity  string    `gorm:"column:severity;size:10;not null;check:severity IN ('info', 'warning', 'critical')" json:"severity"`
+	Note      string    `gorm:"column:note;type:text" json:"note,omitempty"`
+	Active    bool      `gorm:"column:active;not null" json:"active"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PatientAlert) TableName() string {
+	return "patient_alert"
+}
+
+func (a *PatientAlert) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (a *PatientAlert) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// NeedsAcknowledgement reports whether the alert must be acknowledged before treatment starts
+func (a PatientAlert) NeedsAcknowledgement() bool {
+	return a.Active && AlertSeverityRank(a.Severity) >= AlertSeverityRank(AlertSeverityWarning)
+}
+
+// PatientAlertAcknowledgement records that an alert was read before treating the patient at an appointment
+type PatientAlertAcknowledgement struct {
+	ID            uint         `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AlertID       uint         `gorm:"column:alert_id;not null;uniqueIndex:idx_patient_alert_acknowledgement,priority:2" json:"alert_id"`
+	AppointmentID uint         `gorm:"column:appointment_id;not null;uniqueIndex:idx_patient_alert_acknowledgement,priority:1" json:"appointment_id"`
+	CreatedAt     time.Time    `gorm:"column:created_at;autoCreateTime" json:"acknowledged_at"`
+	CreatedBy     *int64       `gorm:"column:created_by" json:"acknowledged_by"`
+	UpdatedBy     *int64       `gorm:"column:updated_by" json:"-"`
+	Alert         PatientAlert `gorm:"foreignKey:AlertID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PatientAlertAcknowledgement) TableName() string {
+	return "patient_alert_acknowledgement"
+}
+
+func (a *PatientAlertAcknowledgement) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// VisitAlert is an active alert of the patient and whether it was acknowledged for the visit
+type VisitAlert struct {
+	PatientAlert
+	Acknowledgement *PatientAlertAcknowledgement `json:"acknowledgement"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// PatientChart is a patient's complete clinical record, exported as one document when the patient
+// transfers to another practice: their details, alerts, allergies and visits, every examination with
+// its findings, treatment plan and prescription, and previews of their radiographs
+type PatientChart struct {
+	Patient        Patient
+	Alerts         []PatientAlert
+	Allergies      []PatientAllergy
+	Appointments   []Appointment // With their doctors
+	Vitals         []Vitals
+	Examinations   []Examination
+	TreatmentPlans []TreatmentPlan
+	Prescriptions  []Prescription
+	Images         []ImagingStudy // With their previews, newest first
+}
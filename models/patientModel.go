@@ -1,24 +1,61 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Doctor model
 type Doctor struct {
-	ID           string        `gorm:"primaryKey;column:id" json:"id"`
-	FirstName    string        `gorm:"column:first_name;not null" json:"first_name"`
-	LastName     string        `gorm:"column:last_name;not null;index" json:"last_name"`
-	CreatedAt    time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	Appointments []Appointment `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
-	Billings     []Billing     `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
+	ID            string  `gorm:"primaryKey;column:id" json:"id"`
+	FirstName     string  `gorm:"column:first_name;not null" json:"first_name"`
+	LastName      string  `gorm:"column:last_name;not null;index" json:"last_name"`
+	UserID        *int64  `gorm:"column:user_id;uniqueIndex" json:"user_id,omitempty"`
+	LicenceNumber *string `gorm:"column:licence_number;size:50;uniqueIndex" json:"licence_number,omitempty"`
+	Specialty     string  `gorm:"column:specialty;size:50;not null;default:general;check:specialty IN ('general', 'orthodontics', 'oral_surgery', 'pediatric', 'periodontics', 'endodontics', 'prosthodontics');index" json:"specialty"`
+	PhotoURL      *string `gorm:"column:photo_url;size:500" json:"photo_url,omitempty"` // Portrait shown on the clinic website
+	// The last days the doctor's practising licence, indemnity insurance and CPD compliance are valid
+	LicenceExpiresOn   *time.Time    `gorm:"column:licence_expires_on;type:date" json:"licence_expires_on,omitempty"`
+	IndemnityExpiresOn *time.Time    `gorm:"column:indemnity_expires_on;type:date" json:"indemnity_expires_on,omitempty"`
+	CPDExpiresOn       *time.Time    `gorm:"column:cpd_expires_on;type:date" json:"cpd_expires_on,omitempty"`
+	CreatedAt          time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time     `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	Appointments       []Appointment `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
+	Billings           []Billing     `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
 }
 
 func (Doctor) TableName() string {
 	return "doctor"
 }
 
-// Patient model
+// Guarantor is the responsible adult of a patient, who is sent the patient's bills, statements and
+// reminders and gives consent on the patient's behalf. Patients who are minors must have one.
+type Guarantor struct {
+	Name         string `gorm:"column:name" json:"name"`
+	Relationship string `gorm:"column:relationship;size:20" json:"relationship"`
+	Phone        string `gorm:"column:phone" json:"phone"`
+	Email        string `gorm:"column:email" json:"email"`
+}
+
+// Trimmed returns the guarantor without surrounding spaces in its fields
+func (g Guarantor) Trimmed() Guarantor {
+	return Guarantor{
+		Name:         strings.TrimSpace(g.Name),
+		Relationship: strings.TrimSpace(g.Relationship),
+		Phone:        strings.TrimSpace(g.Phone),
+		Email:        strings.TrimSpace(g.Email),
+	}
+}
+
+// IsZero reports whether no guarantor is filled in
+func (g Guarantor) IsZero() bool {
+	return g.Trimmed() == Guarantor{}
+}
+
+// Patient model. Alerts are the patient's active risk alerts and Summary an outline of their record,
+// both filled in when a single patient is read.
 type Patient struct {
 	ID                string             `gorm:"primaryKey;column:id" json:"id"`
 	FirstName         string             `gorm:"column:first_name;not null" json:"first_name"`
@@ -35,9 +72,22 @@ type Patient struct {
 	PlaceOfWork       string             `gorm:"column:place_of_work" json:"place_of_work"`
 	Phone             string             `gorm:"column:phone" json:"phone"`
 	Email             string             `gorm:"column:email" json:"email"`
-	Address           string             `gorm:"column:address" json:"address"`
+	EmailBouncedAt    *time.Time         `gorm:"column:email_bounced_at" json:"email_bounced_at,omitempty"` // When the email bounced permanently or was reported as spam
+	Language          string             `gorm:"column:language;size:10" json:"language"`
+	Address           Address            `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+	Location          GeoLocation        `gorm:"embedded;embeddedPrefix:address_" json:"location"`
+	Guarantor         Guarantor          `gorm:"embedded;embeddedPrefix:guarantor_" json:"guarantor"`
+	NationalID        string             `gorm:"column:national_id;index" json:"national_id"`
+	MemberNumber      string             `gorm:"column:member_number" json:"member_number"`
+	UserID            *int64             `gorm:"column:user_id;uniqueIndex" json:"user_id,omitempty"`
+	CustomFields      CustomFieldValues  `gorm:"column:custom_fields;type:jsonb;not null;default:'{}'" json:"custom_fields"`
+	Attribution       Attribution        `gorm:"embedded" json:"attribution"`
+	Alerts            []PatientAlert     `gorm:"-" json:"alerts,omitempty"`
+	Summary           *PatientSummary    `gorm:"-" json:"summary,omitempty"`
 	CreatedAt         time.Time          `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time          `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
 	EmergencyContacts []EmergencyContact `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+	PrimaryContact    *EmergencyContact  `gorm:"foreignKey:PatientID;references:ID" json:"primary_contact,omitempty"`
 	Examinations      []Examination      `gorm:"foreignKey:PatientID;references:ID" json:"-"`
 	Billings          []Billing          `gorm:"foreignKey:PatientID;references:ID" json:"-"`
 	TreatmentPlans    []TreatmentPlan    `gorm:"foreignKey:PatientID;references:ID" json:"-"`
@@ -50,22 +100,53 @@ func (Patient) TableName() string {
 
 // EmergencyContact model
 type EmergencyContact struct {
-	ID           uint    `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
-	PatientID    string  `gorm:"column:patient_id;not null;index;uniqueIndex:idx_patient_phone" json:"patient_id"`
-	Name         string  `gorm:"column:name;not null" json:"name"`
-	Phone        string  `gorm:"column:phone;not null;uniqueIndex:idx_patient_phone" json:"phone"`
-	Relationship string  `gorm:"column:relationship;not null" json:"relationship"`
-	Patient      Patient `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+	ID           uint       `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
+	PatientID    string     `gorm:"column:patient_id;not null;index;uniqueIndex:idx_patient_phone;uniqueIndex:idx_emergency_contact_primary,where:is_primary" json:"patient_id"`
+	Name         string     `gorm:"column:name;not null" json:"name"`
+	Phone        string     `gorm:"column:phone;not null;uniqueIndex:idx_patient_phone" json:"phone"`
+	Relationship string     `gorm:"column:relationship;not null" json:"relationship"`
+	Primary      bool       `gorm:"column:is_primary;not null;default:false" json:"primary"`
+	SMSConsent   bool       `gorm:"column:sms_consent;not null;default:false" json:"sms_consent"` // The contact agreed to be texted about the patient, recorded by SMSConsentBy
+	SMSConsentAt *time.Time `gorm:"column:sms_consent_at" json:"sms_consent_at,omitempty"`
+	SMSConsentBy *int64     `gorm:"column:sms_consent_by" json:"sms_consent_by,omitempty"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	Patient      Patient    `gorm:"foreignKey:PatientID;references:ID" json:"-"`
 }
 
 func (EmergencyContact) TableName() string {
 	return "emergency_contact"
 }
 
+// Emergency contact relationships
+const (
+	RelationshipSpouse    = "spouse"
+	RelationshipPartner   = "partner"
+	RelationshipParent    = "parent"
+	RelationshipChild     = "child"
+	RelationshipSibling   = "sibling"
+	RelationshipGuardian  = "guardian"
+	RelationshipRelative  = "relative"
+	RelationshipFriend    = "friend"
+	RelationshipCaregiver = "caregiver"
+	RelationshipOther     = "other"
+)
+
+// IsValidRelationship reports whether relationship is one of the emergency contact relationships
+func IsValidRelationship(relationship string) bool {
+	switch relationship {
+	case RelationshipSpouse, RelationshipPartner, RelationshipParent, RelationshipChild, RelationshipSibling,
+		RelationshipGuardian, RelationshipRelative, RelationshipFriend, RelationshipCaregiver, RelationshipOther:
+		return true
+	}
+	return false
+}
+
 // InsuranceCompany model
 type InsuranceCompany struct {
-	ID   string `gorm:"primaryKey;column:id" json:"id"`
-	Name string `gorm:"column:name;unique;not null" json:"name"`
+	ID          string    `gorm:"primaryKey;column:id" json:"id"`
+	Name        string    `gorm:"column:name;unique;not null" json:"name"`
+	ClaimFormat string    `gorm:"column:claim_format;size:10;not null;default:csv;check:claim_format IN ('csv', 'xml')" json:"claim_format"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
 }
 
 func (InsuranceCompany) TableName() string {
@@ -74,29 +155,56 @@ func (InsuranceCompany) TableName() string {
 
 // Examination model
 type Examination struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
-	PatientID string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
-	Report    string    `gorm:"column:report;not null" json:"report"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+	ID          uint                    `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
+	PatientID   string                  `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Report      string                  `gorm:"column:report;not null" json:"report"`
+	Findings    ExaminationFindings     `gorm:"column:findings;type:jsonb;not null;default:'[]';index:idx_examination_findings,type:gin" json:"findings"`
+	TemplateID  *uint                   `gorm:"-" json:"template_id,omitempty"` // Template whose text is put ahead of the report on creation
+	CreatedAt   time.Time               `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time               `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	CreatedBy   *int64                  `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy   *int64                  `gorm:"column:updated_by" json:"updated_by"`
+	Patient     Patient                 `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+	Attachments []ExaminationAttachment `gorm:"foreignKey:ExaminationID;references:ID;constraint:OnDelete:CASCADE" json:"attachments"`
 }
 
 func (Examination) TableName() string {
 	return "examination"
 }
 
-// Billing model
+func (e *Examination) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (e *Examination) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Billing model. Bills of a closed financial period are only updated with Adjustment set and
+// an AdjustmentReason, and the change is recorded as a BillingAdjustment, linked to the open
+// dispute DisputeID when given.
 type Billing struct {
-	BillingID           string    `gorm:"primaryKey;column:billing_id" json:"billing_id"`
-	PatientID           string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
-	DoctorID            string    `gorm:"column:doctor_id;not null;index" json:"doctor_id"`
+	BillingID           string    `gorm:"primaryKey;column:billing_id;index:idx_billing_created_id,priority:2" json:"billing_id"`
+	PatientID           string    `gorm:"column:patient_id;not null;index;index:idx_billing_patient_created,priority:1" json:"patient_id"`
+	DoctorID            string    `gorm:"column:doctor_id;not null;index;index:idx_billing_doctor_created,priority:1" json:"doctor_id"`
 	Procedure           string    `gorm:"column:procedure;not null" json:"procedure"`
+	ProcedureID         *uint     `gorm:"column:procedure_id" json:"procedure_id,omitempty"`
+	ContractRateID      *uint     `gorm:"column:contract_rate_id" json:"contract_rate_id,omitempty"`
+	ContractAmount      *float64  `gorm:"column:contract_amount" json:"contract_amount,omitempty"`
 	BillingAmount       float64   `gorm:"column:billing_amount;not null" json:"billing_amount"`
 	PaidCashAmount      float64   `gorm:"column:paid_cash_amount" json:"paid_cash_amount"`
 	PaidInsuranceAmount float64   `gorm:"column:paid_insurance_amount" json:"paid_insurance_amount"`
 	Balance             float64   `gorm:"column:balance" json:"balance"`
 	TotalReceived       float64   `gorm:"column:total_received" json:"total_received"`
-	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime;index:idx_billing_patient_created,priority:2;index:idx_billing_doctor_created,priority:2;index:idx_billing_created_id,priority:1" json:"created_at"`
+	UpdatedAt           time.Time `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	CreatedBy           *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy           *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Adjustment          bool      `gorm:"-" json:"adjustment,omitempty"`
+	AdjustmentReason    string    `gorm:"-" json:"adjustment_reason,omitempty"`
+	DisputeID           *uint     `gorm:"-" json:"dispute_id,omitempty"`
 	Patient             Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
 	Doctor              Doctor    `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
 }
@@ -105,31 +213,232 @@ func (Billing) TableName() string {
 	return "billing"
 }
 
-// TreatmentPlan model
+func (b *Billing) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (b *Billing) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ComputeTotals works the bill's total received and balance out from its amount and payments.
+// Every path writing a bill's amounts goes through it, and the integrity checks compare stored
+// bills with the same arithmetic.
+func (b *Billing) ComputeTotals() {
+	b.TotalReceived = b.PaidCashAmount + b.PaidInsuranceAmount
+	b.Balance = b.BillingAmount - b.TotalReceived
+}
+
+// TreatmentPlan model. Every revision of the plan or its estimated cost is kept as a
+// TreatmentPlanVersion, and Version is the number of the current one.
 type TreatmentPlan struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
-	PatientID string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
-	Plan      string    `gorm:"column:plan;not null" json:"plan"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+	ID            uint      `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
+	PatientID     string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Plan          string    `gorm:"column:plan;not null" json:"plan"`
+	EstimatedCost *float64  `gorm:"column:estimated_cost" json:"estimated_cost"`
+	Version       int       `gorm:"column:version;not null;default:1" json:"version"`
+	Reason        string    `gorm:"-" json:"reason,omitempty"`      // Why the plan was revised, kept on the new version
+	TemplateID    *uint     `gorm:"-" json:"template_id,omitempty"` // Template whose text is put ahead of the plan on creation
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	CreatedBy     *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy     *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Patient       Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
 }
 
 func (TreatmentPlan) TableName() string {
 	return "treatment_plan"
 }
 
+func (p *TreatmentPlan) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (p *TreatmentPlan) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Appointment statuses
+const (
+	AppointmentStatusScheduled  = "scheduled"
+	AppointmentStatusCheckedIn  = "checked_in"
+	AppointmentStatusInProgress = "in_progress"
+	AppointmentStatusFulfilled  = "fulfilled"
+	AppointmentStatusCancelled  = "cancelled"
+)
+
+// Appointment types, each with its own default duration and calendar colour
+const (
+	AppointmentTypeConsultation = "consultation"
+	AppointmentTypeHygiene      = "hygiene"
+	AppointmentTypeSurgery      = "surgery"
+	AppointmentTypeEmergency    = "emergency"
+)
+
+// AppointmentTypes lists the appointment types in the order the calendar shows them
+var AppointmentTypes = []string{AppointmentTypeConsultation, AppointmentTypeHygiene, AppointmentTypeSurgery, AppointmentTypeEmergency}
+
+// Appointment origins
+const (
+	AppointmentOriginBooked = "booked"
+	AppointmentOriginWalkIn = "walk_in"
+)
+
+// IsValidAppointmentStatus reports whether status is one of the appointment statuses
+func IsValidAppointmentStatus(status string) bool {
+	switch status {
+	case AppointmentStatusScheduled, AppointmentStatusCheckedIn, AppointmentStatusInProgress, AppointmentStatusFulfilled, AppointmentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsValidAppointmentType reports whether t is one of the appointment types
+func IsValidAppointmentType(t string) bool {
+	switch t {
+	case AppointmentTypeConsultation, AppointmentTypeHygiene, AppointmentTypeSurgery, AppointmentTypeEmergency:
+		return true
+	}
+	return false
+}
+
 // Appointment model
 type Appointment struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement;column:id;index" json:"id"`
-	PatientID string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
-	DoctorID  string    `gorm:"column:doctor_id;not null;index" json:"doctor_id"`
-	DateTime  string    `gorm:"column:date_time;not null;index" json:"date_time"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	Status    string    `gorm:"column:status;check:status IN ('scheduled', 'fulfilled', 'cancelled');not null" json:"status"`
-	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID" json:"patient"`
-	Doctor    Doctor    `gorm:"foreignKey:DoctorID;references:ID" json:"doctor"`
+	ID              uint              `gorm:"primaryKey;autoIncrement;column:id;index;index:idx_appointment_created_id,priority:2" json:"id"`
+	PatientID       string            `gorm:"column:patient_id;not null;index;index:idx_appointment_patient_created,priority:1" json:"patient_id"`
+	DoctorID        string            `gorm:"column:doctor_id;not null;index;index:idx_appointment_doctor_date_time,priority:1" json:"doctor_id"`
+	DateTime        string            `gorm:"column:date_time;not null;index;index:idx_appointment_doctor_date_time,priority:2" json:"date_time"`
+	CreatedAt       time.Time         `gorm:"column:created_at;autoCreateTime;index:idx_appointment_patient_created,priority:2;index:idx_appointment_created_id,priority:1" json:"created_at"`
+	UpdatedAt       time.Time         `gorm:"column:updated_at;autoUpdateTime;index" json:"updated_at"`
+	Status          string            `gorm:"column:status;check:status IN ('scheduled', 'checked_in', 'in_progress', 'fulfilled', 'cancelled');not null" json:"status"`
+	Origin          string            `gorm:"column:origin;not null;default:booked;check:origin IN ('booked', 'walk_in')" json:"origin"`
+	Type            string            `gorm:"column:type;size:20;not null;default:consultation;check:type IN ('consultation', 'hygiene', 'surgery', 'emergency')" json:"type"`
+	ProcedureID     *uint             `gorm:"column:procedure_id;index" json:"procedure_id,omitempty"`
+	CheckedInAt     *time.Time        `gorm:"column:checked_in_at" json:"checked_in_at,omitempty"`
+	SeenAt          *time.Time        `gorm:"column:seen_at" json:"seen_at,omitempty"`
+	ConfirmedAt     *time.Time        `gorm:"column:confirmed_at" json:"confirmed_at,omitempty"` // When the patient confirmed they are coming
+	ChairID         *uint             `gorm:"column:chair_id;index:idx_appointment_chair_starts,priority:1" json:"chair_id,omitempty"`
+	StartsAt        *time.Time        `gorm:"column:starts_at;index:idx_appointment_chair_starts,priority:2;index" json:"starts_at,omitempty"`
+	EndsAt          *time.Time        `gorm:"column:ends_at" json:"ends_at,omitempty"`
+	Overbooked      bool              `gorm:"column:overbooked;not null;default:false" json:"overbooked"`
+	EmergencyReason string            `gorm:"column:emergency_reason;type:text" json:"emergency_reason,omitempty"`
+	NoShowRisk      *float64          `gorm:"column:no_show_risk" json:"no_show_risk,omitempty"`
+	ScoredAt        *time.Time        `gorm:"column:no_show_scored_at" json:"no_show_scored_at,omitempty"`
+	CustomFields    CustomFieldValues `gorm:"column:custom_fields;type:jsonb;not null;default:'{}'" json:"custom_fields"`
+	ReminderLeads   ReminderLeads     `gorm:"column:reminder_leads;type:jsonb" json:"reminder_leads"` // Overrides the cadence of the type when set
+	Attribution     Attribution       `gorm:"embedded" json:"attribution"`
+	// EligibilityStatus is the status of the insurance eligibility check of an insured patient's
+	// appointment, kept by the eligibility checks only
+	EligibilityStatus string `gorm:"column:eligibility_status;size:20;not null;default:''" json:"eligibility_status,omitempty"`
+	CreatedBy         *int64 `gorm:"column:created_by;index" json:"created_by"`
+	UpdatedBy         *int64 `gorm:"column:updated_by" json:"updated_by"`
+	// OverridePatientConflict books the appointment even though the patient has another at that time
+	OverridePatientConflict bool              `gorm:"-" json:"override_patient_conflict,omitempty"`
+	PatientConflicts        []PatientConflict `gorm:"-" json:"patient_conflicts,omitempty"` // The patient's appointments it was booked over
+	Patient                 Patient           `gorm:"foreignKey:PatientID;references:ID" json:"patient"`
+	Doctor                  Doctor            `gorm:"foreignKey:DoctorID;references:ID" json:"doctor"`
 }
 
 func (Appointment) TableName() string {
 	return "appointment"
 }
+
+func (a *Appointment) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (a *Appointment) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// AfterSave shows the saved appointment's times in clinic time, as AfterFind does for loaded ones
+func (a *Appointment) AfterSave(tx *gorm.DB) error {
+	a.inClinicTime()
+	return nil
+}
+
+// AfterFind shows the appointment's times, which are stored in UTC, in clinic time
+func (a *Appointment) AfterFind(tx *gorm.DB) error {
+	a.inClinicTime()
+	return nil
+}
+
+func (a *Appointment) inClinicTime() {
+	a.DateTime = ClinicDateTime(a.DateTime)
+	a.StartsAt, a.EndsAt = inClinicTime(a.StartsAt), inClinicTime(a.EndsAt)
+	a.CheckedInAt, a.SeenAt = inClinicTime(a.CheckedInAt), inClinicTime(a.SeenAt)
+}
+
+// QueueEntry is an appointment in today's waiting queue with its computed waiting time
+type QueueEntry struct {
+	AppointmentID     uint       `json:"appointment_id"`
+	PatientID         string     `json:"patient_id"`
+	PatientName       string     `json:"patient_name"`
+	DoctorID          string     `json:"doctor_id"`
+	DoctorName        string     `json:"doctor_name"`
+	DateTime          string     `json:"date_time"`
+	Status            string     `json:"status"`
+	Origin            string     `json:"origin"`
+	CheckedInAt       *time.Time `json:"checked_in_at"`
+	SeenAt            *time.Time `json:"seen_at"`
+	NoShowRisk        *float64   `json:"no_show_risk,omitempty"`
+	EligibilityStatus string     `json:"eligibility_status,omitempty"`
+	WaitMinutes       float64    `json:"wait_minutes"`
+}
+
+// DoctorAppointment is an appointment as listed in the doctors' mobile app
+type DoctorAppointment struct {
+	ID          uint       `json:"id"`
+	PatientID   string     `json:"patient_id"`
+	PatientName string     `json:"patient_name"`
+	DateTime    string     `json:"date_time"`
+	Status      string     `json:"status"`
+	Origin      string     `json:"origin"`
+	Type        string     `json:"type"`
+	Color       string     `json:"color"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// PatientVisit is an appointment as the patient it is for sees it in the portal: who they see,
+// when and where. Location names the room and chair once one is assigned.
+type PatientVisit struct {
+	ID          uint       `json:"id"`
+	DateTime    string     `json:"date_time"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Status      string     `json:"status"`
+	Type        string     `json:"type"`
+	DoctorName  string     `json:"doctor_name"`
+	Location    string     `json:"location,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// PatientVisits are a patient's upcoming visits, soonest first, and their past ones, latest first
+type PatientVisits struct {
+	Upcoming []PatientVisit `json:"upcoming"`
+	Past     []PatientVisit `json:"past"`
+}
+
+// DoctorPatientSummary is the one-screen patient overview shown in the doctors' mobile app
+type DoctorPatientSummary struct {
+	ID                string     `json:"id"`
+	FirstName         string     `json:"first_name"`
+	LastName          string     `json:"last_name"`
+	Sex               string     `json:"sex"`
+	DateOfBirth       string     `json:"date_of_birth"`
+	Phone             string     `json:"phone"`
+	Insured           bool       `json:"insured"`
+	InsuranceCompany  string     `json:"insurance_company"`
+	LastVisit         string     `json:"last_visit,omitempty"`
+	NextAppointment   string     `json:"next_appointment,omitempty"`
+	LatestExamination string     `json:"latest_examination,omitempty"`
+	ExaminedAt        *time.Time `json:"examined_at,omitempty"`
+	TreatmentPlan     string     `json:"treatment_plan,omitempty"`
+	Balance           float64    `json:"balance"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// PatientSummaryRecent is how many of the latest appointments and bills a patient summary shows
+const PatientSummaryRecent = 5
+
+// PatientSummary outlines a patient's record: how much of it there is, the latest of it and what is
+// owed. The full history is read a page at a time from the patient's history endpoints.
+type PatientSummary struct {
+	Appointments         int64           `json:"appointments"`
+	Billings             int64           `json:"billings"`
+	Examinations         int64           `json:"examinations"`
+	TreatmentPlans       int64           `json:"treatment_plans"`
+	UpcomingAppointments []Appointment   `json:"upcoming_appointments"` // The next ones, soonest first
+	RecentAppointments   []Appointment   `json:"recent_appointments"`   // The last ones before now, latest first
+	RecentBillings       []Billing       `json:"recent_billings"`
+	LatestExamination    *Examination    `json:"latest_examination,omitempty"`
+	LatestTreatmentPlan  *TreatmentPlan  `json:"latest_treatment_plan,omitempty"`
+	Balance              *PatientBalance `json:"balance"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment is money staff recorded against a bill, PaymentMethodCash or PaymentMethodInsurance; a
+// correction downwards is recorded as a negative amount. With the bill's succeeded online payments
+// it is the ledger the bill's paid amounts must add up to.
+type Payment struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BillingID string    `gorm:"column:billing_id;not null;index" json:"billing_id"`
+	Method    string    `gorm:"column:method;size:10;not null;check:method IN ('cash', 'insurance')" json:"method"`
+	Amount    float64   `gorm:"column:amount;not null" json:"amount"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+}
+
+func (Payment) TableName() string {
+	return "payment"
+}
+
+func (p *Payment) BeforeCreate(tx *gorm.DB) error {
+	p.CreatedBy = actorColumn(tx)
+	return nil
+}
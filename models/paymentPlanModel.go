@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment plan statuses
+const (
+	PaymentPlanStatusActive    = "active"
+	PaymentPlanStatusCompleted = "completed"
+	PaymentPlanStatusCancelled = "cancelled"
+)
+
+// Installment statuses; pending installments become overdue once their due date has passed
+const (
+	InstallmentStatusPending = "pending"
+	InstallmentStatusOverdue = "overdue"
+	InstallmentStatusPaid    = "paid"
+)
+
+// IsValidPaymentPlanStatus reports whether status is one of the payment plan statuses
+func IsValidPaymentPlanStatus(status string) bool {
+	switch status {
+	case PaymentPlanStatusActive, PaymentPlanStatusCompleted, PaymentPlanStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// PaymentPlan spreads the cost of a treatment plan or bill over scheduled installments
+type PaymentPlan struct {
+	ID              uint                 `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID       string               `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	TreatmentPlanID *uint                `gorm:"column:treatment_plan_id;index" json:"treatment_plan_id,omitempty"`
+	BillingID       *string              `gorm:"column:billing_id;index" json:"billing_id,omitempty"`
+	Description     string               `gorm:"column:description;type:text" json:"description"`
+	TotalAmount     float64              `gorm:"column:total_amount;not null" json:"total_amount"`
+	Status          string               `gorm:"column:status;size:20;not null;default:active;check:status IN ('active', 'completed', 'cancelled');index" json:"status"`
+	CreatedAt       time.Time            `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time            `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy       *int64               `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy       *int64               `gorm:"column:updated_by" json:"updated_by"`
+	Installments    []PaymentInstallment `gorm:"foreignKey:PlanID;references:ID;constraint:OnDelete:CASCADE" json:"installments"`
+	Patient         Patient              `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	TreatmentPlan   *TreatmentPlan       `gorm:"foreignKey:TreatmentPlanID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (PaymentPlan) TableName() string {
+	return "payment_plan"
+}
+
+func (p *PaymentPlan) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (p *PaymentPlan) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// PaymentInstallment is one scheduled payment of a plan
+type PaymentInstallment struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PlanID            uint       `gorm:"column:plan_id;not null;uniqueIndex:idx_installment_plan_number,priority:1" json:"plan_id"`
+	Number            int        `gorm:"column:number;not null;uniqueIndex:idx_installment_plan_number,priority:2" json:"number"`
+	DueDate           time.Time  `gorm:"column:due_date;type:date;not null;index:idx_installment_status_due,priority:2" json:"due_date"`
+	Amount            float64    `gorm:"column:amount;not null" json:"amount"`
+	PaidAmount        float64    `gorm:"column:paid_amount;not null;default:0" json:"paid_amount"`
+	Status            string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'overdue', 'paid');index:idx_installment_status_due,priority:1" json:"status"`
+	PaidAt            *time.Time `gorm:"column:paid_at" json:"paid_at,omitempty"`
+	ReminderSentAt    *time.Time `gorm:"column:reminder_sent_at" json:"reminder_sent_at,omitempty"`
+	OverdueNotifiedAt *time.Time `gorm:"column:overdue_notified_at" json:"overdue_notified_at,omitempty"`
+	UpdatedAt         time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (PaymentInstallment) TableName() string {
+	return "payment_installment"
+}
+
+// PaymentPlanProgress summarises how far a plan has been paid
+type PaymentPlanProgress struct {
+	PaidAmount        float64    `json:"paid_amount"`
+	RemainingAmount   float64    `json:"remaining_amount"`
+	PercentPaid       float64    `json:"percent_paid"`
+	InstallmentsPaid  int        `json:"installments_paid"`
+	InstallmentsTotal int        `json:"installments_total"`
+	OverdueCount      int        `json:"overdue_count"`
+	OverdueAmount     float64    `json:"overdue_amount"`
+	NextDueDate       *time.Time `json:"next_due_date,omitempty"`
+	NextDueAmount     float64    `json:"next_due_amount,omitempty"`
+}
+
+// PaymentPlanView is a plan with its progress
+type PaymentPlanView struct {
+	PaymentPlan
+	Progress PaymentPlanProgress `json:"progress"`
+}
+
+// InstallmentReminder is an installment due soon or overdue, with the patient to notify
+type InstallmentReminder struct {
+	InstallmentID    uint      `json:"installment_id"`
+	PlanID           uint      `json:"plan_id"`
+	Number           int       `json:"number"`
+	DueDate          time.Time `json:"due_date"`
+	Amount           float64   `json:"amount"`
+	PaidAmount       float64   `json:"paid_amount"`
+	PatientID        string    `json:"patient_id"`
+	PatientFirstName string    `json:"patient_first_name"`
+	PatientEmail     string    `json:"patient_email"`
+}
+
+// InstallmentSchedule spreads a plan's total evenly over Count installments, the first due on
+// FirstDueDate and each following one IntervalMonths later
+type InstallmentSchedule struct {
+	Count          int
+	FirstDueDate   time.Time
+	IntervalMonths int
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CommissionRate is the percentage of the money collected on a doctor's bills paid to them as
+// commission, overriding the clinic's default rate
+type CommissionRate struct {
+	DoctorID  string    `gorm:"primaryKey;column:doctor_id" json:"doctor_id"`
+	Rate      float64   `gorm:"column:rate;not null;check:rate >= 0 AND rate <= 100" json:"rate"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Doctor    Doctor    `gorm:"foreignKey:DoctorID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (CommissionRate) TableName() string {
+	return "commission_rate"
+}
+
+func (r *CommissionRate) BeforeSave(tx *gorm.DB) error {
+	r.UpdatedBy = actorColumn(tx)
+	return nil
+}
+
+// CommissionBasis is the money billed and collected on a doctor's bills over a month. Bills of
+// closed periods count as they were closed, and adjustments to them in the month they were made.
+type CommissionBasis struct {
+	DoctorID   string   `json:"doctor_id"`
+	DoctorName string   `json:"doctor_name"`
+	UserID     *int64   `json:"user_id"`
+	Billed     float64  `json:"billed"`
+	Collected  float64  `json:"collected"`
+	Rate       *float64 `json:"rate"`
+}
+
+// PayrollPeriod is a month whose payroll an admin signed off. The lines are kept as signed off,
+// whatever changes to the roster or billing afterwards.
+type PayrollPeriod struct {
+	Period      string        `gorm:"primaryKey;size:7;column:period" json:"period"`
+	SignedOffAt time.Time     `gorm:"column:signed_off_at;not null" json:"signed_off_at"`
+	SignedOffBy *int64        `gorm:"column:signed_off_by" json:"signed_off_by"`
+	Lines       []PayrollLine `gorm:"foreignKey:Period;references:Period;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PayrollPeriod) TableName() string {
+	return "payroll_period"
+}
+
+// PayrollLine is what one staff member is paid on for a month: the hours they were rostered,
+// less shifts on approved leave, and for doctors the commission on their collections. Doctors
+// without a staff account have no UserID.
+type PayrollLine struct {
+	ID             uint    `gorm:"primaryKey;autoIncrement;column:id" json:"-"`
+	Period         string  `gorm:"size:7;column:period;not null;index" json:"-"`
+	UserID         *int64  `gorm:"column:user_id" json:"user_id"`
+	DoctorID       *string `gorm:"column:doctor_id" json:"doctor_id,omitempty"`
+	Name           string  `gorm:"column:name;not null" json:"name"`
+	Role           string  `gorm:"column:role;size:50" json:"role"`
+	Shifts         int     `gorm:"column:shifts;not null" json:"shifts"`
+	Hours          float64 `gorm:"column:hours;not null" json:"hours"`
+	LeaveDays      int     `gorm:"column:leave_days;not null" json:"leave_days"`
+	Billed         float64 `gorm:"column:billed;not null" json:"billed"`
+	Collected      float64 `gorm:"column:collected;not null" json:"collected"`
+	CommissionRate float64 `gorm:"column:commission_rate;not null" json:"commission_rate"`
+	Commission     float64 `gorm:"column:commission;not null" json:"commission"`
+}
+
+func (PayrollLine) TableName() string {
+	return "payroll_line"
+}
+
+// Payroll statuses
+const (
+	PayrollStatusDraft     = "draft"
+	PayrollStatusSignedOff = "signed_off"
+)
+
+// Payroll is the payroll of a month: worked out from the roster and billing as they are until an
+// admin signs it off, and as signed off afterwards
+type Payroll struct {
+	Period      string        `json:"period"`
+	Status      string        `json:"status"`
+	SignedOffAt *time.Time    `json:"signed_off_at,omitempty"`
+	SignedOffBy *int64        `json:"signed_off_by,omitempty"`
+	Lines       []PayrollLine `json:"lines"`
+}
+
+// PayrollStaff is a staff member with their role
+type PayrollStaff struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
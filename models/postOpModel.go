@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostOpInstruction is the aftercare advice sent to patients once a procedure is done, in one
+// language. {first_name} and {procedure} in the subject and body are replaced with the patient's
+// first name and the procedure.
+type PostOpInstruction struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ProcedureID uint      `gorm:"column:procedure_id;not null;uniqueIndex:idx_post_op_instruction,priority:1" json:"procedure_id"`
+	Language    string    `gorm:"column:language;size:10;not null;uniqueIndex:idx_post_op_instruction,priority:2" json:"language"`
+	Subject     string    `gorm:"column:subject;size:255;not null" json:"subject"`
+	Body        string    `gorm:"column:body;type:text;not null" json:"body"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy   *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy   *int64    `gorm:"column:updated_by" json:"updated_by"`
+	Procedure   Procedure `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PostOpInstruction) TableName() string {
+	return "post_op_instruction"
+}
+
+func (i *PostOpInstruction) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (i *PostOpInstruction) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Allergy severities
+const (
+	AllergySeverityMild     = "mild"
+	AllergySeverityModerate = "moderate"
+	AllergySeveritySevere   = "severe"
+)
+
+// Drug interaction severities
+const (
+	InteractionSeverityMinor           = "minor"
+	InteractionSeverityModerate        = "moderate"
+	InteractionSeverityMajor           = "major"
+	InteractionSeverityContraindicated = "contraindicated"
+)
+
+// Prescription warning kinds
+const (
+	PrescriptionWarningAllergy     = "allergy"
+	PrescriptionWarningInteraction = "interaction"
+)
+
+// IsValidAllergySeverity reports whether severity is one of the allergy severities
+func IsValidAllergySeverity(severity string) bool {
+	switch severity {
+	case AllergySeverityMild, AllergySeverityModerate, AllergySeveritySevere:
+		return true
+	}
+	return false
+}
+
+// IsValidInteractionSeverity reports whether severity is one of the interaction severities
+func IsValidInteractionSeverity(severity string) bool {
+	switch severity {
+	case InteractionSeverityMinor, InteractionSeverityModerate, InteractionSeverityMajor, InteractionSeverityContraindicated:
+		return true
+	}
+	return false
+}
+
+// NormalizeDrugName is the form drug and allergen names are compared in
+func NormalizeDrugName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// PatientAllergy is a substance the patient is known to react to
+type PatientAllergy struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string    `gorm:"column:patient_id;not null;uniqueIndex:idx_patient_allergy,priority:1" json:"patient_id"`
+	Substance string    `gorm:"column:substance;size:255;not null;uniqueIndex:idx_patient_allergy,priority:2" json:"substance"`
+	Reaction  string    `gorm:"column:reaction" json:"reaction,omitempty"`
+	Severity  string    `gorm:"column:severity;size:20;not null;default:moderate;check:severity IN ('mild', 'moderate', 'severe')" json:"severity"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PatientAllergy) TableName() string {
+	return "patient_allergy"
+}
+
+// DrugInteraction is an entry of the practice's interaction table. Each pair is stored once, with
+// the names normalized and in alphabetical order.
+type DrugInteraction struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	DrugA       string    `gorm:"column:drug_a;size:255;not null;uniqueIndex:idx_drug_interaction_pair,priority:1" json:"drug_a"`
+	DrugB       string    `gorm:"column:drug_b;size:255;not null;uniqueIndex:idx_drug_interaction_pair,priority:2;index" json:"drug_b"`
+	Severity    string    `gorm:"column:severity;size:20;not null;check:severity IN ('minor', 'moderate', 'major', 'contraindicated')" json:"severity"`
+	Description string    `gorm:"column:description;type:text" json:"description"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (DrugInteraction) TableName() string {
+	return "drug_interaction"
+}
+
+// Prescription is a medication prescribed to a patient
+type Prescription struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID    string    `gorm:"column:patient_id;not null;index:idx_prescription_patient_ends,priority:1" json:"patient_id"`
+	PrescribedBy int64     `gorm:"column:prescribed_by;not null;index" json:"prescribed_by"`
+	Drug         string    `gorm:"column:drug;size:255;not null" json:"drug"`
+	Dose         string    `gorm:"column:dose;size:100" json:"dose"`
+	Frequency    string    `gorm:"column:frequency;size:100" json:"frequency"`
+	DurationDays int       `gorm:"column:duration_days;not null" json:"duration_days"`
+	Quantity     float64   `gorm:"column:quantity" json:"quantity"`
+	Instructions string    `gorm:"column:instructions;type:text" json:"instructions,omitempty"`
+	EndsOn       time.Time `gorm:"column:ends_on;type:date;not null;index:idx_prescription_patient_ends,priority:2" json:"ends_on"`
+	Warnings     int       `gorm:"column:warnings;not null;default:0" json:"warnings"`
+	Controlled   bool      `gorm:"column:controlled;not null;default:false" json:"controlled"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Patient      Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Prescription) TableName() string {
+	return "prescription"
+}
+
+// PrescriptionWarning is an allergy or interaction found for a drug being prescribed. The prescriber
+// acknowledges it by sending its code back.
+type PrescriptionWarning struct {
+	Code     string `json:"code"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Drug     string `json:"drug"`
+	With     string `json:"with"`
+	Message  string `json:"message"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Documents printed at the front desk
+const (
+	PrintJobInvoice         = "invoice"
+	PrintJobStatement       = "statement"
+	PrintJobAppointmentCard = "appointment_card"
+	PrintJobLabel           = "label"
+)
+
+// IsValidPrintJobKind reports whether kind is a document the print queue renders
+func IsValidPrintJobKind(kind string) bool {
+	switch kind {
+	case PrintJobInvoice, PrintJobStatement, PrintJobAppointmentCard, PrintJobLabel:
+		return true
+	}
+	return false
+}
+
+// Formats print jobs are rendered in: PDF for the desk's document printer, ZPL for its label printer
+const (
+	PrintFormatPDF = "pdf"
+	PrintFormatZPL = "zpl"
+)
+
+// Statuses of a print job
+const (
+	PrintJobQueued    = "queued"
+	PrintJobPrinting  = "printing"
+	PrintJobPrinted   = "printed"
+	PrintJobFailed    = "failed"
+	PrintJobCancelled = "cancelled"
+)
+
+// PrintJob is a document rendered for one of the desk's printers, waiting for the print agent
+// running next to the printer to pick it up. A job the agent claimed but never reported on goes
+// back to the queue after a while, so a crashed agent does not lose it.
+type PrintJob struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Printer     string     `gorm:"column:printer;size:100;not null;index:idx_print_job_printer_status,priority:1" json:"printer"`
+	Kind        string     `gorm:"column:kind;size:20;not null;check:kind IN ('invoice', 'statement', 'appointment_card', 'label')" json:"kind"`
+	Format      string     `gorm:"column:format;size:10;not null;check:format IN ('pdf', 'zpl')" json:"format"`
+	PatientID   string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	RecordID    string     `gorm:"column:record_id" json:"record_id,omitempty"` // The bill or appointment printed
+	Copies      int        `gorm:"column:copies;not null;default:1;check:copies > 0" json:"copies"`
+	Status      string     `gorm:"column:status;size:20;not null;default:queued;index:idx_print_job_printer_status,priority:2;check:status IN ('queued', 'printing', 'printed', 'failed', 'cancelled')" json:"status"`
+	ContentType string     `gorm:"column:content_type;size:50;not null" json:"content_type"`
+	Content     []byte     `gorm:"column:content;type:bytea;not null" json:"-"`
+	Error       string     `gorm:"column:error" json:"error,omitempty"`
+	ClaimedBy   string     `gorm:"column:claimed_by;size:100" json:"claimed_by,omitempty"`
+	ClaimedAt   *time.Time `gorm:"column:claimed_at" json:"claimed_at,omitempty"`
+	PrintedAt   *time.Time `gorm:"column:printed_at" json:"printed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	CreatedBy   *int64     `gorm:"column:created_by" json:"created_by"`
+	Patient     *Patient   `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PrintJob) TableName() string {
+	return "print_job"
+}
+
+func (j *PrintJob) BeforeCreate(tx *gorm.DB) error {
+	tx.Statement.SetColumn("created_by", actorColumn(tx))
+	return nil
+}
+
+// PrintJobRequest asks for a document to be printed. Invoices name the bill and appointment cards
+// the appointment in RecordID; statements cover From through To (YYYY-MM-DD), the year to date by
+// default; labels carry the patient's name, date of birth and a barcode of their ID.
+type PrintJobRequest struct {
+	Printer   string `json:"printer" binding:"required"`
+	Kind      string `json:"kind" binding:"required"`
+	PatientID string `json:"patient_id" binding:"required"`
+	RecordID  string `json:"record_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Copies    int    `json:"copies"`
+}
+
+// PrintJobResult is what the print agent reports once it has tried to print a job
+type PrintJobResult struct {
+	Printed bool   `json:"printed"`
+	Error   string `json:"error"`
+}
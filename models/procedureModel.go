@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Doctor specialties
+const (
+	SpecialtyGeneral        = "general"
+	SpecialtyOrthodontics   = "orthodontics"
+	SpecialtyOralSurgery    = "oral_surgery"
+	SpecialtyPediatric      = "pediatric"
+	SpecialtyPeriodontics   = "periodontics"
+	SpecialtyEndodontics    = "endodontics"
+	SpecialtyProsthodontics = "prosthodontics"
+)
+
+// IsValidSpecialty reports whether specialty is one of the doctor specialties
+func IsValidSpecialty(specialty string) bool {
+	switch specialty {
+	case SpecialtyGeneral, SpecialtyOrthodontics, SpecialtyOralSurgery, SpecialtyPediatric,
+		SpecialtyPeriodontics, SpecialtyEndodontics, SpecialtyProsthodontics:
+		return true
+	}
+	return false
+}
+
+// Procedure is a service in the practice's catalog, such as a scaling or an extraction
+type Procedure struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name      string    `gorm:"column:name;size:255;not null;uniqueIndex" json:"name"`
+	Specialty string    `gorm:"column:specialty;size:50;not null;default:general;index" json:"specialty"`
+	Price     float64   `gorm:"column:price;not null;default:0" json:"price"` // What cash patients are charged
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (Procedure) TableName() string {
+	return "procedure"
+}
+
+// DoctorProcedure records that a doctor performs a procedure
+type DoctorProcedure struct {
+	DoctorID    string    `gorm:"column:doctor_id;primaryKey" json:"doctor_id"`
+	ProcedureID uint      `gorm:"column:procedure_id;primaryKey;index" json:"procedure_id"`
+	Doctor      Doctor    `gorm:"foreignKey:DoctorID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Procedure   Procedure `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (DoctorProcedure) TableName() string {
+	return "doctor_procedure"
+}
+
+// DoctorFilter narrows a doctor search to a specialty or to doctors performing a procedure
+type DoctorFilter struct {
+	Specialty   string
+	ProcedureID uint
+}
+
+// SpecialtyRevenue is what the doctors of a specialty billed and received over a period
+type SpecialtyRevenue struct {
+	Specialty string  `json:"specialty"`
+	Bills     int64   `json:"bills"`
+	Billed    float64 `json:"billed"`
+	Received  float64 `json:"received"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Sources of quarantined files: the upload they were sent to
+const (
+	QuarantineSourceAttachment = "examination_attachment"
+)
+
+// QuarantinedFile is an upload the virus scanner flagged. It is kept apart from patients' records,
+// with what was found in it, so admins can see what was refused and from whom before discarding it.
+type QuarantinedFile struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Source      string    `gorm:"column:source;size:50;not null" json:"source"`
+	PatientID   *string   `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	FileName    string    `gorm:"column:file_name;not null" json:"file_name"`
+	ContentType string    `gorm:"column:content_type;size:100;not null" json:"content_type"`
+	Size        int64     `gorm:"column:size;not null" json:"size"`
+	Threat      string    `gorm:"column:threat;not null" json:"threat"`
+	Content     []byte    `gorm:"column:content;type:bytea;not null" json:"-"`
+	UploadedBy  *int64    `gorm:"column:uploaded_by;index" json:"uploaded_by,omitempty"`
+	DetectedAt  time.Time `gorm:"column:detected_at;not null;index" json:"detected_at"`
+	Patient     *Patient  `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (QuarantinedFile) TableName() string {
+	return "quarantined_file"
+}
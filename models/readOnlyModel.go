@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Why the server is read-only
+const (
+	ReadOnlyReasonManual             = "manual"              // An admin turned it on
+	ReadOnlyReasonPrimaryUnavailable = "primary_unavailable" // The primary database is down and reads come from the replica
+)
+
+// ReadOnlyToggle is read-only mode as an admin turned it on, shared by every server
+type ReadOnlyToggle struct {
+	Enabled bool      `json:"enabled"`
+	Message string    `json:"message,omitempty"` // Shown to staff whose changes are refused
+	SetBy   int64     `json:"set_by"`
+	SetAt   time.Time `json:"set_at"`
+}
+
+// ReadOnlyStatus tells whether the server refuses writes, why, and where it reads from
+type ReadOnlyStatus struct {
+	Enabled           bool            `json:"enabled"`
+	Reason            string          `json:"reason,omitempty"`
+	Message           string          `json:"message,omitempty"`
+	Since             *time.Time      `json:"since,omitempty"`
+	ReadsFromReplica  bool            `json:"reads_from_replica"`
+	PrimaryHealthy    bool            `json:"primary_healthy"`
+	ReplicaConfigured bool            `json:"replica_configured"`
+	ReplicaHealthy    bool            `json:"replica_healthy"`
+	Toggle            *ReadOnlyToggle `json:"toggle,omitempty"`
+}
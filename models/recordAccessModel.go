@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// RecordSectionPatient is the section of a patient's record read from the route of the patient
+// itself, their details
+const RecordSectionPatient = "record"
+
+// RecordAccess is a read of a patient's record by a user, kept apart from the audit log so patients
+// can be shown who viewed their record. Section names the part read, such as examinations or
+// prescriptions. UserID is nil for reads by requests no user could be identified for.
+type RecordAccess struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID  string    `gorm:"column:patient_id;not null;index:idx_record_access_patient_accessed,priority:1" json:"patient_id"`
+	UserID     *int64    `gorm:"column:user_id;index" json:"user_id"`
+	Role       string    `gorm:"column:role;size:50" json:"role,omitempty"`
+	Section    string    `gorm:"column:section;size:50;not null" json:"section"`
+	Route      string    `gorm:"column:route;not null" json:"route"`
+	IP         string    `gorm:"column:ip;size:64" json:"ip"`
+	AccessedAt time.Time `gorm:"column:accessed_at;not null;index:idx_record_access_patient_accessed,priority:2;index" json:"accessed_at"`
+	Patient    *Patient  `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (RecordAccess) TableName() string {
+	return "record_access"
+}
+
+// RecordAccessFilter narrows down the record reads listed
+type RecordAccessFilter struct {
+	PatientID string
+	UserID    *int64
+	Section   string
+	From      *time.Time
+	To        *time.Time // Exclusive
+	Limit     int
+}
+
+// RecordViewer is a read of a patient's record as the patient is shown it: who read which part and
+// when, without the route and address it was read from
+type RecordViewer struct {
+	AccessedAt time.Time `json:"accessed_at"`
+	Section    string    `json:"section"`
+	ViewerName string    `json:"viewer_name"`
+	ViewerRole string    `json:"viewer_role,omitempty"`
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Referral statuses, as the front desk follows a referral up with the specialist
+const (
+	ReferralStatusDraft     = "draft"
+	ReferralStatusSent      = "sent"
+	ReferralStatusAccepted  = "accepted"
+	ReferralStatusCompleted = "completed"
+	ReferralStatusDeclined  = "declined"
+)
+
+// IsValidReferralStatus reports whether status is one of the referral statuses
+func IsValidReferralStatus(status string) bool {
+	switch status {
+	case ReferralStatusDraft, ReferralStatusSent, ReferralStatusAccepted, ReferralStatusCompleted, ReferralStatusDeclined:
+		return true
+	}
+	return false
+}
+
+// DefaultReferralLetter is the body of letters written without a template
+const DefaultReferralLetter = "Dear {specialist},\n\n" +
+	"Thank you for seeing {first_name} {last_name}, born {date_of_birth}, whom I am referring to you for the following reason:\n\n" +
+	"{reason}\n\n" +
+	"The relevant findings and imaging are summarised below. Please do not hesitate to contact me should you need anything further.\n\n" +
+	"Yours sincerely,\n\nDr {doctor}"
+
+// ReferralTemplate is the wording of referral letters to specialists. {first_name}, {last_name},
+// {date_of_birth}, {sex}, {national_id}, {phone}, {specialist}, {specialty}, {reason} and {doctor}
+// are replaced with the referral's details when a letter is written.
+type ReferralTemplate struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name      string    `gorm:"column:name;size:100;not null;uniqueIndex" json:"name"`
+	Specialty string    `gorm:"column:specialty;size:50" json:"specialty,omitempty"`
+	Body      string    `gorm:"column:body;type:text;not null" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (ReferralTemplate) TableName() string {
+	return "referral_template"
+}
+
+func (t *ReferralTemplate) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (t *ReferralTemplate) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Referral is a patient referred to a specialist outside the clinic. The letter is written once,
+// from the template, the patient's details and the examinations quoted, and kept as sent.
+type Referral struct {
+	ID                 uint                  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID          string                `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	DoctorID           string                `gorm:"column:doctor_id;not null;index" json:"doctor_id"`
+	TemplateID         *uint                 `gorm:"column:template_id" json:"template_id,omitempty"`
+	Specialty          string                `gorm:"column:specialty;size:50" json:"specialty,omitempty"`
+	SpecialistName     string                `gorm:"column:specialist_name;size:255;not null" json:"specialist_name"`
+	SpecialistPractice string                `gorm:"column:specialist_practice;size:255" json:"specialist_practice,omitempty"`
+	SpecialistEmail    string                `gorm:"column:specialist_email;size:255" json:"specialist_email,omitempty"`
+	Reason             string                `gorm:"column:reason;type:text;not null" json:"reason"`
+	Letter             string                `gorm:"column:letter;type:text;not null" json:"letter"`
+	Status             string                `gorm:"column:status;size:20;not null;default:draft;check:status IN ('draft', 'sent', 'accepted', 'completed', 'declined');index" json:"status"`
+	StatusNote         string                `gorm:"column:status_note;type:text" json:"status_note,omitempty"`
+	CreatedAt          time.Time             `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	UpdatedAt          time.Time             `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy          *int64                `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy          *int64                `gorm:"column:updated_by" json:"updated_by"`
+	Examinations       []ReferralExamination `gorm:"foreignKey:ReferralID;references:ID;constraint:OnDelete:CASCADE" json:"examinations"`
+	Imaging            []ReferralImaging     `gorm:"foreignKey:ReferralID;references:ID;constraint:OnDelete:CASCADE" json:"imaging"`
+	ExaminationIDs     []uint                `gorm:"-" json:"examination_ids,omitempty"`   // Examinations quoted in the letter on creation
+	ImagingStudyIDs    []uint                `gorm:"-" json:"imaging_study_ids,omitempty"` // Imaging linked from the letter on creation
+	Patient            Patient               `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Doctor             Doctor                `gorm:"foreignKey:DoctorID;references:ID" json:"-"`
+	Template           *ReferralTemplate     `gorm:"foreignKey:TemplateID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (Referral) TableName() string {
+	return "referral"
+}
+
+func (r *Referral) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (r *Referral) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// Fill replaces the placeholders of a referral template with the referral's details
+func (r *Referral) Fill(text string) string {
+	return strings.NewReplacer(
+		"{first_name}", r.Patient.FirstName,
+		"{last_name}", r.Patient.LastName,
+		"{date_of_birth}", r.Patient.DateOfBirth,
+		"{sex}", r.Patient.Sex,
+		"{national_id}", r.Patient.NationalID,
+		"{phone}", r.Patient.Phone,
+		"{specialist}", r.SpecialistName,
+		"{specialty}", strings.ReplaceAll(r.Specialty, "_", " "),
+		"{reason}", r.Reason,
+		"{doctor}", strings.TrimSpace(r.Doctor.FirstName+" "+r.Doctor.LastName),
+	).Replace(text)
+}
+
+// ReferralExamination is the excerpt of an examination report quoted in a referral letter, kept as
+// quoted even if the examination is later changed or deleted
+type ReferralExamination struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ReferralID    uint      `gorm:"column:referral_id;not null;index" json:"referral_id"`
+	ExaminationID uint      `gorm:"column:examination_id;not null" json:"examination_id"`
+	ExaminedAt    time.Time `gorm:"column:examined_at;not null" json:"examined_at"`
+	Excerpt       string    `gorm:"column:excerpt;type:text;not null" json:"excerpt"`
+}
+
+func (ReferralExamination) TableName() string {
+	return "referral_examination"
+}
+
+// ReferralImaging is an imaging study linked from a referral letter. The specialist can download
+// only the studies linked from their referral.
+type ReferralImaging struct {
+	ReferralID     uint         `gorm:"primaryKey;column:referral_id" json:"referral_id"`
+	ImagingStudyID uint         `gorm:"primaryKey;column:imaging_study_id" json:"imaging_study_id"`
+	Modality       string       `gorm:"column:modality;size:16" json:"modality"`
+	StudyDate      *time.Time   `gorm:"column:study_date;type:date" json:"study_date"`
+	ImagingStudy   ImagingStudy `gorm:"foreignKey:ImagingStudyID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ReferralImaging) TableName() string {
+	return "referral_imaging"
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Pre-registration statuses
+const (
+	RegistrationPending   = "pending"
+	RegistrationConverted = "converted"
+	RegistrationRejected  = "rejected"
+)
+
+// PendingRegistration is what a new patient submitted on the public pre-registration form, kept
+// apart from patient records until a receptionist reviews it
+type PendingRegistration struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	FirstName        string     `gorm:"column:first_name;not null" json:"first_name"`
+	MiddleName       string     `gorm:"column:middle_name" json:"middle_name"`
+	LastName         string     `gorm:"column:last_name;not null" json:"last_name"`
+	Sex              string     `gorm:"column:sex;check:sex IN ('Male', 'Female', 'Other');not null" json:"sex"`
+	DateOfBirth      string     `gorm:"column:date_of_birth;not null" json:"date_of_birth"`
+	Phone            string     `gorm:"column:phone" json:"phone"`
+	Email            string     `gorm:"column:email" json:"email"`
+	Address          Address    `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+	Guarantor        Guarantor  `gorm:"embedded;embeddedPrefix:guarantor_" json:"guarantor"`
+	Occupation       string     `gorm:"column:occupation" json:"occupation"`
+	Insured          bool       `gorm:"column:insured;not null" json:"insured"`
+	InsuranceCompany string     `gorm:"column:insurance_company" json:"insurance_company"`
+	Scheme           string     `gorm:"column:scheme" json:"scheme"`
+	MedicalHistory   string     `gorm:"column:medical_history;type:text" json:"medical_history"`
+	Allergies        string     `gorm:"column:allergies;type:text" json:"allergies"`
+	Medications      string     `gorm:"column:medications;type:text" json:"medications"`
+	CampaignCode     string     `gorm:"column:campaign_code;size:50" json:"campaign_code,omitempty"` // Campaign that brought the patient to the form
+	Status           string     `gorm:"size:20;column:status;not null;default:pending;index;check:status IN ('pending', 'converted', 'rejected')" json:"status"`
+	PatientID        *string    `gorm:"column:patient_id" json:"patient_id,omitempty"`
+	ReviewedBy       *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewNote       string     `gorm:"column:review_note;type:text" json:"review_note,omitempty"`
+	IP               string     `gorm:"size:64;column:ip" json:"ip"`
+	CreatedAt        time.Time  `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+func (PendingRegistration) TableName() string {
+	return "pending_registration"
+}
+
+// Patient returns the patient record the registration becomes once converted
+func (r *PendingRegistration) Patient() *Patient {
+	return &Patient{
+		FirstName:        r.FirstName,
+		MiddleName:       r.MiddleName,
+		LastName:         r.LastName,
+		Sex:              r.Sex,
+		DateOfBirth:      r.DateOfBirth,
+		Insured:          r.Insured,
+		Cash:             !r.Insured,
+		InsuranceCompany: r.InsuranceCompany,
+		Scheme:           r.Scheme,
+		Occupation:       r.Occupation,
+		Phone:            r.Phone,
+		Email:            r.Email,
+		Address:          r.Address,
+		Guarantor:        r.Guarantor,
+		Attribution:      Attribution{Source: SourceWebsite, CampaignCode: r.CampaignCode},
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ReminderLeads are how many minutes before an appointment its reminders are sent, longest first,
+// stored as a JSONB array. Nil follows the cadence of the appointment's type; an empty list sends
+// no reminders.
+type ReminderLeads []int
+
+func (l ReminderLeads) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *ReminderLeads) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported reminder leads value")
+	}
+	return json.Unmarshal(data, l)
+}
+
+// DefaultReminderCadences returns the reminder cadences used until an Admin changes them: one
+// reminder lead before appointments of every type
+func DefaultReminderCadences(lead time.Duration) map[string]ReminderLeads {
+	minutes := int(lead / time.Minute)
+	return map[string]ReminderLeads{
+		AppointmentTypeConsultation: {minutes},
+		AppointmentTypeHygiene:      {minutes},
+		AppointmentTypeSurgery:      {minutes},
+		AppointmentTypeEmergency:    {minutes},
+	}
+}
+
+// Statuses of a planned reminder
+const (
+	ReminderSent    = "sent"    // Sent, or held back by the patient's preferences
+	ReminderSkipped = "skipped" // Not sent, as a later reminder was already due when it was
+	ReminderDue     = "due"     // Its time has come; it goes out on the next dispatch
+	ReminderPlanned = "planned"
+)
+
+// PlannedReminder is one of the reminders of an appointment
+type PlannedReminder struct {
+	LeadMinutes int        `json:"lead_minutes"`
+	SendAt      time.Time  `json:"send_at"`
+	Status      string     `json:"status"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+}
+
+// ReminderPlan is when an appointment's reminders are sent: the cadence of its type, or its own
+type ReminderPlan struct {
+	AppointmentID uint              `json:"appointment_id"`
+	Type          string            `json:"type"`
+	StartsAt      *time.Time        `json:"starts_at"`
+	Override      bool              `json:"override"` // Whether the appointment has its own cadence
+	LeadMinutes   ReminderLeads     `json:"lead_minutes"`
+	Reminders     []PlannedReminder `json:"reminders"`
+}
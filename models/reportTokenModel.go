@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reports read with report tokens, named as in their /reporting/<report> route
+const (
+	ReportAnalytics          = "analytics"
+	ReportContractVariance   = "contract-variance"
+	ReportDailySummary       = "daily-summary"
+	ReportDisputes           = "disputes"
+	ReportDowntime           = "downtime"
+	ReportMarketing          = "marketing"
+	ReportOverbooking        = "overbooking"
+	ReportRevenueBySpecialty = "revenue-by-specialty"
+	ReportStaffActivity      = "staff-activity"
+	ReportSurveys            = "surveys"
+)
+
+// IsValidReport reports whether report is one of the reports read with report tokens
+func IsValidReport(report string) bool {
+	switch report {
+	case ReportAnalytics, ReportContractVariance, ReportDailySummary, ReportDisputes, ReportDowntime, ReportMarketing,
+		ReportOverbooking, ReportRevenueBySpecialty, ReportStaffActivity, ReportSurveys:
+		return true
+	}
+	return false
+}
+
+// ReportTokenScope is the reports or branches a report token is limited to, stored as a JSONB
+// array. An empty scope does not limit the token.
+type ReportTokenScope []string
+
+func (s ReportTokenScope) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *ReportTokenScope) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = ReportTokenScope{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported report token scope value")
+	}
+	return json.Unmarshal(data, s)
+}
+
+// Allows reports whether the scope includes value
+func (s ReportTokenScope) Allows(value string) bool {
+	return len(s) == 0 || slices.Contains(s, value)
+}
+
+// ReportToken is a read-only credential for a reporting tool, such as the BI tool or the
+// accountant's, that only reads the reports in Reports for the branches in Branches. Only a hash
+// of the token is kept; the token itself is returned once, when it is minted.
+type ReportToken struct {
+	ID         uint             `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name       string           `gorm:"column:name;size:100;not null" json:"name"`
+	TokenHash  string           `gorm:"column:token_hash;size:64;not null;uniqueIndex" json:"-"`
+	Prefix     string           `gorm:"column:prefix;size:16;not null" json:"prefix"` // The start of the token, to tell tokens apart
+	Reports    ReportTokenScope `gorm:"column:reports;type:jsonb;not null;default:'[]'" json:"reports"`
+	Branches   ReportTokenScope `gorm:"column:branches;type:jsonb;not null;default:'[]'" json:"branches"`
+	ExpiresAt  *time.Time       `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	RevokedAt  *time.Time       `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	RevokedBy  *int64           `gorm:"column:revoked_by" json:"revoked_by,omitempty"`
+	LastUsedAt *time.Time       `gorm:"column:last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time        `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy  *int64           `gorm:"column:created_by" json:"created_by"`
+	Token      string           `gorm:"-" json:"token,omitempty"` // The token itself, only returned when it is minted
+}
+
+func (ReportToken) TableName() string {
+	return "report_token"
+}
+
+func (t *ReportToken) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// Active reports whether the token still works at now
+func (t ReportToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,9 @@
+package models
+
+// CachedResponse is the response to a GET kept to answer the same request again until the records
+// behind it change
+type CachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
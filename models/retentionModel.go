@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy is the retention rule in force for an entity
+type RetentionPolicy struct {
+	Entity string    `json:"entity"`
+	Action string    `json:"action"` // purge or archive
+	Days   int       `json:"days"`
+	Cutoff time.Time `json:"cutoff"` // Records created before it are due
+}
+
+// RetentionRun is one enforcement of an entity's retention rule, or a dry run counting the records
+// it would remove. Runs are kept as evidence the data-protection policy is applied.
+type RetentionRun struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Entity      string     `gorm:"column:entity;size:50;not null;index:idx_retention_run_entity_started,priority:1" json:"entity"`
+	Action      string     `gorm:"column:action;size:20;not null;check:action IN ('purge', 'archive')" json:"action"`
+	Days        int        `gorm:"column:days;not null" json:"days"`
+	Cutoff      time.Time  `gorm:"column:cutoff;not null" json:"cutoff"`
+	DryRun      bool       `gorm:"column:dry_run;not null;default:false" json:"dry_run"`
+	Due         int64      `gorm:"column:due;not null;default:0" json:"due"`          // Records older than the cutoff when the run started
+	Removed     int64      `gorm:"column:removed;not null;default:0" json:"removed"`  // Records purged or archived
+	ArchiveFile string     `gorm:"column:archive_file" json:"archive_file,omitempty"` // File the archived records were written to
+	Error       string     `gorm:"column:error;type:text" json:"error,omitempty"`     // Why the run stopped early
+	StartedAt   time.Time  `gorm:"column:started_at;not null;index:idx_retention_run_entity_started,priority:2" json:"started_at"`
+	FinishedAt  *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	CreatedBy   *int64     `gorm:"column:created_by" json:"created_by"` // Who ran it by hand; empty for the scheduled job
+}
+
+func (RetentionRun) TableName() string {
+	return "retention_run"
+}
+
+func (r *RetentionRun) BeforeCreate(tx *gorm.DB) error {
+	tx.Statement.SetColumn("created_by", actorColumn(tx))
+	return nil
+}
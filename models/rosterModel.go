@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of shift swaps and leave requests, which an admin approves or rejects while pending
+const (
+	RosterRequestPending   = "pending"
+	RosterRequestApproved  = "approved"
+	RosterRequestRejected  = "rejected"
+	RosterRequestCancelled = "cancelled"
+)
+
+// Shift is a staff member rostered at a branch from StartsAt to EndsAt. A staff member's shifts do
+// not overlap.
+type Shift struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UserID    int64     `gorm:"column:user_id;not null;index:idx_shift_user_start,priority:1" json:"user_id"`
+	Branch    string    `gorm:"column:branch;size:50;not null;index" json:"branch"`
+	StartsAt  time.Time `gorm:"column:starts_at;not null;index:idx_shift_user_start,priority:2;index" json:"starts_at"`
+	EndsAt    time.Time `gorm:"column:ends_at;not null" json:"ends_at"`
+	Note      string    `gorm:"column:note" json:"note,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (Shift) TableName() string {
+	return "shift"
+}
+
+func (s *Shift) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (s *Shift) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ShiftSwap asks for a shift to be handed to another staff member. The shift changes hands once
+// an admin approves the swap.
+type ShiftSwap struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	ShiftID    uint       `gorm:"column:shift_id;not null;index" json:"shift_id"`
+	FromUserID int64      `gorm:"column:from_user_id;not null;index" json:"from_user_id"`
+	ToUserID   int64      `gorm:"column:to_user_id;not null;index" json:"to_user_id"`
+	Note       string     `gorm:"column:note" json:"note,omitempty"`
+	Status     string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'approved', 'rejected', 'cancelled');index" json:"status"`
+	ReviewNote string     `gorm:"column:review_note" json:"review_note,omitempty"`
+	ReviewedBy *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy  *int64     `gorm:"column:created_by" json:"created_by"`
+	Shift      Shift      `gorm:"foreignKey:ShiftID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	FromUser   User       `gorm:"foreignKey:FromUserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	ToUser     User       `gorm:"foreignKey:ToUserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ShiftSwap) TableName() string {
+	return "shift_swap"
+}
+
+func (s *ShiftSwap) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// LeaveRequest is a staff member asking to be away on the days StartDate through EndDate, as
+// YYYY-MM-DD clinic days. Approved leave takes the staff member off the roster on those days.
+type LeaveRequest struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UserID     int64      `gorm:"column:user_id;not null;index" json:"user_id"`
+	StartDate  string     `gorm:"column:start_date;size:10;not null;index" json:"start_date"`
+	EndDate    string     `gorm:"column:end_date;size:10;not null;index" json:"end_date"`
+	Reason     string     `gorm:"column:reason" json:"reason,omitempty"`
+	Status     string     `gorm:"column:status;size:20;not null;default:pending;check:status IN ('pending', 'approved', 'rejected', 'cancelled');index" json:"status"`
+	ReviewNote string     `gorm:"column:review_note" json:"review_note,omitempty"`
+	ReviewedBy *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy  *int64     `gorm:"column:created_by" json:"created_by"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (LeaveRequest) TableName() string {
+	return "leave_request"
+}
+
+func (l *LeaveRequest) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// Covers reports whether the leave includes the clinic day day, as YYYY-MM-DD
+func (l LeaveRequest) Covers(day string) bool {
+	return l.StartDate <= day && day <= l.EndDate
+}
+
+// RosterShift is a shift with the staff member working it
+type RosterShift struct {
+	Shift
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	OnLeave  bool   `json:"on_leave"` // Whether the staff member has approved leave on the day the shift starts
+}
+
+// RosterLeave is a leave request with the staff member asking for it
+type RosterLeave struct {
+	LeaveRequest
+	Username string `json:"username"`
+}
+
+// RosterSwap is a shift swap with the shift and the staff members handing it over and taking it
+type RosterSwap struct {
+	ShiftSwap
+	Branch       string    `json:"branch"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	FromUsername string    `json:"from_username"`
+	ToUsername   string    `json:"to_username"`
+}
+
+// Roster is the shifts and approved leave over the days From through To
+type Roster struct {
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+	Shifts []RosterShift `json:"shifts"`
+	Leave  []RosterLeave `json:"leave"`
+}
+
+// Presence is when a doctor is rostered on a clinic day. Rostered is false for doctors without a
+// staff account, who cannot be put on the roster.
+type Presence struct {
+	Rostered bool
+	OnLeave  bool
+	Shifts   []Shift
+}
+
+// Covers reports whether the doctor is rostered for the whole of start to end
+func (p Presence) Covers(start, end time.Time) bool {
+	if p.OnLeave {
+		return false
+	}
+	for _, shift := range p.Shifts {
+		if !shift.StartsAt.After(start) && !shift.EndsAt.Before(end) {
+			return true
+		}
+	}
+	return false
+}
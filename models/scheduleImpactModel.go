@@ -0,0 +1,56 @@
+package models
+
+// AffectedAppointment is an appointment that would need rebooking if its doctor could not work that
+// day, with how to reach the patient and what it was expected to bring in
+type AffectedAppointment struct {
+	AppointmentID uint     `json:"appointment_id"`
+	PatientID     string   `json:"patient_id"`
+	PatientName   string   `json:"patient_name"`
+	PatientPhone  string   `json:"patient_phone"`
+	PatientEmail  string   `json:"patient_email"`
+	DateTime      string   `json:"date_time"`
+	Type          string   `json:"type"`
+	Status        string   `json:"status"`
+	ProcedureName string   `json:"procedure_name,omitempty"`
+	Price         *float64 `json:"price,omitempty"` // List price of the booked procedure
+}
+
+// ScheduleImpact summarises what would need rebooking if a doctor called in sick on a day. Revenue
+// at risk totals the list prices of the booked procedures; appointments booked without a procedure
+// are counted as unpriced.
+type ScheduleImpact struct {
+	DoctorID      string                `json:"doctor_id"`
+	DoctorName    string                `json:"doctor_name"`
+	Date          string                `json:"date"`
+	Appointments  []AffectedAppointment `json:"appointments"`
+	Patients      int                   `json:"patients"`
+	RevenueAtRisk float64               `json:"revenue_at_risk"`
+	Unpriced      int                   `json:"unpriced"`
+	Unreachable   int                   `json:"unreachable"` // Patients with neither a phone number nor an email address
+}
+
+// ScheduleNotice is the message sent to every patient affected on a doctor's day
+type ScheduleNotice struct {
+	Date    string `json:"date" binding:"required"`
+	Channel string `json:"channel"` // sms or email, sms by default
+	Message string `json:"message"` // Added to the standard notice, such as when to call to rebook
+}
+
+// ScheduleNoticeResult is the outcome of the notice to one affected appointment's patient
+type ScheduleNoticeResult struct {
+	AppointmentID uint   `json:"appointment_id"`
+	PatientID     string `json:"patient_id"`
+	Recipient     string `json:"recipient,omitempty"`
+	Status        string `json:"status"` // sent, deferred, not_permitted, failed or unreachable
+	Error         string `json:"error,omitempty"`
+}
+
+// ScheduleNoticeSummary counts the outcomes of a bulk notice
+type ScheduleNoticeSummary struct {
+	Sent         int                    `json:"sent"`
+	Deferred     int                    `json:"deferred"` // Held until quiet hours end
+	NotPermitted int                    `json:"not_permitted"`
+	Failed       int                    `json:"failed"`
+	Unreachable  int                    `json:"unreachable"`
+	Results      []ScheduleNoticeResult `json:"results"`
+}
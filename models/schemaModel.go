@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaDrift is how the live database schema differs from what the running build expects: the
+// tables, columns and indexes of its models, the sequences IDs are numbered from and the versioned
+// migrations it applies
+type SchemaDrift struct {
+	CheckedAt         time.Time `json:"checked_at"`
+	Drifted           bool      `json:"drifted"`
+	MigrationVersion  int       `json:"migration_version"`  // Latest versioned migration applied to the database
+	ExpectedVersion   int       `json:"expected_version"`   // Latest versioned migration this build knows
+	PendingMigrations []string  `json:"pending_migrations"` // Known migrations not applied, as version_name
+	UnknownMigrations []int     `json:"unknown_migrations"` // Applied migrations this build does not know, from a newer build
+	MissingTables     []string  `json:"missing_tables"`
+	MissingColumns    []string  `json:"missing_columns"` // As table.column
+	MissingIndexes    []string  `json:"missing_indexes"` // As table.index
+	MissingSequences  []string  `json:"missing_sequences"`
+}
+
+// Problems lists the drift found, one line per kind of difference
+func (d *SchemaDrift) Problems() []string {
+	var problems []string
+	add := func(what string, names []string) {
+		if len(names) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", what, strings.Join(names, ", ")))
+		}
+	}
+	add("pending migrations", d.PendingMigrations)
+	if len(d.UnknownMigrations) > 0 {
+		versions := make([]string, len(d.UnknownMigrations))
+		for i, version := range d.UnknownMigrations {
+			versions[i] = strconv.Itoa(version)
+		}
+		add("unknown migrations", versions)
+	}
+	add("missing tables", d.MissingTables)
+	add("missing columns", d.MissingColumns)
+	add("missing indexes", d.MissingIndexes)
+	add("missing sequences", d.MissingSequences)
+	return problems
+}
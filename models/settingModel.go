@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys
+const (
+	SettingDebugLogging      = "debug_logging"
+	SettingBirthdayGreetings = "birthday_greetings"
+	SettingAppointmentTypes  = "appointment_types"
+	SettingReminderCadences  = "reminder_cadences"
+	SettingDocumentFormat    = "document_format"
+)
+
+// Setting is a runtime setting Admins change through the API, shared by every instance
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:100;column:key" json:"key"`
+	Value     string    `gorm:"type:text;not null;column:value" json:"value"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	UpdatedBy *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (Setting) TableName() string {
+	return "settings"
+}
+
+// BeforeCreate stamps the user, as settings are only ever written by upsert
+func (s *Setting) BeforeCreate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// AppSettings are the runtime settings as the settings API shows them
+type AppSettings struct {
+	DebugLogging      bool                              `json:"debug_logging"`
+	BirthdayGreetings bool                              `json:"birthday_greetings"`
+	AppointmentTypes  map[string]AppointmentTypeSetting `json:"appointment_types"`
+	ReminderCadences  map[string]ReminderLeads          `json:"reminder_cadences"`
+	DocumentFormat    DocumentFormat                    `json:"document_format"`
+}
+
+// AppointmentTypeSetting is how long an appointment of a type takes by default and the colour the
+// calendar shows it in, as #RRGGBB
+type AppointmentTypeSetting struct {
+	DurationMinutes int    `json:"duration_minutes"`
+	Color           string `json:"color"`
+}
+
+// DefaultAppointmentTypes returns the appointment type settings used until an Admin changes them.
+// Consultations and emergencies take a regular slot.
+func DefaultAppointmentTypes(slot time.Duration) map[string]AppointmentTypeSetting {
+	minutes := int(slot / time.Minute)
+	return map[string]AppointmentTypeSetting{
+		AppointmentTypeConsultation: {DurationMinutes: minutes, Color: "#4A90D9"},
+		AppointmentTypeHygiene:      {DurationMinutes: 45, Color: "#50B86C"},
+		AppointmentTypeSurgery:      {DurationMinutes: 90, Color: "#9B59B6"},
+		AppointmentTypeEmergency:    {DurationMinutes: minutes, Color: "#E74C3C"},
+	}
+}
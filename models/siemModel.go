@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SIEMCursor is how far the audit trail was forwarded to a SIEM collector, and why forwarding
+// last failed when it did
+type SIEMCursor struct {
+	Endpoint    string     `gorm:"primaryKey;size:255;column:endpoint" json:"endpoint"`
+	LastAuditID int64      `gorm:"column:last_audit_id;not null" json:"last_audit_id"`
+	ForwardedAt *time.Time `gorm:"column:forwarded_at" json:"forwarded_at,omitempty"`
+	LastError   string     `gorm:"type:text;column:last_error" json:"last_error,omitempty"`
+	FailedAt    *time.Time `gorm:"column:failed_at" json:"failed_at,omitempty"`
+}
+
+func (SIEMCursor) TableName() string {
+	return "siem_cursor"
+}
+
+// SIEMStatus is how the forwarding of the audit trail to the SIEM collector is doing
+type SIEMStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Endpoint    string     `json:"endpoint,omitempty"`
+	Format      string     `json:"format,omitempty"`
+	LastAuditID int64      `json:"last_audit_id"`
+	ForwardedAt *time.Time `json:"forwarded_at,omitempty"`
+	Pending     int64      `json:"pending"` // Audit entries not forwarded yet
+	LastError   string     `json:"last_error,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+}
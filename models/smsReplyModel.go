@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// How a patient's reply to an appointment reminder was understood
+const (
+	SMSIntentConfirm      = "confirm"
+	SMSIntentCancel       = "cancel"
+	SMSIntentUnrecognized = "unrecognized"
+)
+
+// Statuses of a reply: acted on automatically, waiting in the staff inbox, or dealt with by staff
+const (
+	SMSReplyConfirmed = "confirmed"
+	SMSReplyCancelled = "cancelled"
+	SMSReplyOpen      = "open"
+	SMSReplyHandled   = "handled"
+)
+
+// Why a reply was left in the staff inbox
+const (
+	SMSReplyUnrecognized = "unrecognized"  // The reply is not one of the confirm or cancel words
+	SMSReplyUnmatched    = "unmatched"     // No appointment reminder was recently texted to the number
+	SMSReplyNotScheduled = "not_scheduled" // The appointment reminded of has since started, been cancelled or checked in
+)
+
+// InboundSMS is a text message the SMS gateway forwards from a patient
+type InboundSMS struct {
+	MessageID  string     `json:"message_id"` // The gateway's ID of the message, so a message forwarded twice is only taken once
+	From       string     `json:"from"`
+	Body       string     `json:"body"`
+	ReceivedAt *time.Time `json:"received_at"`
+}
+
+// SMSReply is a text message a patient sent the clinic. Replies confirming or cancelling the
+// appointment they were last reminded of are acted on as they arrive; the others wait in the staff
+// inbox until someone deals with them.
+type SMSReply struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	MessageID     string     `gorm:"column:message_id;not null;default:'';uniqueIndex:idx_sms_reply_message,where:message_id <> ''" json:"message_id,omitempty"`
+	From          string     `gorm:"column:from_number;not null" json:"from"`
+	Body          string     `gorm:"column:body;type:text;not null" json:"body"`
+	Intent        string     `gorm:"column:intent;size:20;not null;check:intent IN ('confirm', 'cancel', 'unrecognized')" json:"intent"`
+	Status        string     `gorm:"column:status;size:20;not null;index:idx_sms_reply_status_received,priority:1;check:status IN ('confirmed', 'cancelled', 'open', 'handled')" json:"status"`
+	Reason        string     `gorm:"column:reason;size:20" json:"reason,omitempty"` // Why the reply was left in the inbox
+	PatientID     *string    `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	AppointmentID *uint      `gorm:"column:appointment_id" json:"appointment_id,omitempty"`
+	ReceivedAt    time.Time  `gorm:"column:received_at;not null;index:idx_sms_reply_status_received,priority:2" json:"received_at"`
+	HandledAt     *time.Time `gorm:"column:handled_at" json:"handled_at,omitempty"`
+	HandledBy     *int64     `gorm:"column:handled_by" json:"handled_by,omitempty"`
+	Note          string     `gorm:"column:note;type:text" json:"note,omitempty"`
+}
+
+func (SMSReply) TableName() string {
+	return "sms_reply"
+}
+
+// SMSReminder is the appointment reminder a reply is taken to answer: the last one texted to the
+// number it came from
+type SMSReminder struct {
+	PatientID     string
+	AppointmentID uint
+	Status        string // The appointment's status
+	StartsAt      *time.Time
+}
@@ -0,0 +1,45 @@
+package models
+
+// StaffActivity counts the records a staff member created and last edited over a period,
+// by record type
+type StaffActivity struct {
+	UserID       int64            `json:"user_id"`
+	Username     string           `json:"username"`
+	Role         string           `json:"role"`
+	Created      map[string]int64 `json:"created"`
+	Edited       map[string]int64 `json:"edited"`
+	TotalCreated int64            `json:"total_created"`
+	TotalEdited  int64            `json:"total_edited"`
+}
+
+// StaffBookings counts the appointments a staff member booked
+type StaffBookings struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	Appointments int64  `json:"appointments"`
+}
+
+// DoctorExaminations counts the examinations a doctor recorded
+type DoctorExaminations struct {
+	DoctorID     string `json:"doctor_id"`
+	DoctorName   string `json:"doctor_name"`
+	Examinations int64  `json:"examinations"`
+}
+
+// StaffActivityCount is a number of records of one type a user created or edited
+type StaffActivityCount struct {
+	UserID int64
+	Record string
+	Edited bool
+	Count  int64
+}
+
+// StaffActivityReport summarizes who did what over a period, for performance reviews
+type StaffActivityReport struct {
+	From               string               `json:"from"`
+	To                 string               `json:"to"`
+	Users              []StaffActivity      `json:"users"`
+	AppointmentsBooked []StaffBookings      `json:"appointments_booked"`
+	Examinations       []DoctorExaminations `json:"examinations"`
+}
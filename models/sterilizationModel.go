@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sterilization cycle results
+const (
+	SterilizationResultPending = "pending"
+	SterilizationResultPassed  = "passed"
+	SterilizationResultFailed  = "failed"
+)
+
+// IsValidSterilizationResult reports whether result is one of the sterilization cycle results
+func IsValidSterilizationResult(result string) bool {
+	switch result {
+	case SterilizationResultPending, SterilizationResultPassed, SterilizationResultFailed:
+		return true
+	}
+	return false
+}
+
+// SterilizationBatch is an instrument batch processed in one sterilizer cycle, identified by the
+// code printed on its pouch labels
+type SterilizationBatch struct {
+	ID           string    `gorm:"primaryKey;column:id;size:50" json:"id"`
+	Sterilizer   string    `gorm:"column:sterilizer;size:100;not null" json:"sterilizer"`
+	Cycle        string    `gorm:"column:cycle;size:50" json:"cycle,omitempty"`
+	SterilizedAt time.Time `gorm:"column:sterilized_at;not null;index" json:"sterilized_at"`
+	Result       string    `gorm:"column:result;size:20;not null;default:pending;check:result IN ('pending', 'passed', 'failed')" json:"result"`
+	Notes        string    `gorm:"column:notes;type:text" json:"notes,omitempty"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy    *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy    *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (SterilizationBatch) TableName() string {
+	return "sterilization_batch"
+}
+
+func (b *SterilizationBatch) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (b *SterilizationBatch) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// BatchUsage records that instruments from a batch were used for a billed procedure. The patient,
+// doctor and procedure are copied from the bill so traces survive changes to it.
+type BatchUsage struct {
+	ID        uint               `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	BatchID   string             `gorm:"column:batch_id;size:50;not null;uniqueIndex:idx_batch_usage,priority:1" json:"batch_id"`
+	BillingID string             `gorm:"column:billing_id;not null;uniqueIndex:idx_batch_usage,priority:2;index" json:"billing_id"`
+	PatientID string             `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	DoctorID  string             `gorm:"column:doctor_id;not null" json:"doctor_id"`
+	Procedure string             `gorm:"column:procedure;not null" json:"procedure"`
+	TreatedAt time.Time          `gorm:"column:treated_at;not null" json:"treated_at"`
+	CreatedAt time.Time          `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy *int64             `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy *int64             `gorm:"column:updated_by" json:"-"`
+	Batch     SterilizationBatch `gorm:"foreignKey:BatchID;references:ID;constraint:OnDelete:RESTRICT" json:"-"`
+}
+
+func (BatchUsage) TableName() string {
+	return "batch_usage"
+}
+
+func (u *BatchUsage) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// BatchTrace is a patient treated with instruments from a batch, as listed in infection-control audits
+type BatchTrace struct {
+	BillingID   string    `json:"billing_id"`
+	PatientID   string    `json:"patient_id"`
+	PatientName string    `json:"patient_name"`
+	Phone       string    `json:"phone"`
+	DoctorID    string    `json:"doctor_id"`
+	Procedure   string    `json:"procedure"`
+	TreatedAt   time.Time `json:"treated_at"`
+}
+
+// BatchTraceReport lists everyone treated with a batch
+type BatchTraceReport struct {
+	Batch    SterilizationBatch `json:"batch"`
+	Patients []BatchTrace       `json:"patients"`
+}
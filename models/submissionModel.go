@@ -0,0 +1,9 @@
+package models
+
+// SubmissionResponse is the response to a create request, kept for a short while to answer the
+// same request submitted again
+type SubmissionResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Survey is the satisfaction survey sent to a patient after a fulfilled appointment
+type Survey struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AppointmentID uint       `gorm:"column:appointment_id;not null;uniqueIndex" json:"appointment_id"`
+	PatientID     string     `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	DoctorID      string     `gorm:"column:doctor_id;not null;index:idx_survey_doctor_sent,priority:1" json:"doctor_id"`
+	Channel       string     `gorm:"column:channel;size:20;not null" json:"channel"`
+	SentAt        time.Time  `gorm:"column:sent_at;not null;index:idx_survey_doctor_sent,priority:2" json:"sent_at"`
+	Rating        *int       `gorm:"column:rating;check:rating BETWEEN 1 AND 5" json:"rating,omitempty"`
+	NPS           *int       `gorm:"column:nps;check:nps BETWEEN 0 AND 10" json:"nps,omitempty"`
+	Comment       string     `gorm:"column:comment;type:text" json:"comment,omitempty"`
+	RespondedAt   *time.Time `gorm:"column:responded_at" json:"responded_at,omitempty"`
+}
+
+func (Survey) TableName() string {
+	return "survey"
+}
+
+// SurveyInvitation is a fulfilled appointment whose patient has not been sent a survey yet
+type SurveyInvitation struct {
+	AppointmentID    uint
+	PatientID        string
+	PatientFirstName string
+	PatientEmail     string
+	DoctorID         string
+	DoctorName       string
+	DateTime         string
+}
+
+// SurveyView is what the public survey page shows about the visit being rated
+type SurveyView struct {
+	AppointmentID uint   `json:"appointment_id"`
+	DoctorName    string `json:"doctor_name"`
+	DateTime      string `json:"date_time"`
+	Completed     bool   `json:"completed"`
+}
+
+// DoctorSurveyReport aggregates survey answers for one doctor
+type DoctorSurveyReport struct {
+	DoctorID      string  `json:"doctor_id"`
+	DoctorName    string  `json:"doctor_name"`
+	Sent          int64   `json:"sent"`
+	Responses     int64   `json:"responses"`
+	AverageRating float64 `json:"average_rating"`
+	Promoters     int64   `json:"promoters"`
+	Passives      int64   `json:"passives"`
+	Detractors    int64   `json:"detractors"`
+	NPS           float64 `json:"nps"`
+}
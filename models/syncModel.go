@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outcomes of the changes a sync client pushes
+const (
+	SyncApplied  = "applied"  // The change was saved
+	SyncConflict = "conflict" // The record changed or was deleted since the client pulled it; nothing was saved
+	SyncRejected = "rejected" // The change was refused, such as for failing validation
+)
+
+// SyncChange is a record created, updated or deleted since a sync cursor. Records still there come
+// with their data and sync version, which counts their updates.
+type SyncChange struct {
+	ID        string          `json:"id"`
+	Action    string          `json:"action"`
+	ChangedAt time.Time       `json:"changed_at"`
+	Version   int64           `json:"version,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// SyncEntityPage is the changes of an entity in a sync pull, and whether more were held back
+type SyncEntityPage struct {
+	Changes []SyncChange `json:"changes"`
+	HasMore bool         `json:"has_more"`
+}
+
+// SyncPage is a sync pull: the changes of each entity asked for, and the cursor to pull the next
+// ones with. Changes may come again in the next pull, which clients apply by version.
+type SyncPage struct {
+	Entities  map[string]SyncEntityPage `json:"entities"`
+	NextSince time.Time                 `json:"next_since"`
+	HasMore   bool                      `json:"has_more"`
+}
+
+// SyncPushChange is a change a sync client made offline. Records are created and updated with their
+// whole data, as through the API; updates and deletions name the version the client changed.
+type SyncPushChange struct {
+	Entity   string          `json:"entity"`
+	Action   string          `json:"action"`              // created, updated or deleted
+	ID       string          `json:"id,omitempty"`        // Of the record updated or deleted
+	ClientID string          `json:"client_id,omitempty"` // The client's own ID of a record it created, echoed back
+	Version  int64           `json:"version,omitempty"`   // Sync version the client changed
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// SyncPushResult is how a pushed change went: its record's ID and new version when it was applied,
+// the record as it is now on a conflict, or why it was rejected
+type SyncPushResult struct {
+	Entity   string          `json:"entity"`
+	ID       string          `json:"id,omitempty"`
+	ClientID string          `json:"client_id,omitempty"`
+	Status   string          `json:"status"`
+	Version  int64           `json:"version,omitempty"`
+	Current  json.RawMessage `json:"current,omitempty"` // Left out when the record was deleted
+	Error    string          `json:"error,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Task statuses
+const (
+	TaskStatusOpen       = "open"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusDone       = "done"
+	TaskStatusCancelled  = "cancelled"
+)
+
+// IsValidTaskStatus reports whether status is one of the task statuses
+func IsValidTaskStatus(status string) bool {
+	switch status {
+	case TaskStatusOpen, TaskStatusInProgress, TaskStatusDone, TaskStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Task is a to-do assigned to a staff member, optionally about a patient
+type Task struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Title       string     `gorm:"column:title;size:255;not null" json:"title"`
+	Description string     `gorm:"column:description;type:text" json:"description"`
+	AssigneeID  int64      `gorm:"column:assignee_id;not null;index:idx_task_assignee_status,priority:1" json:"assignee_id"`
+	CreatedBy   int64      `gorm:"column:created_by;not null" json:"created_by"`
+	PatientID   *string    `gorm:"column:patient_id;index" json:"patient_id,omitempty"`
+	DueDate     *time.Time `gorm:"column:due_date;index" json:"due_date,omitempty"`
+	Status      string     `gorm:"column:status;not null;default:open;check:status IN ('open', 'in_progress', 'done', 'cancelled');index:idx_task_assignee_status,priority:2" json:"status"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	Assignee    User       `gorm:"foreignKey:AssigneeID;references:ID" json:"-"`
+	Patient     *Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+}
+
+func (Task) TableName() string {
+	return "task"
+}
+
+// TaskFilter narrows down task queries
+type TaskFilter struct {
+	AssigneeID int64
+	PatientID  string
+	Status     string
+}
+
+// PatientNote is an internal note about a patient, visible only to staff
+type PatientNote struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID string    `gorm:"column:patient_id;not null;index:idx_patient_note_patient_created,priority:1" json:"patient_id"`
+	AuthorID  int64     `gorm:"column:author_id;not null" json:"author_id"`
+	Body      string    `gorm:"column:body;type:text;not null" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime;index:idx_patient_note_patient_created,priority:2" json:"created_at"`
+	Patient   Patient   `gorm:"foreignKey:PatientID;references:ID" json:"-"`
+}
+
+func (PatientNote) TableName() string {
+	return "patient_note"
+}
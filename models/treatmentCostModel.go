@@ -0,0 +1,53 @@
+package models
+
+// Financing options a treatment plan can be paid by
+const (
+	FinancingCash      = "cash"
+	FinancingInsurance = "insurance"
+)
+
+// TreatmentCostItem is a procedure of a treatment plan to be priced. Price overrides the catalog
+// price charged to cash patients, e.g. for work quoted separately.
+type TreatmentCostItem struct {
+	ProcedureID uint     `json:"procedure_id"`
+	Quantity    int      `json:"quantity"`
+	Price       *float64 `json:"price,omitempty"`
+}
+
+// TreatmentCostLine is what one procedure of a plan costs under a financing option
+type TreatmentCostLine struct {
+	ProcedureID    uint    `json:"procedure_id"`
+	Procedure      string  `json:"procedure"`
+	Quantity       int     `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	ContractRateID *uint   `json:"contract_rate_id,omitempty"`
+	Amount         float64 `json:"amount"`
+	InsurerPays    float64 `json:"insurer_pays"`
+	PatientPays    float64 `json:"patient_pays"`
+}
+
+// TreatmentCostBreakdown is what a plan costs under one financing option. Under insurance the
+// procedures are charged at the insurer's contract rates, and the insurer pays those it has a rate
+// for until the patient's remaining cover runs out; the patient pays the rest as a co-pay.
+type TreatmentCostBreakdown struct {
+	Option               string              `json:"option"`
+	InsuranceCompanyID   string              `json:"insurance_company_id,omitempty"`
+	InsuranceCompanyName string              `json:"insurance_company_name,omitempty"`
+	Scheme               string              `json:"scheme,omitempty"`
+	CoverLimit           float64             `json:"cover_limit,omitempty"`
+	CoverUsed            float64             `json:"cover_used,omitempty"`
+	RemainingCover       float64             `json:"remaining_cover,omitempty"`
+	Lines                []TreatmentCostLine `json:"lines"`
+	Total                float64             `json:"total"`
+	InsurerPays          float64             `json:"insurer_pays"`
+	PatientPays          float64             `json:"patient_pays"`
+}
+
+// TreatmentCostComparison sets what a treatment plan costs paying cash against each of the
+// patient's insurance schemes, so the patient sees their out-of-pocket cost before consenting
+type TreatmentCostComparison struct {
+	PatientID       string                   `json:"patient_id"`
+	TreatmentPlanID uint                     `json:"treatment_plan_id"`
+	CoverYear       int                      `json:"cover_year"`
+	Options         []TreatmentCostBreakdown `json:"options"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TreatmentPackage is a course of treatment sold at a fixed price, such as a full orthodontic
+// package of records, braces and adjustments. Applying it to a patient writes its items into a
+// treatment plan and spreads its price over InstallmentCount installments of a payment plan, so
+// every receptionist quotes the package the same way.
+type TreatmentPackage struct {
+	ID               uint                   `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name             string                 `gorm:"column:name;size:255;not null;uniqueIndex" json:"name"`
+	Description      string                 `gorm:"column:description;type:text" json:"description"`
+	Price            float64                `gorm:"column:price;not null" json:"price"`
+	InstallmentCount int                    `gorm:"column:installment_count;not null;default:1" json:"installment_count"`
+	IntervalMonths   int                    `gorm:"column:interval_months;not null;default:1" json:"interval_months"`
+	CreatedAt        time.Time              `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time              `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy        *int64                 `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy        *int64                 `gorm:"column:updated_by" json:"updated_by"`
+	Items            []TreatmentPackageItem `gorm:"foreignKey:PackageID;references:ID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+func (TreatmentPackage) TableName() string {
+	return "treatment_package"
+}
+
+func (p *TreatmentPackage) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (p *TreatmentPackage) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// TreatmentPackageItem is a line of a package: a catalog procedure or any other treatment,
+// done Quantity times
+type TreatmentPackageItem struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PackageID   uint       `gorm:"column:package_id;not null;index" json:"package_id"`
+	ProcedureID *uint      `gorm:"column:procedure_id;index" json:"procedure_id,omitempty"`
+	Description string     `gorm:"column:description;size:255;not null" json:"description"`
+	Quantity    int        `gorm:"column:quantity;not null;default:1" json:"quantity"`
+	Procedure   *Procedure `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (TreatmentPackageItem) TableName() string {
+	return "treatment_package_item"
+}
+
+// TreatmentPackageApplication is the treatment plan and payment plan a package was expanded into
+type TreatmentPackageApplication struct {
+	PackageID     uint             `json:"package_id"`
+	TreatmentPlan TreatmentPlan    `json:"treatment_plan"`
+	PaymentPlan   *PaymentPlanView `json:"payment_plan"`
+}
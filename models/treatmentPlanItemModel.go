@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Treatment plan item statuses. An item is proposed until the patient accepts or declines it, and
+// completed once the work is done.
+const (
+	TreatmentItemProposed  = "proposed"
+	TreatmentItemAccepted  = "accepted"
+	TreatmentItemCompleted = "completed"
+	TreatmentItemDeclined  = "declined"
+)
+
+// IsValidTreatmentItemStatus reports whether status is one of the treatment plan item statuses
+func IsValidTreatmentItemStatus(status string) bool {
+	switch status {
+	case TreatmentItemProposed, TreatmentItemAccepted, TreatmentItemCompleted, TreatmentItemDeclined:
+		return true
+	}
+	return false
+}
+
+// TreatmentPlanItem is a piece of work of a treatment plan, a catalog procedure or any other
+// treatment, with the fee quoted for it
+type TreatmentPlanItem struct {
+	ID              uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID       string     `gorm:"column:patient_id;not null;index:idx_treatment_plan_item_patient_status,priority:1" json:"patient_id"`
+	TreatmentPlanID uint       `gorm:"column:treatment_plan_id;not null;index" json:"treatment_plan_id"`
+	ProcedureID     *uint      `gorm:"column:procedure_id;index" json:"procedure_id,omitempty"`
+	Description     string     `gorm:"column:description;size:255;not null" json:"description"`
+	Tooth           string     `gorm:"column:tooth;size:20" json:"tooth,omitempty"`
+	Quantity        int        `gorm:"column:quantity;not null;default:1" json:"quantity"`
+	Amount          float64    `gorm:"column:amount;not null;default:0" json:"amount"` // The fee of the whole item, priced from the catalog when left out
+	Status          string     `gorm:"column:status;size:20;not null;default:proposed;check:status IN ('proposed', 'accepted', 'completed', 'declined');index:idx_treatment_plan_item_patient_status,priority:2" json:"status"`
+	AcceptedAt      *time.Time `gorm:"column:accepted_at" json:"accepted_at,omitempty"`
+	CompletedAt     *time.Time `gorm:"column:completed_at" json:"completed_at,omitempty"`
+	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy       *int64     `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy       *int64     `gorm:"column:updated_by" json:"updated_by"`
+
+	Patient       *Patient       `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	TreatmentPlan *TreatmentPlan `gorm:"foreignKey:TreatmentPlanID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Procedure     *Procedure     `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+func (TreatmentPlanItem) TableName() string {
+	return "treatment_plan_item"
+}
+
+func (i *TreatmentPlanItem) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (i *TreatmentPlanItem) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// UnscheduledTaskTitle is the title of the desk's tasks to call patients with unscheduled treatment
+const UnscheduledTaskTitle = "Call to book accepted treatment"
+
+// UnscheduledItem is an accepted item of a patient with no appointment booked to do it
+type UnscheduledItem struct {
+	ID              uint      `json:"id"`
+	PatientID       string    `json:"-"`
+	TreatmentPlanID uint      `json:"treatment_plan_id"`
+	Description     string    `json:"description"`
+	Tooth           string    `json:"tooth,omitempty"`
+	Amount          float64   `json:"amount"`
+	AcceptedAt      time.Time `json:"accepted_at"`
+}
+
+// UnscheduledPatient is a patient who accepted treatment and has no future appointment, with the
+// value of the accepted work waiting to be done
+type UnscheduledPatient struct {
+	PatientID     string            `json:"patient_id"`
+	PatientName   string            `json:"patient_name"`
+	Phone         string            `json:"phone"`
+	Value         float64           `json:"value"`
+	AcceptedSince time.Time         `json:"accepted_since"` // When the longest waiting item was accepted
+	LastVisit     *time.Time        `json:"last_visit,omitempty"`
+	OpenTaskID    *uint             `json:"open_task_id,omitempty"` // The desk's open call task, when one was created
+	Items         []UnscheduledItem `json:"items" gorm:"-"`
+}
+
+// UnscheduledTreatmentReport lists the patients with accepted treatment and no future appointment,
+// the most valuable first, and the value at stake across all of them
+type UnscheduledTreatmentReport struct {
+	Patients   int                  `json:"patients"`
+	Items      int                  `json:"items"`
+	TotalValue float64              `json:"total_value"`
+	Truncated  bool                 `json:"truncated,omitempty"` // Whether more patients are waiting than are listed
+	Entries    []UnscheduledPatient `json:"entries"`
+}
+
+// UnscheduledTreatmentFilter narrows down the unscheduled treatment listed to the items accepted
+// at least MinDays days ago
+type UnscheduledTreatmentFilter struct {
+	MinDays int
+	Limit   int
+}
+
+// UnscheduledTreatmentTotals are the counts and value of all unscheduled treatment
+type UnscheduledTreatmentTotals struct {
+	Patients   int
+	Items      int
+	TotalValue float64
+}
+
+// UnscheduledTaskRequest asks for the desk to call patients with unscheduled treatment, with a task
+// for each assigned to AssigneeID
+type UnscheduledTaskRequest struct {
+	PatientIDs []string   `json:"patient_ids" binding:"required"`
+	AssigneeID int64      `json:"assignee_id" binding:"required"`
+	DueDate    *time.Time `json:"due_date"`
+}
+
+// UnscheduledTaskResult is the call tasks created, and the patients skipped because they already
+// have an open call task or no longer have unscheduled treatment
+type UnscheduledTaskResult struct {
+	Created []Task   `json:"created"`
+	Skipped []string `json:"skipped"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Plan diff operations
+const (
+	PlanLineUnchanged = "unchanged"
+	PlanLineAdded     = "added"
+	PlanLineRemoved   = "removed"
+)
+
+// TreatmentPlanVersion is a treatment plan as it stood after a revision. Versions are never
+// changed, so the patient can be shown how their plan evolved.
+type TreatmentPlanVersion struct {
+	ID              uint          `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	TreatmentPlanID uint          `gorm:"column:treatment_plan_id;not null;uniqueIndex:idx_treatment_plan_version,priority:1" json:"treatment_plan_id"`
+	Version         int           `gorm:"column:version;not null;uniqueIndex:idx_treatment_plan_version,priority:2" json:"version"`
+	PatientID       string        `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	Plan            string        `gorm:"column:plan;not null" json:"plan"`
+	EstimatedCost   *float64      `gorm:"column:estimated_cost" json:"estimated_cost"`
+	Reason          string        `gorm:"column:reason;type:text;not null;default:''" json:"reason"`
+	CreatedAt       time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	CreatedBy       *int64        `gorm:"column:created_by" json:"created_by"`
+	TreatmentPlan   TreatmentPlan `gorm:"foreignKey:TreatmentPlanID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (TreatmentPlanVersion) TableName() string {
+	return "treatment_plan_version"
+}
+
+func (v *TreatmentPlanVersion) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// PlanDiffLine is a line of a plan kept, added or removed between two versions
+type PlanDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// PlanRevision is why a plan was revised into a version
+type PlanRevision struct {
+	Version int    `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+// TreatmentPlanDiff compares two versions of a treatment plan: the lines of the plan that changed,
+// how much the estimated cost moved and the reasons given for the revisions in between.
+type TreatmentPlanDiff struct {
+	TreatmentPlanID uint                 `json:"treatment_plan_id"`
+	From            TreatmentPlanVersion `json:"from"`
+	To              TreatmentPlanVersion `json:"to"`
+	Lines           []PlanDiffLine       `json:"lines"`
+	CostChange      *float64             `json:"cost_change"`
+	Revisions       []PlanRevision       `json:"revisions"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// APIUsage counts a user's requests to a route on a clinic day
+type APIUsage struct {
+	UserID   int64  `gorm:"column:user_id;primaryKey" json:"user_id"`
+	Day      string `gorm:"column:day;size:10;primaryKey" json:"day"`
+	Method   string `gorm:"column:method;size:10;primaryKey" json:"method"`
+	Route    string `gorm:"column:route;size:255;primaryKey" json:"route"`
+	Requests int64  `gorm:"column:requests;not null;default:0" json:"requests"`
+	Errors   int64  `gorm:"column:errors;not null;default:0" json:"errors"` // Requests answered with a 4xx or 5xx status
+}
+
+func (APIUsage) TableName() string {
+	return "api_usage"
+}
+
+// APIUsageCount is how often a user called a route over a period
+type APIUsageCount struct {
+	Method   string `json:"method"`
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// AuditEventCount is how many audit entries of an event a user has over a period
+type AuditEventCount struct {
+	Category string `json:"category"`
+	Event    string `json:"event"`
+	Count    int64  `json:"count"`
+}
+
+// UserActivityReport is what a user did over a period for the quarterly access review: their
+// sign-ins and sign-outs, their actions in the audit trail and how they used the API
+type UserActivityReport struct {
+	UserID         int64             `json:"user_id"`
+	Username       string            `json:"username"`
+	Email          string            `json:"email"`
+	Role           string            `json:"role"`
+	From           string            `json:"from"`
+	To             string            `json:"to"`
+	Logins         int64             `json:"logins"`
+	FailedLogins   int64             `json:"failed_logins"`
+	LastLoginAt    *time.Time        `json:"last_login_at,omitempty"`
+	LoginHistory   []AuditLog        `json:"login_history"`
+	LoginTruncated bool              `json:"login_history_truncated,omitempty"` // Whether there were more sign-ins than are listed
+	Actions        []AuditEventCount `json:"actions"`
+	RecentActions  []AuditLog        `json:"recent_actions"`
+	TotalRequests  int64             `json:"total_requests"`
+	APIUsage       []APIUsageCount   `json:"api_usage"`
+}
@@ -8,9 +8,12 @@ import (
 
 // Role represents a user role
 type Role struct {
-	ID          int64        `gorm:"primaryKey;column:id" json:"id"`
-	Name        string       `gorm:"size:50;not null;unique;index;column:name" json:"name"`
-	Description string       `gorm:"type:text;column:description" json:"description"`
+	ID          int64  `gorm:"primaryKey;column:id" json:"id"`
+	Name        string `gorm:"size:50;not null;unique;index;column:name" json:"name"`
+	Description string `gorm:"type:text;column:description" json:"description"`
+	// BasedOn is the built-in role the users of a custom role sign in as, reaching the routes of
+	// that role; what they may do there is still limited by the custom role's own permissions
+	BasedOn     string       `gorm:"size:50;column:based_on" json:"based_on,omitempty"`
 	CreatedAt   time.Time    `gorm:"autoCreateTime;column:created_at" json:"created_at"`
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
 }
@@ -19,16 +22,38 @@ func (Role) TableName() string {
 	return "roles"
 }
 
+// TokenRole is the role the access tokens of the role's users carry, which routes are guarded by
+func (r Role) TokenRole() string {
+	if r.BasedOn != "" {
+		return r.BasedOn
+	}
+	return r.Name
+}
+
+// seededRoles are the roles every installation starts with. Routes are guarded by their names, so
+// they cannot be deleted, though their permissions can be changed.
+var seededRoles = []Role{
+	{Name: "Admin", Description: "Full access to the system"},
+	{Name: "Doctor", Description: "Can manage patients and prescriptions"},
+	{Name: "Receptionist", Description: "Can handle appointments and billing"},
+	{Name: "Patient", Description: "Limited access to personal data"},
+	{Name: "Insurer", Description: "An insurer's claims officer, limited to the claims and bills of their company's members"},
+}
+
+// IsSeededRole reports whether name is one of the roles every installation starts with
+func IsSeededRole(name string) bool {
+	for _, role := range seededRoles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // SeedRoles inserts initial roles into the database
 func SeedRoles(db *gorm.DB) error {
-	initialRoles := []Role{
-		{Name: "Admin", Description: "Full access to the system"},
-		{Name: "Doctor", Description: "Can manage patients and prescriptions"},
-		{Name: "Receptionist", Description: "Can handle appointments and billing"},
-		{Name: "Patient", Description: "Limited access to personal data"},
-	}
 	return db.Transaction(func(tx *gorm.DB) error {
-		for _, role := range initialRoles {
+		for _, role := range seededRoles {
 			if err := tx.FirstOrCreate(&role, Role{Name: role.Name}).Error; err != nil {
 				return err
 			}
@@ -46,6 +71,8 @@ type User struct {
 	RoleID    int64     `gorm:"index;not null;column:role_id" json:"role_id"`
 	Role      Role      `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"role"`
 	CreatedAt time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	// EmailBouncedAt is when the email bounced permanently or was reported as spam
+	EmailBouncedAt *time.Time `gorm:"column:email_bounced_at" json:"email_bounced_at,omitempty"`
 }
 
 func (User) TableName() string {
@@ -63,6 +90,39 @@ func (Permission) TableName() string {
 	return "permissions"
 }
 
+// Billing permissions, checked on top of the roles billing routes are limited to, so a role can
+// take payments without refunding them, approving discounts or closing the books
+const (
+	PermissionCapturePayment  = "capture_payment"
+	PermissionApproveDiscount = "approve_discount"
+	PermissionIssueRefund     = "issue_refund"
+	PermissionClosePeriod     = "close_period"
+)
+
+// billingPermissions are granted to the roles named when first seeded, so the changes admins make
+// to them later stick
+var billingPermissions = []struct {
+	Permission
+	Roles []string
+}{
+	{Permission{Name: PermissionCapturePayment, Description: "Take payments against payment plan installments"}, []string{"Admin", "Receptionist"}},
+	{Permission{Name: PermissionApproveDiscount, Description: "Approve or reject bills priced well below their list price"}, []string{"Admin"}},
+	{Permission{Name: PermissionIssueRefund, Description: "Refund payments or write off balances"}, []string{"Admin"}},
+	{Permission{Name: PermissionClosePeriod, Description: "Close the books of a financial period"}, []string{"Admin"}},
+}
+
+// PermissionExportChart lets a role export a patient's full clinical chart, checked on top of the
+// roles the export is limited to, for transferring the patient to another practice
+const PermissionExportChart = "export_chart"
+
+// clinicalPermissions are granted to the roles named when first seeded, as billingPermissions are
+var clinicalPermissions = []struct {
+	Permission
+	Roles []string
+}{
+	{Permission{Name: PermissionExportChart, Description: "Export a patient's full clinical chart for a referral out"}, []string{"Admin", "Doctor"}},
+}
+
 // SeedPermissions inserts initial permissions into the database
 func SeedPermissions(db *gorm.DB) error {
 	initialPermissions := []Permission{
@@ -78,10 +138,39 @@ func SeedPermissions(db *gorm.DB) error {
 				return err
 			}
 		}
+		for _, seed := range append(billingPermissions, clinicalPermissions...) {
+			if err := seedGrantedPermission(tx, seed.Permission, seed.Roles); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
 
+// seedGrantedPermission inserts permission, granted to the roles named, unless it already exists
+func seedGrantedPermission(tx *gorm.DB, permission Permission, roles []string) error {
+	var existing int64
+	if err := tx.Model(&Permission{}).Where("name = ?", permission.Name).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+	if err := tx.Create(&permission).Error; err != nil {
+		return err
+	}
+	var roleIDs []int64
+	if err := tx.Model(&Role{}).Where("name IN ?", roles).Pluck("id", &roleIDs).Error; err != nil {
+		return err
+	}
+	for _, roleID := range roleIDs {
+		if err := tx.Create(&RolePermission{RoleID: roleID, PermissionID: permission.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RolePermission represents the association between roles and permissions
 type RolePermission struct {
 	ID           int64 `gorm:"primaryKey;column:id" json:"id"`
@@ -114,3 +203,11 @@ func SeedRolePermissions(db *gorm.DB) error {
 		return nil
 	})
 }
+
+// EffectivePermissions is what a user may do, through the permissions granted to their role
+type EffectivePermissions struct {
+	UserID      int64        `json:"user_id"`
+	Username    string       `json:"username"`
+	Role        string       `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Verification checks run against external services
+const (
+	VerificationCheckNationalID    = "national_id"
+	VerificationCheckInsurerMember = "insurer_member"
+)
+
+// Verification statuses
+const (
+	VerificationVerified = "verified"
+	VerificationFailed   = "failed" // The service rejected the details
+	VerificationError    = "error"  // The service could not be reached or gave no usable answer
+)
+
+// PatientVerification is the latest outcome of a check of a patient's details with an external service
+type PatientVerification struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	PatientID      string     `gorm:"column:patient_id;not null;uniqueIndex:idx_patient_verification_check,priority:1" json:"patient_id"`
+	Check          string     `gorm:"size:30;column:check_type;not null;uniqueIndex:idx_patient_verification_check,priority:2;check:check_type IN ('national_id', 'insurer_member')" json:"check"`
+	Reference      string     `gorm:"column:reference;not null" json:"reference"`
+	Status         string     `gorm:"size:20;column:status;not null;index;check:status IN ('verified', 'failed', 'error')" json:"status"`
+	Detail         string     `gorm:"column:detail;type:text" json:"detail,omitempty"`
+	CheckedAt      time.Time  `gorm:"column:checked_at;not null" json:"checked_at"`
+	ResolvedBy     *int64     `gorm:"column:resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `gorm:"column:resolved_at" json:"resolved_at,omitempty"`
+	ResolutionNote string     `gorm:"column:resolution_note;type:text" json:"resolution_note,omitempty"`
+	Patient        Patient    `gorm:"foreignKey:PatientID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (PatientVerification) TableName() string {
+	return "patient_verification"
+}
+
+// NeedsFollowUp reports whether staff still have to look into the outcome
+func (v *PatientVerification) NeedsFollowUp() bool {
+	return v.Status != VerificationVerified && v.ResolvedAt == nil
+}
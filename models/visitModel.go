@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Vitals are the measurements and confirmations taken when a patient is prepared for treatment
+type Vitals struct {
+	ID                     uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AppointmentID          uint      `gorm:"column:appointment_id;not null;uniqueIndex" json:"appointment_id"`
+	PatientID              string    `gorm:"column:patient_id;not null;index" json:"patient_id"`
+	SystolicBP             int       `gorm:"column:systolic_bp;not null" json:"systolic_bp"`
+	DiastolicBP            int       `gorm:"column:diastolic_bp;not null" json:"diastolic_bp"`
+	Pulse                  int       `gorm:"column:pulse;not null" json:"pulse"`
+	MedicalAlertsConfirmed bool      `gorm:"column:medical_alerts_confirmed;not null;default:false" json:"medical_alerts_confirmed"`
+	Notes                  string    `gorm:"column:notes;type:text" json:"notes,omitempty"`
+	CreatedAt              time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt              time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	CreatedBy              *int64    `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy              *int64    `gorm:"column:updated_by" json:"updated_by"`
+}
+
+func (Vitals) TableName() string {
+	return "vitals"
+}
+
+func (v *Vitals) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+func (v *Vitals) BeforeUpdate(tx *gorm.DB) error {
+	stampUpdated(tx)
+	return nil
+}
+
+// ChecklistItems are stored as a JSONB array of item texts
+type ChecklistItems []string
+
+func (i ChecklistItems) Value() (driver.Value, error) {
+	if i == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (i *ChecklistItems) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*i = ChecklistItems{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported checklist items value")
+	}
+	return json.Unmarshal(data, i)
+}
+
+// Checklist is a pre-procedure checklist that must be completed before treatment starts. It applies
+// to the appointments of doctors with Specialty, or to every appointment when Specialty is empty.
+type Checklist struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Name      string         `gorm:"column:name;size:255;not null;uniqueIndex" json:"name"`
+	Specialty string         `gorm:"column:specialty;size:50;not null;default:''" json:"specialty,omitempty"`
+	Items     ChecklistItems `gorm:"column:items;type:jsonb;not null" json:"items"`
+	Active    bool           `gorm:"column:active;not null;default:true" json:"active"`
+	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (Checklist) TableName() string {
+	return "checklist"
+}
+
+// ChecklistCompletion records that every item of a checklist was checked for an appointment
+type ChecklistCompletion struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	AppointmentID uint           `gorm:"column:appointment_id;not null;uniqueIndex:idx_checklist_completion,priority:1" json:"appointment_id"`
+	ChecklistID   uint           `gorm:"column:checklist_id;not null;uniqueIndex:idx_checklist_completion,priority:2" json:"checklist_id"`
+	Checklist     string         `gorm:"column:checklist_name;size:255;not null" json:"checklist"`
+	Items         ChecklistItems `gorm:"column:items;type:jsonb;not null" json:"items"`
+	CreatedAt     time.Time      `gorm:"column:created_at;autoCreateTime" json:"completed_at"`
+	CreatedBy     *int64         `gorm:"column:created_by" json:"completed_by"`
+	UpdatedBy     *int64         `gorm:"column:updated_by" json:"-"`
+}
+
+func (ChecklistCompletion) TableName() string {
+	return "checklist_completion"
+}
+
+func (c *ChecklistCompletion) BeforeCreate(tx *gorm.DB) error {
+	stampCreated(tx)
+	return nil
+}
+
+// VisitRecord is an appointment with what was captured while preparing the patient, and the
+// patient's active alerts with whether they were acknowledged
+type VisitRecord struct {
+	Appointment Appointment           `json:"appointment"`
+	Alerts      []VisitAlert          `json:"alerts"`
+	Vitals      *Vitals               `json:"vitals"`
+	Checklists  []ChecklistCompletion `json:"checklists"`
+	Outstanding []string              `json:"outstanding"`
+}
+
+// VisitSummary is what a patient is told about a visit: the examinations recorded and bills raised on
+// the day, their latest treatment plan and their next appointment
+type VisitSummary struct {
+	Appointment     Appointment
+	Day             time.Time
+	Procedure       string
+	Examinations    []Examination
+	Billings        []Billing
+	TreatmentPlan   *TreatmentPlan
+	NextAppointment *Appointment
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Appointment request statuses
+const (
+	AppointmentRequestPending  = "pending"
+	AppointmentRequestBooked   = "booked"
+	AppointmentRequestDeclined = "declined"
+)
+
+// Times of day a patient may prefer on an appointment request
+var PreferredTimes = []string{"any", "morning", "afternoon", "evening"}
+
+// AppointmentRequest is an appointment asked for on the clinic website, kept in a queue until a
+// receptionist books it or declines it
+type AppointmentRequest struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	FirstName     string     `gorm:"column:first_name;not null" json:"first_name"`
+	LastName      string     `gorm:"column:last_name;not null" json:"last_name"`
+	Phone         string     `gorm:"column:phone" json:"phone"`
+	Email         string     `gorm:"column:email" json:"email"`
+	DoctorID      *string    `gorm:"column:doctor_id" json:"doctor_id,omitempty"`
+	Doctor        *Doctor    `gorm:"foreignKey:DoctorID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+	ProcedureID   *uint      `gorm:"column:procedure_id" json:"procedure_id,omitempty"`
+	Procedure     *Procedure `gorm:"foreignKey:ProcedureID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+	PreferredDate string     `gorm:"column:preferred_date;size:10" json:"preferred_date,omitempty"` // YYYY-MM-DD
+	PreferredTime string     `gorm:"column:preferred_time;size:20;not null;default:any;check:preferred_time IN ('any', 'morning', 'afternoon', 'evening')" json:"preferred_time"`
+	Message       string     `gorm:"column:message;type:text" json:"message,omitempty"`
+	CampaignCode  string     `gorm:"column:campaign_code;size:50" json:"campaign_code,omitempty"` // Campaign that brought the patient to the website
+	Status        string     `gorm:"size:20;column:status;not null;default:pending;index;check:status IN ('pending', 'booked', 'declined')" json:"status"`
+	AppointmentID *uint      `gorm:"column:appointment_id" json:"appointment_id,omitempty"` // The appointment it was booked as
+	ReviewedBy    *int64     `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewNote    string     `gorm:"column:review_note;type:text" json:"review_note,omitempty"`
+	IP            string     `gorm:"size:64;column:ip" json:"ip"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+func (AppointmentRequest) TableName() string {
+	return "appointment_request"
+}
+
+// WebsiteDoctor is a doctor as listed on the clinic website
+type WebsiteDoctor struct {
+	ID         string `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Specialty  string `json:"specialty"`
+	PhotoURL   string `json:"photo_url,omitempty"`
+	ServiceIDs []uint `json:"service_ids"` // The services the doctor can be requested for
+}
+
+// WebsiteService is a procedure as listed on the clinic website
+type WebsiteService struct {
+	ID        uint     `json:"id"`
+	Name      string   `json:"name"`
+	Specialty string   `json:"specialty"`
+	Price     *float64 `json:"price,omitempty"` // Only listed when the clinic publishes its prices
+}
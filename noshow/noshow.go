@@ -0,0 +1,45 @@
+// Package noshow rates how likely upcoming appointments are to be missed through a pluggable scorer:
+// a heuristic built on the patient's history and the booking, or a webhook in front of any other model.
+package noshow
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Scorer rates upcoming appointments
+type Scorer interface {
+	// Score returns the likelihood between 0 and 1 that the appointment is missed
+	Score(ctx context.Context, features models.NoShowFeatures) (float64, error)
+}
+
+// New returns the configured scorer, or nil when appointments are not scored
+func New(cfg config.NoShowConfig) (Scorer, error) {
+	switch cfg.Scorer {
+	case "":
+		return nil, nil
+	case "heuristic":
+		return Heuristic{}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("NO_SHOW_SCORER_URL is required for the webhook scorer")
+		}
+		return &Webhook{URL: cfg.URL, Token: cfg.Token, Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown no-show scorer %q", cfg.Scorer)
+}
+
+// clamp keeps a score between 0 and 1
+func clamp(score float64) float64 {
+	switch {
+	case score < 0:
+		return 0
+	case score > 1:
+		return 1
+	}
+	return score
+}
@@ -0,0 +1,91 @@
+package noshow
+
+import (
+	"RoyDental/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// baseRate is the share of appointments assumed missed by patients without a history
+	baseRate = 0.1
+	// priorWeight is how many appointments the base rate counts as, so a single miss does not
+	// make a new patient look as risky as a habitual one
+	priorWeight = 3.0
+)
+
+// Heuristic scores appointments by the patient's share of missed appointments, raised for
+// appointments booked far ahead and on the days of the week patients miss most
+type Heuristic struct{}
+
+func (Heuristic) Score(_ context.Context, f models.NoShowFeatures) (float64, error) {
+	score := (float64(f.PastNoShows) + baseRate*priorWeight) / (float64(f.PastAppointments) + priorWeight)
+
+	switch lead := f.LeadTime(); {
+	case lead < 24*time.Hour:
+		score -= 0.05
+	case lead > 30*24*time.Hour:
+		score += 0.1
+	case lead > 14*24*time.Hour:
+		score += 0.05
+	}
+
+	switch f.StartsAt.In(models.ClinicLocation()).Weekday() {
+	case time.Monday, time.Friday:
+		score += 0.05
+	case time.Saturday:
+		score += 0.03
+	}
+
+	// Patients in pain rarely miss an emergency appointment
+	if f.Type == models.AppointmentTypeEmergency {
+		score /= 2
+	}
+	return clamp(score), nil
+}
+
+// Webhook posts the appointment's features as JSON and expects {"score": ...}
+type Webhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (w *Webhook) Score(ctx context.Context, f models.NoShowFeatures) (float64, error) {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("no-show scoring webhook returned %s", resp.Status)
+	}
+
+	var result struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode no-show scoring webhook response: %w", err)
+	}
+	if result.Score == nil {
+		return 0, errors.New("no-show scoring webhook returned no score")
+	}
+	return clamp(*result.Score), nil
+}
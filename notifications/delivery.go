@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrSuppressed is returned for emails to addresses that bounced permanently or complained. Like
+// messages the patient has not agreed to receive, senders treat them as handled rather than
+// retrying them.
+var ErrSuppressed = fmt.Errorf("%w: the address bounced or reported our email as spam", ErrNotPermitted)
+
+// NewMessageID returns a unique Message-ID header for an email, in the domain of the SMTP sender
+func NewMessageID() string {
+	domain := "localhost"
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USER")
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)
+}
+
+// SuppressionChecker tells which addresses are no longer emailed
+type SuppressionChecker interface {
+	Suppressed(ctx context.Context, addresses []string) ([]string, error)
+}
+
+// SuppressingNotifier leaves the addresses that bounced permanently or complained out of the emails
+// it passes on to Next, and returns ErrSuppressed when none is left.
+type SuppressingNotifier struct {
+	Next         Notifier
+	Suppressions SuppressionChecker
+}
+
+func (n SuppressingNotifier) Send(ctx context.Context, notification Notification) error {
+	suppressed, err := n.Suppressions.Suppressed(ctx, notification.Recipients)
+	if err != nil {
+		return err
+	}
+	if len(suppressed) == 0 {
+		return n.Next.Send(ctx, notification)
+	}
+	skip := make(map[string]bool, len(suppressed))
+	for _, address := range suppressed {
+		skip[address] = true
+	}
+	recipients := make([]string, 0, len(notification.Recipients))
+	for _, recipient := range notification.Recipients {
+		if !skip[strings.ToLower(strings.TrimSpace(recipient))] {
+			recipients = append(recipients, recipient)
+		}
+	}
+	if len(recipients) == 0 {
+		return ErrSuppressed
+	}
+	notification.Recipients = recipients
+	return n.Next.Send(ctx, notification)
+}
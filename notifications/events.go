@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"RoyDental/config"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types that can be posted to chat
+const (
+	EventOperationalAlert     = "operational_alert"
+	EventNewOnlineBooking     = "new_online_booking"
+	EventLargeBalance         = "large_balance"
+	EventVerificationFailed   = "verification_failed"
+	EventAppointmentCancelled = "appointment_cancelled"
+	EventApprovalRequested    = "approval_requested"
+	EventApprovalDecided      = "approval_decided"
+	EventEligibilityFailed    = "eligibility_failed"
+	EventCredentialExpiring   = "credential_expiring"
+	EventSMSReplyReceived     = "sms_reply_received"
+)
+
+// eventTimeout bounds the delivery of a published event.
+const eventTimeout = 30 * time.Second
+
+var (
+	eventsMu      sync.RWMutex
+	eventChannels = map[string]Notifier{}
+)
+
+// SetEventChannels routes every configured event type to its chat webhook.
+func SetEventChannels(cfg config.ChatWebhookConfig) {
+	channels := map[string]Notifier{}
+	webhooks := map[string]*WebhookNotifier{}
+	for event, url := range cfg.URLs {
+		// Events sharing a webhook share its client
+		if _, ok := webhooks[url]; !ok {
+			webhooks[url] = NewWebhookNotifier(url, cfg.Format)
+		}
+		channels[event] = webhooks[url]
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventChannels = channels
+}
+
+// RetryEvents has the chat webhooks set with SetEventChannels queue the posts they fail to deliver,
+// wrapping each event's channel with retry under the transport webhook:<event>
+func RetryEvents(retry func(transport string, next Notifier) Notifier) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for event, channel := range eventChannels {
+		eventChannels[event] = retry(TransportWebhook+":"+event, channel)
+	}
+}
+
+// ForEvent returns the channel of an event type, or nil when it is not posted anywhere.
+func ForEvent(event string) Notifier {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	return eventChannels[event]
+}
+
+// Publish posts an event to its channel in the background. Events without a channel are dropped.
+func Publish(event, subject, body string) {
+	channel := ForEvent(event)
+	if channel == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		if err := channel.Send(ctx, Notification{Subject: subject, Body: body}); err != nil {
+			log.Printf("Failed to post %s event: %v", event, err)
+		}
+	}()
+}
@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"RoyDental/metrics"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// MailFailures counts emails the SMTP server did not accept, by kind of email.
+var MailFailures = metrics.NewCounterVec("mail_delivery_failures_total", "Emails that could not be delivered.", "kind")
+
+// Notification is a message sent to staff, such as a security alert, or to a patient. Messages to
+// a patient name them, so a PatientNotifier can check what they agreed to receive.
+type Notification struct {
+	Recipients  []string
+	Subject     string
+	Body        string
+	PatientID   string       // The patient the message is for, empty for staff
+	Purpose     string       // Why a patient is contacted, PurposeService when empty
+	Attachments []Attachment // Files sent along by email; other channels leave them out
+	MessageID   string       // Message-ID header of an email, which the provider's bounces refer to; generated when empty
+	Expires     time.Time    // When a message held back by quiet hours stops being of use, such as the start of the appointment reminded of; zero when it never does
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// EmailNotifier sends notifications over SMTP.
+type EmailNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewEmailNotifierFromEnv configures an EmailNotifier from the SMTP_* environment variables.
+func NewEmailNotifierFromEnv() (*EmailNotifier, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, errors.New("SMTP_HOST environment variable is not set")
+	}
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PORT value: %w", err)
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USER")
+	}
+	return &EmailNotifier{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASS"),
+		From:     from,
+	}, nil
+}
+
+func (n *EmailNotifier) Send(ctx context.Context, notification Notification) error {
+	if len(notification.Recipients) == 0 {
+		return ErrNoRecipients
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", n.From)
+	m.SetHeader("To", notification.Recipients...)
+	m.SetHeader("Subject", notification.Subject)
+	messageID := notification.MessageID
+	if messageID == "" {
+		messageID = NewMessageID()
+	}
+	m.SetHeader("Message-ID", messageID)
+	m.SetBody("text/plain", notification.Body)
+	for _, attachment := range notification.Attachments {
+		data := attachment.Data
+		m.Attach(attachment.Name,
+			gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}))
+	}
+
+	d := gomail.NewDialer(n.Host, n.Port, n.Username, n.Password)
+	if err := d.DialAndSend(m); err != nil {
+		MailFailures.Inc("notification")
+		return fmt.Errorf("failed to send email to %s: %w", strings.Join(notification.Recipients, ", "), err)
+	}
+	return nil
+}
+
+// LogNotifier writes notifications to the log. It is used when no delivery channel is configured.
+type LogNotifier struct {
+	Printf func(format string, args ...interface{})
+}
+
+func (n LogNotifier) Send(_ context.Context, notification Notification) error {
+	n.Printf("Notification to %s: %s\n%s", strings.Join(notification.Recipients, ", "), notification.Subject, notification.Body)
+	return nil
+}
+
+// Webhook payload formats
+const (
+	WebhookFormatSlack = "slack"
+	WebhookFormatTeams = "teams"
+)
+
+// WebhookNotifier posts notifications to a Slack or Microsoft Teams incoming webhook.
+type WebhookNotifier struct {
+	URL    string
+	Format string
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier with a bounded request timeout.
+func NewWebhookNotifier(url, format string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Format: format, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) payload(notification Notification) interface{} {
+	if n.Format == WebhookFormatTeams {
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  notification.Subject,
+			"title":    notification.Subject,
+			"text":     strings.ReplaceAll(notification.Body, "\n", "<br>"),
+		}
+	}
+	return map[string]string{"text": "*" + notification.Subject + "*\n" + notification.Body}
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, notification Notification) error {
+	payload, err := json.Marshal(n.payload(notification))
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MultiNotifier sends every notification through all of its notifiers.
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Send(ctx context.Context, notification Notification) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Send(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+)
+
+// Why a patient is contacted
+const (
+	PurposeService   = "service"   // Messages about care the patient receives, such as surveys
+	PurposeReminder  = "reminder"  // Reminders of appointments and payments, which patients may opt out of
+	PurposeMarketing = "marketing" // Offers and newsletters, only sent to patients who opted in
+)
+
+// Channels patients are contacted on
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// ErrNotPermitted is returned for patient messages the patient has not agreed to receive. Senders
+// treat the message as handled rather than retrying it.
+var ErrNotPermitted = errors.New("the patient has not agreed to receive this message")
+
+// PreferenceChecker answers what a patient agreed to be sent
+type PreferenceChecker interface {
+	// Allows reports whether the patient may be contacted on channel for purpose
+	Allows(ctx context.Context, patientID, channel, purpose string) (bool, error)
+	// PreferencesLink returns the link the patient changes their preferences at, or "" when there is none
+	PreferencesLink(patientID string) string
+}
+
+// PatientNotifier sends messages to patients through Next only when their communication preferences
+// allow it, adding the link to change them. Messages that name no patient are passed on unchanged.
+type PatientNotifier struct {
+	Next        Notifier
+	Channel     string
+	Preferences PreferenceChecker
+}
+
+func (n PatientNotifier) Send(ctx context.Context, notification Notification) error {
+	if notification.PatientID == "" {
+		return n.Next.Send(ctx, notification)
+	}
+	purpose := notification.Purpose
+	if purpose == "" {
+		purpose = PurposeService
+	}
+	allowed, err := n.Preferences.Allows(ctx, notification.PatientID, n.Channel, purpose)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrNotPermitted
+	}
+	if link := n.Preferences.PreferencesLink(notification.PatientID); link != "" {
+		notification.Body += "\n--\nTo choose which messages you receive from us, visit " + link + "\n"
+	}
+	return n.Next.Send(ctx, notification)
+}
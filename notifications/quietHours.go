@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeferred is returned for patient messages held back during quiet hours. They are queued and
+// delivered once the quiet period ends, so senders treat them as handled.
+var ErrDeferred = errors.New("the message is held until quiet hours end")
+
+// ErrQuietHours is returned for patient messages that quiet hours would hold back until after they
+// stop being of use, such as a reminder of an appointment that will have started. They are dropped.
+var ErrQuietHours = fmt.Errorf("%w: quiet hours last until after the message stops being of use", ErrNotPermitted)
+
+// DeferredQueue holds back patient messages during quiet periods
+type DeferredQueue interface {
+	// HeldUntil returns when the quiet period messages on channel are held back in ends, or the zero
+	// time when they go out now
+	HeldUntil(ctx context.Context, channel string) (time.Time, error)
+	// Defer queues the notification to be delivered on channel at sendAt
+	Defer(ctx context.Context, channel string, notification Notification, sendAt time.Time) error
+}
+
+// QuietHoursNotifier passes patient messages on to Next outside quiet periods, and queues them with
+// Queue to be delivered when the quiet period ends otherwise. Messages that name no patient, such as
+// staff alerts, are passed on unchanged.
+type QuietHoursNotifier struct {
+	Next    Notifier
+	Channel string
+	Queue   DeferredQueue
+}
+
+func (n QuietHoursNotifier) Send(ctx context.Context, notification Notification) error {
+	if notification.PatientID == "" {
+		return n.Next.Send(ctx, notification)
+	}
+	until, err := n.Queue.HeldUntil(ctx, n.Channel)
+	if err != nil {
+		return err
+	}
+	if until.IsZero() {
+		return n.Next.Send(ctx, notification)
+	}
+	if !notification.Expires.IsZero() && !until.Before(notification.Expires) {
+		return ErrQuietHours
+	}
+	if err := n.Queue.Defer(ctx, n.Channel, notification, until); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w at %s", ErrDeferred, until.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,66 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Transports notifications are delivered through, which failed deliveries are tried again on.
+// Chat events are posted through TransportWebhook + ":" + the event type.
+const (
+	TransportEmail   = "email"
+	TransportSMS     = "sms"
+	TransportWebhook = "webhook"
+)
+
+// ErrNoRecipients is returned for notifications addressed to no one, which no retry can deliver
+var ErrNoRecipients = errors.New("notification has no recipients")
+
+// ErrRetrying is returned for notifications a transport failed to deliver, such as while the SMTP
+// server or a webhook is down. They are queued and tried again later, so like messages held back
+// by quiet hours, which it counts as, senders treat them as handled.
+var ErrRetrying error = retryingError{}
+
+type retryingError struct{}
+
+func (retryingError) Error() string {
+	return "delivery failed and will be tried again"
+}
+
+func (retryingError) Is(target error) bool {
+	return target == ErrDeferred
+}
+
+// RetryQueue keeps the notifications a transport failed to deliver to try them again later
+type RetryQueue interface {
+	Retry(ctx context.Context, transport string, notification Notification, cause error) error
+}
+
+// RetryingNotifier delivers notifications through Next, a transport, and queues those it fails to
+// deliver with Queue to be tried again. Notifications that could never be delivered, such as those
+// addressed to no one or to suppressed addresses, are not retried.
+type RetryingNotifier struct {
+	Next      Notifier
+	Transport string
+	Queue     RetryQueue
+}
+
+func (n RetryingNotifier) Send(ctx context.Context, notification Notification) error {
+	err := n.Next.Send(ctx, notification)
+	if !Retryable(err) {
+		return err
+	}
+	// The delivery is queued even when the request that sent it was cancelled meanwhile
+	if queueErr := n.Queue.Retry(context.WithoutCancel(ctx), n.Transport, notification, err); queueErr != nil {
+		log.Printf("Failed to queue %s delivery to be tried again: %v", n.Transport, queueErr)
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrRetrying, err)
+}
+
+// Retryable reports whether a delivery that returned err may go through when tried again
+func Retryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotPermitted) && !errors.Is(err, ErrDeferred) && !errors.Is(err, ErrNoRecipients)
+}
@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMSNotifier sends text messages through an SMS gateway webhook, posting
+// {"to": [...], "message": "..."} with the subject ahead of the body
+type SMSNotifier struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewSMSNotifierFromEnv configures an SMSNotifier from the SMS_WEBHOOK_* environment variables.
+func NewSMSNotifierFromEnv() (*SMSNotifier, error) {
+	url := os.Getenv("SMS_WEBHOOK_URL")
+	if url == "" {
+		return nil, errors.New("SMS_WEBHOOK_URL environment variable is not set")
+	}
+	return &SMSNotifier{URL: url, Token: os.Getenv("SMS_WEBHOOK_TOKEN"), Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (n *SMSNotifier) Send(ctx context.Context, notification Notification) error {
+	if len(notification.Recipients) == 0 {
+		return ErrNoRecipients
+	}
+	message := notification.Body
+	if notification.Subject != "" {
+		message = notification.Subject + "\n" + message
+	}
+	payload, err := json.Marshal(map[string]interface{}{"to": notification.Recipients, "message": message})
+	if err != nil {
+		return fmt.Errorf("failed to encode SMS payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create SMS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS to %s: %w", strings.Join(notification.Recipients, ", "), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Webhook posts the checkout request as JSON and expects {"gateway_reference": ..., "payment_url": ...}
+type Webhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (w *Webhook) Checkout(ctx context.Context, request CheckoutRequest) (Checkout, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Checkout{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return Checkout{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return Checkout{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return Checkout{}, fmt.Errorf("payment gateway returned %s", resp.Status)
+	}
+
+	var checkout Checkout
+	if err := json.NewDecoder(resp.Body).Decode(&checkout); err != nil {
+		return Checkout{}, fmt.Errorf("failed to decode payment gateway response: %w", err)
+	}
+	if checkout.PaymentURL == "" {
+		return Checkout{}, errors.New("payment gateway returned no payment URL")
+	}
+	return checkout, nil
+}
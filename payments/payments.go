@@ -0,0 +1,83 @@
+// Package payments takes patients' payments through a pluggable online payment gateway. The gateway
+// hosts the checkout page and reports the outcome back through a signed callback.
+package payments
+
+import (
+	"RoyDental/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Callback statuses reported by the gateway
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// CheckoutRequest asks the gateway to take a payment
+type CheckoutRequest struct {
+	Reference   string  `json:"reference"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Description string  `json:"description"`
+	Email       string  `json:"email,omitempty"`
+	ReturnURL   string  `json:"return_url,omitempty"`
+}
+
+// Checkout is a payment started at the gateway, paid by the patient on PaymentURL
+type Checkout struct {
+	GatewayReference string `json:"gateway_reference"`
+	PaymentURL       string `json:"payment_url"`
+}
+
+// Callback is the gateway reporting how a payment ended
+type Callback struct {
+	Reference        string  `json:"reference"`
+	GatewayReference string  `json:"gateway_reference"`
+	Status           string  `json:"status"`
+	Amount           float64 `json:"amount"`
+	Reason           string  `json:"reason"`
+}
+
+// Gateway starts payments
+type Gateway interface {
+	// Checkout starts a payment and returns where the patient pays it
+	Checkout(ctx context.Context, request CheckoutRequest) (Checkout, error)
+}
+
+// New returns the configured gateway, or nil when online payments are switched off
+func New(cfg config.PaymentGatewayConfig) (Gateway, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("PAYMENT_GATEWAY_URL is required for the webhook provider")
+		}
+		if cfg.CallbackSecret == "" {
+			return nil, errors.New("PAYMENT_GATEWAY_CALLBACK_SECRET is required to accept payment callbacks")
+		}
+		return &Webhook{URL: cfg.URL, Token: cfg.Token, Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown payment gateway provider %q", cfg.Provider)
+}
+
+// Sign returns the hex HMAC-SHA256 of a callback body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback reports whether signature is the gateway's signature of body
+func VerifyCallback(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
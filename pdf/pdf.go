@@ -0,0 +1,276 @@
+// Package pdf writes simple text documents as PDF: headings and wrapped paragraphs on A4 pages in
+// the standard Helvetica fonts, square codes drawn from modules and pictures, enough for letters,
+// summaries and charts without a rendering library.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// A4 page size and margins in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 56.0
+)
+
+// averageCharWidth is a generous average Helvetica character width in ems, so wrapped lines never
+// run past the margin
+const averageCharWidth = 0.55
+
+// Font resources of every page
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// pixelsPerPoint is the resolution pictures are kept at, enough for print while keeping files small
+const pixelsPerPoint = 2
+
+// Document is a PDF being laid out line by line
+type Document struct {
+	pages    []*bytes.Buffer
+	current  *bytes.Buffer
+	y        float64
+	pictures []picture
+}
+
+// picture is an image drawn on a page, as its compressed samples
+type picture struct {
+	width, height int
+	gray          bool
+	samples       []byte
+}
+
+// New returns an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// Title writes a large bold line
+func (d *Document) Title(text string) {
+	d.lines(text, fontBold, 16)
+	d.Space(6)
+}
+
+// Heading writes a bold line, kept on the same page as the line after it
+func (d *Document) Heading(text string) {
+	d.Space(6)
+	if d.current != nil && d.y-2*12*1.4 < margin {
+		d.newPage()
+	}
+	d.lines(text, fontBold, 12)
+	d.Space(2)
+}
+
+// Text writes a paragraph, wrapped to the page width. Line breaks in text are kept.
+func (d *Document) Text(text string) {
+	d.lines(text, fontRegular, 10)
+}
+
+// Space leaves points of vertical space
+func (d *Document) Space(points float64) {
+	if d.current != nil {
+		d.y -= points
+	}
+}
+
+// Matrix draws a grid of dark and light squares, such as a QR code, side points wide at the left margin
+func (d *Document) Matrix(modules [][]bool, side float64) {
+	if len(modules) == 0 {
+		return
+	}
+	if d.current == nil || d.y-side < margin {
+		d.newPage()
+	}
+	d.y -= side
+	cell := side / float64(len(modules))
+	for row, line := range modules {
+		for column, dark := range line {
+			if dark {
+				fmt.Fprintf(d.current, "%.3f %.3f %.3f %.3f re\n", margin+float64(column)*cell, d.y+side-float64(row+1)*cell, cell, cell)
+			}
+		}
+	}
+	d.current.WriteString("f\n")
+}
+
+// Picture draws img width points wide at the left margin, no taller than a page, scaled down to
+// pixelsPerPoint. Grayscale images such as radiographs are kept in gray.
+func (d *Document) Picture(img image.Image, width float64) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	width = min(width, pageWidth-2*margin)
+	height := width * float64(bounds.Dy()) / float64(bounds.Dx())
+	if height > pageHeight-2*margin {
+		width, height = width*(pageHeight-2*margin)/height, pageHeight-2*margin
+	}
+	if d.current == nil || d.y-height < margin {
+		d.newPage()
+	}
+	d.y -= height
+	fmt.Fprintf(d.current, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, margin, d.y, len(d.pictures))
+	d.pictures = append(d.pictures, newPicture(img, int(width*pixelsPerPoint), int(height*pixelsPerPoint)))
+}
+
+// newPicture samples img by nearest neighbour at most columns by rows pixels, and compresses them
+func newPicture(img image.Image, columns, rows int) picture {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	columns, rows = max(min(columns, width), 1), max(min(rows, height), 1)
+	p := picture{width: columns, height: rows}
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		p.gray = true
+	}
+
+	var samples bytes.Buffer
+	writer := zlib.NewWriter(&samples)
+	row := make([]byte, 0, 3*columns)
+	for y := 0; y < rows; y++ {
+		row = row[:0]
+		for x := 0; x < columns; x++ {
+			c := img.At(bounds.Min.X+x*width/columns, bounds.Min.Y+y*height/rows)
+			if p.gray {
+				row = append(row, color.GrayModel.Convert(c).(color.Gray).Y)
+				continue
+			}
+			rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+			row = append(row, rgba.R, rgba.G, rgba.B)
+		}
+		writer.Write(row)
+	}
+	writer.Close()
+	p.samples = samples.Bytes()
+	return p
+}
+
+func (d *Document) lines(text, font string, size float64) {
+	leading := size * 1.4
+	for _, line := range wrap(text, int((pageWidth-2*margin)/(size*averageCharWidth))) {
+		if d.current == nil || d.y-leading < margin {
+			d.newPage()
+		}
+		d.y -= leading
+		fmt.Fprintf(d.current, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, margin, d.y, escape(line))
+	}
+}
+
+func (d *Document) newPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+	d.y = pageHeight - margin
+}
+
+// Bytes returns the document as a PDF file
+func (d *Document) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.newPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 4 are the catalog, page tree and fonts; each page follows with its content, and
+	// the pictures come last
+	out.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	var xObjects strings.Builder
+	for i := range d.pictures {
+		fmt.Fprintf(&xObjects, " /Im%d %d 0 R", i, 5+2*len(d.pages)+i)
+	}
+	resources := fmt.Sprintf("/Font << /%s 3 0 R /%s 4 0 R >>", fontRegular, fontBold)
+	if xObjects.Len() > 0 {
+		resources += " /XObject <<" + xObjects.String() + " >>"
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << %s >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, resources, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+	for _, p := range d.pictures {
+		colorSpace := "/DeviceRGB"
+		if p.gray {
+			colorSpace = "/DeviceGray"
+		}
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			p.width, p.height, colorSpace, len(p.samples), p.samples))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// wrap breaks text into lines of at most width characters, at spaces where it can
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escape encodes a line as a PDF string in WinAnsi, replacing what it cannot show
+func escape(line string) string {
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '\t':
+			b.WriteString("    ")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,242 @@
+package qr
+
+// grid is a code being laid out: the modules and which of them belong to the function patterns
+// rather than the data
+type grid struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newGrid(version int) *grid {
+	size := 17 + 4*version
+	g := &grid{version: version, size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range g.modules {
+		g.modules[y] = make([]bool, size)
+		g.function[y] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		g.set(6, i, i%2 == 0)
+		g.set(i, 6, i%2 == 0)
+	}
+	g.drawFinder(3, 3)
+	g.drawFinder(size-4, 3)
+	g.drawFinder(3, size-4)
+	centres := layouts[version].alignment
+	last := len(centres) - 1
+	for i, x := range centres {
+		for j, y := range centres {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			g.drawAlignment(x, y)
+		}
+	}
+	// Reserve the format areas until the mask is chosen
+	g.drawFormat(0)
+	g.drawVersion()
+	return g
+}
+
+func (g *grid) set(x, y int, dark bool) {
+	g.modules[y][x] = dark
+	g.function[y][x] = true
+}
+
+func (g *grid) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= g.size || y < 0 || y >= g.size {
+				continue
+			}
+			distance := max(abs(dx), abs(dy))
+			g.set(x, y, distance != 2 && distance != 4)
+		}
+	}
+}
+
+func (g *grid) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			g.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat writes the error correction level M and the mask, twice, with the dark module
+func (g *grid) drawFormat(mask int) {
+	data := mask // Level M is 00
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		g.set(8, i, bit(i))
+	}
+	g.set(8, 7, bit(6))
+	g.set(8, 8, bit(7))
+	g.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		g.set(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.set(8, g.size-15+i, bit(i))
+	}
+	g.set(8, g.size-8, true)
+}
+
+// drawVersion writes the version, which versions 7 and up carry twice
+func (g *grid) drawVersion() {
+	if g.version < 7 {
+		return
+	}
+	remainder := g.version
+	for i := 0; i < 12; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+	bits := g.version<<12 | remainder
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := g.size-11+i%3, i/3
+		g.set(a, b, dark)
+		g.set(b, a, dark)
+	}
+}
+
+// place writes the codewords in the zigzag from the bottom right corner, two columns at a time
+func (g *grid) place(data []byte) {
+	i := 0
+	for right := g.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < g.size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vertical
+				if (right+1)&2 == 0 {
+					y = g.size - 1 - vertical
+				}
+				if g.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				g.modules[y][x] = (data[i/8]>>(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask; applying it again undoes it
+func (g *grid) applyMask(mask int) {
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !g.function[y][x] {
+				g.modules[y][x] = !g.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the masked code is to scan: long runs, blocks of one colour, patterns
+// looking like the finders and an unbalanced share of dark modules
+func (g *grid) penalty() int {
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return g.modules[x][y]
+		}
+		return g.modules[y][x]
+	}
+
+	result, dark := 0, 0
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < g.size; y++ {
+			run := 1
+			for x := 1; x <= g.size; x++ {
+				if x < g.size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+len(finderLike) <= g.size; x++ {
+				matches := true
+				for i, want := range finderLike {
+					if at(x+i, y, vertical) != want {
+						matches = false
+						break
+					}
+				}
+				if matches && (lightRun(x-4, x, y, vertical, at, g.size) || lightRun(x+7, x+11, y, vertical, at, g.size)) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				colour := g.modules[y][x]
+				if colour == g.modules[y-1][x] && colour == g.modules[y][x-1] && colour == g.modules[y-1][x-1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := g.size * g.size
+	result += abs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+// lightRun reports whether the modules from start up to end are light, counting those off the
+// edge of the code as light
+func lightRun(start, end, line int, vertical bool, at func(x, y int, vertical bool) bool, size int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < size && at(i, line, vertical) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
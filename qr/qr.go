@@ -0,0 +1,217 @@
+// Package qr encodes text as QR codes: byte mode at error correction level M, versions 1 to 10,
+// enough for the short identifiers printed on the clinic's documents without an imaging library.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong is returned for text that does not fit in the largest supported QR code
+var ErrTooLong = errors.New("the text is too long for a QR code")
+
+// quietZone is the light border, in modules, scanners need around a code
+const quietZone = 4
+
+// blockLayout is how a version's codewords are split into blocks at error correction level M
+type blockLayout struct {
+	total     int // Data and error correction codewords
+	ecc       int // Error correction codewords of each block
+	blocks    int
+	alignment []int // Centres of the alignment patterns
+}
+
+var layouts = [...]blockLayout{
+	1:  {26, 10, 1, nil},
+	2:  {44, 16, 1, []int{6, 18}},
+	3:  {70, 26, 1, []int{6, 22}},
+	4:  {100, 18, 2, []int{6, 26}},
+	5:  {134, 24, 2, []int{6, 30}},
+	6:  {172, 16, 4, []int{6, 34}},
+	7:  {196, 18, 4, []int{6, 22, 38}},
+	8:  {242, 22, 4, []int{6, 24, 42}},
+	9:  {292, 22, 5, []int{6, 26, 46}},
+	10: {346, 26, 5, []int{6, 28, 50}},
+}
+
+// Code is an encoded QR code, Modules[y][x] being true for the dark modules
+type Code struct {
+	Modules [][]bool
+}
+
+// Encode returns the smallest QR code holding text
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v < len(layouts); v++ {
+		if 4+countBits(v)+8*len(data) <= 8*layouts[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	g := newGrid(version)
+	g.place(interleave(layouts[version], codewords(version, data)))
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		g.applyMask(mask)
+		g.drawFormat(mask)
+		if penalty := g.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		g.applyMask(mask)
+	}
+	g.applyMask(best)
+	g.drawFormat(best)
+	return &Code{Modules: g.modules}, nil
+}
+
+// PNG renders the code with its quiet zone, scale pixels to a module
+func (c *Code) PNG(scale int) []byte {
+	size := (len(c.Modules) + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	for y, row := range c.Modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+quietZone)*scale+dx, (y+quietZone)*scale+dy, color.Gray{})
+				}
+			}
+		}
+	}
+	var out bytes.Buffer
+	png.Encode(&out, img)
+	return out.Bytes()
+}
+
+func (l blockLayout) dataCodewords() int {
+	return l.total - l.ecc*l.blocks
+}
+
+// countBits is the width of the character count in byte mode
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// codewords returns data in byte mode, terminated and padded to the version's data capacity
+func codewords(version int, data []byte) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * layouts[version].dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	out := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// interleave splits the data into blocks, adds each block's error correction and interleaves them
+func interleave(layout blockLayout, data []byte) []byte {
+	shortBlocks := layout.blocks - layout.total%layout.blocks
+	shortData := layout.total/layout.blocks - layout.ecc
+	divisor := rsDivisor(layout.ecc)
+
+	var dataBlocks, eccBlocks [][]byte
+	for i, start := 0, 0; i < layout.blocks; i++ {
+		length := shortData
+		if i >= shortBlocks {
+			length++
+		}
+		block := data[start : start+length]
+		start += length
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, rsRemainder(block, divisor))
+	}
+
+	var out []byte
+	for i := 0; i <= shortData; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecc; i++ {
+		for _, block := range eccBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the degree, highest power first
+// without its leading 1
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnalyticsVisit is the little the aggregation job reads about one attended appointment
+type AnalyticsVisit struct {
+	DateOfBirth      string
+	Insured          bool
+	InsuranceCompany string
+	County           string
+	City             string
+}
+
+// AnalyticsBooking is whether a booked appointment was kept, with where its patient lives
+type AnalyticsBooking struct {
+	Attendance string
+	Latitude   *float64
+	Longitude  *float64
+}
+
+// AnalyticsRepository reads the operational tables once a night and serves reports from the
+// anonymized analytics_aggregate table only.
+type AnalyticsRepository struct{}
+
+func NewAnalyticsRepository() *AnalyticsRepository {
+	return &AnalyticsRepository{}
+}
+
+// GetVisits returns the attended appointments on the clinic day day falls on
+func (r *AnalyticsRepository) GetVisits(ctx context.Context, day time.Time) ([]AnalyticsVisit, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var visits []AnalyticsVisit
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("p.date_of_birth, p.insured, p.insurance_company, p.address_county AS county, p.address_city AS city").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Where("a.starts_at >= ? AND a.starts_at < ? AND (a.status IN ? OR a.checked_in_at IS NOT NULL)", from, to,
+			[]string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}).
+		Scan(&visits).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visits: %w", err)
+	}
+	return visits, nil
+}
+
+// GetProcedureTotals returns the number and amount of procedures billed on the clinic day day falls on
+func (r *AnalyticsRepository) GetProcedureTotals(ctx context.Context, day time.Time) ([]models.AnalyticsBucket, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var buckets []models.AnalyticsBucket
+	err := database.DB.WithContext(ctx).Model(&models.Billing{}).
+		Select("procedure AS bucket, COUNT(*) AS count, COALESCE(SUM(billing_amount), 0) AS amount").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("procedure").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get procedure totals: %w", err)
+	}
+	return buckets, nil
+}
+
+// GetStatusCounts returns the number of appointments on the clinic day day falls on per status,
+// counting scheduled appointments nobody checked in for as no-shows
+func (r *AnalyticsRepository) GetStatusCounts(ctx context.Context, day time.Time) ([]models.AnalyticsBucket, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var buckets []models.AnalyticsBucket
+	err := database.DB.WithContext(ctx).Model(&models.Appointment{}).
+		Select(`CASE
+			WHEN status = ? THEN ?
+			WHEN status IN ? OR checked_in_at IS NOT NULL THEN ?
+			ELSE ? END AS bucket, COUNT(*) AS count`,
+			models.AppointmentStatusCancelled, models.AttendanceCancelled,
+			[]string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}, models.AttendanceAttended,
+			models.AttendanceNoShow).
+		Where("starts_at >= ? AND starts_at < ?", from, to).
+		Group("bucket").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment status counts: %w", err)
+	}
+	return buckets, nil
+}
+
+// GetBookings returns the appointments on the clinic day day falls on that were not cancelled,
+// with whether they were attended and where their patients live
+func (r *AnalyticsRepository) GetBookings(ctx context.Context, day time.Time) ([]AnalyticsBooking, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var bookings []AnalyticsBooking
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select(`CASE WHEN a.status IN ? OR a.checked_in_at IS NOT NULL THEN ? ELSE ? END AS attendance,
+			p.address_latitude AS latitude, p.address_longitude AS longitude`,
+			[]string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}, models.AttendanceAttended,
+			models.AttendanceNoShow).
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Where("a.starts_at >= ? AND a.starts_at < ? AND a.status <> ?", from, to, models.AppointmentStatusCancelled).
+		Scan(&bookings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+// ReplaceDay swaps the aggregates of day for a freshly computed set
+func (r *AnalyticsRepository) ReplaceDay(ctx context.Context, day time.Time, aggregates []models.AnalyticsAggregate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("analytics_lock:%s", day.Format("2006-01-02")), func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", day.Format("2006-01-02")).Delete(&models.AnalyticsAggregate{}).Error; err != nil {
+			return fmt.Errorf("failed to clear analytics for %s: %w", day.Format("2006-01-02"), err)
+		}
+		if len(aggregates) == 0 {
+			return nil
+		}
+		if err := tx.Create(&aggregates).Error; err != nil {
+			return fmt.Errorf("failed to store analytics for %s: %w", day.Format("2006-01-02"), err)
+		}
+		return nil
+	})
+}
+
+// GetTotals sums the aggregates of days in [from, to] per dimension and bucket
+func (r *AnalyticsRepository) GetTotals(ctx context.Context, from, to time.Time) ([]models.AnalyticsAggregate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var totals []models.AnalyticsAggregate
+	err := database.DB.WithContext(ctx).Model(&models.AnalyticsAggregate{}).
+		Select("dimension, bucket, SUM(count) AS count, SUM(amount) AS amount").
+		Where("day BETWEEN ? AND ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Group("dimension, bucket").
+		Order("dimension, count DESC, bucket").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analytics totals: %w", err)
+	}
+	return totals, nil
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIUsageRepository keeps each user's daily request counts by route
+type APIUsageRepository struct{}
+
+func NewAPIUsageRepository() *APIUsageRepository {
+	return &APIUsageRepository{}
+}
+
+// Add adds counts to those already stored
+func (r *APIUsageRepository) Add(ctx context.Context, usage []models.APIUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}, {Name: "method"}, {Name: "route"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"requests": gorm.Expr("api_usage.requests + excluded.requests"),
+			"errors":   gorm.Expr("api_usage.errors + excluded.errors"),
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to record API usage: %w", err)
+	}
+	return nil
+}
+
+// ForUser totals the user's requests by route over the days from through to (YYYY-MM-DD), most used first
+func (r *APIUsageRepository) ForUser(ctx context.Context, userID int64, from, to string) ([]models.APIUsageCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.APIUsageCount
+	err := database.DB.WithContext(ctx).Model(&models.APIUsage{}).
+		Select("method, route, SUM(requests) AS requests, SUM(errors) AS errors").
+		Where("user_id = ? AND day BETWEEN ? AND ?", userID, from, to).
+		Group("method, route").
+		Order("requests DESC, route, method").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API usage: %w", err)
+	}
+	return counts, nil
+}
+
+// AddBuckets adds hourly counts to those already stored
+func (r *APIUsageRepository) AddBuckets(ctx context.Context, buckets []models.APIUsageBucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "consumer"}, {Name: "hour"}, {Name: "method"}, {Name: "route"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"requests":       gorm.Expr("api_usage_buckets.requests + excluded.requests"),
+			"errors":         gorm.Expr("api_usage_buckets.errors + excluded.errors"),
+			"request_bytes":  gorm.Expr("api_usage_buckets.request_bytes + excluded.request_bytes"),
+			"response_bytes": gorm.Expr("api_usage_buckets.response_bytes + excluded.response_bytes"),
+		}),
+	}).Create(&buckets).Error
+	if err != nil {
+		return fmt.Errorf("failed to record API usage buckets: %w", err)
+	}
+	return nil
+}
+
+// Buckets totals the usage matching query by consumer and time bucket, and by route when asked,
+// oldest first
+func (r *APIUsageRepository) Buckets(ctx context.Context, query models.APIUsageQuery) ([]models.APIUsageBucketCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	// Days are cut at midnight clinic time
+	start := gorm.Expr("date_trunc(?, hour AT TIME ZONE ?) AT TIME ZONE ?", query.Bucket, models.ClinicLocation().String(), models.ClinicLocation().String())
+	columns, groups := "consumer", "start, consumer"
+	if query.ByRoute {
+		columns, groups = "consumer, method, route", "start, consumer, method, route"
+	}
+	var counts []models.APIUsageBucketCount
+	err := r.filter(database.DB.WithContext(ctx), query).
+		Select("? AS start, "+columns+", SUM(requests) AS requests, SUM(errors) AS errors, "+
+			"SUM(request_bytes) AS request_bytes, SUM(response_bytes) AS response_bytes", start).
+		Group(groups).
+		Order(groups).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API usage: %w", err)
+	}
+	return counts, nil
+}
+
+// HourlyTotals totals the requests matching query by consumer and hour
+func (r *APIUsageRepository) HourlyTotals(ctx context.Context, query models.APIUsageQuery) ([]models.APIUsageBucket, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var totals []models.APIUsageBucket
+	err := r.filter(database.DB.WithContext(ctx), query).
+		Select("consumer, hour, SUM(requests) AS requests").
+		Group("consumer, hour").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly API usage: %w", err)
+	}
+	return totals, nil
+}
+
+// filter narrows the buckets to the period, consumer and route of query
+func (r *APIUsageRepository) filter(db *gorm.DB, query models.APIUsageQuery) *gorm.DB {
+	db = db.Model(&models.APIUsageBucket{}).Where("hour >= ? AND hour < ?", query.From, query.To)
+	if query.Consumer != "" {
+		if strings.Contains(query.Consumer, ":") {
+			db = db.Where("consumer = ?", query.Consumer)
+		} else {
+			db = db.Where("consumer LIKE ?", query.Consumer+":%")
+		}
+	}
+	if query.Route != "" {
+		db = db.Where("route = ?", query.Route)
+	}
+	return db
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// AppointmentReminderRepository finds the upcoming appointments to remind patients of and records
+// the reminders sent
+type AppointmentReminderRepository struct{}
+
+func NewAppointmentReminderRepository() *AppointmentReminderRepository {
+	return &AppointmentReminderRepository{}
+}
+
+// Pending returns the reminders due by now of the scheduled appointments still to come, soonest
+// appointment first and, for each, longest lead first, with the contact details of the patient's
+// guarantor. Patients in a household also come with those of their guardian: the member named as
+// such, or the household's guarantor. Appointments follow the cadence of their type in cadences
+// unless they have their own.
+func (r *AppointmentReminderRepository) Pending(ctx context.Context, now time.Time, cadences map[string]models.ReminderLeads, limit int) ([]models.AppointmentReminderCandidate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	cadenceJSON, err := json.Marshal(cadences)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []models.AppointmentReminderCandidate
+	err = database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id AS appointment_id, l.lead::int AS lead_minutes, a.patient_id, a.starts_at, p.first_name AS patient_first_name, p.email AS patient_email, "+
+			"p.phone AS patient_phone, p.date_of_birth, d.first_name AS doctor_first_name, d.last_name AS doctor_last_name, "+
+			"COALESCE(p.guarantor_name, '') AS guarantor_name, COALESCE(p.guarantor_email, '') AS guarantor_email, COALESCE(p.guarantor_phone, '') AS guarantor_phone, "+
+			"COALESCE(g.id, '') AS guardian_id, COALESCE(g.first_name, '') AS guardian_first_name, "+
+			"COALESCE(g.email, '') AS guardian_email, COALESCE(g.phone, '') AS guardian_phone").
+		Joins("CROSS JOIN LATERAL jsonb_array_elements_text(COALESCE(a.reminder_leads, (?::jsonb) -> a.type)) AS l(lead)", string(cadenceJSON)).
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Joins("LEFT JOIN household_member m ON m.patient_id = a.patient_id").
+		Joins("LEFT JOIN household h ON h.id = m.household_id").
+		Joins("LEFT JOIN patient g ON g.id = COALESCE(m.guardian_id, h.guarantor_id) AND g.id <> a.patient_id").
+		Where("a.status = ? AND a.starts_at > ? AND a.starts_at - l.lead::int * interval '1 minute' <= ?", models.AppointmentStatusScheduled, now, now).
+		Where("NOT EXISTS (SELECT 1 FROM appointment_reminder ar WHERE ar.appointment_id = a.id AND ar.lead_minutes = l.lead::int)").
+		Order("a.starts_at, a.id, lead_minutes DESC").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointments to remind of: %w", err)
+	}
+	return candidates, nil
+}
+
+// Sent returns the reminders of an appointment recorded so far, longest lead first
+func (r *AppointmentReminderRepository) Sent(ctx context.Context, appointmentID uint) ([]models.AppointmentReminder, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var reminders []models.AppointmentReminder
+	if err := database.DB.WithContext(ctx).Where("appointment_id = ?", appointmentID).Order("lead_minutes DESC").Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("failed to get appointment reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// Record records that one of an appointment's reminders is being sent, or skipped, and reports
+// false when another replica already did
+func (r *AppointmentReminderRepository) Record(ctx context.Context, reminder *models.AppointmentReminder) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record appointment reminder: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Delete clears the record of a reminder that could not be delivered so it is retried
+func (r *AppointmentReminderRepository) Delete(ctx context.Context, appointmentID uint, leadMinutes int) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Delete(&models.AppointmentReminder{}, "appointment_id = ? AND lead_minutes = ?", appointmentID, leadMinutes).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete appointment reminder: %w", err)
+	}
+	return nil
+}
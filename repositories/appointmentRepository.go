@@ -3,23 +3,58 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-const (
-	AppointmentCacheExpiry = 7 * 24 * time.Hour
+// ErrAppointmentNotCheckable is returned when an appointment is not scheduled for today.
+var ErrAppointmentNotCheckable = errors.New("appointment cannot be checked in")
+
+// ErrAppointmentNotCheckedIn is returned when a patient is marked seen before checking in.
+var ErrAppointmentNotCheckedIn = errors.New("appointment is not checked in")
+
+var (
+	// ErrChairNotFound is returned when an appointment is assigned a chair that does not exist or is inactive.
+	ErrChairNotFound = errors.New("chair not found or inactive")
+	// ErrChairDoubleBooked is returned when the assigned chair is taken by another appointment at that time.
+	ErrChairDoubleBooked = errors.New("chair is already booked at that time")
+	// ErrChairDown is returned when the assigned chair is out of service at the appointment's time.
+	ErrChairDown = errors.New("chair is out of service at that time")
+	// ErrNoChairAvailable is returned when every chair is taken at the appointment's time.
+	ErrNoChairAvailable = errors.New("no chair is available at that time")
+	// ErrDoctorDoubleBooked is returned when the doctor has another appointment at that time that
+	// the overbooking policy does not allow booking over.
+	ErrDoctorDoubleBooked = errors.New("doctor is already booked at that time")
+	// ErrPatientDoubleBooked is returned when the patient has another appointment at that time and
+	// booking over it was not asked for.
+	ErrPatientDoubleBooked = errors.New("patient already has an appointment at that time")
 )
 
+// PatientDoubleBookedError is returned instead of booking a patient into two overlapping
+// appointments. It matches ErrPatientDoubleBooked and holds the appointments in the way.
+type PatientDoubleBookedError struct {
+	Conflicts []models.PatientConflict
+}
+
+func (e *PatientDoubleBookedError) Error() string {
+	return fmt.Sprintf("%v: %d overlapping appointment(s)", ErrPatientDoubleBooked, len(e.Conflicts))
+}
+
+func (e *PatientDoubleBookedError) Unwrap() error {
+	return ErrPatientDoubleBooked
+}
+
 type AppointmentRepository struct {
 	cache *cache.Cache
 }
@@ -28,71 +63,71 @@ func NewAppointmentRepository(cache *cache.Cache) *AppointmentRepository {
 	return &AppointmentRepository{cache: cache}
 }
 
-func (r *AppointmentRepository) Create(ctx context.Context, appointment *models.Appointment) error {
-	lockKey := fmt.Sprintf("appointment_lock:%s_%d", appointment.PatientID, appointment.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+// Create books an appointment, over another of its doctor's only as far as the overbooking policy allows
+// and over another of its patient's only when asked to, which is written to the audit trail from audit
+func (r *AppointmentRepository) Create(ctx context.Context, appointment *models.Appointment, policy models.OverbookingPolicy, audit models.AuditLog) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", appointment.PatientID, appointment.ID), func(tx *gorm.DB) error {
+		// Validate the Status and Type fields
+		if !models.IsValidAppointmentStatus(appointment.Status) {
+			return errors.New("invalid status value")
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+		if appointment.Type == "" {
+			appointment.Type = models.AppointmentTypeConsultation
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if !models.IsValidAppointmentType(appointment.Type) {
+			return errors.New("invalid type value")
+		}
+		if err := checkChairCapacity(tx, appointment); err != nil {
+			return err
+		}
+		if err := checkDoctorCapacity(tx, appointment, policy); err != nil {
+			return err
+		}
+		if err := checkPatientConflicts(tx, appointment, audit); err != nil {
+			return err
 		}
-	}()
-
-	// Validate the Status field
-	if appointment.Status != "scheduled" && appointment.Status != "fulfilled" && appointment.Status != "cancelled" {
-		return errors.New("invalid status value")
-	}
 
-	err = database.DB.Create(appointment).Error
+		appointment.EligibilityStatus = ""
+		err := tx.Create(appointment).Error
+		if err != nil {
+			return fmt.Errorf("failed to create appointment: %w", err)
+		}
+		if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, appointment.PatientID, appointment.ID)); err != nil {
+			return fmt.Errorf("failed to delete appointment cache: %w", err)
+		}
+		if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
+			return fmt.Errorf("failed to delete all appointments cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all appointments cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, appointment.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create appointment: %w", err)
-	}
-	if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(appointment.PatientID, appointment.ID)); err != nil {
-		return fmt.Errorf("failed to delete appointment cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "appointments_cache"); err != nil {
-		return fmt.Errorf("failed to delete all appointments cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all appointments cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(appointment.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+		return err
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	events.Publish(ctx, events.Event{Name: events.AppointmentBooked, PatientID: appointment.PatientID, Record: "appointment",
+		RecordID: strconv.FormatUint(uint64(appointment.ID), 10)})
+	return nil
 }
 
 func (r *AppointmentRepository) GetByID(ctx context.Context, patientID string, id uint) (*models.Appointment, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getAppointmentCacheKey(patientID, id)
-	cachedAppointment, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var appointment models.Appointment
-		if err := json.Unmarshal([]byte(cachedAppointment), &appointment); err == nil {
-			return &appointment, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getAppointmentCacheKey(ctx, patientID, id)
+	var appointment models.Appointment
+	if found, err := r.cache.GetObject(ctx, cacheKey, &appointment); err != nil {
 		log.Printf("Failed to get appointment from cache: %v", err)
+	} else if found {
+		return &appointment, nil
 	}
 
-	var appointment models.Appointment
-	err = database.DB.Select("id, patient_id, doctor_id, date_time, created_at, status").
+	err := database.DB.WithContext(ctx).Select("id, patient_id, doctor_id, date_time, created_at, status, origin, type, procedure_id, checked_in_at, seen_at, confirmed_at, chair_id, starts_at, ends_at, overbooked, emergency_reason, no_show_risk, no_show_scored_at, custom_fields, reminder_leads, source, campaign_code, referred_by, eligibility_status, updated_at, created_by, updated_by").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
@@ -107,11 +142,7 @@ func (r *AppointmentRepository) GetByID(ctx context.Context, patientID string, i
 		return nil, fmt.Errorf("failed to get appointment: %w", err)
 	}
 
-	appointmentJSON, err := json.Marshal(appointment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal appointment: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, appointmentJSON, AppointmentCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, appointment, cache.ItemTTL("appointment")); err != nil {
 		log.Printf("Failed to set appointment in cache: %v", err)
 	}
 
@@ -119,148 +150,845 @@ func (r *AppointmentRepository) GetByID(ctx context.Context, patientID string, i
 }
 
 func (r *AppointmentRepository) GetAll(ctx context.Context) ([]models.Appointment, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "appointments_cache"
-	cachedAppointments, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "appointments"), "appointment", func(ctx context.Context) ([]models.Appointment, error) {
 		var appointments []models.Appointment
-		if err := json.Unmarshal([]byte(cachedAppointments), &appointments); err == nil {
-			return appointments, nil
+		err := r.listQuery(database.DB.WithContext(ctx)).
+			Order("created_at DESC").
+			Find(&appointments).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all appointments: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get appointments from cache: %v", err)
+		return appointments, nil
+	})
+}
+
+// Stream hands every appointment to fn newest first without holding the whole list in memory
+func (r *AppointmentRepository) Stream(ctx context.Context, fn func(models.Appointment) error) error {
+	return streamNewestFirst(ctx, "id", r.listQuery, func(a *models.Appointment) (time.Time, interface{}) {
+		return a.CreatedAt, a.ID
+	}, fn)
+}
+
+// StreamFiltered hands the appointments matching query to fn in the order it asks for
+func (r *AppointmentRepository) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Appointment) error) error {
+	filter, err := listFilter(models.ListAppointments, query, time.Now())
+	if err != nil {
+		return err
+	}
+	return streamOrdered(ctx, func(db *gorm.DB) *gorm.DB { return filter(r.listQuery(db)) }, fn)
+}
+
+// ListAfter returns up to limit appointments newest first, starting after the cursor when one is given
+func (r *AppointmentRepository) ListAfter(ctx context.Context, after *models.ListCursor, limit int) ([]models.Appointment, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		id, err := strconv.ParseUint(after.Key, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidCursor
+		}
+		createdAt, key = after.CreatedAt, id
+	}
+	return listNewestFirst[models.Appointment](ctx, "id", r.listQuery, createdAt, key, limit)
+}
+
+// ListByDoctorAfter returns up to limit of the doctor's appointments newest first, starting after
+// the cursor when one is given
+func (r *AppointmentRepository) ListByDoctorAfter(ctx context.Context, doctorID string, after *models.ListCursor, limit int) ([]models.Appointment, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		id, err := strconv.ParseUint(after.Key, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidCursor
+		}
+		createdAt, key = after.CreatedAt, id
 	}
+	return cachedDoctorPage(ctx, r.cache, doctorAppointmentsCache, "appointment", doctorID, after, limit, func(ctx context.Context) ([]models.Appointment, error) {
+		return listNewestFirst[models.Appointment](ctx, "id", func(db *gorm.DB) *gorm.DB {
+			return r.listQuery(db).Where("doctor_id = ?", doctorID)
+		}, createdAt, key, limit)
+	})
+}
 
-	var appointments []models.Appointment
-	err = database.DB.Select("id, patient_id, doctor_id, date_time, created_at, status").
+// ListByPatientAfter returns up to limit of the patient's appointments newest first, starting after
+// the cursor when one is given
+func (r *AppointmentRepository) ListByPatientAfter(ctx context.Context, patientID string, after *models.ListCursor, limit int) ([]models.Appointment, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		id, err := strconv.ParseUint(after.Key, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidCursor
+		}
+		createdAt, key = after.CreatedAt, id
+	}
+	return listNewestFirst[models.Appointment](ctx, "id", func(db *gorm.DB) *gorm.DB {
+		return r.listQuery(db).Where("patient_id = ?", patientID)
+	}, createdAt, key, limit)
+}
+
+// listQuery selects the columns and relations returned in appointment lists
+func (r *AppointmentRepository) listQuery(db *gorm.DB) *gorm.DB {
+	return db.Select("id, patient_id, doctor_id, date_time, created_at, status, origin, type, procedure_id, checked_in_at, seen_at, confirmed_at, chair_id, starts_at, ends_at, overbooked, emergency_reason, no_show_risk, no_show_scored_at, custom_fields, reminder_leads, source, campaign_code, referred_by, eligibility_status, updated_at, created_by, updated_by").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
 		Preload("Doctor", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
-		}).
-		Order("created_at DESC").
-		Find(&appointments).Error
+		})
+}
+
+// Update changes an appointment, checking its chair, doctor and patient's other appointments again when it moves
+func (r *AppointmentRepository) Update(ctx context.Context, appointment *models.Appointment, policy models.OverbookingPolicy, audit models.AuditLog) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var cancelled, fulfilled bool
+	err := database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", appointment.PatientID, appointment.ID), func(tx *gorm.DB) error {
+		// Validate the Status field
+		if !models.IsValidAppointmentStatus(appointment.Status) {
+			return errors.New("invalid status value")
+		}
+
+		var current models.Appointment
+		if err := tx.Select("status, type, procedure_id, doctor_id, chair_id, starts_at, ends_at, overbooked, custom_fields, eligibility_status, source, campaign_code, referred_by").First(&current, "id = ? AND patient_id = ?", appointment.ID, appointment.PatientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAppointmentNotFound
+			}
+			return fmt.Errorf("failed to get appointment: %w", err)
+		}
+
+		// An appointment updated without a type, procedure, custom field values or source keeps the ones it has
+		if appointment.Type == "" {
+			appointment.Type = current.Type
+		}
+		if appointment.Attribution.Source == "" {
+			appointment.Attribution = current.Attribution
+		}
+		if appointment.ProcedureID == nil {
+			appointment.ProcedureID = current.ProcedureID
+		}
+		if appointment.CustomFields == nil {
+			appointment.CustomFields = current.CustomFields
+		}
+		appointment.EligibilityStatus = current.EligibilityStatus
+		if !models.IsValidAppointmentType(appointment.Type) {
+			return errors.New("invalid type value")
+		}
+
+		cancelled = appointment.Status == models.AppointmentStatusCancelled && current.Status != models.AppointmentStatusCancelled
+		fulfilled = appointment.Status == models.AppointmentStatusFulfilled && current.Status != models.AppointmentStatusFulfilled
+
+		// Treatment may only start once the patient has been prepared
+		if appointment.Status == models.AppointmentStatusInProgress && current.Status != models.AppointmentStatusInProgress {
+			if err := checkReadyToStart(tx, appointment); err != nil {
+				return err
+			}
+		}
+
+		// Only a new slot, chair or doctor, or a cancelled appointment coming back, needs the
+		// chair and doctor checked; otherwise the appointment stays overbooked or not as it was
+		moved := current.Status == models.AppointmentStatusCancelled || !sameTime(current.StartsAt, appointment.StartsAt) || !sameTime(current.EndsAt, appointment.EndsAt)
+		if moved || !sameChair(current.ChairID, appointment.ChairID) {
+			if err := checkChairCapacity(tx, appointment); err != nil {
+				return err
+			}
+		}
+		if moved || current.DoctorID != appointment.DoctorID {
+			if err := checkDoctorCapacity(tx, appointment, policy); err != nil {
+				return err
+			}
+		} else {
+			appointment.Overbooked = current.Overbooked
+		}
+		if moved {
+			if err := checkPatientConflicts(tx, appointment, audit); err != nil {
+				return err
+			}
+		}
+
+		// created_at is the partition key and must never be overwritten by an update;
+		// origin and the reason for an emergency are fixed at creation, queue timestamps are only
+		// set through CheckIn and MarkSeen, confirmations only through Confirm, reminder cadences only
+		// through SetReminderLeads, no-show risks only by the scorer and eligibility only by its checks
+		err := tx.Omit("created_at", "created_by", "origin", "emergency_reason", "checked_in_at", "seen_at", "confirmed_at", "reminder_leads", "no_show_risk", "no_show_scored_at", "eligibility_status").Save(appointment).Error
+		if err != nil {
+			return fmt.Errorf("failed to update appointment: %w", err)
+		}
+		if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, appointment.PatientID, appointment.ID)); err != nil {
+			return fmt.Errorf("failed to delete appointment cache: %w", err)
+		}
+		if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
+			return fmt.Errorf("failed to delete all appointments cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all appointments cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, appointment.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all appointments: %w", err)
+		return err
+	}
+	if cancelled {
+		events.Publish(ctx, events.Event{Name: events.AppointmentCancelled, PatientID: appointment.PatientID, Record: "appointment",
+			RecordID: strconv.FormatUint(uint64(appointment.ID), 10)})
 	}
+	if fulfilled {
+		events.Publish(ctx, events.Event{Name: events.AppointmentFulfilled, PatientID: appointment.PatientID, Record: "appointment",
+			RecordID: strconv.FormatUint(uint64(appointment.ID), 10)})
+	}
+	return nil
+}
+
+func (r *AppointmentRepository) Delete(ctx context.Context, patientID string, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.Appointment{}, "id = ? AND patient_id = ?", id, patientID).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete appointment: %w", err)
+		}
+		if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, patientID, id)); err != nil {
+			return fmt.Errorf("failed to delete appointment cache: %w", err)
+		}
+		if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
+			return fmt.Errorf("failed to delete all appointments cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all appointments cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
+
+// CheckIn moves a scheduled appointment of the clinic day day falls on to checked_in and starts its
+// waiting time.
+func (r *AppointmentRepository) CheckIn(ctx context.Context, patientID string, id uint, day time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND patient_id = ? AND status = ? AND starts_at >= ? AND starts_at < ?", id, patientID, models.AppointmentStatusScheduled, from, to).
+			Updates(map[string]interface{}{"status": models.AppointmentStatusCheckedIn, "checked_in_at": time.Now()})
+		if result.Error != nil {
+			return fmt.Errorf("failed to check in appointment: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppointmentNotCheckable
+		}
+		return r.invalidate(ctx, patientID, id)
+	})
+}
+
+// MarkSeen records when the doctor saw a checked-in patient, ending their waiting time.
+func (r *AppointmentRepository) MarkSeen(ctx context.Context, patientID string, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND patient_id = ? AND checked_in_at IS NOT NULL AND seen_at IS NULL", id, patientID).
+			Update("seen_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark appointment seen: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppointmentNotCheckedIn
+		}
+		return r.invalidate(ctx, patientID, id)
+	})
+}
+
+// Confirm records that the patient confirmed a scheduled appointment, reporting false when it is no
+// longer scheduled
+func (r *AppointmentRepository) Confirm(ctx context.Context, patientID string, id uint, at time.Time) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var confirmed bool
+	err := database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND patient_id = ? AND status = ?", id, patientID, models.AppointmentStatusScheduled).
+			Update("confirmed_at", at)
+		if result.Error != nil {
+			return fmt.Errorf("failed to confirm appointment: %w", result.Error)
+		}
+		if confirmed = result.RowsAffected > 0; !confirmed {
+			return nil
+		}
+		return r.invalidate(ctx, patientID, id)
+	})
+	return confirmed, err
+}
+
+// CancelScheduled cancels a scheduled appointment at the patient's request, reporting false when it
+// is no longer scheduled. The cancellation is published as if staff had cancelled it.
+func (r *AppointmentRepository) CancelScheduled(ctx context.Context, patientID string, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var cancelled bool
+	err := database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND patient_id = ? AND status = ?", id, patientID, models.AppointmentStatusScheduled).
+			Update("status", models.AppointmentStatusCancelled)
+		if result.Error != nil {
+			return fmt.Errorf("failed to cancel appointment: %w", result.Error)
+		}
+		if cancelled = result.RowsAffected > 0; !cancelled {
+			return nil
+		}
+		if err := r.invalidate(ctx, patientID, id); err != nil {
+			return err
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+	if err != nil || !cancelled {
+		return false, err
+	}
+	events.Publish(ctx, events.Event{Name: events.AppointmentCancelled, PatientID: patientID, Record: "appointment",
+		RecordID: strconv.FormatUint(uint64(id), 10)})
+	return true, nil
+}
+
+// SetReminderLeads gives an appointment its own reminder cadence, or with nil leads has it follow
+// the cadence of its type again
+func (r *AppointmentRepository) SetReminderLeads(ctx context.Context, patientID string, id uint, leads models.ReminderLeads) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).Where("id = ? AND patient_id = ?", id, patientID).Update("reminder_leads", leads)
+		if result.Error != nil {
+			return fmt.Errorf("failed to set appointment reminder cadence: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppointmentNotFound
+		}
+		return r.invalidate(ctx, patientID, id)
+	})
+}
+
+// Attribute records where an appointment, and its patient, came from when they were saved without a
+// source, such as a booking made for an appointment requested on the website
+func (r *AppointmentRepository) Attribute(ctx context.Context, patientID string, id uint, attribution models.Attribution) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	columns := map[string]interface{}{"source": attribution.Source, "campaign_code": attribution.CampaignCode, "referred_by": attribution.ReferredBy}
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).Where("id = ? AND patient_id = ? AND source = ''", id, patientID).Updates(columns).Error; err != nil {
+			return fmt.Errorf("failed to attribute appointment: %w", err)
+		}
+		if err := tx.Model(&models.Patient{}).Where("id = ? AND source = ''", patientID).Updates(columns).Error; err != nil {
+			return fmt.Errorf("failed to attribute patient: %w", err)
+		}
+		if err := r.invalidate(ctx, patientID, id); err != nil {
+			return err
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
+
+// Start moves a checked-in appointment to in_progress once its vitals and checklists are complete,
+// marking the patient seen if that was not done already.
+func (r *AppointmentRepository) Start(ctx context.Context, patientID string, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		var appointment models.Appointment
+		if err := tx.Select("id, patient_id, doctor_id, status").First(&appointment, "id = ? AND patient_id = ?", id, patientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAppointmentNotFound
+			}
+			return fmt.Errorf("failed to get appointment: %w", err)
+		}
+		if appointment.Status != models.AppointmentStatusCheckedIn {
+			return ErrAppointmentNotCheckedIn
+		}
+		if err := checkReadyToStart(tx, &appointment); err != nil {
+			return err
+		}
 
-	appointmentsJSON, err := json.Marshal(appointments)
+		err := tx.Model(&models.Appointment{}).
+			Where("id = ? AND patient_id = ?", id, patientID).
+			Updates(map[string]interface{}{"status": models.AppointmentStatusInProgress, "seen_at": gorm.Expr("COALESCE(seen_at, ?)", time.Now())}).Error
+		if err != nil {
+			return fmt.Errorf("failed to start appointment: %w", err)
+		}
+		return r.invalidate(ctx, patientID, id)
+	})
+}
+
+// checkReadyToStart returns ErrVisitNotReady with what is outstanding unless treatment can start
+func checkReadyToStart(tx *gorm.DB, appointment *models.Appointment) error {
+	outstanding, err := outstandingBeforeStart(tx, appointment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal appointments: %w", err)
+		return err
 	}
-	if err := r.cache.Set(ctx, cacheKey, appointmentsJSON, AppointmentCacheExpiry); err != nil {
-		log.Printf("Failed to set appointments in cache: %v", err)
+	if len(outstanding) > 0 {
+		return fmt.Errorf("%w: %s", ErrVisitNotReady, strings.Join(outstanding, "; "))
 	}
-
-	return appointments, nil
+	return nil
 }
 
-func (r *AppointmentRepository) Update(ctx context.Context, appointment *models.Appointment) error {
-	lockKey := fmt.Sprintf("appointment_lock:%s_%d", appointment.PatientID, appointment.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+// checkChairCapacity makes sure the appointment's chair, and a chair at all, is free and in service
+// for its slot. The active chairs are locked so concurrent bookings are checked one after the other;
+// while no chairs are set up, capacity is not limited.
+func checkChairCapacity(tx *gorm.DB, appointment *models.Appointment) error {
+	if appointment.StartsAt == nil || appointment.EndsAt == nil || appointment.Status == models.AppointmentStatusCancelled {
+		return nil
+	}
+
+	var chairIDs []uint
+	if err := tx.Model(&models.Chair{}).Where("active").Order("id").Clauses(clause.Locking{Strength: "UPDATE"}).Pluck("id", &chairIDs).Error; err != nil {
+		return fmt.Errorf("failed to lock chairs: %w", err)
+	}
+	if appointment.ChairID != nil {
+		found := false
+		for _, id := range chairIDs {
+			found = found || id == *appointment.ChairID
+		}
+		if !found {
+			return ErrChairNotFound
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	}
+	if len(chairIDs) == 0 {
+		return nil
+	}
+
+	// Chairs out of service take no bookings, and the appointments left on them take up no other chair
+	down, err := downChairs(tx, *appointment.StartsAt, *appointment.EndsAt)
+	if err != nil {
+		return err
+	}
+	isDown := map[uint]bool{}
+	for _, id := range down {
+		isDown[id] = true
+	}
+	if appointment.ChairID != nil && isDown[*appointment.ChairID] {
+		return ErrChairDown
+	}
+	serviceable := 0
+	for _, id := range chairIDs {
+		if !isDown[id] {
+			serviceable++
 		}
 	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+
+	overlapping := func() *gorm.DB {
+		query := tx.Model(&models.Appointment{}).
+			Where("id <> ? AND status <> ? AND starts_at < ? AND ends_at > ?", appointment.ID, models.AppointmentStatusCancelled, *appointment.EndsAt, *appointment.StartsAt)
+		if len(down) > 0 {
+			query = query.Where("chair_id IS NULL OR chair_id NOT IN ?", down)
+		}
+		return query
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+	if appointment.ChairID != nil {
+		var taken int64
+		if err := overlapping().Where("chair_id = ?", *appointment.ChairID).Count(&taken).Error; err != nil {
+			return fmt.Errorf("failed to check chair bookings: %w", err)
+		}
+		if taken > 0 {
+			return ErrChairDoubleBooked
 		}
-	}()
+	}
+	var busy int64
+	if err := overlapping().Count(&busy).Error; err != nil {
+		return fmt.Errorf("failed to check chair capacity: %w", err)
+	}
+	if busy >= int64(serviceable) {
+		return ErrNoChairAvailable
+	}
+	return nil
+}
+
+// checkDoctorCapacity refuses an appointment overlapping another of its doctor's, unless the
+// overbooking policy permits booking it over that one, in which case it is marked overbooked.
+func checkDoctorCapacity(tx *gorm.DB, appointment *models.Appointment, policy models.OverbookingPolicy) error {
+	appointment.Overbooked = false
+	if appointment.StartsAt == nil || appointment.EndsAt == nil || appointment.Status == models.AppointmentStatusCancelled {
+		return nil
+	}
 
-	// Validate the Status field
-	if appointment.Status != "scheduled" && appointment.Status != "fulfilled" && appointment.Status != "cancelled" {
-		return errors.New("invalid status value")
+	// Bookings for the same doctor wait for each other, so two cannot take the same place over an appointment
+	var doctorIDs []string
+	if err := tx.Model(&models.Doctor{}).Where("id = ?", appointment.DoctorID).Clauses(clause.Locking{Strength: "UPDATE"}).Pluck("id", &doctorIDs).Error; err != nil {
+		return fmt.Errorf("failed to lock doctor: %w", err)
 	}
 
-	err = database.DB.Save(appointment).Error
+	doctorBookings := func() *gorm.DB {
+		return tx.Model(&models.Appointment{}).
+			Where("id <> ? AND doctor_id = ? AND status <> ?", appointment.ID, appointment.DoctorID, models.AppointmentStatusCancelled)
+	}
+	var overlapping int64
+	if err := doctorBookings().Where("starts_at < ? AND ends_at > ?", *appointment.EndsAt, *appointment.StartsAt).Count(&overlapping).Error; err != nil {
+		return fmt.Errorf("failed to check doctor bookings: %w", err)
+	}
+	if overlapping == 0 {
+		return nil
+	}
+
+	hour := models.ClinicHour(*appointment.StartsAt)
+	var overbooked int64
+	if err := doctorBookings().Where("overbooked AND starts_at >= ? AND starts_at < ?", hour, hour.Add(time.Hour)).Count(&overbooked).Error; err != nil {
+		return fmt.Errorf("failed to check overbooked appointments: %w", err)
+	}
+	if !policy.Permits(appointment.EndsAt.Sub(*appointment.StartsAt), int(overlapping), int(overbooked)) {
+		return ErrDoctorDoubleBooked
+	}
+	appointment.Overbooked = true
+	return nil
+}
+
+// checkPatientConflicts refuses an appointment overlapping another of its patient's, with any
+// doctor, unless the booking overrides the conflict; the override is then written to the audit
+// trail with the appointments it was booked over.
+func checkPatientConflicts(tx *gorm.DB, appointment *models.Appointment, audit models.AuditLog) error {
+	appointment.PatientConflicts = nil
+	if appointment.StartsAt == nil || appointment.EndsAt == nil || appointment.Status == models.AppointmentStatusCancelled {
+		return nil
+	}
+
+	// Bookings for the same patient wait for each other, so both cannot miss the other
+	var patientIDs []string
+	if err := tx.Model(&models.Patient{}).Where("id = ?", appointment.PatientID).Clauses(clause.Locking{Strength: "UPDATE"}).Pluck("id", &patientIDs).Error; err != nil {
+		return fmt.Errorf("failed to lock patient: %w", err)
+	}
+
+	var conflicts []models.PatientConflict
+	err := tx.Table("appointment a").
+		Select("a.id AS appointment_id, a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, a.starts_at, a.ends_at, a.status").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.id <> ? AND a.patient_id = ? AND a.status <> ?", appointment.ID, appointment.PatientID, models.AppointmentStatusCancelled).
+		Where("a.starts_at < ? AND a.ends_at > ?", *appointment.EndsAt, *appointment.StartsAt).
+		Order("a.starts_at").
+		Scan(&conflicts).Error
 	if err != nil {
-		return fmt.Errorf("failed to update appointment: %w", err)
+		return fmt.Errorf("failed to check patient appointments: %w", err)
 	}
-	if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(appointment.PatientID, appointment.ID)); err != nil {
-		return fmt.Errorf("failed to delete appointment cache: %w", err)
+	if len(conflicts) == 0 {
+		return nil
 	}
-	if err := r.cache.DeleteAll(ctx, "appointments_cache"); err != nil {
-		return fmt.Errorf("failed to delete all appointments cache: %w", err)
+	for i := range conflicts {
+		conflicts[i].DateTime = models.ClinicDateTime(conflicts[i].DateTime)
 	}
-	// Invalidate the specific patient cache and all appointments cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(appointment.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	if !appointment.OverridePatientConflict {
+		return &PatientDoubleBookedError{Conflicts: conflicts}
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+
+	detail, err := json.Marshal(map[string]interface{}{
+		"patient_id": appointment.PatientID,
+		"doctor_id":  appointment.DoctorID,
+		"date_time":  models.ClinicDateTime(appointment.DateTime),
+		"conflicts":  conflicts,
+	})
+	if err != nil {
+		return err
+	}
+	audit.Detail = string(detail)
+	if err := tx.Create(&audit).Error; err != nil {
+		return fmt.Errorf("failed to record the patient conflict override: %w", err)
+	}
+	appointment.PatientConflicts = conflicts
+	return nil
 }
 
-func (r *AppointmentRepository) Delete(ctx context.Context, patientID string, id uint) error {
-	lockKey := fmt.Sprintf("appointment_lock:%s_%d", patientID, id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+func sameChair(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameTime(a, b *time.Time) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+}
+
+// Bookings returns the non-cancelled appointments overlapping from to to
+func (r *AppointmentRepository) Bookings(ctx context.Context, from, to time.Time) ([]models.Booking, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var bookings []models.Booking
+	err := database.DB.WithContext(ctx).Model(&models.Appointment{}).
+		Select("id AS appointment_id, doctor_id, chair_id, starts_at, ends_at, overbooked").
+		Where("status <> ? AND starts_at < ? AND ends_at > ?", models.AppointmentStatusCancelled, to, from).
+		Order("starts_at").
+		Scan(&bookings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+// GetQueue returns the appointments of the clinic day day falls on, checked-in patients first in
+// arrival order.
+func (r *AppointmentRepository) GetQueue(ctx context.Context, day time.Time) ([]models.QueueEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var entries []models.QueueEntry
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id AS appointment_id, a.patient_id, p.first_name || ' ' || p.last_name AS patient_name, a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, a.status, a.origin, a.checked_in_at, a.seen_at, a.no_show_risk, a.eligibility_status").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.starts_at >= ? AND a.starts_at < ? AND a.status <> ?", from, to, models.AppointmentStatusCancelled).
+		Order("a.checked_in_at ASC NULLS LAST, a.starts_at ASC").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue: %w", err)
+	}
+	for i := range entries {
+		entries[i].DateTime = models.ClinicDateTime(entries[i].DateTime)
+	}
+	return entries, nil
+}
+
+// AverageWait returns the mean time between check-in and being seen for the appointments of the
+// clinic day day falls on.
+func (r *AppointmentRepository) AverageWait(ctx context.Context, day time.Time) (time.Duration, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var seconds *float64
+	err := database.DB.WithContext(ctx).Model(&models.Appointment{}).
+		Select("AVG(EXTRACT(EPOCH FROM seen_at - checked_in_at))").
+		Where("starts_at >= ? AND starts_at < ? AND checked_in_at IS NOT NULL AND seen_at IS NOT NULL", from, to).
+		Scan(&seconds).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute average wait: %w", err)
+	}
+	if seconds == nil {
+		return 0, nil
+	}
+	return time.Duration(*seconds * float64(time.Second)), nil
+}
+
+// OverbookingByDoctor counts per doctor the overbooked appointments starting from from until before
+// to, and those that collided: both the overbooked patient and the patient of an appointment it
+// overlapped turned up.
+func (r *AppointmentRepository) OverbookingByDoctor(ctx context.Context, from, to time.Time) ([]models.DoctorOverbookingReport, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	attended := []string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}
+	var doctors []models.DoctorOverbookingReport
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select(`a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, COUNT(*) AS overbooked,
+			COUNT(*) FILTER (WHERE (a.status IN ? OR a.checked_in_at IS NOT NULL) AND EXISTS (
+				SELECT 1 FROM appointment o
+				WHERE o.doctor_id = a.doctor_id AND o.id <> a.id AND o.starts_at < a.ends_at AND o.ends_at > a.starts_at
+					AND (o.status IN ? OR o.checked_in_at IS NOT NULL))) AS collided`, attended, attended).
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.overbooked AND a.status <> ? AND a.starts_at >= ? AND a.starts_at < ?", models.AppointmentStatusCancelled, from, to).
+		Group("a.doctor_id, d.first_name, d.last_name").
+		Order("overbooked DESC, a.doctor_id").
+		Scan(&doctors).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overbooking report: %w", err)
+	}
+	return doctors, nil
+}
+
+// NoShowCandidate is an upcoming appointment to be scored, with the risk it was last given
+type NoShowCandidate struct {
+	models.NoShowFeatures
+	NoShowRisk *float64
+}
+
+// NoShowScore is the risk a scorer gave an appointment
+type NoShowScore struct {
+	AppointmentID uint
+	PatientID     string
+	Risk          float64
+}
+
+// NoShowCandidates returns the scheduled appointments starting from from until before to, with
+// how many of their patients' appointments since historySince were kept and missed. Missed
+// appointments are past ones neither cancelled nor checked in.
+func (r *AppointmentRepository) NoShowCandidates(ctx context.Context, from, to, historySince time.Time) ([]NoShowCandidate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var candidates []NoShowCandidate
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select(`a.id AS appointment_id, a.patient_id, a.type, a.starts_at, a.created_at AS booked_at, a.no_show_risk,
+			COUNT(h.id) AS past_appointments,
+			COUNT(h.id) FILTER (WHERE h.status NOT IN ? AND h.checked_in_at IS NULL) AS past_no_shows`,
+			[]string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}).
+		Joins("LEFT JOIN appointment h ON h.patient_id = a.patient_id AND h.starts_at >= ? AND h.starts_at < ? AND h.status <> ?",
+			historySince, from, models.AppointmentStatusCancelled).
+		Where("a.status = ? AND a.starts_at >= ? AND a.starts_at < ?", models.AppointmentStatusScheduled, from, to).
+		Group("a.id, a.patient_id, a.type, a.starts_at, a.created_at, a.no_show_risk").
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointments to score: %w", err)
+	}
+	return candidates, nil
+}
+
+// SaveNoShowRisks stores the risks scorers gave appointments. The appointments are not marked
+// updated, as nobody changed them.
+func (r *AppointmentRepository) SaveNoShowRisks(ctx context.Context, scores []NoShowScore) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, score := range scores {
+			err := tx.Model(&models.Appointment{}).
+				Where("id = ? AND patient_id = ?", score.AppointmentID, score.PatientID).
+				UpdateColumns(map[string]interface{}{"no_show_risk": score.Risk, "no_show_scored_at": now}).Error
+			if err != nil {
+				return fmt.Errorf("failed to save no-show risk of appointment %d: %w", score.AppointmentID, err)
+			}
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	patients := map[string]bool{}
+	for _, score := range scores {
+		if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, score.PatientID, score.AppointmentID)); err != nil {
+			return fmt.Errorf("failed to delete appointment cache: %w", err)
+		}
+		if !patients[score.PatientID] {
+			patients[score.PatientID] = true
+			if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, score.PatientID)); err != nil {
+				return fmt.Errorf("failed to delete patient cache: %w", err)
+			}
 		}
 	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+	if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
+		return fmt.Errorf("failed to delete all appointments cache: %w", err)
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	return nil
+}
+
+// NoShowRisks returns the scheduled appointments starting from from until before to whose no-show
+// risk is at least minRisk, riskiest first
+func (r *AppointmentRepository) NoShowRisks(ctx context.Context, from, to time.Time, minRisk float64) ([]models.NoShowRisk, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	err = database.DB.Delete(&models.Appointment{}, "id = ? AND patient_id = ?", id, patientID).Error
+	var risks []models.NoShowRisk
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select(`a.id AS appointment_id, a.patient_id, p.first_name || ' ' || p.last_name AS patient_name, p.phone AS patient_phone,
+			a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, a.type, a.no_show_risk, a.no_show_scored_at AS scored_at`).
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.status = ? AND a.starts_at >= ? AND a.starts_at < ? AND a.no_show_risk >= ?", models.AppointmentStatusScheduled, from, to, minRisk).
+		Order("a.no_show_risk DESC, a.starts_at ASC").
+		Scan(&risks).Error
 	if err != nil {
-		return fmt.Errorf("failed to delete appointment: %w", err)
+		return nil, fmt.Errorf("failed to get no-show risks: %w", err)
 	}
-	if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(patientID, id)); err != nil {
+	for i := range risks {
+		risks[i].DateTime = models.ClinicDateTime(risks[i].DateTime)
+	}
+	return risks, nil
+}
+
+func (r *AppointmentRepository) invalidate(ctx context.Context, patientID string, id uint) error {
+	if err := r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, patientID, id)); err != nil {
 		return fmt.Errorf("failed to delete appointment cache: %w", err)
 	}
-	if err := r.cache.DeleteAll(ctx, "appointments_cache"); err != nil {
+	if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
 		return fmt.Errorf("failed to delete all appointments cache: %w", err)
 	}
-	// Invalidate the specific patient cache and all appointments cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(patientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	return r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patientID))
+}
+
+// Invalidate drops the cached appointment, appointment lists and patient after the appointment was
+// changed elsewhere, such as by its eligibility check
+func (r *AppointmentRepository) Invalidate(ctx context.Context, patientID string, id uint) error {
+	return r.invalidate(ctx, patientID, id)
+}
+
+// InvalidatePatientCache drops the cached appointments of a deleted patient on PatientDeleted
+func (r *AppointmentRepository) InvalidatePatientCache(ctx context.Context, event events.Event) error {
+	for _, id := range relatedIDs(event, "appointment") {
+		if err := r.DeleteCache(ctx, event.PatientID, id); err != nil {
+			return err
+		}
+	}
+	return r.DeleteAllCache(ctx)
+}
+
+// ListPatientVisits returns the patient's visits from now on and their latest pastLimit before now,
+// with only what the patient is shown. They are cached briefly rather than dropped on every write,
+// so a change can take up to the patient_visits TTL to show.
+func (r *AppointmentRepository) ListPatientVisits(ctx context.Context, patientID string, now time.Time, pastLimit int) (*models.PatientVisits, error) {
+	cacheKey := r.cache.Key(ctx, "patient_visits", patientID)
+	var visits models.PatientVisits
+	if found, err := r.cache.GetObject(ctx, cacheKey, &visits); err != nil {
+		log.Printf("Failed to get patient visits from cache: %v", err)
+	} else if found {
+		return &visits, nil
+	}
+
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := func() *gorm.DB {
+		return database.DB.WithContext(ctx).Table("appointment AS a").
+			Select("a.id, a.date_time, a.ends_at, a.status, a.type, a.confirmed_at, d.first_name || ' ' || d.last_name AS doctor_name, COALESCE(rm.name || ', ' || c.name, '') AS location").
+			Joins("JOIN doctor d ON d.id = a.doctor_id").
+			Joins("LEFT JOIN chair c ON c.id = a.chair_id").
+			Joins("LEFT JOIN room rm ON rm.id = c.room_id").
+			Where("a.patient_id = ?", patientID)
+	}
+	if err := query().Where("a.starts_at >= ?", now).Order("a.starts_at").Scan(&visits.Upcoming).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upcoming patient visits: %w", err)
+	}
+	if err := query().Where("a.starts_at < ?", now).Order("a.starts_at DESC").Limit(pastLimit).Scan(&visits.Past).Error; err != nil {
+		return nil, fmt.Errorf("failed to get past patient visits: %w", err)
+	}
+	if visits.Upcoming == nil {
+		visits.Upcoming = []models.PatientVisit{}
+	}
+	if visits.Past == nil {
+		visits.Past = []models.PatientVisit{}
+	}
+	for _, list := range [][]models.PatientVisit{visits.Upcoming, visits.Past} {
+		for i := range list {
+			list[i].DateTime = models.ClinicDateTime(list[i].DateTime)
+		}
+	}
+
+	if err := r.cache.SetObject(ctx, cacheKey, visits, cache.ListTTL("patient_visits")); err != nil {
+		log.Printf("Failed to set patient visits in cache: %v", err)
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return &visits, nil
 }
 
 func (r *AppointmentRepository) DeleteCache(ctx context.Context, patientID string, id uint) error {
-	return r.cache.Delete(ctx, r.getAppointmentCacheKey(patientID, id))
+	return r.cache.Delete(ctx, r.getAppointmentCacheKey(ctx, patientID, id))
 }
 
 func (r *AppointmentRepository) DeleteAllCache(ctx context.Context) error {
-	return r.cache.DeleteAll(ctx, "appointments_cache")
+	return deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache)
 }
 
-func (r *AppointmentRepository) getAppointmentCacheKey(patientID string, id uint) string {
-	return fmt.Sprintf("appointment_cache:%s_%d", patientID, id)
+func (r *AppointmentRepository) getAppointmentCacheKey(ctx context.Context, patientID string, id uint) string {
+	return r.cache.Key(ctx, "appointment", patientID, id)
 }
 
-func (r *AppointmentRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+func (r *AppointmentRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
 }
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrApprovalNotPending is returned when deciding an approval someone already decided or withdrew
+var ErrApprovalNotPending = errors.New("the approval is no longer pending")
+
+// ApprovalRepository stores the actions waiting for approval and how they were decided
+type ApprovalRepository struct{}
+
+func NewApprovalRepository() *ApprovalRepository {
+	return &ApprovalRepository{}
+}
+
+func (r *ApprovalRepository) Create(ctx context.Context, approval *models.Approval) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(approval).Error; err != nil {
+		return fmt.Errorf("failed to create approval: %w", err)
+	}
+	return nil
+}
+
+func (r *ApprovalRepository) Get(ctx context.Context, id uint) (*models.Approval, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var approval models.Approval
+	if err := database.DB.WithContext(ctx).First(&approval, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get approval: %w", err)
+	}
+	return &approval, nil
+}
+
+// Pending returns the approval still waiting for the action on a record, if any
+func (r *ApprovalRepository) Pending(ctx context.Context, action, recordID string) (*models.Approval, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var approval models.Approval
+	err := database.DB.WithContext(ctx).
+		Where("action = ? AND record_id = ? AND status = ?", action, recordID, models.ApprovalStatusPending).
+		Order("created_at DESC").First(&approval).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pending approval: %w", err)
+	}
+	return &approval, nil
+}
+
+// List returns the approvals matching filter, newest first
+func (r *ApprovalRepository) List(ctx context.Context, filter models.ApprovalFilter) ([]models.Approval, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.Approval{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.CreatedBy != nil {
+		query = query.Where("created_by = ?", *filter.CreatedBy)
+	}
+	var approvals []models.Approval
+	if err := query.Order("created_at DESC, id DESC").Find(&approvals).Error; err != nil {
+		return nil, fmt.Errorf("failed to list approvals: %w", err)
+	}
+	return approvals, nil
+}
+
+// Decide records how a pending approval was decided. Two admins deciding at once cannot both
+// carry the action out: the second gets ErrApprovalNotPending.
+func (r *ApprovalRepository) Decide(ctx context.Context, approval *models.Approval) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.Approval{}).
+		Where("id = ? AND status = ?", approval.ID, models.ApprovalStatusPending).
+		Updates(map[string]interface{}{
+			"status":        approval.Status,
+			"decided_by":    approval.DecidedBy,
+			"decided_at":    approval.DecidedAt,
+			"decision_note": approval.DecisionNote,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to decide approval: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrApprovalNotPending
+	}
+	return nil
+}
+
+// Fail marks an approved action that could not be carried out
+func (r *ApprovalRepository) Fail(ctx context.Context, approval *models.Approval) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(approval).Updates(map[string]interface{}{
+		"status": models.ApprovalStatusFailed,
+		"error":  approval.Error,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record approval failure: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// attachmentColumns are the attachment columns returned with examinations; the file itself is
+// only read when downloaded
+const attachmentColumns = "id, examination_id, file_name, content_type, size, storage_key, annotations, created_at, updated_at, created_by, updated_by"
+
+// AttachmentRepository stores the files filed with examinations. Attachments are cached as part of
+// their examination, so every change drops the examination's cache entries.
+type AttachmentRepository struct {
+	cache *cache.Cache
+}
+
+func NewAttachmentRepository(cache *cache.Cache) *AttachmentRepository {
+	return &AttachmentRepository{cache: cache}
+}
+
+func (r *AttachmentRepository) Create(ctx context.Context, patientID string, attachment *models.ExaminationAttachment) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(attachment).Error; err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return r.invalidateExamination(ctx, patientID, attachment.ExaminationID)
+}
+
+// GetByID returns the attachment without its content
+func (r *AttachmentRepository) GetByID(ctx context.Context, examinationID, id uint) (*models.ExaminationAttachment, error) {
+	return r.get(ctx, attachmentColumns, examinationID, id)
+}
+
+// GetWithContent returns the attachment with the file itself
+func (r *AttachmentRepository) GetWithContent(ctx context.Context, examinationID, id uint) (*models.ExaminationAttachment, error) {
+	return r.get(ctx, "*", examinationID, id)
+}
+
+func (r *AttachmentRepository) get(ctx context.Context, columns string, examinationID, id uint) (*models.ExaminationAttachment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var attachment models.ExaminationAttachment
+	err := database.DB.WithContext(ctx).Select(columns).
+		First(&attachment, "examination_id = ? AND id = ?", examinationID, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// UpdateAnnotations replaces the annotations of an attachment
+func (r *AttachmentRepository) UpdateAnnotations(ctx context.Context, patientID string, attachment *models.ExaminationAttachment) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(attachment).
+		Where("examination_id = ?", attachment.ExaminationID).
+		Select("annotations", "updated_at", "updated_by").
+		Updates(attachment)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update attachment annotations: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("attachment not found")
+	}
+	return r.invalidateExamination(ctx, patientID, attachment.ExaminationID)
+}
+
+func (r *AttachmentRepository) Delete(ctx context.Context, patientID string, examinationID, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).
+		Delete(&models.ExaminationAttachment{}, "examination_id = ? AND id = ?", examinationID, id).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return r.invalidateExamination(ctx, patientID, examinationID)
+}
+
+// invalidateExamination drops the cached examination and examination lists the attachments travel with
+func (r *AttachmentRepository) invalidateExamination(ctx context.Context, patientID string, examinationID uint) error {
+	if err := r.cache.Delete(ctx, r.cache.Key(ctx, "examination", patientID, examinationID)); err != nil {
+		return fmt.Errorf("failed to delete examination cache: %w", err)
+	}
+	return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "examinations"))
+}
@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// audioNoteColumns are the audio note columns listed; the recording itself is only read when
+// downloaded or transcribed
+const audioNoteColumns = "id, examination_id, title, file_name, content_type, size, duration_seconds, recorded_at, transcription_status, " +
+	"transcript, transcribed_by, transcribed_at, transcription_error, attempts, created_at, updated_at, created_by, updated_by"
+
+// AudioNoteRepository stores the audio notes dictated on examinations and their transcripts
+type AudioNoteRepository struct{}
+
+func NewAudioNoteRepository() *AudioNoteRepository {
+	return &AudioNoteRepository{}
+}
+
+func (r *AudioNoteRepository) Create(ctx context.Context, note *models.ExaminationAudioNote) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Examination").Create(note).Error; err != nil {
+		return fmt.Errorf("failed to create audio note: %w", err)
+	}
+	return nil
+}
+
+// List returns the audio notes of an examination in the order they were recorded, without the recordings
+func (r *AudioNoteRepository) List(ctx context.Context, examinationID uint) ([]models.ExaminationAudioNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var notes []models.ExaminationAudioNote
+	err := database.DB.WithContext(ctx).Select(audioNoteColumns).
+		Where("examination_id = ?", examinationID).
+		Order("recorded_at, id").
+		Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio notes: %w", err)
+	}
+	return notes, nil
+}
+
+// GetByID returns the audio note without its recording
+func (r *AudioNoteRepository) GetByID(ctx context.Context, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	return r.get(ctx, audioNoteColumns, examinationID, id)
+}
+
+// GetWithContent returns the audio note with the recording itself
+func (r *AudioNoteRepository) GetWithContent(ctx context.Context, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	return r.get(ctx, "*", examinationID, id)
+}
+
+func (r *AudioNoteRepository) get(ctx context.Context, columns string, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var note models.ExaminationAudioNote
+	err := database.DB.WithContext(ctx).Select(columns).
+		First(&note, "examination_id = ? AND id = ?", examinationID, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get audio note: %w", err)
+	}
+	return &note, nil
+}
+
+// Queue returns the audio notes across all examinations in a transcription state, oldest first,
+// with the patients they were dictated about
+func (r *AudioNoteRepository) Queue(ctx context.Context, status string, limit int) ([]models.TranscriptionQueueItem, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var items []models.TranscriptionQueueItem
+	err := database.DB.WithContext(ctx).Table("examination_audio_note n").
+		Select("n.id, n.examination_id, e.patient_id, n.title, n.duration_seconds, n.recorded_at, n.transcription_status, "+
+			"n.transcription_error, n.attempts, n.created_by").
+		Joins("JOIN examination e ON e.id = n.examination_id").
+		Where("n.transcription_status = ?", status).
+		Order("n.recorded_at, n.id").
+		Limit(limit).
+		Scan(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio notes to transcribe: %w", err)
+	}
+	return items, nil
+}
+
+// SaveTranscription stores the transcription state of a note, with its transcript
+func (r *AudioNoteRepository) SaveTranscription(ctx context.Context, note *models.ExaminationAudioNote) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(note).
+		Where("examination_id = ?", note.ExaminationID).
+		Select("transcription_status", "transcript", "transcribed_by", "transcribed_at", "transcription_error", "attempts", "updated_at", "updated_by").
+		Updates(note)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save audio note transcription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("audio note not found")
+	}
+	return nil
+}
+
+// ClaimPending hands the oldest note waiting for the transcription provider, or claimed before
+// staleBefore and never finished, to the caller with its recording, counting the attempt. It returns
+// nil when there is none left with fewer than maxAttempts tries. Replicas transcribing at once each
+// get a different note.
+func (r *AudioNoteRepository) ClaimPending(ctx context.Context, maxAttempts int, staleBefore time.Time) (*models.ExaminationAudioNote, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var note models.ExaminationAudioNote
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(transcription_status = ? OR (transcription_status = ? AND updated_at < ?)) AND attempts < ?",
+				models.TranscriptionPending, models.TranscriptionTranscribing, staleBefore, maxAttempts).
+			Order("recorded_at, id").
+			First(&note).Error
+		if err != nil {
+			return err
+		}
+		note.TranscriptionStatus, note.Attempts = models.TranscriptionTranscribing, note.Attempts+1
+		return tx.Model(&models.ExaminationAudioNote{}).Where("id = ?", note.ID).
+			Updates(map[string]interface{}{"transcription_status": note.TranscriptionStatus, "attempts": note.Attempts, "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim audio note: %w", err)
+	}
+	return &note, nil
+}
+
+func (r *AudioNoteRepository) Delete(ctx context.Context, examinationID, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).
+		Delete(&models.ExaminationAudioNote{}, "examination_id = ? AND id = ?", examinationID, id).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete audio note: %w", err)
+	}
+	return nil
+}
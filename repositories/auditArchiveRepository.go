@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditArchiveRepository reads the audit entries to ship to the archive and records the batches
+// shipped, in a table that only ever grows
+type AuditArchiveRepository struct{}
+
+func NewAuditArchiveRepository() *AuditArchiveRepository {
+	return &AuditArchiveRepository{}
+}
+
+// LastBatch returns the most recently shipped batch, or nil before the first one
+func (r *AuditArchiveRepository) LastBatch(ctx context.Context) (*models.AuditArchiveBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batch models.AuditArchiveBatch
+	if err := database.DB.WithContext(ctx).Order("last_seq DESC").First(&batch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last audit archive batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// Batches returns every shipped batch in the order it was shipped
+func (r *AuditArchiveRepository) Batches(ctx context.Context) ([]models.AuditArchiveBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batches []models.AuditArchiveBatch
+	if err := database.DB.WithContext(ctx).Order("last_seq").Find(&batches).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit archive batches: %w", err)
+	}
+	return batches, nil
+}
+
+func (r *AuditArchiveRepository) CountBatches(ctx context.Context) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.AuditArchiveBatch{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count audit archive batches: %w", err)
+	}
+	return count, nil
+}
+
+func (r *AuditArchiveRepository) RecordBatch(ctx context.Context, batch *models.AuditArchiveBatch) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(batch).Error; err != nil {
+		return fmt.Errorf("failed to record audit archive batch: %w", err)
+	}
+	return nil
+}
+
+// Unshipped returns up to limit audit entries after afterID written before before, in ID order
+func (r *AuditArchiveRepository) Unshipped(ctx context.Context, afterID int64, before time.Time, limit int) ([]models.AuditLog, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AuditLog
+	err := database.DB.WithContext(ctx).Where("id > ? AND created_at < ?", afterID, before).
+		Order("id").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unshipped audit logs: %w", err)
+	}
+	return entries, nil
+}
+
+// CountUnshipped counts the audit entries after afterID
+func (r *AuditArchiveRepository) CountUnshipped(ctx context.Context, afterID int64) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.AuditLog{}).Where("id > ?", afterID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count unshipped audit logs: %w", err)
+	}
+	return count, nil
+}
+
+// AuditEntries returns the audit entries with the given IDs by ID; entries that no longer exist are left out
+func (r *AuditArchiveRepository) AuditEntries(ctx context.Context, ids []int64) (map[int64]models.AuditLog, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AuditLog
+	if err := database.DB.WithContext(ctx).Where("id IN ?", ids).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	byID := make(map[int64]models.AuditLog, len(entries))
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+	return byID, nil
+}
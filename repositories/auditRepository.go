@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// maxAuditPageSize caps how many audit entries a single query returns.
+const maxAuditPageSize = 500
+
+// AuditRepository stores the audit trail. Entries are append-only and never cached.
+type AuditRepository struct{}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// List returns audit entries matching filter, newest first, with the total number of matches.
+func (r *AuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := filteredAudit(database.DB.WithContext(ctx).Model(&models.AuditLog{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	if filter.Limit <= 0 || filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	var entries []models.AuditLog
+	if err := query.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return entries, total, nil
+}
+
+// CountByEvent counts the audit entries matching filter by category and event, most frequent first
+func (r *AuditRepository) CountByEvent(ctx context.Context, filter models.AuditFilter) ([]models.AuditEventCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.AuditEventCount
+	err := filteredAudit(database.DB.WithContext(ctx).Model(&models.AuditLog{}), filter).
+		Select("category, event, COUNT(*) AS count").
+		Group("category, event").
+		Order("count DESC, event").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+	return counts, nil
+}
+
+// filteredAudit narrows query to the audit entries matching filter
+func filteredAudit(query *gorm.DB, filter models.AuditFilter) *gorm.DB {
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if len(filter.Events) > 0 {
+		query = query.Where("event IN ?", filter.Events)
+	}
+	if len(filter.ExcludeEvents) > 0 {
+		query = query.Where("event NOT IN ?", filter.ExcludeEvents)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuthRateLimitRepository counts attempts at the auth endpoints in Redis, so the count is shared by
+// every replica
+type AuthRateLimitRepository struct{}
+
+func NewAuthRateLimitRepository() *AuthRateLimitRepository {
+	return &AuthRateLimitRepository{}
+}
+
+// Hit counts an attempt against key, whose count starts afresh window after its first attempt, and
+// returns the count with the time left until it does. Nothing is counted while Redis is unavailable.
+func (r *AuthRateLimitRepository) Hit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return 0, 0, nil
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	key = "auth_rate:" + key
+	count, err := database.RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		database.RedisBreaker.Failure()
+		return 0, 0, fmt.Errorf("failed to count attempt: %w", err)
+	}
+	database.RedisBreaker.Success()
+	if count == 1 {
+		if err := database.RedisClient.Expire(ctx, key, window).Err(); err != nil {
+			return 0, 0, fmt.Errorf("failed to start attempt window: %w", err)
+		}
+		return count, window, nil
+	}
+	ttl, err := database.RedisClient.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get attempt window: %w", err)
+	}
+	// A count left without an expiry, e.g. when setting it failed, would never start afresh
+	if ttl < 0 {
+		ttl = window
+		if err := database.RedisClient.Expire(ctx, key, window).Err(); err != nil {
+			return 0, 0, fmt.Errorf("failed to start attempt window: %w", err)
+		}
+	}
+	return count, ttl, nil
+}
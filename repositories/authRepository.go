@@ -2,20 +2,15 @@ package repositories
 
 import (
 	"RoyDental/cache"
+	"RoyDental/database"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
-)
-
-const (
-	UserCacheExpiry = 7 * 24 * time.Hour
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
@@ -33,6 +28,7 @@ type UserRepository interface {
 	UpdateUserProfile(ctx context.Context, userID int64, username, email string) error
 	GetUserPermissions(ctx context.Context, userID int64) ([]models.Permission, error)
 	DeleteUser(ctx context.Context, userID int64) error
+	AdminEmails(ctx context.Context) ([]string, error)
 }
 
 type userRepository struct {
@@ -46,7 +42,7 @@ func NewUserRepository(db *gorm.DB, cache *cache.Cache) UserRepository {
 
 func (r *userRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("email = ?", email).Count(&count).Error
 	if err != nil {
 		return false, fmt.Errorf("failed to check email existence: %w", err)
 	}
@@ -54,22 +50,18 @@ func (r *userRepository) EmailExists(ctx context.Context, email string) (bool, e
 }
 
 func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getUserCacheKey(username)
-	cachedUser, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var user models.User
-		if err := json.Unmarshal([]byte(cachedUser), &user); err == nil {
-			return &user, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getUserCacheKey(ctx, username)
+	var user models.User
+	if found, err := r.cache.GetObject(ctx, cacheKey, &user); err != nil {
 		log.Printf("Failed to get user from cache: %v", err)
+	} else if found {
+		return &user, nil
 	}
 
-	var user models.User
-	err = r.db.Select("id, username, email, role_id, created_at").
+	err := r.db.WithContext(ctx).Select("id, username, email, email_bounced_at, role_id, created_at").
 		Preload("Role", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, description")
 		}).
@@ -82,11 +74,7 @@ func (r *userRepository) GetUserByUsername(ctx context.Context, username string)
 		return nil, err
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return nil, err
-	}
-	if err := r.cache.Set(ctx, cacheKey, userJSON, UserCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, user, cache.ItemTTL("user")); err != nil {
 		log.Printf("Failed to set user in cache: %v", err)
 	}
 
@@ -94,22 +82,18 @@ func (r *userRepository) GetUserByUsername(ctx context.Context, username string)
 }
 
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getUserCacheKey(email)
-	cachedUser, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var user models.User
-		if err := json.Unmarshal([]byte(cachedUser), &user); err == nil {
-			return &user, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getUserCacheKey(ctx, email)
+	var user models.User
+	if found, err := r.cache.GetObject(ctx, cacheKey, &user); err != nil {
 		log.Printf("Failed to get user from cache: %v", err)
+	} else if found {
+		return &user, nil
 	}
 
-	var user models.User
-	err = r.db.Select("id, username, email, role_id, created_at").
+	err := r.db.WithContext(ctx).Select("id, username, email, email_bounced_at, role_id, created_at").
 		Preload("Role", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, description")
 		}).
@@ -122,11 +106,7 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 		return nil, err
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return nil, err
-	}
-	if err := r.cache.Set(ctx, cacheKey, userJSON, UserCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, user, cache.ItemTTL("user")); err != nil {
 		log.Printf("Failed to set user in cache: %v", err)
 	}
 
@@ -134,12 +114,14 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 }
 
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
-	return r.db.Create(&user).Error
+	// Only a bounce sets the email's flag
+	user.EmailBouncedAt = nil
+	return r.db.WithContext(ctx).Create(&user).Error
 }
 
 func (r *userRepository) AuthenticateUser(ctx context.Context, email, password string) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id, username, email, password, role_id, created_at").
+	err := r.db.WithContext(ctx).Select("id, username, email, password, role_id, created_at").
 		Preload("Role", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, description")
 		}).
@@ -157,27 +139,34 @@ func (r *userRepository) AuthenticateUser(ctx context.Context, email, password s
 
 func (r *userRepository) ValidateRoleID(ctx context.Context, roleID int64) error {
 	var count int64
-	err := r.db.Model(&models.Role{}).Where("id = ?", roleID).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.Role{}).Where("id = ?", roleID).Count(&count).Error
 	if err != nil {
 		return fmt.Errorf("failed to validate role ID: %w", err)
 	}
+	if count == 0 {
+		return errors.New("role not found")
+	}
 	return nil
 }
 
+// UpdateUserEmail changes a user's email, clearing its bounce flag when it is a new address
 func (r *userRepository) UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error {
-	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("email", newEmail).Error
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email":            newEmail,
+		"email_bounced_at": emailBouncedAt(newEmail),
+	}).Error
 }
 
 func (r *userRepository) UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
-	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
 }
 
 func (r *userRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
 	var users []models.User
-	err := r.db.Select("id, username, email, role_id, created_at").
+	err := r.db.WithContext(ctx).Select("id, username, email, email_bounced_at, role_id, created_at").
 		Preload("Role", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, description")
 		}).
@@ -189,27 +178,23 @@ func (r *userRepository) GetAllUsers(ctx context.Context) ([]models.User, error)
 }
 
 func (r *userRepository) DeleteUserCache(ctx context.Context, identifier string) error {
-	cacheKey := r.getUserCacheKey(identifier)
+	cacheKey := r.getUserCacheKey(ctx, identifier)
 	return r.cache.Delete(ctx, cacheKey)
 }
 
 func (r *userRepository) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getUserCacheKey(fmt.Sprintf("%d", userID))
-	cachedUser, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var user models.User
-		if err := json.Unmarshal([]byte(cachedUser), &user); err == nil {
-			return &user, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getUserCacheKey(ctx, fmt.Sprintf("%d", userID))
+	var user models.User
+	if found, err := r.cache.GetObject(ctx, cacheKey, &user); err != nil {
 		log.Printf("Failed to get user from cache: %v", err)
+	} else if found {
+		return &user, nil
 	}
 
-	var user models.User
-	err = r.db.Select("id, username, email, role_id, created_at").
+	err := r.db.WithContext(ctx).Select("id, username, email, email_bounced_at, role_id, created_at").
 		Preload("Role", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, name, description")
 		}).
@@ -221,11 +206,7 @@ func (r *userRepository) GetUserByID(ctx context.Context, userID int64) (*models
 		return nil, err
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return nil, err
-	}
-	if err := r.cache.Set(ctx, cacheKey, userJSON, UserCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, user, cache.ItemTTL("user")); err != nil {
 		log.Printf("Failed to set user in cache: %v", err)
 	}
 
@@ -233,28 +214,50 @@ func (r *userRepository) GetUserByID(ctx context.Context, userID int64) (*models
 }
 
 func (r *userRepository) UpdateUserProfile(ctx context.Context, userID int64, username, email string) error {
-	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"username": username,
-		"email":    email,
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"username":         username,
+		"email":            email,
+		"email_bounced_at": emailBouncedAt(email),
 	}).Error
 }
 
+// emailBouncedAt keeps a user's bounce flag while their email stays the same address
+func emailBouncedAt(email string) clause.Expr {
+	return gorm.Expr("CASE WHEN LOWER(email) = LOWER(?) THEN email_bounced_at END", email)
+}
+
 func (r *userRepository) GetUserPermissions(ctx context.Context, userID int64) ([]models.Permission, error) {
-	var permissions []models.Permission
-	err := r.db.Joins("JOIN role_permissions rp ON permissions.id = rp.permission_id").
-		Joins("JOIN roles r ON rp.role_id = r.id").
-		Where("r.id = (SELECT role_id FROM users WHERE id = ?)", userID).
-		Find(&permissions).Error
+	user, err := r.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	return permissions, nil
+	if user == nil {
+		return nil, nil
+	}
+	return rolePermissions(ctx, r.cache, r.db, user.RoleID)
 }
 
 func (r *userRepository) DeleteUser(ctx context.Context, userID int64) error {
-	return r.db.Delete(&models.User{}, userID).Error
+	return r.db.WithContext(ctx).Delete(&models.User{}, userID).Error
+}
+
+func (r *userRepository) getUserCacheKey(ctx context.Context, identifier string) string {
+	return r.cache.Key(ctx, "user", identifier)
 }
 
-func (r *userRepository) getUserCacheKey(identifier string) string {
-	return fmt.Sprintf("user_cache:%s", identifier)
+// AdminEmails returns the addresses of the users with the Admin role whose email has not bounced
+func (r *userRepository) AdminEmails(ctx context.Context) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var emails []string
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("roles.name = ? AND users.email_bounced_at IS NULL", "Admin").
+		Order("users.id").
+		Pluck("users.email", &emails).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin emails: %w", err)
+	}
+	return emails, nil
 }
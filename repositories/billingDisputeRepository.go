@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDisputeNotOpen is returned for changes to a dispute that was settled, or that is not about the
+// bill being corrected
+var ErrDisputeNotOpen = errors.New("billing dispute is not open")
+
+// BillingDisputeRepository stores the disputes raised about bills
+type BillingDisputeRepository struct{}
+
+func NewBillingDisputeRepository() *BillingDisputeRepository {
+	return &BillingDisputeRepository{}
+}
+
+func (r *BillingDisputeRepository) Create(ctx context.Context, dispute *models.BillingDispute) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(dispute).Error; err != nil {
+		return fmt.Errorf("failed to create billing dispute: %w", err)
+	}
+	return nil
+}
+
+// Get returns a dispute with the adjustments linked to it, or nil when there is none
+func (r *BillingDisputeRepository) Get(ctx context.Context, id uint) (*models.BillingDispute, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var dispute models.BillingDispute
+	if err := database.DB.WithContext(ctx).First(&dispute, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get billing dispute: %w", err)
+	}
+	if err := database.DB.WithContext(ctx).Where("dispute_id = ?", id).Order("created_at, id").Find(&dispute.Adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get billing dispute adjustments: %w", err)
+	}
+	return &dispute, nil
+}
+
+// ListByBilling returns the disputes about a bill, newest first
+func (r *BillingDisputeRepository) ListByBilling(ctx context.Context, billingID string) ([]models.BillingDispute, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var disputes []models.BillingDispute
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", billingID).Order("created_at DESC, id DESC").Find(&disputes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list billing disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+// SetStatus moves a dispute that is still open to status, stamping when it was settled
+func (r *BillingDisputeRepository) SetStatus(ctx context.Context, id uint, status, note string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	updates := map[string]interface{}{"status": status, "resolution_note": note, "resolved_at": nil}
+	if !models.IsOpenDisputeStatus(status) {
+		updates["resolved_at"] = time.Now()
+	}
+	result := database.DB.WithContext(ctx).Model(&models.BillingDispute{}).
+		Where("id = ? AND status IN ?", id, models.OpenDisputeStatuses).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update billing dispute: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDisputeNotOpen
+	}
+	return nil
+}
+
+// Open returns the disputes still being worked on, oldest first, with their bill and patient
+func (r *BillingDisputeRepository) Open(ctx context.Context) ([]models.OpenDispute, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var disputes []models.OpenDispute
+	err := database.DB.WithContext(ctx).Table("billing_dispute d").
+		Select("d.*, b.patient_id, p.first_name || ' ' || p.last_name AS patient_name, b.balance").
+		Joins("JOIN billing b ON b.billing_id = d.billing_id").
+		Joins("JOIN patient p ON p.id = b.patient_id").
+		Where("d.status IN ?", models.OpenDisputeStatuses).
+		Order("d.created_at, d.id").
+		Scan(&disputes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open billing disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+// checkDisputeOpen refuses to link an adjustment to a dispute that is settled or about another bill
+func checkDisputeOpen(tx *gorm.DB, disputeID uint, billingID string) error {
+	var count int64
+	err := tx.Model(&models.BillingDispute{}).
+		Where("id = ? AND billing_id = ? AND status IN ?", disputeID, billingID, models.OpenDisputeStatuses).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check billing dispute: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: dispute %d about bill %s", ErrDisputeNotOpen, disputeID, billingID)
+	}
+	return nil
+}
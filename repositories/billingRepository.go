@@ -3,21 +3,17 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
-)
-
-const (
-	BillingCacheExpiry = 7 * 24 * time.Hour
+	"gorm.io/gorm/clause"
 )
 
 type BillingRepository struct {
@@ -29,95 +25,79 @@ func NewBillingRepository(cache *cache.Cache) *BillingRepository {
 }
 
 func (r *BillingRepository) Create(ctx context.Context, billing *models.Billing) error {
-	lockKey := fmt.Sprintf("billing_lock:%s", billing.BillingID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second)
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	// Check if the doctor exists
-	var doctor models.Doctor
-	if err := database.DB.First(&doctor, "id = ?", billing.DoctorID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("doctor not found")
+	err := database.WithLock(ctx, fmt.Sprintf("billing_lock:%s", billing.BillingID), func(tx *gorm.DB) error {
+		// Check if the doctor exists
+		var doctor models.Doctor
+		if err := tx.First(&doctor, "id = ?", billing.DoctorID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("doctor not found")
+			}
+			return fmt.Errorf("failed to find doctor: %w", err)
 		}
-		return fmt.Errorf("failed to find doctor: %w", err)
-	}
 
-	// Obtain the next sequence value outside the transaction
-	var nextID string
-	if err := database.DB.Raw("SELECT 'PB-' || LPAD(nextval('billing_id_seq')::TEXT, 6, '0')").Scan(&nextID).Error; err != nil {
-		return fmt.Errorf("failed to obtain next sequence value: %w", err)
-	}
+		// Obtain the next ID in its configured format
+		nextID, err := generateID(tx, "billing")
+		if err != nil {
+			return err
+		}
 
-	// Set the obtained ID to the billing
-	billing.BillingID = nextID
+		// Set the obtained ID to the billing
+		billing.BillingID = nextID
 
-	// Calculate the balance and total_received
-	billing.Balance = billing.BillingAmount - (billing.PaidCashAmount + billing.PaidInsuranceAmount)
-	billing.TotalReceived = billing.PaidCashAmount + billing.PaidInsuranceAmount
+		// Calculate the balance and total_received
+		billing.ComputeTotals()
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		// Create the billing record
-		if err := tx.Create(billing).Error; err != nil {
-			// If the creation fails, rollback the sequence
-			if rollbackErr := database.DB.Exec("SELECT setval('billing_id_seq', (SELECT last_value FROM billing_id_seq) - 1, false)").Error; rollbackErr != nil {
-				return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+		return tx.Transaction(func(tx *gorm.DB) error {
+			// Create the billing record
+			if err := tx.Create(billing).Error; err != nil {
+				// If the creation fails, rollback the sequence
+				if rollbackErr := releaseID(database.DB.WithContext(ctx), "billing"); rollbackErr != nil {
+					return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+				}
+				return fmt.Errorf("failed to create billing: %w", err)
+			}
+			if err := recordPayments(tx, billing.BillingID, billing.PaidCashAmount, billing.PaidInsuranceAmount); err != nil {
+				return err
 			}
-			return fmt.Errorf("failed to create billing: %w", err)
-		}
 
-		// Delete cache for the newly created billing and all billings
-		if err := r.cache.Delete(ctx, r.getBillingCacheKey(billing.BillingID)); err != nil {
-			return fmt.Errorf("failed to delete billing cache: %w", err)
-		}
-		if err := r.cache.DeleteAll(ctx, "billings_cache"); err != nil {
-			return fmt.Errorf("failed to delete all billings cache: %w", err)
-		}
-		// Invalidate the specific patient cache and all billings cache
-		if err := r.cache.Delete(ctx, r.getPatientCacheKey(billing.PatientID)); err != nil {
-			return fmt.Errorf("failed to delete patient cache: %w", err)
-		}
-		return r.cache.DeleteAll(ctx, "patients_cache")
+			// Delete cache for the newly created billing and all billings
+			if err := r.cache.Delete(ctx, r.getBillingCacheKey(ctx, billing.BillingID)); err != nil {
+				return fmt.Errorf("failed to delete billing cache: %w", err)
+			}
+			if err := deleteListCache(ctx, r.cache, "billings", doctorBillingsCache); err != nil {
+				return fmt.Errorf("failed to delete all billings cache: %w", err)
+			}
+			// Invalidate the specific patient cache and all billings cache
+			if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, billing.PatientID)); err != nil {
+				return fmt.Errorf("failed to delete patient cache: %w", err)
+			}
+			return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+		})
 	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.BillingCreated, PatientID: billing.PatientID, Record: "billing", RecordID: billing.BillingID})
+	publishPaymentRecorded(ctx, billing, billing.TotalReceived)
+	return nil
 }
 
 func (r *BillingRepository) GetByID(ctx context.Context, id string) (*models.Billing, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getBillingCacheKey(id)
-	cachedBilling, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var billing models.Billing
-		if err := json.Unmarshal([]byte(cachedBilling), &billing); err == nil {
-			return &billing, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getBillingCacheKey(ctx, id)
+	var billing models.Billing
+	if found, err := r.cache.GetObject(ctx, cacheKey, &billing); err != nil {
 		log.Printf("Failed to get billing from cache: %v", err)
+	} else if found {
+		return &billing, nil
 	}
 
-	var billing models.Billing
-	err = database.DB.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at").
+	err := database.DB.WithContext(ctx).Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, updated_at, created_by, updated_by").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
@@ -132,11 +112,7 @@ func (r *BillingRepository) GetByID(ctx context.Context, id string) (*models.Bil
 		return nil, fmt.Errorf("failed to get billing: %w", err)
 	}
 
-	billingJSON, err := json.Marshal(billing)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal billing: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, billingJSON, BillingCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, billing, cache.ItemTTL("billing")); err != nil {
 		log.Printf("Failed to set billing in cache: %v", err)
 	}
 
@@ -144,163 +120,416 @@ func (r *BillingRepository) GetByID(ctx context.Context, id string) (*models.Bil
 }
 
 func (r *BillingRepository) GetAll(ctx context.Context) ([]models.Billing, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "billings_cache"
-	cachedBillings, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "billings"), "billing", func(ctx context.Context) ([]models.Billing, error) {
 		var billings []models.Billing
-		if err := json.Unmarshal([]byte(cachedBillings), &billings); err == nil {
-			return billings, nil
+		err := r.listQuery(database.DB.WithContext(ctx)).
+			Order("created_at DESC").
+			Find(&billings).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all billings: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get billings from cache: %v", err)
-	}
+		return billings, nil
+	})
+}
 
-	var billings []models.Billing
-	err = database.DB.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at").
-		Preload("Patient", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, first_name, last_name")
-		}).
-		Preload("Doctor", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, first_name, last_name")
-		}).
-		Order("created_at DESC").
-		Find(&billings).Error
+// Stream hands every billing to fn newest first without holding the whole list in memory
+func (r *BillingRepository) Stream(ctx context.Context, fn func(models.Billing) error) error {
+	return streamNewestFirst(ctx, "billing_id", r.listQuery, func(b *models.Billing) (time.Time, interface{}) {
+		return b.CreatedAt, b.BillingID
+	}, fn)
+}
+
+// StreamFiltered hands the billings matching query to fn in the order it asks for
+func (r *BillingRepository) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Billing) error) error {
+	filter, err := listFilter(models.ListBillings, query, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all billings: %w", err)
+		return err
 	}
+	return streamOrdered(ctx, func(db *gorm.DB) *gorm.DB { return filter(r.listQuery(db)) }, fn)
+}
 
-	billingsJSON, err := json.Marshal(billings)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal billings: %w", err)
+// ListAfter returns up to limit billings newest first, starting after the cursor when one is given
+func (r *BillingRepository) ListAfter(ctx context.Context, after *models.ListCursor, limit int) ([]models.Billing, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		createdAt, key = after.CreatedAt, after.Key
+	}
+	return listNewestFirst[models.Billing](ctx, "billing_id", r.listQuery, createdAt, key, limit)
+}
+
+// ListByDoctorAfter returns up to limit of the doctor's billings newest first, starting after the
+// cursor when one is given
+func (r *BillingRepository) ListByDoctorAfter(ctx context.Context, doctorID string, after *models.ListCursor, limit int) ([]models.Billing, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		createdAt, key = after.CreatedAt, after.Key
 	}
-	if err := r.cache.Set(ctx, cacheKey, billingsJSON, BillingCacheExpiry); err != nil {
-		log.Printf("Failed to set billings in cache: %v", err)
+	return cachedDoctorPage(ctx, r.cache, doctorBillingsCache, "billing", doctorID, after, limit, func(ctx context.Context) ([]models.Billing, error) {
+		return listNewestFirst[models.Billing](ctx, "billing_id", func(db *gorm.DB) *gorm.DB {
+			return r.listQuery(db).Where("doctor_id = ?", doctorID)
+		}, createdAt, key, limit)
+	})
+}
+
+// ListByPatientAfter returns up to limit of the patient's billings newest first, starting after the
+// cursor when one is given
+func (r *BillingRepository) ListByPatientAfter(ctx context.Context, patientID string, after *models.ListCursor, limit int) ([]models.Billing, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		createdAt, key = after.CreatedAt, after.Key
 	}
+	return listNewestFirst[models.Billing](ctx, "billing_id", func(db *gorm.DB) *gorm.DB {
+		return r.listQuery(db).Where("patient_id = ?", patientID)
+	}, createdAt, key, limit)
+}
 
-	return billings, nil
+// listQuery selects the columns and relations returned in billing lists
+func (r *BillingRepository) listQuery(db *gorm.DB) *gorm.DB {
+	return db.Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, updated_at, created_by, updated_by").
+		Preload("Patient", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		}).
+		Preload("Doctor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		})
 }
 
 func (r *BillingRepository) Update(ctx context.Context, billing *models.Billing) error {
-	lockKey := fmt.Sprintf("billing_lock:%s", billing.BillingID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var received float64
+	err := database.WithLock(ctx, fmt.Sprintf("billing_lock:%s", billing.BillingID), func(tx *gorm.DB) error {
+		// Check if the doctor exists
+		var doctor models.Doctor
+		if err := tx.First(&doctor, "id = ?", billing.DoctorID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("doctor not found")
+			}
+			return fmt.Errorf("failed to find doctor: %w", err)
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+
+		// Calculate the balance and total_received
+		billing.ComputeTotals()
+
+		var current models.Billing
+		if err := tx.First(&current, "billing_id = ?", billing.BillingID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to find billing: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		received = billing.TotalReceived - current.TotalReceived
+
+		adjustment, err := billingAdjustment(tx, &current, billing)
+		if err != nil {
+			return err
 		}
-	}()
 
-	// Check if the doctor exists
-	var doctor models.Doctor
-	if err := database.DB.First(&doctor, "id = ?", billing.DoctorID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("doctor not found")
+		// created_at is the partition key and must never be overwritten by an update, nor may who captured the bill
+		err = tx.Omit("created_at", "created_by").Save(billing).Error
+		if err != nil {
+			return fmt.Errorf("failed to update billing: %w", err)
+		}
+		if adjustment != nil {
+			if err := tx.Create(adjustment).Error; err != nil {
+				return fmt.Errorf("failed to record billing adjustment: %w", err)
+			}
+		}
+		err = recordPayments(tx, billing.BillingID, billing.PaidCashAmount-current.PaidCashAmount, billing.PaidInsuranceAmount-current.PaidInsuranceAmount)
+		if err != nil {
+			return err
+		}
+		// Delete cache for the updated billing and all billings
+		if err := r.cache.Delete(ctx, r.getBillingCacheKey(ctx, billing.BillingID)); err != nil {
+			return fmt.Errorf("failed to delete billing cache: %w", err)
+		}
+		if err := deleteListCache(ctx, r.cache, "billings", doctorBillingsCache); err != nil {
+			return fmt.Errorf("failed to delete all billings cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all billings cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, billing.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
-		return fmt.Errorf("failed to find doctor: %w", err)
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+	if err != nil {
+		return err
 	}
+	publishPaymentRecorded(ctx, billing, received)
+	return nil
+}
+
+func (r *BillingRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	// Calculate the balance and total_received
-	billing.Balance = billing.BillingAmount - (billing.PaidCashAmount + billing.PaidInsuranceAmount)
-	billing.TotalReceived = billing.PaidCashAmount + billing.PaidInsuranceAmount
+	return database.WithLock(ctx, fmt.Sprintf("billing_lock:%s", id), func(tx *gorm.DB) error {
+		var billing models.Billing
+		if err := tx.First(&billing, "billing_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to find billing: %w", err)
+		}
+		if err := checkBillingPeriodOpen(tx, billing.CreatedAt); err != nil {
+			return err
+		}
 
-	err = database.DB.Save(billing).Error
-	if err != nil {
-		return fmt.Errorf("failed to update billing: %w", err)
+		err := tx.Delete(&models.Billing{}, "billing_id = ?", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete billing: %w", err)
+		}
+		if err := tx.Delete(&models.Payment{}, "billing_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete billing payments: %w", err)
+		}
+		// Delete cache for the deleted billing and all billings
+		if err := r.cache.Delete(ctx, r.getBillingCacheKey(ctx, id)); err != nil {
+			return fmt.Errorf("failed to delete billing cache: %w", err)
+		}
+		if err := deleteListCache(ctx, r.cache, "billings", doctorBillingsCache); err != nil {
+			return fmt.Errorf("failed to delete all billings cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all billings cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, billing.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
+
+// ListByPatient returns the patient's billings oldest first, limited to those created from from and
+// before to when they are not zero
+func (r *BillingRepository) ListByPatient(ctx context.Context, patientID string, from, to time.Time) ([]models.Billing, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, updated_at").
+		Where("patient_id = ?", patientID)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
 	}
-	// Delete cache for the updated billing and all billings
-	if err := r.cache.Delete(ctx, r.getBillingCacheKey(billing.BillingID)); err != nil {
-		return fmt.Errorf("failed to delete billing cache: %w", err)
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
 	}
-	if err := r.cache.DeleteAll(ctx, "billings_cache"); err != nil {
-		return fmt.Errorf("failed to delete all billings cache: %w", err)
+	var billings []models.Billing
+	if err := query.Order("created_at, billing_id").Find(&billings).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patient billings: %w", err)
 	}
-	// Invalidate the specific patient cache and all billings cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(billing.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	return billings, nil
+}
+
+// CountByPatient returns how many bills the patient has
+func (r *BillingRepository) CountByPatient(ctx context.Context, patientID string) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Billing{}).Where("patient_id = ?", patientID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count patient billings: %w", err)
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return count, nil
 }
 
-func (r *BillingRepository) Delete(ctx context.Context, id string) error {
-	lockKey := fmt.Sprintf("billing_lock:%s", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second)
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
+// PatientBalance sums up the patient's billings, limited to those created before before when it is
+// not zero
+func (r *BillingRepository) PatientBalance(ctx context.Context, patientID string, before time.Time) (*models.PatientBalance, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	balance := models.PatientBalance{PatientID: patientID}
+	query := database.DB.WithContext(ctx).Model(&models.Billing{}).
+		Select("COALESCE(SUM(billing_amount), 0) AS billed, COALESCE(SUM(total_received), 0) AS received, COALESCE(SUM(balance), 0) AS balance, COUNT(*) FILTER (WHERE balance >= 0.005) AS open_bills").
+		Where("patient_id = ?", patientID)
+	if !before.IsZero() {
+		query = query.Where("created_at < ?", before)
 	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+	if err := query.Scan(&balance).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patient balance: %w", err)
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	return &balance, nil
+}
+
+// InsurancePaid returns what insurers paid on the patient's billings created from from and before to
+func (r *BillingRepository) InsurancePaid(ctx context.Context, patientID string, from, to time.Time) (float64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var paid float64
+	err := database.DB.WithContext(ctx).Model(&models.Billing{}).
+		Select("COALESCE(SUM(paid_insurance_amount), 0)").
+		Where("patient_id = ? AND created_at >= ? AND created_at < ?", patientID, from, to).
+		Scan(&paid).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get insurance paid: %w", err)
+	}
+	return paid, nil
+}
+
+// RecordOnlinePayment marks a pending online payment succeeded and adds it to the cash received on
+// its bill. A bill of a closed financial period takes the payment as an adjustment. It returns
+// false when the payment was no longer pending, so a repeated gateway callback is counted once.
+func (r *BillingRepository) RecordOnlinePayment(ctx context.Context, payment *models.OnlinePayment, gatewayReference string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
 	var billing models.Billing
-	if err := database.DB.First(&billing, "billing_id = ?", id).Error; err != nil {
-		return fmt.Errorf("failed to find billing: %w", err)
+	recorded := false
+	err := database.WithLock(ctx, fmt.Sprintf("billing_lock:%s", payment.BillingID), func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			var current models.OnlinePayment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, payment.ID).Error; err != nil {
+				return fmt.Errorf("failed to find online payment: %w", err)
+			}
+			if current.Status != models.OnlinePaymentPending {
+				*payment = current
+				return nil
+			}
+			if err := tx.First(&billing, "billing_id = ?", payment.BillingID).Error; err != nil {
+				return fmt.Errorf("failed to find billing: %w", err)
+			}
+
+			updated := billing
+			updated.PaidCashAmount += current.Amount
+			updated.ComputeTotals()
+			updated.Adjustment = true
+			updated.AdjustmentReason = fmt.Sprintf("Online payment %s", current.Reference)
+			adjustment, err := billingAdjustment(tx, &billing, &updated)
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&models.Billing{}).Where("billing_id = ?", billing.BillingID).Updates(map[string]interface{}{
+				"paid_cash_amount": updated.PaidCashAmount,
+				"balance":          updated.Balance,
+				"total_received":   updated.TotalReceived,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update billing: %w", err)
+			}
+			if adjustment != nil {
+				if err := tx.Create(adjustment).Error; err != nil {
+					return fmt.Errorf("failed to record billing adjustment: %w", err)
+				}
+			}
+
+			now := time.Now()
+			current.Status = models.OnlinePaymentSucceeded
+			current.CompletedAt = &now
+			if gatewayReference != "" {
+				current.GatewayReference = gatewayReference
+			}
+			err = tx.Model(&current).Updates(map[string]interface{}{
+				"status":            current.Status,
+				"completed_at":      current.CompletedAt,
+				"gateway_reference": current.GatewayReference,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update online payment: %w", err)
+			}
+			*payment = current
+			billing = updated
+			recorded = true
+
+			if err := r.cache.Delete(ctx, r.getBillingCacheKey(ctx, billing.BillingID)); err != nil {
+				return fmt.Errorf("failed to delete billing cache: %w", err)
+			}
+			if err := deleteListCache(ctx, r.cache, "billings", doctorBillingsCache); err != nil {
+				return fmt.Errorf("failed to delete all billings cache: %w", err)
+			}
+			if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, billing.PatientID)); err != nil {
+				return fmt.Errorf("failed to delete patient cache: %w", err)
+			}
+			return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+		})
+	})
+	if err != nil || !recorded {
+		return false, err
 	}
+	publishPaymentRecorded(ctx, &billing, payment.Amount)
+	return true, nil
+}
 
-	err = database.DB.Delete(&models.Billing{}, "billing_id = ?", id).Error
-	if err != nil {
-		return fmt.Errorf("failed to delete billing: %w", err)
+// recordPayments adds the cash and insurance received against a bill to the payments ledger,
+// skipping a method whose amount did not change
+func recordPayments(tx *gorm.DB, billingID string, cash, insurance float64) error {
+	var payments []models.Payment
+	if math.Abs(cash) >= 0.005 {
+		payments = append(payments, models.Payment{BillingID: billingID, Method: models.PaymentMethodCash, Amount: cash})
+	}
+	if math.Abs(insurance) >= 0.005 {
+		payments = append(payments, models.Payment{BillingID: billingID, Method: models.PaymentMethodInsurance, Amount: insurance})
+	}
+	if len(payments) == 0 {
+		return nil
 	}
-	// Delete cache for the deleted billing and all billings
-	if err := r.cache.Delete(ctx, r.getBillingCacheKey(id)); err != nil {
-		return fmt.Errorf("failed to delete billing cache: %w", err)
+	if err := tx.Create(&payments).Error; err != nil {
+		return fmt.Errorf("failed to record payments: %w", err)
 	}
-	if err := r.cache.DeleteAll(ctx, "billings_cache"); err != nil {
-		return fmt.Errorf("failed to delete all billings cache: %w", err)
+	return nil
+}
+
+// billingAdjustment returns the adjustment to record when billing corrects current, a bill of a
+// closed financial period, or nil for a bill of an open period. Bills of closed periods are only
+// changed by adjustments, and only in their amounts.
+func billingAdjustment(tx *gorm.DB, current, billing *models.Billing) (*models.BillingAdjustment, error) {
+	if current.BillingID == "" {
+		return nil, nil
+	}
+	err := checkBillingPeriodOpen(tx, current.CreatedAt)
+	if err == nil || !errors.Is(err, ErrPeriodClosed) || !billing.Adjustment {
+		return nil, err
 	}
-	// Invalidate the specific patient cache and all billings cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(billing.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	if billing.PatientID != current.PatientID || billing.DoctorID != current.DoctorID || billing.Procedure != current.Procedure {
+		return nil, fmt.Errorf("%w: an adjustment may only change the amounts of a bill", ErrPeriodClosed)
+	}
+
+	if billing.DisputeID != nil {
+		if err := checkDisputeOpen(tx, *billing.DisputeID, billing.BillingID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.BillingAdjustment{
+		BillingID:           billing.BillingID,
+		Period:              models.FinancialPeriodOf(current.CreatedAt),
+		BillingAmount:       billing.BillingAmount - current.BillingAmount,
+		PaidCashAmount:      billing.PaidCashAmount - current.PaidCashAmount,
+		PaidInsuranceAmount: billing.PaidInsuranceAmount - current.PaidInsuranceAmount,
+		Reason:              billing.AdjustmentReason,
+		DisputeID:           billing.DisputeID,
+	}, nil
+}
+
+// publishPaymentRecorded announces money received against a bill; refunds have a negative amount
+func publishPaymentRecorded(ctx context.Context, billing *models.Billing, received float64) {
+	if math.Abs(received) < 0.005 {
+		return
+	}
+	events.Publish(ctx, events.Event{Name: events.PaymentRecorded, PatientID: billing.PatientID, Record: "billing", RecordID: billing.BillingID, Amount: received})
+}
+
+// InvalidatePatientCache drops the cached billings of a deleted patient on PatientDeleted
+func (r *BillingRepository) InvalidatePatientCache(ctx context.Context, event events.Event) error {
+	for _, id := range event.Related["billing"] {
+		if err := r.DeleteCache(ctx, id); err != nil {
+			return err
+		}
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return r.DeleteAllCache(ctx)
 }
 
 func (r *BillingRepository) DeleteCache(ctx context.Context, id string) error {
-	return r.cache.Delete(ctx, r.getBillingCacheKey(id))
+	return r.cache.Delete(ctx, r.getBillingCacheKey(ctx, id))
 }
 
 func (r *BillingRepository) DeleteAllCache(ctx context.Context) error {
-	return r.cache.DeleteAll(ctx, "billings_cache")
+	return deleteListCache(ctx, r.cache, "billings", doctorBillingsCache)
 }
 
-func (r *BillingRepository) getBillingCacheKey(id string) string {
-	return fmt.Sprintf("billing_cache:%s", id)
+func (r *BillingRepository) getBillingCacheKey(ctx context.Context, id string) string {
+	return r.cache.Key(ctx, "billing", id)
 }
 
-func (r *BillingRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+func (r *BillingRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
 }
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BreakGlassRepository stores the emergency accesses clinicians opened to patients' records
+type BreakGlassRepository struct{}
+
+func NewBreakGlassRepository() *BreakGlassRepository {
+	return &BreakGlassRepository{}
+}
+
+func (r *BreakGlassRepository) Create(ctx context.Context, access *models.BreakGlassAccess) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(access).Error; err != nil {
+		return fmt.Errorf("failed to create break-glass access: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns an emergency access, or nil when there is none
+func (r *BreakGlassRepository) GetByID(ctx context.Context, id uint) (*models.BreakGlassAccess, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var access models.BreakGlassAccess
+	if err := database.DB.WithContext(ctx).First(&access, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get break-glass access: %w", err)
+	}
+	return &access, nil
+}
+
+// Active reports whether the user has an emergency access to the patient's record open at now
+func (r *BreakGlassRepository) Active(ctx context.Context, userID int64, patientID string, now time.Time) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.BreakGlassAccess{}).
+		Where("user_id = ? AND patient_id = ? AND granted_at <= ? AND expires_at > ?", userID, patientID, now, now).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check break-glass access: %w", err)
+	}
+	return count > 0, nil
+}
+
+// List returns the emergency accesses matching filter, newest first
+func (r *BreakGlassRepository) List(ctx context.Context, filter models.BreakGlassFilter) ([]models.BreakGlassAccess, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	if filter.PatientID != "" {
+		db = db.Where("patient_id = ?", filter.PatientID)
+	}
+	if filter.UserID != nil {
+		db = db.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Unreviewed {
+		db = db.Where("reviewed_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		db = db.Limit(filter.Limit)
+	}
+	var accesses []models.BreakGlassAccess
+	if err := db.Order("granted_at DESC, id DESC").Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list break-glass accesses: %w", err)
+	}
+	return accesses, nil
+}
+
+// Review records an admin's review of an emergency access
+func (r *BreakGlassRepository) Review(ctx context.Context, access *models.BreakGlassAccess) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(access).Omit(clause.Associations).
+		Select("reviewed_at", "reviewed_by", "review_note").
+		Updates(access).Error
+	if err != nil {
+		return fmt.Errorf("failed to review break-glass access: %w", err)
+	}
+	return nil
+}
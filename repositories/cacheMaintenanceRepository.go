@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// KeySweep picks the junk among the Redis keys matching Pattern
+type KeySweep struct {
+	Pattern string
+	Match   func(key string) bool // Narrows down the keys looked at, when set
+	Sizes   bool                  // Whether Stale needs the size of keys, which costs a lookup each
+	Stale   func(key models.RedisKey) bool
+}
+
+// CacheMaintenanceRepository walks the keys kept in Redis and deletes those the cache maintenance
+// finds to be junk. A cluster is walked master by master, as SCAN only walks the node it is sent to.
+type CacheMaintenanceRepository struct{}
+
+func NewCacheMaintenanceRepository() *CacheMaintenanceRepository {
+	return &CacheMaintenanceRepository{}
+}
+
+// Prune deletes the stale keys of sweep, looking at count keys at a time, and returns how many it
+// deleted. Nothing is deleted while Redis is unavailable.
+func (r *CacheMaintenanceRepository) Prune(ctx context.Context, sweep KeySweep, count int) (int, error) {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return 0, nil
+	}
+	cluster, ok := database.RedisClient.(*redis.ClusterClient)
+	if !ok {
+		return r.prune(ctx, database.RedisClient, sweep, count)
+	}
+
+	var mu sync.Mutex
+	total := 0
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		pruned, err := r.prune(ctx, master, sweep, count)
+		mu.Lock()
+		total += pruned
+		mu.Unlock()
+		return err
+	})
+	return total, err
+}
+
+func (r *CacheMaintenanceRepository) prune(ctx context.Context, client redis.UniversalClient, sweep KeySweep, count int) (int, error) {
+	pruned := 0
+	var cursor uint64
+	for {
+		batchCtx, cancel := database.WithWriteTimeout(ctx)
+		keys, next, err := client.Scan(batchCtx, cursor, sweep.Pattern, int64(count)).Result()
+		if err != nil {
+			cancel()
+			database.RedisBreaker.Failure()
+			return pruned, fmt.Errorf("failed to scan keys: %w", err)
+		}
+		deleted, err := r.pruneBatch(batchCtx, client, sweep, keys)
+		cancel()
+		pruned += deleted
+		if err != nil {
+			return pruned, err
+		}
+		database.RedisBreaker.Success()
+
+		cursor = next
+		if cursor == 0 {
+			return pruned, nil
+		}
+	}
+}
+
+// pruneBatch deletes the stale keys among keys
+func (r *CacheMaintenanceRepository) pruneBatch(ctx context.Context, client redis.UniversalClient, sweep KeySweep, keys []string) (int, error) {
+	if sweep.Match != nil {
+		matched := keys[:0]
+		for _, key := range keys {
+			if sweep.Match(key) {
+				matched = append(matched, key)
+			}
+		}
+		keys = matched
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	type lookup struct {
+		ttl   *redis.DurationCmd
+		idle  *redis.DurationCmd
+		bytes *redis.IntCmd
+	}
+	lookups := make([]lookup, len(keys))
+	pipe := client.Pipeline()
+	for i, key := range keys {
+		lookups[i].ttl = pipe.PTTL(ctx, key)
+		lookups[i].idle = pipe.ObjectIdleTime(ctx, key)
+		if sweep.Sizes {
+			lookups[i].bytes = pipe.MemoryUsage(ctx, key)
+		}
+	}
+	// Keys gone since the scan, and idle times Redis does not keep, fail on their own
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+		return 0, fmt.Errorf("failed to inspect keys: %w", err)
+	}
+
+	var stale []string
+	for i, key := range keys {
+		ttl, err := lookups[i].ttl.Result()
+		// A TTL of -2 is a key that is gone
+		if err != nil || ttl == -2 {
+			continue
+		}
+		inspected := models.RedisKey{Key: key, TTL: ttl}
+		if idle, err := lookups[i].idle.Result(); err == nil {
+			inspected.Idle = idle
+		}
+		if lookups[i].bytes != nil {
+			inspected.Bytes, _ = lookups[i].bytes.Result()
+		}
+		if sweep.Stale(inspected) {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	// Keys of a cluster node may be in different slots, so they are unlinked one by one
+	pipe = client.Pipeline()
+	unlinked := make([]*redis.IntCmd, len(stale))
+	for i, key := range stale {
+		unlinked[i] = pipe.Unlink(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	deleted := 0
+	for _, cmd := range unlinked {
+		deleted += int(cmd.Val())
+	}
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete keys: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,386 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// campaignChannels are the patient column each campaign channel reaches patients at and the
+// preference that allows it
+var campaignChannels = map[string]struct {
+	Recipient  string
+	Preference string
+}{
+	models.ConsentEmail: {"patient.email", "cp.email"},
+	models.ConsentSMS:   {"patient.phone", "cp.sms"},
+}
+
+// CampaignRepository stores bulk communication campaigns and the patients each is sent to
+type CampaignRepository struct{}
+
+func NewCampaignRepository() *CampaignRepository {
+	return &CampaignRepository{}
+}
+
+func (r *CampaignRepository) Create(ctx context.Context, campaign *models.Campaign) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(campaign).Error; err != nil {
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a campaign, or nil when there is none
+func (r *CampaignRepository) GetByID(ctx context.Context, id uint) (*models.Campaign, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var campaign models.Campaign
+	if err := database.DB.WithContext(ctx).First(&campaign, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+	return &campaign, nil
+}
+
+// List returns the campaigns in status, or all of them when status is empty, newest first
+func (r *CampaignRepository) List(ctx context.Context, status string) ([]models.Campaign, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var campaigns []models.Campaign
+	if err := db.Order("created_at DESC, id DESC").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+// Update changes a draft campaign, reporting false when it is no longer a draft
+func (r *CampaignRepository) Update(ctx context.Context, campaign *models.Campaign) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(campaign).Omit(clause.Associations).
+		Where("status = ?", models.CampaignDraft).
+		Select("name", "channel", "purpose", "subject", "body", "criteria", "saved_filter_id", "updated_at", "updated_by").
+		Updates(campaign)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update campaign: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *CampaignRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Campaign{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+	return nil
+}
+
+// Transition moves a campaign in one of the statuses from to the status and other columns given,
+// reporting false when it was in none of them
+func (r *CampaignRepository) Transition(ctx context.Context, id uint, from []string, columns map[string]interface{}) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.Campaign{ID: id}).Where("status IN ?", from).Updates(columns)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to change campaign status: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AudienceCounts counts the patients of the campaign's segment as it stands at now, those of them
+// with an address or number on its channel, and those whose preferences allow the campaign
+func (r *CampaignRepository) AudienceCounts(ctx context.Context, campaign *models.Campaign, now time.Time) (audience, reachable, permitted int64, err error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	segment, err := r.segment(db, campaign, now)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var counts struct {
+		Audience  int64
+		Reachable int64
+		Permitted int64
+	}
+	err = db.Table("(?) AS segment", segment).
+		Select("COUNT(*) AS audience, COUNT(*) FILTER (WHERE recipient <> '') AS reachable, COUNT(*) FILTER (WHERE recipient <> '' AND permitted) AS permitted").
+		Scan(&counts).Error
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count campaign audience: %w", err)
+	}
+	return counts.Audience, counts.Reachable, counts.Permitted, nil
+}
+
+// Audience returns up to limit patients of the campaign's segment the campaign would reach at now
+func (r *CampaignRepository) Audience(ctx context.Context, campaign *models.Campaign, now time.Time, limit int) ([]models.CampaignAudienceMember, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	segment, err := r.segment(db, campaign, now)
+	if err != nil {
+		return nil, err
+	}
+	var members []models.CampaignAudienceMember
+	err = db.Table("(?) AS segment", segment).
+		Select("patient_id, first_name, last_name, recipient").
+		Where("recipient <> '' AND permitted").
+		Limit(limit).Scan(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign audience: %w", err)
+	}
+	return members, nil
+}
+
+// Start moves the campaigns scheduled by now to sending and takes their recipients from their
+// segments, every patient with an address or number on the campaign's channel. Those whose
+// preferences do not allow the campaign are kept, so the report shows how many it could not reach.
+// Replicas starting at once each start different campaigns.
+func (r *CampaignRepository) Start(ctx context.Context, now time.Time) ([]models.Campaign, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var campaigns []models.Campaign
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND scheduled_at <= ?", models.CampaignScheduled, now).
+			Order("scheduled_at, id").
+			Find(&campaigns).Error
+		if err != nil {
+			return err
+		}
+		for i := range campaigns {
+			segment, err := r.segment(tx, &campaigns[i], now)
+			if err != nil {
+				return err
+			}
+			err = tx.Exec("INSERT INTO campaign_recipient (campaign_id, patient_id, recipient, status) SELECT CAST(? AS bigint), patient_id, recipient, ? FROM (?) AS segment WHERE recipient <> '' ON CONFLICT DO NOTHING",
+				campaigns[i].ID, models.CampaignRecipientPending, segment).Error
+			if err != nil {
+				return err
+			}
+			campaigns[i].Status, campaigns[i].StartedAt = models.CampaignSending, &now
+			err = tx.Model(&campaigns[i]).Omit(clause.Associations).Select("status", "started_at").Updates(&campaigns[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+// ClaimRecipients takes up to limit recipients of the campaigns sending off the queue and marks
+// them sending, with the names of the patients filled in. Replicas claiming at once each get
+// different recipients, and no patient is sent a campaign twice.
+func (r *CampaignRepository) ClaimRecipients(ctx context.Context, limit int) ([]models.CampaignRecipient, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var recipients []models.CampaignRecipient
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND campaign_id IN (SELECT id FROM campaign WHERE status = ?)", models.CampaignRecipientPending, models.CampaignSending).
+			Order("campaign_id, patient_id").
+			Limit(limit).
+			Find(&recipients).Error
+		if err != nil || len(recipients) == 0 {
+			return err
+		}
+		byCampaign := make(map[uint][]string)
+		for _, recipient := range recipients {
+			byCampaign[recipient.CampaignID] = append(byCampaign[recipient.CampaignID], recipient.PatientID)
+		}
+		for campaignID, patientIDs := range byCampaign {
+			err := tx.Model(&models.CampaignRecipient{}).
+				Where("campaign_id = ? AND patient_id IN ?", campaignID, patientIDs).
+				Update("status", models.CampaignRecipientSending).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim campaign recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return recipients, nil
+	}
+
+	patientIDs := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		patientIDs[i] = recipient.PatientID
+	}
+	var patients []models.Patient
+	if err := database.DB.WithContext(ctx).Select("id, first_name, last_name").Where("id IN ?", patientIDs).Find(&patients).Error; err != nil {
+		return nil, fmt.Errorf("failed to get campaign recipients' names: %w", err)
+	}
+	byID := make(map[string]*models.Patient, len(patients))
+	for i := range patients {
+		byID[patients[i].ID] = &patients[i]
+	}
+	for i := range recipients {
+		recipients[i].Patient = byID[recipients[i].PatientID]
+	}
+	return recipients, nil
+}
+
+// UpdateRecipient records how the message to a recipient went
+func (r *CampaignRepository) UpdateRecipient(ctx context.Context, recipient *models.CampaignRecipient) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND patient_id = ?", recipient.CampaignID, recipient.PatientID).
+		Updates(map[string]interface{}{
+			"status":     recipient.Status,
+			"error":      recipient.Error,
+			"message_id": recipient.MessageID,
+			"sent_at":    recipient.SentAt,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update campaign recipient: %w", err)
+	}
+	return nil
+}
+
+// Finish marks the campaigns sending that have no recipients left to claim as sent
+func (r *CampaignRepository) Finish(ctx context.Context, now time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.Campaign{}).
+		Where("status = ? AND NOT EXISTS (SELECT 1 FROM campaign_recipient r WHERE r.campaign_id = campaign.id AND r.status = ?)",
+			models.CampaignSending, models.CampaignRecipientPending).
+		Updates(map[string]interface{}{"status": models.CampaignSent, "finished_at": now}).Error
+	if err != nil {
+		return fmt.Errorf("failed to finish campaigns: %w", err)
+	}
+	return nil
+}
+
+// ListRecipients returns up to limit of the campaign's recipients, those in status when it is set
+func (r *CampaignRepository) ListRecipients(ctx context.Context, campaignID uint, status string, limit int) ([]models.CampaignRecipient, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx).Where("campaign_id = ?", campaignID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var recipients []models.CampaignRecipient
+	if err := db.Order("patient_id").Limit(limit).Find(&recipients).Error; err != nil {
+		return nil, fmt.Errorf("failed to list campaign recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// CountRecipients counts the campaign's recipients by status
+func (r *CampaignRepository) CountRecipients(ctx context.Context, campaignID uint) (map[string]int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := database.DB.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign recipients: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// CountDeliveries counts the messages logged for the record that the mail provider reported
+// bounced or complained about, by delivery
+func (r *CampaignRepository) CountDeliveries(ctx context.Context, record, recordID string) (map[string]int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		Delivery string
+		Count    int64
+	}
+	err := database.DB.WithContext(ctx).Model(&models.CommunicationLog{}).
+		Select("delivery, COUNT(*) AS count").
+		Where("record = ? AND record_id = ? AND delivery <> ''", record, recordID).
+		Group("delivery").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign deliveries: %w", err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Delivery] = row.Count
+	}
+	return counts, nil
+}
+
+// CountOptOuts counts the campaign's recipients who withdrew any of consents since
+func (r *CampaignRepository) CountOptOuts(ctx context.Context, campaignID uint, consents []string, since time.Time) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ?", campaignID).
+		Where("EXISTS (SELECT 1 FROM consent_record c WHERE c.patient_id = campaign_recipient.patient_id AND NOT c.granted AND c.consent IN ? AND c.recorded_at >= ?)", consents, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count campaign opt-outs: %w", err)
+	}
+	return count, nil
+}
+
+// segment selects the patients of the campaign's segment at now with the address or number the
+// campaign reaches them at, empty when they have none, and whether their preferences allow it.
+// Patients without preferences get messages about their care but no marketing.
+func (r *CampaignRepository) segment(db *gorm.DB, campaign *models.Campaign, now time.Time) (*gorm.DB, error) {
+	filter, err := listFilter(models.ListPatients, models.ListQuery{Criteria: campaign.Criteria}, now)
+	if err != nil {
+		return nil, err
+	}
+	channel, ok := campaignChannels[campaign.Channel]
+	if !ok {
+		return nil, fmt.Errorf("unknown campaign channel %q", campaign.Channel)
+	}
+	permitted := "COALESCE(" + channel.Preference + ", true)"
+	if campaign.Purpose == models.ConsentMarketing {
+		permitted += " AND COALESCE(cp.marketing, false)"
+	}
+	return filter(db.Model(&models.Patient{}).
+		Select("patient.id AS patient_id, patient.first_name, patient.last_name, COALESCE(" + channel.Recipient + ", '') AS recipient, (" + permitted + ") AS permitted").
+		Joins("LEFT JOIN communication_preference cp ON cp.patient_id = patient.id")), nil
+}
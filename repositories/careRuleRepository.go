@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CareRuleRepository stores the care pathway rules and the recalls they put patients on
+type CareRuleRepository struct{}
+
+func NewCareRuleRepository() *CareRuleRepository {
+	return &CareRuleRepository{}
+}
+
+func (r *CareRuleRepository) Create(ctx context.Context, rule *models.CareRule) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create care rule: %w", err)
+	}
+	return nil
+}
+
+func (r *CareRuleRepository) Get(ctx context.Context, id uint) (*models.CareRule, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rule models.CareRule
+	if err := database.DB.WithContext(ctx).First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get care rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// List returns every care rule by event and name
+func (r *CareRuleRepository) List(ctx context.Context) ([]models.CareRule, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rules []models.CareRule
+	if err := database.DB.WithContext(ctx).Order("event, name, id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list care rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Matching returns the active rules for event that apply to every procedure or to procedureID
+func (r *CareRuleRepository) Matching(ctx context.Context, event string, procedureID *uint) ([]models.CareRule, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Where("active AND event = ?", event)
+	if procedureID != nil {
+		query = query.Where("procedure_id IS NULL OR procedure_id = ?", *procedureID)
+	} else {
+		query = query.Where("procedure_id IS NULL")
+	}
+	var rules []models.CareRule
+	if err := query.Order("id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get care rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *CareRuleRepository) Update(ctx context.Context, rule *models.CareRule) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(rule).Select("name", "event", "procedure_id", "action", "active", "delay_days", "assignee_id", "title", "subject", "body", "updated_at").Updates(rule).Error
+	if err != nil {
+		return fmt.Errorf("failed to update care rule: %w", err)
+	}
+	return nil
+}
+
+func (r *CareRuleRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.CareRule{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete care rule: %w", err)
+	}
+	return nil
+}
+
+// Subject returns the patient and procedure of a bill or appointment, or nil when it is gone
+func (r *CareRuleRepository) Subject(ctx context.Context, record, id string) (*models.CareSubject, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var query *gorm.DB
+	switch record {
+	case "billing":
+		query = database.DB.WithContext(ctx).Table("billing s").
+			Select("s.patient_id, p.first_name AS patient_first_name, p.last_name AS patient_last_name, p.email AS patient_email, p.phone AS patient_phone, p.language AS patient_language, s.procedure_id, COALESCE(pr.name, s.procedure) AS procedure").
+			Where("s.billing_id = ?", id)
+	case "appointment":
+		query = database.DB.WithContext(ctx).Table("appointment s").
+			Select("s.patient_id, p.first_name AS patient_first_name, p.last_name AS patient_last_name, p.email AS patient_email, p.phone AS patient_phone, p.language AS patient_language, s.procedure_id, COALESCE(pr.name, '') AS procedure").
+			Where("s.id = ?", id)
+	default:
+		return nil, fmt.Errorf("care rules do not run on %s records", record)
+	}
+	var subject models.CareSubject
+	err := query.Joins("JOIN patient p ON p.id = s.patient_id").
+		Joins("LEFT JOIN procedure pr ON pr.id = s.procedure_id").
+		Limit(1).
+		Scan(&subject).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get care rule subject: %w", err)
+	}
+	if subject.PatientID == "" {
+		return nil, nil
+	}
+	return &subject, nil
+}
+
+func (r *CareRuleRepository) CreateRecall(ctx context.Context, recall *models.Recall) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(recall).Error; err != nil {
+		return fmt.Errorf("failed to create recall: %w", err)
+	}
+	return nil
+}
+
+// Recalls returns the recalls in status due by before, earliest first, with whom to call
+func (r *CareRuleRepository) Recalls(ctx context.Context, status string, before time.Time) ([]models.DueRecall, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var recalls []models.DueRecall
+	err := database.DB.WithContext(ctx).Table("recall r").
+		Select("r.*, p.first_name || ' ' || p.last_name AS patient_name, p.phone AS patient_phone").
+		Joins("JOIN patient p ON p.id = r.patient_id").
+		Where("r.status = ? AND r.due_date < ?", status, before.Format("2006-01-02")).
+		Order("r.due_date, r.id").
+		Scan(&recalls).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recalls: %w", err)
+	}
+	return recalls, nil
+}
+
+// SetRecallStatus moves a recall to status, returning false when there is no such recall
+func (r *CareRuleRepository) SetRecallStatus(ctx context.Context, id uint, status string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.Recall{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update recall: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
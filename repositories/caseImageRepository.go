@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// imageConsentColumns are the columns recording each use a pair may be shown for
+var imageConsentColumns = map[string]string{
+	models.ImageConsentMarketing:    "marketing_consent",
+	models.ImageConsentPresentation: "presentation_consent",
+	models.ImageConsentTeaching:     "teaching_consent",
+}
+
+// CaseImageRepository stores the before and after image pairs of treatment plans
+type CaseImageRepository struct{}
+
+func NewCaseImageRepository() *CaseImageRepository {
+	return &CaseImageRepository{}
+}
+
+func (r *CaseImageRepository) Create(ctx context.Context, pair *models.CaseImagePair) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(pair).Error; err != nil {
+		return fmt.Errorf("failed to create case image pair: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a pair of the patient's treatment plan, or nil when there is none
+func (r *CaseImageRepository) GetByID(ctx context.Context, patientID string, treatmentPlanID, id uint) (*models.CaseImagePair, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var pair models.CaseImagePair
+	err := database.DB.WithContext(ctx).
+		First(&pair, "patient_id = ? AND treatment_plan_id = ? AND id = ?", patientID, treatmentPlanID, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get case image pair: %w", err)
+	}
+	return &pair, nil
+}
+
+// List returns the pairs of the patient's treatment plan, oldest first
+func (r *CaseImageRepository) List(ctx context.Context, patientID string, treatmentPlanID uint) ([]models.CaseImagePair, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var pairs []models.CaseImagePair
+	err := database.DB.WithContext(ctx).Where("patient_id = ? AND treatment_plan_id = ?", patientID, treatmentPlanID).
+		Order("created_at, id").Find(&pairs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list case image pairs: %w", err)
+	}
+	return pairs, nil
+}
+
+// Update saves the treatment and images of a pair; its consent is changed with UpdateConsent
+func (r *CaseImageRepository) Update(ctx context.Context, pair *models.CaseImagePair) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(pair).
+		Select("item", "tooth", "before_attachment_id", "after_attachment_id", "notes", "updated_at", "updated_by").
+		Updates(pair).Error
+	if err != nil {
+		return fmt.Errorf("failed to update case image pair: %w", err)
+	}
+	return nil
+}
+
+// UpdateConsent saves what the patient agreed the pair may be used for, and who recorded it when
+func (r *CaseImageRepository) UpdateConsent(ctx context.Context, pair *models.CaseImagePair) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(pair).
+		Select("marketing_consent", "presentation_consent", "teaching_consent", "consent_recorded_at", "consent_recorded_by", "updated_at", "updated_by").
+		Updates(pair).Error
+	if err != nil {
+		return fmt.Errorf("failed to update case image consent: %w", err)
+	}
+	return nil
+}
+
+func (r *CaseImageRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.CaseImagePair{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete case image pair: %w", err)
+	}
+	return nil
+}
+
+// PatientAttachment returns an attachment filed with one of the patient's examinations, without
+// its content, or nil when the patient has none with the ID
+func (r *CaseImageRepository) PatientAttachment(ctx context.Context, patientID string, id uint) (*models.ExaminationAttachment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var attachment models.ExaminationAttachment
+	err := database.DB.WithContext(ctx).Table("examination_attachment AS a").
+		Select("a.id, a.examination_id, a.file_name, a.content_type, a.size, a.created_at").
+		Joins("JOIN examination e ON e.id = a.examination_id").
+		Where("a.id = ? AND e.patient_id = ?", id, patientID).
+		Take(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// Gallery returns the pairs consented to for the use, newest first, optionally only those whose
+// treatment contains search
+func (r *CaseImageRepository) Gallery(ctx context.Context, consent, search string, limit int) ([]models.GalleryCase, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	column, ok := imageConsentColumns[consent]
+	if !ok {
+		return nil, fmt.Errorf("unknown image consent %q", consent)
+	}
+	query := database.DB.WithContext(ctx).Model(&models.CaseImagePair{}).
+		Select("id, item, tooth, notes, marketing_consent, presentation_consent, teaching_consent, created_at").
+		Where(column)
+	if search != "" {
+		query = query.Where("item ILIKE ?", containsPattern(search))
+	}
+	var cases []models.GalleryCase
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Scan(&cases).Error; err != nil {
+		return nil, fmt.Errorf("failed to list the gallery: %w", err)
+	}
+	return cases, nil
+}
+
+// GalleryImage returns the before or after image of a pair with its content when the pair is
+// consented to for the use, or nil otherwise
+func (r *CaseImageRepository) GalleryImage(ctx context.Context, id uint, consent string, after bool) (*models.ExaminationAttachment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	consentColumn, ok := imageConsentColumns[consent]
+	if !ok {
+		return nil, fmt.Errorf("unknown image consent %q", consent)
+	}
+	column := "p.before_attachment_id"
+	if after {
+		column = "p.after_attachment_id"
+	}
+	var attachment models.ExaminationAttachment
+	err := database.DB.WithContext(ctx).Table("examination_attachment AS a").
+		Select("a.*").
+		Joins("JOIN case_image_pair p ON "+column+" = a.id").
+		Where("p.id = ? AND p."+consentColumn, id).
+		Take(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get gallery image: %w", err)
+	}
+	return &attachment, nil
+}
+
+// AttachmentExaminations returns the examinations the attachments are filed with, without their
+// attachments, oldest first
+func (r *CaseImageRepository) AttachmentExaminations(ctx context.Context, attachmentIDs []uint) ([]models.Examination, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	var examinations []models.Examination
+	err := db.Where("id IN (?)", db.Table("examination_attachment").Select("examination_id").Where("id IN ?", attachmentIDs)).
+		Order("created_at, id").Find(&examinations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the examinations of the images: %w", err)
+	}
+	return examinations, nil
+}
@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChairRepository stores treatment rooms and their chairs
+type ChairRepository struct{}
+
+func NewChairRepository() *ChairRepository {
+	return &ChairRepository{}
+}
+
+func (r *ChairRepository) CreateRoom(ctx context.Context, room *models.Room) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Chairs").Create(room).Error; err != nil {
+		return fmt.Errorf("failed to create room: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) GetRoom(ctx context.Context, id uint) (*models.Room, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var room models.Room
+	if err := database.DB.WithContext(ctx).Preload("Chairs", orderChairs).First(&room, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	return &room, nil
+}
+
+// ListRooms returns every room with its chairs
+func (r *ChairRepository) ListRooms(ctx context.Context) ([]models.Room, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rooms []models.Room
+	if err := database.DB.WithContext(ctx).Preload("Chairs", orderChairs).Order("name").Find(&rooms).Error; err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	return rooms, nil
+}
+
+func (r *ChairRepository) UpdateRoom(ctx context.Context, room *models.Room) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(room).Select("name", "notes", "updated_at").Updates(room).Error; err != nil {
+		return fmt.Errorf("failed to update room: %w", err)
+	}
+	return nil
+}
+
+// DeleteRoom removes a room and its chairs; appointments keep the number of the chair they had
+func (r *ChairRepository) DeleteRoom(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Room{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) CreateChair(ctx context.Context, chair *models.Chair) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(chair).Error; err != nil {
+		return fmt.Errorf("failed to create chair: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) GetChair(ctx context.Context, id uint) (*models.Chair, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var chair models.Chair
+	if err := database.DB.WithContext(ctx).First(&chair, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get chair: %w", err)
+	}
+	return &chair, nil
+}
+
+// ListChairs returns the chairs of every room, only the active ones when activeOnly is set
+func (r *ChairRepository) ListChairs(ctx context.Context, activeOnly bool) ([]models.Chair, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if activeOnly {
+		query = query.Where("active")
+	}
+	var chairs []models.Chair
+	if err := orderChairs(query).Find(&chairs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list chairs: %w", err)
+	}
+	return chairs, nil
+}
+
+func (r *ChairRepository) UpdateChair(ctx context.Context, chair *models.Chair) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(chair).Select("room_id", "name", "active", "updated_at").Updates(chair).Error; err != nil {
+		return fmt.Errorf("failed to update chair: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) DeleteChair(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Chair{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete chair: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) CreateDowntime(ctx context.Context, downtime *models.Downtime) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Chair").Create(downtime).Error; err != nil {
+		return fmt.Errorf("failed to create downtime: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) GetDowntime(ctx context.Context, id uint) (*models.Downtime, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var downtime models.Downtime
+	if err := database.DB.WithContext(ctx).First(&downtime, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get downtime: %w", err)
+	}
+	return &downtime, nil
+}
+
+// ListDowntime returns the downtime matching filter, latest first. Ongoing downtime overlaps any
+// period after it started.
+func (r *ChairRepository) ListDowntime(ctx context.Context, filter models.DowntimeFilter) ([]models.Downtime, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if filter.ChairID != nil {
+		query = query.Where("chair_id = ?", *filter.ChairID)
+	}
+	if filter.Equipment != "" {
+		query = query.Where("LOWER(equipment) = LOWER(?)", filter.Equipment)
+	}
+	if filter.To != nil {
+		query = query.Where("starts_at < ?", *filter.To)
+	}
+	if filter.From != nil {
+		query = query.Where("ends_at IS NULL OR ends_at > ?", *filter.From)
+	}
+	var downtime []models.Downtime
+	if err := query.Order("starts_at DESC, id DESC").Find(&downtime).Error; err != nil {
+		return nil, fmt.Errorf("failed to list downtime: %w", err)
+	}
+	return downtime, nil
+}
+
+func (r *ChairRepository) UpdateDowntime(ctx context.Context, downtime *models.Downtime) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(downtime).
+		Select("chair_id", "equipment", "reason", "starts_at", "ends_at", "notes", "updated_at").
+		Updates(downtime).Error
+	if err != nil {
+		return fmt.Errorf("failed to update downtime: %w", err)
+	}
+	return nil
+}
+
+func (r *ChairRepository) DeleteDowntime(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Downtime{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete downtime: %w", err)
+	}
+	return nil
+}
+
+// BookedDuring returns the non-cancelled appointments on the chair overlapping downtime, which
+// need moving to another chair or time
+func (r *ChairRepository) BookedDuring(ctx context.Context, downtime *models.Downtime) ([]uint, error) {
+	if downtime.ChairID == nil {
+		return nil, nil
+	}
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.Appointment{}).
+		Where("chair_id = ? AND status <> ? AND ends_at > ?", *downtime.ChairID, models.AppointmentStatusCancelled, downtime.StartsAt)
+	if downtime.EndsAt != nil {
+		query = query.Where("starts_at < ?", *downtime.EndsAt)
+	}
+	var ids []uint
+	if err := query.Order("starts_at").Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get appointments booked during downtime: %w", err)
+	}
+	return ids, nil
+}
+
+// downChairs returns the chairs out of service for any of start to end
+func downChairs(tx *gorm.DB, start, end time.Time) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&models.Downtime{}).
+		Where("chair_id IS NOT NULL AND starts_at < ? AND (ends_at IS NULL OR ends_at > ?)", end, start).
+		Distinct().Pluck("chair_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chair downtime: %w", err)
+	}
+	return ids, nil
+}
+
+func orderChairs(db *gorm.DB) *gorm.DB {
+	return db.Order("room_id, name")
+}
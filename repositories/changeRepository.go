@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChangeRepository lists record changes for incremental sync clients.
+type ChangeRepository struct{}
+
+func NewChangeRepository() *ChangeRepository {
+	return &ChangeRepository{}
+}
+
+// List returns up to limit changes of entity after since, oldest first. Rows are ordered by
+// timestamp then ID so that paging is stable.
+func (r *ChangeRepository) List(ctx context.Context, entity string, since time.Time, limit int) ([]models.ChangeRecord, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	source, ok := database.ChangeSources[entity]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity %q", entity)
+	}
+	action := changeAction(source)
+	query := fmt.Sprintf(`SELECT %s::text AS id, %s AS action, updated_at AS changed_at FROM %q WHERE updated_at > @since
+		UNION ALL
+		SELECT record_id AS id, '%s' AS action, deleted_at AS changed_at FROM deleted_record WHERE entity = @entity AND deleted_at > @since
+		ORDER BY changed_at, id
+		LIMIT @limit`, source.IDColumn, action, source.Table, models.ChangeDeleted)
+
+	var changes []models.ChangeRecord
+	err := database.DB.WithContext(ctx).Raw(query, map[string]interface{}{
+		"since":  since,
+		"entity": source.Table,
+		"limit":  limit,
+	}).Scan(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s changes: %w", entity, err)
+	}
+	return changes, nil
+}
+
+// Records returns up to limit changes of entity after since as List does, with the sync version of
+// the records still there and their data as the API returns them
+func (r *ChangeRepository) Records(ctx context.Context, entity string, since time.Time, limit int) ([]models.SyncChange, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	source, ok := database.ChangeSources[entity]
+	load, loadable := syncLoaders[entity]
+	if !ok || !loadable {
+		return nil, fmt.Errorf("unknown entity %q", entity)
+	}
+	query := fmt.Sprintf(`SELECT %s::text AS id, %s AS action, updated_at AS changed_at, sync_version AS version FROM %q WHERE updated_at > @since
+		UNION ALL
+		SELECT record_id AS id, '%s' AS action, deleted_at AS changed_at, 0 AS version FROM deleted_record WHERE entity = @entity AND deleted_at > @since
+		ORDER BY changed_at, id
+		LIMIT @limit`, source.IDColumn, changeAction(source), source.Table, models.ChangeDeleted)
+
+	db := database.DB.WithContext(ctx)
+	var changes []models.SyncChange
+	err := db.Raw(query, map[string]interface{}{
+		"since":  since,
+		"entity": source.Table,
+		"limit":  limit,
+	}).Scan(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s changes: %w", entity, err)
+	}
+
+	var ids []string
+	for _, change := range changes {
+		if change.Action != models.ChangeDeleted {
+			ids = append(ids, change.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return changes, nil
+	}
+	data, err := load(db, source, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s records: %w", entity, err)
+	}
+	for i := range changes {
+		changes[i].Data = data[changes[i].ID]
+	}
+	return changes, nil
+}
+
+// Record returns the sync version of a record of entity and its data as the API returns it, or nil
+// when there is none
+func (r *ChangeRepository) Record(ctx context.Context, entity, id string) (*models.SyncChange, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	source, ok := database.ChangeSources[entity]
+	load, loadable := syncLoaders[entity]
+	if !ok || !loadable {
+		return nil, fmt.Errorf("unknown entity %q", entity)
+	}
+	keys, valid := recordKeys(source, []string{id})
+	if !valid {
+		return nil, nil
+	}
+	db := database.DB.WithContext(ctx)
+	var versions []models.SyncChange
+	query := fmt.Sprintf(`SELECT %s::text AS id, '%s' AS action, updated_at AS changed_at, sync_version AS version FROM %q WHERE %s IN ?`,
+		source.IDColumn, models.ChangeUpdated, source.Table, source.IDColumn)
+	if err := db.Raw(query, keys).Scan(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get %s version: %w", entity, err)
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	data, err := load(db, source, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s record: %w", entity, err)
+	}
+	record := versions[0]
+	record.Data = data[id]
+	return &record, nil
+}
+
+// recordKeys returns the IDs to look records of source up by, as numbers when its IDs are numeric so
+// the primary key's index is used, or false when none is valid
+func recordKeys(source database.ChangeSource, ids []string) (interface{}, bool) {
+	if !source.NumericID {
+		return ids, len(ids) > 0
+	}
+	numbers := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if number, err := strconv.ParseUint(id, 10, 64); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers, len(numbers) > 0
+}
+
+// changeAction is the SQL telling created records of source from updated ones, after @since
+func changeAction(source database.ChangeSource) string {
+	if source.HasCreatedAt {
+		return fmt.Sprintf("CASE WHEN created_at > @since THEN '%s' ELSE '%s' END", models.ChangeCreated, models.ChangeUpdated)
+	}
+	return fmt.Sprintf("'%s'", models.ChangeUpdated)
+}
+
+// syncLoader reads the records of an entity with the IDs given, as JSON by ID
+type syncLoader func(db *gorm.DB, source database.ChangeSource, ids []string) (map[string]json.RawMessage, error)
+
+// syncLoaders read the records of each entity sync clients mirror
+var syncLoaders = map[string]syncLoader{
+	"doctors":             loadRecords(func(r models.Doctor) string { return r.ID }),
+	"patients":            loadRecords(func(r models.Patient) string { return r.ID }),
+	"emergency_contacts":  loadRecords(func(r models.EmergencyContact) string { return strconv.FormatUint(uint64(r.ID), 10) }),
+	"insurance_companies": loadRecords(func(r models.InsuranceCompany) string { return r.ID }),
+	"examinations":        loadRecords(func(r models.Examination) string { return strconv.FormatUint(uint64(r.ID), 10) }),
+	"billings":            loadRecords(func(r models.Billing) string { return r.BillingID }),
+	"treatment_plans":     loadRecords(func(r models.TreatmentPlan) string { return strconv.FormatUint(uint64(r.ID), 10) }),
+	"appointments":        loadRecords(func(r models.Appointment) string { return strconv.FormatUint(uint64(r.ID), 10) }),
+}
+
+// loadRecords returns a loader of records of model T, keyed by the ID id returns
+func loadRecords[T any](id func(T) string) syncLoader {
+	return func(db *gorm.DB, source database.ChangeSource, ids []string) (map[string]json.RawMessage, error) {
+		keys, ok := recordKeys(source, ids)
+		if !ok {
+			return map[string]json.RawMessage{}, nil
+		}
+		var records []T
+		if err := db.Where(source.IDColumn+" IN ?", keys).Find(&records).Error; err != nil {
+			return nil, err
+		}
+		data := make(map[string]json.RawMessage, len(records))
+		for _, record := range records {
+			encoded, err := json.Marshal(record)
+			if err != nil {
+				return nil, err
+			}
+			data[id(record)] = encoded
+		}
+		return data, nil
+	}
+}
@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrClaimsChanged is returned when claims picked for a batch were changed before it was saved
+var ErrClaimsChanged = errors.New("claims changed while the batch was being prepared")
+
+// ClaimRepository stores insurance claims and the batches they are submitted in
+type ClaimRepository struct{}
+
+func NewClaimRepository() *ClaimRepository {
+	return &ClaimRepository{}
+}
+
+func (r *ClaimRepository) Create(ctx context.Context, claim *models.InsuranceClaim) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(claim).Error; err != nil {
+		return fmt.Errorf("failed to create insurance claim: %w", err)
+	}
+	return nil
+}
+
+func (r *ClaimRepository) GetByID(ctx context.Context, id uint) (*models.InsuranceClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var claim models.InsuranceClaim
+	if err := database.DB.WithContext(ctx).First(&claim, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get insurance claim: %w", err)
+	}
+	return &claim, nil
+}
+
+func (r *ClaimRepository) GetByBillingID(ctx context.Context, billingID string) (*models.InsuranceClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var claim models.InsuranceClaim
+	if err := database.DB.WithContext(ctx).First(&claim, "billing_id = ?", billingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get insurance claim: %w", err)
+	}
+	return &claim, nil
+}
+
+// InsurerForPatient returns the insurer of an insured patient, whose insurance company may be
+// recorded by ID or by name, or nil if the patient has none on file
+func (r *ClaimRepository) InsurerForPatient(ctx context.Context, patientID string) (*models.InsuranceCompany, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var company models.InsuranceCompany
+	err := database.DB.WithContext(ctx).Table("insurance_company ic").
+		Select("ic.id, ic.name, ic.claim_format, ic.updated_at").
+		Joins("JOIN patient p ON p.insured AND (p.insurance_company = ic.id OR LOWER(p.insurance_company) = LOWER(ic.name))").
+		Where("p.id = ?", patientID).
+		Limit(1).
+		Scan(&company).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the patient's insurer: %w", err)
+	}
+	if company.ID == "" {
+		return nil, nil
+	}
+	return &company, nil
+}
+
+// List returns claims matching filter, newest first
+func (r *ClaimRepository) List(ctx context.Context, filter models.ClaimFilter) ([]models.InsuranceClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.InsuranceClaim{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.InsuranceCompanyID != "" {
+		query = query.Where("insurance_company_id = ?", filter.InsuranceCompanyID)
+	}
+	var claims []models.InsuranceClaim
+	if err := query.Order("created_at DESC, id DESC").Find(&claims).Error; err != nil {
+		return nil, fmt.Errorf("failed to list insurance claims: %w", err)
+	}
+	return claims, nil
+}
+
+// Approve marks a pending claim approved, reporting false when it was no longer pending
+func (r *ClaimRepository) Approve(ctx context.Context, id uint, at time.Time) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.InsuranceClaim{ID: id}).
+		Where("status = ?", models.ClaimStatusPending).
+		Updates(map[string]interface{}{"status": models.ClaimStatusApproved, "approved_at": at})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to approve insurance claim: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ApprovedLines returns the insurer's approved claims for services between from and to (exclusive)
+// with the patient and procedure details the submission file needs
+func (r *ClaimRepository) ApprovedLines(ctx context.Context, insuranceCompanyID string, from, to time.Time) ([]models.ClaimLine, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var lines []models.ClaimLine
+	err := database.DB.WithContext(ctx).Table("insurance_claim c").
+		Select("c.id AS claim_id, c.billing_id, c.patient_id, CONCAT_WS(' ', p.first_name, NULLIF(p.middle_name, ''), p.last_name) AS patient_name, p.date_of_birth, p.scheme, b.doctor_id, b.procedure, c.service_date, c.amount").
+		Joins("JOIN patient p ON p.id = c.patient_id").
+		Joins("JOIN billing b ON b.billing_id = c.billing_id").
+		Where("c.insurance_company_id = ? AND c.status = ? AND c.service_date >= ? AND c.service_date < ?",
+			insuranceCompanyID, models.ClaimStatusApproved, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("c.service_date, c.id").
+		Scan(&lines).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approved claims: %w", err)
+	}
+	return lines, nil
+}
+
+// CreateBatch numbers the batch after the insurer's others that day, lets prepare fill in its reference
+// and file, saves it and moves its claims to submitted. It fails with ErrClaimsChanged if any of them
+// stopped being approved in the meantime.
+func (r *ClaimRepository) CreateBatch(ctx context.Context, batch *models.ClaimBatch, claimIDs []uint, prepare func(seq int64) error) error {
+	return database.WithLock(ctx, "claim_batch_lock:"+batch.InsuranceCompanyID, func(tx *gorm.DB) error {
+		day := batch.SubmittedAt.Format("2006-01-02")
+		var count int64
+		if err := tx.Model(&models.ClaimBatch{}).
+			Where("insurance_company_id = ? AND submitted_at::date = ?", batch.InsuranceCompanyID, day).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count claim batches: %w", err)
+		}
+		if err := prepare(count + 1); err != nil {
+			return err
+		}
+		if err := tx.Create(batch).Error; err != nil {
+			return fmt.Errorf("failed to create claim batch: %w", err)
+		}
+
+		result := tx.Model(&models.InsuranceClaim{}).
+			Where("id IN ? AND status = ?", claimIDs, models.ClaimStatusApproved).
+			Updates(map[string]interface{}{"status": models.ClaimStatusSubmitted, "batch_id": batch.ID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to submit claims: %w", result.Error)
+		}
+		if result.RowsAffected != int64(len(claimIDs)) {
+			return ErrClaimsChanged
+		}
+		return nil
+	})
+}
+
+func (r *ClaimRepository) GetBatch(ctx context.Context, id uint) (*models.ClaimBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batch models.ClaimBatch
+	if err := database.DB.WithContext(ctx).First(&batch, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get claim batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// ListBatches returns the insurer's batches, newest first, without their files
+func (r *ClaimRepository) ListBatches(ctx context.Context, insuranceCompanyID string) ([]models.ClaimBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batches []models.ClaimBatch
+	err := database.DB.WithContext(ctx).Omit("content").
+		Where("insurance_company_id = ?", insuranceCompanyID).
+		Order("submitted_at DESC, id DESC").
+		Find(&batches).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claim batches: %w", err)
+	}
+	return batches, nil
+}
+
+// ListBatchClaims returns the claims submitted in a batch
+func (r *ClaimRepository) ListBatchClaims(ctx context.Context, batchID uint) ([]models.InsuranceClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var claims []models.InsuranceClaim
+	if err := database.DB.WithContext(ctx).Where("batch_id = ?", batchID).Order("service_date, id").Find(&claims).Error; err != nil {
+		return nil, fmt.Errorf("failed to list batch claims: %w", err)
+	}
+	return claims, nil
+}
+
+// UpdateBatchStatus moves a batch from one status to the next, reporting false when it was no longer
+// in the expected status. Rejected batches hand their claims back as approved so they can be resubmitted;
+// settled batches mark them paid.
+func (r *ClaimRepository) UpdateBatchStatus(ctx context.Context, batch *models.ClaimBatch, from string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	updated := false
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(batch).Where("status = ?", from).
+			Select("status", "note", "acknowledged_at", "closed_at").
+			Updates(batch)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update claim batch: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+
+		claims := tx.Model(&models.InsuranceClaim{}).Where("batch_id = ?", batch.ID)
+		switch batch.Status {
+		case models.ClaimBatchStatusRejected:
+			claims = claims.Updates(map[string]interface{}{"status": models.ClaimStatusApproved, "batch_id": nil})
+		case models.ClaimBatchStatusSettled:
+			claims = claims.Update("status", models.ClaimStatusPaid)
+		default:
+			return nil
+		}
+		if claims.Error != nil {
+			return fmt.Errorf("failed to update batch claims: %w", claims.Error)
+		}
+		return nil
+	})
+	return updated, err
+}
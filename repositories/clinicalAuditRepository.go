@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClinicalAuditRepository stores the samples of visits drawn for clinical audits and their scores
+type ClinicalAuditRepository struct{}
+
+func NewClinicalAuditRepository() *ClinicalAuditRepository {
+	return &ClinicalAuditRepository{}
+}
+
+// Create stores the audit with a random sample of the visits fulfilled from from until before to
+// that no audit sampled yet, and returns how many were sampled. Nothing is stored when there is
+// no visit to sample.
+func (r *ClinicalAuditRepository) Create(ctx context.Context, audit *models.ClinicalAudit, from, to time.Time) (int64, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var sampled int64
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(audit).Error; err != nil {
+			return fmt.Errorf("failed to create clinical audit: %w", err)
+		}
+		visits := tx.Table("appointment AS a").
+			Select("CAST(? AS bigint), a.id, a.patient_id, a.doctor_id, a.starts_at", audit.ID).
+			Where("a.status = ? AND a.starts_at >= ? AND a.starts_at < ?", models.AppointmentStatusFulfilled, from, to).
+			Where("NOT EXISTS (SELECT 1 FROM clinical_audit_item i WHERE i.appointment_id = a.id)")
+		if audit.DoctorID != nil {
+			visits = visits.Where("a.doctor_id = ?", *audit.DoctorID)
+		}
+		result := tx.Exec("INSERT INTO clinical_audit_item (audit_id, appointment_id, patient_id, doctor_id, visited_at) ?",
+			visits.Order("random()").Limit(audit.SampleSize))
+		if result.Error != nil {
+			return fmt.Errorf("failed to sample visits: %w", result.Error)
+		}
+		sampled = result.RowsAffected
+		if sampled == 0 {
+			return errNothingSampled
+		}
+		return nil
+	})
+	if errors.Is(err, errNothingSampled) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return sampled, nil
+}
+
+// errNothingSampled rolls back an audit no visit was sampled for
+var errNothingSampled = errors.New("no visit sampled")
+
+// GetByID returns an audit with its sampled visits in the order they took place, or nil when there
+// is none
+func (r *ClinicalAuditRepository) GetByID(ctx context.Context, id uint) (*models.ClinicalAudit, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var audit models.ClinicalAudit
+	err := database.DB.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("visited_at, id")
+		}).
+		First(&audit, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get clinical audit: %w", err)
+	}
+	return &audit, nil
+}
+
+// List returns the audits newest first, without their visits
+func (r *ClinicalAuditRepository) List(ctx context.Context) ([]models.ClinicalAudit, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var audits []models.ClinicalAudit
+	if err := database.DB.WithContext(ctx).Order("created_at DESC, id DESC").Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to list clinical audits: %w", err)
+	}
+	return audits, nil
+}
+
+// Delete removes an audit and its scores, leaving its visits free to be sampled again, and
+// reports whether there was one
+func (r *ClinicalAuditRepository) Delete(ctx context.Context, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Delete(&models.ClinicalAudit{}, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete clinical audit: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetItem returns a visit sampled for the audit, or nil when the audit did not sample it
+func (r *ClinicalAuditRepository) GetItem(ctx context.Context, auditID, id uint) (*models.ClinicalAuditItem, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var item models.ClinicalAuditItem
+	if err := database.DB.WithContext(ctx).First(&item, "audit_id = ? AND id = ?", auditID, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get clinical audit item: %w", err)
+	}
+	return &item, nil
+}
+
+// Score stores the auditor's score and comments of a sampled visit
+func (r *ClinicalAuditRepository) Score(ctx context.Context, item *models.ClinicalAuditItem) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(item).Omit(clause.Associations).
+		Select("score", "comments", "scored_by", "scored_at").Updates(item).Error
+	if err != nil {
+		return fmt.Errorf("failed to score clinical audit item: %w", err)
+	}
+	return nil
+}
+
+// VisitDetails returns the patient's and doctor's names and the type of each sampled visit, by
+// appointment ID
+func (r *ClinicalAuditRepository) VisitDetails(ctx context.Context, auditID uint) (map[uint]models.ClinicalAuditVisit, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		AppointmentID uint
+		PatientName   string
+		DoctorName    string
+		Type          string
+	}
+	err := database.DB.WithContext(ctx).Table("clinical_audit_item AS i").
+		Select("i.appointment_id, p.first_name || ' ' || p.last_name AS patient_name, d.first_name || ' ' || d.last_name AS doctor_name, a.type").
+		Joins("JOIN appointment a ON a.id = i.appointment_id").
+		Joins("JOIN patient p ON p.id = i.patient_id").
+		Joins("JOIN doctor d ON d.id = i.doctor_id").
+		Where("i.audit_id = ?", auditID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clinical audit visits: %w", err)
+	}
+	details := make(map[uint]models.ClinicalAuditVisit, len(rows))
+	for _, row := range rows {
+		details[row.AppointmentID] = models.ClinicalAuditVisit{PatientName: row.PatientName, DoctorName: row.DoctorName, Type: row.Type}
+	}
+	return details, nil
+}
+
+// VisitRecords fills in the records of the patient an auditor reviews a visit on the clinic day
+// from from until before to with: the examinations and bills of the day, and the treatment plans
+// made by its end
+func (r *ClinicalAuditRepository) VisitRecords(ctx context.Context, visit *models.ClinicalAuditVisit, from, to time.Time) error {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	patientID := visit.Item.PatientID
+	err := db.Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+			return db.Select(attachmentColumns)
+		}).
+		Where("patient_id = ? AND created_at >= ? AND created_at < ?", patientID, from, to).
+		Order("created_at").Find(&visit.Examinations).Error
+	if err != nil {
+		return fmt.Errorf("failed to get visit examinations: %w", err)
+	}
+	if err := db.Where("patient_id = ? AND created_at < ?", patientID, to).Order("created_at").Find(&visit.TreatmentPlans).Error; err != nil {
+		return fmt.Errorf("failed to get visit treatment plans: %w", err)
+	}
+	if err := db.Where("patient_id = ? AND created_at >= ? AND created_at < ?", patientID, from, to).Order("created_at").Find(&visit.Billings).Error; err != nil {
+		return fmt.Errorf("failed to get visit billings: %w", err)
+	}
+	return nil
+}
+
+// Trends returns how the visits of each month from from until before to scored, of the doctor's
+// visits when doctorID is set, oldest month first
+func (r *ClinicalAuditRepository) Trends(ctx context.Context, from, to time.Time, doctorID string) ([]models.ClinicalAuditTrend, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	month := gorm.Expr("to_char(visited_at AT TIME ZONE ?, 'YYYY-MM')", models.ClinicLocation().String())
+	query := database.DB.WithContext(ctx).Model(&models.ClinicalAuditItem{}).
+		Select("? AS month, COUNT(*) AS scored, AVG(score) AS average_score, COUNT(*) FILTER (WHERE score <= ?) AS below_standard", month, models.ClinicalAuditBelowStandard).
+		Where("score IS NOT NULL AND visited_at >= ? AND visited_at < ?", from, to)
+	if doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	var trends []models.ClinicalAuditTrend
+	if err := query.Group("month").Order("month").Scan(&trends).Error; err != nil {
+		return nil, fmt.Errorf("failed to get clinical audit trends: %w", err)
+	}
+	return trends, nil
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ClinicalTemplateRepository stores examination and treatment plan templates
+type ClinicalTemplateRepository struct{}
+
+func NewClinicalTemplateRepository() *ClinicalTemplateRepository {
+	return &ClinicalTemplateRepository{}
+}
+
+func (r *ClinicalTemplateRepository) Create(ctx context.Context, template *models.ClinicalTemplate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Owner").Create(template).Error; err != nil {
+		return fmt.Errorf("failed to create template: %w", err)
+	}
+	return nil
+}
+
+func (r *ClinicalTemplateRepository) GetByID(ctx context.Context, id uint) (*models.ClinicalTemplate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var template models.ClinicalTemplate
+	if err := database.DB.WithContext(ctx).First(&template, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	return &template, nil
+}
+
+// List returns the templates matching filter by kind and name
+func (r *ClinicalTemplateRepository) List(ctx context.Context, filter models.TemplateFilter) ([]models.ClinicalTemplate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.ClinicalTemplate{})
+	if filter.ViewerID != 0 {
+		query = query.Where("owner_id = ? OR sharing = ?", filter.ViewerID, models.TemplateShared)
+	}
+	if filter.OwnerID != 0 {
+		query = query.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Sharing != "" {
+		query = query.Where("sharing = ?", filter.Sharing)
+	}
+
+	var templates []models.ClinicalTemplate
+	if err := query.Order("kind, name, id").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return templates, nil
+}
+
+// Update saves the content, sharing state and review of a template
+func (r *ClinicalTemplateRepository) Update(ctx context.Context, template *models.ClinicalTemplate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("clinical_template_lock:%d", template.ID), func(tx *gorm.DB) error {
+		result := tx.Model(template).
+			Select("kind", "name", "body", "sharing", "reviewed_by", "reviewed_at", "review_note", "updated_at").
+			Updates(template)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update template: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("template not found")
+		}
+		return nil
+	})
+}
+
+func (r *ClinicalTemplateRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.ClinicalTemplate{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	return nil
+}
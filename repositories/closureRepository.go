@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClosureRepository stores the days the clinic is closed
+type ClosureRepository struct{}
+
+func NewClosureRepository() *ClosureRepository {
+	return &ClosureRepository{}
+}
+
+func (r *ClosureRepository) Create(ctx context.Context, closure *models.Closure) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(closure).Error; err != nil {
+		return fmt.Errorf("failed to create closure: %w", err)
+	}
+	return nil
+}
+
+func (r *ClosureRepository) Get(ctx context.Context, id uint) (*models.Closure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var closure models.Closure
+	if err := database.DB.WithContext(ctx).First(&closure, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get closure: %w", err)
+	}
+	return &closure, nil
+}
+
+// List returns the closures overlapping the days from through to (YYYY-MM-DD), earliest first.
+// Either bound may be empty.
+func (r *ClosureRepository) List(ctx context.Context, from, to string) ([]models.Closure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Order("start_date, id")
+	if from != "" {
+		query = query.Where("end_date >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("start_date <= ?", to)
+	}
+	var closures []models.Closure
+	if err := query.Find(&closures).Error; err != nil {
+		return nil, fmt.Errorf("failed to list closures: %w", err)
+	}
+	return closures, nil
+}
+
+// On returns the closure covering day (YYYY-MM-DD), or nil when the clinic is open
+func (r *ClosureRepository) On(ctx context.Context, day string) (*models.Closure, error) {
+	closures, err := r.List(ctx, day, day)
+	if err != nil {
+		return nil, err
+	}
+	if len(closures) == 0 {
+		return nil, nil
+	}
+	return &closures[0], nil
+}
+
+func (r *ClosureRepository) Update(ctx context.Context, closure *models.Closure) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(closure).Select("start_date", "end_date", "kind", "reason", "updated_at").Updates(closure).Error
+	if err != nil {
+		return fmt.Errorf("failed to update closure: %w", err)
+	}
+	return nil
+}
+
+func (r *ClosureRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Closure{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete closure: %w", err)
+	}
+	return nil
+}
+
+// AppointmentsBetween returns the appointments that have not been cancelled or completed starting
+// from from until before to, earliest first
+func (r *ClosureRepository) AppointmentsBetween(ctx context.Context, from, to time.Time) ([]models.ClosedAppointment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var appointments []models.ClosedAppointment
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id AS appointment_id, a.patient_id, p.first_name || ' ' || p.last_name AS patient_name, p.phone AS patient_phone, a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, a.status").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.starts_at >= ? AND a.starts_at < ? AND a.status NOT IN ?", from, to,
+			[]string{models.AppointmentStatusCancelled, models.AppointmentStatusFulfilled}).
+		Order("a.starts_at").
+		Scan(&appointments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointments on closure: %w", err)
+	}
+	for i := range appointments {
+		appointments[i].DateTime = models.ClinicDateTime(appointments[i].DateTime)
+	}
+	return appointments, nil
+}
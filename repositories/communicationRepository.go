@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CommunicationRepository stores patients' communication preferences and the consents behind them
+type CommunicationRepository struct{}
+
+func NewCommunicationRepository() *CommunicationRepository {
+	return &CommunicationRepository{}
+}
+
+// GetPreference returns the preferences of a patient, or nil when they never stated any
+func (r *CommunicationRepository) GetPreference(ctx context.Context, patientID string) (*models.CommunicationPreference, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var preference models.CommunicationPreference
+	if err := database.DB.WithContext(ctx).First(&preference, "patient_id = ?", patientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get communication preference: %w", err)
+	}
+	return &preference, nil
+}
+
+// SavePreference applies update to the patient's preferences, starting from the defaults when they
+// have none, and records the consents it changes. The saved preferences are returned.
+func (r *CommunicationRepository) SavePreference(ctx context.Context, patientID string, update models.CommunicationPreferenceUpdate, source, givenBy string) (*models.CommunicationPreference, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var preference models.CommunicationPreference
+	err := database.WithLock(ctx, fmt.Sprintf("communication_preference_lock:%s", patientID), func(tx *gorm.DB) error {
+		if err := tx.First(&preference, "patient_id = ?", patientID).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to get communication preference: %w", err)
+			}
+			preference = models.DefaultCommunicationPreference(patientID)
+		}
+
+		now := time.Now()
+		var records []models.ConsentRecord
+		apply := func(consent string, current *bool, requested *bool) {
+			if requested == nil || *requested == *current {
+				return
+			}
+			*current = *requested
+			records = append(records, models.ConsentRecord{PatientID: patientID, Consent: consent, Granted: *requested, Source: source, GivenBy: givenBy, RecordedAt: now})
+		}
+		apply(models.ConsentEmail, &preference.Email, update.Email)
+		apply(models.ConsentSMS, &preference.SMS, update.SMS)
+		apply(models.ConsentReminders, &preference.Reminders, update.Reminders)
+		apply(models.ConsentMarketing, &preference.Marketing, update.Marketing)
+		if len(records) == 0 {
+			return nil
+		}
+		if update.Marketing != nil {
+			if preference.Marketing {
+				preference.MarketingConsentAt = &now
+			} else {
+				preference.MarketingConsentAt = nil
+			}
+		}
+
+		err := tx.Omit("Patient").Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "patient_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"email", "sms", "reminders", "marketing", "marketing_consent_at", "updated_at", "updated_by"}),
+		}).Create(&preference).Error
+		if err != nil {
+			return fmt.Errorf("failed to save communication preference: %w", err)
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return fmt.Errorf("failed to record consent: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// ListConsents returns the consents a patient gave and withdrew, newest first
+func (r *CommunicationRepository) ListConsents(ctx context.Context, patientID string) ([]models.ConsentRecord, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var records []models.ConsentRecord
+	err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("recorded_at DESC, id DESC").Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consents: %w", err)
+	}
+	return records, nil
+}
+
+// LogMessage records a message sent, or held back, to a patient
+func (r *CommunicationRepository) LogMessage(ctx context.Context, entry *models.CommunicationLog) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to log message: %w", err)
+	}
+	return nil
+}
+
+// ListLog returns the messages sent to a patient, newest first
+func (r *CommunicationRepository) ListLog(ctx context.Context, patientID string) ([]models.CommunicationLog, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.CommunicationLog
+	err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("sent_at DESC, id DESC").Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list communication log: %w", err)
+	}
+	return entries, nil
+}
+
+// SettleDeferred records how a message held back by quiet hours went once delivered: sent, held
+// back by the patient's preferences, or failed with errText
+func (r *CommunicationRepository) SettleDeferred(ctx context.Context, messageID, status, errText string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.CommunicationLog{}).
+		Where("message_id = ? AND status = ?", messageID, models.MessageDeferred).
+		Updates(map[string]interface{}{"status": status, "error": errText, "sent_at": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to settle deferred message: %w", err)
+	}
+	return nil
+}
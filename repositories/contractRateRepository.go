@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ContractRateRepository stores insurers' contract price lists
+type ContractRateRepository struct{}
+
+func NewContractRateRepository() *ContractRateRepository {
+	return &ContractRateRepository{}
+}
+
+func (r *ContractRateRepository) Create(ctx context.Context, rate *models.ContractRate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("InsuranceCompany", "Procedure").Create(rate).Error; err != nil {
+		return fmt.Errorf("failed to create contract rate: %w", err)
+	}
+	return nil
+}
+
+func (r *ContractRateRepository) GetByID(ctx context.Context, insuranceCompanyID string, id uint) (*models.ContractRate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rate models.ContractRate
+	err := database.DB.WithContext(ctx).Preload("Procedure").
+		First(&rate, "id = ? AND insurance_company_id = ?", id, insuranceCompanyID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get contract rate: %w", err)
+	}
+	return &rate, nil
+}
+
+// ListByInsurer returns the insurer's price list, each procedure's rates newest first
+func (r *ContractRateRepository) ListByInsurer(ctx context.Context, insuranceCompanyID string) ([]models.ContractRate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rates []models.ContractRate
+	err := database.DB.WithContext(ctx).Preload("Procedure").
+		Where("insurance_company_id = ?", insuranceCompanyID).
+		Order("procedure_id, effective_from DESC").
+		Find(&rates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contract rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (r *ContractRateRepository) Update(ctx context.Context, rate *models.ContractRate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(rate).Select("amount", "effective_from", "updated_at").Updates(rate).Error; err != nil {
+		return fmt.Errorf("failed to update contract rate: %w", err)
+	}
+	return nil
+}
+
+func (r *ContractRateRepository) Delete(ctx context.Context, insuranceCompanyID string, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.ContractRate{}, "id = ? AND insurance_company_id = ?", id, insuranceCompanyID).Error; err != nil {
+		return fmt.Errorf("failed to delete contract rate: %w", err)
+	}
+	return nil
+}
+
+// RateForPatient returns the rate the patient's insurer pays for the procedure on day, or nil when
+// the patient is uninsured or the insurer has no rate for it. Patients name their insurer by ID or name.
+func (r *ContractRateRepository) RateForPatient(ctx context.Context, patientID string, procedureID uint, day time.Time) (*models.ContractRate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rate models.ContractRate
+	err := database.DB.WithContext(ctx).Table("contract_rate cr").
+		Select("cr.*").
+		Joins("JOIN insurance_company ic ON ic.id = cr.insurance_company_id").
+		Joins("JOIN patient p ON p.insured AND (p.insurance_company = ic.id OR LOWER(p.insurance_company) = LOWER(ic.name))").
+		Where("p.id = ? AND cr.procedure_id = ? AND cr.effective_from <= ?", patientID, procedureID, day.Format("2006-01-02")).
+		Order("cr.effective_from DESC").
+		Limit(1).
+		Scan(&rate).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contract rate: %w", err)
+	}
+	if rate.ID == 0 {
+		return nil, nil
+	}
+	return &rate, nil
+}
+
+// InsurerForPatient returns the insurer the patient is covered by, or nil when the patient is
+// uninsured or names an insurer that is not on file
+func (r *ContractRateRepository) InsurerForPatient(ctx context.Context, patientID string) (*models.InsuranceCompany, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var insurer models.InsuranceCompany
+	err := database.DB.WithContext(ctx).Table("insurance_company ic").
+		Select("ic.*").
+		Joins("JOIN patient p ON p.insured AND (p.insurance_company = ic.id OR LOWER(p.insurance_company) = LOWER(ic.name))").
+		Where("p.id = ?", patientID).
+		Order("p.insurance_company = ic.id DESC").
+		Limit(1).
+		Scan(&insurer).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patient insurer: %w", err)
+	}
+	if insurer.ID == "" {
+		return nil, nil
+	}
+	return &insurer, nil
+}
+
+// Variances returns the bills created between from and to (exclusive) at a contract rate whose amount
+// differs from it, limited to one insurer when insuranceCompanyID is set
+func (r *ContractRateRepository) Variances(ctx context.Context, from, to time.Time, insuranceCompanyID string) ([]models.ContractVariance, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("billing b").
+		Select("b.billing_id, b.patient_id, cr.insurance_company_id, ic.name AS insurance_company_name, b.procedure, b.contract_amount, b.billing_amount, b.billing_amount - b.contract_amount AS variance, b.created_at").
+		Joins("JOIN contract_rate cr ON cr.id = b.contract_rate_id").
+		Joins("JOIN insurance_company ic ON ic.id = cr.insurance_company_id").
+		Where("b.created_at >= ? AND b.created_at < ? AND b.billing_amount <> b.contract_amount", from, to)
+	if insuranceCompanyID != "" {
+		query = query.Where("cr.insurance_company_id = ?", insuranceCompanyID)
+	}
+	var variances []models.ContractVariance
+	if err := query.Order("b.created_at, b.billing_id").Scan(&variances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get contract variances: %w", err)
+	}
+	return variances, nil
+}
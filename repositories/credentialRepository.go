@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
+
+// CredentialRepository reads the doctors' credentials and records the reminders sent about them
+type CredentialRepository struct{}
+
+func NewCredentialRepository() *CredentialRepository {
+	return &CredentialRepository{}
+}
+
+// Doctors returns every doctor with their credentials, by name
+func (r *CredentialRepository) Doctors(ctx context.Context) ([]models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var doctors []models.Doctor
+	err := database.DB.WithContext(ctx).
+		Select("id, first_name, last_name, user_id, licence_number, licence_expires_on, indemnity_expires_on, cpd_expires_on").
+		Order("last_name, first_name, id").
+		Find(&doctors).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get doctors' credentials: %w", err)
+	}
+	return doctors, nil
+}
+
+// UserEmail returns the email of a user account, empty when there is none
+func (r *CredentialRepository) UserEmail(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var emails []string
+	if err := database.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Limit(1).Pluck("email", &emails).Error; err != nil {
+		return "", fmt.Errorf("failed to get user email: %w", err)
+	}
+	if len(emails) == 0 {
+		return "", nil
+	}
+	return emails[0], nil
+}
+
+// RecordReminder records that a credential reminder is being sent and reports false when it was
+// already sent, by this or another replica
+func (r *CredentialRepository) RecordReminder(ctx context.Context, reminder *models.CredentialReminder) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record credential reminder: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
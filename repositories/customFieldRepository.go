@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CustomFieldRepository stores the custom fields admins define for patients and appointments
+type CustomFieldRepository struct {
+	cache *cache.Cache
+}
+
+func NewCustomFieldRepository(cache *cache.Cache) *CustomFieldRepository {
+	return &CustomFieldRepository{cache: cache}
+}
+
+func (r *CustomFieldRepository) Create(ctx context.Context, field *models.CustomField) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(field).Error; err != nil {
+		return fmt.Errorf("failed to create custom field: %w", err)
+	}
+	return nil
+}
+
+func (r *CustomFieldRepository) Get(ctx context.Context, id uint) (*models.CustomField, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var field models.CustomField
+	if err := database.DB.WithContext(ctx).First(&field, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get custom field: %w", err)
+	}
+	return &field, nil
+}
+
+// GetByKey returns the field of entity named key, or nil when there is none
+func (r *CustomFieldRepository) GetByKey(ctx context.Context, entity, key string) (*models.CustomField, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var field models.CustomField
+	if err := database.DB.WithContext(ctx).Where(`entity = ? AND "key" = ?`, entity, key).First(&field).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get custom field: %w", err)
+	}
+	return &field, nil
+}
+
+// List returns the fields of entity, or of every entity when it is empty, in the order they were added
+func (r *CustomFieldRepository) List(ctx context.Context, entity string) ([]models.CustomField, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+	var fields []models.CustomField
+	if err := query.Order("entity, id").Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("failed to list custom fields: %w", err)
+	}
+	return fields, nil
+}
+
+// Update changes how a field is labelled and validated; its entity, key and type are fixed
+func (r *CustomFieldRepository) Update(ctx context.Context, field *models.CustomField) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(field).Select("label", "required", "options", "pattern", "min", "max", "active", "updated_at", "updated_by").Updates(field).Error
+	if err != nil {
+		return fmt.Errorf("failed to update custom field: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a field and the values recorded for it. Cached patients and appointments may
+// hold those values, so the whole cache namespace is dropped.
+func (r *CustomFieldRepository) Delete(ctx context.Context, field *models.CustomField) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.CustomField{}, field.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete custom field: %w", err)
+		}
+		// field.Entity is one of the entities, which are named after their tables
+		err := tx.Exec(fmt.Sprintf("UPDATE %q SET custom_fields = custom_fields - ? WHERE custom_fields -> ? IS NOT NULL", field.Entity), field.Key, field.Key).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete custom field values: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.cache.InvalidateNamespace(ctx)
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// DailySummaryRepository gathers the figures of a clinic day for the end-of-day summary
+type DailySummaryRepository struct{}
+
+func NewDailySummaryRepository() *DailySummaryRepository {
+	return &DailySummaryRepository{}
+}
+
+// attendedStatuses are the statuses of appointments the patient came in for
+var attendedStatuses = []string{models.AppointmentStatusCheckedIn, models.AppointmentStatusInProgress, models.AppointmentStatusFulfilled}
+
+// Figures fills in the figures of the appointments starting, patients registered and bills raised
+// in [from, to), and the tasks open at now. Appointments nobody checked in for count as no-shows once
+// they started. Money is what the day's bills were paid: online payments towards them are told apart
+// from cash paid at the desk, and online payments completed in the day towards older bills added.
+func (r *DailySummaryRepository) Figures(ctx context.Context, from, to, now time.Time, summary *models.DailySummary) error {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	var attendance struct {
+		Appointments, PatientsSeen, NoShows, Cancelled int
+	}
+	err := db.Model(&models.Appointment{}).
+		Select(`COUNT(*) FILTER (WHERE status <> ?) AS appointments,
+			COUNT(DISTINCT patient_id) FILTER (WHERE status IN ? OR checked_in_at IS NOT NULL) AS patients_seen,
+			COUNT(*) FILTER (WHERE status = ? AND checked_in_at IS NULL AND starts_at < ?) AS no_shows,
+			COUNT(*) FILTER (WHERE status = ?) AS cancelled`,
+			models.AppointmentStatusCancelled, attendedStatuses, models.AppointmentStatusScheduled, now, models.AppointmentStatusCancelled).
+		Where("starts_at >= ? AND starts_at < ?", from, to).
+		Scan(&attendance).Error
+	if err != nil {
+		return fmt.Errorf("failed to count the day's appointments: %w", err)
+	}
+	summary.Appointments, summary.PatientsSeen, summary.NoShows, summary.Cancelled =
+		attendance.Appointments, attendance.PatientsSeen, attendance.NoShows, attendance.Cancelled
+
+	var newPatients int64
+	if err := db.Model(&models.Patient{}).Where("created_at >= ? AND created_at < ?", from, to).Count(&newPatients).Error; err != nil {
+		return fmt.Errorf("failed to count new patients: %w", err)
+	}
+	summary.NewPatients = int(newPatients)
+
+	var money struct {
+		Billed, Cash, Insurance, Online float64
+	}
+	err = db.Raw(`SELECT COALESCE(SUM(b.billing_amount), 0) AS billed, COALESCE(SUM(b.paid_cash_amount), 0) AS cash,
+			COALESCE(SUM(b.paid_insurance_amount), 0) AS insurance
+		FROM billing b WHERE b.created_at >= ? AND b.created_at < ?`, from, to).
+		Scan(&money).Error
+	if err != nil {
+		return fmt.Errorf("failed to total the day's bills: %w", err)
+	}
+	var online struct {
+		ForDay, Total float64
+	}
+	err = db.Raw(`SELECT COALESCE(SUM(o.amount) FILTER (WHERE b.created_at >= ? AND b.created_at < ?), 0) AS for_day,
+			COALESCE(SUM(o.amount) FILTER (WHERE o.completed_at >= ? AND o.completed_at < ?), 0) AS total
+		FROM online_payment o JOIN billing b ON b.billing_id = o.billing_id
+		WHERE o.status = ? AND ((b.created_at >= ? AND b.created_at < ?) OR (o.completed_at >= ? AND o.completed_at < ?))`,
+		from, to, from, to, models.OnlinePaymentSucceeded, from, to, from, to).
+		Scan(&online).Error
+	if err != nil {
+		return fmt.Errorf("failed to total the day's online payments: %w", err)
+	}
+	summary.Billed = money.Billed
+	summary.Collected = []models.PaymentTotal{
+		{Method: models.PaymentMethodCash, Amount: money.Cash - online.ForDay},
+		{Method: models.PaymentMethodOnline, Amount: online.Total},
+		{Method: models.PaymentMethodInsurance, Amount: money.Insurance},
+	}
+
+	var tasks struct {
+		OpenTasks, OverdueTasks int
+	}
+	err = db.Model(&models.Task{}).
+		Select("COUNT(*) AS open_tasks, COUNT(*) FILTER (WHERE due_date < ?) AS overdue_tasks", now).
+		Where("status IN ?", []string{models.TaskStatusOpen, models.TaskStatusInProgress}).
+		Scan(&tasks).Error
+	if err != nil {
+		return fmt.Errorf("failed to count open tasks: %w", err)
+	}
+	summary.OpenTasks, summary.OverdueTasks = tasks.OpenTasks, tasks.OverdueTasks
+	return nil
+}
+
+// MarkSent records that the summary of date is being sent, reporting false when it already was
+func (r *DailySummaryRepository) MarkSent(ctx context.Context, date string, at time.Time) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.DailySummaryRun{Date: date, SentAt: at})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record daily summary: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ClearSent forgets that the summary of date was sent, after it could not be
+func (r *DailySummaryRepository) ClearSent(ctx context.Context, date string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.DailySummaryRun{}, "date = ?", date).Error; err != nil {
+		return fmt.Errorf("failed to clear daily summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeferredMessageRepository queues the messages to patients held back during quiet hours
+type DeferredMessageRepository struct{}
+
+func NewDeferredMessageRepository() *DeferredMessageRepository {
+	return &DeferredMessageRepository{}
+}
+
+func (r *DeferredMessageRepository) Create(ctx context.Context, message *models.DeferredMessage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(message).Error; err != nil {
+		return fmt.Errorf("failed to queue deferred message: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue takes up to limit messages due by now off the queue, oldest first. Replicas claiming at
+// once each get different messages, and a message is never delivered twice; one that fails is
+// queued again with Create.
+func (r *DeferredMessageRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]models.DeferredMessage, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var messages []models.DeferredMessage
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("send_at <= ?", now).
+			Order("send_at, id").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]uint, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		return tx.Delete(&models.DeferredMessage{}, ids).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim deferred messages: %w", err)
+	}
+	return messages, nil
+}
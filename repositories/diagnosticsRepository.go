@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// diagnosticsQueryLength caps the statement text returned, since ORM queries can run long.
+const diagnosticsQueryLength = 2000
+
+var (
+	// ErrSlowQueriesUnavailable is returned when the pg_stat_statements extension is not installed
+	ErrSlowQueriesUnavailable = errors.New("pg_stat_statements is not installed in the database")
+	// ErrRedisUnavailable is returned when the server has no Redis client
+	ErrRedisUnavailable = errors.New("redis is not initialized")
+)
+
+// DiagnosticsRepository reads Postgres's statistics views and probes Redis for the Admin runbook
+// endpoints
+type DiagnosticsRepository struct{}
+
+func NewDiagnosticsRepository() *DiagnosticsRepository {
+	return &DiagnosticsRepository{}
+}
+
+// SlowQueries returns up to limit statements run against this database, slowest on average first
+func (r *DiagnosticsRepository) SlowQueries(ctx context.Context, limit int) ([]models.SlowQuery, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var installed bool
+	if err := database.DB.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')").Scan(&installed).Error; err != nil {
+		return nil, fmt.Errorf("failed to check for pg_stat_statements: %w", err)
+	}
+	if !installed {
+		return nil, ErrSlowQueriesUnavailable
+	}
+
+	var queries []models.SlowQuery
+	err := database.DB.WithContext(ctx).Raw(`
+		SELECT LEFT(s.query, ?) AS query, s.calls, s.rows, s.total_exec_time AS total_ms,
+			s.mean_exec_time AS mean_ms, s.max_exec_time AS max_ms
+		FROM pg_stat_statements s
+		WHERE s.dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY s.mean_exec_time DESC
+		LIMIT ?`, diagnosticsQueryLength, limit).
+		Scan(&queries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slow queries: %w", err)
+	}
+	return queries, nil
+}
+
+// LockWaits returns the sessions on this database waiting on a lock, one row per session blocking
+// them, longest waiting first
+func (r *DiagnosticsRepository) LockWaits(ctx context.Context) ([]models.LockWait, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var waits []models.LockWait
+	err := database.DB.WithContext(ctx).Raw(`
+		SELECT w.pid, COALESCE(w.application_name, '') AS application,
+			COALESCE(w.wait_event_type || ':' || w.wait_event, '') AS wait_event,
+			COALESCE(EXTRACT(EPOCH FROM now() - w.state_change), 0) AS waiting_seconds,
+			LEFT(COALESCE(w.query, ''), ?) AS query,
+			b.pid AS blocking_pid, COALESCE(b.state, '') AS blocking_state,
+			LEFT(COALESCE(b.query, ''), ?) AS blocking_query
+		FROM pg_stat_activity w
+		CROSS JOIN LATERAL unnest(pg_blocking_pids(w.pid)) AS blocker(pid)
+		JOIN pg_stat_activity b ON b.pid = blocker.pid
+		WHERE w.datname = current_database()
+		ORDER BY waiting_seconds DESC, w.pid, b.pid`, diagnosticsQueryLength, diagnosticsQueryLength).
+		Scan(&waits).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock waits: %w", err)
+	}
+	return waits, nil
+}
+
+// PoolStats returns the state of the Postgres connection pool
+func (r *DiagnosticsRepository) PoolStats() (*models.DatabasePoolStats, error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database pool: %w", err)
+	}
+	stats := sqlDB.Stats()
+	return &models.DatabasePoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitSeconds:  stats.WaitDuration.Seconds(),
+		MaxIdleClose: stats.MaxIdleClosed,
+	}, nil
+}
+
+// RedisLatency times samples PINGs to Redis. Failed PINGs are counted rather than returned, since
+// they are what is being diagnosed.
+func (r *DiagnosticsRepository) RedisLatency(ctx context.Context, samples int) (*models.RedisLatency, error) {
+	if database.RedisClient == nil {
+		return nil, ErrRedisUnavailable
+	}
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	latency := &models.RedisLatency{Circuit: database.RedisStatus().String(), Samples: []float64{}}
+	var total float64
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if err := database.RedisClient.Ping(ctx).Err(); err != nil {
+			latency.Errors++
+			latency.LastError = err.Error()
+			continue
+		}
+		ms := float64(time.Since(start).Microseconds()) / 1000
+		if len(latency.Samples) == 0 || ms < latency.MinMs {
+			latency.MinMs = ms
+		}
+		latency.MaxMs = max(latency.MaxMs, ms)
+		total += ms
+		latency.Samples = append(latency.Samples, ms)
+	}
+	if len(latency.Samples) > 0 {
+		latency.MeanMs = total / float64(len(latency.Samples))
+	}
+	stats := database.RedisClient.PoolStats()
+	latency.TotalConns, latency.IdleConns, latency.StaleConns = stats.TotalConns, stats.IdleConns, stats.StaleConns
+	return latency, nil
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DoctorAppRepository serves the lean read models of the doctors' mobile app. The queries select
+// only the columns the app shows instead of preloading whole patient trees.
+type DoctorAppRepository struct{}
+
+func NewDoctorAppRepository() *DoctorAppRepository {
+	return &DoctorAppRepository{}
+}
+
+// GetDoctorByUserID returns the doctor linked to a user account, or nil when there is none
+func (r *DoctorAppRepository) GetDoctorByUserID(ctx context.Context, userID int64) (*models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var doctor models.Doctor
+	err := database.DB.WithContext(ctx).Select("id, first_name, last_name, user_id").
+		First(&doctor, "user_id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get doctor for user: %w", err)
+	}
+	return &doctor, nil
+}
+
+// GetDoctor returns a doctor without its appointments and bills, or nil when there is none
+func (r *DoctorAppRepository) GetDoctor(ctx context.Context, id string) (*models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var doctor models.Doctor
+	if err := database.DB.WithContext(ctx).Select("id, first_name, last_name").First(&doctor, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get doctor: %w", err)
+	}
+	return &doctor, nil
+}
+
+// GetAppointmentsOn returns the doctor's appointments on the clinic day day falls on, earliest first
+func (r *DoctorAppRepository) GetAppointmentsOn(ctx context.Context, doctorID string, day time.Time) ([]models.DoctorAppointment, error) {
+	from, to := models.ClinicDay(day)
+	return r.appointmentsBetween(ctx, doctorID, from, to, true)
+}
+
+// GetAppointmentsBetween returns the doctor's appointments on the clinic days from through to, earliest first
+func (r *DoctorAppRepository) GetAppointmentsBetween(ctx context.Context, doctorID string, from, to time.Time) ([]models.DoctorAppointment, error) {
+	from, _ = models.ClinicDay(from)
+	_, to = models.ClinicDay(to)
+	return r.appointmentsBetween(ctx, doctorID, from, to, false)
+}
+
+// appointmentsBetween returns the doctor's appointments starting from from until before to
+func (r *DoctorAppRepository) appointmentsBetween(ctx context.Context, doctorID string, from, to time.Time, skipCancelled bool) ([]models.DoctorAppointment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("appointment AS a").
+		Select("a.id, a.patient_id, p.first_name || ' ' || p.last_name AS patient_name, a.date_time, a.status, a.origin, a.type, a.ends_at, a.checked_in_at").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Where("a.doctor_id = ? AND a.starts_at >= ? AND a.starts_at < ?", doctorID, from, to)
+	if skipCancelled {
+		query = query.Where("a.status <> ?", models.AppointmentStatusCancelled)
+	}
+
+	var appointments []models.DoctorAppointment
+	if err := query.Order("a.starts_at").Scan(&appointments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get doctor appointments: %w", err)
+	}
+	for i := range appointments {
+		appointments[i].DateTime = models.ClinicDateTime(appointments[i].DateTime)
+	}
+	return appointments, nil
+}
+
+// IsDoctorPatient reports whether the patient is one of the doctor's patients, one with an
+// appointment with the doctor
+func (r *DoctorAppRepository) IsDoctorPatient(ctx context.Context, doctorID, patientID string) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Appointment{}).Where("doctor_id = ? AND patient_id = ?", doctorID, patientID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check doctor patient: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetPatientSummary returns the summary of a patient, or nil when the patient does not exist.
+// Callers check the patient is one the doctor may see.
+func (r *DoctorAppRepository) GetPatientSummary(ctx context.Context, patientID string) (*models.DoctorPatientSummary, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+
+	var summary models.DoctorPatientSummary
+	err := db.Model(&models.Patient{}).
+		Select("id, first_name, last_name, sex, date_of_birth, phone, insured, insurance_company").
+		Where("id = ?", patientID).
+		Take(&summary).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get patient summary: %w", err)
+	}
+
+	// Stored date_time values are all in UTC, so the latest one is also the latest in time
+	now := time.Now()
+	visits := db.Model(&models.Appointment{}).Where("patient_id = ? AND status <> ?", patientID, models.AppointmentStatusCancelled)
+	if err := visits.Session(&gorm.Session{}).Select("COALESCE(MAX(date_time), '')").Where("starts_at < ?", now).Scan(&summary.LastVisit).Error; err != nil {
+		return nil, fmt.Errorf("failed to get last visit: %w", err)
+	}
+	if err := visits.Session(&gorm.Session{}).Select("COALESCE(MIN(date_time), '')").Where("starts_at >= ? AND status = ?", now, models.AppointmentStatusScheduled).Scan(&summary.NextAppointment).Error; err != nil {
+		return nil, fmt.Errorf("failed to get next appointment: %w", err)
+	}
+	summary.LastVisit, summary.NextAppointment = models.ClinicDateTime(summary.LastVisit), models.ClinicDateTime(summary.NextAppointment)
+
+	var examination models.Examination
+	err = db.Select("report, created_at").Where("patient_id = ?", patientID).Order("created_at DESC").Take(&examination).Error
+	if err == nil {
+		summary.LatestExamination = examination.Report
+		summary.ExaminedAt = &examination.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get latest examination: %w", err)
+	}
+
+	var plan models.TreatmentPlan
+	err = db.Select("plan").Where("patient_id = ?", patientID).Order("created_at DESC").Take(&plan).Error
+	if err == nil {
+		summary.TreatmentPlan = plan.Plan
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get treatment plan: %w", err)
+	}
+
+	if err := db.Model(&models.Billing{}).Select("COALESCE(SUM(balance), 0)").Where("patient_id = ?", patientID).Scan(&summary.Balance).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patient balance: %w", err)
+	}
+
+	return &summary, nil
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUsernameTaken is returned when an imported doctor's account would reuse another user's username
+	ErrUsernameTaken = errors.New("the username belongs to another user")
+	// ErrAccountLinked is returned when an imported doctor's account is already another doctor's
+	ErrAccountLinked = errors.New("the user account belongs to another doctor")
+)
+
+// MatchImported finds the doctor a staff directory row is about: the doctor with its licence number,
+// or failing that the doctor of the same name, ignoring case
+func (r *DoctorRepository) MatchImported(ctx context.Context, licenceNumber, firstName, lastName string) (*models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx).Select("id, first_name, last_name, user_id, licence_number, specialty, licence_expires_on, indemnity_expires_on, cpd_expires_on, photo_url, created_at, updated_at")
+	var doctor models.Doctor
+	if licenceNumber != "" {
+		err := db.Session(&gorm.Session{}).Where("licence_number = ?", licenceNumber).Take(&doctor).Error
+		if err == nil {
+			return &doctor, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to match doctor by licence: %w", err)
+		}
+	}
+	err := db.Where("LOWER(first_name) = LOWER(?) AND LOWER(last_name) = LOWER(?)", firstName, lastName).
+		Order("created_at").
+		Take(&doctor).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to match doctor by name: %w", err)
+	}
+	return &doctor, nil
+}
+
+// ImportAccount returns the user with the email, if any, and the doctor already linked to it
+func (r *DoctorRepository) ImportAccount(ctx context.Context, email string) (*models.User, string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	err := database.DB.WithContext(ctx).Select("id, username, email, role_id").
+		Where("LOWER(email) = LOWER(?)", email).
+		Take(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get user account: %w", err)
+	}
+	var doctorIDs []string
+	if err := database.DB.WithContext(ctx).Model(&models.Doctor{}).Where("user_id = ?", user.ID).Limit(1).Pluck("id", &doctorIDs).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to get the doctor of user account: %w", err)
+	}
+	if len(doctorIDs) == 0 {
+		return &user, "", nil
+	}
+	return &user, doctorIDs[0], nil
+}
+
+// Import saves a doctor from the staff directory with its user account in one transaction: a doctor
+// without an ID is created, and an account without an ID is created with the Doctor role and linked
+// to the doctor. Only the fields the directory holds are written to an existing doctor.
+func (r *DoctorRepository) Import(ctx context.Context, doctor *models.Doctor, account *models.User) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if account != nil && account.ID == 0 {
+			var taken int64
+			if err := tx.Model(&models.User{}).Where("username = ?", account.Username).Count(&taken).Error; err != nil {
+				return fmt.Errorf("failed to check username: %w", err)
+			}
+			if taken > 0 {
+				return fmt.Errorf("%w: %s", ErrUsernameTaken, account.Username)
+			}
+			var role models.Role
+			if err := tx.Select("id").Where("name = ?", "Doctor").Take(&role).Error; err != nil {
+				return fmt.Errorf("failed to get the Doctor role: %w", err)
+			}
+			account.RoleID = role.ID
+			if err := tx.Omit("Role").Create(account).Error; err != nil {
+				return fmt.Errorf("failed to create user account: %w", err)
+			}
+		}
+		if account != nil {
+			doctor.UserID = &account.ID
+		}
+
+		if doctor.ID == "" {
+			nextID, err := generateID(tx, "doctor")
+			if err != nil {
+				return err
+			}
+			doctor.ID = nextID
+			if err := tx.Omit("Appointments", "Billings").Create(doctor).Error; err != nil {
+				return fmt.Errorf("failed to create doctor: %w", err)
+			}
+			return nil
+		}
+		err := tx.Model(&models.Doctor{}).Where("id = ?", doctor.ID).Updates(map[string]interface{}{
+			"first_name":     doctor.FirstName,
+			"last_name":      doctor.LastName,
+			"licence_number": doctor.LicenceNumber,
+			"specialty":      doctor.Specialty,
+			"user_id":        doctor.UserID,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update doctor: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	publishDoctorChanged(ctx, doctor.ID)
+	if err := r.cache.Delete(ctx, r.getDoctorCacheKey(ctx, doctor.ID)); err != nil {
+		return fmt.Errorf("failed to delete doctor cache: %w", err)
+	}
+	return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "doctors"))
+}
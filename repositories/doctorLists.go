@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/models"
+	"context"
+	"fmt"
+)
+
+// Lists scoped to a doctor are cached per doctor and page. A change to any row they list drops
+// them for every doctor, as it drops the clinic-wide list.
+const (
+	doctorPatientsCache     = "doctor_patients"
+	doctorBillingsCache     = "doctor_billings"
+	doctorAppointmentsCache = "doctor_appointments"
+)
+
+// deleteListCache drops the clinic-wide list cached under list and the doctors' lists of the same rows
+func deleteListCache(ctx context.Context, c *cache.Cache, list string, doctorLists ...string) error {
+	if err := c.DeleteAll(ctx, c.Key(ctx, list)); err != nil {
+		return err
+	}
+	for _, doctorList := range doctorLists {
+		if err := c.DeleteAll(ctx, c.Key(ctx, doctorList)+":*"); err != nil {
+			return fmt.Errorf("failed to delete %s cache: %w", doctorList, err)
+		}
+	}
+	return nil
+}
+
+// cachedDoctorPage returns a page of a doctor's list from the cache, reading and caching it on a miss
+func cachedDoctorPage[T any](ctx context.Context, c *cache.Cache, list, entity, doctorID string, after *models.ListCursor, limit int, read func(ctx context.Context) ([]T, error)) ([]T, error) {
+	cursor := ""
+	if after != nil {
+		cursor = after.String()
+	}
+	return cache.ReadList(ctx, c, c.Key(ctx, list, doctorID, cursor, limit), entity, read)
+}
@@ -3,23 +3,17 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-const (
-	DoctorCacheExpiry = 7 * 24 * time.Hour
-)
-
 type DoctorRepository struct {
 	cache *cache.Cache
 }
@@ -29,88 +23,69 @@ func NewDoctorRepository(cache *cache.Cache) *DoctorRepository {
 }
 
 func (r *DoctorRepository) Create(ctx context.Context, doctor *models.Doctor) error {
-	lockKey := fmt.Sprintf("doctor_lock:%s_%s", doctor.FirstName, doctor.LastName)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
-
-	// Check if a record with the same unique fields already exists
-	var existingDoctor models.Doctor
-	if err := database.DB.Where("first_name = ? AND last_name = ?", doctor.FirstName, doctor.LastName).First(&existingDoctor).Error; err == nil {
-		return errors.New("doctor with the same name already exists")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("failed to check for existing doctor: %w", err)
-	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	// Obtain the next sequence value outside the transaction
-	var nextID string
-	if err := database.DB.Raw("SELECT 'DR-' || LPAD(nextval('doctor_id_seq')::TEXT, 6, '0')").Scan(&nextID).Error; err != nil {
-		return fmt.Errorf("failed to obtain next sequence value: %w", err)
-	}
+	err := database.WithLock(ctx, fmt.Sprintf("doctor_lock:%s_%s", doctor.FirstName, doctor.LastName), func(tx *gorm.DB) error {
+		// Check if a record with the same unique fields already exists
+		var existingDoctor models.Doctor
+		if err := tx.Where("first_name = ? AND last_name = ?", doctor.FirstName, doctor.LastName).First(&existingDoctor).Error; err == nil {
+			return errors.New("doctor with the same name already exists")
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to check for existing doctor: %w", err)
+		}
 
-	// Set the obtained ID to the doctor
-	doctor.ID = nextID
+		// Obtain the next ID in its configured format
+		nextID, err := generateID(tx, "doctor")
+		if err != nil {
+			return err
+		}
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		// Create the doctor record
-		if err := tx.Create(doctor).Error; err != nil {
-			// If the creation fails, rollback the sequence
-			if rollbackErr := database.DB.Exec("SELECT setval('doctor_id_seq', (SELECT last_value FROM doctor_id_seq) - 1, false)").Error; rollbackErr != nil {
-				return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+		// Set the obtained ID to the doctor
+		doctor.ID = nextID
+
+		return tx.Transaction(func(tx *gorm.DB) error {
+			// Create the doctor record
+			if err := tx.Create(doctor).Error; err != nil {
+				// If the creation fails, rollback the sequence
+				if rollbackErr := releaseID(database.DB.WithContext(ctx), "doctor"); rollbackErr != nil {
+					return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+				}
+				return fmt.Errorf("failed to create doctor: %w", err)
 			}
-			return fmt.Errorf("failed to create doctor: %w", err)
-		}
 
-		// Delete cache for the newly created doctor and all doctors
-		if err := r.cache.Delete(ctx, r.getDoctorCacheKey(doctor.ID)); err != nil {
-			return fmt.Errorf("failed to delete doctor cache: %w", err)
-		}
-		return r.cache.DeleteAll(ctx, "doctors_cache")
+			// Delete cache for the newly created doctor and all doctors
+			if err := r.cache.Delete(ctx, r.getDoctorCacheKey(ctx, doctor.ID)); err != nil {
+				return fmt.Errorf("failed to delete doctor cache: %w", err)
+			}
+			return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "doctors"))
+		})
 	})
+	if err != nil {
+		return err
+	}
+	publishDoctorChanged(ctx, doctor.ID)
+	return nil
 }
 
 func (r *DoctorRepository) GetByID(ctx context.Context, id string) (*models.Doctor, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getDoctorCacheKey(id)
-	cachedDoctor, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var doctor models.Doctor
-		if err := json.Unmarshal([]byte(cachedDoctor), &doctor); err == nil {
-			return &doctor, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getDoctorCacheKey(ctx, id)
+	var doctor models.Doctor
+	if found, err := r.cache.GetObject(ctx, cacheKey, &doctor); err != nil {
 		log.Printf("Failed to get doctor from cache: %v", err)
+	} else if found {
+		return &doctor, nil
 	}
 
-	var doctor models.Doctor
-	err = database.DB.Select("id, first_name, last_name, created_at").
+	err := database.DB.WithContext(ctx).Select("id, first_name, last_name, user_id, licence_number, specialty, licence_expires_on, indemnity_expires_on, cpd_expires_on, photo_url, created_at, updated_at").
 		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
 			return db.Select("patient_id, doctor_id, date_time, created_at")
 		}).
 		Preload("Billings", func(db *gorm.DB) *gorm.DB {
-			return db.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at")
+			return db.Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, created_by, updated_by")
 		}).
 		First(&doctor, "id = ?", id).Error
 	if err != nil {
@@ -120,11 +95,7 @@ func (r *DoctorRepository) GetByID(ctx context.Context, id string) (*models.Doct
 		return nil, fmt.Errorf("failed to get doctor: %w", err)
 	}
 
-	doctorJSON, err := json.Marshal(doctor)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal doctor: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, doctorJSON, DoctorCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, doctor, cache.ItemTTL("doctor")); err != nil {
 		log.Printf("Failed to set doctor in cache: %v", err)
 	}
 
@@ -132,119 +103,118 @@ func (r *DoctorRepository) GetByID(ctx context.Context, id string) (*models.Doct
 }
 
 func (r *DoctorRepository) GetAll(ctx context.Context) ([]models.Doctor, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "doctors_cache"
-	cachedDoctors, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "doctors"), "doctor", func(ctx context.Context) ([]models.Doctor, error) {
 		var doctors []models.Doctor
-		if err := json.Unmarshal([]byte(cachedDoctors), &doctors); err == nil {
-			return doctors, nil
+		err := database.DB.WithContext(ctx).Select("id, first_name, last_name, user_id, licence_number, specialty, licence_expires_on, indemnity_expires_on, cpd_expires_on, photo_url, created_at, updated_at").
+			Preload("Appointments", func(db *gorm.DB) *gorm.DB {
+				return db.Select("patient_id, doctor_id, date_time, created_at")
+			}).
+			Preload("Billings", func(db *gorm.DB) *gorm.DB {
+				return db.Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, created_by, updated_by")
+			}).
+			Order("created_at DESC").
+			Find(&doctors).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all doctors: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get doctors from cache: %v", err)
+		return doctors, nil
+	})
+}
+
+// Search returns the doctors matching filter. Filtered lists are not cached; they are cheap and
+// change whenever a doctor's procedures do.
+func (r *DoctorRepository) Search(ctx context.Context, filter models.DoctorFilter) ([]models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.Doctor{}).
+		Select("id, first_name, last_name, user_id, licence_number, specialty, licence_expires_on, indemnity_expires_on, cpd_expires_on, photo_url, created_at, updated_at")
+	if filter.Specialty != "" {
+		query = query.Where("specialty = ?", filter.Specialty)
+	}
+	if filter.ProcedureID != 0 {
+		query = query.Where("id IN (?)", database.DB.Model(&models.DoctorProcedure{}).
+			Select("doctor_id").Where("procedure_id = ?", filter.ProcedureID))
 	}
 
 	var doctors []models.Doctor
-	err = database.DB.Select("id, first_name, last_name, created_at").
-		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
-			return db.Select("patient_id, doctor_id, date_time, created_at")
-		}).
-		Preload("Billings", func(db *gorm.DB) *gorm.DB {
-			return db.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at")
-		}).
-		Order("created_at DESC").
-		Find(&doctors).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all doctors: %w", err)
+	if err := query.Order("last_name ASC, first_name ASC").Find(&doctors).Error; err != nil {
+		return nil, fmt.Errorf("failed to search doctors: %w", err)
 	}
+	return doctors, nil
+}
+
+// RevenueBySpecialty totals the bills created in [from, to) by the specialty of the billing doctor
+func (r *DoctorRepository) RevenueBySpecialty(ctx context.Context, from, to time.Time) ([]models.SpecialtyRevenue, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	doctorsJSON, err := json.Marshal(doctors)
+	var revenue []models.SpecialtyRevenue
+	err := database.DB.WithContext(ctx).Table("billing b").
+		Select("d.specialty AS specialty, COUNT(*) AS bills, COALESCE(SUM(b.billing_amount), 0) AS billed, COALESCE(SUM(b.total_received), 0) AS received").
+		Joins("JOIN doctor d ON d.id = b.doctor_id").
+		Where("b.created_at >= ? AND b.created_at < ?", from, to).
+		Group("d.specialty").
+		Order("billed DESC").
+		Scan(&revenue).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal doctors: %w", err)
+		return nil, fmt.Errorf("failed to get revenue by specialty: %w", err)
 	}
-	if err := r.cache.Set(ctx, cacheKey, doctorsJSON, DoctorCacheExpiry); err != nil {
-		log.Printf("Failed to set doctors in cache: %v", err)
-	}
-
-	return doctors, nil
+	return revenue, nil
 }
 
 func (r *DoctorRepository) Update(ctx context.Context, doctor *models.Doctor) error {
-	lockKey := fmt.Sprintf("doctor_lock:%s", doctor.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("doctor_lock:%s", doctor.ID), func(tx *gorm.DB) error {
+		err := tx.Save(doctor).Error
+		if err != nil {
+			return fmt.Errorf("failed to update doctor: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the updated doctor and all doctors
+		if err := r.cache.Delete(ctx, r.getDoctorCacheKey(ctx, doctor.ID)); err != nil {
+			return fmt.Errorf("failed to delete doctor cache: %w", err)
 		}
-	}()
-
-	err = database.DB.Save(doctor).Error
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "doctors"))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update doctor: %w", err)
-	}
-	// Delete cache for the updated doctor and all doctors
-	if err := r.cache.Delete(ctx, r.getDoctorCacheKey(doctor.ID)); err != nil {
-		return fmt.Errorf("failed to delete doctor cache: %w", err)
+		return err
 	}
-	return r.cache.DeleteAll(ctx, "doctors_cache")
+	publishDoctorChanged(ctx, doctor.ID)
+	return nil
 }
 
 func (r *DoctorRepository) Delete(ctx context.Context, id string) error {
-	lockKey := fmt.Sprintf("doctor_lock:%s", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("doctor_lock:%s", id), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.Doctor{}, "id = ?", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete doctor: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the deleted doctor and all doctors
+		if err := r.cache.Delete(ctx, r.getDoctorCacheKey(ctx, id)); err != nil {
+			return fmt.Errorf("failed to delete doctor cache: %w", err)
 		}
-	}()
-
-	err = database.DB.Delete(&models.Doctor{}, "id = ?", id).Error
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "doctors"))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete doctor: %w", err)
+		return err
 	}
-	// Delete cache for the deleted doctor and all doctors
-	if err := r.cache.Delete(ctx, r.getDoctorCacheKey(id)); err != nil {
-		return fmt.Errorf("failed to delete doctor cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "doctors_cache")
+	publishDoctorChanged(ctx, id)
+	return nil
+}
+
+// publishDoctorChanged tells subscribers, such as the response cache, that a doctor was saved or removed
+func publishDoctorChanged(ctx context.Context, id string) {
+	events.Publish(ctx, events.Event{Name: events.DoctorChanged, Record: "doctor", RecordID: id})
 }
 
-func (r *DoctorRepository) getDoctorCacheKey(id string) string {
-	return fmt.Sprintf("doctor_cache:%s", id)
+func (r *DoctorRepository) getDoctorCacheKey(ctx context.Context, id string) string {
+	return r.cache.Key(ctx, "doctor", id)
 }
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentShareRepository stores the documents shared with patients and who opened them
+type DocumentShareRepository struct{}
+
+func NewDocumentShareRepository() *DocumentShareRepository {
+	return &DocumentShareRepository{}
+}
+
+// Create saves a share with its documents
+func (r *DocumentShareRepository) Create(ctx context.Context, share *models.DocumentShare) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient", "Accesses").Create(share).Error; err != nil {
+		return fmt.Errorf("failed to create document share: %w", err)
+	}
+	return nil
+}
+
+// Get returns a patient's share with its documents and access log, or nil when it does not exist
+func (r *DocumentShareRepository) Get(ctx context.Context, patientID string, id uint) (*models.DocumentShare, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var share models.DocumentShare
+	err := database.DB.WithContext(ctx).
+		Preload("Documents", orderSharedDocuments).
+		Preload("Accesses", func(db *gorm.DB) *gorm.DB { return db.Order("accessed_at, id") }).
+		First(&share, "patient_id = ? AND id = ?", patientID, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get document share: %w", err)
+	}
+	return &share, nil
+}
+
+// GetLinked returns the share a link points to with its documents and patient, or nil when it does not exist
+func (r *DocumentShareRepository) GetLinked(ctx context.Context, id uint) (*models.DocumentShare, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var share models.DocumentShare
+	err := database.DB.WithContext(ctx).
+		Preload("Documents", orderSharedDocuments).
+		Preload("Patient", func(db *gorm.DB) *gorm.DB { return db.Select("id, first_name") }).
+		First(&share, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get document share: %w", err)
+	}
+	return &share, nil
+}
+
+// List returns a patient's shares with their documents, latest first
+func (r *DocumentShareRepository) List(ctx context.Context, patientID string) ([]models.DocumentShare, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var shares []models.DocumentShare
+	err := database.DB.WithContext(ctx).
+		Preload("Documents", orderSharedDocuments).
+		Where("patient_id = ?", patientID).
+		Order("created_at DESC, id DESC").
+		Find(&shares).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document shares: %w", err)
+	}
+	return shares, nil
+}
+
+// Revoke stops a share's link from working
+func (r *DocumentShareRepository) Revoke(ctx context.Context, share *models.DocumentShare, revokedBy *int64, at time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.DocumentShare{}).
+		Where("id = ? AND revoked_at IS NULL", share.ID).
+		Updates(map[string]interface{}{"revoked_at": at, "revoked_by": revokedBy}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke document share: %w", err)
+	}
+	share.RevokedAt, share.RevokedBy = &at, revokedBy
+	return nil
+}
+
+// Delete removes a share whose link could not be sent
+func (r *DocumentShareRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.DocumentShare{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete document share: %w", err)
+	}
+	return nil
+}
+
+// LogAccess records a use of a share link
+func (r *DocumentShareRepository) LogAccess(ctx context.Context, access *models.DocumentShareAccess) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(access).Error; err != nil {
+		return fmt.Errorf("failed to log document share access: %w", err)
+	}
+	return nil
+}
+
+func orderSharedDocuments(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
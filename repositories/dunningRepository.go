@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DunningRepository stores the dunning stages and which of them each overdue bill has reached
+type DunningRepository struct{}
+
+func NewDunningRepository() *DunningRepository {
+	return &DunningRepository{}
+}
+
+func (r *DunningRepository) CreateStage(ctx context.Context, stage *models.DunningStage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(stage).Error; err != nil {
+		return fmt.Errorf("failed to create dunning stage: %w", err)
+	}
+	return nil
+}
+
+func (r *DunningRepository) GetStage(ctx context.Context, id uint) (*models.DunningStage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var stage models.DunningStage
+	if err := database.DB.WithContext(ctx).First(&stage, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dunning stage: %w", err)
+	}
+	return &stage, nil
+}
+
+// ListStages returns the dunning stages in the order bills reach them
+func (r *DunningRepository) ListStages(ctx context.Context) ([]models.DunningStage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var stages []models.DunningStage
+	if err := database.DB.WithContext(ctx).Order("days_overdue").Find(&stages).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dunning stages: %w", err)
+	}
+	return stages, nil
+}
+
+// StageExists reports whether a stage other than excludeID is already at daysOverdue
+func (r *DunningRepository) StageExists(ctx context.Context, daysOverdue int, excludeID uint) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.DunningStage{}).
+		Where("days_overdue = ? AND id <> ?", daysOverdue, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check dunning stages: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *DunningRepository) UpdateStage(ctx context.Context, stage *models.DunningStage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(stage).
+		Select("days_overdue", "subject", "body", "send_email", "send_sms", "active", "updated_at", "updated_by").
+		Updates(stage).Error
+	if err != nil {
+		return fmt.Errorf("failed to update dunning stage: %w", err)
+	}
+	return nil
+}
+
+// DeleteStage removes a stage along with the notices recording which bills reached it
+func (r *DunningRepository) DeleteStage(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.DunningStage{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete dunning stage: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the overdue bills that reached an active stage since their last notice, by account:
+// the guarantor of the patient's household, or the patient when they are in none. Accounts are
+// reached through their own guarantor when they have one. A bill has reached a stage when it was
+// raised more than the stage's days before overdueBefore. Bills under an open dispute or an active
+// payment plan are left out, as are accounts the stage cannot reach on its channels.
+func (r *DunningRepository) Pending(ctx context.Context, overdueBefore time.Time, limit int) ([]models.DunningCandidate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var candidates []models.DunningCandidate
+	err := database.DB.WithContext(ctx).Table("billing b").
+		Select("b.billing_id, b.patient_id, b.procedure, b.created_at, b.balance, s.id AS stage_id, s.days_overdue AS stage_days, "+
+			"bp.first_name AS billed_first_name, p.id AS account_id, "+
+			guarantorContact("p", "first_name", "p.guarantor_name")+" AS patient_first_name, "+
+			guarantorContact("p", "last_name", "''")+" AS patient_last_name, "+
+			guarantorContact("p", "email", "p.guarantor_email")+" AS patient_email, "+
+			guarantorContact("p", "phone", "p.guarantor_phone")+" AS patient_phone").
+		Joins("JOIN patient bp ON bp.id = b.patient_id").
+		Joins("LEFT JOIN household_member m ON m.patient_id = b.patient_id").
+		Joins("LEFT JOIN household h ON h.id = m.household_id").
+		// Statements go to the household's guarantor while they are still a patient
+		Joins("JOIN patient p ON p.id = COALESCE((SELECT g.id FROM patient g WHERE g.id = h.guarantor_id), b.patient_id)").
+		// The latest stage the bill has reached
+		Joins("JOIN LATERAL (SELECT id, days_overdue, send_email, send_sms FROM dunning_stage WHERE active AND b.created_at < CAST(? AS timestamptz) - days_overdue * INTERVAL '1 day' ORDER BY days_overdue DESC LIMIT 1) s ON true", overdueBefore).
+		Where("b.balance > 0").
+		Where("(s.send_email AND COALESCE("+guarantorContact("p", "email", "p.guarantor_email")+", '') <> '') OR "+
+			"(s.send_sms AND COALESCE("+guarantorContact("p", "phone", "p.guarantor_phone")+", '') <> '')").
+		Where("NOT EXISTS (SELECT 1 FROM dunning_notice n JOIN dunning_stage ns ON ns.id = n.stage_id WHERE n.billing_id = b.billing_id AND ns.days_overdue >= s.days_overdue)").
+		Where("NOT EXISTS (SELECT 1 FROM billing_dispute d WHERE d.billing_id = b.billing_id AND d.status IN ?)", models.OpenDisputeStatuses).
+		Where("NOT EXISTS (SELECT 1 FROM payment_plan pp WHERE pp.billing_id = b.billing_id AND pp.status = ?)", models.PaymentPlanStatusActive).
+		Order("p.id, b.created_at, b.billing_id").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdue bills: %w", err)
+	}
+	return candidates, nil
+}
+
+// RecordNotice records that a bill reached a stage and reports false when another replica already
+// recorded it
+func (r *DunningRepository) RecordNotice(ctx context.Context, notice *models.DunningNotice) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Omit("Stage").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "billing_id"}, {Name: "stage_id"}},
+		DoNothing: true,
+	}).Create(notice)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record dunning notice: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteNotice removes a notice whose statement could not be delivered so it is retried
+func (r *DunningRepository) DeleteNotice(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.DunningNotice{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete dunning notice: %w", err)
+	}
+	return nil
+}
+
+// ListNotices returns the dunning notices of a bill, oldest first
+func (r *DunningRepository) ListNotices(ctx context.Context, billingID string) ([]models.DunningNotice, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var notices []models.DunningNotice
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", billingID).Order("sent_at, id").Find(&notices).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dunning notices: %w", err)
+	}
+	return notices, nil
+}
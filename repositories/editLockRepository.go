@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// EditLockRepository keeps edit locks in Redis with the same primitives as write locks. The lock
+// value is the holder's user ID, so the same user editing from a second tab shares the lock.
+type EditLockRepository struct{}
+
+func NewEditLockRepository() *EditLockRepository {
+	return &EditLockRepository{}
+}
+
+// Acquire takes the lock of a record for userID, or extends it when userID already holds it.
+// It reports whether userID holds the lock afterwards.
+func (r *EditLockRepository) Acquire(ctx context.Context, entity, recordID string, userID int64, ttl time.Duration) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	key, value := r.key(entity, recordID), strconv.FormatInt(userID, 10)
+	locked, err := database.NewLock(ctx, key, value, ttl)
+	if err != nil {
+		return false, fmt.Errorf("failed to take edit lock: %w", err)
+	}
+	if locked {
+		return true, nil
+	}
+	return r.extend(ctx, key, value, ttl)
+}
+
+// Extend renews the lock of a record held by userID and reports whether it was still held
+func (r *EditLockRepository) Extend(ctx context.Context, entity, recordID string, userID int64, ttl time.Duration) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return r.extend(ctx, r.key(entity, recordID), strconv.FormatInt(userID, 10), ttl)
+}
+
+func (r *EditLockRepository) extend(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	extended, err := database.ExtendLock(ctx, key, value, ttl)
+	if err != nil {
+		return false, fmt.Errorf("failed to extend edit lock: %w", err)
+	}
+	return extended, nil
+}
+
+// Release drops the lock of a record if userID holds it
+func (r *EditLockRepository) Release(ctx context.Context, entity, recordID string, userID int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.ReleaseLock(ctx, r.key(entity, recordID), strconv.FormatInt(userID, 10))
+	// Releasing a lock that expired or was taken over is not an error for the caller
+	if err != nil && !errors.Is(err, database.ErrNotLockOwner) {
+		return err
+	}
+	return nil
+}
+
+// Get returns the current lock of a record, or nil when nobody holds it
+func (r *EditLockRepository) Get(ctx context.Context, entity, recordID string) (*models.EditLock, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	key := r.key(entity, recordID)
+	value, err := database.RedisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edit lock: %w", err)
+	}
+	ttl, err := database.RedisClient.PTTL(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edit lock expiry: %w", err)
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+	userID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edit lock holder %q", value)
+	}
+
+	lock := &models.EditLock{Entity: entity, RecordID: recordID, UserID: userID, ExpiresAt: time.Now().Add(ttl)}
+	var usernames []string
+	if err := database.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Pluck("username", &usernames).Error; err != nil {
+		return nil, fmt.Errorf("failed to get edit lock holder: %w", err)
+	}
+	if len(usernames) > 0 {
+		lock.Username = usernames[0]
+	}
+	return lock, nil
+}
+
+func (r *EditLockRepository) key(entity, recordID string) string {
+	return fmt.Sprintf("edit_lock:%s:%s", entity, recordID)
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EligibilityRepository stores the insurance eligibility checks of appointments, keeping each
+// appointment's eligibility status in step with its check
+type EligibilityRepository struct{}
+
+func NewEligibilityRepository() *EligibilityRepository {
+	return &EligibilityRepository{}
+}
+
+// Create queues the check of an appointment, reporting false when the appointment already has one
+func (r *EligibilityRepository) Create(ctx context.Context, check *models.EligibilityCheck) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var created bool
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "appointment_id"}}, DoNothing: true}).Create(check)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return setAppointmentEligibility(tx, check)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create eligibility check: %w", err)
+	}
+	return created, nil
+}
+
+// Save records the outcome of a check on it and its appointment
+func (r *EligibilityRepository) Save(ctx context.Context, check *models.EligibilityCheck) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(check).Select("status", "detail", "checked_at", "checked_by", "updated_at").Updates(check).Error
+		if err != nil {
+			return err
+		}
+		return setAppointmentEligibility(tx, check)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save eligibility check: %w", err)
+	}
+	return nil
+}
+
+func setAppointmentEligibility(tx *gorm.DB, check *models.EligibilityCheck) error {
+	return tx.Model(&models.Appointment{}).Where("id = ? AND patient_id = ?", check.AppointmentID, check.PatientID).
+		UpdateColumn("eligibility_status", check.Status).Error
+}
+
+func (r *EligibilityRepository) GetByID(ctx context.Context, id uint) (*models.EligibilityCheck, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var check models.EligibilityCheck
+	if err := database.DB.WithContext(ctx).First(&check, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get eligibility check: %w", err)
+	}
+	return &check, nil
+}
+
+// GetByAppointment returns the check of the patient's appointment, or nil when it has none
+func (r *EligibilityRepository) GetByAppointment(ctx context.Context, patientID string, appointmentID uint) (*models.EligibilityCheck, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var check models.EligibilityCheck
+	err := database.DB.WithContext(ctx).First(&check, "appointment_id = ? AND patient_id = ?", appointmentID, patientID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get eligibility check: %w", err)
+	}
+	return &check, nil
+}
+
+// List returns the checks with status, or every check not confirmed yet when status is empty,
+// soonest appointment first
+func (r *EligibilityRepository) List(ctx context.Context, status string, limit int) ([]models.EligibilityCheck, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status <> ?", models.EligibilityConfirmed)
+	}
+	var checks []models.EligibilityCheck
+	if err := query.Order("service_date, id").Limit(limit).Find(&checks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list eligibility checks: %w", err)
+	}
+	return checks, nil
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailDeliveryRepository records the bounces and complaints the mail provider reports, and keeps
+// the addresses that are no longer emailed
+type EmailDeliveryRepository struct {
+	cache *cache.Cache
+}
+
+func NewEmailDeliveryRepository(cache *cache.Cache) *EmailDeliveryRepository {
+	return &EmailDeliveryRepository{cache: cache}
+}
+
+// flaggedAccounts are the patients and users whose email was flagged or cleared, whose cache is deleted
+type flaggedAccounts struct {
+	patientIDs []string
+	users      []models.User
+}
+
+// RecordEvent marks the email the event is about in the communication log, reporting whether it
+// was found there, and when suppress is set adds the recipient to the suppression list and flags
+// the patients and users with that email, reporting whether it was not suppressed yet
+func (r *EmailDeliveryRepository) RecordEvent(ctx context.Context, event models.EmailEvent, suppress bool) (bool, bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	delivery := models.DeliveryBounced
+	if event.Type == models.EmailEventComplaint {
+		delivery = models.DeliveryComplained
+	}
+	address := strings.ToLower(strings.TrimSpace(event.Recipient))
+	var matched, suppressed bool
+	var flagged flaggedAccounts
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if event.MessageID != "" {
+			result := tx.Model(&models.CommunicationLog{}).Where("message_id = ? AND channel = ?", event.MessageID, "email").
+				Updates(map[string]interface{}{"delivery": delivery, "error": event.Diagnostic})
+			if result.Error != nil {
+				return fmt.Errorf("failed to record email delivery: %w", result.Error)
+			}
+			matched = result.RowsAffected > 0
+		}
+		if !suppress || address == "" {
+			return nil
+		}
+
+		result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "address"}}, DoNothing: true}).
+			Create(&models.EmailSuppression{Address: address, Reason: event.Type, Diagnostic: event.Diagnostic, MessageID: event.MessageID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to suppress email address: %w", result.Error)
+		}
+		suppressed = result.RowsAffected > 0
+
+		now := time.Now()
+		var err error
+		flagged, err = flagAccounts(tx, address, &now)
+		return err
+	})
+	if err != nil {
+		return false, false, err
+	}
+	if err := r.deleteAccountCache(ctx, flagged); err != nil {
+		return false, false, err
+	}
+	return matched, suppressed, nil
+}
+
+// Suppressed returns those of addresses on the suppression list
+func (r *EmailDeliveryRepository) Suppressed(ctx context.Context, addresses []string) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	lower := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lower = append(lower, strings.ToLower(strings.TrimSpace(address)))
+	}
+	var suppressed []string
+	if err := database.DB.WithContext(ctx).Model(&models.EmailSuppression{}).Where("address IN ?", lower).Pluck("address", &suppressed).Error; err != nil {
+		return nil, fmt.Errorf("failed to check suppressed email addresses: %w", err)
+	}
+	return suppressed, nil
+}
+
+// ListSuppressions returns the suppressed addresses, most recent first
+func (r *EmailDeliveryRepository) ListSuppressions(ctx context.Context) ([]models.EmailSuppression, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var suppressions []models.EmailSuppression
+	if err := database.DB.WithContext(ctx).Order("created_at DESC, id DESC").Find(&suppressions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list suppressed email addresses: %w", err)
+	}
+	return suppressions, nil
+}
+
+// DeleteSuppression takes an address off the suppression list and clears the flag on the patients
+// and users with that email, reporting whether it was on the list
+func (r *EmailDeliveryRepository) DeleteSuppression(ctx context.Context, address string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	address = strings.ToLower(strings.TrimSpace(address))
+	var deleted bool
+	var flagged flaggedAccounts
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("address = ?", address).Delete(&models.EmailSuppression{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete email suppression: %w", result.Error)
+		}
+		deleted = result.RowsAffected > 0
+		if !deleted {
+			return nil
+		}
+		var err error
+		flagged, err = flagAccounts(tx, address, nil)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := r.deleteAccountCache(ctx, flagged); err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// flagAccounts sets, or with a nil bouncedAt clears, when the email of the patients and users with
+// address bounced
+func flagAccounts(tx *gorm.DB, address string, bouncedAt *time.Time) (flaggedAccounts, error) {
+	var flagged flaggedAccounts
+	if err := tx.Model(&models.Patient{}).Where("LOWER(email) = ?", address).Pluck("id", &flagged.patientIDs).Error; err != nil {
+		return flagged, fmt.Errorf("failed to find patients by email: %w", err)
+	}
+	if len(flagged.patientIDs) > 0 {
+		if err := tx.Model(&models.Patient{}).Where("id IN ?", flagged.patientIDs).UpdateColumn("email_bounced_at", bouncedAt).Error; err != nil {
+			return flagged, fmt.Errorf("failed to flag patient email: %w", err)
+		}
+	}
+	if err := tx.Model(&models.User{}).Select("id, username, email").Where("LOWER(email) = ?", address).Find(&flagged.users).Error; err != nil {
+		return flagged, fmt.Errorf("failed to find users by email: %w", err)
+	}
+	if len(flagged.users) > 0 {
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = ?", address).UpdateColumn("email_bounced_at", bouncedAt).Error; err != nil {
+			return flagged, fmt.Errorf("failed to flag user email: %w", err)
+		}
+	}
+	return flagged, nil
+}
+
+// deleteAccountCache drops the cached patients and users whose email was flagged or cleared
+func (r *EmailDeliveryRepository) deleteAccountCache(ctx context.Context, flagged flaggedAccounts) error {
+	var keys []string
+	for _, id := range flagged.patientIDs {
+		keys = append(keys, r.cache.Key(ctx, "patient", id))
+	}
+	for _, user := range flagged.users {
+		keys = append(keys, r.cache.Key(ctx, "user", strconv.FormatInt(user.ID, 10)), r.cache.Key(ctx, "user", user.Username), r.cache.Key(ctx, "user", user.Email))
+	}
+	if len(keys) > 0 {
+		if err := r.cache.DeleteBatch(ctx, keys...); err != nil {
+			return fmt.Errorf("failed to delete account cache: %w", err)
+		}
+	}
+	if len(flagged.patientIDs) > 0 {
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients")); err != nil {
+			return fmt.Errorf("failed to delete all patients cache: %w", err)
+		}
+	}
+	return nil
+}
@@ -3,24 +3,18 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-const (
-	EmergencyContactCacheExpiry = 7 * 24 * time.Hour
-)
-
 type EmergencyContactRepository struct {
 	cache *cache.Cache
 }
@@ -30,134 +24,118 @@ func NewEmergencyContactRepository(cache *cache.Cache) *EmergencyContactReposito
 }
 
 func (r *EmergencyContactRepository) Create(ctx context.Context, contact *models.EmergencyContact) error {
-	lockKey := fmt.Sprintf("emergency_contact_lock:%s", contact.PatientID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("emergency_contact_lock:%s", contact.PatientID), func(tx *gorm.DB) error {
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			if contact.Primary {
+				if err := clearPrimaryContact(tx, contact.PatientID, contact.Phone); err != nil {
+					return err
+				}
+			}
+			// Insert the emergency contact record if it does not exist
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "patient_id"}, {Name: "phone"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name", "relationship", "is_primary", "updated_at"}),
+			}).Omit("Patient").Create(contact).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create emergency contact: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if contact.Primary {
+			// Another contact of the patient may have lost its primary flag
+			if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contact", contact.PatientID)+":*"); err != nil {
+				return fmt.Errorf("failed to delete patient emergency contacts cache: %w", err)
+			}
 		}
-	}()
-
-	// Insert the emergency contact record if it does not exist
-	err = database.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "patient_id"}, {Name: "phone"}},
-		DoUpdates: clause.AssignmentColumns([]string{"name", "relationship"}),
-	}).Create(contact).Error
-	if err != nil {
-		return fmt.Errorf("failed to create emergency contact: %w", err)
-	}
 
-	// Delete cache for the newly created emergency contact and all emergency contacts
-	if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(contact.PatientID, contact.ID)); err != nil {
-		return fmt.Errorf("failed to delete emergency contact cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "emergency_contacts_cache"); err != nil {
-		return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all emergency contacts cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(contact.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+		// Delete cache for the newly created emergency contact and all emergency contacts
+		if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(ctx, contact.PatientID, contact.ID)); err != nil {
+			return fmt.Errorf("failed to delete emergency contact cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contacts")); err != nil {
+			return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all emergency contacts cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, contact.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
 func (r *EmergencyContactRepository) Update(ctx context.Context, contact *models.EmergencyContact) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
 	// Acquire a lock based on the contact ID and patient ID
-	lockKey := fmt.Sprintf("emergency_contact_lock:%s_%d", contact.PatientID, contact.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+	return database.WithLock(ctx, fmt.Sprintf("emergency_contact_lock:%s_%d", contact.PatientID, contact.ID), func(tx *gorm.DB) error {
+		// Fetch the existing contact to check if it exists
+		existingContact, err := r.GetByID(ctx, contact.PatientID, contact.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get existing emergency contact: %w", err)
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if existingContact == nil {
+			return errors.New("emergency contact not found")
 		}
-	}()
-
-	// Fetch the existing contact to check if it exists
-	existingContact, err := r.GetByID(ctx, contact.PatientID, contact.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get existing emergency contact: %w", err)
-	}
-	if existingContact == nil {
-		return errors.New("emergency contact not found")
-	}
 
-	// Update the contact details
-	existingContact.Name = contact.Name
-	existingContact.Relationship = contact.Relationship
-	existingContact.Phone = contact.Phone
+		// Update the contact details; consent to be texted was given for the old phone only
+		if existingContact.Phone != contact.Phone {
+			existingContact.SMSConsent, existingContact.SMSConsentAt, existingContact.SMSConsentBy = false, nil, nil
+		}
+		existingContact.Name = contact.Name
+		existingContact.Relationship = contact.Relationship
+		existingContact.Phone = contact.Phone
+		existingContact.Primary = contact.Primary
 
-	// Save the updated contact to the database
-	err = database.DB.Save(existingContact).Error
-	if err != nil {
-		return fmt.Errorf("failed to update emergency contact: %w", err)
-	}
+		// Save the updated contact to the database, making it the only primary contact when flagged
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			if existingContact.Primary {
+				if err := clearPrimaryContact(tx, existingContact.PatientID, existingContact.Phone); err != nil {
+					return err
+				}
+			}
+			return tx.Omit("Patient").Save(existingContact).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update emergency contact: %w", err)
+		}
+		if existingContact.Primary {
+			// Another contact of the patient may have lost its primary flag
+			if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contact", contact.PatientID)+":*"); err != nil {
+				return fmt.Errorf("failed to delete patient emergency contacts cache: %w", err)
+			}
+		}
 
-	// Delete cache for the updated emergency contact and all emergency contacts
-	if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(contact.PatientID, contact.ID)); err != nil {
-		return fmt.Errorf("failed to delete emergency contact cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "emergency_contacts_cache"); err != nil {
-		return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all emergency contacts cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(contact.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+		// Delete cache for the updated emergency contact and all emergency contacts
+		if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(ctx, contact.PatientID, contact.ID)); err != nil {
+			return fmt.Errorf("failed to delete emergency contact cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contacts")); err != nil {
+			return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all emergency contacts cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, contact.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
 func (r *EmergencyContactRepository) GetByID(ctx context.Context, patientID string, id uint) (*models.EmergencyContact, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getEmergencyContactCacheKey(patientID, id)
-	cachedContact, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var contact models.EmergencyContact
-		if err := json.Unmarshal([]byte(cachedContact), &contact); err != nil {
-			log.Printf("Failed to unmarshal emergency contact from cache: %v", err)
-		} else {
-			return &contact, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getEmergencyContactCacheKey(ctx, patientID, id)
+	var contact models.EmergencyContact
+	if found, err := r.cache.GetObject(ctx, cacheKey, &contact); err != nil {
 		log.Printf("Failed to get emergency contact from cache: %v", err)
+	} else if found {
+		return &contact, nil
 	}
 
-	var contact models.EmergencyContact
-	err = database.DB.Select("id, patient_id, name, phone, relationship").
+	err := database.DB.WithContext(ctx).Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by, updated_at").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
@@ -169,109 +147,139 @@ func (r *EmergencyContactRepository) GetByID(ctx context.Context, patientID stri
 		return nil, fmt.Errorf("failed to get emergency contact: %w", err)
 	}
 
-	contactJSON, err := json.Marshal(contact)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal emergency contact: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, contactJSON, EmergencyContactCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, contact, cache.ItemTTL("emergency_contact")); err != nil {
 		log.Printf("Failed to set emergency contact in cache: %v", err)
 	}
 
 	return &contact, nil
 }
 
-func (r *EmergencyContactRepository) GetAll(ctx context.Context) ([]models.EmergencyContact, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+// GetPrimary returns the patient's primary emergency contact, or nil when none is flagged
+func (r *EmergencyContactRepository) GetPrimary(ctx context.Context, patientID string) (*models.EmergencyContact, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "emergency_contacts_cache"
-	cachedContacts, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var contacts []models.EmergencyContact
-		if err := json.Unmarshal([]byte(cachedContacts), &contacts); err == nil {
-			return contacts, nil
+	var contact models.EmergencyContact
+	err := database.DB.WithContext(ctx).Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by, updated_at").
+		First(&contact, "patient_id = ? AND is_primary", patientID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get emergency contacts from cache: %v", err)
+		return nil, fmt.Errorf("failed to get primary emergency contact: %w", err)
 	}
+	return &contact, nil
+}
 
-	var contacts []models.EmergencyContact
-	err = database.DB.Select("id, patient_id, name, phone, relationship").
-		Preload("Patient", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, first_name, last_name")
-		}).
-		Find(&contacts).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all emergency contacts: %w", err)
+// SetSMSConsent records whether the contact agreed to be texted about the patient, reporting false
+// when the patient has no such contact
+func (r *EmergencyContactRepository) SetSMSConsent(ctx context.Context, contact *models.EmergencyContact) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.EmergencyContact{}).
+		Where("patient_id = ? AND id = ?", contact.PatientID, contact.ID).
+		Updates(map[string]interface{}{
+			"sms_consent":    contact.SMSConsent,
+			"sms_consent_at": contact.SMSConsentAt,
+			"sms_consent_by": contact.SMSConsentBy,
+			"updated_at":     time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update emergency contact consent: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
 	}
 
-	contactsJSON, err := json.Marshal(contacts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal emergency contacts: %w", err)
+	if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(ctx, contact.PatientID, contact.ID)); err != nil {
+		return true, fmt.Errorf("failed to delete emergency contact cache: %w", err)
+	}
+	if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contacts")); err != nil {
+		return true, fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
 	}
-	if err := r.cache.Set(ctx, cacheKey, contactsJSON, EmergencyContactCacheExpiry); err != nil {
-		log.Printf("Failed to set emergency contacts in cache: %v", err)
+	if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, contact.PatientID)); err != nil {
+		return true, fmt.Errorf("failed to delete patient cache: %w", err)
 	}
+	return true, r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+}
 
-	return contacts, nil
+func (r *EmergencyContactRepository) GetAll(ctx context.Context) ([]models.EmergencyContact, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "emergency_contacts"), "emergency_contact", func(ctx context.Context) ([]models.EmergencyContact, error) {
+		var contacts []models.EmergencyContact
+		err := database.DB.WithContext(ctx).Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by, updated_at").
+			Preload("Patient", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id, first_name, last_name")
+			}).
+			Find(&contacts).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all emergency contacts: %w", err)
+		}
+		return contacts, nil
+	})
 }
 
 func (r *EmergencyContactRepository) Delete(ctx context.Context, patientID string, id uint) error {
-	lockKey := fmt.Sprintf("emergency_contact_lock:%s_%d", patientID, id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("emergency_contact_lock:%s_%d", patientID, id), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.EmergencyContact{}, "patient_id = ? AND id = ?", patientID, id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete emergency contact: %w", err)
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+		// Delete cache for the deleted emergency contact and all emergency contacts
+		if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(ctx, patientID, id)); err != nil {
+			return fmt.Errorf("failed to delete emergency contact cache: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contacts")); err != nil {
+			return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
 		}
-	}()
+		// Invalidate the specific patient cache and all emergency contacts cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
 
-	err = database.DB.Delete(&models.EmergencyContact{}, "patient_id = ? AND id = ?", patientID, id).Error
-	if err != nil {
-		return fmt.Errorf("failed to delete emergency contact: %w", err)
-	}
-	// Delete cache for the deleted emergency contact and all emergency contacts
-	if err := r.cache.Delete(ctx, r.getEmergencyContactCacheKey(patientID, id)); err != nil {
-		return fmt.Errorf("failed to delete emergency contact cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "emergency_contacts_cache"); err != nil {
-		return fmt.Errorf("failed to delete all emergency contacts cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all emergency contacts cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(patientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+// InvalidatePatientCache drops the cached contacts of a deleted patient on PatientDeleted
+func (r *EmergencyContactRepository) InvalidatePatientCache(ctx context.Context, event events.Event) error {
+	for _, id := range relatedIDs(event, "emergency_contact") {
+		if err := r.DeleteCache(ctx, event.PatientID, id); err != nil {
+			return err
+		}
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return r.DeleteAllCache(ctx)
 }
 
 func (r *EmergencyContactRepository) DeleteCache(ctx context.Context, patientID string, id uint) error {
-	return r.cache.Delete(ctx, r.getEmergencyContactCacheKey(patientID, id))
+	return r.cache.Delete(ctx, r.getEmergencyContactCacheKey(ctx, patientID, id))
 }
 
 func (r *EmergencyContactRepository) DeleteAllCache(ctx context.Context) error {
-	return r.cache.DeleteAll(ctx, "emergency_contacts_cache")
+	return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "emergency_contacts"))
 }
 
-func (r *EmergencyContactRepository) getEmergencyContactCacheKey(patientID string, id uint) string {
-	return fmt.Sprintf("emergency_contact_cache:%s_%d", patientID, id)
+func (r *EmergencyContactRepository) getEmergencyContactCacheKey(ctx context.Context, patientID string, id uint) string {
+	return r.cache.Key(ctx, "emergency_contact", patientID, id)
 }
 
-func (r *EmergencyContactRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+func (r *EmergencyContactRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
+}
+
+// clearPrimaryContact unsets the primary flag on the patient's contacts other than the one with phone,
+// so a patient never has more than one primary contact
+func clearPrimaryContact(tx *gorm.DB, patientID, phone string) error {
+	err := tx.Model(&models.EmergencyContact{}).
+		Where("patient_id = ? AND phone <> ? AND is_primary", patientID, phone).
+		Update("is_primary", false).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear primary emergency contact: %w", err)
+	}
+	return nil
 }
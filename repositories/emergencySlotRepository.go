@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmergencySlotRepository stores the parts of the schedule held back for emergencies
+type EmergencySlotRepository struct{}
+
+func NewEmergencySlotRepository() *EmergencySlotRepository {
+	return &EmergencySlotRepository{}
+}
+
+func (r *EmergencySlotRepository) Create(ctx context.Context, slot *models.EmergencySlot) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(slot).Error; err != nil {
+		return fmt.Errorf("failed to create emergency slot: %w", err)
+	}
+	return nil
+}
+
+func (r *EmergencySlotRepository) Get(ctx context.Context, id uint) (*models.EmergencySlot, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var slot models.EmergencySlot
+	if err := database.DB.WithContext(ctx).First(&slot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get emergency slot: %w", err)
+	}
+	return &slot, nil
+}
+
+// List returns every emergency slot, the weekly ones first, by day and time
+func (r *EmergencySlotRepository) List(ctx context.Context) ([]models.EmergencySlot, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var slots []models.EmergencySlot
+	if err := database.DB.WithContext(ctx).Order("date NULLS FIRST, weekday, start_time, id").Find(&slots).Error; err != nil {
+		return nil, fmt.Errorf("failed to list emergency slots: %w", err)
+	}
+	return slots, nil
+}
+
+// On returns the emergency slots held on the clinic day day falls on, earliest first
+func (r *EmergencySlotRepository) On(ctx context.Context, day time.Time) ([]models.EmergencySlot, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	day = day.In(models.ClinicLocation())
+	var slots []models.EmergencySlot
+	err := database.DB.WithContext(ctx).
+		Where("date = ? OR (COALESCE(date, '') = '' AND weekday = ?)", day.Format("2006-01-02"), int(day.Weekday())).
+		Order("start_time, id").
+		Find(&slots).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emergency slots: %w", err)
+	}
+	return slots, nil
+}
+
+func (r *EmergencySlotRepository) Update(ctx context.Context, slot *models.EmergencySlot) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(slot).Select("doctor_id", "date", "weekday", "start_time", "end_time", "note", "updated_at").Updates(slot).Error
+	if err != nil {
+		return fmt.Errorf("failed to update emergency slot: %w", err)
+	}
+	return nil
+}
+
+func (r *EmergencySlotRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.EmergencySlot{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete emergency slot: %w", err)
+	}
+	return nil
+}
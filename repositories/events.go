@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"RoyDental/events"
+	"log"
+	"strconv"
+)
+
+// relatedIDs returns the numeric IDs of the records of table an event names
+func relatedIDs(event events.Event, table string) []uint {
+	var ids []uint
+	for _, value := range event.Related[table] {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring %s ID %q of %s event: %v", table, value, event.Name, err)
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
@@ -3,23 +3,20 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-const (
-	ExaminationCacheExpiry = 7 * 24 * time.Hour
-)
-
 type ExaminationRepository struct {
 	cache *cache.Cache
 }
@@ -29,69 +26,49 @@ func NewExaminationRepository(cache *cache.Cache) *ExaminationRepository {
 }
 
 func (r *ExaminationRepository) Create(ctx context.Context, examination *models.Examination) error {
-	lockKey := fmt.Sprintf("examination_lock:%d", examination.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("examination_lock:%d", examination.ID), func(tx *gorm.DB) error {
+		// Attachments are uploaded separately, once the examination exists
+		err := tx.Omit("Attachments").Create(examination).Error
+		if err != nil {
+			return fmt.Errorf("failed to create examination: %w", err)
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+		// Delete cache for the newly created examination and all examinations
+		if err := r.cache.Delete(ctx, r.getExaminationCacheKey(ctx, examination.PatientID, examination.ID)); err != nil {
+			return fmt.Errorf("failed to delete examination cache: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "examinations")); err != nil {
+			return fmt.Errorf("failed to delete all examinations cache: %w", err)
 		}
-	}()
-
-	err = database.DB.Create(examination).Error
-	if err != nil {
-		return fmt.Errorf("failed to create examination: %w", err)
-	}
-	// Delete cache for the newly created examination and all examinations
-	if err := r.cache.Delete(ctx, r.getExaminationCacheKey(examination.PatientID, examination.ID)); err != nil {
-		return fmt.Errorf("failed to delete examination cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "examinations_cache"); err != nil {
-		return fmt.Errorf("failed to delete all examinations cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all examinations cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(examination.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+		// Invalidate the specific patient cache and all examinations cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, examination.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
 func (r *ExaminationRepository) GetByID(ctx context.Context, patientID string, id uint) (*models.Examination, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getExaminationCacheKey(patientID, id)
-	cachedExamination, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var examination models.Examination
-		if err := json.Unmarshal([]byte(cachedExamination), &examination); err == nil {
-			return &examination, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getExaminationCacheKey(ctx, patientID, id)
+	var examination models.Examination
+	if found, err := r.cache.GetObject(ctx, cacheKey, &examination); err != nil {
 		log.Printf("Failed to get examination from cache: %v", err)
+	} else if found {
+		return &examination, nil
 	}
 
-	var examination models.Examination
-	err = database.DB.Select("id, patient_id, report, created_at").
+	err := database.DB.WithContext(ctx).Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+			return db.Select(attachmentColumns).Order("created_at ASC")
+		}).
 		First(&examination, "id = ? AND patient_id = ?", id, patientID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -100,11 +77,7 @@ func (r *ExaminationRepository) GetByID(ctx context.Context, patientID string, i
 		return nil, fmt.Errorf("failed to get examination: %w", err)
 	}
 
-	examinationJSON, err := json.Marshal(examination)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal examination: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, examinationJSON, ExaminationCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, examination, cache.ItemTTL("examination")); err != nil {
 		log.Printf("Failed to set examination in cache: %v", err)
 	}
 
@@ -112,147 +85,208 @@ func (r *ExaminationRepository) GetByID(ctx context.Context, patientID string, i
 }
 
 func (r *ExaminationRepository) GetAll(ctx context.Context) ([]models.Examination, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "examinations_cache"
-	cachedExaminations, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "examinations"), "examination", func(ctx context.Context) ([]models.Examination, error) {
 		var examinations []models.Examination
-		if err := json.Unmarshal([]byte(cachedExaminations), &examinations); err == nil {
-			return examinations, nil
+		err := database.DB.WithContext(ctx).Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
+			Preload("Patient", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id, first_name, last_name")
+			}).
+			Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+				return db.Select(attachmentColumns).Order("created_at ASC")
+			}).
+			Order("created_at DESC").
+			Find(&examinations).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all examinations: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get examinations from cache: %v", err)
-	}
+		return examinations, nil
+	})
+}
 
-	var examinations []models.Examination
-	err = database.DB.Select("id, patient_id, report, created_at").
-		Preload("Patient", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, first_name, last_name")
-		}).
-		Order("created_at DESC").
-		Find(&examinations).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all examinations: %w", err)
+// ListByPatientAfter returns up to limit of the patient's examinations newest first, starting after
+// the cursor when one is given
+func (r *ExaminationRepository) ListByPatientAfter(ctx context.Context, patientID string, after *models.ListCursor, limit int) ([]models.Examination, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		id, err := strconv.ParseUint(after.Key, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidCursor
+		}
+		createdAt, key = after.CreatedAt, id
 	}
+	return listNewestFirst[models.Examination](ctx, "id", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
+			Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+				return db.Select(attachmentColumns).Order("created_at ASC")
+			}).
+			Where("patient_id = ?", patientID)
+	}, createdAt, key, limit)
+}
 
-	examinationsJSON, err := json.Marshal(examinations)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal examinations: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, examinationsJSON, ExaminationCacheExpiry); err != nil {
-		log.Printf("Failed to set examinations in cache: %v", err)
-	}
+func (r *ExaminationRepository) Update(ctx context.Context, examination *models.Examination) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	return examinations, nil
+	return database.WithLock(ctx, fmt.Sprintf("examination_lock:%d", examination.ID), func(tx *gorm.DB) error {
+		// Who captured the record never changes
+		err := tx.Omit("created_by", "Attachments").Save(examination).Error
+		if err != nil {
+			return fmt.Errorf("failed to update examination: %w", err)
+		}
+		// Delete cache for the updated examination and all examinations
+		if err := r.cache.Delete(ctx, r.getExaminationCacheKey(ctx, examination.PatientID, examination.ID)); err != nil {
+			return fmt.Errorf("failed to delete examination cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "examinations")); err != nil {
+			return fmt.Errorf("failed to delete all examinations cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all examinations cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, examination.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
-func (r *ExaminationRepository) Update(ctx context.Context, examination *models.Examination) error {
-	lockKey := fmt.Sprintf("examination_lock:%d", examination.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
+func (r *ExaminationRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("examination_lock:%d", id), func(tx *gorm.DB) error {
+		var examination models.Examination
+		if err := tx.First(&examination, "id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to find examination: %w", err)
 		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+
+		err := tx.Delete(&models.Examination{}, "id = ?", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete examination: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the deleted examination and all examinations
+		if err := r.cache.Delete(ctx, r.getExaminationCacheKey(ctx, examination.PatientID, id)); err != nil {
+			return fmt.Errorf("failed to delete examination cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "examinations")); err != nil {
+			return fmt.Errorf("failed to delete all examinations cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all examinations cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, examination.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
-	}()
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
+
+// SearchFindings returns the examinations with a finding matching filter, latest first. The
+// findings are matched by JSONB containment, which the GIN index on them serves.
+func (r *ExaminationRepository) SearchFindings(ctx context.Context, filter models.FindingFilter) ([]models.FindingMatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	err = database.DB.Save(examination).Error
+	query, err := r.findings(database.DB.WithContext(ctx), filter)
 	if err != nil {
-		return fmt.Errorf("failed to update examination: %w", err)
+		return nil, err
 	}
-	// Delete cache for the updated examination and all examinations
-	if err := r.cache.Delete(ctx, r.getExaminationCacheKey(examination.PatientID, examination.ID)); err != nil {
-		return fmt.Errorf("failed to delete examination cache: %w", err)
+	var matches []models.FindingMatch
+	err = query.Select("e.id AS examination_id, e.patient_id, CONCAT_WS(' ', p.first_name, NULLIF(p.middle_name, ''), p.last_name) AS patient_name, " +
+		"e.created_at AS examined_at, e.findings").
+		Joins("JOIN patient p ON p.id = e.patient_id").
+		Order("e.created_at DESC, e.id DESC").
+		Limit(filter.Limit).
+		Scan(&matches).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search examination findings: %w", err)
 	}
-	if err := r.cache.DeleteAll(ctx, "examinations_cache"); err != nil {
-		return fmt.Errorf("failed to delete all examinations cache: %w", err)
+	return matches, nil
+}
+
+// CountFindingPatients returns how many patients have an examination with a finding matching filter
+func (r *ExaminationRepository) CountFindingPatients(ctx context.Context, filter models.FindingFilter) (int, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query, err := r.findings(database.DB.WithContext(ctx), filter)
+	if err != nil {
+		return 0, err
 	}
-	// Invalidate the specific patient cache and all examinations cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(examination.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	var count int64
+	if err := query.Distinct("e.patient_id").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count patients with examination findings: %w", err)
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return int(count), nil
 }
 
-func (r *ExaminationRepository) Delete(ctx context.Context, id uint) error {
-	lockKey := fmt.Sprintf("examination_lock:%d", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
+// findings narrows db down to the examinations with a finding matching filter. A finding in one of
+// several stages or grades matches one of the findings contained, one for each combination.
+func (r *ExaminationRepository) findings(db *gorm.DB, filter models.FindingFilter) (*gorm.DB, error) {
+	stages, grades := filter.Stages, filter.Grades
+	if len(stages) == 0 {
+		stages = []string{""}
 	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+	if len(grades) == 0 {
+		grades = []string{""}
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+	var conditions []string
+	var args []interface{}
+	for _, stage := range stages {
+		for _, grade := range grades {
+			finding := map[string]interface{}{"condition": filter.Condition}
+			if stage != "" {
+				finding["stage"] = stage
+			}
+			if grade != "" {
+				finding["grade"] = grade
+			}
+			if filter.Extent != "" {
+				finding["extent"] = filter.Extent
+			}
+			if filter.Tooth != 0 {
+				finding["teeth"] = []int{filter.Tooth}
+			}
+			contained, err := json.Marshal([]interface{}{finding})
+			if err != nil {
+				return nil, fmt.Errorf("failed to search examination findings: %w", err)
+			}
+			conditions = append(conditions, "e.findings @> ?::jsonb")
+			args = append(args, string(contained))
 		}
-	}()
-
-	var examination models.Examination
-	if err := database.DB.First(&examination, "id = ?", id).Error; err != nil {
-		return fmt.Errorf("failed to find examination: %w", err)
-	}
-
-	err = database.DB.Delete(&models.Examination{}, "id = ?", id).Error
-	if err != nil {
-		return fmt.Errorf("failed to delete examination: %w", err)
 	}
-	// Delete cache for the deleted examination and all examinations
-	if err := r.cache.Delete(ctx, r.getExaminationCacheKey(examination.PatientID, id)); err != nil {
-		return fmt.Errorf("failed to delete examination cache: %w", err)
+	db = db.Table("examination e").Where("("+strings.Join(conditions, " OR ")+")", args...)
+	if filter.From != nil {
+		db = db.Where("e.created_at >= ?", *filter.From)
 	}
-	if err := r.cache.DeleteAll(ctx, "examinations_cache"); err != nil {
-		return fmt.Errorf("failed to delete all examinations cache: %w", err)
+	if filter.To != nil {
+		db = db.Where("e.created_at < ?", filter.To.AddDate(0, 0, 1))
 	}
-	// Invalidate the specific patient cache and all examinations cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(examination.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	return db, nil
+}
+
+// InvalidatePatientCache drops the cached examinations of a deleted patient on PatientDeleted
+func (r *ExaminationRepository) InvalidatePatientCache(ctx context.Context, event events.Event) error {
+	for _, id := range relatedIDs(event, "examination") {
+		if err := r.DeleteCache(ctx, event.PatientID, id); err != nil {
+			return err
+		}
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return r.DeleteAllCache(ctx)
 }
 
 func (r *ExaminationRepository) DeleteCache(ctx context.Context, patientID string, id uint) error {
-	return r.cache.Delete(ctx, r.getExaminationCacheKey(patientID, id))
+	return r.cache.Delete(ctx, r.getExaminationCacheKey(ctx, patientID, id))
 }
 
 func (r *ExaminationRepository) DeleteAllCache(ctx context.Context) error {
-	return r.cache.DeleteAll(ctx, "examinations_cache")
+	return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "examinations"))
 }
 
-func (r *ExaminationRepository) getExaminationCacheKey(patientID string, id uint) string {
-	return fmt.Sprintf("examination_cache:%s:%d", patientID, id)
+func (r *ExaminationRepository) getExaminationCacheKey(ctx context.Context, patientID string, id uint) string {
+	return r.cache.Key(ctx, "examination", patientID, id)
 }
 
-func (r *ExaminationRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+func (r *ExaminationRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
 }
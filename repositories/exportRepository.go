@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// exportJobColumns are the columns of an export job without its file
+const exportJobColumns = "id, type, format, from_date, to_date, status, error, file_name, requested_by, created_at, completed_at"
+
+type ExportRepository struct{}
+
+func NewExportRepository() *ExportRepository {
+	return &ExportRepository{}
+}
+
+func (r *ExportRepository) Create(ctx context.Context, job *models.ExportJob) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(job).Error; err != nil {
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns the job without its file, or nil when it does not exist
+func (r *ExportRepository) GetByID(ctx context.Context, id uint) (*models.ExportJob, error) {
+	return r.get(ctx, id, exportJobColumns)
+}
+
+// GetWithContent returns the job with its generated file, or nil when it does not exist
+func (r *ExportRepository) GetWithContent(ctx context.Context, id uint) (*models.ExportJob, error) {
+	return r.get(ctx, id, exportJobColumns+", content_type, content, storage_key")
+}
+
+func (r *ExportRepository) get(ctx context.Context, id uint, columns string) (*models.ExportJob, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var job models.ExportJob
+	err := database.DB.WithContext(ctx).Select(columns).First(&job, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get export job: %w", err)
+	}
+	return &job, nil
+}
+
+// List returns the most recent jobs without their files
+func (r *ExportRepository) List(ctx context.Context, limit int) ([]models.ExportJob, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var jobs []models.ExportJob
+	err := database.DB.WithContext(ctx).Select(exportJobColumns).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// PendingIDs returns the jobs waiting for a worker, oldest first
+func (r *ExportRepository) PendingIDs(ctx context.Context) ([]uint, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var ids []uint
+	err := database.DB.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("status = ?", models.ExportStatusPending).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending export jobs: %w", err)
+	}
+	return ids, nil
+}
+
+// Claim marks a pending job as running and reports whether this caller got it, so each job
+// runs once even with several replicas polling
+func (r *ExportRepository) Claim(ctx context.Context, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", id, models.ExportStatusPending).
+		Update("status", models.ExportStatusRunning)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim export job: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Complete stores the generated file of a job
+func (r *ExportRepository) Complete(ctx context.Context, id uint, fileName, contentType string, content []byte, storageKey string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.ExportJob{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.ExportStatusDone,
+			"file_name":    fileName,
+			"content_type": contentType,
+			"content":      content,
+			"storage_key":  storageKey,
+			"completed_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to complete export job: %w", err)
+	}
+	return nil
+}
+
+// Fail records why a job could not be generated
+func (r *ExportRepository) Fail(ctx context.Context, id uint, reason string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.ExportJob{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.ExportStatusFailed,
+			"error":        reason,
+			"completed_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record export job failure: %w", err)
+	}
+	return nil
+}
+
+// GetAccountingEntries returns the bills created between from and to (exclusive) in billing order,
+// with the amounts they had before any adjustment
+func (r *ExportRepository) GetAccountingEntries(ctx context.Context, from, to time.Time) ([]models.AccountingEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AccountingEntry
+	err := database.DB.WithContext(ctx).Table("billing b").
+		Select("b.billing_id, p.first_name || ' ' || p.last_name AS patient_name, b.procedure, "+
+			"b.billing_amount - COALESCE(a.billing_amount, 0) AS billing_amount, "+
+			"b.paid_cash_amount - COALESCE(a.paid_cash_amount, 0) AS paid_cash_amount, "+
+			"b.paid_insurance_amount - COALESCE(a.paid_insurance_amount, 0) AS paid_insurance_amount, b.created_at").
+		Joins("JOIN patient p ON p.id = b.patient_id").
+		Joins("LEFT JOIN (SELECT billing_id, SUM(billing_amount) AS billing_amount, SUM(paid_cash_amount) AS paid_cash_amount, "+
+			"SUM(paid_insurance_amount) AS paid_insurance_amount FROM billing_adjustment GROUP BY billing_id) a ON a.billing_id = b.billing_id").
+		Where("b.created_at >= ? AND b.created_at < ?", from, to).
+		Order("b.created_at, b.billing_id").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounting entries: %w", err)
+	}
+	return entries, nil
+}
+
+// GetAccountingAdjustments returns the adjustments to bills of closed periods made between from and
+// to (exclusive), each as an entry of the differences it made
+func (r *ExportRepository) GetAccountingAdjustments(ctx context.Context, from, to time.Time) ([]models.AccountingEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AccountingEntry
+	err := database.DB.WithContext(ctx).Table("billing_adjustment a").
+		Select("a.billing_id, p.first_name || ' ' || p.last_name AS patient_name, b.procedure, a.billing_amount, a.paid_cash_amount, a.paid_insurance_amount, a.created_at, true AS adjustment, a.reason AS adjustment_reason").
+		Joins("JOIN billing b ON b.billing_id = a.billing_id").
+		Joins("JOIN patient p ON p.id = b.patient_id").
+		Where("a.created_at >= ? AND a.created_at < ?", from, to).
+		Order("a.created_at, a.id").
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounting adjustments: %w", err)
+	}
+	return entries, nil
+}
+
+// GetControlledRegisterEntries returns the register entries for prescriptions written between from and
+// to (exclusive) in register order
+func (r *ExportRepository) GetControlledRegisterEntries(ctx context.Context, from, to time.Time) ([]models.ControlledRegisterEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.ControlledRegisterEntry
+	err := database.DB.WithContext(ctx).
+		Where("prescribed_on >= ? AND prescribed_on < ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("number").
+		Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get controlled register entries: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FailedDeliveryRepository keeps the notifications that failed to be delivered, those still tried
+// again and those in the dead-letter queue
+type FailedDeliveryRepository struct{}
+
+func NewFailedDeliveryRepository() *FailedDeliveryRepository {
+	return &FailedDeliveryRepository{}
+}
+
+func (r *FailedDeliveryRepository) Create(ctx context.Context, delivery *models.FailedDelivery) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(delivery).Error; err != nil {
+		return fmt.Errorf("failed to queue failed delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue takes up to limit deliveries whose next attempt is due by now, oldest first, and puts
+// their next attempt off by lease, so replicas claiming at once each get different deliveries and
+// a replica that dies while trying them does not lose them
+func (r *FailedDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.FailedDelivery, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var deliveries []models.FailedDelivery
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryRetrying, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.FailedDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim failed deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Reschedule stores how the last attempt at a delivery went: its status, attempts, error and when
+// it is tried next
+func (r *FailedDeliveryRepository) Reschedule(ctx context.Context, delivery *models.FailedDelivery) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(delivery).
+		Select("status", "attempts", "last_error", "next_attempt_at").Updates(delivery).Error
+	if err != nil {
+		return fmt.Errorf("failed to reschedule failed delivery: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a delivery that went through or is not tried any more
+func (r *FailedDeliveryRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.FailedDelivery{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete failed delivery: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a delivery, or nil when there is none
+func (r *FailedDeliveryRepository) GetByID(ctx context.Context, id uint) (*models.FailedDelivery, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var delivery models.FailedDelivery
+	if err := database.DB.WithContext(ctx).First(&delivery, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get failed delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListDead returns a page of the dead-letter queue, latest failures first, of the transport when
+// set, and how many deliveries it holds
+func (r *FailedDeliveryRepository) ListDead(ctx context.Context, transport string, limit, offset int) ([]models.FailedDelivery, int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.FailedDelivery{}).Where("status = ?", models.DeliveryDead)
+	if transport != "" {
+		query = query.Where("transport = ?", transport)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count failed deliveries: %w", err)
+	}
+	var deliveries []models.FailedDelivery
+	if err := query.Order("updated_at DESC, id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list failed deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// Replay queues the dead deliveries of the transport, every transport's when empty, that have not
+// stopped being of use by now to be tried again at once with every attempt, and returns how many
+// there were
+func (r *FailedDeliveryRepository) Replay(ctx context.Context, transport string, now time.Time) (int64, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.FailedDelivery{}).
+		Where("status = ? AND (expires IS NULL OR expires > ?)", models.DeliveryDead, now)
+	if transport != "" {
+		query = query.Where("transport = ?", transport)
+	}
+	result := query.Updates(map[string]interface{}{"status": models.DeliveryRetrying, "attempts": 0, "next_attempt_at": now})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to replay failed deliveries: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
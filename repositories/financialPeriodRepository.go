@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPeriodClosed is returned when a write would change the books of a closed financial period
+	ErrPeriodClosed = errors.New("financial period is closed")
+	// ErrPeriodAlreadyClosed is returned when closing a period twice
+	ErrPeriodAlreadyClosed = errors.New("financial period is already closed")
+)
+
+// FinancialPeriodRepository stores closed financial periods and the adjustments made to them
+type FinancialPeriodRepository struct{}
+
+func NewFinancialPeriodRepository() *FinancialPeriodRepository {
+	return &FinancialPeriodRepository{}
+}
+
+// Close closes period. It waits for billing writes in flight and holds new ones back until the
+// period is recorded, so none can slip into the period as it closes.
+func (r *FinancialPeriodRepository) Close(ctx context.Context, period *models.FinancialPeriod) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, "financial_period_lock", func(tx *gorm.DB) error {
+		closed, err := periodClosed(tx, period.Period)
+		if err != nil {
+			return err
+		}
+		if closed {
+			return ErrPeriodAlreadyClosed
+		}
+		if err := tx.Exec("LOCK TABLE billing IN SHARE MODE").Error; err != nil {
+			return fmt.Errorf("failed to lock billing: %w", err)
+		}
+		if err := tx.Create(period).Error; err != nil {
+			return fmt.Errorf("failed to close financial period: %w", err)
+		}
+		return nil
+	})
+}
+
+// List returns the closed periods, latest first
+func (r *FinancialPeriodRepository) List(ctx context.Context) ([]models.FinancialPeriod, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var periods []models.FinancialPeriod
+	if err := database.DB.WithContext(ctx).Order("period DESC").Find(&periods).Error; err != nil {
+		return nil, fmt.Errorf("failed to list financial periods: %w", err)
+	}
+	return periods, nil
+}
+
+// ListAdjustments returns the adjustments made to a bill, oldest first
+func (r *FinancialPeriodRepository) ListAdjustments(ctx context.Context, billingID string) ([]models.BillingAdjustment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var adjustments []models.BillingAdjustment
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", billingID).Order("created_at, id").Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to list billing adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+// periodClosed reports whether the financial period has been closed
+func periodClosed(tx *gorm.DB, period string) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.FinancialPeriod{}).Where("period = ?", period).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check financial period: %w", err)
+	}
+	return count > 0, nil
+}
+
+// checkBillingPeriodOpen refuses to change bills created in a closed financial period
+func checkBillingPeriodOpen(tx *gorm.DB, createdAt ...time.Time) error {
+	checked := map[string]bool{}
+	for _, t := range createdAt {
+		period := models.FinancialPeriodOf(t)
+		if checked[period] {
+			continue
+		}
+		checked[period] = true
+		closed, err := periodClosed(tx, period)
+		if err != nil {
+			return err
+		}
+		if closed {
+			return fmt.Errorf("%w: bills of %s can only be corrected with an adjustment", ErrPeriodClosed, period)
+		}
+	}
+	return nil
+}
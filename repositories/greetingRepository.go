@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type GreetingRepository struct{}
+
+func NewGreetingRepository() *GreetingRepository {
+	return &GreetingRepository{}
+}
+
+// BirthdaysOn returns the patients who may have their birthday on day, have an email address,
+// opted in to marketing by email and were not greeted that day yet. Dates of birth are matched on
+// text in every layout they are stored in, so the service checks the date again once parsed.
+// Patients born on 29 February are included on 28 February of other years.
+func (r *GreetingRepository) BirthdaysOn(ctx context.Context, day time.Time, limit int) ([]models.GreetingCandidate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	patterns := birthdayPatterns(day.Month(), day.Day())
+	if day.Month() == time.February && day.Day() == 28 && !isLeapYear(day.Year()) {
+		patterns = append(patterns, birthdayPatterns(time.February, 29)...)
+	}
+
+	var candidates []models.GreetingCandidate
+	err := database.DB.WithContext(ctx).Table("patient p").
+		Select("p.id AS patient_id, p.first_name, p.last_name, p.email, p.date_of_birth").
+		Joins("LEFT JOIN communication_preference cp ON cp.patient_id = p.id").
+		Joins("LEFT JOIN greeting g ON g.patient_id = p.id AND g.day = ?", day.Format("2006-01-02")).
+		Where("p.date_of_birth LIKE ANY (ARRAY[?])", patterns).
+		Where("g.id IS NULL AND COALESCE(p.email, '') <> '' AND COALESCE(cp.marketing, FALSE) AND COALESCE(cp.email, TRUE)").
+		Order("p.id").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get birthdays: %w", err)
+	}
+	return candidates, nil
+}
+
+// birthdayPatterns returns LIKE patterns matching a month and day in the date of birth layouts
+func birthdayPatterns(month time.Month, day int) []string {
+	mm, dd := fmt.Sprintf("%02d", int(month)), fmt.Sprintf("%02d", day)
+	return []string{"%-" + mm + "-" + dd, dd + "/" + mm + "/%", "%/" + mm + "/" + dd, dd + "-" + mm + "-%"}
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// Create records a greeting and reports false when another replica already greeted the patient that day
+func (r *GreetingRepository) Create(ctx context.Context, greeting *models.Greeting) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "patient_id"}, {Name: "day"}},
+		DoNothing: true,
+	}).Create(greeting)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to create greeting: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SetStatus changes the status of a recorded greeting
+func (r *GreetingRepository) SetStatus(ctx context.Context, id uint, status string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(&models.Greeting{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+		return fmt.Errorf("failed to update greeting: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a greeting that could not be delivered so it is retried
+func (r *GreetingRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Greeting{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete greeting: %w", err)
+	}
+	return nil
+}
+
+// List returns the send log from day from through day to (YYYY-MM-DD, both optional), newest first
+func (r *GreetingRepository) List(ctx context.Context, from, to string) ([]models.GreetingLogEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("greeting g").
+		Select("g.*, p.first_name || ' ' || p.last_name AS patient_name").
+		Joins("JOIN patient p ON p.id = g.patient_id").
+		Order("g.sent_at DESC, g.id DESC")
+	if from != "" {
+		query = query.Where("g.day >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("g.day <= ?", to)
+	}
+	var entries []models.GreetingLogEntry
+	if err := query.Scan(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list greetings: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HandoverRepository stores the notes clinicians hand over between shifts and which doctors read them
+type HandoverRepository struct{}
+
+func NewHandoverRepository() *HandoverRepository {
+	return &HandoverRepository{}
+}
+
+func (r *HandoverRepository) Create(ctx context.Context, note *models.HandoverNote) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(note).Error; err != nil {
+		return fmt.Errorf("failed to create handover note: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a note with its read receipts, or nil when there is none
+func (r *HandoverRepository) GetByID(ctx context.Context, id uint) (*models.HandoverNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var note models.HandoverNote
+	if err := r.withReads(database.DB.WithContext(ctx)).First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get handover note: %w", err)
+	}
+	return &note, nil
+}
+
+// List returns the notes handed over to the branch for the day, oldest first
+func (r *HandoverRepository) List(ctx context.Context, branch, date string) ([]models.HandoverNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var notes []models.HandoverNote
+	err := r.withReads(database.DB.WithContext(ctx)).Where("branch = ? AND date = ?", branch, date).
+		Order("created_at, id").Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list handover notes: %w", err)
+	}
+	return notes, nil
+}
+
+// LabArrivals returns the branch's notes of lab work due back on the day, whatever day they were
+// handed over on
+func (r *HandoverRepository) LabArrivals(ctx context.Context, branch, date string) ([]models.HandoverNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var notes []models.HandoverNote
+	err := r.withReads(database.DB.WithContext(ctx)).
+		Where("branch = ? AND kind = ? AND expected_on = ?", branch, models.HandoverKindLabWork, date).
+		Order("created_at, id").Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lab arrivals: %w", err)
+	}
+	return notes, nil
+}
+
+func (r *HandoverRepository) Update(ctx context.Context, note *models.HandoverNote) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(note).Omit(clause.Associations).
+		Select("date", "branch", "kind", "patient_id", "body", "expected_on", "updated_at", "updated_by").
+		Updates(note).Error
+	if err != nil {
+		return fmt.Errorf("failed to update handover note: %w", err)
+	}
+	return nil
+}
+
+func (r *HandoverRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.HandoverNote{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete handover note: %w", err)
+	}
+	return nil
+}
+
+// MarkRead records that the doctor read the note, keeping the time they first read it
+func (r *HandoverRepository) MarkRead(ctx context.Context, read *models.HandoverRead) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(read).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark handover note read: %w", err)
+	}
+	return nil
+}
+
+func (r *HandoverRepository) withReads(db *gorm.DB) *gorm.DB {
+	return db.Preload("Reads", func(db *gorm.DB) *gorm.DB {
+		return db.Order("read_at")
+	})
+}
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HL7Repository stores the hospital's ADT messages and the identifiers they are matched by
+type HL7Repository struct{}
+
+func NewHL7Repository() *HL7Repository {
+	return &HL7Repository{}
+}
+
+func (r *HL7Repository) CreateMessage(ctx context.Context, message *models.HL7Message) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(message).Error; err != nil {
+		return fmt.Errorf("failed to store HL7 message: %w", err)
+	}
+	return nil
+}
+
+// GetMessageByControlID returns a message already received from facility, so resends are answered
+// with the original acknowledgement
+func (r *HL7Repository) GetMessageByControlID(ctx context.Context, facility, controlID string) (*models.HL7Message, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var message models.HL7Message
+	if err := database.DB.WithContext(ctx).First(&message, "facility = ? AND control_id = ?", facility, controlID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get HL7 message: %w", err)
+	}
+	return &message, nil
+}
+
+func (r *HL7Repository) GetMessage(ctx context.Context, id uint) (*models.HL7Message, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var message models.HL7Message
+	if err := database.DB.WithContext(ctx).First(&message, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get HL7 message: %w", err)
+	}
+	return &message, nil
+}
+
+// ListMessages returns messages newest first, limited to status when it is set
+func (r *HL7Repository) ListMessages(ctx context.Context, status string) ([]models.HL7Message, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.HL7Message{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var messages []models.HL7Message
+	if err := query.Order("created_at DESC").Limit(500).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to list HL7 messages: %w", err)
+	}
+	return messages, nil
+}
+
+// ResolveMessage records the outcome of reviewing a pending message
+func (r *HL7Repository) ResolveMessage(ctx context.Context, id uint, status string, patientID *string, reviewer int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.HL7Message{}).
+		Where("id = ? AND status = ?", id, models.HL7StatusPendingReview).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"patient_id":  patientID,
+			"reviewed_by": reviewer,
+			"reviewed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve HL7 message: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("HL7 message is no longer pending review")
+	}
+	return nil
+}
+
+// GetLink returns our patient ID for the hospital's identifier, or "" when they are not linked yet
+func (r *HL7Repository) GetLink(ctx context.Context, facility, externalID string) (string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var link models.HL7PatientLink
+	if err := database.DB.WithContext(ctx).First(&link, "facility = ? AND external_id = ?", facility, externalID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get HL7 patient link: %w", err)
+	}
+	return link.PatientID, nil
+}
+
+// SaveLink links the hospital's identifier to patientID, replacing an earlier link
+func (r *HL7Repository) SaveLink(ctx context.Context, facility, externalID, patientID string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	link := models.HL7PatientLink{Facility: facility, ExternalID: externalID, PatientID: patientID}
+	err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "facility"}, {Name: "external_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"patient_id"}),
+	}).Create(&link).Error
+	if err != nil {
+		return fmt.Errorf("failed to save HL7 patient link: %w", err)
+	}
+	return nil
+}
+
+// FindCandidates returns the IDs of patients with the same last name and date of birth, who may be
+// the person a message describes
+func (r *HL7Repository) FindCandidates(ctx context.Context, lastName, dateOfBirth string) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var ids []string
+	err := database.DB.WithContext(ctx).Model(&models.Patient{}).
+		Where("LOWER(last_name) = LOWER(?) AND date_of_birth = ?", lastName, dateOfBirth).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find matching patients: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,277 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrPatientInHousehold is returned when adding a patient who is already in a household to another
+var ErrPatientInHousehold = errors.New("the patient is already in a household")
+
+// HouseholdRepository stores the households linking the patients of a family
+type HouseholdRepository struct{}
+
+func NewHouseholdRepository() *HouseholdRepository {
+	return &HouseholdRepository{}
+}
+
+// Create inserts the household with its members, refusing patients already in a household
+func (r *HouseholdRepository) Create(ctx context.Context, household *models.Household) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	patientIDs := make([]string, len(household.Members))
+	for i, member := range household.Members {
+		patientIDs[i] = member.PatientID
+	}
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkNotInHousehold(tx, patientIDs...); err != nil {
+			return err
+		}
+		if err := tx.Omit("Members").Create(household).Error; err != nil {
+			return err
+		}
+		for i := range household.Members {
+			household.Members[i].HouseholdID = household.ID
+		}
+		return tx.Omit("Patient").Create(&household.Members).Error
+	})
+	if errors.Is(err, ErrPatientInHousehold) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create household: %w", err)
+	}
+	return r.fillMembers(ctx, household)
+}
+
+// Get returns a household with its members, or nil when there is none
+func (r *HouseholdRepository) Get(ctx context.Context, id uint) (*models.Household, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+// ByPatient returns the household a patient is in, or nil when they are in none
+func (r *HouseholdRepository) ByPatient(ctx context.Context, patientID string) (*models.Household, error) {
+	return r.first(ctx, "id = (SELECT household_id FROM household_member WHERE patient_id = ?)", patientID)
+}
+
+func (r *HouseholdRepository) first(ctx context.Context, where string, args ...any) (*models.Household, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var household models.Household
+	err := database.DB.WithContext(ctx).Preload("Members", orderHouseholdMembers).Where(where, args...).First(&household).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+	if err := r.fillMembers(ctx, &household); err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+// List returns the households with their members by name
+func (r *HouseholdRepository) List(ctx context.Context) ([]models.Household, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var households []models.Household
+	if err := database.DB.WithContext(ctx).Preload("Members", orderHouseholdMembers).Order("name, id").Find(&households).Error; err != nil {
+		return nil, fmt.Errorf("failed to list households: %w", err)
+	}
+	pointers := make([]*models.Household, len(households))
+	for i := range households {
+		pointers[i] = &households[i]
+	}
+	if err := r.fillMembers(ctx, pointers...); err != nil {
+		return nil, err
+	}
+	return households, nil
+}
+
+// Update changes the household's name, phone, address and guarantor. A new guarantor, who must
+// already be a member, takes over the guarantor relationship and the previous one becomes other.
+func (r *HouseholdRepository) Update(ctx context.Context, household *models.Household, previousGuarantorID string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(household).
+			Select("name", "guarantor_id", "phone", "address_street", "address_city", "address_county", "address_postal_code", "updated_at", "updated_by").
+			Updates(household).Error
+		if err != nil || household.GuarantorID == previousGuarantorID {
+			return err
+		}
+		err = tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ? AND patient_id = ?", household.ID, previousGuarantorID).
+			Update("relationship", models.HouseholdRelationshipOther).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ? AND patient_id = ?", household.ID, household.GuarantorID).
+			Updates(map[string]any{"relationship": models.HouseholdRelationshipGuarantor, "guardian_id": nil}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update household: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a household and its members' links to it; the patients themselves are kept
+func (r *HouseholdRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Household{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete household: %w", err)
+	}
+	return nil
+}
+
+// AddMember adds a patient to a household, refusing one already in a household
+func (r *HouseholdRepository) AddMember(ctx context.Context, member *models.HouseholdMember) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkNotInHousehold(tx, member.PatientID); err != nil {
+			return err
+		}
+		return tx.Omit("Patient").Create(member).Error
+	})
+	if errors.Is(err, ErrPatientInHousehold) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add household member: %w", err)
+	}
+	return nil
+}
+
+// UpdateMember changes how a member is related to the guarantor and who their guardian is
+func (r *HouseholdRepository) UpdateMember(ctx context.Context, member *models.HouseholdMember) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(member).Select("relationship", "guardian_id").Updates(member).Error
+	if err != nil {
+		return fmt.Errorf("failed to update household member: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember takes a patient out of a household. Members they were the guardian of fall back to
+// the guarantor.
+func (r *HouseholdRepository) RemoveMember(ctx context.Context, householdID uint, patientID string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ? AND guardian_id = ?", householdID, patientID).
+			Update("guardian_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("household_id = ? AND patient_id = ?", householdID, patientID).Delete(&models.HouseholdMember{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove household member: %w", err)
+	}
+	return nil
+}
+
+// Suggestions returns up to limit groups of patients in no household who share a phone number or
+// a street address, phone matches first
+func (r *HouseholdRepository) Suggestions(ctx context.Context, limit int) ([]models.HouseholdSuggestion, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		Match      string
+		Value      string
+		PatientIDs string
+	}
+	err := database.DB.WithContext(ctx).Raw(`
+		SELECT match, value, patient_ids FROM (
+			SELECT ? AS match, TRIM(p.phone) AS value, STRING_AGG(p.id, ',' ORDER BY p.id) AS patient_ids, 0 AS rank
+			FROM patient p
+			WHERE TRIM(COALESCE(p.phone, '')) <> '' AND NOT EXISTS (SELECT 1 FROM household_member m WHERE m.patient_id = p.id)
+			GROUP BY TRIM(p.phone)
+			HAVING COUNT(*) > 1
+			UNION ALL
+			SELECT ?, LOWER(TRIM(p.address_street)) || ', ' || LOWER(TRIM(COALESCE(p.address_city, ''))), STRING_AGG(p.id, ',' ORDER BY p.id), 1
+			FROM patient p
+			WHERE TRIM(COALESCE(p.address_street, '')) <> '' AND NOT EXISTS (SELECT 1 FROM household_member m WHERE m.patient_id = p.id)
+			GROUP BY LOWER(TRIM(p.address_street)), LOWER(TRIM(COALESCE(p.address_city, '')))
+			HAVING COUNT(*) > 1
+		) s
+		ORDER BY rank, value
+		LIMIT ?`, models.HouseholdMatchPhone, models.HouseholdMatchAddress, limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find household suggestions: %w", err)
+	}
+	suggestions := make([]models.HouseholdSuggestion, len(rows))
+	for i, row := range rows {
+		suggestions[i] = models.HouseholdSuggestion{Match: row.Match, Value: row.Value, PatientIDs: strings.Split(row.PatientIDs, ",")}
+	}
+	return suggestions, nil
+}
+
+// fillMembers fills in the names and dates of birth of the households' members
+func (r *HouseholdRepository) fillMembers(ctx context.Context, households ...*models.Household) error {
+	var patientIDs []string
+	for _, household := range households {
+		for _, member := range household.Members {
+			patientIDs = append(patientIDs, member.PatientID)
+		}
+	}
+	if len(patientIDs) == 0 {
+		return nil
+	}
+	var patients []models.Patient
+	err := database.DB.WithContext(ctx).Select("id", "first_name", "last_name", "date_of_birth").
+		Where("id IN ?", patientIDs).Find(&patients).Error
+	if err != nil {
+		return fmt.Errorf("failed to get household members: %w", err)
+	}
+	byID := make(map[string]models.Patient, len(patients))
+	for _, patient := range patients {
+		byID[patient.ID] = patient
+	}
+	for _, household := range households {
+		for i := range household.Members {
+			member := &household.Members[i]
+			patient := byID[member.PatientID]
+			member.FirstName, member.LastName, member.DateOfBirth = patient.FirstName, patient.LastName, patient.DateOfBirth
+		}
+	}
+	return nil
+}
+
+// checkNotInHousehold refuses patients who are already in a household
+func checkNotInHousehold(tx *gorm.DB, patientIDs ...string) error {
+	var count int64
+	if err := tx.Model(&models.HouseholdMember{}).Where("patient_id IN ?", patientIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check household members: %w", err)
+	}
+	if count > 0 {
+		return ErrPatientInHousehold
+	}
+	return nil
+}
+
+func orderHouseholdMembers(db *gorm.DB) *gorm.DB {
+	return db.Order("CASE relationship WHEN 'guarantor' THEN 0 ELSE 1 END, id")
+}
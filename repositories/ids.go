@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	idFormatsMu sync.RWMutex
+	idFormats   = config.DefaultIDFormatConfig()
+)
+
+// idSequences are the Postgres sequences entities are numbered from when their numbering does not
+// start over each year
+var idSequences = map[string]string{
+	"patient":           "patient_id_seq",
+	"doctor":            "doctor_id_seq",
+	"billing":           "billing_id_seq",
+	"insurance_company": "insurance_company_id_seq",
+}
+
+// SetIDFormats replaces the ID formats of patients, doctors, bills and insurers, typically once at
+// startup. Entities it leaves out keep their default format.
+func SetIDFormats(cfg config.IDFormatConfig) {
+	formats := config.DefaultIDFormatConfig()
+	for entity, format := range cfg {
+		if _, ok := formats[entity]; ok {
+			formats[entity] = format
+		}
+	}
+
+	idFormatsMu.Lock()
+	defer idFormatsMu.Unlock()
+	idFormats = formats
+}
+
+func idFormat(entity string) config.IDFormat {
+	idFormatsMu.RLock()
+	defer idFormatsMu.RUnlock()
+	return idFormats[entity]
+}
+
+// generateID takes the next ID of entity within tx, in its configured format. Numbers that start over
+// each year come from the id_counter table and are given back when tx rolls back; the others come
+// from the entity's sequence, which releaseID winds back.
+func generateID(tx *gorm.DB, entity string) (string, error) {
+	format := idFormat(entity)
+	var number int64
+	if !format.YearlyReset {
+		if err := tx.Raw("SELECT nextval(?::regclass)", idSequences[entity]).Scan(&number).Error; err != nil {
+			return "", fmt.Errorf("failed to obtain next sequence value: %w", err)
+		}
+		return fmt.Sprintf("%s%0*d", format.Prefix, format.Padding, number), nil
+	}
+
+	year := time.Now().In(models.ClinicLocation()).Year()
+	err := tx.Raw(`INSERT INTO id_counter (entity, year, value) VALUES (?, ?, 1)
+		ON CONFLICT (entity, year) DO UPDATE SET value = id_counter.value + 1
+		RETURNING value`, entity, year).Scan(&number).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain next %s number: %w", entity, err)
+	}
+	return fmt.Sprintf("%s%d-%0*d", format.Prefix, year, format.Padding, number), nil
+}
+
+// releaseID gives back the number generateID took from the entity's sequence when the record could not
+// be created. Yearly numbers need nothing: they are given back with the transaction.
+func releaseID(db *gorm.DB, entity string) error {
+	if idFormat(entity).YearlyReset {
+		return nil
+	}
+	sequence := idSequences[entity]
+	return db.Exec("SELECT setval(?::regclass, (SELECT last_value FROM "+sequence+") - 1, false)", sequence).Error
+}
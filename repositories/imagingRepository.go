@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// imagingColumns are the imaging study columns returned in lists; the files are only read when downloaded
+const imagingColumns = "id, patient_id, status, sop_instance_uid, study_instance_uid, study_date, modality, source_patient_id, source_patient_name, file_name, size, has_preview, created_at, matched_at, matched_by"
+
+// ImagingRepository stores the DICOM images received from the imaging unit
+type ImagingRepository struct{}
+
+func NewImagingRepository() *ImagingRepository {
+	return &ImagingRepository{}
+}
+
+func (r *ImagingRepository) Create(ctx context.Context, study *models.ImagingStudy) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(study).Error; err != nil {
+		return fmt.Errorf("failed to create imaging study: %w", err)
+	}
+	return nil
+}
+
+// GetBySOPInstanceUID returns the image already received with uid, so resends are not stored twice
+func (r *ImagingRepository) GetBySOPInstanceUID(ctx context.Context, uid string) (*models.ImagingStudy, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var study models.ImagingStudy
+	if err := database.DB.WithContext(ctx).Select(imagingColumns).First(&study, "sop_instance_uid = ?", uid).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get imaging study: %w", err)
+	}
+	return &study, nil
+}
+
+// GetByID returns the study without its files
+func (r *ImagingRepository) GetByID(ctx context.Context, id uint) (*models.ImagingStudy, error) {
+	return r.get(ctx, imagingColumns, id)
+}
+
+// GetWithContent returns the study with the original file and its preview
+func (r *ImagingRepository) GetWithContent(ctx context.Context, id uint) (*models.ImagingStudy, error) {
+	return r.get(ctx, "*", id)
+}
+
+func (r *ImagingRepository) get(ctx context.Context, columns string, id uint) (*models.ImagingStudy, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var study models.ImagingStudy
+	if err := database.DB.WithContext(ctx).Select(columns).First(&study, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get imaging study: %w", err)
+	}
+	return &study, nil
+}
+
+// List returns studies newest first, limited to a status or patient when they are set
+func (r *ImagingRepository) List(ctx context.Context, status, patientID string) ([]models.ImagingStudy, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Select(imagingColumns)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var studies []models.ImagingStudy
+	if err := query.Order("created_at DESC").Find(&studies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list imaging studies: %w", err)
+	}
+	return studies, nil
+}
+
+// Match links a study to a patient
+func (r *ImagingRepository) Match(ctx context.Context, id uint, patientID string, matchedBy int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.ImagingStudy{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"patient_id": patientID,
+		"status":     models.ImagingStatusMatched,
+		"matched_at": time.Now(),
+		"matched_by": matchedBy,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to match imaging study: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("imaging study not found")
+	}
+	return nil
+}
+
+// PatientExists reports whether a patient has the ID read from an image
+func (r *ImagingRepository) PatientExists(ctx context.Context, patientID string) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Patient{}).Where("id = ?", patientID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to look up patient: %w", err)
+	}
+	return count > 0, nil
+}
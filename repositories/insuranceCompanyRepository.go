@@ -3,23 +3,16 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-const (
-	InsuranceCompanyCacheExpiry = 7 * 24 * time.Hour
-)
-
 type InsuranceCompanyRepository struct {
 	cache *cache.Cache
 }
@@ -29,83 +22,64 @@ func NewInsuranceCompanyRepository(cache *cache.Cache) *InsuranceCompanyReposito
 }
 
 func (r *InsuranceCompanyRepository) Create(ctx context.Context, company *models.InsuranceCompany) error {
-	lockKey := fmt.Sprintf("insurance_company_lock:%s", company.Name)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
-
-	// Check if a record with the same name already exists
-	var existingCompany models.InsuranceCompany
-	if err := database.DB.Where("name = ?", company.Name).First(&existingCompany).Error; err == nil {
-		return fmt.Errorf("insurance company with name %s already exists", company.Name)
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("failed to check for existing insurance company: %w", err)
-	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	// Obtain the next sequence value outside the transaction
-	var nextID string
-	if err := database.DB.Raw("SELECT 'IC-' || LPAD(nextval('insurance_company_id_seq')::TEXT, 6, '0')").Scan(&nextID).Error; err != nil {
-		return fmt.Errorf("failed to obtain next sequence value: %w", err)
-	}
+	err := database.WithLock(ctx, fmt.Sprintf("insurance_company_lock:%s", company.Name), func(tx *gorm.DB) error {
+		// Check if a record with the same name already exists
+		var existingCompany models.InsuranceCompany
+		if err := tx.Where("name = ?", company.Name).First(&existingCompany).Error; err == nil {
+			return fmt.Errorf("insurance company with name %s already exists", company.Name)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to check for existing insurance company: %w", err)
+		}
 
-	// Set the obtained ID to the insurance company
-	company.ID = nextID
+		// Obtain the next ID in its configured format
+		nextID, err := generateID(tx, "insurance_company")
+		if err != nil {
+			return err
+		}
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		// Create the insurance company record
-		if err := tx.Create(company).Error; err != nil {
-			// If the creation fails, rollback the sequence
-			if rollbackErr := database.DB.Exec("SELECT setval('insurance_company_id_seq', (SELECT last_value FROM insurance_company_id_seq) - 1, false)").Error; rollbackErr != nil {
-				return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+		// Set the obtained ID to the insurance company
+		company.ID = nextID
+
+		return tx.Transaction(func(tx *gorm.DB) error {
+			// Create the insurance company record
+			if err := tx.Create(company).Error; err != nil {
+				// If the creation fails, rollback the sequence
+				if rollbackErr := releaseID(database.DB.WithContext(ctx), "insurance_company"); rollbackErr != nil {
+					return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
+				}
+				return fmt.Errorf("failed to create insurance company: %w", err)
 			}
-			return fmt.Errorf("failed to create insurance company: %w", err)
-		}
 
-		// Delete cache for the newly created insurance company and all insurance companies
-		if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(company.ID)); err != nil {
-			return fmt.Errorf("failed to delete insurance company cache: %w", err)
-		}
-		return r.cache.DeleteAll(ctx, "insurance_companies_cache")
+			// Delete cache for the newly created insurance company and all insurance companies
+			if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(ctx, company.ID)); err != nil {
+				return fmt.Errorf("failed to delete insurance company cache: %w", err)
+			}
+			return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "insurance_companies"))
+		})
 	})
+	if err != nil {
+		return err
+	}
+	publishInsuranceCompanyChanged(ctx, company.ID)
+	return nil
 }
 
 func (r *InsuranceCompanyRepository) GetByID(ctx context.Context, id string) (*models.InsuranceCompany, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getInsuranceCompanyCacheKey(id)
-	cachedCompany, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var company models.InsuranceCompany
-		if err := json.Unmarshal([]byte(cachedCompany), &company); err == nil {
-			return &company, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getInsuranceCompanyCacheKey(ctx, id)
+	var company models.InsuranceCompany
+	if found, err := r.cache.GetObject(ctx, cacheKey, &company); err != nil {
 		log.Printf("Failed to get insurance company from cache: %v", err)
+	} else if found {
+		return &company, nil
 	}
 
-	var company models.InsuranceCompany
-	err = database.DB.Select("id, name").First(&company, "id = ?", id).Error
+	err := database.DB.WithContext(ctx).Select("id, name, claim_format, updated_at").First(&company, "id = ?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -113,11 +87,7 @@ func (r *InsuranceCompanyRepository) GetByID(ctx context.Context, id string) (*m
 		return nil, fmt.Errorf("failed to get insurance company: %w", err)
 	}
 
-	companyJSON, err := json.Marshal(company)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal insurance company: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, companyJSON, InsuranceCompanyCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, company, cache.ItemTTL("insurance_company")); err != nil {
 		log.Printf("Failed to set insurance company in cache: %v", err)
 	}
 
@@ -125,115 +95,73 @@ func (r *InsuranceCompanyRepository) GetByID(ctx context.Context, id string) (*m
 }
 
 func (r *InsuranceCompanyRepository) GetAll(ctx context.Context) ([]models.InsuranceCompany, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "insurance_companies_cache"
-	cachedCompanies, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "insurance_companies"), "insurance_company", func(ctx context.Context) ([]models.InsuranceCompany, error) {
 		var companies []models.InsuranceCompany
-		if err := json.Unmarshal([]byte(cachedCompanies), &companies); err == nil {
-			return companies, nil
+		err := database.DB.WithContext(ctx).
+			Select("id, name, claim_format, updated_at").
+			Order("id DESC").
+			Find(&companies).
+			Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all insurance companies: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get insurance companies from cache: %v", err)
-	}
-
-	var companies []models.InsuranceCompany
-	err = database.DB.
-		Select("id, name").
-		Order("id DESC").
-		Find(&companies).
-		Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all insurance companies: %w", err)
-	}
-
-	companiesJSON, err := json.Marshal(companies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal insurance companies: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, companiesJSON, InsuranceCompanyCacheExpiry); err != nil {
-		log.Printf("Failed to set insurance companies in cache: %v", err)
-	}
-
-	return companies, nil
+		return companies, nil
+	})
 }
 
 func (r *InsuranceCompanyRepository) Update(ctx context.Context, company *models.InsuranceCompany) error {
-	lockKey := fmt.Sprintf("insurance_company_lock:%s", company.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second)
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("insurance_company_lock:%s", company.ID), func(tx *gorm.DB) error {
+		err := tx.Save(company).Error
+		if err != nil {
+			return fmt.Errorf("failed to update insurance company: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the updated insurance company and all insurance companies
+		if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(ctx, company.ID)); err != nil {
+			return fmt.Errorf("failed to delete insurance company cache: %w", err)
 		}
-	}()
-
-	err = database.DB.Save(company).Error
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "insurance_companies"))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update insurance company: %w", err)
-	}
-	// Delete cache for the updated insurance company and all insurance companies
-	if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(company.ID)); err != nil {
-		return fmt.Errorf("failed to delete insurance company cache: %w", err)
+		return err
 	}
-	return r.cache.DeleteAll(ctx, "insurance_companies_cache")
+	publishInsuranceCompanyChanged(ctx, company.ID)
+	return nil
 }
 
 func (r *InsuranceCompanyRepository) Delete(ctx context.Context, id string) error {
-	lockKey := fmt.Sprintf("insurance_company_lock:%s", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second)
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("insurance_company_lock:%s", id), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.InsuranceCompany{}, "id = ?", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete insurance company: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the deleted insurance company and all insurance companies
+		if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(ctx, id)); err != nil {
+			return fmt.Errorf("failed to delete insurance company cache: %w", err)
 		}
-	}()
-
-	err = database.DB.Delete(&models.InsuranceCompany{}, "id = ?", id).Error
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "insurance_companies"))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete insurance company: %w", err)
+		return err
 	}
-	// Delete cache for the deleted insurance company and all insurance companies
-	if err := r.cache.Delete(ctx, r.getInsuranceCompanyCacheKey(id)); err != nil {
-		return fmt.Errorf("failed to delete insurance company cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "insurance_companies_cache")
+	publishInsuranceCompanyChanged(ctx, id)
+	return nil
+}
+
+// publishInsuranceCompanyChanged tells subscribers, such as the response cache, that an insurance
+// company was saved or removed
+func publishInsuranceCompanyChanged(ctx context.Context, id string) {
+	events.Publish(ctx, events.Event{Name: events.InsuranceCompanyChanged, Record: "insurance_company", RecordID: id})
 }
 
-func (r *InsuranceCompanyRepository) getInsuranceCompanyCacheKey(id string) string {
-	return fmt.Sprintf("insurance_company_cache:%s", id)
+func (r *InsuranceCompanyRepository) getInsuranceCompanyCacheKey(ctx context.Context, id string) string {
+	return r.cache.Key(ctx, "insurance_company", id)
 }
@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// insurerMemberColumns are the patient details an insurer is shown of its members
+const insurerMemberColumns = "p.id AS patient_id, CONCAT_WS(' ', p.first_name, NULLIF(p.middle_name, ''), p.last_name) AS member_name, " +
+	"p.member_number, p.scheme, p.date_of_birth"
+
+// InsurerPortalRepository links insurers' claims officers to their companies and reads the claims
+// and bills they may see. Every read takes the officer's insurance company and is limited to its
+// claims and members, so no other record can be reached through it.
+type InsurerPortalRepository struct{}
+
+func NewInsurerPortalRepository() *InsurerPortalRepository {
+	return &InsurerPortalRepository{}
+}
+
+// Account returns the company a user is linked to, or nil when they are linked to none
+func (r *InsurerPortalRepository) Account(ctx context.Context, userID int64) (*models.InsurerAccount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var account models.InsurerAccount
+	if err := database.DB.WithContext(ctx).First(&account, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get insurer account: %w", err)
+	}
+	return &account, nil
+}
+
+// SaveAccount links the user to the company, in place of any company they were linked to
+func (r *InsurerPortalRepository) SaveAccount(ctx context.Context, account *models.InsurerAccount) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"insurance_company_id", "created_at", "created_by"}),
+	}).Create(account).Error
+	if err != nil {
+		return fmt.Errorf("failed to save insurer account: %w", err)
+	}
+	return nil
+}
+
+// DeleteAccount unlinks the user, reporting false when they were not linked
+func (r *InsurerPortalRepository) DeleteAccount(ctx context.Context, userID int64) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Delete(&models.InsurerAccount{}, "user_id = ?", userID)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete insurer account: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Accounts lists the linked users, of one company when insuranceCompanyID is set
+func (r *InsurerPortalRepository) Accounts(ctx context.Context, insuranceCompanyID string) ([]models.InsurerAccount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if insuranceCompanyID != "" {
+		query = query.Where("insurance_company_id = ?", insuranceCompanyID)
+	}
+	var accounts []models.InsurerAccount
+	if err := query.Order("insurance_company_id, user_id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list insurer accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// Claims returns the company's claims matching filter by service date, newest first
+func (r *InsurerPortalRepository) Claims(ctx context.Context, insuranceCompanyID string, filter models.InsurerFilter) ([]models.InsurerClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := r.claims(database.DB.WithContext(ctx), insuranceCompanyID)
+	if filter.Status != "" {
+		query = query.Where("c.status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("c.service_date >= ?", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query = query.Where("c.service_date <= ?", filter.To.Format("2006-01-02"))
+	}
+	var claims []models.InsurerClaim
+	if err := query.Order("c.service_date DESC, c.id DESC").Limit(filter.Limit).Scan(&claims).Error; err != nil {
+		return nil, fmt.Errorf("failed to list insurer claims: %w", err)
+	}
+	return claims, nil
+}
+
+// Claim returns one of the company's claims, or nil when it has none with the ID
+func (r *InsurerPortalRepository) Claim(ctx context.Context, insuranceCompanyID string, id uint) (*models.InsurerClaim, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var claims []models.InsurerClaim
+	if err := r.claims(database.DB.WithContext(ctx), insuranceCompanyID).Where("c.id = ?", id).Limit(1).Scan(&claims).Error; err != nil {
+		return nil, fmt.Errorf("failed to get insurer claim: %w", err)
+	}
+	if len(claims) == 0 {
+		return nil, nil
+	}
+	return &claims[0], nil
+}
+
+func (r *InsurerPortalRepository) claims(db *gorm.DB, insuranceCompanyID string) *gorm.DB {
+	return db.Table("insurance_claim c").
+		Select(insurerMemberColumns+", c.id, c.billing_id, b.procedure, c.amount, c.status, c.batch_id, c.service_date, c.approved_at, c.created_at").
+		Joins("JOIN patient p ON p.id = c.patient_id").
+		Joins("JOIN billing b ON b.billing_id = c.billing_id").
+		Where("c.insurance_company_id = ?", insuranceCompanyID)
+}
+
+// Billings returns the bills of the company's members matching filter by the day they were raised,
+// newest first. A bill is the company's when it was claimed from it, or when the patient is insured
+// with it and the bill was claimed from no one.
+func (r *InsurerPortalRepository) Billings(ctx context.Context, insuranceCompanyID string, filter models.InsurerFilter) ([]models.InsurerBilling, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := r.billings(database.DB.WithContext(ctx), insuranceCompanyID)
+	if filter.Status != "" {
+		query = query.Where("c.status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("b.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("b.created_at < ?", filter.To.AddDate(0, 0, 1))
+	}
+	var billings []models.InsurerBilling
+	if err := query.Order("b.created_at DESC, b.billing_id DESC").Limit(filter.Limit).Scan(&billings).Error; err != nil {
+		return nil, fmt.Errorf("failed to list insurer billings: %w", err)
+	}
+	return billings, nil
+}
+
+// Billing returns a bill of one of the company's members, or nil when it is not one of theirs
+func (r *InsurerPortalRepository) Billing(ctx context.Context, insuranceCompanyID, billingID string) (*models.InsurerBilling, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var billings []models.InsurerBilling
+	if err := r.billings(database.DB.WithContext(ctx), insuranceCompanyID).Where("b.billing_id = ?", billingID).Limit(1).Scan(&billings).Error; err != nil {
+		return nil, fmt.Errorf("failed to get insurer billing: %w", err)
+	}
+	if len(billings) == 0 {
+		return nil, nil
+	}
+	return &billings[0], nil
+}
+
+func (r *InsurerPortalRepository) billings(db *gorm.DB, insuranceCompanyID string) *gorm.DB {
+	return db.Table("billing b").
+		Select(insurerMemberColumns+", b.billing_id, b.procedure, b.billing_amount, b.paid_insurance_amount, b.paid_cash_amount, b.balance, "+
+			"c.id AS claim_id, c.status AS claim_status, b.created_at").
+		Joins("JOIN patient p ON p.id = b.patient_id").
+		Joins("JOIN insurance_company ic ON ic.id = ?", insuranceCompanyID).
+		Joins("LEFT JOIN insurance_claim c ON c.billing_id = b.billing_id").
+		Where("c.insurance_company_id = ic.id OR (c.id IS NULL AND p.insured AND (p.insurance_company = ic.id OR LOWER(p.insurance_company) = LOWER(ic.name)))")
+}
+
+// LogAccess records a look-up by an insurer's claims officer
+func (r *InsurerPortalRepository) LogAccess(ctx context.Context, access *models.InsurerAccess) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(access).Error; err != nil {
+		return fmt.Errorf("failed to log insurer access: %w", err)
+	}
+	return nil
+}
+
+// Accesses returns the look-ups matching filter, newest first
+func (r *InsurerPortalRepository) Accesses(ctx context.Context, filter models.InsurerAccessFilter) ([]models.InsurerAccess, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.InsurerAccess{})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.InsuranceCompanyID != "" {
+		query = query.Where("insurance_company_id = ?", filter.InsuranceCompanyID)
+	}
+	if filter.DeniedOnly {
+		query = query.Where("denied")
+	}
+	var accesses []models.InsurerAccess
+	if err := query.Order("accessed_at DESC, id DESC").Limit(filter.Limit).Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list insurer accesses: %w", err)
+	}
+	return accesses, nil
+}
@@ -0,0 +1,459 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// balanceTolerance is how far a bill's stored balance may be off its amounts before it counts as wrong
+const balanceTolerance = 0.005
+
+// The cash and insurance a bill has received according to the payments ledger: the payments staff
+// recorded and, for cash, the online payments that went through
+const (
+	ledgerCashSQL = "(SELECT COALESCE(SUM(amount), 0) FROM payment WHERE payment.billing_id = billing.billing_id AND method = 'cash')" +
+		" + (SELECT COALESCE(SUM(amount), 0) FROM online_payment WHERE online_payment.billing_id = billing.billing_id AND status = 'succeeded')"
+	ledgerInsuranceSQL = "(SELECT COALESCE(SUM(amount), 0) FROM payment WHERE payment.billing_id = billing.billing_id AND method = 'insurance')"
+)
+
+// IntegrityRepository finds records that are inconsistent with the rest of the database or the
+// cache, repairs them and keeps the report of each run
+type IntegrityRepository struct {
+	cache *cache.Cache
+}
+
+func NewIntegrityRepository(cache *cache.Cache) *IntegrityRepository {
+	return &IntegrityRepository{cache: cache}
+}
+
+// Start records a new run unless another one is still running, marking runs that have been
+// running since before staleBefore as failed first so a replica that died mid-run does not block
+// the checks for good. It reports whether the run was started.
+func (r *IntegrityRepository) Start(ctx context.Context, run *models.IntegrityRun, staleBefore time.Time) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.IntegrityRun{}).
+		Where("status = ? AND started_at < ?", models.IntegrityStatusRunning, staleBefore).
+		Updates(map[string]interface{}{"status": models.IntegrityStatusFailed, "error": "the run was interrupted", "completed_at": time.Now()}).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to fail interrupted integrity runs: %w", err)
+	}
+
+	run.Status = models.IntegrityStatusRunning
+	result := database.DB.WithContext(ctx).Omit("Issues").Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to start integrity run: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Complete stores the issues the run found and marks it done
+func (r *IntegrityRepository) Complete(ctx context.Context, run *models.IntegrityRun) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	run.Status = models.IntegrityStatusDone
+	run.CompletedAt = &now
+	run.IssueCount = len(run.Issues)
+	run.RepairedCount = 0
+	for i := range run.Issues {
+		run.Issues[i].RunID = run.ID
+		if run.Issues[i].Repaired {
+			run.RepairedCount++
+		}
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(run.Issues) > 0 {
+			if err := tx.CreateInBatches(run.Issues, 200).Error; err != nil {
+				return fmt.Errorf("failed to store integrity issues: %w", err)
+			}
+		}
+		err := tx.Model(&models.IntegrityRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+			"status":         run.Status,
+			"issue_count":    run.IssueCount,
+			"repaired_count": run.RepairedCount,
+			"completed_at":   run.CompletedAt,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to complete integrity run: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *IntegrityRepository) Fail(ctx context.Context, id uint, message string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.IntegrityRun{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": models.IntegrityStatusFailed, "error": message, "completed_at": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to fail integrity run: %w", err)
+	}
+	return nil
+}
+
+// Get returns the run with its issues, or nil when it does not exist
+func (r *IntegrityRepository) Get(ctx context.Context, id uint) (*models.IntegrityRun, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var run models.IntegrityRun
+	err := database.DB.WithContext(ctx).
+		Preload("Issues", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&run, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get integrity run: %w", err)
+	}
+	return &run, nil
+}
+
+// List returns the most recent runs without their issues
+func (r *IntegrityRepository) List(ctx context.Context, limit int) ([]models.IntegrityRun, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var runs []models.IntegrityRun
+	if err := database.DB.WithContext(ctx).Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list integrity runs: %w", err)
+	}
+	return runs, nil
+}
+
+// orphanedRow is a record referring to a parent that no longer exists
+type orphanedRow struct {
+	ID       string
+	ParentID string
+	Status   string
+}
+
+// orphans returns up to limit rows of table whose column refers to a missing row of parent
+func (r *IntegrityRepository) orphans(ctx context.Context, table, idColumn, column, parent, status string, limit int) ([]orphanedRow, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []orphanedRow
+	err := database.DB.WithContext(ctx).Raw(fmt.Sprintf(`SELECT c.%[2]s AS id, c.%[3]s AS parent_id, %[5]s AS status
+		FROM %[1]s c LEFT JOIN %[4]s p ON p.id = c.%[3]s
+		WHERE p.id IS NULL ORDER BY c.%[2]s LIMIT ?`, table, idColumn, column, parent, status), limit).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s without %s: %w", table, parent, err)
+	}
+	return rows, nil
+}
+
+// BillingsWithoutPatient returns bills of patients that no longer exist
+func (r *IntegrityRepository) BillingsWithoutPatient(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	rows, err := r.orphans(ctx, "billing", "billing_id", "patient_id", "patient", "''", limit)
+	if err != nil {
+		return nil, err
+	}
+	issues := make([]models.IntegrityIssue, 0, len(rows))
+	for _, row := range rows {
+		issues = append(issues, models.IntegrityIssue{
+			Check:    models.IntegrityCheckBillingWithoutPatient,
+			RecordID: row.ID,
+			Detail:   fmt.Sprintf("Bill refers to patient %s, who does not exist.", row.ParentID),
+			Repair:   models.IntegrityRepairDelete,
+		})
+	}
+	return issues, nil
+}
+
+// BillingsWithoutDoctor returns bills of doctors that no longer exist. The bill still stands, so
+// it is left to staff to move it to another doctor.
+func (r *IntegrityRepository) BillingsWithoutDoctor(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	rows, err := r.orphans(ctx, "billing", "billing_id", "doctor_id", "doctor", "''", limit)
+	if err != nil {
+		return nil, err
+	}
+	issues := make([]models.IntegrityIssue, 0, len(rows))
+	for _, row := range rows {
+		issues = append(issues, models.IntegrityIssue{
+			Check:    models.IntegrityCheckBillingWithoutDoctor,
+			RecordID: row.ID,
+			Detail:   fmt.Sprintf("Bill refers to doctor %s, who does not exist.", row.ParentID),
+		})
+	}
+	return issues, nil
+}
+
+// AppointmentsWithoutPatient returns appointments of patients that no longer exist
+func (r *IntegrityRepository) AppointmentsWithoutPatient(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	rows, err := r.orphans(ctx, "appointment", "id::text", "patient_id", "patient", "c.status", limit)
+	if err != nil {
+		return nil, err
+	}
+	issues := make([]models.IntegrityIssue, 0, len(rows))
+	for _, row := range rows {
+		issues = append(issues, models.IntegrityIssue{
+			Check:    models.IntegrityCheckAppointmentWithoutPatient,
+			RecordID: row.ID,
+			Detail:   fmt.Sprintf("Appointment (%s) refers to patient %s, who does not exist.", row.Status, row.ParentID),
+			Repair:   models.IntegrityRepairDelete,
+		})
+	}
+	return issues, nil
+}
+
+// AppointmentsWithoutDoctor returns appointments with doctors that no longer exist. Those still
+// to come are cancelled on repair; past ones are kept for the patient's history.
+func (r *IntegrityRepository) AppointmentsWithoutDoctor(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	rows, err := r.orphans(ctx, "appointment", "id::text", "doctor_id", "doctor", "c.status", limit)
+	if err != nil {
+		return nil, err
+	}
+	issues := make([]models.IntegrityIssue, 0, len(rows))
+	for _, row := range rows {
+		issue := models.IntegrityIssue{
+			Check:    models.IntegrityCheckAppointmentWithoutDoctor,
+			RecordID: row.ID,
+			Detail:   fmt.Sprintf("Appointment (%s) refers to doctor %s, who does not exist.", row.Status, row.ParentID),
+		}
+		if row.Status == "scheduled" {
+			issue.Repair = models.IntegrityRepairCancel
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// NegativeBalances returns the bills paid beyond their amount. Overpayments are refunded or
+// credited by staff, so they have no repair.
+func (r *IntegrityRepository) NegativeBalances(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var bills []models.Billing
+	err := database.DB.WithContext(ctx).Select("billing_id, patient_id, billing_amount, total_received, balance").
+		Where("balance < ?", -balanceTolerance).Order("balance").Limit(limit).Find(&bills).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find negative balances: %w", err)
+	}
+	issues := make([]models.IntegrityIssue, 0, len(bills))
+	for _, bill := range bills {
+		issues = append(issues, models.IntegrityIssue{
+			Check:    models.IntegrityCheckNegativeBalance,
+			RecordID: bill.BillingID,
+			Detail: fmt.Sprintf("Bill of patient %s for %.2f has received %.2f, leaving a balance of %.2f.",
+				bill.PatientID, bill.BillingAmount, bill.TotalReceived, bill.Balance),
+		})
+	}
+	return issues, nil
+}
+
+// BalanceMismatches returns the bills whose paid amounts, balance or total received do not add up
+// from their amount and the payments recorded against them
+func (r *IntegrityRepository) BalanceMismatches(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var bills []struct {
+		models.Billing
+		LedgerCash      float64
+		LedgerInsurance float64
+	}
+	ledger := database.DB.Table("billing").Select("billing_id, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, " +
+		ledgerCashSQL + " AS ledger_cash, " + ledgerInsuranceSQL + " AS ledger_insurance")
+	err := database.DB.WithContext(ctx).Table("(?) AS bill", ledger).
+		Where("ABS(paid_cash_amount - ledger_cash) > ? OR ABS(paid_insurance_amount - ledger_insurance) > ? OR "+
+			"ABS(balance - (billing_amount - ledger_cash - ledger_insurance)) > ? OR ABS(total_received - (ledger_cash + ledger_insurance)) > ?",
+			balanceTolerance, balanceTolerance, balanceTolerance, balanceTolerance).
+		Order("billing_id").Limit(limit).Scan(&bills).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mismatched balances: %w", err)
+	}
+	issues := make([]models.IntegrityIssue, 0, len(bills))
+	for _, bill := range bills {
+		computed := bill.Billing
+		computed.PaidCashAmount, computed.PaidInsuranceAmount = bill.LedgerCash, bill.LedgerInsurance
+		computed.ComputeTotals()
+		issues = append(issues, models.IntegrityIssue{
+			Check:    models.IntegrityCheckBalanceMismatch,
+			RecordID: bill.BillingID,
+			Detail: fmt.Sprintf("Bill stores %.2f cash and %.2f insurance received, a balance of %.2f and %.2f received, but its payments come to %.2f cash and %.2f insurance, giving %.2f and %.2f.",
+				bill.PaidCashAmount, bill.PaidInsuranceAmount, bill.Balance, bill.TotalReceived,
+				computed.PaidCashAmount, computed.PaidInsuranceAmount, computed.Balance, computed.TotalReceived),
+			Repair: models.IntegrityRepairRecompute,
+		})
+	}
+	return issues, nil
+}
+
+// recentlyUpdated returns the IDs and update times of the limit most recently updated rows of table
+func (r *IntegrityRepository) recentlyUpdated(ctx context.Context, table, idColumn string, limit int) (map[string]time.Time, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		ID        string
+		UpdatedAt time.Time
+	}
+	err := database.DB.WithContext(ctx).Table(table).Select(idColumn + " AS id, updated_at").
+		Order("updated_at DESC").Limit(limit).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recently updated %s: %w", table, err)
+	}
+	updated := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		updated[row.ID] = row.UpdatedAt
+	}
+	return updated, nil
+}
+
+// cacheMismatches compares the cached copies of the limit most recently updated rows of table
+// with the database. cached reads the cached copy's update time, reporting false when nothing is cached.
+func (r *IntegrityRepository) cacheMismatches(ctx context.Context, check, table, idColumn, entity string, limit int,
+	cached func(ctx context.Context, key string) (time.Time, bool, error)) ([]models.IntegrityIssue, error) {
+	updated, err := r.recentlyUpdated(ctx, table, idColumn, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []models.IntegrityIssue
+	for id, updatedAt := range updated {
+		cachedAt, found, err := cached(ctx, r.cache.Key(ctx, entity, id))
+		if err != nil {
+			log.Printf("Failed to read cached %s %s: %v", entity, id, err)
+			continue
+		}
+		if !found || cachedAt.Equal(updatedAt) {
+			continue
+		}
+		issues = append(issues, models.IntegrityIssue{
+			Check:    check,
+			RecordID: id,
+			Detail: fmt.Sprintf("Cached copy was last updated %s, the database copy %s.",
+				cachedAt.Format(time.RFC3339), updatedAt.Format(time.RFC3339)),
+			Repair: models.IntegrityRepairEvict,
+		})
+	}
+	return issues, nil
+}
+
+// PatientCacheMismatches returns the recently updated patients whose cached copy is out of date
+func (r *IntegrityRepository) PatientCacheMismatches(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	return r.cacheMismatches(ctx, models.IntegrityCheckPatientCache, "patient", "id", "patient", limit,
+		func(ctx context.Context, key string) (time.Time, bool, error) {
+			var patient models.Patient
+			found, err := r.cache.GetObject(ctx, key, &patient)
+			return patient.UpdatedAt, found, err
+		})
+}
+
+// DoctorCacheMismatches returns the recently updated doctors whose cached copy is out of date
+func (r *IntegrityRepository) DoctorCacheMismatches(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	return r.cacheMismatches(ctx, models.IntegrityCheckDoctorCache, "doctor", "id", "doctor", limit,
+		func(ctx context.Context, key string) (time.Time, bool, error) {
+			var doctor models.Doctor
+			found, err := r.cache.GetObject(ctx, key, &doctor)
+			return doctor.UpdatedAt, found, err
+		})
+}
+
+// BillingCacheMismatches returns the recently updated bills whose cached copy is out of date
+func (r *IntegrityRepository) BillingCacheMismatches(ctx context.Context, limit int) ([]models.IntegrityIssue, error) {
+	return r.cacheMismatches(ctx, models.IntegrityCheckBillingCache, "billing", "billing_id", "billing", limit,
+		func(ctx context.Context, key string) (time.Time, bool, error) {
+			var billing models.Billing
+			found, err := r.cache.GetObject(ctx, key, &billing)
+			return billing.UpdatedAt, found, err
+		})
+}
+
+// DeleteBilling deletes an orphaned bill
+func (r *IntegrityRepository) DeleteBilling(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", id).Delete(&models.Billing{}).Error; err != nil {
+		return fmt.Errorf("failed to delete billing: %w", err)
+	}
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", id).Delete(&models.Payment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete billing payments: %w", err)
+	}
+	if err := r.cache.Delete(ctx, r.cache.Key(ctx, "billing", id)); err != nil {
+		return fmt.Errorf("failed to delete billing cache: %w", err)
+	}
+	return deleteListCache(ctx, r.cache, "billings", doctorBillingsCache)
+}
+
+// DeleteAppointment deletes an orphaned appointment
+func (r *IntegrityRepository) DeleteAppointment(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var patientID string
+	if err := database.DB.WithContext(ctx).Raw("DELETE FROM appointment WHERE id = ? RETURNING patient_id", id).Scan(&patientID).Error; err != nil {
+		return fmt.Errorf("failed to delete appointment: %w", err)
+	}
+	return r.dropAppointmentCache(ctx, patientID, id)
+}
+
+// CancelAppointment cancels an appointment that is still scheduled
+func (r *IntegrityRepository) CancelAppointment(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var patientID string
+	err := database.DB.WithContext(ctx).Raw("UPDATE appointment SET status = ? WHERE id = ? AND status = ? RETURNING patient_id",
+		"cancelled", id, "scheduled").Scan(&patientID).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel appointment: %w", err)
+	}
+	return r.dropAppointmentCache(ctx, patientID, id)
+}
+
+func (r *IntegrityRepository) dropAppointmentCache(ctx context.Context, patientID, id string) error {
+	if err := r.cache.DeleteBatch(ctx, r.cache.Key(ctx, "appointment", patientID, id), r.cache.Key(ctx, "patient", patientID)); err != nil {
+		return fmt.Errorf("failed to delete appointment cache: %w", err)
+	}
+	return deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache)
+}
+
+// RecomputeBalance works the bill's paid amounts, balance and total received out again from its
+// amount and the payments recorded against it
+func (r *IntegrityRepository) RecomputeBalance(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var patientID string
+	err := database.DB.WithContext(ctx).Raw(`UPDATE billing
+		SET paid_cash_amount = `+ledgerCashSQL+`,
+			paid_insurance_amount = `+ledgerInsuranceSQL+`,
+			balance = billing_amount - (`+ledgerCashSQL+`) - (`+ledgerInsuranceSQL+`),
+			total_received = `+ledgerCashSQL+` + `+ledgerInsuranceSQL+`
+		WHERE billing_id = ? RETURNING patient_id`, id).Scan(&patientID).Error
+	if err != nil {
+		return fmt.Errorf("failed to recompute billing balance: %w", err)
+	}
+	if err := r.cache.DeleteBatch(ctx, r.cache.Key(ctx, "billing", id), r.cache.Key(ctx, "patient", patientID)); err != nil {
+		return fmt.Errorf("failed to delete billing cache: %w", err)
+	}
+	return deleteListCache(ctx, r.cache, "billings", doctorBillingsCache)
+}
+
+// Evict drops the cached copy of a record of entity
+func (r *IntegrityRepository) Evict(ctx context.Context, entity, id string) error {
+	if err := r.cache.Delete(ctx, r.cache.Key(ctx, entity, id)); err != nil {
+		return fmt.Errorf("failed to delete %s cache: %w", entity, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobQueueRepository counts the jobs of the background queues by status
+type JobQueueRepository struct{}
+
+func NewJobQueueRepository() *JobQueueRepository {
+	return &JobQueueRepository{}
+}
+
+// jobQueueSource is the table a queue keeps its jobs in: status is the column or expression of a
+// job's status, queuedAt the column of when it was queued, empty when the table keeps none, and
+// waiting the statuses of jobs not finished yet, every job's when nil
+type jobQueueSource struct {
+	name     string
+	table    string
+	status   interface{}
+	queuedAt string
+	waiting  []string
+}
+
+// Queues returns the background queues with their jobs counted by status as of now
+func (r *JobQueueRepository) Queues(ctx context.Context, now time.Time) ([]models.JobQueue, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	sources := []jobQueueSource{
+		{name: models.JobQueueExports, table: "export_job", status: gorm.Expr("status"), queuedAt: "created_at",
+			waiting: []string{models.ExportStatusPending, models.ExportStatusRunning}},
+		{name: models.JobQueuePrintJobs, table: "print_job", status: gorm.Expr("status"), queuedAt: "created_at",
+			waiting: []string{models.PrintJobQueued, models.PrintJobPrinting}},
+		{name: models.JobQueueIntegrityChecks, table: "integrity_run", status: gorm.Expr("status"), queuedAt: "started_at",
+			waiting: []string{models.IntegrityStatusRunning}},
+		{name: models.JobQueueDeferredMessages, table: "deferred_message", queuedAt: "created_at",
+			status: gorm.Expr("CASE WHEN send_at <= ? THEN ? ELSE ? END", now, models.DeferredMessageDue, models.DeferredMessageHeld)},
+		{name: models.JobQueueCampaignMessages, table: "campaign_recipient", status: gorm.Expr("status")},
+	}
+
+	db := database.DB.WithContext(ctx)
+	queues := make([]models.JobQueue, 0, len(sources))
+	for _, source := range sources {
+		var rows []struct {
+			Status string
+			Jobs   int64
+		}
+		err := db.Table(source.table).Select("? AS status, COUNT(*) AS jobs", source.status).Group("1").Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", source.name, err)
+		}
+		queue := models.JobQueue{Name: source.name, Statuses: make(map[string]int64, len(rows))}
+		for _, row := range rows {
+			queue.Statuses[row.Status] = row.Jobs
+		}
+
+		if source.queuedAt != "" {
+			query := db.Table(source.table).Select("MIN(" + source.queuedAt + ")")
+			if source.waiting != nil {
+				query = query.Where("status IN ?", source.waiting)
+			}
+			var oldest sql.NullTime
+			if err := query.Scan(&oldest).Error; err != nil {
+				return nil, fmt.Errorf("failed to get the oldest of %s: %w", source.name, err)
+			}
+			if oldest.Valid {
+				queue.OldestWaiting = &oldest.Time
+			}
+		}
+		queues = append(queues, queue)
+	}
+	return queues, nil
+}
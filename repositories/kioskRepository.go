@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KioskRepository serves the waiting-room kiosk. Its queries are narrow on purpose: a kiosk only
+// ever sees today's appointments of a patient it has identified by phone and date of birth.
+type KioskRepository struct {
+	cache *cache.Cache
+}
+
+func NewKioskRepository(cache *cache.Cache) *KioskRepository {
+	return &KioskRepository{cache: cache}
+}
+
+// FindPatientIDs returns the patients matching both phone and date of birth.
+func (r *KioskRepository) FindPatientIDs(ctx context.Context, phone, dateOfBirth string) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var ids []string
+	err := database.DB.WithContext(ctx).Model(&models.Patient{}).
+		Where("phone = ? AND date_of_birth = ?", phone, dateOfBirth).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find patient: %w", err)
+	}
+	return ids, nil
+}
+
+// GetAppointmentsOn returns the patients' appointments on the clinic day day falls on, earliest first.
+func (r *KioskRepository) GetAppointmentsOn(ctx context.Context, patientIDs []string, day time.Time) ([]models.KioskAppointment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	from, to := models.ClinicDay(day)
+	var appointments []models.KioskAppointment
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id, a.patient_id, p.first_name AS patient_first_name, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, a.status").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Where("a.patient_id IN ? AND a.starts_at >= ? AND a.starts_at < ?", patientIDs, from, to).
+		Order("a.starts_at").
+		Scan(&appointments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointments: %w", err)
+	}
+	for i := range appointments {
+		appointments[i].DateTime = models.ClinicDateTime(appointments[i].DateTime)
+	}
+	return appointments, nil
+}
+
+func (r *KioskRepository) CreateContactUpdate(ctx context.Context, update *models.PatientContactUpdate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(update).Error; err != nil {
+		return fmt.Errorf("failed to create contact update: %w", err)
+	}
+	return nil
+}
+
+func (r *KioskRepository) GetContactUpdates(ctx context.Context, status string) ([]models.PatientContactUpdate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Order("created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var updates []models.PatientContactUpdate
+	if err := query.Find(&updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get contact updates: %w", err)
+	}
+	return updates, nil
+}
+
+// ReviewContactUpdate approves or rejects a pending update. Approval copies the submitted
+// details onto the patient in the same transaction.
+func (r *KioskRepository) ReviewContactUpdate(ctx context.Context, id uint, approve bool, reviewer string) (*models.PatientContactUpdate, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var update models.PatientContactUpdate
+	err := database.WithLock(ctx, fmt.Sprintf("contact_update_lock:%d", id), func(tx *gorm.DB) error {
+		if err := tx.First(&update, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("contact update %d not found", id)
+			}
+			return fmt.Errorf("failed to get contact update: %w", err)
+		}
+		if update.Status != models.ContactUpdatePending {
+			return fmt.Errorf("contact update %d is already %s", id, update.Status)
+		}
+
+		now := time.Now()
+		update.Status = models.ContactUpdateRejected
+		if approve {
+			update.Status = models.ContactUpdateApproved
+			changes := map[string]interface{}{}
+			if update.Phone != "" {
+				changes["phone"] = update.Phone
+			}
+			if update.Email != "" {
+				changes["email"] = update.Email
+			}
+			if !update.Address.IsZero() {
+				// A new address is placed on the map again by the geocoding job
+				address := update.Address.Trimmed()
+				changes["address_street"] = address.Street
+				changes["address_city"] = address.City
+				changes["address_county"] = address.County
+				changes["address_postal_code"] = address.PostalCode
+				changes["address_latitude"] = nil
+				changes["address_longitude"] = nil
+				changes["address_geocoded_at"] = nil
+			}
+			if err := tx.Model(&models.Patient{}).Where("id = ?", update.PatientID).Updates(changes).Error; err != nil {
+				return fmt.Errorf("failed to update patient contact details: %w", err)
+			}
+		}
+		update.ReviewedBy = reviewer
+		update.ReviewedAt = &now
+		if err := tx.Save(&update).Error; err != nil {
+			return fmt.Errorf("failed to save contact update: %w", err)
+		}
+
+		if !approve {
+			return nil
+		}
+		if err := r.cache.Delete(ctx, r.cache.Key(ctx, "patient", update.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &update, nil
+}
@@ -0,0 +1,278 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidListQuery is returned for filters and sorts on fields or with values a list does not support
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// Kinds of list fields, which decide the operators and values a filter on them takes
+const (
+	fieldText   = "text"
+	fieldNumber = "number"
+	fieldBool   = "bool"
+	fieldTime   = "time"
+	fieldDate   = "date"
+)
+
+// listField is a field a list can be filtered and sorted on and the SQL it reads
+type listField struct {
+	Expr string
+	Kind string
+}
+
+// listTable is the table a list reads and the column that orders rows otherwise equal
+type listTable struct {
+	Table  string
+	Key    string
+	Fields map[string]listField
+}
+
+// listTables whitelists the fields of each list. Fields of related records are read through
+// subqueries so the lists keep their own columns.
+var listTables = map[string]listTable{
+	models.ListPatients: {Table: "patient", Key: "id", Fields: map[string]listField{
+		"id":                {"patient.id", fieldText},
+		"first_name":        {"patient.first_name", fieldText},
+		"last_name":         {"patient.last_name", fieldText},
+		"sex":               {"patient.sex", fieldText},
+		"date_of_birth":     {"patient.date_of_birth", fieldDate},
+		"insured":           {"patient.insured", fieldBool},
+		"cash":              {"patient.cash", fieldBool},
+		"insurance_company": {"patient.insurance_company", fieldText},
+		"language":          {"patient.language", fieldText},
+		"source":            {"patient.source", fieldText},
+		"campaign_code":     {"patient.campaign_code", fieldText},
+		"created_at":        {"patient.created_at", fieldTime},
+		"updated_at":        {"patient.updated_at", fieldTime},
+		"balance":           {"(SELECT COALESCE(SUM(b.balance), 0) FROM billing b WHERE b.patient_id = patient.id)", fieldNumber},
+		"last_billed_at":    {"(SELECT MAX(b.created_at) FROM billing b WHERE b.patient_id = patient.id)", fieldTime},
+	}},
+	models.ListAppointments: {Table: "appointment", Key: "id", Fields: map[string]listField{
+		"id":              {"appointment.id", fieldNumber},
+		"patient_id":      {"appointment.patient_id", fieldText},
+		"doctor_id":       {"appointment.doctor_id", fieldText},
+		"status":          {"appointment.status", fieldText},
+		"type":            {"appointment.type", fieldText},
+		"origin":          {"appointment.origin", fieldText},
+		"source":          {"appointment.source", fieldText},
+		"campaign_code":   {"appointment.campaign_code", fieldText},
+		"procedure_id":    {"appointment.procedure_id", fieldNumber},
+		"chair_id":        {"appointment.chair_id", fieldNumber},
+		"overbooked":      {"appointment.overbooked", fieldBool},
+		"no_show_risk":    {"appointment.no_show_risk", fieldNumber},
+		"starts_at":       {"appointment.starts_at", fieldTime},
+		"created_at":      {"appointment.created_at", fieldTime},
+		"patient_insured": {"(SELECT p.insured FROM patient p WHERE p.id = appointment.patient_id)", fieldBool},
+	}},
+	models.ListBillings: {Table: "billing", Key: "billing_id", Fields: map[string]listField{
+		"billing_id":            {"billing.billing_id", fieldText},
+		"patient_id":            {"billing.patient_id", fieldText},
+		"doctor_id":             {"billing.doctor_id", fieldText},
+		"procedure":             {"billing.procedure", fieldText},
+		"procedure_id":          {"billing.procedure_id", fieldNumber},
+		"billing_amount":        {"billing.billing_amount", fieldNumber},
+		"paid_cash_amount":      {"billing.paid_cash_amount", fieldNumber},
+		"paid_insurance_amount": {"billing.paid_insurance_amount", fieldNumber},
+		"balance":               {"billing.balance", fieldNumber},
+		"total_received":        {"billing.total_received", fieldNumber},
+		"created_at":            {"billing.created_at", fieldTime},
+		"patient_insured":       {"(SELECT p.insured FROM patient p WHERE p.id = billing.patient_id)", fieldBool},
+	}},
+}
+
+// CheckListQuery reports whether list can be filtered and sorted as query asks
+func CheckListQuery(list string, query models.ListQuery) error {
+	_, err := listFilter(list, query, time.Now())
+	return err
+}
+
+// listFilter returns a scope applying query to the rows of list, ordering them newest first when
+// query gives no sort. Periods are relative to now.
+func listFilter(list string, query models.ListQuery, now time.Time) (func(db *gorm.DB) *gorm.DB, error) {
+	table, ok := listTables[list]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown list %s", ErrInvalidListQuery, list)
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, condition := range query.Criteria {
+		field, ok := table.Fields[condition.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s cannot be filtered on %s", ErrInvalidListQuery, list, condition.Field)
+		}
+		sql, values, err := filterSQL(field, condition, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidListQuery, condition.Field, err)
+		}
+		conditions = append(conditions, sql)
+		args = append(args, values...)
+	}
+
+	var order []string
+	for _, sort := range query.Sort {
+		field, ok := table.Fields[sort.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s cannot be sorted on %s", ErrInvalidListQuery, list, sort.Field)
+		}
+		direction := "ASC NULLS FIRST"
+		if sort.Desc {
+			direction = "DESC NULLS LAST"
+		}
+		order = append(order, field.Expr+" "+direction)
+	}
+	if len(order) == 0 {
+		order = append(order, table.Fields["created_at"].Expr+" DESC")
+	}
+	order = append(order, table.Table+"."+table.Key)
+
+	return func(db *gorm.DB) *gorm.DB {
+		if len(conditions) > 0 {
+			db = db.Where(strings.Join(conditions, " AND "), args...)
+		}
+		return db.Order(strings.Join(order, ", "))
+	}, nil
+}
+
+// filterSQL returns the SQL of a condition on field and the values it binds
+func filterSQL(field listField, condition models.FilterCondition, now time.Time) (string, []interface{}, error) {
+	comparisons := map[string]string{
+		models.FilterEq: "=", models.FilterNe: "<>",
+		models.FilterGt: ">", models.FilterGte: ">=", models.FilterLt: "<", models.FilterLte: "<=",
+	}
+
+	switch condition.Op {
+	case models.FilterEq, models.FilterNe, models.FilterGt, models.FilterGte, models.FilterLt, models.FilterLte:
+		if field.Kind == fieldBool && condition.Op != models.FilterEq && condition.Op != models.FilterNe {
+			return "", nil, fmt.Errorf("only eq and ne apply to true or false")
+		}
+		if field.Kind == fieldTime && (condition.Op == models.FilterEq || condition.Op == models.FilterNe) {
+			return "", nil, fmt.Errorf("times are compared with gt, gte, lt, lte or period")
+		}
+		value, err := filterValue(field.Kind, condition.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s %s ?", field.Expr, comparisons[condition.Op]), []interface{}{value}, nil
+	case models.FilterIn:
+		if field.Kind != fieldText && field.Kind != fieldNumber {
+			return "", nil, fmt.Errorf("in applies to text and numbers")
+		}
+		list, ok := condition.Value.([]interface{})
+		if !ok || len(list) == 0 {
+			return "", nil, fmt.Errorf("in needs a list of values")
+		}
+		values := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			value, err := filterValue(field.Kind, item)
+			if err != nil {
+				return "", nil, err
+			}
+			values = append(values, value)
+		}
+		return fmt.Sprintf("%s IN ?", field.Expr), []interface{}{values}, nil
+	case models.FilterContains:
+		if field.Kind != fieldText {
+			return "", nil, fmt.Errorf("contains applies to text")
+		}
+		text, ok := condition.Value.(string)
+		if !ok || text == "" {
+			return "", nil, fmt.Errorf("contains needs text")
+		}
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+		return fmt.Sprintf("%s ILIKE ?", field.Expr), []interface{}{"%" + escaped + "%"}, nil
+	case models.FilterPeriod:
+		if field.Kind != fieldTime && field.Kind != fieldDate {
+			return "", nil, fmt.Errorf("period applies to times and dates")
+		}
+		period, _ := condition.Value.(string)
+		from, to, err := models.PeriodBounds(period, now)
+		if err != nil {
+			return "", nil, err
+		}
+		if field.Kind == fieldDate {
+			return fmt.Sprintf("%s >= ? AND %s < ?", field.Expr, field.Expr), []interface{}{from.Format("2006-01-02"), to.Format("2006-01-02")}, nil
+		}
+		return fmt.Sprintf("%s >= ? AND %s < ?", field.Expr, field.Expr), []interface{}{from, to}, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator %q", condition.Op)
+}
+
+// filterValue checks a value given for a field of kind, reading times as RFC 3339 or as the start
+// of a clinic day
+func filterValue(kind string, value interface{}) (interface{}, error) {
+	switch kind {
+	case fieldText:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+		return nil, fmt.Errorf("needs text")
+	case fieldNumber:
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+		return nil, fmt.Errorf("needs a number")
+	case fieldBool:
+		if flag, ok := value.(bool); ok {
+			return flag, nil
+		}
+		return nil, fmt.Errorf("needs true or false")
+	case fieldDate:
+		if text, ok := value.(string); ok {
+			if _, err := models.ParseClinicDate(text); err == nil {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("needs a date (YYYY-MM-DD)")
+	case fieldTime:
+		if text, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339, text); err == nil {
+				return t, nil
+			}
+			if t, err := models.ParseClinicDate(text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("needs a time (RFC 3339) or a date (YYYY-MM-DD)")
+	}
+	return nil, fmt.Errorf("unknown field kind %s", kind)
+}
+
+// streamOrdered reads the rows of query in the order it gives, streamBatchSize at a time, and hands
+// each to emit. Unlike streamNewestFirst it moves through the rows by offset, as the order can be
+// on any field.
+func streamOrdered[T any](ctx context.Context, query func(db *gorm.DB) *gorm.DB, emit func(T) error) error {
+	for offset := 0; ; offset += streamBatchSize {
+		batch, err := func() ([]T, error) {
+			ctx, cancel := database.WithReadTimeout(ctx)
+			defer cancel()
+
+			var rows []T
+			if err := query(database.DB.WithContext(ctx)).Offset(offset).Limit(streamBatchSize).Find(&rows).Error; err != nil {
+				return nil, fmt.Errorf("failed to read rows: %w", err)
+			}
+			return rows, nil
+		}()
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// MarketingRepository counts patients, bookings and revenue by where the patients and appointments
+// came from
+type MarketingRepository struct{}
+
+func NewMarketingRepository() *MarketingRepository {
+	return &MarketingRepository{}
+}
+
+// NewPatients counts the patients registered in [from, to) by source and campaign
+func (r *MarketingRepository) NewPatients(ctx context.Context, from, to time.Time) ([]models.MarketingCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.MarketingCount
+	err := database.DB.WithContext(ctx).Model(&models.Patient{}).
+		Select("source, campaign_code, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("source, campaign_code").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count new patients by source: %w", err)
+	}
+	return counts, nil
+}
+
+// Bookings counts the appointments booked in [from, to) by source and campaign, with how many of
+// them were fulfilled and cancelled
+func (r *MarketingRepository) Bookings(ctx context.Context, from, to time.Time) ([]models.MarketingCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.MarketingCount
+	err := database.DB.WithContext(ctx).Model(&models.Appointment{}).
+		Select("source, campaign_code, COUNT(*) AS count, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS fulfilled, COUNT(*) FILTER (WHERE status = ?) AS cancelled",
+			models.AppointmentStatusFulfilled, models.AppointmentStatusCancelled).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("source, campaign_code").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count bookings by source: %w", err)
+	}
+	return counts, nil
+}
+
+// Revenue totals what was billed in [from, to), and received of it, by the source and campaign of
+// the patients billed, counting the patients
+func (r *MarketingRepository) Revenue(ctx context.Context, from, to time.Time) ([]models.MarketingCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.MarketingCount
+	err := database.DB.WithContext(ctx).Table("billing").
+		Select("patient.source, patient.campaign_code, COUNT(DISTINCT billing.patient_id) AS count, "+
+			"COALESCE(SUM(billing.billing_amount), 0) AS amount, COALESCE(SUM(billing.total_received), 0) AS collected").
+		Joins("JOIN patient ON patient.id = billing.patient_id").
+		Where("billing.created_at >= ? AND billing.created_at < ?", from, to).
+		Group("patient.source, patient.campaign_code").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to total revenue by source: %w", err)
+	}
+	return counts, nil
+}
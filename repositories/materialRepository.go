@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MaterialRepository stores the material lots used for billed procedures
+type MaterialRepository struct{}
+
+func NewMaterialRepository() *MaterialRepository {
+	return &MaterialRepository{}
+}
+
+func (r *MaterialRepository) Create(ctx context.Context, usage *models.MaterialUsage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(usage).Error; err != nil {
+		return fmt.Errorf("failed to record material usage: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a material recorded against the bill and reports whether it existed
+func (r *MaterialRepository) Delete(ctx context.Context, billingID string, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Delete(&models.MaterialUsage{}, "billing_id = ? AND id = ?", billingID, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete material usage: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetByBilling returns the materials used for the bill
+func (r *MaterialRepository) GetByBilling(ctx context.Context, billingID string) ([]models.MaterialUsage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var usages []models.MaterialUsage
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", billingID).Order("id").Find(&usages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get materials of billing: %w", err)
+	}
+	return usages, nil
+}
+
+// GetByPatient returns the materials a patient was treated with, latest first
+func (r *MaterialRepository) GetByPatient(ctx context.Context, patientID string) ([]models.MaterialUsage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var usages []models.MaterialUsage
+	if err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("treated_at DESC, id DESC").Find(&usages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get materials of patient: %w", err)
+	}
+	return usages, nil
+}
+
+// Recall returns the patients treated with material from the lot, in treatment order. Lot numbers
+// match whole, ignoring case; manufacturer and product match any part.
+func (r *MaterialRepository) Recall(ctx context.Context, filter models.MaterialRecallFilter) ([]models.MaterialTrace, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("material_usage u").
+		Select("u.billing_id, u.patient_id, COALESCE(p.first_name || ' ' || p.last_name, '') AS patient_name, COALESCE(p.phone, '') AS phone, "+
+			"COALESCE(p.email, '') AS email, u.doctor_id, u.procedure, u.treated_at, u.kind, u.manufacturer, u.product, u.lot_number, u.quantity").
+		Joins("LEFT JOIN patient p ON p.id = u.patient_id").
+		Where("UPPER(u.lot_number) = UPPER(?)", filter.LotNumber)
+	if filter.Manufacturer != "" {
+		query = query.Where("u.manufacturer ILIKE ?", containsPattern(filter.Manufacturer))
+	}
+	if filter.Product != "" {
+		query = query.Where("u.product ILIKE ?", containsPattern(filter.Product))
+	}
+	var traces []models.MaterialTrace
+	if err := query.Order("u.treated_at, u.billing_id, u.id").Scan(&traces).Error; err != nil {
+		return nil, fmt.Errorf("failed to search material recall: %w", err)
+	}
+	return traces, nil
+}
+
+// containsPattern is an ILIKE pattern matching text anywhere, wildcards in text matched literally
+func containsPattern(text string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+}
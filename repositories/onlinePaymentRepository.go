@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OnlinePaymentRepository stores the payments patients start from the portal
+type OnlinePaymentRepository struct{}
+
+func NewOnlinePaymentRepository() *OnlinePaymentRepository {
+	return &OnlinePaymentRepository{}
+}
+
+func (r *OnlinePaymentRepository) Create(ctx context.Context, payment *models.OnlinePayment) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(payment).Error; err != nil {
+		return fmt.Errorf("failed to create online payment: %w", err)
+	}
+	return nil
+}
+
+// GetByReference returns the payment with our reference, or nil when there is none
+func (r *OnlinePaymentRepository) GetByReference(ctx context.Context, reference string) (*models.OnlinePayment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var payment models.OnlinePayment
+	if err := database.DB.WithContext(ctx).First(&payment, "reference = ?", reference).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get online payment: %w", err)
+	}
+	return &payment, nil
+}
+
+// ListByPatient returns the patient's online payments newest first
+func (r *OnlinePaymentRepository) ListByPatient(ctx context.Context, patientID string) ([]models.OnlinePayment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var payments []models.OnlinePayment
+	if err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("created_at DESC, id DESC").Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to list online payments: %w", err)
+	}
+	return payments, nil
+}
+
+// SaveCheckout records where the gateway takes a started payment
+func (r *OnlinePaymentRepository) SaveCheckout(ctx context.Context, id uint, gatewayReference, paymentURL string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.OnlinePayment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"gateway_reference": gatewayReference,
+		"payment_url":       paymentURL,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to save online payment checkout: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed marks a pending payment failed. It returns false when the payment was no longer
+// pending.
+func (r *OnlinePaymentRepository) MarkFailed(ctx context.Context, id uint, gatewayReference, reason string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	updates := map[string]interface{}{
+		"status":         models.OnlinePaymentFailed,
+		"failure_reason": reason,
+		"completed_at":   time.Now(),
+	}
+	if gatewayReference != "" {
+		updates["gateway_reference"] = gatewayReference
+	}
+	result := database.DB.WithContext(ctx).Model(&models.OnlinePayment{}).
+		Where("id = ? AND status = ?", id, models.OnlinePaymentPending).Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark online payment failed: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// MarkReceiptSent records that the patient was emailed a receipt for the payment
+func (r *OnlinePaymentRepository) MarkReceiptSent(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(&models.OnlinePayment{}).Where("id = ?", id).Update("receipt_sent_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to mark online payment receipt sent: %w", err)
+	}
+	return nil
+}
+
+// Receipt returns what the receipt of a payment is written from, or nil when there is no such payment
+func (r *OnlinePaymentRepository) Receipt(ctx context.Context, id uint) (*models.OnlinePaymentReceipt, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var payment models.OnlinePayment
+	if err := database.DB.WithContext(ctx).First(&payment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get online payment: %w", err)
+	}
+	receipt := models.OnlinePaymentReceipt{Payment: payment}
+	err := database.DB.WithContext(ctx).Table("online_payment").
+		Select("patient.first_name AS patient_first_name, patient.email AS patient_email, billing.procedure, billing.balance").
+		Joins("JOIN patient ON patient.id = online_payment.patient_id").
+		Joins("JOIN billing ON billing.billing_id = online_payment.billing_id").
+		Where("online_payment.id = ?", id).
+		Scan(&receipt).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get online payment receipt: %w", err)
+	}
+	return &receipt, nil
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAlertNotActive is returned when acknowledging an alert that is not an active alert of the patient
+var ErrAlertNotActive = errors.New("alert is not an active alert of the patient")
+
+// alertOrder puts the most severe alerts first
+const alertOrder = "CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, created_at, id"
+
+// PatientAlertRepository stores patients' risk alerts and their acknowledgements at appointments
+type PatientAlertRepository struct{}
+
+func NewPatientAlertRepository() *PatientAlertRepository {
+	return &PatientAlertRepository{}
+}
+
+func (r *PatientAlertRepository) Create(ctx context.Context, alert *models.PatientAlert) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(alert).Error; err != nil {
+		return fmt.Errorf("failed to create patient alert: %w", err)
+	}
+	return nil
+}
+
+// Get returns an alert of the patient, or nil when there is none
+func (r *PatientAlertRepository) Get(ctx context.Context, patientID string, id uint) (*models.PatientAlert, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var alert models.PatientAlert
+	if err := database.DB.WithContext(ctx).First(&alert, "id = ? AND patient_id = ?", id, patientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get patient alert: %w", err)
+	}
+	return &alert, nil
+}
+
+// List returns the patient's alerts, only the active ones unless all is set, most severe first
+func (r *PatientAlertRepository) List(ctx context.Context, patientID string, all bool) ([]models.PatientAlert, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Where("patient_id = ?", patientID)
+	if !all {
+		query = query.Where("active")
+	}
+	var alerts []models.PatientAlert
+	if err := query.Order(alertOrder).Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list patient alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (r *PatientAlertRepository) Update(ctx context.Context, alert *models.PatientAlert) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(alert).Select("type", "severity", "note", "active", "updated_at", "updated_by").Updates(alert).Error
+	if err != nil {
+		return fmt.Errorf("failed to update patient alert: %w", err)
+	}
+	return nil
+}
+
+// Acknowledge records that the patient's active alerts alertIDs were read before treatment at the
+// appointment. Alerts already acknowledged for it keep their first acknowledgement.
+func (r *PatientAlertRepository) Acknowledge(ctx context.Context, patientID string, appointmentID uint, alertIDs []uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := appointmentExists(tx, patientID, appointmentID); err != nil {
+			return err
+		}
+		var count int64
+		err := tx.Model(&models.PatientAlert{}).Where("id IN ? AND patient_id = ? AND active", alertIDs, patientID).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to check patient alerts: %w", err)
+		}
+		if int(count) != len(alertIDs) {
+			return ErrAlertNotActive
+		}
+
+		acknowledgements := make([]models.PatientAlertAcknowledgement, len(alertIDs))
+		for i, id := range alertIDs {
+			acknowledgements[i] = models.PatientAlertAcknowledgement{AlertID: id, AppointmentID: appointmentID}
+		}
+		err = tx.Omit("Alert").Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "appointment_id"}, {Name: "alert_id"}},
+			DoNothing: true,
+		}).Create(&acknowledgements).Error
+		if err != nil {
+			return fmt.Errorf("failed to acknowledge patient alerts: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListForVisit returns the patient's active alerts with their acknowledgements at the appointment
+func (r *PatientAlertRepository) ListForVisit(ctx context.Context, patientID string, appointmentID uint) ([]models.VisitAlert, error) {
+	alerts, err := r.List(ctx, patientID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var acknowledgements []models.PatientAlertAcknowledgement
+	err = database.DB.WithContext(ctx).
+		Where("appointment_id = ? AND alert_id IN (SELECT id FROM patient_alert WHERE patient_id = ?)", appointmentID, patientID).
+		Find(&acknowledgements).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert acknowledgements: %w", err)
+	}
+	byAlert := make(map[uint]*models.PatientAlertAcknowledgement, len(acknowledgements))
+	for i := range acknowledgements {
+		byAlert[acknowledgements[i].AlertID] = &acknowledgements[i]
+	}
+
+	visitAlerts := make([]models.VisitAlert, len(alerts))
+	for i, alert := range alerts {
+		visitAlerts[i] = models.VisitAlert{PatientAlert: alert, Acknowledgement: byAlert[alert.ID]}
+	}
+	return visitAlerts, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// PatientChartRepository reads the whole of a patient's clinical record for chart exports
+type PatientChartRepository struct{}
+
+func NewPatientChartRepository() *PatientChartRepository {
+	return &PatientChartRepository{}
+}
+
+// Chart fills in the clinical record of the chart's patient, oldest first, with the previews of
+// their latest maxImages radiographs
+func (r *PatientChartRepository) Chart(ctx context.Context, chart *models.PatientChart, maxImages int) error {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	patientID := chart.Patient.ID
+	if err := db.Where("patient_id = ?", patientID).Order(alertOrder).Find(&chart.Alerts).Error; err != nil {
+		return fmt.Errorf("failed to get chart alerts: %w", err)
+	}
+	if err := db.Where("patient_id = ?", patientID).Order("substance").Find(&chart.Allergies).Error; err != nil {
+		return fmt.Errorf("failed to get chart allergies: %w", err)
+	}
+	err := db.Select("id, patient_id, doctor_id, date_time, status, type, starts_at, created_at").
+		Preload("Doctor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		}).
+		Where("patient_id = ?", patientID).Order("COALESCE(starts_at, created_at), id").Find(&chart.Appointments).Error
+	if err != nil {
+		return fmt.Errorf("failed to get chart appointments: %w", err)
+	}
+	if err := db.Where("patient_id = ?", patientID).Order("created_at, id").Find(&chart.Vitals).Error; err != nil {
+		return fmt.Errorf("failed to get chart vitals: %w", err)
+	}
+	err = db.Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
+		Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+			return db.Select(attachmentColumns)
+		}).
+		Where("patient_id = ?", patientID).Order("created_at, id").Find(&chart.Examinations).Error
+	if err != nil {
+		return fmt.Errorf("failed to get chart examinations: %w", err)
+	}
+	if err := db.Where("patient_id = ?", patientID).Order("created_at, id").Find(&chart.TreatmentPlans).Error; err != nil {
+		return fmt.Errorf("failed to get chart treatment plans: %w", err)
+	}
+	if err := db.Where("patient_id = ?", patientID).Order("created_at, id").Find(&chart.Prescriptions).Error; err != nil {
+		return fmt.Errorf("failed to get chart prescriptions: %w", err)
+	}
+	err = db.Select(imagingColumns+", preview").Where("patient_id = ? AND has_preview", patientID).
+		Order("COALESCE(study_date, created_at) DESC, id DESC").Limit(maxImages).Find(&chart.Images).Error
+	if err != nil {
+		return fmt.Errorf("failed to get chart images: %w", err)
+	}
+	return nil
+}
@@ -3,154 +3,122 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-const (
-	PatientCacheExpiry = 7 * 24 * time.Hour
-)
-
 type PatientRepository struct {
-	cache                *cache.Cache
-	emergencyContactRepo *EmergencyContactRepository
-	billingRepo          *BillingRepository
-	examinationRepo      *ExaminationRepository
-	treatmentPlanRepo    *TreatmentPlanRepository
-	appointmentRepo      *AppointmentRepository
+	cache *cache.Cache
 }
 
-func NewPatientRepository(
-	cache *cache.Cache,
-	emergencyContactRepo *EmergencyContactRepository,
-	billingRepo *BillingRepository,
-	examinationRepo *ExaminationRepository,
-	treatmentPlanRepo *TreatmentPlanRepository,
-	appointmentRepo *AppointmentRepository,
-) *PatientRepository {
-	return &PatientRepository{
-		cache:                cache,
-		emergencyContactRepo: emergencyContactRepo,
-		billingRepo:          billingRepo,
-		examinationRepo:      examinationRepo,
-		treatmentPlanRepo:    treatmentPlanRepo,
-		appointmentRepo:      appointmentRepo,
-	}
+// NewPatientRepository publishes the patient events; the repositories of records kept with a
+// patient drop their own cached copies on PatientDeleted.
+func NewPatientRepository(cache *cache.Cache) *PatientRepository {
+	return &PatientRepository{cache: cache}
 }
 
 func (r *PatientRepository) Create(ctx context.Context, patient *models.Patient) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, patientCreateLockKey(patient), func(tx *gorm.DB) error {
+		if err := r.createInTx(tx, patient); err != nil {
+			return err
+		}
+
+		// Invalidate cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patient.ID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.PatientCreated, PatientID: patient.ID})
+	return nil
+}
+
+// patientCreateLockKey serialises creation of patients with the same identifying details.
+func patientCreateLockKey(patient *models.Patient) string {
 	// Handle empty middle name
 	middleName := patient.MiddleName
 	if middleName == "" {
 		middleName = "N/A"
 	}
+	return fmt.Sprintf("patient_lock:%s_%s_%s_%s", patient.FirstName, middleName, patient.LastName, patient.DateOfBirth)
+}
 
-	lockKey := fmt.Sprintf("patient_lock:%s_%s_%s_%s", patient.FirstName, middleName, patient.LastName, patient.DateOfBirth)
-	lockValue := uuid.New().String() // Generate a unique lock value
-
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+// createInTx assigns the next patient ID and inserts the patient within tx, rejecting duplicates.
+// The caller must hold patientCreateLockKey.
+func (r *PatientRepository) createInTx(tx *gorm.DB, patient *models.Patient) error {
+	// Only a bounce sets the email's flag
+	patient.EmailBouncedAt = nil
+	middleName := patient.MiddleName
+	if middleName == "" {
+		middleName = "N/A"
 	}
 
-	// Ensure lock release
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
-
 	// Check if a record with the same unique fields already exists
 	var existingPatient models.Patient
-	if err := database.DB.Where("first_name = ? AND middle_name = ? AND last_name = ? AND date_of_birth = ?",
+	if err := tx.Where("first_name = ? AND middle_name = ? AND last_name = ? AND date_of_birth = ?",
 		patient.FirstName, middleName, patient.LastName, patient.DateOfBirth).First(&existingPatient).Error; err == nil {
 		return fmt.Errorf("patient with the same details already exists")
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("failed to check for existing patient: %w", err)
 	}
 
-	// Obtain the next sequence value
-	var nextID string
-	if err := database.DB.Raw("SELECT 'DP-' || LPAD(nextval('patient_id_seq')::TEXT, 6, '0')").Scan(&nextID).Error; err != nil {
-		return fmt.Errorf("failed to obtain next sequence value: %w", err)
+	// Obtain the next ID in its configured format
+	nextID, err := generateID(tx, "patient")
+	if err != nil {
+		return err
 	}
 
-	// Assign ID to the patient
+	// Assign ID to the patient; the geocoding job places the address on the map
 	patient.ID = nextID
+	patient.Address = patient.Address.Trimmed()
+	patient.Location = models.GeoLocation{}
 
-	// Transaction to create patient and invalidate cache
-	return database.DB.Transaction(func(tx *gorm.DB) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
 		// Create the patient record
-		if err := tx.Create(patient).Error; err != nil {
+		// Contacts are managed through their own endpoints, never through the patient payload
+		if err := tx.Omit("PrimaryContact").Create(patient).Error; err != nil {
 			// Rollback sequence in case of failure
-			if rollbackErr := tx.Exec("SELECT setval('patient_id_seq', (SELECT last_value FROM patient_id_seq) - 1, false)").Error; rollbackErr != nil {
+			if rollbackErr := releaseID(tx, "patient"); rollbackErr != nil {
 				return fmt.Errorf("transaction failed and sequence rollback failed: %v, rollback error: %v", err, rollbackErr)
 			}
 			return fmt.Errorf("failed to create patient: %w", err)
 		}
-
-		// Invalidate cache
-		if err := r.cache.Delete(ctx, r.getPatientCacheKey(patient.ID)); err != nil {
-			return fmt.Errorf("failed to delete patient cache: %w", err)
-		}
-		return r.cache.DeleteAll(ctx, "patients_cache")
+		return nil
 	})
 }
 
+// GetByID returns the patient with their primary contact. Their records are left out, being read
+// through Summary and the patients' history pages instead.
 func (r *PatientRepository) GetByID(ctx context.Context, id string) (*models.Patient, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getPatientCacheKey(id)
-	cachedPatient, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var patient models.Patient
-		if err := json.Unmarshal([]byte(cachedPatient), &patient); err == nil {
-			return &patient, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getPatientCacheKey(ctx, id)
+	var patient models.Patient
+	if found, err := r.cache.GetObject(ctx, cacheKey, &patient); err != nil {
 		log.Printf("Failed to get patient from cache: %v", err)
+	} else if found {
+		return &patient, nil
 	}
 
-	var patient models.Patient
-	err = database.DB.Select("id, first_name, middle_name, last_name, sex, date_of_birth, insured, cash, insurance_company, scheme, cover_limit, occupation, place_of_work, phone, email, address, created_at").
-		Preload("EmergencyContacts", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, name, phone, relationship")
-		}).
-		Preload("Examinations", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, report, created_at")
-		}).
-		Preload("Billings", func(db *gorm.DB) *gorm.DB {
-			return db.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at")
-		}).
-		Preload("TreatmentPlans", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, plan, created_at")
-		}).
-		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, doctor_id, date_time, created_at, status")
+	err := database.DB.WithContext(ctx).Select("id, first_name, middle_name, last_name, sex, date_of_birth, insured, cash, insurance_company, scheme, cover_limit, occupation, place_of_work, phone, email, email_bounced_at, language, address_street, address_city, address_county, address_postal_code, address_latitude, address_longitude, address_geocoded_at, guarantor_name, guarantor_relationship, guarantor_phone, guarantor_email, national_id, member_number, user_id, custom_fields, source, campaign_code, referred_by, created_at, updated_at").
+		Preload("PrimaryContact", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by").Where("is_primary")
 		}).
 		First(&patient, "id = ?", id).Error
 	if err != nil {
@@ -160,318 +128,354 @@ func (r *PatientRepository) GetByID(ctx context.Context, id string) (*models.Pat
 		return nil, fmt.Errorf("failed to get patient: %w", err)
 	}
 
-	patientJSON, err := json.Marshal(patient)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal patient: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, patientJSON, PatientCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, patient, cache.ItemTTL("patient")); err != nil {
 		log.Printf("Failed to set patient in cache: %v", err)
 	}
 
 	return &patient, nil
 }
 
-func (r *PatientRepository) GetAll(ctx context.Context) ([]models.Patient, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+// Summary outlines the patient's record as of now: how many appointments, bills, examinations and
+// treatment plans they have, their next and last few appointments, last few bills and latest
+// examination and treatment plan. It is read afresh every time, being what changes most on a patient.
+func (r *PatientRepository) Summary(ctx context.Context, patientID string, recent int, now time.Time) (*models.PatientSummary, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "patients_cache"
-	cachedPatients, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var patients []models.Patient
-		if err := json.Unmarshal([]byte(cachedPatients), &patients); err == nil {
-			return patients, nil
-		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get patients from cache: %v", err)
+	db := database.DB.WithContext(ctx)
+	var summary models.PatientSummary
+	err := db.Raw(`SELECT
+		(SELECT COUNT(*) FROM appointment WHERE patient_id = @patient) AS appointments,
+		(SELECT COUNT(*) FROM billing WHERE patient_id = @patient) AS billings,
+		(SELECT COUNT(*) FROM examination WHERE patient_id = @patient) AS examinations,
+		(SELECT COUNT(*) FROM treatment_plan WHERE patient_id = @patient) AS treatment_plans`,
+		map[string]interface{}{"patient": patientID}).Row().Scan(&summary.Appointments, &summary.Billings, &summary.Examinations, &summary.TreatmentPlans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count patient records: %w", err)
 	}
 
-	var patients []models.Patient
-	err = database.DB.Select("id, first_name, middle_name, last_name, sex, date_of_birth, insured, cash, insurance_company, scheme, cover_limit, occupation, place_of_work, phone, email, address, created_at").
-		Preload("EmergencyContacts", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, name, phone, relationship")
-		}).
-		Preload("Examinations", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, report, created_at")
-		}).
-		Preload("Billings", func(db *gorm.DB) *gorm.DB {
-			return db.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at")
-		}).
-		Preload("TreatmentPlans", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, plan, created_at")
-		}).
-		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, patient_id, doctor_id, date_time, created_at, status")
-		}).
-		Order("created_at DESC").
-		Find(&patients).Error
+	const appointmentColumns = "id, patient_id, doctor_id, date_time, status, type, procedure_id, chair_id, starts_at, ends_at, created_at"
+	doctor := func(db *gorm.DB) *gorm.DB { return db.Select("id, first_name, last_name") }
+	err = db.Select(appointmentColumns).Preload("Doctor", doctor).
+		Where("patient_id = ? AND date_time >= ? AND status <> ?", patientID, now, models.AppointmentStatusCancelled).
+		Order("date_time, id").Limit(recent).Find(&summary.UpcomingAppointments).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all patients: %w", err)
+		return nil, fmt.Errorf("failed to get upcoming appointments: %w", err)
 	}
-
-	patientsJSON, err := json.Marshal(patients)
+	err = db.Select(appointmentColumns).Preload("Doctor", doctor).
+		Where("patient_id = ? AND date_time < ?", patientID, now).
+		Order("date_time DESC, id DESC").Limit(recent).Find(&summary.RecentAppointments).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal patients: %w", err)
+		return nil, fmt.Errorf("failed to get recent appointments: %w", err)
 	}
-	if err := r.cache.Set(ctx, cacheKey, patientsJSON, PatientCacheExpiry); err != nil {
-		log.Printf("Failed to set patients in cache: %v", err)
+	err = db.Select("billing_id, patient_id, doctor_id, procedure, procedure_id, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at").
+		Preload("Doctor", doctor).
+		Where("patient_id = ?", patientID).
+		Order("created_at DESC, billing_id DESC").Limit(recent).Find(&summary.RecentBillings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent billings: %w", err)
 	}
 
-	return patients, nil
-}
-
-func (r *PatientRepository) Update(ctx context.Context, patient *models.Patient) error {
-	lockKey := fmt.Sprintf("patient_lock:%s", patient.ID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second)
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
+	var examinations []models.Examination
+	err = db.Select("id, patient_id, report, findings, created_at, updated_at, created_by, updated_by").
+		Where("patient_id = ?", patientID).Order("created_at DESC, id DESC").Limit(1).Find(&examinations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest examination: %w", err)
 	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
+	if len(examinations) > 0 {
+		summary.LatestExamination = &examinations[0]
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
-
-	// Use ON CONFLICT to handle conflicts
-	err = database.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"first_name", "middle_name", "last_name", "date_of_birth", "sex", "insured", "cash", "insurance_company", "scheme", "cover_limit", "occupation", "place_of_work", "phone", "email", "address"}),
-	}).Save(patient).Error
+	var plans []models.TreatmentPlan
+	err = db.Select("id, patient_id, plan, estimated_cost, version, created_at, updated_at, created_by, updated_by").
+		Where("patient_id = ?", patientID).Order("created_at DESC, id DESC").Limit(1).Find(&plans).Error
 	if err != nil {
-		return fmt.Errorf("failed to update patient: %w", err)
+		return nil, fmt.Errorf("failed to get latest treatment plan: %w", err)
 	}
-
-	// Invalidate cache for the updated patient and all patients
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(patient.ID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	if len(plans) > 0 {
+		summary.LatestTreatmentPlan = &plans[0]
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return &summary, nil
 }
 
-func (r *PatientRepository) Delete(ctx context.Context, id string) error {
-	lockKey := fmt.Sprintf("patient_lock:%s", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
-		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+func (r *PatientRepository) GetAll(ctx context.Context) ([]models.Patient, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "patients"), "patient", func(ctx context.Context) ([]models.Patient, error) {
+		var patients []models.Patient
+		err := r.listQuery(database.DB.WithContext(ctx)).
+			Order("created_at DESC").
+			Find(&patients).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all patients: %w", err)
 		}
-	}()
+		return patients, nil
+	})
+}
 
-	err = database.DB.Delete(&models.Patient{}, "id = ?", id).Error
-	if err != nil {
-		return fmt.Errorf("failed to delete patient: %w", err)
-	}
-	// Invalidate cache for the deleted patient and all patients
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(id)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+// Stream hands every patient to fn newest first without holding the whole list in memory
+func (r *PatientRepository) Stream(ctx context.Context, fn func(models.Patient) error) error {
+	return streamNewestFirst(ctx, "id", r.listQuery, func(p *models.Patient) (time.Time, interface{}) {
+		return p.CreatedAt, p.ID
+	}, fn)
 }
 
-func (r *PatientRepository) DeletePatientAndRelated(ctx context.Context, id string) error {
-	lockKey := fmt.Sprintf("patient_lock:%s", id)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
+// StreamFiltered hands the patients matching query to fn in the order it asks for
+func (r *PatientRepository) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Patient) error) error {
+	filter, err := listFilter(models.ListPatients, query, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
+		return err
 	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
-
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		if err := r.invalidateEmergencyContactsCache(ctx, tx, id); err != nil {
-			return err
-		}
+	return streamOrdered(ctx, func(db *gorm.DB) *gorm.DB { return filter(r.listQuery(db)) }, fn)
+}
 
-		if err := r.invalidateExaminationsCache(ctx, tx, id); err != nil {
-			return err
-		}
+// ListByDoctorAfter returns up to limit of the doctor's patients, those with an appointment with
+// the doctor, newest first, starting after the cursor when one is given. Only the patients' own
+// details are read, not their records.
+func (r *PatientRepository) ListByDoctorAfter(ctx context.Context, doctorID string, after *models.ListCursor, limit int) ([]models.Patient, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		createdAt, key = after.CreatedAt, after.Key
+	}
+	return cachedDoctorPage(ctx, r.cache, doctorPatientsCache, "patient", doctorID, after, limit, func(ctx context.Context) ([]models.Patient, error) {
+		return listNewestFirst[models.Patient](ctx, "id", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, middle_name, last_name, sex, date_of_birth, phone, email, insured, insurance_company, created_at, updated_at").
+				Where("id IN (SELECT patient_id FROM appointment WHERE doctor_id = ?)", doctorID)
+		}, createdAt, key, limit)
+	})
+}
 
-		if err := r.invalidateBillingsCache(ctx, tx, id); err != nil {
-			return err
-		}
+// listQuery selects the columns and relations returned in patient lists
+func (r *PatientRepository) listQuery(db *gorm.DB) *gorm.DB {
+	return db.Select("id, first_name, middle_name, last_name, sex, date_of_birth, insured, cash, insurance_company, scheme, cover_limit, occupation, place_of_work, phone, email, email_bounced_at, language, address_street, address_city, address_county, address_postal_code, address_latitude, address_longitude, address_geocoded_at, guarantor_name, guarantor_relationship, guarantor_phone, guarantor_email, national_id, member_number, user_id, custom_fields, source, campaign_code, referred_by, created_at, updated_at").
+		Preload("EmergencyContacts", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by")
+		}).
+		Preload("PrimaryContact", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, name, phone, relationship, is_primary, sms_consent, sms_consent_at, sms_consent_by").Where("is_primary")
+		}).
+		Preload("Examinations", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, report, findings, created_at, created_by, updated_by")
+		}).
+		Preload("Billings", func(db *gorm.DB) *gorm.DB {
+			return db.Select("billing_id, patient_id, doctor_id, procedure, procedure_id, contract_rate_id, contract_amount, billing_amount, paid_cash_amount, paid_insurance_amount, balance, total_received, created_at, created_by, updated_by")
+		}).
+		Preload("TreatmentPlans", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, plan, created_at, created_by, updated_by")
+		}).
+		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, patient_id, doctor_id, date_time, created_at, status")
+		})
+}
 
-		if err := r.invalidateTreatmentPlansCache(ctx, tx, id); err != nil {
-			return err
-		}
+func (r *PatientRepository) Update(ctx context.Context, patient *models.Patient) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-		if err := r.invalidateAppointmentsCache(ctx, tx, id); err != nil {
-			return err
+	err := database.WithLock(ctx, fmt.Sprintf("patient_lock:%s", patient.ID), func(tx *gorm.DB) error {
+		// Keep the address where the geocoding job placed it unless the address changed, and the
+		// custom field values unless new ones are given
+		patient.Address = patient.Address.Trimmed()
+		patient.Location = models.GeoLocation{}
+		var current models.Patient
+		err := tx.Select("email, email_bounced_at, address_street, address_city, address_county, address_postal_code, address_latitude, address_longitude, address_geocoded_at, custom_fields, source, campaign_code, referred_by").
+			First(&current, "id = ?", patient.ID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get patient address: %w", err)
 		}
-
-		if err := tx.Where("patient_id = ?", id).Delete(&models.EmergencyContact{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+		if err == nil && current.Address == patient.Address {
+			patient.Location = current.Location
 		}
-		if err := tx.Where("patient_id = ?", id).Delete(&models.Examination{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+		if err == nil && patient.CustomFields == nil {
+			patient.CustomFields = current.CustomFields
 		}
-		if err := tx.Where("patient_id = ?", id).Delete(&models.Billing{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+		// A patient updated without a source keeps where they came from
+		if err == nil && patient.Attribution.Source == "" {
+			patient.Attribution = current.Attribution
 		}
-		if err := tx.Where("patient_id = ?", id).Delete(&models.TreatmentPlan{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+		// Only a bounce sets the email's flag, and a new email clears it
+		patient.EmailBouncedAt = nil
+		if err == nil && strings.EqualFold(current.Email, patient.Email) {
+			patient.EmailBouncedAt = current.EmailBouncedAt
 		}
-		if err := tx.Where("patient_id = ?", id).Delete(&models.Appointment{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+
+		// Use ON CONFLICT to handle conflicts
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"first_name", "middle_name", "last_name", "date_of_birth", "sex", "insured", "cash", "insurance_company", "scheme", "cover_limit", "occupation", "place_of_work", "phone", "email", "email_bounced_at", "language", "address_street", "address_city", "address_county", "address_postal_code", "address_latitude", "address_longitude", "address_geocoded_at", "guarantor_name", "guarantor_relationship", "guarantor_phone", "guarantor_email", "national_id", "member_number", "user_id", "custom_fields", "source", "campaign_code", "referred_by", "updated_at"}),
+		}).Omit("PrimaryContact").Save(patient).Error
+		if err != nil {
+			return fmt.Errorf("failed to update patient: %w", err)
 		}
 
-		if err := tx.Delete(&models.Patient{}, "id = ?", id).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+		// Invalidate cache for the updated patient and all patients
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patient.ID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
+		return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.PatientUpdated, PatientID: patient.ID})
+	return nil
+}
 
-		if err := r.cache.Delete(ctx, r.getPatientCacheKey(id)); err != nil {
-			return err
+func (r *PatientRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("patient_lock:%s", id), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.Patient{}, "id = ?", id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete patient: %w", err)
 		}
-		if err := r.cache.DeleteAll(ctx, "patients_cache"); err != nil {
-			return err
+		// Invalidate cache for the deleted patient and all patients
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, id)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
+		return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.PatientDeleted, PatientID: id})
+	return nil
+}
 
-		if err := r.appointmentRepo.DeleteAllCache(ctx); err != nil {
-			return err
-		}
+// DeletePatientAndRelated deletes a patient with their contacts, examinations, billings, treatment
+// plans and appointments. The PatientDeleted event names the deleted records, so their repositories
+// can drop them from the cache.
+func (r *PatientRepository) DeletePatientAndRelated(ctx context.Context, id string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-		if err := r.emergencyContactRepo.DeleteAllCache(ctx); err != nil {
-			return err
-		}
+	related := map[string][]string{}
+	err := database.WithLock(ctx, fmt.Sprintf("patient_lock:%s", id), func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			// Bills of closed financial periods must stay on the books
+			var billed []time.Time
+			if err := tx.Model(&models.Billing{}).Where("patient_id = ?", id).Pluck("created_at", &billed).Error; err != nil {
+				return fmt.Errorf("failed to get patient billings: %w", err)
+			}
+			if err := checkBillingPeriodOpen(tx, billed...); err != nil {
+				return err
+			}
 
-		if err := r.billingRepo.DeleteAllCache(ctx); err != nil {
-			return err
-		}
+			// The payments ledger is kept by bill, so it goes before the bills it refers to
+			if err := tx.Exec("DELETE FROM payment WHERE billing_id IN (SELECT billing_id FROM billing WHERE patient_id = ?)", id).Error; err != nil {
+				return fmt.Errorf("failed to delete patient payments: %w", err)
+			}
 
-		if err := r.examinationRepo.DeleteAllCache(ctx); err != nil {
-			return err
-		}
+			for _, record := range []struct {
+				table string
+				key   string
+				model interface{}
+			}{
+				{"emergency_contact", "id", &models.EmergencyContact{}},
+				{"examination", "id", &models.Examination{}},
+				{"billing", "billing_id", &models.Billing{}},
+				{"treatment_plan", "id", &models.TreatmentPlan{}},
+				{"appointment", "id", &models.Appointment{}},
+			} {
+				var ids []string
+				if err := tx.Model(record.model).Where("patient_id = ?", id).Pluck("CAST("+record.key+" AS text)", &ids).Error; err != nil {
+					return fmt.Errorf("failed to get patient %s records: %w", record.table, err)
+				}
+				if err := tx.Where("patient_id = ?", id).Delete(record.model).Error; err != nil {
+					return fmt.Errorf("failed to delete patient %s records: %w", record.table, err)
+				}
+				related[record.table] = ids
+			}
 
-		if err := r.treatmentPlanRepo.DeleteAllCache(ctx); err != nil {
-			return err
-		}
+			if err := tx.Delete(&models.Patient{}, "id = ?", id).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
 
-		return nil
+			if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, id)); err != nil {
+				return err
+			}
+			return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+		})
 	})
-}
-
-func (r *PatientRepository) invalidateEmergencyContactsCache(ctx context.Context, tx *gorm.DB, patientID string) error {
-	var emergencyContacts []models.EmergencyContact
-	if err := tx.Where("patient_id = ?", patientID).Find(&emergencyContacts).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
+	if err != nil {
 		return err
 	}
-
-	for _, contact := range emergencyContacts {
-		if err := r.emergencyContactRepo.DeleteCache(ctx, contact.PatientID, contact.ID); err != nil {
-			return err
-		}
-	}
+	events.Publish(ctx, events.Event{Name: events.PatientDeleted, PatientID: id, Related: related})
 	return nil
 }
 
-func (r *PatientRepository) invalidateExaminationsCache(ctx context.Context, tx *gorm.DB, patientID string) error {
-	var examinations []models.Examination
-	if err := tx.Where("patient_id = ?", patientID).Find(&examinations).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return err
-	}
+// PendingGeocoding returns up to limit patients whose address has not been looked up yet, with
+// only their ID and address
+func (r *PatientRepository) PendingGeocoding(ctx context.Context, limit int) ([]models.Patient, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	for _, examination := range examinations {
-		if err := r.examinationRepo.DeleteCache(ctx, examination.PatientID, examination.ID); err != nil {
-			return err
-		}
+	var patients []models.Patient
+	err := database.DB.WithContext(ctx).
+		Select("id, address_street, address_city, address_county, address_postal_code").
+		Where("address_geocoded_at IS NULL").
+		Where("COALESCE(address_street, '') <> '' OR COALESCE(address_city, '') <> '' OR COALESCE(address_county, '') <> '' OR COALESCE(address_postal_code, '') <> ''").
+		Order("id").
+		Limit(limit).
+		Find(&patients).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patients to geocode: %w", err)
 	}
-	return nil
+	return patients, nil
 }
 
-func (r *PatientRepository) invalidateBillingsCache(ctx context.Context, tx *gorm.DB, patientID string) error {
-	var billings []models.Billing
-	if err := tx.Where("patient_id = ?", patientID).Find(&billings).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return err
-	}
+// SaveLocation stores where address was placed, unless the patient's address changed since it was
+// read. It leaves updated_at alone, as the location is derived from the address.
+func (r *PatientRepository) SaveLocation(ctx context.Context, patientID string, address models.Address, location models.GeoLocation) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	for _, billing := range billings {
-		if err := r.billingRepo.DeleteCache(ctx, billing.BillingID); err != nil {
-			return err
+	return database.WithLock(ctx, fmt.Sprintf("patient_lock:%s", patientID), func(tx *gorm.DB) error {
+		err := tx.Model(&models.Patient{}).
+			Where(`id = ? AND COALESCE(address_street, '') = ? AND COALESCE(address_city, '') = ?
+				AND COALESCE(address_county, '') = ? AND COALESCE(address_postal_code, '') = ?`,
+				patientID, address.Street, address.City, address.County, address.PostalCode).
+			UpdateColumns(map[string]interface{}{
+				"address_latitude":    location.Latitude,
+				"address_longitude":   location.Longitude,
+				"address_geocoded_at": location.GeocodedAt,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to save patient location: %w", err)
+		}
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
-	}
-	return nil
+		return deleteListCache(ctx, r.cache, "patients", doctorPatientsCache)
+	})
 }
 
-func (r *PatientRepository) invalidateTreatmentPlansCache(ctx context.Context, tx *gorm.DB, patientID string) error {
-	var treatmentPlans []models.TreatmentPlan
-	if err := tx.Where("patient_id = ?", patientID).Find(&treatmentPlans).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return err
-	}
-
-	for _, treatmentPlan := range treatmentPlans {
-		if err := r.treatmentPlanRepo.DeleteCache(ctx, treatmentPlan.PatientID, treatmentPlan.ID); err != nil {
-			return err
-		}
-	}
-	return nil
+func (r *PatientRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
 }
 
-func (r *PatientRepository) invalidateAppointmentsCache(ctx context.Context, tx *gorm.DB, patientID string) error {
-	var appointments []models.Appointment
-	if err := tx.Where("patient_id = ?", patientID).Find(&appointments).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return err
-	}
+// GetByUserID returns the patient linked to a portal user account, with only their name, contact
+// details and cover, or nil when there is none
+func (r *PatientRepository) GetByUserID(ctx context.Context, userID int64) (*models.Patient, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	for _, appointment := range appointments {
-		if err := r.appointmentRepo.DeleteCache(ctx, appointment.PatientID, appointment.ID); err != nil {
-			return err
+	var patient models.Patient
+	err := database.DB.WithContext(ctx).Select("id, first_name, middle_name, last_name, insured, cash, insurance_company, scheme, cover_limit, phone, email, email_bounced_at, user_id").
+		First(&patient, "user_id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
+		return nil, fmt.Errorf("failed to get patient for user: %w", err)
 	}
-	return nil
+	return &patient, nil
 }
 
-func (r *PatientRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+// guarantorContact selects column of the patient aliased alias, or guarantorColumn instead when the
+// patient has a guarantor, who is sent their bills and reminders
+func guarantorContact(alias, column, guarantorColumn string) string {
+	return fmt.Sprintf("CASE WHEN COALESCE(%[1]s.guarantor_name, '') <> '' THEN %[3]s ELSE %[1]s.%[2]s END", alias, column, guarantorColumn)
 }
@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInstallmentNotFound = errors.New("installment not found")
+	ErrInstallmentPaid     = errors.New("installment is already paid")
+	ErrOverpayment         = errors.New("payment exceeds the amount still due on the installment")
+	ErrPaymentPlanInactive = errors.New("payment plan is not active")
+)
+
+// Installment reminder kinds, named after the column recording when each was sent
+const (
+	InstallmentReminderDue     = "reminder_sent_at"
+	InstallmentReminderOverdue = "overdue_notified_at"
+)
+
+// PaymentPlanRepository stores installment payment plans
+type PaymentPlanRepository struct{}
+
+func NewPaymentPlanRepository() *PaymentPlanRepository {
+	return &PaymentPlanRepository{}
+}
+
+// Create saves a plan together with its installments
+func (r *PaymentPlanRepository) Create(ctx context.Context, plan *models.PaymentPlan) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient", "TreatmentPlan").Create(plan).Error; err != nil {
+		return fmt.Errorf("failed to create payment plan: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentPlanRepository) GetByID(ctx context.Context, id uint) (*models.PaymentPlan, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var plan models.PaymentPlan
+	err := database.DB.WithContext(ctx).Preload("Installments", orderInstallments).First(&plan, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get payment plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// List returns plans, newest first, for one patient when patientID is set, in one status when status
+// is set, and only those with overdue installments when overdue is true
+func (r *PaymentPlanRepository) List(ctx context.Context, patientID, status string, overdue bool) ([]models.PaymentPlan, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Preload("Installments", orderInstallments)
+	if patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if overdue {
+		query = query.Where("EXISTS (SELECT 1 FROM payment_installment i WHERE i.plan_id = payment_plan.id AND i.status = ?)", models.InstallmentStatusOverdue)
+	}
+	var plans []models.PaymentPlan
+	if err := query.Order("created_at DESC, id DESC").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("failed to list payment plans: %w", err)
+	}
+	return plans, nil
+}
+
+// RecordPayment adds amount to an installment of an active plan, marking it paid once it is covered
+// and the plan completed once every installment is
+func (r *PaymentPlanRepository) RecordPayment(ctx context.Context, planID uint, number int, amount float64, at time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var plan models.PaymentPlan
+	var installment models.PaymentInstallment
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&plan, planID).Error; err != nil {
+			return fmt.Errorf("failed to get payment plan: %w", err)
+		}
+		if plan.Status != models.PaymentPlanStatusActive {
+			return ErrPaymentPlanInactive
+		}
+
+		err := tx.First(&installment, "plan_id = ? AND number = ?", planID, number).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInstallmentNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get installment: %w", err)
+		}
+		if installment.Status == models.InstallmentStatusPaid {
+			return ErrInstallmentPaid
+		}
+		// Compare in cents so rounding never leaves a fraction outstanding
+		paid := installment.PaidAmount + amount
+		if int64(paid*100+0.5) > int64(installment.Amount*100+0.5) {
+			return ErrOverpayment
+		}
+
+		updates := map[string]interface{}{"paid_amount": paid}
+		if int64(paid*100+0.5) == int64(installment.Amount*100+0.5) {
+			updates["status"] = models.InstallmentStatusPaid
+			updates["paid_at"] = at
+		}
+		if err := tx.Model(&installment).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to record installment payment: %w", err)
+		}
+
+		var unpaid int64
+		if err := tx.Model(&models.PaymentInstallment{}).Where("plan_id = ? AND status <> ?", planID, models.InstallmentStatusPaid).Count(&unpaid).Error; err != nil {
+			return fmt.Errorf("failed to count unpaid installments: %w", err)
+		}
+		if unpaid == 0 {
+			if err := tx.Model(&plan).Update("status", models.PaymentPlanStatusCompleted).Error; err != nil {
+				return fmt.Errorf("failed to complete payment plan: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.PaymentRecorded, PatientID: plan.PatientID, Record: "payment_installment",
+		RecordID: strconv.FormatUint(uint64(installment.ID), 10), Amount: amount})
+	return nil
+}
+
+// Cancel stops an active plan, reporting false when it was no longer active
+func (r *PaymentPlanRepository) Cancel(ctx context.Context, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.PaymentPlan{ID: id}).
+		Where("status = ?", models.PaymentPlanStatusActive).
+		Update("status", models.PaymentPlanStatusCancelled)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to cancel payment plan: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkOverdue flags the unpaid installments of active plans that fell due before today
+func (r *PaymentPlanRepository) MarkOverdue(ctx context.Context, today time.Time) (int64, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.PaymentInstallment{}).
+		Where("status = ? AND due_date < ?", models.InstallmentStatusPending, today.Format("2006-01-02")).
+		Where("plan_id IN (SELECT id FROM payment_plan WHERE status = ?)", models.PaymentPlanStatusActive).
+		Update("status", models.InstallmentStatusOverdue)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark overdue installments: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// PendingReminders returns installments of active plans, whose patients have an email address, their
+// guarantor's when they have one, and whose bill is not under dispute, that have not had the given
+// kind of reminder: pending ones due on or before until for InstallmentReminderDue, and overdue ones
+// for InstallmentReminderOverdue
+func (r *PaymentPlanRepository) PendingReminders(ctx context.Context, kind string, until time.Time, limit int) ([]models.InstallmentReminder, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("payment_installment i").
+		Select("i.id AS installment_id, i.plan_id, i.number, i.due_date, i.amount, i.paid_amount, pp.patient_id, "+
+			guarantorContact("p", "first_name", "p.guarantor_name")+" AS patient_first_name, "+
+			guarantorContact("p", "email", "p.guarantor_email")+" AS patient_email").
+		Joins("JOIN payment_plan pp ON pp.id = i.plan_id").
+		Joins("JOIN patient p ON p.id = pp.patient_id").
+		Where("pp.status = ? AND COALESCE("+guarantorContact("p", "email", "p.guarantor_email")+", '') <> ''", models.PaymentPlanStatusActive).
+		// Reminders about a bill wait while it is disputed
+		Where("NOT EXISTS (SELECT 1 FROM billing_dispute d WHERE d.billing_id = pp.billing_id AND d.status IN ?)", models.OpenDisputeStatuses)
+	switch kind {
+	case InstallmentReminderDue:
+		query = query.Where("i.status = ? AND i.due_date <= ? AND i.reminder_sent_at IS NULL", models.InstallmentStatusPending, until.Format("2006-01-02"))
+	case InstallmentReminderOverdue:
+		query = query.Where("i.status = ? AND i.overdue_notified_at IS NULL", models.InstallmentStatusOverdue)
+	default:
+		return nil, fmt.Errorf("unknown installment reminder %q", kind)
+	}
+	var reminders []models.InstallmentReminder
+	if err := query.Order("i.due_date, i.id").Limit(limit).Scan(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("failed to get installment reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// MarkReminded records that a reminder is being sent and reports false when another replica already
+// sent it; at is nil to clear the mark after a failed delivery so it is retried
+func (r *PaymentPlanRepository) MarkReminded(ctx context.Context, installmentID uint, kind string, at *time.Time) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.PaymentInstallment{}).Where("id = ?", installmentID)
+	if at != nil {
+		query = query.Where(kind + " IS NULL")
+	}
+	result := query.Update(kind, at)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record installment reminder: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func orderInstallments(db *gorm.DB) *gorm.DB {
+	return db.Order("number")
+}
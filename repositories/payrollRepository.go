@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPayrollSignedOff is returned when signing off a month whose payroll is already signed off
+var ErrPayrollSignedOff = errors.New("payroll is already signed off")
+
+// commissionBasisQuery sums per doctor the bills created between @from and @to as they were closed,
+// and the adjustments made to bills of closed periods between @from and @to
+const commissionBasisQuery = `SELECT d.id AS doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, d.user_id,
+		SUM(x.billed) AS billed, SUM(x.collected) AS collected, cr.rate
+	FROM (
+		SELECT b.doctor_id, b.billing_amount - COALESCE(a.billed, 0) AS billed,
+			b.paid_cash_amount + b.paid_insurance_amount - COALESCE(a.collected, 0) AS collected
+		FROM billing b
+		LEFT JOIN (SELECT billing_id, SUM(billing_amount) AS billed, SUM(paid_cash_amount + paid_insurance_amount) AS collected
+			FROM billing_adjustment GROUP BY billing_id) a ON a.billing_id = b.billing_id
+		WHERE b.created_at >= @from AND b.created_at < @to
+		UNION ALL
+		SELECT b.doctor_id, a.billing_amount, a.paid_cash_amount + a.paid_insurance_amount
+		FROM billing_adjustment a
+		JOIN billing b ON b.billing_id = a.billing_id
+		WHERE a.created_at >= @from AND a.created_at < @to
+	) x
+	JOIN doctor d ON d.id = x.doctor_id
+	LEFT JOIN commission_rate cr ON cr.doctor_id = d.id
+	GROUP BY d.id, d.first_name, d.last_name, d.user_id, cr.rate
+	ORDER BY doctor_name, d.id`
+
+// PayrollRepository stores commission rates and signed-off payroll
+type PayrollRepository struct{}
+
+func NewPayrollRepository() *PayrollRepository {
+	return &PayrollRepository{}
+}
+
+// CommissionBasis returns what each doctor billed and collected between from and to (exclusive)
+func (r *PayrollRepository) CommissionBasis(ctx context.Context, from, to time.Time) ([]models.CommissionBasis, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var basis []models.CommissionBasis
+	err := database.DB.WithContext(ctx).Raw(commissionBasisQuery, map[string]interface{}{
+		"from": from,
+		"to":   to,
+	}).Scan(&basis).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commission basis: %w", err)
+	}
+	return basis, nil
+}
+
+// Staff returns the staff members with the given IDs and their role
+func (r *PayrollRepository) Staff(ctx context.Context, userIDs []int64) ([]models.PayrollStaff, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var staff []models.PayrollStaff
+	err := database.DB.WithContext(ctx).Table("users u").
+		Select("u.id AS user_id, u.username, r.name AS role").
+		Joins("LEFT JOIN roles r ON r.id = u.role_id").
+		Where("u.id IN ?", userIDs).
+		Scan(&staff).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staff: %w", err)
+	}
+	return staff, nil
+}
+
+// CommissionRates returns the doctors' own commission rates
+func (r *PayrollRepository) CommissionRates(ctx context.Context) ([]models.CommissionRate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var rates []models.CommissionRate
+	if err := database.DB.WithContext(ctx).Order("doctor_id").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list commission rates: %w", err)
+	}
+	return rates, nil
+}
+
+// SetCommissionRate creates or replaces a doctor's commission rate
+func (r *PayrollRepository) SetCommissionRate(ctx context.Context, rate *models.CommissionRate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "doctor_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at", "updated_by"}),
+	}).Create(rate).Error
+	if err != nil {
+		return fmt.Errorf("failed to set commission rate: %w", err)
+	}
+	return nil
+}
+
+// DeleteCommissionRate takes a doctor back to the clinic's default rate
+func (r *PayrollRepository) DeleteCommissionRate(ctx context.Context, doctorID string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.CommissionRate{}, "doctor_id = ?", doctorID).Error; err != nil {
+		return fmt.Errorf("failed to delete commission rate: %w", err)
+	}
+	return nil
+}
+
+// GetPeriod returns the signed-off payroll of period with its lines, or nil when it is not signed off
+func (r *PayrollRepository) GetPeriod(ctx context.Context, period string) (*models.PayrollPeriod, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var signedOff models.PayrollPeriod
+	err := database.DB.WithContext(ctx).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("name, id") }).
+		First(&signedOff, "period = ?", period).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get payroll period: %w", err)
+	}
+	return &signedOff, nil
+}
+
+// SignOff records the payroll of a period with its lines as signed off
+func (r *PayrollRepository) SignOff(ctx context.Context, period *models.PayrollPeriod) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.PayrollPeriod{}).Where("period = ?", period.Period).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check payroll period: %w", err)
+		}
+		if count > 0 {
+			return ErrPayrollSignedOff
+		}
+		if err := tx.Omit(clause.Associations).Create(period).Error; err != nil {
+			return fmt.Errorf("failed to sign off payroll: %w", err)
+		}
+		if len(period.Lines) == 0 {
+			return nil
+		}
+		for i := range period.Lines {
+			period.Lines[i].Period = period.Period
+		}
+		if err := tx.Create(&period.Lines).Error; err != nil {
+			return fmt.Errorf("failed to store payroll lines: %w", err)
+		}
+		return nil
+	})
+}
+
+// Reopen withdraws the sign-off of a period and reports whether it was signed off
+func (r *PayrollRepository) Reopen(ctx context.Context, period string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var deleted int64
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.PayrollLine{}, "period = ?", period).Error; err != nil {
+			return fmt.Errorf("failed to delete payroll lines: %w", err)
+		}
+		result := tx.Delete(&models.PayrollPeriod{}, "period = ?", period)
+		if result.Error != nil {
+			return fmt.Errorf("failed to reopen payroll period: %w", result.Error)
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+	return deleted > 0, err
+}
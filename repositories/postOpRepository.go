@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// PostOpRepository stores the post-operative instructions of each procedure, one per language
+type PostOpRepository struct{}
+
+func NewPostOpRepository() *PostOpRepository {
+	return &PostOpRepository{}
+}
+
+func (r *PostOpRepository) Create(ctx context.Context, instruction *models.PostOpInstruction) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Procedure").Create(instruction).Error; err != nil {
+		return fmt.Errorf("failed to create post-operative instruction: %w", err)
+	}
+	return nil
+}
+
+func (r *PostOpRepository) Get(ctx context.Context, id uint) (*models.PostOpInstruction, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var instruction models.PostOpInstruction
+	if err := database.DB.WithContext(ctx).First(&instruction, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get post-operative instruction: %w", err)
+	}
+	return &instruction, nil
+}
+
+// List returns the instructions of procedureID, or of every procedure when it is nil, by procedure
+// and language
+func (r *PostOpRepository) List(ctx context.Context, procedureID *uint) ([]models.PostOpInstruction, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if procedureID != nil {
+		query = query.Where("procedure_id = ?", *procedureID)
+	}
+	var instructions []models.PostOpInstruction
+	if err := query.Order("procedure_id, language").Find(&instructions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list post-operative instructions: %w", err)
+	}
+	return instructions, nil
+}
+
+// Find returns the instructions of a procedure in language, or nil when there are none
+func (r *PostOpRepository) Find(ctx context.Context, procedureID uint, language string) (*models.PostOpInstruction, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var instruction models.PostOpInstruction
+	err := database.DB.WithContext(ctx).Where("procedure_id = ? AND language = ?", procedureID, language).First(&instruction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get post-operative instruction: %w", err)
+	}
+	return &instruction, nil
+}
+
+// Exists reports whether a procedure already has instructions in language other than excludeID
+func (r *PostOpRepository) Exists(ctx context.Context, procedureID uint, language string, excludeID uint) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.PostOpInstruction{}).
+		Where("procedure_id = ? AND language = ? AND id <> ?", procedureID, language, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check post-operative instructions: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *PostOpRepository) Update(ctx context.Context, instruction *models.PostOpInstruction) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(instruction).Select("procedure_id", "language", "subject", "body", "updated_at", "updated_by").Updates(instruction).Error
+	if err != nil {
+		return fmt.Errorf("failed to update post-operative instruction: %w", err)
+	}
+	return nil
+}
+
+func (r *PostOpRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.PostOpInstruction{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete post-operative instruction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrescriptionRepository stores prescriptions, patients' allergies and the drug interaction table
+type PrescriptionRepository struct{}
+
+func NewPrescriptionRepository() *PrescriptionRepository {
+	return &PrescriptionRepository{}
+}
+
+func (r *PrescriptionRepository) Create(ctx context.Context, prescription *models.Prescription) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(prescription).Error; err != nil {
+		return fmt.Errorf("failed to create prescription: %w", err)
+	}
+	return nil
+}
+
+// CreateControlled saves a prescription of a controlled substance together with its register
+// entry, which takes the next register number.
+func (r *PrescriptionRepository) CreateControlled(ctx context.Context, prescription *models.Prescription, entry *models.ControlledRegisterEntry) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, "controlled_register_lock", func(tx *gorm.DB) error {
+		if err := tx.Omit("Patient").Create(prescription).Error; err != nil {
+			return fmt.Errorf("failed to create prescription: %w", err)
+		}
+
+		// Numbers come from the register itself rather than a sequence, which would leave gaps on rollback
+		var last int64
+		if err := tx.Model(&models.ControlledRegisterEntry{}).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return fmt.Errorf("failed to get the last register number: %w", err)
+		}
+		entry.Number = last + 1
+		entry.PrescriptionID = prescription.ID
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("failed to write the controlled substance register: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByPatient returns the patient's prescriptions, newest first
+func (r *PrescriptionRepository) ListByPatient(ctx context.Context, patientID string) ([]models.Prescription, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var prescriptions []models.Prescription
+	if err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("created_at DESC").Find(&prescriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list prescriptions: %w", err)
+	}
+	return prescriptions, nil
+}
+
+// ActiveDrugs returns the drugs the patient is still taking on day
+func (r *PrescriptionRepository) ActiveDrugs(ctx context.Context, patientID string, day time.Time) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var drugs []string
+	err := database.DB.WithContext(ctx).Model(&models.Prescription{}).
+		Where("patient_id = ? AND ends_on >= ?", patientID, day.Format("2006-01-02")).
+		Distinct().Pluck("drug", &drugs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active prescriptions: %w", err)
+	}
+	return drugs, nil
+}
+
+func (r *PrescriptionRepository) CreateAllergy(ctx context.Context, allergy *models.PatientAllergy) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(allergy).Error; err != nil {
+		return fmt.Errorf("failed to record allergy: %w", err)
+	}
+	return nil
+}
+
+func (r *PrescriptionRepository) ListAllergies(ctx context.Context, patientID string) ([]models.PatientAllergy, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var allergies []models.PatientAllergy
+	if err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("substance").Find(&allergies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list allergies: %w", err)
+	}
+	return allergies, nil
+}
+
+func (r *PrescriptionRepository) DeleteAllergy(ctx context.Context, patientID string, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.PatientAllergy{}, "patient_id = ? AND id = ?", patientID, id).Error; err != nil {
+		return fmt.Errorf("failed to delete allergy: %w", err)
+	}
+	return nil
+}
+
+func (r *PrescriptionRepository) CreateInteraction(ctx context.Context, interaction *models.DrugInteraction) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(interaction).Error; err != nil {
+		return fmt.Errorf("failed to create drug interaction: %w", err)
+	}
+	return nil
+}
+
+// ListInteractions returns the interaction table, limited to the pairs involving drug when it is set
+func (r *PrescriptionRepository) ListInteractions(ctx context.Context, drug string) ([]models.DrugInteraction, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.DrugInteraction{})
+	if drug != "" {
+		query = query.Where("drug_a = ? OR drug_b = ?", drug, drug)
+	}
+	var interactions []models.DrugInteraction
+	if err := query.Order("drug_a, drug_b").Find(&interactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list drug interactions: %w", err)
+	}
+	return interactions, nil
+}
+
+func (r *PrescriptionRepository) DeleteInteraction(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.DrugInteraction{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete drug interaction: %w", err)
+	}
+	return nil
+}
+
+// GetControlledSubstance returns the controlled substance listing drug, or nil when drug is not controlled
+func (r *PrescriptionRepository) GetControlledSubstance(ctx context.Context, drug string) (*models.ControlledSubstance, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var substance models.ControlledSubstance
+	if err := database.DB.WithContext(ctx).First(&substance, "drug = ?", drug).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get controlled substance: %w", err)
+	}
+	return &substance, nil
+}
+
+func (r *PrescriptionRepository) ListControlledSubstances(ctx context.Context) ([]models.ControlledSubstance, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var substances []models.ControlledSubstance
+	if err := database.DB.WithContext(ctx).Order("drug").Find(&substances).Error; err != nil {
+		return nil, fmt.Errorf("failed to list controlled substances: %w", err)
+	}
+	return substances, nil
+}
+
+func (r *PrescriptionRepository) CreateControlledSubstance(ctx context.Context, substance *models.ControlledSubstance) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(substance).Error; err != nil {
+		return fmt.Errorf("failed to create controlled substance: %w", err)
+	}
+	return nil
+}
+
+func (r *PrescriptionRepository) DeleteControlledSubstance(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.ControlledSubstance{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete controlled substance: %w", err)
+	}
+	return nil
+}
+
+// ListRegister returns the register entries for prescriptions written between from and to
+// (exclusive), in register order
+func (r *PrescriptionRepository) ListRegister(ctx context.Context, from, to time.Time) ([]models.ControlledRegisterEntry, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.ControlledRegisterEntry
+	err := database.DB.WithContext(ctx).
+		Where("prescribed_on >= ? AND prescribed_on < ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("number").
+		Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the controlled substance register: %w", err)
+	}
+	return entries, nil
+}
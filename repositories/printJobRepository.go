@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// printJobColumns are the columns of a print job without its content
+const printJobColumns = "id, printer, kind, format, patient_id, record_id, copies, status, content_type, error, claimed_by, claimed_at, printed_at, created_at, created_by"
+
+type PrintJobRepository struct{}
+
+func NewPrintJobRepository() *PrintJobRepository {
+	return &PrintJobRepository{}
+}
+
+func (r *PrintJobRepository) Create(ctx context.Context, job *models.PrintJob) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(job).Error; err != nil {
+		return fmt.Errorf("failed to create print job: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a print job without its content, or nil when there is none
+func (r *PrintJobRepository) GetByID(ctx context.Context, id uint) (*models.PrintJob, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var job models.PrintJob
+	if err := database.DB.WithContext(ctx).Select(printJobColumns).First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get print job: %w", err)
+	}
+	return &job, nil
+}
+
+// List returns the print jobs for printer and with status, either of which may be empty, newest
+// first and without their content
+func (r *PrintJobRepository) List(ctx context.Context, printer, status string, limit int) ([]models.PrintJob, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Select(printJobColumns)
+	if printer != "" {
+		query = query.Where("printer = ?", printer)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var jobs []models.PrintJob
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list print jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Claim hands the oldest job queued for printer, or claimed before staleBefore and never reported
+// on, to agent with its content, or returns nil when there is none. Agents polling at once each
+// get a different job.
+func (r *PrintJobRepository) Claim(ctx context.Context, printer, agent string, staleBefore time.Time) (*models.PrintJob, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	var job models.PrintJob
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("printer = ? AND (status = ? OR (status = ? AND claimed_at < ?))", printer, models.PrintJobQueued, models.PrintJobPrinting, staleBefore).
+			Order("created_at, id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job.Status, job.ClaimedBy, job.ClaimedAt = models.PrintJobPrinting, agent, &now
+		return tx.Model(&models.PrintJob{}).Where("id = ?", job.ID).
+			Updates(map[string]interface{}{"status": job.Status, "claimed_by": agent, "claimed_at": now}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim print job: %w", err)
+	}
+	return &job, nil
+}
+
+// Complete records how printing a claimed job ended, reporting false when the job is not being printed
+func (r *PrintJobRepository) Complete(ctx context.Context, id uint, result models.PrintJobResult) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	updates := map[string]interface{}{"status": models.PrintJobFailed, "error": result.Error}
+	if result.Printed {
+		updates = map[string]interface{}{"status": models.PrintJobPrinted, "error": "", "printed_at": time.Now()}
+	}
+	res := database.DB.WithContext(ctx).Model(&models.PrintJob{}).Where("id = ? AND status = ?", id, models.PrintJobPrinting).Updates(updates)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to complete print job: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// Cancel takes a job off the queue, reporting false when it is no longer queued
+func (r *PrintJobRepository) Cancel(ctx context.Context, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	res := database.DB.WithContext(ctx).Model(&models.PrintJob{}).Where("id = ? AND status = ?", id, models.PrintJobQueued).
+		Update("status", models.PrintJobCancelled)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to cancel print job: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
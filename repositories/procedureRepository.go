@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// ProcedureRepository stores the procedure catalog and which doctors perform each procedure
+type ProcedureRepository struct{}
+
+func NewProcedureRepository() *ProcedureRepository {
+	return &ProcedureRepository{}
+}
+
+func (r *ProcedureRepository) Create(ctx context.Context, procedure *models.Procedure) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(procedure).Error; err != nil {
+		return fmt.Errorf("failed to create procedure: %w", err)
+	}
+	publishProcedureChanged(ctx, procedure.ID)
+	return nil
+}
+
+func (r *ProcedureRepository) GetByID(ctx context.Context, id uint) (*models.Procedure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var procedure models.Procedure
+	if err := database.DB.WithContext(ctx).First(&procedure, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get procedure: %w", err)
+	}
+	return &procedure, nil
+}
+
+// List returns the catalog by name, limited to specialty when it is set
+func (r *ProcedureRepository) List(ctx context.Context, specialty string) ([]models.Procedure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.Procedure{})
+	if specialty != "" {
+		query = query.Where("specialty = ?", specialty)
+	}
+
+	var procedures []models.Procedure
+	if err := query.Order("name ASC").Find(&procedures).Error; err != nil {
+		return nil, fmt.Errorf("failed to list procedures: %w", err)
+	}
+	return procedures, nil
+}
+
+func (r *ProcedureRepository) Update(ctx context.Context, procedure *models.Procedure) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(procedure).Select("name", "specialty", "price", "updated_at").Updates(procedure)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update procedure: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("procedure not found")
+	}
+	publishProcedureChanged(ctx, procedure.ID)
+	return nil
+}
+
+// Delete removes a procedure; the doctors performing it lose it with the cascade
+func (r *ProcedureRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Procedure{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete procedure: %w", err)
+	}
+	publishProcedureChanged(ctx, id)
+	return nil
+}
+
+// GetByDoctor returns the procedures doctorID performs, by name
+func (r *ProcedureRepository) GetByDoctor(ctx context.Context, doctorID string) ([]models.Procedure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var procedures []models.Procedure
+	err := database.DB.WithContext(ctx).Model(&models.Procedure{}).
+		Joins("JOIN doctor_procedure dp ON dp.procedure_id = procedure.id").
+		Where("dp.doctor_id = ?", doctorID).
+		Order("procedure.name ASC").
+		Find(&procedures).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get doctor procedures: %w", err)
+	}
+	return procedures, nil
+}
+
+// SetDoctorProcedures replaces the procedures doctorID performs with procedureIDs
+func (r *ProcedureRepository) SetDoctorProcedures(ctx context.Context, doctorID string, procedureIDs []uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, fmt.Sprintf("doctor_procedure_lock:%s", doctorID), func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&models.DoctorProcedure{}, "doctor_id = ?", doctorID).Error; err != nil {
+				return fmt.Errorf("failed to clear doctor procedures: %w", err)
+			}
+			if len(procedureIDs) == 0 {
+				return nil
+			}
+			links := make([]models.DoctorProcedure, 0, len(procedureIDs))
+			for _, id := range procedureIDs {
+				links = append(links, models.DoctorProcedure{DoctorID: doctorID, ProcedureID: id})
+			}
+			if err := tx.Omit("Doctor", "Procedure").Create(&links).Error; err != nil {
+				return fmt.Errorf("failed to set doctor procedures: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	publishDoctorChanged(ctx, doctorID)
+	return nil
+}
+
+// CountExisting returns how many of ids are in the catalog
+func (r *ProcedureRepository) CountExisting(ctx context.Context, ids []uint) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Procedure{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count procedures: %w", err)
+	}
+	return count, nil
+}
+
+// publishProcedureChanged tells subscribers, such as the response cache, that a procedure was saved or removed
+func publishProcedureChanged(ctx context.Context, id uint) {
+	events.Publish(ctx, events.Event{Name: events.ProcedureChanged, Record: "procedure", RecordID: strconv.FormatUint(uint64(id), 10)})
+}
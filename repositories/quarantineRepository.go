@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// quarantinedFileColumns are the columns of a quarantined file but its content
+const quarantinedFileColumns = "id, source, patient_id, file_name, content_type, size, threat, uploaded_by, detected_at"
+
+// QuarantineRepository keeps the uploads the virus scanner flagged
+type QuarantineRepository struct{}
+
+func NewQuarantineRepository() *QuarantineRepository {
+	return &QuarantineRepository{}
+}
+
+func (r *QuarantineRepository) Create(ctx context.Context, file *models.QuarantinedFile) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(file).Error; err != nil {
+		return fmt.Errorf("failed to quarantine file: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a quarantined file without its content, or nil when there is none
+func (r *QuarantineRepository) GetByID(ctx context.Context, id uint) (*models.QuarantinedFile, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var file models.QuarantinedFile
+	if err := database.DB.WithContext(ctx).Select(quarantinedFileColumns).First(&file, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get quarantined file: %w", err)
+	}
+	return &file, nil
+}
+
+// List returns the quarantined files without their content, latest first
+func (r *QuarantineRepository) List(ctx context.Context) ([]models.QuarantinedFile, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var files []models.QuarantinedFile
+	err := database.DB.WithContext(ctx).Select(quarantinedFileColumns).Order("detected_at DESC, id DESC").Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantined files: %w", err)
+	}
+	return files, nil
+}
+
+// Delete discards a quarantined file and reports whether there was one
+func (r *QuarantineRepository) Delete(ctx context.Context, id uint) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Delete(&models.QuarantinedFile{}, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete quarantined file: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// readOnlyToggleKey holds the admins' read-only toggle. It is kept out of the cache namespace so
+// invalidating the cache never turns read-only mode off.
+const readOnlyToggleKey = "read_only"
+
+// ErrReadOnlyToggleUnavailable is returned when the toggle cannot be saved because Redis is unavailable
+var ErrReadOnlyToggleUnavailable = errors.New("redis is unavailable to share read-only mode")
+
+// ReadOnlyRepository keeps the admins' read-only toggle in Redis, where every server reads it, and
+// probes the primary database and its replica
+type ReadOnlyRepository struct{}
+
+func NewReadOnlyRepository() *ReadOnlyRepository {
+	return &ReadOnlyRepository{}
+}
+
+// Toggle returns the read-only toggle, or nil when no admin has turned read-only mode on
+func (r *ReadOnlyRepository) Toggle(ctx context.Context) (*models.ReadOnlyToggle, error) {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return nil, ErrReadOnlyToggleUnavailable
+	}
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	value, err := database.RedisClient.Get(ctx, readOnlyToggleKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		database.RedisBreaker.Success()
+		return nil, nil
+	}
+	if err != nil {
+		database.RedisBreaker.Failure()
+		return nil, fmt.Errorf("failed to get read-only toggle: %w", err)
+	}
+	database.RedisBreaker.Success()
+	var toggle models.ReadOnlyToggle
+	if err := json.Unmarshal(value, &toggle); err != nil {
+		return nil, fmt.Errorf("failed to decode read-only toggle: %w", err)
+	}
+	return &toggle, nil
+}
+
+// SaveToggle turns read-only mode on for every server until it is cleared
+func (r *ReadOnlyRepository) SaveToggle(ctx context.Context, toggle models.ReadOnlyToggle) error {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return ErrReadOnlyToggleUnavailable
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	value, err := json.Marshal(toggle)
+	if err != nil {
+		return fmt.Errorf("failed to encode read-only toggle: %w", err)
+	}
+	if err := database.RedisClient.Set(ctx, readOnlyToggleKey, value, 0).Err(); err != nil {
+		database.RedisBreaker.Failure()
+		return fmt.Errorf("failed to save read-only toggle: %w", err)
+	}
+	database.RedisBreaker.Success()
+	return nil
+}
+
+// ClearToggle turns the admins' read-only mode off
+func (r *ReadOnlyRepository) ClearToggle(ctx context.Context) error {
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return ErrReadOnlyToggleUnavailable
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.RedisClient.Del(ctx, readOnlyToggleKey).Err(); err != nil {
+		database.RedisBreaker.Failure()
+		return fmt.Errorf("failed to clear read-only toggle: %w", err)
+	}
+	database.RedisBreaker.Success()
+	return nil
+}
+
+// HasReplica reports whether a read replica is configured
+func (r *ReadOnlyRepository) HasReplica() bool {
+	return database.Replica != nil
+}
+
+func (r *ReadOnlyRepository) PingPrimary(ctx context.Context) error {
+	return database.PingPrimary(ctx)
+}
+
+func (r *ReadOnlyRepository) PingReplica(ctx context.Context) error {
+	return database.PingReplica(ctx)
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordAccessRepository stores the reads of patients' records and lists them for the patients
+// and admins
+type RecordAccessRepository struct{}
+
+func NewRecordAccessRepository() *RecordAccessRepository {
+	return &RecordAccessRepository{}
+}
+
+func (r *RecordAccessRepository) Create(ctx context.Context, access *models.RecordAccess) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(access).Error; err != nil {
+		return fmt.Errorf("failed to record patient record access: %w", err)
+	}
+	return nil
+}
+
+// List returns the reads matching filter, newest first
+func (r *RecordAccessRepository) List(ctx context.Context, filter models.RecordAccessFilter) ([]models.RecordAccess, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var accesses []models.RecordAccess
+	query := r.filter(database.DB.WithContext(ctx).Model(&models.RecordAccess{}), "", filter)
+	if err := query.Order("accessed_at DESC, id DESC").Limit(filter.Limit).Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list patient record accesses: %w", err)
+	}
+	return accesses, nil
+}
+
+// Viewers returns the reads of the patient's record matching filter as the patient is shown them,
+// newest first, naming doctors by their name and other staff by their username
+func (r *RecordAccessRepository) Viewers(ctx context.Context, filter models.RecordAccessFilter) ([]models.RecordViewer, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var viewers []models.RecordViewer
+	query := r.filter(database.DB.WithContext(ctx).Table("record_access a"), "a.", filter).
+		Select("a.accessed_at, a.section, a.role AS viewer_role, " +
+			"COALESCE(NULLIF(CONCAT_WS(' ', d.first_name, d.last_name), ''), u.username, 'Unidentified user') AS viewer_name").
+		Joins("LEFT JOIN users u ON u.id = a.user_id").
+		Joins("LEFT JOIN doctor d ON d.user_id = a.user_id")
+	if err := query.Order("a.accessed_at DESC, a.id DESC").Limit(filter.Limit).Scan(&viewers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list patient record viewers: %w", err)
+	}
+	return viewers, nil
+}
+
+func (r *RecordAccessRepository) filter(query *gorm.DB, prefix string, filter models.RecordAccessFilter) *gorm.DB {
+	if filter.PatientID != "" {
+		query = query.Where(prefix+"patient_id = ?", filter.PatientID)
+	}
+	if filter.UserID != nil {
+		query = query.Where(prefix+"user_id = ?", *filter.UserID)
+	}
+	if filter.Section != "" {
+		query = query.Where(prefix+"section = ?", filter.Section)
+	}
+	if filter.From != nil {
+		query = query.Where(prefix+"accessed_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where(prefix+"accessed_at < ?", *filter.To)
+	}
+	return query
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReferralRepository stores the referral letter templates and patients' referrals to specialists
+type ReferralRepository struct{}
+
+func NewReferralRepository() *ReferralRepository {
+	return &ReferralRepository{}
+}
+
+func (r *ReferralRepository) CreateTemplate(ctx context.Context, template *models.ReferralTemplate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(template).Error; err != nil {
+		return fmt.Errorf("failed to create referral template: %w", err)
+	}
+	return nil
+}
+
+func (r *ReferralRepository) GetTemplate(ctx context.Context, id uint) (*models.ReferralTemplate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var template models.ReferralTemplate
+	if err := database.DB.WithContext(ctx).First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get referral template: %w", err)
+	}
+	return &template, nil
+}
+
+// ListTemplates returns the templates by name
+func (r *ReferralRepository) ListTemplates(ctx context.Context) ([]models.ReferralTemplate, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var templates []models.ReferralTemplate
+	if err := database.DB.WithContext(ctx).Order("name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list referral templates: %w", err)
+	}
+	return templates, nil
+}
+
+// TemplateExists reports whether a template other than excludeID is called name
+func (r *ReferralRepository) TemplateExists(ctx context.Context, name string, excludeID uint) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.ReferralTemplate{}).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", name, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check referral templates: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *ReferralRepository) UpdateTemplate(ctx context.Context, template *models.ReferralTemplate) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(template).Select("name", "specialty", "body", "updated_at", "updated_by").Updates(template).Error
+	if err != nil {
+		return fmt.Errorf("failed to update referral template: %w", err)
+	}
+	return nil
+}
+
+// DeleteTemplate removes a template; referrals written from it keep their letters
+func (r *ReferralRepository) DeleteTemplate(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.ReferralTemplate{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete referral template: %w", err)
+	}
+	return nil
+}
+
+// Create saves a referral with the examination excerpts and imaging of its letter
+func (r *ReferralRepository) Create(ctx context.Context, referral *models.Referral) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(referral).Error; err != nil {
+			return err
+		}
+		for i := range referral.Examinations {
+			referral.Examinations[i].ReferralID = referral.ID
+		}
+		for i := range referral.Imaging {
+			referral.Imaging[i].ReferralID = referral.ID
+		}
+		if len(referral.Examinations) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&referral.Examinations).Error; err != nil {
+				return err
+			}
+		}
+		if len(referral.Imaging) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&referral.Imaging).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create referral: %w", err)
+	}
+	return nil
+}
+
+// Get returns a referral of the patient with its excerpts and imaging, or nil when there is none
+func (r *ReferralRepository) Get(ctx context.Context, patientID string, id uint) (*models.Referral, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var referral models.Referral
+	err := r.withLetter(database.DB.WithContext(ctx)).First(&referral, "id = ? AND patient_id = ?", id, patientID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get referral: %w", err)
+	}
+	return &referral, nil
+}
+
+// List returns the patient's referrals, newest first
+func (r *ReferralRepository) List(ctx context.Context, patientID string) ([]models.Referral, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var referrals []models.Referral
+	err := r.withLetter(database.DB.WithContext(ctx)).Where("patient_id = ?", patientID).Order("created_at DESC, id DESC").Find(&referrals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrals: %w", err)
+	}
+	return referrals, nil
+}
+
+// withLetter loads what a letter is printed from along with referrals
+func (r *ReferralRepository) withLetter(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("Patient").
+		Preload("Doctor").
+		Preload("Examinations", func(db *gorm.DB) *gorm.DB { return db.Order("examined_at, id") }).
+		Preload("Imaging", func(db *gorm.DB) *gorm.DB { return db.Order("study_date, imaging_study_id") })
+}
+
+// SetStatus records how far the referral has got with the specialist
+func (r *ReferralRepository) SetStatus(ctx context.Context, referral *models.Referral) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(referral).Select("status", "status_note", "updated_at", "updated_by").Updates(referral).Error
+	if err != nil {
+		return fmt.Errorf("failed to update referral: %w", err)
+	}
+	return nil
+}
+
+// LinkedStudy returns the content of an imaging study linked from the referral's letter, or nil
+// when the study is not linked from it
+func (r *ReferralRepository) LinkedStudy(ctx context.Context, referralID, studyID uint) (*models.ImagingStudy, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var study models.ImagingStudy
+	err := database.DB.WithContext(ctx).
+		Joins("JOIN referral_imaging ri ON ri.imaging_study_id = imaging_study.id").
+		Where("ri.referral_id = ? AND imaging_study.id = ?", referralID, studyID).
+		First(&study).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get referral imaging: %w", err)
+	}
+	return &study, nil
+}
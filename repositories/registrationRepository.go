@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRegistrationReviewed is returned when a pre-registration has already been converted or rejected.
+var ErrRegistrationReviewed = errors.New("registration has already been reviewed")
+
+// RegistrationRepository stores pre-registrations submitted on the public form
+type RegistrationRepository struct {
+	cache       *cache.Cache
+	patientRepo *PatientRepository
+}
+
+func NewRegistrationRepository(cache *cache.Cache, patientRepo *PatientRepository) *RegistrationRepository {
+	return &RegistrationRepository{cache: cache, patientRepo: patientRepo}
+}
+
+func (r *RegistrationRepository) Create(ctx context.Context, registration *models.PendingRegistration) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(registration).Error; err != nil {
+		return fmt.Errorf("failed to create registration: %w", err)
+	}
+	return nil
+}
+
+func (r *RegistrationRepository) GetByID(ctx context.Context, id uint) (*models.PendingRegistration, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var registration models.PendingRegistration
+	if err := database.DB.WithContext(ctx).First(&registration, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get registration: %w", err)
+	}
+	return &registration, nil
+}
+
+// List returns registrations in status, oldest first so the queue is worked in order
+func (r *RegistrationRepository) List(ctx context.Context, status string) ([]models.PendingRegistration, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var registrations []models.PendingRegistration
+	if err := query.Order("created_at, id").Find(&registrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list registrations: %w", err)
+	}
+	return registrations, nil
+}
+
+// Convert creates the patient from a pending registration, with its medical history as a note by the
+// reviewer, and marks the registration converted, all in one transaction
+func (r *RegistrationRepository) Convert(ctx context.Context, registration *models.PendingRegistration, patient *models.Patient, reviewer int64, note string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.WithLock(ctx, patientCreateLockKey(patient), func(tx *gorm.DB) error {
+		if err := lockPendingRegistration(tx, registration.ID); err != nil {
+			return err
+		}
+		if err := r.patientRepo.createInTx(tx, patient); err != nil {
+			return err
+		}
+
+		if history := registrationHistory(registration); history != "" {
+			patientNote := &models.PatientNote{PatientID: patient.ID, AuthorID: reviewer, Body: history}
+			if err := tx.Omit("Patient").Create(patientNote).Error; err != nil {
+				return fmt.Errorf("failed to save medical history: %w", err)
+			}
+		}
+
+		now := time.Now()
+		registration.Status = models.RegistrationConverted
+		registration.PatientID = &patient.ID
+		registration.ReviewedBy = &reviewer
+		registration.ReviewedAt = &now
+		registration.ReviewNote = note
+		if err := tx.Model(registration).Select("status", "patient_id", "reviewed_by", "reviewed_at", "review_note").Updates(registration).Error; err != nil {
+			return fmt.Errorf("failed to update registration: %w", err)
+		}
+
+		if err := r.cache.Delete(ctx, r.cache.Key(ctx, "patient", patient.ID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+	if err != nil {
+		return err
+	}
+	events.Publish(ctx, events.Event{Name: events.PatientCreated, PatientID: patient.ID})
+	return nil
+}
+
+// Reject closes a pending registration without creating a patient
+func (r *RegistrationRepository) Reject(ctx context.Context, registration *models.PendingRegistration, reviewer int64, note string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingRegistration(tx, registration.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		registration.Status = models.RegistrationRejected
+		registration.ReviewedBy = &reviewer
+		registration.ReviewedAt = &now
+		registration.ReviewNote = note
+		if err := tx.Model(registration).Select("status", "reviewed_by", "reviewed_at", "review_note").Updates(registration).Error; err != nil {
+			return fmt.Errorf("failed to update registration: %w", err)
+		}
+		return nil
+	})
+}
+
+// lockPendingRegistration locks a registration for review and fails unless it is still pending
+func lockPendingRegistration(tx *gorm.DB, id uint) error {
+	var current models.PendingRegistration
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, status").First(&current, id).Error; err != nil {
+		return fmt.Errorf("failed to get registration: %w", err)
+	}
+	if current.Status != models.RegistrationPending {
+		return ErrRegistrationReviewed
+	}
+	return nil
+}
+
+// registrationHistory formats the medical details submitted on the form for the patient's notes
+func registrationHistory(registration *models.PendingRegistration) string {
+	var sections []string
+	if registration.MedicalHistory != "" {
+		sections = append(sections, "Medical history: "+registration.MedicalHistory)
+	}
+	if registration.Allergies != "" {
+		sections = append(sections, "Allergies: "+registration.Allergies)
+	}
+	if registration.Medications != "" {
+		sections = append(sections, "Current medications: "+registration.Medications)
+	}
+	if len(sections) == 0 {
+		return ""
+	}
+	return "Submitted on the pre-registration form:\n\n" + strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReportTokenRepository stores the read-only tokens of reporting tools
+type ReportTokenRepository struct{}
+
+func NewReportTokenRepository() *ReportTokenRepository {
+	return &ReportTokenRepository{}
+}
+
+func (r *ReportTokenRepository) Create(ctx context.Context, token *models.ReportToken) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create report token: %w", err)
+	}
+	return nil
+}
+
+func (r *ReportTokenRepository) Get(ctx context.Context, id uint) (*models.ReportToken, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var token models.ReportToken
+	if err := database.DB.WithContext(ctx).First(&token, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get report token: %w", err)
+	}
+	return &token, nil
+}
+
+// ByHash returns the token with the given hash, or nil when there is none
+func (r *ReportTokenRepository) ByHash(ctx context.Context, hash string) (*models.ReportToken, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var token models.ReportToken
+	if err := database.DB.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get report token: %w", err)
+	}
+	return &token, nil
+}
+
+// List returns every token, newest first
+func (r *ReportTokenRepository) List(ctx context.Context) ([]models.ReportToken, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var tokens []models.ReportToken
+	if err := database.DB.WithContext(ctx).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list report tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Revoke stops a token from working
+func (r *ReportTokenRepository) Revoke(ctx context.Context, token *models.ReportToken, revokedBy *int64, at time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.ReportToken{}).
+		Where("id = ? AND revoked_at IS NULL", token.ID).
+		Updates(map[string]interface{}{"revoked_at": at, "revoked_by": revokedBy}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke report token: %w", err)
+	}
+	token.RevokedAt, token.RevokedBy = &at, revokedBy
+	return nil
+}
+
+// Touch records when a token was last used
+func (r *ReportTokenRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(&models.ReportToken{}).Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+	if err != nil {
+		return fmt.Errorf("failed to record report token use: %w", err)
+	}
+	return nil
+}
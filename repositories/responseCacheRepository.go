@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ResponseCacheRepository keeps cached GET responses in Redis, grouped by the route they answer
+type ResponseCacheRepository struct {
+	cache *cache.Cache
+}
+
+func NewResponseCacheRepository(cache *cache.Cache) *ResponseCacheRepository {
+	return &ResponseCacheRepository{cache: cache}
+}
+
+// Get returns the response cached for a request to route, or nil when there is none
+func (r *ResponseCacheRepository) Get(ctx context.Context, route, fingerprint string) (*models.CachedResponse, error) {
+	var response models.CachedResponse
+	found, err := r.cache.GetObject(ctx, r.cache.Key(ctx, "response", route, fingerprint), &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &response, nil
+}
+
+// Save keeps the response to a request to route for ttl
+func (r *ResponseCacheRepository) Save(ctx context.Context, route, fingerprint string, response models.CachedResponse, ttl time.Duration) error {
+	if err := r.cache.SetObject(ctx, r.cache.Key(ctx, "response", route, fingerprint), response, ttl); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
+
+// Invalidate drops every response cached for route
+func (r *ResponseCacheRepository) Invalidate(ctx context.Context, route string) error {
+	if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "response", route)+":*"); err != nil {
+		return fmt.Errorf("failed to invalidate cached responses: %w", err)
+	}
+	return nil
+}
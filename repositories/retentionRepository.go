@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// retentionEntity is where an entity's records are kept and which timestamp their retention
+// period runs from
+type retentionEntity struct {
+	table  string
+	column string
+	// document is the SQL giving a row, t, as the JSON written to the archive
+	document string
+}
+
+// retentionEntities are the records retention rules apply to, matching config.RetentionEntities
+var retentionEntities = map[string]retentionEntity{
+	"communication_log":     {table: "communication_log", column: "sent_at"},
+	"document_share_access": {table: "document_share_access", column: "accessed_at"},
+	// Examinations are archived with their attachment and audio note records, which are deleted with them
+	"examination": {table: "examination", column: "created_at", document: "to_jsonb(t) || jsonb_build_object('attachments', " +
+		"COALESCE((SELECT jsonb_agg(to_jsonb(x) ORDER BY x.id) FROM examination_attachment x WHERE x.examination_id = t.id), '[]'::jsonb), 'audio_notes', " +
+		"COALESCE((SELECT jsonb_agg(to_jsonb(n) ORDER BY n.id) FROM examination_audio_note n WHERE n.examination_id = t.id), '[]'::jsonb))"},
+	"hl7_message":   {table: "hl7_message", column: "created_at"},
+	"print_job":     {table: "print_job", column: "created_at"},
+	"record_access": {table: "record_access", column: "accessed_at"},
+}
+
+// retainedRow is a record removed by a retention rule
+type retainedRow struct {
+	ID        uint
+	PatientID string
+	Document  string
+}
+
+// RetentionRepository removes the records older than their retention period, a batch at a time,
+// and keeps the record of each run
+type RetentionRepository struct {
+	examinationRepo *ExaminationRepository
+}
+
+func NewRetentionRepository(examinationRepo *ExaminationRepository) *RetentionRepository {
+	return &RetentionRepository{examinationRepo: examinationRepo}
+}
+
+func retentionEntityOf(entity string) (retentionEntity, error) {
+	target, ok := retentionEntities[entity]
+	if !ok {
+		return retentionEntity{}, fmt.Errorf("no retention rules apply to %q", entity)
+	}
+	return target, nil
+}
+
+// CountDue counts the entity's records from before cutoff
+func (r *RetentionRepository) CountDue(ctx context.Context, entity string, cutoff time.Time) (int64, error) {
+	target, err := retentionEntityOf(entity)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Table(target.table).Where(target.column+" < ?", cutoff).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count %s records due for removal: %w", entity, err)
+	}
+	return count, nil
+}
+
+// Purge deletes up to limit of the entity's records from before cutoff, oldest first, and returns
+// how many it deleted
+func (r *RetentionRepository) Purge(ctx context.Context, entity string, cutoff time.Time, limit int) (int64, error) {
+	return r.remove(ctx, entity, cutoff, limit, nil)
+}
+
+// Archive passes up to limit of the entity's records from before cutoff, oldest first, to write as
+// JSON and deletes them once write returns without error. It returns how many it archived.
+func (r *RetentionRepository) Archive(ctx context.Context, entity string, cutoff time.Time, limit int, write func([]json.RawMessage) error) (int64, error) {
+	if write == nil {
+		return 0, fmt.Errorf("no archive to write %s records to", entity)
+	}
+	return r.remove(ctx, entity, cutoff, limit, write)
+}
+
+func (r *RetentionRepository) remove(ctx context.Context, entity string, cutoff time.Time, limit int, write func([]json.RawMessage) error) (int64, error) {
+	target, err := retentionEntityOf(entity)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	document := target.document
+	if document == "" {
+		document = "to_jsonb(t)"
+	}
+	patient := "''"
+	if entity == "examination" {
+		patient = "t.patient_id"
+	}
+
+	var rows []retainedRow
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := fmt.Sprintf("SELECT t.id, %s AS patient_id, %s AS document FROM %s t WHERE t.%s < ? ORDER BY t.%s, t.id LIMIT ? FOR UPDATE",
+			patient, document, target.table, target.column, target.column)
+		if err := tx.Raw(query, cutoff, limit).Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if write != nil {
+			documents := make([]json.RawMessage, len(rows))
+			for i, row := range rows {
+				documents[i] = json.RawMessage(row.Document)
+			}
+			if err := write(documents); err != nil {
+				return err
+			}
+		}
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		return tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN ?", target.table), ids).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove %s records: %w", entity, err)
+	}
+
+	if entity == "examination" && len(rows) > 0 {
+		for _, row := range rows {
+			if err := r.examinationRepo.DeleteCache(ctx, row.PatientID, row.ID); err != nil {
+				log.Printf("Failed to delete examination %d from cache: %v", row.ID, err)
+			}
+		}
+		if err := r.examinationRepo.DeleteAllCache(ctx); err != nil {
+			log.Printf("Failed to delete examination lists from cache: %v", err)
+		}
+	}
+	return int64(len(rows)), nil
+}
+
+func (r *RetentionRepository) CreateRun(ctx context.Context, run *models.RetentionRun) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(run).Error; err != nil {
+		return fmt.Errorf("failed to record retention run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns the latest runs, of entity when one is given
+func (r *RetentionRepository) ListRuns(ctx context.Context, entity string, limit int) ([]models.RetentionRun, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Order("started_at DESC, id DESC").Limit(limit)
+	if entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+	var runs []models.RetentionRun
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list retention runs: %w", err)
+	}
+	return runs, nil
+}
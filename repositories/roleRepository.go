@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ErrRoleInUse is returned when deleting a role users still have
+var ErrRoleInUse = errors.New("the role is still assigned to users")
+
+// RoleRepository stores roles and the permissions granted to them. Permission lookups are cached
+// per role and dropped whenever a role's permissions change.
+type RoleRepository struct {
+	cache *cache.Cache
+}
+
+func NewRoleRepository(cache *cache.Cache) *RoleRepository {
+	return &RoleRepository{cache: cache}
+}
+
+func (r *RoleRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Permissions").Create(role).Error; err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return nil
+}
+
+func (r *RoleRepository) GetRole(ctx context.Context, id int64) (*models.Role, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var role models.Role
+	if err := database.DB.WithContext(ctx).Preload("Permissions", orderPermissions).First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+// RoleNameTaken reports whether another role than id is called name, ignoring case
+func (r *RoleRepository) RoleNameTaken(ctx context.Context, name string, id int64) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Role{}).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check role name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListRoles returns every role with its permissions
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var roles []models.Role
+	if err := database.DB.WithContext(ctx).Preload("Permissions", orderPermissions).Order("id").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) UpdateRole(ctx context.Context, role *models.Role) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(role).Update("description", role.Description).Error; err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// DeleteRole deletes a role no user has, with the permissions granted to it
+func (r *RoleRepository) DeleteRole(ctx context.Context, id int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users int64
+		if err := tx.Model(&models.User{}).Where("role_id = ?", id).Count(&users).Error; err != nil {
+			return err
+		}
+		if users > 0 {
+			return ErrRoleInUse
+		}
+		if err := tx.Where("role_id = ?", id).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Role{}, id).Error
+	})
+	if errors.Is(err, ErrRoleInUse) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	return r.dropPermissions(ctx, id)
+}
+
+// ListPermissions returns every permission that can be granted to roles
+func (r *RoleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var permissions []models.Permission
+	if err := orderPermissions(database.DB.WithContext(ctx)).Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *RoleRepository) GetPermission(ctx context.Context, id int64) (*models.Permission, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var permission models.Permission
+	if err := database.DB.WithContext(ctx).First(&permission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+	return &permission, nil
+}
+
+// Grant gives a role a permission; granting one it already has changes nothing
+func (r *RoleRepository) Grant(ctx context.Context, roleID, permissionID int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	grant := models.RolePermission{RoleID: roleID, PermissionID: permissionID}
+	if err := database.DB.WithContext(ctx).Where(&grant).FirstOrCreate(&grant).Error; err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return r.dropPermissions(ctx, roleID)
+}
+
+// Revoke takes a permission from a role
+func (r *RoleRepository) Revoke(ctx context.Context, roleID, permissionID int64) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Where("role_id = ? AND permission_id = ?", roleID, permissionID).
+		Delete(&models.RolePermission{}).Error; err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return r.dropPermissions(ctx, roleID)
+}
+
+// RolePermissions returns the permissions granted to a role
+func (r *RoleRepository) RolePermissions(ctx context.Context, roleID int64) ([]models.Permission, error) {
+	return rolePermissions(ctx, r.cache, database.DB, roleID)
+}
+
+func (r *RoleRepository) dropPermissions(ctx context.Context, roleID int64) error {
+	if err := r.cache.Delete(ctx, rolePermissionsKey(ctx, r.cache, roleID)); err != nil {
+		return fmt.Errorf("failed to invalidate role permissions: %w", err)
+	}
+	return nil
+}
+
+// rolePermissions looks up the permissions granted to a role, through the cache
+func rolePermissions(ctx context.Context, c *cache.Cache, db *gorm.DB, roleID int64) ([]models.Permission, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	cacheKey := rolePermissionsKey(ctx, c, roleID)
+	var permissions []models.Permission
+	if found, err := c.GetObject(ctx, cacheKey, &permissions); err != nil {
+		log.Printf("Failed to get role permissions from cache: %v", err)
+	} else if found {
+		return permissions, nil
+	}
+
+	err := orderPermissions(db.WithContext(ctx).
+		Joins("JOIN role_permissions rp ON permissions.id = rp.permission_id").
+		Where("rp.role_id = ?", roleID)).
+		Find(&permissions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	if err := c.SetObject(ctx, cacheKey, permissions, cache.ItemTTL("user")); err != nil {
+		log.Printf("Failed to set role permissions in cache: %v", err)
+	}
+	return permissions, nil
+}
+
+func rolePermissionsKey(ctx context.Context, c *cache.Cache, roleID int64) string {
+	return c.Key(ctx, "role_permissions", roleID)
+}
+
+func orderPermissions(db *gorm.DB) *gorm.DB {
+	return db.Order("permissions.name")
+}
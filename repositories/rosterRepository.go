@@ -0,0 +1,343 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShiftOverlap is returned when a staff member would work two shifts at once
+	ErrShiftOverlap = errors.New("the staff member already has a shift at that time")
+	// ErrRequestNotPending is returned when reviewing a swap or leave request already decided
+	ErrRequestNotPending = errors.New("the request is no longer pending")
+)
+
+// RosterRepository stores the staff roster: shifts, shift swaps and leave requests
+type RosterRepository struct{}
+
+func NewRosterRepository() *RosterRepository {
+	return &RosterRepository{}
+}
+
+// UserExists reports whether a staff account exists
+func (r *RosterRepository) UserExists(ctx context.Context, userID int64) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check user: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateShift saves a shift unless it overlaps another of the staff member's
+func (r *RosterRepository) CreateShift(ctx context.Context, shift *models.Shift) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkShiftFree(tx, shift.UserID, shift.StartsAt, shift.EndsAt, 0); err != nil {
+			return err
+		}
+		if err := tx.Omit("User").Create(shift).Error; err != nil {
+			return fmt.Errorf("failed to create shift: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetShift returns a shift, or nil when there is none
+func (r *RosterRepository) GetShift(ctx context.Context, id uint) (*models.Shift, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var shift models.Shift
+	if err := database.DB.WithContext(ctx).First(&shift, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get shift: %w", err)
+	}
+	return &shift, nil
+}
+
+// UpdateShift changes a shift unless it would overlap another of the staff member's
+func (r *RosterRepository) UpdateShift(ctx context.Context, shift *models.Shift) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkShiftFree(tx, shift.UserID, shift.StartsAt, shift.EndsAt, shift.ID); err != nil {
+			return err
+		}
+		err := tx.Model(shift).Select("user_id", "branch", "starts_at", "ends_at", "note", "updated_at", "updated_by").Updates(shift).Error
+		if err != nil {
+			return fmt.Errorf("failed to update shift: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *RosterRepository) DeleteShift(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Shift{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete shift: %w", err)
+	}
+	return nil
+}
+
+// Shifts returns the shifts starting from start until end, of userID and at branch when given, by
+// start time
+func (r *RosterRepository) Shifts(ctx context.Context, start, end time.Time, userID *int64, branch string) ([]models.RosterShift, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("shift s").
+		Select("s.*, u.username, r.name AS role").
+		Joins("JOIN users u ON u.id = s.user_id").
+		Joins("LEFT JOIN roles r ON r.id = u.role_id").
+		Where("s.starts_at >= ? AND s.starts_at < ?", start, end)
+	if userID != nil {
+		query = query.Where("s.user_id = ?", *userID)
+	}
+	if branch != "" {
+		query = query.Where("s.branch = ?", branch)
+	}
+	var shifts []models.RosterShift
+	if err := query.Order("s.starts_at, u.username, s.id").Scan(&shifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list shifts: %w", err)
+	}
+	return shifts, nil
+}
+
+// checkShiftFree refuses a shift overlapping another of the staff member's than excludeID
+func checkShiftFree(tx *gorm.DB, userID int64, start, end time.Time, excludeID uint) error {
+	var count int64
+	err := tx.Model(&models.Shift{}).
+		Where("user_id = ? AND id <> ? AND starts_at < ? AND ends_at > ?", userID, excludeID, end, start).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check shifts: %w", err)
+	}
+	if count > 0 {
+		return ErrShiftOverlap
+	}
+	return nil
+}
+
+func (r *RosterRepository) CreateSwap(ctx context.Context, swap *models.ShiftSwap) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Shift", "FromUser", "ToUser").Create(swap).Error; err != nil {
+		return fmt.Errorf("failed to create shift swap: %w", err)
+	}
+	return nil
+}
+
+// GetSwap returns a shift swap, or nil when there is none
+func (r *RosterRepository) GetSwap(ctx context.Context, id uint) (*models.RosterSwap, error) {
+	swaps, err := r.swaps(ctx, "w.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(swaps) == 0 {
+		return nil, nil
+	}
+	return &swaps[0], nil
+}
+
+// Swaps returns the shift swaps in status, or all of them when it is empty, oldest first
+func (r *RosterRepository) Swaps(ctx context.Context, status string) ([]models.RosterSwap, error) {
+	if status == "" {
+		return r.swaps(ctx, "TRUE")
+	}
+	return r.swaps(ctx, "w.status = ?", status)
+}
+
+// SwapsOf returns the shift swaps a staff member asked for or was asked to take, newest first
+func (r *RosterRepository) SwapsOf(ctx context.Context, userID int64) ([]models.RosterSwap, error) {
+	swaps, err := r.swaps(ctx, "w.from_user_id = ? OR w.to_user_id = ?", userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(swaps)-1; i < j; i, j = i+1, j-1 {
+		swaps[i], swaps[j] = swaps[j], swaps[i]
+	}
+	return swaps, nil
+}
+
+func (r *RosterRepository) swaps(ctx context.Context, where string, args ...any) ([]models.RosterSwap, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var swaps []models.RosterSwap
+	err := database.DB.WithContext(ctx).Table("shift_swap w").
+		Select("w.*, s.branch, s.starts_at, s.ends_at, fu.username AS from_username, tu.username AS to_username").
+		Joins("JOIN shift s ON s.id = w.shift_id").
+		Joins("JOIN users fu ON fu.id = w.from_user_id").
+		Joins("JOIN users tu ON tu.id = w.to_user_id").
+		Where(where, args...).
+		Order("w.created_at, w.id").
+		Scan(&swaps).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shift swaps: %w", err)
+	}
+	return swaps, nil
+}
+
+// ReviewSwap decides a pending swap. Approving it hands the shift over, unless it no longer
+// belongs to the staff member who asked or the one taking it has another shift at that time.
+func (r *RosterRepository) ReviewSwap(ctx context.Context, swap *models.ShiftSwap) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var shift models.Shift
+		if err := tx.First(&shift, swap.ShiftID).Error; err != nil {
+			return fmt.Errorf("failed to get shift: %w", err)
+		}
+		if swap.Status == models.RosterRequestApproved {
+			if shift.UserID != swap.FromUserID {
+				return fmt.Errorf("%w: the shift has changed hands since", ErrRequestNotPending)
+			}
+			if err := checkShiftFree(tx, swap.ToUserID, shift.StartsAt, shift.EndsAt, shift.ID); err != nil {
+				return err
+			}
+			shift.UserID = swap.ToUserID
+			if err := tx.Model(&shift).Select("user_id", "updated_at", "updated_by").Updates(&shift).Error; err != nil {
+				return fmt.Errorf("failed to hand the shift over: %w", err)
+			}
+		}
+		return reviewRequest(tx, &models.ShiftSwap{}, swap.ID, swap.Status, swap.ReviewNote, swap.ReviewedBy, swap.ReviewedAt)
+	})
+}
+
+func (r *RosterRepository) CreateLeave(ctx context.Context, leave *models.LeaveRequest) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("User").Create(leave).Error; err != nil {
+		return fmt.Errorf("failed to create leave request: %w", err)
+	}
+	return nil
+}
+
+// GetLeave returns a leave request, or nil when there is none
+func (r *RosterRepository) GetLeave(ctx context.Context, id uint) (*models.RosterLeave, error) {
+	leave, err := r.leave(ctx, "l.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(leave) == 0 {
+		return nil, nil
+	}
+	return &leave[0], nil
+}
+
+// Leave returns the leave requests in status, or all of them when it is empty, by start date
+func (r *RosterRepository) Leave(ctx context.Context, status string) ([]models.RosterLeave, error) {
+	if status == "" {
+		return r.leave(ctx, "TRUE")
+	}
+	return r.leave(ctx, "l.status = ?", status)
+}
+
+// LeaveOf returns a staff member's leave requests by start date
+func (r *RosterRepository) LeaveOf(ctx context.Context, userID int64) ([]models.RosterLeave, error) {
+	return r.leave(ctx, "l.user_id = ?", userID)
+}
+
+// ApprovedLeave returns the approved leave overlapping the days from through to, as YYYY-MM-DD
+func (r *RosterRepository) ApprovedLeave(ctx context.Context, from, to string) ([]models.RosterLeave, error) {
+	return r.leave(ctx, "l.status = ? AND l.start_date <= ? AND l.end_date >= ?", models.RosterRequestApproved, to, from)
+}
+
+func (r *RosterRepository) leave(ctx context.Context, where string, args ...any) ([]models.RosterLeave, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var leave []models.RosterLeave
+	err := database.DB.WithContext(ctx).Table("leave_request l").
+		Select("l.*, u.username").
+		Joins("JOIN users u ON u.id = l.user_id").
+		Where(where, args...).
+		Order("l.start_date, u.username, l.id").
+		Scan(&leave).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leave requests: %w", err)
+	}
+	return leave, nil
+}
+
+// ReviewLeave decides a pending leave request
+func (r *RosterRepository) ReviewLeave(ctx context.Context, leave *models.LeaveRequest) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return reviewRequest(database.DB.WithContext(ctx), &models.LeaveRequest{}, leave.ID, leave.Status, leave.ReviewNote, leave.ReviewedBy, leave.ReviewedAt)
+}
+
+// reviewRequest moves a pending swap or leave request to status, reporting ErrRequestNotPending when
+// it was decided in the meantime
+func reviewRequest(tx *gorm.DB, model any, id uint, status, note string, reviewedBy *int64, reviewedAt *time.Time) error {
+	result := tx.Model(model).Where("id = ? AND status = ?", id, models.RosterRequestPending).Updates(map[string]any{
+		"status":      status,
+		"review_note": note,
+		"reviewed_by": reviewedBy,
+		"reviewed_at": reviewedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to review request: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRequestNotPending
+	}
+	return nil
+}
+
+// Presence returns when the doctor is rostered between start and end, the bounds of a clinic day
+func (r *RosterRepository) Presence(ctx context.Context, doctorID string, start, end time.Time) (*models.Presence, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var doctor models.Doctor
+	if err := database.DB.WithContext(ctx).Select("id", "user_id").First(&doctor, "id = ?", doctorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &models.Presence{}, nil
+		}
+		return nil, fmt.Errorf("failed to get doctor: %w", err)
+	}
+	if doctor.UserID == nil {
+		return &models.Presence{}, nil
+	}
+
+	presence := &models.Presence{Rostered: true}
+	err := database.DB.WithContext(ctx).
+		Where("user_id = ? AND starts_at < ? AND ends_at > ?", *doctor.UserID, end, start).
+		Order("starts_at").
+		Find(&presence.Shifts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shifts: %w", err)
+	}
+
+	day := start.In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+	var leave int64
+	err = database.DB.WithContext(ctx).Model(&models.LeaveRequest{}).
+		Where("user_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", *doctor.UserID, models.RosterRequestApproved, day, day).
+		Count(&leave).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check leave: %w", err)
+	}
+	presence.OnLeave = leave > 0
+	return presence, nil
+}
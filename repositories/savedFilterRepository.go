@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// SavedFilterRepository stores the list views users save
+type SavedFilterRepository struct{}
+
+func NewSavedFilterRepository() *SavedFilterRepository {
+	return &SavedFilterRepository{}
+}
+
+func (r *SavedFilterRepository) Create(ctx context.Context, filter *models.SavedFilter) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(filter).Error; err != nil {
+		return fmt.Errorf("failed to create saved filter: %w", err)
+	}
+	return nil
+}
+
+// Get returns a filter saved by userID, or nil when they saved no such filter
+func (r *SavedFilterRepository) Get(ctx context.Context, userID int64, id uint) (*models.SavedFilter, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var filter models.SavedFilter
+	if err := database.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&filter).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get saved filter: %w", err)
+	}
+	return &filter, nil
+}
+
+// List returns the filters userID saved for list, or for every list when it is empty, by name
+func (r *SavedFilterRepository) List(ctx context.Context, userID int64, list string) ([]models.SavedFilter, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Where("user_id = ?", userID)
+	if list != "" {
+		query = query.Where("list = ?", list)
+	}
+	var filters []models.SavedFilter
+	if err := query.Order("list, name, id").Find(&filters).Error; err != nil {
+		return nil, fmt.Errorf("failed to list saved filters: %w", err)
+	}
+	return filters, nil
+}
+
+// NameTaken reports whether userID saved another filter than excludeID for list under name
+func (r *SavedFilterRepository) NameTaken(ctx context.Context, userID int64, list, name string, excludeID uint) (bool, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := database.DB.WithContext(ctx).Model(&models.SavedFilter{}).
+		Where("user_id = ? AND list = ? AND name = ? AND id <> ?", userID, list, name, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check saved filter names: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *SavedFilterRepository) Update(ctx context.Context, filter *models.SavedFilter) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(filter).Where("user_id = ?", filter.UserID).
+		Select("list", "name", "criteria", "sort", "updated_at").Updates(filter).Error
+	if err != nil {
+		return fmt.Errorf("failed to update saved filter: %w", err)
+	}
+	return nil
+}
+
+func (r *SavedFilterRepository) Delete(ctx context.Context, userID int64, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.SavedFilter{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete saved filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScheduleImpactRepository reads the appointments a doctor's absence would affect
+type ScheduleImpactRepository struct{}
+
+func NewScheduleImpactRepository() *ScheduleImpactRepository {
+	return &ScheduleImpactRepository{}
+}
+
+// Appointments returns the doctor's appointments that have not been cancelled or completed starting
+// from from until before to, earliest first
+func (r *ScheduleImpactRepository) Appointments(ctx context.Context, doctorID string, from, to time.Time) ([]models.AffectedAppointment, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var appointments []models.AffectedAppointment
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id AS appointment_id, a.patient_id, p.first_name || ' ' || p.last_name AS patient_name, p.phone AS patient_phone, p.email AS patient_email, a.date_time, a.type, a.status, COALESCE(pr.name, '') AS procedure_name, pr.price").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("LEFT JOIN procedure pr ON pr.id = a.procedure_id").
+		Where("a.doctor_id = ? AND a.starts_at >= ? AND a.starts_at < ? AND a.status NOT IN ?", doctorID, from, to,
+			[]string{models.AppointmentStatusCancelled, models.AppointmentStatusFulfilled}).
+		Order("a.starts_at, a.id").
+		Scan(&appointments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get doctor's appointments: %w", err)
+	}
+	for i := range appointments {
+		appointments[i].DateTime = models.ClinicDateTime(appointments[i].DateTime)
+	}
+	return appointments, nil
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// SchemaRepository compares the live database schema with the one this build migrates to
+type SchemaRepository struct{}
+
+func NewSchemaRepository() *SchemaRepository {
+	return &SchemaRepository{}
+}
+
+// Drift lists what the build expects and the database lacks: tables, columns and indexes of the
+// migrated models, the sequences of the IDs not numbered per year and the versioned migrations. Only
+// what is missing is reported, not what the database holds on top, which older builds may still use.
+func (r *SchemaRepository) Drift(ctx context.Context) (*models.SchemaDrift, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	drift := &models.SchemaDrift{CheckedAt: time.Now()}
+
+	var columns []struct{ TableName, ColumnName string }
+	if err := db.Raw("SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema()").Scan(&columns).Error; err != nil {
+		return nil, fmt.Errorf("failed to list columns: %w", err)
+	}
+	liveColumns := map[string]map[string]bool{}
+	for _, column := range columns {
+		if liveColumns[column.TableName] == nil {
+			liveColumns[column.TableName] = map[string]bool{}
+		}
+		liveColumns[column.TableName][column.ColumnName] = true
+	}
+
+	var indexes []struct{ Tablename, Indexname string }
+	if err := db.Raw("SELECT tablename, indexname FROM pg_indexes WHERE schemaname = current_schema()").Scan(&indexes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	liveIndexes := map[string]bool{}
+	for _, index := range indexes {
+		liveIndexes[index.Tablename+"."+index.Indexname] = true
+	}
+
+	var sequences []string
+	if err := db.Raw("SELECT relname FROM pg_class WHERE relkind = 'S' AND relnamespace = current_schema()::regnamespace").Scan(&sequences).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sequences: %w", err)
+	}
+	liveSequences := map[string]bool{}
+	for _, sequence := range sequences {
+		liveSequences[sequence] = true
+	}
+
+	seen := map[string]bool{}
+	cacheStore := &sync.Map{}
+	for _, model := range database.SchemaModels() {
+		parsed, err := schema.Parse(model, cacheStore, database.DB.NamingStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		table := parsed.Table
+		if seen[table] {
+			continue
+		}
+		seen[table] = true
+
+		live, ok := liveColumns[table]
+		if !ok {
+			drift.MissingTables = append(drift.MissingTables, table)
+			continue
+		}
+		for _, field := range parsed.Fields {
+			if field.DBName != "" && !field.IgnoreMigration && !live[field.DBName] {
+				drift.MissingColumns = append(drift.MissingColumns, table+"."+field.DBName)
+			}
+		}
+		for name := range parsed.ParseIndexes() {
+			if !liveIndexes[table+"."+name] {
+				drift.MissingIndexes = append(drift.MissingIndexes, table+"."+name)
+			}
+		}
+	}
+
+	for entity, sequence := range idSequences {
+		if !idFormat(entity).YearlyReset && !liveSequences[sequence] {
+			drift.MissingSequences = append(drift.MissingSequences, sequence)
+		}
+	}
+
+	applied := map[int]bool{}
+	if _, ok := liveColumns["schema_migrations"]; ok {
+		var versions []int
+		if err := db.Table("schema_migrations").Pluck("version", &versions).Error; err != nil {
+			return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+		}
+		for _, version := range versions {
+			applied[version] = true
+			if version > drift.MigrationVersion {
+				drift.MigrationVersion = version
+			}
+		}
+	}
+	known := map[int]bool{}
+	for _, migration := range database.Migrations() {
+		known[migration.Version] = true
+		if migration.Version > drift.ExpectedVersion {
+			drift.ExpectedVersion = migration.Version
+		}
+		if !applied[migration.Version] {
+			drift.PendingMigrations = append(drift.PendingMigrations, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			drift.UnknownMigrations = append(drift.UnknownMigrations, version)
+		}
+	}
+
+	sort.Strings(drift.MissingColumns)
+	sort.Strings(drift.MissingIndexes)
+	sort.Strings(drift.MissingSequences)
+	sort.Ints(drift.UnknownMigrations)
+	return drift, nil
+}
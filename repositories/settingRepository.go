@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingRepository struct{}
+
+func NewSettingRepository() *SettingRepository {
+	return &SettingRepository{}
+}
+
+// Get returns the setting stored under key, or nil when it was never set
+func (r *SettingRepository) Get(ctx context.Context, key string) (*models.Setting, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var setting models.Setting
+	if err := database.DB.WithContext(ctx).First(&setting, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get setting: %w", err)
+	}
+	return &setting, nil
+}
+
+// Set stores value under key, replacing any previous value
+func (r *SettingRepository) Set(ctx context.Context, key, value string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	setting := models.Setting{Key: key, Value: value}
+	err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at", "updated_by"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return fmt.Errorf("failed to save setting: %w", err)
+	}
+	return nil
+}
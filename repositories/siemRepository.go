@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SIEMRepository reads the audit entries to forward to the SIEM collector and keeps how far they
+// were forwarded
+type SIEMRepository struct{}
+
+func NewSIEMRepository() *SIEMRepository {
+	return &SIEMRepository{}
+}
+
+// Cursor returns how far the audit trail was forwarded to endpoint, or nil before it ever was
+func (r *SIEMRepository) Cursor(ctx context.Context, endpoint string) (*models.SIEMCursor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var cursor models.SIEMCursor
+	if err := database.DB.WithContext(ctx).Where("endpoint = ?", endpoint).First(&cursor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get SIEM cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+func (r *SIEMRepository) SaveCursor(ctx context.Context, cursor *models.SIEMCursor) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Save(cursor).Error; err != nil {
+		return fmt.Errorf("failed to save SIEM cursor: %w", err)
+	}
+	return nil
+}
+
+// LastAuditID returns the ID of the latest audit entry, 0 when there is none
+func (r *SIEMRepository) LastAuditID(ctx context.Context) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	if err := database.DB.WithContext(ctx).Model(&models.AuditLog{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error; err != nil {
+		return 0, fmt.Errorf("failed to get last audit log: %w", err)
+	}
+	return id, nil
+}
+
+// Unforwarded returns up to limit audit entries after afterID written before before, in ID order
+func (r *SIEMRepository) Unforwarded(ctx context.Context, afterID int64, before time.Time, limit int) ([]models.AuditLog, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var entries []models.AuditLog
+	err := database.DB.WithContext(ctx).Where("id > ? AND created_at < ?", afterID, before).
+		Order("id").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unforwarded audit logs: %w", err)
+	}
+	return entries, nil
+}
+
+// CountUnforwarded counts the audit entries after afterID
+func (r *SIEMRepository) CountUnforwarded(ctx context.Context, afterID int64) (int64, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.AuditLog{}).Where("id > ?", afterID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count unforwarded audit logs: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SMSReplyRepository keeps the text messages patients send the clinic and finds the appointment
+// reminder each answers
+type SMSReplyRepository struct{}
+
+func NewSMSReplyRepository() *SMSReplyRepository {
+	return &SMSReplyRepository{}
+}
+
+// Create records a reply, reporting false when the gateway already forwarded a message with its ID
+func (r *SMSReplyRepository) Create(ctx context.Context, reply *models.SMSReply) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "message_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "message_id <> ''"}}},
+		DoNothing:   true,
+	}).Create(reply)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record SMS reply: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetByMessageID returns the reply with the gateway's message ID, or nil when there is none
+func (r *SMSReplyRepository) GetByMessageID(ctx context.Context, messageID string) (*models.SMSReply, error) {
+	return r.get(ctx, "message_id = ?", messageID)
+}
+
+// GetByID returns a reply, or nil when there is none
+func (r *SMSReplyRepository) GetByID(ctx context.Context, id uint) (*models.SMSReply, error) {
+	return r.get(ctx, "id = ?", id)
+}
+
+func (r *SMSReplyRepository) get(ctx context.Context, query string, arg interface{}) (*models.SMSReply, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var reply models.SMSReply
+	if err := database.DB.WithContext(ctx).Where(query, arg).First(&reply).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get SMS reply: %w", err)
+	}
+	return &reply, nil
+}
+
+// LastReminder returns the last appointment reminder texted since since to the number whose
+// trailing digits are digits, or nil when there is none
+func (r *SMSReplyRepository) LastReminder(ctx context.Context, digits string, since time.Time) (*models.SMSReminder, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var reminders []models.SMSReminder
+	err := database.DB.WithContext(ctx).Table("communication_log AS c").
+		Select("c.patient_id, a.id AS appointment_id, a.status, a.starts_at").
+		Joins("JOIN appointment a ON a.patient_id = c.patient_id AND a.id::text = c.record_id").
+		Where("c.channel = ? AND c.record = ? AND c.status = ? AND c.sent_at >= ?", "sms", "appointment", models.MessageSent, since).
+		Where("RIGHT(REGEXP_REPLACE(c.recipient, '[^0-9]', '', 'g'), ?) = ?", len(digits), digits).
+		Order("c.sent_at DESC").Limit(1).
+		Scan(&reminders).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find appointment reminder: %w", err)
+	}
+	if len(reminders) == 0 {
+		return nil, nil
+	}
+	return &reminders[0], nil
+}
+
+// SetOutcome records what became of a reply: the appointment it answers, if any, and its status
+func (r *SMSReplyRepository) SetOutcome(ctx context.Context, reply *models.SMSReply) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(reply).Select("status", "reason", "patient_id", "appointment_id").Updates(reply).Error
+	if err != nil {
+		return fmt.Errorf("failed to record SMS reply outcome: %w", err)
+	}
+	return nil
+}
+
+// List returns the replies with status, or every reply when status is empty, most recent first
+func (r *SMSReplyRepository) List(ctx context.Context, status string, limit int) ([]models.SMSReply, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Order("received_at DESC, id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var replies []models.SMSReply
+	if err := query.Find(&replies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SMS replies: %w", err)
+	}
+	return replies, nil
+}
+
+// MarkHandled records that staff dealt with an open reply, reporting false when it is not open
+func (r *SMSReplyRepository) MarkHandled(ctx context.Context, id uint, userID int64, note string) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Model(&models.SMSReply{}).Where("id = ? AND status = ?", id, models.SMSReplyOpen).
+		Updates(map[string]interface{}{"status": models.SMSReplyHandled, "handled_at": time.Now(), "handled_by": userID, "note": note})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark SMS reply handled: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
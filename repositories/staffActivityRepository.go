@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// staffActivityTables are the record types whose created_by and updated_by count towards staff activity
+var staffActivityTables = []string{
+	"appointment",
+	"billing",
+	"examination",
+	"examination_attachment",
+	"insurance_claim",
+	"payment_plan",
+	"sterilization_batch",
+	"treatment_plan",
+	"vitals",
+}
+
+// StaffMember is the account behind a user ID in the staff activity report
+type StaffMember struct {
+	UserID   int64
+	Username string
+	Role     string
+}
+
+// StaffActivityRepository counts who created and edited records from their actor columns
+type StaffActivityRepository struct{}
+
+func NewStaffActivityRepository() *StaffActivityRepository {
+	return &StaffActivityRepository{}
+}
+
+// GetCounts returns, per user and record type, the records created in [from, to) and the records
+// last edited in [from, to). Only the latest editor of a record is known, so earlier edits are not counted.
+func (r *StaffActivityRepository) GetCounts(ctx context.Context, from, to time.Time) ([]models.StaffActivityCount, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var counts []models.StaffActivityCount
+	for _, table := range staffActivityTables {
+		var created []models.StaffActivityCount
+		err := database.DB.WithContext(ctx).Table(table).
+			Select("created_by AS user_id, CAST(? AS text) AS record, false AS edited, COUNT(*) AS count", table).
+			Where("created_by IS NOT NULL AND created_at >= ? AND created_at < ?", from, to).
+			Group("created_by").
+			Scan(&created).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count created %s records: %w", table, err)
+		}
+
+		// updated_by is also stamped on creation, so only rows changed afterwards are edits
+		var edited []models.StaffActivityCount
+		err = database.DB.WithContext(ctx).Table(table).
+			Select("updated_by AS user_id, CAST(? AS text) AS record, true AS edited, COUNT(*) AS count", table).
+			Where("updated_by IS NOT NULL AND updated_at >= ? AND updated_at < ? AND updated_at > created_at + INTERVAL '1 second'", from, to).
+			Group("updated_by").
+			Scan(&edited).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count edited %s records: %w", table, err)
+		}
+		counts = append(append(counts, created...), edited...)
+	}
+	return counts, nil
+}
+
+// GetStaffMembers returns the username and role of each of userIDs
+func (r *StaffActivityRepository) GetStaffMembers(ctx context.Context, userIDs []int64) ([]StaffMember, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var members []StaffMember
+	if len(userIDs) == 0 {
+		return members, nil
+	}
+	err := database.DB.WithContext(ctx).Table("users u").
+		Select("u.id AS user_id, u.username, r.name AS role").
+		Joins("LEFT JOIN roles r ON r.id = u.role_id").
+		Where("u.id IN ?", userIDs).
+		Scan(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staff members: %w", err)
+	}
+	return members, nil
+}
+
+// GetBookings counts the appointments each user booked in [from, to); walk-ins are not bookings
+func (r *StaffActivityRepository) GetBookings(ctx context.Context, from, to time.Time) ([]models.StaffBookings, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var bookings []models.StaffBookings
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.created_by AS user_id, u.username, r.name AS role, COUNT(*) AS appointments").
+		Joins("JOIN users u ON u.id = a.created_by").
+		Joins("LEFT JOIN roles r ON r.id = u.role_id").
+		Where("a.origin = ? AND a.created_at >= ? AND a.created_at < ?", models.AppointmentOriginBooked, from, to).
+		Group("a.created_by, u.username, r.name").
+		Order("appointments DESC, u.username").
+		Scan(&bookings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count booked appointments: %w", err)
+	}
+	return bookings, nil
+}
+
+// GetExaminations counts the examinations each doctor recorded in [from, to), through the doctor's user account
+func (r *StaffActivityRepository) GetExaminations(ctx context.Context, from, to time.Time) ([]models.DoctorExaminations, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var examinations []models.DoctorExaminations
+	err := database.DB.WithContext(ctx).Table("examination e").
+		Select("d.id AS doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, COUNT(*) AS examinations").
+		Joins("JOIN doctor d ON d.user_id = e.created_by").
+		Where("e.created_at >= ? AND e.created_at < ?", from, to).
+		Group("d.id, d.first_name, d.last_name").
+		Order("examinations DESC, doctor_name").
+		Scan(&examinations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count examinations: %w", err)
+	}
+	return examinations, nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SterilizationRepository stores the sterilization log and which bills each batch was used for
+type SterilizationRepository struct{}
+
+func NewSterilizationRepository() *SterilizationRepository {
+	return &SterilizationRepository{}
+}
+
+func (r *SterilizationRepository) CreateBatch(ctx context.Context, batch *models.SterilizationBatch) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(batch).Error; err != nil {
+		return fmt.Errorf("failed to create sterilization batch: %w", err)
+	}
+	return nil
+}
+
+func (r *SterilizationRepository) GetBatch(ctx context.Context, id string) (*models.SterilizationBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batch models.SterilizationBatch
+	if err := database.DB.WithContext(ctx).First(&batch, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get sterilization batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// ListBatches returns the batches sterilized between from and to (exclusive), newest first
+func (r *SterilizationRepository) ListBatches(ctx context.Context, from, to time.Time) ([]models.SterilizationBatch, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var batches []models.SterilizationBatch
+	err := database.DB.WithContext(ctx).
+		Where("sterilized_at >= ? AND sterilized_at < ?", from, to).
+		Order("sterilized_at DESC").
+		Find(&batches).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sterilization batches: %w", err)
+	}
+	return batches, nil
+}
+
+// UpdateResult records the outcome of the batch's cycle once its indicators have been read
+func (r *SterilizationRepository) UpdateResult(ctx context.Context, batch *models.SterilizationBatch) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Model(batch).Select("result", "notes", "updated_at").Updates(batch).Error; err != nil {
+		return fmt.Errorf("failed to update sterilization batch: %w", err)
+	}
+	return nil
+}
+
+// LinkBatches records that the batches were used for the bill; links that already exist are kept
+func (r *SterilizationRepository) LinkBatches(ctx context.Context, usages []models.BatchUsage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Omit("Batch").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "batch_id"}, {Name: "billing_id"}},
+		DoNothing: true,
+	}).Create(&usages).Error
+	if err != nil {
+		return fmt.Errorf("failed to link sterilization batches: %w", err)
+	}
+	return nil
+}
+
+func (r *SterilizationRepository) UnlinkBatch(ctx context.Context, billingID, batchID string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.BatchUsage{}, "billing_id = ? AND batch_id = ?", billingID, batchID).Error; err != nil {
+		return fmt.Errorf("failed to unlink sterilization batch: %w", err)
+	}
+	return nil
+}
+
+// GetUsagesByBilling returns the batches used for the bill
+func (r *SterilizationRepository) GetUsagesByBilling(ctx context.Context, billingID string) ([]models.BatchUsage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var usages []models.BatchUsage
+	if err := database.DB.WithContext(ctx).Where("billing_id = ?", billingID).Order("batch_id").Find(&usages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sterilization batches of billing: %w", err)
+	}
+	return usages, nil
+}
+
+// Trace returns the patients treated with instruments from the batch, in treatment order
+func (r *SterilizationRepository) Trace(ctx context.Context, batchID string) ([]models.BatchTrace, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var traces []models.BatchTrace
+	err := database.DB.WithContext(ctx).Table("batch_usage u").
+		Select("u.billing_id, u.patient_id, COALESCE(p.first_name || ' ' || p.last_name, '') AS patient_name, COALESCE(p.phone, '') AS phone, u.doctor_id, u.procedure, u.treated_at").
+		Joins("LEFT JOIN patient p ON p.id = u.patient_id").
+		Where("u.batch_id = ?", batchID).
+		Order("u.treated_at, u.billing_id").
+		Scan(&traces).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to trace sterilization batch: %w", err)
+	}
+	return traces, nil
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// streamBatchSize is how many rows a streamed list holds in memory at a time.
+const streamBatchSize = 500
+
+// streamNewestFirst reads the rows of query newest first, streamBatchSize at a time, and hands each
+// to emit. Each batch continues after the last row seen on (created_at, keyColumn) rather than at an
+// offset, so late batches cost no more than early ones and rows written meanwhile are not repeated.
+func streamNewestFirst[T any](ctx context.Context, keyColumn string, query func(db *gorm.DB) *gorm.DB, key func(row *T) (time.Time, interface{}), emit func(T) error) error {
+	var lastCreatedAt time.Time
+	var lastKey interface{}
+	for {
+		batch, err := listNewestFirst[T](ctx, keyColumn, query, lastCreatedAt, lastKey, streamBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		lastCreatedAt, lastKey = key(&batch[len(batch)-1])
+	}
+}
+
+// listNewestFirst returns up to limit rows of query newest first, starting after the row at
+// (lastCreatedAt, lastKey) when lastKey is set
+func listNewestFirst[T any](ctx context.Context, keyColumn string, query func(db *gorm.DB) *gorm.DB, lastCreatedAt time.Time, lastKey interface{}, limit int) ([]T, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := query(database.DB.WithContext(ctx))
+	if lastKey != nil {
+		db = db.Where(fmt.Sprintf("(created_at, %s) < (?, ?)", keyColumn), lastCreatedAt, lastKey)
+	}
+	var rows []T
+	if err := db.Order("created_at DESC, " + keyColumn + " DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return rows, nil
+}
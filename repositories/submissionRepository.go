@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubmissionRepository keeps the recent create submissions and their responses in Redis
+type SubmissionRepository struct {
+	cache *cache.Cache
+}
+
+func NewSubmissionRepository(cache *cache.Cache) *SubmissionRepository {
+	return &SubmissionRepository{cache: cache}
+}
+
+// Claim records the submission with the given fingerprint for window, reporting false when it was
+// already recorded
+func (r *SubmissionRepository) Claim(ctx context.Context, fingerprint string, window time.Duration) (bool, error) {
+	claimed, err := r.cache.SetNX(ctx, r.cache.Key(ctx, "submission", fingerprint), time.Now().Unix(), window)
+	if err != nil {
+		return false, fmt.Errorf("failed to record submission: %w", err)
+	}
+	return claimed, nil
+}
+
+// Response returns the response saved for a submission, or nil while it is still being handled
+func (r *SubmissionRepository) Response(ctx context.Context, fingerprint string) (*models.SubmissionResponse, error) {
+	var response models.SubmissionResponse
+	found, err := r.cache.GetObject(ctx, r.cache.Key(ctx, "submission_response", fingerprint), &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submission response: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &response, nil
+}
+
+// SaveResponse keeps the response to a submission for window
+func (r *SubmissionRepository) SaveResponse(ctx context.Context, fingerprint string, response models.SubmissionResponse, window time.Duration) error {
+	if err := r.cache.SetObject(ctx, r.cache.Key(ctx, "submission_response", fingerprint), response, window); err != nil {
+		return fmt.Errorf("failed to save submission response: %w", err)
+	}
+	return nil
+}
+
+// Release forgets a submission that failed, so it can be submitted again straight away
+func (r *SubmissionRepository) Release(ctx context.Context, fingerprint string) error {
+	if err := r.cache.Delete(ctx, r.cache.Key(ctx, "submission", fingerprint)); err != nil {
+		return fmt.Errorf("failed to release submission: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSurveyNotFound        = errors.New("survey not found")
+	ErrSurveyAlreadyAnswered = errors.New("survey has already been answered")
+)
+
+// SurveyRepository stores satisfaction surveys. Answers are written once and never cached.
+type SurveyRepository struct{}
+
+func NewSurveyRepository() *SurveyRepository {
+	return &SurveyRepository{}
+}
+
+// PendingInvitations returns fulfilled appointments on or after since whose patients have an
+// email address, accept email and have not been sent a survey for them.
+func (r *SurveyRepository) PendingInvitations(ctx context.Context, since time.Time, limit int) ([]models.SurveyInvitation, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var invitations []models.SurveyInvitation
+	err := database.DB.WithContext(ctx).Table("appointment a").
+		Select("a.id AS appointment_id, a.patient_id, p.first_name AS patient_first_name, p.email AS patient_email, a.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time").
+		Joins("JOIN patient p ON p.id = a.patient_id").
+		Joins("JOIN doctor d ON d.id = a.doctor_id").
+		Joins("LEFT JOIN survey s ON s.appointment_id = a.id").
+		Joins("LEFT JOIN communication_preference cp ON cp.patient_id = a.patient_id").
+		Where("a.status = ? AND a.starts_at >= ? AND s.id IS NULL AND COALESCE(p.email, '') <> '' AND COALESCE(cp.email, TRUE)", models.AppointmentStatusFulfilled, since).
+		Order("a.starts_at").
+		Limit(limit).
+		Scan(&invitations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending survey invitations: %w", err)
+	}
+	for i := range invitations {
+		invitations[i].DateTime = models.ClinicDateTime(invitations[i].DateTime)
+	}
+	return invitations, nil
+}
+
+// Create records a sent survey and reports false when another replica already recorded one for the appointment
+func (r *SurveyRepository) Create(ctx context.Context, survey *models.Survey) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "appointment_id"}},
+		DoNothing: true,
+	}).Create(survey)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to create survey: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Delete removes a survey whose invitation could not be delivered so it is retried
+func (r *SurveyRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Survey{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete survey: %w", err)
+	}
+	return nil
+}
+
+func (r *SurveyRepository) GetView(ctx context.Context, appointmentID uint) (*models.SurveyView, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var views []models.SurveyView
+	err := database.DB.WithContext(ctx).Table("survey s").
+		Select("s.appointment_id, d.first_name || ' ' || d.last_name AS doctor_name, a.date_time, s.responded_at IS NOT NULL AS completed").
+		Joins("JOIN appointment a ON a.id = s.appointment_id").
+		Joins("JOIN doctor d ON d.id = s.doctor_id").
+		Where("s.appointment_id = ?", appointmentID).
+		Limit(1).
+		Scan(&views).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get survey: %w", err)
+	}
+	if len(views) == 0 {
+		return nil, ErrSurveyNotFound
+	}
+	views[0].DateTime = models.ClinicDateTime(views[0].DateTime)
+	return &views[0], nil
+}
+
+// Respond stores the answers of a survey that has not been answered yet
+func (r *SurveyRepository) Respond(ctx context.Context, appointmentID uint, rating, nps int, comment string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("survey_lock:%d", appointmentID), func(tx *gorm.DB) error {
+		var survey models.Survey
+		if err := tx.Select("id, responded_at").First(&survey, "appointment_id = ?", appointmentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSurveyNotFound
+			}
+			return fmt.Errorf("failed to get survey: %w", err)
+		}
+		if survey.RespondedAt != nil {
+			return ErrSurveyAlreadyAnswered
+		}
+
+		err := tx.Model(&survey).Updates(map[string]interface{}{
+			"rating":       rating,
+			"nps":          nps,
+			"comment":      comment,
+			"responded_at": time.Now(),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to save survey answers: %w", err)
+		}
+		return nil
+	})
+}
+
+// DoctorReports aggregates surveys sent within [from, to) per doctor. NPS is the share of
+// promoters (9-10) minus the share of detractors (0-6), in percent.
+func (r *SurveyRepository) DoctorReports(ctx context.Context, from, to *time.Time) ([]models.DoctorSurveyReport, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Table("survey s").
+		Select(`s.doctor_id, d.first_name || ' ' || d.last_name AS doctor_name,
+			COUNT(*) AS sent,
+			COUNT(s.responded_at) AS responses,
+			COALESCE(AVG(s.rating), 0) AS average_rating,
+			COUNT(*) FILTER (WHERE s.nps >= 9) AS promoters,
+			COUNT(*) FILTER (WHERE s.nps BETWEEN 7 AND 8) AS passives,
+			COUNT(*) FILTER (WHERE s.nps <= 6) AS detractors,
+			COALESCE(100.0 * (COUNT(*) FILTER (WHERE s.nps >= 9) - COUNT(*) FILTER (WHERE s.nps <= 6)) / NULLIF(COUNT(s.nps), 0), 0) AS nps`).
+		Joins("JOIN doctor d ON d.id = s.doctor_id").
+		Group("s.doctor_id, d.first_name, d.last_name").
+		Order("doctor_name")
+	if from != nil {
+		query = query.Where("s.sent_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("s.sent_at < ?", *to)
+	}
+
+	var reports []models.DoctorSurveyReport
+	if err := query.Scan(&reports).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate surveys: %w", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TaskRepository stores staff tasks and internal patient notes. Both change too often to cache.
+type TaskRepository struct{}
+
+func NewTaskRepository() *TaskRepository {
+	return &TaskRepository{}
+}
+
+func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Assignee", "Patient").Create(task).Error; err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) GetByID(ctx context.Context, id uint) (*models.Task, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var task models.Task
+	if err := database.DB.WithContext(ctx).First(&task, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return &task, nil
+}
+
+// List returns tasks matching filter, the ones due soonest first
+func (r *TaskRepository) List(ctx context.Context, filter models.TaskFilter) ([]models.Task, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx).Model(&models.Task{})
+	if filter.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeID)
+	}
+	if filter.PatientID != "" {
+		query = query.Where("patient_id = ?", filter.PatientID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var tasks []models.Task
+	if err := query.Order("due_date ASC NULLS LAST, created_at DESC").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *TaskRepository) Update(ctx context.Context, task *models.Task) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("task_lock:%d", task.ID), func(tx *gorm.DB) error {
+		result := tx.Model(task).Select("title", "description", "assignee_id", "patient_id", "due_date", "status", "updated_at").Updates(task)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update task: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("task not found")
+		}
+		return nil
+	})
+}
+
+func (r *TaskRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Task{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	return nil
+}
+
+// GetUserEmail returns the email of a user, or an empty string when the user does not exist
+func (r *TaskRepository) GetUserEmail(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var emails []string
+	if err := database.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Pluck("email", &emails).Error; err != nil {
+		return "", fmt.Errorf("failed to get user email: %w", err)
+	}
+	if len(emails) == 0 {
+		return "", nil
+	}
+	return emails[0], nil
+}
+
+func (r *TaskRepository) CreateNote(ctx context.Context, note *models.PatientNote) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Patient").Create(note).Error; err != nil {
+		return fmt.Errorf("failed to create patient note: %w", err)
+	}
+	return nil
+}
+
+// GetNotes returns the internal notes about a patient, newest first
+func (r *TaskRepository) GetNotes(ctx context.Context, patientID string) ([]models.PatientNote, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var notes []models.PatientNote
+	err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("created_at DESC").Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get patient notes: %w", err)
+	}
+	return notes, nil
+}
+
+// DeleteNote removes a note written by authorID and reports whether one was removed
+func (r *TaskRepository) DeleteNote(ctx context.Context, patientID string, id uint, authorID int64) (bool, error) {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	result := database.DB.WithContext(ctx).Delete(&models.PatientNote{}, "patient_id = ? AND id = ? AND author_id = ?", patientID, id, authorID)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete patient note: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
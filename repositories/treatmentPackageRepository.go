@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TreatmentPackageRepository stores the treatment packages and their items
+type TreatmentPackageRepository struct{}
+
+func NewTreatmentPackageRepository() *TreatmentPackageRepository {
+	return &TreatmentPackageRepository{}
+}
+
+// Create inserts the package with its items
+func (r *TreatmentPackageRepository) Create(ctx context.Context, pkg *models.TreatmentPackage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(pkg).Error; err != nil {
+			return err
+		}
+		return createTreatmentPackageItems(tx, pkg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create treatment package: %w", err)
+	}
+	return nil
+}
+
+// Get returns a package with its items, or nil when there is none
+func (r *TreatmentPackageRepository) Get(ctx context.Context, id uint) (*models.TreatmentPackage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var pkg models.TreatmentPackage
+	if err := database.DB.WithContext(ctx).Preload("Items", orderTreatmentPackageItems).First(&pkg, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get treatment package: %w", err)
+	}
+	return &pkg, nil
+}
+
+// List returns the packages with their items by name
+func (r *TreatmentPackageRepository) List(ctx context.Context) ([]models.TreatmentPackage, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var packages []models.TreatmentPackage
+	if err := database.DB.WithContext(ctx).Preload("Items", orderTreatmentPackageItems).Order("name, id").Find(&packages).Error; err != nil {
+		return nil, fmt.Errorf("failed to list treatment packages: %w", err)
+	}
+	return packages, nil
+}
+
+// Update changes the package and replaces its items
+func (r *TreatmentPackageRepository) Update(ctx context.Context, pkg *models.TreatmentPackage) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(pkg).
+			Select("name", "description", "price", "installment_count", "interval_months", "updated_at", "updated_by").
+			Updates(pkg).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("package_id = ?", pkg.ID).Delete(&models.TreatmentPackageItem{}).Error; err != nil {
+			return err
+		}
+		return createTreatmentPackageItems(tx, pkg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update treatment package: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a package; the plans it was applied to are kept
+func (r *TreatmentPackageRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.TreatmentPackage{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete treatment package: %w", err)
+	}
+	return nil
+}
+
+func createTreatmentPackageItems(tx *gorm.DB, pkg *models.TreatmentPackage) error {
+	if len(pkg.Items) == 0 {
+		return nil
+	}
+	for i := range pkg.Items {
+		pkg.Items[i].ID = 0
+		pkg.Items[i].PackageID = pkg.ID
+	}
+	return tx.Omit("Procedure").Create(&pkg.Items).Error
+}
+
+func orderTreatmentPackageItems(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
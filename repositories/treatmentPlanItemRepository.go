@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TreatmentPlanItemRepository stores the items of treatment plans and finds the accepted ones no
+// appointment is booked for
+type TreatmentPlanItemRepository struct{}
+
+func NewTreatmentPlanItemRepository() *TreatmentPlanItemRepository {
+	return &TreatmentPlanItemRepository{}
+}
+
+func (r *TreatmentPlanItemRepository) Create(ctx context.Context, item *models.TreatmentPlanItem) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit(clause.Associations).Create(item).Error; err != nil {
+		return fmt.Errorf("failed to create treatment plan item: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns an item of the patient's treatment plan, or nil when there is none
+func (r *TreatmentPlanItemRepository) GetByID(ctx context.Context, patientID string, treatmentPlanID, id uint) (*models.TreatmentPlanItem, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var item models.TreatmentPlanItem
+	err := database.DB.WithContext(ctx).
+		First(&item, "patient_id = ? AND treatment_plan_id = ? AND id = ?", patientID, treatmentPlanID, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get treatment plan item: %w", err)
+	}
+	return &item, nil
+}
+
+// List returns the items of the patient's treatment plan, oldest first
+func (r *TreatmentPlanItemRepository) List(ctx context.Context, patientID string, treatmentPlanID uint) ([]models.TreatmentPlanItem, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var items []models.TreatmentPlanItem
+	err := database.DB.WithContext(ctx).Where("patient_id = ? AND treatment_plan_id = ?", patientID, treatmentPlanID).
+		Order("created_at, id").Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list treatment plan items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *TreatmentPlanItemRepository) Update(ctx context.Context, item *models.TreatmentPlanItem) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(item).
+		Select("procedure_id", "description", "tooth", "quantity", "amount", "status", "accepted_at", "completed_at", "updated_at", "updated_by").
+		Updates(item).Error
+	if err != nil {
+		return fmt.Errorf("failed to update treatment plan item: %w", err)
+	}
+	return nil
+}
+
+func (r *TreatmentPlanItemRepository) Delete(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.TreatmentPlanItem{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete treatment plan item: %w", err)
+	}
+	return nil
+}
+
+// Unscheduled returns the patients with items accepted by acceptedBefore and no appointment
+// scheduled after now, the most valuable accepted work first, only those of patientIDs when given
+func (r *TreatmentPlanItemRepository) Unscheduled(ctx context.Context, now, acceptedBefore time.Time, patientIDs []string, limit int) ([]models.UnscheduledPatient, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := r.unscheduled(database.DB.WithContext(ctx), now, acceptedBefore)
+	if patientIDs != nil {
+		query = query.Where("i.patient_id IN ?", patientIDs)
+	}
+	var patients []models.UnscheduledPatient
+	err := query.
+		Select("i.patient_id, CONCAT_WS(' ', p.first_name, NULLIF(p.middle_name, ''), p.last_name) AS patient_name, p.phone, "+
+			"SUM(i.amount) AS value, MIN(i.accepted_at) AS accepted_since, "+
+			"(SELECT MAX(a.starts_at) FROM appointment a WHERE a.patient_id = i.patient_id AND a.status = ?) AS last_visit, "+
+			"(SELECT MIN(t.id) FROM task t WHERE t.patient_id = i.patient_id AND t.title = ? AND t.status IN ?) AS open_task_id",
+			models.AppointmentStatusFulfilled, models.UnscheduledTaskTitle, []string{models.TaskStatusOpen, models.TaskStatusInProgress}).
+		Joins("JOIN patient p ON p.id = i.patient_id").
+		Group("i.patient_id, p.first_name, p.middle_name, p.last_name, p.phone").
+		Order("value DESC, accepted_since, i.patient_id").
+		Limit(limit).
+		Scan(&patients).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unscheduled treatment: %w", err)
+	}
+	return patients, nil
+}
+
+// UnscheduledItems returns the items of the patients that Unscheduled found, longest waiting first
+func (r *TreatmentPlanItemRepository) UnscheduledItems(ctx context.Context, now, acceptedBefore time.Time, patientIDs []string) ([]models.UnscheduledItem, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var items []models.UnscheduledItem
+	err := r.unscheduled(database.DB.WithContext(ctx), now, acceptedBefore).
+		Select("i.id, i.patient_id, i.treatment_plan_id, i.description, i.tooth, i.amount, i.accepted_at").
+		Where("i.patient_id IN ?", patientIDs).
+		Order("i.accepted_at, i.id").
+		Scan(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unscheduled treatment items: %w", err)
+	}
+	return items, nil
+}
+
+// UnscheduledTotals counts the patients and items of all unscheduled treatment, and its value
+func (r *TreatmentPlanItemRepository) UnscheduledTotals(ctx context.Context, now, acceptedBefore time.Time) (*models.UnscheduledTreatmentTotals, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var totals models.UnscheduledTreatmentTotals
+	err := r.unscheduled(database.DB.WithContext(ctx), now, acceptedBefore).
+		Select("COUNT(DISTINCT i.patient_id) AS patients, COUNT(*) AS items, COALESCE(SUM(i.amount), 0) AS total_value").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to total unscheduled treatment: %w", err)
+	}
+	return &totals, nil
+}
+
+// unscheduled narrows db down to the items accepted by acceptedBefore of patients with no
+// appointment scheduled after now
+func (r *TreatmentPlanItemRepository) unscheduled(db *gorm.DB, now, acceptedBefore time.Time) *gorm.DB {
+	return db.Table("treatment_plan_item i").
+		Where("i.status = ? AND i.accepted_at <= ?", models.TreatmentItemAccepted, acceptedBefore).
+		Where("NOT EXISTS (SELECT 1 FROM appointment a WHERE a.patient_id = i.patient_id AND a.status = ? AND a.starts_at > ?)",
+			models.AppointmentStatusScheduled, now)
+}
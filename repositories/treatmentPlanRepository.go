@@ -3,22 +3,20 @@ package repositories
 import (
 	"RoyDental/cache"
 	"RoyDental/database"
+	"RoyDental/events"
 	"RoyDental/models"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-const (
-	TreatmentPlanCacheExpiry = 7 * 24 * time.Hour
-)
+// ErrTreatmentPlanNotFound is returned when revising a treatment plan that does not exist
+var ErrTreatmentPlanNotFound = errors.New("treatment plan not found")
 
 type TreatmentPlanRepository struct {
 	cache *cache.Cache
@@ -29,66 +27,46 @@ func NewTreatmentPlanRepository(cache *cache.Cache) *TreatmentPlanRepository {
 }
 
 func (r *TreatmentPlanRepository) Create(ctx context.Context, plan *models.TreatmentPlan) error {
-	lockKey := fmt.Sprintf("treatment_plan_lock:%s", plan.PatientID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("treatment_plan_lock:%s", plan.PatientID), func(tx *gorm.DB) error {
+		plan.Version = 1
+		err := tx.Create(plan).Error
+		if err != nil {
+			return fmt.Errorf("failed to create treatment plan: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		if err := createTreatmentPlanVersion(tx, plan); err != nil {
+			return err
 		}
-	}()
-
-	err = database.DB.Create(plan).Error
-	if err != nil {
-		return fmt.Errorf("failed to create treatment plan: %w", err)
-	}
-	// Delete cache for the newly created treatment plan and all treatment plans
-	if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(plan.PatientID, plan.ID)); err != nil {
-		return fmt.Errorf("failed to delete treatment plan cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "treatment_plans_cache"); err != nil {
-		return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all treatment plans cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(plan.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+		// Delete cache for the newly created treatment plan and all treatment plans
+		if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(ctx, plan.PatientID, plan.ID)); err != nil {
+			return fmt.Errorf("failed to delete treatment plan cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "treatment_plans")); err != nil {
+			return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all treatment plans cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, plan.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
 func (r *TreatmentPlanRepository) GetByID(ctx context.Context, patientID string, id uint) (*models.TreatmentPlan, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := r.getTreatmentPlanCacheKey(patientID, id)
-	cachedPlan, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
-		var plan models.TreatmentPlan
-		if err := json.Unmarshal([]byte(cachedPlan), &plan); err == nil {
-			return &plan, nil
-		}
-	} else if err != redis.Nil {
+	cacheKey := r.getTreatmentPlanCacheKey(ctx, patientID, id)
+	var plan models.TreatmentPlan
+	if found, err := r.cache.GetObject(ctx, cacheKey, &plan); err != nil {
 		log.Printf("Failed to get treatment plan from cache: %v", err)
+	} else if found {
+		return &plan, nil
 	}
 
-	var plan models.TreatmentPlan
-	err = database.DB.Select("id, patient_id, plan, created_at").
+	err := database.DB.WithContext(ctx).Select("id, patient_id, plan, estimated_cost, version, created_at, updated_at, created_by, updated_by").
 		Preload("Patient", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id, first_name, last_name")
 		}).
@@ -100,11 +78,7 @@ func (r *TreatmentPlanRepository) GetByID(ctx context.Context, patientID string,
 		return nil, fmt.Errorf("failed to get treatment plan: %w", err)
 	}
 
-	planJSON, err := json.Marshal(plan)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal treatment plan: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, planJSON, TreatmentPlanCacheExpiry); err != nil {
+	if err := r.cache.SetObject(ctx, cacheKey, plan, cache.ItemTTL("treatment_plan")); err != nil {
 		log.Printf("Failed to set treatment plan in cache: %v", err)
 	}
 
@@ -112,142 +86,168 @@ func (r *TreatmentPlanRepository) GetByID(ctx context.Context, patientID string,
 }
 
 func (r *TreatmentPlanRepository) GetAll(ctx context.Context) ([]models.TreatmentPlan, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := database.WithReadTimeout(ctx)
 	defer cancel()
 
-	cacheKey := "treatment_plans_cache"
-	cachedPlans, err := r.cache.Get(ctx, cacheKey)
-	if err == nil {
+	return cache.ReadList(ctx, r.cache, r.cache.Key(ctx, "treatment_plans"), "treatment_plan", func(ctx context.Context) ([]models.TreatmentPlan, error) {
 		var plans []models.TreatmentPlan
-		if err := json.Unmarshal([]byte(cachedPlans), &plans); err == nil {
-			return plans, nil
+		err := database.DB.WithContext(ctx).Select("id, patient_id, plan, estimated_cost, version, created_at, updated_at, created_by, updated_by").
+			Preload("Patient", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id, first_name, last_name")
+			}).
+			Order("created_at DESC").
+			Find(&plans).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get all treatment plans: %w", err)
 		}
-	} else if err != redis.Nil {
-		log.Printf("Failed to get treatment plans from cache: %v", err)
-	}
-
-	var plans []models.TreatmentPlan
-	err = database.DB.Select("id, patient_id, plan, created_at").
-		Preload("Patient", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id, first_name, last_name")
-		}).
-		Order("created_at DESC").
-		Find(&plans).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all treatment plans: %w", err)
-	}
+		return plans, nil
+	})
+}
 
-	plansJSON, err := json.Marshal(plans)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal treatment plans: %w", err)
-	}
-	if err := r.cache.Set(ctx, cacheKey, plansJSON, TreatmentPlanCacheExpiry); err != nil {
-		log.Printf("Failed to set treatment plans in cache: %v", err)
+// ListByPatientAfter returns up to limit of the patient's treatment plans newest first, starting
+// after the cursor when one is given
+func (r *TreatmentPlanRepository) ListByPatientAfter(ctx context.Context, patientID string, after *models.ListCursor, limit int) ([]models.TreatmentPlan, error) {
+	var createdAt time.Time
+	var key interface{}
+	if after != nil {
+		id, err := strconv.ParseUint(after.Key, 10, 64)
+		if err != nil {
+			return nil, models.ErrInvalidCursor
+		}
+		createdAt, key = after.CreatedAt, id
 	}
-
-	return plans, nil
+	return listNewestFirst[models.TreatmentPlan](ctx, "id", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, patient_id, plan, estimated_cost, version, created_at, updated_at, created_by, updated_by").
+			Where("patient_id = ?", patientID)
+	}, createdAt, key, limit)
 }
 
+// Update revises a treatment plan. A change to the plan or its estimated cost is kept as a new
+// version rather than overwriting the previous one.
 func (r *TreatmentPlanRepository) Update(ctx context.Context, plan *models.TreatmentPlan) error {
-	lockKey := fmt.Sprintf("treatment_plan_lock:%s", plan.PatientID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("treatment_plan_lock:%s", plan.PatientID), func(tx *gorm.DB) error {
+		var current models.TreatmentPlan
+		if err := tx.Select("id, plan, estimated_cost, version").First(&current, "patient_id = ? AND id = ?", plan.PatientID, plan.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTreatmentPlanNotFound
+			}
+			return fmt.Errorf("failed to get treatment plan: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		plan.Version = current.Version
+		if plan.Plan != current.Plan || !sameCost(plan.EstimatedCost, current.EstimatedCost) {
+			plan.Version++
+			if err := createTreatmentPlanVersion(tx, plan); err != nil {
+				return err
+			}
 		}
-	}()
 
-	err = database.DB.Save(plan).Error
-	if err != nil {
-		return fmt.Errorf("failed to update treatment plan: %w", err)
-	}
-	// Delete cache for the updated treatment plan and all treatment plans
-	if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(plan.PatientID, plan.ID)); err != nil {
-		return fmt.Errorf("failed to delete treatment plan cache: %w", err)
-	}
-	if err := r.cache.DeleteAll(ctx, "treatment_plans_cache"); err != nil {
-		return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
-	}
-	// Invalidate the specific patient cache and all treatment plans cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(plan.PatientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
-	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+		// Who captured the record never changes
+		err := tx.Omit("created_by").Save(plan).Error
+		if err != nil {
+			return fmt.Errorf("failed to update treatment plan: %w", err)
+		}
+		// Delete cache for the updated treatment plan and all treatment plans
+		if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(ctx, plan.PatientID, plan.ID)); err != nil {
+			return fmt.Errorf("failed to delete treatment plan cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "treatment_plans")); err != nil {
+			return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all treatment plans cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, plan.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
 }
 
 func (r *TreatmentPlanRepository) Delete(ctx context.Context, patientID string, id uint) error {
-	lockKey := fmt.Sprintf("treatment_plan_lock:%s", patientID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	// Retry logic for acquiring lock
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-	var locked bool
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		locked, err = database.NewLock(ctx, lockKey, lockValue, 10*time.Second) // Shortened expiry
-		if err == nil && locked {
-			break
-		}
-		if i < maxRetries-1 {
-			time.Sleep(retryDelay)
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("treatment_plan_lock:%s", patientID), func(tx *gorm.DB) error {
+		err := tx.Delete(&models.TreatmentPlan{}, "patient_id = ? AND id = ?", patientID, id).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete treatment plan: %w", err)
 		}
-	}
-	if !locked {
-		return fmt.Errorf("failed to acquire lock after retries: %w", err)
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
+		// Delete cache for the deleted treatment plan and all treatment plans
+		if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(ctx, patientID, id)); err != nil {
+			return fmt.Errorf("failed to delete treatment plan cache: %w", err)
+		}
+		if err := r.cache.DeleteAll(ctx, r.cache.Key(ctx, "treatment_plans")); err != nil {
+			return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
+		}
+		// Invalidate the specific patient cache and all treatment plans cache
+		if err := r.cache.Delete(ctx, r.getPatientCacheKey(ctx, patientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
 		}
-	}()
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+}
+
+// GetVersions returns the versions of a treatment plan, oldest first
+func (r *TreatmentPlanRepository) GetVersions(ctx context.Context, patientID string, id uint) ([]models.TreatmentPlanVersion, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
 
-	err = database.DB.Delete(&models.TreatmentPlan{}, "patient_id = ? AND id = ?", patientID, id).Error
+	var versions []models.TreatmentPlanVersion
+	err := database.DB.WithContext(ctx).
+		Where("patient_id = ? AND treatment_plan_id = ?", patientID, id).
+		Order("version").
+		Find(&versions).Error
 	if err != nil {
-		return fmt.Errorf("failed to delete treatment plan: %w", err)
+		return nil, fmt.Errorf("failed to get treatment plan versions: %w", err)
 	}
-	// Delete cache for the deleted treatment plan and all treatment plans
-	if err := r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(patientID, id)); err != nil {
-		return fmt.Errorf("failed to delete treatment plan cache: %w", err)
+	return versions, nil
+}
+
+func createTreatmentPlanVersion(tx *gorm.DB, plan *models.TreatmentPlan) error {
+	version := models.TreatmentPlanVersion{
+		TreatmentPlanID: plan.ID,
+		Version:         plan.Version,
+		PatientID:       plan.PatientID,
+		Plan:            plan.Plan,
+		EstimatedCost:   plan.EstimatedCost,
+		Reason:          plan.Reason,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return fmt.Errorf("failed to create treatment plan version: %w", err)
 	}
-	if err := r.cache.DeleteAll(ctx, "treatment_plans_cache"); err != nil {
-		return fmt.Errorf("failed to delete all treatment plans cache: %w", err)
+	return nil
+}
+
+func sameCost(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	// Invalidate the specific patient cache and all treatment plans cache
-	if err := r.cache.Delete(ctx, r.getPatientCacheKey(patientID)); err != nil {
-		return fmt.Errorf("failed to delete patient cache: %w", err)
+	return *a == *b
+}
+
+// InvalidatePatientCache drops the cached treatment plans of a deleted patient on PatientDeleted
+func (r *TreatmentPlanRepository) InvalidatePatientCache(ctx context.Context, event events.Event) error {
+	for _, id := range relatedIDs(event, "treatment_plan") {
+		if err := r.DeleteCache(ctx, event.PatientID, id); err != nil {
+			return err
+		}
 	}
-	return r.cache.DeleteAll(ctx, "patients_cache")
+	return r.DeleteAllCache(ctx)
 }
 
 func (r *TreatmentPlanRepository) DeleteCache(ctx context.Context, patientID string, id uint) error {
-	return r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(patientID, id))
+	return r.cache.Delete(ctx, r.getTreatmentPlanCacheKey(ctx, patientID, id))
 }
 
 func (r *TreatmentPlanRepository) DeleteAllCache(ctx context.Context) error {
-	return r.cache.DeleteAll(ctx, "treatment_plans_cache")
+	return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "treatment_plans"))
 }
 
-func (r *TreatmentPlanRepository) getTreatmentPlanCacheKey(patientID string, id uint) string {
-	return fmt.Sprintf("treatment_plan_cache:%s:%d", patientID, id)
+func (r *TreatmentPlanRepository) getTreatmentPlanCacheKey(ctx context.Context, patientID string, id uint) string {
+	return r.cache.Key(ctx, "treatment_plan", patientID, id)
 }
 
-func (r *TreatmentPlanRepository) getPatientCacheKey(patientID string) string {
-	return fmt.Sprintf("patient_cache:%s", patientID)
+func (r *TreatmentPlanRepository) getPatientCacheKey(ctx context.Context, patientID string) string {
+	return r.cache.Key(ctx, "patient", patientID)
 }
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVerificationResolved is returned when a verification no longer needs following up
+var ErrVerificationResolved = errors.New("verification does not need follow-up")
+
+// VerificationRepository stores the outcome of each patient's external verification checks
+type VerificationRepository struct{}
+
+func NewVerificationRepository() *VerificationRepository {
+	return &VerificationRepository{}
+}
+
+// Save records the outcome of a check, replacing the previous one and reopening it for follow-up
+func (r *VerificationRepository) Save(ctx context.Context, verification *models.PatientVerification) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	verification.ResolvedBy = nil
+	verification.ResolvedAt = nil
+	verification.ResolutionNote = ""
+	err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "patient_id"}, {Name: "check_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reference", "status", "detail", "checked_at", "resolved_by", "resolved_at", "resolution_note"}),
+	}).Create(verification).Error
+	if err != nil {
+		return fmt.Errorf("failed to save verification: %w", err)
+	}
+	return nil
+}
+
+func (r *VerificationRepository) GetByID(ctx context.Context, id uint) (*models.PatientVerification, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var verification models.PatientVerification
+	if err := database.DB.WithContext(ctx).First(&verification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get verification: %w", err)
+	}
+	return &verification, nil
+}
+
+// Get returns the latest outcome of a check for a patient
+func (r *VerificationRepository) Get(ctx context.Context, patientID, check string) (*models.PatientVerification, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var verification models.PatientVerification
+	if err := database.DB.WithContext(ctx).Where("patient_id = ? AND check_type = ?", patientID, check).First(&verification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get verification: %w", err)
+	}
+	return &verification, nil
+}
+
+func (r *VerificationRepository) ListByPatient(ctx context.Context, patientID string) ([]models.PatientVerification, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var verifications []models.PatientVerification
+	if err := database.DB.WithContext(ctx).Where("patient_id = ?", patientID).Order("check_type").Find(&verifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to list verifications: %w", err)
+	}
+	return verifications, nil
+}
+
+// ListOpen returns the failed and errored checks staff have not resolved yet, oldest first
+func (r *VerificationRepository) ListOpen(ctx context.Context) ([]models.PatientVerification, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var verifications []models.PatientVerification
+	err := database.DB.WithContext(ctx).
+		Where("status <> ? AND resolved_at IS NULL", models.VerificationVerified).
+		Order("checked_at").
+		Find(&verifications).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open verifications: %w", err)
+	}
+	return verifications, nil
+}
+
+// Resolve closes the follow-up of a failed or errored check
+func (r *VerificationRepository) Resolve(ctx context.Context, verification *models.PatientVerification, userID int64, note string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	result := database.DB.WithContext(ctx).Model(&models.PatientVerification{}).
+		Where("id = ? AND status <> ? AND resolved_at IS NULL", verification.ID, models.VerificationVerified).
+		Updates(map[string]interface{}{"resolved_by": userID, "resolved_at": now, "resolution_note": note})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve verification: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrVerificationResolved
+	}
+	verification.ResolvedBy = &userID
+	verification.ResolvedAt = &now
+	verification.ResolutionNote = note
+	return nil
+}
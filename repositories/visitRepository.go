@@ -0,0 +1,342 @@
+package repositories
+
+import (
+	"RoyDental/cache"
+	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVisitNotReady is returned when treatment is started before the vitals and checklists are done.
+var ErrVisitNotReady = errors.New("the patient is not ready for treatment")
+
+// ErrAppointmentNotFound is returned when an appointment does not exist for the patient.
+var ErrAppointmentNotFound = errors.New("appointment not found")
+
+// VisitRepository records visits that do not start from a booked appointment.
+type VisitRepository struct {
+	cache       *cache.Cache
+	patientRepo *PatientRepository
+}
+
+func NewVisitRepository(cache *cache.Cache, patientRepo *PatientRepository) *VisitRepository {
+	return &VisitRepository{cache: cache, patientRepo: patientRepo}
+}
+
+// CreateWalkIn creates an appointment starting now with walk_in origin, already checked in. When
+// newPatient is set, the patient is created in the same transaction and the appointment is made for it.
+func (r *VisitRepository) CreateWalkIn(ctx context.Context, newPatient *models.Patient, appointment *models.Appointment) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	lockKey := fmt.Sprintf("walk_in_lock:%s", appointment.PatientID)
+	if newPatient != nil {
+		lockKey = patientCreateLockKey(newPatient)
+	}
+
+	err := database.WithLock(ctx, lockKey, func(tx *gorm.DB) error {
+		if newPatient != nil {
+			if err := r.patientRepo.createInTx(tx, newPatient); err != nil {
+				return err
+			}
+			appointment.PatientID = newPatient.ID
+		} else {
+			var count int64
+			if err := tx.Model(&models.Patient{}).Where("id = ?", appointment.PatientID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check patient: %w", err)
+			}
+			if count == 0 {
+				return errors.New("patient not found")
+			}
+		}
+
+		// A walk-in starts as it is recorded; it has no booked end, so it takes up no slot
+		now := time.Now().UTC()
+		appointment.ID = 0
+		appointment.DateTime = models.StoredAppointmentTime(now)
+		appointment.StartsAt, appointment.EndsAt = &now, nil
+		appointment.Status = models.AppointmentStatusCheckedIn
+		appointment.Origin = models.AppointmentOriginWalkIn
+		appointment.CheckedInAt = &now
+		appointment.SeenAt = nil
+		if err := tx.Omit("Patient", "Doctor").Create(appointment).Error; err != nil {
+			return fmt.Errorf("failed to create walk-in appointment: %w", err)
+		}
+
+		if err := deleteListCache(ctx, r.cache, "appointments", doctorAppointmentsCache, doctorPatientsCache); err != nil {
+			return fmt.Errorf("failed to delete all appointments cache: %w", err)
+		}
+		if err := r.cache.Delete(ctx, r.cache.Key(ctx, "patient", appointment.PatientID)); err != nil {
+			return fmt.Errorf("failed to delete patient cache: %w", err)
+		}
+		return r.cache.DeleteAll(ctx, r.cache.Key(ctx, "patients"))
+	})
+	if err != nil {
+		return err
+	}
+	if newPatient != nil {
+		events.Publish(ctx, events.Event{Name: events.PatientCreated, PatientID: newPatient.ID})
+	}
+	events.Publish(ctx, events.Event{Name: events.AppointmentBooked, PatientID: appointment.PatientID, Record: "appointment",
+		RecordID: strconv.FormatUint(uint64(appointment.ID), 10)})
+	return nil
+}
+
+// SaveVitals records the vitals of the patient's appointment, replacing any taken earlier
+func (r *VisitRepository) SaveVitals(ctx context.Context, vitals *models.Vitals) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", vitals.PatientID, vitals.AppointmentID), func(tx *gorm.DB) error {
+		if err := appointmentExists(tx, vitals.PatientID, vitals.AppointmentID); err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "appointment_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"systolic_bp", "diastolic_bp", "pulse", "medical_alerts_confirmed", "notes", "updated_at", "updated_by"}),
+		}).Create(vitals).Error
+		if err != nil {
+			return fmt.Errorf("failed to save vitals: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetVitals returns the vitals taken for the appointment, or nil when none were taken
+func (r *VisitRepository) GetVitals(ctx context.Context, appointmentID uint) (*models.Vitals, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var vitals models.Vitals
+	if err := database.DB.WithContext(ctx).First(&vitals, "appointment_id = ?", appointmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get vitals: %w", err)
+	}
+	return &vitals, nil
+}
+
+func (r *VisitRepository) CreateChecklist(ctx context.Context, checklist *models.Checklist) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Create(checklist).Error; err != nil {
+		return fmt.Errorf("failed to create checklist: %w", err)
+	}
+	return nil
+}
+
+func (r *VisitRepository) GetChecklist(ctx context.Context, id uint) (*models.Checklist, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var checklist models.Checklist
+	if err := database.DB.WithContext(ctx).First(&checklist, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get checklist: %w", err)
+	}
+	return &checklist, nil
+}
+
+func (r *VisitRepository) ListChecklists(ctx context.Context) ([]models.Checklist, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var checklists []models.Checklist
+	if err := database.DB.WithContext(ctx).Order("name").Find(&checklists).Error; err != nil {
+		return nil, fmt.Errorf("failed to list checklists: %w", err)
+	}
+	return checklists, nil
+}
+
+func (r *VisitRepository) UpdateChecklist(ctx context.Context, checklist *models.Checklist) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.WithContext(ctx).Model(checklist).
+		Select("name", "specialty", "items", "active", "updated_at").
+		Updates(checklist).Error
+	if err != nil {
+		return fmt.Errorf("failed to update checklist: %w", err)
+	}
+	return nil
+}
+
+func (r *VisitRepository) DeleteChecklist(ctx context.Context, id uint) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Delete(&models.Checklist{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete checklist: %w", err)
+	}
+	return nil
+}
+
+// CompleteChecklist records the checklist as completed for the patient's appointment; completing it
+// again is a no-op
+func (r *VisitRepository) CompleteChecklist(ctx context.Context, patientID string, completion *models.ChecklistCompletion) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.WithLock(ctx, fmt.Sprintf("appointment_lock:%s_%d", patientID, completion.AppointmentID), func(tx *gorm.DB) error {
+		if err := appointmentExists(tx, patientID, completion.AppointmentID); err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "appointment_id"}, {Name: "checklist_id"}},
+			DoNothing: true,
+		}).Create(completion).Error
+		if err != nil {
+			return fmt.Errorf("failed to complete checklist: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *VisitRepository) ListCompletions(ctx context.Context, appointmentID uint) ([]models.ChecklistCompletion, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var completions []models.ChecklistCompletion
+	if err := database.DB.WithContext(ctx).Where("appointment_id = ?", appointmentID).Order("created_at").Find(&completions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list checklist completions: %w", err)
+	}
+	return completions, nil
+}
+
+// Outstanding returns what still has to be done before treatment of the appointment can start
+func (r *VisitRepository) Outstanding(ctx context.Context, appointment *models.Appointment) ([]string, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	return outstandingBeforeStart(database.DB.WithContext(ctx), appointment)
+}
+
+// Summary gathers what a visit summary tells the patient about summary.Appointment, whose Day is set:
+// the examinations and bills of that clinic day, the latest treatment plan and the next scheduled
+// appointment after the day
+func (r *VisitRepository) Summary(ctx context.Context, summary *models.VisitSummary) error {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	db := database.DB.WithContext(ctx)
+	appointment := summary.Appointment
+	from, to := models.ClinicDay(summary.Day)
+
+	if appointment.ProcedureID != nil {
+		var names []string
+		if err := db.Model(&models.Procedure{}).Where("id = ?", *appointment.ProcedureID).Pluck("name", &names).Error; err != nil {
+			return fmt.Errorf("failed to get procedure: %w", err)
+		}
+		if len(names) > 0 {
+			summary.Procedure = names[0]
+		}
+	}
+
+	err := db.Select("id, patient_id, report, created_at").
+		Where("patient_id = ? AND created_at >= ? AND created_at < ?", appointment.PatientID, from, to).
+		Order("created_at, id").Find(&summary.Examinations).Error
+	if err != nil {
+		return fmt.Errorf("failed to get examinations: %w", err)
+	}
+
+	err = db.Select("billing_id, patient_id, doctor_id, procedure, billing_amount, paid_cash_amount, paid_insurance_amount, balance, created_at").
+		Where("patient_id = ? AND created_at >= ? AND created_at < ?", appointment.PatientID, from, to).
+		Order("created_at, billing_id").Find(&summary.Billings).Error
+	if err != nil {
+		return fmt.Errorf("failed to get billings: %w", err)
+	}
+
+	var plan models.TreatmentPlan
+	err = db.Select("id, patient_id, plan, estimated_cost, version, created_at, updated_at").
+		Where("patient_id = ?", appointment.PatientID).Order("updated_at DESC, id DESC").First(&plan).Error
+	switch {
+	case err == nil:
+		summary.TreatmentPlan = &plan
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to get treatment plan: %w", err)
+	}
+
+	var next models.Appointment
+	err = db.Select("id, patient_id, doctor_id, date_time, status, type, starts_at").
+		Preload("Doctor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		}).
+		Where("patient_id = ? AND id <> ? AND status = ? AND starts_at >= ?", appointment.PatientID, appointment.ID, models.AppointmentStatusScheduled, to).
+		Order("starts_at, id").First(&next).Error
+	switch {
+	case err == nil:
+		summary.NextAppointment = &next
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to get next appointment: %w", err)
+	}
+	return nil
+}
+
+// outstandingBeforeStart lists the vitals, pre-procedure checklists and alert acknowledgements still
+// missing for appointment: vitals with the medical alerts confirmed, every active checklist for all
+// appointments or for the doctor's specialty, and every active warning or critical patient alert.
+func outstandingBeforeStart(tx *gorm.DB, appointment *models.Appointment) ([]string, error) {
+	outstanding := []string{}
+
+	var vitals models.Vitals
+	err := tx.Select("id, medical_alerts_confirmed").First(&vitals, "appointment_id = ?", appointment.ID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		outstanding = append(outstanding, "vitals have not been recorded")
+	case err != nil:
+		return nil, fmt.Errorf("failed to get vitals: %w", err)
+	case !vitals.MedicalAlertsConfirmed:
+		outstanding = append(outstanding, "medical alerts have not been confirmed")
+	}
+
+	var missing []string
+	err = tx.Model(&models.Checklist{}).
+		Where("active AND (specialty = '' OR specialty = (SELECT specialty FROM doctor WHERE id = ?))", appointment.DoctorID).
+		Where("NOT EXISTS (SELECT 1 FROM checklist_completion cc WHERE cc.checklist_id = checklist.id AND cc.appointment_id = ?)", appointment.ID).
+		Order("name").
+		Pluck("name", &missing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check checklists: %w", err)
+	}
+	for _, name := range missing {
+		outstanding = append(outstanding, fmt.Sprintf("checklist %q has not been completed", name))
+	}
+
+	var unacknowledged []string
+	err = tx.Model(&models.PatientAlert{}).
+		Where("patient_id = ? AND active AND severity IN ?", appointment.PatientID, []string{models.AlertSeverityWarning, models.AlertSeverityCritical}).
+		Where("NOT EXISTS (SELECT 1 FROM patient_alert_acknowledgement pa WHERE pa.alert_id = patient_alert.id AND pa.appointment_id = ?)", appointment.ID).
+		Order(alertOrder).
+		Pluck("type", &unacknowledged).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check patient alerts: %w", err)
+	}
+	for _, alertType := range unacknowledged {
+		outstanding = append(outstanding, fmt.Sprintf("%s alert has not been acknowledged", strings.ReplaceAll(alertType, "_", " ")))
+	}
+	return outstanding, nil
+}
+
+func appointmentExists(tx *gorm.DB, patientID string, id uint) error {
+	var count int64
+	if err := tx.Model(&models.Appointment{}).Where("id = ? AND patient_id = ?", id, patientID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check appointment: %w", err)
+	}
+	if count == 0 {
+		return ErrAppointmentNotFound
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAppointmentRequestReviewed is returned when an appointment request has already been booked or declined
+var ErrAppointmentRequestReviewed = errors.New("appointment request has already been reviewed")
+
+// WebsiteRepository reads what the clinic website lists and stores the appointments requested on it
+type WebsiteRepository struct{}
+
+func NewWebsiteRepository() *WebsiteRepository {
+	return &WebsiteRepository{}
+}
+
+// Doctors returns every doctor with what the website shows of them and their credential expiries,
+// ordered by name
+func (r *WebsiteRepository) Doctors(ctx context.Context) ([]models.Doctor, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var doctors []models.Doctor
+	err := database.DB.WithContext(ctx).
+		Select("id, first_name, last_name, specialty, photo_url, licence_expires_on, indemnity_expires_on, cpd_expires_on").
+		Order("last_name, first_name").
+		Find(&doctors).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list website doctors: %w", err)
+	}
+	return doctors, nil
+}
+
+// DoctorProcedures returns the procedures every doctor performs
+func (r *WebsiteRepository) DoctorProcedures(ctx context.Context) ([]models.DoctorProcedure, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var links []models.DoctorProcedure
+	if err := database.DB.WithContext(ctx).Order("doctor_id, procedure_id").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to list doctor procedures: %w", err)
+	}
+	return links, nil
+}
+
+func (r *WebsiteRepository) CreateRequest(ctx context.Context, request *models.AppointmentRequest) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	if err := database.DB.WithContext(ctx).Omit("Doctor", "Procedure").Create(request).Error; err != nil {
+		return fmt.Errorf("failed to create appointment request: %w", err)
+	}
+	return nil
+}
+
+func (r *WebsiteRepository) GetRequest(ctx context.Context, id uint) (*models.AppointmentRequest, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	var request models.AppointmentRequest
+	if err := database.DB.WithContext(ctx).First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get appointment request: %w", err)
+	}
+	return &request, nil
+}
+
+// ListRequests returns appointment requests in status, oldest first so the queue is worked in order
+func (r *WebsiteRepository) ListRequests(ctx context.Context, status string) ([]models.AppointmentRequest, error) {
+	ctx, cancel := database.WithReadTimeout(ctx)
+	defer cancel()
+
+	query := database.DB.WithContext(ctx)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []models.AppointmentRequest
+	if err := query.Order("created_at, id").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to list appointment requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ReviewRequest closes a pending appointment request as booked, with the appointment it was booked
+// as, or declined
+func (r *WebsiteRepository) ReviewRequest(ctx context.Context, request *models.AppointmentRequest, status string, appointmentID *uint, reviewer int64, note string) error {
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.AppointmentRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, status").First(&current, request.ID).Error; err != nil {
+			return fmt.Errorf("failed to get appointment request: %w", err)
+		}
+		if current.Status != models.AppointmentRequestPending {
+			return ErrAppointmentRequestReviewed
+		}
+		now := time.Now()
+		request.Status = status
+		request.AppointmentID = appointmentID
+		request.ReviewedBy = &reviewer
+		request.ReviewedAt = &now
+		request.ReviewNote = note
+		err := tx.Model(request).Select("status", "appointment_id", "reviewed_by", "reviewed_at", "review_note").Updates(request).Error
+		if err != nil {
+			return fmt.Errorf("failed to update appointment request: %w", err)
+		}
+		return nil
+	})
+}
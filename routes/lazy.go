@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"RoyDental/controllers"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LazyHandler serves startup probes until the application handler is ready, then delegates to it.
+type LazyHandler struct {
+	current atomic.Value // http.Handler
+}
+
+// NewLazyHandler returns a handler that reports not-ready until Ready is called.
+func NewLazyHandler() *LazyHandler {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	controllers.SetupStartupRoutes(router)
+
+	h := &LazyHandler{}
+	h.current.Store(http.Handler(router))
+	return h
+}
+
+// Ready switches all traffic to handler.
+func (h *LazyHandler) Ready(handler http.Handler) {
+	h.current.Store(handler)
+}
+
+func (h *LazyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().(http.Handler).ServeHTTP(w, r)
+}
@@ -1,13 +1,25 @@
 package routes
 
 import (
+	"RoyDental/antivirus"
 	"RoyDental/cache"
+	"RoyDental/clock"
 	"RoyDental/config"
 	"RoyDental/controllers"
+	"RoyDental/database"
+	"RoyDental/debuglog"
+	"RoyDental/events"
 	"RoyDental/handlers"
 	"RoyDental/middlewares"
+	"RoyDental/models"
+	"RoyDental/notifications"
 	"RoyDental/repositories"
 	"RoyDental/services"
+	"RoyDental/siem"
+	"RoyDental/storage"
+	"context"
+	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,21 +27,260 @@ import (
 )
 
 // SetupRoutes initializes the routes and middleware for the server
-func SetupRoutes(cache *cache.Cache, config *config.AppConfig, db *gorm.DB) http.Handler {
-	// Set Gin to release mode
-	gin.SetMode(gin.ReleaseMode)
+func SetupRoutes(cache *cache.Cache, config *config.AppConfig, db *gorm.DB) (http.Handler, error) {
+	// Gin logs its debug output, such as the route table, only in development
+	if config.Profiling.Development() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Create a Gin router; panics are recovered by our own middleware below so they get the standard error response
+	router := gin.New()
+	router.Use(gin.Logger())
+
+	// Tag every request with an ID that error responses and logs share
+	router.Use(middlewares.RequestIDMiddleware())
+
+	// Let handlers pass the gin context on as a context.Context carrying the request's values,
+	// such as the user writes are attributed to
+	router.ContextWithFallback = true
+
+	// Security headers go on every response, including probes and rejected requests
+	router.Use(middlewares.SecurityHeadersMiddleware(config.SecurityHeaders))
+
+	// Count responses by status class for /metrics and operational alerts
+	router.Use(middlewares.ResponseMetricsMiddleware())
+
+	// Answer panics with a 500 carrying the request ID; registered after the metrics so they are counted
+	router.Use(middlewares.RecoveryMiddleware())
+
+	// Flag requests slower than their route's budget, and turn heavy reports away while the
+	// database pool is saturated
+	router.Use(middlewares.LatencyBudgetMiddleware(config.LatencyBudgets, database.PoolUsage))
+
+	// Bound every request with a deadline the data layer honours
+	router.Use(middlewares.RequestTimeoutMiddleware(config.RequestTimeouts))
+
+	// IP rules and the per-address auth limits are meaningless if clients can spoof
+	// X-Forwarded-For, so only the listed proxies are believed, and none when none are listed
+	if err := router.SetTrustedProxies(config.IPFilter.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Emails, text messages and chat posts that fail are tried again with growing delays, and kept
+	// for admins to replay when they never go through; patients' messages settle in their log then
+	communicationService := services.NewCommunicationService(repositories.NewCommunicationRepository(), repositories.NewPatientRepository(cache), config.Portal)
+	deliveryRetryService := services.NewDeliveryRetryService(repositories.NewFailedDeliveryRepository(), communicationService, config.DeliveryRetry, clock.Default())
+	notifications.RetryEvents(deliveryRetryService.Notifier)
+
+	// Record authorization failures from every middleware in the security audit trail
+	auditRepo := repositories.NewAuditRepository()
+	auditService := services.NewAuditService(auditRepo, newSecurityNotifier(config.Audit, deliveryRetryService), config.Audit)
+	middlewares.SetAuthFailureAuditor(auditService)
+
+	// Payments, cancellations and patient deletions are kept in the activity audit trail
+	events.Subscribe(events.PaymentRecorded, auditService.HandleDomainEvent)
+	events.Subscribe(events.AppointmentCancelled, auditService.HandleDomainEvent)
+	events.Subscribe(events.PatientDeleted, auditService.HandleDomainEvent)
+
+	// Name what the database lacks, such as an ID sequence, before it fails the first request needing it
+	schemaService := services.NewSchemaService(repositories.NewSchemaRepository())
+	if config.SchemaCheck.OnStartup {
+		if err := schemaService.CheckOnStartup(context.Background(), config.SchemaCheck.FailOnDrift); err != nil {
+			return nil, err
+		}
+	}
+
+	// Probes and metrics are registered before any middleware so orchestrators can reach them without credentials
+	controllers.SetupHealthRoutes(router)
+
+	// Reject clients outside the configured networks and countries before they authenticate
+	ipFilter, err := middlewares.IPFilterMiddleware(config.IPFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP filter: %w", err)
+	}
+	router.Use(ipFilter)
+
+	// Attachments, exports and archives go to the configured object storage, in the database when none is
+	store, err := storage.New(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage: %w", err)
+	}
+	files := services.NewFiles(store)
+	scanner, err := antivirus.New(config.VirusScan)
+	if err != nil {
+		return nil, fmt.Errorf("invalid virus scanning: %w", err)
+	}
+
+	// While the primary is down, or an admin says so, writes are refused and reads go on from the
+	// cache and the replica. Staff can still sign in, and admins can turn the mode off.
+	readOnlyService := services.NewReadOnlyService(repositories.NewReadOnlyRepository(), config.ReadOnly)
+	router.Use(middlewares.ReadOnlyMiddleware(readOnlyService, controllers.ReadOnlyTogglePath, "/auth/login", "/auth/refresh-token", "/auth/logoff"))
+
+	// Count each signed-in user's and integration's requests by route for the access reviews and the
+	// usage analytics. It comes before the integrations' routes so their keys are counted too.
+	apiUsageRepo := repositories.NewAPIUsageRepository()
+	apiUsageService := services.NewAPIUsageService(apiUsageRepo, config.APIUsage)
+	router.Use(middlewares.APIUsageMiddleware(apiUsageService))
+
+	// Log every read of a patient's record, which the patient sees in the portal
+	recordAccessService := services.NewRecordAccessService(repositories.NewRecordAccessRepository(), repositories.NewPatientRepository(cache))
+	router.Use(middlewares.RecordAccessMiddleware(recordAccessService))
+
+	// The waiting-room kiosk authenticates with its own keys instead of the API bearer token
+	kioskHandler := handlers.NewKioskHandler(services.NewKioskService(repositories.NewKioskRepository(cache), repositories.NewAppointmentRepository(cache)))
+	if len(config.KioskAPIKeys) > 0 {
+		controllers.SetupKioskRoutes(router, kioskHandler, config.KioskAPIKeys)
+	}
+
+	// Patients choose which messages they receive through signed links in those messages
+	emailDeliveryService := services.NewEmailDeliveryService(repositories.NewEmailDeliveryRepository(cache), config.EmailDelivery)
+	// Patients are not texted during quiet hours or on public holidays; the messages go out afterwards
+	closureRepo := repositories.NewClosureRepository()
+	quietHoursService := services.NewQuietHoursService(repositories.NewDeferredMessageRepository(), closureRepo, communicationService, config.QuietHours, clock.Default())
+	communicationHandler := handlers.NewCommunicationHandler(communicationService)
+	if communicationService.PortalEnabled() {
+		controllers.SetupPortalRoutes(router, communicationHandler)
+	}
+
+	// Patients answer satisfaction surveys through signed links, without an API token
+	surveyService := services.NewSurveyService(repositories.NewSurveyRepository(), newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), config.Survey)
+	surveyHandler := handlers.NewSurveyHandler(surveyService)
+	if surveyService.Enabled() {
+		controllers.SetupSurveyRoutes(router, surveyHandler)
+	}
+
+	// Calendar apps subscribe to doctors' feeds with a signed token in the URL
+	doctorAppRepo := repositories.NewDoctorAppRepository()
+	calendarService := services.NewCalendarService(doctorAppRepo, config.Calendar)
+	calendarHandler := handlers.NewCalendarHandler(calendarService)
+	if calendarService.Enabled() {
+		controllers.SetupCalendarFeedRoutes(router, calendarHandler)
+	}
+
+	// The imaging unit sends DICOM files with its own keys
+	imagingRepo := repositories.NewImagingRepository()
+	imagingService := services.NewImagingService(imagingRepo, config.Imaging)
+	imagingHandler := handlers.NewImagingHandler(imagingService)
+	if imagingService.Enabled() {
+		controllers.SetupImagingIngestRoutes(router, imagingHandler, config.Imaging.APIKeys)
+	}
+
+	// Initialize repositories; the patient service is shared with the hospital integration below
+	emergencyContactRepo := repositories.NewEmergencyContactRepository(cache)
+	billingRepo := repositories.NewBillingRepository(cache)
+	examinationRepo := repositories.NewExaminationRepository(cache)
+	treatmentPlanRepo := repositories.NewTreatmentPlanRepository(cache)
+	appointmentRepo := repositories.NewAppointmentRepository(cache)
+
+	// Admins add practice-specific fields to patients and appointments
+	customFieldService := services.NewCustomFieldService(repositories.NewCustomFieldRepository(cache))
+
+	patientRepo := repositories.NewPatientRepository(cache)
+	patientAlertRepo := repositories.NewPatientAlertRepository()
+	// Deleting patients with billing history, large discounts, reopening payroll and exporting teaching
+	// cases wait for an admin's approval
+	userRepo := repositories.NewUserRepository(db, cache)
+	approvalService := services.NewApprovalService(repositories.NewApprovalRepository(), auditService, userRepo)
+	patientService := services.NewPatientService(patientRepo, customFieldService, patientAlertRepo, billingRepo, approvalService, config.Guarantors)
+
+	// Cards and invoices carry a signed QR code reception scans to open the patient's record
+	patientQRService := services.NewPatientQRService(patientRepo, config.PatientQR)
+
+	// Records deleted with a patient leave the cache through their own repositories
+	events.Subscribe(events.PatientDeleted, emergencyContactRepo.InvalidatePatientCache)
+	events.Subscribe(events.PatientDeleted, billingRepo.InvalidatePatientCache)
+	events.Subscribe(events.PatientDeleted, examinationRepo.InvalidatePatientCache)
+	events.Subscribe(events.PatientDeleted, treatmentPlanRepo.InvalidatePatientCache)
+	events.Subscribe(events.PatientDeleted, appointmentRepo.InvalidatePatientCache)
+
+	// Saved patients are checked against the configured national ID and insurer member webhooks
+	verificationService := services.NewVerificationService(repositories.NewVerificationRepository(), patientRepo, config.Verification)
+	events.Subscribe(events.PatientCreated, verificationService.HandlePatientSaved)
+	events.Subscribe(events.PatientUpdated, verificationService.HandlePatientSaved)
+
+	// Insured patients' bookings queue a check of their cover on the appointment day
+	eligibilityService := services.NewEligibilityService(repositories.NewEligibilityRepository(), patientRepo, appointmentRepo, config.Eligibility)
+	events.Subscribe(events.AppointmentBooked, eligibilityService.HandleAppointmentBooked)
+
+	// New and changed patient addresses are placed on the map for the catchment and distance reports
+	services.NewGeocodingService(patientRepo, config.Geocoding)
+
+	// New patients pre-register on a public form guarded by a captcha
+	registrationService := services.NewRegistrationService(repositories.NewRegistrationRepository(cache, patientRepo), config.Registration, config.Guarantors)
+	registrationHandler := handlers.NewRegistrationHandler(registrationService)
+	if registrationService.Enabled() {
+		controllers.SetupRegistrationFormRoutes(router, registrationHandler)
+	}
+
+	// The partner hospital sends ADT messages with its own keys; MLLP is served on its own port
+	hl7Service := services.NewHL7Service(repositories.NewHL7Repository(), patientService, config.HL7)
+	hl7Handler := handlers.NewHL7Handler(hl7Service)
+	if hl7Service.HTTPEnabled() {
+		controllers.SetupHL7ListenerRoutes(router, hl7Handler, config.HL7.APIKeys)
+	}
+
+	// The payment gateway reports how patients' online payments ended with signed callbacks
+	patientPortalService := services.NewPatientPortalService(repositories.NewOnlinePaymentRepository(), patientRepo, billingRepo, appointmentRepo, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), config.PaymentGateway)
+	patientPortalHandler := handlers.NewPatientPortalHandler(patientPortalService)
+	if patientPortalService.PaymentsEnabled() {
+		controllers.SetupPaymentCallbackRoutes(router, patientPortalHandler)
+	}
+
+	// Specialists download the imaging linked from referral letters through signed, expiring links
+	referralService := services.NewReferralService(repositories.NewReferralRepository(), patientRepo, doctorAppRepo, examinationRepo, imagingRepo, config.Referral)
+	referralHandler := handlers.NewReferralHandler(referralService)
+	if referralService.LinksEnabled() {
+		controllers.SetupReferralImagingRoutes(router, referralHandler)
+	}
 
-	// Create a Gin router
-	router := gin.Default()
+	// The desk printers' agents poll for print jobs with their own keys
+	printService := services.NewPrintService(repositories.NewPrintJobRepository(), patientRepo, billingRepo, appointmentRepo, patientQRService, config.Printing)
+	printJobHandler := handlers.NewPrintJobHandler(printService)
+	if printService.AgentsEnabled() {
+		controllers.SetupPrintAgentRoutes(router, printJobHandler, config.Printing.AgentKeys)
+	}
+
+	// Patients open the x-rays and invoices shared with them through signed, expiring links
+	attachmentRepo := repositories.NewAttachmentRepository(cache)
+	documentShareHandler := handlers.NewDocumentShareHandler(services.NewDocumentShareService(repositories.NewDocumentShareRepository(), patientRepo,
+		examinationRepo, attachmentRepo, imagingRepo, billingRepo, communicationService, patientQRService,
+		newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.DocumentShare))
+	controllers.SetupSharedDocumentRoutes(router, documentShareHandler)
+
+	// The clinic website lists doctors and services and sends appointment requests with its own keys
+	websiteService := services.NewWebsiteService(repositories.NewWebsiteRepository(), repositories.NewDoctorRepository(cache),
+		repositories.NewProcedureRepository(), appointmentRepo, config.Website)
+	websiteHandler := handlers.NewWebsiteHandler(websiteService)
+	if websiteService.Enabled() {
+		controllers.SetupWebsiteRoutes(router, websiteHandler, config.Website.APIKeys)
+	}
+
+	// The BI tool and the accountant read reports with scoped, read-only tokens instead of the bearer
+	// token. The group is created here so the bearer token is not required on it; the reports are
+	// registered once their handlers exist.
+	reportTokenService := services.NewReportTokenService(repositories.NewReportTokenRepository(), config.ReportTokens, clock.Default())
+	reportingGroup := controllers.NewReportingGroup(router, reportTokenService, config.CacheKeys.Branch)
+
+	// Small deployments manage the server from the admin console the API serves. Its page holds no
+	// data, so it is registered before the bearer token is required; the admin endpoints it calls
+	// still require it.
+	if config.Console.Enabled {
+		controllers.SetupConsoleRoutes(router, config.Console.ContentSecurityPolicy)
+	}
 
 	// Apply Bearer token validation to all routes
 	router.Use(middlewares.ValidateBearerToken(config.GetBearerToken()))
 
+	// Require a CSRF token on state-changing requests authenticated by cookies
+	router.Use(middlewares.CSRFMiddleware())
+
 	// Create and apply CORS middleware configuration
 	corsConfig := &middlewares.CorsConfig{
 		AllowedOrigins:   []string{"http://localhost:3000", "https://www.example.com", "https://example-dev.com"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-CSRF-Token"},
 		AllowCredentials: true,
 	}
 	router.Use(middlewares.CorsMiddleware(corsConfig))
@@ -43,36 +294,71 @@ func SetupRoutes(cache *cache.Cache, config *config.AppConfig, db *gorm.DB) http
 	// Apply logging middleware
 	router.Use(middlewares.LoggingMiddleware())
 
-	// Initialize repositories, services, and handlers
-	emergencyContactRepo := repositories.NewEmergencyContactRepository(cache)
-	billingRepo := repositories.NewBillingRepository(cache)
-	examinationRepo := repositories.NewExaminationRepository(cache)
-	treatmentPlanRepo := repositories.NewTreatmentPlanRepository(cache)
-	appointmentRepo := repositories.NewAppointmentRepository(cache)
+	// Record scrubbed request and response bodies while an Admin has debug logging switched on
+	debugLog := debuglog.New(config.DebugLog)
+	router.Use(middlewares.DebugLogMiddleware(debugLog))
 
-	patientRepo := repositories.NewPatientRepository(
-		cache,
-		emergencyContactRepo,
-		billingRepo,
-		examinationRepo,
-		treatmentPlanRepo,
-		appointmentRepo,
-	)
+	// Attribute created and updated records to the signed-in staff member
+	router.Use(middlewares.IdentifyUserMiddleware())
 
-	userRepo := repositories.NewUserRepository(db, cache)
+	// Every route registered from here on is authorized by its entry in the policy table
+	policies, err := config.Authorization.Policies()
+	if err != nil {
+		return nil, err
+	}
+	for i, policy := range policies {
+		// CPU and heap profiles are open in development and for Admins only elsewhere
+		if policy.Route == controllers.ProfilingPolicy && config.Profiling.Development() {
+			policies[i] = models.AuthPolicy{Route: policy.Route, Anonymous: true}
+		}
+	}
+	authorization, err := middlewares.NewAuthorization(policies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization policies: %w", err)
+	}
+	if err := authorization.Install(router); err != nil {
+		return nil, err
+	}
 
-	patientService := services.NewPatientService(patientRepo)
+	// Initialize services and handlers
 	userService := services.NewUserService(userRepo)
 
-	patientHandler := handlers.NewPatientHandler(patientService)
+	savedFilterService := services.NewSavedFilterService(repositories.NewSavedFilterRepository())
+	patientHandler := handlers.NewPatientHandler(patientService, savedFilterService)
 	authHandler := handlers.NewAuthHandler(userService)
-	doctorHandler := handlers.NewDoctorHandler(services.NewDoctorService(repositories.NewDoctorRepository(cache)))
-	insuranceCompanyHandler := handlers.NewInsuranceCompanyHandler(services.NewInsuranceCompanyService(repositories.NewInsuranceCompanyRepository(cache)))
-	emergencyContactHandler := handlers.NewEmergencyContactHandler(services.NewEmergencyContactService(emergencyContactRepo))
-	examinationHandler := handlers.NewExaminationHandler(services.NewExaminationService(examinationRepo))
-	billingHandler := handlers.NewBillingHandler(services.NewBillingService(billingRepo))
-	treatmentPlanHandler := handlers.NewTreatmentPlanHandler(services.NewTreatmentPlanService(treatmentPlanRepo))
-	appointmentHandler := handlers.NewAppointmentHandler(services.NewAppointmentService(appointmentRepo))
+	procedureRepo := repositories.NewProcedureRepository()
+	doctorRepo := repositories.NewDoctorRepository(cache)
+	doctorHandler := handlers.NewDoctorHandler(services.NewDoctorService(doctorRepo, procedureRepo))
+	insuranceCompanyRepo := repositories.NewInsuranceCompanyRepository(cache)
+	insuranceCompanyHandler := handlers.NewInsuranceCompanyHandler(services.NewInsuranceCompanyService(insuranceCompanyRepo))
+	emergencyContactService := services.NewEmergencyContactService(emergencyContactRepo, patientRepo, communicationService, newSMSNotifier(deliveryRetryService), config.DocumentShare.ClinicName, clock.Default())
+	emergencyContactHandler := handlers.NewEmergencyContactHandler(emergencyContactService)
+	templateService := services.NewClinicalTemplateService(repositories.NewClinicalTemplateRepository())
+	examinationService := services.NewExaminationService(examinationRepo, templateService)
+	examinationHandler := handlers.NewExaminationHandler(examinationService)
+	contractRateRepo := repositories.NewContractRateRepository()
+	billingHandler := handlers.NewBillingHandler(services.NewBillingService(billingRepo, contractRateRepo, procedureRepo, config.ChatWebhooks.LargeBalanceThreshold, approvalService, config.Approval.DiscountThreshold), savedFilterService)
+	treatmentPlanService := services.NewTreatmentPlanService(treatmentPlanRepo, templateService)
+	treatmentPlanHandler := handlers.NewTreatmentPlanHandler(treatmentPlanService)
+	chairRepo := repositories.NewChairRepository()
+	emergencySlotRepo := repositories.NewEmergencySlotRepository()
+	rosterRepo := repositories.NewRosterRepository()
+	settingService := services.NewSettingService(repositories.NewSettingRepository(), debugLog, config.DebugLog, config.Scheduling, config.AppointmentReminder)
+	// Doctors whose licence, indemnity insurance or CPD compliance expired cannot be booked
+	credentialService := services.NewCredentialService(repositories.NewCredentialRepository(), doctorRepo, newEmailNotifier(deliveryRetryService), config.Credentials)
+	appointmentService := services.NewAppointmentService(appointmentRepo, chairRepo, closureRepo, emergencySlotRepo, rosterRepo, settingService, customFieldService, credentialService, config.Scheduling)
+	events.Subscribe(events.AppointmentCancelled, appointmentService.HandleAppointmentCancelled)
+	appointmentHandler := handlers.NewAppointmentHandler(appointmentService, savedFilterService)
+
+	// Desk forms saved twice by a double click get the first save's response back
+	duplicateSubmissions := middlewares.DuplicateSubmissionMiddleware(services.NewSubmissionService(repositories.NewSubmissionRepository(cache), config.DuplicateSubmissions))
+
+	// The doctors list and the insurer and procedure catalogs are served from cache until they change
+	responseCacheService := services.NewResponseCacheService(repositories.NewResponseCacheRepository(cache), config.ResponseCache)
+	events.Subscribe(events.DoctorChanged, responseCacheService.InvalidateOn("doctors", "doctor_procedures"))
+	events.Subscribe(events.ProcedureChanged, responseCacheService.InvalidateOn("procedures", "doctors", "doctor_procedures"))
+	events.Subscribe(events.InsuranceCompanyChanged, responseCacheService.InvalidateOn("insurance_companies"))
+	responseCache := middlewares.NewResponseCache(responseCacheService)
 
 	// Register routes
 	controllers.SetupPatientRoutes(
@@ -85,12 +371,243 @@ func SetupRoutes(cache *cache.Cache, config *config.AppConfig, db *gorm.DB) http
 		billingHandler,
 		treatmentPlanHandler,
 		appointmentHandler,
+		duplicateSubmissions,
+		responseCache,
 	)
+	controllers.SetupExaminationFindingRoutes(router, examinationHandler)
 
-	authController := controllers.NewAuthController(authHandler)
+	authRateLimit := middlewares.AuthRateLimitMiddleware(services.NewAuthRateLimitService(repositories.NewAuthRateLimitRepository(), config.AuthRateLimits))
+	authController := controllers.NewAuthController(authHandler, authRateLimit)
 	authController.RegisterRoutes(router)
+	controllers.SetupApprovalRoutes(router, handlers.NewApprovalHandler(approvalService))
+	roleService := services.NewRoleService(repositories.NewRoleRepository(cache), userRepo)
+	// Billing routes check the permissions granted to the user's role on top of the role itself
+	middlewares.SetPermissionChecker(roleService)
+	controllers.SetupRoleRoutes(router, handlers.NewRoleHandler(roleService))
+	controllers.SetupUserActivityRoutes(router, handlers.NewUserActivityHandler(services.NewUserActivityService(userRepo, auditRepo, apiUsageRepo)))
+	controllers.SetupAPIUsageRoutes(router, handlers.NewAPIUsageHandler(apiUsageService))
+
+	controllers.SetupHL7ReviewRoutes(router, hl7Handler)
+	controllers.SetupImagingRoutes(router, imagingHandler)
+	controllers.SetupPrintJobRoutes(router, printJobHandler)
+	controllers.SetupPatientQRRoutes(router, handlers.NewPatientQRHandler(patientQRService))
+	controllers.SetupPatientHistoryRoutes(router, handlers.NewPatientHistoryHandler(services.NewPatientHistoryService(patientRepo, appointmentRepo, billingRepo, examinationRepo, treatmentPlanRepo)))
+	// Uploads are scanned before they are filed; flagged files are quarantined and the admins alerted
+	virusScanService := services.NewVirusScanService(scanner, repositories.NewQuarantineRepository(), userRepo, newEmailNotifier(deliveryRetryService), config.Audit.SecurityAlertRecipients, config.VirusScan, clock.Default())
+	controllers.SetupAttachmentRoutes(router, handlers.NewAttachmentHandler(services.NewAttachmentService(attachmentRepo, examinationRepo, files, virusScanService)))
+	controllers.SetupQuarantineRoutes(router, handlers.NewQuarantineHandler(virusScanService))
+	controllers.SetupAudioNoteRoutes(router, handlers.NewAudioNoteHandler(services.NewAudioNoteService(repositories.NewAudioNoteRepository(), examinationRepo, config.Transcription)))
+	caseImageRepo := repositories.NewCaseImageRepository()
+	controllers.SetupCaseImageRoutes(router, handlers.NewCaseImageHandler(services.NewCaseImageService(caseImageRepo, treatmentPlanRepo, files)))
+	controllers.SetupCaseExportRoutes(router, handlers.NewCaseExportHandler(services.NewCaseExportService(caseImageRepo, treatmentPlanRepo, patientRepo, approvalService, files, clock.Default())))
+	contractRateHandler := handlers.NewContractRateHandler(services.NewContractRateService(contractRateRepo, insuranceCompanyRepo, procedureRepo))
+	controllers.SetupContractRateRoutes(router, contractRateHandler)
+	controllers.SetupRegistrationReviewRoutes(router, registrationHandler)
+	controllers.SetupAppointmentRequestRoutes(router, websiteHandler)
+	controllers.SetupVerificationRoutes(router, handlers.NewVerificationHandler(verificationService))
+	controllers.SetupFinancialPeriodRoutes(router, handlers.NewFinancialPeriodHandler(services.NewFinancialPeriodService(repositories.NewFinancialPeriodRepository(), clock.Default())))
+	chairHandler := handlers.NewChairHandler(services.NewChairService(chairRepo))
+	controllers.SetupChairRoutes(router, chairHandler)
+	controllers.SetupClosureRoutes(router, handlers.NewClosureHandler(services.NewClosureService(closureRepo)))
+	controllers.SetupEmergencySlotRoutes(router, handlers.NewEmergencySlotHandler(services.NewEmergencySlotService(emergencySlotRepo)), appointmentHandler)
+	paymentPlanService := services.NewPaymentPlanService(repositories.NewPaymentPlanRepository(), patientRepo, treatmentPlanRepo, billingRepo, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), config.PaymentPlans, clock.Default())
+	controllers.SetupPaymentPlanRoutes(router, handlers.NewPaymentPlanHandler(paymentPlanService))
+	controllers.SetupTreatmentPackageRoutes(router, handlers.NewTreatmentPackageHandler(services.NewTreatmentPackageService(repositories.NewTreatmentPackageRepository(), patientRepo, procedureRepo, treatmentPlanService, paymentPlanService)))
+	controllers.SetupTreatmentCostRoutes(router, handlers.NewTreatmentCostHandler(services.NewTreatmentCostService(treatmentPlanRepo, patientRepo, procedureRepo, contractRateRepo, billingRepo)))
+	controllers.SetupClaimRoutes(router, handlers.NewClaimHandler(services.NewClaimService(repositories.NewClaimRepository(), billingRepo, insuranceCompanyRepo)))
+	// Insurers' claims officers see their company's claims and bills only, and each look-up is logged
+	controllers.SetupInsurerPortalRoutes(router, handlers.NewInsurerPortalHandler(services.NewInsurerPortalService(repositories.NewInsurerPortalRepository(), userRepo, insuranceCompanyRepo, clock.Default())))
+	controllers.SetupSterilizationRoutes(router, handlers.NewSterilizationHandler(services.NewSterilizationService(repositories.NewSterilizationRepository(), billingRepo)))
+	controllers.SetupMaterialRoutes(router, handlers.NewMaterialHandler(services.NewMaterialService(repositories.NewMaterialRepository(), billingRepo)))
+	controllers.SetupPrescriptionRoutes(router, handlers.NewPrescriptionHandler(services.NewPrescriptionService(repositories.NewPrescriptionRepository(), patientRepo, doctorAppRepo, auditService, config.ControlledSubstances)))
+	controllers.SetupProcedureRoutes(router, handlers.NewProcedureHandler(services.NewProcedureService(procedureRepo)), doctorHandler, responseCache)
+	controllers.SetupEditLockRoutes(router, handlers.NewEditLockHandler(services.NewEditLockService(repositories.NewEditLockRepository(), config.EditLocks)))
+	changeRepo := repositories.NewChangeRepository()
+	controllers.SetupChangeRoutes(router, handlers.NewChangeHandler(services.NewChangeService(changeRepo)))
+	// The offline-capable desktop app mirrors records and pushes back what it changed while offline
+	controllers.SetupSyncRoutes(router, handlers.NewSyncHandler(services.NewSyncService(changeRepo, patientService, appointmentService, examinationService, treatmentPlanService, emergencyContactService)))
+	controllers.SetupAuditRoutes(router, handlers.NewAuditHandler(auditService))
+	controllers.SetupAuditArchiveRoutes(router, handlers.NewAuditArchiveHandler(services.NewAuditArchiveService(repositories.NewAuditArchiveRepository(), config.AuditArchive)))
+	siemSink, err := siem.New(config.SIEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIEM forwarding: %w", err)
+	}
+	controllers.SetupSIEMRoutes(router, handlers.NewSIEMHandler(services.NewSIEMService(repositories.NewSIEMRepository(), siemSink, config.SIEM, clock.Default())))
+	controllers.SetupRetentionRoutes(router, handlers.NewRetentionHandler(services.NewRetentionService(repositories.NewRetentionRepository(examinationRepo), config.Retention, files)))
+	controllers.SetupSettingRoutes(router, handlers.NewSettingHandler(settingService))
+	controllers.SetupGreetingRoutes(router, handlers.NewGreetingHandler(services.NewGreetingService(repositories.NewGreetingRepository(), settingService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), config.Greeting)))
+	controllers.SetupContactUpdateRoutes(router, kioskHandler)
+	controllers.SetupCommunicationRoutes(router, communicationHandler)
+	visitRepo := repositories.NewVisitRepository(cache, patientRepo)
+	controllers.SetupQueueRoutes(
+		router,
+		handlers.NewQueueHandler(services.NewQueueService(appointmentRepo, eligibilityService)),
+		handlers.NewVisitHandler(services.NewVisitService(visitRepo, appointmentRepo, patientAlertRepo)),
+	)
+	controllers.SetupEligibilityRoutes(router, handlers.NewEligibilityHandler(eligibilityService))
+	// When a doctor calls in sick the front desk sees what needs rebooking and tells the patients at once
+	controllers.SetupScheduleImpactRoutes(router, handlers.NewScheduleImpactHandler(services.NewScheduleImpactService(repositories.NewScheduleImpactRepository(),
+		doctorRepo, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.DocumentShare.ClinicName)))
+	// Doctors and their accounts follow the staff directory, from CSV exports or the HR system
+	controllers.SetupDoctorImportRoutes(router, handlers.NewDoctorImportHandler(services.NewDoctorImportService(doctorRepo, config.StaffDirectory)))
+	controllers.SetupCredentialRoutes(router, handlers.NewCredentialHandler(credentialService))
+	controllers.SetupNoShowRoutes(router, handlers.NewNoShowHandler(services.NewNoShowService(appointmentRepo, config.NoShow)))
+	// Doctors open emergency access to patients who are not theirs, and the admins are alerted
+	breakGlassService := services.NewBreakGlassService(repositories.NewBreakGlassRepository(), patientRepo, userRepo, auditService, newEmailNotifier(deliveryRetryService), config.Audit.SecurityAlertRecipients, config.BreakGlass, clock.Default())
+	doctorAppHandler := handlers.NewDoctorAppHandler(services.NewDoctorAppService(doctorAppRepo, patientRepo, appointmentRepo, billingRepo, settingService, breakGlassService))
+	controllers.SetupDoctorAppRoutes(router, doctorAppHandler)
+	controllers.SetupBreakGlassRoutes(router, handlers.NewBreakGlassHandler(breakGlassService))
+	controllers.SetupClinicalAuditRoutes(router, handlers.NewClinicalAuditHandler(services.NewClinicalAuditService(repositories.NewClinicalAuditRepository(), appointmentRepo, doctorAppRepo, clock.Default())))
+	controllers.SetupPatientPortalRoutes(router, patientPortalHandler)
+	controllers.SetupMyAppointmentRoutes(router, doctorAppHandler, patientPortalHandler)
+	controllers.SetupRecordAccessRoutes(router, handlers.NewRecordAccessHandler(recordAccessService))
+	controllers.SetupCalendarLinkRoutes(router, calendarHandler)
+	controllers.SetupSurveyReportRoutes(router, surveyHandler)
+	analyticsHandler := handlers.NewAnalyticsHandler(services.NewAnalyticsService(repositories.NewAnalyticsRepository(), config.Analytics, config.Geocoding))
+	controllers.SetupAnalyticsRoutes(router, analyticsHandler)
+	staffActivityHandler := handlers.NewStaffActivityHandler(services.NewStaffActivityService(repositories.NewStaffActivityRepository()))
+	controllers.SetupStaffActivityRoutes(router, staffActivityHandler)
+
+	// The owner compares what each source and campaign, such as the Google Ads spend, brings in
+	marketingHandler := handlers.NewMarketingHandler(services.NewMarketingService(repositories.NewMarketingRepository()))
+	controllers.SetupMarketingRoutes(router, marketingHandler)
+	// Management is emailed the day's key figures at closing
+	dailySummaryHandler := handlers.NewDailySummaryHandler(services.NewDailySummaryService(repositories.NewDailySummaryRepository(), newEmailNotifier(deliveryRetryService), config.DailySummary, clock.Default()))
+	controllers.SetupDailySummaryRoutes(router, dailySummaryHandler)
+	controllers.SetupExportRoutes(router, handlers.NewExportHandler(services.NewExportService(repositories.NewExportRepository(), config.Accounting, config.ControlledSubstances, files)))
+	controllers.SetupIntegrityRoutes(router, handlers.NewIntegrityHandler(services.NewIntegrityService(repositories.NewIntegrityRepository(cache), config.Integrity)))
+	controllers.SetupReadOnlyRoutes(router, handlers.NewReadOnlyHandler(readOnlyService))
+	controllers.SetupDiagnosticsRoutes(router, handlers.NewDiagnosticsHandler(services.NewDiagnosticsService(repositories.NewDiagnosticsRepository(), schemaService)))
+	// Redis is cleaned of the keys nothing else removes on a schedule, or from the admin console
+	cacheMaintenanceService := services.NewCacheMaintenanceService(repositories.NewCacheMaintenanceRepository(), config.CacheMaintenance, clock.Default())
+	controllers.SetupOperationsRoutes(router, handlers.NewOperationsHandler(services.NewOperationsService(cache, cacheMaintenanceService, repositories.NewJobQueueRepository(), clock.Default())))
+	controllers.SetupEmailDeliveryRoutes(router, handlers.NewEmailDeliveryHandler(emailDeliveryService))
+	controllers.SetupFailedDeliveryRoutes(router, handlers.NewFailedDeliveryHandler(deliveryRetryService))
+	// Patients confirm or cancel by answering their reminder texts; other replies go to the staff inbox
+	controllers.SetupSMSReplyRoutes(router, handlers.NewSMSReplyHandler(services.NewSMSReplyService(repositories.NewSMSReplyRepository(), repositories.NewAppointmentRepository(cache), config.SMSReplies, clock.Default())))
+	// CPU and heap profiles, when enabled
+	if config.Profiling.Development() || config.Profiling.Enabled {
+		controllers.SetupProfilingRoutes(router)
+	}
+	taskService := services.NewTaskService(repositories.NewTaskRepository(), newEmailNotifier(deliveryRetryService))
+	controllers.SetupTaskRoutes(router, handlers.NewTaskHandler(taskService))
+	controllers.SetupClinicalTemplateRoutes(router, handlers.NewClinicalTemplateHandler(templateService))
+
+	// Accepted treatment with no appointment booked is reported for the desk to call the patients
+	treatmentPlanItemService := services.NewTreatmentPlanItemService(repositories.NewTreatmentPlanItemRepository(), treatmentPlanRepo, procedureRepo, taskService, clock.Default())
+	controllers.SetupTreatmentPlanItemRoutes(router, handlers.NewTreatmentPlanItemHandler(treatmentPlanItemService))
+
+	// Clinicians hand over to the next shift and go through the day at the morning huddle
+	controllers.SetupHandoverRoutes(router, handlers.NewHandoverHandler(services.NewHandoverService(repositories.NewHandoverRepository(), doctorAppRepo, patientRepo, appointmentRepo, clock.Default())))
+
+	// Care pathway rules follow up created bills and fulfilled appointments
+	careRuleRepo := repositories.NewCareRuleRepository()
+	careRuleService := services.NewCareRuleService(careRuleRepo, procedureRepo, taskService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), clock.Default())
+	events.Subscribe(events.BillingCreated, careRuleService.HandleEvent)
+	events.Subscribe(events.AppointmentFulfilled, careRuleService.HandleEvent)
+	controllers.SetupCareRuleRoutes(router, handlers.NewCareRuleHandler(careRuleService))
+
+	// Post-operative instructions go out to patients once their procedure is done
+	postOpService := services.NewPostOpService(repositories.NewPostOpRepository(), procedureRepo, careRuleRepo, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.PostOp)
+	events.Subscribe(events.AppointmentFulfilled, postOpService.HandleAppointmentFulfilled)
+	controllers.SetupPostOpRoutes(router, handlers.NewPostOpHandler(postOpService))
+
+	controllers.SetupCustomFieldRoutes(router, handlers.NewCustomFieldHandler(customFieldService))
+	controllers.SetupSavedFilterRoutes(router, handlers.NewSavedFilterHandler(savedFilterService))
+
+	billingDisputeService := services.NewBillingDisputeService(repositories.NewBillingDisputeRepository(), billingRepo)
+	billingDisputeHandler := handlers.NewBillingDisputeHandler(billingDisputeService)
+	controllers.SetupBillingDisputeRoutes(router, billingDisputeHandler)
+
+	// Statements go out as bills reach each stage of the dunning schedule
+	dunningService := services.NewDunningService(repositories.NewDunningRepository(), billingRepo, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.Dunning, clock.Default())
+	controllers.SetupDunningRoutes(router, handlers.NewDunningHandler(dunningService))
+	// Campaigns go out to their segment of patients a batch at a time once scheduled
+	campaignService := services.NewCampaignService(repositories.NewCampaignRepository(), savedFilterService, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.DocumentShare.ClinicName, config.Campaigns, clock.Default())
+	controllers.SetupCampaignRoutes(router, handlers.NewCampaignHandler(campaignService))
+	// Reminders go out ahead of appointments, to the guardian of patients who are minors
+	appointmentReminderService := services.NewAppointmentReminderService(repositories.NewAppointmentReminderRepository(), appointmentRepo, settingService, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), newPatientSMSNotifier(communicationService, quietHoursService, deliveryRetryService), config.AppointmentReminder, config.Guarantors, config.SMSReplies, clock.Default())
+	controllers.SetupAppointmentReminderRoutes(router, handlers.NewAppointmentReminderHandler(appointmentReminderService))
+
+	controllers.SetupPatientAlertRoutes(router, handlers.NewPatientAlertHandler(services.NewPatientAlertService(patientAlertRepo, patientRepo)))
+	controllers.SetupHouseholdRoutes(router, handlers.NewHouseholdHandler(services.NewHouseholdService(repositories.NewHouseholdRepository(), patientRepo, billingRepo)))
+
+	// Patients can be emailed a summary of their visit once they are checked out
+	visitSummaryService := services.NewVisitSummaryService(visitRepo, appointmentRepo, patientRepo, communicationService, newPatientEmailNotifier(communicationService, emailDeliveryService, quietHoursService, deliveryRetryService), config.VisitSummary)
+	if config.VisitSummary.EmailOnCheckout {
+		events.Subscribe(events.AppointmentFulfilled, visitSummaryService.HandleAppointmentFulfilled)
+	}
+	controllers.SetupVisitSummaryRoutes(router, handlers.NewVisitSummaryHandler(visitSummaryService))
+	controllers.SetupPatientChartRoutes(router, handlers.NewPatientChartHandler(services.NewPatientChartService(repositories.NewPatientChartRepository(), patientRepo, config.DocumentShare.ClinicName, clock.Default())))
+
+	controllers.SetupReferralRoutes(router, referralHandler)
+	controllers.SetupDocumentShareRoutes(router, documentShareHandler)
+
+	controllers.SetupRosterRoutes(router, handlers.NewRosterHandler(services.NewRosterService(rosterRepo)))
+
+	payrollService := services.NewPayrollService(repositories.NewPayrollRepository(), rosterRepo, doctorAppRepo, approvalService, config.Payroll)
+	controllers.SetupPayrollRoutes(router, handlers.NewPayrollHandler(payrollService))
+
+	controllers.SetupReportTokenRoutes(router, handlers.NewReportTokenHandler(reportTokenService))
+	controllers.SetupReportingRoutes(reportingGroup, analyticsHandler, contractRateHandler, billingDisputeHandler, chairHandler,
+		appointmentHandler, doctorHandler, staffActivityHandler, surveyHandler, marketingHandler, dailySummaryHandler)
 
 	controllers.SetupRootRoute(router)
+	controllers.SetupPolicyRoutes(router, handlers.NewPolicyHandler(authorization))
+
+	// A route the policy table does not cover would be refused to everyone, so it keeps the API from starting
+	if err := authorization.Check(router.Routes()); err != nil {
+		return nil, err
+	}
 
-	return router
+	return router, nil
+}
+
+// newEmailNotifier sends emails over SMTP, trying those the server fails again with retries, and
+// logs them when SMTP is not configured.
+func newEmailNotifier(retries *services.DeliveryRetryService) notifications.Notifier {
+	notifier, err := notifications.NewEmailNotifierFromEnv()
+	if err != nil {
+		log.Printf("Notifications will only be logged: %v", err)
+		return notifications.LogNotifier{Printf: log.Printf}
+	}
+	return retries.Notifier(notifications.TransportEmail, notifier)
+}
+
+// newPatientEmailNotifier emails patients only the messages their communication preferences allow,
+// and never to addresses that bounced permanently or complained. Emails are held during quiet hours
+// when email is a quiet channel.
+func newPatientEmailNotifier(preferences notifications.PreferenceChecker, suppressions notifications.SuppressionChecker, quietHours *services.QuietHoursService, retries *services.DeliveryRetryService) notifications.Notifier {
+	next := notifications.SuppressingNotifier{Next: newEmailNotifier(retries), Suppressions: suppressions}
+	return quietHours.Notifier(notifications.ChannelEmail, notifications.PatientNotifier{Next: next, Channel: notifications.ChannelEmail, Preferences: preferences})
+}
+
+// newPatientSMSNotifier texts patients only the messages their communication preferences allow,
+// outside quiet hours, and logs the messages when no SMS gateway is configured.
+func newPatientSMSNotifier(preferences notifications.PreferenceChecker, quietHours *services.QuietHoursService, retries *services.DeliveryRetryService) notifications.Notifier {
+	return quietHours.Notifier(notifications.ChannelSMS, notifications.PatientNotifier{Next: newSMSNotifier(retries), Channel: notifications.ChannelSMS, Preferences: preferences})
+}
+
+// newSMSNotifier sends text messages through the SMS gateway, trying those it fails again with
+// retries, and logs them when none is configured.
+func newSMSNotifier(retries *services.DeliveryRetryService) notifications.Notifier {
+	notifier, err := notifications.NewSMSNotifierFromEnv()
+	if err != nil {
+		log.Printf("Text messages will only be logged: %v", err)
+		return notifications.LogNotifier{Printf: log.Printf}
+	}
+	return retries.Notifier(notifications.TransportSMS, notifier)
+}
+
+// newSecurityNotifier emails security alerts when SMTP and recipients are configured, and logs them otherwise.
+func newSecurityNotifier(cfg config.AuditConfig, retries *services.DeliveryRetryService) notifications.Notifier {
+	if len(cfg.SecurityAlertRecipients) == 0 {
+		return notifications.LogNotifier{Printf: log.Printf}
+	}
+	notifier, err := notifications.NewEmailNotifierFromEnv()
+	if err != nil {
+		log.Printf("Security alerts will only be logged: %v", err)
+		return notifications.LogNotifier{Printf: log.Printf}
+	}
+	return retries.Notifier(notifications.TransportEmail, notifier)
 }
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Kinds of accounting transactions posted from a bill
+const (
+	postingInvoice    = "invoice"
+	postingCreditNote = "credit_note"
+	postingPayment    = "payment"
+	postingRefund     = "refund"
+)
+
+// iifTransactionTypes are the QuickBooks transaction types of each posting kind
+var iifTransactionTypes = map[string]string{
+	postingInvoice:    "INVOICE",
+	postingCreditNote: "CREDIT MEMO",
+	postingPayment:    "PAYMENT",
+	postingRefund:     "CHECK",
+}
+
+// posting is a balanced two-line accounting transaction
+type posting struct {
+	Kind     string
+	Entry    models.AccountingEntry
+	Debit    string
+	Credit   string
+	Amount   float64
+	Memo     string
+	OnCredit bool // QuickBooks heads the transaction with the credited account, e.g. the bank on a refund cheque
+}
+
+// AccountingExporter turns billing into files the practice's accounting package imports.
+//
+// Billing keeps no payment history, so each bill is posted as of its creation date: the billed
+// amount as an invoice, and the cash and insurance amounts received as payments. Negative amounts
+// are corrections and are posted as a credit note or a refund. Bills of closed periods are posted
+// as they were closed, and adjustments to them are posted the same way on the date they were made.
+type AccountingExporter struct {
+	repository *repositories.ExportRepository
+	config     config.AccountingConfig
+}
+
+// QuickBooksIIF exports the period as a QuickBooks Desktop IIF file
+func (e *AccountingExporter) QuickBooksIIF(ctx context.Context, job models.ExportJob) (*ExportFile, error) {
+	postings, err := e.postings(ctx, job, e.config.QuickBooks)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("!TRNS\tTRNSTYPE\tDATE\tACCNT\tNAME\tAMOUNT\tDOCNUM\tMEMO\r\n")
+	buf.WriteString("!SPL\tTRNSTYPE\tDATE\tACCNT\tNAME\tAMOUNT\tDOCNUM\tMEMO\r\n")
+	buf.WriteString("!ENDTRNS\r\n")
+	for _, p := range postings {
+		head, headAmount, split := p.Debit, p.Amount, p.Credit
+		if p.OnCredit {
+			head, headAmount, split = p.Credit, -p.Amount, p.Debit
+		}
+		trnsType := iifTransactionTypes[p.Kind]
+		date := p.Entry.CreatedAt.Format("01/02/2006")
+		name := iifField(p.Entry.PatientName)
+		memo := iifField(p.Memo)
+		fmt.Fprintf(&buf, "TRNS\t%s\t%s\t%s\t%s\t%.2f\t%s\t%s\r\n", trnsType, date, iifField(head), name, headAmount, p.Entry.BillingID, memo)
+		fmt.Fprintf(&buf, "SPL\t%s\t%s\t%s\t%s\t%.2f\t%s\t%s\r\n", trnsType, date, iifField(split), name, -headAmount, p.Entry.BillingID, memo)
+		buf.WriteString("ENDTRNS\r\n")
+	}
+
+	return &ExportFile{
+		Name:        exportFileName(job, "iif"),
+		ContentType: "text/plain; charset=utf-8",
+		Content:     buf.Bytes(),
+	}, nil
+}
+
+// XeroCSV exports the period as Xero manual journals, one balanced journal per transaction
+func (e *AccountingExporter) XeroCSV(ctx context.Context, job models.ExportJob) (*ExportFile, error) {
+	postings, err := e.postings(ctx, job, e.config.Xero)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount"}); err != nil {
+		return nil, err
+	}
+	for _, p := range postings {
+		narration := fmt.Sprintf("%s %s - %s", p.Entry.BillingID, strings.ReplaceAll(p.Kind, "_", " "), p.Entry.PatientName)
+		date := p.Entry.CreatedAt.Format(e.config.XeroDateFormat)
+		lines := [][]string{
+			{narration, date, p.Memo, p.Debit, e.config.XeroTaxRate, fmt.Sprintf("%.2f", p.Amount)},
+			{narration, date, p.Memo, p.Credit, e.config.XeroTaxRate, fmt.Sprintf("%.2f", -p.Amount)},
+		}
+		if err := w.WriteAll(lines); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write Xero CSV: %w", err)
+	}
+
+	return &ExportFile{
+		Name:        exportFileName(job, "csv"),
+		ContentType: "text/csv; charset=utf-8",
+		Content:     buf.Bytes(),
+	}, nil
+}
+
+// postings maps the bills of the job's period to transactions on accounts
+func (e *AccountingExporter) postings(ctx context.Context, job models.ExportJob, accounts config.AccountCodes) ([]posting, error) {
+	entries, err := e.repository.GetAccountingEntries(ctx, job.From, job.To.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := e.repository.GetAccountingAdjustments(ctx, job.From, job.To.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	for _, adjustment := range adjustments {
+		adjustment.Procedure = fmt.Sprintf("Adjustment to %s: %s", adjustment.Procedure, adjustment.AdjustmentReason)
+		entries = append(entries, adjustment)
+	}
+
+	var postings []posting
+	for _, entry := range entries {
+		switch {
+		case entry.BillingAmount > 0:
+			postings = append(postings, posting{Kind: postingInvoice, Entry: entry, Debit: accounts.Receivable, Credit: accounts.Income,
+				Amount: entry.BillingAmount, Memo: entry.Procedure})
+		case entry.BillingAmount < 0:
+			postings = append(postings, posting{Kind: postingCreditNote, Entry: entry, Debit: accounts.Income, Credit: accounts.Receivable,
+				Amount: -entry.BillingAmount, Memo: entry.Procedure, OnCredit: true})
+		}
+		postings = appendPayment(postings, entry, entry.PaidCashAmount, accounts.Cash, accounts, "Cash")
+		postings = appendPayment(postings, entry, entry.PaidInsuranceAmount, accounts.Insurance, accounts, "Insurance")
+	}
+	return postings, nil
+}
+
+// appendPayment posts an amount received into deposit, or refunded out of it when negative
+func appendPayment(postings []posting, entry models.AccountingEntry, amount float64, deposit string, accounts config.AccountCodes, source string) []posting {
+	amount = math.Round(amount*100) / 100
+	switch {
+	case amount > 0:
+		return append(postings, posting{Kind: postingPayment, Entry: entry, Debit: deposit, Credit: accounts.Receivable,
+			Amount: amount, Memo: source + " payment for " + entry.Procedure})
+	case amount < 0:
+		return append(postings, posting{Kind: postingRefund, Entry: entry, Debit: accounts.Refunds, Credit: deposit,
+			Amount: -amount, Memo: source + " refund for " + entry.Procedure, OnCredit: true})
+	}
+	return postings
+}
+
+// iifField keeps tabs and line breaks in free text from breaking the IIF columns
+func iifField(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+func exportFileName(job models.ExportJob, extension string) string {
+	return fmt.Sprintf("%s-%s-%s.%s", job.Type, job.From.Format("2006-01-02"), job.To.Format("2006-01-02"), extension)
+}
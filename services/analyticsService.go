@@ -0,0 +1,339 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/geocoding"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxAnalyticsRange caps the period a single analytics report covers.
+const maxAnalyticsRange = 366 * 24 * time.Hour
+
+// dateOfBirthLayouts are the formats date_of_birth has been entered in
+var dateOfBirthLayouts = []string{"2006-01-02", "02/01/2006", "2006/01/02", "02-01-2006"}
+
+type AnalyticsService struct {
+	repository *repositories.AnalyticsRepository
+	config     config.AnalyticsConfig
+	geocoding  config.GeocodingConfig
+}
+
+// NewAnalyticsService starts the nightly aggregation job when it is enabled. Distances are measured
+// from the clinic's location in geocodingCfg.
+func NewAnalyticsService(repository *repositories.AnalyticsRepository, cfg config.AnalyticsConfig, geocodingCfg config.GeocodingConfig) *AnalyticsService {
+	s := &AnalyticsService{repository: repository, config: cfg, geocoding: geocodingCfg}
+	if cfg.Enabled {
+		go s.run()
+	}
+	return s
+}
+
+func (s *AnalyticsService) run() {
+	for {
+		time.Sleep(time.Until(nextRunAt(models.ClinicNow(), s.config.RunHour)))
+		if !database.IsLeader() {
+			continue
+		}
+
+		today := startOfDay(models.ClinicNow())
+		for i := s.config.RecomputeDays; i >= 1; i-- {
+			day := today.AddDate(0, 0, -i)
+			if err := s.AggregateDay(context.Background(), day); err != nil {
+				log.Printf("Failed to aggregate analytics for %s: %v", day.Format("2006-01-02"), err)
+			}
+		}
+	}
+}
+
+// AggregateDay rebuilds the anonymized aggregates of one day
+func (s *AnalyticsService) AggregateDay(ctx context.Context, day time.Time) error {
+	day = startOfDay(day)
+
+	procedures, err := s.repository.GetProcedureTotals(ctx, day)
+	if err != nil {
+		return err
+	}
+	visits, err := s.repository.GetVisits(ctx, day)
+	if err != nil {
+		return err
+	}
+	attendance, err := s.repository.GetStatusCounts(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	ageBands := map[string]*models.AnalyticsBucket{}
+	insurers := map[string]*models.AnalyticsBucket{}
+	counties := map[string]*models.AnalyticsBucket{}
+	for _, visit := range visits {
+		countBucket(ageBands, ageBand(visit.DateOfBirth, day))
+		countBucket(counties, catchmentArea(visit.County, visit.City))
+		insurer := "Cash"
+		if visit.Insured {
+			insurer = strings.TrimSpace(visit.InsuranceCompany)
+			if insurer == "" {
+				insurer = "Unknown insurer"
+			}
+		}
+		countBucket(insurers, insurer)
+	}
+
+	var aggregates []models.AnalyticsAggregate
+	add := func(dimension string, buckets []models.AnalyticsBucket) {
+		for _, b := range buckets {
+			aggregates = append(aggregates, models.AnalyticsAggregate{Day: day, Dimension: dimension, Bucket: b.Bucket, Count: b.Count, Amount: b.Amount})
+		}
+	}
+	add(models.AnalyticsDimensionProcedure, s.suppressSmallCells(procedures))
+	add(models.AnalyticsDimensionAgeBand, s.suppressSmallCells(bucketValues(ageBands)))
+	add(models.AnalyticsDimensionInsurer, s.suppressSmallCells(bucketValues(insurers)))
+	add(models.AnalyticsDimensionCounty, s.suppressSmallCells(bucketValues(counties)))
+	// Attendance counts carry no patient attributes, so they are kept exact
+	add(models.AnalyticsDimensionAttendance, attendance)
+
+	if s.geocoding.ClinicLocated() {
+		bookings, err := s.repository.GetBookings(ctx, day)
+		if err != nil {
+			return err
+		}
+		booked, noShows := s.distanceBuckets(bookings)
+		add(models.AnalyticsDimensionBookedDistance, booked)
+		add(models.AnalyticsDimensionNoShowDistance, noShows)
+	}
+
+	return s.repository.ReplaceDay(ctx, day, aggregates)
+}
+
+// Report sums the aggregates of the days in [from, to]
+func (s *AnalyticsService) Report(ctx context.Context, from, to time.Time) (*models.AnalyticsReport, error) {
+	if to.Before(from) {
+		return nil, errors.New("to must not be before from")
+	}
+	if to.Sub(from) > maxAnalyticsRange {
+		return nil, errors.New("the report period may not exceed one year")
+	}
+
+	totals, err := s.repository.GetTotals(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.AnalyticsReport{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		Procedures: []models.AnalyticsBucket{},
+		AgeBands:   []models.AnalyticsBucket{},
+		Insurers:   []models.AnalyticsBucket{},
+		Attendance: []models.AnalyticsBucket{},
+		Counties:   []models.AnalyticsBucket{},
+	}
+	distances := map[string]*models.DistanceNoShows{}
+	distance := func(band string) *models.DistanceNoShows {
+		if distances[band] == nil {
+			distances[band] = &models.DistanceNoShows{Band: band}
+		}
+		return distances[band]
+	}
+	var booked, noShows int64
+	for _, t := range totals {
+		bucket := models.AnalyticsBucket{Bucket: t.Bucket, Count: t.Count, Amount: t.Amount}
+		switch t.Dimension {
+		case models.AnalyticsDimensionProcedure:
+			report.Procedures = append(report.Procedures, bucket)
+		case models.AnalyticsDimensionAgeBand:
+			report.AgeBands = append(report.AgeBands, bucket)
+		case models.AnalyticsDimensionInsurer:
+			report.Insurers = append(report.Insurers, bucket)
+		case models.AnalyticsDimensionAttendance:
+			report.Attendance = append(report.Attendance, bucket)
+			if t.Bucket != models.AttendanceCancelled {
+				booked += t.Count
+			}
+			if t.Bucket == models.AttendanceNoShow {
+				noShows = t.Count
+			}
+		case models.AnalyticsDimensionCounty:
+			report.Counties = append(report.Counties, bucket)
+		case models.AnalyticsDimensionBookedDistance:
+			distance(t.Bucket).Booked = t.Count
+		case models.AnalyticsDimensionNoShowDistance:
+			distance(t.Bucket).NoShows = t.Count
+		}
+	}
+	if booked > 0 {
+		report.NoShowRate = float64(noShows) / float64(booked)
+	}
+	report.NoShowsByDistance = distanceReport(distances)
+	return report, nil
+}
+
+// distanceBuckets counts the bookings and no-shows per distance band of where patients live. A band
+// with too few bookings is merged into "Other" in both counts, so its no-show rate stays meaningful.
+func (s *AnalyticsService) distanceBuckets(bookings []repositories.AnalyticsBooking) (booked, noShows []models.AnalyticsBucket) {
+	clinic := geocoding.Coordinates{Latitude: s.geocoding.ClinicLatitude, Longitude: s.geocoding.ClinicLongitude}
+	bookedByBand := map[string]*models.AnalyticsBucket{}
+	noShowsByBand := map[string]*models.AnalyticsBucket{}
+	for _, booking := range bookings {
+		band := models.AnalyticsUnknownBucket
+		if booking.Latitude != nil && booking.Longitude != nil {
+			band = models.DistanceBandOf(geocoding.DistanceKM(clinic, geocoding.Coordinates{Latitude: *booking.Latitude, Longitude: *booking.Longitude}))
+		}
+		countBucket(bookedByBand, band)
+		if booking.Attendance == models.AttendanceNoShow {
+			countBucket(noShowsByBand, band)
+		}
+	}
+
+	var otherBooked, otherNoShows int64
+	for band, b := range bookedByBand {
+		if b.Count >= int64(s.config.MinCellSize) {
+			continue
+		}
+		otherBooked += b.Count
+		if n, ok := noShowsByBand[band]; ok {
+			otherNoShows += n.Count
+			delete(noShowsByBand, band)
+		}
+		delete(bookedByBand, band)
+	}
+	if otherBooked > 0 {
+		bookedByBand[models.AnalyticsOtherBucket] = &models.AnalyticsBucket{Bucket: models.AnalyticsOtherBucket, Count: otherBooked}
+	}
+	if otherNoShows > 0 {
+		noShowsByBand[models.AnalyticsOtherBucket] = &models.AnalyticsBucket{Bucket: models.AnalyticsOtherBucket, Count: otherNoShows}
+	}
+	return bucketValues(bookedByBand), bucketValues(noShowsByBand)
+}
+
+// distanceReport lists the no-shows per distance band, nearest first
+func distanceReport(distances map[string]*models.DistanceNoShows) []models.DistanceNoShows {
+	order := []string{}
+	for _, band := range models.DistanceBands {
+		order = append(order, band.Name)
+	}
+	order = append(order, models.AnalyticsUnknownBucket, models.AnalyticsOtherBucket)
+
+	report := []models.DistanceNoShows{}
+	for _, band := range order {
+		d, ok := distances[band]
+		if !ok {
+			continue
+		}
+		if d.Booked > 0 {
+			d.NoShowRate = float64(d.NoShows) / float64(d.Booked)
+		}
+		report = append(report, *d)
+	}
+	return report
+}
+
+// catchmentArea returns where a patient is counted in the catchment report: their county, or
+// else their city
+func catchmentArea(county, city string) string {
+	if county = strings.TrimSpace(county); county != "" {
+		return county
+	}
+	if city = strings.TrimSpace(city); city != "" {
+		return city
+	}
+	return models.AnalyticsUnknownBucket
+}
+
+// suppressSmallCells merges buckets below the minimum cell size into "Other"
+func (s *AnalyticsService) suppressSmallCells(buckets []models.AnalyticsBucket) []models.AnalyticsBucket {
+	var kept []models.AnalyticsBucket
+	other := models.AnalyticsBucket{Bucket: models.AnalyticsOtherBucket}
+	for _, b := range buckets {
+		if b.Count < int64(s.config.MinCellSize) || b.Bucket == models.AnalyticsOtherBucket {
+			other.Count += b.Count
+			other.Amount += b.Amount
+			continue
+		}
+		kept = append(kept, b)
+	}
+	if other.Count > 0 {
+		kept = append(kept, other)
+	}
+	return kept
+}
+
+func countBucket(buckets map[string]*models.AnalyticsBucket, name string) {
+	if b, ok := buckets[name]; ok {
+		b.Count++
+		return
+	}
+	buckets[name] = &models.AnalyticsBucket{Bucket: name, Count: 1}
+}
+
+func bucketValues(buckets map[string]*models.AnalyticsBucket) []models.AnalyticsBucket {
+	values := make([]models.AnalyticsBucket, 0, len(buckets))
+	for _, b := range buckets {
+		values = append(values, *b)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Bucket < values[j].Bucket })
+	return values
+}
+
+// ageBand returns the age band of someone born on dateOfBirth as of day
+func ageBand(dateOfBirth string, day time.Time) string {
+	born, ok := parseDateOfBirth(dateOfBirth)
+	if !ok {
+		return "Unknown"
+	}
+	switch age := ageOn(born, day); {
+	case age < 0:
+		return "Unknown"
+	case age < 18:
+		return "0-17"
+	case age < 35:
+		return "18-34"
+	case age < 50:
+		return "35-49"
+	case age < 65:
+		return "50-64"
+	default:
+		return "65+"
+	}
+}
+
+// parseDateOfBirth parses a date of birth in any of the layouts it is stored in
+func parseDateOfBirth(dateOfBirth string) (time.Time, bool) {
+	for _, layout := range dateOfBirthLayouts {
+		if born, err := time.Parse(layout, strings.TrimSpace(dateOfBirth)); err == nil {
+			return born, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ageOn returns the age in years of someone born on born as of day
+func ageOn(born, day time.Time) int {
+	age := day.Year() - born.Year()
+	if day.Month() < born.Month() || (day.Month() == born.Month() && day.Day() < born.Day()) {
+		age--
+	}
+	return age
+}
+
+func startOfDay(t time.Time) time.Time {
+	start, _ := models.ClinicDay(t)
+	return start
+}
+
+// nextRunAt returns the next time after now at the given hour of the clinic day
+func nextRunAt(now time.Time, hour int) time.Time {
+	day := startOfDay(now)
+	next := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+	if !next.After(now) {
+		next = time.Date(day.Year(), day.Month(), day.Day()+1, hour, 0, 0, 0, day.Location())
+	}
+	return next
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// apiUsageDefaultHours is the period reported when none is given, the last day
+	apiUsageDefaultHours = 24
+	// apiUsageMaxDays caps the period of a report
+	apiUsageMaxDays = 92
+)
+
+var ErrInvalidAPIUsageQuery = errors.New("invalid API usage query")
+
+// apiUsageKey identifies a count kept in memory
+type apiUsageKey struct {
+	userID int64
+	day    string
+	method string
+	route  string
+}
+
+// apiUsageBucketKey identifies an hourly count kept in memory
+type apiUsageBucketKey struct {
+	consumer string
+	hour     time.Time
+	method   string
+	route    string
+}
+
+// APIUsageService counts each signed-in user's requests by route and clinic day for the access
+// reviews, and every consumer's requests, errors and bytes by route and hour for the usage analytics.
+// Counts are kept in memory and added to the database every config.FlushInterval, so counting never
+// slows a request. After each flush, consumers over their soft hourly quota are warned about once an
+// hour; their requests are still served.
+type APIUsageService struct {
+	repository *repositories.APIUsageRepository
+	config     config.APIUsageConfig
+	mu         sync.Mutex
+	counts     map[apiUsageKey]*models.APIUsage
+	buckets    map[apiUsageBucketKey]*models.APIUsageBucket
+	warned     map[string]time.Time // Hour each consumer was last warned about
+}
+
+// NewAPIUsageService starts flushing the counts when a flush interval is set
+func NewAPIUsageService(repository *repositories.APIUsageRepository, cfg config.APIUsageConfig) *APIUsageService {
+	s := &APIUsageService{
+		repository: repository,
+		config:     cfg,
+		counts:     make(map[apiUsageKey]*models.APIUsage),
+		buckets:    make(map[apiUsageBucketKey]*models.APIUsageBucket),
+		warned:     make(map[string]time.Time),
+	}
+	if s.Enabled() {
+		go s.run()
+	}
+	return s
+}
+
+// Enabled reports whether requests are counted
+func (s *APIUsageService) Enabled() bool {
+	return s.config.FlushInterval > 0
+}
+
+// RecordAPIUsage counts an answered request
+func (s *APIUsageService) RecordAPIUsage(request models.APIRequest) {
+	failed := request.Status >= 400
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request.UserID != 0 {
+		key := apiUsageKey{userID: request.UserID, day: request.At.In(models.ClinicLocation()).Format("2006-01-02"), method: request.Method, route: request.Route}
+		usage, ok := s.counts[key]
+		if !ok {
+			usage = &models.APIUsage{UserID: request.UserID, Day: key.day, Method: request.Method, Route: request.Route}
+			s.counts[key] = usage
+		}
+		usage.Requests++
+		if failed {
+			usage.Errors++
+		}
+	}
+
+	key := apiUsageBucketKey{consumer: request.Consumer, hour: request.At.UTC().Truncate(time.Hour), method: request.Method, route: request.Route}
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &models.APIUsageBucket{Consumer: key.consumer, Hour: key.hour, Method: key.method, Route: key.route}
+		s.buckets[key] = bucket
+	}
+	bucket.Requests++
+	if failed {
+		bucket.Errors++
+	}
+	bucket.RequestBytes += request.RequestBytes
+	bucket.ResponseBytes += request.ResponseBytes
+}
+
+func (s *APIUsageService) run() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.flush(context.Background())
+	}
+}
+
+// flush adds the counts kept since the last flush to the database, keeping them for the next
+// flush when they cannot be written, then warns about the consumers over their quota
+func (s *APIUsageService) flush(ctx context.Context) {
+	s.mu.Lock()
+	counts, buckets := s.counts, s.buckets
+	s.counts = make(map[apiUsageKey]*models.APIUsage)
+	s.buckets = make(map[apiUsageBucketKey]*models.APIUsageBucket)
+	s.mu.Unlock()
+
+	if len(counts) > 0 {
+		usage := make([]models.APIUsage, 0, len(counts))
+		for _, count := range counts {
+			usage = append(usage, *count)
+		}
+		if err := s.repository.Add(ctx, usage); err != nil {
+			log.Printf("Failed to flush API usage: %v", err)
+			s.mu.Lock()
+			for key, count := range counts {
+				if current, ok := s.counts[key]; ok {
+					current.Requests += count.Requests
+					current.Errors += count.Errors
+				} else {
+					s.counts[key] = count
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	if len(buckets) > 0 {
+		rows := make([]models.APIUsageBucket, 0, len(buckets))
+		for _, bucket := range buckets {
+			rows = append(rows, *bucket)
+		}
+		if err := s.repository.AddBuckets(ctx, rows); err != nil {
+			log.Printf("Failed to flush API usage buckets: %v", err)
+			s.mu.Lock()
+			for key, bucket := range buckets {
+				if current, ok := s.buckets[key]; ok {
+					current.Requests += bucket.Requests
+					current.Errors += bucket.Errors
+					current.RequestBytes += bucket.RequestBytes
+					current.ResponseBytes += bucket.ResponseBytes
+				} else {
+					s.buckets[key] = bucket
+				}
+			}
+			s.mu.Unlock()
+			return
+		}
+		// Every replica flushes its own counts, but only one warns, so each alert is posted once
+		if database.IsLeader() {
+			s.warnOverQuota(ctx)
+		}
+	}
+}
+
+// warnOverQuota posts an alert for each consumer over its hourly quota in the current hour, once an
+// hour. The totals come from the database so every server's requests count.
+func (s *APIUsageService) warnOverQuota(ctx context.Context) {
+	if !s.config.HasQuotas() {
+		return
+	}
+	hour := time.Now().UTC().Truncate(time.Hour)
+	totals, err := s.repository.HourlyTotals(ctx, models.APIUsageQuery{From: hour, To: hour.Add(time.Hour)})
+	if err != nil {
+		log.Printf("Failed to check API usage quotas: %v", err)
+		return
+	}
+	for _, total := range totals {
+		quota := s.config.QuotaFor(total.Consumer)
+		if quota == 0 || total.Requests <= quota || s.warned[total.Consumer].Equal(hour) {
+			continue
+		}
+		s.warned[total.Consumer] = hour
+		log.Printf("API consumer %s made %d requests this hour, over its quota of %d", total.Consumer, total.Requests, quota)
+		notifications.Publish(notifications.EventOperationalAlert, "API usage over quota",
+			fmt.Sprintf("%s has made %d requests since %s UTC, over its hourly quota of %d. Its requests are still served.",
+				total.Consumer, total.Requests, hour.Format("15:04"), quota))
+	}
+}
+
+// Report returns the usage over the days from through to (YYYY-MM-DD), both included, in hourly or
+// daily buckets, with each consumer's totals, busiest hour and the hours it went over its quota. The
+// period defaults to the last 24 hours.
+func (s *APIUsageService) Report(ctx context.Context, from, to, bucket, consumer, route string, byRoute bool) (*models.APIUsageReport, error) {
+	query := models.APIUsageQuery{Bucket: bucket, Consumer: consumer, Route: route, ByRoute: byRoute}
+	if query.Bucket == "" {
+		query.Bucket = models.APIUsageBucketHour
+	}
+	if query.Bucket != models.APIUsageBucketHour && query.Bucket != models.APIUsageBucketDay {
+		return nil, fmt.Errorf("%w: bucket must be hour or day", ErrInvalidAPIUsageQuery)
+	}
+	if from == "" && to == "" {
+		query.To = time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
+		query.From = query.To.Add(-apiUsageDefaultHours * time.Hour)
+	} else {
+		start, end, err := apiUsagePeriod(from, to)
+		if err != nil {
+			return nil, err
+		}
+		query.From, query.To = start, end
+	}
+
+	buckets, err := s.repository.Buckets(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := s.repository.HourlyTotals(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.APIUsageReport{From: query.From, To: query.To, Bucket: query.Bucket, Buckets: []models.APIUsageBucketCount{}, Consumers: []models.APIConsumerUsage{}}
+	consumers := map[string]*models.APIConsumerUsage{}
+	for _, count := range buckets {
+		count.ErrorRate = errorRate(count.Errors, count.Requests)
+		report.Buckets = append(report.Buckets, count)
+
+		usage, ok := consumers[count.Consumer]
+		if !ok {
+			usage = &models.APIConsumerUsage{Consumer: count.Consumer, HourlyQuota: s.config.QuotaFor(count.Consumer)}
+			consumers[count.Consumer] = usage
+		}
+		usage.Requests += count.Requests
+		usage.Errors += count.Errors
+		usage.RequestBytes += count.RequestBytes
+		usage.ResponseBytes += count.ResponseBytes
+	}
+	for _, hour := range hours {
+		usage, ok := consumers[hour.Consumer]
+		if !ok {
+			continue
+		}
+		usage.PeakHourRequests = max(usage.PeakHourRequests, hour.Requests)
+		if usage.HourlyQuota > 0 && hour.Requests > usage.HourlyQuota {
+			usage.OverQuotaHours++
+		}
+	}
+	for _, usage := range consumers {
+		usage.ErrorRate = errorRate(usage.Errors, usage.Requests)
+		report.Consumers = append(report.Consumers, *usage)
+	}
+	sort.Slice(report.Consumers, func(i, j int) bool {
+		if report.Consumers[i].Requests != report.Consumers[j].Requests {
+			return report.Consumers[i].Requests > report.Consumers[j].Requests
+		}
+		return report.Consumers[i].Consumer < report.Consumers[j].Consumer
+	})
+	return report, nil
+}
+
+// apiUsagePeriod returns the start of the from day and the end of the to day in clinic time, either
+// defaulting to the other
+func apiUsagePeriod(from, to string) (time.Time, time.Time, error) {
+	if from == "" {
+		from = to
+	}
+	if to == "" {
+		to = from
+	}
+	start, err := models.ParseClinicDate(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be given as YYYY-MM-DD", ErrInvalidAPIUsageQuery)
+	}
+	end, err := models.ParseClinicDate(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be given as YYYY-MM-DD", ErrInvalidAPIUsageQuery)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must not be after to", ErrInvalidAPIUsageQuery)
+	}
+	if end.After(start.AddDate(0, 0, apiUsageMaxDays-1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the period may not be longer than %d days", ErrInvalidAPIUsageQuery, apiUsageMaxDays)
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// errorRate is the share of requests answered with an error
+func errorRate(failed, requests int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(failed) / float64(requests)
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	// maxReminders caps the reminders of one appointment
+	maxReminders = 5
+	// maxReminderLead caps how long before an appointment a reminder is sent, in minutes
+	maxReminderLead = 60 * 24 * 60
+)
+
+// ErrInvalidReminderCadence is returned for reminder cadences that cannot be applied
+var ErrInvalidReminderCadence = errors.New("invalid reminder cadence")
+
+// AppointmentReminderService reminds patients of their appointments in the background, by email
+// and SMS as far as they agreed to be reminded. Each appointment is reminded of at the leads of the
+// cadence of its type in settings, or at those of its own cadence. Patients who are minors and in a household are
+// reminded through their household guardian instead, and other patients with a guarantor through
+// the guarantor. Every reminder is written to the patient's communication log, and texted ones ask
+// for a reply confirming or cancelling the appointment while replies are accepted.
+type AppointmentReminderService struct {
+	repository     *repositories.AppointmentReminderRepository
+	appointments   *repositories.AppointmentRepository
+	settings       *SettingService
+	communications *CommunicationService
+	email          notifications.Notifier
+	sms            notifications.Notifier
+	config         config.AppointmentReminderConfig
+	guarantors     config.GuarantorConfig
+	replies        config.SMSReplyConfig
+	clock          clock.Clock
+}
+
+// NewAppointmentReminderService starts sending reminders in the background when a dispatch interval is set.
+func NewAppointmentReminderService(repository *repositories.AppointmentReminderRepository, appointments *repositories.AppointmentRepository, settings *SettingService, communications *CommunicationService, email, sms notifications.Notifier, cfg config.AppointmentReminderConfig, guarantors config.GuarantorConfig, replies config.SMSReplyConfig, clock clock.Clock) *AppointmentReminderService {
+	s := &AppointmentReminderService{
+		repository:     repository,
+		appointments:   appointments,
+		settings:       settings,
+		communications: communications,
+		email:          email,
+		sms:            sms,
+		config:         cfg,
+		guarantors:     guarantors,
+		replies:        replies,
+		clock:          clock,
+	}
+	if cfg.DispatchInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *AppointmentReminderService) run() {
+	ticker := time.NewTicker(s.config.DispatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.dispatch(context.Background())
+	}
+}
+
+// dispatch sends the reminders that are due. An appointment with several due at once, such as a
+// surgery booked the day before, is only sent the last of them; the earlier ones are skipped.
+func (s *AppointmentReminderService) dispatch(ctx context.Context) {
+	now := s.clock.Now()
+	candidates, err := s.repository.Pending(ctx, now, s.settings.ReminderCadences(), s.config.BatchSize)
+	if err != nil {
+		log.Printf("Failed to find appointments to remind of: %v", err)
+		return
+	}
+	for i := 0; i < len(candidates); {
+		last := i
+		for last+1 < len(candidates) && candidates[last+1].AppointmentID == candidates[i].AppointmentID {
+			last++
+		}
+		for _, skipped := range candidates[i:last] {
+			if skipped.LeadMinutes == candidates[last].LeadMinutes {
+				continue
+			}
+			_, err := s.repository.Record(ctx, &models.AppointmentReminder{AppointmentID: skipped.AppointmentID, LeadMinutes: skipped.LeadMinutes,
+				RecipientID: skipped.PatientID, Skipped: true, SentAt: now})
+			if err != nil {
+				log.Printf("Failed to skip reminder for appointment %d: %v", skipped.AppointmentID, err)
+			}
+		}
+		s.send(ctx, candidates[last])
+		i = last + 1
+	}
+}
+
+// Plan returns when the reminders of an appointment are sent, and which were sent already
+func (s *AppointmentReminderService) Plan(ctx context.Context, patientID string, id uint) (*models.ReminderPlan, error) {
+	appointment, err := s.appointments.GetByID(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, repositories.ErrAppointmentNotFound
+	}
+	sent, err := s.repository.Sent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.ReminderPlan{AppointmentID: id, Type: appointment.Type, StartsAt: appointment.StartsAt,
+		Override: appointment.ReminderLeads != nil, LeadMinutes: appointment.ReminderLeads, Reminders: []models.PlannedReminder{}}
+	if !plan.Override {
+		plan.LeadMinutes = s.settings.ReminderCadence(appointment.Type)
+	}
+	recorded := map[int]bool{}
+	for _, reminder := range sent {
+		recorded[reminder.LeadMinutes] = true
+		planned := models.PlannedReminder{LeadMinutes: reminder.LeadMinutes, Status: models.ReminderSent, SentAt: &reminder.SentAt}
+		if reminder.Skipped {
+			planned.Status, planned.SentAt = models.ReminderSkipped, nil
+		}
+		if appointment.StartsAt != nil {
+			planned.SendAt = appointment.StartsAt.Add(-time.Duration(reminder.LeadMinutes) * time.Minute)
+		}
+		plan.Reminders = append(plan.Reminders, planned)
+	}
+	// Reminders still to come are only sent while the appointment is scheduled and ahead
+	now := s.clock.Now()
+	if appointment.Status == models.AppointmentStatusScheduled && appointment.StartsAt != nil && appointment.StartsAt.After(now) {
+		for _, lead := range plan.LeadMinutes {
+			if recorded[lead] {
+				continue
+			}
+			planned := models.PlannedReminder{LeadMinutes: lead, SendAt: appointment.StartsAt.Add(-time.Duration(lead) * time.Minute), Status: models.ReminderPlanned}
+			if !planned.SendAt.After(now) {
+				planned.Status = models.ReminderDue
+			}
+			plan.Reminders = append(plan.Reminders, planned)
+		}
+	}
+	sort.SliceStable(plan.Reminders, func(i, j int) bool { return plan.Reminders[i].LeadMinutes > plan.Reminders[j].LeadMinutes })
+	return plan, nil
+}
+
+// SetCadence gives an appointment its own reminder cadence, or with nil leads has it follow the
+// cadence of its type again. Reminders already sent are not sent again.
+func (s *AppointmentReminderService) SetCadence(ctx context.Context, patientID string, id uint, leads models.ReminderLeads) (*models.ReminderPlan, error) {
+	leads, err := checkReminderLeads(leads, ErrInvalidReminderCadence)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.appointments.SetReminderLeads(ctx, patientID, id, leads); err != nil {
+		return nil, err
+	}
+	return s.Plan(ctx, patientID, id)
+}
+
+// checkReminderLeads checks a reminder cadence and returns it longest lead first without repeats,
+// keeping a nil cadence nil
+func checkReminderLeads(leads models.ReminderLeads, invalid error) (models.ReminderLeads, error) {
+	if leads == nil {
+		return nil, nil
+	}
+	if len(leads) > maxReminders {
+		return nil, fmt.Errorf("%w: an appointment is reminded of at most %d times", invalid, maxReminders)
+	}
+	checked := models.ReminderLeads{}
+	seen := map[int]bool{}
+	for _, lead := range leads {
+		if lead <= 0 || lead > maxReminderLead {
+			return nil, fmt.Errorf("%w: reminders are sent between 1 and %d minutes ahead", invalid, maxReminderLead)
+		}
+		if !seen[lead] {
+			seen[lead] = true
+			checked = append(checked, lead)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(checked)))
+	return checked, nil
+}
+
+// send records the reminder before sending so replicas never send it twice, and clears it again if
+// it could not be delivered on any channel
+func (s *AppointmentReminderService) send(ctx context.Context, candidate models.AppointmentReminderCandidate) {
+	recipientID, firstName, email, phone := candidate.PatientID, candidate.PatientFirstName, candidate.PatientEmail, candidate.PatientPhone
+	whose := "your"
+	switch {
+	case candidate.GuardianID != "" && isMinor(candidate.DateOfBirth, candidate.StartsAt.In(models.ClinicLocation()), s.guarantors.AdultAge):
+		recipientID, firstName, email, phone = candidate.GuardianID, candidate.GuardianFirstName, candidate.GuardianEmail, candidate.GuardianPhone
+		whose = candidate.PatientFirstName + "'s"
+	case candidate.GuarantorName != "":
+		// The guarantor is contacted on the patient's behalf, so the patient's preferences apply
+		firstName, email, phone = candidate.GuarantorName, candidate.GuarantorEmail, candidate.GuarantorPhone
+		whose = candidate.PatientFirstName + "'s"
+	}
+
+	recorded, err := s.repository.Record(ctx, &models.AppointmentReminder{AppointmentID: candidate.AppointmentID, LeadMinutes: candidate.LeadMinutes,
+		RecipientID: recipientID, SentAt: s.clock.Now()})
+	if err != nil {
+		log.Printf("Failed to record reminder for appointment %d: %v", candidate.AppointmentID, err)
+		return
+	}
+	// Patients nobody can be reached for keep their record and are not tried again
+	if !recorded || (email == "" && phone == "") {
+		return
+	}
+
+	startsAt := candidate.StartsAt.In(models.ClinicLocation())
+	notification := notifications.Notification{
+		PatientID: recipientID,
+		Purpose:   notifications.PurposeReminder,
+		Expires:   candidate.StartsAt,
+		Subject:   "Appointment reminder for " + models.FormatDate(startsAt),
+		Body: fmt.Sprintf("Dear %s,\n\nThis is a reminder of %s appointment with Dr %s %s on %s at %s.\n",
+			firstName, whose, candidate.DoctorFirstName, candidate.DoctorLastName, models.FormatDayDate(startsAt), startsAt.Format("15:04")),
+	}
+	channels := []struct {
+		name      string
+		recipient string
+		notifier  notifications.Notifier
+	}{
+		{notifications.ChannelEmail, email, s.email},
+		{notifications.ChannelSMS, phone, s.sms},
+	}
+	body := notification.Body
+	delivered := false
+	for _, channel := range channels {
+		if channel.recipient == "" || channel.notifier == nil {
+			continue
+		}
+		notification.Recipients = []string{channel.recipient}
+		notification.Body = body
+		// Texts can be answered to confirm or cancel once replies are forwarded to the clinic
+		if channel.name == notifications.ChannelSMS {
+			notification.Body += s.replies.Invitation()
+		}
+		notification.MessageID = notifications.NewMessageID()
+		sendErr := channel.notifier.Send(ctx, notification)
+		if sendErr == nil || errors.Is(sendErr, notifications.ErrNotPermitted) || errors.Is(sendErr, notifications.ErrDeferred) {
+			// Recipients who opted out of reminders, or whose address bounced, keep their record and are not tried again,
+			// and reminders held until quiet hours end are delivered then
+			delivered = true
+		} else {
+			log.Printf("Failed to send reminder of appointment %d by %s: %v", candidate.AppointmentID, channel.name, sendErr)
+		}
+		err := s.communications.LogMessage(ctx, models.CommunicationLog{
+			PatientID: candidate.PatientID,
+			Channel:   channel.name,
+			Purpose:   notification.Purpose,
+			Recipient: channel.recipient,
+			Subject:   notification.Subject,
+			Body:      notification.Body,
+			MessageID: notification.MessageID,
+			Record:    "appointment",
+			RecordID:  fmt.Sprint(candidate.AppointmentID),
+		}, sendErr)
+		if err != nil {
+			log.Printf("Failed to log reminder of appointment %d: %v", candidate.AppointmentID, err)
+		}
+	}
+
+	if !delivered {
+		if err := s.repository.Delete(ctx, candidate.AppointmentID, candidate.LeadMinutes); err != nil {
+			log.Printf("Failed to clear reminder for appointment %d: %v", candidate.AppointmentID, err)
+		}
+	}
+}
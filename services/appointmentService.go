@@ -1,21 +1,209 @@
 package services
 
 import (
+	"RoyDental/config"
+	"RoyDental/events"
 	"RoyDental/models"
+	"RoyDental/notifications"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var (
+	// ErrInvalidAppointment is returned for appointments that cannot be scheduled as given
+	ErrInvalidAppointment = errors.New("invalid appointment")
+	// ErrClinicClosed is returned for bookings on a day in the closure calendar
+	ErrClinicClosed = errors.New("the clinic is closed")
+	// ErrEmergencyOnly is returned for routine bookings in a slot held back for emergencies
+	ErrEmergencyOnly = errors.New("the slot is held for emergencies")
+	// ErrNoEmergencySlot is returned when no emergency slot is left today for an emergency
+	ErrNoEmergencySlot = errors.New("no emergency slot is free today")
+)
+
+// emergencyStartStep rounds the start of an emergency booked into a slot already under way
+const emergencyStartStep = 5 * time.Minute
+
 type AppointmentService struct {
-	repository *repositories.AppointmentRepository
+	repository        *repositories.AppointmentRepository
+	chairRepo         *repositories.ChairRepository
+	closureRepo       *repositories.ClosureRepository
+	emergencySlotRepo *repositories.EmergencySlotRepository
+	rosterRepo        *repositories.RosterRepository
+	settings          *SettingService
+	customFields      *CustomFieldService
+	credentials       *CredentialService
+	config            config.SchedulingConfig
+}
+
+func NewAppointmentService(repository *repositories.AppointmentRepository, chairRepo *repositories.ChairRepository, closureRepo *repositories.ClosureRepository, emergencySlotRepo *repositories.EmergencySlotRepository, rosterRepo *repositories.RosterRepository, settings *SettingService, customFields *CustomFieldService, credentials *CredentialService, cfg config.SchedulingConfig) *AppointmentService {
+	return &AppointmentService{repository: repository, chairRepo: chairRepo, closureRepo: closureRepo, emergencySlotRepo: emergencySlotRepo, rosterRepo: rosterRepo, settings: settings, customFields: customFields, credentials: credentials, config: cfg}
+}
+
+// Create books an appointment. Bookings on a closed day or in a slot held for emergencies are
+// refused; walk-ins are recorded as they happen. A booking overlapping another of the patient's is
+// refused unless it overrides the conflict, which is written to the audit trail from audit.
+func (s *AppointmentService) Create(ctx context.Context, appointment *models.Appointment, audit models.AuditLog) error {
+	if appointment.Type == "" {
+		appointment.Type = models.AppointmentTypeConsultation
+	}
+	appointment.EmergencyReason = ""
+	if err := s.schedule(appointment); err != nil {
+		return err
+	}
+	if appointment.Origin != models.AppointmentOriginWalkIn {
+		if err := s.checkEmergencySlots(ctx, appointment); err != nil {
+			return err
+		}
+	}
+	return s.book(ctx, appointment, audit)
+}
+
+// CreateEmergency books an emergency, which may take a slot held for emergencies. Without a
+// date_time it goes in the first of today's emergency slots still free for its doctor, or for any
+// doctor when none is given, skipping slots that overlap another of the patient's appointments.
+func (s *AppointmentService) CreateEmergency(ctx context.Context, appointment *models.Appointment, audit models.AuditLog) error {
+	appointment.EmergencyReason = strings.TrimSpace(appointment.EmergencyReason)
+	if appointment.EmergencyReason == "" {
+		return fmt.Errorf("%w: the reason for the emergency is required", ErrInvalidAppointment)
+	}
+	appointment.Type = models.AppointmentTypeEmergency
+	appointment.Status = models.AppointmentStatusScheduled
+	appointment.Origin = models.AppointmentOriginBooked
+	if appointment.DateTime != "" {
+		if err := s.schedule(appointment); err != nil {
+			return err
+		}
+		return s.book(ctx, appointment, audit)
+	}
+
+	now := time.Now()
+	slots, err := s.emergencySlotRepo.On(ctx, now)
+	if err != nil {
+		return err
+	}
+	duration := s.settings.AppointmentDuration(appointment.Type)
+	doctorID := appointment.DoctorID
+	for _, slot := range slots {
+		if doctorID != "" && slot.DoctorID != nil && *slot.DoctorID != doctorID {
+			continue
+		}
+		if doctorID == "" && slot.DoctorID == nil {
+			continue
+		}
+		start, end, ok := slot.On(now)
+		if !ok {
+			continue
+		}
+		if start.Before(now) {
+			start = now.Truncate(emergencyStartStep).Add(emergencyStartStep)
+		}
+		if start.Add(duration).After(end) {
+			continue
+		}
+
+		if slot.DoctorID != nil {
+			appointment.DoctorID = *slot.DoctorID
+		}
+		appointment.DateTime = models.StoredAppointmentTime(start)
+		if err := s.schedule(appointment); err != nil {
+			return err
+		}
+		err := s.book(ctx, appointment, audit)
+		if errors.Is(err, repositories.ErrDoctorDoubleBooked) || errors.Is(err, repositories.ErrNoChairAvailable) || errors.Is(err, repositories.ErrChairDoubleBooked) ||
+			errors.Is(err, repositories.ErrChairDown) || errors.Is(err, repositories.ErrPatientDoubleBooked) || errors.Is(err, ErrCredentialsExpired) {
+			continue
+		}
+		return err
+	}
+	if doctorID == "" {
+		return fmt.Errorf("%w: give a doctor_id to book with a doctor outright", ErrNoEmergencySlot)
+	}
+	return ErrNoEmergencySlot
+}
+
+// book saves a scheduled appointment unless the clinic is closed, the doctor's credentials have
+// expired or its custom field values or reminder cadence are not valid, and tells the desk about it
+func (s *AppointmentService) book(ctx context.Context, appointment *models.Appointment, audit models.AuditLog) error {
+	if appointment.Attribution.Source == "" && appointment.Origin == models.AppointmentOriginWalkIn {
+		appointment.Attribution.Source = models.SourceWalkIn
+	}
+	if err := checkAttribution(&appointment.Attribution, ErrInvalidAppointment); err != nil {
+		return err
+	}
+	leads, err := checkReminderLeads(appointment.ReminderLeads, ErrInvalidAppointment)
+	if err != nil {
+		return err
+	}
+	appointment.ReminderLeads = leads
+	if appointment.CustomFields == nil {
+		appointment.CustomFields = models.CustomFieldValues{}
+	}
+	if err := s.customFields.CheckValues(ctx, models.CustomFieldEntityAppointment, appointment.CustomFields); err != nil {
+		return err
+	}
+	if err := s.checkCredentials(ctx, appointment); err != nil {
+		return err
+	}
+	if appointment.Origin != models.AppointmentOriginWalkIn && appointment.StartsAt != nil {
+		closure, err := s.closureOn(ctx, *appointment.StartsAt)
+		if err != nil {
+			return err
+		}
+		if closure != nil {
+			return fmt.Errorf("%w on %s: %s", ErrClinicClosed, appointment.StartsAt.In(models.ClinicLocation()).Format(models.ClosureDateLayout), closure.Reason)
+		}
+	}
+	if err := s.repository.Create(ctx, appointment, s.overbooking(), audit); err != nil {
+		return err
+	}
+	switch {
+	case appointment.EmergencyReason != "":
+		notifications.Publish(notifications.EventNewOnlineBooking, "Emergency booked",
+			fmt.Sprintf("Emergency appointment #%d booked for patient %s with doctor %s on %s: %s", appointment.ID, appointment.PatientID, appointment.DoctorID, models.ClinicDateTime(appointment.DateTime), appointment.EmergencyReason))
+	case appointment.Origin != models.AppointmentOriginWalkIn:
+		notifications.Publish(notifications.EventNewOnlineBooking, "New booking",
+			fmt.Sprintf("Appointment #%d booked for patient %s with doctor %s on %s.", appointment.ID, appointment.PatientID, appointment.DoctorID, models.ClinicDateTime(appointment.DateTime)))
+	}
+	return nil
 }
 
-func NewAppointmentService(repository *repositories.AppointmentRepository) *AppointmentService {
-	return &AppointmentService{repository: repository}
+// checkEmergencySlots refuses a routine appointment in a slot held for emergencies
+func (s *AppointmentService) checkEmergencySlots(ctx context.Context, appointment *models.Appointment) error {
+	if appointment.StartsAt == nil || appointment.EndsAt == nil || appointment.Status == models.AppointmentStatusCancelled {
+		return nil
+	}
+	slots, err := s.emergencySlotRepo.On(ctx, *appointment.StartsAt)
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if slot.Holds(appointment.DoctorID, *appointment.StartsAt, *appointment.EndsAt) {
+			return fmt.Errorf("%w from %s to %s; book it through POST /appointments/emergency", ErrEmergencyOnly, slot.StartTime, slot.EndTime)
+		}
+	}
+	return nil
 }
 
-func (s *AppointmentService) Create(ctx context.Context, appointment *models.Appointment) error {
-	return s.repository.Create(ctx, appointment)
+// checkCredentials refuses an appointment with a doctor whose credentials have expired by its day,
+// or by today for an appointment not yet given a time
+func (s *AppointmentService) checkCredentials(ctx context.Context, appointment *models.Appointment) error {
+	at := time.Now()
+	if appointment.StartsAt != nil {
+		at = *appointment.StartsAt
+	}
+	return s.credentials.CheckSchedulable(ctx, appointment.DoctorID, at)
+}
+
+// HandleAppointmentCancelled posts a cancelled appointment to chat, so its slot can be offered to someone else.
+func (s *AppointmentService) HandleAppointmentCancelled(_ context.Context, event events.Event) error {
+	notifications.Publish(notifications.EventAppointmentCancelled, "Appointment cancelled",
+		fmt.Sprintf("Appointment #%s for patient %s was cancelled.", event.RecordID, event.PatientID))
+	return nil
 }
 
 func (s *AppointmentService) GetByID(ctx context.Context, patientID string, id uint) (*models.Appointment, error) {
@@ -26,10 +214,317 @@ func (s *AppointmentService) GetAll(ctx context.Context) ([]models.Appointment,
 	return s.repository.GetAll(ctx)
 }
 
-func (s *AppointmentService) Update(ctx context.Context, appointment *models.Appointment) error {
-	return s.repository.Update(ctx, appointment)
+// ListPage returns a page of appointments newest first, after the ?after= cursor of the previous page
+func (s *AppointmentService) ListPage(ctx context.Context, after string, limit int) (*models.ListPage[models.Appointment], error) {
+	cursor, limit, err := pageRequest(after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repository.ListAfter(ctx, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Appointment) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: strconv.FormatUint(uint64(row.ID), 10)}
+	}), nil
+}
+
+// Stream hands every appointment to fn newest first, for lists too large to build in memory
+func (s *AppointmentService) Stream(ctx context.Context, fn func(models.Appointment) error) error {
+	return s.repository.Stream(ctx, fn)
+}
+
+// StreamFiltered hands the appointments matching query to fn in the order it asks for
+func (s *AppointmentService) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Appointment) error) error {
+	return s.repository.StreamFiltered(ctx, query, fn)
+}
+
+// Update changes an appointment. One updated without a type keeps the type it has, and so its
+// duration, and one updated without custom_fields keeps the values it has. Only emergencies may be moved into a slot held for emergencies.
+// One moved over another of the patient's appointments needs the conflict overridden, as when booking,
+// and one moved to a doctor or day on which the doctor's credentials have expired is refused.
+func (s *AppointmentService) Update(ctx context.Context, appointment *models.Appointment, audit models.AuditLog) error {
+	current, err := s.repository.GetByID(ctx, appointment.PatientID, appointment.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return repositories.ErrAppointmentNotFound
+	}
+	if appointment.Type == "" {
+		appointment.Type = current.Type
+	}
+	appointment.EmergencyReason = current.EmergencyReason
+	if err := s.schedule(appointment); err != nil {
+		return err
+	}
+	if err := checkAttribution(&appointment.Attribution, ErrInvalidAppointment); err != nil {
+		return err
+	}
+	if appointment.CustomFields != nil {
+		if err := s.customFields.CheckValues(ctx, models.CustomFieldEntityAppointment, appointment.CustomFields); err != nil {
+			return err
+		}
+	}
+	moved := appointment.DoctorID != current.DoctorID || !sameInstant(appointment.StartsAt, current.StartsAt) || !sameInstant(appointment.EndsAt, current.EndsAt)
+	if moved && appointment.EmergencyReason == "" && current.Origin != models.AppointmentOriginWalkIn {
+		if err := s.checkEmergencySlots(ctx, appointment); err != nil {
+			return err
+		}
+	}
+	if moved {
+		if err := s.checkCredentials(ctx, appointment); err != nil {
+			return err
+		}
+	}
+	return s.repository.Update(ctx, appointment, s.overbooking(), audit)
+}
+
+// Patch changes only the fields present in patch, a JSON merge patch, keeping the others as they are
+func (s *AppointmentService) Patch(ctx context.Context, patientID string, id uint, patch []byte, audit models.AuditLog) (*models.Appointment, error) {
+	appointment, err := s.repository.GetByID(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, repositories.ErrAppointmentNotFound
+	}
+	if err := applyPatch(appointment, patch); err != nil {
+		return nil, err
+	}
+	appointment.ID, appointment.PatientID = id, patientID
+	appointment.Patient, appointment.Doctor = models.Patient{}, models.Doctor{}
+	if err := s.Update(ctx, appointment, audit); err != nil {
+		return nil, err
+	}
+	return appointment, nil
 }
 
 func (s *AppointmentService) Delete(ctx context.Context, patientID string, id uint) error {
 	return s.repository.Delete(ctx, patientID, id)
 }
+
+// Availability returns the day's slots, within opening hours and not yet started, in which the doctor
+// has no other appointment and a chair is free. Appointments without a chair still take one up.
+// Chairs out of service are not offered. While no chairs are set up, only the doctor's appointments
+// are considered. A closed day has no slots.
+// Slots in which the doctor is busy are offered for overbooking while the policy allows one there;
+// slots held for emergencies are not offered. A doctor on approved leave or whose credentials have
+// expired has no slots, and when the roster is required a doctor with a staff account is only
+// offered slots within their shifts.
+func (s *AppointmentService) Availability(ctx context.Context, day time.Time, doctorID string) ([]models.AvailableSlot, error) {
+	closure, err := s.closureOn(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	if closure != nil {
+		return []models.AvailableSlot{}, nil
+	}
+
+	dayStart, err := s.timeOfDay(day, s.config.DayStart)
+	if err != nil {
+		return nil, err
+	}
+	dayEnd, err := s.timeOfDay(day, s.config.DayEnd)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.SlotDuration <= 0 {
+		return nil, errors.New("the appointment slot duration must be positive")
+	}
+
+	chairs, err := s.chairRepo.ListChairs(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	downtime, err := s.chairRepo.ListDowntime(ctx, models.DowntimeFilter{From: &dayStart, To: &dayEnd})
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := s.repository.Bookings(ctx, dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+	emergencySlots, err := s.emergencySlotRepo.On(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	presence := &models.Presence{}
+	if doctorID != "" {
+		if err := s.credentials.CheckSchedulable(ctx, doctorID, day); errors.Is(err, ErrCredentialsExpired) {
+			return []models.AvailableSlot{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		clinicDayStart, clinicDayEnd := models.ClinicDay(day)
+		if presence, err = s.rosterRepo.Presence(ctx, doctorID, clinicDayStart, clinicDayEnd); err != nil {
+			return nil, err
+		}
+		if presence.Rostered && presence.OnLeave {
+			return []models.AvailableSlot{}, nil
+		}
+	}
+
+	policy := s.overbooking()
+	now := time.Now()
+	slots := []models.AvailableSlot{}
+	for start := dayStart; !start.Add(s.config.SlotDuration).After(dayEnd); start = start.Add(s.config.SlotDuration) {
+		end := start.Add(s.config.SlotDuration)
+		if start.Before(now) || heldForEmergencies(emergencySlots, doctorID, start, end) {
+			continue
+		}
+		if presence.Rostered && s.config.RequireRoster && !presence.Covers(start, end) {
+			continue
+		}
+
+		doctorBookings := 0
+		takenChairs := map[uint]bool{}
+		for _, d := range downtime {
+			if d.Down(start, end) {
+				takenChairs[*d.ChairID] = true
+			}
+		}
+		unassigned := 0
+		for _, booking := range bookings {
+			if !booking.StartsAt.Before(end) || !booking.EndsAt.After(start) {
+				continue
+			}
+			if doctorID != "" && booking.DoctorID == doctorID {
+				doctorBookings++
+			}
+			if booking.ChairID != nil {
+				takenChairs[*booking.ChairID] = true
+			} else {
+				unassigned++
+			}
+		}
+
+		// A busy doctor's slot is only offered for a short appointment booked over theirs
+		overbook := doctorBookings > 0
+		if overbook && !policy.Permits(policy.MaxDuration, doctorBookings, overbookedInHour(bookings, doctorID, start)) {
+			continue
+		}
+
+		slot := models.AvailableSlot{Start: start, End: end, ChairIDs: []uint{}, Overbook: overbook}
+		for _, chair := range chairs {
+			if !takenChairs[chair.ID] {
+				slot.ChairIDs = append(slot.ChairIDs, chair.ID)
+			}
+		}
+		slot.FreeChairs = len(slot.ChairIDs) - unassigned
+		if len(chairs) > 0 && slot.FreeChairs <= 0 {
+			continue
+		}
+		if slot.FreeChairs < 0 {
+			slot.FreeChairs = 0
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// OverbookingReport returns how many appointments on the clinic days from through to were booked
+// over another of their doctor's, and how many of those collided because both patients turned up.
+func (s *AppointmentService) OverbookingReport(ctx context.Context, from, to time.Time) (*models.OverbookingReport, error) {
+	start, _ := models.ClinicDay(from)
+	_, end := models.ClinicDay(to)
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidAppointment)
+	}
+	doctors, err := s.repository.OverbookingByDoctor(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.OverbookingReport{
+		From:    from.In(models.ClinicLocation()).Format("2006-01-02"),
+		To:      to.In(models.ClinicLocation()).Format("2006-01-02"),
+		Doctors: []models.DoctorOverbookingReport{},
+	}
+	for _, doctor := range doctors {
+		doctor.CollisionRate = collisionRate(doctor.Collided, doctor.Overbooked)
+		report.Overbooked += doctor.Overbooked
+		report.Collided += doctor.Collided
+		report.Doctors = append(report.Doctors, doctor)
+	}
+	report.CollisionRate = collisionRate(report.Collided, report.Overbooked)
+	return report, nil
+}
+
+func collisionRate(collided, overbooked int64) float64 {
+	if overbooked == 0 {
+		return 0
+	}
+	return float64(collided) / float64(overbooked)
+}
+
+// heldForEmergencies reports whether the slot is held for emergencies for the doctor, or for every
+// doctor when none is given
+func heldForEmergencies(slots []models.EmergencySlot, doctorID string, start, end time.Time) bool {
+	for _, slot := range slots {
+		if slot.Holds(doctorID, start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameInstant reports whether two optional times are both unset or the same instant
+func sameInstant(a, b *time.Time) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+}
+
+// overbooking returns the configured overbooking policy
+func (s *AppointmentService) overbooking() models.OverbookingPolicy {
+	return models.OverbookingPolicy{PerHour: s.config.OverbookPerHour, MaxDuration: s.config.OverbookMaxDuration}
+}
+
+// overbookedInHour counts the doctor's overbooked appointments starting in the clinic hour of t
+func overbookedInHour(bookings []models.Booking, doctorID string, t time.Time) int {
+	hour := models.ClinicHour(t)
+	count := 0
+	for _, booking := range bookings {
+		if booking.Overbooked && booking.DoctorID == doctorID && !booking.StartsAt.Before(hour) && booking.StartsAt.Before(hour.Add(time.Hour)) {
+			count++
+		}
+	}
+	return count
+}
+
+// schedule sets the time an appointment takes up its doctor and chair from its date_time, which is
+// read as clinic time unless it carries an offset and then stored in UTC, and the default duration
+// of its type. Appointments whose date_time cannot be read are left unscheduled, unless they are
+// given a chair.
+func (s *AppointmentService) schedule(appointment *models.Appointment) error {
+	if !models.IsValidAppointmentType(appointment.Type) {
+		return fmt.Errorf("%w: type must be one of %s", ErrInvalidAppointment, strings.Join(models.AppointmentTypes, ", "))
+	}
+	start, ok := models.ParseAppointmentTime(appointment.DateTime)
+	if !ok {
+		if appointment.ChairID != nil {
+			return fmt.Errorf("%w: a chair can only be assigned with a date_time such as 2006-01-02T15:04", ErrInvalidAppointment)
+		}
+		appointment.StartsAt, appointment.EndsAt = nil, nil
+		return nil
+	}
+	start = start.UTC()
+	end := start.Add(s.settings.AppointmentDuration(appointment.Type))
+	appointment.DateTime = models.StoredAppointmentTime(start)
+	appointment.StartsAt, appointment.EndsAt = &start, &end
+	return nil
+}
+
+// closureOn returns the closure covering the clinic day t falls on, or nil when the clinic is open
+func (s *AppointmentService) closureOn(ctx context.Context, t time.Time) (*models.Closure, error) {
+	return s.closureRepo.On(ctx, t.In(models.ClinicLocation()).Format(models.ClosureDateLayout))
+}
+
+// timeOfDay returns the HH:MM clinic time on the clinic day day falls on
+func (s *AppointmentService) timeOfDay(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid opening time %q, expected HH:MM", clock)
+	}
+	day = day.In(models.ClinicLocation())
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrApprovalRequired   = errors.New("the action needs approval")
+	ErrApprovalNotFound   = errors.New("approval not found")
+	ErrInvalidApproval    = errors.New("invalid approval")
+	ErrApprovalNotPending = errors.New("the approval is no longer pending")
+	ErrSelfApproval       = errors.New("an action cannot be approved by the user who asked for it")
+	ErrApprovalPermission = errors.New("deciding the approval needs a permission the user's role lacks")
+	ErrApprovalFailed     = errors.New("the approved action could not be carried out")
+)
+
+// ApprovalRequiredError is returned instead of carrying out an action that waits for approval.
+// It matches ErrApprovalRequired and holds the pending approval.
+type ApprovalRequiredError struct {
+	Approval *models.Approval
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%v: approval %d is pending", ErrApprovalRequired, e.Approval.ID)
+}
+
+func (e *ApprovalRequiredError) Unwrap() error {
+	return ErrApprovalRequired
+}
+
+// ApprovalExecutor carries out an approved action
+type ApprovalExecutor func(ctx context.Context, approval *models.Approval) error
+
+// ApprovalService holds back sensitive actions, such as deleting a patient with billing history,
+// until an admin other than the user asking approves them. Each step is kept in the activity
+// audit trail and posted to chat.
+type ApprovalService struct {
+	repository *repositories.ApprovalRepository
+	audit      *AuditService
+	userRepo   repositories.UserRepository
+	executors  map[string]ApprovalExecutor
+}
+
+func NewApprovalService(repository *repositories.ApprovalRepository, audit *AuditService, userRepo repositories.UserRepository) *ApprovalService {
+	return &ApprovalService{repository: repository, audit: audit, userRepo: userRepo, executors: map[string]ApprovalExecutor{}}
+}
+
+// Register sets how an approved action is carried out. The services guarding the actions register
+// them when they are created.
+func (s *ApprovalService) Register(action string, executor ApprovalExecutor) {
+	s.executors[action] = executor
+}
+
+// Request holds back an action on a record until it is approved, with payload saved to carry it
+// out later. It returns an *ApprovalRequiredError with the pending approval, which is the one
+// already waiting when the same action was asked for on the record before.
+func (s *ApprovalService) Request(ctx context.Context, action, recordID, summary string, payload interface{}) error {
+	if recordID != "" {
+		pending, err := s.repository.Pending(ctx, action, recordID)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			return &ApprovalRequiredError{Approval: pending}
+		}
+	}
+
+	approval := &models.Approval{Action: action, RecordID: recordID, Summary: summary, Status: models.ApprovalStatusPending}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to save the action: %w", err)
+		}
+		approval.Payload = string(data)
+	}
+	if err := s.repository.Create(ctx, approval); err != nil {
+		return err
+	}
+	if err := s.record(ctx, models.AuditEventApprovalRequested, approval); err != nil {
+		return err
+	}
+	notifications.Publish(notifications.EventApprovalRequested, "Approval requested",
+		fmt.Sprintf("Approval %d: %s", approval.ID, approval.Summary))
+	return &ApprovalRequiredError{Approval: approval}
+}
+
+func (s *ApprovalService) Get(ctx context.Context, id uint) (*models.Approval, error) {
+	approval, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval == nil {
+		return nil, ErrApprovalNotFound
+	}
+	return approval, nil
+}
+
+// List returns the approvals in status and for action, or all of them when empty
+func (s *ApprovalService) List(ctx context.Context, filter models.ApprovalFilter) ([]models.Approval, error) {
+	if filter.Status != "" && !models.IsValidApprovalStatus(filter.Status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidApproval, filter.Status)
+	}
+	if filter.Action != "" && !models.IsValidApprovalAction(filter.Action) {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidApproval, filter.Action)
+	}
+	approvals, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if approvals == nil {
+		approvals = []models.Approval{}
+	}
+	return approvals, nil
+}
+
+// Approve approves a pending action and carries it out straight away
+func (s *ApprovalService) Approve(ctx context.Context, id uint, userID int64, note string) (*models.Approval, error) {
+	approval, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.CreatedBy != nil && *approval.CreatedBy == userID {
+		return nil, ErrSelfApproval
+	}
+	if err := s.authorize(ctx, approval, userID); err != nil {
+		return nil, err
+	}
+	executor, ok := s.executors[approval.Action]
+	if !ok {
+		return nil, fmt.Errorf("%w: no way to carry out %s", ErrInvalidApproval, approval.Action)
+	}
+	if err := s.decide(ctx, approval, models.ApprovalStatusApproved, userID, note); err != nil {
+		return nil, err
+	}
+
+	if err := executor(ctx, approval); err != nil {
+		approval.Status, approval.Error = models.ApprovalStatusFailed, err.Error()
+		if failErr := s.repository.Fail(ctx, approval); failErr != nil {
+			return nil, failErr
+		}
+		if auditErr := s.record(ctx, models.AuditEventApprovalFailed, approval); auditErr != nil {
+			return nil, auditErr
+		}
+		s.publishDecision(approval)
+		return nil, fmt.Errorf("%w: %v", ErrApprovalFailed, err)
+	}
+	if err := s.record(ctx, models.AuditEventApprovalApproved, approval); err != nil {
+		return nil, err
+	}
+	s.publishDecision(approval)
+	return approval, nil
+}
+
+// Reject turns down a pending action
+func (s *ApprovalService) Reject(ctx context.Context, id uint, userID int64, note string) (*models.Approval, error) {
+	approval, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("%w: a rejection needs a note", ErrInvalidApproval)
+	}
+	if err := s.authorize(ctx, approval, userID); err != nil {
+		return nil, err
+	}
+	if err := s.decide(ctx, approval, models.ApprovalStatusRejected, userID, note); err != nil {
+		return nil, err
+	}
+	if err := s.record(ctx, models.AuditEventApprovalRejected, approval); err != nil {
+		return nil, err
+	}
+	s.publishDecision(approval)
+	return approval, nil
+}
+
+// Cancel withdraws a pending action the user asked for
+func (s *ApprovalService) Cancel(ctx context.Context, id uint, userID int64) (*models.Approval, error) {
+	approval, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.CreatedBy == nil || *approval.CreatedBy != userID {
+		return nil, ErrApprovalNotFound
+	}
+	if err := s.decide(ctx, approval, models.ApprovalStatusCancelled, userID, ""); err != nil {
+		return nil, err
+	}
+	if err := s.record(ctx, models.AuditEventApprovalCancelled, approval); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// authorize checks the user's role grants the permission deciding the approval takes, such as
+// approve_discount for discounts
+func (s *ApprovalService) authorize(ctx context.Context, approval *models.Approval, userID int64) error {
+	permission := models.ApprovalPermission(approval.Action)
+	if permission == "" {
+		return nil
+	}
+	granted, err := hasPermission(ctx, s.userRepo, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return ErrApprovalPermission
+	}
+	return nil
+}
+
+func (s *ApprovalService) decide(ctx context.Context, approval *models.Approval, status string, userID int64, note string) error {
+	if approval.Status != models.ApprovalStatusPending {
+		return ErrApprovalNotPending
+	}
+	now := time.Now()
+	approval.Status, approval.DecidedBy, approval.DecidedAt, approval.DecisionNote = status, &userID, &now, strings.TrimSpace(note)
+	if err := s.repository.Decide(ctx, approval); err != nil {
+		if errors.Is(err, repositories.ErrApprovalNotPending) {
+			return ErrApprovalNotPending
+		}
+		return err
+	}
+	return nil
+}
+
+// record writes a step of an approval to the activity audit trail
+func (s *ApprovalService) record(ctx context.Context, event string, approval *models.Approval) error {
+	entry := models.AuditLog{
+		Category: models.AuditCategoryActivity,
+		Event:    event,
+		Detail:   fmt.Sprintf("approval=%d action=%s", approval.ID, approval.Action),
+	}
+	if approval.RecordID != "" {
+		entry.Detail += " record=" + approval.RecordID
+	}
+	if approval.DecisionNote != "" {
+		entry.Detail += " note=" + strconv.Quote(approval.DecisionNote)
+	}
+	if approval.Error != "" {
+		entry.Detail += " error=" + strconv.Quote(approval.Error)
+	}
+	if userID, ok := models.ActorFrom(ctx); ok {
+		entry.UserID = strconv.FormatInt(userID, 10)
+	}
+	if err := s.audit.Record(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record the approval in the audit trail: %w", err)
+	}
+	return nil
+}
+
+func (s *ApprovalService) publishDecision(approval *models.Approval) {
+	body := fmt.Sprintf("Approval %d was %s: %s", approval.ID, approval.Status, approval.Summary)
+	if approval.Error != "" {
+		body += " (" + approval.Error + ")"
+	}
+	notifications.Publish(notifications.EventApprovalDecided, "Approval decided", body)
+}
+
+// approvalPayload reads back the payload an action was saved with
+func approvalPayload(approval *models.Approval, payload interface{}) error {
+	if err := json.Unmarshal([]byte(approval.Payload), payload); err != nil {
+		return fmt.Errorf("failed to read the saved action: %w", err)
+	}
+	return nil
+}
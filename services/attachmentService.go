@@ -0,0 +1,200 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxAttachmentSize bounds an uploaded examination file; full-mouth radiographs stay well below it.
+const MaxAttachmentSize = 25 << 20
+
+// attachmentContentTypes are the documents and images that may be filed with an examination
+var attachmentContentTypes = map[string]bool{
+	"application/pdf":   true,
+	"application/dicom": true,
+	"image/jpeg":        true,
+	"image/png":         true,
+	"image/tiff":        true,
+	"image/webp":        true,
+}
+
+var (
+	ErrExaminationNotFound = errors.New("examination not found")
+	ErrAttachmentNotFound  = errors.New("attachment not found")
+	ErrInvalidAttachment   = errors.New("invalid attachment")
+	ErrInvalidAnnotation   = errors.New("invalid annotation")
+)
+
+// AttachmentUpload is a file sent to be filed with an examination
+type AttachmentUpload struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	Annotations models.Annotations
+}
+
+type AttachmentService struct {
+	repository            *repositories.AttachmentRepository
+	examinationRepository *repositories.ExaminationRepository
+	files                 *Files
+	scans                 *VirusScanService
+}
+
+func NewAttachmentService(repository *repositories.AttachmentRepository, examinationRepository *repositories.ExaminationRepository, files *Files, scans *VirusScanService) *AttachmentService {
+	return &AttachmentService{repository: repository, examinationRepository: examinationRepository, files: files, scans: scans}
+}
+
+// Upload files a document or image with the patient's examination once the virus scanner passed it
+func (s *AttachmentService) Upload(ctx context.Context, patientID string, examinationID uint, upload AttachmentUpload) (*models.ExaminationAttachment, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	contentType := strings.ToLower(strings.TrimSpace(strings.SplitN(upload.ContentType, ";", 2)[0]))
+	switch {
+	case len(upload.Content) == 0:
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidAttachment)
+	case len(upload.Content) > MaxAttachmentSize:
+		return nil, fmt.Errorf("%w: the file is larger than %d MB", ErrInvalidAttachment, MaxAttachmentSize>>20)
+	case !attachmentContentTypes[contentType]:
+		return nil, fmt.Errorf("%w: unsupported file type %q", ErrInvalidAttachment, contentType)
+	}
+	if err := validateAnnotations(upload.Annotations); err != nil {
+		return nil, err
+	}
+
+	fileName := strings.TrimSpace(upload.FileName)
+	if fileName == "" {
+		fileName = "attachment"
+	}
+	err := s.scans.Check(ctx, models.QuarantinedFile{
+		Source:      models.QuarantineSourceAttachment,
+		PatientID:   &patientID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Content:     upload.Content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	annotations := upload.Annotations
+	if annotations == nil {
+		annotations = models.Annotations{}
+	}
+	attachment := &models.ExaminationAttachment{
+		ExaminationID: examinationID,
+		FileName:      fileName,
+		ContentType:   contentType,
+		Size:          int64(len(upload.Content)),
+		Content:       upload.Content,
+		Annotations:   annotations,
+	}
+	if s.files.External() {
+		attachment.StorageKey = fmt.Sprintf("examinations/%d/%s", examinationID, uuid.NewString())
+		attachment.Content = []byte{}
+		if err := s.files.Put(ctx, storage.BucketFor(contentType), attachment.StorageKey, upload.Content, contentType); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repository.Create(ctx, patientID, attachment); err != nil {
+		s.files.Delete(ctx, storage.BucketFor(contentType), attachment.StorageKey)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// Download returns the attachment with its file
+func (s *AttachmentService) Download(ctx context.Context, patientID string, examinationID, id uint) (*models.ExaminationAttachment, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	attachment, err := s.repository.GetWithContent(ctx, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+	if err := s.files.LoadAttachment(ctx, attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// UpdateAnnotations replaces the regions and labels drawn on an attachment
+func (s *AttachmentService) UpdateAnnotations(ctx context.Context, patientID string, examinationID, id uint, annotations models.Annotations) (*models.ExaminationAttachment, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	if err := validateAnnotations(annotations); err != nil {
+		return nil, err
+	}
+	attachment, err := s.repository.GetByID(ctx, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+	if annotations == nil {
+		annotations = models.Annotations{}
+	}
+	attachment.Annotations = annotations
+	if err := s.repository.UpdateAnnotations(ctx, patientID, attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) Delete(ctx context.Context, patientID string, examinationID, id uint) error {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return err
+	}
+	attachment, err := s.repository.GetByID(ctx, examinationID, id)
+	if err != nil {
+		return err
+	}
+	if attachment == nil {
+		return nil
+	}
+	if err := s.repository.Delete(ctx, patientID, examinationID, id); err != nil {
+		return err
+	}
+	s.files.Delete(ctx, storage.BucketFor(attachment.ContentType), attachment.StorageKey)
+	return nil
+}
+
+// checkExamination makes sure the examination belongs to the patient in the URL
+func (s *AttachmentService) checkExamination(ctx context.Context, patientID string, examinationID uint) error {
+	examination, err := s.examinationRepository.GetByID(ctx, patientID, examinationID)
+	if err != nil {
+		return err
+	}
+	if examination == nil {
+		return ErrExaminationNotFound
+	}
+	return nil
+}
+
+// validateAnnotations requires a label and a region inside the image for every annotation
+func validateAnnotations(annotations models.Annotations) error {
+	for i := range annotations {
+		annotation := &annotations[i]
+		annotation.Label = strings.TrimSpace(annotation.Label)
+		if annotation.Label == "" {
+			return fmt.Errorf("%w %d: a label is required", ErrInvalidAnnotation, i)
+		}
+		region := annotation.Region
+		if region.X < 0 || region.Y < 0 || region.Width <= 0 || region.Height <= 0 ||
+			region.X+region.Width > 1 || region.Y+region.Height > 1 {
+			return fmt.Errorf("%w %d: the region must lie within the image, in fractions of its size", ErrInvalidAnnotation, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/transcription"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxAudioNoteSize bounds an uploaded audio note; a few minutes of compressed dictation stays well below it.
+const MaxAudioNoteSize = 10 << 20
+
+const (
+	defaultTranscriptionQueueLimit = 50
+	maxTranscriptionQueueLimit     = 200
+)
+
+// transcribedByStaff marks the notes transcribed by hand
+const transcribedByStaff = "staff"
+
+// audioNoteContentTypes are the recordings phones and dictation apps produce
+var audioNoteContentTypes = map[string]bool{
+	"audio/mpeg":   true,
+	"audio/mp4":    true,
+	"audio/x-m4a":  true,
+	"audio/aac":    true,
+	"audio/ogg":    true,
+	"audio/webm":   true,
+	"audio/wav":    true,
+	"audio/x-wav":  true,
+	"audio/wave":   true,
+	"audio/flac":   true,
+	"audio/x-flac": true,
+}
+
+var (
+	ErrAudioNoteNotFound        = errors.New("audio note not found")
+	ErrInvalidAudioNote         = errors.New("invalid audio note")
+	ErrInvalidTranscript        = errors.New("invalid transcript")
+	ErrAudioNoteTranscribing    = errors.New("the audio note is being transcribed")
+	ErrTranscriptionUnavailable = errors.New("no transcription provider is configured")
+)
+
+// AudioNoteUpload is a recording sent to be filed with an examination
+type AudioNoteUpload struct {
+	FileName        string
+	ContentType     string
+	Content         []byte
+	Title           string
+	DurationSeconds *int
+	RecordedAt      *time.Time // When it was dictated; the upload time when not given
+}
+
+// AudioNoteService files the memos dentists dictate on examinations between patients. Notes wait
+// for the transcription provider, when one is configured, or for staff to transcribe them by hand;
+// the provider's failures are retried up to config.MaxAttempts times before being left to staff.
+type AudioNoteService struct {
+	repository            *repositories.AudioNoteRepository
+	examinationRepository *repositories.ExaminationRepository
+	provider              transcription.Provider
+	config                config.TranscriptionConfig
+}
+
+// NewAudioNoteService starts transcribing waiting notes every config.PollInterval when a provider is configured
+func NewAudioNoteService(repository *repositories.AudioNoteRepository, examinationRepository *repositories.ExaminationRepository, cfg config.TranscriptionConfig) *AudioNoteService {
+	provider, err := transcription.New(cfg)
+	if err != nil {
+		log.Printf("Transcription disabled: %v", err)
+	}
+	s := &AudioNoteService{repository: repository, examinationRepository: examinationRepository, provider: provider, config: cfg}
+	if provider != nil && cfg.PollInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *AudioNoteService) run() {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		if err := s.transcribePending(context.Background()); err != nil {
+			log.Printf("Failed to transcribe audio notes: %v", err)
+		}
+	}
+}
+
+// Upload files a dictated recording with the patient's examination, waiting to be transcribed
+func (s *AudioNoteService) Upload(ctx context.Context, patientID string, examinationID uint, upload AudioNoteUpload) (*models.ExaminationAudioNote, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	contentType := strings.ToLower(strings.TrimSpace(strings.SplitN(upload.ContentType, ";", 2)[0]))
+	title := strings.TrimSpace(upload.Title)
+	switch {
+	case len(upload.Content) == 0:
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidAudioNote)
+	case len(upload.Content) > MaxAudioNoteSize:
+		return nil, fmt.Errorf("%w: the file is larger than %d MB", ErrInvalidAudioNote, MaxAudioNoteSize>>20)
+	case !audioNoteContentTypes[contentType]:
+		return nil, fmt.Errorf("%w: unsupported audio type %q", ErrInvalidAudioNote, contentType)
+	case utf8.RuneCountInString(title) > 255:
+		return nil, fmt.Errorf("%w: the title is longer than 255 characters", ErrInvalidAudioNote)
+	case upload.DurationSeconds != nil && *upload.DurationSeconds < 0:
+		return nil, fmt.Errorf("%w: the duration cannot be negative", ErrInvalidAudioNote)
+	case upload.RecordedAt != nil && upload.RecordedAt.After(time.Now().Add(time.Minute)):
+		return nil, fmt.Errorf("%w: the recording time is in the future", ErrInvalidAudioNote)
+	}
+
+	fileName := strings.TrimSpace(upload.FileName)
+	if fileName == "" {
+		fileName = "audio-note"
+	}
+	recordedAt := time.Now()
+	if upload.RecordedAt != nil {
+		recordedAt = *upload.RecordedAt
+	}
+	note := &models.ExaminationAudioNote{
+		ExaminationID:       examinationID,
+		Title:               title,
+		FileName:            fileName,
+		ContentType:         contentType,
+		Size:                int64(len(upload.Content)),
+		DurationSeconds:     upload.DurationSeconds,
+		Content:             upload.Content,
+		RecordedAt:          recordedAt,
+		TranscriptionStatus: models.TranscriptionPending,
+	}
+	if err := s.repository.Create(ctx, note); err != nil {
+		return nil, err
+	}
+	note.Content = nil
+	return note, nil
+}
+
+// List returns the audio notes of the patient's examination with their transcripts
+func (s *AudioNoteService) List(ctx context.Context, patientID string, examinationID uint) ([]models.ExaminationAudioNote, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	notes, err := s.repository.List(ctx, examinationID)
+	if err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []models.ExaminationAudioNote{}
+	}
+	return notes, nil
+}
+
+// Download returns the audio note with its recording
+func (s *AudioNoteService) Download(ctx context.Context, patientID string, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	note, err := s.repository.GetWithContent(ctx, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if note == nil {
+		return nil, ErrAudioNoteNotFound
+	}
+	return note, nil
+}
+
+// SetTranscript records the transcript staff typed for a note, replacing the provider's if any
+func (s *AudioNoteService) SetTranscript(ctx context.Context, patientID string, examinationID, id uint, transcript string) (*models.ExaminationAudioNote, error) {
+	transcript = strings.TrimSpace(transcript)
+	if transcript == "" {
+		return nil, fmt.Errorf("%w: the transcript is empty", ErrInvalidTranscript)
+	}
+	note, err := s.get(ctx, patientID, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if note.TranscriptionStatus == models.TranscriptionTranscribing {
+		return nil, ErrAudioNoteTranscribing
+	}
+	now := time.Now()
+	note.TranscriptionStatus, note.Transcript, note.TranscriptionError = models.TranscriptionCompleted, transcript, ""
+	note.TranscribedBy, note.TranscribedAt = transcribedByStaff, &now
+	if err := s.repository.SaveTranscription(ctx, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Retranscribe sends a note back to the transcription provider, with a fresh set of attempts
+func (s *AudioNoteService) Retranscribe(ctx context.Context, patientID string, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	if s.provider == nil {
+		return nil, ErrTranscriptionUnavailable
+	}
+	note, err := s.get(ctx, patientID, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if note.TranscriptionStatus == models.TranscriptionTranscribing {
+		return nil, ErrAudioNoteTranscribing
+	}
+	note.TranscriptionStatus, note.TranscriptionError, note.Attempts = models.TranscriptionPending, "", 0
+	if err := s.repository.SaveTranscription(ctx, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *AudioNoteService) Delete(ctx context.Context, patientID string, examinationID, id uint) error {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, examinationID, id)
+}
+
+// Queue returns the notes across all patients in a transcription state, pending by default, for
+// staff to work through oldest first
+func (s *AudioNoteService) Queue(ctx context.Context, status string, limit int) ([]models.TranscriptionQueueItem, error) {
+	if status == "" {
+		status = models.TranscriptionPending
+	}
+	if !slices.Contains(models.TranscriptionStatuses, status) {
+		return nil, fmt.Errorf("%w: status must be one of %s", ErrInvalidAudioNote, strings.Join(models.TranscriptionStatuses, ", "))
+	}
+	if limit == 0 {
+		limit = defaultTranscriptionQueueLimit
+	}
+	if limit < 0 || limit > maxTranscriptionQueueLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAudioNote, maxTranscriptionQueueLimit)
+	}
+	items, err := s.repository.Queue(ctx, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []models.TranscriptionQueueItem{}
+	}
+	return items, nil
+}
+
+// transcribePending hands the waiting notes to the provider one at a time until none is left. Notes
+// claimed by a replica that stopped before finishing are picked up again once the timeout has passed.
+func (s *AudioNoteService) transcribePending(ctx context.Context) error {
+	for {
+		note, err := s.repository.ClaimPending(ctx, s.config.MaxAttempts, time.Now().Add(-2*s.config.Timeout))
+		if err != nil || note == nil {
+			return err
+		}
+		s.transcribe(ctx, note)
+	}
+}
+
+// transcribe sends a claimed note to the provider and records the outcome. A failed note goes back
+// to wait for another try, or is left to staff once it has used up its attempts.
+func (s *AudioNoteService) transcribe(ctx context.Context, note *models.ExaminationAudioNote) {
+	transcribeCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	text, err := s.provider.Transcribe(transcribeCtx, transcription.Audio{
+		FileName:    note.FileName,
+		ContentType: note.ContentType,
+		Content:     note.Content,
+		Language:    s.config.Language,
+	})
+	cancel()
+	if err == nil && strings.TrimSpace(text) == "" {
+		err = errors.New("the provider returned an empty transcript")
+	}
+
+	if err != nil {
+		log.Printf("Failed to transcribe audio note %d (attempt %d): %v", note.ID, note.Attempts, err)
+		note.TranscriptionStatus, note.TranscriptionError = models.TranscriptionPending, err.Error()
+		if note.Attempts >= s.config.MaxAttempts {
+			note.TranscriptionStatus = models.TranscriptionFailed
+		}
+	} else {
+		now := time.Now()
+		note.TranscriptionStatus, note.Transcript, note.TranscriptionError = models.TranscriptionCompleted, strings.TrimSpace(text), ""
+		note.TranscribedBy, note.TranscribedAt = s.provider.Name(), &now
+	}
+	if err := s.repository.SaveTranscription(ctx, note); err != nil {
+		log.Printf("Failed to save the transcription of audio note %d: %v", note.ID, err)
+	}
+}
+
+func (s *AudioNoteService) get(ctx context.Context, patientID string, examinationID, id uint) (*models.ExaminationAudioNote, error) {
+	if err := s.checkExamination(ctx, patientID, examinationID); err != nil {
+		return nil, err
+	}
+	note, err := s.repository.GetByID(ctx, examinationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if note == nil {
+		return nil, ErrAudioNoteNotFound
+	}
+	return note, nil
+}
+
+// checkExamination makes sure the examination belongs to the patient in the URL
+func (s *AudioNoteService) checkExamination(ctx context.Context, patientID string, examinationID uint) error {
+	examination, err := s.examinationRepository.GetByID(ctx, patientID, examinationID)
+	if err != nil {
+		return err
+	}
+	if examination == nil {
+		return ErrExaminationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// auditArchiveLockKey serialises shipping across replicas sharing the archive.
+	auditArchiveLockKey = "audit_archive"
+	// auditArchiveMaxLine bounds a line of the archive, an audit entry with its hashes.
+	auditArchiveMaxLine = 1 << 20
+	// auditArchiveCompareChunk is how many archived entries are compared with the audit trail at once.
+	auditArchiveCompareChunk = 500
+	// auditArchiveMaxListed caps the problems of each kind a verification lists.
+	auditArchiveMaxListed = 100
+)
+
+var (
+	ErrAuditArchiveDisabled = errors.New("the audit archive is not configured")
+	ErrAuditArchiveMismatch = errors.New("the audit archive does not match the batches recorded in the database")
+)
+
+// errAuditArchiveCorrupt is returned when a line of the archive is not an archive record
+var errAuditArchiveCorrupt = errors.New("the audit archive holds a line that is not an archive record")
+
+// archiveHead is where the archive ends: its last line and the last audit entry shipped
+type archiveHead struct {
+	seq     int64
+	hash    string
+	auditID int64
+}
+
+// AuditArchiveService ships the audit trail, security events included, to an append-only file
+// outside the database in which every line is chained to the one before by its hash, and checks
+// that neither the file nor the audit trail changed since
+type AuditArchiveService struct {
+	repository *repositories.AuditArchiveRepository
+	config     config.AuditArchiveConfig
+	mu         sync.Mutex
+}
+
+// NewAuditArchiveService starts shipping new audit entries every config.Interval when an archive
+// path is configured
+func NewAuditArchiveService(repository *repositories.AuditArchiveRepository, cfg config.AuditArchiveConfig) *AuditArchiveService {
+	s := &AuditArchiveService{repository: repository, config: cfg}
+	if s.Enabled() && cfg.Interval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *AuditArchiveService) Enabled() bool {
+	return s.config.Path != ""
+}
+
+func (s *AuditArchiveService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		if _, err := s.Ship(context.Background()); err != nil {
+			log.Printf("Failed to ship audit logs to the archive: %v", err)
+		}
+	}
+}
+
+// Ship appends the audit entries written since the last batch to the archive, a batch at a time,
+// and returns how many it shipped. It stops with ErrAuditArchiveMismatch when the archive no
+// longer ends where the last recorded batch did.
+func (s *AuditArchiveService) Ship(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, ErrAuditArchiveDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	release, err := database.Locks.Acquire(ctx, auditArchiveLockKey)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	last, err := s.repository.LastBatch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	head, err := s.reconcile(ctx, last)
+	if err != nil {
+		return 0, err
+	}
+
+	shipped := 0
+	for {
+		entries, err := s.repository.Unshipped(ctx, head.auditID, time.Now().Add(-s.config.Lag), s.config.BatchSize)
+		if err != nil {
+			return shipped, err
+		}
+		if len(entries) == 0 {
+			return shipped, nil
+		}
+		batch, err := s.append(head, entries)
+		if err != nil {
+			return shipped, err
+		}
+		if err := s.repository.RecordBatch(ctx, batch); err != nil {
+			return shipped, err
+		}
+		head = archiveHead{seq: batch.LastSeq, hash: batch.Hash, auditID: batch.LastAuditID}
+		shipped += len(entries)
+		if len(entries) < s.config.BatchSize {
+			return shipped, nil
+		}
+	}
+}
+
+// Status reports how far the audit trail has been shipped
+func (s *AuditArchiveService) Status(ctx context.Context) (*models.AuditArchiveStatus, error) {
+	status := models.AuditArchiveStatus{Enabled: s.Enabled()}
+	batches, err := s.repository.CountBatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Batches = batches
+	last, err := s.repository.LastBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		status.LastSeq, status.LastAuditID, status.Hash = last.LastSeq, last.LastAuditID, last.Hash
+		status.LastShippedAt = &last.CreatedAt
+	}
+	if status.Pending, err = s.repository.CountUnshipped(ctx, status.LastAuditID); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Verify recomputes the archive's hash chain, checks that it still reproduces every batch
+// recorded in the database, and compares each archived entry with the audit trail
+func (s *AuditArchiveService) Verify(ctx context.Context) (*models.AuditArchiveVerification, error) {
+	if !s.Enabled() {
+		return nil, ErrAuditArchiveDisabled
+	}
+	batches, err := s.repository.Batches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recorded := make(map[int64]string, len(batches))
+	for _, batch := range batches {
+		recorded[batch.LastSeq] = batch.Hash
+	}
+
+	verification := models.AuditArchiveVerification{VerifiedAt: time.Now()}
+	var seq int64
+	var prev string
+	var archived []models.AuditLog
+	err = readAuditArchive(s.config.Path, func(record *models.AuditArchiveRecord) error {
+		verification.Records++
+		if verification.BrokenAtSeq == nil &&
+			(record.Seq != seq+1 || record.PrevHash != prev || record.Hash != auditArchiveHash(prev, record.Seq, record.Entry)) {
+			broken := record.Seq
+			verification.BrokenAtSeq = &broken
+		}
+		if verification.BrokenAtSeq == nil && recorded[record.Seq] == record.Hash {
+			delete(recorded, record.Seq)
+		}
+		seq, prev = record.Seq, record.Hash
+
+		var entry models.AuditLog
+		if err := json.Unmarshal(record.Entry, &entry); err != nil {
+			return fmt.Errorf("%w: line %d", errAuditArchiveCorrupt, verification.Records)
+		}
+		archived = append(archived, entry)
+		if len(archived) == auditArchiveCompareChunk {
+			if err := s.compare(ctx, archived, &verification); err != nil {
+				return err
+			}
+			archived = archived[:0]
+		}
+		return nil
+	})
+	if errors.Is(err, errAuditArchiveCorrupt) {
+		broken := seq + 1
+		verification.BrokenAtSeq = &broken
+	} else if err != nil {
+		return nil, err
+	}
+	if len(archived) > 0 {
+		if err := s.compare(ctx, archived, &verification); err != nil {
+			return nil, err
+		}
+	}
+
+	for lastSeq := range recorded {
+		verification.MissingBatches = append(verification.MissingBatches, lastSeq)
+	}
+	sort.Slice(verification.MissingBatches, func(i, j int) bool {
+		return verification.MissingBatches[i] < verification.MissingBatches[j]
+	})
+	if len(verification.MissingBatches) > auditArchiveMaxListed {
+		verification.MissingBatches = verification.MissingBatches[:auditArchiveMaxListed]
+		verification.Truncated = true
+	}
+
+	verification.Valid = verification.BrokenAtSeq == nil && len(verification.MissingBatches) == 0 &&
+		len(verification.AlteredEntries) == 0 && len(verification.DeletedEntries) == 0
+	return &verification, nil
+}
+
+// compare records the archived entries that were since changed in or removed from the audit trail
+func (s *AuditArchiveService) compare(ctx context.Context, archived []models.AuditLog, verification *models.AuditArchiveVerification) error {
+	ids := make([]int64, len(archived))
+	for i, entry := range archived {
+		ids[i] = entry.ID
+	}
+	current, err := s.repository.AuditEntries(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, entry := range archived {
+		stored, ok := current[entry.ID]
+		switch {
+		case !ok:
+			verification.DeletedEntries, verification.Truncated = appendListed(verification.DeletedEntries, entry.ID, verification.Truncated)
+		case !sameAuditEntry(entry, stored):
+			verification.AlteredEntries, verification.Truncated = appendListed(verification.AlteredEntries, entry.ID, verification.Truncated)
+		}
+	}
+	return nil
+}
+
+// reconcile returns where the archive ends, checking it ends where last, the last recorded batch,
+// did. Lines written after last whose batch could not be recorded are recorded now.
+func (s *AuditArchiveService) reconcile(ctx context.Context, last *models.AuditArchiveBatch) (archiveHead, error) {
+	var head archiveHead
+	if last != nil {
+		head = archiveHead{seq: last.LastSeq, hash: last.Hash, auditID: last.LastAuditID}
+	}
+	tail, err := lastAuditArchiveRecord(s.config.Path)
+	if err != nil {
+		return head, err
+	}
+	switch {
+	case tail == nil && last == nil:
+		return head, nil
+	case tail == nil:
+		return head, fmt.Errorf("%w: the archive is empty", ErrAuditArchiveMismatch)
+	case tail.Seq == head.seq && tail.Hash == head.hash:
+		return head, nil
+	case tail.Seq < head.seq:
+		return head, fmt.Errorf("%w: the archive ends at line %d, before line %d", ErrAuditArchiveMismatch, tail.Seq, head.seq)
+	}
+
+	// The archive runs past the last recorded batch, so record the lines after it as a batch of
+	// their own once they are shown to follow from it
+	batch := models.AuditArchiveBatch{FirstSeq: head.seq + 1}
+	seq, prev := head.seq, head.hash
+	err = readAuditArchive(s.config.Path, func(record *models.AuditArchiveRecord) error {
+		if record.Seq <= head.seq {
+			return nil
+		}
+		if record.Seq != seq+1 || record.PrevHash != prev || record.Hash != auditArchiveHash(prev, record.Seq, record.Entry) {
+			return fmt.Errorf("%w: line %d does not follow from the last recorded batch", ErrAuditArchiveMismatch, record.Seq)
+		}
+		var entry models.AuditLog
+		if err := json.Unmarshal(record.Entry, &entry); err != nil {
+			return fmt.Errorf("%w: line %d", errAuditArchiveCorrupt, record.Seq)
+		}
+		if batch.FirstAuditID == 0 {
+			batch.FirstAuditID = entry.ID
+		}
+		batch.LastAuditID, batch.LastSeq, batch.Hash = entry.ID, record.Seq, record.Hash
+		seq, prev = record.Seq, record.Hash
+		return nil
+	})
+	if err != nil {
+		return head, err
+	}
+	if batch.LastSeq == 0 {
+		return head, fmt.Errorf("%w: the archive does not reproduce line %d", ErrAuditArchiveMismatch, head.seq)
+	}
+	if err := s.repository.RecordBatch(ctx, &batch); err != nil {
+		return head, err
+	}
+	log.Printf("Recorded audit archive lines %d to %d written before their batch could be recorded", batch.FirstSeq, batch.LastSeq)
+	return archiveHead{seq: batch.LastSeq, hash: batch.Hash, auditID: batch.LastAuditID}, nil
+}
+
+// append writes entries to the end of the archive, chained on from head, and returns their batch
+func (s *AuditArchiveService) append(head archiveHead, entries []models.AuditLog) (*models.AuditArchiveBatch, error) {
+	batch := models.AuditArchiveBatch{FirstAuditID: entries[0].ID, FirstSeq: head.seq + 1}
+	var buf bytes.Buffer
+	seq, prev := head.seq, head.hash
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode audit log %d: %w", entry.ID, err)
+		}
+		seq++
+		record := models.AuditArchiveRecord{Seq: seq, Entry: data, PrevHash: prev, Hash: auditArchiveHash(prev, seq, data)}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode audit archive record: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		prev = record.Hash
+	}
+
+	file, err := os.OpenFile(s.config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit archive: %w", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to sync audit archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close audit archive: %w", err)
+	}
+
+	batch.LastAuditID, batch.LastSeq, batch.Hash = entries[len(entries)-1].ID, seq, prev
+	return &batch, nil
+}
+
+// auditArchiveHash chains an archived entry to the line before it
+func auditArchiveHash(prev string, seq int64, entry []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write([]byte("\n" + strconv.FormatInt(seq, 10) + "\n"))
+	h.Write(entry)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readAuditArchive calls fn with every record of the archive at path in order. A missing archive
+// has no records.
+func readAuditArchive(path string, fn func(record *models.AuditArchiveRecord) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit archive: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), auditArchiveMaxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record models.AuditArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: line %d", errAuditArchiveCorrupt, line)
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit archive: %w", err)
+	}
+	return nil
+}
+
+// lastAuditArchiveRecord returns the last record of the archive at path, or nil when it is missing or empty
+func lastAuditArchiveRecord(path string) (*models.AuditArchiveRecord, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit archive: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit archive: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+	offset := size - auditArchiveMaxLine
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, size-offset)
+	if _, err := file.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read audit archive: %w", err)
+	}
+	data = bytes.TrimRight(data, "\n")
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	var record models.AuditArchiveRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("%w: the last line", errAuditArchiveCorrupt)
+	}
+	return &record, nil
+}
+
+// sameAuditEntry reports whether two copies of an audit entry are the same
+func sameAuditEntry(a, b models.AuditLog) bool {
+	return a.ID == b.ID && a.Category == b.Category && a.Event == b.Event && a.UserID == b.UserID &&
+		a.Role == b.Role && a.IP == b.IP && a.Method == b.Method && a.Route == b.Route &&
+		a.Status == b.Status && a.Detail == b.Detail && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+// appendListed adds id to list unless it already holds as many as are listed, reporting whether
+// anything was left out
+func appendListed(list []int64, id int64, truncated bool) ([]int64, bool) {
+	if len(list) >= auditArchiveMaxListed {
+		return list, true
+	}
+	return append(list, id), truncated
+}
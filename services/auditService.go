@@ -0,0 +1,209 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/events"
+	"RoyDental/metrics"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditQueueSize bounds the audit entries waiting to be written.
+const auditQueueSize = 1024
+
+var auditDropped = metrics.NewCounterVec("audit_entries_dropped_total", "Audit entries discarded because the write queue was full.")
+
+var authFailures = metrics.NewCounterVec("auth_failures_total", "Rejected authorization attempts by event.", "event")
+
+type AuditService struct {
+	repository *repositories.AuditRepository
+	notifier   notifications.Notifier
+	config     config.AuditConfig
+	queue      chan models.AuditLog
+}
+
+// NewAuditService starts a background writer so recording an entry never blocks the request.
+func NewAuditService(repository *repositories.AuditRepository, notifier notifications.Notifier, cfg config.AuditConfig) *AuditService {
+	s := &AuditService{
+		repository: repository,
+		notifier:   notifier,
+		config:     cfg,
+		queue:      make(chan models.AuditLog, auditQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// RecordAuthFailure queues an authorization failure for the security audit trail.
+func (s *AuditService) RecordAuthFailure(_ context.Context, entry models.AuditLog) {
+	authFailures.Inc(entry.Event)
+	log.Printf("Authorization failure: event=%s ip=%s route=%s %s user=%s", entry.Event, entry.IP, entry.Method, entry.Route, entry.UserID)
+
+	entry.Category = models.AuditCategorySecurity
+	entry.CreatedAt = time.Now()
+	select {
+	case s.queue <- entry:
+	default:
+		auditDropped.Inc()
+	}
+}
+
+// RecordSecurityEvent queues a security event that is not a failure, such as a sign-in.
+func (s *AuditService) RecordSecurityEvent(_ context.Context, entry models.AuditLog) {
+	entry.Category = models.AuditCategorySecurity
+	entry.CreatedAt = time.Now()
+	select {
+	case s.queue <- entry:
+	default:
+		auditDropped.Inc()
+	}
+}
+
+// activityEvents names the activity audit event of each domain event that is kept in the audit trail.
+var activityEvents = map[string]string{
+	events.PatientDeleted:       models.AuditEventPatientDeleted,
+	events.PaymentRecorded:      models.AuditEventPaymentRecorded,
+	events.AppointmentCancelled: models.AuditEventAppointmentCancelled,
+}
+
+// HandleDomainEvent queues an activity audit entry for a domain event published on the event bus.
+func (s *AuditService) HandleDomainEvent(_ context.Context, event events.Event) error {
+	name, ok := activityEvents[event.Name]
+	if !ok {
+		return nil
+	}
+
+	entry := models.AuditLog{
+		Category:  models.AuditCategoryActivity,
+		Event:     name,
+		Detail:    activityDetail(event),
+		CreatedAt: event.OccurredAt,
+	}
+	if event.ActorID != nil {
+		entry.UserID = strconv.FormatInt(*event.ActorID, 10)
+	}
+	select {
+	case s.queue <- entry:
+	default:
+		auditDropped.Inc()
+	}
+	return nil
+}
+
+func activityDetail(event events.Event) string {
+	detail := fmt.Sprintf("patient=%s", event.PatientID)
+	if event.Record != "" {
+		detail += fmt.Sprintf(" %s=%s", event.Record, event.RecordID)
+	}
+	if event.Name == events.PaymentRecorded {
+		detail += fmt.Sprintf(" amount=%.2f", event.Amount)
+	}
+	for table, ids := range event.Related {
+		detail += fmt.Sprintf(" %s=%d", table, len(ids))
+	}
+	return detail
+}
+
+// Record writes an entry to the audit trail straight away, such as a clinical decision a user
+// confirmed, so the caller can refuse to go ahead when it cannot be recorded.
+func (s *AuditService) Record(ctx context.Context, entry models.AuditLog) error {
+	return s.repository.Create(ctx, &entry)
+}
+
+// ListClinicalEvents returns clinical audit entries matching filter with the total number of matches.
+func (s *AuditService) ListClinicalEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	filter.Category = models.AuditCategoryClinical
+	return s.repository.List(ctx, filter)
+}
+
+// ListActivityEvents returns activity audit entries matching filter with the total number of matches.
+func (s *AuditService) ListActivityEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	filter.Category = models.AuditCategoryActivity
+	return s.repository.List(ctx, filter)
+}
+
+// ListAuthFailures returns security audit entries matching filter with the total number of matches.
+func (s *AuditService) ListAuthFailures(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, int64, error) {
+	filter.Category = models.AuditCategorySecurity
+	return s.repository.List(ctx, filter)
+}
+
+func (s *AuditService) run() {
+	for entry := range s.queue {
+		entry := entry
+		ctx, cancel := context.WithTimeout(context.Background(), database.Timeouts.DBWrite)
+		if err := s.repository.Create(ctx, &entry); err != nil {
+			log.Printf("Failed to persist audit entry: %v", err)
+		}
+		// Only authorization failures count towards the alert thresholds
+		if entry.Category == models.AuditCategorySecurity {
+			s.checkThreshold(ctx, "ip", entry.IP, entry)
+			if entry.UserID != "" {
+				s.checkThreshold(ctx, "user", entry.UserID, entry)
+			}
+		}
+		cancel()
+	}
+}
+
+// checkThreshold counts failures per IP or user in Redis, shared across replicas, and alerts
+// once when the count reaches the threshold within the window.
+func (s *AuditService) checkThreshold(ctx context.Context, kind, subject string, entry models.AuditLog) {
+	if s.config.AuthFailureAlertThreshold <= 0 || subject == "" {
+		return
+	}
+	if database.RedisClient == nil || !database.RedisBreaker.Allow() {
+		return
+	}
+
+	key := fmt.Sprintf("auth_failures:%s:%s", kind, subject)
+	count, err := database.RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		database.RedisBreaker.Failure()
+		log.Printf("Failed to count authorization failures: %v", err)
+		return
+	}
+	database.RedisBreaker.Success()
+	if count == 1 {
+		database.RedisClient.Expire(ctx, key, s.config.AuthFailureAlertWindow)
+	}
+	if count != int64(s.config.AuthFailureAlertThreshold) {
+		return
+	}
+
+	alert := notifications.Notification{
+		Recipients: s.config.SecurityAlertRecipients,
+		Subject:    fmt.Sprintf("Security alert: %d authorization failures from %s %s", count, kind, subject),
+		Body: fmt.Sprintf("%d authorization failures were recorded for %s %s within %s.\n\nLatest: %s on %s %s (IP %s, user %q) at %s.",
+			count, kind, subject, s.config.AuthFailureAlertWindow, entry.Event, entry.Method, entry.Route, entry.IP, entry.UserID, entry.CreatedAt.Format(time.RFC3339)),
+	}
+	if err := s.notifier.Send(ctx, alert); err != nil {
+		log.Printf("Failed to send security alert: %v", err)
+	}
+}
+
+// adminAlertRecipients returns the addresses of the admins followed by the extra recipients, each
+// once, for alerts the admins are sent along with the security alert recipients. The extra
+// recipients are returned even when the admins could not be looked up.
+func adminAlertRecipients(ctx context.Context, userRepo repositories.UserRepository, extra []string) ([]string, error) {
+	recipients, err := userRepo.AdminEmails(ctx)
+	seen := make(map[string]bool, len(recipients)+len(extra))
+	for _, recipient := range recipients {
+		seen[strings.ToLower(recipient)] = true
+	}
+	for _, recipient := range extra {
+		if !seen[strings.ToLower(recipient)] {
+			seen[strings.ToLower(recipient)] = true
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients, err
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/repositories"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuthRateLimitService limits attempts at logging in and resetting passwords per email address and
+// per client address, to stop credential stuffing and reset-code spam
+type AuthRateLimitService struct {
+	repository *repositories.AuthRateLimitRepository
+	config     config.AuthRateLimitConfig
+}
+
+func NewAuthRateLimitService(repository *repositories.AuthRateLimitRepository, cfg config.AuthRateLimitConfig) *AuthRateLimitService {
+	return &AuthRateLimitService{repository: repository, config: cfg}
+}
+
+// Enabled reports whether the auth endpoints are rate limited
+func (s *AuthRateLimitService) Enabled() bool {
+	return s.config.Window > 0 && (s.config.PerEmail > 0 || s.config.PerIP > 0)
+}
+
+// CheckAuthAttempt counts an attempt at endpoint for the email, which may be empty, and the client
+// address, and returns how long until another is allowed once either is over its limit, or 0.
+// Emails are counted by a hash so the addresses are not kept in Redis.
+func (s *AuthRateLimitService) CheckAuthAttempt(ctx context.Context, endpoint, email, ip string) (time.Duration, error) {
+	var retryAfter time.Duration
+	if s.config.PerIP > 0 && ip != "" {
+		wait, err := s.hit(ctx, fmt.Sprintf("%s:ip:%s", endpoint, ip), s.config.PerIP)
+		if err != nil {
+			return 0, err
+		}
+		retryAfter = wait
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if s.config.PerEmail > 0 && email != "" {
+		sum := sha256.Sum256([]byte(email))
+		wait, err := s.hit(ctx, fmt.Sprintf("%s:email:%s", endpoint, hex.EncodeToString(sum[:])), s.config.PerEmail)
+		if err != nil {
+			return 0, err
+		}
+		retryAfter = max(retryAfter, wait)
+	}
+	return retryAfter, nil
+}
+
+// hit counts an attempt against key and returns the time left in its window once it is over limit
+func (s *AuthRateLimitService) hit(ctx context.Context, key string, limit int) (time.Duration, error) {
+	count, ttl, err := s.repository.Hit(ctx, key, s.config.Window)
+	if err != nil || count <= int64(limit) {
+		return 0, err
+	}
+	return ttl, nil
+}
@@ -1,22 +1,17 @@
 package services
 
 import (
+	"RoyDental/cache"
 	"RoyDental/database"
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"RoyDental/utils"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
-)
-
-const (
-	UserCacheExpiry = 7 * 24 * time.Hour
+	"gorm.io/gorm"
 )
 
 type UserService interface {
@@ -42,45 +37,35 @@ func NewUserService(userRepo repositories.UserRepository) UserService {
 }
 
 func (s *userService) ValidateAndCreateUser(ctx context.Context, user *models.User) error {
-	lockKey := fmt.Sprintf("user_lock:%s", user.Email)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	// Validate user data before creating
-	if err := utils.ValidateUserData(*user); err != nil {
-		return fmt.Errorf("invalid user data: %w", err)
-	}
+	return database.WithLock(ctx, fmt.Sprintf("user_lock:%s", user.Email), func(*gorm.DB) error {
+		// Validate user data before creating
+		if err := utils.ValidateUserData(*user); err != nil {
+			return fmt.Errorf("invalid user data: %w", err)
+		}
 
-	if user.Password == "" {
-		return errors.New("password cannot be blank")
-	}
+		if user.Password == "" {
+			return errors.New("password cannot be blank")
+		}
 
-	if exists, err := s.userRepo.EmailExists(ctx, user.Email); err != nil || exists {
-		return errors.New("email already registered")
-	}
+		if exists, err := s.userRepo.EmailExists(ctx, user.Email); err != nil || exists {
+			return errors.New("email already registered")
+		}
 
-	if err := s.userRepo.ValidateRoleID(ctx, user.RoleID); err != nil {
-		return fmt.Errorf("invalid role ID: %w", err)
-	}
+		if err := s.userRepo.ValidateRoleID(ctx, user.RoleID); err != nil {
+			return fmt.Errorf("invalid role ID: %w", err)
+		}
 
-	hashedPassword, err := utils.HashPassword(user.Password)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-	user.Password = hashedPassword
+		hashedPassword, err := utils.HashPassword(user.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		user.Password = hashedPassword
 
-	return s.userRepo.CreateUser(ctx, user)
+		return s.userRepo.CreateUser(ctx, user)
+	})
 }
 
 func (s *userService) AuthenticateUser(ctx context.Context, email, password string) (*models.User, error) {
@@ -94,82 +79,66 @@ func (s *userService) AuthenticateUser(ctx context.Context, email, password stri
 	}
 
 	// Cache the user data on successful login
-	userJSON, err := json.Marshal(user)
+	userData, err := cache.Encode(user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal user data: %w", err)
+		return nil, fmt.Errorf("failed to encode user data: %w", err)
 	}
-	cacheKey := fmt.Sprintf("user_cache:%s", email)
-	if err := database.RedisClient.Set(ctx, cacheKey, userJSON, UserCacheExpiry).Err(); err != nil {
+	if err := database.RedisClient.Set(ctx, userCacheKey(email), userData, cache.ItemTTL("user")).Err(); err != nil {
 		log.Printf("Failed to set user in cache: %v", err)
 	}
 
 	return user, nil
 }
 
+// userCacheKey returns the key a user signed in with email is cached under for their session
+func userCacheKey(email string) string {
+	return "user_cache:" + email
+}
+
 func (s *userService) UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error {
-	lockKey := fmt.Sprintf("user_lock:%d", userID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	if err := s.userRepo.UpdateUserEmail(ctx, userID, newEmail); err != nil {
-		return fmt.Errorf("failed to update user email: %w", err)
-	}
+	return database.WithLock(ctx, fmt.Sprintf("user_lock:%d", userID), func(*gorm.DB) error {
+		if err := s.userRepo.UpdateUserEmail(ctx, userID, newEmail); err != nil {
+			return fmt.Errorf("failed to update user email: %w", err)
+		}
 
-	// Invalidate cache for both old and new email
-	if err := s.userRepo.DeleteUserCache(ctx, newEmail); err != nil {
-		return fmt.Errorf("failed to delete user cache: %w", err)
-	}
-	user, err := s.userRepo.GetUserByID(ctx, userID)
-	if err != nil {
-		return fmt.Errorf("failed to get user by ID: %w", err)
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
-	return s.userRepo.DeleteUserCache(ctx, user.Email)
+		// Invalidate cache for both old and new email
+		if err := s.userRepo.DeleteUserCache(ctx, newEmail); err != nil {
+			return fmt.Errorf("failed to delete user cache: %w", err)
+		}
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user by ID: %w", err)
+		}
+		if user == nil {
+			return errors.New("user not found")
+		}
+		return s.userRepo.DeleteUserCache(ctx, user.Email)
+	})
 }
 
 func (s *userService) UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
-	lockKey := fmt.Sprintf("user_lock:%d", userID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	if err := s.userRepo.UpdateUserPassword(ctx, userID, hashedPassword); err != nil {
-		return fmt.Errorf("failed to update user password: %w", err)
-	}
+	return database.WithLock(ctx, fmt.Sprintf("user_lock:%d", userID), func(*gorm.DB) error {
+		if err := s.userRepo.UpdateUserPassword(ctx, userID, hashedPassword); err != nil {
+			return fmt.Errorf("failed to update user password: %w", err)
+		}
 
-	user, err := s.userRepo.GetUserByID(ctx, userID)
-	if err != nil {
-		return fmt.Errorf("failed to get user by ID: %w", err)
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user by ID: %w", err)
+		}
+		if user == nil {
+			return errors.New("user not found")
+		}
 
-	// Invalidate cache for the user
-	return s.userRepo.DeleteUserCache(ctx, user.Username)
+		// Invalidate cache for the user
+		return s.userRepo.DeleteUserCache(ctx, user.Username)
+	})
 }
 
 func (s *userService) GetAllUsers(ctx context.Context) ([]models.User, error) {
@@ -189,27 +158,17 @@ func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models
 }
 
 func (s *userService) UpdateUserProfile(ctx context.Context, userID int64, username, email string) error {
-	lockKey := fmt.Sprintf("user_lock:%d", userID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	if err := s.userRepo.UpdateUserProfile(ctx, userID, username, email); err != nil {
-		return fmt.Errorf("failed to update user profile: %w", err)
-	}
+	return database.WithLock(ctx, fmt.Sprintf("user_lock:%d", userID), func(*gorm.DB) error {
+		if err := s.userRepo.UpdateUserProfile(ctx, userID, username, email); err != nil {
+			return fmt.Errorf("failed to update user profile: %w", err)
+		}
 
-	// Invalidate cache for the user
-	return s.userRepo.DeleteUserCache(ctx, username)
+		// Invalidate cache for the user
+		return s.userRepo.DeleteUserCache(ctx, username)
+	})
 }
 
 func (s *userService) GetUserPermissions(ctx context.Context, userID int64) ([]models.Permission, error) {
@@ -217,33 +176,23 @@ func (s *userService) GetUserPermissions(ctx context.Context, userID int64) ([]m
 }
 
 func (s *userService) DeleteUser(ctx context.Context, userID int64) error {
-	lockKey := fmt.Sprintf("user_lock:%d", userID)
-	lockValue := uuid.New().String() // Generate a unique lock value
-	locked, err := database.NewLock(ctx, lockKey, lockValue, time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
-	}
-	if !locked {
-		return errors.New("failed to acquire lock")
-	}
-	defer func() {
-		if err := database.ReleaseLock(ctx, lockKey, lockValue); err != nil {
-			log.Printf("Failed to release lock: %v", err)
-		}
-	}()
+	ctx, cancel := database.WithWriteTimeout(ctx)
+	defer cancel()
 
-	user, err := s.userRepo.GetUserByID(ctx, userID)
-	if err != nil {
-		return fmt.Errorf("failed to get user by ID: %w", err)
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
+	return database.WithLock(ctx, fmt.Sprintf("user_lock:%d", userID), func(*gorm.DB) error {
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user by ID: %w", err)
+		}
+		if user == nil {
+			return errors.New("user not found")
+		}
 
-	// Invalidate cache for the user
-	if err := s.userRepo.DeleteUserCache(ctx, user.Username); err != nil {
-		return fmt.Errorf("failed to delete user cache: %w", err)
-	}
+		// Invalidate cache for the user
+		if err := s.userRepo.DeleteUserCache(ctx, user.Username); err != nil {
+			return fmt.Errorf("failed to delete user cache: %w", err)
+		}
 
-	return s.userRepo.DeleteUser(ctx, userID)
+		return s.userRepo.DeleteUser(ctx, userID)
+	})
 }
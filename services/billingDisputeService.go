@@ -0,0 +1,141 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBillingDisputeNotFound = errors.New("billing dispute not found")
+	ErrInvalidBillingDispute  = errors.New("invalid billing dispute")
+)
+
+// BillingDisputeService tracks queries raised about bills until they are resolved or rejected.
+// Corrections are made as billing adjustments linked to the dispute.
+type BillingDisputeService struct {
+	repository  *repositories.BillingDisputeRepository
+	billingRepo *repositories.BillingRepository
+}
+
+func NewBillingDisputeService(repository *repositories.BillingDisputeRepository, billingRepo *repositories.BillingRepository) *BillingDisputeService {
+	return &BillingDisputeService{repository: repository, billingRepo: billingRepo}
+}
+
+// Create opens a dispute about a bill
+func (s *BillingDisputeService) Create(ctx context.Context, dispute *models.BillingDispute) (*models.BillingDispute, error) {
+	dispute.Reason = strings.TrimSpace(dispute.Reason)
+	dispute.Reference = strings.TrimSpace(dispute.Reference)
+	if dispute.RaisedBy != models.DisputeRaisedByPatient && dispute.RaisedBy != models.DisputeRaisedByInsurer {
+		return nil, fmt.Errorf("%w: raised_by must be %s or %s", ErrInvalidBillingDispute, models.DisputeRaisedByPatient, models.DisputeRaisedByInsurer)
+	}
+	if dispute.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidBillingDispute)
+	}
+	if dispute.Amount != nil && *dispute.Amount <= 0 {
+		return nil, fmt.Errorf("%w: the disputed amount must be positive", ErrInvalidBillingDispute)
+	}
+
+	billing, err := s.billingRepo.GetByID(ctx, dispute.BillingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+
+	dispute.ID = 0
+	dispute.Status = models.DisputeStatusOpen
+	dispute.ResolutionNote = ""
+	dispute.ResolvedAt = nil
+	if err := s.repository.Create(ctx, dispute); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, dispute.ID)
+}
+
+func (s *BillingDisputeService) Get(ctx context.Context, id uint) (*models.BillingDispute, error) {
+	dispute, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil {
+		return nil, ErrBillingDisputeNotFound
+	}
+	return dispute, nil
+}
+
+func (s *BillingDisputeService) ListByBilling(ctx context.Context, billingID string) ([]models.BillingDispute, error) {
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+	disputes, err := s.repository.ListByBilling(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if disputes == nil {
+		disputes = []models.BillingDispute{}
+	}
+	return disputes, nil
+}
+
+// Update moves a dispute that is still open along. Resolved and rejected disputes are final; settling
+// one needs a note on the outcome.
+func (s *BillingDisputeService) Update(ctx context.Context, id uint, update models.BillingDisputeUpdate) (*models.BillingDispute, error) {
+	update.ResolutionNote = strings.TrimSpace(update.ResolutionNote)
+	if !models.IsValidDisputeStatus(update.Status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidBillingDispute, update.Status)
+	}
+	if !models.IsOpenDisputeStatus(update.Status) && update.ResolutionNote == "" {
+		return nil, fmt.Errorf("%w: a resolution note is required to settle a dispute", ErrInvalidBillingDispute)
+	}
+
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repository.SetStatus(ctx, id, update.Status, update.ResolutionNote); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// OpenDisputeReport lists the disputes still being worked on, oldest first, with how long they have
+// been open and the amounts they hold up. A bill disputed twice counts its balance once.
+func (s *BillingDisputeService) OpenDisputeReport(ctx context.Context) (*models.OpenDisputeReport, error) {
+	disputes, err := s.repository.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	today, _ := models.ClinicDay(time.Now())
+	report := &models.OpenDisputeReport{ByRaisedBy: map[string]int{}, Disputes: []models.OpenDispute{}}
+	billed := map[string]bool{}
+	for _, dispute := range disputes {
+		opened, _ := models.ClinicDay(dispute.CreatedAt)
+		dispute.AgeDays = int(math.Round(today.Sub(opened).Hours() / 24))
+		dispute.Adjustments = []models.BillingAdjustment{}
+
+		report.Count++
+		report.ByRaisedBy[dispute.RaisedBy]++
+		if dispute.Amount != nil {
+			report.DisputedAmount += *dispute.Amount
+		}
+		if !billed[dispute.BillingID] {
+			billed[dispute.BillingID] = true
+			report.Balance += dispute.Balance
+		}
+		report.Disputes = append(report.Disputes, dispute)
+	}
+	report.DisputedAmount = math.Round(report.DisputedAmount*100) / 100
+	report.Balance = math.Round(report.Balance*100) / 100
+	return report, nil
+}
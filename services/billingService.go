@@ -2,20 +2,70 @@ package services
 
 import (
 	"RoyDental/models"
+	"RoyDental/notifications"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
+var ErrInvalidAdjustment = errors.New("invalid billing adjustment")
+
 type BillingService struct {
-	repository *repositories.BillingRepository
+	repository            *repositories.BillingRepository
+	contractRateRepo      *repositories.ContractRateRepository
+	procedureRepo         *repositories.ProcedureRepository
+	largeBalanceThreshold float64
+	approvals             *ApprovalService
+	discountThreshold     float64
+}
+
+// billingDiscount is a bill priced well below its list price saved until it is approved
+type billingDiscount struct {
+	Create  bool           `json:"create"`
+	Billing models.Billing `json:"billing"`
 }
 
-func NewBillingService(repository *repositories.BillingRepository) *BillingService {
-	return &BillingService{repository: repository}
+// NewBillingService posts a large_balance event whenever a new bill leaves at least
+// largeBalanceThreshold outstanding; a threshold of 0 disables the event. Bills priced more than
+// discountThreshold percent below their list price wait for an admin's approval.
+func NewBillingService(repository *repositories.BillingRepository, contractRateRepo *repositories.ContractRateRepository, procedureRepo *repositories.ProcedureRepository,
+	largeBalanceThreshold float64, approvals *ApprovalService, discountThreshold float64) *BillingService {
+	s := &BillingService{
+		repository:            repository,
+		contractRateRepo:      contractRateRepo,
+		procedureRepo:         procedureRepo,
+		largeBalanceThreshold: largeBalanceThreshold,
+		approvals:             approvals,
+		discountThreshold:     discountThreshold,
+	}
+	approvals.Register(models.ApprovalActionBillingDiscount, s.executeDiscount)
+	return s
 }
 
+// Create bills a patient. A bill discounted beyond the threshold is only created once approved,
+// and an *ApprovalRequiredError is returned meanwhile.
 func (s *BillingService) Create(ctx context.Context, billing *models.Billing) error {
-	return s.repository.Create(ctx, billing)
+	listPrice, err := s.applyContractRate(ctx, billing, time.Now())
+	if err != nil {
+		return err
+	}
+	if s.discounted(billing.BillingAmount, listPrice) {
+		return s.approvals.Request(ctx, models.ApprovalActionBillingDiscount, "",
+			fmt.Sprintf("Bill patient %s %.2f for %s, listed at %.2f", billing.PatientID, billing.BillingAmount, billing.Procedure, listPrice),
+			billingDiscount{Create: true, Billing: *billing})
+	}
+	return s.create(ctx, billing)
+}
+
+func (s *BillingService) create(ctx context.Context, billing *models.Billing) error {
+	if err := s.repository.Create(ctx, billing); err != nil {
+		return err
+	}
+	s.checkBalance(billing)
+	return nil
 }
 
 func (s *BillingService) GetByID(ctx context.Context, id string) (*models.Billing, error) {
@@ -26,10 +76,142 @@ func (s *BillingService) GetAll(ctx context.Context) ([]models.Billing, error) {
 	return s.repository.GetAll(ctx)
 }
 
+// ListPage returns a page of billings newest first, after the ?after= cursor of the previous page
+func (s *BillingService) ListPage(ctx context.Context, after string, limit int) (*models.ListPage[models.Billing], error) {
+	cursor, limit, err := pageRequest(after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repository.ListAfter(ctx, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Billing) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: row.BillingID}
+	}), nil
+}
+
+// Stream hands every billing to fn newest first, for lists too large to build in memory
+func (s *BillingService) Stream(ctx context.Context, fn func(models.Billing) error) error {
+	return s.repository.Stream(ctx, fn)
+}
+
+// StreamFiltered hands the billings matching query to fn in the order it asks for
+func (s *BillingService) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Billing) error) error {
+	return s.repository.StreamFiltered(ctx, query, fn)
+}
+
+// Update changes a bill. A bill of a closed financial period is only corrected when the update is
+// flagged as an adjustment with a reason.
 func (s *BillingService) Update(ctx context.Context, billing *models.Billing) error {
+	billing.AdjustmentReason = strings.TrimSpace(billing.AdjustmentReason)
+	if billing.Adjustment && billing.AdjustmentReason == "" {
+		return fmt.Errorf("%w: an adjustment needs a reason", ErrInvalidAdjustment)
+	}
+
+	existing, err := s.repository.GetByID(ctx, billing.BillingID)
+	if err != nil {
+		return err
+	}
+	day := time.Now()
+	if existing != nil {
+		day = existing.CreatedAt
+	}
+	listPrice, err := s.applyContractRate(ctx, billing, day)
+	if err != nil {
+		return err
+	}
+	// Only lowering the amount of a bill further needs approval
+	if existing != nil && billing.BillingAmount < existing.BillingAmount && s.discounted(billing.BillingAmount, listPrice) {
+		return s.approvals.Request(ctx, models.ApprovalActionBillingDiscount, billing.BillingID,
+			fmt.Sprintf("Lower bill %s of patient %s from %.2f to %.2f for %s, listed at %.2f",
+				billing.BillingID, existing.PatientID, existing.BillingAmount, billing.BillingAmount, billing.Procedure, listPrice),
+			billingDiscount{Billing: *billing})
+	}
 	return s.repository.Update(ctx, billing)
 }
 
+// Patch changes only the fields present in patch, a JSON merge patch, keeping the others as they are
+func (s *BillingService) Patch(ctx context.Context, id string, patch []byte) (*models.Billing, error) {
+	billing, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+	if err := applyPatch(billing, patch); err != nil {
+		return nil, err
+	}
+	billing.BillingID = id
+	billing.Patient, billing.Doctor = models.Patient{}, models.Doctor{}
+	if err := s.Update(ctx, billing); err != nil {
+		return nil, err
+	}
+	return billing, nil
+}
+
+// discounted reports whether amount is more than the discount threshold below listPrice
+func (s *BillingService) discounted(amount, listPrice float64) bool {
+	if s.discountThreshold <= 0 || listPrice <= 0 {
+		return false
+	}
+	return amount < listPrice*(1-s.discountThreshold/100)
+}
+
+func (s *BillingService) executeDiscount(ctx context.Context, approval *models.Approval) error {
+	var discount billingDiscount
+	if err := approvalPayload(approval, &discount); err != nil {
+		return err
+	}
+	if discount.Create {
+		return s.create(ctx, &discount.Billing)
+	}
+	return s.repository.Update(ctx, &discount.Billing)
+}
+
+// applyContractRate names the bill after its catalog procedure and prices it at the contract rate of
+// the patient's insurer on day. A bill sent without an amount takes the contract rate; one with an
+// amount keeps it, and any difference shows in the variance report. It returns the price the
+// procedure is listed at for the patient: the contract rate, or the catalog price without one.
+func (s *BillingService) applyContractRate(ctx context.Context, billing *models.Billing, day time.Time) (float64, error) {
+	billing.ContractRateID = nil
+	billing.ContractAmount = nil
+	if billing.ProcedureID == nil {
+		return 0, nil
+	}
+	procedure, err := s.procedureRepo.GetByID(ctx, *billing.ProcedureID)
+	if err != nil {
+		return 0, err
+	}
+	if procedure == nil {
+		return 0, ErrProcedureNotFound
+	}
+	billing.Procedure = procedure.Name
+
+	rate, err := s.contractRateRepo.RateForPatient(ctx, billing.PatientID, procedure.ID, day)
+	if err != nil {
+		return 0, err
+	}
+	if rate == nil {
+		return procedure.Price, nil
+	}
+	billing.ContractRateID = &rate.ID
+	billing.ContractAmount = &rate.Amount
+	if billing.BillingAmount == 0 {
+		billing.BillingAmount = rate.Amount
+	}
+	return rate.Amount, nil
+}
+
 func (s *BillingService) Delete(ctx context.Context, id string) error {
 	return s.repository.Delete(ctx, id)
 }
+
+func (s *BillingService) checkBalance(billing *models.Billing) {
+	if s.largeBalanceThreshold <= 0 || billing.Balance < s.largeBalanceThreshold {
+		return
+	}
+	notifications.Publish(notifications.EventLargeBalance, "Large outstanding balance",
+		fmt.Sprintf("Bill %s for patient %s (%s) leaves %.2f outstanding.", billing.BillingID, billing.PatientID, billing.Procedure, billing.Balance))
+}
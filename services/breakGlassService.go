@@ -0,0 +1,160 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBreakGlassNotFound is returned for emergency accesses that do not exist
+	ErrBreakGlassNotFound = errors.New("break-glass access not found")
+	// ErrInvalidBreakGlass is returned for emergency accesses without a reason long enough
+	ErrInvalidBreakGlass = errors.New("invalid break-glass access")
+)
+
+// BreakGlassService opens emergency access to records a clinician is otherwise kept out of, so
+// urgent care never waits on someone sharing Admin credentials. Every access needs a reason, is
+// written to the clinical audit trail before it is granted, is emailed straight away to the admins
+// and the security alert recipients, and stays listed until an admin reviews it.
+type BreakGlassService struct {
+	repository      *repositories.BreakGlassRepository
+	patientRepo     *repositories.PatientRepository
+	userRepo        repositories.UserRepository
+	auditService    *AuditService
+	notifier        notifications.Notifier
+	alertRecipients []string
+	config          config.BreakGlassConfig
+	clock           clock.Clock
+}
+
+func NewBreakGlassService(repository *repositories.BreakGlassRepository, patientRepo *repositories.PatientRepository, userRepo repositories.UserRepository, auditService *AuditService, notifier notifications.Notifier, alertRecipients []string, cfg config.BreakGlassConfig, clock clock.Clock) *BreakGlassService {
+	return &BreakGlassService{
+		repository:      repository,
+		patientRepo:     patientRepo,
+		userRepo:        userRepo,
+		auditService:    auditService,
+		notifier:        notifier,
+		alertRecipients: alertRecipients,
+		config:          cfg,
+		clock:           clock,
+	}
+}
+
+// Open grants the user access to the patient's record for the configured duration. The audit entry
+// is written first, so no access is ever granted without one.
+func (s *BreakGlassService) Open(ctx context.Context, access *models.BreakGlassAccess) error {
+	access.Reason = strings.TrimSpace(access.Reason)
+	if len([]rune(access.Reason)) < s.config.MinReasonLength {
+		return fmt.Errorf("%w: the reason must be at least %d characters", ErrInvalidBreakGlass, s.config.MinReasonLength)
+	}
+	patient, err := s.patientRepo.GetByID(ctx, access.PatientID)
+	if err != nil {
+		return err
+	}
+	if patient == nil {
+		return ErrPatientNotFound
+	}
+
+	now := s.clock.Now()
+	access.ID = 0
+	access.GrantedAt, access.ExpiresAt = now, now.Add(s.config.Duration)
+	access.ReviewedAt, access.ReviewedBy, access.ReviewNote = nil, nil, ""
+
+	detail, err := json.Marshal(map[string]interface{}{
+		"patient_id": access.PatientID,
+		"reason":     access.Reason,
+		"expires_at": access.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	err = s.auditService.Record(ctx, models.AuditLog{
+		Category:  models.AuditCategoryClinical,
+		Event:     models.AuditEventBreakGlassAccess,
+		UserID:    strconv.FormatInt(access.UserID, 10),
+		Role:      access.Role,
+		IP:        access.IP,
+		Detail:    string(detail),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit the break-glass access: %w", err)
+	}
+	if err := s.repository.Create(ctx, access); err != nil {
+		return err
+	}
+	s.alert(context.WithoutCancel(ctx), access, patient)
+	return nil
+}
+
+// Granted reports whether the user has an emergency access to the patient's record open
+func (s *BreakGlassService) Granted(ctx context.Context, userID int64, patientID string) (bool, error) {
+	return s.repository.Active(ctx, userID, patientID, s.clock.Now())
+}
+
+// List returns the emergency accesses matching filter, newest first
+func (s *BreakGlassService) List(ctx context.Context, filter models.BreakGlassFilter) ([]models.BreakGlassAccess, error) {
+	accesses, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if accesses == nil {
+		accesses = []models.BreakGlassAccess{}
+	}
+	return accesses, nil
+}
+
+// Review records that the signed-in admin looked into an emergency access
+func (s *BreakGlassService) Review(ctx context.Context, id uint, review models.BreakGlassReview) (*models.BreakGlassAccess, error) {
+	access, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if access == nil {
+		return nil, ErrBreakGlassNotFound
+	}
+	now := s.clock.Now()
+	access.ReviewedAt, access.ReviewNote = &now, strings.TrimSpace(review.Note)
+	if userID, ok := models.ActorFrom(ctx); ok {
+		access.ReviewedBy = &userID
+	}
+	if err := s.repository.Review(ctx, access); err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// alert emails the admins and the security alert recipients of the access; failing to is logged,
+// as the access must not wait on it
+func (s *BreakGlassService) alert(ctx context.Context, access *models.BreakGlassAccess, patient *models.Patient) {
+	recipients, err := adminAlertRecipients(ctx, s.userRepo, s.alertRecipients)
+	if err != nil {
+		log.Printf("Failed to get the admins to alert of break-glass access %d: %v", access.ID, err)
+	}
+	if len(recipients) == 0 {
+		log.Printf("Break-glass access %d to patient %s by user %d has no admin to alert", access.ID, access.PatientID, access.UserID)
+		return
+	}
+
+	alert := notifications.Notification{
+		Recipients: recipients,
+		Subject:    fmt.Sprintf("Break-glass access to the record of %s %s", patient.FirstName, patient.LastName),
+		Body: fmt.Sprintf("User %d (%s) opened emergency access to the record of patient %s (%s %s) at %s from IP %s, until %s.\n\nReason: %s\n\nPlease review it under break-glass accesses.",
+			access.UserID, access.Role, access.PatientID, patient.FirstName, patient.LastName,
+			access.GrantedAt.Format(time.RFC3339), access.IP, access.ExpiresAt.Format(time.RFC3339), access.Reason),
+	}
+	if err := s.notifier.Send(ctx, alert); err != nil {
+		log.Printf("Failed to alert admins of break-glass access %d: %v", access.ID, err)
+	}
+}
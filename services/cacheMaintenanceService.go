@@ -0,0 +1,143 @@
+package services
+
+import (
+	"RoyDental/cache"
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/metrics"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/utils"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// cacheMaintenanceLockKey keeps replicas from cleaning Redis at the same time
+const cacheMaintenanceLockKey = "cache_maintenance_lock"
+
+// Kinds of junk keys the cache maintenance deletes
+const (
+	prunedResetCodes = "reset_codes"
+	prunedLocks      = "locks"
+	prunedSessions   = "sessions"
+	prunedLists      = "lists"
+)
+
+var cacheKeysPruned = metrics.NewCounterVec("cache_keys_pruned_total", "Junk Redis keys deleted by the cache maintenance.", "kind")
+
+// lockPatterns match the keys locks are taken under, edit locks and claims of lists being
+// refreshed included
+var lockPatterns = []string{"*_lock", "*_lock:*", "*:refresh", retentionLockKey, auditArchiveLockKey}
+
+// CacheMaintenanceService clears out the Redis keys nothing else removes, which piled up until
+// Redis was flushed by hand: reset codes and sessions left without an expiry or kept longer than
+// they can be used, locks left without an expiry by processes that crashed holding them, and cached
+// lists grown too big to be worth keeping, which are read again from the database when next needed.
+type CacheMaintenanceService struct {
+	repository *repositories.CacheMaintenanceRepository
+	config     config.CacheMaintenanceConfig
+	clock      clock.Clock
+	mu         sync.Mutex
+}
+
+// NewCacheMaintenanceService starts cleaning Redis every config.Interval when enabled
+func NewCacheMaintenanceService(repository *repositories.CacheMaintenanceRepository, cfg config.CacheMaintenanceConfig, clock clock.Clock) *CacheMaintenanceService {
+	s := &CacheMaintenanceService{repository: repository, config: cfg, clock: clock}
+	if cfg.Enabled {
+		go s.run()
+	}
+	return s
+}
+
+func (s *CacheMaintenanceService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		run, err := s.Prune(context.Background())
+		if err != nil {
+			log.Printf("Failed to clean Redis: %v", err)
+			continue
+		}
+		if run.Error != "" {
+			log.Printf("Cleaning Redis stopped early: %s", run.Error)
+		}
+	}
+}
+
+// Prune deletes the junk keys of every kind and reports how many of each went. A kind that fails
+// to be cleaned does not stop the others.
+func (s *CacheMaintenanceService) Prune(ctx context.Context) (*models.CacheMaintenanceRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	release, err := database.Locks.Acquire(ctx, cacheMaintenanceLockKey)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	run := &models.CacheMaintenanceRun{StartedAt: s.clock.Now(), Pruned: map[string]int{}}
+	for kind, sweeps := range s.sweeps() {
+		run.Pruned[kind] = 0
+		for _, sweep := range sweeps {
+			pruned, err := s.repository.Prune(ctx, sweep, s.config.ScanCount)
+			run.Pruned[kind] += pruned
+			cacheKeysPruned.Add(float64(pruned), kind)
+			if err != nil {
+				if run.Error == "" {
+					run.Error = fmt.Sprintf("%s: %v", kind, err)
+				}
+				break
+			}
+		}
+	}
+	run.FinishedAt = s.clock.Now()
+	return run, nil
+}
+
+// sweeps returns the keys looked at for each kind of junk and which of them are junk
+func (s *CacheMaintenanceService) sweeps() map[string][]repositories.KeySweep {
+	lockSweeps := make([]repositories.KeySweep, 0, len(lockPatterns))
+	for _, pattern := range lockPatterns {
+		lockSweeps = append(lockSweeps, repositories.KeySweep{
+			Pattern: pattern,
+			// Locks expire by themselves unless LOCK_TTL is 0; one left without an expiry and untouched
+			// for LockMaxAge was left by a process that crashed holding it
+			Stale: func(key models.RedisKey) bool {
+				return key.TTL == models.NoExpiry && key.Idle > s.config.LockMaxAge
+			},
+		})
+	}
+
+	return map[string][]repositories.KeySweep{
+		prunedResetCodes: {{
+			Pattern: utils.ResetCodeKey("*"),
+			Stale: func(key models.RedisKey) bool {
+				return key.TTL == models.NoExpiry || key.TTL > utils.ResetCodeTTL
+			},
+		}},
+		// Users are cached when they sign in for as long as their session; a session ends with
+		// its refresh token
+		prunedSessions: {{
+			Pattern: userCacheKey("*"),
+			Stale: func(key models.RedisKey) bool {
+				return key.TTL == models.NoExpiry || key.Idle > utils.RefreshTokenExpiry
+			},
+		}},
+		prunedLocks: lockSweeps,
+		prunedLists: {{
+			Pattern: cache.EnvironmentPattern(),
+			Match:   cache.IsListKey,
+			Sizes:   true,
+			Stale: func(key models.RedisKey) bool {
+				return key.Bytes > s.config.MaxListBytes
+			},
+		}},
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCalendarToken  = errors.New("invalid calendar token")
+	ErrCalendarDoctorMissing = errors.New("doctor not found")
+)
+
+// CalendarService builds the doctors' iCalendar feeds. A feed token names the doctor and whether
+// patient names are hidden, so a private link cannot be turned into a full one.
+type CalendarService struct {
+	repository *repositories.DoctorAppRepository
+	config     config.CalendarConfig
+}
+
+func NewCalendarService(repository *repositories.DoctorAppRepository, cfg config.CalendarConfig) *CalendarService {
+	return &CalendarService{repository: repository, config: cfg}
+}
+
+// Enabled reports whether feed tokens can be signed
+func (s *CalendarService) Enabled() bool {
+	return s.config.SigningKey != ""
+}
+
+// Token returns the feed token of a doctor; private feeds hide patient names
+func (s *CalendarService) Token(doctorID string, private bool) string {
+	mode := "f"
+	if private {
+		mode = "p"
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	mac.Write([]byte("calendar:" + doctorID + ":" + mode))
+	return mode + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Link returns the subscription link of a doctor's feed
+func (s *CalendarService) Link(ctx context.Context, doctorID string, private bool) (string, error) {
+	if !s.Enabled() {
+		return "", errors.New("calendar feeds are not configured")
+	}
+	doctor, err := s.repository.GetDoctor(ctx, doctorID)
+	if err != nil {
+		return "", err
+	}
+	if doctor == nil {
+		return "", ErrCalendarDoctorMissing
+	}
+	return fmt.Sprintf("%s/doctors/%s/calendar.ics?token=%s", strings.TrimRight(s.config.BaseURL, "/"), url.PathEscape(doctorID), s.Token(doctorID, private)), nil
+}
+
+// Feed returns the iCalendar document of the doctor's recent and upcoming appointments
+func (s *CalendarService) Feed(ctx context.Context, doctorID, token string) ([]byte, error) {
+	private, err := s.verify(doctorID, token)
+	if err != nil {
+		return nil, err
+	}
+	doctor, err := s.repository.GetDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrCalendarDoctorMissing
+	}
+
+	today := models.ClinicNow()
+	appointments, err := s.repository.GetAppointmentsBetween(ctx, doctorID, today.AddDate(0, 0, -s.config.PastDays), today.AddDate(0, 0, s.config.FutureDays))
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//RoyDental//Doctor Calendar//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(fmt.Sprintf("Appointments - Dr. %s %s", doctor.FirstName, doctor.LastName)))
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, appointment := range appointments {
+		start, ok := models.ParseAppointmentTime(appointment.DateTime)
+		if !ok {
+			continue
+		}
+		summary := "Appointment"
+		if !private {
+			summary += ": " + appointment.PatientName
+		}
+
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:appointment-%d@roydental", appointment.ID))
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		writeICSLine(&b, "DTSTART:"+start.UTC().Format("20060102T150405Z"))
+		end := start.Add(s.config.EventDuration)
+		if appointment.EndsAt != nil {
+			end = *appointment.EndsAt
+		}
+		writeICSLine(&b, "DTEND:"+end.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(summary))
+		if appointment.Type != "" {
+			writeICSLine(&b, "CATEGORIES:"+escapeICSText(appointment.Type))
+		}
+		if !private {
+			writeICSLine(&b, "DESCRIPTION:"+escapeICSText("Patient ID: "+appointment.PatientID))
+		}
+		if appointment.Status == models.AppointmentStatusCancelled {
+			writeICSLine(&b, "STATUS:CANCELLED")
+		} else {
+			writeICSLine(&b, "STATUS:CONFIRMED")
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.Bytes(), nil
+}
+
+// verify checks a feed token and reports whether it is for a private feed
+func (s *CalendarService) verify(doctorID, token string) (bool, error) {
+	private := strings.HasPrefix(token, "p.")
+	if !s.Enabled() || !hmac.Equal([]byte(token), []byte(s.Token(doctorID, private))) {
+		return false, ErrInvalidCalendarToken
+	}
+	return private, nil
+}
+
+// escapeICSText escapes a TEXT value as required by RFC 5545
+func escapeICSText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// writeICSLine writes a content line, folded at 75 octets as required by RFC 5545
+func writeICSLine(b *bytes.Buffer, line string) {
+	for len(line) > 75 {
+		cut := 75
+		// Never split a multi-byte UTF-8 character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
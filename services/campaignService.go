@@ -0,0 +1,415 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCampaignNotFound is returned for campaigns that do not exist
+	ErrCampaignNotFound = errors.New("campaign not found")
+	// ErrInvalidCampaign is returned for campaigns without a name or message, with unknown channels
+	// or purposes, and with segments the patients list cannot be filtered by
+	ErrInvalidCampaign = errors.New("invalid campaign")
+	// ErrCampaignStatus is returned for changes a campaign's status does not allow, such as editing a
+	// campaign already scheduled
+	ErrCampaignStatus = errors.New("campaign cannot be changed in its status")
+)
+
+// campaignRecord names campaigns in the communication log
+const campaignRecord = "campaign"
+
+// CampaignService keeps bulk communication campaigns and, in the background, sends them: scheduled
+// campaigns start by taking the patients of their segment, who are then sent the campaign a batch
+// at a time so the send is throttled. Messages go through the patient notifiers, so preferences,
+// opt-outs, bounced addresses and quiet hours are respected, and every message is written to the
+// communication log.
+type CampaignService struct {
+	repository     *repositories.CampaignRepository
+	savedFilters   *SavedFilterService
+	communications *CommunicationService
+	email          notifications.Notifier
+	sms            notifications.Notifier
+	clinicName     string
+	config         config.CampaignConfig
+	clock          clock.Clock
+}
+
+// NewCampaignService starts sending campaigns in the background when a send interval is set.
+func NewCampaignService(repository *repositories.CampaignRepository, savedFilters *SavedFilterService, communications *CommunicationService, email, sms notifications.Notifier, clinicName string, cfg config.CampaignConfig, clock clock.Clock) *CampaignService {
+	s := &CampaignService{
+		repository:     repository,
+		savedFilters:   savedFilters,
+		communications: communications,
+		email:          email,
+		sms:            sms,
+		clinicName:     clinicName,
+		config:         cfg,
+		clock:          clock,
+	}
+	if cfg.SendInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+// Create saves a draft campaign
+func (s *CampaignService) Create(ctx context.Context, campaign *models.Campaign) error {
+	campaign.ID = 0
+	campaign.Status = models.CampaignDraft
+	campaign.ScheduledAt, campaign.StartedAt, campaign.FinishedAt = nil, nil, nil
+	if err := s.validate(ctx, campaign); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, campaign)
+}
+
+func (s *CampaignService) Get(ctx context.Context, id uint) (*models.Campaign, error) {
+	campaign, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// List returns the campaigns in status, or all of them when status is empty, newest first
+func (s *CampaignService) List(ctx context.Context, status string) ([]models.Campaign, error) {
+	campaigns, err := s.repository.List(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if campaigns == nil {
+		campaigns = []models.Campaign{}
+	}
+	return campaigns, nil
+}
+
+// Update changes a campaign's message and segment while it is a draft
+func (s *CampaignService) Update(ctx context.Context, id uint, update models.Campaign) (*models.Campaign, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.CampaignDraft {
+		return nil, fmt.Errorf("%w: only drafts can be edited, this campaign is %s", ErrCampaignStatus, campaign.Status)
+	}
+	campaign.Name, campaign.Channel, campaign.Purpose = update.Name, update.Channel, update.Purpose
+	campaign.Subject, campaign.Body = update.Subject, update.Body
+	campaign.Criteria, campaign.SavedFilterID = update.Criteria, update.SavedFilterID
+	if err := s.validate(ctx, campaign); err != nil {
+		return nil, err
+	}
+	updated, err := s.repository.Update(ctx, campaign)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("%w: only drafts can be edited", ErrCampaignStatus)
+	}
+	return campaign, nil
+}
+
+// Delete removes a draft or cancelled campaign; campaigns scheduled or sent are kept for their
+// report
+func (s *CampaignService) Delete(ctx context.Context, id uint) error {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if campaign.Status != models.CampaignDraft && campaign.Status != models.CampaignCancelled {
+		return fmt.Errorf("%w: only drafts and cancelled campaigns can be deleted, this campaign is %s", ErrCampaignStatus, campaign.Status)
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Preview shows the audience the campaign would reach if it were sent now and the messages the
+// first of them would be sent
+func (s *CampaignService) Preview(ctx context.Context, id uint) (*models.CampaignPreview, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	audience, reachable, permitted, err := s.repository.AudienceCounts(ctx, campaign, now)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repository.Audience(ctx, campaign, now, s.config.PreviewSample)
+	if err != nil {
+		return nil, err
+	}
+	preview := &models.CampaignPreview{Audience: audience, Reachable: reachable, Permitted: permitted, Sample: []models.CampaignPreviewMessage{}}
+	for _, member := range members {
+		message := models.CampaignPreviewMessage{
+			PatientID: member.PatientID,
+			Recipient: member.Recipient,
+			Body:      campaign.Fill(campaign.Body, member.FirstName, member.LastName, s.clinicName),
+		}
+		if campaign.Channel == notifications.ChannelEmail {
+			message.Subject = campaign.Fill(campaign.Subject, member.FirstName, member.LastName, s.clinicName)
+		}
+		preview.Sample = append(preview.Sample, message)
+	}
+	return preview, nil
+}
+
+// Schedule queues a draft campaign to be sent at sendAt, straight away when it is nil or past. Its
+// segment is taken when it starts sending, so patients who join it until then are included.
+func (s *CampaignService) Schedule(ctx context.Context, id uint, sendAt *time.Time) (*models.Campaign, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.CheckListQuery(models.ListPatients, models.ListQuery{Criteria: campaign.Criteria}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCampaign, err)
+	}
+	at := s.clock.Now()
+	if sendAt != nil && sendAt.After(at) {
+		at = *sendAt
+	}
+	scheduled, err := s.repository.Transition(ctx, id, []string{models.CampaignDraft}, map[string]interface{}{
+		"status":       models.CampaignScheduled,
+		"scheduled_at": at,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !scheduled {
+		return nil, fmt.Errorf("%w: only drafts can be scheduled, this campaign is %s", ErrCampaignStatus, campaign.Status)
+	}
+	return s.Get(ctx, id)
+}
+
+// Cancel stops a campaign scheduled or sending; recipients not sent to yet are left unsent
+func (s *CampaignService) Cancel(ctx context.Context, id uint) (*models.Campaign, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := s.repository.Transition(ctx, id, []string{models.CampaignScheduled, models.CampaignSending}, map[string]interface{}{
+		"status":      models.CampaignCancelled,
+		"finished_at": s.clock.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, fmt.Errorf("%w: only scheduled campaigns and those sending can be cancelled, this campaign is %s", ErrCampaignStatus, campaign.Status)
+	}
+	return s.Get(ctx, id)
+}
+
+// Recipients returns up to limit of the patients a campaign was sent to, those in status when it is
+// set
+func (s *CampaignService) Recipients(ctx context.Context, id uint, status string, limit int) ([]models.CampaignRecipient, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	recipients, err := s.repository.ListRecipients(ctx, id, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if recipients == nil {
+		recipients = []models.CampaignRecipient{}
+	}
+	return recipients, nil
+}
+
+// Report counts how the campaign's messages fared, the bounces and complaints the mail provider
+// reported and the recipients who opted out since it started sending
+func (s *CampaignService) Report(ctx context.Context, id uint) (*models.CampaignReport, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := s.repository.CountRecipients(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repository.CountDeliveries(ctx, campaignRecord, fmt.Sprint(id))
+	if err != nil {
+		return nil, err
+	}
+	report := &models.CampaignReport{
+		Campaign:     *campaign,
+		Pending:      statuses[models.CampaignRecipientPending] + statuses[models.CampaignRecipientSending],
+		Sent:         statuses[models.MessageSent],
+		Deferred:     statuses[models.MessageDeferred],
+		NotPermitted: statuses[models.MessageNotPermitted],
+		Failed:       statuses[models.MessageFailed],
+		Bounced:      deliveries[models.DeliveryBounced],
+		Complained:   deliveries[models.DeliveryComplained],
+	}
+	for _, count := range statuses {
+		report.Recipients += count
+	}
+	if campaign.StartedAt != nil {
+		consents := []string{campaign.Channel}
+		if campaign.Purpose == notifications.PurposeMarketing {
+			consents = append(consents, models.ConsentMarketing)
+		}
+		if report.OptedOut, err = s.repository.CountOptOuts(ctx, id, consents, *campaign.StartedAt); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// validate normalizes a campaign and checks its message, channel, purpose and segment. A campaign
+// built from a saved filter takes the filter's criteria.
+func (s *CampaignService) validate(ctx context.Context, campaign *models.Campaign) error {
+	campaign.Name = strings.TrimSpace(campaign.Name)
+	campaign.Subject = strings.TrimSpace(campaign.Subject)
+	campaign.Body = strings.TrimSpace(campaign.Body)
+	if campaign.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCampaign)
+	}
+	if campaign.Body == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidCampaign)
+	}
+	switch campaign.Channel {
+	case notifications.ChannelEmail:
+		if campaign.Subject == "" {
+			return fmt.Errorf("%w: emails need a subject", ErrInvalidCampaign)
+		}
+	case notifications.ChannelSMS:
+		campaign.Subject = ""
+	default:
+		return fmt.Errorf("%w: channel must be %s or %s", ErrInvalidCampaign, notifications.ChannelEmail, notifications.ChannelSMS)
+	}
+	if campaign.Purpose == "" {
+		campaign.Purpose = notifications.PurposeMarketing
+	}
+	if campaign.Purpose != notifications.PurposeMarketing && campaign.Purpose != notifications.PurposeService {
+		return fmt.Errorf("%w: purpose must be %s or %s", ErrInvalidCampaign, notifications.PurposeMarketing, notifications.PurposeService)
+	}
+
+	if campaign.SavedFilterID != nil {
+		userID, _ := models.ActorFrom(ctx)
+		filter, err := s.savedFilters.Get(ctx, userID, *campaign.SavedFilterID)
+		if errors.Is(err, ErrSavedFilterNotFound) {
+			return fmt.Errorf("%w: saved filter %d does not exist", ErrInvalidCampaign, *campaign.SavedFilterID)
+		}
+		if err != nil {
+			return err
+		}
+		if filter.List != models.ListPatients {
+			return fmt.Errorf("%w: saved filter %d is not of the %s list", ErrInvalidCampaign, filter.ID, models.ListPatients)
+		}
+		campaign.Criteria = filter.Criteria
+	}
+	if campaign.Criteria == nil {
+		campaign.Criteria = models.FilterCriteria{}
+	}
+	if err := repositories.CheckListQuery(models.ListPatients, models.ListQuery{Criteria: campaign.Criteria}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCampaign, err)
+	}
+	return nil
+}
+
+func (s *CampaignService) run() {
+	ticker := time.NewTicker(s.config.SendInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.dispatch(context.Background())
+	}
+}
+
+// dispatch starts the campaigns due, sends the next batch of recipients and marks the campaigns
+// with none left as sent
+func (s *CampaignService) dispatch(ctx context.Context) {
+	started, err := s.repository.Start(ctx, s.clock.Now())
+	if err != nil {
+		log.Printf("Failed to start campaigns: %v", err)
+	}
+	for _, campaign := range started {
+		log.Printf("Campaign %d (%s) started sending", campaign.ID, campaign.Name)
+	}
+
+	recipients, err := s.repository.ClaimRecipients(ctx, s.config.BatchSize)
+	if err != nil {
+		log.Printf("Failed to claim campaign recipients: %v", err)
+		return
+	}
+	campaigns := make(map[uint]*models.Campaign)
+	for i := range recipients {
+		campaign, ok := campaigns[recipients[i].CampaignID]
+		if !ok {
+			campaign, err = s.repository.GetByID(ctx, recipients[i].CampaignID)
+			if err != nil {
+				log.Printf("Failed to get campaign %d: %v", recipients[i].CampaignID, err)
+			}
+			campaigns[recipients[i].CampaignID] = campaign
+		}
+		if campaign == nil {
+			continue
+		}
+		s.send(ctx, campaign, &recipients[i])
+	}
+
+	if err := s.repository.Finish(ctx, s.clock.Now()); err != nil {
+		log.Printf("Failed to finish campaigns: %v", err)
+	}
+}
+
+// send sends the campaign to a recipient and records how it went on the recipient and in the
+// communication log
+func (s *CampaignService) send(ctx context.Context, campaign *models.Campaign, recipient *models.CampaignRecipient) {
+	var firstName, lastName string
+	if recipient.Patient != nil {
+		firstName, lastName = recipient.Patient.FirstName, recipient.Patient.LastName
+	}
+	notification := notifications.Notification{
+		Recipients: []string{recipient.Recipient},
+		PatientID:  recipient.PatientID,
+		Purpose:    campaign.Purpose,
+		Subject:    campaign.Fill(campaign.Subject, firstName, lastName, s.clinicName),
+		Body:       campaign.Fill(campaign.Body, firstName, lastName, s.clinicName),
+		MessageID:  notifications.NewMessageID(),
+	}
+	notifier := s.email
+	if campaign.Channel == notifications.ChannelSMS {
+		notifier = s.sms
+	}
+	sendErr := notifier.Send(ctx, notification)
+	if sendErr != nil && !errors.Is(sendErr, notifications.ErrNotPermitted) && !errors.Is(sendErr, notifications.ErrDeferred) {
+		log.Printf("Failed to send campaign %d to patient %s: %v", campaign.ID, recipient.PatientID, sendErr)
+	}
+
+	now := s.clock.Now()
+	recipient.Status, recipient.Error = messageStatus(sendErr)
+	recipient.MessageID, recipient.SentAt = notification.MessageID, &now
+	if err := s.repository.UpdateRecipient(ctx, recipient); err != nil {
+		log.Printf("Failed to record campaign %d sent to patient %s: %v", campaign.ID, recipient.PatientID, err)
+	}
+	err := s.communications.LogMessage(ctx, models.CommunicationLog{
+		PatientID: recipient.PatientID,
+		Channel:   campaign.Channel,
+		Purpose:   campaign.Purpose,
+		Recipient: recipient.Recipient,
+		Subject:   notification.Subject,
+		Body:      notification.Body,
+		MessageID: notification.MessageID,
+		Record:    campaignRecord,
+		RecordID:  fmt.Sprint(campaign.ID),
+	}, sendErr)
+	if err != nil {
+		log.Printf("Failed to log campaign %d sent to patient %s: %v", campaign.ID, recipient.PatientID, err)
+	}
+}
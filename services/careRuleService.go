@@ -0,0 +1,254 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// careInstructionTimeout bounds emailing a patient the instructions of a care rule
+const careInstructionTimeout = time.Minute
+
+var (
+	ErrCareRuleNotFound = errors.New("care rule not found")
+	ErrInvalidCareRule  = errors.New("invalid care rule")
+	ErrRecallNotFound   = errors.New("recall not found")
+	ErrInvalidRecall    = errors.New("invalid recall")
+)
+
+// CareRuleService runs the care pathway rules the admins set up: when a bill is created or an
+// appointment fulfilled, the matching rules assign follow-up tasks, email the patient instructions
+// and put the patient on the recall list. A failing rule is logged and does not stop the others.
+type CareRuleService struct {
+	repository    *repositories.CareRuleRepository
+	procedureRepo *repositories.ProcedureRepository
+	tasks         *TaskService
+	notifier      notifications.Notifier
+	clock         clock.Clock
+}
+
+func NewCareRuleService(repository *repositories.CareRuleRepository, procedureRepo *repositories.ProcedureRepository, tasks *TaskService, notifier notifications.Notifier, clock clock.Clock) *CareRuleService {
+	return &CareRuleService{repository: repository, procedureRepo: procedureRepo, tasks: tasks, notifier: notifier, clock: clock}
+}
+
+func (s *CareRuleService) Create(ctx context.Context, rule *models.CareRule) error {
+	if err := s.validate(ctx, rule); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, rule)
+}
+
+func (s *CareRuleService) Get(ctx context.Context, id uint) (*models.CareRule, error) {
+	rule, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrCareRuleNotFound
+	}
+	return rule, nil
+}
+
+func (s *CareRuleService) List(ctx context.Context) ([]models.CareRule, error) {
+	rules, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.CareRule{}
+	}
+	return rules, nil
+}
+
+func (s *CareRuleService) Update(ctx context.Context, rule *models.CareRule) error {
+	if _, err := s.Get(ctx, rule.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, rule); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, rule)
+}
+
+func (s *CareRuleService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *CareRuleService) validate(ctx context.Context, rule *models.CareRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Title = strings.TrimSpace(rule.Title)
+	rule.Subject = strings.TrimSpace(rule.Subject)
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCareRule)
+	}
+	if !models.IsValidCareEvent(rule.Event) {
+		return fmt.Errorf("%w: event must be %s or %s", ErrInvalidCareRule, models.CareEventBillingCreated, models.CareEventAppointmentFulfilled)
+	}
+	if !models.IsValidCareAction(rule.Action) {
+		return fmt.Errorf("%w: action must be %s, %s or %s", ErrInvalidCareRule, models.CareActionCreateTask, models.CareActionSendInstructions, models.CareActionCreateRecall)
+	}
+	if rule.DelayDays < 0 {
+		return fmt.Errorf("%w: delay_days must not be negative", ErrInvalidCareRule)
+	}
+	if rule.ProcedureID != nil {
+		procedure, err := s.procedureRepo.GetByID(ctx, *rule.ProcedureID)
+		if err != nil {
+			return err
+		}
+		if procedure == nil {
+			return fmt.Errorf("%w: procedure %d not found", ErrInvalidCareRule, *rule.ProcedureID)
+		}
+	}
+
+	switch rule.Action {
+	case models.CareActionCreateTask:
+		if rule.AssigneeID == nil {
+			return fmt.Errorf("%w: a task needs an assignee_id", ErrInvalidCareRule)
+		}
+		if rule.Title == "" {
+			return fmt.Errorf("%w: a task needs a title", ErrInvalidCareRule)
+		}
+	case models.CareActionSendInstructions:
+		if rule.Subject == "" || strings.TrimSpace(rule.Body) == "" {
+			return fmt.Errorf("%w: instructions need a subject and a body", ErrInvalidCareRule)
+		}
+	case models.CareActionCreateRecall:
+		if rule.Title == "" {
+			return fmt.Errorf("%w: a recall needs a title", ErrInvalidCareRule)
+		}
+	}
+	return nil
+}
+
+// HandleEvent runs the rules matching a created bill or a fulfilled appointment
+func (s *CareRuleService) HandleEvent(ctx context.Context, event events.Event) error {
+	subject, err := s.repository.Subject(ctx, event.Record, event.RecordID)
+	if err != nil {
+		return err
+	}
+	if subject == nil {
+		return nil
+	}
+	rules, err := s.repository.Matching(ctx, event.Name, subject.ProcedureID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := s.apply(ctx, rule, event, subject); err != nil {
+			log.Printf("Care rule %d failed on %s %s: %v", rule.ID, event.Record, event.RecordID, err)
+		}
+	}
+	return nil
+}
+
+func (s *CareRuleService) apply(ctx context.Context, rule models.CareRule, event events.Event, subject *models.CareSubject) error {
+	day, _ := models.ClinicDay(event.OccurredAt)
+	due := day.AddDate(0, 0, rule.DelayDays)
+
+	switch rule.Action {
+	case models.CareActionCreateTask:
+		createdBy := *rule.AssigneeID
+		if event.ActorID != nil {
+			createdBy = *event.ActorID
+		} else if rule.CreatedBy != nil {
+			createdBy = *rule.CreatedBy
+		}
+		return s.tasks.CreateTask(ctx, &models.Task{
+			Title:       subject.Fill(rule.Title),
+			Description: fmt.Sprintf("Created by care rule %q for %s %s.", rule.Name, event.Record, event.RecordID),
+			AssigneeID:  *rule.AssigneeID,
+			CreatedBy:   createdBy,
+			PatientID:   &subject.PatientID,
+			DueDate:     &due,
+		})
+	case models.CareActionSendInstructions:
+		if subject.PatientEmail == "" {
+			return nil
+		}
+		s.sendInstructions(rule, subject)
+		return nil
+	case models.CareActionCreateRecall:
+		return s.repository.CreateRecall(ctx, &models.Recall{
+			PatientID:   subject.PatientID,
+			ProcedureID: subject.ProcedureID,
+			RuleID:      &rule.ID,
+			DueDate:     due,
+			Reason:      subject.Fill(rule.Title),
+			Status:      models.RecallStatusDue,
+		})
+	}
+	return fmt.Errorf("unknown care rule action %q", rule.Action)
+}
+
+// sendInstructions emails the patient in the background so a slow mail server never delays the
+// request that raised the event
+func (s *CareRuleService) sendInstructions(rule models.CareRule, subject *models.CareSubject) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), careInstructionTimeout)
+		defer cancel()
+
+		err := s.notifier.Send(ctx, notifications.Notification{
+			Recipients: []string{subject.PatientEmail},
+			PatientID:  subject.PatientID,
+			Purpose:    notifications.PurposeService,
+			Subject:    subject.Fill(rule.Subject),
+			Body:       subject.Fill(rule.Body),
+		})
+		if err != nil && !errors.Is(err, notifications.ErrDeferred) {
+			log.Printf("Failed to send the instructions of care rule %d to patient %s: %v", rule.ID, subject.PatientID, err)
+		}
+	}()
+}
+
+// Recalls returns the recalls in status (due by default) falling due before the clinic day
+// before (YYYY-MM-DD, tomorrow by default), earliest first
+func (s *CareRuleService) Recalls(ctx context.Context, status, before string) ([]models.DueRecall, error) {
+	if status == "" {
+		status = models.RecallStatusDue
+	}
+	if !models.IsValidRecallStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidRecall, status)
+	}
+	_, end := models.ClinicDay(s.clock.Now())
+	if before != "" {
+		day, err := models.ParseClinicDate(before)
+		if err != nil {
+			return nil, fmt.Errorf("%w: before must be YYYY-MM-DD", ErrInvalidRecall)
+		}
+		end = day
+	}
+	recalls, err := s.repository.Recalls(ctx, status, end)
+	if err != nil {
+		return nil, err
+	}
+	if recalls == nil {
+		recalls = []models.DueRecall{}
+	}
+	return recalls, nil
+}
+
+// SetRecallStatus records that a recalled patient booked or was dismissed
+func (s *CareRuleService) SetRecallStatus(ctx context.Context, id uint, status string) error {
+	if !models.IsValidRecallStatus(status) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidRecall, status)
+	}
+	found, err := s.repository.SetRecallStatus(ctx, id, status)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrRecallNotFound
+	}
+	return nil
+}
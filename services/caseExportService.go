@@ -0,0 +1,309 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Decodes GIF images, which are shared as JPEG
+	"image/jpeg"
+	"image/png"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseExportJPEGQuality is the quality images without transparency are encoded at in the package
+const caseExportJPEGQuality = 90
+
+var (
+	ErrInvalidCaseExport     = errors.New("invalid case export")
+	ErrCaseExportNotFound    = errors.New("case export not found")
+	ErrCaseExportNotApproved = errors.New("the case export has not been approved")
+)
+
+// caseExport is the export of a treatment plan saved until it is approved
+type caseExport struct {
+	PatientID       string `json:"patient_id"`
+	TreatmentPlanID uint   `json:"treatment_plan_id"`
+	IncludeCosts    bool   `json:"include_costs"`
+}
+
+// CaseExportService packages treatment cases for study clubs and teaching: the examination
+// findings, the plan and the before and after images the patient consented to for teaching, with
+// what identifies the patient taken out. An export waits for an admin's approval and the package is
+// built afresh on each download, so images whose consent was withdrawn meanwhile are left out.
+type CaseExportService struct {
+	repository        *repositories.CaseImageRepository
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+	patientRepo       *repositories.PatientRepository
+	approvals         *ApprovalService
+	files             *Files
+	clock             clock.Clock
+}
+
+// NewCaseExportService registers case exports with approvals, which builds them once approved
+func NewCaseExportService(repository *repositories.CaseImageRepository, treatmentPlanRepo *repositories.TreatmentPlanRepository,
+	patientRepo *repositories.PatientRepository, approvals *ApprovalService, files *Files, clock clock.Clock) *CaseExportService {
+	s := &CaseExportService{repository: repository, treatmentPlanRepo: treatmentPlanRepo, patientRepo: patientRepo, approvals: approvals, files: files, clock: clock}
+	approvals.Register(models.ApprovalActionCaseExport, s.executeExport)
+	return s
+}
+
+// Request asks for the patient's treatment plan to be exported as an anonymized case, with its
+// estimated cost when asked for. It returns an *ApprovalRequiredError until an admin approves it;
+// the package is then downloaded by the approval's ID.
+func (s *CaseExportService) Request(ctx context.Context, patientID string, treatmentPlanID uint, request models.CaseExportRequest) error {
+	export := caseExport{PatientID: patientID, TreatmentPlanID: treatmentPlanID, IncludeCosts: request.IncludeCosts}
+	if _, err := s.build(ctx, "", export); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Export treatment plan %d of patient %s as an anonymized teaching case", treatmentPlanID, patientID)
+	if request.IncludeCosts {
+		summary += ", with its estimated cost"
+	}
+	return s.approvals.Request(ctx, models.ApprovalActionCaseExport, fmt.Sprintf("%s/%d", patientID, treatmentPlanID), summary, export)
+}
+
+// executeExport checks an approved export can still be built
+func (s *CaseExportService) executeExport(ctx context.Context, approval *models.Approval) error {
+	var export caseExport
+	if err := approvalPayload(approval, &export); err != nil {
+		return err
+	}
+	_, err := s.build(ctx, caseExportReference(approval.ID), export)
+	return err
+}
+
+// Download builds the package of an approved export as a zip file
+func (s *CaseExportService) Download(ctx context.Context, approvalID uint) (*ExportFile, error) {
+	approval, err := s.approvals.Get(ctx, approvalID)
+	if err != nil {
+		if errors.Is(err, ErrApprovalNotFound) {
+			return nil, ErrCaseExportNotFound
+		}
+		return nil, err
+	}
+	if approval.Action != models.ApprovalActionCaseExport {
+		return nil, ErrCaseExportNotFound
+	}
+	if approval.Status != models.ApprovalStatusApproved {
+		return nil, fmt.Errorf("%w: it is %s", ErrCaseExportNotApproved, approval.Status)
+	}
+	var export caseExport
+	if err := approvalPayload(approval, &export); err != nil {
+		return nil, err
+	}
+	reference := caseExportReference(approval.ID)
+	content, err := s.build(ctx, reference, export)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportFile{Name: reference + ".zip", ContentType: "application/zip", Content: content}, nil
+}
+
+// build zips the case: case.json with the anonymized findings and plan, and the images of the pairs
+// consented to for teaching with their metadata dropped and their redacted regions blacked out
+func (s *CaseExportService) build(ctx context.Context, reference string, export caseExport) ([]byte, error) {
+	plan, err := s.treatmentPlanRepo.GetByID(ctx, export.PatientID, export.TreatmentPlanID)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrTreatmentPlanNotFound
+	}
+	patient, err := s.patientRepo.GetByID(ctx, export.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	pairs, err := s.repository.List(ctx, export.PatientID, export.TreatmentPlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	redact := patientRedactor(patient)
+	presentation := models.CasePresentation{
+		Reference:  reference,
+		Sex:        patient.Sex,
+		AgeBand:    ageBand(patient.DateOfBirth, plan.CreatedAt),
+		Findings:   []models.CaseFinding{},
+		Plan:       redact(plan.Plan),
+		Images:     []models.CaseImageSet{},
+		ExportedAt: s.clock.Now(),
+	}
+	if export.IncludeCosts {
+		presentation.EstimatedCost = plan.EstimatedCost
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	var attachmentIDs []uint
+	for _, pair := range pairs {
+		if !pair.TeachingConsent {
+			continue
+		}
+		set := models.CaseImageSet{Item: redact(pair.Item), Tooth: pair.Tooth, Notes: redact(pair.Notes)}
+		for _, after := range []bool{false, true} {
+			image, err := s.repository.GalleryImage(ctx, pair.ID, models.ImageConsentTeaching, after)
+			if err != nil {
+				return nil, err
+			}
+			if image == nil {
+				return nil, fmt.Errorf("%w: the images of pair %d are no longer consented to for teaching", ErrInvalidCaseExport, pair.ID)
+			}
+			if err := s.files.LoadAttachment(ctx, image); err != nil {
+				return nil, err
+			}
+			content, extension, err := anonymizeImage(image)
+			if err != nil {
+				return nil, err
+			}
+			side := "before"
+			if after {
+				side = "after"
+			}
+			name := fmt.Sprintf("images/%d-%s%s", len(presentation.Images)+1, side, extension)
+			if err := writeZipFile(archive, name, content); err != nil {
+				return nil, err
+			}
+			if after {
+				set.After = name
+			} else {
+				set.Before = name
+			}
+			attachmentIDs = append(attachmentIDs, image.ID)
+		}
+		presentation.Images = append(presentation.Images, set)
+	}
+	if len(presentation.Images) == 0 {
+		return nil, fmt.Errorf("%w: the patient has not consented to any image pair of the plan being used for teaching", ErrInvalidCaseExport)
+	}
+
+	examinations, err := s.repository.AttachmentExaminations(ctx, attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	planDay := startOfDay(plan.CreatedAt)
+	for _, examination := range examinations {
+		days := math.Round(startOfDay(examination.CreatedAt).Sub(planDay).Hours() / 24)
+		presentation.Findings = append(presentation.Findings, models.CaseFinding{Day: int(days), Report: redact(examination.Report)})
+	}
+
+	data, err := json.MarshalIndent(presentation, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the case: %w", err)
+	}
+	if err := writeZipFile(archive, "case.json", data); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to package the case: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func caseExportReference(approvalID uint) string {
+	return fmt.Sprintf("case-%d", approvalID)
+}
+
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to package %s: %w", name, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to package %s: %w", name, err)
+	}
+	return nil
+}
+
+// anonymizeImage re-encodes an image, which drops its metadata such as EXIF, with the regions
+// annotated for redaction blacked out. PNG images stay PNG and the rest become JPEG; images that
+// cannot be decoded, such as DICOM, cannot be shared.
+func anonymizeImage(attachment *models.ExaminationAttachment) ([]byte, string, error) {
+	decoded, format, err := image.Decode(bytes.NewReader(attachment.Content))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: image %d is not a JPEG, PNG or GIF image that can be anonymized", ErrInvalidCaseExport, attachment.ID)
+	}
+	bounds := decoded.Bounds()
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, decoded, bounds.Min, draw.Src)
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	for _, annotation := range attachment.Annotations {
+		if !strings.EqualFold(strings.TrimSpace(annotation.Label), models.AnnotationLabelRedact) {
+			continue
+		}
+		region := annotation.Region
+		rect := image.Rect(
+			bounds.Min.X+int(math.Floor(region.X*width)), bounds.Min.Y+int(math.Floor(region.Y*height)),
+			bounds.Min.X+int(math.Ceil((region.X+region.Width)*width)), bounds.Min.Y+int(math.Ceil((region.Y+region.Height)*height)),
+		).Intersect(bounds)
+		draw.Draw(canvas, rect, image.Black, image.Point{}, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if format == "png" {
+		if err := png.Encode(&buf, canvas); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image %d: %w", attachment.ID, err)
+		}
+		return buf.Bytes(), ".png", nil
+	}
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: caseExportJPEGQuality}); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image %d: %w", attachment.ID, err)
+	}
+	return buf.Bytes(), ".jpg", nil
+}
+
+// patientRedactor returns a function replacing the patient's and their guarantor's names, IDs and
+// contact details in free text with [redacted]
+func patientRedactor(patient *models.Patient) func(string) string {
+	var terms []string
+	for _, term := range []string{
+		patient.FirstName, patient.MiddleName, patient.LastName, patient.ID, patient.NationalID, patient.MemberNumber,
+		patient.Phone, patient.Email, patient.Guarantor.Name, patient.Guarantor.Phone, patient.Guarantor.Email,
+	} {
+		for _, word := range append(strings.Fields(term), strings.TrimSpace(term)) {
+			if utf8.RuneCountInString(word) >= 2 {
+				terms = append(terms, word)
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return strings.TrimSpace
+	}
+	// The longest terms go first so a full name is replaced whole rather than word by word
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		pattern := regexp.QuoteMeta(term)
+		if first, _ := utf8.DecodeRuneInString(term); isWordRune(first) {
+			pattern = `\b` + pattern
+		}
+		if last, _ := utf8.DecodeLastRuneInString(term); isWordRune(last) {
+			pattern += `\b`
+		}
+		patterns[i] = pattern
+	}
+	identifiers := regexp.MustCompile(`(?i)` + strings.Join(patterns, "|"))
+	return func(text string) string {
+		return strings.TrimSpace(identifiers.ReplaceAllString(text, "[redacted]"))
+	}
+}
+
+// isWordRune reports whether r is a character \b in a regular expression treats as part of a word
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCaseImagePairNotFound = errors.New("case image pair not found")
+	ErrInvalidCaseImagePair  = errors.New("invalid case image pair")
+	// ErrGalleryImageNotFound is returned for gallery images that do not exist or that the patient
+	// did not consent to for the use asked for
+	ErrGalleryImageNotFound = errors.New("gallery image not found")
+)
+
+// Gallery listings are limited to
+const (
+	defaultGalleryLimit = 50
+	maxGalleryLimit     = 200
+)
+
+// CaseImageService pairs the images taken before and after treatment plan items and shows the
+// pairs patients consented to in a gallery for case presentations
+type CaseImageService struct {
+	repository        *repositories.CaseImageRepository
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+	files             *Files
+}
+
+func NewCaseImageService(repository *repositories.CaseImageRepository, treatmentPlanRepo *repositories.TreatmentPlanRepository, files *Files) *CaseImageService {
+	return &CaseImageService{repository: repository, treatmentPlanRepo: treatmentPlanRepo, files: files}
+}
+
+// Create pairs two of the patient's images for an item of their treatment plan
+func (s *CaseImageService) Create(ctx context.Context, patientID string, treatmentPlanID uint, pair *models.CaseImagePair) error {
+	if err := s.checkTreatmentPlan(ctx, patientID, treatmentPlanID); err != nil {
+		return err
+	}
+	pair.ID, pair.PatientID, pair.TreatmentPlanID = 0, patientID, treatmentPlanID
+	if err := s.validate(ctx, pair); err != nil {
+		return err
+	}
+	pair.ConsentRecordedAt, pair.ConsentRecordedBy = nil, nil
+	if pair.MarketingConsent || pair.PresentationConsent || pair.TeachingConsent {
+		stampImageConsent(ctx, pair)
+	}
+	return s.repository.Create(ctx, pair)
+}
+
+func (s *CaseImageService) Get(ctx context.Context, patientID string, treatmentPlanID, id uint) (*models.CaseImagePair, error) {
+	pair, err := s.repository.GetByID(ctx, patientID, treatmentPlanID, id)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, ErrCaseImagePairNotFound
+	}
+	return pair, nil
+}
+
+// List returns the pairs of the patient's treatment plan
+func (s *CaseImageService) List(ctx context.Context, patientID string, treatmentPlanID uint) ([]models.CaseImagePair, error) {
+	if err := s.checkTreatmentPlan(ctx, patientID, treatmentPlanID); err != nil {
+		return nil, err
+	}
+	pairs, err := s.repository.List(ctx, patientID, treatmentPlanID)
+	if err != nil {
+		return nil, err
+	}
+	if pairs == nil {
+		pairs = []models.CaseImagePair{}
+	}
+	return pairs, nil
+}
+
+// Update changes the treatment and images of a pair, keeping its consent
+func (s *CaseImageService) Update(ctx context.Context, patientID string, treatmentPlanID, id uint, update models.CaseImagePair) (*models.CaseImagePair, error) {
+	pair, err := s.Get(ctx, patientID, treatmentPlanID, id)
+	if err != nil {
+		return nil, err
+	}
+	pair.Item, pair.Tooth, pair.Notes = update.Item, update.Tooth, update.Notes
+	pair.BeforeAttachmentID, pair.AfterAttachmentID = update.BeforeAttachmentID, update.AfterAttachmentID
+	if err := s.validate(ctx, pair); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, pair); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// UpdateConsent records what the patient now agrees the pair may be used for
+func (s *CaseImageService) UpdateConsent(ctx context.Context, patientID string, treatmentPlanID, id uint, consent models.CaseImageConsent) (*models.CaseImagePair, error) {
+	pair, err := s.Get(ctx, patientID, treatmentPlanID, id)
+	if err != nil {
+		return nil, err
+	}
+	pair.MarketingConsent, pair.PresentationConsent, pair.TeachingConsent = consent.Marketing, consent.Presentation, consent.Teaching
+	stampImageConsent(ctx, pair)
+	if err := s.repository.UpdateConsent(ctx, pair); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (s *CaseImageService) Delete(ctx context.Context, patientID string, treatmentPlanID, id uint) error {
+	if _, err := s.Get(ctx, patientID, treatmentPlanID, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Gallery returns the pairs patients consented to for the use, marketing, presentation or teaching,
+// optionally only those whose treatment contains search
+func (s *CaseImageService) Gallery(ctx context.Context, consent, search string, limit int) ([]models.GalleryCase, error) {
+	if !models.IsValidImageConsent(consent) {
+		return nil, fmt.Errorf("%w: consent must be %q, %q or %q", ErrInvalidCaseImagePair, models.ImageConsentMarketing, models.ImageConsentPresentation, models.ImageConsentTeaching)
+	}
+	if limit <= 0 {
+		limit = defaultGalleryLimit
+	}
+	cases, err := s.repository.Gallery(ctx, consent, strings.TrimSpace(search), min(limit, maxGalleryLimit))
+	if err != nil {
+		return nil, err
+	}
+	if cases == nil {
+		cases = []models.GalleryCase{}
+	}
+	return cases, nil
+}
+
+// GalleryImage returns the before or after image of a pair consented to for the use
+func (s *CaseImageService) GalleryImage(ctx context.Context, id uint, consent, side string) (*models.ExaminationAttachment, error) {
+	if !models.IsValidImageConsent(consent) {
+		return nil, fmt.Errorf("%w: consent must be %q, %q or %q", ErrInvalidCaseImagePair, models.ImageConsentMarketing, models.ImageConsentPresentation, models.ImageConsentTeaching)
+	}
+	if side != "before" && side != "after" {
+		return nil, ErrGalleryImageNotFound
+	}
+	image, err := s.repository.GalleryImage(ctx, id, consent, side == "after")
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, ErrGalleryImageNotFound
+	}
+	if err := s.files.LoadAttachment(ctx, image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func (s *CaseImageService) checkTreatmentPlan(ctx context.Context, patientID string, treatmentPlanID uint) error {
+	plan, err := s.treatmentPlanRepo.GetByID(ctx, patientID, treatmentPlanID)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		return ErrTreatmentPlanNotFound
+	}
+	return nil
+}
+
+// validate checks the pair names the treatment and two different images filed with the patient's
+// examinations
+func (s *CaseImageService) validate(ctx context.Context, pair *models.CaseImagePair) error {
+	pair.Item, pair.Tooth, pair.Notes = strings.TrimSpace(pair.Item), strings.TrimSpace(pair.Tooth), strings.TrimSpace(pair.Notes)
+	switch {
+	case pair.Item == "":
+		return fmt.Errorf("%w: the treatment item is required", ErrInvalidCaseImagePair)
+	case pair.BeforeAttachmentID == 0 || pair.AfterAttachmentID == 0:
+		return fmt.Errorf("%w: both a before and an after image are required", ErrInvalidCaseImagePair)
+	case pair.BeforeAttachmentID == pair.AfterAttachmentID:
+		return fmt.Errorf("%w: the before and after images must differ", ErrInvalidCaseImagePair)
+	}
+	for _, id := range []uint{pair.BeforeAttachmentID, pair.AfterAttachmentID} {
+		attachment, err := s.repository.PatientAttachment(ctx, pair.PatientID, id)
+		if err != nil {
+			return err
+		}
+		if attachment == nil {
+			return fmt.Errorf("%w: the patient has no attachment %d", ErrInvalidCaseImagePair, id)
+		}
+		if !strings.HasPrefix(attachment.ContentType, "image/") {
+			return fmt.Errorf("%w: attachment %d is not an image", ErrInvalidCaseImagePair, id)
+		}
+	}
+	return nil
+}
+
+// stampImageConsent notes who recorded the pair's consent and when
+func stampImageConsent(ctx context.Context, pair *models.CaseImagePair) {
+	now := time.Now()
+	pair.ConsentRecordedAt, pair.ConsentRecordedBy = &now, nil
+	if userID, ok := models.ActorFrom(ctx); ok {
+		pair.ConsentRecordedBy = &userID
+	}
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrChairNotFound = errors.New("chair not found")
+	ErrInvalidChair  = errors.New("invalid room or chair")
+
+	ErrDowntimeNotFound = errors.New("downtime not found")
+	ErrInvalidDowntime  = errors.New("invalid downtime")
+)
+
+// maxDowntimeReportMonths is the longest span of months a downtime report covers
+const maxDowntimeReportMonths = 24
+
+type ChairService struct {
+	repository *repositories.ChairRepository
+}
+
+func NewChairService(repository *repositories.ChairRepository) *ChairService {
+	return &ChairService{repository: repository}
+}
+
+func (s *ChairService) CreateRoom(ctx context.Context, room *models.Room) error {
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" {
+		return fmt.Errorf("%w: the room name is required", ErrInvalidChair)
+	}
+	room.ID = 0
+	room.Chairs = nil
+	return s.repository.CreateRoom(ctx, room)
+}
+
+func (s *ChairService) GetRoom(ctx context.Context, id uint) (*models.Room, error) {
+	room, err := s.repository.GetRoom(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if room == nil {
+		return nil, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+func (s *ChairService) ListRooms(ctx context.Context) ([]models.Room, error) {
+	rooms, err := s.repository.ListRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rooms == nil {
+		rooms = []models.Room{}
+	}
+	return rooms, nil
+}
+
+func (s *ChairService) UpdateRoom(ctx context.Context, room *models.Room) error {
+	if _, err := s.GetRoom(ctx, room.ID); err != nil {
+		return err
+	}
+	room.Name = strings.TrimSpace(room.Name)
+	if room.Name == "" {
+		return fmt.Errorf("%w: the room name is required", ErrInvalidChair)
+	}
+	return s.repository.UpdateRoom(ctx, room)
+}
+
+func (s *ChairService) DeleteRoom(ctx context.Context, id uint) error {
+	if _, err := s.GetRoom(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteRoom(ctx, id)
+}
+
+// CreateChair adds a chair to a room; chairs take bookings unless created inactive
+func (s *ChairService) CreateChair(ctx context.Context, chair *models.Chair) error {
+	if err := s.validateChair(ctx, chair); err != nil {
+		return err
+	}
+	chair.ID = 0
+	return s.repository.CreateChair(ctx, chair)
+}
+
+func (s *ChairService) GetChair(ctx context.Context, id uint) (*models.Chair, error) {
+	chair, err := s.repository.GetChair(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if chair == nil {
+		return nil, ErrChairNotFound
+	}
+	return chair, nil
+}
+
+func (s *ChairService) ListChairs(ctx context.Context, activeOnly bool) ([]models.Chair, error) {
+	chairs, err := s.repository.ListChairs(ctx, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	if chairs == nil {
+		chairs = []models.Chair{}
+	}
+	return chairs, nil
+}
+
+// UpdateChair renames, moves or (de)activates a chair; an inactive chair keeps its existing bookings
+// but takes no new ones
+func (s *ChairService) UpdateChair(ctx context.Context, chair *models.Chair) error {
+	if _, err := s.GetChair(ctx, chair.ID); err != nil {
+		return err
+	}
+	if err := s.validateChair(ctx, chair); err != nil {
+		return err
+	}
+	return s.repository.UpdateChair(ctx, chair)
+}
+
+func (s *ChairService) DeleteChair(ctx context.Context, id uint) error {
+	if _, err := s.GetChair(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteChair(ctx, id)
+}
+
+func (s *ChairService) validateChair(ctx context.Context, chair *models.Chair) error {
+	chair.Name = strings.TrimSpace(chair.Name)
+	if chair.Name == "" {
+		return fmt.Errorf("%w: the chair name is required", ErrInvalidChair)
+	}
+	if _, err := s.GetRoom(ctx, chair.RoomID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateDowntime takes a chair or piece of equipment out of service. The appointments already booked
+// on the chair while it is down are returned with it, for the front desk to move.
+func (s *ChairService) CreateDowntime(ctx context.Context, downtime *models.Downtime) error {
+	if err := s.validateDowntime(ctx, downtime); err != nil {
+		return err
+	}
+	downtime.ID = 0
+	if err := s.repository.CreateDowntime(ctx, downtime); err != nil {
+		return err
+	}
+	return s.affected(ctx, downtime)
+}
+
+func (s *ChairService) GetDowntime(ctx context.Context, id uint) (*models.Downtime, error) {
+	downtime, err := s.repository.GetDowntime(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if downtime == nil {
+		return nil, ErrDowntimeNotFound
+	}
+	if err := s.affected(ctx, downtime); err != nil {
+		return nil, err
+	}
+	return downtime, nil
+}
+
+func (s *ChairService) ListDowntime(ctx context.Context, filter models.DowntimeFilter) ([]models.Downtime, error) {
+	filter.Equipment = strings.TrimSpace(filter.Equipment)
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidDowntime)
+	}
+	downtime, err := s.repository.ListDowntime(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if downtime == nil {
+		downtime = []models.Downtime{}
+	}
+	return downtime, nil
+}
+
+// UpdateDowntime changes a downtime, e.g. to set when the chair came back into service
+func (s *ChairService) UpdateDowntime(ctx context.Context, downtime *models.Downtime) error {
+	if _, err := s.GetDowntime(ctx, downtime.ID); err != nil {
+		return err
+	}
+	if err := s.validateDowntime(ctx, downtime); err != nil {
+		return err
+	}
+	if err := s.repository.UpdateDowntime(ctx, downtime); err != nil {
+		return err
+	}
+	return s.affected(ctx, downtime)
+}
+
+func (s *ChairService) DeleteDowntime(ctx context.Context, id uint) error {
+	if _, err := s.GetDowntime(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteDowntime(ctx, id)
+}
+
+// DowntimeReport returns the hours each chair and piece of equipment was out of service in the
+// months from through to (YYYY-MM), for maintenance budgeting. Downtime spanning months is split
+// between them, and ongoing downtime counts until now.
+func (s *ChairService) DowntimeReport(ctx context.Context, from, to string) (*models.DowntimeReport, error) {
+	first, err := time.ParseInLocation(models.FinancialPeriodLayout, from, models.ClinicLocation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: from must be a month such as 2024-05", ErrInvalidDowntime)
+	}
+	last, err := time.ParseInLocation(models.FinancialPeriodLayout, to, models.ClinicLocation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: to must be a month such as 2024-05", ErrInvalidDowntime)
+	}
+	end := last.AddDate(0, 1, 0)
+	if !end.After(first) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidDowntime)
+	}
+	if end.After(first.AddDate(0, maxDowntimeReportMonths, 0)) {
+		return nil, fmt.Errorf("%w: a report covers at most %d months", ErrInvalidDowntime, maxDowntimeReportMonths)
+	}
+
+	downtime, err := s.repository.ListDowntime(ctx, models.DowntimeFilter{From: &first, To: &end})
+	if err != nil {
+		return nil, err
+	}
+	chairs, err := s.repository.ListChairs(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	chairNames := map[uint]string{}
+	for _, chair := range chairs {
+		chairNames[chair.ID] = chair.Name
+	}
+
+	type key struct {
+		month     string
+		chairID   uint
+		equipment string
+	}
+	totals := map[key]*models.DowntimeHours{}
+	now := time.Now()
+	for _, d := range downtime {
+		stop := now
+		if d.EndsAt != nil {
+			stop = *d.EndsAt
+		}
+		for month := first; month.Before(end); month = month.AddDate(0, 1, 0) {
+			start, finish := d.StartsAt, stop
+			if start.Before(month) {
+				start = month
+			}
+			if next := month.AddDate(0, 1, 0); finish.After(next) {
+				finish = next
+			}
+			if !finish.After(start) {
+				continue
+			}
+			k := key{month: models.FinancialPeriodOf(month), equipment: d.Equipment}
+			if d.ChairID != nil {
+				k.chairID = *d.ChairID
+			}
+			hours := totals[k]
+			if hours == nil {
+				hours = &models.DowntimeHours{Month: k.month, ChairID: d.ChairID, ChairName: chairNames[k.chairID], Equipment: d.Equipment}
+				totals[k] = hours
+			}
+			hours.Incidents++
+			hours.Hours += finish.Sub(start).Hours()
+		}
+	}
+
+	report := &models.DowntimeReport{
+		From:   models.FinancialPeriodOf(first),
+		To:     models.FinancialPeriodOf(last),
+		Months: make([]models.DowntimeHours, 0, len(totals)),
+	}
+	for _, hours := range totals {
+		hours.Hours = math.Round(hours.Hours*100) / 100
+		report.TotalHours += hours.Hours
+		report.Months = append(report.Months, *hours)
+	}
+	report.TotalHours = math.Round(report.TotalHours*100) / 100
+	sort.Slice(report.Months, func(i, j int) bool {
+		a, b := report.Months[i], report.Months[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.ChairName != b.ChairName {
+			return a.ChairName < b.ChairName
+		}
+		return a.Equipment < b.Equipment
+	})
+	return report, nil
+}
+
+func (s *ChairService) validateDowntime(ctx context.Context, downtime *models.Downtime) error {
+	downtime.Equipment = strings.TrimSpace(downtime.Equipment)
+	downtime.Reason = strings.TrimSpace(downtime.Reason)
+	if downtime.ChairID == nil && downtime.Equipment == "" {
+		return fmt.Errorf("%w: a chair_id or the equipment is required", ErrInvalidDowntime)
+	}
+	if downtime.Reason == "" {
+		return fmt.Errorf("%w: the reason is required", ErrInvalidDowntime)
+	}
+	if downtime.StartsAt.IsZero() {
+		return fmt.Errorf("%w: starts_at is required", ErrInvalidDowntime)
+	}
+	if downtime.EndsAt != nil && !downtime.EndsAt.After(downtime.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidDowntime)
+	}
+	if downtime.ChairID != nil {
+		if _, err := s.GetChair(ctx, *downtime.ChairID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// affected fills in the appointments booked on the chair while it is down
+func (s *ChairService) affected(ctx context.Context, downtime *models.Downtime) error {
+	ids, err := s.repository.BookedDuring(ctx, downtime)
+	if err != nil {
+		return err
+	}
+	downtime.AffectedAppointments = ids
+	return nil
+}
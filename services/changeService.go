@@ -0,0 +1,68 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"time"
+)
+
+// maxChangesPageSize caps how many changes a single poll returns.
+const maxChangesPageSize = 1000
+
+// ChangePage is one page of changes and the cursor to poll the next one with
+type ChangePage struct {
+	Changes   []models.ChangeRecord `json:"changes"`
+	NextSince time.Time             `json:"next_since"`
+	HasMore   bool                  `json:"has_more"`
+}
+
+type ChangeService struct {
+	repository *repositories.ChangeRepository
+}
+
+func NewChangeService(repository *repositories.ChangeRepository) *ChangeService {
+	return &ChangeService{repository: repository}
+}
+
+// Changes returns the changes of entity after since. When the page is full, changes sharing the
+// last timestamp are held back for the next poll so a cursor never skips a record.
+func (s *ChangeService) Changes(ctx context.Context, entity string, since time.Time, limit int) (*ChangePage, error) {
+	if limit <= 0 || limit > maxChangesPageSize {
+		limit = maxChangesPageSize
+	}
+	changes, err := s.repository.List(ctx, entity, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ChangePage{NextSince: since}
+	page.Changes, page.HasMore = holdBackLastTimestamp(changes, limit, func(change models.ChangeRecord) time.Time {
+		return change.ChangedAt
+	})
+	if len(page.Changes) > 0 {
+		page.NextSince = page.Changes[len(page.Changes)-1].ChangedAt
+	}
+	if page.Changes == nil {
+		page.Changes = []models.ChangeRecord{}
+	}
+	return page, nil
+}
+
+// holdBackLastTimestamp reports whether a page of changes read with limit is full and, when it is,
+// drops the changes sharing its last timestamp for the next poll to return. A page made of a single
+// timestamp is returned whole rather than stalling the client.
+func holdBackLastTimestamp[T any](changes []T, limit int, changedAt func(T) time.Time) ([]T, bool) {
+	if len(changes) == 0 || len(changes) < limit {
+		return changes, false
+	}
+	last := changedAt(changes[len(changes)-1])
+	cut := len(changes)
+	for cut > 0 && changedAt(changes[cut-1]).Equal(last) {
+		cut--
+	}
+	if cut == 0 {
+		return changes, true
+	}
+	return changes[:cut], true
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrClaimNotFound      = errors.New("insurance claim not found")
+	ErrClaimExists        = errors.New("the bill already has an insurance claim")
+	ErrInvalidClaim       = errors.New("invalid insurance claim")
+	ErrClaimNotPending    = errors.New("insurance claim is not pending")
+	ErrNoApprovedClaims   = errors.New("no approved claims in the period")
+	ErrClaimBatchNotFound = errors.New("claim batch not found")
+	ErrInvalidBatchStatus = errors.New("invalid claim batch status")
+	ErrClaimBatchConflict = errors.New("claims changed while the batch was being prepared, try again")
+)
+
+// claimBatchTransitions lists the statuses each batch status may move to
+var claimBatchTransitions = map[string][]string{
+	models.ClaimBatchStatusSubmitted:    {models.ClaimBatchStatusAcknowledged, models.ClaimBatchStatusSettled, models.ClaimBatchStatusRejected},
+	models.ClaimBatchStatusAcknowledged: {models.ClaimBatchStatusSettled, models.ClaimBatchStatusRejected},
+}
+
+type ClaimService struct {
+	repository  *repositories.ClaimRepository
+	billingRepo *repositories.BillingRepository
+	insurerRepo *repositories.InsuranceCompanyRepository
+}
+
+func NewClaimService(repository *repositories.ClaimRepository, billingRepo *repositories.BillingRepository, insurerRepo *repositories.InsuranceCompanyRepository) *ClaimService {
+	return &ClaimService{repository: repository, billingRepo: billingRepo, insurerRepo: insurerRepo}
+}
+
+// CreateForBilling opens a claim against the patient's insurer for a bill. The amount defaults to
+// the part of the bill not paid in cash.
+func (s *ClaimService) CreateForBilling(ctx context.Context, billingID string, amount float64) (*models.InsuranceClaim, error) {
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+	existing, err := s.repository.GetByBillingID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrClaimExists
+	}
+	insurer, err := s.repository.InsurerForPatient(ctx, billing.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	if insurer == nil {
+		return nil, fmt.Errorf("%w: the patient has no insurer on file", ErrInvalidClaim)
+	}
+
+	if amount == 0 {
+		amount = billing.BillingAmount - billing.PaidCashAmount
+	}
+	amount = math.Round(amount*100) / 100
+	if amount <= 0 || amount > billing.BillingAmount {
+		return nil, fmt.Errorf("%w: the amount must be positive and at most the billed amount", ErrInvalidClaim)
+	}
+
+	serviceDate, _ := models.ClinicDay(billing.CreatedAt)
+	claim := &models.InsuranceClaim{
+		BillingID:          billing.BillingID,
+		PatientID:          billing.PatientID,
+		InsuranceCompanyID: insurer.ID,
+		Amount:             amount,
+		Status:             models.ClaimStatusPending,
+		ServiceDate:        serviceDate,
+	}
+	if err := s.repository.Create(ctx, claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+func (s *ClaimService) GetByID(ctx context.Context, id uint) (*models.InsuranceClaim, error) {
+	claim, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, ErrClaimNotFound
+	}
+	return claim, nil
+}
+
+func (s *ClaimService) List(ctx context.Context, filter models.ClaimFilter) ([]models.InsuranceClaim, error) {
+	claims, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		claims = []models.InsuranceClaim{}
+	}
+	return claims, nil
+}
+
+// Approve releases a pending claim for the next submission batch
+func (s *ClaimService) Approve(ctx context.Context, id uint) (*models.InsuranceClaim, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	approved, err := s.repository.Approve(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		return nil, ErrClaimNotPending
+	}
+	return s.GetByID(ctx, id)
+}
+
+// CreateBatch bundles the insurer's approved claims for services from from to to inclusive into a
+// submission file in the insurer's format, and marks them submitted under the batch reference
+func (s *ClaimService) CreateBatch(ctx context.Context, insuranceCompanyID string, from, to time.Time) (*models.ClaimBatch, error) {
+	insurer, err := s.insurerRepo.GetByID(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if insurer == nil {
+		return nil, ErrInsuranceCompanyNotFound
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidClaim)
+	}
+
+	lines, err := s.repository.ApprovedLines(ctx, insurer.ID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, ErrNoApprovedClaims
+	}
+
+	format := insurer.ClaimFormat
+	if format == "" {
+		format = models.ClaimFormatCSV
+	}
+	batch := &models.ClaimBatch{
+		InsuranceCompanyID: insurer.ID,
+		From:               from,
+		To:                 to,
+		Format:             format,
+		Status:             models.ClaimBatchStatusSubmitted,
+		ClaimCount:         len(lines),
+		SubmittedAt:        time.Now(),
+	}
+	ids := make([]uint, len(lines))
+	for i, line := range lines {
+		ids[i] = line.ClaimID
+		batch.TotalAmount += line.Amount
+	}
+	batch.TotalAmount = math.Round(batch.TotalAmount*100) / 100
+
+	// The reference is only known inside the batch's transaction, and it goes into the file
+	prepare := func(seq int64) error {
+		batch.Reference = fmt.Sprintf("%s-%s-%03d", strings.ToUpper(insurer.ID), batch.SubmittedAt.Format("20060102"), seq)
+		batch.FileName = fmt.Sprintf("claims_%s.%s", batch.Reference, format)
+		var err error
+		batch.ContentType, batch.Content, err = renderClaimFile(insurer, batch, lines)
+		return err
+	}
+	if err := s.repository.CreateBatch(ctx, batch, ids, prepare); err != nil {
+		if errors.Is(err, repositories.ErrClaimsChanged) {
+			return nil, ErrClaimBatchConflict
+		}
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (s *ClaimService) GetBatch(ctx context.Context, id uint) (*models.ClaimBatch, error) {
+	batch, err := s.repository.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrClaimBatchNotFound
+	}
+	return batch, nil
+}
+
+func (s *ClaimService) ListBatches(ctx context.Context, insuranceCompanyID string) ([]models.ClaimBatch, error) {
+	insurer, err := s.insurerRepo.GetByID(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if insurer == nil {
+		return nil, ErrInsuranceCompanyNotFound
+	}
+	batches, err := s.repository.ListBatches(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if batches == nil {
+		batches = []models.ClaimBatch{}
+	}
+	return batches, nil
+}
+
+func (s *ClaimService) ListBatchClaims(ctx context.Context, id uint) ([]models.InsuranceClaim, error) {
+	if _, err := s.GetBatch(ctx, id); err != nil {
+		return nil, err
+	}
+	claims, err := s.repository.ListBatchClaims(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		claims = []models.InsuranceClaim{}
+	}
+	return claims, nil
+}
+
+// UpdateBatchStatus records the insurer's response to a batch: acknowledged, then settled or rejected
+func (s *ClaimService) UpdateBatchStatus(ctx context.Context, id uint, status, note string) (*models.ClaimBatch, error) {
+	batch, err := s.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	status = strings.ToLower(strings.TrimSpace(status))
+	allowed := false
+	for _, next := range claimBatchTransitions[batch.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: a %s batch cannot become %q", ErrInvalidBatchStatus, batch.Status, status)
+	}
+
+	from := batch.Status
+	now := time.Now()
+	batch.Status = status
+	batch.Note = strings.TrimSpace(note)
+	if status == models.ClaimBatchStatusAcknowledged {
+		batch.AcknowledgedAt = &now
+	} else {
+		batch.ClosedAt = &now
+	}
+	updated, err := s.repository.UpdateBatchStatus(ctx, batch, from)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("%w: the batch was updated by someone else", ErrInvalidBatchStatus)
+	}
+	return batch, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"RoyDental/models"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// renderClaimFile writes a batch's claims in the insurer's submission format, returning the
+// content type and file content
+func renderClaimFile(insurer *models.InsuranceCompany, batch *models.ClaimBatch, lines []models.ClaimLine) (string, []byte, error) {
+	switch batch.Format {
+	case models.ClaimFormatXML:
+		content, err := claimXML(insurer, batch, lines)
+		return "application/xml; charset=utf-8", content, err
+	case models.ClaimFormatCSV:
+		content, err := claimCSV(batch, lines)
+		return "text/csv; charset=utf-8", content, err
+	default:
+		return "", nil, fmt.Errorf("unknown claim format %q", batch.Format)
+	}
+}
+
+// claimCSV writes one line per claim, each carrying the batch reference
+func claimCSV(batch *models.ClaimBatch, lines []models.ClaimLine) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"BatchReference", "ClaimID", "BillingID", "PatientID", "PatientName", "DateOfBirth", "Scheme", "DoctorID", "Procedure", "ServiceDate", "Amount"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		err := w.Write([]string{
+			batch.Reference,
+			strconv.FormatUint(uint64(line.ClaimID), 10),
+			line.BillingID,
+			line.PatientID,
+			line.PatientName,
+			line.DateOfBirth,
+			line.Scheme,
+			line.DoctorID,
+			line.Procedure,
+			line.ServiceDate.Format("2006-01-02"),
+			strconv.FormatFloat(line.Amount, 'f', 2, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write claim CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type claimXMLBatch struct {
+	XMLName     xml.Name       `xml:"ClaimBatch"`
+	Reference   string         `xml:"Reference,attr"`
+	Insurer     string         `xml:"Insurer"`
+	InsurerName string         `xml:"InsurerName"`
+	PeriodFrom  string         `xml:"PeriodFrom"`
+	PeriodTo    string         `xml:"PeriodTo"`
+	ClaimCount  int            `xml:"ClaimCount"`
+	TotalAmount string         `xml:"TotalAmount"`
+	Claims      []claimXMLLine `xml:"Claims>Claim"`
+}
+
+type claimXMLLine struct {
+	ID          uint   `xml:"ID,attr"`
+	BillingID   string `xml:"BillingID"`
+	PatientID   string `xml:"Patient>ID"`
+	PatientName string `xml:"Patient>Name"`
+	DateOfBirth string `xml:"Patient>DateOfBirth"`
+	Scheme      string `xml:"Patient>Scheme"`
+	DoctorID    string `xml:"DoctorID"`
+	Procedure   string `xml:"Procedure"`
+	ServiceDate string `xml:"ServiceDate"`
+	Amount      string `xml:"Amount"`
+}
+
+// claimXML writes the batch as a single document with its totals ahead of the claims
+func claimXML(insurer *models.InsuranceCompany, batch *models.ClaimBatch, lines []models.ClaimLine) ([]byte, error) {
+	doc := claimXMLBatch{
+		Reference:   batch.Reference,
+		Insurer:     insurer.ID,
+		InsurerName: insurer.Name,
+		PeriodFrom:  batch.From.Format("2006-01-02"),
+		PeriodTo:    batch.To.Format("2006-01-02"),
+		ClaimCount:  batch.ClaimCount,
+		TotalAmount: strconv.FormatFloat(batch.TotalAmount, 'f', 2, 64),
+		Claims:      make([]claimXMLLine, len(lines)),
+	}
+	for i, line := range lines {
+		doc.Claims[i] = claimXMLLine{
+			ID:          line.ClaimID,
+			BillingID:   line.BillingID,
+			PatientID:   line.PatientID,
+			PatientName: line.PatientName,
+			DateOfBirth: line.DateOfBirth,
+			Scheme:      line.Scheme,
+			DoctorID:    line.DoctorID,
+			Procedure:   line.Procedure,
+			ServiceDate: line.ServiceDate.Format("2006-01-02"),
+			Amount:      strconv.FormatFloat(line.Amount, 'f', 2, 64),
+		}
+	}
+
+	content, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write claim XML: %w", err)
+	}
+	return append([]byte(xml.Header), content...), nil
+}
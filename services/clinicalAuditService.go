@@ -0,0 +1,252 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxClinicalAuditSample bounds the visits an audit samples, which an auditor reviews one by one
+	maxClinicalAuditSample = 200
+	// clinicalAuditTrendMonths is the months the trends cover when no period is asked for
+	clinicalAuditTrendMonths = 12
+)
+
+var (
+	// ErrClinicalAuditNotFound is returned for audits, and visits of audits, that do not exist or
+	// whose appointment was deleted
+	ErrClinicalAuditNotFound = errors.New("clinical audit not found")
+	// ErrInvalidClinicalAudit is returned for audits without a name, with unreadable or reversed
+	// periods, sample sizes out of range, unknown doctors or no visit left to sample, and for scores
+	// out of range
+	ErrInvalidClinicalAudit = errors.New("invalid clinical audit")
+	// ErrClinicalAuditOwnVisit is returned to doctors scoring a visit of their own
+	ErrClinicalAuditOwnVisit = errors.New("doctors may not score their own visits")
+)
+
+// ClinicalAuditService draws random samples of fulfilled visits for the practice's internal
+// quality program, bundles the records of each sampled visit into a worksheet for the auditor,
+// keeps the auditor's scores and comments and reports how scores trend from month to month.
+type ClinicalAuditService struct {
+	repository      *repositories.ClinicalAuditRepository
+	appointmentRepo *repositories.AppointmentRepository
+	doctorAppRepo   *repositories.DoctorAppRepository
+	clock           clock.Clock
+}
+
+func NewClinicalAuditService(repository *repositories.ClinicalAuditRepository, appointmentRepo *repositories.AppointmentRepository, doctorAppRepo *repositories.DoctorAppRepository, clock clock.Clock) *ClinicalAuditService {
+	return &ClinicalAuditService{repository: repository, appointmentRepo: appointmentRepo, doctorAppRepo: doctorAppRepo, clock: clock}
+}
+
+// Create samples the audit's visits at random among those fulfilled in its period that no other
+// audit sampled, as many as its sample size or all there are when fewer
+func (s *ClinicalAuditService) Create(ctx context.Context, audit *models.ClinicalAudit) (*models.ClinicalAudit, error) {
+	audit.ID = 0
+	audit.Items = nil
+	audit.Name = strings.TrimSpace(audit.Name)
+	if audit.Name == "" {
+		return nil, fmt.Errorf("%w: a name is required", ErrInvalidClinicalAudit)
+	}
+	from, to, err := s.period(audit.From, audit.To)
+	if err != nil {
+		return nil, err
+	}
+	if audit.SampleSize < 1 || audit.SampleSize > maxClinicalAuditSample {
+		return nil, fmt.Errorf("%w: the sample size must be between 1 and %d", ErrInvalidClinicalAudit, maxClinicalAuditSample)
+	}
+	if audit.DoctorID != nil && *audit.DoctorID == "" {
+		audit.DoctorID = nil
+	}
+	if audit.DoctorID != nil {
+		doctor, err := s.doctorAppRepo.GetDoctor(ctx, *audit.DoctorID)
+		if err != nil {
+			return nil, err
+		}
+		if doctor == nil {
+			return nil, fmt.Errorf("%w: doctor %s not found", ErrInvalidClinicalAudit, *audit.DoctorID)
+		}
+	}
+
+	sampled, err := s.repository.Create(ctx, audit, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if sampled == 0 {
+		return nil, fmt.Errorf("%w: no fulfilled visit from %s to %s is left to sample", ErrInvalidClinicalAudit, audit.From, audit.To)
+	}
+	return s.Get(ctx, audit.ID)
+}
+
+func (s *ClinicalAuditService) Get(ctx context.Context, id uint) (*models.ClinicalAudit, error) {
+	audit, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if audit == nil {
+		return nil, ErrClinicalAuditNotFound
+	}
+	return audit, nil
+}
+
+func (s *ClinicalAuditService) List(ctx context.Context) ([]models.ClinicalAudit, error) {
+	audits, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if audits == nil {
+		audits = []models.ClinicalAudit{}
+	}
+	return audits, nil
+}
+
+// Delete removes an audit with its scores; its visits may be sampled by later audits
+func (s *ClinicalAuditService) Delete(ctx context.Context, id uint) error {
+	deleted, err := s.repository.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrClinicalAuditNotFound
+	}
+	return nil
+}
+
+// Worksheet returns the audit's sampled visits with the records to review for each, and how far
+// scoring went
+func (s *ClinicalAuditService) Worksheet(ctx context.Context, id uint) (*models.ClinicalAuditWorksheet, error) {
+	audit, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	details, err := s.repository.VisitDetails(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	worksheet := &models.ClinicalAuditWorksheet{Visits: make([]models.ClinicalAuditVisit, 0, len(audit.Items))}
+	total := 0
+	for _, item := range audit.Items {
+		// Visits whose appointment was deleted since they were sampled are left out
+		visit, ok := details[item.AppointmentID]
+		if !ok {
+			continue
+		}
+		visit.Item = item
+		from, to := models.ClinicDay(item.VisitedAt)
+		if err := s.repository.VisitRecords(ctx, &visit, from, to); err != nil {
+			return nil, err
+		}
+		if visit.Examinations == nil {
+			visit.Examinations = []models.Examination{}
+		}
+		if visit.TreatmentPlans == nil {
+			visit.TreatmentPlans = []models.TreatmentPlan{}
+		}
+		if visit.Billings == nil {
+			visit.Billings = []models.Billing{}
+		}
+		worksheet.Visits = append(worksheet.Visits, visit)
+		if item.Score != nil {
+			worksheet.Scored++
+			total += *item.Score
+		}
+	}
+	if worksheet.Scored > 0 {
+		average := float64(total) / float64(worksheet.Scored)
+		worksheet.AverageScore = &average
+	}
+	audit.Items = nil
+	worksheet.Audit = *audit
+	return worksheet, nil
+}
+
+// Score records the signed-in auditor's score and comments of a sampled visit, replacing an
+// earlier score. Doctors may not score visits of their own, and visits whose appointment was
+// deleted cannot be scored.
+func (s *ClinicalAuditService) Score(ctx context.Context, auditID, id uint, score models.ClinicalAuditScore) (*models.ClinicalAuditItem, error) {
+	if score.Score < models.MinClinicalAuditScore || score.Score > models.MaxClinicalAuditScore {
+		return nil, fmt.Errorf("%w: the score must be between %d and %d", ErrInvalidClinicalAudit, models.MinClinicalAuditScore, models.MaxClinicalAuditScore)
+	}
+	item, err := s.repository.GetItem(ctx, auditID, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrClinicalAuditNotFound
+	}
+	appointment, err := s.appointmentRepo.GetByID(ctx, item.PatientID, item.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, ErrClinicalAuditNotFound
+	}
+
+	var auditor *int64
+	if userID, ok := models.ActorFrom(ctx); ok {
+		doctor, err := s.doctorAppRepo.GetDoctorByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if doctor != nil && doctor.ID == item.DoctorID {
+			return nil, ErrClinicalAuditOwnVisit
+		}
+		auditor = &userID
+	}
+
+	now := s.clock.Now()
+	item.Score = &score.Score
+	item.Comments = strings.TrimSpace(score.Comments)
+	item.ScoredBy, item.ScoredAt = auditor, &now
+	if err := s.repository.Score(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Trends returns how scored visits fared month by month from from to to (YYYY-MM-DD), the last
+// twelve months by default, of the doctor's visits when doctorID is set
+func (s *ClinicalAuditService) Trends(ctx context.Context, from, to, doctorID string) ([]models.ClinicalAuditTrend, error) {
+	now := s.clock.Now().In(models.ClinicLocation())
+	if to == "" {
+		to = now.Format("2006-01-02")
+	}
+	if from == "" {
+		from = time.Date(now.Year(), now.Month()-clinicalAuditTrendMonths+1, 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+	}
+	start, end, err := s.period(from, to)
+	if err != nil {
+		return nil, err
+	}
+	trends, err := s.repository.Trends(ctx, start, end, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if trends == nil {
+		trends = []models.ClinicalAuditTrend{}
+	}
+	return trends, nil
+}
+
+// period returns when the clinic day from starts and when the day after to starts
+func (s *ClinicalAuditService) period(from, to string) (time.Time, time.Time, error) {
+	start, err := models.ParseClinicDate(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a date as YYYY-MM-DD", ErrInvalidClinicalAudit)
+	}
+	last, err := models.ParseClinicDate(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a date as YYYY-MM-DD", ErrInvalidClinicalAudit)
+	}
+	if last.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the period ends before it starts", ErrInvalidClinicalAudit)
+	}
+	_, end := models.ClinicDay(last)
+	return start, end, nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrInvalidTemplate       = errors.New("invalid template")
+	ErrTemplateNotOwned      = errors.New("only the owner of a template may change it")
+	ErrTemplateNotInReview   = errors.New("template is not awaiting approval")
+	ErrTemplateWrongKind     = errors.New("template is of another kind")
+	ErrTemplateAlreadyShared = errors.New("template is already shared or awaiting approval")
+)
+
+// ClinicalTemplateService manages doctors' examination and treatment plan templates. A template
+// is private to its owner until an Admin approves sharing it with the clinic; changing a shared
+// template puts it back up for approval, so the clinic only ever uses reviewed text.
+type ClinicalTemplateService struct {
+	repository *repositories.ClinicalTemplateRepository
+}
+
+func NewClinicalTemplateService(repository *repositories.ClinicalTemplateRepository) *ClinicalTemplateService {
+	return &ClinicalTemplateService{repository: repository}
+}
+
+func (s *ClinicalTemplateService) Create(ctx context.Context, template *models.ClinicalTemplate) error {
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	template.Sharing = models.TemplatePrivate
+	template.ReviewedBy, template.ReviewedAt, template.ReviewNote = nil, nil, ""
+	return s.repository.Create(ctx, template)
+}
+
+// Get returns a template the user owns or that is shared; Admins see every template
+func (s *ClinicalTemplateService) Get(ctx context.Context, id uint, userID int64, admin bool) (*models.ClinicalTemplate, error) {
+	template, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil || !(admin || template.OwnerID == userID || template.Sharing == models.TemplateShared) {
+		return nil, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// List returns the templates the user owns and the shared ones, narrowed down by filter
+func (s *ClinicalTemplateService) List(ctx context.Context, userID int64, filter models.TemplateFilter) ([]models.ClinicalTemplate, error) {
+	if filter.Kind != "" && !models.IsValidTemplateKind(filter.Kind) {
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidTemplate, filter.Kind)
+	}
+	filter.ViewerID = userID
+	return s.list(ctx, filter)
+}
+
+// Pending returns the templates awaiting approval for clinic-wide use
+func (s *ClinicalTemplateService) Pending(ctx context.Context) ([]models.ClinicalTemplate, error) {
+	return s.list(ctx, models.TemplateFilter{Sharing: models.TemplatePending})
+}
+
+func (s *ClinicalTemplateService) list(ctx context.Context, filter models.TemplateFilter) ([]models.ClinicalTemplate, error) {
+	templates, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if templates == nil {
+		templates = []models.ClinicalTemplate{}
+	}
+	return templates, nil
+}
+
+// Update changes the name, kind and body of the user's own template
+func (s *ClinicalTemplateService) Update(ctx context.Context, changes *models.ClinicalTemplate, userID int64) (*models.ClinicalTemplate, error) {
+	if err := validateTemplate(changes); err != nil {
+		return nil, err
+	}
+	template, err := s.owned(ctx, changes.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	template.Kind, template.Name, template.Body = changes.Kind, changes.Name, changes.Body
+	if template.Sharing == models.TemplateShared {
+		template.Sharing = models.TemplatePending
+	}
+	if err := s.repository.Update(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Delete removes a template; Admins may remove any template, doctors only their own
+func (s *ClinicalTemplateService) Delete(ctx context.Context, id uint, userID int64, admin bool) error {
+	template, err := s.Get(ctx, id, userID, admin)
+	if err != nil {
+		return err
+	}
+	if !admin && template.OwnerID != userID {
+		return ErrTemplateNotOwned
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// RequestSharing puts the user's private template up for approval for clinic-wide use
+func (s *ClinicalTemplateService) RequestSharing(ctx context.Context, id uint, userID int64) (*models.ClinicalTemplate, error) {
+	template, err := s.owned(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if template.Sharing != models.TemplatePrivate {
+		return nil, ErrTemplateAlreadyShared
+	}
+	template.Sharing = models.TemplatePending
+	if err := s.repository.Update(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Review approves or declines sharing a template with the clinic. A declined template stays
+// private to its owner, with the reviewer's note explaining why.
+func (s *ClinicalTemplateService) Review(ctx context.Context, id uint, reviewerID int64, approve bool, note string) (*models.ClinicalTemplate, error) {
+	template, err := s.Get(ctx, id, reviewerID, true)
+	if err != nil {
+		return nil, err
+	}
+	if template.Sharing != models.TemplatePending {
+		return nil, ErrTemplateNotInReview
+	}
+	now := time.Now()
+	template.Sharing = models.TemplatePrivate
+	if approve {
+		template.Sharing = models.TemplateShared
+	}
+	template.ReviewedBy, template.ReviewedAt, template.ReviewNote = &reviewerID, &now, strings.TrimSpace(note)
+	if err := s.repository.Update(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Insert puts the body of a template of the given kind ahead of text, for records created from a
+// template. Shared templates can be used by anyone, private ones only by the user writing.
+func (s *ClinicalTemplateService) Insert(ctx context.Context, id uint, kind, text string) (string, error) {
+	userID, _ := models.ActorFrom(ctx)
+	template, err := s.Get(ctx, id, userID, false)
+	if err != nil {
+		return "", err
+	}
+	if template.Kind != kind {
+		return "", ErrTemplateWrongKind
+	}
+	if strings.TrimSpace(text) == "" {
+		return template.Body, nil
+	}
+	return strings.TrimRight(template.Body, "\n") + "\n\n" + text, nil
+}
+
+func (s *ClinicalTemplateService) owned(ctx context.Context, id uint, userID int64) (*models.ClinicalTemplate, error) {
+	template, err := s.Get(ctx, id, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	if template.OwnerID != userID {
+		return nil, ErrTemplateNotOwned
+	}
+	return template, nil
+}
+
+func validateTemplate(template *models.ClinicalTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return fmt.Errorf("%w: a name is required", ErrInvalidTemplate)
+	}
+	if strings.TrimSpace(template.Body) == "" {
+		return fmt.Errorf("%w: a body is required", ErrInvalidTemplate)
+	}
+	if !models.IsValidTemplateKind(template.Kind) {
+		return fmt.Errorf("%w: kind must be examination or treatment_plan", ErrInvalidTemplate)
+	}
+	return nil
+}
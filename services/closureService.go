@@ -0,0 +1,164 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxClosureDays caps how long a single closure may run
+const maxClosureDays = 366
+
+var (
+	ErrClosureNotFound = errors.New("closure not found")
+	ErrInvalidClosure  = errors.New("invalid closure")
+)
+
+// ClosureService manages the clinic's closure calendar. Saving a closure reports the appointments
+// already booked on it, as declaring it does not move or cancel them.
+type ClosureService struct {
+	repository *repositories.ClosureRepository
+}
+
+func NewClosureService(repository *repositories.ClosureRepository) *ClosureService {
+	return &ClosureService{repository: repository}
+}
+
+func (s *ClosureService) Create(ctx context.Context, closure *models.Closure) (*models.ClosureResult, error) {
+	if err := validateClosure(closure); err != nil {
+		return nil, err
+	}
+	closure.ID = 0
+	if err := s.repository.Create(ctx, closure); err != nil {
+		return nil, err
+	}
+	return s.result(ctx, *closure)
+}
+
+func (s *ClosureService) Get(ctx context.Context, id uint) (*models.Closure, error) {
+	closure, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if closure == nil {
+		return nil, ErrClosureNotFound
+	}
+	return closure, nil
+}
+
+// List returns the closures overlapping the days from through to, both optional YYYY-MM-DD dates
+func (s *ClosureService) List(ctx context.Context, from, to string) ([]models.Closure, error) {
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if _, err := models.ParseClinicDate(value); err != nil {
+			return nil, fmt.Errorf("%w: dates must be given as YYYY-MM-DD", ErrInvalidClosure)
+		}
+	}
+	closures, err := s.repository.List(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if closures == nil {
+		closures = []models.Closure{}
+	}
+	return closures, nil
+}
+
+func (s *ClosureService) Update(ctx context.Context, closure *models.Closure) (*models.ClosureResult, error) {
+	if _, err := s.Get(ctx, closure.ID); err != nil {
+		return nil, err
+	}
+	if err := validateClosure(closure); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, closure); err != nil {
+		return nil, err
+	}
+	return s.result(ctx, *closure)
+}
+
+func (s *ClosureService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Appointments returns the appointments still booked on a closure
+func (s *ClosureService) Appointments(ctx context.Context, id uint) ([]models.ClosedAppointment, error) {
+	closure, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.appointmentsOn(ctx, *closure)
+}
+
+// result pairs a saved closure with the appointments falling on it, and warns when there are any
+func (s *ClosureService) result(ctx context.Context, closure models.Closure) (*models.ClosureResult, error) {
+	appointments, err := s.appointmentsOn(ctx, closure)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ClosureResult{Closure: closure, Appointments: appointments}
+	if len(appointments) > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d appointment(s) are booked while the clinic is closed and need to be moved or cancelled", len(appointments)))
+	}
+	return result, nil
+}
+
+func (s *ClosureService) appointmentsOn(ctx context.Context, closure models.Closure) ([]models.ClosedAppointment, error) {
+	start, err := models.ParseClinicDate(closure.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := models.ParseClinicDate(closure.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	from, _ := models.ClinicDay(start)
+	_, to := models.ClinicDay(end)
+	appointments, err := s.repository.AppointmentsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if appointments == nil {
+		appointments = []models.ClosedAppointment{}
+	}
+	return appointments, nil
+}
+
+func validateClosure(closure *models.Closure) error {
+	closure.Reason = strings.TrimSpace(closure.Reason)
+	if closure.Reason == "" {
+		return fmt.Errorf("%w: a reason such as the name of the holiday is required", ErrInvalidClosure)
+	}
+	if closure.Kind == "" {
+		closure.Kind = models.ClosureKindClosure
+	}
+	if !models.IsValidClosureKind(closure.Kind) {
+		return fmt.Errorf("%w: kind must be public_holiday or closure", ErrInvalidClosure)
+	}
+	if closure.EndDate == "" {
+		closure.EndDate = closure.StartDate
+	}
+	start, err := models.ParseClinicDate(closure.StartDate)
+	if err != nil {
+		return fmt.Errorf("%w: start_date must be given as YYYY-MM-DD", ErrInvalidClosure)
+	}
+	end, err := models.ParseClinicDate(closure.EndDate)
+	if err != nil {
+		return fmt.Errorf("%w: end_date must be given as YYYY-MM-DD", ErrInvalidClosure)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("%w: end_date must not be before start_date", ErrInvalidClosure)
+	}
+	if end.After(start.AddDate(0, 0, maxClosureDays-1)) {
+		return fmt.Errorf("%w: a closure may not run for more than %d days", ErrInvalidClosure, maxClosureDays)
+	}
+	return nil
+}
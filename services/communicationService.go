@@ -0,0 +1,237 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPortalSignature is returned for portal links that were not issued by this server
+var ErrInvalidPortalSignature = errors.New("invalid portal link")
+
+// CommunicationService keeps patients' communication preferences, which the desk changes on their
+// behalf and patients change themselves through signed portal links. It decides which messages
+// the notifiers may send.
+type CommunicationService struct {
+	repository        *repositories.CommunicationRepository
+	patientRepository *repositories.PatientRepository
+	config            config.PortalConfig
+}
+
+func NewCommunicationService(repository *repositories.CommunicationRepository, patientRepository *repositories.PatientRepository, cfg config.PortalConfig) *CommunicationService {
+	return &CommunicationService{repository: repository, patientRepository: patientRepository, config: cfg}
+}
+
+// PortalEnabled reports whether portal links can be signed and point somewhere
+func (s *CommunicationService) PortalEnabled() bool {
+	return s.config.SigningKey != "" && s.config.BaseURL != ""
+}
+
+// Sign returns the signature of the portal link of a patient
+func (s *CommunicationService) Sign(patientID string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	mac.Write([]byte("portal:" + patientID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *CommunicationService) verify(patientID, signature string) error {
+	expected, err := hex.DecodeString(s.Sign(patientID))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return ErrInvalidPortalSignature
+	}
+	return nil
+}
+
+// PreferencesLink returns the signed portal link a patient changes their preferences at
+func (s *CommunicationService) PreferencesLink(patientID string) string {
+	if !s.PortalEnabled() {
+		return ""
+	}
+	return fmt.Sprintf("%s?patient=%s&sig=%s", strings.TrimRight(s.config.BaseURL, "/"), url.QueryEscape(patientID), s.Sign(patientID))
+}
+
+// Allows reports whether a patient may be contacted on channel for purpose. Nobody is sent
+// marketing without having opted in.
+func (s *CommunicationService) Allows(ctx context.Context, patientID, channel, purpose string) (bool, error) {
+	preference, err := s.preference(ctx, patientID)
+	if err != nil {
+		return false, err
+	}
+	switch channel {
+	case notifications.ChannelEmail:
+		if !preference.Email {
+			return false, nil
+		}
+	case notifications.ChannelSMS:
+		if !preference.SMS {
+			return false, nil
+		}
+	default:
+		return false, fmt.Errorf("unknown channel %q", channel)
+	}
+	switch purpose {
+	case notifications.PurposeReminder:
+		return preference.Reminders, nil
+	case notifications.PurposeMarketing:
+		return preference.Marketing, nil
+	}
+	return true, nil
+}
+
+// GetPreference returns a patient's preferences, the defaults when they never stated any
+func (s *CommunicationService) GetPreference(ctx context.Context, patientID string) (*models.CommunicationPreference, error) {
+	if _, err := s.patient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	return s.preference(ctx, patientID)
+}
+
+// UpdatePreference changes a patient's preferences at the desk
+func (s *CommunicationService) UpdatePreference(ctx context.Context, patientID string, update models.CommunicationPreferenceUpdate) (*models.CommunicationPreference, error) {
+	patient, err := s.patient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.SavePreference(ctx, patientID, update, models.ConsentSourceDesk, consentGivenBy(patient))
+}
+
+// ListConsents returns the consents a patient gave and withdrew, newest first
+func (s *CommunicationService) ListConsents(ctx context.Context, patientID string) ([]models.ConsentRecord, error) {
+	if _, err := s.patient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	records, err := s.repository.ListConsents(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []models.ConsentRecord{}
+	}
+	return records, nil
+}
+
+// ListLog returns the messages sent to a patient, newest first
+func (s *CommunicationService) ListLog(ctx context.Context, patientID string) ([]models.CommunicationLog, error) {
+	if _, err := s.patient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	entries, err := s.repository.ListLog(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.CommunicationLog{}
+	}
+	return entries, nil
+}
+
+// LogMessage records the outcome of sending a message to a patient: sent, held until quiet hours
+// end, held back by their preferences or because the address bounced, or failed with sendErr
+func (s *CommunicationService) LogMessage(ctx context.Context, entry models.CommunicationLog, sendErr error) error {
+	entry.Status, entry.Error = messageStatus(sendErr)
+	if entry.SentAt.IsZero() {
+		entry.SentAt = time.Now()
+	}
+	return s.repository.LogMessage(ctx, &entry)
+}
+
+// SettleDeferred records the outcome of delivering a message held back by quiet hours, logged
+// deferred with messageID when it was held
+func (s *CommunicationService) SettleDeferred(ctx context.Context, messageID string, sendErr error) error {
+	status, errText := messageStatus(sendErr)
+	return s.repository.SettleDeferred(ctx, messageID, status, errText)
+}
+
+// messageStatus returns the status a message is logged with when sending it returned sendErr, and
+// the error shown with it
+func messageStatus(sendErr error) (string, string) {
+	switch {
+	case sendErr == nil:
+		return models.MessageSent, ""
+	case errors.Is(sendErr, notifications.ErrDeferred):
+		return models.MessageDeferred, sendErr.Error()
+	case errors.Is(sendErr, notifications.ErrSuppressed), errors.Is(sendErr, notifications.ErrQuietHours):
+		return models.MessageNotPermitted, sendErr.Error()
+	case errors.Is(sendErr, notifications.ErrNotPermitted):
+		return models.MessageNotPermitted, ""
+	default:
+		return models.MessageFailed, sendErr.Error()
+	}
+}
+
+// GetPortalPreferences returns the preferences shown on a patient's portal page
+func (s *CommunicationService) GetPortalPreferences(ctx context.Context, patientID, signature string) (*models.PortalPreferences, error) {
+	if err := s.verify(patientID, signature); err != nil {
+		return nil, err
+	}
+	patient, err := s.patient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	preference, err := s.preference(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PortalPreferences{FirstName: patient.FirstName, Preferences: *preference}, nil
+}
+
+// UpdatePortalPreferences changes a patient's preferences as they chose on their portal page
+func (s *CommunicationService) UpdatePortalPreferences(ctx context.Context, patientID, signature string, update models.CommunicationPreferenceUpdate) (*models.PortalPreferences, error) {
+	if err := s.verify(patientID, signature); err != nil {
+		return nil, err
+	}
+	patient, err := s.patient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	preference, err := s.repository.SavePreference(ctx, patientID, update, models.ConsentSourcePortal, consentGivenBy(patient))
+	if err != nil {
+		return nil, err
+	}
+	return &models.PortalPreferences{FirstName: patient.FirstName, Preferences: *preference}, nil
+}
+
+// consentGivenBy is who gives consent for a patient: their guarantor when they have one, or the patient
+func consentGivenBy(patient *models.Patient) string {
+	if patient.Guarantor.Name != "" {
+		return patient.Guarantor.Name
+	}
+	return strings.TrimSpace(patient.FirstName + " " + patient.LastName)
+}
+
+func (s *CommunicationService) patient(ctx context.Context, patientID string) (*models.Patient, error) {
+	patient, err := s.patientRepository.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	return patient, nil
+}
+
+func (s *CommunicationService) preference(ctx context.Context, patientID string) (*models.CommunicationPreference, error) {
+	preference, err := s.repository.GetPreference(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if preference == nil {
+		defaults := models.DefaultCommunicationPreference(patientID)
+		return &defaults, nil
+	}
+	return preference, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrContractRateNotFound     = errors.New("contract rate not found")
+	ErrInsuranceCompanyNotFound = errors.New("insurance company not found")
+	ErrInvalidContractRate      = errors.New("invalid contract rate")
+)
+
+type ContractRateService struct {
+	repository    *repositories.ContractRateRepository
+	insurerRepo   *repositories.InsuranceCompanyRepository
+	procedureRepo *repositories.ProcedureRepository
+}
+
+func NewContractRateService(repository *repositories.ContractRateRepository, insurerRepo *repositories.InsuranceCompanyRepository, procedureRepo *repositories.ProcedureRepository) *ContractRateService {
+	return &ContractRateService{repository: repository, insurerRepo: insurerRepo, procedureRepo: procedureRepo}
+}
+
+// Create adds a rate to the insurer's price list, effective today unless a date is given
+func (s *ContractRateService) Create(ctx context.Context, rate *models.ContractRate) error {
+	if err := s.checkInsurer(ctx, rate.InsuranceCompanyID); err != nil {
+		return err
+	}
+	procedure, err := s.procedureRepo.GetByID(ctx, rate.ProcedureID)
+	if err != nil {
+		return err
+	}
+	if procedure == nil {
+		return ErrProcedureNotFound
+	}
+	if err := validateContractRate(rate); err != nil {
+		return err
+	}
+	rate.ID = 0
+	if err := s.repository.Create(ctx, rate); err != nil {
+		return err
+	}
+	rate.Procedure = *procedure
+	return nil
+}
+
+func (s *ContractRateService) List(ctx context.Context, insuranceCompanyID string) ([]models.ContractRate, error) {
+	if err := s.checkInsurer(ctx, insuranceCompanyID); err != nil {
+		return nil, err
+	}
+	rates, err := s.repository.ListByInsurer(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if rates == nil {
+		rates = []models.ContractRate{}
+	}
+	return rates, nil
+}
+
+// Update changes the amount or effective date of a rate; bills already priced keep their amount
+func (s *ContractRateService) Update(ctx context.Context, rate *models.ContractRate) error {
+	existing, err := s.repository.GetByID(ctx, rate.InsuranceCompanyID, rate.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrContractRateNotFound
+	}
+	if rate.EffectiveFrom.IsZero() {
+		rate.EffectiveFrom = existing.EffectiveFrom
+	}
+	if err := validateContractRate(rate); err != nil {
+		return err
+	}
+	if err := s.repository.Update(ctx, rate); err != nil {
+		return err
+	}
+	rate.ProcedureID = existing.ProcedureID
+	rate.Procedure = existing.Procedure
+	rate.CreatedAt = existing.CreatedAt
+	return nil
+}
+
+func (s *ContractRateService) Delete(ctx context.Context, insuranceCompanyID string, id uint) error {
+	return s.repository.Delete(ctx, insuranceCompanyID, id)
+}
+
+// VarianceReport lists the bills from from to to inclusive whose amount differs from the contract
+// rate, with totals
+func (s *ContractRateService) VarianceReport(ctx context.Context, from, to time.Time, insuranceCompanyID string) (*models.ContractVarianceReport, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidContractRate)
+	}
+	variances, err := s.repository.Variances(ctx, from, to.AddDate(0, 0, 1), insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ContractVarianceReport{
+		From:  from.Format("2006-01-02"),
+		To:    to.Format("2006-01-02"),
+		Bills: []models.ContractVariance{},
+	}
+	for _, variance := range variances {
+		report.Bills = append(report.Bills, variance)
+		report.TotalContract += variance.ContractAmount
+		report.TotalBilled += variance.BillingAmount
+	}
+	report.TotalContract = math.Round(report.TotalContract*100) / 100
+	report.TotalBilled = math.Round(report.TotalBilled*100) / 100
+	report.TotalVariance = math.Round((report.TotalBilled-report.TotalContract)*100) / 100
+	return report, nil
+}
+
+func (s *ContractRateService) checkInsurer(ctx context.Context, id string) error {
+	insurer, err := s.insurerRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if insurer == nil {
+		return ErrInsuranceCompanyNotFound
+	}
+	return nil
+}
+
+func validateContractRate(rate *models.ContractRate) error {
+	if rate.Amount < 0 {
+		return fmt.Errorf("%w: the amount cannot be negative", ErrInvalidContractRate)
+	}
+	if rate.EffectiveFrom.IsZero() {
+		rate.EffectiveFrom, _ = models.ClinicDay(time.Now())
+	}
+	return nil
+}
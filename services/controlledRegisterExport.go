@@ -0,0 +1,63 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+)
+
+// ControlledRegisterExporter turns the controlled substance register into the returns filed with
+// the regulator.
+type ControlledRegisterExporter struct {
+	repository *repositories.ExportRepository
+	config     config.ControlledSubstanceConfig
+}
+
+// RegulatorCSV exports the period's register entries with the practice registration on every line,
+// in register order so gaps in the numbering can be spotted
+func (e *ControlledRegisterExporter) RegulatorCSV(ctx context.Context, job models.ExportJob) (*ExportFile, error) {
+	entries, err := e.repository.GetControlledRegisterEntries(ctx, job.From, job.To.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"PracticeRegistration", "RegisterNumber", "DatePrescribed", "PrescriberID", "PrescriberName", "PatientID", "PatientName", "Drug", "Schedule", "Dose", "Quantity"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		err := w.Write([]string{
+			e.config.PracticeRegistration,
+			strconv.FormatInt(entry.Number, 10),
+			entry.PrescribedOn.Format("2006-01-02"),
+			entry.DoctorID,
+			entry.DoctorName,
+			entry.PatientID,
+			entry.PatientName,
+			entry.Drug,
+			entry.Schedule,
+			entry.Dose,
+			strconv.FormatFloat(entry.Quantity, 'f', -1, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write regulator CSV: %w", err)
+	}
+
+	return &ExportFile{
+		Name:        exportFileName(job, "csv"),
+		ContentType: "text/csv; charset=utf-8",
+		Content:     buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrCredentialsExpired is returned when booking a doctor whose licence, indemnity insurance or CPD
+// compliance has expired by the day of the appointment
+var ErrCredentialsExpired = errors.New("the doctor's credentials have expired")
+
+// credentialNames are how credentials are named in reminders and errors
+var credentialNames = map[string]string{
+	models.CredentialLicence:   "practising licence",
+	models.CredentialIndemnity: "indemnity insurance",
+	models.CredentialCPD:       "CPD compliance",
+}
+
+// CredentialService tracks when doctors' licences, indemnity insurance and CPD compliance run out.
+// Doctors are reminded, and the expiry posted to chat, once when a credential enters the warning
+// period and once when it expires; a doctor with an expired credential cannot be scheduled.
+type CredentialService struct {
+	repository *repositories.CredentialRepository
+	doctorRepo *repositories.DoctorRepository
+	notifier   notifications.Notifier
+	config     config.CredentialConfig
+}
+
+// NewCredentialService starts checking for reminders to send every config.ReminderInterval
+func NewCredentialService(repository *repositories.CredentialRepository, doctorRepo *repositories.DoctorRepository, notifier notifications.Notifier, cfg config.CredentialConfig) *CredentialService {
+	s := &CredentialService{repository: repository, doctorRepo: doctorRepo, notifier: notifier, config: cfg}
+	if cfg.ReminderInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *CredentialService) run() {
+	ticker := time.NewTicker(s.config.ReminderInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		if err := s.remind(context.Background()); err != nil {
+			log.Printf("Failed to send credential reminders: %v", err)
+		}
+	}
+}
+
+// Report returns the standing of every doctor's credentials today
+func (s *CredentialService) Report(ctx context.Context) (*models.ComplianceReport, error) {
+	doctors, err := s.repository.Doctors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now().In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+	report := &models.ComplianceReport{Date: today, WarningDays: s.config.WarningDays, Doctors: []models.DoctorCompliance{}}
+	for _, doctor := range doctors {
+		line := models.DoctorCompliance{
+			DoctorID:      doctor.ID,
+			Name:          doctor.FirstName + " " + doctor.LastName,
+			LicenceNumber: doctor.LicenceNumber,
+			Credentials:   make([]models.DoctorCredential, 0, len(models.Credentials)),
+			Schedulable:   true,
+		}
+		var expiring, missing bool
+		for _, credential := range models.Credentials {
+			standing := s.standing(credential, doctor.CredentialExpiry(credential), today)
+			switch standing.Status {
+			case models.CredentialExpired:
+				line.Schedulable = false
+			case models.CredentialExpiring:
+				expiring = true
+			case models.CredentialMissing:
+				missing = true
+			}
+			line.Credentials = append(line.Credentials, standing)
+		}
+		switch {
+		case !line.Schedulable:
+			report.Expired++
+		case expiring:
+			report.Expiring++
+		}
+		if missing {
+			report.Missing++
+		}
+		report.Doctors = append(report.Doctors, line)
+	}
+	return report, nil
+}
+
+// standing rates a credential expiring on expiry, on the day today
+func (s *CredentialService) standing(credential string, expiry *time.Time, today string) models.DoctorCredential {
+	standing := models.DoctorCredential{Credential: credential, ExpiresOn: expiry, Status: models.CredentialMissing}
+	if expiry == nil {
+		return standing
+	}
+	day, _ := time.Parse(models.ClosureDateLayout, today)
+	last, _ := time.Parse(models.ClosureDateLayout, models.CredentialDay(*expiry))
+	daysLeft := int(last.Sub(day).Hours() / 24)
+	standing.DaysLeft = &daysLeft
+	switch {
+	case daysLeft < 0:
+		standing.Status = models.CredentialExpired
+	case daysLeft <= s.config.WarningDays:
+		standing.Status = models.CredentialExpiring
+	default:
+		standing.Status = models.CredentialValid
+	}
+	return standing
+}
+
+// CheckSchedulable refuses to schedule a doctor at t when any of their credentials has expired by
+// then. Unknown doctors are left for the booking itself to refuse.
+func (s *CredentialService) CheckSchedulable(ctx context.Context, doctorID string, t time.Time) error {
+	doctor, err := s.doctorRepo.GetByID(ctx, doctorID)
+	if err != nil || doctor == nil {
+		return err
+	}
+	expired := doctor.ExpiredCredentials(t)
+	if len(expired) == 0 {
+		return nil
+	}
+	names := make([]string, len(expired))
+	for i, credential := range expired {
+		names[i] = credentialNames[credential]
+	}
+	return fmt.Errorf("%w: the %s of doctor %s expired before %s", ErrCredentialsExpired, strings.Join(names, " and "), doctorID,
+		t.In(models.ClinicLocation()).Format(models.ClosureDateLayout))
+}
+
+// remind tells the doctors, and chat, about credentials that entered the warning period or expired
+// since they were last told
+func (s *CredentialService) remind(ctx context.Context) error {
+	doctors, err := s.repository.Doctors(ctx)
+	if err != nil {
+		return err
+	}
+	today := time.Now().In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+	for _, doctor := range doctors {
+		for _, credential := range models.Credentials {
+			standing := s.standing(credential, doctor.CredentialExpiry(credential), today)
+			if standing.Status != models.CredentialExpiring && standing.Status != models.CredentialExpired {
+				continue
+			}
+			recorded, err := s.repository.RecordReminder(ctx, &models.CredentialReminder{
+				DoctorID:   doctor.ID,
+				Credential: credential,
+				ExpiresOn:  *standing.ExpiresOn,
+				Status:     standing.Status,
+				SentAt:     time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			if recorded {
+				s.sendReminder(ctx, doctor, standing)
+			}
+		}
+	}
+	return nil
+}
+
+// sendReminder posts a credential's expiry to chat and emails the doctor when they have an account
+func (s *CredentialService) sendReminder(ctx context.Context, doctor models.Doctor, credential models.DoctorCredential) {
+	name := credentialNames[credential.Credential]
+	day := models.CredentialDay(*credential.ExpiresOn)
+	subject := fmt.Sprintf("Your %s expires on %s", name, day)
+	body := fmt.Sprintf("Your %s expires on %s. Please renew it and send the clinic the new expiry date; "+
+		"once it has expired you can no longer be booked for appointments.", name, day)
+	post := fmt.Sprintf("The %s of doctor %s (%s %s) expires on %s.", name, doctor.ID, doctor.FirstName, doctor.LastName, day)
+	if credential.Status == models.CredentialExpired {
+		subject = fmt.Sprintf("Your %s expired on %s", name, day)
+		body = fmt.Sprintf("Your %s expired on %s, so you can no longer be booked for appointments. "+
+			"Please renew it and send the clinic the new expiry date.", name, day)
+		post = fmt.Sprintf("The %s of doctor %s (%s %s) expired on %s; they can no longer be booked.", name, doctor.ID, doctor.FirstName, doctor.LastName, day)
+	}
+	notifications.Publish(notifications.EventCredentialExpiring, "Doctor credential "+credential.Status, post)
+
+	if doctor.UserID == nil {
+		return
+	}
+	email, err := s.repository.UserEmail(ctx, *doctor.UserID)
+	if err != nil || email == "" {
+		log.Printf("Failed to find the email of doctor %s: %v", doctor.ID, err)
+		return
+	}
+	body = fmt.Sprintf("Dear Dr %s,\n\n%s", doctor.LastName, body)
+	if err := s.notifier.Send(ctx, notifications.Notification{Recipients: []string{email}, Subject: subject, Body: body}); err != nil {
+		log.Printf("Failed to remind doctor %s of their %s: %v", doctor.ID, name, err)
+	}
+}
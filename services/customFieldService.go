@@ -0,0 +1,260 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// customFieldKeyPattern matches the keys custom field values are stored under, e.g. referral_source
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+var (
+	ErrCustomFieldNotFound      = errors.New("custom field not found")
+	ErrCustomFieldExists        = errors.New("custom field already exists")
+	ErrInvalidCustomField       = errors.New("invalid custom field")
+	ErrInvalidCustomFieldValues = errors.New("invalid custom field values")
+)
+
+// CustomFieldService keeps the custom fields admins define for patients and appointments, and
+// checks the values recorded for them against their type and validation rules
+type CustomFieldService struct {
+	repository *repositories.CustomFieldRepository
+}
+
+func NewCustomFieldService(repository *repositories.CustomFieldRepository) *CustomFieldService {
+	return &CustomFieldService{repository: repository}
+}
+
+func (s *CustomFieldService) Create(ctx context.Context, field *models.CustomField) error {
+	field.Key = strings.TrimSpace(field.Key)
+	if !models.IsValidCustomFieldEntity(field.Entity) {
+		return fmt.Errorf("%w: entity must be %s or %s", ErrInvalidCustomField, models.CustomFieldEntityPatient, models.CustomFieldEntityAppointment)
+	}
+	if !customFieldKeyPattern.MatchString(field.Key) {
+		return fmt.Errorf("%w: key must be lowercase letters, digits and underscores, starting with a letter", ErrInvalidCustomField)
+	}
+	if !models.IsValidCustomFieldType(field.Type) {
+		return fmt.Errorf("%w: type must be text, number, boolean, date or select", ErrInvalidCustomField)
+	}
+	if err := s.validate(field); err != nil {
+		return err
+	}
+	existing, err := s.repository.GetByKey(ctx, field.Entity, field.Key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %s already has a field %s", ErrCustomFieldExists, field.Entity, field.Key)
+	}
+	return s.repository.Create(ctx, field)
+}
+
+func (s *CustomFieldService) Get(ctx context.Context, id uint) (*models.CustomField, error) {
+	field, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if field == nil {
+		return nil, ErrCustomFieldNotFound
+	}
+	return field, nil
+}
+
+// List returns the fields of entity, or of every entity when it is empty
+func (s *CustomFieldService) List(ctx context.Context, entity string) ([]models.CustomField, error) {
+	if entity != "" && !models.IsValidCustomFieldEntity(entity) {
+		return nil, fmt.Errorf("%w: entity must be %s or %s", ErrInvalidCustomField, models.CustomFieldEntityPatient, models.CustomFieldEntityAppointment)
+	}
+	fields, err := s.repository.List(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = []models.CustomField{}
+	}
+	return fields, nil
+}
+
+// Update changes how a field is labelled and validated. Its entity, key and type cannot change, as
+// the values already recorded depend on them; values recorded before the rules changed are kept.
+func (s *CustomFieldService) Update(ctx context.Context, field *models.CustomField) error {
+	current, err := s.Get(ctx, field.ID)
+	if err != nil {
+		return err
+	}
+	field.Entity, field.Key, field.Type = current.Entity, current.Key, current.Type
+	if err := s.validate(field); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, field)
+}
+
+// Delete removes a field along with every value recorded for it
+func (s *CustomFieldService) Delete(ctx context.Context, id uint) error {
+	field, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, field)
+}
+
+// validate checks the label and validation rules of a field, dropping the rules its type does not use
+func (s *CustomFieldService) validate(field *models.CustomField) error {
+	field.Label = strings.TrimSpace(field.Label)
+	if field.Label == "" {
+		return fmt.Errorf("%w: label is required", ErrInvalidCustomField)
+	}
+	if field.Type != models.CustomFieldText {
+		field.Pattern = ""
+	}
+	if field.Type != models.CustomFieldNumber {
+		field.Min, field.Max = nil, nil
+	}
+	if field.Type != models.CustomFieldSelect {
+		field.Options = models.CustomFieldOptions{}
+	}
+
+	switch field.Type {
+	case models.CustomFieldText:
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("%w: pattern: %v", ErrInvalidCustomField, err)
+			}
+		}
+	case models.CustomFieldNumber:
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return fmt.Errorf("%w: min must not be above max", ErrInvalidCustomField)
+		}
+	case models.CustomFieldSelect:
+		seen := map[string]bool{}
+		options := models.CustomFieldOptions{}
+		for _, option := range field.Options {
+			option = strings.TrimSpace(option)
+			if option == "" || seen[option] {
+				return fmt.Errorf("%w: options must be distinct and not empty", ErrInvalidCustomField)
+			}
+			seen[option] = true
+			options = append(options, option)
+		}
+		if len(options) == 0 {
+			return fmt.Errorf("%w: a select field needs options", ErrInvalidCustomField)
+		}
+		field.Options = options
+	}
+	return nil
+}
+
+// CheckValues checks the custom field values of a patient or appointment against the fields of
+// entity, normalizing them in place: null values are dropped, text is trimmed and dates are kept
+// as YYYY-MM-DD. Every active required field must have a value.
+func (s *CustomFieldService) CheckValues(ctx context.Context, entity string, values models.CustomFieldValues) error {
+	fields, err := s.repository.List(ctx, entity)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]models.CustomField, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	for key, value := range values {
+		field, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown field %s", ErrInvalidCustomFieldValues, key)
+		}
+		if value == nil {
+			delete(values, key)
+			continue
+		}
+		normalized, err := checkCustomFieldValue(field, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s %v", ErrInvalidCustomFieldValues, key, err)
+		}
+		if normalized == "" {
+			delete(values, key)
+			continue
+		}
+		values[key] = normalized
+	}
+
+	for _, field := range fields {
+		if _, ok := values[field.Key]; field.Active && field.Required && !ok {
+			return fmt.Errorf("%w: %s is required", ErrInvalidCustomFieldValues, field.Key)
+		}
+	}
+	return nil
+}
+
+// checkCustomFieldValue returns value as it is stored for field, or an error saying why it is not valid
+func checkCustomFieldValue(field models.CustomField, value interface{}) (interface{}, error) {
+	switch field.Type {
+	case models.CustomFieldText:
+		text, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be text")
+		}
+		text = strings.TrimSpace(text)
+		if text != "" && field.Pattern != "" {
+			pattern, err := regexp.Compile(field.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			if !pattern.MatchString(text) {
+				return nil, fmt.Errorf("must match %s", field.Pattern)
+			}
+		}
+		return text, nil
+	case models.CustomFieldNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		if field.Min != nil && number < *field.Min {
+			return nil, fmt.Errorf("must be at least %g", *field.Min)
+		}
+		if field.Max != nil && number > *field.Max {
+			return nil, fmt.Errorf("must be at most %g", *field.Max)
+		}
+		return number, nil
+	case models.CustomFieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return nil, errors.New("must be true or false")
+		}
+		return value, nil
+	case models.CustomFieldDate:
+		text, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be a date (YYYY-MM-DD)")
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return text, nil
+		}
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, errors.New("must be a date (YYYY-MM-DD)")
+		}
+		return text, nil
+	case models.CustomFieldSelect:
+		text, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be one of the options")
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return text, nil
+		}
+		for _, option := range field.Options {
+			if option == text {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(field.Options, ", "))
+	}
+	return nil, fmt.Errorf("has unknown type %s", field.Type)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DailySummaryService reports a clinic day's key figures: patients seen, no-shows, new patients,
+// money collected by method and the tasks still open. The day's report is emailed to management at
+// the configured hour when recipients are set.
+type DailySummaryService struct {
+	repository *repositories.DailySummaryRepository
+	notifier   notifications.Notifier
+	config     config.DailySummaryConfig
+	clock      clock.Clock
+}
+
+// NewDailySummaryService starts emailing the summary in the background when recipients are configured.
+func NewDailySummaryService(repository *repositories.DailySummaryRepository, notifier notifications.Notifier, cfg config.DailySummaryConfig, clock clock.Clock) *DailySummaryService {
+	s := &DailySummaryService{repository: repository, notifier: notifier, config: cfg, clock: clock}
+	if len(cfg.Recipients) > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *DailySummaryService) run() {
+	for {
+		now := s.clinicNow()
+		time.Sleep(nextRunAt(now, s.config.SendHour).Sub(now))
+		if database.IsLeader() {
+			s.send(context.Background(), s.clinicNow())
+		}
+	}
+}
+
+// Today returns the current time on the clinic day the summary defaults to
+func (s *DailySummaryService) Today() time.Time {
+	return s.clinicNow()
+}
+
+// Summary returns the figures of the clinic day day falls on, as they stand now
+func (s *DailySummaryService) Summary(ctx context.Context, day time.Time) (*models.DailySummary, error) {
+	day = startOfDay(day)
+	now := s.clinicNow()
+	if day.After(now) {
+		return nil, fmt.Errorf("%w: the day has not started yet", ErrInvalidReportPeriod)
+	}
+	from, to := models.ClinicDay(day)
+	summary := &models.DailySummary{Date: day.Format("2006-01-02")}
+	if err := s.repository.Figures(ctx, from, to, now, summary); err != nil {
+		return nil, err
+	}
+	for _, total := range summary.Collected {
+		summary.TotalCollected += total.Amount
+	}
+	return summary, nil
+}
+
+// send emails the summary of the day day falls on, recording it first so replicas never send it
+// twice, and clearing the record again if it could not be sent
+func (s *DailySummaryService) send(ctx context.Context, day time.Time) {
+	date := startOfDay(day).Format("2006-01-02")
+	marked, err := s.repository.MarkSent(ctx, date, s.clock.Now())
+	if err != nil {
+		log.Printf("Failed to record daily summary of %s: %v", date, err)
+		return
+	}
+	if !marked {
+		return
+	}
+
+	summary, err := s.Summary(ctx, day)
+	if err == nil {
+		err = s.notifier.Send(ctx, notifications.Notification{
+			Recipients: s.config.Recipients,
+			Subject:    "Daily summary for " + models.FormatDayDate(startOfDay(day)),
+			Body:       dailySummaryBody(summary),
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to send daily summary of %s: %v", date, err)
+		if err := s.repository.ClearSent(ctx, date); err != nil {
+			log.Printf("Failed to clear daily summary of %s: %v", date, err)
+		}
+	}
+}
+
+// dailySummaryBody lays the summary out as the text of an email
+func dailySummaryBody(summary *models.DailySummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary of %s\n\n", documentDate(summary.Date))
+	fmt.Fprintf(&b, "Appointments: %d (%d cancelled)\n", summary.Appointments, summary.Cancelled)
+	fmt.Fprintf(&b, "Patients seen: %d\n", summary.PatientsSeen)
+	fmt.Fprintf(&b, "No-shows: %d\n", summary.NoShows)
+	fmt.Fprintf(&b, "New patients: %d\n\n", summary.NewPatients)
+	fmt.Fprintf(&b, "Billed: %s\n", models.FormatMoney(summary.Billed))
+	fmt.Fprintf(&b, "Collected: %s\n", models.FormatMoney(summary.TotalCollected))
+	for _, total := range summary.Collected {
+		fmt.Fprintf(&b, "  %s: %s\n", strings.ToUpper(total.Method[:1])+total.Method[1:], models.FormatMoney(total.Amount))
+	}
+	fmt.Fprintf(&b, "\nOpen tasks: %d (%d overdue)\n", summary.OpenTasks, summary.OverdueTasks)
+	return b.String()
+}
+
+// clinicNow returns the service's clock read in the clinic's time zone
+func (s *DailySummaryService) clinicNow() time.Time {
+	return s.clock.Now().In(models.ClinicLocation())
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxFailedDeliveriesPage bounds the dead-letter queue page an admin lists at once
+const maxFailedDeliveriesPage = 200
+
+var (
+	// ErrFailedDeliveryNotFound is returned for deliveries that are not queued
+	ErrFailedDeliveryNotFound = errors.New("failed delivery not found")
+	// ErrFailedDeliveryExpired is returned for replays of deliveries that stopped being of use, such
+	// as reminders of appointments that have started
+	ErrFailedDeliveryExpired = errors.New("the delivery stopped being of use")
+	// ErrInvalidFailedDeliveryQuery is returned for a bad dead-letter queue page
+	ErrInvalidFailedDeliveryQuery = errors.New("invalid failed delivery query")
+)
+
+// errDeliveryExpired is logged for deliveries dropped because they stopped being of use before they
+// went through
+var errDeliveryExpired = errors.New("the message stopped being of use before it could be delivered")
+
+// DeliveryRetryService tries the emails, text messages and chat webhook posts that failed to be
+// delivered again in the background, waiting twice as long after each failure. Those still failing
+// after the last attempt are kept in a dead-letter queue admins inspect and replay. Deliveries go
+// out through the transport that failed them, past the patient's preferences and quiet hours,
+// which were checked when they were first sent.
+type DeliveryRetryService struct {
+	repository     *repositories.FailedDeliveryRepository
+	communications *CommunicationService
+	config         config.DeliveryRetryConfig
+	clock          clock.Clock
+	mu             sync.RWMutex
+	transports     map[string]notifications.Notifier // Failed deliveries are tried again through, by transport
+}
+
+// NewDeliveryRetryService starts trying failed deliveries again in the background when retries are
+// enabled
+func NewDeliveryRetryService(repository *repositories.FailedDeliveryRepository, communications *CommunicationService, cfg config.DeliveryRetryConfig, clock clock.Clock) *DeliveryRetryService {
+	s := &DeliveryRetryService{
+		repository:     repository,
+		communications: communications,
+		config:         cfg,
+		clock:          clock,
+		transports:     map[string]notifications.Notifier{},
+	}
+	if cfg.Enabled {
+		go s.run()
+	}
+	return s
+}
+
+// Notifier returns next, a transport, queuing the notifications it fails to deliver to be tried
+// again, or next itself when retries are disabled
+func (s *DeliveryRetryService) Notifier(transport string, next notifications.Notifier) notifications.Notifier {
+	if !s.config.Enabled {
+		return next
+	}
+	s.mu.Lock()
+	s.transports[transport] = next
+	s.mu.Unlock()
+	return notifications.RetryingNotifier{Next: next, Transport: transport, Queue: s}
+}
+
+// Retry queues a notification the transport failed to deliver at its first attempt
+func (s *DeliveryRetryService) Retry(ctx context.Context, transport string, notification notifications.Notification, cause error) error {
+	delivery := &models.FailedDelivery{
+		Transport:  transport,
+		PatientID:  notification.PatientID,
+		Purpose:    notification.Purpose,
+		Recipients: notification.Recipients,
+		Subject:    notification.Subject,
+		Body:       notification.Body,
+		MessageID:  notification.MessageID,
+		Attempts:   1,
+	}
+	for _, attachment := range notification.Attachments {
+		delivery.Attachments = append(delivery.Attachments, models.DeferredAttachment(attachment))
+	}
+	if !notification.Expires.IsZero() {
+		delivery.Expires = &notification.Expires
+	}
+	s.schedule(delivery, cause, s.clock.Now())
+	return s.repository.Create(ctx, delivery)
+}
+
+// ListFailed returns a page of the dead-letter queue, of the transport when set, and how many
+// deliveries it holds
+func (s *DeliveryRetryService) ListFailed(ctx context.Context, transport string, limit, offset int) ([]models.FailedDelivery, int64, error) {
+	if limit < 1 || limit > maxFailedDeliveriesPage {
+		return nil, 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFailedDeliveryQuery, maxFailedDeliveriesPage)
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("%w: offset must not be negative", ErrInvalidFailedDeliveryQuery)
+	}
+	deliveries, total, err := s.repository.ListDead(ctx, transport, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if deliveries == nil {
+		deliveries = []models.FailedDelivery{}
+	}
+	return deliveries, total, nil
+}
+
+// Replay queues a delivery to be tried again at once, with every attempt again
+func (s *DeliveryRetryService) Replay(ctx context.Context, id uint) (*models.FailedDelivery, error) {
+	delivery, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrFailedDeliveryNotFound
+	}
+	now := s.clock.Now()
+	if delivery.Expires != nil && !now.Before(*delivery.Expires) {
+		return nil, ErrFailedDeliveryExpired
+	}
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt = models.DeliveryRetrying, 0, now
+	if err := s.repository.Reschedule(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ReplayAll queues every delivery of the dead-letter queue, of the transport when set, that is
+// still of use to be tried again at once, and returns how many there were
+func (s *DeliveryRetryService) ReplayAll(ctx context.Context, transport string) (int64, error) {
+	return s.repository.Replay(ctx, transport, s.clock.Now())
+}
+
+func (s *DeliveryRetryService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.deliver(context.Background())
+	}
+}
+
+// deliver tries the failed deliveries that are due again. A delivery that goes through, stopped
+// being of use or may no longer be sent is deleted; one that fails again waits for its next attempt,
+// or goes to the dead-letter queue after the last one.
+func (s *DeliveryRetryService) deliver(ctx context.Context) {
+	now := s.clock.Now()
+	deliveries, err := s.repository.ClaimDue(ctx, now, s.config.ClaimLease, s.config.BatchSize)
+	if err != nil {
+		log.Printf("Failed to find failed deliveries to try again: %v", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		var sendErr error
+		s.mu.RLock()
+		transport := s.transports[delivery.Transport]
+		s.mu.RUnlock()
+		switch {
+		case delivery.Expires != nil && !now.Before(*delivery.Expires):
+			sendErr = errDeliveryExpired
+		case transport == nil:
+			sendErr = fmt.Errorf("no transport %q", delivery.Transport)
+		default:
+			sendErr = transport.Send(ctx, failedDeliveryNotification(delivery))
+		}
+
+		if notifications.Retryable(sendErr) && !errors.Is(sendErr, errDeliveryExpired) {
+			delivery.Attempts++
+			s.schedule(&delivery, sendErr, now)
+			if err := s.repository.Reschedule(ctx, &delivery); err != nil {
+				log.Printf("Failed to reschedule %s delivery %d: %v", delivery.Transport, delivery.ID, err)
+				continue
+			}
+			if delivery.Status == models.DeliveryRetrying {
+				continue
+			}
+			log.Printf("Failed to deliver %s delivery %d after %d attempts, giving up: %v", delivery.Transport, delivery.ID, delivery.Attempts, sendErr)
+		} else if err := s.repository.Delete(ctx, delivery.ID); err != nil {
+			log.Printf("Failed to delete %s delivery %d: %v", delivery.Transport, delivery.ID, err)
+		}
+		s.settle(ctx, delivery, sendErr)
+	}
+}
+
+// schedule records the failure of a delivery's last attempt and when it is tried next, with the
+// wait doubling after each attempt, or moves it to the dead-letter queue after the last one
+func (s *DeliveryRetryService) schedule(delivery *models.FailedDelivery, cause error, now time.Time) {
+	delivery.LastError = cause.Error()
+	if delivery.Attempts >= s.config.MaxAttempts {
+		delivery.Status, delivery.NextAttemptAt = models.DeliveryDead, now
+		return
+	}
+	delay := s.config.BaseDelay
+	for i := 1; i < delivery.Attempts && delay < s.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	delivery.Status, delivery.NextAttemptAt = models.DeliveryRetrying, now.Add(min(delay, s.config.MaxDelay))
+}
+
+// settle records in the patient's communication log how a retried message went in the end
+func (s *DeliveryRetryService) settle(ctx context.Context, delivery models.FailedDelivery, sendErr error) {
+	if delivery.MessageID == "" {
+		return
+	}
+	if err := s.communications.SettleDeferred(ctx, delivery.MessageID, sendErr); err != nil {
+		log.Printf("Failed to log retried message to patient %s: %v", delivery.PatientID, err)
+	}
+}
+
+// failedDeliveryNotification rebuilds the notification a failed delivery was queued from
+func failedDeliveryNotification(delivery models.FailedDelivery) notifications.Notification {
+	notification := notifications.Notification{
+		Recipients: delivery.Recipients,
+		Subject:    delivery.Subject,
+		Body:       delivery.Body,
+		PatientID:  delivery.PatientID,
+		Purpose:    delivery.Purpose,
+		MessageID:  delivery.MessageID,
+	}
+	for _, attachment := range delivery.Attachments {
+		notification.Attachments = append(notification.Attachments, notifications.Attachment(attachment))
+	}
+	if delivery.Expires != nil {
+		notification.Expires = *delivery.Expires
+	}
+	return notification
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Slow queries listed by default and at most, and the PINGs timed to sample Redis latency.
+const (
+	defaultSlowQueryLimit = 20
+	maxSlowQueryLimit     = 200
+	redisLatencySamples   = 5
+)
+
+// ErrInvalidDiagnostics is returned for a bad slow query limit
+var ErrInvalidDiagnostics = errors.New("invalid diagnostics request")
+
+// processStarted is when the server process started, to report its uptime
+var processStarted = time.Now()
+
+// DiagnosticsService collects what is needed to triage a production issue without direct access
+// to the database: slow statements, lock waits, connection pools, Redis latency and the server's
+// own runtime statistics, and how the database schema drifted from the expected one
+type DiagnosticsService struct {
+	repository *repositories.DiagnosticsRepository
+	schema     *SchemaService
+}
+
+func NewDiagnosticsService(repository *repositories.DiagnosticsRepository, schema *SchemaService) *DiagnosticsService {
+	return &DiagnosticsService{repository: repository, schema: schema}
+}
+
+// Collect gathers every section. Sections that fail are reported in the result's errors so the
+// others are still returned.
+func (s *DiagnosticsService) Collect(ctx context.Context) *models.Diagnostics {
+	diagnostics := &models.Diagnostics{CollectedAt: time.Now(), Runtime: s.Runtime(), Errors: map[string]string{}}
+	var err error
+	if diagnostics.SlowQueries, err = s.SlowQueries(ctx, 0); err != nil {
+		diagnostics.Errors["slow_queries"] = err.Error()
+	}
+	if diagnostics.LockWaits, err = s.LockWaits(ctx); err != nil {
+		diagnostics.Errors["lock_waits"] = err.Error()
+	}
+	if diagnostics.Pool, err = s.repository.PoolStats(); err != nil {
+		diagnostics.Errors["database_pool"] = err.Error()
+	}
+	if diagnostics.Redis, err = s.Redis(ctx); err != nil {
+		diagnostics.Errors["redis"] = err.Error()
+	}
+	if diagnostics.Schema, err = s.Schema(ctx); err != nil {
+		diagnostics.Errors["schema"] = err.Error()
+	}
+	return diagnostics
+}
+
+// SlowQueries returns the limit statements slowest on average, 20 when limit is 0
+func (s *DiagnosticsService) SlowQueries(ctx context.Context, limit int) ([]models.SlowQuery, error) {
+	if limit == 0 {
+		limit = defaultSlowQueryLimit
+	}
+	if limit < 0 || limit > maxSlowQueryLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidDiagnostics, maxSlowQueryLimit)
+	}
+	queries, err := s.repository.SlowQueries(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if queries == nil {
+		queries = []models.SlowQuery{}
+	}
+	return queries, nil
+}
+
+// LockWaits returns the sessions currently waiting on a lock and who holds it
+func (s *DiagnosticsService) LockWaits(ctx context.Context) ([]models.LockWait, error) {
+	waits, err := s.repository.LockWaits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if waits == nil {
+		waits = []models.LockWait{}
+	}
+	return waits, nil
+}
+
+// Redis samples the latency of a few PINGs to Redis
+func (s *DiagnosticsService) Redis(ctx context.Context) (*models.RedisLatency, error) {
+	return s.repository.RedisLatency(ctx, redisLatencySamples)
+}
+
+// Runtime returns the server process's goroutine count, memory and garbage collection statistics
+// Schema compares the live database schema with the one the server migrates to
+func (s *DiagnosticsService) Schema(ctx context.Context) (*models.SchemaDrift, error) {
+	return s.schema.Check(ctx)
+}
+
+func (s *DiagnosticsService) Runtime() models.RuntimeStats {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	stats := models.RuntimeStats{
+		StartedAt:      processStarted,
+		UptimeSeconds:  time.Since(processStarted).Seconds(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: memory.HeapAlloc,
+		HeapInuseBytes: memory.HeapInuse,
+		HeapObjects:    memory.HeapObjects,
+		SysBytes:       memory.Sys,
+		NumGC:          memory.NumGC,
+		GCPauseTotalMs: float64(memory.PauseTotalNs) / float64(time.Millisecond),
+	}
+	if memory.LastGC > 0 {
+		lastGC := time.Unix(0, int64(memory.LastGC))
+		stats.LastGCAt = &lastGC
+	}
+	return stats
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrDoctorNotLinked is returned when the signed-in user has no doctor profile
+	ErrDoctorNotLinked = errors.New("no doctor is linked to this user")
+	// ErrDoctorPatientNotFound is returned for patients that are not the doctor's patients
+	ErrDoctorPatientNotFound = errors.New("patient not found")
+)
+
+type DoctorAppService struct {
+	repository      *repositories.DoctorAppRepository
+	patientRepo     *repositories.PatientRepository
+	appointmentRepo *repositories.AppointmentRepository
+	billingRepo     *repositories.BillingRepository
+	settings        *SettingService
+	breakGlass      *BreakGlassService
+}
+
+func NewDoctorAppService(repository *repositories.DoctorAppRepository, patientRepo *repositories.PatientRepository, appointmentRepo *repositories.AppointmentRepository, billingRepo *repositories.BillingRepository, settings *SettingService, breakGlass *BreakGlassService) *DoctorAppService {
+	return &DoctorAppService{repository: repository, patientRepo: patientRepo, appointmentRepo: appointmentRepo, billingRepo: billingRepo, settings: settings, breakGlass: breakGlass}
+}
+
+func (s *DoctorAppService) doctorID(ctx context.Context, userID int64) (string, error) {
+	doctor, err := s.repository.GetDoctorByUserID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if doctor == nil {
+		return "", ErrDoctorNotLinked
+	}
+	return doctor.ID, nil
+}
+
+func (s *DoctorAppService) Appointments(ctx context.Context, userID int64, day time.Time) ([]models.DoctorAppointment, error) {
+	doctorID, err := s.doctorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	appointments, err := s.repository.GetAppointmentsOn(ctx, doctorID, day)
+	if err != nil {
+		return nil, err
+	}
+	if appointments == nil {
+		appointments = []models.DoctorAppointment{}
+	}
+	for i := range appointments {
+		appointments[i].Color = s.settings.AppointmentColor(appointments[i].Type)
+	}
+	return appointments, nil
+}
+
+// PatientSummary returns the summary of one of the doctor's patients, or of any patient the doctor
+// opened emergency access to
+func (s *DoctorAppService) PatientSummary(ctx context.Context, userID int64, patientID string) (*models.DoctorPatientSummary, error) {
+	doctorID, err := s.doctorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.repository.IsDoctorPatient(ctx, doctorID, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		if allowed, err = s.breakGlass.Granted(ctx, userID, patientID); err != nil {
+			return nil, err
+		}
+	}
+	if !allowed {
+		return nil, ErrDoctorPatientNotFound
+	}
+	summary, err := s.repository.GetPatientSummary(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil {
+		return nil, ErrDoctorPatientNotFound
+	}
+	return summary, nil
+}
+
+// PatientsPage returns a page of the doctor's patients newest first, after the ?after= cursor of the previous page
+func (s *DoctorAppService) PatientsPage(ctx context.Context, userID int64, after string, limit int) (*models.ListPage[models.Patient], error) {
+	doctorID, err := s.doctorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cursor, limit, err := pageRequest(after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.patientRepo.ListByDoctorAfter(ctx, doctorID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Patient) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: row.ID}
+	}), nil
+}
+
+// AppointmentsPage returns a page of the doctor's appointments newest first, after the ?after= cursor of the previous page
+func (s *DoctorAppService) AppointmentsPage(ctx context.Context, userID int64, after string, limit int) (*models.ListPage[models.Appointment], error) {
+	doctorID, err := s.doctorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cursor, limit, err := pageRequest(after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.appointmentRepo.ListByDoctorAfter(ctx, doctorID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Appointment) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: strconv.FormatUint(uint64(row.ID), 10)}
+	}), nil
+}
+
+// BillingsPage returns a page of the doctor's billings newest first, after the ?after= cursor of the previous page
+func (s *DoctorAppService) BillingsPage(ctx context.Context, userID int64, after string, limit int) (*models.ListPage[models.Billing], error) {
+	doctorID, err := s.doctorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cursor, limit, err := pageRequest(after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.billingRepo.ListByDoctorAfter(ctx, doctorID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Billing) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: row.BillingID}
+	}), nil
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/utils"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDoctorImportRows bounds the doctors read from one file or directory listing
+const maxDoctorImportRows = 5000
+
+var (
+	// ErrInvalidDoctorImport is returned for a CSV file or directory listing that cannot be read
+	ErrInvalidDoctorImport = errors.New("invalid doctor import")
+	// ErrStaffDirectoryDisabled is returned when syncing without a staff directory configured
+	ErrStaffDirectoryDisabled = errors.New("the staff directory is not configured")
+)
+
+// doctorImportColumns maps the accepted CSV headers to the fields of a directory row
+var doctorImportColumns = map[string]string{
+	"first_name":     "first_name",
+	"last_name":      "last_name",
+	"licence_number": "licence_number",
+	"license_number": "licence_number",
+	"licence":        "licence_number",
+	"license":        "licence_number",
+	"specialty":      "specialty",
+	"email":          "email",
+	"username":       "username",
+}
+
+// DoctorImportService brings the doctors in line with the clinic's staff directory, from a CSV export
+// or the HR system's API. Rows are matched to doctors on licence number, then name; unmatched rows
+// create doctors and matched ones update them, and a doctor with an email but no user account gets
+// one with the Doctor role. New accounts have no usable password: the doctor sets one with a
+// password reset. Every row is saved on its own, so one bad row is reported without stopping the rest.
+type DoctorImportService struct {
+	repository *repositories.DoctorRepository
+	config     config.StaffDirectoryConfig
+	client     *http.Client
+	mu         sync.Mutex
+}
+
+// NewDoctorImportService starts syncing from the staff directory every config.Interval when one is configured
+func NewDoctorImportService(repository *repositories.DoctorRepository, cfg config.StaffDirectoryConfig) *DoctorImportService {
+	s := &DoctorImportService{repository: repository, config: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+	if cfg.URL != "" && cfg.Interval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *DoctorImportService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		report, err := s.Sync(context.Background(), false)
+		if err != nil {
+			log.Printf("Failed to sync doctors from the staff directory: %v", err)
+			continue
+		}
+		if report.Created+report.Updated+report.Failed > 0 {
+			log.Printf("Synced doctors from the staff directory: %d created, %d updated, %d failed",
+				report.Created, report.Updated, report.Failed)
+		}
+	}
+}
+
+// ImportCSV imports the doctors of a CSV file whose header names its columns: first_name, last_name,
+// licence_number, specialty, email and username, in any order. With dryRun nothing is saved and the
+// report tells what the import would do.
+func (s *DoctorImportService) ImportCSV(ctx context.Context, file io.Reader, dryRun bool) (*models.DoctorImportReport, error) {
+	rows, err := readDoctorCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, "csv", rows, dryRun)
+}
+
+// Sync imports the doctors listed by the staff directory, or with dryRun reports what it would do
+func (s *DoctorImportService) Sync(ctx context.Context, dryRun bool) (*models.DoctorImportReport, error) {
+	if s.config.URL == "" {
+		return nil, ErrStaffDirectoryDisabled
+	}
+	rows, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, "directory", rows, dryRun)
+}
+
+// apply matches every row to a doctor and saves the changes, one row at a time
+func (s *DoctorImportService) apply(ctx context.Context, source string, rows []models.DirectoryDoctor, dryRun bool) (*models.DoctorImportReport, error) {
+	if len(rows) > maxDoctorImportRows {
+		return nil, fmt.Errorf("%w: at most %d doctors can be imported at once", ErrInvalidDoctorImport, maxDoctorImportRows)
+	}
+	if !dryRun {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	report := &models.DoctorImportReport{Source: source, DryRun: dryRun, Changes: []models.DoctorImportChange{}}
+	licences := map[string]int{}
+	for i, row := range rows {
+		change, err := s.applyRow(ctx, i+1, row, licences, dryRun)
+		if err != nil {
+			// The rows left would fail the same way
+			return nil, err
+		}
+		switch change.Action {
+		case models.DoctorImportCreated:
+			report.Created++
+		case models.DoctorImportUpdated:
+			report.Updated++
+		case models.DoctorImportUnchanged:
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+		if change.Account != "" {
+			report.Accounts++
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return report, nil
+}
+
+// applyRow imports one directory row. Problems with the row, saving it included, are reported in
+// the change; only failures to look the row's doctor and account up are returned.
+func (s *DoctorImportService) applyRow(ctx context.Context, number int, row models.DirectoryDoctor, licences map[string]int, dryRun bool) (models.DoctorImportChange, error) {
+	row = trimDirectoryDoctor(row)
+	change := models.DoctorImportChange{Row: number, Name: strings.TrimSpace(row.FirstName + " " + row.LastName), LicenceNumber: row.LicenceNumber}
+	fail := func(format string, args ...any) (models.DoctorImportChange, error) {
+		change.Action, change.Error = models.DoctorImportFailed, fmt.Sprintf(format, args...)
+		return change, nil
+	}
+
+	switch {
+	case row.FirstName == "" || row.LastName == "":
+		return fail("first_name and last_name are required")
+	case row.Specialty != "" && !models.IsValidSpecialty(row.Specialty):
+		return fail("%v %q", ErrInvalidSpecialty, row.Specialty)
+	case len(row.LicenceNumber) > 50:
+		return fail("licence_number must be at most 50 characters")
+	}
+	if row.Email != "" {
+		if _, err := mail.ParseAddress(row.Email); err != nil {
+			return fail("invalid email %q", row.Email)
+		}
+	}
+	if row.LicenceNumber != "" {
+		if first, ok := licences[row.LicenceNumber]; ok {
+			return fail("licence_number is repeated from row %d", first)
+		}
+		licences[row.LicenceNumber] = number
+	}
+
+	doctor, err := s.repository.MatchImported(ctx, row.LicenceNumber, row.FirstName, row.LastName)
+	if err != nil {
+		return change, err
+	}
+	if doctor == nil {
+		doctor = &models.Doctor{Specialty: models.SpecialtyGeneral}
+		change.Action = models.DoctorImportCreated
+	} else {
+		if doctor.LicenceNumber != nil && row.LicenceNumber != "" && *doctor.LicenceNumber != row.LicenceNumber {
+			return fail("doctor %s of the same name has licence %s", doctor.ID, *doctor.LicenceNumber)
+		}
+		change.DoctorID = doctor.ID
+		change.Fields = directoryChanges(doctor, row)
+		change.Action = models.DoctorImportUnchanged
+		if len(change.Fields) > 0 {
+			change.Action = models.DoctorImportUpdated
+		}
+	}
+	doctor.FirstName, doctor.LastName = row.FirstName, row.LastName
+	if row.LicenceNumber != "" {
+		doctor.LicenceNumber = &row.LicenceNumber
+	}
+	if row.Specialty != "" {
+		doctor.Specialty = row.Specialty
+	}
+
+	account, err := s.account(ctx, doctor, row, &change)
+	if err != nil {
+		return change, err
+	}
+	if change.Error != "" {
+		change.Action = models.DoctorImportFailed
+		return change, nil
+	}
+	if account != nil && change.Action == models.DoctorImportUnchanged {
+		change.Action = models.DoctorImportUpdated
+		change.Fields = append(change.Fields, "user_id")
+	}
+	if dryRun || change.Action == models.DoctorImportUnchanged {
+		return change, nil
+	}
+
+	if err := s.repository.Import(ctx, doctor, account); err != nil {
+		return fail("%v", err)
+	}
+	change.DoctorID = doctor.ID
+	if account != nil {
+		change.UserID = &account.ID
+	}
+	return change, nil
+}
+
+// account returns the user account to give a doctor without one: the user with the row's email, or
+// a new account. It returns nil when the doctor has an account or the row has no email, and records
+// why in change when the account cannot be used.
+func (s *DoctorImportService) account(ctx context.Context, doctor *models.Doctor, row models.DirectoryDoctor, change *models.DoctorImportChange) (*models.User, error) {
+	if doctor.UserID != nil || row.Email == "" {
+		return nil, nil
+	}
+	user, linkedDoctor, err := s.repository.ImportAccount(ctx, row.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		if linkedDoctor != "" {
+			change.Error = fmt.Sprintf("%v: %s", repositories.ErrAccountLinked, linkedDoctor)
+			return nil, nil
+		}
+		change.Account, change.UserID = models.DoctorAccountLinked, &user.ID
+		return user, nil
+	}
+
+	username := row.Username
+	if username == "" {
+		username, _, _ = strings.Cut(row.Email, "@")
+	}
+	if len(username) < 3 || len(username) > 50 {
+		change.Error = fmt.Sprintf("username %q must be 3 to 50 characters", username)
+		return nil, nil
+	}
+	password, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+	change.Account = models.DoctorAccountCreated
+	return &models.User{Username: username, Email: row.Email, Password: password}, nil
+}
+
+// directoryChanges lists the fields of doctor a directory row would change
+func directoryChanges(doctor *models.Doctor, row models.DirectoryDoctor) []string {
+	var fields []string
+	if doctor.FirstName != row.FirstName {
+		fields = append(fields, "first_name")
+	}
+	if doctor.LastName != row.LastName {
+		fields = append(fields, "last_name")
+	}
+	if row.LicenceNumber != "" && (doctor.LicenceNumber == nil || *doctor.LicenceNumber != row.LicenceNumber) {
+		fields = append(fields, "licence_number")
+	}
+	if row.Specialty != "" && doctor.Specialty != row.Specialty {
+		fields = append(fields, "specialty")
+	}
+	return fields
+}
+
+// unusablePassword hashes a random password no one knows, for accounts set up by an import
+func unusablePassword() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashed, err := utils.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return hashed, nil
+}
+
+func trimDirectoryDoctor(row models.DirectoryDoctor) models.DirectoryDoctor {
+	return models.DirectoryDoctor{
+		FirstName:     strings.TrimSpace(row.FirstName),
+		LastName:      strings.TrimSpace(row.LastName),
+		LicenceNumber: strings.TrimSpace(row.LicenceNumber),
+		Specialty:     strings.ToLower(strings.TrimSpace(row.Specialty)),
+		Email:         strings.TrimSpace(row.Email),
+		Username:      strings.TrimSpace(row.Username),
+	}
+}
+
+// readDoctorCSV reads the directory rows of a CSV file, whose first line names the columns
+func readDoctorCSV(file io.Reader) ([]models.DirectoryDoctor, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: the file has no header: %v", ErrInvalidDoctorImport, err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[i] = doctorImportColumns[strings.ReplaceAll(name, " ", "_")]
+	}
+	for _, required := range []string{"first_name", "last_name"} {
+		if !slices.Contains(columns, required) {
+			return nil, fmt.Errorf("%w: the header has no %s column", ErrInvalidDoctorImport, required)
+		}
+	}
+
+	rows := []models.DirectoryDoctor{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDoctorImport, err)
+		}
+		if len(rows) == maxDoctorImportRows {
+			return nil, fmt.Errorf("%w: at most %d doctors can be imported at once", ErrInvalidDoctorImport, maxDoctorImportRows)
+		}
+		var row models.DirectoryDoctor
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			switch columns[i] {
+			case "first_name":
+				row.FirstName = value
+			case "last_name":
+				row.LastName = value
+			case "licence_number":
+				row.LicenceNumber = value
+			case "specialty":
+				row.Specialty = value
+			case "email":
+				row.Email = value
+			case "username":
+				row.Username = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// fetch reads the doctors listed by the staff directory, a JSON array of directory rows
+func (s *DoctorImportService) fetch(ctx context.Context) ([]models.DirectoryDoctor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build staff directory request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("staff directory unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("staff directory returned %s", resp.Status)
+	}
+
+	var rows []models.DirectoryDoctor
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to read staff directory: %w", err)
+	}
+	return rows, nil
+}
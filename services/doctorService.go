@@ -4,17 +4,38 @@ import (
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDoctorNotFound   = errors.New("doctor not found")
+	ErrInvalidSpecialty = errors.New("invalid specialty")
+	ErrInvalidPhotoURL  = errors.New("invalid photo URL")
 )
 
 type DoctorService struct {
-	repository *repositories.DoctorRepository
+	repository          *repositories.DoctorRepository
+	procedureRepository *repositories.ProcedureRepository
 }
 
-func NewDoctorService(repository *repositories.DoctorRepository) *DoctorService {
-	return &DoctorService{repository: repository}
+func NewDoctorService(repository *repositories.DoctorRepository, procedureRepository *repositories.ProcedureRepository) *DoctorService {
+	return &DoctorService{repository: repository, procedureRepository: procedureRepository}
 }
 
 func (s *DoctorService) Create(ctx context.Context, doctor *models.Doctor) error {
+	if doctor.Specialty == "" {
+		doctor.Specialty = models.SpecialtyGeneral
+	}
+	if !models.IsValidSpecialty(doctor.Specialty) {
+		return fmt.Errorf("%w %q", ErrInvalidSpecialty, doctor.Specialty)
+	}
+	if err := checkPhotoURL(doctor); err != nil {
+		return err
+	}
 	return s.repository.Create(ctx, doctor)
 }
 
@@ -26,10 +47,149 @@ func (s *DoctorService) GetAll(ctx context.Context) ([]models.Doctor, error) {
 	return s.repository.GetAll(ctx)
 }
 
+// Search returns the doctors of a specialty or performing a procedure, for availability search and booking
+func (s *DoctorService) Search(ctx context.Context, filter models.DoctorFilter) ([]models.Doctor, error) {
+	if filter.Specialty != "" && !models.IsValidSpecialty(filter.Specialty) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSpecialty, filter.Specialty)
+	}
+	doctors, err := s.repository.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if doctors == nil {
+		doctors = []models.Doctor{}
+	}
+	return doctors, nil
+}
+
 func (s *DoctorService) Update(ctx context.Context, doctor *models.Doctor) error {
+	// Updates that leave the specialty out keep the current one
+	if doctor.Specialty == "" {
+		existing, err := s.repository.GetByID(ctx, doctor.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrDoctorNotFound
+		}
+		doctor.Specialty = existing.Specialty
+	}
+	if !models.IsValidSpecialty(doctor.Specialty) {
+		return fmt.Errorf("%w %q", ErrInvalidSpecialty, doctor.Specialty)
+	}
+	if err := checkPhotoURL(doctor); err != nil {
+		return err
+	}
 	return s.repository.Update(ctx, doctor)
 }
 
+// Patch changes only the fields present in patch, a JSON merge patch, keeping the others as they are
+func (s *DoctorService) Patch(ctx context.Context, id string, patch []byte) (*models.Doctor, error) {
+	doctor, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotFound
+	}
+	if err := applyPatch(doctor, patch); err != nil {
+		return nil, err
+	}
+	doctor.ID = id
+	doctor.Appointments, doctor.Billings = nil, nil
+	if err := s.Update(ctx, doctor); err != nil {
+		return nil, err
+	}
+	return doctor, nil
+}
+
 func (s *DoctorService) Delete(ctx context.Context, id string) error {
 	return s.repository.Delete(ctx, id)
 }
+
+// GetProcedures returns the procedures the doctor performs
+func (s *DoctorService) GetProcedures(ctx context.Context, doctorID string) ([]models.Procedure, error) {
+	doctor, err := s.repository.GetByID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotFound
+	}
+	procedures, err := s.procedureRepository.GetByDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if procedures == nil {
+		procedures = []models.Procedure{}
+	}
+	return procedures, nil
+}
+
+// SetProcedures replaces the procedures the doctor performs
+func (s *DoctorService) SetProcedures(ctx context.Context, doctorID string, procedureIDs []uint) ([]models.Procedure, error) {
+	doctor, err := s.repository.GetByID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotFound
+	}
+
+	ids := make([]uint, 0, len(procedureIDs))
+	seen := make(map[uint]bool, len(procedureIDs))
+	for _, id := range procedureIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		count, err := s.procedureRepository.CountExisting(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if count != int64(len(ids)) {
+			return nil, ErrProcedureNotFound
+		}
+	}
+
+	if err := s.procedureRepository.SetDoctorProcedures(ctx, doctorID, ids); err != nil {
+		return nil, err
+	}
+	return s.GetProcedures(ctx, doctorID)
+}
+
+// RevenueBySpecialty totals billing by the specialty of the billing doctor between the from and to days, inclusive
+func (s *DoctorService) RevenueBySpecialty(ctx context.Context, from, to time.Time) ([]models.SpecialtyRevenue, error) {
+	if to.Before(from) {
+		return nil, errors.New("to must not be before from")
+	}
+	revenue, err := s.repository.RevenueBySpecialty(ctx, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if revenue == nil {
+		revenue = []models.SpecialtyRevenue{}
+	}
+	return revenue, nil
+}
+
+// checkPhotoURL requires the doctor's photo, shown on the clinic website, to be an absolute http(s) URL.
+// A blank URL removes the photo.
+func checkPhotoURL(doctor *models.Doctor) error {
+	if doctor.PhotoURL == nil {
+		return nil
+	}
+	photoURL := strings.TrimSpace(*doctor.PhotoURL)
+	if photoURL == "" {
+		doctor.PhotoURL = nil
+		return nil
+	}
+	parsed, err := url.Parse(photoURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(photoURL) > 500 {
+		return fmt.Errorf("%w %q", ErrInvalidPhotoURL, photoURL)
+	}
+	doctor.PhotoURL = &photoURL
+	return nil
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/pdf"
+	"RoyDental/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSharedDocuments is the most documents a share holds
+const maxSharedDocuments = 20
+
+var (
+	ErrDocumentShareNotFound     = errors.New("document share not found")
+	ErrInvalidDocumentShare      = errors.New("invalid document share")
+	ErrDocumentSharingDisabled   = errors.New("document sharing is not configured")
+	ErrDocumentShareNotPermitted = errors.New("the patient has not agreed to receive messages on this channel")
+	ErrDocumentShareNotSent      = errors.New("the document share could not be sent")
+	ErrInvalidDocumentShareLink  = errors.New("invalid or expired document link")
+)
+
+// DocumentShareService shares patients' x-rays, images and invoices with them through signed links
+// sent by email or SMS, so staff do not email attachments from their own accounts. Links expire,
+// can be revoked, and each use of them is logged.
+type DocumentShareService struct {
+	repository      *repositories.DocumentShareRepository
+	patientRepo     *repositories.PatientRepository
+	examinationRepo *repositories.ExaminationRepository
+	attachmentRepo  *repositories.AttachmentRepository
+	imagingRepo     *repositories.ImagingRepository
+	billingRepo     *repositories.BillingRepository
+	communications  *CommunicationService
+	patientQR       *PatientQRService
+	email           notifications.Notifier
+	sms             notifications.Notifier
+	config          config.DocumentShareConfig
+}
+
+func NewDocumentShareService(repository *repositories.DocumentShareRepository, patientRepo *repositories.PatientRepository,
+	examinationRepo *repositories.ExaminationRepository, attachmentRepo *repositories.AttachmentRepository, imagingRepo *repositories.ImagingRepository,
+	billingRepo *repositories.BillingRepository, communications *CommunicationService, patientQR *PatientQRService, email, sms notifications.Notifier,
+	cfg config.DocumentShareConfig) *DocumentShareService {
+	return &DocumentShareService{
+		repository:      repository,
+		patientRepo:     patientRepo,
+		examinationRepo: examinationRepo,
+		attachmentRepo:  attachmentRepo,
+		imagingRepo:     imagingRepo,
+		billingRepo:     billingRepo,
+		communications:  communications,
+		patientQR:       patientQR,
+		email:           email,
+		sms:             sms,
+		config:          cfg,
+	}
+}
+
+// Enabled reports whether share links can be signed and sent
+func (s *DocumentShareService) Enabled() bool {
+	return s.config.SigningKey != "" && s.config.BaseURL != ""
+}
+
+// Share sends the patient a link to the documents by email or SMS. A share whose message could not
+// be sent is not kept.
+func (s *DocumentShareService) Share(ctx context.Context, patientID string, request models.DocumentShareRequest) (*models.DocumentShare, error) {
+	if !s.Enabled() {
+		return nil, ErrDocumentSharingDisabled
+	}
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+
+	share := &models.DocumentShare{PatientID: patientID, Channel: request.Channel, Message: strings.TrimSpace(request.Message)}
+	switch request.Channel {
+	case notifications.ChannelEmail:
+		share.Recipient = strings.TrimSpace(patient.Email)
+	case notifications.ChannelSMS:
+		share.Recipient = strings.TrimSpace(patient.Phone)
+	default:
+		return nil, fmt.Errorf("%w: channel must be email or sms", ErrInvalidDocumentShare)
+	}
+	if share.Recipient == "" {
+		return nil, fmt.Errorf("%w: the patient has no %s on file", ErrInvalidDocumentShare, map[string]string{
+			notifications.ChannelEmail: "email address",
+			notifications.ChannelSMS:   "phone number",
+		}[request.Channel])
+	}
+
+	ttl := s.config.LinkTTL
+	if request.ValidHours != 0 {
+		ttl = time.Duration(request.ValidHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > s.config.MaxLinkTTL {
+		return nil, fmt.Errorf("%w: links stay valid for 1 to %d hours", ErrInvalidDocumentShare, int(s.config.MaxLinkTTL.Hours()))
+	}
+	share.ExpiresAt = time.Now().Add(ttl)
+
+	if len(request.Documents) == 0 || len(request.Documents) > maxSharedDocuments {
+		return nil, fmt.Errorf("%w: share 1 to %d documents", ErrInvalidDocumentShare, maxSharedDocuments)
+	}
+	for _, document := range request.Documents {
+		document.ID, document.ShareID = 0, 0
+		document.RecordID = strings.TrimSpace(document.RecordID)
+		file, err := s.file(ctx, patientID, document, false)
+		if err != nil {
+			return nil, err
+		}
+		document.Name = file.Name
+		share.Documents = append(share.Documents, document)
+	}
+
+	if err := s.repository.Create(ctx, share); err != nil {
+		return nil, err
+	}
+	share.Link = s.Link(share)
+	if err := s.send(ctx, patient, share); err != nil {
+		if deleteErr := s.repository.Delete(ctx, share.ID); deleteErr != nil {
+			log.Printf("Failed to delete unsent document share %d: %v", share.ID, deleteErr)
+		}
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *DocumentShareService) send(ctx context.Context, patient *models.Patient, share *models.DocumentShare) error {
+	expires := models.FormatDateTime(share.ExpiresAt.In(models.ClinicLocation()))
+	notification := notifications.Notification{
+		Recipients: []string{share.Recipient},
+		PatientID:  share.PatientID,
+		Purpose:    notifications.PurposeService,
+		Subject:    "Your documents from " + s.config.ClinicName,
+	}
+	notifier := s.email
+	if share.Channel == notifications.ChannelSMS {
+		notifier = s.sms
+		notification.Body = fmt.Sprintf("%s: your documents are ready at %s until %s.", s.config.ClinicName, share.Link, expires)
+	} else {
+		var body strings.Builder
+		fmt.Fprintf(&body, "Dear %s,\n\n%s has shared the following documents with you:\n\n", patient.FirstName, s.config.ClinicName)
+		for _, document := range share.Documents {
+			body.WriteString("- " + document.Name + "\n")
+		}
+		if share.Message != "" {
+			body.WriteString("\n" + share.Message + "\n")
+		}
+		fmt.Fprintf(&body, "\nYou can open them at %s until %s. The link is for you only, please do not forward it.\n\n%s\n",
+			share.Link, expires, s.config.ClinicName)
+		notification.Body = body.String()
+	}
+
+	notification.MessageID = notifications.NewMessageID()
+	sendErr := notifier.Send(ctx, notification)
+	err := s.communications.LogMessage(ctx, models.CommunicationLog{
+		PatientID: share.PatientID,
+		Channel:   share.Channel,
+		Purpose:   notification.Purpose,
+		Recipient: share.Recipient,
+		Subject:   notification.Subject,
+		Body:      notification.Body,
+		MessageID: notification.MessageID,
+		Record:    "document_share",
+		RecordID:  fmt.Sprint(share.ID),
+	}, sendErr)
+	if err != nil {
+		log.Printf("Failed to log document share to patient %s: %v", share.PatientID, err)
+	}
+	switch {
+	case sendErr == nil, errors.Is(sendErr, notifications.ErrDeferred):
+		return nil
+	case errors.Is(sendErr, notifications.ErrNotPermitted):
+		return ErrDocumentShareNotPermitted
+	default:
+		return fmt.Errorf("%w: %v", ErrDocumentShareNotSent, sendErr)
+	}
+}
+
+// Get returns a patient's share with its access log
+func (s *DocumentShareService) Get(ctx context.Context, patientID string, id uint) (*models.DocumentShare, error) {
+	share, err := s.repository.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, ErrDocumentShareNotFound
+	}
+	if share.Accesses == nil {
+		share.Accesses = []models.DocumentShareAccess{}
+	}
+	return share, nil
+}
+
+func (s *DocumentShareService) List(ctx context.Context, patientID string) ([]models.DocumentShare, error) {
+	shares, err := s.repository.List(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if shares == nil {
+		shares = []models.DocumentShare{}
+	}
+	return shares, nil
+}
+
+// Revoke stops a share's link from working before it expires
+func (s *DocumentShareService) Revoke(ctx context.Context, patientID string, id uint, userID int64) (*models.DocumentShare, error) {
+	share, err := s.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if share.RevokedAt != nil {
+		return share, nil
+	}
+	if err := s.repository.Revoke(ctx, share, &userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// Link returns the signed link of a share, valid until it expires
+func (s *DocumentShareService) Link(share *models.DocumentShare) string {
+	unix := share.ExpiresAt.Unix()
+	return fmt.Sprintf("%s?share=%d&expires=%d&signature=%s",
+		strings.TrimRight(s.config.BaseURL, "/"), share.ID, unix, s.signature(share.ID, unix))
+}
+
+func (s *DocumentShareService) signature(shareID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	fmt.Fprintf(mac, "document-share:%d:%d", shareID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Linked returns the documents a signed link shares, logging that the link was opened
+func (s *DocumentShareService) Linked(ctx context.Context, shareID uint, expires, signature, ip, userAgent string) (*models.SharedDocuments, error) {
+	share, err := s.linked(ctx, shareID, expires, signature)
+	if err != nil {
+		return nil, err
+	}
+	s.logAccess(ctx, share.ID, nil, ip, userAgent)
+
+	shared := &models.SharedDocuments{
+		Clinic:    s.config.ClinicName,
+		FirstName: share.Patient.FirstName,
+		Message:   share.Message,
+		ExpiresAt: share.ExpiresAt,
+		Documents: make([]models.SharedDocumentLink, 0, len(share.Documents)),
+	}
+	for _, document := range share.Documents {
+		shared.Documents = append(shared.Documents, models.SharedDocumentLink{
+			ID:   document.ID,
+			Kind: document.Kind,
+			Name: document.Name,
+			URL:  fmt.Sprintf("/shared_documents/%d/documents/%d?expires=%s&signature=%s", share.ID, document.ID, expires, signature),
+		})
+	}
+	return shared, nil
+}
+
+// LinkedFile returns a document a signed link shares, logging the download
+func (s *DocumentShareService) LinkedFile(ctx context.Context, shareID, documentID uint, expires, signature, ip, userAgent string) (*models.SharedFile, error) {
+	share, err := s.linked(ctx, shareID, expires, signature)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range share.Documents {
+		if document.ID != documentID {
+			continue
+		}
+		file, err := s.file(ctx, share.PatientID, document, true)
+		if err != nil {
+			if errors.Is(err, ErrInvalidDocumentShare) {
+				// The document was deleted since it was shared
+				return nil, ErrInvalidDocumentShareLink
+			}
+			return nil, err
+		}
+		s.logAccess(ctx, share.ID, &document.ID, ip, userAgent)
+		return file, nil
+	}
+	return nil, ErrInvalidDocumentShareLink
+}
+
+// linked returns the share a signed link points to while the link still works
+func (s *DocumentShareService) linked(ctx context.Context, shareID uint, expires, signature string) (*models.DocumentShare, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !s.Enabled() || !hmac.Equal([]byte(signature), []byte(s.signature(shareID, unix))) {
+		return nil, ErrInvalidDocumentShareLink
+	}
+	share, err := s.repository.GetLinked(ctx, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil || share.ExpiresAt.Unix() != unix || !share.Active(time.Now()) {
+		return nil, ErrInvalidDocumentShareLink
+	}
+	return share, nil
+}
+
+func (s *DocumentShareService) logAccess(ctx context.Context, shareID uint, documentID *uint, ip, userAgent string) {
+	access := &models.DocumentShareAccess{ShareID: shareID, DocumentID: documentID, IP: ip, UserAgent: userAgent, AccessedAt: time.Now()}
+	if err := s.repository.LogAccess(ctx, access); err != nil {
+		log.Printf("Failed to log access to document share %d: %v", shareID, err)
+	}
+}
+
+// file looks up a document of the patient, with its content when withContent is set
+func (s *DocumentShareService) file(ctx context.Context, patientID string, document models.SharedDocument, withContent bool) (*models.SharedFile, error) {
+	notFound := fmt.Errorf("%w: the patient has no %s %q", ErrInvalidDocumentShare, strings.ReplaceAll(document.Kind, "_", " "), document.RecordID)
+	switch document.Kind {
+	case models.SharedDocumentAttachment:
+		id, err := strconv.ParseUint(document.RecordID, 10, 32)
+		if err != nil || document.ExaminationID == nil {
+			return nil, fmt.Errorf("%w: attachments need their record_id and examination_id", ErrInvalidDocumentShare)
+		}
+		examination, err := s.examinationRepo.GetByID(ctx, patientID, *document.ExaminationID)
+		if err != nil {
+			return nil, err
+		}
+		if examination == nil {
+			return nil, notFound
+		}
+		get := s.attachmentRepo.GetByID
+		if withContent {
+			get = s.attachmentRepo.GetWithContent
+		}
+		attachment, err := get(ctx, *document.ExaminationID, uint(id))
+		if err != nil {
+			return nil, err
+		}
+		if attachment == nil {
+			return nil, notFound
+		}
+		return &models.SharedFile{Name: attachment.FileName, ContentType: attachment.ContentType, Content: attachment.Content}, nil
+
+	case models.SharedDocumentImagingStudy:
+		id, err := strconv.ParseUint(document.RecordID, 10, 32)
+		if err != nil {
+			return nil, notFound
+		}
+		get := s.imagingRepo.GetByID
+		if withContent {
+			get = s.imagingRepo.GetWithContent
+		}
+		study, err := get(ctx, uint(id))
+		if err != nil {
+			return nil, err
+		}
+		if study == nil || study.PatientID == nil || *study.PatientID != patientID {
+			return nil, notFound
+		}
+		return &models.SharedFile{Name: study.FileName, ContentType: "application/dicom", Content: study.Content}, nil
+
+	case models.SharedDocumentInvoice:
+		billing, err := s.billingRepo.GetByID(ctx, document.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		if billing == nil || billing.PatientID != patientID {
+			return nil, notFound
+		}
+		file := &models.SharedFile{Name: "invoice-" + billing.BillingID + ".pdf", ContentType: "application/pdf"}
+		if withContent {
+			file.Content = invoicePDF(s.config.ClinicName, billing, s.patientQR)
+		}
+		return file, nil
+	}
+	return nil, fmt.Errorf("%w: unknown document kind %q", ErrInvalidDocumentShare, document.Kind)
+}
+
+// invoicePDF renders a bill as the patient's invoice headed with the clinic's name, ending with
+// the patient's QR code when codes are configured
+func invoicePDF(clinicName string, billing *models.Billing, patientQR *PatientQRService) []byte {
+	doc := pdf.New()
+	doc.Title(clinicName + " - Invoice " + billing.BillingID)
+	doc.Text("Patient: " + billing.Patient.FirstName + " " + billing.Patient.LastName)
+	doc.Text("Date: " + models.FormatDate(billing.CreatedAt.In(models.ClinicLocation())))
+	doc.Text("Dentist: Dr " + billing.Doctor.FirstName + " " + billing.Doctor.LastName)
+
+	doc.Heading("Treatment")
+	doc.Text(billing.Procedure + ": " + models.FormatMoney(billing.BillingAmount))
+
+	doc.Heading("Payments")
+	doc.Text("Paid by you: " + models.FormatMoney(billing.PaidCashAmount))
+	doc.Text("Paid by your insurer: " + models.FormatMoney(billing.PaidInsuranceAmount))
+	doc.Space(4)
+	doc.Text("Balance to pay: " + models.FormatMoney(billing.Balance))
+	patientQR.draw(doc, billing.PatientID)
+	return doc.Bytes()
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDunningStageNotFound = errors.New("dunning stage not found")
+	ErrInvalidDunningStage  = errors.New("invalid dunning stage")
+)
+
+// DunningService keeps the dunning schedule and, in the background, sends patients a statement of
+// their bills as each reaches a stage of it, by email and SMS as the stage says and as far as the
+// patient agreed to be contacted. The bills of a household are consolidated into one statement to
+// its guarantor. Bills under an open dispute or an active payment plan are left alone, and every
+// statement is written to the communication log of the patient it went to.
+type DunningService struct {
+	repository     *repositories.DunningRepository
+	billingRepo    *repositories.BillingRepository
+	communications *CommunicationService
+	email          notifications.Notifier
+	sms            notifications.Notifier
+	config         config.DunningConfig
+	clock          clock.Clock
+}
+
+// NewDunningService starts sending statements in the background when a dispatch interval is set.
+func NewDunningService(repository *repositories.DunningRepository, billingRepo *repositories.BillingRepository, communications *CommunicationService, email, sms notifications.Notifier, cfg config.DunningConfig, clock clock.Clock) *DunningService {
+	s := &DunningService{
+		repository:     repository,
+		billingRepo:    billingRepo,
+		communications: communications,
+		email:          email,
+		sms:            sms,
+		config:         cfg,
+		clock:          clock,
+	}
+	if cfg.DispatchInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *DunningService) CreateStage(ctx context.Context, stage *models.DunningStage) error {
+	if err := s.validate(ctx, stage); err != nil {
+		return err
+	}
+	return s.repository.CreateStage(ctx, stage)
+}
+
+func (s *DunningService) GetStage(ctx context.Context, id uint) (*models.DunningStage, error) {
+	stage, err := s.repository.GetStage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if stage == nil {
+		return nil, ErrDunningStageNotFound
+	}
+	return stage, nil
+}
+
+func (s *DunningService) ListStages(ctx context.Context) ([]models.DunningStage, error) {
+	stages, err := s.repository.ListStages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stages == nil {
+		stages = []models.DunningStage{}
+	}
+	return stages, nil
+}
+
+func (s *DunningService) UpdateStage(ctx context.Context, stage *models.DunningStage) error {
+	if _, err := s.GetStage(ctx, stage.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, stage); err != nil {
+		return err
+	}
+	return s.repository.UpdateStage(ctx, stage)
+}
+
+func (s *DunningService) DeleteStage(ctx context.Context, id uint) error {
+	if _, err := s.GetStage(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteStage(ctx, id)
+}
+
+// ListNotices returns the dunning stages a bill reached and when the patient was told
+func (s *DunningService) ListNotices(ctx context.Context, billingID string) ([]models.DunningNotice, error) {
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+	notices, err := s.repository.ListNotices(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if notices == nil {
+		notices = []models.DunningNotice{}
+	}
+	return notices, nil
+}
+
+func (s *DunningService) validate(ctx context.Context, stage *models.DunningStage) error {
+	stage.Subject = strings.TrimSpace(stage.Subject)
+	if stage.DaysOverdue <= 0 {
+		return fmt.Errorf("%w: days_overdue must be positive", ErrInvalidDunningStage)
+	}
+	if stage.Subject == "" || strings.TrimSpace(stage.Body) == "" {
+		return fmt.Errorf("%w: subject and body are required", ErrInvalidDunningStage)
+	}
+	if !stage.SendEmail && !stage.SendSMS {
+		return fmt.Errorf("%w: send_email or send_sms is required", ErrInvalidDunningStage)
+	}
+	exists, err := s.repository.StageExists(ctx, stage.DaysOverdue, stage.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: there already is a stage at %d days overdue", ErrInvalidDunningStage, stage.DaysOverdue)
+	}
+	return nil
+}
+
+func (s *DunningService) run() {
+	ticker := time.NewTicker(s.config.DispatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.dispatch(context.Background())
+	}
+}
+
+// dispatch sends each account one statement of its bills that reached a new stage, under the
+// latest stage among them
+func (s *DunningService) dispatch(ctx context.Context) {
+	stages, err := s.repository.ListStages(ctx)
+	if err != nil {
+		log.Printf("Failed to get dunning stages: %v", err)
+		return
+	}
+	byID := make(map[uint]models.DunningStage, len(stages))
+	for _, stage := range stages {
+		byID[stage.ID] = stage
+	}
+
+	_, tomorrow := models.ClinicDay(s.clock.Now())
+	candidates, err := s.repository.Pending(ctx, tomorrow.AddDate(0, 0, -s.config.PaymentTermsDays), s.config.BatchSize)
+	if err != nil {
+		log.Printf("Failed to find overdue bills: %v", err)
+		return
+	}
+
+	var statements []models.DunningStatement
+	for _, candidate := range candidates {
+		stage, ok := byID[candidate.StageID]
+		if !ok {
+			continue
+		}
+		last := len(statements) - 1
+		if last < 0 || statements[last].Bills[0].AccountID != candidate.AccountID {
+			statements = append(statements, models.DunningStatement{Stage: stage})
+			last++
+		}
+		if stage.DaysOverdue > statements[last].Stage.DaysOverdue {
+			statements[last].Stage = stage
+		}
+		statements[last].Bills = append(statements[last].Bills, candidate)
+	}
+	// The last account's bills may go on past the batch; they are sent together on the next run
+	if len(candidates) == s.config.BatchSize && len(statements) > 1 {
+		statements = statements[:len(statements)-1]
+	}
+
+	for _, statement := range statements {
+		s.send(ctx, statement)
+	}
+}
+
+// send records the statement's notices before sending so replicas never send the same statement
+// twice, and clears them again if it could not be delivered on any channel
+func (s *DunningService) send(ctx context.Context, statement models.DunningStatement) {
+	account := statement.Bills[0]
+	var notices []models.DunningNotice
+	var bills []models.DunningCandidate
+	now := s.clock.Now()
+	for _, bill := range statement.Bills {
+		notice := models.DunningNotice{BillingID: bill.BillingID, StageID: bill.StageID, PatientID: bill.PatientID, SentAt: now}
+		recorded, err := s.repository.RecordNotice(ctx, &notice)
+		if err != nil {
+			log.Printf("Failed to record dunning notice for bill %s: %v", bill.BillingID, err)
+			continue
+		}
+		if recorded {
+			notices = append(notices, notice)
+			bills = append(bills, bill)
+		}
+	}
+	if len(bills) == 0 {
+		return
+	}
+	statement.Bills = bills
+
+	// Statements concern the patient's account, so opting out of reminders does not stop them
+	notification := notifications.Notification{
+		PatientID: account.AccountID,
+		Purpose:   notifications.PurposeService,
+		Subject:   statement.Fill(statement.Stage.Subject),
+		Body:      statement.Fill(statement.Stage.Body),
+	}
+	channels := []struct {
+		name      string
+		enabled   bool
+		recipient string
+		notifier  notifications.Notifier
+	}{
+		{notifications.ChannelEmail, statement.Stage.SendEmail, account.PatientEmail, s.email},
+		{notifications.ChannelSMS, statement.Stage.SendSMS, account.PatientPhone, s.sms},
+	}
+	delivered := false
+	for _, channel := range channels {
+		if !channel.enabled || channel.recipient == "" || channel.notifier == nil {
+			continue
+		}
+		notification.Recipients = []string{channel.recipient}
+		notification.MessageID = notifications.NewMessageID()
+		sendErr := channel.notifier.Send(ctx, notification)
+		if sendErr == nil || errors.Is(sendErr, notifications.ErrNotPermitted) || errors.Is(sendErr, notifications.ErrDeferred) {
+			// Patients who opted out of the channel, or whose address bounced, keep their notice and are not tried again,
+			// and statements held until quiet hours end are delivered then
+			delivered = true
+		} else {
+			log.Printf("Failed to send dunning statement by %s to patient %s: %v", channel.name, account.AccountID, sendErr)
+		}
+		err := s.communications.LogMessage(ctx, models.CommunicationLog{
+			PatientID: account.AccountID,
+			Channel:   channel.name,
+			Purpose:   notification.Purpose,
+			Recipient: channel.recipient,
+			Subject:   notification.Subject,
+			Body:      notification.Body,
+			MessageID: notification.MessageID,
+			Record:    "dunning_stage",
+			RecordID:  fmt.Sprint(statement.Stage.ID),
+		}, sendErr)
+		if err != nil {
+			log.Printf("Failed to log dunning statement to patient %s: %v", account.AccountID, err)
+		}
+	}
+
+	if !delivered {
+		for _, notice := range notices {
+			if err := s.repository.DeleteNotice(ctx, notice.ID); err != nil {
+				log.Printf("Failed to clear dunning notice for bill %s: %v", notice.BillingID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"RoyDental/breaker"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+)
+
+// Entities that can be locked for editing
+const EditLockEntityPatient = "patient"
+
+var (
+	ErrEditLocked           = errors.New("record is being edited by another user")
+	ErrEditLockNotHeld      = errors.New("edit lock is not held")
+	ErrEditLocksUnavailable = errors.New("edit locks are unavailable")
+)
+
+// EditLockService gives staff soft locks on records they are editing. The locks only warn: writes
+// are not refused, and an abandoned lock expires on its own when heartbeats stop.
+type EditLockService struct {
+	repository *repositories.EditLockRepository
+	config     config.EditLockConfig
+}
+
+func NewEditLockService(repository *repositories.EditLockRepository, cfg config.EditLockConfig) *EditLockService {
+	return &EditLockService{repository: repository, config: cfg}
+}
+
+// Acquire locks a record for userID, or renews the lock userID already holds. When someone else
+// holds it, their lock is returned with ErrEditLocked.
+func (s *EditLockService) Acquire(ctx context.Context, entity, recordID string, userID int64) (*models.EditLock, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		held, err := s.repository.Acquire(ctx, entity, recordID, userID, s.config.TTL)
+		if err != nil {
+			return nil, err
+		}
+		lock, err := s.repository.Get(ctx, entity, recordID)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			return lock, nil
+		}
+		// Without a lock to show, the other one expired in between, so try once more
+		if lock != nil || attempt > 0 {
+			return lock, ErrEditLocked
+		}
+	}
+}
+
+// Heartbeat keeps the lock of userID alive while the record is open for editing
+func (s *EditLockService) Heartbeat(ctx context.Context, entity, recordID string, userID int64) (*models.EditLock, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	extended, err := s.repository.Extend(ctx, entity, recordID, userID, s.config.TTL)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := s.repository.Get(ctx, entity, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if !extended {
+		if lock != nil {
+			return lock, ErrEditLocked
+		}
+		return nil, ErrEditLockNotHeld
+	}
+	return lock, nil
+}
+
+// Release ends the edit of userID
+func (s *EditLockService) Release(ctx context.Context, entity, recordID string, userID int64) error {
+	if err := s.available(); err != nil {
+		return err
+	}
+	return s.repository.Release(ctx, entity, recordID, userID)
+}
+
+// Get returns who is editing a record, or nil when nobody is
+func (s *EditLockService) Get(ctx context.Context, entity, recordID string) (*models.EditLock, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	return s.repository.Get(ctx, entity, recordID)
+}
+
+// available refuses lock operations while Redis is known to be down rather than waiting on it
+func (s *EditLockService) available() error {
+	if database.RedisClient == nil || database.RedisStatus() == breaker.Open {
+		return ErrEditLocksUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eligibilityQueueSize bounds the checks waiting for the insurer integration.
+const eligibilityQueueSize = 256
+
+// Eligibility check listings are limited to
+const (
+	defaultEligibilityLimit = 100
+	maxEligibilityLimit     = 500
+)
+
+var (
+	ErrEligibilityCheckNotFound = errors.New("eligibility check not found")
+	ErrInvalidEligibility       = errors.New("invalid eligibility outcome")
+	// ErrEligibilityIntegrationDisabled is returned when a check is sent to the insurer integration
+	// while none is configured
+	ErrEligibilityIntegrationDisabled = errors.New("the insurer eligibility integration is not configured")
+)
+
+// eligibilityRequest is posted to the eligibility webhook
+type eligibilityRequest struct {
+	PatientID        string `json:"patient_id"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	DateOfBirth      string `json:"date_of_birth"`
+	InsuranceCompany string `json:"insurance_company"`
+	Scheme           string `json:"scheme,omitempty"`
+	MemberNumber     string `json:"member_number,omitempty"`
+	ServiceDate      string `json:"service_date"`
+}
+
+// eligibilityResponse is what the eligibility webhook answers
+type eligibilityResponse struct {
+	Eligible bool   `json:"eligible"`
+	Reason   string `json:"reason"`
+}
+
+// EligibilityService checks with the insurer that insured patients are covered on the day of each
+// appointment they book: through the insurer integration when one is configured, otherwise by
+// putting the check on the front desk's list to do by hand.
+type EligibilityService struct {
+	repository      *repositories.EligibilityRepository
+	patientRepo     *repositories.PatientRepository
+	appointmentRepo *repositories.AppointmentRepository
+	config          config.EligibilityConfig
+	client          *http.Client
+	queue           chan uint
+}
+
+// NewEligibilityService starts a background worker so booking never waits on the insurer.
+func NewEligibilityService(repository *repositories.EligibilityRepository, patientRepo *repositories.PatientRepository,
+	appointmentRepo *repositories.AppointmentRepository, cfg config.EligibilityConfig) *EligibilityService {
+	s := &EligibilityService{
+		repository:      repository,
+		patientRepo:     patientRepo,
+		appointmentRepo: appointmentRepo,
+		config:          cfg,
+		client:          &http.Client{Timeout: cfg.Timeout},
+		queue:           make(chan uint, eligibilityQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// IntegrationEnabled reports whether checks are sent to the insurer integration
+func (s *EligibilityService) IntegrationEnabled() bool {
+	return s.config.URL != ""
+}
+
+// HandleAppointmentBooked queues the eligibility check of an insured patient's new appointment on
+// AppointmentBooked
+func (s *EligibilityService) HandleAppointmentBooked(ctx context.Context, event events.Event) error {
+	id, err := strconv.ParseUint(event.RecordID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid appointment ID %q: %w", event.RecordID, err)
+	}
+	patient, err := s.patientRepo.GetByID(ctx, event.PatientID)
+	if err != nil || patient == nil || !patient.Insured {
+		return err
+	}
+	appointment, err := s.appointmentRepo.GetByID(ctx, event.PatientID, uint(id))
+	if err != nil || appointment == nil || appointment.StartsAt == nil {
+		return err
+	}
+
+	check := &models.EligibilityCheck{
+		AppointmentID:    appointment.ID,
+		PatientID:        patient.ID,
+		InsuranceCompany: patient.InsuranceCompany,
+		Scheme:           patient.Scheme,
+		MemberNumber:     patient.MemberNumber,
+		ServiceDate:      appointment.StartsAt.In(models.ClinicLocation()).Format("2006-01-02"),
+		Method:           models.EligibilityMethodManual,
+		Status:           models.EligibilityPending,
+	}
+	if s.IntegrationEnabled() {
+		check.Method = models.EligibilityMethodIntegration
+	}
+	created, err := s.repository.Create(ctx, check)
+	if err != nil || !created {
+		return err
+	}
+	if err := s.appointmentRepo.Invalidate(ctx, check.PatientID, check.AppointmentID); err != nil {
+		return err
+	}
+	if s.IntegrationEnabled() {
+		select {
+		case s.queue <- check.ID:
+		default:
+			log.Printf("Eligibility queue full, leaving check %d of patient %s pending", check.ID, check.PatientID)
+		}
+	}
+	return nil
+}
+
+func (s *EligibilityService) Get(ctx context.Context, id uint) (*models.EligibilityCheck, error) {
+	check, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if check == nil {
+		return nil, ErrEligibilityCheckNotFound
+	}
+	return check, nil
+}
+
+// GetByAppointment returns the check of the patient's appointment
+func (s *EligibilityService) GetByAppointment(ctx context.Context, patientID string, appointmentID uint) (*models.EligibilityCheck, error) {
+	check, err := s.repository.GetByAppointment(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if check == nil {
+		return nil, ErrEligibilityCheckNotFound
+	}
+	return check, nil
+}
+
+// List returns the checks with status, or all those not confirmed yet, soonest appointment first
+func (s *EligibilityService) List(ctx context.Context, status string, limit int) ([]models.EligibilityCheck, error) {
+	if status != "" && !models.IsValidEligibilityStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidEligibility, status)
+	}
+	if limit <= 0 {
+		limit = defaultEligibilityLimit
+	}
+	checks, err := s.repository.List(ctx, status, min(limit, maxEligibilityLimit))
+	if err != nil {
+		return nil, err
+	}
+	if checks == nil {
+		checks = []models.EligibilityCheck{}
+	}
+	return checks, nil
+}
+
+// Record saves the outcome of a check staff carried out themselves, such as by calling the insurer
+func (s *EligibilityService) Record(ctx context.Context, id uint, outcome models.EligibilityOutcome) (*models.EligibilityCheck, error) {
+	if outcome.Status != models.EligibilityConfirmed && outcome.Status != models.EligibilityNotEligible {
+		return nil, fmt.Errorf("%w: status must be %q or %q", ErrInvalidEligibility, models.EligibilityConfirmed, models.EligibilityNotEligible)
+	}
+	check, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	check.Status, check.Detail, check.CheckedAt, check.CheckedBy = outcome.Status, strings.TrimSpace(outcome.Detail), &now, nil
+	if userID, ok := models.ActorFrom(ctx); ok {
+		check.CheckedBy = &userID
+	}
+	if err := s.save(ctx, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// Run asks the insurer integration about a check again straight away
+func (s *EligibilityService) Run(ctx context.Context, id uint) (*models.EligibilityCheck, error) {
+	if !s.IntegrationEnabled() {
+		return nil, ErrEligibilityIntegrationDisabled
+	}
+	check, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(ctx, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// Unconfirmed returns the check of the patient's appointment when the patient's cover was not
+// confirmed, for the front desk to be warned at check-in, or nil when there is nothing to warn about
+func (s *EligibilityService) Unconfirmed(ctx context.Context, patientID string, appointmentID uint) (*models.EligibilityCheck, error) {
+	check, err := s.repository.GetByAppointment(ctx, patientID, appointmentID)
+	if err != nil || check == nil || check.Confirmed() {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (s *EligibilityService) run() {
+	// Webhook calls and queries carry their own timeouts
+	for id := range s.queue {
+		ctx := context.Background()
+		check, err := s.repository.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("Failed to load eligibility check %d: %v", id, err)
+			continue
+		}
+		if check == nil || check.Status != models.EligibilityPending {
+			continue
+		}
+		if err := s.verify(ctx, check); err != nil {
+			log.Printf("Failed to run eligibility check %d: %v", id, err)
+		}
+	}
+}
+
+// verify asks the insurer integration whether the patient is covered on the service date and saves
+// the answer, posting an eligibility_failed event when the cover was not confirmed
+func (s *EligibilityService) verify(ctx context.Context, check *models.EligibilityCheck) error {
+	patient, err := s.patientRepo.GetByID(ctx, check.PatientID)
+	if err != nil {
+		return err
+	}
+	if patient == nil {
+		return ErrPatientNotFound
+	}
+
+	result, err := s.call(ctx, eligibilityRequest{
+		PatientID:        patient.ID,
+		FirstName:        patient.FirstName,
+		LastName:         patient.LastName,
+		DateOfBirth:      patient.DateOfBirth,
+		InsuranceCompany: check.InsuranceCompany,
+		Scheme:           check.Scheme,
+		MemberNumber:     check.MemberNumber,
+		ServiceDate:      check.ServiceDate,
+	})
+	now := time.Now()
+	check.CheckedAt, check.CheckedBy = &now, nil
+	switch {
+	case err != nil:
+		check.Status, check.Detail = models.EligibilityError, err.Error()
+	case result.Eligible:
+		check.Status, check.Detail = models.EligibilityConfirmed, result.Reason
+	default:
+		check.Status, check.Detail = models.EligibilityNotEligible, result.Reason
+	}
+	if err := s.save(ctx, check); err != nil {
+		return err
+	}
+	if !check.Confirmed() {
+		notifications.Publish(notifications.EventEligibilityFailed, "Insurance eligibility needs follow-up",
+			fmt.Sprintf("The eligibility check of patient %s with %s for %s came back %s: %s", check.PatientID, check.InsuranceCompany,
+				check.ServiceDate, strings.ReplaceAll(check.Status, "_", " "), check.Detail))
+	}
+	return nil
+}
+
+// save stores a check's outcome and drops its appointment from the cache
+func (s *EligibilityService) save(ctx context.Context, check *models.EligibilityCheck) error {
+	if err := s.repository.Save(ctx, check); err != nil {
+		return err
+	}
+	return s.appointmentRepo.Invalidate(ctx, check.PatientID, check.AppointmentID)
+}
+
+// call posts a check to the eligibility webhook
+func (s *EligibilityService) call(ctx context.Context, request eligibilityRequest) (*eligibilityResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode eligibility request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build eligibility request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("eligibility service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("eligibility service returned %s", resp.Status)
+	}
+
+	var result eligibilityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to read eligibility response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+var (
+	// ErrEmailWebhookDisabled is returned for provider notifications while no webhook secret is configured
+	ErrEmailWebhookDisabled = errors.New("email delivery notifications are not accepted")
+	// ErrInvalidEmailEvents is returned for provider notifications that are unsigned or unreadable
+	ErrInvalidEmailEvents = errors.New("invalid email delivery notification")
+	// ErrEmailSuppressionNotFound is returned when removing an address that is not suppressed
+	ErrEmailSuppressionNotFound = errors.New("email address is not suppressed")
+)
+
+// EmailDeliveryService takes the bounces and complaints the mail provider reports. Each is recorded
+// against the email in the communication log it is about, and addresses that bounced permanently or
+// complained are suppressed: they are no longer emailed, and the patients and users with them flagged.
+type EmailDeliveryService struct {
+	repository *repositories.EmailDeliveryRepository
+	config     config.EmailDeliveryConfig
+}
+
+func NewEmailDeliveryService(repository *repositories.EmailDeliveryRepository, cfg config.EmailDeliveryConfig) *EmailDeliveryService {
+	return &EmailDeliveryService{repository: repository, config: cfg}
+}
+
+// HandleEvents takes a notification signed with the hex HMAC-SHA256 of its body under the webhook
+// secret, holding a single event or a list of them
+func (s *EmailDeliveryService) HandleEvents(ctx context.Context, body []byte, signature string) (*models.EmailEventResult, error) {
+	if s.config.WebhookSecret == "" {
+		return nil, ErrEmailWebhookDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+	mac.Write(body)
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac.Sum(nil), actual) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidEmailEvents)
+	}
+
+	var events []models.EmailEvent
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '{' {
+		var event models.EmailEvent
+		err = json.Unmarshal(body, &event)
+		events = append(events, event)
+	} else {
+		err = json.Unmarshal(body, &events)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEmailEvents, err)
+	}
+	for i, event := range events {
+		if !models.IsValidEmailEvent(event.Type) {
+			return nil, fmt.Errorf("%w: event %d has unknown type %q", ErrInvalidEmailEvents, i, event.Type)
+		}
+		if event.MessageID == "" && strings.TrimSpace(event.Recipient) == "" {
+			return nil, fmt.Errorf("%w: event %d has neither a message_id nor a recipient", ErrInvalidEmailEvents, i)
+		}
+	}
+
+	result := &models.EmailEventResult{Received: len(events)}
+	for _, event := range events {
+		suppress := event.Type == models.EmailEventComplaint || event.Permanent
+		matched, suppressed, err := s.repository.RecordEvent(ctx, event, suppress)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.Matched++
+		}
+		if suppressed {
+			result.Suppressed++
+			log.Printf("Suppressed email address after a %s: %s", event.Type, event.Diagnostic)
+		}
+	}
+	return result, nil
+}
+
+// Suppressed returns those of addresses that are no longer emailed
+func (s *EmailDeliveryService) Suppressed(ctx context.Context, addresses []string) ([]string, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	return s.repository.Suppressed(ctx, addresses)
+}
+
+// ListSuppressions returns the addresses that are no longer emailed, most recent first
+func (s *EmailDeliveryService) ListSuppressions(ctx context.Context) ([]models.EmailSuppression, error) {
+	suppressions, err := s.repository.ListSuppressions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if suppressions == nil {
+		suppressions = []models.EmailSuppression{}
+	}
+	return suppressions, nil
+}
+
+// DeleteSuppression emails an address again, e.g. once the patient confirms their mailbox works
+func (s *EmailDeliveryService) DeleteSuppression(ctx context.Context, address string) error {
+	deleted, err := s.repository.DeleteSuppression(ctx, address)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEmailSuppressionNotFound
+	}
+	return nil
+}
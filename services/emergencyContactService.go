@@ -1,20 +1,54 @@
 package services
 
 import (
+	"RoyDental/clock"
 	"RoyDental/models"
+	"RoyDental/notifications"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 )
 
+var (
+	ErrInvalidRelationship = errors.New("invalid relationship")
+	// ErrEmergencyContactNotFound is returned when the patient has no such contact, or no primary
+	// contact to text
+	ErrEmergencyContactNotFound = errors.New("emergency contact not found")
+	// ErrInvalidEmergencyContactNotice is returned for unknown templates and missing times or messages
+	ErrInvalidEmergencyContactNotice = errors.New("invalid emergency contact notice")
+	// ErrNoEmergencyContactConsent is returned when texting a contact who has not agreed to be texted
+	ErrNoEmergencyContactConsent = errors.New("the emergency contact has not agreed to be texted")
+	// ErrEmergencyContactNotSent is returned when the SMS gateway did not take the text
+	ErrEmergencyContactNotSent = errors.New("the text to the emergency contact could not be sent")
+)
+
+// EmergencyContactService keeps the people to call about a patient, and texts them from templates,
+// such as to collect the patient after sedation, once they agreed to be texted. Texts go to the
+// contact as soon as they are sent, whatever the patient's own preferences and quiet hours, and are
+// logged in the patient's communication log.
 type EmergencyContactService struct {
-	repository *repositories.EmergencyContactRepository
+	repository     *repositories.EmergencyContactRepository
+	patientRepo    *repositories.PatientRepository
+	communications *CommunicationService
+	sms            notifications.Notifier
+	clinicName     string
+	clock          clock.Clock
 }
 
-func NewEmergencyContactService(repository *repositories.EmergencyContactRepository) *EmergencyContactService {
-	return &EmergencyContactService{repository: repository}
+func NewEmergencyContactService(repository *repositories.EmergencyContactRepository, patientRepo *repositories.PatientRepository, communications *CommunicationService, sms notifications.Notifier, clinicName string, clock clock.Clock) *EmergencyContactService {
+	return &EmergencyContactService{repository: repository, patientRepo: patientRepo, communications: communications, sms: sms, clinicName: clinicName, clock: clock}
 }
 
 func (s *EmergencyContactService) Create(ctx context.Context, contact *models.EmergencyContact) error {
+	if err := validateEmergencyContact(contact); err != nil {
+		return err
+	}
+	// Consent to be texted is only recorded on its own
+	contact.SMSConsent, contact.SMSConsentAt, contact.SMSConsentBy = false, nil, nil
 	return s.repository.Create(ctx, contact)
 }
 
@@ -27,9 +61,135 @@ func (s *EmergencyContactService) GetAll(ctx context.Context) ([]models.Emergenc
 }
 
 func (s *EmergencyContactService) Update(ctx context.Context, contact *models.EmergencyContact) error {
+	if err := validateEmergencyContact(contact); err != nil {
+		return err
+	}
 	return s.repository.Update(ctx, contact)
 }
 
 func (s *EmergencyContactService) Delete(ctx context.Context, patientID string, id uint) error {
 	return s.repository.Delete(ctx, patientID, id)
 }
+
+// SetSMSConsent records whether the contact agreed to be texted about the patient, and who at the
+// clinic recorded it
+func (s *EmergencyContactService) SetSMSConsent(ctx context.Context, patientID string, id uint, consent bool) (*models.EmergencyContact, error) {
+	contact := &models.EmergencyContact{ID: id, PatientID: patientID, SMSConsent: consent}
+	if consent {
+		now := s.clock.Now()
+		contact.SMSConsentAt = &now
+		if userID, ok := models.ActorFrom(ctx); ok {
+			contact.SMSConsentBy = &userID
+		}
+	}
+	found, err := s.repository.SetSMSConsent(ctx, contact)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrEmergencyContactNotFound
+	}
+	return s.repository.GetByID(ctx, patientID, id)
+}
+
+// Templates returns the texts an emergency contact may be sent
+func (s *EmergencyContactService) Templates() []models.EmergencyContactTemplate {
+	return models.EmergencyContactTemplates
+}
+
+// Notify texts the template asked for to the patient's contact, their primary one unless another is
+// named, and logs the text in the patient's communication log whether or not it was sent
+func (s *EmergencyContactService) Notify(ctx context.Context, patientID string, notice models.EmergencyContactNotice) (*models.EmergencyContactNoticeResult, error) {
+	template, ok := models.EmergencyContactTemplateNamed(notice.Template)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown template %q", ErrInvalidEmergencyContactNotice, notice.Template)
+	}
+	notice.Time, notice.Message = strings.TrimSpace(notice.Time), strings.TrimSpace(notice.Message)
+	if template.NeedsTime {
+		if _, err := time.Parse("15:04", notice.Time); err != nil {
+			return nil, fmt.Errorf("%w: the %s template needs a time, HH:MM", ErrInvalidEmergencyContactNotice, template.Name)
+		}
+	}
+	if template.NeedsMessage && notice.Message == "" {
+		return nil, fmt.Errorf("%w: the %s template needs a message", ErrInvalidEmergencyContactNotice, template.Name)
+	}
+
+	contact, err := s.contact(ctx, patientID, notice.ContactID)
+	if err != nil {
+		return nil, err
+	}
+	if !contact.SMSConsent {
+		return nil, fmt.Errorf("%w: %s", ErrNoEmergencyContactConsent, contact.Name)
+	}
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+
+	body := strings.NewReplacer(
+		"{contact_name}", contact.Name,
+		"{patient_name}", patient.FirstName,
+		"{clinic_name}", s.clinicName,
+		"{time}", notice.Time,
+		"{message}", notice.Message,
+	).Replace(template.Body)
+	messageID := notifications.NewMessageID()
+	sendErr := s.sms.Send(ctx, notifications.Notification{
+		Recipients: []string{contact.Phone},
+		Subject:    template.Description,
+		Body:       body,
+		MessageID:  messageID,
+	})
+	entry := models.CommunicationLog{
+		PatientID: patientID,
+		Channel:   notifications.ChannelSMS,
+		Purpose:   notifications.PurposeService,
+		Recipient: contact.Phone,
+		Subject:   template.Description,
+		Body:      body,
+		MessageID: messageID,
+		Record:    "emergency_contact",
+		RecordID:  fmt.Sprint(contact.ID),
+	}
+	if err := s.communications.LogMessage(ctx, entry, sendErr); err != nil {
+		log.Printf("Failed to log the text to emergency contact %d of patient %s: %v", contact.ID, patientID, err)
+	}
+	if sendErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmergencyContactNotSent, sendErr)
+	}
+	return &models.EmergencyContactNoticeResult{ContactID: contact.ID, Recipient: contact.Phone, Body: body, Status: models.MessageSent, MessageID: messageID}, nil
+}
+
+// contact returns the patient's contact with id, or their primary contact when id is nil
+func (s *EmergencyContactService) contact(ctx context.Context, patientID string, id *uint) (*models.EmergencyContact, error) {
+	if id == nil {
+		contact, err := s.repository.GetPrimary(ctx, patientID)
+		if err != nil {
+			return nil, err
+		}
+		if contact == nil {
+			return nil, fmt.Errorf("%w: the patient has no primary emergency contact", ErrEmergencyContactNotFound)
+		}
+		return contact, nil
+	}
+	contact, err := s.repository.GetByID(ctx, patientID, *id)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil {
+		return nil, ErrEmergencyContactNotFound
+	}
+	return contact, nil
+}
+
+// validateEmergencyContact normalizes the relationship and checks it against the known ones
+func validateEmergencyContact(contact *models.EmergencyContact) error {
+	contact.Relationship = strings.ToLower(strings.TrimSpace(contact.Relationship))
+	if !models.IsValidRelationship(contact.Relationship) {
+		return fmt.Errorf("%w %q", ErrInvalidRelationship, contact.Relationship)
+	}
+	return nil
+}
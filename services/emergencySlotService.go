@@ -0,0 +1,106 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmergencySlotNotFound = errors.New("emergency slot not found")
+	ErrInvalidEmergencySlot  = errors.New("invalid emergency slot")
+)
+
+// EmergencySlotService manages the parts of the schedule held back for same-day emergencies
+type EmergencySlotService struct {
+	repository *repositories.EmergencySlotRepository
+}
+
+func NewEmergencySlotService(repository *repositories.EmergencySlotRepository) *EmergencySlotService {
+	return &EmergencySlotService{repository: repository}
+}
+
+func (s *EmergencySlotService) Create(ctx context.Context, slot *models.EmergencySlot) error {
+	if err := validateEmergencySlot(slot); err != nil {
+		return err
+	}
+	slot.ID = 0
+	return s.repository.Create(ctx, slot)
+}
+
+func (s *EmergencySlotService) Get(ctx context.Context, id uint) (*models.EmergencySlot, error) {
+	slot, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if slot == nil {
+		return nil, ErrEmergencySlotNotFound
+	}
+	return slot, nil
+}
+
+func (s *EmergencySlotService) List(ctx context.Context) ([]models.EmergencySlot, error) {
+	slots, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if slots == nil {
+		slots = []models.EmergencySlot{}
+	}
+	return slots, nil
+}
+
+func (s *EmergencySlotService) Update(ctx context.Context, slot *models.EmergencySlot) error {
+	if _, err := s.Get(ctx, slot.ID); err != nil {
+		return err
+	}
+	if err := validateEmergencySlot(slot); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, slot)
+}
+
+func (s *EmergencySlotService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+func validateEmergencySlot(slot *models.EmergencySlot) error {
+	slot.Note = strings.TrimSpace(slot.Note)
+	if slot.DoctorID != nil && strings.TrimSpace(*slot.DoctorID) == "" {
+		slot.DoctorID = nil
+	}
+	switch {
+	case slot.Date != "" && slot.Weekday != nil:
+		return fmt.Errorf("%w: give either a date or a weekday, not both", ErrInvalidEmergencySlot)
+	case slot.Date != "":
+		if _, err := models.ParseClinicDate(slot.Date); err != nil {
+			return fmt.Errorf("%w: date must be given as YYYY-MM-DD", ErrInvalidEmergencySlot)
+		}
+	case slot.Weekday != nil:
+		if *slot.Weekday < 0 || *slot.Weekday > 6 {
+			return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6 (Saturday)", ErrInvalidEmergencySlot)
+		}
+	default:
+		return fmt.Errorf("%w: a date or a weekday is required", ErrInvalidEmergencySlot)
+	}
+
+	start, err := time.Parse(models.EmergencySlotTimeLayout, slot.StartTime)
+	if err != nil {
+		return fmt.Errorf("%w: start_time must be given as HH:MM", ErrInvalidEmergencySlot)
+	}
+	end, err := time.Parse(models.EmergencySlotTimeLayout, slot.EndTime)
+	if err != nil {
+		return fmt.Errorf("%w: end_time must be given as HH:MM", ErrInvalidEmergencySlot)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("%w: end_time must be after start_time", ErrInvalidEmergencySlot)
+	}
+	return nil
+}
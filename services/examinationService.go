@@ -4,17 +4,48 @@ import (
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Findings searches list at most
+const (
+	defaultFindingSearchLimit = 100
+	maxFindingSearchLimit     = 1000
+)
+
+var (
+	// ErrInvalidFindings is returned for examination findings that do not follow their condition's schema
+	ErrInvalidFindings = errors.New("invalid examination findings")
+	// ErrInvalidFindingFilter is returned for findings searches of unknown conditions, stages or grades
+	ErrInvalidFindingFilter = errors.New("invalid findings search")
+	// ErrFindingTemplateNotFound is returned for findings searches by a template that does not exist
+	ErrFindingTemplateNotFound = errors.New("findings search template not found")
 )
 
 type ExaminationService struct {
 	repository *repositories.ExaminationRepository
+	templates  *ClinicalTemplateService
 }
 
-func NewExaminationService(repository *repositories.ExaminationRepository) *ExaminationService {
-	return &ExaminationService{repository: repository}
+func NewExaminationService(repository *repositories.ExaminationRepository, templates *ClinicalTemplateService) *ExaminationService {
+	return &ExaminationService{repository: repository, templates: templates}
 }
 
+// Create saves an examination, starting its report with the text of a template when one is given
 func (s *ExaminationService) Create(ctx context.Context, examination *models.Examination) error {
+	if err := checkFindings(examination.Findings); err != nil {
+		return err
+	}
+	if examination.TemplateID != nil {
+		report, err := s.templates.Insert(ctx, *examination.TemplateID, models.TemplateExamination, examination.Report)
+		if err != nil {
+			return err
+		}
+		examination.Report = report
+	}
 	return s.repository.Create(ctx, examination)
 }
 
@@ -27,9 +58,137 @@ func (s *ExaminationService) GetAll(ctx context.Context) ([]models.Examination,
 }
 
 func (s *ExaminationService) Update(ctx context.Context, examination *models.Examination) error {
+	if err := checkFindings(examination.Findings); err != nil {
+		return err
+	}
 	return s.repository.Update(ctx, examination)
 }
 
 func (s *ExaminationService) Delete(ctx context.Context, id uint) error {
 	return s.repository.Delete(ctx, id)
 }
+
+// SearchFindings returns the examinations with a finding matching filter, latest first, and how
+// many patients they are of
+func (s *ExaminationService) SearchFindings(ctx context.Context, filter models.FindingFilter) (*models.FindingReport, error) {
+	if err := checkFindingFilter(&filter); err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	matches, err := s.repository.SearchFindings(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	filter.Limit = limit
+	patients, err := s.repository.CountFindingPatients(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.FindingReport{Filter: filter, Patients: patients, Matches: []models.FindingMatch{}}
+	if len(matches) > limit {
+		matches, report.Truncated = matches[:limit], true
+	}
+	for _, match := range matches {
+		findings := models.ExaminationFindings{}
+		for _, finding := range match.Findings {
+			if matchesFinding(finding, filter) {
+				findings = append(findings, finding)
+			}
+		}
+		match.Findings = findings
+		report.Matches = append(report.Matches, match)
+	}
+	return report, nil
+}
+
+// RunFindingTemplate runs the findings search of the template called name over its period up to now
+func (s *ExaminationService) RunFindingTemplate(ctx context.Context, name string, limit int) (*models.FindingReport, error) {
+	template := models.FindingQueryTemplateNamed(name)
+	if template == nil {
+		return nil, fmt.Errorf("%w: %q", ErrFindingTemplateNotFound, name)
+	}
+	filter := template.Filter
+	from := startOfDay(time.Now()).AddDate(0, 0, -template.Days)
+	filter.From, filter.Limit = &from, limit
+	report, err := s.SearchFindings(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	report.Template = template.Name
+	return report, nil
+}
+
+// checkFindings checks each finding against the schema of its condition
+func checkFindings(findings models.ExaminationFindings) error {
+	for i, finding := range findings {
+		schema := models.FindingSchemaOf(finding.Condition)
+		if schema == nil {
+			return fmt.Errorf("%w: finding %d is of unknown condition %q", ErrInvalidFindings, i+1, finding.Condition)
+		}
+		for _, attribute := range []struct {
+			name, value string
+			allowed     []string
+		}{
+			{"stage", finding.Stage, schema.Stages},
+			{"grade", finding.Grade, schema.Grades},
+			{"extent", finding.Extent, schema.Extents},
+		} {
+			if attribute.value != "" && !slices.Contains(attribute.allowed, attribute.value) {
+				return fmt.Errorf("%w: %s %s of finding %d is not one of %v", ErrInvalidFindings, finding.Condition, attribute.name, i+1, attribute.allowed)
+			}
+		}
+		if schema.TeethRequired && len(finding.Teeth) == 0 {
+			return fmt.Errorf("%w: finding %d of %s names no teeth", ErrInvalidFindings, i+1, finding.Condition)
+		}
+		for _, tooth := range finding.Teeth {
+			if !models.IsValidFDITooth(tooth) {
+				return fmt.Errorf("%w: finding %d names tooth %d, which is not an FDI tooth number", ErrInvalidFindings, i+1, tooth)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFindingFilter checks a findings search against the schema of its condition and bounds its limit
+func checkFindingFilter(filter *models.FindingFilter) error {
+	schema := models.FindingSchemaOf(filter.Condition)
+	if schema == nil {
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidFindingFilter, filter.Condition)
+	}
+	for _, stage := range filter.Stages {
+		if !slices.Contains(schema.Stages, stage) {
+			return fmt.Errorf("%w: %s stage is not one of %v", ErrInvalidFindingFilter, filter.Condition, schema.Stages)
+		}
+	}
+	for _, grade := range filter.Grades {
+		if !slices.Contains(schema.Grades, grade) {
+			return fmt.Errorf("%w: %s grade is not one of %v", ErrInvalidFindingFilter, filter.Condition, schema.Grades)
+		}
+	}
+	if filter.Extent != "" && !slices.Contains(schema.Extents, filter.Extent) {
+		return fmt.Errorf("%w: %s extent is not one of %v", ErrInvalidFindingFilter, filter.Condition, schema.Extents)
+	}
+	if filter.Tooth != 0 && !models.IsValidFDITooth(filter.Tooth) {
+		return fmt.Errorf("%w: %d is not an FDI tooth number", ErrInvalidFindingFilter, filter.Tooth)
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return fmt.Errorf("%w: to must not be before from", ErrInvalidFindingFilter)
+	}
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = defaultFindingSearchLimit
+	case filter.Limit > maxFindingSearchLimit:
+		filter.Limit = maxFindingSearchLimit
+	}
+	return nil
+}
+
+// matchesFinding reports whether finding is one filter searches for
+func matchesFinding(finding models.Finding, filter models.FindingFilter) bool {
+	return finding.Condition == filter.Condition &&
+		(len(filter.Stages) == 0 || slices.Contains(filter.Stages, finding.Stage)) &&
+		(len(filter.Grades) == 0 || slices.Contains(filter.Grades, finding.Grade)) &&
+		(filter.Extent == "" || finding.Extent == filter.Extent) &&
+		(filter.Tooth == 0 || slices.Contains(finding.Teeth, filter.Tooth))
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// exportQueueSize bounds the jobs handed straight to the worker; the rest wait for the next poll.
+	exportQueueSize = 64
+	// exportPollInterval is how often the worker looks for jobs queued by other replicas or before a restart.
+	exportPollInterval = time.Minute
+	// exportTimeout bounds generating a single file.
+	exportTimeout = 5 * time.Minute
+	// maxExportRange caps the period a single export covers.
+	maxExportRange = 366 * 24 * time.Hour
+	// exportListLimit is how many recent jobs are listed.
+	exportListLimit = 100
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+	ErrExportNotReady = errors.New("export is not ready")
+)
+
+// ExportFile is a generated export ready for download
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// exporter generates the file of a job
+type exporter func(ctx context.Context, job models.ExportJob) (*ExportFile, error)
+
+type ExportService struct {
+	repository *repositories.ExportRepository
+	exporters  map[string]map[string]exporter
+	queue      chan uint
+	files      *Files
+}
+
+// NewExportService starts the background worker generating requested exports.
+func NewExportService(repository *repositories.ExportRepository, accountingCfg config.AccountingConfig, controlledCfg config.ControlledSubstanceConfig, files *Files) *ExportService {
+	accounting := &AccountingExporter{repository: repository, config: accountingCfg}
+	register := &ControlledRegisterExporter{repository: repository, config: controlledCfg}
+	s := &ExportService{
+		repository: repository,
+		files:      files,
+		exporters: map[string]map[string]exporter{
+			models.ExportTypeAccounting: {
+				models.ExportFormatQuickBooksIIF: accounting.QuickBooksIIF,
+				models.ExportFormatXeroCSV:       accounting.XeroCSV,
+			},
+			models.ExportTypeControlledSubstances: {
+				models.ExportFormatRegulatorCSV: register.RegulatorCSV,
+			},
+		},
+		queue: make(chan uint, exportQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// Request queues an export of the days from job.From to job.To inclusive
+func (s *ExportService) Request(ctx context.Context, job *models.ExportJob) error {
+	formats, ok := s.exporters[job.Type]
+	if !ok {
+		return fmt.Errorf("unknown export type %q", job.Type)
+	}
+	if _, ok := formats[job.Format]; !ok {
+		return fmt.Errorf("unknown %s export format %q", job.Type, job.Format)
+	}
+	if job.To.Before(job.From) {
+		return errors.New("to must not be before from")
+	}
+	if job.To.Sub(job.From) > maxExportRange {
+		return errors.New("exports cover at most a year")
+	}
+
+	job.ID = 0
+	job.Status = models.ExportStatusPending
+	if err := s.repository.Create(ctx, job); err != nil {
+		return err
+	}
+	select {
+	case s.queue <- job.ID:
+	default:
+	}
+	return nil
+}
+
+func (s *ExportService) GetExport(ctx context.Context, id uint) (*models.ExportJob, error) {
+	job, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrExportNotFound
+	}
+	return job, nil
+}
+
+func (s *ExportService) ListExports(ctx context.Context) ([]models.ExportJob, error) {
+	return s.repository.List(ctx, exportListLimit)
+}
+
+// Download returns the generated file of a finished job
+func (s *ExportService) Download(ctx context.Context, id uint) (*ExportFile, error) {
+	job, err := s.repository.GetWithContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrExportNotFound
+	}
+	if job.Status != models.ExportStatusDone {
+		return nil, ErrExportNotReady
+	}
+	content := job.Content
+	if job.StorageKey != "" {
+		if content, err = s.files.Get(ctx, storage.Exports, job.StorageKey); err != nil {
+			return nil, err
+		}
+	}
+	return &ExportFile{Name: job.FileName, ContentType: job.ContentType, Content: content}, nil
+}
+
+func (s *ExportService) run() {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+
+	s.processPending()
+	for {
+		select {
+		case id := <-s.queue:
+			s.process(id)
+		case <-ticker.C:
+			s.processPending()
+		}
+	}
+}
+
+func (s *ExportService) processPending() {
+	ids, err := s.repository.PendingIDs(context.Background())
+	if err != nil {
+		log.Printf("Failed to look for pending exports: %v", err)
+		return
+	}
+	for _, id := range ids {
+		s.process(id)
+	}
+}
+
+// process generates the file of job id unless another worker already took it
+func (s *ExportService) process(id uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	claimed, err := s.repository.Claim(ctx, id)
+	if err != nil {
+		log.Printf("Failed to claim export %d: %v", id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	job, err := s.repository.GetByID(ctx, id)
+	if err != nil || job == nil {
+		log.Printf("Failed to load export %d: %v", id, err)
+		return
+	}
+
+	generate, ok := s.exporters[job.Type][job.Format]
+	if !ok {
+		err = fmt.Errorf("unknown %s export format %q", job.Type, job.Format)
+	}
+	var file *ExportFile
+	if err == nil {
+		file, err = generate(ctx, *job)
+	}
+	if err != nil {
+		log.Printf("Export %d failed: %v", id, err)
+		if err := s.repository.Fail(context.Background(), id, err.Error()); err != nil {
+			log.Printf("Failed to record export %d failure: %v", id, err)
+		}
+		return
+	}
+	content, storageKey := file.Content, ""
+	if s.files.External() {
+		storageKey = fmt.Sprintf("exports/%d/%s", id, file.Name)
+		if err := s.files.Put(ctx, storage.Exports, storageKey, file.Content, file.ContentType); err != nil {
+			log.Printf("Failed to store export %d: %v", id, err)
+			if err := s.repository.Fail(context.Background(), id, err.Error()); err != nil {
+				log.Printf("Failed to record export %d failure: %v", id, err)
+			}
+			return
+		}
+		content = nil
+	}
+	if err := s.repository.Complete(ctx, id, file.Name, file.ContentType, content, storageKey); err != nil {
+		log.Printf("Failed to store export %d: %v", id, err)
+	}
+}
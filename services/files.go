@@ -0,0 +1,66 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/storage"
+	"context"
+	"errors"
+	"log"
+)
+
+// errNoFileStore is returned for records whose file is in object storage when none is configured
+var errNoFileStore = errors.New("the file is in object storage, which is not configured")
+
+// Files keeps the files of attachments, exports and archives in object storage when a store is
+// configured, and leaves them in the database otherwise, as they always were. Records whose file is
+// in the store keep its key; those saved before it was configured keep their file in the database.
+type Files struct {
+	store storage.Store
+}
+
+func NewFiles(store storage.Store) *Files {
+	return &Files{store: store}
+}
+
+// External reports whether new files go to object storage
+func (f *Files) External() bool {
+	return f.store != nil
+}
+
+func (f *Files) Put(ctx context.Context, bucket, key string, content []byte, contentType string) error {
+	if f.store == nil {
+		return errNoFileStore
+	}
+	return f.store.Put(ctx, bucket, key, content, contentType)
+}
+
+func (f *Files) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	if f.store == nil {
+		return nil, errNoFileStore
+	}
+	return f.store.Get(ctx, bucket, key)
+}
+
+// Delete removes a file no record refers to anymore; failing to only leaves it behind, so it is
+// logged
+func (f *Files) Delete(ctx context.Context, bucket, key string) {
+	if f.store == nil || key == "" {
+		return
+	}
+	if err := f.store.Delete(ctx, bucket, key); err != nil {
+		log.Printf("Failed to delete %s/%s from storage: %v", bucket, key, err)
+	}
+}
+
+// LoadAttachment reads the file of an attachment kept in object storage into its Content
+func (f *Files) LoadAttachment(ctx context.Context, attachment *models.ExaminationAttachment) error {
+	if attachment.StorageKey == "" {
+		return nil
+	}
+	content, err := f.Get(ctx, storage.BucketFor(attachment.ContentType), attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	attachment.Content = content
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidPeriod       = errors.New("invalid financial period")
+	ErrPeriodAlreadyClosed = errors.New("financial period is already closed")
+)
+
+type FinancialPeriodService struct {
+	repository *repositories.FinancialPeriodRepository
+	clock      clock.Clock
+}
+
+func NewFinancialPeriodService(repository *repositories.FinancialPeriodRepository, clock clock.Clock) *FinancialPeriodService {
+	return &FinancialPeriodService{repository: repository, clock: clock}
+}
+
+// Close closes the books of a month (YYYY-MM) that has ended
+func (s *FinancialPeriodService) Close(ctx context.Context, period string, userID int64) (*models.FinancialPeriod, error) {
+	month, err := time.ParseInLocation(models.FinancialPeriodLayout, period, models.ClinicLocation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: expected YYYY-MM", ErrInvalidPeriod)
+	}
+	now := s.clock.Now()
+	if month.AddDate(0, 1, 0).After(now) {
+		return nil, fmt.Errorf("%w: %s has not ended yet", ErrInvalidPeriod, period)
+	}
+
+	closed := &models.FinancialPeriod{
+		Period:   models.FinancialPeriodOf(month),
+		ClosedAt: now,
+		ClosedBy: &userID,
+	}
+	if err := s.repository.Close(ctx, closed); err != nil {
+		if errors.Is(err, repositories.ErrPeriodAlreadyClosed) {
+			return nil, ErrPeriodAlreadyClosed
+		}
+		return nil, err
+	}
+	return closed, nil
+}
+
+func (s *FinancialPeriodService) List(ctx context.Context) ([]models.FinancialPeriod, error) {
+	periods, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if periods == nil {
+		periods = []models.FinancialPeriod{}
+	}
+	return periods, nil
+}
+
+// ListAdjustments returns the adjustments made to a bill after its period was closed
+func (s *FinancialPeriodService) ListAdjustments(ctx context.Context, billingID string) ([]models.BillingAdjustment, error) {
+	adjustments, err := s.repository.ListAdjustments(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if adjustments == nil {
+		adjustments = []models.BillingAdjustment{}
+	}
+	return adjustments, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/geocoding"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// GeocodingService places patients' addresses on the map in the background, looking up new and
+// changed addresses one at a time so the provider's rate limit is respected. Addresses the
+// provider cannot place are marked as looked up without coordinates and not tried again.
+type GeocodingService struct {
+	provider    geocoding.Provider
+	patientRepo *repositories.PatientRepository
+	config      config.GeocodingConfig
+}
+
+// NewGeocodingService starts the geocoding job when a provider is configured.
+func NewGeocodingService(patientRepo *repositories.PatientRepository, cfg config.GeocodingConfig) *GeocodingService {
+	provider, err := geocoding.New(cfg)
+	if err != nil {
+		log.Printf("Geocoding disabled: %v", err)
+	}
+	s := &GeocodingService{provider: provider, patientRepo: patientRepo, config: cfg}
+	if provider != nil {
+		go s.run()
+	}
+	return s
+}
+
+func (s *GeocodingService) run() {
+	for {
+		if database.IsLeader() {
+			if err := s.geocodePending(context.Background()); err != nil {
+				log.Printf("Failed to geocode addresses: %v", err)
+			}
+		}
+		time.Sleep(s.config.PollInterval)
+	}
+}
+
+// geocodePending looks up the addresses not placed yet. Lookups that fail for other reasons than
+// the address not being found are retried on the next poll.
+func (s *GeocodingService) geocodePending(ctx context.Context) error {
+	patients, err := s.patientRepo.PendingGeocoding(ctx, s.config.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i, patient := range patients {
+		if i > 0 {
+			time.Sleep(s.config.RequestInterval)
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+		coordinates, err := s.provider.Geocode(lookupCtx, patient.Address)
+		cancel()
+
+		now := time.Now()
+		location := models.GeoLocation{GeocodedAt: &now}
+		switch {
+		case errors.Is(err, geocoding.ErrNotFound):
+		case err != nil:
+			log.Printf("Failed to geocode the address of patient %s: %v", patient.ID, err)
+			continue
+		default:
+			location.Latitude, location.Longitude = &coordinates.Latitude, &coordinates.Longitude
+		}
+		if err := s.patientRepo.SaveLocation(ctx, patient.ID, patient.Address, location); err != nil {
+			log.Printf("Failed to save the location of patient %s: %v", patient.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// greetingBatchSize caps the greetings sent per dispatch run.
+const greetingBatchSize = 200
+
+// ErrInvalidGreetingQuery is returned for send log queries with malformed dates
+var ErrInvalidGreetingQuery = errors.New("invalid greeting query")
+
+// GreetingService emails patients a greeting on their birthday while the birthday_greetings
+// setting is on. Greetings are marketing, so only patients who opted in to it are greeted.
+type GreetingService struct {
+	repository *repositories.GreetingRepository
+	settings   *SettingService
+	notifier   notifications.Notifier
+	config     config.GreetingConfig
+	templates  map[string]greetingTemplates
+}
+
+type greetingTemplates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// greetingData is what the greeting templates are given
+type greetingData struct {
+	FirstName string
+	LastName  string
+	Age       int
+}
+
+// NewGreetingService starts sending greetings in the background, unless the templates do not parse.
+func NewGreetingService(repository *repositories.GreetingRepository, settings *SettingService, notifier notifications.Notifier, cfg config.GreetingConfig) *GreetingService {
+	s := &GreetingService{repository: repository, settings: settings, notifier: notifier, config: cfg}
+	templates, err := parseGreetingTemplates(cfg)
+	if err != nil {
+		log.Printf("Birthday greetings disabled: %v", err)
+		return s
+	}
+	s.templates = templates
+	go s.run()
+	return s
+}
+
+func parseGreetingTemplates(cfg config.GreetingConfig) (map[string]greetingTemplates, error) {
+	sources := map[string][2]string{
+		models.GreetingBirthday:  {cfg.Subject, cfg.Body},
+		models.GreetingMilestone: {cfg.MilestoneSubject, cfg.MilestoneBody},
+	}
+	templates := map[string]greetingTemplates{}
+	for kind, source := range sources {
+		subject, err := template.New(kind + " subject").Parse(source[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s greeting subject: %w", kind, err)
+		}
+		body, err := template.New(kind + " body").Parse(source[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s greeting body: %w", kind, err)
+		}
+		templates[kind] = greetingTemplates{subject: subject, body: body}
+	}
+	return templates, nil
+}
+
+func (s *GreetingService) run() {
+	for {
+		now := models.ClinicNow()
+		if database.IsLeader() && s.settings.BirthdayGreetings() && now.Hour() >= s.config.SendHour {
+			s.dispatch(context.Background(), now)
+		}
+		time.Sleep(s.config.DispatchInterval)
+	}
+}
+
+// dispatch greets the patients whose birthday is today. Like surveys, the greeting is recorded
+// before sending so replicas never greet a patient twice on the same day.
+func (s *GreetingService) dispatch(ctx context.Context, now time.Time) {
+	day, _ := models.ClinicDay(now)
+	candidates, err := s.repository.BirthdaysOn(ctx, day, greetingBatchSize)
+	if err != nil {
+		log.Printf("Failed to find birthdays to greet: %v", err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		age, ok := birthdayAge(candidate.DateOfBirth, day)
+		if !ok {
+			continue
+		}
+		kind := models.GreetingBirthday
+		if slices.Contains(s.config.MilestoneAges, age) {
+			kind = models.GreetingMilestone
+		}
+		subject, body, err := s.render(kind, greetingData{FirstName: candidate.FirstName, LastName: candidate.LastName, Age: age})
+		if err != nil {
+			log.Printf("Failed to render the greeting of patient %s: %v", candidate.PatientID, err)
+			continue
+		}
+
+		greeting := &models.Greeting{
+			PatientID: candidate.PatientID,
+			Day:       day.Format("2006-01-02"),
+			Kind:      kind,
+			Age:       age,
+			Channel:   notifications.ChannelEmail,
+			Status:    models.GreetingSent,
+			SentAt:    time.Now(),
+		}
+		created, err := s.repository.Create(ctx, greeting)
+		if err != nil {
+			log.Printf("Failed to record the greeting of patient %s: %v", candidate.PatientID, err)
+			continue
+		}
+		if !created {
+			continue
+		}
+
+		notification := notifications.Notification{
+			Recipients: []string{candidate.Email},
+			PatientID:  candidate.PatientID,
+			Purpose:    notifications.PurposeMarketing,
+			Subject:    subject,
+			Body:       body,
+		}
+		if err := s.notifier.Send(ctx, notification); errors.Is(err, notifications.ErrNotPermitted) {
+			// The patient opted out after being picked, so the greeting is logged as skipped and not tried again
+			if err := s.repository.SetStatus(ctx, greeting.ID, models.GreetingSkipped); err != nil {
+				log.Printf("Failed to mark the greeting of patient %s skipped: %v", candidate.PatientID, err)
+			}
+		} else if err != nil && !errors.Is(err, notifications.ErrDeferred) {
+			log.Printf("Failed to send the greeting of patient %s: %v", candidate.PatientID, err)
+			if err := s.repository.Delete(ctx, greeting.ID); err != nil {
+				log.Printf("Failed to release the greeting of patient %s: %v", candidate.PatientID, err)
+			}
+		}
+	}
+}
+
+func (s *GreetingService) render(kind string, data greetingData) (string, string, error) {
+	var subject, body strings.Builder
+	if err := s.templates[kind].subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := s.templates[kind].body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+// birthdayAge returns the age someone born on dateOfBirth turns on day, and false when day is not
+// their birthday. Those born on 29 February celebrate on 28 February in other years.
+func birthdayAge(dateOfBirth string, day time.Time) (int, bool) {
+	born, ok := parseDateOfBirth(dateOfBirth)
+	if !ok {
+		return 0, false
+	}
+	leapling := born.Month() == time.February && born.Day() == 29 &&
+		day.Month() == time.February && day.Day() == 28 && !isLeapYear(day.Year())
+	if !leapling && (born.Month() != day.Month() || born.Day() != day.Day()) {
+		return 0, false
+	}
+	if leapling {
+		day = day.AddDate(0, 0, 1)
+	}
+	age := ageOn(born, day)
+	return age, age > 0
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// List returns the send log from day from through day to (YYYY-MM-DD, both optional)
+func (s *GreetingService) List(ctx context.Context, from, to string) ([]models.GreetingLogEntry, error) {
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if _, err := models.ParseClinicDate(value); err != nil {
+			return nil, fmt.Errorf("%w: dates must be given as YYYY-MM-DD", ErrInvalidGreetingQuery)
+		}
+	}
+	entries, err := s.repository.List(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.GreetingLogEntry{}
+	}
+	return entries, nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrHandoverNotFound is returned for handover notes that do not exist
+	ErrHandoverNotFound = errors.New("handover note not found")
+	// ErrInvalidHandover is returned for notes and huddles without a branch, with unreadable days,
+	// unknown kinds or patients, and for lab work without the day it is due back
+	ErrInvalidHandover = errors.New("invalid handover note")
+)
+
+// HandoverService keeps the notes clinicians hand over to the next shift at a branch, such as
+// patients to watch and lab work pending, records which doctors read them, and puts together the
+// morning huddle of a clinic day.
+type HandoverService struct {
+	repository      *repositories.HandoverRepository
+	doctorAppRepo   *repositories.DoctorAppRepository
+	patientRepo     *repositories.PatientRepository
+	appointmentRepo *repositories.AppointmentRepository
+	clock           clock.Clock
+}
+
+func NewHandoverService(repository *repositories.HandoverRepository, doctorAppRepo *repositories.DoctorAppRepository, patientRepo *repositories.PatientRepository, appointmentRepo *repositories.AppointmentRepository, clock clock.Clock) *HandoverService {
+	return &HandoverService{repository: repository, doctorAppRepo: doctorAppRepo, patientRepo: patientRepo, appointmentRepo: appointmentRepo, clock: clock}
+}
+
+// Create hands a note over to the branch, for today when no date is given
+func (s *HandoverService) Create(ctx context.Context, note *models.HandoverNote) error {
+	note.ID = 0
+	if err := s.validate(ctx, note); err != nil {
+		return err
+	}
+	if err := s.repository.Create(ctx, note); err != nil {
+		return err
+	}
+	note.Reads = []models.HandoverRead{}
+	return nil
+}
+
+func (s *HandoverService) Get(ctx context.Context, id uint) (*models.HandoverNote, error) {
+	note, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if note == nil {
+		return nil, ErrHandoverNotFound
+	}
+	return note, nil
+}
+
+// List returns the notes handed over to the branch for the day, today when date is empty
+func (s *HandoverService) List(ctx context.Context, branch, date string) ([]models.HandoverNote, error) {
+	branch, date, _, err := s.day(branch, date)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.repository.List(ctx, branch, date)
+	if err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []models.HandoverNote{}
+	}
+	return notes, nil
+}
+
+// Update changes a note. Its read receipts are kept, so doctors who read it before are not asked
+// to again.
+func (s *HandoverService) Update(ctx context.Context, id uint, update models.HandoverNote) (*models.HandoverNote, error) {
+	note, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	note.Date, note.Branch, note.Kind = update.Date, update.Branch, update.Kind
+	note.PatientID, note.Body, note.ExpectedOn = update.PatientID, update.Body, update.ExpectedOn
+	if err := s.validate(ctx, note); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *HandoverService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// MarkRead records that the doctor linked to the signed-in user read the note
+func (s *HandoverService) MarkRead(ctx context.Context, userID int64, id uint) (*models.HandoverNote, error) {
+	doctor, err := s.doctorAppRepo.GetDoctorByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotLinked
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repository.MarkRead(ctx, &models.HandoverRead{NoteID: id, DoctorID: doctor.ID, ReadAt: s.clock.Now()}); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Huddle puts together the morning huddle of the branch for the day, today when date is empty: the
+// notes handed over to it, the clinic's appointments and the lab work due back. Unread counts the
+// notes the signed-in user has not read when they are a doctor.
+func (s *HandoverService) Huddle(ctx context.Context, userID int64, branch, date string) (*models.MorningHuddle, error) {
+	branch, date, day, err := s.day(branch, date)
+	if err != nil {
+		return nil, err
+	}
+	handovers, err := s.List(ctx, branch, date)
+	if err != nil {
+		return nil, err
+	}
+	labArrivals, err := s.repository.LabArrivals(ctx, branch, date)
+	if err != nil {
+		return nil, err
+	}
+	if labArrivals == nil {
+		labArrivals = []models.HandoverNote{}
+	}
+	appointments, err := s.appointmentRepo.GetQueue(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	if appointments == nil {
+		appointments = []models.QueueEntry{}
+	}
+	sort.SliceStable(appointments, func(i, j int) bool { return appointments[i].DateTime < appointments[j].DateTime })
+
+	huddle := &models.MorningHuddle{Date: date, Branch: branch, Handovers: handovers, Appointments: appointments, LabArrivals: labArrivals}
+	doctor, err := s.doctorAppRepo.GetDoctorByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor != nil {
+		for _, note := range handovers {
+			if !readBy(note, doctor.ID) {
+				huddle.Unread++
+			}
+		}
+	}
+	return huddle, nil
+}
+
+// day checks the branch and the clinic day asked for, today when date is empty, and returns the
+// day's start
+func (s *HandoverService) day(branch, date string) (string, string, time.Time, error) {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		return "", "", time.Time{}, fmt.Errorf("%w: branch is required", ErrInvalidHandover)
+	}
+	if date == "" {
+		date = s.clock.Now().In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+	}
+	day, err := models.ParseClinicDate(date)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidHandover)
+	}
+	return branch, date, day, nil
+}
+
+// validate normalizes a note and checks its branch, day, kind and patient, and that lab work says
+// when it is due back
+func (s *HandoverService) validate(ctx context.Context, note *models.HandoverNote) error {
+	branch, date, _, err := s.day(note.Branch, note.Date)
+	if err != nil {
+		return err
+	}
+	note.Branch, note.Date = branch, date
+	if note.Kind == "" {
+		note.Kind = models.HandoverKindConcern
+	}
+	if !models.IsValidHandoverKind(note.Kind) {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidHandover, note.Kind)
+	}
+	note.Body = strings.TrimSpace(note.Body)
+	if note.Body == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidHandover)
+	}
+	if note.Kind == models.HandoverKindLabWork {
+		if note.ExpectedOn == nil {
+			return fmt.Errorf("%w: lab work needs the day it is expected back", ErrInvalidHandover)
+		}
+		if _, err := models.ParseClinicDate(*note.ExpectedOn); err != nil {
+			return fmt.Errorf("%w: expected_on must be YYYY-MM-DD", ErrInvalidHandover)
+		}
+	} else {
+		note.ExpectedOn = nil
+	}
+	if note.PatientID != nil && *note.PatientID == "" {
+		note.PatientID = nil
+	}
+	if note.Kind == models.HandoverKindPatient && note.PatientID == nil {
+		return fmt.Errorf("%w: a patient to watch needs the patient_id", ErrInvalidHandover)
+	}
+	if note.PatientID != nil {
+		patient, err := s.patientRepo.GetByID(ctx, *note.PatientID)
+		if err != nil {
+			return err
+		}
+		if patient == nil {
+			return fmt.Errorf("%w: patient %q does not exist", ErrInvalidHandover, *note.PatientID)
+		}
+	}
+	return nil
+}
+
+// readBy reports whether the doctor read the note
+func readBy(note models.HandoverNote, doctorID string) bool {
+	for _, read := range note.Reads {
+		if read.DoctorID == doctorID {
+			return true
+		}
+	}
+	return false
+}
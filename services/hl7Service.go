@@ -0,0 +1,380 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/hl7"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// hl7MessageTimeout bounds processing one message received over MLLP.
+const hl7MessageTimeout = 30 * time.Second
+
+// hl7PatientEvents are the ADT trigger events carrying patient demographics
+var hl7PatientEvents = map[string]bool{
+	"A01": true, // admit
+	"A04": true, // register
+	"A05": true, // pre-admit
+	"A08": true, // update patient information
+	"A28": true, // add person information
+	"A31": true, // update person information
+}
+
+// hl7CreatingEvents may register a patient we do not know yet; updates for unknown patients are reviewed
+var hl7CreatingEvents = map[string]bool{"A01": true, "A04": true, "A05": true, "A28": true}
+
+var (
+	ErrHL7MessageNotFound = errors.New("HL7 message not found")
+	ErrHL7NotPending      = errors.New("HL7 message is not pending review")
+)
+
+// HL7Service applies the partner hospital's ADT messages to patient demographics. Messages that
+// cannot be matched to a patient with confidence are queued for staff review instead.
+type HL7Service struct {
+	repository     *repositories.HL7Repository
+	patientService *PatientService
+	cfg            config.HL7Config
+	facilities     map[string]bool
+}
+
+// NewHL7Service starts the MLLP listener when an address is configured.
+func NewHL7Service(repository *repositories.HL7Repository, patientService *PatientService, cfg config.HL7Config) *HL7Service {
+	s := &HL7Service{repository: repository, patientService: patientService, cfg: cfg, facilities: map[string]bool{}}
+	for _, facility := range cfg.SendingFacilities {
+		s.facilities[strings.ToUpper(facility)] = true
+	}
+	if cfg.MLLPAddress != "" {
+		go s.listenMLLP()
+	}
+	return s
+}
+
+// HTTPEnabled reports whether the hospital has keys to post messages over HTTP
+func (s *HL7Service) HTTPEnabled() bool {
+	return len(s.cfg.APIKeys) > 0
+}
+
+// Receive processes a raw ADT message and returns the acknowledgement to send back
+func (s *HL7Service) Receive(ctx context.Context, raw string) string {
+	msg, err := hl7.Parse(raw)
+	if err != nil {
+		return s.ack(nil, hl7.AckReject, err.Error())
+	}
+	messageType, event := msg.Type()
+	facility := msg.Get("MSH-4")
+	if messageType != "ADT" {
+		return s.ack(msg, hl7.AckReject, "only ADT messages are accepted")
+	}
+	if len(s.facilities) > 0 && !s.facilities[strings.ToUpper(facility)] {
+		return s.ack(msg, hl7.AckReject, "unknown sending facility")
+	}
+	if msg.ControlID() == "" {
+		return s.ack(msg, hl7.AckReject, "missing message control ID")
+	}
+
+	// A resent message gets the answer it got the first time
+	previous, err := s.repository.GetMessageByControlID(ctx, facility, msg.ControlID())
+	if err != nil {
+		return s.ack(msg, hl7.AckError, "temporary failure, please resend")
+	}
+	if previous != nil {
+		return s.ack(msg, previous.Ack, previous.Reason)
+	}
+
+	record := &models.HL7Message{
+		Facility:   facility,
+		ControlID:  msg.ControlID(),
+		Event:      event,
+		ExternalID: msg.Get("PID-3.1"),
+		Raw:        raw,
+	}
+	record.Demographics = hl7Demographics(msg)
+	s.process(ctx, record)
+
+	if err := s.repository.CreateMessage(ctx, record); err != nil {
+		log.Printf("Failed to store HL7 message %s: %v", record.ControlID, err)
+		return s.ack(msg, hl7.AckError, "temporary failure, please resend")
+	}
+	return s.ack(msg, record.Ack, record.Reason)
+}
+
+// process decides what a message does and applies it, recording the outcome on record
+func (s *HL7Service) process(ctx context.Context, record *models.HL7Message) {
+	record.Ack = hl7.AckAccept
+	if !hl7PatientEvents[record.Event] {
+		record.Status = models.HL7StatusIgnored
+		record.Reason = fmt.Sprintf("event %s does not carry patient demographics", record.Event)
+		return
+	}
+	d := record.Demographics
+	if record.ExternalID == "" || d.LastName == "" || d.FirstName == "" || d.DateOfBirth == "" {
+		record.Status = models.HL7StatusRejected
+		record.Ack = hl7.AckError
+		record.Reason = "PID-3, PID-5 and PID-7 are required"
+		return
+	}
+
+	fail := func(err error) {
+		log.Printf("Failed to apply HL7 message %s: %v", record.ControlID, err)
+		record.Status = models.HL7StatusPendingReview
+		record.Reason = "could not be applied automatically: " + err.Error()
+	}
+
+	patientID, err := s.repository.GetLink(ctx, record.Facility, record.ExternalID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if patientID != "" {
+		patient, err := s.patientService.GetByID(ctx, patientID)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if patient == nil {
+			record.Status = models.HL7StatusPendingReview
+			record.Reason = fmt.Sprintf("linked patient %s no longer exists", patientID)
+			return
+		}
+		// A different birth date or sex suggests the hospital merged or mistyped a record
+		if patient.DateOfBirth != d.DateOfBirth || (d.Sex != "Other" && patient.Sex != d.Sex) {
+			record.PatientID = &patientID
+			record.Status = models.HL7StatusPendingReview
+			record.Reason = fmt.Sprintf("date of birth or sex differs from patient %s", patientID)
+			return
+		}
+		if err := s.applyDemographics(ctx, patient, d); err != nil {
+			fail(err)
+			return
+		}
+		record.PatientID = &patientID
+		record.Status = models.HL7StatusApplied
+		return
+	}
+
+	candidates, err := s.repository.FindCandidates(ctx, d.LastName, d.DateOfBirth)
+	if err != nil {
+		fail(err)
+		return
+	}
+	switch {
+	case len(candidates) > 0:
+		record.Status = models.HL7StatusPendingReview
+		record.Reason = "possible existing patient: " + strings.Join(candidates, ", ")
+	case !hl7CreatingEvents[record.Event]:
+		record.Status = models.HL7StatusPendingReview
+		record.Reason = "update for a patient we have no record of"
+	default:
+		id, err := s.createPatient(ctx, record.Facility, record.ExternalID, d)
+		if err != nil {
+			fail(err)
+			return
+		}
+		record.PatientID = &id
+		record.Status = models.HL7StatusApplied
+	}
+}
+
+// ListMessages returns received messages, such as the ones pending review
+func (s *HL7Service) ListMessages(ctx context.Context, status string) ([]models.HL7Message, error) {
+	messages, err := s.repository.ListMessages(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []models.HL7Message{}
+	}
+	return messages, nil
+}
+
+// Apply resolves a pending message by linking it to patientID and updating that patient, or by
+// registering a new patient when patientID is empty
+func (s *HL7Service) Apply(ctx context.Context, id uint, patientID string, reviewer int64) (*models.HL7Message, error) {
+	record, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if patientID == "" {
+		if patientID, err = s.createPatient(ctx, record.Facility, record.ExternalID, record.Demographics); err != nil {
+			return nil, err
+		}
+	} else {
+		patient, err := s.patientService.GetByID(ctx, patientID)
+		if err != nil {
+			return nil, err
+		}
+		if patient == nil {
+			return nil, ErrPatientNotFound
+		}
+		if err := s.repository.SaveLink(ctx, record.Facility, record.ExternalID, patientID); err != nil {
+			return nil, err
+		}
+		if err := s.applyDemographics(ctx, patient, record.Demographics); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repository.ResolveMessage(ctx, id, models.HL7StatusApplied, &patientID, reviewer); err != nil {
+		return nil, err
+	}
+	return s.repository.GetMessage(ctx, id)
+}
+
+// Reject resolves a pending message without changing any patient
+func (s *HL7Service) Reject(ctx context.Context, id uint, reviewer int64) (*models.HL7Message, error) {
+	record, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.ResolveMessage(ctx, id, models.HL7StatusRejected, record.PatientID, reviewer); err != nil {
+		return nil, err
+	}
+	return s.repository.GetMessage(ctx, id)
+}
+
+func (s *HL7Service) pending(ctx context.Context, id uint) (*models.HL7Message, error) {
+	record, err := s.repository.GetMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrHL7MessageNotFound
+	}
+	if record.Status != models.HL7StatusPendingReview {
+		return nil, ErrHL7NotPending
+	}
+	return record, nil
+}
+
+// createPatient registers the patient a message describes and links the hospital's identifier to them
+func (s *HL7Service) createPatient(ctx context.Context, facility, externalID string, d models.HL7Demographics) (string, error) {
+	patient := &models.Patient{
+		FirstName:   d.FirstName,
+		MiddleName:  d.MiddleName,
+		LastName:    d.LastName,
+		Sex:         d.Sex,
+		DateOfBirth: d.DateOfBirth,
+		Phone:       d.Phone,
+		Address:     d.Address,
+		Attribution: models.Attribution{Source: models.SourceReferral, ReferredBy: facility},
+	}
+	if err := s.patientService.Import(ctx, patient); err != nil {
+		return "", err
+	}
+	if err := s.repository.SaveLink(ctx, facility, externalID, patient.ID); err != nil {
+		return "", err
+	}
+	return patient.ID, nil
+}
+
+// applyDemographics copies the hospital's details onto the patient, keeping ours where theirs are blank
+func (s *HL7Service) applyDemographics(ctx context.Context, patient *models.Patient, d models.HL7Demographics) error {
+	updated := *patient
+	updated.EmergencyContacts, updated.Examinations, updated.Billings = nil, nil, nil
+	updated.TreatmentPlans, updated.Appointments, updated.PrimaryContact = nil, nil, nil
+	updated.CustomFields = nil
+	updated.FirstName, updated.LastName, updated.DateOfBirth = d.FirstName, d.LastName, d.DateOfBirth
+	if d.MiddleName != "" {
+		updated.MiddleName = d.MiddleName
+	}
+	if d.Sex != "Other" {
+		updated.Sex = d.Sex
+	}
+	if d.Phone != "" {
+		updated.Phone = d.Phone
+	}
+	if !d.Address.IsZero() {
+		updated.Address = d.Address
+	}
+	return s.patientService.Update(ctx, &updated)
+}
+
+func (s *HL7Service) ack(msg *hl7.Message, code, text string) string {
+	return hl7.Ack(msg, code, text, s.cfg.Application, s.cfg.Facility)
+}
+
+// listenMLLP accepts connections from the hospital's interface engine and answers every message
+// with its acknowledgement on the same connection
+func (s *HL7Service) listenMLLP() {
+	listener, err := net.Listen("tcp", s.cfg.MLLPAddress)
+	if err != nil {
+		log.Printf("HL7 MLLP listener disabled: %v", err)
+		return
+	}
+	log.Printf("Listening for HL7 messages over MLLP on %s", s.cfg.MLLPAddress)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("HL7 MLLP accept failed: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go s.serveMLLP(conn)
+	}
+}
+
+func (s *HL7Service) serveMLLP(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		raw, err := hl7.ReadFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("HL7 MLLP connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), hl7MessageTimeout)
+		ack := s.Receive(ctx, raw)
+		cancel()
+		if err := hl7.WriteFrame(conn, ack); err != nil {
+			log.Printf("HL7 MLLP acknowledgement to %s failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// hl7Demographics reads the patient details from the PID segment
+func hl7Demographics(msg *hl7.Message) models.HL7Demographics {
+	d := models.HL7Demographics{
+		LastName:   strings.TrimSpace(msg.Get("PID-5.1")),
+		FirstName:  strings.TrimSpace(msg.Get("PID-5.2")),
+		MiddleName: strings.TrimSpace(msg.Get("PID-5.3")),
+		Phone:      strings.TrimSpace(msg.Get("PID-13.1")),
+	}
+	if dob := msg.Get("PID-7"); len(dob) >= 8 {
+		if date, err := time.Parse("20060102", dob[:8]); err == nil {
+			d.DateOfBirth = date.Format("2006-01-02")
+		}
+	}
+	switch strings.ToUpper(msg.Get("PID-8")) {
+	case "M":
+		d.Sex = "Male"
+	case "F":
+		d.Sex = "Female"
+	default:
+		d.Sex = "Other"
+	}
+	// The address is street, other designation, city, state or province and postal code
+	var street []string
+	for _, ref := range []string{"PID-11.1", "PID-11.2"} {
+		if part := strings.TrimSpace(msg.Get(ref)); part != "" {
+			street = append(street, part)
+		}
+	}
+	d.Address = models.Address{
+		Street:     strings.Join(street, ", "),
+		City:       strings.TrimSpace(msg.Get("PID-11.3")),
+		County:     strings.TrimSpace(msg.Get("PID-11.4")),
+		PostalCode: strings.TrimSpace(msg.Get("PID-11.5")),
+	}
+	return d
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// householdSuggestionLimit caps the groups of likely relatives suggested at once.
+const householdSuggestionLimit = 100
+
+var (
+	ErrHouseholdNotFound       = errors.New("household not found")
+	ErrHouseholdMemberNotFound = errors.New("household member not found")
+	ErrInvalidHousehold        = errors.New("invalid household")
+)
+
+// HouseholdService links the patients of a family into a household so their bills are consolidated
+// into one statement to the guarantor, and the reminders of children go to their guardian. It also
+// suggests households from patients entered with the same phone number or address.
+type HouseholdService struct {
+	repository        *repositories.HouseholdRepository
+	patientRepository *repositories.PatientRepository
+	billingRepo       *repositories.BillingRepository
+}
+
+func NewHouseholdService(repository *repositories.HouseholdRepository, patientRepository *repositories.PatientRepository, billingRepo *repositories.BillingRepository) *HouseholdService {
+	return &HouseholdService{repository: repository, patientRepository: patientRepository, billingRepo: billingRepo}
+}
+
+// Create links the guarantor and the given members into a new household. The household takes the
+// guarantor's phone and address unless others are given.
+func (s *HouseholdService) Create(ctx context.Context, household *models.Household) error {
+	household.Name = strings.TrimSpace(household.Name)
+	household.GuarantorID = strings.TrimSpace(household.GuarantorID)
+	if household.Name == "" || household.GuarantorID == "" {
+		return fmt.Errorf("%w: name and guarantor_id are required", ErrInvalidHousehold)
+	}
+	guarantor, err := s.patient(ctx, household.GuarantorID)
+	if err != nil {
+		return err
+	}
+	household.Phone = strings.TrimSpace(household.Phone)
+	if household.Phone == "" {
+		household.Phone = guarantor.Phone
+	}
+	household.Address = household.Address.Trimmed()
+	if household.Address.IsZero() {
+		household.Address = guarantor.Address
+	}
+
+	members := []models.HouseholdMember{{PatientID: guarantor.ID, Relationship: models.HouseholdRelationshipGuarantor}}
+	seen := map[string]bool{guarantor.ID: true}
+	for _, member := range household.Members {
+		member.PatientID = strings.TrimSpace(member.PatientID)
+		if seen[member.PatientID] {
+			continue
+		}
+		seen[member.PatientID] = true
+		if _, err := s.patient(ctx, member.PatientID); err != nil {
+			return err
+		}
+		members = append(members, models.HouseholdMember{PatientID: member.PatientID, Relationship: member.Relationship, GuardianID: member.GuardianID})
+	}
+	household.ID = 0
+	household.Members = members
+	for i := range household.Members {
+		if i > 0 {
+			if err := validateHouseholdMember(household, &household.Members[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return s.repository.Create(ctx, household)
+}
+
+func (s *HouseholdService) Get(ctx context.Context, id uint) (*models.Household, error) {
+	household, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if household == nil {
+		return nil, ErrHouseholdNotFound
+	}
+	return household, nil
+}
+
+// ByPatient returns the household a patient is in
+func (s *HouseholdService) ByPatient(ctx context.Context, patientID string) (*models.Household, error) {
+	if _, err := s.patient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	household, err := s.repository.ByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if household == nil {
+		return nil, ErrHouseholdNotFound
+	}
+	return household, nil
+}
+
+func (s *HouseholdService) List(ctx context.Context) ([]models.Household, error) {
+	households, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if households == nil {
+		households = []models.Household{}
+	}
+	return households, nil
+}
+
+// Update changes the household's name, phone, address and guarantor, who must already be a member
+func (s *HouseholdService) Update(ctx context.Context, household *models.Household) (*models.Household, error) {
+	current, err := s.Get(ctx, household.ID)
+	if err != nil {
+		return nil, err
+	}
+	household.Name = strings.TrimSpace(household.Name)
+	if household.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidHousehold)
+	}
+	household.GuarantorID = strings.TrimSpace(household.GuarantorID)
+	if household.GuarantorID == "" {
+		household.GuarantorID = current.GuarantorID
+	}
+	if householdMember(current, household.GuarantorID) == nil {
+		return nil, fmt.Errorf("%w: the guarantor must be a member of the household", ErrInvalidHousehold)
+	}
+	household.Phone = strings.TrimSpace(household.Phone)
+	household.Address = household.Address.Trimmed()
+	if err := s.repository.Update(ctx, household, current.GuarantorID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, household.ID)
+}
+
+// Delete unlinks the household's members and removes it; the patients themselves are kept
+func (s *HouseholdService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// AddMember adds a patient who is in no household yet to the household
+func (s *HouseholdService) AddMember(ctx context.Context, householdID uint, member *models.HouseholdMember) (*models.Household, error) {
+	household, err := s.Get(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	member.PatientID = strings.TrimSpace(member.PatientID)
+	if _, err := s.patient(ctx, member.PatientID); err != nil {
+		return nil, err
+	}
+	member.ID = 0
+	member.HouseholdID = householdID
+	if err := validateHouseholdMember(household, member); err != nil {
+		return nil, err
+	}
+	if err := s.repository.AddMember(ctx, member); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, householdID)
+}
+
+// UpdateMember changes how a member is related to the guarantor and who their guardian is
+func (s *HouseholdService) UpdateMember(ctx context.Context, householdID uint, patientID string, update models.HouseholdMember) (*models.Household, error) {
+	household, err := s.Get(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	member := householdMember(household, patientID)
+	if member == nil {
+		return nil, ErrHouseholdMemberNotFound
+	}
+	if patientID == household.GuarantorID {
+		return nil, fmt.Errorf("%w: the guarantor is changed on the household", ErrInvalidHousehold)
+	}
+	member.Relationship, member.GuardianID = update.Relationship, update.GuardianID
+	if err := validateHouseholdMember(household, member); err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdateMember(ctx, member); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, householdID)
+}
+
+// RemoveMember takes a patient other than the guarantor out of the household
+func (s *HouseholdService) RemoveMember(ctx context.Context, householdID uint, patientID string) (*models.Household, error) {
+	household, err := s.Get(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if householdMember(household, patientID) == nil {
+		return nil, ErrHouseholdMemberNotFound
+	}
+	if patientID == household.GuarantorID {
+		return nil, fmt.Errorf("%w: the guarantor cannot be removed; make another member the guarantor first", ErrInvalidHousehold)
+	}
+	if err := s.repository.RemoveMember(ctx, householdID, patientID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, householdID)
+}
+
+// Suggestions returns groups of patients in no household who share a phone number or address
+func (s *HouseholdService) Suggestions(ctx context.Context) ([]models.HouseholdSuggestion, error) {
+	suggestions, err := s.repository.Suggestions(ctx, householdSuggestionLimit)
+	if err != nil {
+		return nil, err
+	}
+	if suggestions == nil {
+		suggestions = []models.HouseholdSuggestion{}
+	}
+	return suggestions, nil
+}
+
+// Statement consolidates the statements of the household's members from the clinic day from to the
+// day to, both YYYY-MM-DD, as the patient portal's statement reads them
+func (s *HouseholdService) Statement(ctx context.Context, id uint, from, to string) (*models.HouseholdStatement, error) {
+	start, end, err := statementPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+	household, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.HouseholdStatement{
+		HouseholdID: household.ID,
+		GuarantorID: household.GuarantorID,
+		From:        start.Format("2006-01-02"),
+		To:          end.Format("2006-01-02"),
+		Members:     make([]models.PatientStatement, 0, len(household.Members)),
+	}
+	for _, member := range household.Members {
+		memberStatement, err := patientStatement(ctx, s.billingRepo, member.PatientID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		statement.OpeningBalance += memberStatement.OpeningBalance
+		statement.Billed += memberStatement.Billed
+		statement.Received += memberStatement.Received
+		statement.ClosingBalance += memberStatement.ClosingBalance
+		statement.Members = append(statement.Members, *memberStatement)
+	}
+	return statement, nil
+}
+
+func (s *HouseholdService) patient(ctx context.Context, patientID string) (*models.Patient, error) {
+	if patientID == "" {
+		return nil, fmt.Errorf("%w: patient_id is required", ErrInvalidHousehold)
+	}
+	patient, err := s.patientRepository.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	return patient, nil
+}
+
+// validateHouseholdMember checks the relationship of a member other than the guarantor, and that
+// their guardian is another member of the household
+func validateHouseholdMember(household *models.Household, member *models.HouseholdMember) error {
+	if !models.IsValidHouseholdRelationship(member.Relationship) {
+		return fmt.Errorf("%w: unknown relationship %q for patient %s", ErrInvalidHousehold, member.Relationship, member.PatientID)
+	}
+	if member.GuardianID == nil {
+		return nil
+	}
+	guardianID := strings.TrimSpace(*member.GuardianID)
+	if guardianID == "" {
+		member.GuardianID = nil
+		return nil
+	}
+	if guardianID == member.PatientID || householdMember(household, guardianID) == nil {
+		return fmt.Errorf("%w: the guardian of patient %s must be another member of the household", ErrInvalidHousehold, member.PatientID)
+	}
+	member.GuardianID = &guardianID
+	return nil
+}
+
+// householdMember returns the household's member who is the patient, or nil
+func householdMember(household *models.Household, patientID string) *models.HouseholdMember {
+	for i := range household.Members {
+		if household.Members[i].PatientID == patientID {
+			return &household.Members[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/dicom"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+var (
+	ErrImagingStudyNotFound = errors.New("imaging study not found")
+	ErrInvalidDICOM         = errors.New("invalid DICOM file")
+	ErrNoImagingPreview     = errors.New("no preview available for this image")
+	ErrPatientNotFound      = errors.New("patient not found")
+)
+
+// ImagingService files the DICOM images sent by the imaging unit with their patients
+type ImagingService struct {
+	repository   *repositories.ImagingRepository
+	cfg          config.ImagingConfig
+	patientIDTag dicom.Tag
+}
+
+func NewImagingService(repository *repositories.ImagingRepository, cfg config.ImagingConfig) *ImagingService {
+	tag, err := dicom.ParseTag(cfg.PatientIDTag)
+	if err != nil {
+		log.Printf("Invalid IMAGING_PATIENT_ID_TAG %q, reading the patient ID from %s: %v", cfg.PatientIDTag, dicom.TagPatientID, err)
+		tag = dicom.TagPatientID
+	}
+	return &ImagingService{repository: repository, cfg: cfg, patientIDTag: tag}
+}
+
+// Enabled reports whether the imaging unit has keys to send images with
+func (s *ImagingService) Enabled() bool {
+	return len(s.cfg.APIKeys) > 0
+}
+
+// MaxFileSize is the largest DICOM file accepted
+func (s *ImagingService) MaxFileSize() int64 {
+	return s.cfg.MaxFileSize
+}
+
+// Ingest stores a DICOM file with its metadata and a preview. The image is linked to the patient
+// whose ID it carries, or left unmatched for staff to link. Images received before are returned as is.
+func (s *ImagingService) Ingest(ctx context.Context, fileName string, content []byte) (*models.ImagingStudy, error) {
+	file, err := dicom.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDICOM, err)
+	}
+
+	sopInstanceUID := file.String(dicom.TagSOPInstanceUID)
+	if sopInstanceUID != "" {
+		existing, err := s.repository.GetBySOPInstanceUID(ctx, sopInstanceUID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	study := &models.ImagingStudy{
+		Status:            models.ImagingStatusUnmatched,
+		SOPInstanceUID:    sopInstanceUID,
+		StudyInstanceUID:  file.String(dicom.TagStudyInstanceUID),
+		StudyDate:         file.Date(dicom.TagStudyDate),
+		Modality:          file.String(dicom.TagModality),
+		SourcePatientID:   file.String(s.patientIDTag),
+		SourcePatientName: file.PatientName(),
+		FileName:          strings.TrimSpace(fileName),
+		Size:              int64(len(content)),
+		Content:           content,
+	}
+	if study.FileName == "" {
+		study.FileName = "image.dcm"
+	}
+
+	if preview, err := file.Preview(); err != nil {
+		log.Printf("No preview for DICOM image %s: %v", study.FileName, err)
+	} else {
+		study.Preview = preview
+		study.HasPreview = true
+	}
+
+	if study.SourcePatientID != "" {
+		exists, err := s.repository.PatientExists(ctx, study.SourcePatientID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			study.PatientID = &study.SourcePatientID
+			study.Status = models.ImagingStatusMatched
+		}
+	}
+
+	if err := s.repository.Create(ctx, study); err != nil {
+		return nil, err
+	}
+	return study, nil
+}
+
+func (s *ImagingService) Get(ctx context.Context, id uint) (*models.ImagingStudy, error) {
+	study, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if study == nil {
+		return nil, ErrImagingStudyNotFound
+	}
+	return study, nil
+}
+
+// List returns studies filtered by status, such as the unmatched queue, or by patient
+func (s *ImagingService) List(ctx context.Context, status, patientID string) ([]models.ImagingStudy, error) {
+	if status != "" && status != models.ImagingStatusMatched && status != models.ImagingStatusUnmatched {
+		return nil, fmt.Errorf("invalid imaging status %q", status)
+	}
+	studies, err := s.repository.List(ctx, status, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if studies == nil {
+		studies = []models.ImagingStudy{}
+	}
+	return studies, nil
+}
+
+// Download returns the study with the original DICOM file
+func (s *ImagingService) Download(ctx context.Context, id uint) (*models.ImagingStudy, error) {
+	study, err := s.repository.GetWithContent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if study == nil {
+		return nil, ErrImagingStudyNotFound
+	}
+	return study, nil
+}
+
+// Preview returns the PNG preview of a study
+func (s *ImagingService) Preview(ctx context.Context, id uint) ([]byte, error) {
+	study, err := s.Download(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !study.HasPreview {
+		return nil, ErrNoImagingPreview
+	}
+	return study.Preview, nil
+}
+
+// Match links a study, typically from the unmatched queue, to a patient
+func (s *ImagingService) Match(ctx context.Context, id uint, patientID string, matchedBy int64) (*models.ImagingStudy, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	exists, err := s.repository.PatientExists(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrPatientNotFound
+	}
+	if err := s.repository.Match(ctx, id, patientID, matchedBy); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
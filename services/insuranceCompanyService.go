@@ -4,6 +4,8 @@ import (
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"context"
+	"fmt"
+	"strings"
 )
 
 type InsuranceCompanyService struct {
@@ -15,6 +17,9 @@ func NewInsuranceCompanyService(repository *repositories.InsuranceCompanyReposit
 }
 
 func (s *InsuranceCompanyService) Create(ctx context.Context, company *models.InsuranceCompany) error {
+	if err := normalizeClaimFormat(company); err != nil {
+		return err
+	}
 	return s.repository.Create(ctx, company)
 }
 
@@ -27,9 +32,24 @@ func (s *InsuranceCompanyService) GetAll(ctx context.Context) ([]models.Insuranc
 }
 
 func (s *InsuranceCompanyService) Update(ctx context.Context, company *models.InsuranceCompany) error {
+	if err := normalizeClaimFormat(company); err != nil {
+		return err
+	}
 	return s.repository.Update(ctx, company)
 }
 
 func (s *InsuranceCompanyService) Delete(ctx context.Context, id string) error {
 	return s.repository.Delete(ctx, id)
 }
+
+// normalizeClaimFormat defaults the insurer's claim submission format to CSV
+func normalizeClaimFormat(company *models.InsuranceCompany) error {
+	company.ClaimFormat = strings.ToLower(strings.TrimSpace(company.ClaimFormat))
+	if company.ClaimFormat == "" {
+		company.ClaimFormat = models.ClaimFormatCSV
+	}
+	if !models.IsValidClaimFormat(company.ClaimFormat) {
+		return fmt.Errorf("unknown claim format %q", company.ClaimFormat)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+// insurerRole is the role of insurers' claims officers
+const insurerRole = "Insurer"
+
+// Insurer lists are limited to
+const (
+	defaultInsurerListLimit = 100
+	maxInsurerListLimit     = 500
+)
+
+var (
+	// ErrInsurerNotLinked is returned when the signed-in user is linked to no insurance company
+	ErrInsurerNotLinked = errors.New("no insurance company is linked to this user")
+	// ErrInsurerRecordNotFound is returned for claims and bills that are not the insurer's members'
+	ErrInsurerRecordNotFound = errors.New("record not found")
+	// ErrInvalidInsurerAccount is returned for links to users without the Insurer role or to
+	// companies that do not exist
+	ErrInvalidInsurerAccount = errors.New("invalid insurer account")
+	// ErrInsurerAccountNotFound is returned when unlinking a user who is linked to no company
+	ErrInsurerAccountNotFound = errors.New("insurer account not found")
+	// ErrInvalidInsurerFilter is returned for lists asked for with an unknown status or unreadable days
+	ErrInvalidInsurerFilter = errors.New("invalid insurer filter")
+)
+
+// InsurerPortalService lets an insurer's claims officer see the claims and bills of their company's
+// members, and nothing clinical. Every look-up is logged, including those of records that are not
+// theirs, and admins link officers to their companies and read the log.
+type InsurerPortalService struct {
+	repository  *repositories.InsurerPortalRepository
+	userRepo    repositories.UserRepository
+	companyRepo *repositories.InsuranceCompanyRepository
+	clock       clock.Clock
+}
+
+func NewInsurerPortalService(repository *repositories.InsurerPortalRepository, userRepo repositories.UserRepository, companyRepo *repositories.InsuranceCompanyRepository, clock clock.Clock) *InsurerPortalService {
+	return &InsurerPortalService{repository: repository, userRepo: userRepo, companyRepo: companyRepo, clock: clock}
+}
+
+// LinkAccount lets a user with the Insurer role see the company's claims and bills
+func (s *InsurerPortalService) LinkAccount(ctx context.Context, userID int64, insuranceCompanyID string) (*models.InsurerAccount, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("%w: user %d does not exist", ErrInvalidInsurerAccount, userID)
+	}
+	if user.Role.Name != insurerRole {
+		return nil, fmt.Errorf("%w: user %d does not have the %s role", ErrInvalidInsurerAccount, userID, insurerRole)
+	}
+	company, err := s.companyRepo.GetByID(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if company == nil {
+		return nil, fmt.Errorf("%w: insurance company %q does not exist", ErrInvalidInsurerAccount, insuranceCompanyID)
+	}
+	account := &models.InsurerAccount{UserID: userID, InsuranceCompanyID: company.ID}
+	if err := s.repository.SaveAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// UnlinkAccount stops the user seeing any insurer's claims and bills
+func (s *InsurerPortalService) UnlinkAccount(ctx context.Context, userID int64) error {
+	deleted, err := s.repository.DeleteAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrInsurerAccountNotFound
+	}
+	return nil
+}
+
+// Accounts lists the linked users, of one company when insuranceCompanyID is set
+func (s *InsurerPortalService) Accounts(ctx context.Context, insuranceCompanyID string) ([]models.InsurerAccount, error) {
+	accounts, err := s.repository.Accounts(ctx, insuranceCompanyID)
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []models.InsurerAccount{}
+	}
+	return accounts, nil
+}
+
+// Accesses returns the logged look-ups matching filter, newest first
+func (s *InsurerPortalService) Accesses(ctx context.Context, filter models.InsurerAccessFilter) ([]models.InsurerAccess, error) {
+	filter.Limit = insurerListLimit(filter.Limit)
+	accesses, err := s.repository.Accesses(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if accesses == nil {
+		accesses = []models.InsurerAccess{}
+	}
+	return accesses, nil
+}
+
+// Claims returns the claims made to the signed-in officer's company matching filter, by service date
+func (s *InsurerPortalService) Claims(ctx context.Context, access models.InsurerAccess, filter models.InsurerFilter) ([]models.InsurerClaim, error) {
+	account, err := s.account(ctx, access.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInsurerFilter(&filter); err != nil {
+		return nil, err
+	}
+	claims, err := s.repository.Claims(ctx, account.InsuranceCompanyID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		claims = []models.InsurerClaim{}
+	}
+	s.logAccess(ctx, access, account, models.InsurerResourceClaims, "", filter, len(claims))
+	return claims, nil
+}
+
+// Claim returns a claim made to the signed-in officer's company
+func (s *InsurerPortalService) Claim(ctx context.Context, access models.InsurerAccess, id uint) (*models.InsurerClaim, error) {
+	account, err := s.account(ctx, access.UserID)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := s.repository.Claim(ctx, account.InsuranceCompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+	recordID := strconv.FormatUint(uint64(id), 10)
+	if claim == nil {
+		access.Denied = true
+		s.logAccess(ctx, access, account, models.InsurerResourceClaim, recordID, models.InsurerFilter{}, 0)
+		return nil, ErrInsurerRecordNotFound
+	}
+	s.logAccess(ctx, access, account, models.InsurerResourceClaim, recordID, models.InsurerFilter{}, 1)
+	return claim, nil
+}
+
+// Billings returns the bills of the signed-in officer's company's members matching filter, by the
+// day they were raised
+func (s *InsurerPortalService) Billings(ctx context.Context, access models.InsurerAccess, filter models.InsurerFilter) ([]models.InsurerBilling, error) {
+	account, err := s.account(ctx, access.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInsurerFilter(&filter); err != nil {
+		return nil, err
+	}
+	billings, err := s.repository.Billings(ctx, account.InsuranceCompanyID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if billings == nil {
+		billings = []models.InsurerBilling{}
+	}
+	s.logAccess(ctx, access, account, models.InsurerResourceBillings, "", filter, len(billings))
+	return billings, nil
+}
+
+// Billing returns a bill of one of the signed-in officer's company's members
+func (s *InsurerPortalService) Billing(ctx context.Context, access models.InsurerAccess, billingID string) (*models.InsurerBilling, error) {
+	account, err := s.account(ctx, access.UserID)
+	if err != nil {
+		return nil, err
+	}
+	billing, err := s.repository.Billing(ctx, account.InsuranceCompanyID, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		access.Denied = true
+		s.logAccess(ctx, access, account, models.InsurerResourceBilling, billingID, models.InsurerFilter{}, 0)
+		return nil, ErrInsurerRecordNotFound
+	}
+	s.logAccess(ctx, access, account, models.InsurerResourceBilling, billingID, models.InsurerFilter{}, 1)
+	return billing, nil
+}
+
+func (s *InsurerPortalService) account(ctx context.Context, userID int64) (*models.InsurerAccount, error) {
+	account, err := s.repository.Account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInsurerNotLinked
+	}
+	return account, nil
+}
+
+// logAccess records a look-up with the filter it was made with. A look-up that cannot be logged is
+// still answered; the failure is logged instead.
+func (s *InsurerPortalService) logAccess(ctx context.Context, access models.InsurerAccess, account *models.InsurerAccount, resource, recordID string, filter models.InsurerFilter, results int) {
+	access.ID, access.InsuranceCompanyID, access.Resource, access.RecordID = 0, account.InsuranceCompanyID, resource, recordID
+	access.Query, access.Results, access.AccessedAt = insurerFilterQuery(filter), results, s.clock.Now()
+	if err := s.repository.LogAccess(context.WithoutCancel(ctx), &access); err != nil {
+		log.Printf("Failed to log insurer access by user %d: %v", access.UserID, err)
+	}
+}
+
+// checkInsurerFilter checks the status and days of filter and bounds its limit
+func checkInsurerFilter(filter *models.InsurerFilter) error {
+	switch filter.Status {
+	case "", models.ClaimStatusPending, models.ClaimStatusApproved, models.ClaimStatusSubmitted, models.ClaimStatusPaid, models.ClaimStatusRejected:
+	default:
+		return fmt.Errorf("%w: unknown claim status %q", ErrInvalidInsurerFilter, filter.Status)
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return fmt.Errorf("%w: to must not be before from", ErrInvalidInsurerFilter)
+	}
+	filter.Limit = insurerListLimit(filter.Limit)
+	return nil
+}
+
+func insurerListLimit(limit int) int {
+	if limit <= 0 {
+		return defaultInsurerListLimit
+	}
+	return min(limit, maxInsurerListLimit)
+}
+
+// insurerFilterQuery writes filter as the query string it was asked with, for the access log
+func insurerFilterQuery(filter models.InsurerFilter) string {
+	query := url.Values{}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.From != nil {
+		query.Set("from", filter.From.Format("2006-01-02"))
+	}
+	if filter.To != nil {
+		query.Set("to", filter.To.Format("2006-01-02"))
+	}
+	return query.Encode()
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// integrityTimeout bounds a single run of the checks and their repairs.
+	integrityTimeout = 30 * time.Minute
+	// integrityListLimit is how many recent runs are listed.
+	integrityListLimit = 100
+)
+
+var (
+	ErrIntegrityRunNotFound = errors.New("integrity run not found")
+	ErrIntegrityRunning     = errors.New("an integrity run is already in progress")
+)
+
+// integrityCheck finds up to limit issues of one kind
+type integrityCheck func(ctx context.Context, limit int) ([]models.IntegrityIssue, error)
+
+type IntegrityService struct {
+	repository *repositories.IntegrityRepository
+	config     config.IntegrityConfig
+	checks     []integrityCheck
+}
+
+// NewIntegrityService starts running the checks every config.CheckInterval, repairing what they
+// find when config.AutoRepair is set and recomputing drifted balances when config.RepairBalances
+// is. Admins can start a run at any time.
+func NewIntegrityService(repository *repositories.IntegrityRepository, cfg config.IntegrityConfig) *IntegrityService {
+	s := &IntegrityService{
+		repository: repository,
+		config:     cfg,
+		checks: []integrityCheck{
+			repository.BillingsWithoutPatient,
+			repository.BillingsWithoutDoctor,
+			repository.AppointmentsWithoutPatient,
+			repository.AppointmentsWithoutDoctor,
+			repository.NegativeBalances,
+			repository.BalanceMismatches,
+		},
+	}
+	if cfg.CacheSample > 0 {
+		s.checks = append(s.checks, s.sampled(repository.PatientCacheMismatches),
+			s.sampled(repository.DoctorCacheMismatches), s.sampled(repository.BillingCacheMismatches))
+	}
+	if cfg.CheckInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+// Start begins a run of the checks in the background, repairing what they find when repair is set,
+// and returns the run to poll for its report
+func (s *IntegrityService) Start(ctx context.Context, userID int64, repair bool) (*models.IntegrityRun, error) {
+	run := &models.IntegrityRun{Trigger: models.IntegrityTriggerManual, Repair: repair, RequestedBy: &userID}
+	if err := s.start(ctx, run); err != nil {
+		return nil, err
+	}
+	go s.check(run)
+	return run, nil
+}
+
+func (s *IntegrityService) Get(ctx context.Context, id uint) (*models.IntegrityRun, error) {
+	run, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrIntegrityRunNotFound
+	}
+	return run, nil
+}
+
+func (s *IntegrityService) List(ctx context.Context) ([]models.IntegrityRun, error) {
+	runs, err := s.repository.List(ctx, integrityListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.IntegrityRun{}
+	}
+	return runs, nil
+}
+
+func (s *IntegrityService) start(ctx context.Context, run *models.IntegrityRun) error {
+	run.StartedAt = time.Now()
+	started, err := s.repository.Start(ctx, run, run.StartedAt.Add(-2*integrityTimeout))
+	if err != nil {
+		return err
+	}
+	if !started {
+		return ErrIntegrityRunning
+	}
+	return nil
+}
+
+func (s *IntegrityService) run() {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		run := &models.IntegrityRun{Trigger: models.IntegrityTriggerScheduled, Repair: s.config.AutoRepair}
+		if err := s.start(context.Background(), run); err != nil {
+			// Another replica is already checking
+			if !errors.Is(err, ErrIntegrityRunning) {
+				log.Printf("Failed to start scheduled integrity run: %v", err)
+			}
+			continue
+		}
+		s.check(run)
+	}
+}
+
+// check runs every check, repairs the issues found when the run asks for it and stores the report
+func (s *IntegrityService) check(run *models.IntegrityRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), integrityTimeout)
+	defer cancel()
+
+	for _, check := range s.checks {
+		issues, err := check(ctx, s.config.IssueLimit)
+		if err != nil {
+			log.Printf("Integrity run %d failed: %v", run.ID, err)
+			if err := s.repository.Fail(context.Background(), run.ID, err.Error()); err != nil {
+				log.Printf("Failed to record integrity run %d failure: %v", run.ID, err)
+			}
+			return
+		}
+		run.Issues = append(run.Issues, issues...)
+	}
+
+	for i := range run.Issues {
+		if s.repairs(run, run.Issues[i]) {
+			s.repair(ctx, &run.Issues[i])
+		}
+	}
+
+	if err := s.repository.Complete(ctx, run); err != nil {
+		log.Printf("Failed to store integrity run %d: %v", run.ID, err)
+		if err := s.repository.Fail(context.Background(), run.ID, err.Error()); err != nil {
+			log.Printf("Failed to record integrity run %d failure: %v", run.ID, err)
+		}
+		return
+	}
+	if run.IssueCount > 0 {
+		log.Printf("Integrity run %d found %d issues and repaired %d", run.ID, run.IssueCount, run.RepairedCount)
+	}
+}
+
+// repairs reports whether the run repairs the issue: every issue when it asks for repairs, and
+// drifted balances on schedule when they are recomputed on their own
+func (s *IntegrityService) repairs(run *models.IntegrityRun, issue models.IntegrityIssue) bool {
+	if run.Repair {
+		return true
+	}
+	return run.Trigger == models.IntegrityTriggerScheduled && s.config.RepairBalances && issue.Repair == models.IntegrityRepairRecompute
+}
+
+// repair applies the issue's repair, recording why it could not be when it fails
+func (s *IntegrityService) repair(ctx context.Context, issue *models.IntegrityIssue) {
+	var err error
+	switch issue.Repair {
+	case "":
+		return
+	case models.IntegrityRepairDelete:
+		if issue.Check == models.IntegrityCheckBillingWithoutPatient {
+			err = s.repository.DeleteBilling(ctx, issue.RecordID)
+		} else {
+			err = s.repository.DeleteAppointment(ctx, issue.RecordID)
+		}
+	case models.IntegrityRepairCancel:
+		err = s.repository.CancelAppointment(ctx, issue.RecordID)
+	case models.IntegrityRepairRecompute:
+		err = s.repository.RecomputeBalance(ctx, issue.RecordID)
+	case models.IntegrityRepairEvict:
+		err = s.repository.Evict(ctx, cachedEntity(issue.Check), issue.RecordID)
+	default:
+		err = fmt.Errorf("unknown repair %q", issue.Repair)
+	}
+	if err != nil {
+		issue.RepairError = err.Error()
+		return
+	}
+	issue.Repaired = true
+}
+
+// sampled runs a cache check over the configured number of recently updated records
+func (s *IntegrityService) sampled(check integrityCheck) integrityCheck {
+	return func(ctx context.Context, _ int) ([]models.IntegrityIssue, error) {
+		return check(ctx, s.config.CacheSample)
+	}
+}
+
+// cachedEntity is the cache entity of the records a cache check compares
+func cachedEntity(check string) string {
+	switch check {
+	case models.IntegrityCheckPatientCache:
+		return "patient"
+	case models.IntegrityCheckDoctorCache:
+		return "doctor"
+	default:
+		return "billing"
+	}
+}
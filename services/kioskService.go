@@ -0,0 +1,80 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrKioskPatientNotFound is returned when no single patient matches the phone and date of birth given.
+var ErrKioskPatientNotFound = errors.New("no matching patient found")
+
+type KioskService struct {
+	repository      *repositories.KioskRepository
+	appointmentRepo *repositories.AppointmentRepository
+}
+
+func NewKioskService(repository *repositories.KioskRepository, appointmentRepo *repositories.AppointmentRepository) *KioskService {
+	return &KioskService{repository: repository, appointmentRepo: appointmentRepo}
+}
+
+// LookupToday returns today's appointments of the patients identified by phone and date of birth.
+func (s *KioskService) LookupToday(ctx context.Context, phone, dateOfBirth string) ([]models.KioskAppointment, error) {
+	ids, err := s.repository.FindPatientIDs(ctx, phone, dateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrKioskPatientNotFound
+	}
+	return s.repository.GetAppointmentsOn(ctx, ids, time.Now())
+}
+
+// CheckIn checks in one of today's appointments, provided it belongs to the identified patient.
+func (s *KioskService) CheckIn(ctx context.Context, phone, dateOfBirth string, appointmentID uint) (*models.KioskAppointment, error) {
+	appointments, err := s.LookupToday(ctx, phone, dateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+	for _, appointment := range appointments {
+		if appointment.ID != appointmentID {
+			continue
+		}
+		if err := s.appointmentRepo.CheckIn(ctx, appointment.PatientID, appointment.ID, time.Now()); err != nil {
+			return nil, err
+		}
+		appointment.Status = models.AppointmentStatusCheckedIn
+		return &appointment, nil
+	}
+	return nil, repositories.ErrAppointmentNotCheckable
+}
+
+// SubmitContactUpdate records new contact details for staff approval.
+func (s *KioskService) SubmitContactUpdate(ctx context.Context, phone, dateOfBirth string, update *models.PatientContactUpdate) error {
+	ids, err := s.repository.FindPatientIDs(ctx, phone, dateOfBirth)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return ErrKioskPatientNotFound
+	}
+	if update.Phone == "" && update.Email == "" && update.Address.IsZero() {
+		return errors.New("no contact details to update")
+	}
+	update.ID = 0
+	update.PatientID = ids[0]
+	update.Source = "kiosk"
+	update.Status = models.ContactUpdatePending
+	update.Address = update.Address.Trimmed()
+	return s.repository.CreateContactUpdate(ctx, update)
+}
+
+func (s *KioskService) GetContactUpdates(ctx context.Context, status string) ([]models.PatientContactUpdate, error) {
+	return s.repository.GetContactUpdates(ctx, status)
+}
+
+func (s *KioskService) ReviewContactUpdate(ctx context.Context, id uint, approve bool, reviewer string) (*models.PatientContactUpdate, error) {
+	return s.repository.ReviewContactUpdate(ctx, id, approve, reviewer)
+}
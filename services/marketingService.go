@@ -0,0 +1,153 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// campaignCodeLimit caps a campaign code, as stored
+	campaignCodeLimit = 50
+	// referredByLimit caps who referred a patient or appointment
+	referredByLimit = 255
+)
+
+// MarketingService reports what each source and campaign brings in: new patients, bookings and the
+// revenue billed to the patients who came from it
+type MarketingService struct {
+	repository *repositories.MarketingRepository
+}
+
+func NewMarketingService(repository *repositories.MarketingRepository) *MarketingService {
+	return &MarketingService{repository: repository}
+}
+
+// Report returns what each source and campaign brought in from the start of from to the end of to.
+// Bookings are counted by the source of the appointment, revenue by the source of the patient billed,
+// so a campaign is credited with everything its patients are billed for later.
+func (s *MarketingService) Report(ctx context.Context, from, to time.Time) (*models.MarketingReport, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidReportPeriod)
+	}
+	if to.Sub(from) > maxAnalyticsRange {
+		return nil, fmt.Errorf("%w: the report period may not exceed one year", ErrInvalidReportPeriod)
+	}
+	end := to.AddDate(0, 0, 1)
+
+	patients, err := s.repository.NewPatients(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := s.repository.Bookings(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := s.repository.Revenue(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[[2]string]*models.MarketingSource{}
+	source := func(count models.MarketingCount) *models.MarketingSource {
+		name := count.Source
+		if name == "" {
+			name = models.SourceUnknown
+		}
+		key := [2]string{name, count.CampaignCode}
+		if _, ok := sources[key]; !ok {
+			sources[key] = &models.MarketingSource{Source: name, CampaignCode: count.CampaignCode}
+		}
+		return sources[key]
+	}
+	for _, count := range patients {
+		source(count).NewPatients += count.Count
+	}
+	for _, count := range bookings {
+		booked := source(count)
+		booked.Bookings += count.Count
+		booked.Fulfilled += count.Fulfilled
+		booked.Cancelled += count.Cancelled
+	}
+	for _, count := range revenue {
+		billed := source(count)
+		billed.BilledPatients += count.Count
+		billed.Revenue += count.Amount
+		billed.Collected += count.Collected
+	}
+
+	report := &models.MarketingReport{
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Sources: make([]models.MarketingSource, 0, len(sources)),
+	}
+	for _, source := range sources {
+		if source.BilledPatients > 0 {
+			source.RevenuePerPatient = source.Revenue / float64(source.BilledPatients)
+		}
+		if source.Bookings > 0 {
+			source.ConversionRate = float64(source.Fulfilled) / float64(source.Bookings)
+		}
+		report.Sources = append(report.Sources, *source)
+	}
+	// Best earning sources first
+	sort.Slice(report.Sources, func(i, j int) bool {
+		a, b := report.Sources[i], report.Sources[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		if a.Bookings != b.Bookings {
+			return a.Bookings > b.Bookings
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.CampaignCode < b.CampaignCode
+	})
+	return report, nil
+}
+
+// checkAttribution tidies where a patient or appointment came from and checks that it is a known
+// source. A campaign code or referrer given without a source implies it. Failures wrap invalid.
+func checkAttribution(attribution *models.Attribution, invalid error) error {
+	attribution.Source = strings.ToLower(strings.TrimSpace(attribution.Source))
+	attribution.CampaignCode = strings.TrimSpace(attribution.CampaignCode)
+	attribution.ReferredBy = strings.TrimSpace(attribution.ReferredBy)
+	if attribution.Source == "" {
+		switch {
+		case attribution.CampaignCode != "":
+			attribution.Source = models.SourceCampaign
+		case attribution.ReferredBy != "":
+			attribution.Source = models.SourceReferral
+		default:
+			return nil
+		}
+	}
+	if !models.IsValidSource(attribution.Source) {
+		return fmt.Errorf("%w: unknown source %q, expected one of %s", invalid, attribution.Source, strings.Join(models.Sources, ", "))
+	}
+	if attribution.Source == models.SourceCampaign && attribution.CampaignCode == "" {
+		return fmt.Errorf("%w: a campaign source needs its campaign_code", invalid)
+	}
+	if len(attribution.CampaignCode) > campaignCodeLimit {
+		return fmt.Errorf("%w: the campaign code is limited to %d characters", invalid, campaignCodeLimit)
+	}
+	if len(attribution.ReferredBy) > referredByLimit {
+		return fmt.Errorf("%w: referred_by is limited to %d characters", invalid, referredByLimit)
+	}
+	return nil
+}
+
+// cleanCampaignCode tidies the campaign code a public form was sent with, cutting it to what is
+// stored rather than refusing the form
+func cleanCampaignCode(code string) string {
+	code = strings.TrimSpace(code)
+	if len(code) > campaignCodeLimit {
+		code = code[:campaignCodeLimit]
+	}
+	return code
+}
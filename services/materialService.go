@@ -0,0 +1,123 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMaterialUsageNotFound = errors.New("material usage not found")
+	ErrInvalidMaterialUsage  = errors.New("invalid material usage")
+)
+
+type MaterialService struct {
+	repository  *repositories.MaterialRepository
+	billingRepo *repositories.BillingRepository
+}
+
+func NewMaterialService(repository *repositories.MaterialRepository, billingRepo *repositories.BillingRepository) *MaterialService {
+	return &MaterialService{repository: repository, billingRepo: billingRepo}
+}
+
+// Record records the lot of a material used for a billed procedure
+func (s *MaterialService) Record(ctx context.Context, billingID string, usage *models.MaterialUsage) error {
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return err
+	}
+	if billing == nil {
+		return ErrBillingNotFound
+	}
+
+	usage.Kind = strings.TrimSpace(usage.Kind)
+	usage.Manufacturer = strings.TrimSpace(usage.Manufacturer)
+	usage.Product = strings.TrimSpace(usage.Product)
+	usage.LotNumber = strings.TrimSpace(usage.LotNumber)
+	usage.ExpiresOn = strings.TrimSpace(usage.ExpiresOn)
+	if !models.IsValidMaterialKind(usage.Kind) {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidMaterialUsage, usage.Kind)
+	}
+	if usage.Manufacturer == "" || usage.Product == "" || usage.LotNumber == "" {
+		return fmt.Errorf("%w: manufacturer, product and lot_number are required", ErrInvalidMaterialUsage)
+	}
+	if usage.ExpiresOn != "" {
+		if _, err := time.Parse(models.ClosureDateLayout, usage.ExpiresOn); err != nil {
+			return fmt.Errorf("%w: expires_on must be a date such as 2024-05-31", ErrInvalidMaterialUsage)
+		}
+	}
+	if usage.Quantity == 0 {
+		usage.Quantity = 1
+	}
+	if usage.Quantity < 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidMaterialUsage)
+	}
+
+	usage.ID = 0
+	usage.BillingID = billing.BillingID
+	usage.PatientID = billing.PatientID
+	usage.DoctorID = billing.DoctorID
+	usage.Procedure = billing.Procedure
+	usage.TreatedAt = billing.CreatedAt
+	return s.repository.Create(ctx, usage)
+}
+
+func (s *MaterialService) GetBillingMaterials(ctx context.Context, billingID string) ([]models.MaterialUsage, error) {
+	usages, err := s.repository.GetByBilling(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if usages == nil {
+		usages = []models.MaterialUsage{}
+	}
+	return usages, nil
+}
+
+// GetPatientMaterials returns the materials a patient was treated with, such as their implants
+func (s *MaterialService) GetPatientMaterials(ctx context.Context, patientID string) ([]models.MaterialUsage, error) {
+	usages, err := s.repository.GetByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if usages == nil {
+		usages = []models.MaterialUsage{}
+	}
+	return usages, nil
+}
+
+func (s *MaterialService) Delete(ctx context.Context, billingID string, id uint) error {
+	deleted, err := s.repository.Delete(ctx, billingID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrMaterialUsageNotFound
+	}
+	return nil
+}
+
+// Recall lists the patients treated with material from a lot a supplier recalled
+func (s *MaterialService) Recall(ctx context.Context, filter models.MaterialRecallFilter) (*models.MaterialRecallReport, error) {
+	filter.LotNumber = strings.TrimSpace(filter.LotNumber)
+	filter.Manufacturer = strings.TrimSpace(filter.Manufacturer)
+	filter.Product = strings.TrimSpace(filter.Product)
+	if filter.LotNumber == "" {
+		return nil, fmt.Errorf("%w: lot_number is required", ErrInvalidMaterialUsage)
+	}
+	traces, err := s.repository.Recall(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if traces == nil {
+		traces = []models.MaterialTrace{}
+	}
+	patients := map[string]bool{}
+	for _, trace := range traces {
+		patients[trace.PatientID] = true
+	}
+	return &models.MaterialRecallReport{MaterialRecallFilter: filter, PatientCount: len(patients), Patients: traces}, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/noshow"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidNoShowQuery is returned for risk lists asked for with an unreadable day or threshold
+var ErrInvalidNoShowQuery = errors.New("invalid no-show risk query")
+
+// NoShowService scores upcoming appointments in the background with the configured scorer, so the
+// desk can double-confirm or overbook the slots most likely to be missed. Scores are refreshed
+// every poll, as a patient's history changes when earlier appointments are kept or missed.
+type NoShowService struct {
+	scorer          noshow.Scorer
+	appointmentRepo *repositories.AppointmentRepository
+	config          config.NoShowConfig
+}
+
+// NewNoShowService starts the scoring job when a scorer is configured.
+func NewNoShowService(appointmentRepo *repositories.AppointmentRepository, cfg config.NoShowConfig) *NoShowService {
+	scorer, err := noshow.New(cfg)
+	if err != nil {
+		log.Printf("No-show scoring disabled: %v", err)
+	}
+	s := &NoShowService{scorer: scorer, appointmentRepo: appointmentRepo, config: cfg}
+	if scorer != nil {
+		go s.run()
+	}
+	return s
+}
+
+func (s *NoShowService) run() {
+	for {
+		if database.IsLeader() {
+			if err := s.scoreUpcoming(context.Background()); err != nil {
+				log.Printf("Failed to score upcoming appointments: %v", err)
+			}
+		}
+		time.Sleep(s.config.PollInterval)
+	}
+}
+
+// scoreUpcoming scores the appointments within the horizon, saving the scores that changed.
+// Appointments the scorer fails on keep their last score until the next poll.
+func (s *NoShowService) scoreUpcoming(ctx context.Context) error {
+	now := time.Now()
+	candidates, err := s.appointmentRepo.NoShowCandidates(ctx, now, now.AddDate(0, 0, s.config.HorizonDays), now.AddDate(0, -s.config.HistoryMonths, 0))
+	if err != nil {
+		return err
+	}
+
+	var scores []repositories.NoShowScore
+	for _, candidate := range candidates {
+		scoreCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+		risk, err := s.scorer.Score(scoreCtx, candidate.NoShowFeatures)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to score appointment %d: %v", candidate.AppointmentID, err)
+			continue
+		}
+		risk = math.Round(risk*1000) / 1000
+		if candidate.NoShowRisk != nil && *candidate.NoShowRisk == risk {
+			continue
+		}
+		scores = append(scores, repositories.NoShowScore{AppointmentID: candidate.AppointmentID, PatientID: candidate.PatientID, Risk: risk})
+	}
+	return s.appointmentRepo.SaveNoShowRisks(ctx, scores)
+}
+
+// Risks returns the scored appointments of a clinic day (YYYY-MM-DD, today when empty) whose risk
+// is at least minRisk, riskiest first. Without a threshold only high-risk appointments are listed.
+func (s *NoShowService) Risks(ctx context.Context, date, minRisk string) ([]models.NoShowRisk, error) {
+	day := models.ClinicNow()
+	if date != "" {
+		var err error
+		if day, err = models.ParseClinicDate(date); err != nil {
+			return nil, fmt.Errorf("%w: date must look like 2006-01-02", ErrInvalidNoShowQuery)
+		}
+	}
+	threshold := s.config.HighRisk
+	if minRisk != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(minRisk, 64); err != nil || threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("%w: min_risk must be between 0 and 1", ErrInvalidNoShowQuery)
+		}
+	}
+
+	from, to := models.ClinicDay(day)
+	risks, err := s.appointmentRepo.NoShowRisks(ctx, from, to, threshold)
+	if err != nil {
+		return nil, err
+	}
+	if risks == nil {
+		risks = []models.NoShowRisk{}
+	}
+	for i := range risks {
+		risks[i].HighRisk = risks[i].NoShowRisk >= s.config.HighRisk
+	}
+	return risks, nil
+}
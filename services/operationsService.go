@@ -0,0 +1,70 @@
+package services
+
+import (
+	"RoyDental/cache"
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrInvalidCacheEntity is returned for cache entities that are not a lowercase name such as patient
+var ErrInvalidCacheEntity = errors.New("invalid cache entity")
+
+// cacheEntityPattern is what the entity names cache keys are built with look like
+var cacheEntityPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// OperationsService backs the admin console's operations tools: the state of the cache and
+// flushing or cleaning it, and how the background job queues are doing
+type OperationsService struct {
+	cache       *cache.Cache
+	maintenance *CacheMaintenanceService
+	repository  *repositories.JobQueueRepository
+	clock       clock.Clock
+}
+
+func NewOperationsService(cache *cache.Cache, maintenance *CacheMaintenanceService, repository *repositories.JobQueueRepository, clock clock.Clock) *OperationsService {
+	return &OperationsService{cache: cache, maintenance: maintenance, repository: repository, clock: clock}
+}
+
+// CacheStatus returns the state of the cache of the branch of ctx
+func (s *OperationsService) CacheStatus(ctx context.Context) cache.Status {
+	return s.cache.Status(ctx)
+}
+
+// FlushCache drops every entry cached for the branch of ctx
+func (s *OperationsService) FlushCache(ctx context.Context) error {
+	if err := s.cache.InvalidateNamespace(ctx); err != nil {
+		return fmt.Errorf("failed to flush cache: %w", err)
+	}
+	return nil
+}
+
+// PruneCache runs the cache maintenance now instead of waiting for its next run
+func (s *OperationsService) PruneCache(ctx context.Context) (*models.CacheMaintenanceRun, error) {
+	run, err := s.maintenance.Prune(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clean cache: %w", err)
+	}
+	return run, nil
+}
+
+// InvalidateCacheEntity drops the entries cached under entity for the branch of ctx, such as every
+// cached patient
+func (s *OperationsService) InvalidateCacheEntity(ctx context.Context, entity string) error {
+	if !cacheEntityPattern.MatchString(entity) {
+		return fmt.Errorf("%w: %q", ErrInvalidCacheEntity, entity)
+	}
+	if err := s.cache.InvalidateEntity(ctx, entity); err != nil {
+		return fmt.Errorf("failed to invalidate cached %s: %w", entity, err)
+	}
+	return nil
+}
+
+// JobQueues returns the background job queues with their jobs counted by status
+func (s *OperationsService) JobQueues(ctx context.Context) ([]models.JobQueue, error) {
+	return s.repository.Queues(ctx, s.clock.Now())
+}
@@ -0,0 +1,43 @@
+package services
+
+import "RoyDental/models"
+
+const (
+	// defaultListPageSize is the page size of a paginated list when none is asked for.
+	defaultListPageSize = 50
+	// maxListPageSize caps the page size of a paginated list.
+	maxListPageSize = 500
+)
+
+// pageRequest decodes the ?after= cursor and bounds the requested page size
+func pageRequest(after string, limit int) (*models.ListCursor, int, error) {
+	if limit <= 0 {
+		limit = defaultListPageSize
+	}
+	if limit > maxListPageSize {
+		limit = maxListPageSize
+	}
+	if after == "" {
+		return nil, limit, nil
+	}
+	cursor, err := models.ParseListCursor(after)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cursor, limit, nil
+}
+
+// newListPage builds a page from rows read with one row more than limit, which tells whether
+// another page follows
+func newListPage[T any](rows []T, limit int, cursor func(row *T) models.ListCursor) *models.ListPage[T] {
+	page := &models.ListPage[T]{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		page.HasMore = true
+		page.NextCursor = cursor(&page.Items[limit-1]).String()
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
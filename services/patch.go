@@ -0,0 +1,24 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPatch is returned for a PATCH body that is not a JSON object or does not fit the record
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// applyPatch merges a JSON merge patch into record, loaded as it is stored: only the fields present
+// in the patch change, nested objects such as the address are merged field by field, and null clears
+// the optional fields. The merged record then goes through the same checks as a full update.
+func applyPatch(record any, patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return fmt.Errorf("%w: the body must be a JSON object", ErrInvalidPatch)
+	}
+	if err := json.Unmarshal(patch, record); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrPatientAlertNotFound = errors.New("patient alert not found")
+	ErrInvalidPatientAlert  = errors.New("invalid patient alert")
+)
+
+// PatientAlertService keeps the risk alerts staff must see before treating a patient, such as
+// anticoagulant use or a penicillin allergy. Warning and critical alerts have to be acknowledged at
+// each appointment before treatment starts.
+type PatientAlertService struct {
+	repository        *repositories.PatientAlertRepository
+	patientRepository *repositories.PatientRepository
+}
+
+func NewPatientAlertService(repository *repositories.PatientAlertRepository, patientRepository *repositories.PatientRepository) *PatientAlertService {
+	return &PatientAlertService{repository: repository, patientRepository: patientRepository}
+}
+
+func (s *PatientAlertService) Create(ctx context.Context, alert *models.PatientAlert) error {
+	if err := s.checkPatient(ctx, alert.PatientID); err != nil {
+		return err
+	}
+	if err := validatePatientAlert(alert); err != nil {
+		return err
+	}
+	alert.ID = 0
+	alert.Active = true
+	return s.repository.Create(ctx, alert)
+}
+
+func (s *PatientAlertService) Get(ctx context.Context, patientID string, id uint) (*models.PatientAlert, error) {
+	alert, err := s.repository.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrPatientAlertNotFound
+	}
+	return alert, nil
+}
+
+// List returns the patient's active alerts, or all of them when all is set, most severe first
+func (s *PatientAlertService) List(ctx context.Context, patientID string, all bool) ([]models.PatientAlert, error) {
+	if err := s.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	alerts, err := s.repository.List(ctx, patientID, all)
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []models.PatientAlert{}
+	}
+	return alerts, nil
+}
+
+// Update changes an alert; alerts that no longer apply are deactivated
+func (s *PatientAlertService) Update(ctx context.Context, alert *models.PatientAlert) error {
+	if _, err := s.Get(ctx, alert.PatientID, alert.ID); err != nil {
+		return err
+	}
+	if err := validatePatientAlert(alert); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, alert)
+}
+
+// Acknowledge records that the patient's alerts were read before treatment at the appointment
+func (s *PatientAlertService) Acknowledge(ctx context.Context, patientID string, appointmentID uint, alertIDs []uint) ([]models.VisitAlert, error) {
+	if len(alertIDs) == 0 {
+		return nil, fmt.Errorf("%w: alert_ids is required", ErrInvalidPatientAlert)
+	}
+	if err := s.repository.Acknowledge(ctx, patientID, appointmentID, alertIDs); err != nil {
+		return nil, err
+	}
+	return s.repository.ListForVisit(ctx, patientID, appointmentID)
+}
+
+func (s *PatientAlertService) checkPatient(ctx context.Context, patientID string) error {
+	patient, err := s.patientRepository.GetByID(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	if patient == nil {
+		return ErrPatientNotFound
+	}
+	return nil
+}
+
+func validatePatientAlert(alert *models.PatientAlert) error {
+	alert.Note = strings.TrimSpace(alert.Note)
+	if !models.IsValidAlertType(alert.Type) {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPatientAlert, alert.Type)
+	}
+	if !models.IsValidAlertSeverity(alert.Severity) {
+		return fmt.Errorf("%w: severity must be info, warning or critical", ErrInvalidPatientAlert)
+	}
+	if alert.Type == models.AlertTypeOther && alert.Note == "" {
+		return fmt.Errorf("%w: a note is required for other alerts", ErrInvalidPatientAlert)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/pdf"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxChartImages bounds the radiographs a chart shows, the latest ones, so charts of patients
+	// imaged for years stay a size that can be sent
+	maxChartImages = 24
+	// chartImageWidth is how wide radiographs are drawn in a chart, in points
+	chartImageWidth = 240.0
+)
+
+// periodontalConditions are the findings gathered in a chart's periodontal section
+var periodontalConditions = map[string]bool{
+	models.FindingPeriodontitis: true,
+	models.FindingGingivitis:    true,
+	models.FindingMobility:      true,
+}
+
+// PatientChartService writes a patient's complete clinical chart as one PDF, for transferring the
+// patient to another practice
+type PatientChartService struct {
+	repository  *repositories.PatientChartRepository
+	patientRepo *repositories.PatientRepository
+	clinicName  string
+	clock       clock.Clock
+}
+
+func NewPatientChartService(repository *repositories.PatientChartRepository, patientRepo *repositories.PatientRepository, clinicName string, clock clock.Clock) *PatientChartService {
+	return &PatientChartService{repository: repository, patientRepo: patientRepo, clinicName: clinicName, clock: clock}
+}
+
+// PDF returns the patient's chart: their details, alerts, allergies, visits and vitals, every
+// examination, the periodontal findings, treatment plans and prescriptions, and previews of their
+// latest radiographs
+func (s *PatientChartService) PDF(ctx context.Context, patientID string) ([]byte, error) {
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	chart := &models.PatientChart{Patient: *patient}
+	if err := s.repository.Chart(ctx, chart, maxChartImages); err != nil {
+		return nil, err
+	}
+	return s.render(chart), nil
+}
+
+func (s *PatientChartService) render(chart *models.PatientChart) []byte {
+	patient := chart.Patient
+	doc := pdf.New()
+	doc.Title(s.clinicName + " - Clinical chart")
+	doc.Text("Exported on " + models.FormatDateTime(s.clock.Now().In(models.ClinicLocation())) + " for transfer of care.")
+
+	doc.Heading("Patient")
+	doc.Text("Name: " + strings.Join(strings.Fields(patient.FirstName+" "+patient.MiddleName+" "+patient.LastName), " "))
+	doc.Text("Date of birth: " + patient.DateOfBirth)
+	doc.Text("Sex: " + patient.Sex)
+	chartLine(doc, "National ID", patient.NationalID)
+	chartLine(doc, "Phone", patient.Phone)
+	chartLine(doc, "Email", patient.Email)
+	address := patient.Address.Trimmed()
+	chartLine(doc, "Address", strings.Join(nonEmpty(address.Street, address.City, address.County, address.PostalCode), ", "))
+	chartLine(doc, "Occupation", patient.Occupation)
+	if patient.Insured {
+		chartLine(doc, "Insurance", strings.Join(nonEmpty(patient.InsuranceCompany, patient.Scheme, patient.MemberNumber), ", "))
+	}
+	if guarantor := patient.Guarantor.Trimmed(); !guarantor.IsZero() {
+		chartLine(doc, "Guarantor", strings.Join(nonEmpty(guarantor.Name, guarantor.Relationship, guarantor.Phone, guarantor.Email), ", "))
+	}
+
+	doc.Heading("Alerts")
+	if len(chart.Alerts) == 0 {
+		doc.Text("No alerts were recorded.")
+	}
+	for _, alert := range chart.Alerts {
+		line := fmt.Sprintf("%s (%s)", strings.ReplaceAll(alert.Type, "_", " "), alert.Severity)
+		if alert.Note != "" {
+			line += ": " + alert.Note
+		}
+		if !alert.Active {
+			line += " - no longer active"
+		}
+		doc.Text("- " + line)
+	}
+
+	doc.Heading("Allergies")
+	if len(chart.Allergies) == 0 {
+		doc.Text("No allergies were recorded.")
+	}
+	for _, allergy := range chart.Allergies {
+		line := fmt.Sprintf("%s (%s)", allergy.Substance, allergy.Severity)
+		if allergy.Reaction != "" {
+			line += ": " + allergy.Reaction
+		}
+		doc.Text("- " + line)
+	}
+
+	doc.Heading("Visits")
+	if len(chart.Appointments) == 0 {
+		doc.Text("No visits were booked.")
+	}
+	for _, appointment := range chart.Appointments {
+		doc.Text(fmt.Sprintf("%s: %s, %s, Dr %s %s", chartDay(visitDay(&appointment)), appointment.Type,
+			strings.ReplaceAll(appointment.Status, "_", " "), appointment.Doctor.FirstName, appointment.Doctor.LastName))
+	}
+
+	doc.Heading("Vitals")
+	if len(chart.Vitals) == 0 {
+		doc.Text("No vitals were recorded.")
+	}
+	for _, vitals := range chart.Vitals {
+		line := fmt.Sprintf("%s: blood pressure %d/%d, pulse %d", chartDay(vitals.CreatedAt), vitals.SystolicBP, vitals.DiastolicBP, vitals.Pulse)
+		if vitals.Notes != "" {
+			line += ". " + vitals.Notes
+		}
+		doc.Text(line)
+	}
+
+	doc.Heading("Examinations")
+	if len(chart.Examinations) == 0 {
+		doc.Text("No examinations were recorded.")
+	}
+	for _, examination := range chart.Examinations {
+		doc.Text(chartDay(examination.CreatedAt) + ":")
+		doc.Text(strings.TrimSpace(examination.Report))
+		for _, finding := range examination.Findings {
+			doc.Text("- " + describeFinding(finding))
+		}
+		for _, attachment := range examination.Attachments {
+			doc.Text("Attached: " + attachment.FileName)
+		}
+		doc.Space(4)
+	}
+
+	doc.Heading("Periodontal chart")
+	periodontal := 0
+	for _, examination := range chart.Examinations {
+		for _, finding := range examination.Findings {
+			if periodontalConditions[finding.Condition] {
+				doc.Text(chartDay(examination.CreatedAt) + ": " + describeFinding(finding))
+				periodontal++
+			}
+		}
+	}
+	if periodontal == 0 {
+		doc.Text("No periodontal findings were recorded.")
+	}
+
+	doc.Heading("Treatment plans")
+	if len(chart.TreatmentPlans) == 0 {
+		doc.Text("No treatment plans were made.")
+	}
+	for _, plan := range chart.TreatmentPlans {
+		line := fmt.Sprintf("%s, version %d", chartDay(plan.CreatedAt), plan.Version)
+		if plan.EstimatedCost != nil {
+			line += ", estimated at " + models.FormatMoney(*plan.EstimatedCost)
+		}
+		doc.Text(line + ":")
+		doc.Text(strings.TrimSpace(plan.Plan))
+		doc.Space(4)
+	}
+
+	doc.Heading("Prescriptions")
+	if len(chart.Prescriptions) == 0 {
+		doc.Text("No prescriptions were written.")
+	}
+	for _, prescription := range chart.Prescriptions {
+		line := fmt.Sprintf("%s: %s", chartDay(prescription.CreatedAt), strings.Join(nonEmpty(prescription.Drug, prescription.Dose, prescription.Frequency), ", "))
+		line += fmt.Sprintf(" for %d days", prescription.DurationDays)
+		if prescription.Controlled {
+			line += " (controlled)"
+		}
+		if prescription.Instructions != "" {
+			line += ". " + prescription.Instructions
+		}
+		doc.Text(line)
+	}
+
+	doc.Heading("Radiographs")
+	if len(chart.Images) == 0 {
+		doc.Text("No radiographs were taken.")
+	}
+	if len(chart.Images) == maxChartImages {
+		doc.Text(fmt.Sprintf("The latest %d radiographs are shown; the practice holds the others.", maxChartImages))
+	}
+	for _, study := range chart.Images {
+		img, err := png.Decode(bytes.NewReader(study.Preview))
+		if err != nil {
+			log.Printf("Failed to read the preview of imaging study %d: %v", study.ID, err)
+			continue
+		}
+		taken := study.CreatedAt
+		if study.StudyDate != nil {
+			taken = *study.StudyDate
+		}
+		doc.Space(6)
+		doc.Picture(img, chartImageWidth)
+		doc.Text(strings.Join(nonEmpty(chartDay(taken), study.Modality, study.FileName), ", "))
+	}
+	return doc.Bytes()
+}
+
+// chartLine writes a labelled line of the chart's patient details, unless the value is empty
+func chartLine(doc *pdf.Document, label, value string) {
+	if value != "" {
+		doc.Text(label + ": " + value)
+	}
+}
+
+// chartDay writes the clinic day of t
+func chartDay(t time.Time) string {
+	return models.FormatDate(t.In(models.ClinicLocation()))
+}
+
+// describeFinding writes a structured finding as a line, such as "caries stage dentine on teeth 16, 17"
+func describeFinding(finding models.Finding) string {
+	parts := []string{strings.ReplaceAll(finding.Condition, "_", " ")}
+	if finding.Stage != "" {
+		parts = append(parts, "stage "+strings.ReplaceAll(finding.Stage, "_", " "))
+	}
+	if finding.Grade != "" {
+		parts = append(parts, "grade "+finding.Grade)
+	}
+	if finding.Extent != "" {
+		parts = append(parts, strings.ReplaceAll(finding.Extent, "_", " "))
+	}
+	if len(finding.Teeth) > 0 {
+		teeth := make([]string, len(finding.Teeth))
+		for i, tooth := range finding.Teeth {
+			teeth[i] = strconv.Itoa(tooth)
+		}
+		parts = append(parts, "on teeth "+strings.Join(teeth, ", "))
+	}
+	line := strings.Join(parts, " ")
+	if finding.Note != "" {
+		line += ": " + finding.Note
+	}
+	return line
+}
+
+// nonEmpty returns the values that are not empty
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"strconv"
+)
+
+// PatientHistoryService pages through a patient's full history of appointments, bills,
+// examinations and treatment plans, newest first. The patient itself only carries a summary.
+type PatientHistoryService struct {
+	patientRepo       *repositories.PatientRepository
+	appointmentRepo   *repositories.AppointmentRepository
+	billingRepo       *repositories.BillingRepository
+	examinationRepo   *repositories.ExaminationRepository
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+}
+
+func NewPatientHistoryService(patientRepo *repositories.PatientRepository, appointmentRepo *repositories.AppointmentRepository, billingRepo *repositories.BillingRepository,
+	examinationRepo *repositories.ExaminationRepository, treatmentPlanRepo *repositories.TreatmentPlanRepository) *PatientHistoryService {
+	return &PatientHistoryService{patientRepo: patientRepo, appointmentRepo: appointmentRepo, billingRepo: billingRepo, examinationRepo: examinationRepo, treatmentPlanRepo: treatmentPlanRepo}
+}
+
+// AppointmentsPage returns a page of the patient's appointments newest first, after the ?after= cursor of the previous page
+func (s *PatientHistoryService) AppointmentsPage(ctx context.Context, patientID, after string, limit int) (*models.ListPage[models.Appointment], error) {
+	cursor, limit, err := s.pageRequest(ctx, patientID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.appointmentRepo.ListByPatientAfter(ctx, patientID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Appointment) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: strconv.FormatUint(uint64(row.ID), 10)}
+	}), nil
+}
+
+// BillingsPage returns a page of the patient's billings newest first, after the ?after= cursor of the previous page
+func (s *PatientHistoryService) BillingsPage(ctx context.Context, patientID, after string, limit int) (*models.ListPage[models.Billing], error) {
+	cursor, limit, err := s.pageRequest(ctx, patientID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.billingRepo.ListByPatientAfter(ctx, patientID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Billing) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: row.BillingID}
+	}), nil
+}
+
+// ExaminationsPage returns a page of the patient's examinations newest first, after the ?after= cursor of the previous page
+func (s *PatientHistoryService) ExaminationsPage(ctx context.Context, patientID, after string, limit int) (*models.ListPage[models.Examination], error) {
+	cursor, limit, err := s.pageRequest(ctx, patientID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.examinationRepo.ListByPatientAfter(ctx, patientID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.Examination) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: strconv.FormatUint(uint64(row.ID), 10)}
+	}), nil
+}
+
+// TreatmentPlansPage returns a page of the patient's treatment plans newest first, after the ?after= cursor of the previous page
+func (s *PatientHistoryService) TreatmentPlansPage(ctx context.Context, patientID, after string, limit int) (*models.ListPage[models.TreatmentPlan], error) {
+	cursor, limit, err := s.pageRequest(ctx, patientID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.treatmentPlanRepo.ListByPatientAfter(ctx, patientID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	return newListPage(rows, limit, func(row *models.TreatmentPlan) models.ListCursor {
+		return models.ListCursor{CreatedAt: row.CreatedAt, Key: strconv.FormatUint(uint64(row.ID), 10)}
+	}), nil
+}
+
+// pageRequest checks the patient exists before decoding the cursor and page size
+func (s *PatientHistoryService) pageRequest(ctx context.Context, patientID, after string, limit int) (*models.ListCursor, int, error) {
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if patient == nil {
+		return nil, 0, ErrPatientNotFound
+	}
+	return pageRequest(after, limit)
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/payments"
+	"RoyDental/repositories"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// portalPastVisitLimit bounds the past visits the portal lists
+const portalPastVisitLimit = 50
+
+var (
+	// ErrPatientNotLinked is returned when the signed-in user has no patient record
+	ErrPatientNotLinked = errors.New("no patient is linked to this user")
+	// ErrPortalBillingNotFound is returned for bills that are not the signed-in patient's
+	ErrPortalBillingNotFound = errors.New("billing not found")
+	// ErrInvalidStatement is returned for statements asked for with unreadable or reversed days
+	ErrInvalidStatement = errors.New("invalid statement period")
+	// ErrInvalidOnlinePayment is returned for payments of nothing or of more than is owed
+	ErrInvalidOnlinePayment = errors.New("invalid online payment")
+	// ErrOnlinePaymentsDisabled is returned when no payment gateway is configured
+	ErrOnlinePaymentsDisabled = errors.New("online payments are not available")
+	// ErrPaymentGateway is returned when the gateway does not start a payment
+	ErrPaymentGateway = errors.New("payment gateway failed")
+	// ErrOnlinePaymentNotFound is returned for callbacks about payments we did not start
+	ErrOnlinePaymentNotFound = errors.New("online payment not found")
+	// ErrInvalidPaymentCallback is returned for callbacks that are unsigned, unreadable or do not
+	// match the payment
+	ErrInvalidPaymentCallback = errors.New("invalid payment callback")
+)
+
+// PatientPortalService lets patients signed in to the portal see their visits and what they owe,
+// and pay it online.
+// The gateway reports each payment's outcome through a signed callback; a succeeded payment is
+// added to the bill and the patient is emailed a receipt.
+type PatientPortalService struct {
+	gateway         payments.Gateway
+	repository      *repositories.OnlinePaymentRepository
+	patientRepo     *repositories.PatientRepository
+	billingRepo     *repositories.BillingRepository
+	appointmentRepo *repositories.AppointmentRepository
+	notifier        notifications.Notifier
+	config          config.PaymentGatewayConfig
+}
+
+func NewPatientPortalService(repository *repositories.OnlinePaymentRepository, patientRepo *repositories.PatientRepository, billingRepo *repositories.BillingRepository, appointmentRepo *repositories.AppointmentRepository, notifier notifications.Notifier, cfg config.PaymentGatewayConfig) *PatientPortalService {
+	gateway, err := payments.New(cfg)
+	if err != nil {
+		log.Printf("Online payments disabled: %v", err)
+	}
+	return &PatientPortalService{
+		gateway:         gateway,
+		repository:      repository,
+		patientRepo:     patientRepo,
+		billingRepo:     billingRepo,
+		appointmentRepo: appointmentRepo,
+		notifier:        notifier,
+		config:          cfg,
+	}
+}
+
+// PaymentsEnabled reports whether a payment gateway is configured
+func (s *PatientPortalService) PaymentsEnabled() bool {
+	return s.gateway != nil
+}
+
+func (s *PatientPortalService) patient(ctx context.Context, userID int64) (*models.Patient, error) {
+	patient, err := s.patientRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotLinked
+	}
+	return patient, nil
+}
+
+// Appointments returns the signed-in patient's upcoming visits and their latest past ones
+func (s *PatientPortalService) Appointments(ctx context.Context, userID int64) (*models.PatientVisits, error) {
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.appointmentRepo.ListPatientVisits(ctx, patient.ID, time.Now(), portalPastVisitLimit)
+}
+
+// Billings returns the signed-in patient's bills newest first
+func (s *PatientPortalService) Billings(ctx context.Context, userID int64) ([]models.Billing, error) {
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	billings, err := s.billingRepo.ListByPatient(ctx, patient.ID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	newest := make([]models.Billing, 0, len(billings))
+	for i := len(billings) - 1; i >= 0; i-- {
+		newest = append(newest, billings[i])
+	}
+	return newest, nil
+}
+
+// Balance returns what the signed-in patient was billed, has paid and still owes
+func (s *PatientPortalService) Balance(ctx context.Context, userID int64) (*models.PatientBalance, error) {
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.billingRepo.PatientBalance(ctx, patient.ID, time.Time{})
+}
+
+// Statement lists the signed-in patient's bills from the clinic day from to the day to, both
+// YYYY-MM-DD. To defaults to today and from to the first of January of to's year.
+func (s *PatientPortalService) Statement(ctx context.Context, userID int64, from, to string) (*models.PatientStatement, error) {
+	start, end, err := statementPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return patientStatement(ctx, s.billingRepo, patient.ID, start, end)
+}
+
+// statementPeriod reads the clinic days from and to, both YYYY-MM-DD, a statement runs over. To
+// defaults to today and from to the first of January of to's year.
+func statementPeriod(from, to string) (time.Time, time.Time, error) {
+	end, _ := models.ClinicDay(models.ClinicNow())
+	if to != "" {
+		day, err := models.ParseClinicDate(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be YYYY-MM-DD", ErrInvalidStatement)
+		}
+		end = day
+	}
+	start := time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, end.Location())
+	if from != "" {
+		day, err := models.ParseClinicDate(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be YYYY-MM-DD", ErrInvalidStatement)
+		}
+		start = day
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from is after to", ErrInvalidStatement)
+	}
+	return start, end, nil
+}
+
+// patientStatement lists a patient's bills from the clinic day start through the day end
+func patientStatement(ctx context.Context, billingRepo *repositories.BillingRepository, patientID string, start, end time.Time) (*models.PatientStatement, error) {
+	opening, err := billingRepo.PatientBalance(ctx, patientID, start)
+	if err != nil {
+		return nil, err
+	}
+	billings, err := billingRepo.ListByPatient(ctx, patientID, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.PatientStatement{
+		PatientID:      patientID,
+		From:           start.Format("2006-01-02"),
+		To:             end.Format("2006-01-02"),
+		OpeningBalance: opening.Balance,
+		ClosingBalance: opening.Balance,
+		Billings:       billings,
+	}
+	if statement.Billings == nil {
+		statement.Billings = []models.Billing{}
+	}
+	for _, billing := range billings {
+		statement.Billed += billing.BillingAmount
+		statement.Received += billing.TotalReceived
+		statement.ClosingBalance += billing.Balance
+	}
+	return statement, nil
+}
+
+// Payments returns the signed-in patient's online payments newest first
+func (s *PatientPortalService) Payments(ctx context.Context, userID int64) ([]models.OnlinePayment, error) {
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.repository.ListByPatient(ctx, patient.ID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []models.OnlinePayment{}
+	}
+	return list, nil
+}
+
+// StartPayment starts paying amount of one of the signed-in patient's bills at the gateway, or the
+// whole balance when amount is zero. The patient completes it on the returned payment's PaymentURL.
+func (s *PatientPortalService) StartPayment(ctx context.Context, userID int64, billingID string, amount float64) (*models.OnlinePayment, error) {
+	if s.gateway == nil {
+		return nil, ErrOnlinePaymentsDisabled
+	}
+	patient, err := s.patient(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil || billing.PatientID != patient.ID {
+		return nil, ErrPortalBillingNotFound
+	}
+	if amount == 0 {
+		amount = billing.Balance
+	}
+	amount = roundCents(amount)
+	switch {
+	case billing.Balance < 0.005:
+		return nil, fmt.Errorf("%w: the bill is paid", ErrInvalidOnlinePayment)
+	case amount <= 0:
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOnlinePayment)
+	case amount > billing.Balance+0.005:
+		return nil, fmt.Errorf("%w: amount is more than the balance of %.2f", ErrInvalidOnlinePayment, billing.Balance)
+	}
+
+	reference, err := newPaymentReference()
+	if err != nil {
+		return nil, err
+	}
+	payment := &models.OnlinePayment{
+		Reference: reference,
+		PatientID: patient.ID,
+		BillingID: billing.BillingID,
+		Amount:    amount,
+		Currency:  s.config.Currency,
+		Status:    models.OnlinePaymentPending,
+	}
+	if err := s.repository.Create(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	checkoutCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	checkout, err := s.gateway.Checkout(checkoutCtx, payments.CheckoutRequest{
+		Reference:   payment.Reference,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Description: fmt.Sprintf("%s (%s)", billing.Procedure, billing.BillingID),
+		Email:       patient.Email,
+		ReturnURL:   s.config.ReturnURL,
+	})
+	cancel()
+	if err != nil {
+		if _, markErr := s.repository.MarkFailed(ctx, payment.ID, "", err.Error()); markErr != nil {
+			log.Printf("Failed to mark online payment %s failed: %v", payment.Reference, markErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPaymentGateway, err)
+	}
+	if err := s.repository.SaveCheckout(ctx, payment.ID, checkout.GatewayReference, checkout.PaymentURL); err != nil {
+		return nil, err
+	}
+	payment.GatewayReference, payment.PaymentURL = checkout.GatewayReference, checkout.PaymentURL
+	return payment, nil
+}
+
+// HandleCallback applies the outcome the gateway reports for a payment. The body must carry the
+// gateway's signature; callbacks repeated for a payment already completed change nothing.
+func (s *PatientPortalService) HandleCallback(ctx context.Context, body []byte, signature string) (*models.OnlinePayment, error) {
+	if s.gateway == nil {
+		return nil, ErrOnlinePaymentsDisabled
+	}
+	if !payments.VerifyCallback(s.config.CallbackSecret, body, signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidPaymentCallback)
+	}
+	var callback payments.Callback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPaymentCallback, err)
+	}
+	payment, err := s.repository.GetByReference(ctx, callback.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, ErrOnlinePaymentNotFound
+	}
+
+	switch callback.Status {
+	case payments.StatusSucceeded:
+		if math.Abs(callback.Amount-payment.Amount) >= 0.005 {
+			return nil, fmt.Errorf("%w: paid %.2f of %.2f", ErrInvalidPaymentCallback, callback.Amount, payment.Amount)
+		}
+		recorded, err := s.billingRepo.RecordOnlinePayment(ctx, payment, callback.GatewayReference)
+		if err != nil {
+			return nil, err
+		}
+		if recorded {
+			s.sendReceipt(ctx, payment)
+		}
+	case payments.StatusFailed:
+		if _, err := s.repository.MarkFailed(ctx, payment.ID, callback.GatewayReference, callback.Reason); err != nil {
+			return nil, err
+		}
+		payment, err = s.repository.GetByReference(ctx, callback.Reference)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPaymentCallback, callback.Status)
+	}
+	return payment, nil
+}
+
+// sendReceipt emails the patient a receipt for a succeeded payment. The payment stands whether or
+// not the receipt could be sent.
+func (s *PatientPortalService) sendReceipt(ctx context.Context, payment *models.OnlinePayment) {
+	receipt, err := s.repository.Receipt(ctx, payment.ID)
+	if err != nil || receipt == nil {
+		log.Printf("Failed to get the receipt of online payment %s: %v", payment.Reference, err)
+		return
+	}
+	if receipt.PatientEmail == "" {
+		return
+	}
+	notification := notifications.Notification{
+		Recipients: []string{receipt.PatientEmail},
+		PatientID:  payment.PatientID,
+		Purpose:    notifications.PurposeService,
+		Subject:    fmt.Sprintf("Payment receipt %s", payment.Reference),
+		Body: fmt.Sprintf("Dear %s,\n\nThank you for your payment of %s for %s (bill %s), received on %s.\nReference: %s\nBalance remaining: %s\n",
+			receipt.PatientFirstName, models.FormatAmount(payment.Currency, payment.Amount), receipt.Procedure, payment.BillingID,
+			models.FormatDateTime(payment.CompletedAt.In(models.ClinicLocation())), payment.Reference, models.FormatMoney(receipt.Balance)),
+	}
+	if err := s.notifier.Send(ctx, notification); err != nil && !errors.Is(err, notifications.ErrDeferred) {
+		log.Printf("Failed to send the receipt of online payment %s: %v", payment.Reference, err)
+		return
+	}
+	if err := s.repository.MarkReceiptSent(ctx, payment.ID); err != nil {
+		log.Printf("Failed to record the receipt of online payment %s: %v", payment.Reference, err)
+	}
+}
+
+// newPaymentReference returns a random reference the gateway reports a payment back by
+func newPaymentReference() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate payment reference: %w", err)
+	}
+	return "OP-" + hex.EncodeToString(id), nil
+}
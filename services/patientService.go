@@ -1,39 +1,225 @@
 package services
 
 import (
+	"RoyDental/config"
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"time"
 )
 
+// ErrInvalidGuarantor is returned for patients under the adult age without a guarantor, and for
+// guarantors who cannot be reached
+var ErrInvalidGuarantor = errors.New("invalid guarantor")
+
+// ErrInvalidAttribution is returned for a patient whose source is not one of models.Sources
+var ErrInvalidAttribution = errors.New("invalid source")
+
 type PatientService struct {
-	repository *repositories.PatientRepository
+	repository   *repositories.PatientRepository
+	customFields *CustomFieldService
+	alerts       *repositories.PatientAlertRepository
+	billingRepo  *repositories.BillingRepository
+	approvals    *ApprovalService
+	guarantors   config.GuarantorConfig
+}
+
+// patientDeletion is the deletion of a patient with billing history saved until it is approved
+type patientDeletion struct {
+	Related bool `json:"related"`
 }
 
-func NewPatientService(repository *repositories.PatientRepository) *PatientService {
-	return &PatientService{repository: repository}
+// NewPatientService registers the deletion of patients with billing history with approvals, which
+// hold it back until an admin approves it. Patients under guarantors.AdultAge must have a guarantor.
+func NewPatientService(repository *repositories.PatientRepository, customFields *CustomFieldService, alerts *repositories.PatientAlertRepository,
+	billingRepo *repositories.BillingRepository, approvals *ApprovalService, guarantors config.GuarantorConfig) *PatientService {
+	s := &PatientService{repository: repository, customFields: customFields, alerts: alerts, billingRepo: billingRepo, approvals: approvals, guarantors: guarantors}
+	approvals.Register(models.ApprovalActionPatientDeletion, s.executeDeletion)
+	return s
 }
 
 func (s *PatientService) Create(ctx context.Context, patient *models.Patient) error {
+	if err := checkGuarantor(&patient.Guarantor, patient.DateOfBirth, s.guarantors.AdultAge, ErrInvalidGuarantor); err != nil {
+		return err
+	}
+	if err := checkAttribution(&patient.Attribution, ErrInvalidAttribution); err != nil {
+		return err
+	}
+	if patient.CustomFields == nil {
+		patient.CustomFields = models.CustomFieldValues{}
+	}
+	if err := s.customFields.CheckValues(ctx, models.CustomFieldEntityPatient, patient.CustomFields); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, patient)
+}
+
+// Import registers a patient sent by another system, which cannot fill in the clinic's custom fields
+// nor is held to name a guarantor
+func (s *PatientService) Import(ctx context.Context, patient *models.Patient) error {
+	patient.CustomFields = models.CustomFieldValues{}
 	return s.repository.Create(ctx, patient)
 }
 
+// GetByID returns a patient with their active alerts, most severe first, and a summary of their
+// record
 func (s *PatientService) GetByID(ctx context.Context, id string) (*models.Patient, error) {
-	return s.repository.GetByID(ctx, id)
+	patient, err := s.repository.GetByID(ctx, id)
+	if err != nil || patient == nil {
+		return patient, err
+	}
+	if patient.Alerts, err = s.alerts.List(ctx, id, false); err != nil {
+		return nil, err
+	}
+	if patient.Summary, err = s.summary(ctx, id); err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
+
+// summary outlines the patient's record with the latest few of their appointments and bills
+func (s *PatientService) summary(ctx context.Context, id string) (*models.PatientSummary, error) {
+	summary, err := s.repository.Summary(ctx, id, models.PatientSummaryRecent, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if summary.Balance, err = s.billingRepo.PatientBalance(ctx, id, time.Time{}); err != nil {
+		return nil, err
+	}
+	if summary.UpcomingAppointments == nil {
+		summary.UpcomingAppointments = []models.Appointment{}
+	}
+	if summary.RecentAppointments == nil {
+		summary.RecentAppointments = []models.Appointment{}
+	}
+	if summary.RecentBillings == nil {
+		summary.RecentBillings = []models.Billing{}
+	}
+	return summary, nil
 }
 
 func (s *PatientService) GetAll(ctx context.Context) ([]models.Patient, error) {
 	return s.repository.GetAll(ctx)
 }
 
+// Stream hands every patient to fn newest first, for lists too large to build in memory
+func (s *PatientService) Stream(ctx context.Context, fn func(models.Patient) error) error {
+	return s.repository.Stream(ctx, fn)
+}
+
+// StreamFiltered hands the patients matching query to fn in the order it asks for
+func (s *PatientService) StreamFiltered(ctx context.Context, query models.ListQuery, fn func(models.Patient) error) error {
+	return s.repository.StreamFiltered(ctx, query, fn)
+}
+
+// Update changes a patient. One updated without custom_fields keeps the values it has.
 func (s *PatientService) Update(ctx context.Context, patient *models.Patient) error {
+	if err := checkGuarantor(&patient.Guarantor, patient.DateOfBirth, s.guarantors.AdultAge, ErrInvalidGuarantor); err != nil {
+		return err
+	}
+	if err := checkAttribution(&patient.Attribution, ErrInvalidAttribution); err != nil {
+		return err
+	}
+	if patient.CustomFields != nil {
+		if err := s.customFields.CheckValues(ctx, models.CustomFieldEntityPatient, patient.CustomFields); err != nil {
+			return err
+		}
+	}
 	return s.repository.Update(ctx, patient)
 }
 
+// Patch changes only the fields present in patch, a JSON merge patch, keeping the others as they are
+func (s *PatientService) Patch(ctx context.Context, id string, patch []byte) (*models.Patient, error) {
+	patient, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	if err := applyPatch(patient, patch); err != nil {
+		return nil, err
+	}
+	patient.ID = id
+	patient.PrimaryContact, patient.Alerts, patient.Summary = nil, nil, nil
+	patient.EmergencyContacts, patient.Examinations, patient.Billings, patient.TreatmentPlans, patient.Appointments = nil, nil, nil, nil, nil
+	if err := s.Update(ctx, patient); err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
+
+// Delete deletes a patient. A patient with billing history is only deleted once an admin approves
+// it, and an *ApprovalRequiredError is returned meanwhile.
 func (s *PatientService) Delete(ctx context.Context, id string) error {
+	if err := s.checkDeletion(ctx, id, patientDeletion{}); err != nil {
+		return err
+	}
 	return s.repository.Delete(ctx, id)
 }
 
+// DeletePatientAndRelated deletes a patient with their records, once approved like Delete
 func (s *PatientService) DeletePatientAndRelated(ctx context.Context, id string) error {
+	if err := s.checkDeletion(ctx, id, patientDeletion{Related: true}); err != nil {
+		return err
+	}
 	return s.repository.DeletePatientAndRelated(ctx, id)
 }
+
+// checkDeletion asks for approval of deleting a patient with billing history
+func (s *PatientService) checkDeletion(ctx context.Context, id string, deletion patientDeletion) error {
+	bills, err := s.billingRepo.CountByPatient(ctx, id)
+	if err != nil || bills == 0 {
+		return err
+	}
+	summary := fmt.Sprintf("Delete patient %s, who has %d bills", id, bills)
+	if deletion.Related {
+		summary = fmt.Sprintf("Delete patient %s with all their records, including %d bills", id, bills)
+	}
+	return s.approvals.Request(ctx, models.ApprovalActionPatientDeletion, id, summary, deletion)
+}
+
+func (s *PatientService) executeDeletion(ctx context.Context, approval *models.Approval) error {
+	var deletion patientDeletion
+	if err := approvalPayload(approval, &deletion); err != nil {
+		return err
+	}
+	if deletion.Related {
+		return s.repository.DeletePatientAndRelated(ctx, approval.RecordID)
+	}
+	return s.repository.Delete(ctx, approval.RecordID)
+}
+
+// checkGuarantor trims a patient's guarantor and checks that one who is named can be reached, and
+// that patients under adultAge on their date of birth have one. Failures wrap invalid.
+func checkGuarantor(guarantor *models.Guarantor, dateOfBirth string, adultAge int, invalid error) error {
+	*guarantor = guarantor.Trimmed()
+	if guarantor.IsZero() {
+		if isMinor(dateOfBirth, models.ClinicNow(), adultAge) {
+			return fmt.Errorf("%w: patients under %d must have a guarantor", invalid, adultAge)
+		}
+		return nil
+	}
+	if guarantor.Name == "" {
+		return fmt.Errorf("%w: the guarantor's name is required", invalid)
+	}
+	if !models.IsValidRelationship(guarantor.Relationship) {
+		return fmt.Errorf("%w: unknown guarantor relationship %q", invalid, guarantor.Relationship)
+	}
+	if guarantor.Phone == "" && guarantor.Email == "" {
+		return fmt.Errorf("%w: the guarantor's phone number or email address is required", invalid)
+	}
+	return nil
+}
+
+// isMinor reports whether someone born on dateOfBirth is under adultAge on day. Dates of birth that
+// cannot be read are taken to be adults'.
+func isMinor(dateOfBirth string, day time.Time, adultAge int) bool {
+	born, ok := parseDateOfBirth(dateOfBirth)
+	if !ok {
+		return false
+	}
+	return ageOn(born, day) < adultAge
+}
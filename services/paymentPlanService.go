@@ -0,0 +1,346 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// installmentReminderBatchSize caps the reminders of each kind sent per dispatch run.
+const installmentReminderBatchSize = 200
+
+var (
+	ErrPaymentPlanNotFound   = errors.New("payment plan not found")
+	ErrInvalidPaymentPlan    = errors.New("invalid payment plan")
+	ErrTreatmentPlanNotFound = errors.New("treatment plan not found")
+	ErrPaymentPlanNotActive  = errors.New("payment plan is not active")
+	ErrInstallmentNotFound   = errors.New("installment not found")
+	ErrInvalidPayment        = errors.New("invalid installment payment")
+)
+
+type PaymentPlanService struct {
+	repository        *repositories.PaymentPlanRepository
+	patientRepository *repositories.PatientRepository
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+	billingRepo       *repositories.BillingRepository
+	notifier          notifications.Notifier
+	config            config.PaymentPlanConfig
+	clock             clock.Clock
+}
+
+// NewPaymentPlanService starts flagging overdue installments and sending reminders in the background.
+func NewPaymentPlanService(repository *repositories.PaymentPlanRepository, patientRepository *repositories.PatientRepository, treatmentPlanRepo *repositories.TreatmentPlanRepository, billingRepo *repositories.BillingRepository, notifier notifications.Notifier, cfg config.PaymentPlanConfig, clock clock.Clock) *PaymentPlanService {
+	s := &PaymentPlanService{
+		repository:        repository,
+		patientRepository: patientRepository,
+		treatmentPlanRepo: treatmentPlanRepo,
+		billingRepo:       billingRepo,
+		notifier:          notifier,
+		config:            cfg,
+		clock:             clock,
+	}
+	if cfg.DispatchInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+// Create saves a plan for a patient's treatment plan or bill. The installments are either given or
+// generated from schedule; the total defaults to their sum, or to the bill's balance for a bill.
+func (s *PaymentPlanService) Create(ctx context.Context, plan *models.PaymentPlan, schedule models.InstallmentSchedule) (*models.PaymentPlanView, error) {
+	patient, err := s.patientRepository.GetByID(ctx, plan.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	if plan.TreatmentPlanID != nil {
+		treatmentPlan, err := s.treatmentPlanRepo.GetByID(ctx, plan.PatientID, *plan.TreatmentPlanID)
+		if err != nil {
+			return nil, err
+		}
+		if treatmentPlan == nil {
+			return nil, ErrTreatmentPlanNotFound
+		}
+	}
+	if plan.BillingID != nil {
+		billing, err := s.billingRepo.GetByID(ctx, *plan.BillingID)
+		if err != nil {
+			return nil, err
+		}
+		if billing == nil || billing.PatientID != plan.PatientID {
+			return nil, ErrBillingNotFound
+		}
+		if plan.TotalAmount == 0 && len(plan.Installments) == 0 {
+			plan.TotalAmount = billing.Balance
+		}
+	}
+
+	if len(plan.Installments) == 0 {
+		if plan.Installments, err = scheduleInstallments(plan.TotalAmount, schedule); err != nil {
+			return nil, err
+		}
+	}
+	if err := validatePaymentPlan(plan); err != nil {
+		return nil, err
+	}
+
+	plan.ID = 0
+	plan.Status = models.PaymentPlanStatusActive
+	plan.Description = strings.TrimSpace(plan.Description)
+	if err := s.repository.Create(ctx, plan); err != nil {
+		return nil, err
+	}
+	return paymentPlanView(*plan, s.clock.Now()), nil
+}
+
+func (s *PaymentPlanService) GetByID(ctx context.Context, id uint) (*models.PaymentPlanView, error) {
+	plan, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrPaymentPlanNotFound
+	}
+	return paymentPlanView(*plan, s.clock.Now()), nil
+}
+
+// List returns plans with their progress, for one patient when patientID is set, in one status when
+// status is set, and only those with overdue installments when overdue is true
+func (s *PaymentPlanService) List(ctx context.Context, patientID, status string, overdue bool) ([]models.PaymentPlanView, error) {
+	if status != "" && !models.IsValidPaymentPlanStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPaymentPlan, status)
+	}
+	plans, err := s.repository.List(ctx, patientID, status, overdue)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	views := make([]models.PaymentPlanView, len(plans))
+	for i, plan := range plans {
+		views[i] = *paymentPlanView(plan, now)
+	}
+	return views, nil
+}
+
+// RecordPayment adds a payment towards an installment; partial payments are kept until it is covered
+func (s *PaymentPlanService) RecordPayment(ctx context.Context, id uint, number int, amount float64) (*models.PaymentPlanView, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	amount = math.Round(amount*100) / 100
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: the amount must be positive", ErrInvalidPayment)
+	}
+	err := s.repository.RecordPayment(ctx, id, number, amount, s.clock.Now())
+	switch {
+	case errors.Is(err, repositories.ErrInstallmentNotFound):
+		return nil, ErrInstallmentNotFound
+	case errors.Is(err, repositories.ErrPaymentPlanInactive):
+		return nil, ErrPaymentPlanNotActive
+	case errors.Is(err, repositories.ErrInstallmentPaid), errors.Is(err, repositories.ErrOverpayment):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayment, err)
+	case err != nil:
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+// Cancel stops an active plan; its installments are kept for the record
+func (s *PaymentPlanService) Cancel(ctx context.Context, id uint) (*models.PaymentPlanView, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	cancelled, err := s.repository.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrPaymentPlanNotActive
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *PaymentPlanService) run() {
+	ticker := time.NewTicker(s.config.DispatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.dispatch(context.Background())
+	}
+}
+
+// dispatch flags installments that fell due unpaid, then reminds patients of installments due soon
+// and tells them once about each overdue one
+func (s *PaymentPlanService) dispatch(ctx context.Context) {
+	today, _ := models.ClinicDay(s.clock.Now())
+	if count, err := s.repository.MarkOverdue(ctx, today); err != nil {
+		log.Printf("Failed to flag overdue installments: %v", err)
+	} else if count > 0 {
+		log.Printf("Flagged %d installments as overdue", count)
+	}
+
+	if s.config.ReminderLeadDays > 0 {
+		s.remind(ctx, repositories.InstallmentReminderDue, today.AddDate(0, 0, s.config.ReminderLeadDays))
+	}
+	s.remind(ctx, repositories.InstallmentReminderOverdue, today)
+}
+
+// remind sends one kind of installment reminder. Each is recorded before sending so replicas never
+// send the same reminder twice, and cleared again if it cannot be delivered.
+func (s *PaymentPlanService) remind(ctx context.Context, kind string, until time.Time) {
+	reminders, err := s.repository.PendingReminders(ctx, kind, until, installmentReminderBatchSize)
+	if err != nil {
+		log.Printf("Failed to find installment reminders to send: %v", err)
+		return
+	}
+
+	for _, reminder := range reminders {
+		now := s.clock.Now()
+		marked, err := s.repository.MarkReminded(ctx, reminder.InstallmentID, kind, &now)
+		if err != nil {
+			log.Printf("Failed to record reminder for installment %d: %v", reminder.InstallmentID, err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+
+		due := reminder.Amount - reminder.PaidAmount
+		notification := notifications.Notification{
+			Recipients: []string{reminder.PatientEmail},
+			PatientID:  reminder.PatientID,
+			Purpose:    notifications.PurposeReminder,
+			Subject:    "Upcoming installment payment",
+			Body: fmt.Sprintf("Dear %s,\n\nThis is a reminder that installment %d of your payment plan, %s, is due on %s.\n",
+				reminder.PatientFirstName, reminder.Number, models.FormatMoney(due), models.FormatDate(reminder.DueDate)),
+		}
+		if kind == repositories.InstallmentReminderOverdue {
+			// Overdue notices concern the patient's account, so opting out of reminders does not stop them
+			notification.Purpose = notifications.PurposeService
+			notification.Subject = "Overdue installment payment"
+			notification.Body = fmt.Sprintf("Dear %s,\n\nInstallment %d of your payment plan, %s, was due on %s and has not been paid yet. Please contact us to settle it.\n",
+				reminder.PatientFirstName, reminder.Number, models.FormatMoney(due), models.FormatDate(reminder.DueDate))
+		}
+		if err := s.notifier.Send(ctx, notification); errors.Is(err, notifications.ErrNotPermitted) {
+			// The patient opted out, so the reminder stays recorded and is not tried again
+			log.Printf("Reminder for installment %d not sent: %v", reminder.InstallmentID, err)
+		} else if err != nil && !errors.Is(err, notifications.ErrDeferred) {
+			log.Printf("Failed to send reminder for installment %d: %v", reminder.InstallmentID, err)
+			if _, err := s.repository.MarkReminded(ctx, reminder.InstallmentID, kind, nil); err != nil {
+				log.Printf("Failed to clear reminder for installment %d: %v", reminder.InstallmentID, err)
+			}
+		}
+	}
+}
+
+// scheduleInstallments splits total evenly in cents, the last installment taking the remainder
+func scheduleInstallments(total float64, schedule models.InstallmentSchedule) ([]models.PaymentInstallment, error) {
+	if schedule.Count <= 0 {
+		return nil, fmt.Errorf("%w: either installments or an installment count is required", ErrInvalidPaymentPlan)
+	}
+	if schedule.FirstDueDate.IsZero() {
+		return nil, fmt.Errorf("%w: the first due date is required", ErrInvalidPaymentPlan)
+	}
+	if schedule.IntervalMonths <= 0 {
+		schedule.IntervalMonths = 1
+	}
+	cents := int64(math.Round(total * 100))
+	if cents < int64(schedule.Count) {
+		return nil, fmt.Errorf("%w: the total must cover every installment", ErrInvalidPaymentPlan)
+	}
+
+	each := cents / int64(schedule.Count)
+	installments := make([]models.PaymentInstallment, schedule.Count)
+	for i := range installments {
+		amount := each
+		if i == schedule.Count-1 {
+			amount = cents - each*int64(schedule.Count-1)
+		}
+		installments[i] = models.PaymentInstallment{
+			DueDate: schedule.FirstDueDate.AddDate(0, i*schedule.IntervalMonths, 0),
+			Amount:  float64(amount) / 100,
+		}
+	}
+	return installments, nil
+}
+
+// validatePaymentPlan numbers the installments by due date and checks that they add up to the total
+func validatePaymentPlan(plan *models.PaymentPlan) error {
+	if plan.TreatmentPlanID == nil && plan.BillingID == nil {
+		return fmt.Errorf("%w: a treatment_plan_id or billing_id is required", ErrInvalidPaymentPlan)
+	}
+	var cents int64
+	for i := range plan.Installments {
+		installment := &plan.Installments[i]
+		installment.Amount = math.Round(installment.Amount*100) / 100
+		if installment.Amount <= 0 {
+			return fmt.Errorf("%w: installment amounts must be positive", ErrInvalidPaymentPlan)
+		}
+		if installment.DueDate.IsZero() {
+			return fmt.Errorf("%w: every installment needs a due date", ErrInvalidPaymentPlan)
+		}
+		if i > 0 && installment.DueDate.Before(plan.Installments[i-1].DueDate) {
+			return fmt.Errorf("%w: installments must be in due date order", ErrInvalidPaymentPlan)
+		}
+		installment.ID = 0
+		installment.Number = i + 1
+		installment.PaidAmount = 0
+		installment.Status = models.InstallmentStatusPending
+		cents += int64(math.Round(installment.Amount * 100))
+	}
+	if plan.TotalAmount == 0 {
+		plan.TotalAmount = float64(cents) / 100
+	}
+	if int64(math.Round(plan.TotalAmount*100)) != cents {
+		return fmt.Errorf("%w: the installments add up to %.2f, not the total of %.2f", ErrInvalidPaymentPlan, float64(cents)/100, plan.TotalAmount)
+	}
+	return nil
+}
+
+// paymentPlanView adds the plan's progress. Installments past due are counted as overdue even
+// before the background check has flagged them.
+func paymentPlanView(plan models.PaymentPlan, now time.Time) *models.PaymentPlanView {
+	today, _ := models.ClinicDay(now)
+	progress := models.PaymentPlanProgress{InstallmentsTotal: len(plan.Installments)}
+	for _, installment := range plan.Installments {
+		progress.PaidAmount += installment.PaidAmount
+		if installment.Status == models.InstallmentStatusPaid {
+			progress.InstallmentsPaid++
+			continue
+		}
+		due := installment.DueDate
+		if progress.NextDueDate == nil {
+			progress.NextDueDate = &due
+			progress.NextDueAmount = installment.Amount - installment.PaidAmount
+		}
+		if plan.Status == models.PaymentPlanStatusActive && due.Before(today) {
+			progress.OverdueCount++
+			progress.OverdueAmount += installment.Amount - installment.PaidAmount
+		}
+	}
+	progress.PaidAmount = math.Round(progress.PaidAmount*100) / 100
+	progress.OverdueAmount = math.Round(progress.OverdueAmount*100) / 100
+	progress.NextDueAmount = math.Round(progress.NextDueAmount*100) / 100
+	progress.RemainingAmount = math.Round((plan.TotalAmount-progress.PaidAmount)*100) / 100
+	if plan.TotalAmount > 0 {
+		progress.PercentPaid = math.Round(progress.PaidAmount/plan.TotalAmount*10000) / 100
+	}
+	if plan.Installments == nil {
+		plan.Installments = []models.PaymentInstallment{}
+	}
+	return &models.PaymentPlanView{PaymentPlan: plan, Progress: progress}
+}
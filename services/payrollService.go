@@ -0,0 +1,351 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPayroll      = errors.New("invalid payroll request")
+	ErrPayrollSignedOff    = errors.New("payroll is already signed off")
+	ErrPayrollNotSignedOff = errors.New("payroll is not signed off")
+)
+
+// PayrollService puts together the monthly payroll of each staff member from the roster and, for
+// doctors, the commission on the money collected on their bills. Until an admin signs a month off
+// it is worked out afresh on each request; afterwards it is kept as signed off.
+type PayrollService struct {
+	repository    *repositories.PayrollRepository
+	rosterRepo    *repositories.RosterRepository
+	doctorAppRepo *repositories.DoctorAppRepository
+	approvals     *ApprovalService
+	config        config.PayrollConfig
+}
+
+// NewPayrollService registers reopening a signed-off month with approvals, which hold it back until
+// an admin other than the one asking approves it
+func NewPayrollService(repository *repositories.PayrollRepository, rosterRepo *repositories.RosterRepository,
+	doctorAppRepo *repositories.DoctorAppRepository, approvals *ApprovalService, cfg config.PayrollConfig) *PayrollService {
+	s := &PayrollService{repository: repository, rosterRepo: rosterRepo, doctorAppRepo: doctorAppRepo, approvals: approvals, config: cfg}
+	approvals.Register(models.ApprovalActionPayrollReopen, s.executeReopen)
+	return s
+}
+
+// Payroll returns the payroll of a month (YYYY-MM)
+func (s *PayrollService) Payroll(ctx context.Context, period string) (*models.Payroll, error) {
+	month, err := payrollMonth(period)
+	if err != nil {
+		return nil, err
+	}
+	signedOff, err := s.repository.GetPeriod(ctx, models.FinancialPeriodOf(month))
+	if err != nil {
+		return nil, err
+	}
+	if signedOff != nil {
+		return signedOffPayroll(signedOff), nil
+	}
+	lines, err := s.lines(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Payroll{Period: models.FinancialPeriodOf(month), Status: models.PayrollStatusDraft, Lines: lines}, nil
+}
+
+// SignOff signs off the payroll of a month that has ended, keeping it as it stands
+func (s *PayrollService) SignOff(ctx context.Context, period string, userID int64) (*models.Payroll, error) {
+	month, err := payrollMonth(period)
+	if err != nil {
+		return nil, err
+	}
+	if month.AddDate(0, 1, 0).After(time.Now()) {
+		return nil, fmt.Errorf("%w: %s has not ended yet", ErrInvalidPayroll, period)
+	}
+	lines, err := s.lines(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	signedOff := &models.PayrollPeriod{
+		Period:      models.FinancialPeriodOf(month),
+		SignedOffAt: time.Now(),
+		SignedOffBy: &userID,
+		Lines:       lines,
+	}
+	if err := s.repository.SignOff(ctx, signedOff); err != nil {
+		if errors.Is(err, repositories.ErrPayrollSignedOff) {
+			return nil, ErrPayrollSignedOff
+		}
+		return nil, err
+	}
+	return signedOffPayroll(signedOff), nil
+}
+
+// Reopen asks for the sign-off of a month to be withdrawn, so its payroll is worked out afresh
+// again. It returns an *ApprovalRequiredError until another admin approves it.
+func (s *PayrollService) Reopen(ctx context.Context, period string) error {
+	month, err := payrollMonth(period)
+	if err != nil {
+		return err
+	}
+	period = models.FinancialPeriodOf(month)
+	signedOff, err := s.repository.GetPeriod(ctx, period)
+	if err != nil {
+		return err
+	}
+	if signedOff == nil {
+		return ErrPayrollNotSignedOff
+	}
+	return s.approvals.Request(ctx, models.ApprovalActionPayrollReopen, period, "Reopen the signed-off payroll of "+period, nil)
+}
+
+func (s *PayrollService) executeReopen(ctx context.Context, approval *models.Approval) error {
+	reopened, err := s.repository.Reopen(ctx, approval.RecordID)
+	if err != nil {
+		return err
+	}
+	if !reopened {
+		return ErrPayrollNotSignedOff
+	}
+	return nil
+}
+
+// Export returns the payroll of a month as CSV, one line per staff member
+func (s *PayrollService) Export(ctx context.Context, period string) ([]byte, error) {
+	payroll, err := s.Payroll(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+
+	signedOffAt := ""
+	if payroll.SignedOffAt != nil {
+		signedOffAt = payroll.SignedOffAt.In(models.ClinicLocation()).Format(time.RFC3339)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"Period", "Status", "SignedOffAt", "UserID", "DoctorID", "Name", "Role", "Shifts", "Hours", "LeaveDays", "Billed", "Collected", "CommissionRate", "Commission"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, line := range payroll.Lines {
+		userID, doctorID := "", ""
+		if line.UserID != nil {
+			userID = strconv.FormatInt(*line.UserID, 10)
+		}
+		if line.DoctorID != nil {
+			doctorID = *line.DoctorID
+		}
+		err := w.Write([]string{
+			payroll.Period,
+			payroll.Status,
+			signedOffAt,
+			userID,
+			doctorID,
+			line.Name,
+			line.Role,
+			strconv.Itoa(line.Shifts),
+			strconv.FormatFloat(line.Hours, 'f', 2, 64),
+			strconv.Itoa(line.LeaveDays),
+			strconv.FormatFloat(line.Billed, 'f', 2, 64),
+			strconv.FormatFloat(line.Collected, 'f', 2, 64),
+			strconv.FormatFloat(line.CommissionRate, 'f', 2, 64),
+			strconv.FormatFloat(line.Commission, 'f', 2, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write payroll CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// CommissionRates returns the doctors paid at a rate of their own
+func (s *PayrollService) CommissionRates(ctx context.Context) ([]models.CommissionRate, error) {
+	rates, err := s.repository.CommissionRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rates == nil {
+		rates = []models.CommissionRate{}
+	}
+	return rates, nil
+}
+
+// SetCommissionRate pays a doctor rate percent of what is collected on their bills
+func (s *PayrollService) SetCommissionRate(ctx context.Context, doctorID string, rate float64) (*models.CommissionRate, error) {
+	if rate < 0 || rate > 100 {
+		return nil, fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidPayroll)
+	}
+	doctor, err := s.doctorAppRepo.GetDoctor(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotFound
+	}
+	commission := &models.CommissionRate{DoctorID: doctorID, Rate: rate, UpdatedAt: time.Now()}
+	if err := s.repository.SetCommissionRate(ctx, commission); err != nil {
+		return nil, err
+	}
+	return commission, nil
+}
+
+// DeleteCommissionRate takes a doctor back to the clinic's default rate
+func (s *PayrollService) DeleteCommissionRate(ctx context.Context, doctorID string) error {
+	return s.repository.DeleteCommissionRate(ctx, doctorID)
+}
+
+// lines works out the payroll of the month starting at month. Shifts on days of approved leave
+// are not paid as hours worked; doctors with a staff account are paid on one line with their
+// hours, and doctors without one on a line of their own.
+func (s *PayrollService) lines(ctx context.Context, month time.Time) ([]models.PayrollLine, error) {
+	end := month.AddDate(0, 1, 0)
+	first, last := month.Format(models.ClosureDateLayout), end.AddDate(0, 0, -1).Format(models.ClosureDateLayout)
+
+	shifts, err := s.rosterRepo.Shifts(ctx, month, end, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	leave, err := s.rosterRepo.ApprovedLeave(ctx, first, last)
+	if err != nil {
+		return nil, err
+	}
+	basis, err := s.repository.CommissionBasis(ctx, month, end)
+	if err != nil {
+		return nil, err
+	}
+
+	staff := map[int64]*models.PayrollLine{}
+	line := func(userID int64) *models.PayrollLine {
+		if staff[userID] == nil {
+			id := userID
+			staff[userID] = &models.PayrollLine{UserID: &id}
+		}
+		return staff[userID]
+	}
+	for _, shift := range shifts {
+		day := shift.StartsAt.In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+		onLeave := false
+		for _, request := range leave {
+			if request.UserID == shift.UserID && request.Covers(day) {
+				onLeave = true
+			}
+		}
+		if onLeave {
+			continue
+		}
+		l := line(shift.UserID)
+		l.Shifts++
+		l.Hours += shift.EndsAt.Sub(shift.StartsAt).Hours()
+	}
+	for _, request := range leave {
+		line(request.UserID).LeaveDays += leaveDaysWithin(request.LeaveRequest, first, last)
+	}
+
+	var doctors []models.PayrollLine
+	for _, b := range basis {
+		l := &models.PayrollLine{}
+		if b.UserID != nil {
+			l = line(*b.UserID)
+		}
+		doctorID := b.DoctorID
+		rate := s.config.CommissionRate
+		if b.Rate != nil {
+			rate = *b.Rate
+		}
+		l.DoctorID = &doctorID
+		l.Name = strings.TrimSpace(b.DoctorName)
+		l.Billed = math.Round(b.Billed*100) / 100
+		l.Collected = math.Round(b.Collected*100) / 100
+		l.CommissionRate = rate
+		l.Commission = math.Round(b.Collected*rate) / 100
+		if b.UserID == nil {
+			doctors = append(doctors, *l)
+		}
+	}
+
+	userIDs := make([]int64, 0, len(staff))
+	for userID := range staff {
+		userIDs = append(userIDs, userID)
+	}
+	members, err := s.repository.Staff(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		l := staff[member.UserID]
+		l.Role = member.Role
+		if l.Name == "" {
+			l.Name = member.Username
+		}
+	}
+
+	lines := make([]models.PayrollLine, 0, len(staff)+len(doctors))
+	for _, l := range staff {
+		l.Hours = math.Round(l.Hours*100) / 100
+		lines = append(lines, *l)
+	}
+	for _, l := range doctors {
+		l.Role = "Doctor"
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Name < lines[j].Name })
+	return lines, nil
+}
+
+// payrollMonth parses a payroll period (YYYY-MM) as the start of the month in clinic time
+func payrollMonth(period string) (time.Time, error) {
+	month, err := time.ParseInLocation(models.FinancialPeriodLayout, period, models.ClinicLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: expected YYYY-MM", ErrInvalidPayroll)
+	}
+	return month, nil
+}
+
+// leaveDaysWithin counts the days of leave falling on first through last, as YYYY-MM-DD
+func leaveDaysWithin(leave models.LeaveRequest, first, last string) int {
+	start, end := leave.StartDate, leave.EndDate
+	if start < first {
+		start = first
+	}
+	if end > last {
+		end = last
+	}
+	from, err := time.Parse(models.ClosureDateLayout, start)
+	if err != nil {
+		return 0
+	}
+	to, err := time.Parse(models.ClosureDateLayout, end)
+	if err != nil || to.Before(from) {
+		return 0
+	}
+	return int(to.Sub(from).Hours()/24) + 1
+}
+
+// signedOffPayroll returns a signed-off period as its payroll
+func signedOffPayroll(period *models.PayrollPeriod) *models.Payroll {
+	signedOffAt := period.SignedOffAt
+	lines := period.Lines
+	if lines == nil {
+		lines = []models.PayrollLine{}
+	}
+	return &models.Payroll{
+		Period:      period.Period,
+		Status:      models.PayrollStatusSignedOff,
+		SignedOffAt: &signedOffAt,
+		SignedOffBy: period.SignedOffBy,
+		Lines:       lines,
+	}
+}
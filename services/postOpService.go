@@ -0,0 +1,205 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// postOpDeliveryTimeout bounds sending a patient the instructions of a fulfilled appointment
+const postOpDeliveryTimeout = time.Minute
+
+// languagePattern matches language tags such as en, sw or pt-br
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+var (
+	ErrPostOpInstructionNotFound = errors.New("post-operative instruction not found")
+	ErrInvalidPostOpInstruction  = errors.New("invalid post-operative instruction")
+)
+
+// PostOpService keeps the library of post-operative instructions, one per procedure and language,
+// and sends them once an appointment for the procedure is fulfilled: by email and by SMS, in the
+// patient's language or else the clinic's default, as far as the patient agreed to receive them.
+// Every message is written to the patient's communication log.
+type PostOpService struct {
+	repository     *repositories.PostOpRepository
+	procedureRepo  *repositories.ProcedureRepository
+	careRuleRepo   *repositories.CareRuleRepository
+	communications *CommunicationService
+	email          notifications.Notifier
+	sms            notifications.Notifier
+	config         config.PostOpConfig
+}
+
+func NewPostOpService(repository *repositories.PostOpRepository, procedureRepo *repositories.ProcedureRepository, careRuleRepo *repositories.CareRuleRepository, communications *CommunicationService, email, sms notifications.Notifier, cfg config.PostOpConfig) *PostOpService {
+	return &PostOpService{
+		repository:     repository,
+		procedureRepo:  procedureRepo,
+		careRuleRepo:   careRuleRepo,
+		communications: communications,
+		email:          email,
+		sms:            sms,
+		config:         cfg,
+	}
+}
+
+func (s *PostOpService) Create(ctx context.Context, instruction *models.PostOpInstruction) error {
+	if err := s.validate(ctx, instruction); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, instruction)
+}
+
+func (s *PostOpService) Get(ctx context.Context, id uint) (*models.PostOpInstruction, error) {
+	instruction, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if instruction == nil {
+		return nil, ErrPostOpInstructionNotFound
+	}
+	return instruction, nil
+}
+
+// List returns the instructions of procedureID, or of every procedure when it is nil
+func (s *PostOpService) List(ctx context.Context, procedureID *uint) ([]models.PostOpInstruction, error) {
+	instructions, err := s.repository.List(ctx, procedureID)
+	if err != nil {
+		return nil, err
+	}
+	if instructions == nil {
+		instructions = []models.PostOpInstruction{}
+	}
+	return instructions, nil
+}
+
+func (s *PostOpService) Update(ctx context.Context, instruction *models.PostOpInstruction) error {
+	if _, err := s.Get(ctx, instruction.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, instruction); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, instruction)
+}
+
+func (s *PostOpService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *PostOpService) validate(ctx context.Context, instruction *models.PostOpInstruction) error {
+	instruction.Language = strings.ToLower(strings.TrimSpace(instruction.Language))
+	instruction.Subject = strings.TrimSpace(instruction.Subject)
+	if !languagePattern.MatchString(instruction.Language) {
+		return fmt.Errorf("%w: language must be a language code such as en or sw", ErrInvalidPostOpInstruction)
+	}
+	if instruction.Subject == "" || strings.TrimSpace(instruction.Body) == "" {
+		return fmt.Errorf("%w: subject and body are required", ErrInvalidPostOpInstruction)
+	}
+	procedure, err := s.procedureRepo.GetByID(ctx, instruction.ProcedureID)
+	if err != nil {
+		return err
+	}
+	if procedure == nil {
+		return fmt.Errorf("%w: procedure %d not found", ErrInvalidPostOpInstruction, instruction.ProcedureID)
+	}
+	exists, err := s.repository.Exists(ctx, instruction.ProcedureID, instruction.Language, instruction.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: procedure %d already has instructions in %s", ErrInvalidPostOpInstruction, instruction.ProcedureID, instruction.Language)
+	}
+	return nil
+}
+
+// HandleAppointmentFulfilled sends the instructions of the fulfilled appointment's procedure, when
+// it has any
+func (s *PostOpService) HandleAppointmentFulfilled(ctx context.Context, event events.Event) error {
+	subject, err := s.careRuleRepo.Subject(ctx, "appointment", event.RecordID)
+	if err != nil {
+		return err
+	}
+	if subject == nil || subject.ProcedureID == nil {
+		return nil
+	}
+
+	var instruction *models.PostOpInstruction
+	language := strings.ToLower(strings.TrimSpace(subject.PatientLanguage))
+	if language != "" {
+		if instruction, err = s.repository.Find(ctx, *subject.ProcedureID, language); err != nil {
+			return err
+		}
+	}
+	if instruction == nil && language != s.config.DefaultLanguage {
+		if instruction, err = s.repository.Find(ctx, *subject.ProcedureID, s.config.DefaultLanguage); err != nil {
+			return err
+		}
+	}
+	if instruction == nil {
+		return nil
+	}
+	s.deliver(*instruction, subject, event.RecordID)
+	return nil
+}
+
+// deliver sends the instructions in the background so a slow mail or SMS gateway never delays the
+// request that fulfilled the appointment
+func (s *PostOpService) deliver(instruction models.PostOpInstruction, subject *models.CareSubject, appointmentID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), postOpDeliveryTimeout)
+		defer cancel()
+
+		notification := notifications.Notification{
+			PatientID: subject.PatientID,
+			Purpose:   notifications.PurposeService,
+			Subject:   subject.Fill(instruction.Subject),
+			Body:      subject.Fill(instruction.Body),
+		}
+		channels := []struct {
+			name      string
+			recipient string
+			notifier  notifications.Notifier
+		}{
+			{notifications.ChannelEmail, subject.PatientEmail, s.email},
+			{notifications.ChannelSMS, subject.PatientPhone, s.sms},
+		}
+		for _, channel := range channels {
+			if channel.recipient == "" || channel.notifier == nil {
+				continue
+			}
+			notification.Recipients = []string{channel.recipient}
+			notification.MessageID = notifications.NewMessageID()
+			sendErr := channel.notifier.Send(ctx, notification)
+			if sendErr != nil && !errors.Is(sendErr, notifications.ErrNotPermitted) && !errors.Is(sendErr, notifications.ErrDeferred) {
+				log.Printf("Failed to send post-operative instructions by %s to patient %s: %v", channel.name, subject.PatientID, sendErr)
+			}
+			err := s.communications.LogMessage(ctx, models.CommunicationLog{
+				PatientID: subject.PatientID,
+				Channel:   channel.name,
+				Purpose:   notification.Purpose,
+				Recipient: channel.recipient,
+				Subject:   notification.Subject,
+				Body:      notification.Body,
+				MessageID: notification.MessageID,
+				Record:    "appointment",
+				RecordID:  appointmentID,
+			}, sendErr)
+			if err != nil {
+				log.Printf("Failed to log post-operative instructions to patient %s: %v", subject.PatientID, err)
+			}
+		}
+	}()
+}
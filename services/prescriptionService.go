@@ -0,0 +1,409 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPrescription      = errors.New("invalid prescription")
+	ErrInvalidAllergy           = errors.New("invalid allergy")
+	ErrInvalidDrugInteraction   = errors.New("invalid drug interaction")
+	ErrUnacknowledgedWarnings   = errors.New("the prescription has warnings that must be acknowledged")
+	ErrDrugInteractionDuplicate = errors.New("an interaction between these drugs is already recorded")
+	ErrControlledPrescriber     = errors.New("not allowed to prescribe controlled substances")
+	ErrInvalidControlledDrug    = errors.New("invalid controlled substance")
+)
+
+type PrescriptionService struct {
+	repository          *repositories.PrescriptionRepository
+	patientRepository   *repositories.PatientRepository
+	doctorAppRepository *repositories.DoctorAppRepository
+	auditService        *AuditService
+	config              config.ControlledSubstanceConfig
+}
+
+func NewPrescriptionService(
+	repository *repositories.PrescriptionRepository,
+	patientRepository *repositories.PatientRepository,
+	doctorAppRepository *repositories.DoctorAppRepository,
+	auditService *AuditService,
+	cfg config.ControlledSubstanceConfig,
+) *PrescriptionService {
+	return &PrescriptionService{
+		repository:          repository,
+		patientRepository:   patientRepository,
+		doctorAppRepository: doctorAppRepository,
+		auditService:        auditService,
+		config:              cfg,
+	}
+}
+
+// Check returns the allergy and interaction warnings for prescribing drug to the patient
+func (s *PrescriptionService) Check(ctx context.Context, patientID, drug string) ([]models.PrescriptionWarning, error) {
+	if err := s.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	drug = models.NormalizeDrugName(drug)
+	if drug == "" {
+		return nil, fmt.Errorf("%w: the drug is required", ErrInvalidPrescription)
+	}
+	return s.warnings(ctx, patientID, drug)
+}
+
+// Create saves the prescription once every warning for it has been acknowledged by its code. The
+// acknowledgement is written to the audit trail, starting from audit, before the prescription is
+// saved. When warnings are left unacknowledged they are returned with ErrUnacknowledgedWarnings.
+// Controlled substances may only be prescribed by doctors with one of the configured roles, and are
+// entered in the controlled substance register.
+func (s *PrescriptionService) Create(ctx context.Context, prescription *models.Prescription, role string, acknowledged []string, audit models.AuditLog) ([]models.PrescriptionWarning, error) {
+	patient, err := s.getPatient(ctx, prescription.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	prescription.Drug = models.NormalizeDrugName(prescription.Drug)
+	switch {
+	case prescription.Drug == "":
+		return nil, fmt.Errorf("%w: the drug is required", ErrInvalidPrescription)
+	case prescription.DurationDays <= 0:
+		return nil, fmt.Errorf("%w: the duration must be at least one day", ErrInvalidPrescription)
+	case prescription.Quantity < 0:
+		return nil, fmt.Errorf("%w: the quantity cannot be negative", ErrInvalidPrescription)
+	}
+
+	substance, err := s.repository.GetControlledSubstance(ctx, prescription.Drug)
+	if err != nil {
+		return nil, err
+	}
+	var entry *models.ControlledRegisterEntry
+	if substance != nil {
+		if entry, err = s.registerEntry(ctx, prescription, substance, patient, role); err != nil {
+			return nil, err
+		}
+	}
+
+	warnings, err := s.warnings(ctx, prescription.PatientID, prescription.Drug)
+	if err != nil {
+		return nil, err
+	}
+	acked := make(map[string]bool, len(acknowledged))
+	for _, code := range acknowledged {
+		acked[code] = true
+	}
+	var pending []models.PrescriptionWarning
+	for _, warning := range warnings {
+		if !acked[warning.Code] {
+			pending = append(pending, warning)
+		}
+	}
+	if len(pending) > 0 {
+		return pending, ErrUnacknowledgedWarnings
+	}
+
+	prescription.EndsOn = time.Now().AddDate(0, 0, prescription.DurationDays-1)
+	prescription.Warnings = len(warnings)
+	prescription.Controlled = entry != nil
+
+	if len(warnings) > 0 {
+		detail, err := json.Marshal(map[string]interface{}{
+			"patient_id": prescription.PatientID,
+			"drug":       prescription.Drug,
+			"warnings":   warnings,
+		})
+		if err != nil {
+			return nil, err
+		}
+		audit.Detail = string(detail)
+		if err := s.auditService.Record(ctx, audit); err != nil {
+			return nil, fmt.Errorf("failed to record the acknowledgement: %w", err)
+		}
+	}
+
+	if entry != nil {
+		entry.PrescribedOn = time.Now()
+		err = s.repository.CreateControlled(ctx, prescription, entry)
+	} else {
+		err = s.repository.Create(ctx, prescription)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// registerEntry checks the prescriber may prescribe the controlled substance and prepares its
+// register entry
+func (s *PrescriptionService) registerEntry(ctx context.Context, prescription *models.Prescription, substance *models.ControlledSubstance, patient *models.Patient, role string) (*models.ControlledRegisterEntry, error) {
+	allowed := false
+	for _, prescriberRole := range s.config.PrescriberRoles {
+		if strings.EqualFold(prescriberRole, role) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, ErrControlledPrescriber
+	}
+	doctor, err := s.doctorAppRepository.GetDoctorByUserID(ctx, prescription.PrescribedBy)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, fmt.Errorf("%w: the account is not linked to a doctor", ErrControlledPrescriber)
+	}
+
+	switch {
+	case prescription.Quantity <= 0:
+		return nil, fmt.Errorf("%w: the quantity is required", ErrInvalidPrescription)
+	case s.config.MaxDurationDays > 0 && prescription.DurationDays > s.config.MaxDurationDays:
+		return nil, fmt.Errorf("%w: controlled substances are prescribed for at most %d days", ErrInvalidPrescription, s.config.MaxDurationDays)
+	}
+
+	return &models.ControlledRegisterEntry{
+		DoctorID:    doctor.ID,
+		DoctorName:  strings.TrimSpace(doctor.FirstName + " " + doctor.LastName),
+		PatientID:   patient.ID,
+		PatientName: strings.TrimSpace(patient.FirstName + " " + patient.LastName),
+		Drug:        substance.Drug,
+		Schedule:    substance.Schedule,
+		Dose:        prescription.Dose,
+		Quantity:    prescription.Quantity,
+	}, nil
+}
+
+// GetControlledSubstances returns the drugs whose prescriptions are restricted and registered
+func (s *PrescriptionService) GetControlledSubstances(ctx context.Context) ([]models.ControlledSubstance, error) {
+	substances, err := s.repository.ListControlledSubstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if substances == nil {
+		substances = []models.ControlledSubstance{}
+	}
+	return substances, nil
+}
+
+func (s *PrescriptionService) AddControlledSubstance(ctx context.Context, substance *models.ControlledSubstance) error {
+	substance.Drug = models.NormalizeDrugName(substance.Drug)
+	substance.Schedule = strings.ToUpper(strings.TrimSpace(substance.Schedule))
+	switch {
+	case substance.Drug == "":
+		return fmt.Errorf("%w: the drug is required", ErrInvalidControlledDrug)
+	case substance.Schedule == "":
+		return fmt.Errorf("%w: the schedule is required", ErrInvalidControlledDrug)
+	}
+	existing, err := s.repository.GetControlledSubstance(ctx, substance.Drug)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %s is already controlled", ErrInvalidControlledDrug, substance.Drug)
+	}
+	return s.repository.CreateControlledSubstance(ctx, substance)
+}
+
+// DeleteControlledSubstance stops restricting the drug; its register entries are kept
+func (s *PrescriptionService) DeleteControlledSubstance(ctx context.Context, id uint) error {
+	return s.repository.DeleteControlledSubstance(ctx, id)
+}
+
+// GetRegister returns the register entries for the days from from to to inclusive
+func (s *PrescriptionService) GetRegister(ctx context.Context, from, to time.Time) ([]models.ControlledRegisterEntry, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidControlledDrug)
+	}
+	entries, err := s.repository.ListRegister(ctx, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.ControlledRegisterEntry{}
+	}
+	return entries, nil
+}
+
+func (s *PrescriptionService) GetByPatient(ctx context.Context, patientID string) ([]models.Prescription, error) {
+	if err := s.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	prescriptions, err := s.repository.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if prescriptions == nil {
+		prescriptions = []models.Prescription{}
+	}
+	return prescriptions, nil
+}
+
+func (s *PrescriptionService) AddAllergy(ctx context.Context, allergy *models.PatientAllergy) error {
+	if err := s.checkPatient(ctx, allergy.PatientID); err != nil {
+		return err
+	}
+	allergy.Substance = models.NormalizeDrugName(allergy.Substance)
+	allergy.Severity = strings.ToLower(strings.TrimSpace(allergy.Severity))
+	if allergy.Severity == "" {
+		allergy.Severity = models.AllergySeverityModerate
+	}
+	switch {
+	case allergy.Substance == "":
+		return fmt.Errorf("%w: the substance is required", ErrInvalidAllergy)
+	case !models.IsValidAllergySeverity(allergy.Severity):
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidAllergy, allergy.Severity)
+	}
+	return s.repository.CreateAllergy(ctx, allergy)
+}
+
+func (s *PrescriptionService) GetAllergies(ctx context.Context, patientID string) ([]models.PatientAllergy, error) {
+	if err := s.checkPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	allergies, err := s.repository.ListAllergies(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if allergies == nil {
+		allergies = []models.PatientAllergy{}
+	}
+	return allergies, nil
+}
+
+func (s *PrescriptionService) DeleteAllergy(ctx context.Context, patientID string, id uint) error {
+	return s.repository.DeleteAllergy(ctx, patientID, id)
+}
+
+// AddInteraction records an interaction between two drugs in the practice's interaction table
+func (s *PrescriptionService) AddInteraction(ctx context.Context, interaction *models.DrugInteraction) error {
+	interaction.DrugA = models.NormalizeDrugName(interaction.DrugA)
+	interaction.DrugB = models.NormalizeDrugName(interaction.DrugB)
+	interaction.Severity = strings.ToLower(strings.TrimSpace(interaction.Severity))
+	switch {
+	case interaction.DrugA == "" || interaction.DrugB == "":
+		return fmt.Errorf("%w: both drugs are required", ErrInvalidDrugInteraction)
+	case interaction.DrugA == interaction.DrugB:
+		return fmt.Errorf("%w: the drugs must differ", ErrInvalidDrugInteraction)
+	case !models.IsValidInteractionSeverity(interaction.Severity):
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidDrugInteraction, interaction.Severity)
+	}
+	// Store each pair once whichever way round it was entered
+	if interaction.DrugB < interaction.DrugA {
+		interaction.DrugA, interaction.DrugB = interaction.DrugB, interaction.DrugA
+	}
+	existing, err := s.repository.ListInteractions(ctx, interaction.DrugA)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.DrugA == interaction.DrugA && other.DrugB == interaction.DrugB {
+			return ErrDrugInteractionDuplicate
+		}
+	}
+	return s.repository.CreateInteraction(ctx, interaction)
+}
+
+// GetInteractions returns the interaction table, or only the entries for drug when it is set
+func (s *PrescriptionService) GetInteractions(ctx context.Context, drug string) ([]models.DrugInteraction, error) {
+	interactions, err := s.repository.ListInteractions(ctx, models.NormalizeDrugName(drug))
+	if err != nil {
+		return nil, err
+	}
+	if interactions == nil {
+		interactions = []models.DrugInteraction{}
+	}
+	return interactions, nil
+}
+
+func (s *PrescriptionService) DeleteInteraction(ctx context.Context, id uint) error {
+	return s.repository.DeleteInteraction(ctx, id)
+}
+
+// warnings matches drug against the patient's allergies and the drugs they are still taking
+func (s *PrescriptionService) warnings(ctx context.Context, patientID, drug string) ([]models.PrescriptionWarning, error) {
+	warnings := []models.PrescriptionWarning{}
+
+	allergies, err := s.repository.ListAllergies(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, allergy := range allergies {
+		// Match either way round, so "penicillin" flags "penicillin v" and "codeine phosphate" flags "codeine"
+		if !strings.Contains(drug, allergy.Substance) && !strings.Contains(allergy.Substance, drug) {
+			continue
+		}
+		message := fmt.Sprintf("The patient is allergic to %s", allergy.Substance)
+		if allergy.Reaction != "" {
+			message += fmt.Sprintf(" (%s)", allergy.Reaction)
+		}
+		warnings = append(warnings, models.PrescriptionWarning{
+			Code:     "allergy:" + allergy.Substance,
+			Kind:     models.PrescriptionWarningAllergy,
+			Severity: allergy.Severity,
+			Drug:     drug,
+			With:     allergy.Substance,
+			Message:  message,
+		})
+	}
+
+	active, err := s.repository.ActiveDrugs(ctx, patientID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(active) > 0 {
+		taking := make(map[string]bool, len(active))
+		for _, other := range active {
+			taking[other] = true
+		}
+		interactions, err := s.repository.ListInteractions(ctx, drug)
+		if err != nil {
+			return nil, err
+		}
+		for _, interaction := range interactions {
+			other := interaction.DrugA
+			if other == drug {
+				other = interaction.DrugB
+			}
+			if !taking[other] {
+				continue
+			}
+			message := fmt.Sprintf("%s interacts with %s, which the patient is taking", drug, other)
+			if interaction.Description != "" {
+				message += ": " + interaction.Description
+			}
+			warnings = append(warnings, models.PrescriptionWarning{
+				Code:     "interaction:" + other,
+				Kind:     models.PrescriptionWarningInteraction,
+				Severity: interaction.Severity,
+				Drug:     drug,
+				With:     other,
+				Message:  message,
+			})
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Code < warnings[j].Code })
+	return warnings, nil
+}
+
+func (s *PrescriptionService) checkPatient(ctx context.Context, patientID string) error {
+	_, err := s.getPatient(ctx, patientID)
+	return err
+}
+
+func (s *PrescriptionService) getPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	patient, err := s.patientRepository.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	return patient, nil
+}
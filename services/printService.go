@@ -0,0 +1,278 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/pdf"
+	"RoyDental/repositories"
+	"RoyDental/zpl"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidPrintJob is returned for print requests that cannot be rendered as given
+	ErrInvalidPrintJob = errors.New("invalid print job")
+	// ErrPrintJobNotFound is returned for print jobs that do not exist
+	ErrPrintJobNotFound = errors.New("print job not found")
+	// ErrPrintJobState is returned when a print job is not in the state the change needs, such as
+	// cancelling a job already printed
+	ErrPrintJobState = errors.New("the print job cannot be changed in its current state")
+)
+
+// maxPrintCopies bounds the copies of one print job
+const maxPrintCopies = 50
+
+// Print job listings are limited to
+const (
+	defaultPrintJobLimit = 50
+	maxPrintJobLimit     = 200
+)
+
+// PrintService renders invoices, statements, appointment cards and patient labels for the front
+// desk's printers and queues them for the print agents polling for work next to each printer
+type PrintService struct {
+	repository      *repositories.PrintJobRepository
+	patientRepo     *repositories.PatientRepository
+	billingRepo     *repositories.BillingRepository
+	appointmentRepo *repositories.AppointmentRepository
+	patientQR       *PatientQRService
+	config          config.PrintingConfig
+}
+
+func NewPrintService(repository *repositories.PrintJobRepository, patientRepo *repositories.PatientRepository, billingRepo *repositories.BillingRepository, appointmentRepo *repositories.AppointmentRepository,
+	patientQR *PatientQRService, cfg config.PrintingConfig) *PrintService {
+	return &PrintService{repository: repository, patientRepo: patientRepo, billingRepo: billingRepo, appointmentRepo: appointmentRepo, patientQR: patientQR, config: cfg}
+}
+
+// AgentsEnabled reports whether print agents can authenticate, and so whether anything is printed
+func (s *PrintService) AgentsEnabled() bool {
+	return len(s.config.AgentKeys) > 0
+}
+
+// Enqueue renders the requested document and queues it for its printer
+func (s *PrintService) Enqueue(ctx context.Context, request models.PrintJobRequest) (*models.PrintJob, error) {
+	request.Printer = strings.TrimSpace(request.Printer)
+	if request.Copies == 0 {
+		request.Copies = 1
+	}
+	switch {
+	case request.Printer == "":
+		return nil, fmt.Errorf("%w: the printer is required", ErrInvalidPrintJob)
+	case !models.IsValidPrintJobKind(request.Kind):
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidPrintJob, request.Kind)
+	case request.Copies < 1 || request.Copies > maxPrintCopies:
+		return nil, fmt.Errorf("%w: copies must be between 1 and %d", ErrInvalidPrintJob, maxPrintCopies)
+	}
+	patient, err := s.patientRepo.GetByID(ctx, request.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+
+	job := &models.PrintJob{
+		Printer:     request.Printer,
+		Kind:        request.Kind,
+		Format:      models.PrintFormatPDF,
+		PatientID:   patient.ID,
+		RecordID:    request.RecordID,
+		Copies:      request.Copies,
+		Status:      models.PrintJobQueued,
+		ContentType: "application/pdf",
+	}
+	switch request.Kind {
+	case models.PrintJobInvoice:
+		job.Content, err = s.invoice(ctx, patient.ID, request.RecordID)
+	case models.PrintJobStatement:
+		job.RecordID = ""
+		job.Content, err = s.statement(ctx, patient, request.From, request.To)
+	case models.PrintJobAppointmentCard:
+		job.Content, err = s.appointmentCard(ctx, patient, request.RecordID)
+	case models.PrintJobLabel:
+		job.RecordID = ""
+		job.Format, job.ContentType = models.PrintFormatZPL, "application/zpl"
+		job.Content = s.patientLabel(patient, request.Copies)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get returns a print job without its content
+func (s *PrintService) Get(ctx context.Context, id uint) (*models.PrintJob, error) {
+	job, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrPrintJobNotFound
+	}
+	return job, nil
+}
+
+// List returns the latest print jobs, for printer and with status when given
+func (s *PrintService) List(ctx context.Context, printer, status string, limit int) ([]models.PrintJob, error) {
+	if limit <= 0 {
+		limit = defaultPrintJobLimit
+	}
+	limit = min(limit, maxPrintJobLimit)
+	jobs, err := s.repository.List(ctx, printer, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if jobs == nil {
+		jobs = []models.PrintJob{}
+	}
+	return jobs, nil
+}
+
+// Cancel takes a job that has not been picked up yet off the queue
+func (s *PrintService) Cancel(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	cancelled, err := s.repository.Cancel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return fmt.Errorf("%w: only queued jobs can be cancelled", ErrPrintJobState)
+	}
+	return nil
+}
+
+// Claim hands an agent the next job for its printer with the content to print, or nil when there
+// is nothing to print. Jobs claimed longer ago than the claim timeout without a report are handed
+// out again.
+func (s *PrintService) Claim(ctx context.Context, printer, agent string) (*models.PrintJob, error) {
+	printer = strings.TrimSpace(printer)
+	if printer == "" {
+		return nil, fmt.Errorf("%w: the printer is required", ErrInvalidPrintJob)
+	}
+	return s.repository.Claim(ctx, printer, strings.TrimSpace(agent), time.Now().Add(-s.config.ClaimTimeout))
+}
+
+// Complete records whether the agent printed a job it claimed
+func (s *PrintService) Complete(ctx context.Context, id uint, result models.PrintJobResult) (*models.PrintJob, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if !result.Printed && strings.TrimSpace(result.Error) == "" {
+		result.Error = "the print agent could not print the job"
+	}
+	completed, err := s.repository.Complete(ctx, id, result)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		return nil, fmt.Errorf("%w: the job is not being printed", ErrPrintJobState)
+	}
+	return s.Get(ctx, id)
+}
+
+// invoice renders the patient's bill billingID
+func (s *PrintService) invoice(ctx context.Context, patientID, billingID string) ([]byte, error) {
+	if billingID == "" {
+		return nil, fmt.Errorf("%w: record_id must name the bill to print", ErrInvalidPrintJob)
+	}
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil || billing.PatientID != patientID {
+		return nil, fmt.Errorf("%w: the patient has no bill %s", ErrInvalidPrintJob, billingID)
+	}
+	return invoicePDF(s.config.ClinicName, billing, s.patientQR), nil
+}
+
+// statement renders the patient's bills from from through to, the year to date by default
+func (s *PrintService) statement(ctx context.Context, patient *models.Patient, from, to string) ([]byte, error) {
+	start, end, err := statementPeriod(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrintJob, err)
+	}
+	statement, err := patientStatement(ctx, s.billingRepo, patient.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := pdf.New()
+	doc.Title(s.config.ClinicName + " - Statement")
+	doc.Text("Patient: " + patient.FirstName + " " + patient.LastName + " (" + patient.ID + ")")
+	doc.Text("Period: " + documentDate(statement.From) + " to " + documentDate(statement.To))
+	doc.Text("Balance brought forward: " + models.FormatMoney(statement.OpeningBalance))
+
+	doc.Heading("Bills")
+	if len(statement.Billings) == 0 {
+		doc.Text("No bills in this period.")
+	}
+	for _, billing := range statement.Billings {
+		doc.Text(fmt.Sprintf("%s  %s  %s: billed %s, paid %s, balance %s", models.FormatDate(billing.CreatedAt.In(models.ClinicLocation())),
+			billing.BillingID, billing.Procedure, models.FormatMoney(billing.BillingAmount), models.FormatMoney(billing.TotalReceived), models.FormatMoney(billing.Balance)))
+	}
+	doc.Space(4)
+	doc.Text("Billed: " + models.FormatMoney(statement.Billed))
+	doc.Text("Received: " + models.FormatMoney(statement.Received))
+	doc.Text("Balance to pay: " + models.FormatMoney(statement.ClosingBalance))
+	s.patientQR.draw(doc, patient.ID)
+	return doc.Bytes(), nil
+}
+
+// documentDate writes a YYYY-MM-DD date as documents write dates
+func documentDate(value string) string {
+	day, err := models.ParseClinicDate(value)
+	if err != nil {
+		return value
+	}
+	return models.FormatDate(day)
+}
+
+// appointmentCard renders a reminder card of the patient's appointment appointmentID
+func (s *PrintService) appointmentCard(ctx context.Context, patient *models.Patient, appointmentID string) ([]byte, error) {
+	id, err := strconv.ParseUint(appointmentID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: record_id must name the appointment to print", ErrInvalidPrintJob)
+	}
+	appointment, err := s.appointmentRepo.GetByID(ctx, patient.ID, uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil || appointment.StartsAt == nil {
+		return nil, fmt.Errorf("%w: the patient has no appointment %s", ErrInvalidPrintJob, appointmentID)
+	}
+	startsAt := appointment.StartsAt.In(models.ClinicLocation())
+
+	doc := pdf.New()
+	doc.Title(s.config.ClinicName + " - Appointment card")
+	doc.Text("Patient: " + patient.FirstName + " " + patient.LastName)
+	doc.Text("Dentist: Dr " + appointment.Doctor.FirstName + " " + appointment.Doctor.LastName)
+	doc.Text("Date: " + models.FormatDayDate(startsAt))
+	doc.Text("Time: " + startsAt.Format("15:04"))
+	doc.Space(4)
+	doc.Text("If you cannot attend, please let us know as early as possible so we can offer the time to another patient.")
+	s.patientQR.draw(doc, patient.ID)
+	return doc.Bytes(), nil
+}
+
+// patientLabel renders a sticker with the patient's name, date of birth and a barcode of their ID,
+// with their QR code beside it when codes are configured
+func (s *PrintService) patientLabel(patient *models.Patient, copies int) []byte {
+	label := zpl.New()
+	label.Text(30, 30, 34, patient.FirstName+" "+patient.LastName)
+	label.Text(30, 75, 26, "DOB: "+patient.DateOfBirth)
+	label.Barcode(30, 115, 70, patient.ID)
+	if s.patientQR.Enabled() {
+		label.QRCode(420, 110, 4, s.patientQR.Payload(patient.ID))
+	}
+	return label.Bytes(copies)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrProcedureNotFound     = errors.New("procedure not found")
+	ErrInvalidProcedurePrice = errors.New("procedure price must not be negative")
+)
+
+type ProcedureService struct {
+	repository *repositories.ProcedureRepository
+}
+
+func NewProcedureService(repository *repositories.ProcedureRepository) *ProcedureService {
+	return &ProcedureService{repository: repository}
+}
+
+func (s *ProcedureService) Create(ctx context.Context, procedure *models.Procedure) error {
+	if err := validateProcedure(procedure); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, procedure)
+}
+
+func (s *ProcedureService) GetByID(ctx context.Context, id uint) (*models.Procedure, error) {
+	procedure, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if procedure == nil {
+		return nil, ErrProcedureNotFound
+	}
+	return procedure, nil
+}
+
+func (s *ProcedureService) List(ctx context.Context, specialty string) ([]models.Procedure, error) {
+	if specialty != "" && !models.IsValidSpecialty(specialty) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSpecialty, specialty)
+	}
+	procedures, err := s.repository.List(ctx, specialty)
+	if err != nil {
+		return nil, err
+	}
+	if procedures == nil {
+		procedures = []models.Procedure{}
+	}
+	return procedures, nil
+}
+
+func (s *ProcedureService) Update(ctx context.Context, procedure *models.Procedure) error {
+	if err := validateProcedure(procedure); err != nil {
+		return err
+	}
+	if _, err := s.GetByID(ctx, procedure.ID); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, procedure)
+}
+
+func (s *ProcedureService) Delete(ctx context.Context, id uint) error {
+	return s.repository.Delete(ctx, id)
+}
+
+func validateProcedure(procedure *models.Procedure) error {
+	procedure.Name = strings.TrimSpace(procedure.Name)
+	if procedure.Name == "" {
+		return errors.New("procedure name is required")
+	}
+	if procedure.Specialty == "" {
+		procedure.Specialty = models.SpecialtyGeneral
+	}
+	if !models.IsValidSpecialty(procedure.Specialty) {
+		return fmt.Errorf("%w %q", ErrInvalidSpecialty, procedure.Specialty)
+	}
+	if procedure.Price < 0 {
+		return ErrInvalidProcedurePrice
+	}
+	return nil
+}
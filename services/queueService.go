@@ -0,0 +1,98 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"time"
+)
+
+// DashboardSummary is the front desk's overview of the day
+type DashboardSummary struct {
+	Date               string  `json:"date"`
+	Appointments       int     `json:"appointments"`
+	CheckedIn          int     `json:"checked_in"`
+	Waiting            int     `json:"waiting"`
+	Seen               int     `json:"seen"`
+	AverageWaitMinutes float64 `json:"average_wait_minutes"`
+}
+
+type QueueService struct {
+	appointmentRepo *repositories.AppointmentRepository
+	eligibility     *EligibilityService
+}
+
+func NewQueueService(appointmentRepo *repositories.AppointmentRepository, eligibility *EligibilityService) *QueueService {
+	return &QueueService{appointmentRepo: appointmentRepo, eligibility: eligibility}
+}
+
+// Today returns today's queue with each patient's waiting time: until they were seen, or until now
+// while they are still waiting.
+func (s *QueueService) Today(ctx context.Context) ([]models.QueueEntry, error) {
+	now := time.Now()
+	entries, err := s.appointmentRepo.GetQueue(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.CheckedInAt == nil {
+			continue
+		}
+		end := now
+		if entry.SeenAt != nil {
+			end = *entry.SeenAt
+		}
+		entry.WaitMinutes = end.Sub(*entry.CheckedInAt).Minutes()
+	}
+	return entries, nil
+}
+
+// CheckIn checks the patient in, returning the appointment's eligibility check when the patient's
+// insurance cover was not confirmed so the front desk can be warned before treatment
+func (s *QueueService) CheckIn(ctx context.Context, patientID string, id uint) (*models.EligibilityCheck, error) {
+	if err := s.appointmentRepo.CheckIn(ctx, patientID, id, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.eligibility.Unconfirmed(ctx, patientID, id)
+}
+
+func (s *QueueService) MarkSeen(ctx context.Context, patientID string, id uint) error {
+	return s.appointmentRepo.MarkSeen(ctx, patientID, id)
+}
+
+// Start moves a checked-in patient into treatment once their vitals and checklists are complete
+func (s *QueueService) Start(ctx context.Context, patientID string, id uint) error {
+	return s.appointmentRepo.Start(ctx, patientID, id)
+}
+
+// Summary counts today's appointments by queue state and the average wait of patients already seen.
+func (s *QueueService) Summary(ctx context.Context) (*DashboardSummary, error) {
+	now := time.Now()
+	entries, err := s.appointmentRepo.GetQueue(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	averageWait, err := s.appointmentRepo.AverageWait(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &DashboardSummary{
+		Date:               now.Format("2006-01-02"),
+		Appointments:       len(entries),
+		AverageWaitMinutes: averageWait.Minutes(),
+	}
+	for _, entry := range entries {
+		if entry.CheckedInAt == nil {
+			continue
+		}
+		summary.CheckedIn++
+		if entry.SeenAt != nil {
+			summary.Seen++
+		} else {
+			summary.Waiting++
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxQuietDays bounds how many days in a row of quiet hours and public holidays are looked through
+// for when held messages can go out
+const maxQuietDays = 31
+
+// QuietHoursService holds back messages to patients on the quiet channels during quiet hours and on
+// public holidays, and delivers them in the background once the quiet period ends. Held messages go
+// out through the notifier they would have been sent with, so the patient's preferences at the time
+// of delivery still apply.
+type QuietHoursService struct {
+	repository     *repositories.DeferredMessageRepository
+	closureRepo    *repositories.ClosureRepository
+	communications *CommunicationService
+	config         config.QuietHoursConfig
+	clock          clock.Clock
+	start, end     time.Duration // Clinic times of day quiet hours start and end at
+	mu             sync.RWMutex
+	notifiers      map[string]notifications.Notifier // Held messages are delivered through, by channel
+}
+
+// NewQuietHoursService starts delivering held messages in the background when quiet hours are
+// configured. Quiet hours whose start or end cannot be read are off, and logged.
+func NewQuietHoursService(repository *repositories.DeferredMessageRepository, closureRepo *repositories.ClosureRepository, communications *CommunicationService, cfg config.QuietHoursConfig, clock clock.Clock) *QuietHoursService {
+	s := &QuietHoursService{
+		repository:     repository,
+		closureRepo:    closureRepo,
+		communications: communications,
+		config:         cfg,
+		clock:          clock,
+		notifiers:      map[string]notifications.Notifier{},
+	}
+	start, startErr := quietTimeOfDay(cfg.Start)
+	end, endErr := quietTimeOfDay(cfg.End)
+	if err := errors.Join(startErr, endErr); err != nil {
+		log.Printf("Quiet hours are off: %v", err)
+		s.config.Channels = nil
+	}
+	s.start, s.end = start, end
+	if len(s.config.Channels) > 0 && s.config.DeliveryInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+// Notifier returns next held back during quiet periods when channel is quiet, and next itself
+// otherwise. Held messages are delivered through next.
+func (s *QuietHoursService) Notifier(channel string, next notifications.Notifier) notifications.Notifier {
+	if !s.config.Applies(channel) {
+		return next
+	}
+	s.mu.Lock()
+	s.notifiers[channel] = next
+	s.mu.Unlock()
+	return notifications.QuietHoursNotifier{Next: next, Channel: channel, Queue: s}
+}
+
+// HeldUntil returns when the quiet period now falls in ends for messages on channel, or the zero
+// time when it is not quiet
+func (s *QuietHoursService) HeldUntil(ctx context.Context, channel string) (time.Time, error) {
+	if !s.config.Applies(channel) {
+		return time.Time{}, nil
+	}
+	now := s.clock.Now().In(models.ClinicLocation())
+	until := now
+	for range maxQuietDays {
+		if end, quiet := s.quietHoursEnd(until); quiet {
+			until = end
+			continue
+		}
+		holiday, err := s.publicHoliday(ctx, until)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !holiday {
+			break
+		}
+		until = startOfDay(until).AddDate(0, 0, 1)
+	}
+	if until.Equal(now) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+// Defer queues the notification to be delivered on channel at sendAt
+func (s *QuietHoursService) Defer(ctx context.Context, channel string, notification notifications.Notification, sendAt time.Time) error {
+	message := &models.DeferredMessage{
+		Channel:    channel,
+		PatientID:  notification.PatientID,
+		Purpose:    notification.Purpose,
+		Recipients: notification.Recipients,
+		Subject:    notification.Subject,
+		Body:       notification.Body,
+		MessageID:  notification.MessageID,
+		SendAt:     sendAt,
+	}
+	for _, attachment := range notification.Attachments {
+		message.Attachments = append(message.Attachments, models.DeferredAttachment(attachment))
+	}
+	if !notification.Expires.IsZero() {
+		message.Expires = &notification.Expires
+	}
+	return s.repository.Create(ctx, message)
+}
+
+func (s *QuietHoursService) run() {
+	ticker := time.NewTicker(s.config.DeliveryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.deliver(context.Background())
+	}
+}
+
+// deliver sends the held messages that are due. They are taken off the queue first so replicas
+// never send them twice; a message that fails is queued again until it has been tried MaxAttempts
+// times, and one that stopped being of use is dropped.
+func (s *QuietHoursService) deliver(ctx context.Context) {
+	now := s.clock.Now()
+	messages, err := s.repository.ClaimDue(ctx, now, s.config.BatchSize)
+	if err != nil {
+		log.Printf("Failed to find held messages to deliver: %v", err)
+		return
+	}
+	for _, message := range messages {
+		var sendErr error
+		s.mu.RLock()
+		notifier := s.notifiers[message.Channel]
+		s.mu.RUnlock()
+		switch {
+		case message.Expires != nil && !now.Before(*message.Expires):
+			sendErr = notifications.ErrQuietHours
+		case notifier == nil:
+			sendErr = fmt.Errorf("no notifier for channel %q", message.Channel)
+		default:
+			sendErr = notifier.Send(ctx, deferredNotification(message))
+		}
+		// Messages the transport failed are tried again by the delivery retries, not held again
+		if sendErr != nil && !errors.Is(sendErr, notifications.ErrNotPermitted) && !errors.Is(sendErr, notifications.ErrRetrying) {
+			message.Attempts++
+			if s.retry(ctx, message, sendErr, now) {
+				continue
+			}
+		}
+		if message.MessageID == "" {
+			continue
+		}
+		if err := s.communications.SettleDeferred(ctx, message.MessageID, sendErr); err != nil {
+			log.Printf("Failed to log held message to patient %s: %v", message.PatientID, err)
+		}
+	}
+}
+
+// retry queues a held message that could not be delivered again, reporting false when it has been
+// tried too often or could not be queued
+func (s *QuietHoursService) retry(ctx context.Context, message models.DeferredMessage, sendErr error, now time.Time) bool {
+	if message.Attempts >= s.config.MaxAttempts {
+		log.Printf("Failed to deliver held message %d to patient %s, giving up: %v", message.ID, message.PatientID, sendErr)
+		return false
+	}
+	log.Printf("Failed to deliver held message %d to patient %s, trying again: %v", message.ID, message.PatientID, sendErr)
+	message.ID, message.LastError, message.SendAt = 0, sendErr.Error(), now.Add(s.config.RetryDelay)
+	if err := s.repository.Create(ctx, &message); err != nil {
+		log.Printf("Failed to queue held message to patient %s again: %v", message.PatientID, err)
+		return false
+	}
+	return true
+}
+
+// quietHoursEnd returns when the quiet hours t falls in end, and whether t falls in them
+func (s *QuietHoursService) quietHoursEnd(t time.Time) (time.Time, bool) {
+	if s.start == s.end {
+		return time.Time{}, false
+	}
+	day := startOfDay(t)
+	at := t.Sub(day)
+	switch {
+	case s.start < s.end && at >= s.start && at < s.end:
+		return day.Add(s.end), true
+	case s.start > s.end && at >= s.start:
+		return day.AddDate(0, 0, 1).Add(s.end), true
+	case s.start > s.end && at < s.end:
+		return day.Add(s.end), true
+	}
+	return time.Time{}, false
+}
+
+// publicHoliday reports whether t falls on a public holiday of the closure calendar, when public
+// holidays are quiet
+func (s *QuietHoursService) publicHoliday(ctx context.Context, t time.Time) (bool, error) {
+	if !s.config.PublicHolidays {
+		return false, nil
+	}
+	closure, err := s.closureRepo.On(ctx, t.In(models.ClinicLocation()).Format(models.ClosureDateLayout))
+	if err != nil {
+		return false, err
+	}
+	return closure != nil && closure.Kind == models.ClosureKindPublicHoliday, nil
+}
+
+// deferredNotification rebuilds the notification a held message was queued from
+func deferredNotification(message models.DeferredMessage) notifications.Notification {
+	notification := notifications.Notification{
+		Recipients: message.Recipients,
+		Subject:    message.Subject,
+		Body:       message.Body,
+		PatientID:  message.PatientID,
+		Purpose:    message.Purpose,
+		MessageID:  message.MessageID,
+	}
+	for _, attachment := range message.Attachments {
+		notification.Attachments = append(notification.Attachments, notifications.Attachment(attachment))
+	}
+	if message.Expires != nil {
+		notification.Expires = *message.Expires
+	}
+	return notification
+}
+
+// quietTimeOfDay reads an HH:MM clinic time of day as the time since midnight
+func quietTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quiet hours time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
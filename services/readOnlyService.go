@@ -0,0 +1,156 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readOnlyMessageLimit caps the message an admin shows staff while the server is read-only
+const readOnlyMessageLimit = 500
+
+// primaryUnavailableMessage is shown to staff whose changes are refused while the primary is down
+const primaryUnavailableMessage = "The main database is unavailable. Records can be looked up but not changed until it is back."
+
+// ErrInvalidReadOnly is returned for a read-only toggle that cannot be saved as given
+var ErrInvalidReadOnly = errors.New("invalid read-only mode")
+
+// ReadOnlyService decides whether the server refuses writes: when an admin turns read-only mode on
+// for every server, or on its own while the primary database is down and the replica is up, in
+// which case reads fail over to the replica. It probes the databases and reads the admins' toggle
+// in the background.
+type ReadOnlyService struct {
+	repository *repositories.ReadOnlyRepository
+	config     config.ReadOnlyConfig
+
+	mu       sync.Mutex
+	toggle   *models.ReadOnlyToggle // Last toggle read, kept while Redis is unavailable
+	failures int                    // Failed probes of the primary in a row
+}
+
+// NewReadOnlyService works out the read-only status at once and keeps it up to date in the
+// background when a probe interval is set.
+func NewReadOnlyService(repository *repositories.ReadOnlyRepository, cfg config.ReadOnlyConfig) *ReadOnlyService {
+	s := &ReadOnlyService{repository: repository, config: cfg}
+	s.refresh(context.Background())
+	if cfg.ProbeInterval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *ReadOnlyService) run() {
+	ticker := time.NewTicker(s.config.ProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.refresh(context.Background())
+	}
+}
+
+// Status returns the server's read-only status
+func (s *ReadOnlyService) Status() models.ReadOnlyStatus {
+	return database.ReadOnlyStatus()
+}
+
+// RetryAfter is how long clients whose writes are refused are asked to wait before trying again
+func (s *ReadOnlyService) RetryAfter() time.Duration {
+	return s.config.RetryAfter
+}
+
+// Set turns the admins' read-only mode on, with message shown to staff, or off for every server.
+// The primary being down keeps the server read-only whatever the toggle says.
+func (s *ReadOnlyService) Set(ctx context.Context, enabled bool, message string, userID int64) (models.ReadOnlyStatus, error) {
+	message = strings.TrimSpace(message)
+	if len(message) > readOnlyMessageLimit {
+		return models.ReadOnlyStatus{}, fmt.Errorf("%w: the message is limited to %d characters", ErrInvalidReadOnly, readOnlyMessageLimit)
+	}
+	if enabled {
+		err := s.repository.SaveToggle(ctx, models.ReadOnlyToggle{Enabled: true, Message: message, SetBy: userID, SetAt: time.Now()})
+		if err != nil {
+			return models.ReadOnlyStatus{}, err
+		}
+	} else if err := s.repository.ClearToggle(ctx); err != nil {
+		return models.ReadOnlyStatus{}, err
+	}
+	return s.refresh(ctx), nil
+}
+
+// refresh probes the databases, reads the toggle and records the resulting status, alerting when
+// the server turns read-only or writable again
+func (s *ReadOnlyService) refresh(ctx context.Context) models.ReadOnlyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toggle, err := s.repository.Toggle(ctx)
+	if err != nil {
+		// Keep the last toggle read rather than dropping read-only mode while Redis is away
+		toggle = s.toggle
+	} else {
+		s.toggle = toggle
+	}
+
+	status := models.ReadOnlyStatus{ReplicaConfigured: s.repository.HasReplica(), Toggle: toggle}
+	if err := s.repository.PingPrimary(ctx); err != nil {
+		s.failures++
+	} else {
+		s.failures = 0
+		status.PrimaryHealthy = true
+	}
+	if status.ReplicaConfigured {
+		status.ReplicaHealthy = s.repository.PingReplica(ctx) == nil
+	}
+	status.ReadsFromReplica = s.failures >= s.config.FailureThreshold && status.ReplicaHealthy
+
+	switch {
+	case status.ReadsFromReplica && s.config.Automatic:
+		status.Enabled, status.Reason, status.Message = true, models.ReadOnlyReasonPrimaryUnavailable, primaryUnavailableMessage
+	case toggle != nil && toggle.Enabled:
+		status.Enabled, status.Reason, status.Message = true, models.ReadOnlyReasonManual, toggle.Message
+	}
+
+	previous := database.ReadOnlyStatus()
+	switch {
+	case status.Enabled && previous.Enabled && previous.Reason == status.Reason:
+		status.Since = previous.Since
+	case status.Enabled:
+		since := time.Now()
+		status.Since = &since
+	}
+	if status.ReadsFromReplica != previous.ReadsFromReplica {
+		if status.ReadsFromReplica {
+			log.Printf("Primary database unavailable, reading from the replica")
+		} else {
+			log.Printf("Reading from the primary database again")
+		}
+	}
+	if status.Enabled != previous.Enabled || status.Reason != previous.Reason {
+		s.alert(status)
+	}
+	database.SetReadOnlyStatus(status)
+	return status
+}
+
+// alert posts the server turning read-only, or writable again, to the operational alerts channel
+func (s *ReadOnlyService) alert(status models.ReadOnlyStatus) {
+	if !status.Enabled {
+		log.Printf("Read-only mode off, writes are accepted again")
+		notifications.Publish(notifications.EventOperationalAlert, "Read-only mode off", "The server accepts changes again.")
+		return
+	}
+	reason := "an admin turned it on"
+	if status.Reason == models.ReadOnlyReasonPrimaryUnavailable {
+		reason = "the primary database is unavailable and reads come from the replica"
+	}
+	log.Printf("Read-only mode on: %s", reason)
+	notifications.Publish(notifications.EventOperationalAlert, "Read-only mode on",
+		fmt.Sprintf("The server refuses changes because %s. Patients and appointments can still be looked up.", reason))
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Record access lists are limited to
+const (
+	defaultRecordAccessLimit = 100
+	maxRecordAccessLimit     = 1000
+)
+
+// ErrInvalidRecordAccessFilter is returned for lists asked for with reversed days
+var ErrInvalidRecordAccessFilter = errors.New("invalid record access filter")
+
+// RecordAccessService logs every read of a patient's record, apart from the audit log, and shows
+// patients who viewed their record and admins who read whose
+type RecordAccessService struct {
+	repository  *repositories.RecordAccessRepository
+	patientRepo *repositories.PatientRepository
+}
+
+func NewRecordAccessService(repository *repositories.RecordAccessRepository, patientRepo *repositories.PatientRepository) *RecordAccessService {
+	return &RecordAccessService{repository: repository, patientRepo: patientRepo}
+}
+
+// RecordAccess logs a read of a patient's record. The read was answered already, so a failure to
+// log it is logged instead.
+func (s *RecordAccessService) RecordAccess(ctx context.Context, access models.RecordAccess) {
+	if err := s.repository.Create(context.WithoutCancel(ctx), &access); err != nil {
+		log.Printf("Failed to log the read of patient %s's %s: %v", access.PatientID, access.Section, err)
+	}
+}
+
+// Accesses returns the reads matching filter, newest first
+func (s *RecordAccessService) Accesses(ctx context.Context, filter models.RecordAccessFilter) ([]models.RecordAccess, error) {
+	if err := checkRecordAccessFilter(&filter); err != nil {
+		return nil, err
+	}
+	accesses, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if accesses == nil {
+		accesses = []models.RecordAccess{}
+	}
+	return accesses, nil
+}
+
+// MyViewers returns who read the signed-in patient's record, newest first
+func (s *RecordAccessService) MyViewers(ctx context.Context, userID int64, filter models.RecordAccessFilter) ([]models.RecordViewer, error) {
+	patient, err := s.patientRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotLinked
+	}
+	filter.PatientID, filter.UserID = patient.ID, nil
+	if err := checkRecordAccessFilter(&filter); err != nil {
+		return nil, err
+	}
+	viewers, err := s.repository.Viewers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if viewers == nil {
+		viewers = []models.RecordViewer{}
+	}
+	return viewers, nil
+}
+
+// checkRecordAccessFilter checks the days of filter and bounds its limit
+func checkRecordAccessFilter(filter *models.RecordAccessFilter) error {
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return fmt.Errorf("%w: to must be after from", ErrInvalidRecordAccessFilter)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultRecordAccessLimit
+	}
+	filter.Limit = min(filter.Limit, maxRecordAccessLimit)
+	return nil
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/pdf"
+	"RoyDental/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrReferralNotFound         = errors.New("referral not found")
+	ErrInvalidReferral          = errors.New("invalid referral")
+	ErrReferralTemplateNotFound = errors.New("referral template not found")
+	ErrInvalidReferralTemplate  = errors.New("invalid referral template")
+	ErrInvalidReferralLink      = errors.New("invalid or expired referral link")
+)
+
+// ReferralService writes patients' referral letters to specialists from templates, quoting the
+// examinations the doctor picks and linking the imaging, and keeps each referral with its letter.
+// Imaging links are signed and expire, so the specialist needs no account to open them.
+type ReferralService struct {
+	repository      *repositories.ReferralRepository
+	patientRepo     *repositories.PatientRepository
+	doctorRepo      *repositories.DoctorAppRepository
+	examinationRepo *repositories.ExaminationRepository
+	imagingRepo     *repositories.ImagingRepository
+	config          config.ReferralConfig
+}
+
+func NewReferralService(repository *repositories.ReferralRepository, patientRepo *repositories.PatientRepository, doctorRepo *repositories.DoctorAppRepository, examinationRepo *repositories.ExaminationRepository, imagingRepo *repositories.ImagingRepository, cfg config.ReferralConfig) *ReferralService {
+	return &ReferralService{
+		repository:      repository,
+		patientRepo:     patientRepo,
+		doctorRepo:      doctorRepo,
+		examinationRepo: examinationRepo,
+		imagingRepo:     imagingRepo,
+		config:          cfg,
+	}
+}
+
+// LinksEnabled reports whether imaging links can be signed
+func (s *ReferralService) LinksEnabled() bool {
+	return s.config.SigningKey != ""
+}
+
+func (s *ReferralService) CreateTemplate(ctx context.Context, template *models.ReferralTemplate) error {
+	if err := s.validateTemplate(ctx, template); err != nil {
+		return err
+	}
+	return s.repository.CreateTemplate(ctx, template)
+}
+
+func (s *ReferralService) GetTemplate(ctx context.Context, id uint) (*models.ReferralTemplate, error) {
+	template, err := s.repository.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, ErrReferralTemplateNotFound
+	}
+	return template, nil
+}
+
+func (s *ReferralService) ListTemplates(ctx context.Context) ([]models.ReferralTemplate, error) {
+	templates, err := s.repository.ListTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if templates == nil {
+		templates = []models.ReferralTemplate{}
+	}
+	return templates, nil
+}
+
+func (s *ReferralService) UpdateTemplate(ctx context.Context, template *models.ReferralTemplate) error {
+	if _, err := s.GetTemplate(ctx, template.ID); err != nil {
+		return err
+	}
+	if err := s.validateTemplate(ctx, template); err != nil {
+		return err
+	}
+	return s.repository.UpdateTemplate(ctx, template)
+}
+
+func (s *ReferralService) DeleteTemplate(ctx context.Context, id uint) error {
+	if _, err := s.GetTemplate(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteTemplate(ctx, id)
+}
+
+func (s *ReferralService) validateTemplate(ctx context.Context, template *models.ReferralTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	template.Specialty = strings.TrimSpace(template.Specialty)
+	if template.Name == "" || strings.TrimSpace(template.Body) == "" {
+		return fmt.Errorf("%w: name and body are required", ErrInvalidReferralTemplate)
+	}
+	if template.Specialty != "" && !models.IsValidSpecialty(template.Specialty) {
+		return fmt.Errorf("%w: unknown specialty %q", ErrInvalidReferralTemplate, template.Specialty)
+	}
+	exists, err := s.repository.TemplateExists(ctx, template.Name, template.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: a template called %q already exists", ErrInvalidReferralTemplate, template.Name)
+	}
+	return nil
+}
+
+// Create writes the referral's letter from its template, or the default wording, and saves it
+// with excerpts of the examinations and the imaging it refers to
+func (s *ReferralService) Create(ctx context.Context, referral *models.Referral) error {
+	referral.SpecialistName = strings.TrimSpace(referral.SpecialistName)
+	referral.SpecialistPractice = strings.TrimSpace(referral.SpecialistPractice)
+	referral.SpecialistEmail = strings.TrimSpace(referral.SpecialistEmail)
+	referral.Specialty = strings.TrimSpace(referral.Specialty)
+	referral.Reason = strings.TrimSpace(referral.Reason)
+	if referral.SpecialistName == "" || referral.Reason == "" {
+		return fmt.Errorf("%w: specialist_name and reason are required", ErrInvalidReferral)
+	}
+	if referral.Specialty != "" && !models.IsValidSpecialty(referral.Specialty) {
+		return fmt.Errorf("%w: unknown specialty %q", ErrInvalidReferral, referral.Specialty)
+	}
+	if referral.SpecialistEmail != "" {
+		if _, err := mail.ParseAddress(referral.SpecialistEmail); err != nil {
+			return fmt.Errorf("%w: invalid specialist_email", ErrInvalidReferral)
+		}
+	}
+
+	patient, err := s.patientRepo.GetByID(ctx, referral.PatientID)
+	if err != nil {
+		return err
+	}
+	if patient == nil {
+		return ErrPatientNotFound
+	}
+	doctor, err := s.doctorRepo.GetDoctor(ctx, referral.DoctorID)
+	if err != nil {
+		return err
+	}
+	if doctor == nil {
+		return fmt.Errorf("%w: doctor %s not found", ErrInvalidReferral, referral.DoctorID)
+	}
+	referral.Patient, referral.Doctor = *patient, *doctor
+
+	body := models.DefaultReferralLetter
+	if referral.TemplateID != nil {
+		template, err := s.repository.GetTemplate(ctx, *referral.TemplateID)
+		if err != nil {
+			return err
+		}
+		if template == nil {
+			return fmt.Errorf("%w: template %d not found", ErrInvalidReferral, *referral.TemplateID)
+		}
+		body = template.Body
+		if referral.Specialty == "" {
+			referral.Specialty = template.Specialty
+		}
+	}
+	referral.Letter = referral.Fill(body)
+
+	referral.Examinations = nil
+	for _, id := range uniqueIDs(referral.ExaminationIDs) {
+		examination, err := s.examinationRepo.GetByID(ctx, referral.PatientID, id)
+		if err != nil {
+			return err
+		}
+		if examination == nil {
+			return fmt.Errorf("%w: examination %d not found for the patient", ErrInvalidReferral, id)
+		}
+		referral.Examinations = append(referral.Examinations, models.ReferralExamination{
+			ExaminationID: examination.ID,
+			ExaminedAt:    examination.CreatedAt,
+			Excerpt:       excerpt(examination.Report, s.config.ExcerptLength),
+		})
+	}
+
+	referral.Imaging = nil
+	for _, id := range uniqueIDs(referral.ImagingStudyIDs) {
+		study, err := s.imagingRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if study == nil || study.PatientID == nil || *study.PatientID != referral.PatientID {
+			return fmt.Errorf("%w: imaging study %d not found for the patient", ErrInvalidReferral, id)
+		}
+		referral.Imaging = append(referral.Imaging, models.ReferralImaging{
+			ImagingStudyID: study.ID,
+			Modality:       study.Modality,
+			StudyDate:      study.StudyDate,
+		})
+	}
+
+	referral.ID = 0
+	referral.Status = models.ReferralStatusDraft
+	referral.StatusNote = ""
+	return s.repository.Create(ctx, referral)
+}
+
+func (s *ReferralService) Get(ctx context.Context, patientID string, id uint) (*models.Referral, error) {
+	referral, err := s.repository.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if referral == nil {
+		return nil, ErrReferralNotFound
+	}
+	return referral, nil
+}
+
+// List returns the patient's referrals, newest first
+func (s *ReferralService) List(ctx context.Context, patientID string) ([]models.Referral, error) {
+	referrals, err := s.repository.List(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if referrals == nil {
+		referrals = []models.Referral{}
+	}
+	return referrals, nil
+}
+
+// SetStatus records how far the referral has got with the specialist
+func (s *ReferralService) SetStatus(ctx context.Context, patientID string, id uint, status, note string) (*models.Referral, error) {
+	if !models.IsValidReferralStatus(status) {
+		return nil, fmt.Errorf("%w: status must be draft, sent, accepted, completed or declined", ErrInvalidReferral)
+	}
+	referral, err := s.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	referral.Status = status
+	referral.StatusNote = strings.TrimSpace(note)
+	if err := s.repository.SetStatus(ctx, referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// PDF returns the referral letter to print or send to the specialist. Imaging links are signed
+// afresh each time, valid for the configured time from now.
+func (s *ReferralService) PDF(ctx context.Context, patientID string, id uint) ([]byte, error) {
+	referral, err := s.Get(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(referral, time.Now()), nil
+}
+
+func (s *ReferralService) render(referral *models.Referral, now time.Time) []byte {
+	patient := referral.Patient
+	doc := pdf.New()
+	doc.Title(s.config.ClinicName + " - Referral letter")
+	doc.Text("Date: " + models.FormatDate(referral.CreatedAt.In(models.ClinicLocation())))
+	to := referral.SpecialistName
+	if referral.SpecialistPractice != "" {
+		to += ", " + referral.SpecialistPractice
+	}
+	doc.Text("To: " + to)
+	if referral.Specialty != "" {
+		doc.Text("Specialty: " + strings.ReplaceAll(referral.Specialty, "_", " "))
+	}
+	doc.Text("From: Dr " + referral.Doctor.FirstName + " " + referral.Doctor.LastName)
+
+	doc.Heading("Patient")
+	doc.Text("Name: " + strings.Join(strings.Fields(patient.FirstName+" "+patient.MiddleName+" "+patient.LastName), " "))
+	doc.Text("Date of birth: " + patient.DateOfBirth)
+	doc.Text("Sex: " + patient.Sex)
+	if patient.NationalID != "" {
+		doc.Text("National ID: " + patient.NationalID)
+	}
+	if patient.Phone != "" {
+		doc.Text("Phone: " + patient.Phone)
+	}
+
+	doc.Heading("Referral")
+	doc.Text(strings.TrimSpace(referral.Letter))
+
+	if len(referral.Examinations) > 0 {
+		doc.Heading("Relevant examination findings")
+		for _, examination := range referral.Examinations {
+			doc.Text("Examination of " + models.FormatDate(examination.ExaminedAt.In(models.ClinicLocation())) + ":")
+			doc.Text(examination.Excerpt)
+			doc.Space(4)
+		}
+	}
+
+	if len(referral.Imaging) > 0 {
+		doc.Heading("Imaging")
+		expires := now.Add(s.config.LinkTTL)
+		for _, imaging := range referral.Imaging {
+			line := imagingLabel(imaging)
+			if s.LinksEnabled() {
+				line += ": " + s.ImagingLink(referral.ID, imaging.ImagingStudyID, expires)
+			}
+			doc.Text(line)
+		}
+		doc.Space(4)
+		if s.LinksEnabled() {
+			doc.Text("The links above download the images in DICOM format until " + models.FormatDate(expires.In(models.ClinicLocation())) + ".")
+		} else {
+			doc.Text("The images are available from the clinic on request.")
+		}
+	}
+	return doc.Bytes()
+}
+
+func imagingLabel(imaging models.ReferralImaging) string {
+	label := imaging.Modality
+	if label == "" {
+		label = "Image"
+	}
+	if imaging.StudyDate != nil {
+		label += " of " + models.FormatDate(*imaging.StudyDate)
+	}
+	return label
+}
+
+// ImagingLink returns the signed link to an imaging study of the referral, valid until expires
+func (s *ReferralService) ImagingLink(referralID, studyID uint, expires time.Time) string {
+	unix := expires.Unix()
+	return fmt.Sprintf("%s/referrals/%d/imaging/%d?expires=%d&signature=%s",
+		strings.TrimRight(s.config.BaseURL, "/"), referralID, studyID, unix, s.signature(referralID, studyID, unix))
+}
+
+func (s *ReferralService) signature(referralID, studyID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	fmt.Fprintf(mac, "referral-imaging:%d:%d:%d", referralID, studyID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LinkedStudy returns the imaging study a signed referral link points to
+func (s *ReferralService) LinkedStudy(ctx context.Context, referralID, studyID uint, expires, signature string) (*models.ImagingStudy, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !s.LinksEnabled() || time.Now().Unix() > unix ||
+		!hmac.Equal([]byte(signature), []byte(s.signature(referralID, studyID, unix))) {
+		return nil, ErrInvalidReferralLink
+	}
+	study, err := s.repository.LinkedStudy(ctx, referralID, studyID)
+	if err != nil {
+		return nil, err
+	}
+	if study == nil {
+		return nil, ErrInvalidReferralLink
+	}
+	return study, nil
+}
+
+// excerpt returns the start of a report, cut at a word boundary after at most length characters
+func excerpt(report string, length int) string {
+	report = strings.TrimSpace(report)
+	runes := []rune(report)
+	if length <= 0 || len(runes) <= length {
+		return report
+	}
+	cut := string(runes[:length])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + " ..."
+}
+
+// uniqueIDs returns ids without repeats, in their first order
+func uniqueIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// registrationTextLimit caps each free-text medical field of the public form.
+const registrationTextLimit = 5000
+
+var (
+	ErrCaptchaFailed          = errors.New("captcha verification failed")
+	ErrInvalidRegistration    = errors.New("invalid registration")
+	ErrRegistrationNotFound   = errors.New("registration not found")
+	ErrRegistrationReviewed   = errors.New("registration has already been reviewed")
+	ErrRegistrationDisallowed = errors.New("pre-registration is not configured")
+)
+
+type RegistrationService struct {
+	repository *repositories.RegistrationRepository
+	config     config.RegistrationConfig
+	guarantors config.GuarantorConfig
+	client     *http.Client
+}
+
+func NewRegistrationService(repository *repositories.RegistrationRepository, cfg config.RegistrationConfig, guarantors config.GuarantorConfig) *RegistrationService {
+	return &RegistrationService{repository: repository, config: cfg, guarantors: guarantors, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Enabled reports whether submissions can be checked against a captcha
+func (s *RegistrationService) Enabled() bool {
+	return s.config.CaptchaSecret != "" && s.config.CaptchaVerifyURL != ""
+}
+
+// Submit queues a pre-registration for review once its captcha has been verified
+func (s *RegistrationService) Submit(ctx context.Context, registration *models.PendingRegistration, captchaToken, ip string) error {
+	if !s.Enabled() {
+		return ErrRegistrationDisallowed
+	}
+	if err := s.verifyCaptcha(ctx, captchaToken, ip); err != nil {
+		return err
+	}
+	if err := validateRegistration(registration, s.guarantors.AdultAge); err != nil {
+		return err
+	}
+	registration.ID = 0
+	registration.Status = models.RegistrationPending
+	registration.PatientID = nil
+	registration.ReviewedBy = nil
+	registration.ReviewedAt = nil
+	registration.ReviewNote = ""
+	registration.IP = ip
+	return s.repository.Create(ctx, registration)
+}
+
+func (s *RegistrationService) GetByID(ctx context.Context, id uint) (*models.PendingRegistration, error) {
+	registration, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if registration == nil {
+		return nil, ErrRegistrationNotFound
+	}
+	return registration, nil
+}
+
+// List returns the registrations in status, pending ones when no status is given
+func (s *RegistrationService) List(ctx context.Context, status string) ([]models.PendingRegistration, error) {
+	if status == "" {
+		status = models.RegistrationPending
+	}
+	registrations, err := s.repository.List(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if registrations == nil {
+		registrations = []models.PendingRegistration{}
+	}
+	return registrations, nil
+}
+
+// Convert turns a pending registration into a patient record and returns the new patient
+func (s *RegistrationService) Convert(ctx context.Context, id uint, reviewer int64, note string) (*models.Patient, error) {
+	registration, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	patient := registration.Patient()
+	if err := s.repository.Convert(ctx, registration, patient, reviewer, strings.TrimSpace(note)); err != nil {
+		if errors.Is(err, repositories.ErrRegistrationReviewed) {
+			return nil, ErrRegistrationReviewed
+		}
+		return nil, err
+	}
+	return patient, nil
+}
+
+// Reject closes a pending registration, e.g. spam or a duplicate of an existing patient
+func (s *RegistrationService) Reject(ctx context.Context, id uint, reviewer int64, note string) (*models.PendingRegistration, error) {
+	registration, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.Reject(ctx, registration, reviewer, strings.TrimSpace(note)); err != nil {
+		if errors.Is(err, repositories.ErrRegistrationReviewed) {
+			return nil, ErrRegistrationReviewed
+		}
+		return nil, err
+	}
+	return registration, nil
+}
+
+// verifyCaptcha checks the form's captcha response with the provider
+func (s *RegistrationService) verifyCaptcha(ctx context.Context, token, ip string) error {
+	if token == "" {
+		return ErrCaptchaFailed
+	}
+	form := url.Values{"secret": {s.config.CaptchaSecret}, "response": {token}}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.CaptchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build captcha verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to verify captcha: provider returned %s", resp.Status)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to read captcha verification: %w", err)
+	}
+	if !result.Success {
+		return ErrCaptchaFailed
+	}
+	return nil
+}
+
+// validateRegistration checks the form, which must name a guarantor for patients under adultAge
+func validateRegistration(registration *models.PendingRegistration, adultAge int) error {
+	registration.FirstName = strings.TrimSpace(registration.FirstName)
+	registration.MiddleName = strings.TrimSpace(registration.MiddleName)
+	registration.LastName = strings.TrimSpace(registration.LastName)
+	registration.Phone = strings.TrimSpace(registration.Phone)
+	registration.Email = strings.TrimSpace(registration.Email)
+	registration.Address = registration.Address.Trimmed()
+	registration.MedicalHistory = strings.TrimSpace(registration.MedicalHistory)
+	registration.Allergies = strings.TrimSpace(registration.Allergies)
+	registration.Medications = strings.TrimSpace(registration.Medications)
+	registration.CampaignCode = cleanCampaignCode(registration.CampaignCode)
+
+	switch {
+	case registration.FirstName == "" || registration.LastName == "":
+		return fmt.Errorf("%w: first and last name are required", ErrInvalidRegistration)
+	case registration.Sex != "Male" && registration.Sex != "Female" && registration.Sex != "Other":
+		return fmt.Errorf("%w: sex must be Male, Female or Other", ErrInvalidRegistration)
+	case registration.Phone == "" && registration.Email == "":
+		return fmt.Errorf("%w: a phone number or email address is required", ErrInvalidRegistration)
+	case len(registration.MedicalHistory) > registrationTextLimit || len(registration.Allergies) > registrationTextLimit || len(registration.Medications) > registrationTextLimit:
+		return fmt.Errorf("%w: medical details are limited to %d characters each", ErrInvalidRegistration, registrationTextLimit)
+	}
+	dateOfBirth, err := time.Parse("2006-01-02", registration.DateOfBirth)
+	if err != nil || dateOfBirth.After(time.Now()) {
+		return fmt.Errorf("%w: date_of_birth must be a past date as YYYY-MM-DD", ErrInvalidRegistration)
+	}
+	if registration.Insured && strings.TrimSpace(registration.InsuranceCompany) == "" {
+		return fmt.Errorf("%w: the insurance company is required for insured patients", ErrInvalidRegistration)
+	}
+	return checkGuarantor(&registration.Guarantor, registration.DateOfBirth, adultAge, ErrInvalidRegistration)
+}
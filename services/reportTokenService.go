@@ -0,0 +1,155 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// reportTokenPrefix starts every report token so it is recognised wherever it ends up.
+	reportTokenPrefix = "rdr_"
+	// reportTokenTouchInterval is how often a token's last use is written back while it is in use.
+	reportTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrReportTokenNotFound = errors.New("report token not found")
+	ErrInvalidReportToken  = errors.New("invalid report token")
+)
+
+// ReportTokenService mints the read-only tokens reporting tools read the reports with, limited to
+// some reports and branches, and checks them on every request
+type ReportTokenService struct {
+	repository *repositories.ReportTokenRepository
+	config     config.ReportTokenConfig
+	clock      clock.Clock
+}
+
+func NewReportTokenService(repository *repositories.ReportTokenRepository, cfg config.ReportTokenConfig, clock clock.Clock) *ReportTokenService {
+	return &ReportTokenService{repository: repository, config: cfg, clock: clock}
+}
+
+// Mint creates a token for the reports and branches in token's scope, filling in token.Token with
+// the token itself. It is never shown again.
+func (s *ReportTokenService) Mint(ctx context.Context, token *models.ReportToken) error {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReportToken)
+	}
+	reports := models.ReportTokenScope{}
+	for _, report := range token.Reports {
+		if !models.IsValidReport(report) {
+			return fmt.Errorf("%w: unknown report %q", ErrInvalidReportToken, report)
+		}
+		reports = append(reports, report)
+	}
+	branches := models.ReportTokenScope{}
+	for _, branch := range token.Branches {
+		branch = strings.TrimSpace(branch)
+		if branch == "" {
+			return fmt.Errorf("%w: branches must not be blank", ErrInvalidReportToken)
+		}
+		branches = append(branches, branch)
+	}
+	now := s.clock.Now()
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidReportToken)
+	}
+	if token.ExpiresAt == nil && s.config.Lifetime > 0 {
+		expires := now.Add(s.config.Lifetime)
+		token.ExpiresAt = &expires
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate report token: %w", err)
+	}
+	raw := reportTokenPrefix + hex.EncodeToString(secret)
+
+	token.ID = 0
+	token.Reports, token.Branches = reports, branches
+	token.TokenHash = hashReportToken(raw)
+	token.Prefix = raw[:len(reportTokenPrefix)+8]
+	token.RevokedAt, token.RevokedBy, token.LastUsedAt = nil, nil, nil
+	if err := s.repository.Create(ctx, token); err != nil {
+		return err
+	}
+	token.Token = raw
+	return nil
+}
+
+func (s *ReportTokenService) Get(ctx context.Context, id uint) (*models.ReportToken, error) {
+	token, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrReportTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *ReportTokenService) List(ctx context.Context) ([]models.ReportToken, error) {
+	tokens, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []models.ReportToken{}
+	}
+	return tokens, nil
+}
+
+// Revoke stops a token from working before it expires
+func (s *ReportTokenService) Revoke(ctx context.Context, id uint, userID int64) (*models.ReportToken, error) {
+	token, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt != nil {
+		return token, nil
+	}
+	if err := s.repository.Revoke(ctx, token, &userID, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// AuthenticateReportToken returns the active token raw is, or nil when it is unknown, expired or
+// revoked, and records that it was used
+func (s *ReportTokenService) AuthenticateReportToken(ctx context.Context, raw string) (*models.ReportToken, error) {
+	if !strings.HasPrefix(raw, reportTokenPrefix) {
+		return nil, nil
+	}
+	token, err := s.repository.ByHash(ctx, hashReportToken(raw))
+	if err != nil || token == nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if !token.Active(now) {
+		return nil, nil
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= reportTokenTouchInterval {
+		if err := s.repository.Touch(ctx, token.ID, now); err != nil {
+			log.Printf("Failed to record use of report token %d: %v", token.ID, err)
+		}
+	}
+	return token, nil
+}
+
+// hashReportToken is the hash a token is stored and looked up by
+func hashReportToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+)
+
+// ResponseCacheService caches the responses to expensive GETs that answer every user alike, and
+// drops them when the domain events of the records behind them are published
+type ResponseCacheService struct {
+	repository *repositories.ResponseCacheRepository
+	config     config.ResponseCacheConfig
+}
+
+func NewResponseCacheService(repository *repositories.ResponseCacheRepository, cfg config.ResponseCacheConfig) *ResponseCacheService {
+	return &ResponseCacheService{repository: repository, config: cfg}
+}
+
+// Enabled reports whether responses are cached
+func (s *ResponseCacheService) Enabled() bool {
+	return s.config.TTL > 0
+}
+
+// MaxResponseSize returns the largest response body cached
+func (s *ResponseCacheService) MaxResponseSize() int {
+	return s.config.MaxSize
+}
+
+// CachedResponse returns the response cached for a request to route, or nil
+func (s *ResponseCacheService) CachedResponse(ctx context.Context, route, fingerprint string) (*models.CachedResponse, error) {
+	return s.repository.Get(ctx, route, fingerprint)
+}
+
+// SaveResponse caches the response to a request to route
+func (s *ResponseCacheService) SaveResponse(ctx context.Context, route, fingerprint string, response models.CachedResponse) error {
+	return s.repository.Save(ctx, route, fingerprint, response, s.config.TTL)
+}
+
+// InvalidateOn returns an event handler dropping the responses cached for routes, to subscribe to
+// the events of the records those routes return
+func (s *ResponseCacheService) InvalidateOn(routes ...string) events.Handler {
+	return func(ctx context.Context, event events.Event) error {
+		if !s.Enabled() {
+			return nil
+		}
+		for _, route := range routes {
+			if err := s.repository.Invalidate(context.WithoutCancel(ctx), route); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// retentionLockKey serialises enforcement across replicas.
+const retentionLockKey = "retention"
+
+// Retention run listings are limited to
+const (
+	defaultRetentionRunLimit = 50
+	maxRetentionRunLimit     = 500
+)
+
+var ErrInvalidRetentionEntity = errors.New("no retention rules apply to this entity")
+
+// RetentionService enforces the retention periods of the data-protection policy: records older than
+// their entity's period are purged, or archived to files outside the database and then removed.
+// Dry runs count what would go without touching anything, and every run is recorded.
+type RetentionService struct {
+	repository *repositories.RetentionRepository
+	config     config.RetentionConfig
+	files      *Files
+	mu         sync.Mutex
+}
+
+// NewRetentionService starts enforcing the rules every config.Interval when any are configured
+func NewRetentionService(repository *repositories.RetentionRepository, cfg config.RetentionConfig, files *Files) *RetentionService {
+	s := &RetentionService{repository: repository, config: cfg, files: files}
+	if len(cfg.Rules) > 0 && cfg.Interval > 0 {
+		go s.run()
+	}
+	return s
+}
+
+func (s *RetentionService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		runs, err := s.Enforce(context.Background(), false)
+		if err != nil {
+			log.Printf("Failed to enforce retention rules: %v", err)
+			continue
+		}
+		for _, run := range runs {
+			if run.Error != "" {
+				log.Printf("Retention of %s stopped after %d of %d records: %s", run.Entity, run.Removed, run.Due, run.Error)
+			}
+		}
+	}
+}
+
+// Policies returns the retention rules in force, with the cutoff each would apply now
+func (s *RetentionService) Policies() []models.RetentionPolicy {
+	now := time.Now()
+	policies := []models.RetentionPolicy{}
+	for _, entity := range config.RetentionEntities {
+		rule, ok := s.config.Rules[entity]
+		if !ok {
+			continue
+		}
+		policies = append(policies, models.RetentionPolicy{
+			Entity: entity,
+			Action: rule.Action,
+			Days:   rule.Days,
+			Cutoff: now.AddDate(0, 0, -rule.Days),
+		})
+	}
+	return policies
+}
+
+// Enforce applies every retention rule, or with dryRun only counts the records each would remove.
+// A rule that fails stops at the batch it failed on and the others still run.
+func (s *RetentionService) Enforce(ctx context.Context, dryRun bool) ([]models.RetentionRun, error) {
+	if !dryRun {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		release, err := database.Locks.Acquire(ctx, retentionLockKey)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	runs := []models.RetentionRun{}
+	for _, policy := range s.Policies() {
+		run := models.RetentionRun{
+			Entity:    policy.Entity,
+			Action:    policy.Action,
+			Days:      policy.Days,
+			Cutoff:    policy.Cutoff,
+			DryRun:    dryRun,
+			StartedAt: time.Now(),
+		}
+		due, err := s.repository.CountDue(ctx, policy.Entity, policy.Cutoff)
+		if err != nil {
+			return nil, err
+		}
+		run.Due = due
+		if !dryRun && due > 0 {
+			err := s.apply(ctx, &run)
+			if backupErr := s.backUpArchive(ctx, run); err == nil {
+				err = backupErr
+			}
+			if err != nil {
+				run.Error = err.Error()
+			}
+		}
+		finished := time.Now()
+		run.FinishedAt = &finished
+		if err := s.repository.CreateRun(ctx, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// apply removes the run's due records a batch at a time
+func (s *RetentionService) apply(ctx context.Context, run *models.RetentionRun) error {
+	var write func([]json.RawMessage) error
+	if run.Action == config.RetentionArchive {
+		archive, err := s.openArchive(run)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		write = func(documents []json.RawMessage) error {
+			for _, document := range documents {
+				if _, err := archive.Write(append(document, '\n')); err != nil {
+					return fmt.Errorf("failed to write archive: %w", err)
+				}
+			}
+			// Records are deleted only once their copy is on disk
+			return archive.Sync()
+		}
+	}
+
+	for {
+		var removed int64
+		var err error
+		if write != nil {
+			removed, err = s.repository.Archive(ctx, run.Entity, run.Cutoff, s.config.BatchSize, write)
+		} else {
+			removed, err = s.repository.Purge(ctx, run.Entity, run.Cutoff, s.config.BatchSize)
+		}
+		if err != nil {
+			return err
+		}
+		run.Removed += removed
+		if removed < int64(s.config.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// openArchive creates the file a run's records are archived to
+func (s *RetentionService) openArchive(run *models.RetentionRun) (*os.File, error) {
+	if s.config.ArchiveDir == "" {
+		return nil, errors.New("RETENTION_ARCHIVE_DIR is not set, so nothing can be archived")
+	}
+	if err := os.MkdirAll(s.config.ArchiveDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	run.ArchiveFile = filepath.Join(s.config.ArchiveDir, fmt.Sprintf("%s-%s.jsonl", run.Entity, run.StartedAt.UTC().Format("20060102T150405Z")))
+	archive, err := os.OpenFile(run.ArchiveFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	return archive, nil
+}
+
+// backUpArchive copies the run's archive to the backups bucket when files are kept in object
+// storage, so archived records are kept where the deployment keeps its data and not only on the
+// disk of the replica that ran
+func (s *RetentionService) backUpArchive(ctx context.Context, run models.RetentionRun) error {
+	if run.ArchiveFile == "" || !s.files.External() {
+		return nil
+	}
+	content, err := os.ReadFile(run.ArchiveFile)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	return s.files.Put(ctx, storage.Backups, "retention/"+filepath.Base(run.ArchiveFile), content, "application/x-ndjson")
+}
+
+// Runs returns the latest retention runs, of entity when one is given
+func (s *RetentionService) Runs(ctx context.Context, entity string, limit int) ([]models.RetentionRun, error) {
+	if entity != "" && !slices.Contains(config.RetentionEntities, entity) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRetentionEntity, entity)
+	}
+	if limit <= 0 {
+		limit = defaultRetentionRunLimit
+	}
+	runs, err := s.repository.ListRuns(ctx, entity, min(limit, maxRetentionRunLimit))
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.RetentionRun{}
+	}
+	return runs, nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrSeededRole         = errors.New("the built-in roles cannot be deleted")
+)
+
+// RoleService lets admins define roles beyond the built-in ones and change the permissions each
+// role is granted at runtime
+type RoleService struct {
+	repository *repositories.RoleRepository
+	userRepo   repositories.UserRepository
+}
+
+func NewRoleService(repository *repositories.RoleRepository, userRepo repositories.UserRepository) *RoleService {
+	return &RoleService{repository: repository, userRepo: userRepo}
+}
+
+func (s *RoleService) CreateRole(ctx context.Context, role *models.Role) error {
+	role.ID = 0
+	role.Name = strings.TrimSpace(role.Name)
+	role.Description = strings.TrimSpace(role.Description)
+	role.BasedOn = strings.TrimSpace(role.BasedOn)
+	if role.Name == "" || len(role.Name) > 50 {
+		return fmt.Errorf("%w: name is required and at most 50 characters", ErrInvalidRole)
+	}
+	if role.BasedOn != "" && !models.IsSeededRole(role.BasedOn) {
+		return fmt.Errorf("%w: a role can only be based on a built-in role", ErrInvalidRole)
+	}
+	taken, err := s.repository.RoleNameTaken(ctx, role.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: a role called %q already exists", ErrInvalidRole, role.Name)
+	}
+	role.Permissions = nil
+	if err := s.repository.CreateRole(ctx, role); err != nil {
+		return err
+	}
+	role.Permissions = []models.Permission{}
+	return nil
+}
+
+func (s *RoleService) GetRole(ctx context.Context, id int64) (*models.Role, error) {
+	role, err := s.repository.GetRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	if role.Permissions == nil {
+		role.Permissions = []models.Permission{}
+	}
+	return role, nil
+}
+
+func (s *RoleService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	roles, err := s.repository.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []models.Role{}
+	}
+	for i := range roles {
+		if roles[i].Permissions == nil {
+			roles[i].Permissions = []models.Permission{}
+		}
+	}
+	return roles, nil
+}
+
+// UpdateRole changes a role's description. Names and the roles they are based on are fixed once
+// created because access tokens carry them.
+func (s *RoleService) UpdateRole(ctx context.Context, id int64, description string) (*models.Role, error) {
+	role, err := s.GetRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	role.Description = strings.TrimSpace(description)
+	if err := s.repository.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteRole deletes a custom role no user has
+func (s *RoleService) DeleteRole(ctx context.Context, id int64) error {
+	role, err := s.GetRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	if models.IsSeededRole(role.Name) {
+		return ErrSeededRole
+	}
+	return s.repository.DeleteRole(ctx, id)
+}
+
+func (s *RoleService) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	permissions, err := s.repository.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []models.Permission{}
+	}
+	return permissions, nil
+}
+
+// Grant gives a role a permission and returns the role as it now stands
+func (s *RoleService) Grant(ctx context.Context, roleID, permissionID int64) (*models.Role, error) {
+	if err := s.checkGrant(ctx, roleID, permissionID); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Grant(ctx, roleID, permissionID); err != nil {
+		return nil, err
+	}
+	return s.GetRole(ctx, roleID)
+}
+
+// Revoke takes a permission from a role and returns the role as it now stands
+func (s *RoleService) Revoke(ctx context.Context, roleID, permissionID int64) (*models.Role, error) {
+	if err := s.checkGrant(ctx, roleID, permissionID); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Revoke(ctx, roleID, permissionID); err != nil {
+		return nil, err
+	}
+	return s.GetRole(ctx, roleID)
+}
+
+func (s *RoleService) checkGrant(ctx context.Context, roleID, permissionID int64) error {
+	if _, err := s.GetRole(ctx, roleID); err != nil {
+		return err
+	}
+	permission, err := s.repository.GetPermission(ctx, permissionID)
+	if err != nil {
+		return err
+	}
+	if permission == nil {
+		return ErrPermissionNotFound
+	}
+	return nil
+}
+
+// EffectivePermissions returns what a user may do through their role
+func (s *RoleService) EffectivePermissions(ctx context.Context, userID int64) (*models.EffectivePermissions, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	permissions, err := s.repository.RolePermissions(ctx, user.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []models.Permission{}
+	}
+	return &models.EffectivePermissions{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Role:        user.Role.Name,
+		Permissions: permissions,
+	}, nil
+}
+
+// HasPermission reports whether the user's role grants the permission
+func (s *RoleService) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	return hasPermission(ctx, s.userRepo, userID, permission)
+}
+
+// hasPermission reports whether the role of the user grants the permission
+func hasPermission(ctx context.Context, userRepo repositories.UserRepository, userID int64, permission string) (bool, error) {
+	permissions, err := userRepo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, granted := range permissions {
+		if granted.Name == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxShiftDuration caps how long a single shift may run
+	maxShiftDuration = 24 * time.Hour
+	// maxRosterDays caps the period the roster is shown for at once
+	maxRosterDays = 92
+	// defaultRosterDays is the period the roster is shown for when no end is given
+	defaultRosterDays = 7
+	// maxLeaveDays caps how long a single leave request may run
+	maxLeaveDays = 366
+)
+
+var (
+	ErrShiftNotFound        = errors.New("shift not found")
+	ErrShiftSwapNotFound    = errors.New("shift swap not found")
+	ErrLeaveRequestNotFound = errors.New("leave request not found")
+	ErrInvalidRoster        = errors.New("invalid roster request")
+)
+
+// RosterService keeps the staff roster: the shifts each staff member works at each branch, the
+// swaps they ask to hand shifts to colleagues and their leave requests, both approved by an admin.
+// Doctors on approved leave are offered no appointment slots.
+type RosterService struct {
+	repository *repositories.RosterRepository
+}
+
+func NewRosterService(repository *repositories.RosterRepository) *RosterService {
+	return &RosterService{repository: repository}
+}
+
+func (s *RosterService) CreateShift(ctx context.Context, shift *models.Shift) error {
+	if err := s.validateShift(ctx, shift); err != nil {
+		return err
+	}
+	shift.ID = 0
+	return s.repository.CreateShift(ctx, shift)
+}
+
+func (s *RosterService) GetShift(ctx context.Context, id uint) (*models.Shift, error) {
+	shift, err := s.repository.GetShift(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if shift == nil {
+		return nil, ErrShiftNotFound
+	}
+	return shift, nil
+}
+
+func (s *RosterService) UpdateShift(ctx context.Context, shift *models.Shift) error {
+	if _, err := s.GetShift(ctx, shift.ID); err != nil {
+		return err
+	}
+	if err := s.validateShift(ctx, shift); err != nil {
+		return err
+	}
+	return s.repository.UpdateShift(ctx, shift)
+}
+
+func (s *RosterService) DeleteShift(ctx context.Context, id uint) error {
+	if _, err := s.GetShift(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.DeleteShift(ctx, id)
+}
+
+func (s *RosterService) validateShift(ctx context.Context, shift *models.Shift) error {
+	shift.Branch = strings.TrimSpace(shift.Branch)
+	shift.Note = strings.TrimSpace(shift.Note)
+	if shift.Branch == "" {
+		return fmt.Errorf("%w: branch is required", ErrInvalidRoster)
+	}
+	if shift.StartsAt.IsZero() || !shift.EndsAt.After(shift.StartsAt) {
+		return fmt.Errorf("%w: a shift must end after it starts", ErrInvalidRoster)
+	}
+	if shift.EndsAt.Sub(shift.StartsAt) > maxShiftDuration {
+		return fmt.Errorf("%w: a shift cannot last more than %s", ErrInvalidRoster, maxShiftDuration)
+	}
+	return s.checkUser(ctx, shift.UserID)
+}
+
+func (s *RosterService) checkUser(ctx context.Context, userID int64) error {
+	exists, err := s.repository.UserExists(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: user %d not found", ErrInvalidRoster, userID)
+	}
+	return nil
+}
+
+// Roster returns the shifts and approved leave on the days from through to, as YYYY-MM-DD, of
+// userID and at branch when given. The period starts today and runs a week unless given.
+func (s *RosterService) Roster(ctx context.Context, from, to string, userID *int64, branch string) (*models.Roster, error) {
+	start, end, err := rosterPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+	roster := &models.Roster{
+		From: start.Format(models.ClosureDateLayout),
+		To:   end.AddDate(0, 0, -1).Format(models.ClosureDateLayout),
+	}
+	shifts, err := s.repository.Shifts(ctx, start, end, userID, strings.TrimSpace(branch))
+	if err != nil {
+		return nil, err
+	}
+	leave, err := s.repository.ApprovedLeave(ctx, roster.From, roster.To)
+	if err != nil {
+		return nil, err
+	}
+
+	roster.Shifts, roster.Leave = []models.RosterShift{}, []models.RosterLeave{}
+	for _, request := range leave {
+		if userID == nil || request.UserID == *userID {
+			roster.Leave = append(roster.Leave, request)
+		}
+	}
+	for _, shift := range shifts {
+		day := shift.StartsAt.In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+		for _, request := range leave {
+			if request.UserID == shift.UserID && request.Covers(day) {
+				shift.OnLeave = true
+			}
+		}
+		roster.Shifts = append(roster.Shifts, shift)
+	}
+	return roster, nil
+}
+
+// rosterPeriod returns the clinic days from through to as a half-open interval
+func rosterPeriod(from, to string) (time.Time, time.Time, error) {
+	start, _ := models.ClinicDay(time.Now())
+	if from != "" {
+		parsed, err := models.ParseClinicDate(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: dates must be given as YYYY-MM-DD", ErrInvalidRoster)
+		}
+		start = parsed
+	}
+	end := start.AddDate(0, 0, defaultRosterDays)
+	if to != "" {
+		parsed, err := models.ParseClinicDate(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: dates must be given as YYYY-MM-DD", ErrInvalidRoster)
+		}
+		end = parsed.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must not be after to", ErrInvalidRoster)
+	}
+	if end.Sub(start) > maxRosterDays*24*time.Hour+time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the roster can be shown for at most %d days", ErrInvalidRoster, maxRosterDays)
+	}
+	return start, end, nil
+}
+
+// Calendar returns the roster as an iCalendar document to import into a calendar app. Shifts
+// falling in approved leave are marked cancelled.
+func (s *RosterService) Calendar(ctx context.Context, from, to string, userID *int64, branch string) ([]byte, error) {
+	roster, err := s.Roster(ctx, from, to, userID, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//RoyDental//Staff Roster//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:Staff roster")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, shift := range roster.Shifts {
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:shift-%d@roydental", shift.ID))
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		writeICSLine(&b, "DTSTART:"+shift.StartsAt.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "DTEND:"+shift.EndsAt.UTC().Format("20060102T150405Z"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(fmt.Sprintf("%s at %s", shift.Username, shift.Branch)))
+		writeICSLine(&b, "LOCATION:"+escapeICSText(shift.Branch))
+		if shift.Note != "" {
+			writeICSLine(&b, "DESCRIPTION:"+escapeICSText(shift.Note))
+		}
+		if shift.OnLeave {
+			writeICSLine(&b, "STATUS:CANCELLED")
+		} else {
+			writeICSLine(&b, "STATUS:CONFIRMED")
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+	for _, leave := range roster.Leave {
+		start, _ := models.ParseClinicDate(leave.StartDate)
+		end, _ := models.ParseClinicDate(leave.EndDate)
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:leave-%d@roydental", leave.ID))
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		writeICSLine(&b, "DTSTART;VALUE=DATE:"+start.Format("20060102"))
+		writeICSLine(&b, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(leave.Username+" on leave"))
+		writeICSLine(&b, "TRANSP:TRANSPARENT")
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.Bytes(), nil
+}
+
+// RequestSwap asks for the staff member's upcoming shift to be handed to a colleague
+func (s *RosterService) RequestSwap(ctx context.Context, userID int64, swap *models.ShiftSwap) error {
+	shift, err := s.GetShift(ctx, swap.ShiftID)
+	if err != nil {
+		return err
+	}
+	if shift.UserID != userID {
+		return fmt.Errorf("%w: the shift is not yours", ErrInvalidRoster)
+	}
+	if !shift.StartsAt.After(time.Now()) {
+		return fmt.Errorf("%w: the shift has already started", ErrInvalidRoster)
+	}
+	if swap.ToUserID == userID {
+		return fmt.Errorf("%w: to_user_id must be a colleague", ErrInvalidRoster)
+	}
+	if err := s.checkUser(ctx, swap.ToUserID); err != nil {
+		return err
+	}
+	swap.ID = 0
+	swap.FromUserID = userID
+	swap.Note = strings.TrimSpace(swap.Note)
+	swap.Status = models.RosterRequestPending
+	swap.ReviewNote, swap.ReviewedBy, swap.ReviewedAt = "", nil, nil
+	return s.repository.CreateSwap(ctx, swap)
+}
+
+func (s *RosterService) GetSwap(ctx context.Context, id uint) (*models.RosterSwap, error) {
+	swap, err := s.repository.GetSwap(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if swap == nil {
+		return nil, ErrShiftSwapNotFound
+	}
+	return swap, nil
+}
+
+// Swaps returns the shift swaps in status, or all of them when it is empty
+func (s *RosterService) Swaps(ctx context.Context, status string) ([]models.RosterSwap, error) {
+	if status != "" && !isRosterRequestStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidRoster, status)
+	}
+	swaps, err := s.repository.Swaps(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if swaps == nil {
+		swaps = []models.RosterSwap{}
+	}
+	return swaps, nil
+}
+
+// SwapsOf returns the shift swaps a staff member asked for or was asked to take
+func (s *RosterService) SwapsOf(ctx context.Context, userID int64) ([]models.RosterSwap, error) {
+	swaps, err := s.repository.SwapsOf(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if swaps == nil {
+		swaps = []models.RosterSwap{}
+	}
+	return swaps, nil
+}
+
+// ReviewSwap approves or rejects a pending swap; approving it hands the shift over
+func (s *RosterService) ReviewSwap(ctx context.Context, id uint, status, note string) (*models.RosterSwap, error) {
+	swap, err := s.GetSwap(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reviewedBy, reviewedAt, err := reviewer(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	swap.ShiftSwap.Status, swap.ReviewNote, swap.ReviewedBy, swap.ReviewedAt = status, strings.TrimSpace(note), reviewedBy, reviewedAt
+	if err := s.repository.ReviewSwap(ctx, &swap.ShiftSwap); err != nil {
+		return nil, err
+	}
+	return s.GetSwap(ctx, id)
+}
+
+// CancelSwap withdraws a pending swap the staff member asked for
+func (s *RosterService) CancelSwap(ctx context.Context, userID int64, id uint) (*models.RosterSwap, error) {
+	swap, err := s.GetSwap(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if swap.FromUserID != userID {
+		return nil, ErrShiftSwapNotFound
+	}
+	swap.Status = models.RosterRequestCancelled
+	if err := s.repository.ReviewSwap(ctx, &swap.ShiftSwap); err != nil {
+		return nil, err
+	}
+	return s.GetSwap(ctx, id)
+}
+
+// RequestLeave asks for the staff member to be away on the request's days
+func (s *RosterService) RequestLeave(ctx context.Context, userID int64, leave *models.LeaveRequest) error {
+	start, err := models.ParseClinicDate(leave.StartDate)
+	if err != nil {
+		return fmt.Errorf("%w: start_date must be given as YYYY-MM-DD", ErrInvalidRoster)
+	}
+	end, err := models.ParseClinicDate(leave.EndDate)
+	if err != nil {
+		return fmt.Errorf("%w: end_date must be given as YYYY-MM-DD", ErrInvalidRoster)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("%w: end_date must not be before start_date", ErrInvalidRoster)
+	}
+	if end.Sub(start) >= maxLeaveDays*24*time.Hour {
+		return fmt.Errorf("%w: leave can be requested for at most %d days at once", ErrInvalidRoster, maxLeaveDays)
+	}
+	leave.ID = 0
+	leave.UserID = userID
+	leave.Reason = strings.TrimSpace(leave.Reason)
+	leave.Status = models.RosterRequestPending
+	leave.ReviewNote, leave.ReviewedBy, leave.ReviewedAt = "", nil, nil
+	return s.repository.CreateLeave(ctx, leave)
+}
+
+func (s *RosterService) GetLeave(ctx context.Context, id uint) (*models.RosterLeave, error) {
+	leave, err := s.repository.GetLeave(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if leave == nil {
+		return nil, ErrLeaveRequestNotFound
+	}
+	return leave, nil
+}
+
+// Leave returns the leave requests in status, or all of them when it is empty
+func (s *RosterService) Leave(ctx context.Context, status string) ([]models.RosterLeave, error) {
+	if status != "" && !isRosterRequestStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidRoster, status)
+	}
+	leave, err := s.repository.Leave(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if leave == nil {
+		leave = []models.RosterLeave{}
+	}
+	return leave, nil
+}
+
+// LeaveOf returns a staff member's leave requests
+func (s *RosterService) LeaveOf(ctx context.Context, userID int64) ([]models.RosterLeave, error) {
+	leave, err := s.repository.LeaveOf(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if leave == nil {
+		leave = []models.RosterLeave{}
+	}
+	return leave, nil
+}
+
+// ReviewLeave approves or rejects a pending leave request
+func (s *RosterService) ReviewLeave(ctx context.Context, id uint, status, note string) (*models.RosterLeave, error) {
+	leave, err := s.GetLeave(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reviewedBy, reviewedAt, err := reviewer(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	leave.LeaveRequest.Status, leave.ReviewNote, leave.ReviewedBy, leave.ReviewedAt = status, strings.TrimSpace(note), reviewedBy, reviewedAt
+	if err := s.repository.ReviewLeave(ctx, &leave.LeaveRequest); err != nil {
+		return nil, err
+	}
+	return s.GetLeave(ctx, id)
+}
+
+// CancelLeave withdraws a pending leave request of the staff member
+func (s *RosterService) CancelLeave(ctx context.Context, userID int64, id uint) (*models.RosterLeave, error) {
+	leave, err := s.GetLeave(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if leave.UserID != userID {
+		return nil, ErrLeaveRequestNotFound
+	}
+	leave.Status = models.RosterRequestCancelled
+	if err := s.repository.ReviewLeave(ctx, &leave.LeaveRequest); err != nil {
+		return nil, err
+	}
+	return s.GetLeave(ctx, id)
+}
+
+// reviewer checks an admin's decision on a pending request and returns who made it and when
+func reviewer(ctx context.Context, decision string) (*int64, *time.Time, error) {
+	if decision != models.RosterRequestApproved && decision != models.RosterRequestRejected {
+		return nil, nil, fmt.Errorf("%w: status must be approved or rejected", ErrInvalidRoster)
+	}
+	now := time.Now()
+	if userID, ok := models.ActorFrom(ctx); ok {
+		return &userID, &now, nil
+	}
+	return nil, &now, nil
+}
+
+func isRosterRequestStatus(status string) bool {
+	switch status {
+	case models.RosterRequestPending, models.RosterRequestApproved, models.RosterRequestRejected, models.RosterRequestCancelled:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSavedFilterNotFound = errors.New("saved filter not found")
+	ErrSavedFilterExists   = errors.New("saved filter already exists")
+	ErrInvalidSavedFilter  = errors.New("invalid saved filter")
+)
+
+// SavedFilterService keeps the list views each user saves, and works out how a list request is
+// filtered and sorted from its saved filter and ?sort=
+type SavedFilterService struct {
+	repository *repositories.SavedFilterRepository
+}
+
+func NewSavedFilterService(repository *repositories.SavedFilterRepository) *SavedFilterService {
+	return &SavedFilterService{repository: repository}
+}
+
+func (s *SavedFilterService) Create(ctx context.Context, filter *models.SavedFilter) error {
+	if err := s.validate(ctx, filter); err != nil {
+		return err
+	}
+	return s.repository.Create(ctx, filter)
+}
+
+// Get returns a filter the user saved
+func (s *SavedFilterService) Get(ctx context.Context, userID int64, id uint) (*models.SavedFilter, error) {
+	filter, err := s.repository.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return nil, ErrSavedFilterNotFound
+	}
+	return filter, nil
+}
+
+// List returns the filters the user saved for list, or for every list when it is empty
+func (s *SavedFilterService) List(ctx context.Context, userID int64, list string) ([]models.SavedFilter, error) {
+	if list != "" && !models.IsValidList(list) {
+		return nil, fmt.Errorf("%w: list must be %s, %s or %s", ErrInvalidSavedFilter, models.ListPatients, models.ListAppointments, models.ListBillings)
+	}
+	filters, err := s.repository.List(ctx, userID, list)
+	if err != nil {
+		return nil, err
+	}
+	if filters == nil {
+		filters = []models.SavedFilter{}
+	}
+	return filters, nil
+}
+
+func (s *SavedFilterService) Update(ctx context.Context, filter *models.SavedFilter) error {
+	if _, err := s.Get(ctx, filter.UserID, filter.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, filter); err != nil {
+		return err
+	}
+	return s.repository.Update(ctx, filter)
+}
+
+func (s *SavedFilterService) Delete(ctx context.Context, userID int64, id uint) error {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, userID, id)
+}
+
+func (s *SavedFilterService) validate(ctx context.Context, filter *models.SavedFilter) error {
+	filter.Name = strings.TrimSpace(filter.Name)
+	filter.Sort = strings.TrimSpace(filter.Sort)
+	if filter.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSavedFilter)
+	}
+	if !models.IsValidList(filter.List) {
+		return fmt.Errorf("%w: list must be %s, %s or %s", ErrInvalidSavedFilter, models.ListPatients, models.ListAppointments, models.ListBillings)
+	}
+	if filter.Criteria == nil {
+		filter.Criteria = models.FilterCriteria{}
+	}
+	query := models.ListQuery{Criteria: filter.Criteria, Sort: models.ParseSort(filter.Sort)}
+	if err := repositories.CheckListQuery(filter.List, query); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSavedFilter, err)
+	}
+	taken, err := s.repository.NameTaken(ctx, filter.UserID, filter.List, filter.Name, filter.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: you already saved a %s filter named %s", ErrSavedFilterExists, filter.List, filter.Name)
+	}
+	return nil
+}
+
+// Query returns how a request for list is filtered and sorted: by the user's saved filter filterID
+// when one is given, and by sort, or else the saved filter's sort
+func (s *SavedFilterService) Query(ctx context.Context, userID int64, list, filterID, sort string) (models.ListQuery, error) {
+	var query models.ListQuery
+	if filterID != "" {
+		id, err := strconv.ParseUint(filterID, 10, 32)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid filter_id", repositories.ErrInvalidListQuery)
+		}
+		filter, err := s.Get(ctx, userID, uint(id))
+		if err != nil {
+			return query, err
+		}
+		if filter.List != list {
+			return query, fmt.Errorf("%w: saved filter %d is for %s", repositories.ErrInvalidListQuery, filter.ID, filter.List)
+		}
+		query.Criteria = filter.Criteria
+		if sort == "" {
+			sort = filter.Sort
+		}
+	}
+	query.Sort = models.ParseSort(sort)
+	if err := repositories.CheckListQuery(list, query); err != nil {
+		return query, err
+	}
+	return query, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+var ErrInvalidScheduleNotice = errors.New("invalid schedule notice")
+
+// ScheduleImpactService answers what would need rebooking if a doctor called in sick on a day, and
+// tells every affected patient at once. Notices are written to the patients' communication logs;
+// the appointments themselves are left for the front desk to move.
+type ScheduleImpactService struct {
+	repository     *repositories.ScheduleImpactRepository
+	doctorRepo     *repositories.DoctorRepository
+	communications *CommunicationService
+	email          notifications.Notifier
+	sms            notifications.Notifier
+	clinicName     string
+}
+
+func NewScheduleImpactService(repository *repositories.ScheduleImpactRepository, doctorRepo *repositories.DoctorRepository,
+	communications *CommunicationService, email, sms notifications.Notifier, clinicName string) *ScheduleImpactService {
+	return &ScheduleImpactService{
+		repository:     repository,
+		doctorRepo:     doctorRepo,
+		communications: communications,
+		email:          email,
+		sms:            sms,
+		clinicName:     clinicName,
+	}
+}
+
+// Impact returns the doctor's appointments on date (YYYY-MM-DD) with the patients to contact and
+// the revenue at risk
+func (s *ScheduleImpactService) Impact(ctx context.Context, doctorID, date string) (*models.ScheduleImpact, error) {
+	doctor, err := s.doctorRepo.GetByID(ctx, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	if doctor == nil {
+		return nil, ErrDoctorNotFound
+	}
+	day, err := models.ParseClinicDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be given as YYYY-MM-DD", ErrInvalidScheduleNotice)
+	}
+	from, to := models.ClinicDay(day)
+	appointments, err := s.repository.Appointments(ctx, doctorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if appointments == nil {
+		appointments = []models.AffectedAppointment{}
+	}
+
+	impact := &models.ScheduleImpact{
+		DoctorID:     doctor.ID,
+		DoctorName:   doctor.FirstName + " " + doctor.LastName,
+		Date:         date,
+		Appointments: appointments,
+	}
+	patients := make(map[string]bool)
+	for _, appointment := range appointments {
+		if appointment.Price != nil {
+			impact.RevenueAtRisk += *appointment.Price
+		} else {
+			impact.Unpriced++
+		}
+		if patients[appointment.PatientID] {
+			continue
+		}
+		patients[appointment.PatientID] = true
+		if appointment.PatientPhone == "" && appointment.PatientEmail == "" {
+			impact.Unreachable++
+		}
+	}
+	impact.Patients = len(patients)
+	return impact, nil
+}
+
+// Notify sends the notice to the patient of every appointment affected on the notice's date, on the
+// chosen channel as far as the patient agreed to be contacted on it
+func (s *ScheduleImpactService) Notify(ctx context.Context, doctorID string, notice models.ScheduleNotice) (*models.ScheduleNoticeSummary, error) {
+	notice.Message = strings.TrimSpace(notice.Message)
+	if notice.Channel == "" {
+		notice.Channel = notifications.ChannelSMS
+	}
+	notifier := s.sms
+	switch notice.Channel {
+	case notifications.ChannelSMS:
+	case notifications.ChannelEmail:
+		notifier = s.email
+	default:
+		return nil, fmt.Errorf("%w: channel must be sms or email", ErrInvalidScheduleNotice)
+	}
+	impact, err := s.Impact(ctx, doctorID, notice.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.ScheduleNoticeSummary{Results: []models.ScheduleNoticeResult{}}
+	for _, appointment := range impact.Appointments {
+		result := s.send(ctx, notifier, impact.DoctorName, notice, appointment)
+		switch result.Status {
+		case models.MessageSent:
+			summary.Sent++
+		case models.MessageDeferred:
+			summary.Deferred++
+		case models.MessageNotPermitted:
+			summary.NotPermitted++
+		case models.MessageFailed:
+			summary.Failed++
+		default:
+			summary.Unreachable++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return summary, nil
+}
+
+func (s *ScheduleImpactService) send(ctx context.Context, notifier notifications.Notifier, doctorName string, notice models.ScheduleNotice, appointment models.AffectedAppointment) models.ScheduleNoticeResult {
+	result := models.ScheduleNoticeResult{AppointmentID: appointment.AppointmentID, PatientID: appointment.PatientID, Status: "unreachable"}
+	result.Recipient = appointment.PatientPhone
+	if notice.Channel == notifications.ChannelEmail {
+		result.Recipient = appointment.PatientEmail
+	}
+	if result.Recipient == "" || notifier == nil {
+		return result
+	}
+
+	when := appointment.DateTime
+	if startsAt, ok := models.ParseAppointmentTime(appointment.DateTime); ok {
+		startsAt = startsAt.In(models.ClinicLocation())
+		when = models.FormatDayDate(startsAt) + " at " + startsAt.Format("15:04")
+	}
+	body := fmt.Sprintf("Dear %s, unfortunately Dr %s is unable to see you on %s. Please contact %s to rebook your appointment.",
+		strings.SplitN(appointment.PatientName, " ", 2)[0], doctorName, when, s.clinicName)
+	if notice.Message != "" {
+		body += " " + notice.Message
+	}
+	notification := notifications.Notification{
+		Recipients: []string{result.Recipient},
+		PatientID:  appointment.PatientID,
+		Purpose:    notifications.PurposeService,
+		Subject:    "Your appointment at " + s.clinicName + " needs to be rebooked",
+		Body:       body,
+		MessageID:  notifications.NewMessageID(),
+	}
+	sendErr := notifier.Send(ctx, notification)
+	switch {
+	case sendErr == nil:
+		result.Status = models.MessageSent
+	case errors.Is(sendErr, notifications.ErrDeferred):
+		result.Status = models.MessageDeferred
+	case errors.Is(sendErr, notifications.ErrNotPermitted):
+		result.Status = models.MessageNotPermitted
+	default:
+		result.Status, result.Error = models.MessageFailed, sendErr.Error()
+	}
+	err := s.communications.LogMessage(ctx, models.CommunicationLog{
+		PatientID: appointment.PatientID,
+		Channel:   notice.Channel,
+		Purpose:   notification.Purpose,
+		Recipient: result.Recipient,
+		Subject:   notification.Subject,
+		Body:      notification.Body,
+		MessageID: notification.MessageID,
+		Record:    "appointment",
+		RecordID:  fmt.Sprint(appointment.AppointmentID),
+	}, sendErr)
+	if err != nil {
+		log.Printf("Failed to log schedule notice for appointment %d: %v", appointment.AppointmentID, err)
+	}
+	return result
+}
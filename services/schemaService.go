@@ -0,0 +1,65 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrSchemaDrift is returned by the startup check when the live schema is not the one the build expects
+var ErrSchemaDrift = errors.New("database schema drift")
+
+// SchemaService reports how the live database schema differs from the one this build migrates to,
+// so a missing sequence or column is named on startup rather than failing the first request using it
+type SchemaService struct {
+	repository *repositories.SchemaRepository
+}
+
+func NewSchemaService(repository *repositories.SchemaRepository) *SchemaService {
+	return &SchemaService{repository: repository}
+}
+
+// Check compares the live schema with the expected one
+func (s *SchemaService) Check(ctx context.Context) (*models.SchemaDrift, error) {
+	drift, err := s.repository.Drift(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drift.Drifted = len(drift.Problems()) > 0
+	for _, list := range []*[]string{&drift.PendingMigrations, &drift.MissingTables, &drift.MissingColumns, &drift.MissingIndexes, &drift.MissingSequences} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if drift.UnknownMigrations == nil {
+		drift.UnknownMigrations = []int{}
+	}
+	return drift, nil
+}
+
+// CheckOnStartup logs the drift found and posts it as an operational alert. With failOnDrift the
+// drift is returned as an ErrSchemaDrift error so the server does not start on it; a check that
+// cannot run is only logged.
+func (s *SchemaService) CheckOnStartup(ctx context.Context, failOnDrift bool) error {
+	drift, err := s.Check(ctx)
+	if err != nil {
+		log.Printf("Failed to check the database schema: %v", err)
+		return nil
+	}
+	if !drift.Drifted {
+		return nil
+	}
+	problems := strings.Join(drift.Problems(), "; ")
+	log.Printf("Database schema drift: %s", problems)
+	notifications.Publish(notifications.EventOperationalAlert, "Database schema drift",
+		fmt.Sprintf("The database schema differs from the one the server expects: %s.", problems))
+	if failOnDrift {
+		return fmt.Errorf("%w: %s", ErrSchemaDrift, problems)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/debuglog"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidSetting is returned for setting values that cannot be applied
+var ErrInvalidSetting = errors.New("invalid setting")
+
+// colorPattern matches the #RRGGBB colours of appointment types
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// SettingService holds the runtime settings Admins change through the API. Every instance reloads
+// them periodically, so a change reaches all of them within the refresh interval.
+type SettingService struct {
+	repository        *repositories.SettingRepository
+	debugLog          *debuglog.Logger
+	config            config.DebugLogConfig
+	scheduling        config.SchedulingConfig
+	reminders         config.AppointmentReminderConfig
+	birthdayGreetings atomic.Bool
+	appointmentTypes  atomic.Pointer[map[string]models.AppointmentTypeSetting]
+	reminderCadences  atomic.Pointer[map[string]models.ReminderLeads]
+}
+
+// NewSettingService applies the stored settings straight away and keeps reloading them in the background.
+func NewSettingService(repository *repositories.SettingRepository, debugLog *debuglog.Logger, cfg config.DebugLogConfig, scheduling config.SchedulingConfig, reminders config.AppointmentReminderConfig) *SettingService {
+	s := &SettingService{repository: repository, debugLog: debugLog, config: cfg, scheduling: scheduling, reminders: reminders}
+	defaults := models.DefaultAppointmentTypes(scheduling.SlotDuration)
+	s.appointmentTypes.Store(&defaults)
+	cadences := models.DefaultReminderCadences(reminders.Lead)
+	s.reminderCadences.Store(&cadences)
+	models.SetDocumentFormat(models.DefaultDocumentFormat())
+	go s.run()
+	return s
+}
+
+// Get returns the settings in effect.
+func (s *SettingService) Get(ctx context.Context) (*models.AppSettings, error) {
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return s.current(), nil
+}
+
+// SetDebugLogging switches the request and response debug log on or off on every instance.
+func (s *SettingService) SetDebugLogging(ctx context.Context, enabled bool) (*models.AppSettings, error) {
+	if err := s.repository.Set(ctx, models.SettingDebugLogging, strconv.FormatBool(enabled)); err != nil {
+		return nil, err
+	}
+	s.debugLog.SetEnabled(enabled)
+	return s.current(), nil
+}
+
+// SetBirthdayGreetings switches the birthday greetings job on or off.
+func (s *SettingService) SetBirthdayGreetings(ctx context.Context, enabled bool) (*models.AppSettings, error) {
+	if err := s.repository.Set(ctx, models.SettingBirthdayGreetings, strconv.FormatBool(enabled)); err != nil {
+		return nil, err
+	}
+	s.birthdayGreetings.Store(enabled)
+	return s.current(), nil
+}
+
+// BirthdayGreetings reports whether patients are sent a greeting on their birthday. It is off
+// until an Admin switches it on.
+func (s *SettingService) BirthdayGreetings() bool {
+	return s.birthdayGreetings.Load()
+}
+
+// SetAppointmentTypes changes the default duration and colour of the appointment types given;
+// the other types keep theirs.
+func (s *SettingService) SetAppointmentTypes(ctx context.Context, changes map[string]models.AppointmentTypeSetting) (*models.AppSettings, error) {
+	types := s.AppointmentTypes()
+	for name, setting := range changes {
+		if !models.IsValidAppointmentType(name) {
+			return nil, fmt.Errorf("%w: unknown appointment type %q", ErrInvalidSetting, name)
+		}
+		if setting.DurationMinutes <= 0 {
+			return nil, fmt.Errorf("%w: the duration of %s appointments must be positive", ErrInvalidSetting, name)
+		}
+		if !colorPattern.MatchString(setting.Color) {
+			return nil, fmt.Errorf("%w: the colour of %s appointments must look like #4A90D9", ErrInvalidSetting, name)
+		}
+		types[name] = setting
+	}
+
+	value, err := json.Marshal(types)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.Set(ctx, models.SettingAppointmentTypes, string(value)); err != nil {
+		return nil, err
+	}
+	s.appointmentTypes.Store(&types)
+	return s.current(), nil
+}
+
+// AppointmentTypes returns the default duration and colour of every appointment type.
+func (s *SettingService) AppointmentTypes() map[string]models.AppointmentTypeSetting {
+	types := map[string]models.AppointmentTypeSetting{}
+	for name, setting := range *s.appointmentTypes.Load() {
+		types[name] = setting
+	}
+	return types
+}
+
+// AppointmentDuration returns how long an appointment of the type takes by default. Unknown types
+// take a regular slot.
+func (s *SettingService) AppointmentDuration(appointmentType string) time.Duration {
+	if setting, ok := (*s.appointmentTypes.Load())[appointmentType]; ok {
+		return time.Duration(setting.DurationMinutes) * time.Minute
+	}
+	return s.scheduling.SlotDuration
+}
+
+// AppointmentColor returns the calendar colour of the appointment type, or "" for unknown types.
+func (s *SettingService) AppointmentColor(appointmentType string) string {
+	return (*s.appointmentTypes.Load())[appointmentType].Color
+}
+
+// SetReminderCadences changes when the reminders of appointments of the types given are sent, as
+// minutes before the appointment; the other types keep theirs. An empty cadence sends no reminders.
+func (s *SettingService) SetReminderCadences(ctx context.Context, changes map[string]models.ReminderLeads) (*models.AppSettings, error) {
+	cadences := s.ReminderCadences()
+	for name, leads := range changes {
+		if !models.IsValidAppointmentType(name) {
+			return nil, fmt.Errorf("%w: unknown appointment type %q", ErrInvalidSetting, name)
+		}
+		leads, err := checkReminderLeads(leads, ErrInvalidSetting)
+		if err != nil {
+			return nil, err
+		}
+		if leads == nil {
+			leads = models.ReminderLeads{}
+		}
+		cadences[name] = leads
+	}
+
+	value, err := json.Marshal(cadences)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.Set(ctx, models.SettingReminderCadences, string(value)); err != nil {
+		return nil, err
+	}
+	s.reminderCadences.Store(&cadences)
+	return s.current(), nil
+}
+
+// ReminderCadences returns when the reminders of appointments of every type are sent.
+func (s *SettingService) ReminderCadences() map[string]models.ReminderLeads {
+	cadences := map[string]models.ReminderLeads{}
+	for name, leads := range *s.reminderCadences.Load() {
+		cadences[name] = leads
+	}
+	return cadences
+}
+
+// ReminderCadence returns when the reminders of an appointment of the type are sent. Unknown types
+// are reminded of once, the configured lead ahead.
+func (s *SettingService) ReminderCadence(appointmentType string) models.ReminderLeads {
+	if leads, ok := (*s.reminderCadences.Load())[appointmentType]; ok {
+		return leads
+	}
+	return models.ReminderLeads{int(s.reminders.Lead / time.Minute)}
+}
+
+// SetDocumentFormat changes how the documents, statements and emails sent to patients write amounts
+// and dates.
+func (s *SettingService) SetDocumentFormat(ctx context.Context, format models.DocumentFormat) (*models.AppSettings, error) {
+	format.Currency = strings.TrimSpace(format.Currency)
+	if len(format.Currency) > 5 {
+		return nil, fmt.Errorf("%w: the currency is at most 5 characters, such as KES or KSh", ErrInvalidSetting)
+	}
+	if !strings.Contains(",. '", format.ThousandsSeparator) || len(format.ThousandsSeparator) > 1 {
+		return nil, fmt.Errorf("%w: the thousands separator is one of , . ' a space or none", ErrInvalidSetting)
+	}
+	if format.DecimalSeparator != "." && format.DecimalSeparator != "," {
+		return nil, fmt.Errorf("%w: the decimal separator is . or ,", ErrInvalidSetting)
+	}
+	if format.DecimalSeparator == format.ThousandsSeparator {
+		return nil, fmt.Errorf("%w: the thousands and decimal separators must differ", ErrInvalidSetting)
+	}
+	if !models.IsValidDateStyle(format.DateStyle) {
+		return nil, fmt.Errorf("%w: the date style is one of %s, %s, %s or %s", ErrInvalidSetting,
+			models.DateStyleLong, models.DateStyleDayMonthYear, models.DateStyleMonthDayYear, models.DateStyleISO)
+	}
+
+	value, err := json.Marshal(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.Set(ctx, models.SettingDocumentFormat, string(value)); err != nil {
+		return nil, err
+	}
+	models.SetDocumentFormat(format)
+	return s.current(), nil
+}
+
+func (s *SettingService) current() *models.AppSettings {
+	return &models.AppSettings{
+		DebugLogging:      s.debugLog.Enabled(),
+		BirthdayGreetings: s.birthdayGreetings.Load(),
+		AppointmentTypes:  s.AppointmentTypes(),
+		ReminderCadences:  s.ReminderCadences(),
+		DocumentFormat:    models.CurrentDocumentFormat(),
+	}
+}
+
+// load applies the stored settings; settings that were never changed keep their configured value.
+func (s *SettingService) load(ctx context.Context) error {
+	if err := s.loadBool(ctx, models.SettingDebugLogging, s.debugLog.SetEnabled); err != nil {
+		return err
+	}
+	if err := s.loadBool(ctx, models.SettingBirthdayGreetings, s.birthdayGreetings.Store); err != nil {
+		return err
+	}
+	if err := s.loadAppointmentTypes(ctx); err != nil {
+		return err
+	}
+	if err := s.loadReminderCadences(ctx); err != nil {
+		return err
+	}
+	return s.loadDocumentFormat(ctx)
+}
+
+// loadAppointmentTypes applies the stored appointment types over the defaults, so types added
+// since the setting was last saved keep their default.
+func (s *SettingService) loadAppointmentTypes(ctx context.Context) error {
+	setting, err := s.repository.Get(ctx, models.SettingAppointmentTypes)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		return nil
+	}
+	var stored map[string]models.AppointmentTypeSetting
+	if err := json.Unmarshal([]byte(setting.Value), &stored); err != nil {
+		log.Printf("Ignoring invalid %s setting: %v", setting.Key, err)
+		return nil
+	}
+	types := models.DefaultAppointmentTypes(s.scheduling.SlotDuration)
+	for name, value := range stored {
+		if models.IsValidAppointmentType(name) && value.DurationMinutes > 0 {
+			types[name] = value
+		}
+	}
+	s.appointmentTypes.Store(&types)
+	return nil
+}
+
+// loadReminderCadences applies the stored reminder cadences over the defaults, so types added since
+// the setting was last saved keep their default.
+func (s *SettingService) loadReminderCadences(ctx context.Context) error {
+	setting, err := s.repository.Get(ctx, models.SettingReminderCadences)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		return nil
+	}
+	var stored map[string]models.ReminderLeads
+	if err := json.Unmarshal([]byte(setting.Value), &stored); err != nil {
+		log.Printf("Ignoring invalid %s setting: %v", setting.Key, err)
+		return nil
+	}
+	cadences := models.DefaultReminderCadences(s.reminders.Lead)
+	for name, leads := range stored {
+		if models.IsValidAppointmentType(name) && leads != nil {
+			cadences[name] = leads
+		}
+	}
+	s.reminderCadences.Store(&cadences)
+	return nil
+}
+
+// loadDocumentFormat applies the stored document format over the default, so fields added since the
+// setting was last saved keep their default.
+func (s *SettingService) loadDocumentFormat(ctx context.Context) error {
+	setting, err := s.repository.Get(ctx, models.SettingDocumentFormat)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		return nil
+	}
+	format := models.DefaultDocumentFormat()
+	if err := json.Unmarshal([]byte(setting.Value), &format); err != nil {
+		log.Printf("Ignoring invalid %s setting: %v", setting.Key, err)
+		return nil
+	}
+	models.SetDocumentFormat(format)
+	return nil
+}
+
+func (s *SettingService) loadBool(ctx context.Context, key string, apply func(bool)) error {
+	setting, err := s.repository.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(setting.Value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s setting %q", setting.Key, setting.Value)
+		return nil
+	}
+	apply(enabled)
+	return nil
+}
+
+func (s *SettingService) run() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), database.Timeouts.DBRead)
+		if err := s.load(ctx); err != nil {
+			log.Printf("Failed to reload settings: %v", err)
+		}
+		cancel()
+
+		// Without an interval the settings are only loaded on start
+		if s.config.RefreshInterval <= 0 {
+			return
+		}
+		time.Sleep(s.config.RefreshInterval)
+	}
+}
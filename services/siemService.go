@@ -0,0 +1,176 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/metrics"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/siem"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// siemLockKey keeps replicas from forwarding the same audit entries at once
+const siemLockKey = "siem_forward_lock"
+
+var (
+	ErrSIEMDisabled = errors.New("no SIEM collector is configured")
+	// ErrSIEMUnavailable is returned when the SIEM collector could not be sent the audit entries
+	ErrSIEMUnavailable = errors.New("the SIEM collector is unavailable")
+)
+
+var siemForwarded = metrics.NewCounterVec("siem_audit_entries_forwarded_total", "Audit entries forwarded to the SIEM collector.")
+
+var siemFailures = metrics.NewCounterVec("siem_forward_failures_total", "Batches of audit entries that could not be forwarded to the SIEM collector.")
+
+// SIEMService forwards the audit trail, security events included, to the SIEM collector of the
+// deployment moments after it is written, while it is still kept in the database. Entries are
+// forwarded in the order they were written and at least once: a batch the collector did not take
+// is sent again on the next run, from where the last batch it took ended.
+type SIEMService struct {
+	repository *repositories.SIEMRepository
+	sink       siem.Sink
+	config     config.SIEMConfig
+	clock      clock.Clock
+	mu         sync.Mutex
+	failing    bool
+}
+
+// NewSIEMService starts forwarding new audit entries every config.Interval when a collector is
+// configured
+func NewSIEMService(repository *repositories.SIEMRepository, sink siem.Sink, cfg config.SIEMConfig, clock clock.Clock) *SIEMService {
+	s := &SIEMService{repository: repository, sink: sink, config: cfg, clock: clock}
+	if s.Enabled() {
+		go s.run()
+	}
+	return s
+}
+
+func (s *SIEMService) Enabled() bool {
+	return s.sink != nil
+}
+
+func (s *SIEMService) run() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		_, err := s.Forward(context.Background())
+		// A collector that is down is reported once, not on every run until it is back
+		s.mu.Lock()
+		switch {
+		case err != nil && !s.failing:
+			log.Printf("Failed to forward audit logs to the SIEM: %v", err)
+		case err == nil && s.failing:
+			log.Printf("Forwarding audit logs to the SIEM resumed")
+		}
+		s.failing = err != nil
+		s.mu.Unlock()
+	}
+}
+
+// Forward sends the audit entries written since the last forwarded one to the collector, a batch at
+// a time, and returns how many it sent. The first time a collector is forwarded to, it is sent the
+// entries written from then on, or the whole audit trail with config.Backfill.
+func (s *SIEMService) Forward(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, ErrSIEMDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	release, err := database.Locks.Acquire(ctx, siemLockKey)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	cursor, err := s.cursor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	forwarded := 0
+	for {
+		// Entries are only forwarded once old enough that no entry written before them is left to commit
+		entries, err := s.repository.Unforwarded(ctx, cursor.LastAuditID, s.clock.Now().Add(-s.config.Lag), s.config.BatchSize)
+		if err != nil {
+			return forwarded, err
+		}
+		if len(entries) == 0 {
+			return forwarded, nil
+		}
+
+		now := s.clock.Now()
+		if err := s.sink.Send(ctx, entries); err != nil {
+			siemFailures.Inc()
+			cursor.LastError, cursor.FailedAt = err.Error(), &now
+			if saveErr := s.repository.SaveCursor(ctx, cursor); saveErr != nil {
+				log.Printf("Failed to record SIEM failure: %v", saveErr)
+			}
+			return forwarded, fmt.Errorf("%w: %v", ErrSIEMUnavailable, err)
+		}
+		cursor.LastAuditID, cursor.ForwardedAt = entries[len(entries)-1].ID, &now
+		cursor.LastError, cursor.FailedAt = "", nil
+		if err := s.repository.SaveCursor(ctx, cursor); err != nil {
+			return forwarded, err
+		}
+		siemForwarded.Add(float64(len(entries)))
+		forwarded += len(entries)
+		if len(entries) < s.config.BatchSize {
+			return forwarded, nil
+		}
+	}
+}
+
+// cursor returns how far the audit trail was forwarded to the configured collector, starting it
+// on first use
+func (s *SIEMService) cursor(ctx context.Context) (*models.SIEMCursor, error) {
+	endpoint := siem.Endpoint(s.config.URL)
+	cursor, err := s.repository.Cursor(ctx, endpoint)
+	if err != nil || cursor != nil {
+		return cursor, err
+	}
+
+	cursor = &models.SIEMCursor{Endpoint: endpoint}
+	if !s.config.Backfill {
+		if cursor.LastAuditID, err = s.repository.LastAuditID(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repository.SaveCursor(ctx, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// Status reports where the audit trail is forwarded to and how far it got
+func (s *SIEMService) Status(ctx context.Context) (*models.SIEMStatus, error) {
+	status := &models.SIEMStatus{Enabled: s.Enabled()}
+	if !status.Enabled {
+		return status, nil
+	}
+	status.Endpoint, status.Format = siem.Endpoint(s.config.URL), s.config.Format
+
+	cursor, err := s.repository.Cursor(ctx, status.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		// Nothing was forwarded yet; the first run starts the collector off
+		return status, nil
+	}
+	status.LastAuditID, status.ForwardedAt = cursor.LastAuditID, cursor.ForwardedAt
+	status.LastError, status.FailedAt = cursor.LastError, cursor.FailedAt
+	if status.Pending, err = s.repository.CountUnforwarded(ctx, cursor.LastAuditID); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// smsInboxLimit caps the replies the staff inbox lists at once
+const smsInboxLimit = 500
+
+var (
+	// ErrSMSWebhookDisabled is returned for forwarded replies while no webhook secret is configured
+	ErrSMSWebhookDisabled = errors.New("SMS replies are not accepted")
+	// ErrInvalidSMSReply is returned for forwarded replies that are unsigned or unreadable
+	ErrInvalidSMSReply = errors.New("invalid SMS reply")
+	// ErrSMSReplyNotFound is returned for a reply that does not exist
+	ErrSMSReplyNotFound = errors.New("SMS reply not found")
+	// ErrSMSReplyNotOpen is returned when marking handled a reply that is not waiting in the inbox
+	ErrSMSReplyNotOpen = errors.New("SMS reply is not waiting in the inbox")
+)
+
+// SMSReplyService takes the text messages patients send back to the clinic. A reply confirming or
+// cancelling the appointment last reminded of by text to the number it came from is acted on at
+// once; any other reply waits in the staff inbox, posted to chat, until someone deals with it.
+type SMSReplyService struct {
+	repository   *repositories.SMSReplyRepository
+	appointments *repositories.AppointmentRepository
+	config       config.SMSReplyConfig
+	clock        clock.Clock
+}
+
+func NewSMSReplyService(repository *repositories.SMSReplyRepository, appointments *repositories.AppointmentRepository, cfg config.SMSReplyConfig, clock clock.Clock) *SMSReplyService {
+	return &SMSReplyService{repository: repository, appointments: appointments, config: cfg, clock: clock}
+}
+
+// Receive takes a reply the gateway forwards, signed with the hex HMAC-SHA256 of its body under the
+// webhook secret. A message forwarded again is not acted on twice; the reply first recorded is returned.
+func (s *SMSReplyService) Receive(ctx context.Context, body []byte, signature string) (*models.SMSReply, error) {
+	if !s.config.Enabled() {
+		return nil, ErrSMSWebhookDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+	mac.Write(body)
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac.Sum(nil), actual) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidSMSReply)
+	}
+	var message models.InboundSMS
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSMSReply, err)
+	}
+	if strings.TrimSpace(message.From) == "" {
+		return nil, fmt.Errorf("%w: from is required", ErrInvalidSMSReply)
+	}
+
+	reply := &models.SMSReply{
+		MessageID:  strings.TrimSpace(message.MessageID),
+		From:       strings.TrimSpace(message.From),
+		Body:       message.Body,
+		Intent:     s.intent(message.Body),
+		Status:     models.SMSReplyOpen,
+		ReceivedAt: s.clock.Now(),
+	}
+	if message.ReceivedAt != nil {
+		reply.ReceivedAt = *message.ReceivedAt
+	}
+	created, err := s.repository.Create(ctx, reply)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return s.repository.GetByMessageID(ctx, reply.MessageID)
+	}
+
+	if err := s.act(ctx, reply); err != nil {
+		return nil, err
+	}
+	if err := s.repository.SetOutcome(ctx, reply); err != nil {
+		return nil, err
+	}
+	if reply.Status == models.SMSReplyOpen {
+		log.Printf("SMS reply %d left in the inbox: %s", reply.ID, reply.Reason)
+		notifications.Publish(notifications.EventSMSReplyReceived, "SMS reply to answer",
+			fmt.Sprintf("%s texted: %q. It is waiting in the SMS inbox (%s).", reply.From, reply.Body, strings.ReplaceAll(reply.Reason, "_", " ")))
+	}
+	return reply, nil
+}
+
+// act links a reply to the appointment reminder it answers and confirms or cancels the appointment
+// when the reply says to, leaving it open with the reason otherwise
+func (s *SMSReplyService) act(ctx context.Context, reply *models.SMSReply) error {
+	digits := trailingDigits(reply.From, s.config.MatchDigits)
+	if digits == "" {
+		reply.Reason = models.SMSReplyUnmatched
+		return nil
+	}
+	reminder, err := s.repository.LastReminder(ctx, digits, reply.ReceivedAt.Add(-s.config.Window))
+	if err != nil {
+		return err
+	}
+	if reminder == nil {
+		reply.Reason = models.SMSReplyUnmatched
+		return nil
+	}
+	reply.PatientID, reply.AppointmentID = &reminder.PatientID, &reminder.AppointmentID
+
+	if reply.Intent == models.SMSIntentUnrecognized {
+		reply.Reason = models.SMSReplyUnrecognized
+		return nil
+	}
+	if reminder.Status != models.AppointmentStatusScheduled || (reminder.StartsAt != nil && !reminder.StartsAt.After(s.clock.Now())) {
+		reply.Reason = models.SMSReplyNotScheduled
+		return nil
+	}
+
+	var done bool
+	if reply.Intent == models.SMSIntentConfirm {
+		done, err = s.appointments.Confirm(ctx, reminder.PatientID, reminder.AppointmentID, reply.ReceivedAt)
+		reply.Status = models.SMSReplyConfirmed
+	} else {
+		done, err = s.appointments.CancelScheduled(ctx, reminder.PatientID, reminder.AppointmentID)
+		reply.Status = models.SMSReplyCancelled
+	}
+	if err != nil {
+		return err
+	}
+	if !done {
+		reply.Status, reply.Reason = models.SMSReplyOpen, models.SMSReplyNotScheduled
+	}
+	return nil
+}
+
+// intent tells whether a reply is one of the confirm or cancel words, ignoring case, surrounding
+// space and punctuation
+func (s *SMSReplyService) intent(body string) string {
+	word := strings.TrimFunc(body, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })
+	for _, confirm := range s.config.ConfirmWords {
+		if strings.EqualFold(word, confirm) {
+			return models.SMSIntentConfirm
+		}
+	}
+	for _, cancel := range s.config.CancelWords {
+		if strings.EqualFold(word, cancel) {
+			return models.SMSIntentCancel
+		}
+	}
+	return models.SMSIntentUnrecognized
+}
+
+// Inbox lists the replies with status, most recent first: those waiting for staff unless another
+// status, or "all", is asked for
+func (s *SMSReplyService) Inbox(ctx context.Context, status string) ([]models.SMSReply, error) {
+	switch status {
+	case "":
+		status = models.SMSReplyOpen
+	case "all":
+		status = ""
+	case models.SMSReplyOpen, models.SMSReplyHandled, models.SMSReplyConfirmed, models.SMSReplyCancelled:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidSMSReply, status)
+	}
+	replies, err := s.repository.List(ctx, status, smsInboxLimit)
+	if err != nil {
+		return nil, err
+	}
+	if replies == nil {
+		replies = []models.SMSReply{}
+	}
+	return replies, nil
+}
+
+// MarkHandled takes a reply out of the inbox once staff dealt with it, with an optional note of what was done
+func (s *SMSReplyService) MarkHandled(ctx context.Context, id uint, userID int64, note string) (*models.SMSReply, error) {
+	handled, err := s.repository.MarkHandled(ctx, id, userID, strings.TrimSpace(note))
+	if err != nil {
+		return nil, err
+	}
+	reply, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrSMSReplyNotFound
+	}
+	if !handled {
+		return nil, ErrSMSReplyNotOpen
+	}
+	return reply, nil
+}
+
+// trailingDigits returns the last n digits of a phone number, so +254 712 345678 and 0712345678 match
+func trailingDigits(phone string, n int) string {
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, phone)
+	if n > 0 && len(digits) > n {
+		digits = digits[len(digits)-n:]
+	}
+	return digits
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrInvalidReportPeriod = errors.New("invalid report period")
+
+type StaffActivityService struct {
+	repository *repositories.StaffActivityRepository
+}
+
+func NewStaffActivityService(repository *repositories.StaffActivityRepository) *StaffActivityService {
+	return &StaffActivityService{repository: repository}
+}
+
+// Report summarizes staff activity from the start of from to the end of to
+func (s *StaffActivityService) Report(ctx context.Context, from, to time.Time) (*models.StaffActivityReport, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidReportPeriod)
+	}
+	if to.Sub(from) > maxAnalyticsRange {
+		return nil, fmt.Errorf("%w: the report period may not exceed one year", ErrInvalidReportPeriod)
+	}
+	end := to.AddDate(0, 0, 1)
+
+	counts, err := s.repository.GetCounts(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := s.repository.GetBookings(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+	examinations, err := s.repository.GetExaminations(ctx, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[int64]*models.StaffActivity{}
+	var userIDs []int64
+	for _, count := range counts {
+		activity, ok := users[count.UserID]
+		if !ok {
+			activity = &models.StaffActivity{UserID: count.UserID, Created: map[string]int64{}, Edited: map[string]int64{}}
+			users[count.UserID] = activity
+			userIDs = append(userIDs, count.UserID)
+		}
+		if count.Edited {
+			activity.Edited[count.Record] += count.Count
+			activity.TotalEdited += count.Count
+		} else {
+			activity.Created[count.Record] += count.Count
+			activity.TotalCreated += count.Count
+		}
+	}
+
+	members, err := s.repository.GetStaffMembers(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		users[member.UserID].Username = member.Username
+		users[member.UserID].Role = member.Role
+	}
+
+	report := &models.StaffActivityReport{
+		From:               from.Format("2006-01-02"),
+		To:                 to.Format("2006-01-02"),
+		Users:              make([]models.StaffActivity, 0, len(users)),
+		AppointmentsBooked: bookings,
+		Examinations:       examinations,
+	}
+	for _, id := range userIDs {
+		report.Users = append(report.Users, *users[id])
+	}
+	// Busiest staff first
+	sort.Slice(report.Users, func(i, j int) bool {
+		a, b := report.Users[i], report.Users[j]
+		if a.TotalCreated+a.TotalEdited != b.TotalCreated+b.TotalEdited {
+			return a.TotalCreated+a.TotalEdited > b.TotalCreated+b.TotalEdited
+		}
+		return a.UserID < b.UserID
+	})
+	if report.AppointmentsBooked == nil {
+		report.AppointmentsBooked = []models.StaffBookings{}
+	}
+	if report.Examinations == nil {
+		report.Examinations = []models.DoctorExaminations{}
+	}
+	return report, nil
+}
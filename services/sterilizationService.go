@@ -0,0 +1,170 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBatchNotFound   = errors.New("sterilization batch not found")
+	ErrInvalidBatch    = errors.New("invalid sterilization batch")
+	ErrBatchNotPassed  = errors.New("sterilization batch has not passed")
+	ErrBillingNotFound = errors.New("billing not found")
+)
+
+type SterilizationService struct {
+	repository  *repositories.SterilizationRepository
+	billingRepo *repositories.BillingRepository
+}
+
+func NewSterilizationService(repository *repositories.SterilizationRepository, billingRepo *repositories.BillingRepository) *SterilizationService {
+	return &SterilizationService{repository: repository, billingRepo: billingRepo}
+}
+
+func (s *SterilizationService) CreateBatch(ctx context.Context, batch *models.SterilizationBatch) error {
+	batch.ID = strings.ToUpper(strings.TrimSpace(batch.ID))
+	batch.Sterilizer = strings.TrimSpace(batch.Sterilizer)
+	batch.Result = strings.ToLower(strings.TrimSpace(batch.Result))
+	if batch.Result == "" {
+		batch.Result = models.SterilizationResultPending
+	}
+	if batch.SterilizedAt.IsZero() {
+		batch.SterilizedAt = time.Now()
+	}
+	switch {
+	case batch.ID == "":
+		return fmt.Errorf("%w: the batch ID is required", ErrInvalidBatch)
+	case batch.Sterilizer == "":
+		return fmt.Errorf("%w: the sterilizer is required", ErrInvalidBatch)
+	case !models.IsValidSterilizationResult(batch.Result):
+		return fmt.Errorf("%w: unknown result %q", ErrInvalidBatch, batch.Result)
+	}
+	existing, err := s.repository.GetBatch(ctx, batch.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: batch %s is already logged", ErrInvalidBatch, batch.ID)
+	}
+	return s.repository.CreateBatch(ctx, batch)
+}
+
+func (s *SterilizationService) GetBatch(ctx context.Context, id string) (*models.SterilizationBatch, error) {
+	batch, err := s.repository.GetBatch(ctx, strings.ToUpper(id))
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrBatchNotFound
+	}
+	return batch, nil
+}
+
+// ListBatches returns the batches sterilized on the days from from to to inclusive
+func (s *SterilizationService) ListBatches(ctx context.Context, from, to time.Time) ([]models.SterilizationBatch, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidBatch)
+	}
+	batches, err := s.repository.ListBatches(ctx, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if batches == nil {
+		batches = []models.SterilizationBatch{}
+	}
+	return batches, nil
+}
+
+// RecordResult sets the outcome of the batch's cycle
+func (s *SterilizationService) RecordResult(ctx context.Context, id, result, notes string) (*models.SterilizationBatch, error) {
+	batch, err := s.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result = strings.ToLower(strings.TrimSpace(result))
+	if !models.IsValidSterilizationResult(result) {
+		return nil, fmt.Errorf("%w: unknown result %q", ErrInvalidBatch, result)
+	}
+	batch.Result = result
+	if notes != "" {
+		batch.Notes = notes
+	}
+	if err := s.repository.UpdateResult(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// LinkBatches attaches instrument batches to a billed procedure. Only batches whose cycle passed may
+// be used on patients.
+func (s *SterilizationService) LinkBatches(ctx context.Context, billingID string, batchIDs []string) ([]models.BatchUsage, error) {
+	billing, err := s.billingRepo.GetByID(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if billing == nil {
+		return nil, ErrBillingNotFound
+	}
+	if len(batchIDs) == 0 {
+		return nil, fmt.Errorf("%w: at least one batch ID is required", ErrInvalidBatch)
+	}
+
+	usages := make([]models.BatchUsage, 0, len(batchIDs))
+	for _, batchID := range batchIDs {
+		batch, err := s.GetBatch(ctx, strings.TrimSpace(batchID))
+		if err != nil {
+			return nil, err
+		}
+		if batch.Result != models.SterilizationResultPassed {
+			return nil, fmt.Errorf("%w: batch %s is %s", ErrBatchNotPassed, batch.ID, batch.Result)
+		}
+		usages = append(usages, models.BatchUsage{
+			BatchID:   batch.ID,
+			BillingID: billing.BillingID,
+			PatientID: billing.PatientID,
+			DoctorID:  billing.DoctorID,
+			Procedure: billing.Procedure,
+			TreatedAt: billing.CreatedAt,
+		})
+	}
+	if err := s.repository.LinkBatches(ctx, usages); err != nil {
+		return nil, err
+	}
+	return s.GetBillingBatches(ctx, billingID)
+}
+
+func (s *SterilizationService) GetBillingBatches(ctx context.Context, billingID string) ([]models.BatchUsage, error) {
+	usages, err := s.repository.GetUsagesByBilling(ctx, billingID)
+	if err != nil {
+		return nil, err
+	}
+	if usages == nil {
+		usages = []models.BatchUsage{}
+	}
+	return usages, nil
+}
+
+func (s *SterilizationService) UnlinkBatch(ctx context.Context, billingID, batchID string) error {
+	return s.repository.UnlinkBatch(ctx, billingID, strings.ToUpper(batchID))
+}
+
+// Trace reports which patients were treated with instruments from the batch
+func (s *SterilizationService) Trace(ctx context.Context, batchID string) (*models.BatchTraceReport, error) {
+	batch, err := s.GetBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	traces, err := s.repository.Trace(ctx, batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	if traces == nil {
+		traces = []models.BatchTrace{}
+	}
+	return &models.BatchTraceReport{Batch: *batch, Patients: traces}, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"time"
+)
+
+// submissionPollInterval is how often a duplicate checks whether the first submission was answered.
+const submissionPollInterval = 100 * time.Millisecond
+
+// SubmissionService catches create requests submitted twice, such as a form saved with a double
+// click, so the duplicate is answered with the first request's response instead of creating a twin
+type SubmissionService struct {
+	repository *repositories.SubmissionRepository
+	config     config.DuplicateSubmissionConfig
+}
+
+func NewSubmissionService(repository *repositories.SubmissionRepository, cfg config.DuplicateSubmissionConfig) *SubmissionService {
+	return &SubmissionService{repository: repository, config: cfg}
+}
+
+// Enabled reports whether duplicate submissions are caught
+func (s *SubmissionService) Enabled() bool {
+	return s.config.Window > 0
+}
+
+// ClaimSubmission records a submission and reports whether it is the first within the window
+func (s *SubmissionService) ClaimSubmission(ctx context.Context, fingerprint string) (bool, error) {
+	return s.repository.Claim(ctx, fingerprint, s.config.Window)
+}
+
+// AwaitSubmissionResponse waits for the response to the first submission, returning nil when it
+// is not answered in time or failed
+func (s *SubmissionService) AwaitSubmissionResponse(ctx context.Context, fingerprint string) (*models.SubmissionResponse, error) {
+	deadline := time.Now().Add(s.config.Wait)
+	for {
+		response, err := s.repository.Response(ctx, fingerprint)
+		if err != nil || response != nil || !time.Now().Before(deadline) {
+			return response, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(submissionPollInterval):
+		}
+	}
+}
+
+// SaveSubmissionResponse keeps the first submission's response for its duplicates
+func (s *SubmissionService) SaveSubmissionResponse(ctx context.Context, fingerprint string, response models.SubmissionResponse) error {
+	return s.repository.SaveResponse(ctx, fingerprint, response, s.config.Window)
+}
+
+// ReleaseSubmission forgets a submission that failed, so submitting it again is handled afresh
+func (s *SubmissionService) ReleaseSubmission(ctx context.Context, fingerprint string) error {
+	return s.repository.Release(ctx, fingerprint)
+}
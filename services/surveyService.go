@@ -0,0 +1,157 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// surveyBatchSize caps the invitations sent per dispatch run.
+const surveyBatchSize = 200
+
+// ErrInvalidSurveySignature is returned for survey links that were not issued by this server
+var ErrInvalidSurveySignature = errors.New("invalid survey link")
+
+type SurveyService struct {
+	repository *repositories.SurveyRepository
+	notifier   notifications.Notifier
+	config     config.SurveyConfig
+}
+
+// NewSurveyService starts sending surveys in the background when surveys are configured.
+func NewSurveyService(repository *repositories.SurveyRepository, notifier notifications.Notifier, cfg config.SurveyConfig) *SurveyService {
+	s := &SurveyService{repository: repository, notifier: notifier, config: cfg}
+	if s.Enabled() {
+		go s.run()
+	}
+	return s
+}
+
+// Enabled reports whether survey links can be signed and point somewhere
+func (s *SurveyService) Enabled() bool {
+	return s.config.SigningKey != "" && s.config.BaseURL != ""
+}
+
+// Sign returns the signature of the survey link for an appointment
+func (s *SurveyService) Sign(appointmentID uint) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	mac.Write([]byte("survey:" + strconv.FormatUint(uint64(appointmentID), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *SurveyService) verify(appointmentID uint, signature string) error {
+	expected, err := hex.DecodeString(s.Sign(appointmentID))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return ErrInvalidSurveySignature
+	}
+	return nil
+}
+
+// Link returns the signed public link of the survey for an appointment
+func (s *SurveyService) Link(appointmentID uint) string {
+	return fmt.Sprintf("%s?appointment=%d&sig=%s", strings.TrimRight(s.config.BaseURL, "/"), appointmentID, s.Sign(appointmentID))
+}
+
+func (s *SurveyService) GetSurvey(ctx context.Context, appointmentID uint, signature string) (*models.SurveyView, error) {
+	if err := s.verify(appointmentID, signature); err != nil {
+		return nil, err
+	}
+	return s.repository.GetView(ctx, appointmentID)
+}
+
+func (s *SurveyService) Respond(ctx context.Context, appointmentID uint, signature string, rating, nps int, comment string) error {
+	if err := s.verify(appointmentID, signature); err != nil {
+		return err
+	}
+	if rating < 1 || rating > 5 {
+		return errors.New("rating must be between 1 and 5")
+	}
+	if nps < 0 || nps > 10 {
+		return errors.New("nps must be between 0 and 10")
+	}
+	return s.repository.Respond(ctx, appointmentID, rating, nps, strings.TrimSpace(comment))
+}
+
+func (s *SurveyService) DoctorReports(ctx context.Context, from, to *time.Time) ([]models.DoctorSurveyReport, error) {
+	reports, err := s.repository.DoctorReports(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if reports == nil {
+		reports = []models.DoctorSurveyReport{}
+	}
+	return reports, nil
+}
+
+func (s *SurveyService) run() {
+	ticker := time.NewTicker(s.config.DispatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !database.IsLeader() {
+			continue
+		}
+		s.dispatch(context.Background())
+	}
+}
+
+// dispatch emails a survey for every recently fulfilled appointment that has not had one. The
+// survey row is recorded before sending so replicas never send the same survey twice.
+func (s *SurveyService) dispatch(ctx context.Context) {
+	invitations, err := s.repository.PendingInvitations(ctx, time.Now().Add(-s.config.Lookback), surveyBatchSize)
+	if err != nil {
+		log.Printf("Failed to find surveys to send: %v", err)
+		return
+	}
+
+	for _, invitation := range invitations {
+		survey := &models.Survey{
+			AppointmentID: invitation.AppointmentID,
+			PatientID:     invitation.PatientID,
+			DoctorID:      invitation.DoctorID,
+			Channel:       "email",
+			SentAt:        time.Now(),
+		}
+		created, err := s.repository.Create(ctx, survey)
+		if err != nil {
+			log.Printf("Failed to record survey for appointment %d: %v", invitation.AppointmentID, err)
+			continue
+		}
+		if !created {
+			continue
+		}
+
+		notification := notifications.Notification{
+			Recipients: []string{invitation.PatientEmail},
+			PatientID:  invitation.PatientID,
+			Purpose:    notifications.PurposeService,
+			Subject:    "How was your visit?",
+			Body: fmt.Sprintf("Dear %s,\n\nThank you for visiting %s on %s. We would appreciate a minute of your time to tell us how it went:\n\n%s\n",
+				invitation.PatientFirstName, invitation.DoctorName, invitation.DateTime, s.Link(invitation.AppointmentID)),
+		}
+		if err := s.notifier.Send(ctx, notification); errors.Is(err, notifications.ErrNotPermitted) {
+			// The patient stopped emails after the invitation was picked, so it is not tried again
+			log.Printf("Survey for appointment %d not sent: %v", invitation.AppointmentID, err)
+		} else if err != nil && !errors.Is(err, notifications.ErrDeferred) {
+			log.Printf("Failed to send survey for appointment %d: %v", invitation.AppointmentID, err)
+			if err := s.repository.Delete(ctx, survey.ID); err != nil {
+				log.Printf("Failed to release survey for appointment %d: %v", invitation.AppointmentID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxSyncPushChanges bounds the changes a sync client pushes at once
+const maxSyncPushChanges = 500
+
+// ErrInvalidSyncRequest is returned for pulls of unknown entities and for pushes that are too large
+var ErrInvalidSyncRequest = errors.New("invalid sync request")
+
+// syncWriter saves the changes pushed to an entity through the service owning it, so pushed records
+// are checked and their events raised as when saved through the API. Updates and deletions are given
+// the record as it is, whose patient records kept per patient stay with.
+type syncWriter struct {
+	create func(ctx context.Context, data json.RawMessage, audit models.AuditLog) (string, error)
+	update func(ctx context.Context, id string, data, current json.RawMessage, audit models.AuditLog) error
+	delete func(ctx context.Context, id string, current json.RawMessage) error
+}
+
+// SyncService lets the offline-capable desktop app mirror the records it works on and push back the
+// changes it made while the clinic was offline. Pulls return the records changed since a cursor with
+// their sync version, which counts their updates, and tombstones of those deleted. Pushed updates
+// and deletions of records changed on the server since the client pulled them are not saved but
+// reported as conflicts with the record as it is now, for the client to reconcile.
+type SyncService struct {
+	repository *repositories.ChangeRepository
+	writers    map[string]syncWriter
+}
+
+func NewSyncService(repository *repositories.ChangeRepository, patients *PatientService, appointments *AppointmentService, examinations *ExaminationService,
+	treatmentPlans *TreatmentPlanService, emergencyContacts *EmergencyContactService) *SyncService {
+	return &SyncService{
+		repository: repository,
+		// The records the front desk and surgeries work on offline; doctors, insurers and bills are
+		// only pulled
+		writers: map[string]syncWriter{
+			"patients": {
+				create: func(ctx context.Context, data json.RawMessage, _ models.AuditLog) (string, error) {
+					var patient models.Patient
+					if err := decodeSyncRecord(data, &patient); err != nil {
+						return "", err
+					}
+					patient.ID = ""
+					err := patients.Create(ctx, &patient)
+					return patient.ID, err
+				},
+				update: func(ctx context.Context, id string, data, _ json.RawMessage, _ models.AuditLog) error {
+					var patient models.Patient
+					if err := decodeSyncRecord(data, &patient); err != nil {
+						return err
+					}
+					patient.ID = id
+					return patients.Update(ctx, &patient)
+				},
+				delete: func(ctx context.Context, id string, _ json.RawMessage) error {
+					return patients.Delete(ctx, id)
+				},
+			},
+			"appointments": {
+				create: func(ctx context.Context, data json.RawMessage, audit models.AuditLog) (string, error) {
+					var appointment models.Appointment
+					if err := decodeSyncRecord(data, &appointment); err != nil {
+						return "", err
+					}
+					appointment.ID = 0
+					err := appointments.Create(ctx, &appointment, audit)
+					return strconv.FormatUint(uint64(appointment.ID), 10), err
+				},
+				update: func(ctx context.Context, id string, data, current json.RawMessage, audit models.AuditLog) error {
+					var appointment models.Appointment
+					if err := decodeSyncRecord(data, &appointment); err != nil {
+						return err
+					}
+					appointment.ID, appointment.PatientID = syncRecordID(id), syncPatientID(current)
+					return appointments.Update(ctx, &appointment, audit)
+				},
+				delete: func(ctx context.Context, id string, current json.RawMessage) error {
+					return appointments.Delete(ctx, syncPatientID(current), syncRecordID(id))
+				},
+			},
+			"examinations": {
+				create: func(ctx context.Context, data json.RawMessage, _ models.AuditLog) (string, error) {
+					var examination models.Examination
+					if err := decodeSyncRecord(data, &examination); err != nil {
+						return "", err
+					}
+					examination.ID, examination.Attachments = 0, nil
+					err := examinations.Create(ctx, &examination)
+					return strconv.FormatUint(uint64(examination.ID), 10), err
+				},
+				update: func(ctx context.Context, id string, data, current json.RawMessage, _ models.AuditLog) error {
+					var examination models.Examination
+					if err := decodeSyncRecord(data, &examination); err != nil {
+						return err
+					}
+					examination.ID, examination.PatientID, examination.Attachments = syncRecordID(id), syncPatientID(current), nil
+					return examinations.Update(ctx, &examination)
+				},
+				delete: func(ctx context.Context, id string, _ json.RawMessage) error {
+					return examinations.Delete(ctx, syncRecordID(id))
+				},
+			},
+			"treatment_plans": {
+				create: func(ctx context.Context, data json.RawMessage, _ models.AuditLog) (string, error) {
+					var plan models.TreatmentPlan
+					if err := decodeSyncRecord(data, &plan); err != nil {
+						return "", err
+					}
+					plan.ID = 0
+					err := treatmentPlans.Create(ctx, &plan)
+					return strconv.FormatUint(uint64(plan.ID), 10), err
+				},
+				update: func(ctx context.Context, id string, data, current json.RawMessage, _ models.AuditLog) error {
+					var plan models.TreatmentPlan
+					if err := decodeSyncRecord(data, &plan); err != nil {
+						return err
+					}
+					plan.ID, plan.PatientID = syncRecordID(id), syncPatientID(current)
+					return treatmentPlans.Update(ctx, &plan)
+				},
+				delete: func(ctx context.Context, id string, current json.RawMessage) error {
+					return treatmentPlans.Delete(ctx, syncPatientID(current), syncRecordID(id))
+				},
+			},
+			"emergency_contacts": {
+				create: func(ctx context.Context, data json.RawMessage, _ models.AuditLog) (string, error) {
+					var contact models.EmergencyContact
+					if err := decodeSyncRecord(data, &contact); err != nil {
+						return "", err
+					}
+					contact.ID = 0
+					err := emergencyContacts.Create(ctx, &contact)
+					return strconv.FormatUint(uint64(contact.ID), 10), err
+				},
+				update: func(ctx context.Context, id string, data, current json.RawMessage, _ models.AuditLog) error {
+					var contact models.EmergencyContact
+					if err := decodeSyncRecord(data, &contact); err != nil {
+						return err
+					}
+					contact.ID, contact.PatientID = syncRecordID(id), syncPatientID(current)
+					return emergencyContacts.Update(ctx, &contact)
+				},
+				delete: func(ctx context.Context, id string, current json.RawMessage) error {
+					return emergencyContacts.Delete(ctx, syncPatientID(current), syncRecordID(id))
+				},
+			},
+		},
+	}
+}
+
+// Pull returns the changes after since of the entities asked for, or of every entity, up to limit
+// changes of each. NextSince is where the entity that has the most left to pull stopped, so changes
+// of others may come again.
+func (s *SyncService) Pull(ctx context.Context, since time.Time, entities []string, limit int) (*models.SyncPage, error) {
+	if limit <= 0 || limit > maxChangesPageSize {
+		limit = maxChangesPageSize
+	}
+	if len(entities) == 0 {
+		for entity := range database.ChangeSources {
+			entities = append(entities, entity)
+		}
+		sort.Strings(entities)
+	}
+
+	page := &models.SyncPage{Entities: make(map[string]models.SyncEntityPage, len(entities)), NextSince: since}
+	var caughtUp time.Time
+	for _, entity := range entities {
+		if _, ok := database.ChangeSources[entity]; !ok {
+			return nil, fmt.Errorf("%w: unknown entity %q", ErrInvalidSyncRequest, entity)
+		}
+		changes, err := s.repository.Records(ctx, entity, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		entityPage := models.SyncEntityPage{}
+		entityPage.Changes, entityPage.HasMore = holdBackLastTimestamp(changes, limit, func(change models.SyncChange) time.Time {
+			return change.ChangedAt
+		})
+		if entityPage.Changes == nil {
+			entityPage.Changes = []models.SyncChange{}
+		}
+		page.Entities[entity] = entityPage
+
+		if len(entityPage.Changes) == 0 {
+			continue
+		}
+		last := entityPage.Changes[len(entityPage.Changes)-1].ChangedAt
+		switch {
+		case entityPage.HasMore && (!page.HasMore || last.Before(page.NextSince)):
+			page.HasMore, page.NextSince = true, last
+		case !entityPage.HasMore && last.After(caughtUp):
+			caughtUp = last
+		}
+	}
+	if !page.HasMore && caughtUp.After(since) {
+		page.NextSince = caughtUp
+	}
+	return page, nil
+}
+
+// Push saves the changes a client made offline, in order, and reports how each went. A change that
+// fails does not stop the others; the client pushes it again once it is reconciled.
+func (s *SyncService) Push(ctx context.Context, changes []models.SyncPushChange, audit models.AuditLog) ([]models.SyncPushResult, error) {
+	if len(changes) > maxSyncPushChanges {
+		return nil, fmt.Errorf("%w: at most %d changes are pushed at once", ErrInvalidSyncRequest, maxSyncPushChanges)
+	}
+	results := make([]models.SyncPushResult, 0, len(changes))
+	for _, change := range changes {
+		results = append(results, s.push(ctx, change, audit))
+	}
+	return results, nil
+}
+
+func (s *SyncService) push(ctx context.Context, change models.SyncPushChange, audit models.AuditLog) models.SyncPushResult {
+	result := models.SyncPushResult{Entity: change.Entity, ID: change.ID, ClientID: change.ClientID}
+	reject := func(err error) models.SyncPushResult {
+		result.Status, result.Error = models.SyncRejected, err.Error()
+		return result
+	}
+	writer, ok := s.writers[change.Entity]
+	if !ok {
+		return reject(fmt.Errorf("%s cannot be pushed", change.Entity))
+	}
+
+	if change.Action == models.ChangeCreated {
+		id, err := writer.create(ctx, change.Data, audit)
+		if err != nil {
+			return reject(err)
+		}
+		result.ID = id
+		return s.applied(ctx, result)
+	}
+	if change.Action != models.ChangeUpdated && change.Action != models.ChangeDeleted {
+		return reject(fmt.Errorf("unknown action %q", change.Action))
+	}
+	if change.ID == "" {
+		return reject(errors.New("the ID of the record is required"))
+	}
+
+	// The record is compared with the version the client changed just before it is saved
+	current, err := s.repository.Record(ctx, change.Entity, change.ID)
+	if err != nil {
+		return reject(err)
+	}
+	switch {
+	case current == nil && change.Action == models.ChangeDeleted:
+		result.Status = models.SyncApplied
+		return result
+	case current == nil:
+		result.Status = models.SyncConflict
+		return result
+	case current.Version != change.Version:
+		result.Status, result.Version, result.Current = models.SyncConflict, current.Version, current.Data
+		return result
+	}
+
+	if change.Action == models.ChangeDeleted {
+		if err := writer.delete(ctx, change.ID, current.Data); err != nil {
+			return reject(err)
+		}
+		result.Status = models.SyncApplied
+		return result
+	}
+	if err := writer.update(ctx, change.ID, change.Data, current.Data, audit); err != nil {
+		return reject(err)
+	}
+	return s.applied(ctx, result)
+}
+
+// applied reports a record created or updated with its version after the change
+func (s *SyncService) applied(ctx context.Context, result models.SyncPushResult) models.SyncPushResult {
+	result.Status = models.SyncApplied
+	record, err := s.repository.Record(ctx, result.Entity, result.ID)
+	if err == nil && record != nil {
+		result.Version = record.Version
+	}
+	return result
+}
+
+// decodeSyncRecord reads a pushed record as the API takes it
+func decodeSyncRecord(data json.RawMessage, record interface{}) error {
+	if len(data) == 0 {
+		return errors.New("the data of the record is required")
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	return nil
+}
+
+// syncRecordID reads the ID of a record with a numeric ID, which the record was looked up by
+func syncRecordID(id string) uint {
+	number, _ := strconv.ParseUint(id, 10, 32)
+	return uint(number)
+}
+
+// syncPatientID returns the patient a record kept per patient belongs to
+func syncPatientID(record json.RawMessage) string {
+	var owner struct {
+		PatientID string `json:"patient_id"`
+	}
+	json.Unmarshal(record, &owner)
+	return owner.PatientID
+}
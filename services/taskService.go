@@ -0,0 +1,175 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// taskNotificationTimeout bounds looking up the assignee and sending the assignment email.
+const taskNotificationTimeout = time.Minute
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrNoteNotFound = errors.New("note not found")
+)
+
+type TaskService struct {
+	repository *repositories.TaskRepository
+	notifier   notifications.Notifier
+}
+
+func NewTaskService(repository *repositories.TaskRepository, notifier notifications.Notifier) *TaskService {
+	return &TaskService{repository: repository, notifier: notifier}
+}
+
+func (s *TaskService) CreateTask(ctx context.Context, task *models.Task) error {
+	if err := validateTask(task); err != nil {
+		return err
+	}
+	if err := s.repository.Create(ctx, task); err != nil {
+		return err
+	}
+	s.notifyAssignee(*task)
+	return nil
+}
+
+func (s *TaskService) GetTask(ctx context.Context, id uint) (*models.Task, error) {
+	task, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	return task, nil
+}
+
+func (s *TaskService) ListTasks(ctx context.Context, filter models.TaskFilter) ([]models.Task, error) {
+	if filter.Status != "" && !models.IsValidTaskStatus(filter.Status) {
+		return nil, fmt.Errorf("invalid task status %q", filter.Status)
+	}
+	tasks, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+	return tasks, nil
+}
+
+// UpdateTask saves task and notifies the new assignee when it was reassigned
+func (s *TaskService) UpdateTask(ctx context.Context, task *models.Task) error {
+	if err := validateTask(task); err != nil {
+		return err
+	}
+	existing, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	task.CreatedBy = existing.CreatedBy
+	task.CreatedAt = existing.CreatedAt
+	if err := s.repository.Update(ctx, task); err != nil {
+		return err
+	}
+	if task.AssigneeID != existing.AssigneeID {
+		s.notifyAssignee(*task)
+	}
+	return nil
+}
+
+func (s *TaskService) DeleteTask(ctx context.Context, id uint) error {
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *TaskService) AddNote(ctx context.Context, note *models.PatientNote) error {
+	note.Body = strings.TrimSpace(note.Body)
+	if note.Body == "" {
+		return errors.New("note body is required")
+	}
+	return s.repository.CreateNote(ctx, note)
+}
+
+func (s *TaskService) GetNotes(ctx context.Context, patientID string) ([]models.PatientNote, error) {
+	notes, err := s.repository.GetNotes(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []models.PatientNote{}
+	}
+	return notes, nil
+}
+
+// DeleteNote removes a note; staff may only delete their own notes
+func (s *TaskService) DeleteNote(ctx context.Context, patientID string, id uint, authorID int64) error {
+	deleted, err := s.repository.DeleteNote(ctx, patientID, id, authorID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+func validateTask(task *models.Task) error {
+	task.Title = strings.TrimSpace(task.Title)
+	if task.Title == "" {
+		return errors.New("task title is required")
+	}
+	if task.AssigneeID == 0 {
+		return errors.New("task assignee_id is required")
+	}
+	if task.Status == "" {
+		task.Status = models.TaskStatusOpen
+	}
+	if !models.IsValidTaskStatus(task.Status) {
+		return fmt.Errorf("invalid task status %q", task.Status)
+	}
+	if task.PatientID != nil && *task.PatientID == "" {
+		task.PatientID = nil
+	}
+	return nil
+}
+
+// notifyAssignee emails the assignee in the background so a slow mail server never delays the request
+func (s *TaskService) notifyAssignee(task models.Task) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), taskNotificationTimeout)
+		defer cancel()
+
+		email, err := s.repository.GetUserEmail(ctx, task.AssigneeID)
+		if err != nil || email == "" {
+			log.Printf("Failed to find assignee %d of task %d: %v", task.AssigneeID, task.ID, err)
+			return
+		}
+
+		body := fmt.Sprintf("You have been assigned task #%d: %s", task.ID, task.Title)
+		if task.PatientID != nil {
+			body += fmt.Sprintf("\nPatient: %s", *task.PatientID)
+		}
+		if task.DueDate != nil {
+			body += fmt.Sprintf("\nDue: %s", task.DueDate.Format("2006-01-02 15:04"))
+		}
+		if task.Description != "" {
+			body += "\n\n" + task.Description
+		}
+
+		notification := notifications.Notification{
+			Recipients: []string{email},
+			Subject:    "New task: " + task.Title,
+			Body:       body,
+		}
+		if err := s.notifier.Send(ctx, notification); err != nil {
+			log.Printf("Failed to notify assignee of task %d: %v", task.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInvalidTreatmentCost is returned for cost comparisons of no procedures or unreadable ones
+var ErrInvalidTreatmentCost = errors.New("invalid treatment cost comparison")
+
+// TreatmentCostService prices treatment plans under each way the patient can pay for them, so the
+// consultation can quote the patient's own share before the work is agreed.
+type TreatmentCostService struct {
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+	patientRepo       *repositories.PatientRepository
+	procedureRepo     *repositories.ProcedureRepository
+	contractRateRepo  *repositories.ContractRateRepository
+	billingRepo       *repositories.BillingRepository
+}
+
+func NewTreatmentCostService(treatmentPlanRepo *repositories.TreatmentPlanRepository, patientRepo *repositories.PatientRepository, procedureRepo *repositories.ProcedureRepository, contractRateRepo *repositories.ContractRateRepository, billingRepo *repositories.BillingRepository) *TreatmentCostService {
+	return &TreatmentCostService{
+		treatmentPlanRepo: treatmentPlanRepo,
+		patientRepo:       patientRepo,
+		procedureRepo:     procedureRepo,
+		contractRateRepo:  contractRateRepo,
+		billingRepo:       billingRepo,
+	}
+}
+
+// Compare prices the procedures of a treatment plan paying cash and under the patient's insurance.
+// Cover is counted per calendar year, less what the insurer already paid on this year's bills.
+func (s *TreatmentCostService) Compare(ctx context.Context, patientID string, planID uint, items []models.TreatmentCostItem) (*models.TreatmentCostComparison, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no procedures given", ErrInvalidTreatmentCost)
+	}
+	plan, err := s.treatmentPlanRepo.GetByID(ctx, patientID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrTreatmentPlanNotFound
+	}
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrTreatmentPlanNotFound
+	}
+
+	now := models.ClinicNow()
+	cash := models.TreatmentCostBreakdown{Option: models.FinancingCash, Lines: []models.TreatmentCostLine{}}
+	procedures := make([]*models.Procedure, len(items))
+	for i, item := range items {
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidTreatmentCost)
+		}
+		if item.Price != nil && *item.Price < 0 {
+			return nil, fmt.Errorf("%w: price must not be negative", ErrInvalidTreatmentCost)
+		}
+		procedure, err := s.procedureRepo.GetByID(ctx, item.ProcedureID)
+		if err != nil {
+			return nil, err
+		}
+		if procedure == nil {
+			return nil, fmt.Errorf("%w: procedure %d not found", ErrInvalidTreatmentCost, item.ProcedureID)
+		}
+		procedures[i] = procedure
+
+		price := procedure.Price
+		if item.Price != nil {
+			price = *item.Price
+		}
+		amount := roundCents(price * float64(item.Quantity))
+		cash.Lines = append(cash.Lines, models.TreatmentCostLine{
+			ProcedureID: procedure.ID,
+			Procedure:   procedure.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   price,
+			Amount:      amount,
+			PatientPays: amount,
+		})
+		cash.Total += amount
+	}
+	cash.PatientPays = cash.Total
+
+	comparison := &models.TreatmentCostComparison{
+		PatientID:       patientID,
+		TreatmentPlanID: plan.ID,
+		CoverYear:       now.Year(),
+		Options:         []models.TreatmentCostBreakdown{cash},
+	}
+
+	insurer, err := s.contractRateRepo.InsurerForPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if insurer == nil {
+		return comparison, nil
+	}
+	yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	used, err := s.billingRepo.InsurancePaid(ctx, patientID, yearStart, yearStart.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	insurance := models.TreatmentCostBreakdown{
+		Option:               models.FinancingInsurance,
+		InsuranceCompanyID:   insurer.ID,
+		InsuranceCompanyName: insurer.Name,
+		Scheme:               patient.Scheme,
+		CoverLimit:           patient.CoverLimit,
+		CoverUsed:            used,
+		RemainingCover:       math.Max(patient.CoverLimit-used, 0),
+		Lines:                []models.TreatmentCostLine{},
+	}
+	remaining := insurance.RemainingCover
+	for i, procedure := range procedures {
+		line := cash.Lines[i]
+		line.PatientPays = 0
+		rate, err := s.contractRateRepo.RateForPatient(ctx, patientID, procedure.ID, now)
+		if err != nil {
+			return nil, err
+		}
+		if rate != nil {
+			line.ContractRateID = &rate.ID
+			line.UnitPrice = rate.Amount
+			line.Amount = roundCents(rate.Amount * float64(line.Quantity))
+			line.InsurerPays = math.Min(line.Amount, remaining)
+			remaining -= line.InsurerPays
+		}
+		line.PatientPays = roundCents(line.Amount - line.InsurerPays)
+		insurance.Lines = append(insurance.Lines, line)
+		insurance.Total += line.Amount
+		insurance.InsurerPays += line.InsurerPays
+		insurance.PatientPays += line.PatientPays
+	}
+	comparison.Options = append(comparison.Options, insurance)
+	return comparison, nil
+}
+
+// roundCents rounds an amount of money to the cent
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
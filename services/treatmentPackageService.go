@@ -0,0 +1,212 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTreatmentPackageNotFound = errors.New("treatment package not found")
+	ErrInvalidTreatmentPackage  = errors.New("invalid treatment package")
+)
+
+// TreatmentPackageService keeps the clinic's fixed-price treatment packages and expands them into a
+// patient's treatment plan and payment plan in one step
+type TreatmentPackageService struct {
+	repository        *repositories.TreatmentPackageRepository
+	patientRepository *repositories.PatientRepository
+	procedureRepo     *repositories.ProcedureRepository
+	treatmentPlans    *TreatmentPlanService
+	paymentPlans      *PaymentPlanService
+}
+
+func NewTreatmentPackageService(repository *repositories.TreatmentPackageRepository, patientRepository *repositories.PatientRepository, procedureRepo *repositories.ProcedureRepository, treatmentPlans *TreatmentPlanService, paymentPlans *PaymentPlanService) *TreatmentPackageService {
+	return &TreatmentPackageService{
+		repository:        repository,
+		patientRepository: patientRepository,
+		procedureRepo:     procedureRepo,
+		treatmentPlans:    treatmentPlans,
+		paymentPlans:      paymentPlans,
+	}
+}
+
+func (s *TreatmentPackageService) Create(ctx context.Context, pkg *models.TreatmentPackage) error {
+	if err := s.validate(ctx, pkg); err != nil {
+		return err
+	}
+	pkg.ID = 0
+	return s.repository.Create(ctx, pkg)
+}
+
+func (s *TreatmentPackageService) Get(ctx context.Context, id uint) (*models.TreatmentPackage, error) {
+	pkg, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		return nil, ErrTreatmentPackageNotFound
+	}
+	return pkg, nil
+}
+
+func (s *TreatmentPackageService) List(ctx context.Context) ([]models.TreatmentPackage, error) {
+	packages, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if packages == nil {
+		packages = []models.TreatmentPackage{}
+	}
+	return packages, nil
+}
+
+// Update changes a package and replaces its items. Plans it was already applied to keep the price
+// they were given.
+func (s *TreatmentPackageService) Update(ctx context.Context, pkg *models.TreatmentPackage) (*models.TreatmentPackage, error) {
+	if _, err := s.Get(ctx, pkg.ID); err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, pkg); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Update(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, pkg.ID)
+}
+
+func (s *TreatmentPackageService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Apply writes the package's items into a new treatment plan for the patient, estimated at the
+// package price, and spreads the price over the package's installments from firstDueDate, or from
+// today when it is zero. A free package gets no payment plan. The treatment plan is removed again
+// when the payment plan cannot be created.
+func (s *TreatmentPackageService) Apply(ctx context.Context, id uint, patientID string, firstDueDate time.Time) (*models.TreatmentPackageApplication, error) {
+	pkg, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	patient, err := s.patientRepository.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	if firstDueDate.IsZero() {
+		firstDueDate, _ = models.ClinicDay(time.Now())
+	}
+	schedule := models.InstallmentSchedule{Count: pkg.InstallmentCount, FirstDueDate: firstDueDate, IntervalMonths: pkg.IntervalMonths}
+	if pkg.Price > 0 {
+		// Checked up front so a package that cannot be scheduled leaves no treatment plan behind
+		if _, err := scheduleInstallments(pkg.Price, schedule); err != nil {
+			return nil, err
+		}
+	}
+
+	price := pkg.Price
+	application := &models.TreatmentPackageApplication{
+		PackageID:     pkg.ID,
+		TreatmentPlan: models.TreatmentPlan{PatientID: patientID, Plan: treatmentPackagePlan(pkg), EstimatedCost: &price},
+	}
+	if err := s.treatmentPlans.Create(ctx, &application.TreatmentPlan); err != nil {
+		return nil, err
+	}
+	if pkg.Price == 0 {
+		return application, nil
+	}
+
+	treatmentPlanID := application.TreatmentPlan.ID
+	paymentPlan := &models.PaymentPlan{PatientID: patientID, TreatmentPlanID: &treatmentPlanID, Description: pkg.Name, TotalAmount: pkg.Price}
+	application.PaymentPlan, err = s.paymentPlans.Create(ctx, paymentPlan, schedule)
+	if err != nil {
+		if deleteErr := s.treatmentPlans.Delete(ctx, patientID, treatmentPlanID); deleteErr != nil {
+			log.Printf("Failed to remove treatment plan %d of unapplied package %d: %v", treatmentPlanID, pkg.ID, deleteErr)
+		}
+		return nil, err
+	}
+	return application, nil
+}
+
+// validate checks the package and its items, naming items after their procedure unless a
+// description is given
+func (s *TreatmentPackageService) validate(ctx context.Context, pkg *models.TreatmentPackage) error {
+	pkg.Name = strings.TrimSpace(pkg.Name)
+	pkg.Description = strings.TrimSpace(pkg.Description)
+	if pkg.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTreatmentPackage)
+	}
+	pkg.Price = math.Round(pkg.Price*100) / 100
+	if pkg.Price < 0 {
+		return fmt.Errorf("%w: the price must not be negative", ErrInvalidTreatmentPackage)
+	}
+	if pkg.InstallmentCount == 0 {
+		pkg.InstallmentCount = 1
+	}
+	if pkg.IntervalMonths == 0 {
+		pkg.IntervalMonths = 1
+	}
+	if pkg.InstallmentCount < 0 || pkg.IntervalMonths < 0 {
+		return fmt.Errorf("%w: installment_count and interval_months must be positive", ErrInvalidTreatmentPackage)
+	}
+	if pkg.Price > 0 && int64(math.Round(pkg.Price*100)) < int64(pkg.InstallmentCount) {
+		return fmt.Errorf("%w: the price must cover every installment", ErrInvalidTreatmentPackage)
+	}
+	if len(pkg.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidTreatmentPackage)
+	}
+	for i := range pkg.Items {
+		item := &pkg.Items[i]
+		item.Description = strings.TrimSpace(item.Description)
+		if item.ProcedureID != nil {
+			procedure, err := s.procedureRepo.GetByID(ctx, *item.ProcedureID)
+			if err != nil {
+				return err
+			}
+			if procedure == nil {
+				return fmt.Errorf("%w: procedure %d not found", ErrInvalidTreatmentPackage, *item.ProcedureID)
+			}
+			if item.Description == "" {
+				item.Description = procedure.Name
+			}
+		}
+		if item.Description == "" {
+			return fmt.Errorf("%w: every item needs a procedure_id or a description", ErrInvalidTreatmentPackage)
+		}
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("%w: item quantities must be positive", ErrInvalidTreatmentPackage)
+		}
+	}
+	return nil
+}
+
+// treatmentPackagePlan writes a package out as treatment plan text, one line per item
+func treatmentPackagePlan(pkg *models.TreatmentPackage) string {
+	lines := []string{pkg.Name}
+	if pkg.Description != "" {
+		lines = append(lines, pkg.Description)
+	}
+	for _, item := range pkg.Items {
+		if item.Quantity > 1 {
+			lines = append(lines, fmt.Sprintf("- %d x %s", item.Quantity, item.Description))
+		} else {
+			lines = append(lines, "- "+item.Description)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"RoyDental/clock"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrTreatmentPlanItemNotFound = errors.New("treatment plan item not found")
+	ErrInvalidTreatmentPlanItem  = errors.New("invalid treatment plan item")
+)
+
+// Unscheduled treatment listings are limited to
+const (
+	defaultUnscheduledLimit = 100
+	maxUnscheduledLimit     = 500
+)
+
+// TreatmentPlanItemService keeps the pieces of work of treatment plans and what patients decided
+// about them, and finds the accepted work no appointment is booked for so the desk can call those
+// patients before the revenue is lost
+type TreatmentPlanItemService struct {
+	repository        *repositories.TreatmentPlanItemRepository
+	treatmentPlanRepo *repositories.TreatmentPlanRepository
+	procedureRepo     *repositories.ProcedureRepository
+	tasks             *TaskService
+	clock             clock.Clock
+}
+
+func NewTreatmentPlanItemService(repository *repositories.TreatmentPlanItemRepository, treatmentPlanRepo *repositories.TreatmentPlanRepository, procedureRepo *repositories.ProcedureRepository, tasks *TaskService, clock clock.Clock) *TreatmentPlanItemService {
+	return &TreatmentPlanItemService{repository: repository, treatmentPlanRepo: treatmentPlanRepo, procedureRepo: procedureRepo, tasks: tasks, clock: clock}
+}
+
+// Create adds an item to the patient's treatment plan, priced from the catalog when no amount is given
+func (s *TreatmentPlanItemService) Create(ctx context.Context, patientID string, treatmentPlanID uint, item *models.TreatmentPlanItem) error {
+	if err := s.checkTreatmentPlan(ctx, patientID, treatmentPlanID); err != nil {
+		return err
+	}
+	item.ID, item.PatientID, item.TreatmentPlanID = 0, patientID, treatmentPlanID
+	if item.Status == "" {
+		item.Status = models.TreatmentItemProposed
+	}
+	item.AcceptedAt, item.CompletedAt = nil, nil
+	if err := s.validate(ctx, item); err != nil {
+		return err
+	}
+	s.stampStatus(item, "")
+	return s.repository.Create(ctx, item)
+}
+
+func (s *TreatmentPlanItemService) Get(ctx context.Context, patientID string, treatmentPlanID, id uint) (*models.TreatmentPlanItem, error) {
+	item, err := s.repository.GetByID(ctx, patientID, treatmentPlanID, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrTreatmentPlanItemNotFound
+	}
+	return item, nil
+}
+
+// List returns the items of the patient's treatment plan
+func (s *TreatmentPlanItemService) List(ctx context.Context, patientID string, treatmentPlanID uint) ([]models.TreatmentPlanItem, error) {
+	if err := s.checkTreatmentPlan(ctx, patientID, treatmentPlanID); err != nil {
+		return nil, err
+	}
+	items, err := s.repository.List(ctx, patientID, treatmentPlanID)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []models.TreatmentPlanItem{}
+	}
+	return items, nil
+}
+
+// Update changes an item and what the patient decided about it. When it was accepted or completed
+// is recorded as its status changes.
+func (s *TreatmentPlanItemService) Update(ctx context.Context, patientID string, treatmentPlanID, id uint, update models.TreatmentPlanItem) (*models.TreatmentPlanItem, error) {
+	item, err := s.Get(ctx, patientID, treatmentPlanID, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := item.Status
+	item.ProcedureID, item.Description, item.Tooth = update.ProcedureID, update.Description, update.Tooth
+	item.Quantity, item.Amount, item.Status = update.Quantity, update.Amount, update.Status
+	if item.Status == "" {
+		item.Status = previous
+	}
+	if err := s.validate(ctx, item); err != nil {
+		return nil, err
+	}
+	s.stampStatus(item, previous)
+	if err := s.repository.Update(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *TreatmentPlanItemService) Delete(ctx context.Context, patientID string, treatmentPlanID, id uint) error {
+	if _, err := s.Get(ctx, patientID, treatmentPlanID, id); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Unscheduled reports the patients with treatment accepted at least filter.MinDays ago and no
+// future appointment, the most valuable accepted work first, with the value at stake
+func (s *TreatmentPlanItemService) Unscheduled(ctx context.Context, filter models.UnscheduledTreatmentFilter) (*models.UnscheduledTreatmentReport, error) {
+	if filter.MinDays < 0 {
+		return nil, fmt.Errorf("%w: min_days must not be negative", ErrInvalidTreatmentPlanItem)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultUnscheduledLimit
+	}
+	limit = min(limit, maxUnscheduledLimit)
+
+	now := s.clock.Now()
+	acceptedBefore := now.AddDate(0, 0, -filter.MinDays)
+	totals, err := s.repository.UnscheduledTotals(ctx, now, acceptedBefore)
+	if err != nil {
+		return nil, err
+	}
+	patients, err := s.repository.Unscheduled(ctx, now, acceptedBefore, nil, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.UnscheduledTreatmentReport{Patients: totals.Patients, Items: totals.Items, TotalValue: totals.TotalValue,
+		Entries: []models.UnscheduledPatient{}}
+	if len(patients) > limit {
+		patients, report.Truncated = patients[:limit], true
+	}
+	if len(patients) == 0 {
+		return report, nil
+	}
+
+	patientIDs := make([]string, len(patients))
+	for i, patient := range patients {
+		patientIDs[i] = patient.PatientID
+	}
+	items, err := s.repository.UnscheduledItems(ctx, now, acceptedBefore, patientIDs)
+	if err != nil {
+		return nil, err
+	}
+	byPatient := map[string][]models.UnscheduledItem{}
+	for _, item := range items {
+		byPatient[item.PatientID] = append(byPatient[item.PatientID], item)
+	}
+	for _, patient := range patients {
+		patient.Items = byPatient[patient.PatientID]
+		report.Entries = append(report.Entries, patient)
+	}
+	return report, nil
+}
+
+// CreateCallTasks gives the desk a task to call each of the patients to book their accepted
+// treatment. Patients who already have an open call task, or no longer have unscheduled treatment,
+// are skipped.
+func (s *TreatmentPlanItemService) CreateCallTasks(ctx context.Context, request models.UnscheduledTaskRequest) (*models.UnscheduledTaskResult, error) {
+	if len(request.PatientIDs) == 0 {
+		return nil, fmt.Errorf("%w: patient_ids must name at least one patient", ErrInvalidTreatmentPlanItem)
+	}
+	if len(request.PatientIDs) > maxUnscheduledLimit {
+		return nil, fmt.Errorf("%w: at most %d patients can be called at once", ErrInvalidTreatmentPlanItem, maxUnscheduledLimit)
+	}
+	createdBy := request.AssigneeID
+	if userID, ok := models.ActorFrom(ctx); ok {
+		createdBy = userID
+	}
+
+	now := s.clock.Now()
+	patients, err := s.repository.Unscheduled(ctx, now, now, request.PatientIDs, len(request.PatientIDs))
+	if err != nil {
+		return nil, err
+	}
+	unscheduled := map[string]models.UnscheduledPatient{}
+	for _, patient := range patients {
+		unscheduled[patient.PatientID] = patient
+	}
+	items, err := s.repository.UnscheduledItems(ctx, now, now, request.PatientIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.UnscheduledTaskResult{Created: []models.Task{}, Skipped: []string{}}
+	for _, patientID := range slices.Compact(slices.Sorted(slices.Values(request.PatientIDs))) {
+		patient, ok := unscheduled[patientID]
+		if !ok || patient.OpenTaskID != nil {
+			result.Skipped = append(result.Skipped, patientID)
+			continue
+		}
+		var description strings.Builder
+		fmt.Fprintf(&description, "%s accepted treatment worth %.2f that is not booked yet. Call %s to book:\n", patient.PatientName, patient.Value, patient.Phone)
+		for _, item := range items {
+			if item.PatientID == patientID {
+				fmt.Fprintf(&description, "- %s (accepted %s)\n", item.Description, item.AcceptedAt.In(models.ClinicLocation()).Format("2 January 2006"))
+			}
+		}
+		task := models.Task{
+			Title:       models.UnscheduledTaskTitle,
+			Description: description.String(),
+			AssigneeID:  request.AssigneeID,
+			CreatedBy:   createdBy,
+			PatientID:   &patient.PatientID,
+			DueDate:     request.DueDate,
+		}
+		if err := s.tasks.CreateTask(ctx, &task); err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, task)
+	}
+	return result, nil
+}
+
+func (s *TreatmentPlanItemService) checkTreatmentPlan(ctx context.Context, patientID string, treatmentPlanID uint) error {
+	plan, err := s.treatmentPlanRepo.GetByID(ctx, patientID, treatmentPlanID)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		return ErrTreatmentPlanNotFound
+	}
+	return nil
+}
+
+// validate checks the item names its work and status, and prices it from the catalog procedure it
+// is of when no amount is given
+func (s *TreatmentPlanItemService) validate(ctx context.Context, item *models.TreatmentPlanItem) error {
+	item.Description, item.Tooth = strings.TrimSpace(item.Description), strings.TrimSpace(item.Tooth)
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+	switch {
+	case item.Quantity < 0:
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidTreatmentPlanItem)
+	case item.Amount < 0:
+		return fmt.Errorf("%w: amount must not be negative", ErrInvalidTreatmentPlanItem)
+	case !models.IsValidTreatmentItemStatus(item.Status):
+		return fmt.Errorf("%w: status must be %s, %s, %s or %s", ErrInvalidTreatmentPlanItem,
+			models.TreatmentItemProposed, models.TreatmentItemAccepted, models.TreatmentItemCompleted, models.TreatmentItemDeclined)
+	}
+	if item.ProcedureID != nil {
+		procedure, err := s.procedureRepo.GetByID(ctx, *item.ProcedureID)
+		if err != nil {
+			return err
+		}
+		if procedure == nil {
+			return fmt.Errorf("%w: procedure %d does not exist", ErrInvalidTreatmentPlanItem, *item.ProcedureID)
+		}
+		if item.Description == "" {
+			item.Description = procedure.Name
+		}
+		if item.Amount == 0 {
+			item.Amount = procedure.Price * float64(item.Quantity)
+		}
+	}
+	if item.Description == "" {
+		return fmt.Errorf("%w: description or procedure_id is required", ErrInvalidTreatmentPlanItem)
+	}
+	return nil
+}
+
+// stampStatus records when the item was accepted and completed as its status changes from previous
+func (s *TreatmentPlanItemService) stampStatus(item *models.TreatmentPlanItem, previous string) {
+	if item.Status == previous {
+		return
+	}
+	now := s.clock.Now()
+	switch item.Status {
+	case models.TreatmentItemAccepted:
+		item.AcceptedAt, item.CompletedAt = &now, nil
+	case models.TreatmentItemCompleted:
+		if item.AcceptedAt == nil {
+			item.AcceptedAt = &now
+		}
+		item.CompletedAt = &now
+	default:
+		item.AcceptedAt, item.CompletedAt = nil, nil
+	}
+}
@@ -4,17 +4,34 @@ import (
 	"RoyDental/models"
 	"RoyDental/repositories"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrTreatmentPlanVersionNotFound = errors.New("treatment plan version not found")
+	ErrInvalidTreatmentPlanDiff     = errors.New("invalid treatment plan comparison")
 )
 
 type TreatmentPlanService struct {
 	repository *repositories.TreatmentPlanRepository
+	templates  *ClinicalTemplateService
 }
 
-func NewTreatmentPlanService(repository *repositories.TreatmentPlanRepository) *TreatmentPlanService {
-	return &TreatmentPlanService{repository: repository}
+func NewTreatmentPlanService(repository *repositories.TreatmentPlanRepository, templates *ClinicalTemplateService) *TreatmentPlanService {
+	return &TreatmentPlanService{repository: repository, templates: templates}
 }
 
+// Create saves a treatment plan, starting it with the boilerplate of a template when one is given
 func (s *TreatmentPlanService) Create(ctx context.Context, plan *models.TreatmentPlan) error {
+	if plan.TemplateID != nil {
+		text, err := s.templates.Insert(ctx, *plan.TemplateID, models.TemplateTreatmentPlan, plan.Plan)
+		if err != nil {
+			return err
+		}
+		plan.Plan = text
+	}
 	return s.repository.Create(ctx, plan)
 }
 
@@ -33,3 +50,95 @@ func (s *TreatmentPlanService) Update(ctx context.Context, plan *models.Treatmen
 func (s *TreatmentPlanService) Delete(ctx context.Context, patientID string, id uint) error {
 	return s.repository.Delete(ctx, patientID, id)
 }
+
+// GetVersions returns every version of a treatment plan, oldest first
+func (s *TreatmentPlanService) GetVersions(ctx context.Context, patientID string, id uint) ([]models.TreatmentPlanVersion, error) {
+	versions, err := s.repository.GetVersions(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrTreatmentPlanNotFound
+	}
+	return versions, nil
+}
+
+// Diff compares version from of a treatment plan with version to. Without to the current version
+// is compared, and without from the version before to.
+func (s *TreatmentPlanService) Diff(ctx context.Context, patientID string, id uint, from, to int) (*models.TreatmentPlanDiff, error) {
+	versions, err := s.GetVersions(ctx, patientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = versions[len(versions)-1].Version
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+	if from > to {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidTreatmentPlanDiff)
+	}
+
+	diff := &models.TreatmentPlanDiff{TreatmentPlanID: id, Revisions: []models.PlanRevision{}}
+	var foundFrom, foundTo bool
+	for _, version := range versions {
+		switch {
+		case version.Version == from:
+			diff.From, foundFrom = version, true
+		case version.Version > from && version.Version <= to:
+			diff.Revisions = append(diff.Revisions, models.PlanRevision{Version: version.Version, Reason: version.Reason})
+		}
+		if version.Version == to {
+			diff.To, foundTo = version, true
+		}
+	}
+	if !foundFrom || !foundTo {
+		return nil, ErrTreatmentPlanVersionNotFound
+	}
+
+	diff.Lines = diffPlanLines(diff.From.Plan, diff.To.Plan)
+	if diff.From.EstimatedCost != nil && diff.To.EstimatedCost != nil {
+		change := *diff.To.EstimatedCost - *diff.From.EstimatedCost
+		diff.CostChange = &change
+	}
+	return diff, nil
+}
+
+// diffPlanLines compares two plans line by line, keeping the longest run of lines they share
+func diffPlanLines(from, to string) []models.PlanDiffLine {
+	a, b := strings.Split(from, "\n"), strings.Split(to, "\n")
+
+	// common[i][j] is the number of lines a[i:] and b[j:] have in common
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := []models.PlanDiffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, models.PlanDiffLine{Op: models.PlanLineUnchanged, Text: a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, models.PlanDiffLine{Op: models.PlanLineRemoved, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, models.PlanDiffLine{Op: models.PlanLineAdded, Text: b[j]})
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// userActivityDefaultDays is the period reported when none is given, a quarter
+	userActivityDefaultDays = 90
+	// userActivityMaxDays caps the period of a report
+	userActivityMaxDays = 366
+	// userActivityRecentActions is how many of the latest actions a report lists
+	userActivityRecentActions = 100
+)
+
+var ErrInvalidActivityPeriod = errors.New("invalid activity period")
+
+// loginEvents are the audit events of signing in and out
+var loginEvents = []string{models.AuditEventLoginSucceeded, models.AuditEventLoginFailed, models.AuditEventLogout}
+
+// UserActivityService reports what a user did over a period for the access reviews auditors
+// require, from the audit trail and the API usage counts
+type UserActivityService struct {
+	userRepo     repositories.UserRepository
+	auditRepo    *repositories.AuditRepository
+	apiUsageRepo *repositories.APIUsageRepository
+}
+
+func NewUserActivityService(userRepo repositories.UserRepository, auditRepo *repositories.AuditRepository, apiUsageRepo *repositories.APIUsageRepository) *UserActivityService {
+	return &UserActivityService{userRepo: userRepo, auditRepo: auditRepo, apiUsageRepo: apiUsageRepo}
+}
+
+// Report returns the user's activity over the days from through to (YYYY-MM-DD), both included.
+// The period defaults to the last 90 days.
+func (s *UserActivityService) Report(ctx context.Context, userID int64, from, to string) (*models.UserActivityReport, error) {
+	start, end, err := activityPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	report := &models.UserActivityReport{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role.Name,
+		From:     start.Format("2006-01-02"),
+		To:       end.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+	subject := strconv.FormatInt(user.ID, 10)
+
+	logins, total, err := s.auditRepo.List(ctx, models.AuditFilter{UserID: subject, Events: loginEvents, From: &start, To: &end})
+	if err != nil {
+		return nil, err
+	}
+	report.LoginHistory, report.LoginTruncated = logins, total > int64(len(logins))
+	if report.LoginHistory == nil {
+		report.LoginHistory = []models.AuditLog{}
+	}
+	loginCounts, err := s.auditRepo.CountByEvent(ctx, models.AuditFilter{UserID: subject, Events: loginEvents, From: &start, To: &end})
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range loginCounts {
+		switch count.Event {
+		case models.AuditEventLoginSucceeded:
+			report.Logins = count.Count
+		case models.AuditEventLoginFailed:
+			report.FailedLogins = count.Count
+		}
+	}
+	for _, entry := range logins {
+		if entry.Event == models.AuditEventLoginSucceeded {
+			lastLogin := entry.CreatedAt
+			report.LastLoginAt = &lastLogin
+			break
+		}
+	}
+
+	actions := models.AuditFilter{UserID: subject, ExcludeEvents: loginEvents, From: &start, To: &end, Limit: userActivityRecentActions}
+	if report.Actions, err = s.auditRepo.CountByEvent(ctx, actions); err != nil {
+		return nil, err
+	}
+	if report.RecentActions, _, err = s.auditRepo.List(ctx, actions); err != nil {
+		return nil, err
+	}
+	if report.Actions == nil {
+		report.Actions = []models.AuditEventCount{}
+	}
+	if report.RecentActions == nil {
+		report.RecentActions = []models.AuditLog{}
+	}
+
+	if report.APIUsage, err = s.apiUsageRepo.ForUser(ctx, user.ID, report.From, report.To); err != nil {
+		return nil, err
+	}
+	if report.APIUsage == nil {
+		report.APIUsage = []models.APIUsageCount{}
+	}
+	for _, usage := range report.APIUsage {
+		report.TotalRequests += usage.Requests
+	}
+	return report, nil
+}
+
+// activityPeriod returns the start of the from day and the end of the to day in clinic time
+func activityPeriod(from, to string) (time.Time, time.Time, error) {
+	today, _ := models.ClinicDay(time.Now())
+	end := today
+	if to != "" {
+		day, err := models.ParseClinicDate(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be given as YYYY-MM-DD", ErrInvalidActivityPeriod)
+		}
+		end = day
+	}
+	start := end.AddDate(0, 0, -userActivityDefaultDays+1)
+	if from != "" {
+		day, err := models.ParseClinicDate(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be given as YYYY-MM-DD", ErrInvalidActivityPeriod)
+		}
+		start = day
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must not be after to", ErrInvalidActivityPeriod)
+	}
+	if end.After(start.AddDate(0, 0, userActivityMaxDays-1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the period may not be longer than %d days", ErrInvalidActivityPeriod, userActivityMaxDays)
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// verificationQueueSize bounds the patients waiting to be checked.
+const verificationQueueSize = 256
+
+var (
+	ErrVerificationNotFound = errors.New("verification not found")
+	ErrVerificationResolved = errors.New("verification does not need follow-up")
+)
+
+// verificationRequest is posted to a verification webhook
+type verificationRequest struct {
+	Check            string `json:"check"`
+	PatientID        string `json:"patient_id"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	DateOfBirth      string `json:"date_of_birth"`
+	NationalID       string `json:"national_id,omitempty"`
+	InsuranceCompany string `json:"insurance_company,omitempty"`
+	Scheme           string `json:"scheme,omitempty"`
+	MemberNumber     string `json:"member_number,omitempty"`
+}
+
+// verificationResponse is what a verification webhook answers
+type verificationResponse struct {
+	Verified bool   `json:"verified"`
+	Reason   string `json:"reason"`
+}
+
+// VerificationService checks patient details with external services, such as a national ID
+// registry or an insurer's member lookup, after patients are created or updated.
+type VerificationService struct {
+	repository  *repositories.VerificationRepository
+	patientRepo *repositories.PatientRepository
+	config      config.VerificationConfig
+	client      *http.Client
+	queue       chan string
+}
+
+// NewVerificationService starts a background worker so saving a patient never waits on a webhook.
+func NewVerificationService(repository *repositories.VerificationRepository, patientRepo *repositories.PatientRepository, cfg config.VerificationConfig) *VerificationService {
+	s := &VerificationService{
+		repository:  repository,
+		patientRepo: patientRepo,
+		config:      cfg,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		queue:       make(chan string, verificationQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// HandlePatientSaved schedules the checks of a patient on PatientCreated and PatientUpdated.
+// Patients are skipped when the queue is full.
+func (s *VerificationService) HandlePatientSaved(_ context.Context, event events.Event) error {
+	if s.config.NationalIDURL == "" && s.config.InsurerMemberURL == "" {
+		return nil
+	}
+	select {
+	case s.queue <- event.PatientID:
+	default:
+		log.Printf("Verification queue full, skipping checks of patient %s", event.PatientID)
+	}
+	return nil
+}
+
+// Recheck runs every check of a patient again straight away and returns the outcomes
+func (s *VerificationService) Recheck(ctx context.Context, patientID string) ([]models.PatientVerification, error) {
+	patient, err := s.patientRepo.GetByID(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient == nil {
+		return nil, ErrPatientNotFound
+	}
+	s.verify(ctx, *patient, true)
+	return s.ListByPatient(ctx, patientID)
+}
+
+func (s *VerificationService) ListByPatient(ctx context.Context, patientID string) ([]models.PatientVerification, error) {
+	verifications, err := s.repository.ListByPatient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if verifications == nil {
+		verifications = []models.PatientVerification{}
+	}
+	return verifications, nil
+}
+
+// ListOpen returns the failed checks staff still have to follow up
+func (s *VerificationService) ListOpen(ctx context.Context) ([]models.PatientVerification, error) {
+	verifications, err := s.repository.ListOpen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if verifications == nil {
+		verifications = []models.PatientVerification{}
+	}
+	return verifications, nil
+}
+
+// Resolve records that staff followed up a failed check, for example after confirming the details by phone
+func (s *VerificationService) Resolve(ctx context.Context, id uint, userID int64, note string) (*models.PatientVerification, error) {
+	verification, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if verification == nil {
+		return nil, ErrVerificationNotFound
+	}
+	if err := s.repository.Resolve(ctx, verification, userID, strings.TrimSpace(note)); err != nil {
+		if errors.Is(err, repositories.ErrVerificationResolved) {
+			return nil, ErrVerificationResolved
+		}
+		return nil, err
+	}
+	return verification, nil
+}
+
+func (s *VerificationService) run() {
+	// Webhook calls and queries carry their own timeouts
+	for patientID := range s.queue {
+		ctx := context.Background()
+		patient, err := s.patientRepo.GetByID(ctx, patientID)
+		if err != nil {
+			log.Printf("Failed to load patient %s for verification: %v", patientID, err)
+			continue
+		}
+		if patient != nil {
+			s.verify(ctx, *patient, false)
+		}
+	}
+}
+
+// verify runs the configured checks of a patient. Unless forced, details that were already
+// verified are not sent again.
+func (s *VerificationService) verify(ctx context.Context, patient models.Patient, force bool) {
+	request := verificationRequest{
+		PatientID:   patient.ID,
+		FirstName:   patient.FirstName,
+		LastName:    patient.LastName,
+		DateOfBirth: patient.DateOfBirth,
+	}
+
+	if s.config.NationalIDURL != "" && patient.NationalID != "" {
+		check := request
+		check.Check = models.VerificationCheckNationalID
+		check.NationalID = patient.NationalID
+		s.check(ctx, s.config.NationalIDURL, check, patient.NationalID, force)
+	}
+	if s.config.InsurerMemberURL != "" && patient.Insured && patient.MemberNumber != "" {
+		check := request
+		check.Check = models.VerificationCheckInsurerMember
+		check.InsuranceCompany = patient.InsuranceCompany
+		check.Scheme = patient.Scheme
+		check.MemberNumber = patient.MemberNumber
+		s.check(ctx, s.config.InsurerMemberURL, check, patient.InsuranceCompany+"/"+patient.MemberNumber, force)
+	}
+}
+
+// check calls one webhook and stores its outcome, posting a verification_failed event when it fails
+func (s *VerificationService) check(ctx context.Context, url string, request verificationRequest, reference string, force bool) {
+	if !force {
+		previous, err := s.repository.Get(ctx, request.PatientID, request.Check)
+		if err != nil {
+			log.Printf("Failed to load %s verification of patient %s: %v", request.Check, request.PatientID, err)
+			return
+		}
+		if previous != nil && previous.Reference == reference && previous.Status == models.VerificationVerified {
+			return
+		}
+	}
+
+	verification := &models.PatientVerification{
+		PatientID: request.PatientID,
+		Check:     request.Check,
+		Reference: reference,
+		CheckedAt: time.Now(),
+	}
+	result, err := s.call(ctx, url, request)
+	switch {
+	case err != nil:
+		verification.Status = models.VerificationError
+		verification.Detail = err.Error()
+	case result.Verified:
+		verification.Status = models.VerificationVerified
+		verification.Detail = result.Reason
+	default:
+		verification.Status = models.VerificationFailed
+		verification.Detail = result.Reason
+	}
+
+	if err := s.repository.Save(ctx, verification); err != nil {
+		log.Printf("Failed to save %s verification of patient %s: %v", request.Check, request.PatientID, err)
+		return
+	}
+	if verification.Status != models.VerificationVerified {
+		notifications.Publish(notifications.EventVerificationFailed, "Patient verification needs follow-up",
+			fmt.Sprintf("The %s check of patient %s came back %s: %s", strings.ReplaceAll(request.Check, "_", " "), request.PatientID, verification.Status, verification.Detail))
+	}
+}
+
+// call posts a check to a verification webhook
+func (s *VerificationService) call(ctx context.Context, url string, request verificationRequest) (*verificationResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode verification request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("verification service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("verification service returned %s", resp.Status)
+	}
+
+	var result verificationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to read verification response: %w", err)
+	}
+	return &result, nil
+}
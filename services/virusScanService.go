@@ -0,0 +1,147 @@
+package services
+
+import (
+	"RoyDental/antivirus"
+	"RoyDental/clock"
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	// ErrInfectedFile is returned for uploads the virus scanner flagged; they are quarantined
+	ErrInfectedFile = errors.New("the file was flagged by the virus scanner and quarantined")
+	// ErrVirusScanUnavailable is returned for uploads the scanner could not check while uploads are
+	// refused in that case
+	ErrVirusScanUnavailable = errors.New("the file could not be scanned for viruses, please try again later")
+	// ErrQuarantinedFileNotFound is returned for quarantined files that do not exist
+	ErrQuarantinedFileNotFound = errors.New("quarantined file not found")
+)
+
+// VirusScanService checks uploaded files with the configured scanner before they are kept. Flagged
+// files are quarantined instead of being filed, and the admins and the security alert recipients
+// are emailed. Uploads are kept unscanned while no scanner is configured.
+type VirusScanService struct {
+	scanner         antivirus.Scanner
+	repository      *repositories.QuarantineRepository
+	userRepo        repositories.UserRepository
+	notifier        notifications.Notifier
+	alertRecipients []string
+	config          config.VirusScanConfig
+	clock           clock.Clock
+}
+
+func NewVirusScanService(scanner antivirus.Scanner, repository *repositories.QuarantineRepository, userRepo repositories.UserRepository, notifier notifications.Notifier, alertRecipients []string, cfg config.VirusScanConfig, clock clock.Clock) *VirusScanService {
+	return &VirusScanService{
+		scanner:         scanner,
+		repository:      repository,
+		userRepo:        userRepo,
+		notifier:        notifier,
+		alertRecipients: alertRecipients,
+		config:          cfg,
+		clock:           clock,
+	}
+}
+
+// Check scans an upload before it is kept. The file names its source, the patient it was sent
+// for, its name, type and content. Infected files are quarantined and ErrInfectedFile returned.
+func (s *VirusScanService) Check(ctx context.Context, file models.QuarantinedFile) error {
+	if s.scanner == nil {
+		return nil
+	}
+	threat, err := s.scanner.Scan(ctx, file.FileName, file.Content)
+	if err != nil {
+		if s.config.FailOpen {
+			log.Printf("Keeping %s unscanned: %v", file.FileName, err)
+			return nil
+		}
+		log.Printf("Failed to scan %s: %v", file.FileName, err)
+		return ErrVirusScanUnavailable
+	}
+	if threat == "" {
+		return nil
+	}
+
+	file.ID = 0
+	file.Threat = threat
+	file.Size = int64(len(file.Content))
+	file.DetectedAt = s.clock.Now()
+	if userID, ok := models.ActorFrom(ctx); ok {
+		file.UploadedBy = &userID
+	}
+	if err := s.repository.Create(ctx, &file); err != nil {
+		return err
+	}
+	s.alert(context.WithoutCancel(ctx), &file)
+	return fmt.Errorf("%w: %s", ErrInfectedFile, threat)
+}
+
+func (s *VirusScanService) List(ctx context.Context) ([]models.QuarantinedFile, error) {
+	files, err := s.repository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if files == nil {
+		files = []models.QuarantinedFile{}
+	}
+	return files, nil
+}
+
+func (s *VirusScanService) Get(ctx context.Context, id uint) (*models.QuarantinedFile, error) {
+	file, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, ErrQuarantinedFileNotFound
+	}
+	return file, nil
+}
+
+// Delete discards a quarantined file once an admin looked into it
+func (s *VirusScanService) Delete(ctx context.Context, id uint) error {
+	deleted, err := s.repository.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrQuarantinedFileNotFound
+	}
+	return nil
+}
+
+// alert emails the admins and the security alert recipients of the quarantined file; failing to
+// is logged, as the upload is refused either way
+func (s *VirusScanService) alert(ctx context.Context, file *models.QuarantinedFile) {
+	recipients, err := adminAlertRecipients(ctx, s.userRepo, s.alertRecipients)
+	if err != nil {
+		log.Printf("Failed to get the admins to alert of quarantined file %d: %v", file.ID, err)
+	}
+	if len(recipients) == 0 {
+		log.Printf("Quarantined file %d (%s) has no admin to alert", file.ID, file.Threat)
+		return
+	}
+
+	uploader, patient := "an unidentified user", "no patient"
+	if file.UploadedBy != nil {
+		uploader = fmt.Sprintf("user %d", *file.UploadedBy)
+	}
+	if file.PatientID != nil {
+		patient = "patient " + *file.PatientID
+	}
+	alert := notifications.Notification{
+		Recipients: recipients,
+		Subject:    fmt.Sprintf("Upload quarantined: %s found in %s", file.Threat, file.FileName),
+		Body: fmt.Sprintf("The virus scanner found %s in %s (%s, %d bytes), uploaded as %s for %s by %s at %s. The file was not filed and is kept in quarantine as file %d.\n\nPlease review it under quarantined files.",
+			file.Threat, file.FileName, file.ContentType, file.Size, file.Source, patient, uploader, file.DetectedAt.Format(time.RFC3339), file.ID),
+	}
+	if err := s.notifier.Send(ctx, alert); err != nil {
+		log.Printf("Failed to alert admins of quarantined file %d: %v", file.ID, err)
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidVitals       = errors.New("invalid vitals")
+	ErrInvalidChecklist    = errors.New("invalid checklist")
+	ErrChecklistNotFound   = errors.New("checklist not found")
+	ErrChecklistIncomplete = errors.New("not every checklist item is checked")
+)
+
+// WalkInRequest is a visit by a patient without an appointment. Either PatientID or Patient is set;
+// Patient holds the minimal details of someone not yet registered.
+type WalkInRequest struct {
+	PatientID string          `json:"patient_id"`
+	Patient   *models.Patient `json:"patient"`
+	DoctorID  string          `json:"doctor_id" binding:"required"`
+}
+
+type VisitService struct {
+	repository      *repositories.VisitRepository
+	appointmentRepo *repositories.AppointmentRepository
+	alertRepo       *repositories.PatientAlertRepository
+}
+
+func NewVisitService(repository *repositories.VisitRepository, appointmentRepo *repositories.AppointmentRepository, alertRepo *repositories.PatientAlertRepository) *VisitService {
+	return &VisitService{repository: repository, appointmentRepo: appointmentRepo, alertRepo: alertRepo}
+}
+
+func (s *VisitService) CreateWalkIn(ctx context.Context, request WalkInRequest) (*models.Appointment, error) {
+	if (request.PatientID == "") == (request.Patient == nil) {
+		return nil, errors.New("exactly one of patient_id or patient is required")
+	}
+	if p := request.Patient; p != nil {
+		if p.FirstName == "" || p.LastName == "" || p.DateOfBirth == "" || p.Sex == "" {
+			return nil, errors.New("a new patient needs first_name, last_name, date_of_birth and sex")
+		}
+		if !p.Insured {
+			// Walk-ins without insurance details pay cash until the record is completed
+			p.Cash = true
+		}
+		if p.Attribution.Source == "" {
+			p.Attribution.Source = models.SourceWalkIn
+		}
+	}
+
+	appointment := &models.Appointment{PatientID: request.PatientID, DoctorID: request.DoctorID, Attribution: models.Attribution{Source: models.SourceWalkIn}}
+	if err := s.repository.CreateWalkIn(ctx, request.Patient, appointment); err != nil {
+		return nil, err
+	}
+	return appointment, nil
+}
+
+// GetVisit returns the appointment with the patient's alerts, its vitals, completed checklists and
+// what is outstanding before treatment can start
+func (s *VisitService) GetVisit(ctx context.Context, patientID string, appointmentID uint) (*models.VisitRecord, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, repositories.ErrAppointmentNotFound
+	}
+	vitals, err := s.repository.GetVitals(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	completions, err := s.repository.ListCompletions(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if completions == nil {
+		completions = []models.ChecklistCompletion{}
+	}
+	alerts, err := s.alertRepo.ListForVisit(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding := []string{}
+	if appointment.Status == models.AppointmentStatusScheduled || appointment.Status == models.AppointmentStatusCheckedIn {
+		if outstanding, err = s.repository.Outstanding(ctx, appointment); err != nil {
+			return nil, err
+		}
+	}
+	return &models.VisitRecord{Appointment: *appointment, Alerts: alerts, Vitals: vitals, Checklists: completions, Outstanding: outstanding}, nil
+}
+
+// SaveVitals records the vitals of the patient's appointment
+func (s *VisitService) SaveVitals(ctx context.Context, vitals *models.Vitals) error {
+	switch {
+	case vitals.SystolicBP < 50 || vitals.SystolicBP > 300:
+		return fmt.Errorf("%w: systolic_bp must be between 50 and 300", ErrInvalidVitals)
+	case vitals.DiastolicBP < 20 || vitals.DiastolicBP > 200:
+		return fmt.Errorf("%w: diastolic_bp must be between 20 and 200", ErrInvalidVitals)
+	case vitals.DiastolicBP >= vitals.SystolicBP:
+		return fmt.Errorf("%w: diastolic_bp must be below systolic_bp", ErrInvalidVitals)
+	case vitals.Pulse < 20 || vitals.Pulse > 250:
+		return fmt.Errorf("%w: pulse must be between 20 and 250", ErrInvalidVitals)
+	}
+	vitals.ID = 0
+	return s.repository.SaveVitals(ctx, vitals)
+}
+
+// CompleteChecklist records the checklist as completed for the appointment once every item is checked
+func (s *VisitService) CompleteChecklist(ctx context.Context, patientID string, appointmentID, checklistID uint, checked []string) (*models.ChecklistCompletion, error) {
+	checklist, err := s.repository.GetChecklist(ctx, checklistID)
+	if err != nil {
+		return nil, err
+	}
+	if checklist == nil {
+		return nil, ErrChecklistNotFound
+	}
+	ticked := make(map[string]bool, len(checked))
+	for _, item := range checked {
+		ticked[strings.TrimSpace(item)] = true
+	}
+	var missing []string
+	for _, item := range checklist.Items {
+		if !ticked[item] {
+			missing = append(missing, item)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrChecklistIncomplete, strings.Join(missing, "; "))
+	}
+
+	completion := &models.ChecklistCompletion{
+		AppointmentID: appointmentID,
+		ChecklistID:   checklist.ID,
+		Checklist:     checklist.Name,
+		Items:         checklist.Items,
+	}
+	if err := s.repository.CompleteChecklist(ctx, patientID, completion); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+func (s *VisitService) GetChecklists(ctx context.Context) ([]models.Checklist, error) {
+	checklists, err := s.repository.ListChecklists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if checklists == nil {
+		checklists = []models.Checklist{}
+	}
+	return checklists, nil
+}
+
+func (s *VisitService) CreateChecklist(ctx context.Context, checklist *models.Checklist) error {
+	if err := validateChecklist(checklist); err != nil {
+		return err
+	}
+	return s.repository.CreateChecklist(ctx, checklist)
+}
+
+func (s *VisitService) UpdateChecklist(ctx context.Context, checklist *models.Checklist) error {
+	existing, err := s.repository.GetChecklist(ctx, checklist.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrChecklistNotFound
+	}
+	if err := validateChecklist(checklist); err != nil {
+		return err
+	}
+	return s.repository.UpdateChecklist(ctx, checklist)
+}
+
+// DeleteChecklist removes a checklist; completions already recorded keep its name and items
+func (s *VisitService) DeleteChecklist(ctx context.Context, id uint) error {
+	return s.repository.DeleteChecklist(ctx, id)
+}
+
+func validateChecklist(checklist *models.Checklist) error {
+	checklist.Name = strings.TrimSpace(checklist.Name)
+	checklist.Specialty = strings.ToLower(strings.TrimSpace(checklist.Specialty))
+	if checklist.Name == "" {
+		return fmt.Errorf("%w: the name is required", ErrInvalidChecklist)
+	}
+	if checklist.Specialty != "" && !models.IsValidSpecialty(checklist.Specialty) {
+		return fmt.Errorf("%w: unknown specialty %q", ErrInvalidChecklist, checklist.Specialty)
+	}
+	items := models.ChecklistItems{}
+	for _, item := range checklist.Items {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidChecklist)
+	}
+	checklist.Items = items
+	return nil
+}
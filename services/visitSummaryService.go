@@ -0,0 +1,218 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/events"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/pdf"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// visitSummaryDeliveryTimeout bounds emailing a patient the summary of a fulfilled appointment
+const visitSummaryDeliveryTimeout = time.Minute
+
+// ErrVisitNotStarted is returned for summaries of appointments the patient was not treated at
+var ErrVisitNotStarted = errors.New("the visit has not started")
+
+// VisitSummaryService writes patient-friendly letters summing up a visit: what was found, the
+// treatment done, the next steps and what it cost. Patients can be emailed theirs on checkout.
+type VisitSummaryService struct {
+	visitRepo       *repositories.VisitRepository
+	appointmentRepo *repositories.AppointmentRepository
+	patientRepo     *repositories.PatientRepository
+	communications  *CommunicationService
+	email           notifications.Notifier
+	config          config.VisitSummaryConfig
+}
+
+func NewVisitSummaryService(visitRepo *repositories.VisitRepository, appointmentRepo *repositories.AppointmentRepository, patientRepo *repositories.PatientRepository, communications *CommunicationService, email notifications.Notifier, cfg config.VisitSummaryConfig) *VisitSummaryService {
+	return &VisitSummaryService{
+		visitRepo:       visitRepo,
+		appointmentRepo: appointmentRepo,
+		patientRepo:     patientRepo,
+		communications:  communications,
+		email:           email,
+		config:          cfg,
+	}
+}
+
+// PDF returns the summary letter of the patient's visit at the appointment
+func (s *VisitSummaryService) PDF(ctx context.Context, patientID string, appointmentID uint) ([]byte, error) {
+	summary, err := s.summary(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(summary), nil
+}
+
+func (s *VisitSummaryService) summary(ctx context.Context, patientID string, appointmentID uint) (*models.VisitSummary, error) {
+	appointment, err := s.appointmentRepo.GetByID(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, repositories.ErrAppointmentNotFound
+	}
+	if appointment.Status != models.AppointmentStatusInProgress && appointment.Status != models.AppointmentStatusFulfilled {
+		return nil, ErrVisitNotStarted
+	}
+
+	summary := &models.VisitSummary{Appointment: *appointment, Day: visitDay(appointment)}
+	if err := s.visitRepo.Summary(ctx, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// visitDay returns when the patient was seen, or else when the appointment was booked for
+func visitDay(appointment *models.Appointment) time.Time {
+	for _, at := range []*time.Time{appointment.SeenAt, appointment.CheckedInAt, appointment.StartsAt} {
+		if at != nil {
+			return *at
+		}
+	}
+	if at, ok := models.ParseAppointmentTime(appointment.DateTime); ok {
+		return at
+	}
+	return appointment.CreatedAt
+}
+
+func (s *VisitSummaryService) render(summary *models.VisitSummary) []byte {
+	appointment := summary.Appointment
+	doc := pdf.New()
+	doc.Title(s.config.ClinicName + " - Summary of your visit")
+	doc.Text("Patient: " + appointment.Patient.FirstName + " " + appointment.Patient.LastName)
+	doc.Text("Date: " + models.FormatDayDate(summary.Day.In(models.ClinicLocation())))
+	doc.Text("Dentist: Dr " + appointment.Doctor.FirstName + " " + appointment.Doctor.LastName)
+
+	doc.Heading("What we found")
+	if len(summary.Examinations) == 0 {
+		doc.Text("No examination findings were recorded at this visit.")
+	}
+	for _, examination := range summary.Examinations {
+		doc.Text(strings.TrimSpace(examination.Report))
+		doc.Space(4)
+	}
+
+	doc.Heading("Treatment done")
+	var treatments []string
+	if summary.Procedure != "" {
+		treatments = append(treatments, summary.Procedure)
+	}
+	for _, billing := range summary.Billings {
+		if billing.Procedure != summary.Procedure {
+			treatments = append(treatments, billing.Procedure)
+		}
+	}
+	if len(treatments) == 0 {
+		doc.Text("No treatment was recorded at this visit.")
+	}
+	for _, treatment := range treatments {
+		doc.Text("- " + treatment)
+	}
+
+	doc.Heading("Next steps")
+	if summary.TreatmentPlan != nil {
+		doc.Text("Your treatment plan:")
+		doc.Text(strings.TrimSpace(summary.TreatmentPlan.Plan))
+		doc.Space(4)
+	}
+	if next := summary.NextAppointment; next != nil && next.StartsAt != nil {
+		startsAt := next.StartsAt.In(models.ClinicLocation())
+		doc.Text(fmt.Sprintf("Your next appointment is on %s at %s with Dr %s %s.",
+			models.FormatDayDate(startsAt), startsAt.Format("15:04"), next.Doctor.FirstName, next.Doctor.LastName))
+	} else {
+		doc.Text("You have no further appointment booked. Please contact us if you need one.")
+	}
+
+	doc.Heading("Costs")
+	if len(summary.Billings) == 0 {
+		doc.Text("Nothing was billed at this visit.")
+	}
+	var billed, paid, balance float64
+	for _, billing := range summary.Billings {
+		received := billing.PaidCashAmount + billing.PaidInsuranceAmount
+		doc.Text(fmt.Sprintf("%s: charged %s, paid %s, to pay %s", billing.Procedure,
+			models.FormatMoney(billing.BillingAmount), models.FormatMoney(received), models.FormatMoney(billing.Balance)))
+		billed += billing.BillingAmount
+		paid += received
+		balance += billing.Balance
+	}
+	if len(summary.Billings) > 1 {
+		doc.Space(4)
+		doc.Text(fmt.Sprintf("Total: charged %s, paid %s, to pay %s", models.FormatMoney(billed), models.FormatMoney(paid), models.FormatMoney(balance)))
+	}
+	return doc.Bytes()
+}
+
+// HandleAppointmentFulfilled emails the patient the summary of the visit they were checked out of
+func (s *VisitSummaryService) HandleAppointmentFulfilled(ctx context.Context, event events.Event) error {
+	appointmentID, err := strconv.ParseUint(event.RecordID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid appointment ID %q: %w", event.RecordID, err)
+	}
+	summary, err := s.summary(ctx, event.PatientID, uint(appointmentID))
+	if err != nil {
+		return err
+	}
+	patient, err := s.patientRepo.GetByID(ctx, event.PatientID)
+	if err != nil {
+		return err
+	}
+	if patient == nil || patient.Email == "" {
+		return nil
+	}
+	s.deliver(summary, patient.Email)
+	return nil
+}
+
+// deliver emails the summary in the background so a slow mail server never delays the checkout
+func (s *VisitSummaryService) deliver(summary *models.VisitSummary, recipient string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), visitSummaryDeliveryTimeout)
+		defer cancel()
+
+		appointment := summary.Appointment
+		day := summary.Day.In(models.ClinicLocation())
+		notification := notifications.Notification{
+			Recipients: []string{recipient},
+			PatientID:  appointment.PatientID,
+			Purpose:    notifications.PurposeService,
+			MessageID:  notifications.NewMessageID(),
+			Subject:    "Summary of your visit on " + models.FormatDate(day),
+			Body: fmt.Sprintf("Dear %s,\n\nThank you for visiting us. Please find attached a summary of your visit, with the treatment done, the next steps and its costs.\n",
+				appointment.Patient.FirstName),
+			Attachments: []notifications.Attachment{{
+				Name:        "visit-summary-" + day.Format("2006-01-02") + ".pdf",
+				ContentType: "application/pdf",
+				Data:        s.render(summary),
+			}},
+		}
+		sendErr := s.email.Send(ctx, notification)
+		if sendErr != nil && !errors.Is(sendErr, notifications.ErrNotPermitted) && !errors.Is(sendErr, notifications.ErrDeferred) {
+			log.Printf("Failed to email the visit summary to patient %s: %v", appointment.PatientID, sendErr)
+		}
+		err := s.communications.LogMessage(ctx, models.CommunicationLog{
+			PatientID: appointment.PatientID,
+			Channel:   notifications.ChannelEmail,
+			Purpose:   notification.Purpose,
+			Recipient: recipient,
+			Subject:   notification.Subject,
+			Body:      notification.Body,
+			MessageID: notification.MessageID,
+			Record:    "appointment",
+			RecordID:  strconv.FormatUint(uint64(appointment.ID), 10),
+		}, sendErr)
+		if err != nil {
+			log.Printf("Failed to log the visit summary to patient %s: %v", appointment.PatientID, err)
+		}
+	}()
+}
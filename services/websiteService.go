@@ -0,0 +1,259 @@
+package services
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"RoyDental/notifications"
+	"RoyDental/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+// appointmentRequestTextLimit caps the message sent with an appointment request
+const appointmentRequestTextLimit = 2000
+
+var (
+	ErrInvalidAppointmentRequest  = errors.New("invalid appointment request")
+	ErrAppointmentRequestNotFound = errors.New("appointment request not found")
+	ErrAppointmentRequestReviewed = errors.New("appointment request has already been reviewed")
+)
+
+// WebsiteService serves the clinic website: the doctors and services it lists, and the appointment
+// requests it sends, which wait for a receptionist to book them
+type WebsiteService struct {
+	repository      *repositories.WebsiteRepository
+	doctorRepo      *repositories.DoctorRepository
+	procedureRepo   *repositories.ProcedureRepository
+	appointmentRepo *repositories.AppointmentRepository
+	config          config.WebsiteConfig
+}
+
+func NewWebsiteService(repository *repositories.WebsiteRepository, doctorRepo *repositories.DoctorRepository, procedureRepo *repositories.ProcedureRepository,
+	appointmentRepo *repositories.AppointmentRepository, cfg config.WebsiteConfig) *WebsiteService {
+	return &WebsiteService{repository: repository, doctorRepo: doctorRepo, procedureRepo: procedureRepo, appointmentRepo: appointmentRepo, config: cfg}
+}
+
+// Enabled reports whether the website has keys to call its API with
+func (s *WebsiteService) Enabled() bool {
+	return len(s.config.APIKeys) > 0
+}
+
+// Doctors lists the doctors patients can request, leaving out those whose credentials have expired
+func (s *WebsiteService) Doctors(ctx context.Context) ([]models.WebsiteDoctor, error) {
+	doctors, err := s.repository.Doctors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.repository.DoctorProcedures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := map[string][]uint{}
+	for _, link := range links {
+		services[link.DoctorID] = append(services[link.DoctorID], link.ProcedureID)
+	}
+
+	now := time.Now()
+	listed := make([]models.WebsiteDoctor, 0, len(doctors))
+	for _, doctor := range doctors {
+		if len(doctor.ExpiredCredentials(now)) > 0 {
+			continue
+		}
+		line := models.WebsiteDoctor{
+			ID:         doctor.ID,
+			FirstName:  doctor.FirstName,
+			LastName:   doctor.LastName,
+			Specialty:  doctor.Specialty,
+			ServiceIDs: services[doctor.ID],
+		}
+		if doctor.PhotoURL != nil {
+			line.PhotoURL = *doctor.PhotoURL
+		}
+		if line.ServiceIDs == nil {
+			line.ServiceIDs = []uint{}
+		}
+		listed = append(listed, line)
+	}
+	return listed, nil
+}
+
+// Services lists the procedures offered, with their cash prices when the clinic publishes them
+func (s *WebsiteService) Services(ctx context.Context) ([]models.WebsiteService, error) {
+	procedures, err := s.procedureRepo.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]models.WebsiteService, 0, len(procedures))
+	for _, procedure := range procedures {
+		line := models.WebsiteService{ID: procedure.ID, Name: procedure.Name, Specialty: procedure.Specialty}
+		if s.config.ShowPrices {
+			price := procedure.Price
+			line.Price = &price
+		}
+		listed = append(listed, line)
+	}
+	return listed, nil
+}
+
+// RequestAppointment queues an appointment requested on the website for the front desk and posts it to chat
+func (s *WebsiteService) RequestAppointment(ctx context.Context, request *models.AppointmentRequest, ip string) error {
+	if err := s.validateRequest(ctx, request); err != nil {
+		return err
+	}
+	request.ID = 0
+	request.Status = models.AppointmentRequestPending
+	request.AppointmentID = nil
+	request.ReviewedBy = nil
+	request.ReviewedAt = nil
+	request.ReviewNote = ""
+	request.IP = ip
+	if err := s.repository.CreateRequest(ctx, request); err != nil {
+		return err
+	}
+
+	preferred := "any day"
+	if request.PreferredDate != "" {
+		preferred = request.PreferredDate
+	}
+	notifications.Publish(notifications.EventNewOnlineBooking, "Appointment requested",
+		fmt.Sprintf("Appointment request #%d from %s %s on the website, preferring %s (%s).", request.ID, request.FirstName, request.LastName, preferred, request.PreferredTime))
+	return nil
+}
+
+func (s *WebsiteService) GetRequest(ctx context.Context, id uint) (*models.AppointmentRequest, error) {
+	request, err := s.repository.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrAppointmentRequestNotFound
+	}
+	return request, nil
+}
+
+// ListRequests returns the appointment requests in status, pending ones when no status is given
+func (s *WebsiteService) ListRequests(ctx context.Context, status string) ([]models.AppointmentRequest, error) {
+	if status == "" {
+		status = models.AppointmentRequestPending
+	}
+	requests, err := s.repository.ListRequests(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []models.AppointmentRequest{}
+	}
+	return requests, nil
+}
+
+// Book closes a pending request with the appointment the receptionist booked for it
+func (s *WebsiteService) Book(ctx context.Context, id uint, patientID string, appointmentID uint, reviewer int64, note string) (*models.AppointmentRequest, error) {
+	request, err := s.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	appointment, err := s.appointmentRepo.GetByID(ctx, patientID, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if appointment == nil {
+		return nil, repositories.ErrAppointmentNotFound
+	}
+	// The booking, and the patient when they are new, came from the website unless the desk said otherwise
+	attribution := models.Attribution{Source: models.SourceWebsite, CampaignCode: request.CampaignCode}
+	if err := s.appointmentRepo.Attribute(ctx, patientID, appointment.ID, attribution); err != nil {
+		return nil, err
+	}
+	return s.review(ctx, request, models.AppointmentRequestBooked, &appointment.ID, reviewer, note)
+}
+
+// Decline closes a pending request without booking it, e.g. spam or a patient who could not be reached
+func (s *WebsiteService) Decline(ctx context.Context, id uint, reviewer int64, note string) (*models.AppointmentRequest, error) {
+	request, err := s.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.review(ctx, request, models.AppointmentRequestDeclined, nil, reviewer, note)
+}
+
+func (s *WebsiteService) review(ctx context.Context, request *models.AppointmentRequest, status string, appointmentID *uint, reviewer int64, note string) (*models.AppointmentRequest, error) {
+	if err := s.repository.ReviewRequest(ctx, request, status, appointmentID, reviewer, strings.TrimSpace(note)); err != nil {
+		if errors.Is(err, repositories.ErrAppointmentRequestReviewed) {
+			return nil, ErrAppointmentRequestReviewed
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// validateRequest checks the website form, which must leave a way to reach the patient and may only
+// prefer a day within the booking horizon
+func (s *WebsiteService) validateRequest(ctx context.Context, request *models.AppointmentRequest) error {
+	request.FirstName = strings.TrimSpace(request.FirstName)
+	request.LastName = strings.TrimSpace(request.LastName)
+	request.Phone = strings.TrimSpace(request.Phone)
+	request.Email = strings.TrimSpace(request.Email)
+	request.PreferredDate = strings.TrimSpace(request.PreferredDate)
+	request.Message = strings.TrimSpace(request.Message)
+	request.CampaignCode = cleanCampaignCode(request.CampaignCode)
+	if request.PreferredTime == "" {
+		request.PreferredTime = "any"
+	}
+
+	switch {
+	case request.FirstName == "" || request.LastName == "":
+		return fmt.Errorf("%w: first and last name are required", ErrInvalidAppointmentRequest)
+	case request.Phone == "" && request.Email == "":
+		return fmt.Errorf("%w: a phone number or email address is required", ErrInvalidAppointmentRequest)
+	case !slices.Contains(models.PreferredTimes, request.PreferredTime):
+		return fmt.Errorf("%w: preferred_time must be one of %s", ErrInvalidAppointmentRequest, strings.Join(models.PreferredTimes, ", "))
+	case len(request.Message) > appointmentRequestTextLimit:
+		return fmt.Errorf("%w: the message is limited to %d characters", ErrInvalidAppointmentRequest, appointmentRequestTextLimit)
+	}
+	if request.Email != "" {
+		if _, err := mail.ParseAddress(request.Email); err != nil {
+			return fmt.Errorf("%w: invalid email address", ErrInvalidAppointmentRequest)
+		}
+	}
+	if request.PreferredDate != "" {
+		day, err := time.ParseInLocation(models.ClosureDateLayout, request.PreferredDate, models.ClinicLocation())
+		today := time.Now().In(models.ClinicLocation()).Format(models.ClosureDateLayout)
+		if err != nil || request.PreferredDate < today {
+			return fmt.Errorf("%w: preferred_date must be today or later as YYYY-MM-DD", ErrInvalidAppointmentRequest)
+		}
+		if day.After(time.Now().AddDate(0, 0, s.config.BookingAhead)) {
+			return fmt.Errorf("%w: appointments can be requested up to %d days ahead", ErrInvalidAppointmentRequest, s.config.BookingAhead)
+		}
+	}
+
+	if request.DoctorID != nil && *request.DoctorID == "" {
+		request.DoctorID = nil
+	}
+	if request.DoctorID != nil {
+		doctor, err := s.doctorRepo.GetByID(ctx, *request.DoctorID)
+		if err != nil {
+			return err
+		}
+		if doctor == nil {
+			return fmt.Errorf("%w: unknown doctor %s", ErrInvalidAppointmentRequest, *request.DoctorID)
+		}
+	}
+	if request.ProcedureID != nil && *request.ProcedureID == 0 {
+		request.ProcedureID = nil
+	}
+	if request.ProcedureID != nil {
+		procedure, err := s.procedureRepo.GetByID(ctx, *request.ProcedureID)
+		if err != nil {
+			return err
+		}
+		if procedure == nil {
+			return fmt.Errorf("%w: unknown service %d", ErrInvalidAppointmentRequest, *request.ProcedureID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package siem
+
+import (
+	"RoyDental/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The device audit entries are reported from in CEF headers
+const (
+	deviceVendor  = "RoyDental"
+	deviceProduct = "RoyDental API"
+	deviceVersion = "1"
+)
+
+// Format writes an audit entry recorded on host as a single line, without a line ending
+type Format func(entry models.AuditLog, host string) string
+
+// Severity rates an audit entry from 0 to 10 as CEF does: access to records under break-glass and
+// refused requests are what security analysts look at first
+func Severity(entry models.AuditLog) int {
+	switch {
+	case entry.Event == models.AuditEventBreakGlassAccess:
+		return 8
+	case entry.Category == models.AuditCategorySecurity && entry.Status >= 400:
+		return 6
+	case entry.Category == models.AuditCategorySecurity:
+		return 3
+	}
+	return 2
+}
+
+// CEF writes an entry in the Common Event Format, its event as the signature ID, e.g.
+// CEF:0|RoyDental|RoyDental API|1|role_mismatch|role mismatch|6|rt=1700000000000 src=10.0.0.5 ...
+func CEF(entry models.AuditLog, host string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|", cefHeader(deviceVendor), cefHeader(deviceProduct), deviceVersion,
+		cefHeader(entry.Event), cefHeader(strings.ReplaceAll(entry.Event, "_", " ")), Severity(entry))
+
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(entry.CreatedAt.UnixMilli(), 10)},
+		{"externalId", strconv.FormatInt(entry.ID, 10)},
+		{"cat", entry.Category},
+		{"dvchost", host},
+		{"src", entry.IP},
+		{"suser", entry.UserID},
+		{"requestMethod", entry.Method},
+		{"request", entry.Route},
+		{"msg", entry.Detail},
+	}
+	if entry.Role != "" {
+		extensions = append(extensions, [2]string{"cs1Label", "role"}, [2]string{"cs1", entry.Role})
+	}
+	if entry.Status != 0 {
+		extensions = append(extensions, [2]string{"outcome", strconv.Itoa(entry.Status)})
+	}
+	written := 0
+	for _, extension := range extensions {
+		if extension[1] == "" {
+			continue
+		}
+		if written > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(extension[0] + "=" + cefExtension(extension[1]))
+		written++
+	}
+	return b.String()
+}
+
+// jsonEvent is an audit entry as JSON with where it came from and how severe it is
+type jsonEvent struct {
+	models.AuditLog
+	Product  string `json:"product"`
+	Host     string `json:"host,omitempty"`
+	Severity int    `json:"severity"`
+}
+
+// JSON writes an entry as a JSON object, with the product and host it came from and its severity
+func JSON(entry models.AuditLog, host string) string {
+	data, err := json.Marshal(jsonEvent{AuditLog: entry, Product: deviceProduct, Host: host, Severity: Severity(entry)})
+	if err != nil {
+		// An audit entry is only strings, numbers and a time, so it always encodes
+		return "{}"
+	}
+	return string(data)
+}
+
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefHeader escapes a CEF header field, in which pipes and backslashes are escaped and line breaks
+// may not appear
+func cefHeader(value string) string {
+	return cefHeaderEscaper.Replace(value)
+}
+
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+// cefExtension escapes a CEF extension value, in which equals signs, backslashes and line breaks
+// are escaped
+func cefExtension(value string) string {
+	return cefExtensionEscaper.Replace(value)
+}
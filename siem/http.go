@@ -0,0 +1,49 @@
+package siem
+
+import (
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HTTP posts entries to a collector, such as an HTTP event collector or a log ingestion API: as a
+// JSON array of the entries when they are written as JSON, and one entry per line otherwise
+type HTTP struct {
+	URL       string
+	Token     string // Sent as a bearer token when set
+	Format    Format
+	JSONArray bool
+	Host      string // Host the entries are sent from
+	Client    *http.Client
+}
+
+func (h *HTTP) Send(ctx context.Context, entries []models.AuditLog) error {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = h.Format(entry, h.Host)
+	}
+	body, contentType := strings.Join(lines, "\n")+"\n", "text/plain; charset=utf-8"
+	if h.JSONArray {
+		body, contentType = "["+strings.Join(lines, ",")+"]", "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send audit entries to the SIEM collector: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("SIEM collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package siem forwards the audit trail to a security information and event management system or
+// syslog collector: as syslog messages over TCP, with or without TLS, or posted to an HTTP
+// collector, each entry written in the Common Event Format or as JSON.
+package siem
+
+import (
+	"RoyDental/config"
+	"RoyDental/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Sink receives the audit entries forwarded out of the database
+type Sink interface {
+	// Send delivers entries in order; when it fails none of them are taken as delivered
+	Send(ctx context.Context, entries []models.AuditLog) error
+}
+
+// New returns the configured sink, or nil when the audit trail is not forwarded
+func New(cfg config.SIEMConfig) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	var format Format
+	switch cfg.Format {
+	case config.SIEMFormatCEF:
+		format = CEF
+	case config.SIEMFormatJSON:
+		format = JSON
+	default:
+		return nil, fmt.Errorf("unknown SIEM format %q", cfg.Format)
+	}
+	host, _ := os.Hostname()
+
+	collector, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIEM_URL: %w", err)
+	}
+	switch collector.Scheme {
+	case "tcp", "tls":
+		if collector.Host == "" {
+			return nil, errors.New("SIEM_URL must name the collector as tcp://host:port or tls://host:port")
+		}
+		return &Syslog{Address: collector.Host, TLS: collector.Scheme == "tls", Format: format, Host: host, Timeout: cfg.Timeout}, nil
+	case "http", "https":
+		return &HTTP{URL: cfg.URL, Token: cfg.Token, Format: format, JSONArray: cfg.Format == config.SIEMFormatJSON, Host: host,
+			Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown SIEM_URL scheme %q", collector.Scheme)
+}
+
+// Endpoint returns the collector of rawURL without the credentials or query it may hold, to show
+// where entries go
+func Endpoint(rawURL string) string {
+	collector, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	collector.User = nil
+	collector.RawQuery = ""
+	return collector.String()
+}
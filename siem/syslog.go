@@ -0,0 +1,85 @@
+package siem
+
+import (
+	"RoyDental/models"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// syslogFacility is authpriv, the facility of security and authorization messages
+	syslogFacility = 10
+	// syslogApp is the application messages are sent as
+	syslogApp = "roydental"
+	// maxMsgID is the longest message ID syslog allows
+	maxMsgID = 32
+)
+
+// Syslog sends entries as RFC 5424 syslog messages over TCP, one per line as collectors such as
+// rsyslog, syslog-ng and most SIEM agents read them, each entry's event as the message ID
+type Syslog struct {
+	Address string // host:port of the collector
+	TLS     bool
+	Format  Format
+	Host    string // Host the messages are sent from
+	Timeout time.Duration
+}
+
+func (s *Syslog) Send(ctx context.Context, entries []models.AuditLog) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if s.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", s.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to the SIEM collector: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	for _, entry := range entries {
+		w.WriteString(s.message(entry))
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to send audit entries to the SIEM collector: %w", err)
+	}
+	return nil
+}
+
+// message writes an entry as a syslog message: a warning when CEF rates it 6 or more, a notice
+// otherwise
+func (s *Syslog) message(entry models.AuditLog) string {
+	severity := 5
+	if Severity(entry) >= 6 {
+		severity = 4
+	}
+	host := s.Host
+	if host == "" {
+		host = "-"
+	}
+	msgID := entry.Event
+	if msgID == "" {
+		msgID = "-"
+	}
+	if len(msgID) > maxMsgID {
+		msgID = msgID[:maxMsgID]
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s", syslogFacility*8+severity, entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		host, syslogApp, msgID, s.Format(entry, s.Host))
+}
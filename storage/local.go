@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps files under a directory on disk, a directory per bucket. Encryption at rest is left
+// to the disk.
+type Local struct {
+	Dir string
+}
+
+func (l *Local) Put(_ context.Context, bucket, key string, content []byte, _ string) error {
+	path, err := l.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	// Written aside and renamed, so a reader never sees half a file
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to store %s/%s: %w", bucket, key, err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store %s/%s: %w", bucket, key, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store %s/%s: %w", bucket, key, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to store %s/%s: %w", bucket, key, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to store %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (l *Local) Get(_ context.Context, bucket, key string) ([]byte, error) {
+	path, err := l.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", bucket, key, err)
+	}
+	return content, nil
+}
+
+func (l *Local) Delete(_ context.Context, bucket, key string) error {
+	path, err := l.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (l *Local) path(bucket, key string) (string, error) {
+	if err := checkKey(bucket); err != nil {
+		return "", err
+	}
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Dir, bucket, filepath.FromSlash(key)), nil
+}
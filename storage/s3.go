@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"RoyDental/config"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Server-side encryption of S3 objects
+const (
+	EncryptionAES256 = "AES256"  // Keys the store manages
+	EncryptionKMS    = "aws:kms" // A KMS key, the account's default unless KMSKey names one
+)
+
+// defaultGCSEndpoint is Google Cloud Storage's S3-compatible endpoint
+const defaultGCSEndpoint = "https://storage.googleapis.com"
+
+// S3 keeps files in S3 buckets, or in those of a store speaking S3's API such as MinIO or Google
+// Cloud Storage, signing requests with AWS Signature Version 4. Each bucket is addressed in its own
+// region, so files of each kind stay where its bucket lives.
+type S3 struct {
+	Endpoint   string // Empty for AWS, addressing buckets by host name
+	AccessKey  string
+	SecretKey  string
+	Buckets    map[string]config.StorageBucket
+	Region     string // Region of buckets not configured
+	Encryption string
+	KMSKey     string
+	GCS        bool // Google Cloud Storage, which takes its own encryption header
+	Client     *http.Client
+}
+
+func (s *S3) Put(ctx context.Context, bucket, key string, content []byte, contentType string) error {
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["content-type"] = contentType
+	}
+	switch {
+	case s.GCS && s.KMSKey != "":
+		headers["x-goog-encryption-kms-key-name"] = s.KMSKey
+	case s.GCS:
+	case s.Encryption != "":
+		headers["x-amz-server-side-encryption"] = s.Encryption
+		if s.Encryption == EncryptionKMS && s.KMSKey != "" {
+			headers["x-amz-server-side-encryption-aws-kms-key-id"] = s.KMSKey
+		}
+	}
+	response, err := s.do(ctx, http.MethodPut, bucket, key, content, headers)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return s.failure("store", bucket, key, response)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	response, err := s.do(ctx, http.MethodGet, bucket, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, s.failure("read", bucket, key, response)
+	}
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", bucket, key, err)
+	}
+	return content, nil
+}
+
+func (s *S3) Delete(ctx context.Context, bucket, key string) error {
+	response, err := s.do(ctx, http.MethodDelete, bucket, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+		return s.failure("delete", bucket, key, response)
+	}
+	return nil
+}
+
+// do sends a signed request for the object key of the bucket of kind bucket
+func (s *S3) do(ctx context.Context, method, bucket, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	target, region := s.bucket(bucket)
+	endpoint, err := s.objectURL(target, region, key)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	s.sign(request, body, region, time.Now().UTC())
+
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach storage: %w", err)
+	}
+	return response, nil
+}
+
+// bucket returns the name and region of the bucket of kind, named after the kind in the default
+// region when it is not configured
+func (s *S3) bucket(kind string) (string, string) {
+	bucket := s.Buckets[kind]
+	if bucket.Name == "" {
+		bucket.Name = kind
+	}
+	if bucket.Region == "" {
+		bucket.Region = s.Region
+	}
+	return bucket.Name, bucket.Region
+}
+
+// objectURL addresses the object: by host name on AWS, in the bucket's regional endpoint, and by
+// path on other stores
+func (s *S3) objectURL(bucket, region, key string) (*url.URL, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" && s.GCS {
+		endpoint = defaultGCSEndpoint
+	}
+	if endpoint == "" {
+		return url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapePath(key)))
+	}
+	target, err := url.Parse(strings.TrimRight(endpoint, "/") + "/" + escapePath(bucket) + "/" + escapePath(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage endpoint: %w", err)
+	}
+	return target, nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request, signing its host, its x-amz and
+// x-goog headers, its content type and its body's hash
+func (s *S3) sign(request *http.Request, body []byte, region string, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signed := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || strings.HasPrefix(lower, "x-goog-") || lower == "content-type" {
+			signed[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signedHeaders, signature))
+}
+
+// failure describes a response the store refused a request with
+func (s *S3) failure(action, bucket, key string, response *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("failed to %s %s/%s: storage answered %s: %s", action, bucket, key, response.Status, strings.TrimSpace(string(detail)))
+}
+
+// escapePath URI-encodes each segment of key as Signature Version 4 requires, every byte but
+// letters, digits and -_.~, keeping the slashes
+func escapePath(key string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~', c == '/':
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,97 @@
+// Package storage keeps files such as attachments, exports and archives in a pluggable object
+// store: a directory on disk, S3 or an S3-compatible store, or Google Cloud Storage, each kind of
+// file in a bucket of its own so deployments can keep them in the region they must stay in.
+package storage
+
+import (
+	"RoyDental/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kinds of files, each kept in its own bucket
+const (
+	Documents = "documents"
+	Photos    = "photos"
+	Exports   = "exports"
+	Backups   = "backups"
+)
+
+// ErrNotFound is returned for objects the store does not have
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps files by bucket kind and key
+type Store interface {
+	// Put stores content under key, replacing what was there
+	Put(ctx context.Context, bucket, key string, content []byte, contentType string) error
+	// Get returns the content stored under key, or ErrNotFound
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	// Delete removes what is stored under key; keys with nothing stored are not an error
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// New returns the configured store, or nil when files stay in the database
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", config.StorageDatabase:
+		return nil, nil
+	case config.StorageLocal:
+		if cfg.LocalDir == "" {
+			return nil, errors.New("STORAGE_LOCAL_DIR is required for the local backend")
+		}
+		return &Local{Dir: cfg.LocalDir}, nil
+	case config.StorageS3, config.StorageGCS:
+		if cfg.AccessKey == "" || cfg.SecretKey == "" {
+			return nil, fmt.Errorf("STORAGE_ACCESS_KEY and STORAGE_SECRET_KEY are required for the %s backend", cfg.Backend)
+		}
+		switch cfg.Encryption {
+		case "", EncryptionAES256:
+		case EncryptionKMS:
+			if cfg.KMSKey == "" && cfg.Backend == config.StorageGCS {
+				return nil, errors.New("STORAGE_KMS_KEY is required for aws:kms encryption on gcs")
+			}
+		default:
+			return nil, fmt.Errorf("unknown storage encryption %q", cfg.Encryption)
+		}
+		if cfg.Backend == config.StorageGCS && cfg.Encryption == EncryptionAES256 {
+			return nil, errors.New("Google Cloud Storage always encrypts files with its own keys; set STORAGE_ENCRYPTION to aws:kms with STORAGE_KMS_KEY to use a key of your own")
+		}
+		return &S3{
+			Endpoint:   cfg.Endpoint,
+			AccessKey:  cfg.AccessKey,
+			SecretKey:  cfg.SecretKey,
+			Buckets:    cfg.Buckets,
+			Region:     cfg.Region,
+			Encryption: cfg.Encryption,
+			KMSKey:     cfg.KMSKey,
+			GCS:        cfg.Backend == config.StorageGCS,
+			Client:     &http.Client{Timeout: cfg.Timeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+}
+
+// BucketFor returns the bucket a file of contentType that is otherwise a document goes to: photos
+// for images, documents for the rest
+func BucketFor(contentType string) string {
+	if strings.HasPrefix(contentType, "image/") {
+		return Photos
+	}
+	return Documents
+}
+
+// checkKey rejects keys that could leave their bucket, such as those with .. segments
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
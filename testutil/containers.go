@@ -0,0 +1,73 @@
+// Package testutil runs the API against real Postgres and Redis servers started in Docker, for the
+// integration tests under the integration build tag.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Images of the dependencies, matching the versions deployed next to the API
+const (
+	PostgresImage = "postgres:16-alpine"
+	RedisImage    = "redis:7-alpine"
+)
+
+// startupTimeout bounds how long a container may take to accept connections
+const startupTimeout = 2 * time.Minute
+
+// StartPostgres starts an empty Postgres server and returns its connection string.
+func StartPostgres(ctx context.Context) (testcontainers.Container, string, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        PostgresImage,
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "roydental",
+				"POSTGRES_PASSWORD": "roydental",
+				"POSTGRES_DB":       "roydental",
+			},
+			// Postgres restarts once after running its init scripts, so wait for the second start
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(startupTimeout),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start Postgres: %w", err)
+	}
+
+	endpoint, err := container.PortEndpoint(ctx, "5432/tcp", "")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, "", fmt.Errorf("failed to get Postgres endpoint: %w", err)
+	}
+	return container, fmt.Sprintf("postgres://roydental:roydental@%s/roydental?sslmode=disable", endpoint), nil
+}
+
+// StartRedis starts an empty Redis server and returns its URL.
+func StartRedis(ctx context.Context) (testcontainers.Container, string, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        RedisImage,
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections").WithStartupTimeout(startupTimeout),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start Redis: %w", err)
+	}
+
+	endpoint, err := container.PortEndpoint(ctx, "6379/tcp", "redis")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, "", fmt.Errorf("failed to get Redis endpoint: %w", err)
+	}
+	return container, endpoint, nil
+}
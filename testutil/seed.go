@@ -0,0 +1,244 @@
+package testutil
+
+import (
+	"RoyDental/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// seedBatchSize is the number of rows inserted per statement
+const seedBatchSize = 500
+
+// SeedConfig sets how much data Seed generates
+type SeedConfig struct {
+	Doctors                int   // Doctors the appointments and bills are spread over
+	Patients               int   // Patients, each with one emergency contact
+	AppointmentsPerPatient int   // Appointments of every patient
+	BillingsPerPatient     int   // Bills of every patient
+	Months                 int   // Records are created over this many months up to now
+	RandomSeed             int64 // Seed of the generator, so a run can be repeated
+}
+
+// DefaultSeedConfig returns a clinic of a few years with a few thousand patients.
+func DefaultSeedConfig() SeedConfig {
+	return SeedConfig{
+		Doctors:                10,
+		Patients:               5000,
+		AppointmentsPerPatient: 4,
+		BillingsPerPatient:     3,
+		Months:                 24,
+		RandomSeed:             1,
+	}
+}
+
+// SeedResult counts the records Seed inserted
+type SeedResult struct {
+	Run          string // Prefix of the IDs of this run
+	Doctors      int
+	Patients     int
+	Appointments int
+	Billings     int
+	PatientIDs   []string
+}
+
+var (
+	seedFirstNames = []string{"Amani", "Baraka", "Wanjiru", "Otieno", "Akinyi", "Kipchoge", "Njeri", "Mwangi", "Achieng", "Kamau", "Zawadi", "Juma", "Halima", "Mutua", "Nafula", "Omondi"}
+	seedLastNames  = []string{"Odhiambo", "Kariuki", "Wambui", "Kiprono", "Chebet", "Ndungu", "Onyango", "Wekesa", "Muthoni", "Barasa", "Kosgei", "Njoroge"}
+	seedInsurers   = []string{"Jubilee", "AAR", "Britam", "CIC", "Madison"}
+	seedTowns      = []struct{ city, county string }{
+		{"Nairobi", "Nairobi"}, {"Westlands", "Nairobi"}, {"Kiambu", "Kiambu"}, {"Thika", "Kiambu"},
+		{"Machakos", "Machakos"}, {"Kajiado", "Kajiado"}, {"Nakuru", "Nakuru"},
+	}
+	seedProcedures = []struct {
+		name   string
+		amount float64
+	}{
+		{"Consultation", 1500}, {"Scaling and polishing", 4500}, {"Filling", 6000}, {"Extraction", 3500},
+		{"Root canal", 18000}, {"Crown", 35000}, {"X-ray", 1200}, {"Whitening", 25000},
+	}
+	seedStatuses = []string{
+		models.AppointmentStatusFulfilled, models.AppointmentStatusFulfilled, models.AppointmentStatusFulfilled,
+		models.AppointmentStatusCancelled, models.AppointmentStatusScheduled,
+	}
+)
+
+// Seed inserts generated doctors, patients, appointments and bills straight into the database, for
+// load tests and benchmarks. Every run uses IDs of its own, so it can add to a database that is in use.
+// It bypasses the repositories, so cached lists are stale until they expire or are invalidated.
+func Seed(ctx context.Context, db *gorm.DB, cfg SeedConfig) (*SeedResult, error) {
+	if cfg.Doctors <= 0 || cfg.Patients <= 0 {
+		return nil, fmt.Errorf("seeding needs at least one doctor and one patient")
+	}
+	if cfg.Months <= 0 {
+		cfg.Months = 1
+	}
+
+	rnd := rand.New(rand.NewSource(cfg.RandomSeed))
+	now := time.Now()
+	result := &SeedResult{Run: "LT" + strconv.FormatInt(now.UnixNano()%1e10, 36)}
+	db = db.WithContext(ctx)
+
+	// createdAt picks a time within the seeded months, during opening hours
+	createdAt := func() time.Time {
+		t := now.AddDate(0, 0, -rnd.Intn(cfg.Months*30))
+		return time.Date(t.Year(), t.Month(), t.Day(), 8+rnd.Intn(9), rnd.Intn(60), 0, 0, t.Location())
+	}
+
+	doctors := make([]models.Doctor, cfg.Doctors)
+	for i := range doctors {
+		doctors[i] = models.Doctor{
+			ID:        fmt.Sprintf("%s-D%04d", result.Run, i+1),
+			FirstName: seedFirstNames[rnd.Intn(len(seedFirstNames))],
+			LastName:  seedLastNames[rnd.Intn(len(seedLastNames))],
+			Specialty: models.SpecialtyGeneral,
+		}
+	}
+	if err := db.CreateInBatches(doctors, seedBatchSize).Error; err != nil {
+		return nil, fmt.Errorf("failed to seed doctors: %w", err)
+	}
+	result.Doctors = len(doctors)
+
+	// Patients are inserted in batches with their records, so memory stays flat at any volume
+	for start := 0; start < cfg.Patients; start += seedBatchSize {
+		end := min(start+seedBatchSize, cfg.Patients)
+
+		var (
+			patients     []models.Patient
+			contacts     []models.EmergencyContact
+			appointments []models.Appointment
+			billings     []models.Billing
+			payments     []models.Payment
+		)
+		for i := start; i < end; i++ {
+			patient := seedPatient(rnd, fmt.Sprintf("%s-P%07d", result.Run, i+1), createdAt())
+			patients = append(patients, patient)
+			contacts = append(contacts, models.EmergencyContact{
+				PatientID:    patient.ID,
+				Name:         seedFirstNames[rnd.Intn(len(seedFirstNames))] + " " + patient.LastName,
+				Phone:        fmt.Sprintf("+2547%08d", rnd.Intn(1e8)),
+				Relationship: models.RelationshipParent,
+			})
+
+			for j := 0; j < cfg.AppointmentsPerPatient; j++ {
+				at := createdAt()
+				startsAt := at.AddDate(0, 0, 7).UTC().Truncate(time.Minute)
+				endsAt := startsAt.Add(30 * time.Minute)
+				appointments = append(appointments, models.Appointment{
+					PatientID: patient.ID,
+					DoctorID:  doctors[rnd.Intn(len(doctors))].ID,
+					DateTime:  models.StoredAppointmentTime(startsAt),
+					Status:    seedStatuses[rnd.Intn(len(seedStatuses))],
+					Origin:    models.AppointmentOriginBooked,
+					StartsAt:  &startsAt,
+					EndsAt:    &endsAt,
+					CreatedAt: at,
+				})
+			}
+			for j := 0; j < cfg.BillingsPerPatient; j++ {
+				procedure := seedProcedures[rnd.Intn(len(seedProcedures))]
+				billing := models.Billing{
+					BillingID:     fmt.Sprintf("%s-B%07d-%d", result.Run, i+1, j+1),
+					PatientID:     patient.ID,
+					DoctorID:      doctors[rnd.Intn(len(doctors))].ID,
+					Procedure:     procedure.name,
+					BillingAmount: procedure.amount,
+					CreatedAt:     createdAt(),
+				}
+				// Most bills are settled, by cash or the insurer, and some are left part paid
+				paid := procedure.amount
+				if rnd.Intn(5) == 0 {
+					paid = float64(rnd.Intn(int(procedure.amount)/100)) * 100
+				}
+				if patient.Insured {
+					billing.PaidInsuranceAmount = paid
+				} else {
+					billing.PaidCashAmount = paid
+				}
+				billing.TotalReceived = paid
+				billing.Balance = procedure.amount - paid
+				billings = append(billings, billing)
+				if paid > 0 {
+					method := models.PaymentMethodCash
+					if patient.Insured {
+						method = models.PaymentMethodInsurance
+					}
+					payments = append(payments, models.Payment{BillingID: billing.BillingID, Method: method, Amount: paid, CreatedAt: billing.CreatedAt})
+				}
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.CreateInBatches(patients, seedBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to seed patients: %w", err)
+			}
+			if err := tx.CreateInBatches(contacts, seedBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to seed emergency contacts: %w", err)
+			}
+			if len(appointments) > 0 {
+				if err := tx.CreateInBatches(appointments, seedBatchSize).Error; err != nil {
+					return fmt.Errorf("failed to seed appointments: %w", err)
+				}
+			}
+			if len(billings) > 0 {
+				if err := tx.CreateInBatches(billings, seedBatchSize).Error; err != nil {
+					return fmt.Errorf("failed to seed billings: %w", err)
+				}
+			}
+			if len(payments) > 0 {
+				if err := tx.CreateInBatches(payments, seedBatchSize).Error; err != nil {
+					return fmt.Errorf("failed to seed payments: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Patients += len(patients)
+		result.Appointments += len(appointments)
+		result.Billings += len(billings)
+		for _, patient := range patients {
+			result.PatientIDs = append(result.PatientIDs, patient.ID)
+		}
+	}
+	return result, nil
+}
+
+func seedPatient(rnd *rand.Rand, id string, createdAt time.Time) models.Patient {
+	first := seedFirstNames[rnd.Intn(len(seedFirstNames))]
+	last := seedLastNames[rnd.Intn(len(seedLastNames))]
+	town := seedTowns[rnd.Intn(len(seedTowns))]
+	born := time.Date(1940+rnd.Intn(80), time.Month(1+rnd.Intn(12)), 1+rnd.Intn(28), 0, 0, 0, 0, time.UTC)
+
+	patient := models.Patient{
+		ID:          id,
+		FirstName:   first,
+		LastName:    last,
+		Sex:         []string{"Male", "Female"}[rnd.Intn(2)],
+		DateOfBirth: born.Format("2006-01-02"),
+		Phone:       fmt.Sprintf("+2547%08d", rnd.Intn(1e8)),
+		Email:       fmt.Sprintf("%s.%s.%d@example.com", first, last, rnd.Intn(1e6)),
+		Address: models.Address{
+			Street:     fmt.Sprintf("P.O. Box %d", 100+rnd.Intn(9900)),
+			City:       town.city,
+			County:     town.county,
+			PostalCode: fmt.Sprintf("%05d", 100*rnd.Intn(900)),
+		},
+		CreatedAt: createdAt,
+	}
+	if rnd.Intn(3) == 0 {
+		patient.Insured = true
+		patient.InsuranceCompany = seedInsurers[rnd.Intn(len(seedInsurers))]
+		patient.Scheme = "Corporate"
+		patient.CoverLimit = float64(50000 + 10000*rnd.Intn(20))
+	} else {
+		patient.Cash = true
+	}
+	return patient
+}
@@ -0,0 +1,251 @@
+package testutil
+
+import (
+	"RoyDental/cache"
+	"RoyDental/config"
+	"RoyDental/database"
+	"RoyDental/models"
+	"RoyDental/repositories"
+	"RoyDental/routes"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"gorm.io/gorm"
+)
+
+// BearerToken is the API bearer token the test server expects
+const BearerToken = "integration-test-bearer-token"
+
+// testSymmetricKey signs the access tokens issued by the test server
+const testSymmetricKey = "integration-test-symmetric-key!!"
+
+// rateLimitRetries bounds how often a request refused by the rate limiter is sent again
+const rateLimitRetries = 20
+
+var idSequence atomic.Int64
+
+// Env is the API served on a local port against its own Postgres and Redis.
+type Env struct {
+	URL    string
+	DB     *gorm.DB
+	Cache  *cache.Cache
+	server *httptest.Server
+	client *http.Client
+
+	containers []testcontainers.Container
+}
+
+// Start starts Postgres and Redis, migrates and seeds the database as the server does on start and
+// serves every route. The routes subscribe to the process-wide event bus, so start one Env per
+// test binary, from TestMain.
+func Start(ctx context.Context) (*Env, error) {
+	env := &Env{client: &http.Client{Timeout: 30 * time.Second}}
+
+	postgres, dbURL, err := StartPostgres(ctx)
+	if err != nil {
+		return nil, err
+	}
+	env.containers = append(env.containers, postgres)
+
+	redis, redisURL, err := StartRedis(ctx)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	env.containers = append(env.containers, redis)
+
+	if err := env.serve(ctx, dbURL, redisURL); err != nil {
+		env.Close()
+		return nil, err
+	}
+	return env, nil
+}
+
+// serve configures the application from the environment, as cmd/main does, with the containers
+// in place of the configured servers.
+func (e *Env) serve(ctx context.Context, dbURL, redisURL string) error {
+	settings := map[string]string{
+		"DB_URL":        dbURL,
+		"REDIS_URL":     redisURL,
+		"REDIS_MODE":    "single",
+		"BEARER_TOKEN":  BearerToken,
+		"SYMMETRIC_KEY": testSymmetricKey,
+	}
+	for name, value := range settings {
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	cfg, err := config.LoadAppConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	database.SetTimeouts(cfg.Timeouts)
+	repositories.SetIDFormats(cfg.IDFormats)
+	if err := database.SetLockProvider(cfg.LockProvider); err != nil {
+		return fmt.Errorf("failed to configure lock provider: %w", err)
+	}
+	clinicLocation, err := cfg.Scheduling.Location()
+	if err != nil {
+		return err
+	}
+	models.SetClinicLocation(clinicLocation)
+
+	if e.DB, err = database.InitDB(ctx, cfg.DBURL); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if err := database.InitializeRedis(); err != nil {
+		return err
+	}
+
+	cache.SetTTLs(cfg.CacheTTLs)
+	cache.SetKeyConfig(cfg.CacheKeys)
+	if err := cache.SetCodec(cfg.CacheCodec); err != nil {
+		return fmt.Errorf("failed to configure cache codec: %w", err)
+	}
+	if e.Cache, err = cache.NewCache(); err != nil {
+		return fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
+	handler, err := routes.SetupRoutes(e.Cache, cfg, e.DB)
+	if err != nil {
+		return fmt.Errorf("failed to set up routes: %w", err)
+	}
+	e.server = httptest.NewServer(handler)
+	e.URL = e.server.URL
+	return nil
+}
+
+// Close stops the server and removes the containers.
+func (e *Env) Close() {
+	if e.server != nil {
+		e.server.Close()
+	}
+	database.CloseDB()
+	if database.RedisClient != nil {
+		database.RedisClient.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, container := range e.containers {
+		if err := container.Terminate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to remove container: %v\n", err)
+		}
+	}
+}
+
+// Request sends body as JSON to path with the bearer token, and with accessToken when one is given,
+// decodes the response into out when it is not nil and returns the status code. Requests the rate
+// limiter refuses are sent again after a pause.
+func (e *Env) Request(t testing.TB, method, path, accessToken string, body, out interface{}) int {
+	t.Helper()
+	return e.request(t, method, path, accessToken, "Bearer "+BearerToken, body, out)
+}
+
+// RequestWithoutBearer sends a request like Request but without the API bearer token.
+func (e *Env) RequestWithoutBearer(t testing.TB, method, path string, out interface{}) int {
+	t.Helper()
+	return e.request(t, method, path, "", "", nil, out)
+}
+
+func (e *Env) request(t testing.TB, method, path, accessToken, authorization string, body, out interface{}) int {
+	t.Helper()
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+	}
+	if accessToken != "" {
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		path += separator + "accessToken=" + url.QueryEscape(accessToken)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, e.URL+path, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response of %s %s: %v", method, path, err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < rateLimitRetries {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		if out != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, out); err != nil {
+				t.Fatalf("failed to decode response of %s %s (%d): %v\n%s", method, path, resp.StatusCode, err, data)
+			}
+		}
+		return resp.StatusCode
+	}
+}
+
+// SignIn registers a user with role and returns an access token for them.
+func (e *Env) SignIn(t testing.TB, role string) string {
+	t.Helper()
+
+	var roleID int64
+	if err := e.DB.Model(&models.Role{}).Where("name = ?", role).Pluck("id", &roleID).Error; err != nil || roleID == 0 {
+		t.Fatalf("failed to find role %s: %v", role, err)
+	}
+
+	name := NewID(strings.ToLower(role))
+	user := map[string]interface{}{
+		"username": name,
+		"email":    name + "@example.com",
+		"password": "Integr4tion!",
+		"role_id":  roleID,
+	}
+	if status := e.Request(t, http.MethodPost, "/auth/register", "", user, nil); status != http.StatusCreated {
+		t.Fatalf("failed to register %s: status %d", name, status)
+	}
+
+	var tokens struct {
+		AccessToken string `json:"accessToken"`
+	}
+	credentials := map[string]string{"email": user["email"].(string), "password": user["password"].(string)}
+	if status := e.Request(t, http.MethodPost, "/auth/login", "", credentials, &tokens); status != http.StatusOK {
+		t.Fatalf("failed to sign in %s: status %d", name, status)
+	}
+	return tokens.AccessToken
+}
+
+// NewID returns an ID with prefix that no other call in the process returns, for records the
+// tests create with client-chosen IDs.
+func NewID(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano()%1e9, idSequence.Add(1))
+}
@@ -0,0 +1,85 @@
+// Package transcription turns dictated audio notes into text through a pluggable provider: a webhook
+// in front of any speech-to-text service. Without one, staff transcribe the notes by hand.
+package transcription
+
+import (
+	"RoyDental/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Audio is a recording to transcribe
+type Audio struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	Language    string // Language the note was dictated in, e.g. "en"
+}
+
+// Provider transcribes audio notes
+type Provider interface {
+	// Name identifies the provider on the notes it transcribed
+	Name() string
+	// Transcribe returns the text of a recording
+	Transcribe(ctx context.Context, audio Audio) (string, error)
+}
+
+// New returns the configured provider, or nil when notes are transcribed by hand
+func New(cfg config.TranscriptionConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("TRANSCRIPTION_URL is required for the webhook provider")
+		}
+		return &Webhook{URL: cfg.URL, Token: cfg.Token, Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown transcription provider %q", cfg.Provider)
+}
+
+// Webhook posts the recording as the request body, with its type as the Content-Type and its
+// language in the X-Language header, and expects {"text": "..."}
+type Webhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+func (w *Webhook) Transcribe(ctx context.Context, audio Audio) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(audio.Content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", audio.ContentType)
+	if audio.Language != "" {
+		req.Header.Set("X-Language", audio.Language)
+	}
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription webhook returned %s", resp.Status)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode transcription webhook response: %w", err)
+	}
+	return result.Text, nil
+}
@@ -1,11 +1,21 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// CSRFCookieName is the cookie holding the double-submit CSRF token; scripts must be able to read it
+	CSRFCookieName = "csrfToken"
+	// CSRFHeaderName is the header cookie-authenticated clients echo the CSRF token in
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
 func SetAuthCookies(c *gin.Context, accessToken, refreshToken string) {
 	setCookie(c, "accessToken", accessToken, AccessTokenExpiry)
 	setCookie(c, "refreshToken", refreshToken, RefreshTokenExpiry)
@@ -16,12 +26,33 @@ func setCookie(c *gin.Context, name, value string, expiry time.Duration) {
 	if gin.Mode() == gin.DebugMode { // Toggle for local dev
 		secure = false
 	}
+	c.SetSameSite(http.SameSiteLaxMode)
 	c.SetCookie(name, value, int(expiry.Seconds()), "/", "", secure, true)
 }
 
+// GenerateCSRFToken returns a random token for double-submit CSRF protection.
+func GenerateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetCSRFCookie stores the CSRF token in a cookie readable by the client's scripts.
+func SetCSRFCookie(c *gin.Context, token string) {
+	secure := true
+	if gin.Mode() == gin.DebugMode { // Toggle for local dev
+		secure = false
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(CSRFCookieName, token, int(RefreshTokenExpiry.Seconds()), "/", "", secure, false)
+}
+
 func ClearAuthCookies(c *gin.Context) {
 	clearCookie(c, "accessToken")
 	clearCookie(c, "refreshToken")
+	clearCookie(c, CSRFCookieName)
 }
 
 func clearCookie(c *gin.Context, name string) {
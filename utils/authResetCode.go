@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// ResetCodeTTL is how long a reset code can be used
+const ResetCodeTTL = 15 * time.Minute
+
 // GenerateResetCode generates a random 6-digit reset code.
 func GenerateResetCode() string {
 	rand.Seed(time.Now().UnixNano())
@@ -21,7 +24,7 @@ func SetResetCode(ctx context.Context, email, code string) error {
 		return err
 	}
 	// Use the Cache's Set method
-	return cacheInstance.Set(ctx, "reset_code:"+email, code, 15*time.Minute)
+	return cacheInstance.Set(ctx, ResetCodeKey(email), code, ResetCodeTTL)
 }
 
 // GetResetCode retrieves the reset code for a given email from Redis.
@@ -31,7 +34,7 @@ func GetResetCode(ctx context.Context, email string) (*string, error) {
 		return nil, err
 	}
 	// Use the Cache's Get method
-	code, err := cacheInstance.Get(ctx, "reset_code:"+email)
+	code, err := cacheInstance.Get(ctx, ResetCodeKey(email))
 	if err != nil {
 		return nil, err
 	}
@@ -48,5 +51,10 @@ func DeleteResetCode(ctx context.Context, email string) error {
 		return err
 	}
 	// Use the Cache's Delete method
-	return cacheInstance.Delete(ctx, "reset_code:"+email)
+	return cacheInstance.Delete(ctx, ResetCodeKey(email))
+}
+
+// ResetCodeKey returns the key the reset code of email is kept under
+func ResetCodeKey(email string) string {
+	return "reset_code:" + email
 }
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"RoyDental/notifications"
 	"log"
 	"os"
 	"strconv"
@@ -79,5 +80,9 @@ func SendResetCodeEmail(email, code string) error {
 
 	// Create the dialer with the retrieved configuration
 	d := gomail.NewDialer(smtpHost, smtpPort, smtpUser, smtpPass)
-	return d.DialAndSend(m)
+	if err := d.DialAndSend(m); err != nil {
+		notifications.MailFailures.Inc("password_reset")
+		return err
+	}
+	return nil
 }
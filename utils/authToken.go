@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"RoyDental/clock"
 	"errors"
 	"fmt"
 	"log"
@@ -16,11 +17,63 @@ const (
 	RefreshTokenExpiry = 7 * 24 * time.Hour
 )
 
-// TokenClaims struct represents the data in the token (UserID, Role, Expiry).
+// Token audiences: the kind of client a token was issued to. Each route group accepts the audiences
+// of the clients it serves, so a token leaked from a kiosk cannot call staff or Admin endpoints
+// whatever role its account was given.
+const (
+	AudienceStaff         = "staff"
+	AudiencePatientPortal = "patient-portal"
+	AudienceKiosk         = "kiosk"
+	AudienceIntegration   = "integration"
+)
+
+// ErrInsufficientPermissions is returned when a valid token does not carry a required role.
+var ErrInsufficientPermissions = errors.New("insufficient permissions")
+
+// ErrInvalidAudience is returned when a token is asked for an audience its role may not have.
+var ErrInvalidAudience = errors.New("invalid token audience")
+
+// TokenClaims struct represents the data in the token (UserID, Role, Audience, Expiry).
 type TokenClaims struct {
-	UserID string    `json:"userId"`
-	Role   string    `json:"role"`
-	Expiry time.Time `json:"expiry"`
+	UserID   string    `json:"userId"`
+	Role     string    `json:"role"`
+	Audience string    `json:"aud,omitempty"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// Scope returns the audience of the token. Tokens issued before audiences existed carry none and
+// belong to the default audience of their role.
+func (c *TokenClaims) Scope() string {
+	if c.Audience != "" {
+		return c.Audience
+	}
+	return DefaultAudience(c.Role)
+}
+
+// DefaultAudience returns the audience a user of role signs in to: patients to the patient portal,
+// insurers' claims officers as an integration and staff to the staff application
+func DefaultAudience(role string) string {
+	switch role {
+	case "Patient":
+		return AudiencePatientPortal
+	case "Insurer":
+		return AudienceIntegration
+	}
+	return AudienceStaff
+}
+
+// TokenAudience returns the audience of a token issued to a user of role, the role's default when
+// audience is empty. Staff may sign in a kiosk with a token scoped to it; no role may widen its
+// audience.
+func TokenAudience(role, audience string) (string, error) {
+	defaultAudience := DefaultAudience(role)
+	switch {
+	case audience == "", audience == defaultAudience:
+		return defaultAudience, nil
+	case audience == AudienceKiosk && defaultAudience == AudienceStaff:
+		return audience, nil
+	}
+	return "", fmt.Errorf("%w: %s tokens are not issued to role %s", ErrInvalidAudience, audience, role)
 }
 
 // GetSymmetricKey retrieves the symmetric key from the environment variable.
@@ -33,17 +86,18 @@ func GetSymmetricKey() []byte {
 	return []byte(key)
 }
 
-// GenerateTokens generates both the access token and refresh token for the given user ID and role.
-func GenerateTokens(userID, role string) (accessToken, refreshToken string, err error) {
+// GenerateTokens generates both the access token and refresh token for the given user ID, role and
+// audience.
+func GenerateTokens(userID, role, audience string) (accessToken, refreshToken string, err error) {
 	// Generate the access token
-	accessToken, err = generatePASEToken(userID, role, AccessTokenExpiry)
+	accessToken, err = generatePASEToken(userID, role, audience, AccessTokenExpiry)
 	if err != nil {
 		log.Printf("Error generating access token: %v", err)
 		return "", "", err
 	}
 
 	// Generate the refresh token
-	refreshToken, err = generatePASEToken(userID, role, RefreshTokenExpiry)
+	refreshToken, err = generatePASEToken(userID, role, audience, RefreshTokenExpiry)
 	if err != nil {
 		log.Printf("Error generating refresh token: %v", err)
 		return "", "", err
@@ -53,8 +107,8 @@ func GenerateTokens(userID, role string) (accessToken, refreshToken string, err
 }
 
 // GenerateAccessToken generates only the access token for a user.
-func GenerateAccessToken(userID, role string) (string, error) {
-	token, err := generatePASEToken(userID, role, AccessTokenExpiry)
+func GenerateAccessToken(userID, role, audience string) (string, error) {
+	token, err := generatePASEToken(userID, role, audience, AccessTokenExpiry)
 	if err != nil {
 		log.Printf("Error generating access token: %v", err)
 		return "", err
@@ -62,13 +116,14 @@ func GenerateAccessToken(userID, role string) (string, error) {
 	return token, nil
 }
 
-// generatePASEToken generates a PASETO token for the given user ID, role, and expiry duration.
-func generatePASEToken(userID, role string, expiry time.Duration) (string, error) {
+// generatePASEToken generates a PASETO token for the given user ID, role, audience and expiry duration.
+func generatePASEToken(userID, role, audience string, expiry time.Duration) (string, error) {
 	// Create token claims
 	claims := TokenClaims{
-		UserID: userID,
-		Role:   role,
-		Expiry: time.Now().Add(expiry),
+		UserID:   userID,
+		Role:     role,
+		Audience: audience,
+		Expiry:   clock.Now().Add(expiry),
 	}
 
 	// Encrypt the token using the symmetric key
@@ -89,7 +144,7 @@ func ValidateToken(tokenString string, requiredRoles ...string) (*TokenClaims, e
 	}
 
 	// Check if the token has expired
-	if time.Now().After(claims.Expiry) {
+	if clock.Now().After(claims.Expiry) {
 		log.Printf("Token expired: %v", claims)
 		return nil, errors.New("token expired")
 	}
@@ -108,7 +163,7 @@ func ValidateToken(tokenString string, requiredRoles ...string) (*TokenClaims, e
 
 	// Log role mismatch for debugging purposes
 	log.Printf("Insufficient permissions. Required roles: %v, found role: %v", requiredRoles, claims.Role)
-	return nil, errors.New("insufficient permissions")
+	return nil, ErrInsufficientPermissions
 }
 
 // parseToken decrypts the token and extracts claims from it.
@@ -0,0 +1,52 @@
+// Package zpl writes labels in ZPL II, the command language of Zebra and compatible label printers:
+// lines of text, Code 128 barcodes and QR codes placed in dots from the top left of the label.
+package zpl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Label is a ZPL label being laid out
+type Label struct {
+	fields bytes.Buffer
+}
+
+// New returns an empty label
+func New() *Label {
+	return &Label{}
+}
+
+// Text writes a line of text at x, y with the printer's default scalable font, height dots tall
+func (l *Label) Text(x, y, height int, text string) {
+	fmt.Fprintf(&l.fields, "^FO%d,%d^A0N,%d,%d^FD%s^FS\n", x, y, height, height, escape(text))
+}
+
+// Barcode writes data as a Code 128 barcode at x, y, height dots tall, with the data printed below it
+func (l *Label) Barcode(x, y, height int, data string) {
+	fmt.Fprintf(&l.fields, "^FO%d,%d^BY2,3,%d^BCN,%d,Y,N,N^FD%s^FS\n", x, y, height, height, escape(data))
+}
+
+// QRCode writes data as a QR code at error correction level M at x, y, each module magnification
+// dots wide
+func (l *Label) QRCode(x, y, magnification int, data string) {
+	fmt.Fprintf(&l.fields, "^FO%d,%d^BQN,2,%d^FDMA,%s^FS\n", x, y, magnification, escape(data))
+}
+
+// Bytes returns the label's ZPL, printed copies times
+func (l *Label) Bytes(copies int) []byte {
+	if copies < 1 {
+		copies = 1
+	}
+	var out bytes.Buffer
+	out.WriteString("^XA\n^CI28\n")
+	out.Write(l.fields.Bytes())
+	fmt.Fprintf(&out, "^PQ%d\n^XZ\n", copies)
+	return out.Bytes()
+}
+
+// escape drops the characters that start ZPL commands, which would end the field early
+func escape(text string) string {
+	return strings.NewReplacer("^", "", "~", "", "\n", " ", "\r", "").Replace(text)
+}